@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"pandacea/agent-backend/internal/api"
+	"pandacea/agent-backend/internal/buildinfo"
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/remotecfg"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// command is an operations subcommand, run as agent <name> [flags] [args].
+// Running agent with flags alone starts the agent.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string, out io.Writer) error
+}
+
+// commands returns the subcommands
+func commands() []command {
+	return []command{
+		{"keygen", "keygen [-out file] [-type ed25519|secp256k1|rsa]", "Create the agent's P2P identity key", runKeygen},
+		{"products", "products list|add [flags]", "List the product catalog, or add a product to it", runProducts},
+		{"lease", "lease status [-api url] <leaseProposalId>", "Show a lease proposal's status from a running agent", runLease},
+		{"doctor", "doctor [-config file]", "Check the agent can reach Docker, IPFS and its RPC endpoints", runDoctor},
+		{"encryption", "encryption status|encrypt|rotate [-keyring-out file]", "Show, apply or rotate encryption at rest of assets and artifacts", runEncryption},
+		{"version", "version [-api url]", "Print the agent's version, and a running agent's", runVersion},
+	}
+}
+
+// isCommand reports whether args start with a subcommand rather than flags
+func isCommand(args []string) bool {
+	return len(args) > 0 && !strings.HasPrefix(args[0], "-")
+}
+
+// runCommand runs the subcommand named by args[0] and returns the exit code
+func runCommand(args []string, out, errOut io.Writer) int {
+	if args[0] == "help" {
+		printCommands(out)
+		return 0
+	}
+	for _, c := range commands() {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], out)
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "agent %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(errOut, "agent: unknown command %q\n", args[0])
+	printCommands(errOut)
+	return 2
+}
+
+// printCommands lists the subcommands
+func printCommands(out io.Writer) {
+	fmt.Fprintln(out, "Usage: agent [flags]      start the agent (agent -h lists its flags)")
+	fmt.Fprintln(out, "       agent <command>    run an operations command")
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %s\t%s\n", c.usage, c.summary)
+	}
+	w.Flush()
+}
+
+// commandFlags creates the flag set of a subcommand, with the -config and
+// -profile flags every subcommand takes
+func commandFlags(name string, out io.Writer) (*flag.FlagSet, func() (*config.Config, error)) {
+	fs := flag.NewFlagSet("agent "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	configPath := fs.String("config", "", "Path to configuration file")
+	profile := fs.String("profile", "", "Deployment profile: dev, staging or production (default $PANDACEA_PROFILE or dev)")
+	return fs, func() (*config.Config, error) {
+		return config.Load(*configPath, *profile)
+	}
+}
+
+// apiFlags adds the flags of subcommands that talk to a running agent. The
+// returned function makes the client once the flags are parsed; requests are
+// signed with the key file, which defaults to the agent's own identity.
+func apiFlags(fs *flag.FlagSet, load func() (*config.Config, error)) func() (*apiClient, error) {
+	base := fs.String("api", "", "Base URL of the running agent (default http://127.0.0.1:<server.port>)")
+	keyFile := fs.String("key", "", "libp2p key file requests are signed with (default p2p.key_file_path)")
+	return func() (*apiClient, error) {
+		cfg, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if *base == "" {
+			*base = "http://127.0.0.1:" + strconv.Itoa(cfg.Server.Port)
+		}
+		if *keyFile == "" {
+			*keyFile = cfg.P2P.KeyFilePath
+		}
+		key, err := p2p.ReadIdentity(*keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("requests are signed with a key file: %w", err)
+		}
+		return &apiClient{base: strings.TrimSuffix(*base, "/"), key: key, http: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+}
+
+// apiClient makes signed requests to a running agent's /api/v1
+type apiClient struct {
+	base string
+	key  crypto.PrivKey
+	http *http.Client
+}
+
+// get fetches path under /api/v1 and decodes the JSON response into v
+func (c *apiClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/api/v1"+path, nil)
+	if err != nil {
+		return err
+	}
+	if err := reqsig.Sign(c.key, req, nil, time.Now()); err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr api.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return fmt.Errorf("agent answered %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode the agent's response: %w", err)
+	}
+	return nil
+}
+
+// runKeygen creates the P2P identity key, refusing to replace one
+func runKeygen(args []string, out io.Writer) error {
+	fs, load := commandFlags("keygen", out)
+	path := fs.String("out", "", "Key file to create (default p2p.key_file_path)")
+	keyType := fs.String("type", "", "Key type: ed25519, secp256k1 or rsa (default p2p.key_type)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" || *keyType == "" {
+		cfg, err := load()
+		if err != nil {
+			return err
+		}
+		if *path == "" {
+			*path = cfg.P2P.KeyFilePath
+		}
+		if *keyType == "" {
+			*keyType = cfg.P2P.KeyType
+		}
+	}
+
+	priv, err := p2p.CreateIdentity(*path, *keyType)
+	if err != nil {
+		return err
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+	fmt.Fprintf(out, "created %s identity at %s\n", p2p.KeyType(priv), *path)
+	fmt.Fprintf(out, "peer ID: %s\n", id)
+	return nil
+}
+
+// runProducts lists or adds to the product catalog
+func runProducts(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: agent products list|add [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runProductsList(args[1:], out)
+	case "add":
+		return runProductsAdd(args[1:], out)
+	default:
+		return fmt.Errorf("unknown products command %q (want list or add)", args[0])
+	}
+}
+
+// runProductsList prints the catalog in products.json, or the one a running
+// agent serves if -api is set
+func runProductsList(args []string, out io.Writer) error {
+	fs, load := commandFlags("products list", out)
+	productsFile := fs.String("products", "products.json", "Product catalog to read when -api is not set")
+	client := apiFlags(fs, load)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var products []api.DataProduct
+	if apiSet(fs) {
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		var response api.ProductsResponse
+		if err := c.get(context.Background(), "/products", &response); err != nil {
+			return err
+		}
+		products = response.Data
+	} else {
+		var err error
+		if products, err = readProducts(*productsFile); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT ID\tNAME\tDATA TYPE\tKEYWORDS")
+	for _, p := range products {
+		name := p.Name
+		if p.Quarantined {
+			name += " (quarantined)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.ProductID, name, p.DataType, strings.Join(p.Keywords, ","))
+	}
+	return w.Flush()
+}
+
+// runProductsAdd appends a product to products.json. A catalog signature
+// next to the file is renewed with -signing-key, as it no longer matches.
+func runProductsAdd(args []string, out io.Writer) error {
+	fs, _ := commandFlags("products add", out)
+	productsFile := fs.String("products", "products.json", "Product catalog to add to")
+	id := fs.String("id", "", "Product ID, e.g. did:pandacea:earner:123/abc-456")
+	name := fs.String("name", "", "Product name")
+	dataType := fs.String("type", "", "Data type, e.g. RoboticSensorData")
+	keywords := fs.String("keywords", "", "Comma-separated search keywords")
+	signingKey := fs.String("signing-key", "", "libp2p key file to re-sign the catalog with, if it is signed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" || *name == "" || *dataType == "" {
+		return fmt.Errorf("-id, -name and -type are required")
+	}
+
+	products, err := readProducts(*productsFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if slices.ContainsFunc(products, func(p api.DataProduct) bool { return p.ProductID == *id }) {
+		return fmt.Errorf("product %s is already in %s", *id, *productsFile)
+	}
+	product := api.DataProduct{ProductID: *id, Name: *name, DataType: *dataType, Keywords: []string{}}
+	for _, keyword := range strings.Split(*keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			product.Keywords = append(product.Keywords, keyword)
+		}
+	}
+	products = append(products, product)
+
+	content, err := json.MarshalIndent(products, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode products: %w", err)
+	}
+	content = append(content, '\n')
+	if err := os.WriteFile(*productsFile, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *productsFile, err)
+	}
+	fmt.Fprintf(out, "added %s to %s (%d products)\n", *id, *productsFile, len(products))
+
+	sigPath := *productsFile + ".sig"
+	switch {
+	case *signingKey != "":
+		priv, err := p2p.ReadIdentity(*signingKey)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %w", err)
+		}
+		sig, err := remotecfg.Sign(priv, remoteProducts, signingVersion(), content)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(sigPath, []byte(sig+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write signature: %w", err)
+		}
+		fmt.Fprintf(out, "re-signed %s: %s\n", *productsFile, sigPath)
+	case fileExists(sigPath):
+		fmt.Fprintf(out, "%s no longer matches the catalog; re-sign it with -signing-key or agent -sign-config\n", sigPath)
+	}
+	fmt.Fprintln(out, "reload the agent with SIGHUP, or restart it, to serve the new product")
+	return nil
+}
+
+// readProducts reads a products.json catalog
+func readProducts(path string) ([]api.DataProduct, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var products []api.DataProduct
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return products, nil
+}
+
+// runLease shows a lease proposal's status from a running agent
+func runLease(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "status" {
+		return fmt.Errorf("usage: agent lease status [-api url] <leaseProposalId>")
+	}
+	fs, load := commandFlags("lease status", out)
+	client := apiFlags(fs, load)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: agent lease status [-api url] <leaseProposalId>")
+	}
+	c, err := client()
+	if err != nil {
+		return err
+	}
+
+	var state api.LeaseProposalState
+	if err := c.get(context.Background(), "/leases/"+fs.Arg(0), &state); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "status:\t%s\n", state.Status)
+	fmt.Fprintf(w, "product:\t%s\n", state.ProductID)
+	if state.LeaseID != nil {
+		fmt.Fprintf(w, "lease ID:\t%d\n", *state.LeaseID)
+	}
+	if state.Price != nil {
+		fmt.Fprintf(w, "price:\t%s\n", *state.Price)
+	}
+	if state.Duration != "" {
+		fmt.Fprintf(w, "duration:\t%s\n", state.Duration)
+	}
+	if state.SpenderAddr != "" {
+		fmt.Fprintf(w, "spender:\t%s\n", state.SpenderAddr)
+	}
+	if state.EarnerAddr != "" {
+		fmt.Fprintf(w, "earner:\t%s\n", state.EarnerAddr)
+	}
+	fmt.Fprintf(w, "created:\t%s\n", state.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "updated:\t%s\n", state.UpdatedAt.Format(time.RFC3339))
+	if state.ExpiresAt != nil {
+		fmt.Fprintf(w, "expires:\t%s\n", state.ExpiresAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// runVersion prints the agent's version and, with -api, a running agent's
+func runVersion(args []string, out io.Writer) error {
+	fs, load := commandFlags("version", out)
+	client := apiFlags(fs, load)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	build := buildinfo.Get()
+	fmt.Fprintf(out, "agent %s (%s, %s/%s)\n", build.Version, build.GoVersion, runtime.GOOS, runtime.GOARCH)
+	if build.Commit != "" {
+		fmt.Fprintf(out, "commit %s, built %s\n", build.Commit, build.Date)
+	}
+	if !apiSet(fs) {
+		return nil
+	}
+	c, err := client()
+	if err != nil {
+		return err
+	}
+	var running api.VersionResponse
+	if err := c.get(context.Background(), "/version", &running); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "running agent at %s: %s (commit %s), API %s, profile %s, training mode %s\n",
+		c.base, running.Version, running.Commit, running.APIVersion, running.Profile, running.ExecutionMode)
+	return nil
+}
+
+// apiSet reports whether -api was given
+func apiSet(fs *flag.FlagSet) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == "api" })
+	return set
+}
+
+// check is one dependency doctor looks at
+type check struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// runDoctor checks the dependencies the configuration needs and prints one
+// line per check. It fails if any check fails.
+func runDoctor(args []string, out io.Writer) error {
+	fs, load := commandFlags("doctor", out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := load()
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  config       %v\n", err)
+		return fmt.Errorf("configuration is invalid")
+	}
+	fmt.Fprintf(out, "ok    config       profile %s\n", cfg.Profile)
+
+	failed := 0
+	for _, c := range doctorChecks(cfg) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		detail, err := c.run(ctx)
+		cancel()
+		status := "ok"
+		if err != nil {
+			status, detail = "FAIL", err.Error()
+			failed++
+		}
+		fmt.Fprintf(out, "%-4s  %-11s  %s\n", status, c.name, detail)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// doctorChecks returns the checks cfg calls for
+func doctorChecks(cfg *config.Config) []check {
+	checks := []check{{"identity", func(context.Context) (string, error) {
+		if cfg.P2P.KeyFilePath == "" {
+			return "p2p.key_file_path is unset; the agent starts with a new peer ID each time", nil
+		}
+		priv, err := p2p.ReadIdentity(cfg.P2P.KeyFilePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return "no key at " + cfg.P2P.KeyFilePath + "; one is created on first start or with agent keygen", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		id, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s key, peer ID %s", p2p.KeyType(priv), id), nil
+	}}}
+
+	switch sandbox := cfg.Pool.Sandbox; sandbox.Backend {
+	case "", privacy.SandboxDocker, privacy.SandboxGVisor:
+		checks = append(checks, check{"docker", func(ctx context.Context) (string, error) {
+			server, err := commandOutput(ctx, "docker", "version", "--format", "{{.Server.Version}}")
+			if err != nil {
+				return "", err
+			}
+			if sandbox.Backend != privacy.SandboxGVisor {
+				return "server " + server, nil
+			}
+			runtimes, err := commandOutput(ctx, "docker", "info", "--format", "{{json .Runtimes}}")
+			if err != nil {
+				return "", err
+			}
+			if !strings.Contains(runtimes, `"runsc"`) {
+				return "", fmt.Errorf("docker %s has no runsc runtime for the gvisor sandbox", server)
+			}
+			return "server " + server + " with runsc", nil
+		}})
+	case privacy.SandboxFirecracker:
+		checks = append(checks, check{"ignite", func(ctx context.Context) (string, error) {
+			return commandOutput(ctx, "ignite", "version", "-o", "short")
+		}})
+	case privacy.SandboxWASM:
+		checks = append(checks, check{"wasmtime", func(ctx context.Context) (string, error) {
+			for command, module := range sandbox.Modules {
+				if _, err := os.Stat(module); err != nil {
+					return "", fmt.Errorf("module for %s: %w", command, err)
+				}
+			}
+			return commandOutput(ctx, "wasmtime", "--version")
+		}})
+	}
+
+	checks = append(checks, check{"ipfs", func(ctx context.Context) (string, error) {
+		apiURL := cfg.IPFS.APIURL
+		if apiURL == "" {
+			apiURL = "http://127.0.0.1:5001"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(apiURL, "/")+"/api/v0/version", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var v struct{ Version string }
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&v) != nil {
+			return "", fmt.Errorf("%s answered %s", apiURL, resp.Status)
+		}
+		return "kubo " + v.Version + " at " + apiURL, nil
+	}})
+
+	for _, n := range cfg.Blockchain.AllNetworks() {
+		checks = append(checks, check{"rpc " + n.Name, func(ctx context.Context) (string, error) {
+			client, err := dialRPC(ctx, n.RPCURL)
+			if err != nil {
+				return "", err
+			}
+			defer client.Close()
+			if err := chain.CheckChainID(ctx, client, n.ChainID); err != nil {
+				return "", err
+			}
+			head, err := client.BlockNumber(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("block %d at %s", head, n.RPCURL), nil
+		}})
+	}
+
+	if cfg.ScriptScan.Enabled {
+		checks = append(checks, check{"python", func(ctx context.Context) (string, error) {
+			return commandOutput(ctx, cfg.ScriptScan.Python, "--version")
+		}})
+	}
+	return checks
+}
+
+// commandOutput runs a command and returns its trimmed output
+func commandOutput(ctx context.Context, name string, args ...string) (string, error) {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(output.String()); detail != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, detail)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimSpace(output.String()), nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/config"
+)
+
+// keyringEnv holds the keyring's JSON when a secrets manager injects it
+const keyringEnv = "PANDACEA_ATREST_KEYRING"
+
+// loadKeyring returns the keyring encryption at rest uses: the one in
+// $PANDACEA_ATREST_KEYRING if it is set, otherwise the keyring file, which
+// is generated if create is set and it does not exist yet
+func loadKeyring(cfg config.EncryptionConfig, create bool) (*atrest.Keyring, error) {
+	if stored := os.Getenv(keyringEnv); stored != "" {
+		k, err := atrest.ParseKeyring([]byte(stored))
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", keyringEnv, err)
+		}
+		return k, nil
+	}
+	if create {
+		return atrest.LoadOrCreateKeyring(cfg.KeyringFile)
+	}
+	return atrest.LoadKeyring(cfg.KeyringFile)
+}
+
+// runEncryption runs agent encryption status|encrypt|rotate
+func runEncryption(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: agent encryption status|encrypt|rotate [flags]")
+	}
+	switch args[0] {
+	case "status":
+		return runEncryptionStatus(args[1:], out)
+	case "encrypt":
+		return runEncryptionEncrypt(args[1:], out)
+	case "rotate":
+		return runEncryptionRotate(args[1:], out)
+	default:
+		return fmt.Errorf("unknown encryption command %q (want status, encrypt or rotate)", args[0])
+	}
+}
+
+// runEncryptionStatus prints the keyring's keys and how many files each
+// one wraps
+func runEncryptionStatus(args []string, out io.Writer) error {
+	fs, load := commandFlags("encryption status", out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	k, err := loadKeyring(cfg.Encryption, false)
+	if err != nil {
+		return err
+	}
+	files, err := encryptionFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	wrapped := make(map[string]int)
+	plaintext := 0
+	for _, path := range files {
+		id, encrypted, err := atrest.KeyID(path)
+		if err != nil {
+			return err
+		}
+		if !encrypted {
+			plaintext++
+			continue
+		}
+		wrapped[id]++
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY ID\tFILES\t")
+	for _, id := range k.IDs() {
+		current := ""
+		if id == k.Current() {
+			current = "current"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", id, wrapped[id], current)
+		delete(wrapped, id)
+	}
+	for id, n := range wrapped {
+		fmt.Fprintf(w, "%s\t%d\tnot in keyring\n", id, n)
+	}
+	fmt.Fprintf(w, "plaintext\t%d\t\n", plaintext)
+	return w.Flush()
+}
+
+// runEncryptionEncrypt encrypts the files that are still plaintext
+func runEncryptionEncrypt(args []string, out io.Writer) error {
+	fs, load := commandFlags("encryption encrypt", out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	k, err := loadKeyring(cfg.Encryption, true)
+	if err != nil {
+		return err
+	}
+	files, err := encryptionFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, path := range files {
+		encrypted, err := k.EncryptFile(path)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			count++
+		}
+	}
+	fmt.Fprintf(out, "encrypted %d of %d files with key %s\n", count, len(files), k.Current())
+	return nil
+}
+
+// runEncryptionRotate adds a new master key, rewraps every file's data key
+// with it and drops the keys no file uses any more. The files' contents are
+// not re-encrypted. The keyring is saved with the new key before any file is
+// rewrapped, so an interrupted rotation can be run again.
+func runEncryptionRotate(args []string, out io.Writer) error {
+	fs, load := commandFlags("encryption rotate", out)
+	keyringOut := fs.String("keyring-out", "", "File to write the rotated keyring to (required when it comes from $"+keyringEnv+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	if *keyringOut == "" {
+		if os.Getenv(keyringEnv) != "" {
+			return fmt.Errorf("the keyring comes from $%s; pass -keyring-out and store the rotated keyring in the secrets manager", keyringEnv)
+		}
+		*keyringOut = cfg.Encryption.KeyringFile
+	}
+	k, err := loadKeyring(cfg.Encryption, false)
+	if err != nil {
+		return err
+	}
+	files, err := encryptionFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	current, err := k.Rotate()
+	if err != nil {
+		return err
+	}
+	if err := k.Save(*keyringOut); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "new master key %s\n", current)
+
+	inUse := make(map[string]bool)
+	rewrapped := 0
+	for _, path := range files {
+		ok, err := k.Rewrap(path)
+		if errors.Is(err, atrest.ErrUnknownKey) {
+			// Keep the key the file names, in case it turns up again
+			id, _, _ := atrest.KeyID(path)
+			inUse[id] = true
+			fmt.Fprintf(out, "skipped %s: %v\n", path, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ok {
+			rewrapped++
+		}
+	}
+	retired := k.Retire(inUse)
+	if err := k.Save(*keyringOut); err != nil {
+		return err
+	}
+	if len(retired) == 0 {
+		retired = []string{"none"}
+	}
+	fmt.Fprintf(out, "rewrapped %d files; retired keys: %s\n", rewrapped, strings.Join(retired, ", "))
+	fmt.Fprintf(out, "wrote the keyring to %s; restart the agent to use it\n", *keyringOut)
+	return nil
+}
+
+// encryptionFiles returns the files encryption at rest covers: those under
+// encryption.dirs and the local sources of registered assets. Artifact
+// signatures stay in plaintext, as they are checked by anyone holding the
+// artifact.
+func encryptionFiles(cfg *config.Config) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, dir := range cfg.Encryption.Dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && filepath.Ext(path) != ".sig" {
+				add(filepath.Clean(path))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+	}
+
+	registry, err := assets.NewRegistry(cfg.Assets.RegistryPath, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range registry.List("") {
+		if a.Local() && fileExists(a.Source) {
+			add(filepath.Clean(a.Source))
+		}
+	}
+	return files, nil
+}
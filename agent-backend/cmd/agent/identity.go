@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/remotecfg"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// runRotateKey replaces the agent's P2P identity with a new key of keyType
+// (empty uses p2p.key_type) and re-signs the product catalog at productsPath
+// with it, so the catalog stays verifiable under the new peer ID. A missing
+// catalog is skipped.
+func runRotateKey(cfg config.P2PConfig, keyType, productsPath string, out io.Writer) error {
+	if keyType == "" {
+		keyType = cfg.KeyType
+	}
+	rotation, err := p2p.RotateIdentity(cfg.KeyFilePath, keyType)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "rotated %s identity: %s -> %s\n", p2p.KeyType(rotation.NewKey), rotation.OldPeerID, rotation.NewPeerID)
+	fmt.Fprintf(out, "old key kept at %s\n", rotation.BackupPath)
+
+	if productsPath != "" {
+		content, err := os.ReadFile(productsPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Fprintf(out, "no product catalog at %s, skipped re-signing\n", productsPath)
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", productsPath, err)
+		default:
+			sig, err := remotecfg.Sign(rotation.NewKey, remoteProducts, signingVersion(), content)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(productsPath+".sig", []byte(sig+"\n"), 0644); err != nil {
+				return fmt.Errorf("failed to write signature: %w", err)
+			}
+			fmt.Fprintf(out, "re-signed %s: %s.sig\n", productsPath, productsPath)
+		}
+	}
+
+	pub, err := crypto.MarshalPublicKey(rotation.NewKey.GetPublic())
+	if err != nil {
+		return fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	fmt.Fprintf(out, "signer key: %s\n", base64.StdEncoding.EncodeToString(pub))
+	fmt.Fprintf(out, "update admin.peer_ids, allow lists and remote.signer_keys that name the old identity\n")
+	return nil
+}
@@ -5,27 +5,85 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"math/big"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"pandacea/agent-backend/internal/api"
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/attest"
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/autoscale"
+	"pandacea/agent-backend/internal/buildinfo"
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/compute"
 	"pandacea/agent-backend/internal/config"
 	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/egress"
+	"pandacea/agent-backend/internal/federation"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/models"
 	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/pinning"
 	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/pricing"
 	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reputation"
+	"pandacea/agent-backend/internal/revocation"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/scriptscan"
 	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/spender"
 	"pandacea/agent-backend/internal/telemetry"
+	"pandacea/agent-backend/internal/tenant"
+	"pandacea/agent-backend/internal/txmgr"
+	"pandacea/agent-backend/internal/usage"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/shopspring/decimal"
 )
 
+// securityConfigPath is where the security service reads its configuration
+const securityConfigPath = "config/security.yaml"
+
+// pinningTokenEnv holds the remote pinning service's access token
+const pinningTokenEnv = "PANDACEA_PINNING_TOKEN"
+
 func main() {
+	// agent <command> runs an operations subcommand instead of the agent
+	if isCommand(os.Args[1:]) {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "", "Path to configuration file")
+	profile := flag.String("profile", "", "Deployment profile: dev, staging or production (default $PANDACEA_PROFILE or dev)")
+	verifyWatermark := flag.String("verify-watermark", "", "Check a suspected leak for watermarks, then exit")
+	watermarkIDs := flag.String("watermark-ids", "", "Comma-separated lease or job IDs to check with --verify-watermark")
+	signConfig := flag.String("sign-config", "", "Write a detached signature for a remote configuration file, then exit")
+	configName := flag.String("config-name", "", "Name the --sign-config file is signed under: products or security")
+	signingKey := flag.String("signing-key", "", "libp2p private key file used by --sign-config (created if missing)")
+	walletKey := flag.String("wallet-key", "", "Hex Ethereum key file --sign-config signs with instead, as personal_sign would")
+	rotateKey := flag.Bool("rotate-key", false, "Replace the agent's P2P identity key and re-sign the product catalog, then exit")
+	keyType := flag.String("key-type", "", "Key type for --rotate-key: ed25519, secp256k1 or rsa (default p2p.key_type)")
+	productsFile := flag.String("products", "products.json", "Product catalog re-signed by --rotate-key")
+	validateConfig := flag.Bool("validate-config", false, "Check the configuration and policy rules, list every problem, then exit")
 	flag.Parse()
 
 	// Configure log level from env
@@ -43,7 +101,24 @@ func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
 	slog.SetDefault(logger)
 
-	logger.Info("starting Pandacea agent backend")
+	if *signConfig != "" {
+		if err := runSignConfig(*signConfig, *configName, *signingKey, *walletKey, os.Stdout); err != nil {
+			logger.Error("failed to sign configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *validateConfig {
+		if err := runValidateConfig(*configPath, *profile, os.Stdout); err != nil {
+			logger.Error("configuration is invalid", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	build := buildinfo.Get()
+	logger.Info("starting Pandacea agent backend", "version", build.Version, "commit", build.Commit, "build_date", build.Date, "go", build.GoVersion)
 
 	// Initialize OpenTelemetry (opt-in via PANDACEA_OTEL=1)
 	shutdownOTEL := func(context.Context) error { return nil }
@@ -57,30 +132,112 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.Load(*configPath, *profile)
 	if err != nil {
 		logger.Error("failed to load configuration", "error", err)
 		os.Exit(1)
 	}
 
+	if *verifyWatermark != "" {
+		matched, err := runVerifyWatermark(cfg.Watermark, *verifyWatermark, *watermarkIDs, os.Stdout)
+		if err != nil {
+			logger.Error("watermark verification failed", "error", err)
+			os.Exit(2)
+		}
+		if !matched {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *rotateKey {
+		if err := runRotateKey(cfg.P2P, *keyType, *productsFile, os.Stdout); err != nil {
+			logger.Error("failed to rotate identity key", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger.Info("configuration loaded",
+		"profile", cfg.Profile,
+		"training_execution_mode", cfg.Training.ExecutionMode,
 		"http_port", cfg.Server.Port,
 		"p2p_port", cfg.P2P.ListenPort,
 	)
+	if cfg.Training.ExecutionMode == config.ExecutionModeMock {
+		logger.Warn("training.execution_mode is mock: training and computation results are synthetic")
+	}
+	if cfg.Profile == config.ProfileStaging {
+		for _, hazard := range cfg.Hazards() {
+			logger.Warn("configuration would be refused in production", "hazard", hazard)
+		}
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialize policy engine
-	policyEngine, err := policy.NewEngine(logger, cfg.Server) // Pass the whole ServerConfig
+	// Price floors follow the contract's MIN_PRICE and recent demand
+	pricer, err := pricing.NewPricer(logger, cfg.Server.MinPrice, cfg.Pricing)
+	if err != nil {
+		logger.Error("failed to initialize pricer", "error", err)
+		os.Exit(1)
+	}
+	// Connect to every configured LeaseAgreement deployment, refusing to
+	// start if an endpoint serves a different chain than configured
+	networks := cfg.Blockchain.AllNetworks()
+	ethClients, err := dialNetworks(ctx, networks, logger)
+	if err != nil {
+		logger.Error("failed to connect to blockchain networks", "error", err)
+		os.Exit(1)
+	}
+	defer closeClients(ethClients)
+	readers := make(map[string]*contractReader, len(networks))
+	for _, n := range networks {
+		if readers[n.Name], err = newContractReader(ctx, ethClients[n.Name], n.ContractAddress, logger.With("network", n.Name)); err != nil {
+			logger.Error("failed to initialize on-chain contract reader", "error", err, "network", n.Name)
+			os.Exit(1)
+		}
+	}
+	defaultNetwork, hasNetwork := cfg.Blockchain.Network("")
+	var minPriceReader pricing.MinPriceReader
+	if hasNetwork {
+		minPriceReader = readers[defaultNetwork.Name]
+	}
+	go pricer.Run(ctx, minPriceReader, time.Duration(cfg.Pricing.RefreshSeconds)*time.Second)
+
+	// Initialize policy engine, replaceable when the configuration is reloaded
+	evaluator, err := policy.NewEvaluator(ctx, logger, cfg, pricer)
 	if err != nil {
 		logger.Error("failed to initialize policy engine", "error", err)
 		os.Exit(1)
 	}
+	policyEngine := policy.NewReloadable(evaluator)
 
-	// Initialize P2P node
-	p2pNode, err := p2p.NewNode(ctx, cfg.P2P.ListenPort, cfg.P2P.KeyFilePath, logger)
+	// Initialize P2P node, refusing denied and low-reputation peers
+	gater, err := p2p.NewGater(p2p.GaterConfig{
+		MinScore: cfg.P2P.MinPeerScore,
+		HalfLife: time.Duration(cfg.P2P.ScoreHalfLifeMinutes) * time.Minute,
+		Allow:    cfg.P2P.AllowPeers,
+		Deny:     cfg.P2P.DenyPeers,
+		Path:     cfg.P2P.PeersPath,
+	}, logger)
+	if err != nil {
+		logger.Error("failed to initialize peer gater", "error", err)
+		os.Exit(1)
+	}
+	p2pNode, err := p2p.NewNode(ctx, p2p.NodeConfig{
+		ListenPort:     cfg.P2P.ListenPort,
+		KeyFilePath:    cfg.P2P.KeyFilePath,
+		KeyType:        cfg.P2P.KeyType,
+		Transports:     cfg.P2P.Transports,
+		ListenAddrs:    cfg.P2P.ListenAddrs,
+		BootstrapPeers: cfg.P2P.BootstrapPeers,
+		StaticRelays:   cfg.P2P.StaticRelays,
+		HolePunching:   cfg.P2P.HolePunching,
+		RelayService:   cfg.P2P.RelayService,
+		Gater:          gater,
+	}, logger)
 	if err != nil {
 		logger.Error("failed to initialize P2P node", "error", err)
 		os.Exit(1)
@@ -91,40 +248,98 @@ func main() {
 		}
 	}()
 
-	// Initialize privacy service if blockchain configuration is provided
+	// Job state lives outside ./data, which is copied into computation containers
+	jobStateDir := os.Getenv("JOB_STATE_DIR")
+	if jobStateDir == "" {
+		jobStateDir = "./state/jobs"
+	}
+
+	// Initialize privacy service if blockchain configuration is provided.
+	// Leases are verified on the default network unless a request or its
+	// product names another.
 	var privacyService privacy.PrivacyService
-	if cfg.Blockchain.RPCURL != "" && cfg.Blockchain.ContractAddress != "" {
-		// Connect to Ethereum client
-		ethClient, err := ethclient.Dial(cfg.Blockchain.RPCURL)
+	if hasNetwork {
+		contractAddress := common.HexToAddress(defaultNetwork.ContractAddress)
+		dataDir := "./data"           // Default data directory
+		poolSize := cfg.Pool.Size     // Containers started with the agent
+		ipfsAPIURL := cfg.IPFS.APIURL // Get IPFS API URL from config
+		computationStore, err := jobs.NewFileStore(filepath.Join(jobStateDir, "computation"))
 		if err != nil {
-			logger.Error("failed to connect to Ethereum client", "error", err)
+			logger.Error("failed to initialize computation job store", "error", err)
 			os.Exit(1)
 		}
-		defer ethClient.Close()
-
-		// Create privacy service
-		contractAddress := common.HexToAddress(cfg.Blockchain.ContractAddress)
-		dataDir := "./data"           // Default data directory
-		poolSize := 3                 // Default pool size
-		ipfsAPIURL := cfg.IPFS.APIURL // Get IPFS API URL from config
-		privacyService, err = privacy.NewPrivacyService(logger, ethClient, contractAddress, dataDir, poolSize, ipfsAPIURL)
+		privacyService, err = privacy.NewPrivacyService(logger, ethClients[defaultNetwork.Name], contractAddress, dataDir, poolSize, ipfsAPIURL, computationStore)
 		if err != nil {
 			logger.Error("failed to initialize privacy service", "error", err)
 			os.Exit(1)
 		}
+		sandbox := cfg.Pool.Sandbox
+		if policy := sandbox.ImagePolicy; policy.Enabled {
+			pinned, err := privacy.ImagePolicy{
+				Allowed:   policy.Allowed,
+				CosignKey: policy.CosignKey,
+				Cosign:    policy.Cosign,
+			}.Verify(ctx, sandbox.Image)
+			if err != nil {
+				logger.Error("sandbox image failed policy checks; refusing to start the container pool", "error", err, "image", sandbox.Image)
+				os.Exit(1)
+			}
+			logger.Info("sandbox image verified", "image", pinned, "signature_checked", policy.CosignKey != "")
+			sandbox.Image = pinned
+		}
+		runtime, err := privacy.NewSandbox(privacy.SandboxOptions{
+			Backend:       sandbox.Backend,
+			Image:         sandbox.Image,
+			MemoryMB:      sandbox.MemoryMB,
+			CPUs:          sandbox.CPUs,
+			KernelImage:   sandbox.KernelImage,
+			WorkDir:       sandbox.WorkDir,
+			Modules:       sandbox.Modules,
+			EgressNetwork: sandbox.Egress.Network,
+		})
+		if err != nil {
+			logger.Error("failed to initialize computation sandbox", "error", err, "backend", sandbox.Backend)
+			os.Exit(1)
+		}
+		if runner, ok := privacyService.(privacy.ContainerRunner); ok {
+			runner.UseContainerRuntime(runtime)
+		}
+		if scan := cfg.ScriptScan; scan.Enabled {
+			products := make(map[string]scriptscan.Policy, len(scan.Products))
+			for productID, p := range scan.Products {
+				products[productID] = scriptPolicy(p)
+			}
+			scanner, err := scriptscan.NewScanner(scan.Python, scriptPolicy(scan.ScriptPolicyConfig), products)
+			if err != nil {
+				logger.Error("failed to initialize script scanning", "error", err)
+				os.Exit(1)
+			}
+			if s, ok := privacyService.(privacy.ScriptScanner); ok {
+				s.UseScriptScanner(scanner)
+			}
+			logger.Info("computation script scanning enabled", "products", len(products))
+		}
+		if registry, ok := privacyService.(privacy.NetworkRegistry); ok {
+			for _, n := range networks {
+				if err := registry.AddNetwork(n.Name, ethClients[n.Name], common.HexToAddress(n.ContractAddress)); err != nil {
+					logger.Error("failed to register blockchain network", "error", err, "network", n.Name)
+					os.Exit(1)
+				}
+			}
+		}
 
 		// Start the privacy service
 		if err := privacyService.Start(); err != nil {
 			logger.Error("failed to start privacy service", "error", err)
 			os.Exit(1)
 		}
-		logger.Info("privacy service started", "contract_address", cfg.Blockchain.ContractAddress, "pool_size", poolSize)
+		logger.Info("privacy service started", "network", defaultNetwork.Name, "contract_address", defaultNetwork.ContractAddress, "pool_size", poolSize, "sandbox", sandbox.Backend)
 	} else {
 		logger.Warn("blockchain configuration not provided, privacy service disabled")
 	}
 
 	// Initialize security service
-	securityService, err := security.NewSecurityService("config/security.yaml", logger)
+	securityService, err := security.NewSecurityService(securityConfigPath, logger)
 	if err != nil {
 		logger.Error("failed to initialize security service", "error", err)
 		os.Exit(1)
@@ -134,6 +349,400 @@ func main() {
 	// Initialize API server
 	apiServer := api.NewServer(policyEngine, logger, p2pNode, privacyService, securityService)
 
+	trainingStore, err := jobs.NewFileStore(filepath.Join(jobStateDir, "training"))
+	if err != nil {
+		logger.Error("failed to initialize training job store", "error", err)
+		os.Exit(1)
+	}
+	if err := apiServer.SetJobStore(trainingStore); err != nil {
+		logger.Error("failed to restore training jobs", "error", err)
+		os.Exit(1)
+	}
+
+	// Reputation scores persist next to job state and feed lease policy decisions
+	reputationFile := os.Getenv("REPUTATION_STATE_FILE")
+	if reputationFile == "" {
+		reputationFile = "./state/reputation.json"
+	}
+	reputationTracker, err := reputation.NewTracker(cfg.Server.ReputationWeight, cfg.Server.ReputationDecayRate, reputationFile)
+	if err != nil {
+		logger.Error("failed to initialize reputation tracker", "error", err)
+		os.Exit(1)
+	}
+	apiServer.SetReputationTracker(reputationTracker)
+	apiServer.SetPricer(pricer)
+	apiServer.SetHTTPConfig(cfg.HTTP)
+	apiServer.SetProfile(cfg.Profile, cfg.Hardening)
+	apiServer.SetTrainingConfig(cfg.Training)
+	apiServer.SetCompressionConfig(cfg.Server.Compression)
+	apiServer.SetCORSConfig(cfg.Server.CORS)
+	apiServer.SetLegacyRoutes(cfg.Server.LegacyRoutes)
+	if cfg.Audit.JournalPath != "" {
+		journal, err := audit.OpenJournal(cfg.Audit.JournalPath)
+		if err != nil {
+			logger.Error("failed to open audit journal", "error", err, "path", cfg.Audit.JournalPath)
+			os.Exit(1)
+		}
+		defer journal.Close()
+		if err := apiServer.SetAuditJournal(journal); err != nil {
+			logger.Error("failed to load audit journal", "error", err, "path", cfg.Audit.JournalPath)
+			os.Exit(1)
+		}
+		logger.Info("audit journal opened", "path", cfg.Audit.JournalPath)
+	}
+	if cfg.Watermark.Enabled {
+		marker, err := newWatermarker(cfg.Watermark)
+		if err != nil {
+			logger.Error("failed to initialize watermarking", "error", err)
+			os.Exit(1)
+		}
+		apiServer.SetWatermarker(marker)
+		logger.Info("result watermarking enabled")
+	}
+	var keyring *atrest.Keyring
+	if cfg.Encryption.Enabled {
+		if keyring, err = loadKeyring(cfg.Encryption, true); err != nil {
+			logger.Error("failed to load encryption keyring", "error", err)
+			os.Exit(1)
+		}
+		apiServer.SetKeyring(keyring)
+		logger.Info("encryption at rest enabled", "key_id", keyring.Current())
+	}
+	budgetLedger, err := privacy.NewBudgetLedger(cfg.Privacy.DefaultEpsilon, cfg.Privacy.DatasetEpsilon, cfg.Privacy.LedgerPath)
+	if err != nil {
+		logger.Error("failed to initialize privacy budget ledger", "error", err, "path", cfg.Privacy.LedgerPath)
+		os.Exit(1)
+	}
+	apiServer.SetBudgetLedger(budgetLedger)
+	assignments, err := privacy.NewAssignmentRegistry(cfg.Server.LeaseAssignmentsPath)
+	if err != nil {
+		logger.Error("failed to restore lease assignments", "error", err, "path", cfg.Server.LeaseAssignmentsPath)
+		os.Exit(1)
+	}
+	apiServer.SetLeaseAssignments(assignments)
+	earningsLedger, err := earnings.NewLedger(cfg.Server.RoyaltyPercentage, cfg.Earnings.LedgerPath)
+	if err != nil {
+		logger.Error("failed to restore earnings ledger", "error", err, "path", cfg.Earnings.LedgerPath)
+		os.Exit(1)
+	}
+	apiServer.SetEarnings(earningsLedger)
+	var tenants *tenant.Registry
+	if len(cfg.Tenants) > 0 {
+		tenants = tenant.NewRegistry(cfg.Tenants)
+		apiServer.SetTenants(tenants)
+		logger.Info("hosting tenants", "count", len(cfg.Tenants))
+	}
+	disputes, err := dispute.NewStore(cfg.Disputes.RecordsPath)
+	if err != nil {
+		logger.Error("failed to restore dispute records", "error", err, "path", cfg.Disputes.RecordsPath)
+		os.Exit(1)
+	}
+	apiServer.SetDisputes(disputes, cfg.IPFS.APIURL)
+	modelRegistry, err := models.NewRegistry(cfg.Models.RegistryPath)
+	if err != nil {
+		logger.Error("failed to restore model registry", "error", err, "path", cfg.Models.RegistryPath)
+		os.Exit(1)
+	}
+	apiServer.SetModels(modelRegistry)
+	lineageGraph, err := lineage.NewGraph(cfg.Lineage.GraphPath)
+	if err != nil {
+		logger.Error("failed to restore lineage graph", "error", err, "path", cfg.Lineage.GraphPath)
+		os.Exit(1)
+	}
+	apiServer.SetLineage(lineageGraph)
+	apiServer.SetConsent(cfg.Consent)
+	usageStore, err := usage.NewStore(cfg.Usage.RecordsPath)
+	if err != nil {
+		logger.Error("failed to restore usage", "error", err, "path", cfg.Usage.RecordsPath)
+		os.Exit(1)
+	}
+	apiServer.SetUsage(usageStore)
+	if cfg.Metering.Enabled {
+		rates, err := metering.ParseRates(cfg.Metering.CPUSecondPrice, cfg.Metering.MemoryGBHourPrice, cfg.Metering.WallSecondPrice)
+		if err != nil {
+			logger.Error("invalid metering prices", "error", err)
+			os.Exit(1)
+		}
+		meteringLedger, err := metering.NewLedger(cfg.Metering.LedgerPath)
+		if err != nil {
+			logger.Error("failed to restore metering ledger", "error", err, "path", cfg.Metering.LedgerPath)
+			os.Exit(1)
+		}
+		apiServer.SetMetering(rates, meteringLedger)
+		logger.Info("job metering enabled", "cpu_second_price", cfg.Metering.CPUSecondPrice, "memory_gb_hour_price", cfg.Metering.MemoryGBHourPrice, "wall_second_price", cfg.Metering.WallSecondPrice)
+	}
+	if cfg.Usage.RecordsPath != "" {
+		go usageStore.Run(ctx, time.Duration(cfg.Usage.SaveSeconds)*time.Second, func(err error) {
+			logger.Error("failed to save usage", "error", err)
+		})
+	}
+	if cfg.Verification.Fraction > 0 {
+		apiServer.SetVerification(cfg.Verification.Fraction, cfg.Verification.Escalate)
+		logger.Info("computation result verification enabled", "fraction", cfg.Verification.Fraction, "escalate", cfg.Verification.Escalate)
+	}
+	apiServer.SetBlockchain(cfg.Blockchain)
+	if hasNetwork {
+		reader := readers[defaultNetwork.Name]
+		apiServer.SetContract(reader.deployment, reader)
+	}
+	var transactions *txmgr.Manager
+	if cfg.Transactions.KeyFile != "" {
+		if !hasNetwork {
+			logger.Error("transactions.key_file is set but no blockchain network is configured")
+			os.Exit(1)
+		}
+		manager, err := newTransactionManager(ctx, cfg.Transactions, ethClients[defaultNetwork.Name], logger)
+		if err != nil {
+			logger.Error("failed to initialize transaction manager", "error", err)
+			os.Exit(1)
+		}
+		go manager.Run(ctx)
+		transactions = manager
+		apiServer.SetTransactionManager(manager, common.HexToAddress(defaultNetwork.ContractAddress))
+		logger.Info("transaction sending enabled", "network", defaultNetwork.Name, "address", manager.Address().Hex())
+		if len(cfg.Delivery.Sources) > 0 {
+			deliveries, err := delivery.NewStore(cfg.Delivery.RecordsPath)
+			if err != nil {
+				logger.Error("failed to restore deliveries", "error", err, "path", cfg.Delivery.RecordsPath)
+				os.Exit(1)
+			}
+			preparer := delivery.NewPreparer(cfg.IPFS.APIURL, cfg.Delivery.Sources)
+			preparer.UseKeyring(keyring)
+			apiServer.SetDelivery(deliveries, preparer,
+				readers[defaultNetwork.Name], time.Duration(cfg.Delivery.WaitSeconds)*time.Second)
+			logger.Info("lease delivery enabled", "products", len(cfg.Delivery.Sources))
+		}
+	}
+	if cfg.Attestation.Enabled {
+		attestations, err := attest.NewStore(cfg.Attestation.RecordsPath)
+		if err != nil {
+			logger.Error("failed to restore computation attestations", "error", err, "path", cfg.Attestation.RecordsPath)
+			os.Exit(1)
+		}
+		var anchorTo *common.Address
+		if cfg.Attestation.AnchorAddress != "" {
+			address := common.HexToAddress(cfg.Attestation.AnchorAddress)
+			anchorTo = &address
+		}
+		apiServer.SetAttestations(attestations, cfg.Attestation.Anchor, anchorTo)
+		logger.Info("computation attestations enabled", "anchor", cfg.Attestation.Anchor)
+	}
+	jobScheduler := scheduler.New(cfg.Scheduler.Workers, cfg.Scheduler.MaxQueued, cfg.Scheduler.MaxQueuedPerIdentity, logger)
+	apiServer.SetScheduler(jobScheduler, cfg.Scheduler)
+	var gpuScheduler *scheduler.Scheduler
+	if cfg.GPU.Enabled {
+		info, err := gpu.Detect(ctx)
+		switch {
+		case err != nil:
+			logger.Warn("GPU detection failed, GPU jobs disabled", "error", err)
+		case !info.Available():
+			logger.Warn("no GPUs usable by containers, GPU jobs disabled", "devices", len(info.Devices), "nvidia_runtime", info.Runtime)
+		default:
+			gpuScheduler = scheduler.New(cfg.GPU.Workers, cfg.GPU.MaxQueued, cfg.Scheduler.MaxQueuedPerIdentity, logger)
+			monitor := gpu.NewMonitor(logger)
+			go monitor.Run(ctx, time.Duration(cfg.GPU.SampleSeconds)*time.Second)
+			apiServer.SetGPUs(gpuScheduler, gpu.NewAllocator(info.Indexes()), monitor, info.Indexes())
+			logger.Info("GPU jobs enabled", "devices", gpu.VisibleDevices(info.Indexes()), "workers", cfg.GPU.Workers)
+		}
+	}
+	if scaler, ok := privacyService.(privacy.PoolAutoscaler); ok && cfg.Pool.Autoscale != autoscale.ModeOff {
+		history, err := autoscale.LoadHistory(cfg.Pool.HistoryPath)
+		if err != nil {
+			logger.Error("failed to restore container pool load history", "error", err, "path", cfg.Pool.HistoryPath)
+			os.Exit(1)
+		}
+		scaler.UseLoadHistory(history)
+		controller := autoscale.NewController(history, scaler, autoscale.Config{
+			Mode:     cfg.Pool.Autoscale,
+			MinSize:  cfg.Pool.MinSize,
+			MaxSize:  cfg.Pool.MaxSize,
+			Lead:     time.Duration(cfg.Pool.LeadMinutes) * time.Minute,
+			Headroom: cfg.Pool.Headroom,
+			Interval: time.Duration(cfg.Pool.IntervalSeconds) * time.Second,
+		}, logger)
+		go controller.Run(ctx)
+		apiServer.SetPoolAutoscaler(controller)
+		logger.Info("container pool forecasting enabled", "mode", cfg.Pool.Autoscale)
+	}
+	if cfg.Catalog.Verify || cfg.Catalog.Path != "" {
+		if err := apiServer.SetCatalog(cfg.Catalog, p2pNode.PrivateKey().GetPublic()); err != nil {
+			logger.Error("refusing to serve product catalog", "error", err, "path", apiServer.CatalogPath())
+			os.Exit(1)
+		}
+	}
+	if cfg.Remote.ProductsURL != "" || cfg.Remote.SecurityURL != "" {
+		if err := startRemoteConfig(ctx, cfg.Remote, cfg.IPFS.APIURL, logger, apiServer.SetProducts, securityService.ApplyConfig); err != nil {
+			logger.Error("failed to initialize remote configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Federation.Coordinator || cfg.Federation.Participant {
+		var transport federation.Transport
+		if cfg.Federation.Coordinator {
+			transport = federation.NewP2PTransport(p2pNode.Host())
+		}
+		apiServer.SetFederation(cfg.Federation, transport)
+		if cfg.Federation.Coordinator {
+			registry := federation.NewRegistry(time.Duration(cfg.Federation.ParticipantTTLSeconds)*time.Second, p2pNode)
+			federation.ServeRegistry(p2pNode.Host(), registry, p2pNode, logger)
+			apiServer.SetParticipantRegistry(registry)
+		}
+		if cfg.Federation.Participant {
+			federation.Serve(p2pNode.Host(), apiServer, p2pNode, logger)
+			if len(cfg.Federation.AllowedCoordinators) > 0 {
+				announcer := federation.NewAnnouncer(p2pNode.Host(), cfg.Federation.AllowedCoordinators, apiServer.FederationCapabilities, logger)
+				go announcer.Run(ctx, time.Duration(cfg.Federation.HeartbeatSeconds)*time.Second)
+			}
+		}
+		logger.Info("federated training enabled", "coordinator", cfg.Federation.Coordinator, "participant", cfg.Federation.Participant)
+	}
+	if cfg.P2P.ServeComputations {
+		if privacyService == nil {
+			logger.Error("p2p.serve_computations is set but no blockchain network is configured to verify leases on")
+			os.Exit(1)
+		}
+		compute.Serve(p2pNode.Host(), apiServer, p2pNode, logger)
+		logger.Info("computation requests accepted over libp2p", "protocol", compute.ProtocolID)
+	}
+	if cfg.Market.Enabled {
+		apiServer.SetListingContact(cfg.Market.APIURL, cfg.Market.EarnerAddress)
+		market.Serve(p2pNode.Host(), apiServer, logger)
+		searcher := market.NewSearcher(p2pNode, apiServer, reputationTracker, market.Config{
+			MaxPeers:    cfg.Market.MaxPeers,
+			PeerTimeout: time.Duration(cfg.Market.PeerTimeoutSeconds) * time.Second,
+			CacheTTL:    time.Duration(cfg.Market.CacheTTLSeconds) * time.Second,
+		}, logger)
+		apiServer.SetMarket(searcher)
+		go market.Advertise(ctx, p2pNode, time.Duration(cfg.Market.AdvertiseIntervalMinutes)*time.Minute, logger)
+		logger.Info("network product search enabled")
+
+		if cfg.Spender.Enabled {
+			spenderCfg := spender.Config{
+				Contract:       common.HexToAddress(defaultNetwork.ContractAddress),
+				RequestTimeout: time.Duration(cfg.Spender.RequestTimeoutSeconds) * time.Second,
+			}
+			if cfg.Spender.MaxPrice != "" {
+				maxPrice := decimal.RequireFromString(cfg.Spender.MaxPrice)
+				spenderCfg.MaxPrice = &maxPrice
+			}
+			outbound, err := spender.New(searcher, transactions, p2pNode.PrivateKey(), spenderCfg, cfg.Spender.RecordsPath, logger)
+			if err != nil {
+				logger.Error("failed to restore outbound leases", "error", err, "path", cfg.Spender.RecordsPath)
+				os.Exit(1)
+			}
+			apiServer.SetSpender(outbound)
+			go outbound.Run(ctx, time.Duration(cfg.Spender.PollSeconds)*time.Second)
+			logger.Info("leasing from other agents enabled", "address", transactions.Address().Hex())
+		}
+	}
+	if cfg.Assets.Enabled {
+		registry, err := assets.NewRegistry(cfg.Assets.RegistryPath, cfg.IPFS.APIURL)
+		if err != nil {
+			logger.Error("failed to restore asset registry", "error", err, "path", cfg.Assets.RegistryPath)
+			os.Exit(1)
+		}
+		registry.UseKeyring(keyring)
+		registry.UseStorage(assets.SchemeS3, assets.NewS3Storage(assets.S3Options{
+			Endpoint:        cfg.Assets.S3.Endpoint,
+			Region:          cfg.Assets.S3.Region,
+			PathStyle:       cfg.Assets.S3.PathStyle,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}))
+		apiServer.SetAssets(registry)
+		logger.Info("asset registry enabled", "assets", len(registry.List("")))
+		if preview := cfg.Assets.Preview; preview.Enabled {
+			apiServer.SetPreviews(assets.NewPreviewer(registry, assets.PreviewOptions{
+				Rows:     preview.Rows,
+				Mode:     assets.PreviewMode(preview.Mode),
+				Suppress: preview.SuppressColumns,
+				CacheTTL: time.Duration(preview.CacheSeconds) * time.Second,
+			}), preview.RequestsPerMinute)
+			logger.Info("asset previews enabled", "mode", preview.Mode, "rows", preview.Rows)
+		}
+		if profile := cfg.Assets.Profile; profile.Enabled {
+			if sandbox, ok := privacyService.(privacy.AssetProfiler); ok {
+				apiServer.SetProfiles(assets.NewProfiler(registry, sandbox.ProfileAsset, assets.ProfileOptions{
+					Bins:      profile.Bins,
+					TopValues: profile.TopValues,
+					MinCount:  profile.MinCount,
+					CacheTTL:  time.Duration(profile.CacheSeconds) * time.Second,
+					Timeout:   time.Duration(profile.TimeoutSeconds) * time.Second,
+				}))
+				logger.Info("asset profiles enabled", "bins", profile.Bins, "min_count", profile.MinCount)
+			} else {
+				logger.Warn("asset profiles need the sandboxed privacy service; profiles disabled")
+			}
+		}
+	}
+	if egressCfg := cfg.Pool.Sandbox.Egress; egressCfg.Enabled && privacyService != nil {
+		proxy, err := egress.NewProxy(egressCfg.ProxyURL, logger)
+		if err != nil {
+			logger.Error("failed to initialize egress proxy", "error", err)
+			os.Exit(1)
+		}
+		apiServer.SetEgress(proxy, egress.Policy(egressCfg.Products))
+		go func() {
+			if err := proxy.ListenAndServe(ctx, egressCfg.Listen); err != nil {
+				logger.Error("egress proxy stopped", "error", err, "listen", egressCfg.Listen)
+			}
+		}()
+		logger.Info("computation egress enabled", "network", egressCfg.Network, "products", len(egressCfg.Products))
+	}
+	if cfg.Incident.QuarantinePath != "" {
+		if err := apiServer.SetQuarantineFile(cfg.Incident.QuarantinePath); err != nil {
+			logger.Error("failed to restore product quarantines", "error", err, "path", cfg.Incident.QuarantinePath)
+			os.Exit(1)
+		}
+	}
+	revocations, err := revocation.New(p2pNode.PrivateKey(), cfg.Incident.RevocationIssuers, cfg.Incident.RevocationsPath, logger)
+	if err != nil {
+		logger.Error("failed to restore identity revocations", "error", err, "path", cfg.Incident.RevocationsPath)
+		os.Exit(1)
+	}
+	var revocationGossip *revocation.Gossip
+	if cfg.Incident.GossipRevocations {
+		revocationGossip = revocation.Serve(p2pNode.Host(), revocations, p2pNode, logger)
+		go revocationGossip.Run(ctx, time.Duration(cfg.Incident.GossipIntervalMinutes)*time.Minute)
+		logger.Info("revocation gossip enabled", "protocol", revocation.ProtocolID, "trusted_issuers", len(cfg.Incident.RevocationIssuers))
+	}
+	apiServer.SetRevocations(revocations, revocationGossip)
+
+	// Mark leases expired once their duration has elapsed
+	go apiServer.RunLeaseExpirer(ctx, time.Minute)
+
+	// Publish job outputs to IPFS; retention releases their pins
+	if cfg.Publish.Enabled {
+		var service pinning.Service = pinning.NewNode(cfg.IPFS.APIURL)
+		if cfg.Publish.Service == "remote" {
+			token := os.Getenv(pinningTokenEnv)
+			if token == "" {
+				logger.Error("remote pinning service requires an access token", "env", pinningTokenEnv)
+				os.Exit(1)
+			}
+			service = pinning.NewRemote(pinning.NewNode(cfg.IPFS.APIURL), cfg.Publish.RemoteURL, token)
+		}
+		publisher, err := pinning.New(service, cfg.Publish.PinsPath, logger)
+		if err != nil {
+			logger.Error("failed to restore pins", "error", err, "path", cfg.Publish.PinsPath)
+			os.Exit(1)
+		}
+		apiServer.SetPublisher(publisher, cfg.Publish)
+		go publisher.Run(ctx, time.Duration(cfg.Publish.PollSeconds)*time.Second)
+		if !cfg.Retention.Enabled {
+			logger.Warn("retention is disabled; published pins are never released")
+		}
+		logger.Info("publishing to IPFS enabled", "service", cfg.Publish.Service, "training", cfg.Publish.Training, "computations", cfg.Publish.Computations)
+	}
+
+	// Drop finished jobs and artifacts past their retention
+	if cfg.Retention.Enabled {
+		apiServer.SetRetention(cfg.Retention)
+		go apiServer.RunRetention(ctx, time.Duration(cfg.Retention.IntervalMinutes)*time.Minute)
+		logger.Info("job retention enabled", "max_age_hours", cfg.Retention.MaxAgeHours, "max_total_mb", cfg.Retention.MaxTotalMB, "max_jobs", cfg.Retention.MaxJobs)
+	}
+
 	// Start API server in a goroutine
 	go func() {
 		if err := apiServer.Start(cfg.GetServerAddr()); err != nil {
@@ -142,18 +751,69 @@ func main() {
 		}
 	}()
 
-	// Start blockchain event listener if blockchain configuration is provided
-	if cfg.Blockchain.RPCURL != "" && cfg.Blockchain.ContractAddress != "" {
-		go func() {
-			if err := startEventListener(ctx, cfg, apiServer, logger); err != nil {
-				logger.Error("failed to start blockchain event listener", "error", err)
-				cancel() // Signal shutdown
-			}
-		}()
-	} else {
+	// Leases on the shared contract are booked only if they pay one of the
+	// agent's tenants or its own earner address
+	var earner common.Address
+	if cfg.Market.EarnerAddress != "" {
+		earner = common.HexToAddress(cfg.Market.EarnerAddress)
+	} else if transactions != nil {
+		earner = transactions.Address()
+	}
+	if len(networks) > 0 && tenants == nil && earner == (common.Address{}) {
+		logger.Warn("no tenants or earner address configured, earnings will not be booked; set market.earner_address")
+	}
+
+	// Start a blockchain event listener per network. Readiness stays
+	// not_ready until each listener's first subscription is established.
+	for _, n := range networks {
+		listenerStatus := chain.NewListenerStatus(n.Name, cfg.Blockchain.MaxLagBlocks)
+		apiServer.AddListenerStatus(listenerStatus)
+		listener, err := chain.NewListener(cfg.Blockchain.ForNetwork(n), func(ctx context.Context) (chain.Client, error) {
+			logger.Info("connecting to blockchain", "network", n.Name, "rpc_url", n.RPCURL)
+			return dialRPC(ctx, n.RPCURL)
+		}, apiServer, reputationTracker, listenerStatus, logger)
+		if err != nil {
+			logger.Error("failed to initialize event listener", "error", err, "network", n.Name)
+			os.Exit(1)
+		}
+		listener.SetEarnings(earningsLedger, readers[n.Name])
+		listener.SetDisputes(disputes)
+		if tenants != nil {
+			listener.SetTenants(tenants)
+		}
+		listener.SetEarner(earner)
+		go listener.Run(ctx)
+	}
+	if len(networks) == 0 {
 		logger.Warn("blockchain configuration not provided, skipping event listener")
 	}
 
+	// Reload configuration, security.yaml and policy rules on SIGHUP and,
+	// unless disabled, whenever one of the files changes
+	configReloader := &reloader{
+		configPath:   *configPath,
+		profile:      cfg.Profile,
+		securityPath: securityConfigPath,
+		current:      cfg,
+		pricer:       pricer,
+		policy:       policyEngine,
+		security:     securityService,
+		logger:       logger,
+	}
+	if cfg.Remote.ProductsURL == "" {
+		configReloader.catalog = apiServer
+	}
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	var configChanges <-chan string
+	if cfg.Reload.Watch {
+		if configChanges, err = config.Watch(ctx, configReloader.paths(), logger); err != nil {
+			logger.Error("failed to watch configuration files", "error", err)
+			os.Exit(1)
+		}
+	}
+	go configReloader.run(ctx, configChanges, hupChan)
+
 	// Log startup information
 	logger.Info("agent backend started successfully",
 		"peer_id", p2pNode.GetPeerID(),
@@ -185,6 +845,21 @@ func main() {
 		logger.Error("failed to shutdown API server", "error", err)
 	}
 
+	// Stop starting queued jobs; running ones have until the shutdown deadline
+	schedulerClosed := make(chan struct{})
+	go func() {
+		jobScheduler.Close()
+		if gpuScheduler != nil {
+			gpuScheduler.Close()
+		}
+		close(schedulerClosed)
+	}()
+	select {
+	case <-schedulerClosed:
+	case <-shutdownCtx.Done():
+		logger.Warn("jobs still running at shutdown")
+	}
+
 	// Shutdown privacy service
 	if privacyService != nil {
 		if err := privacyService.Stop(); err != nil {
@@ -192,6 +867,11 @@ func main() {
 		}
 	}
 
+	// Save usage accounted since the last periodic save
+	if err := usageStore.Save(); err != nil {
+		logger.Error("failed to save usage", "error", err)
+	}
+
 	// Shutdown telemetry last
 	if err := shutdownOTEL(context.Background()); err != nil {
 		logger.Error("failed to shutdown telemetry", "error", err)
@@ -200,83 +880,154 @@ func main() {
 	logger.Info("agent backend shutdown complete")
 }
 
-// startEventListener starts listening for blockchain events
-func startEventListener(ctx context.Context, cfg *config.Config, apiServer *api.Server, logger *slog.Logger) error {
-	logger.Info("connecting to blockchain", "rpc_url", cfg.Blockchain.RPCURL)
+// contractReader reads MIN_PRICE and lease terms from the LeaseAgreement
+// contract, through the binding of the interface version it speaks
+type contractReader struct {
+	caller     *contracts.LeaseAgreementCaller
+	v2         *contracts.LeaseAgreementV2Caller
+	deployment contracts.Deployment
+}
 
-	// Connect to the Ethereum client
-	client, err := ethclient.Dial(cfg.Blockchain.RPCURL)
-	if err != nil {
-		return err
+// scriptPolicy converts a configured script policy
+func scriptPolicy(c config.ScriptPolicyConfig) scriptscan.Policy {
+	return scriptscan.Policy{
+		Deny:     c.DenyModules,
+		Allow:    c.AllowModules,
+		MaxBytes: c.MaxBytes,
+		MaxNodes: c.MaxNodes,
+		MaxDepth: c.MaxDepth,
 	}
-	defer client.Close()
+}
 
-	// Create contract instance
-	contractAddress := common.HexToAddress(cfg.Blockchain.ContractAddress)
-	contract, err := contracts.NewLeaseAgreement(contractAddress, client)
+// dialNetworks connects to each network's RPC endpoint and checks it
+// serves the configured chain
+func dialNetworks(ctx context.Context, networks []config.NetworkConfig, logger *slog.Logger) (map[string]*ethclient.Client, error) {
+	clients := make(map[string]*ethclient.Client, len(networks))
+	for _, n := range networks {
+		client, err := dialRPC(ctx, n.RPCURL)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("failed to connect to network %s: %w", n.Name, err)
+		}
+		clients[n.Name] = client
+
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = chain.CheckChainID(checkCtx, client, n.ChainID)
+		cancel()
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("network %s: %w", n.Name, err)
+		}
+		logger.Info("blockchain network configured", "network", n.Name, "chain_id", n.ChainID, "contract_address", n.ContractAddress)
+	}
+	return clients, nil
+}
+
+// dialRPC connects to an RPC endpoint. Calls over HTTP are traced and
+// carry the trace context of the call that made them.
+func dialRPC(ctx context.Context, url string) (*ethclient.Client, error) {
+	client, err := rpc.DialOptions(ctx, url, rpc.WithHTTPClient(&http.Client{Transport: telemetry.Transport(nil)}))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ethclient.NewClient(client), nil
+}
 
-	logger.Info("blockchain connection established",
-		"contract_address", cfg.Blockchain.ContractAddress,
-		"rpc_url", cfg.Blockchain.RPCURL,
-	)
+// closeClients closes every client in clients
+func closeClients(clients map[string]*ethclient.Client) {
+	for _, client := range clients {
+		client.Close()
+	}
+}
 
-	// Subscribe to LeaseCreated events
-	logs := make(chan *contracts.LeaseAgreementLeaseCreated)
-	sub, err := contract.WatchLeaseCreated(nil, logs, nil, nil, nil)
+// newTransactionManager creates a manager that sends with the configured
+// key on client's chain
+func newTransactionManager(ctx context.Context, cfg config.TransactionsConfig, client *ethclient.Client, logger *slog.Logger) (*txmgr.Manager, error) {
+	key, err := crypto.LoadECDSA(cfg.KeyFile)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to load transaction key: %w", err)
 	}
-	defer sub.Unsubscribe()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chain ID: %w", err)
+	}
+	var maxFeeCap *big.Int
+	if cfg.MaxFeeGwei != "" {
+		maxFeeCap = decimal.RequireFromString(cfg.MaxFeeGwei).Shift(9).BigInt()
+	}
+	return txmgr.New(client, key, chainID, txmgr.Config{
+		Confirmations: cfg.Confirmations,
+		PollInterval:  time.Duration(cfg.PollSeconds) * time.Second,
+		StallTimeout:  time.Duration(cfg.StallSeconds) * time.Second,
+		BumpPercent:   cfg.BumpPercent,
+		MaxFeeCap:     maxFeeCap,
+		QueueSize:     cfg.QueueSize,
+	}, cfg.RecordsPath, logger)
+}
 
-	logger.Info("subscribed to LeaseCreated events")
+// newContractReader binds a reader to the contract at address. It probes
+// the interface version the contract speaks and logs the methods it lacks,
+// whose features are then turned off. A contract that cannot be probed
+// keeps every feature on and is read through the v1 binding, whose leases
+// are a prefix of every later version's.
+func newContractReader(ctx context.Context, client *ethclient.Client, address string, logger *slog.Logger) (*contractReader, error) {
+	caller, err := contracts.NewLeaseAgreementCaller(common.HexToAddress(address), client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind LeaseAgreement contract: %w", err)
+	}
+	v2, err := contracts.NewLeaseAgreementV2Caller(common.HexToAddress(address), client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind LeaseAgreement contract: %w", err)
+	}
 
-	// Process events
-	for {
-		select {
-		case err := <-sub.Err():
-			logger.Error("subscription error", "error", err)
-			return err
-		case log := <-logs:
-			handleLeaseCreatedEvent(log, apiServer, logger)
-		case <-ctx.Done():
-			logger.Info("shutting down event listener")
-			return nil
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	deployment, err := contracts.Probe(probeCtx, client, common.HexToAddress(address))
+	switch {
+	case err != nil:
+		logger.Warn("failed to probe LeaseAgreement contract version", "error", err, "contract_address", address)
+		deployment = contracts.Deployment{Address: common.HexToAddress(address)}
+	case deployment.Version > contracts.LatestVersion:
+		logger.Warn("LeaseAgreement contract is newer than this agent's bindings", "version", deployment.Version, "latest_binding", contracts.LatestVersion)
+	case deployment.Version == 0:
+		logger.Warn("LeaseAgreement contract matches no known interface version", "missing_methods", deployment.Missing)
+	}
+	if err == nil {
+		logger.Info("LeaseAgreement contract probed",
+			"version", deployment.Version,
+			"reported", deployment.Reported,
+			"implementation", deployment.Implementation.Hex(),
+		)
+		if len(deployment.Missing) > 0 {
+			logger.Warn("LeaseAgreement contract lacks methods, their features are disabled", "missing_methods", deployment.Missing)
 		}
 	}
+	return &contractReader{caller: caller, v2: v2, deployment: deployment}, nil
 }
 
-// Telemetry init moved to internal/telemetry with build tags.
+// MinPrice implements pricing.MinPriceReader
+func (c *contractReader) MinPrice(ctx context.Context) (*big.Int, error) {
+	return c.caller.MINPRICE(&bind.CallOpts{Context: ctx})
+}
 
-// handleLeaseCreatedEvent processes a LeaseCreated event
-func handleLeaseCreatedEvent(event *contracts.LeaseAgreementLeaseCreated, apiServer *api.Server, logger *slog.Logger) {
-	logger.Info("received LeaseCreated event",
-		"lease_id", event.LeaseId,
-		"spender", event.Spender.Hex(),
-		"earner", event.Earner.Hex(),
-		"price", event.Price.String(),
-	)
+// LeaseProduct implements chain.LeaseProductReader
+func (c *contractReader) LeaseProduct(ctx context.Context, leaseID [32]byte) (string, error) {
+	// Lease grew fields in v2, so it is decoded with the matching binding
+	if c.deployment.Version >= 2 {
+		lease, err := c.v2.GetLease(&bind.CallOpts{Context: ctx}, leaseID)
+		if err != nil {
+			return "", fmt.Errorf("failed to read lease: %w", err)
+		}
+		return chain.DecodeProductID(lease.DataProductId), nil
+	}
+	lease, err := c.caller.GetLease(&bind.CallOpts{Context: ctx}, leaseID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read lease: %w", err)
+	}
+	return chain.DecodeProductID(lease.DataProductId), nil
+}
 
-	// Convert price to string
-	priceStr := event.Price.String()
-
-	// Convert lease ID to uint64 for storage
-	// Note: This is a simplified approach. In production, you might want to store the full bytes32
-	leaseID := uint64(0) // We'll use 0 for now since we don't have a direct mapping
-
-	// For now, we'll use a simple mapping from lease ID to proposal ID
-	// In a real implementation, you might want to maintain a mapping table
-	leaseProposalID := fmt.Sprintf("lease_prop_%x", event.LeaseId)
-
-	// Update the lease status in the API server
-	apiServer.UpdateLeaseStatus(
-		leaseProposalID,
-		"approved",
-		&leaseID,
-		event.Spender.Hex(),
-		event.Earner.Hex(),
-		&priceStr,
-	)
+// RequiredStake implements api.StakeReader
+func (c *contractReader) RequiredStake(ctx context.Context, leaseID [32]byte) (*big.Int, error) {
+	return c.v2.GetRequiredStake(&bind.CallOpts{Context: ctx}, leaseID)
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"pandacea/agent-backend/internal/api"
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/pricing"
+	"pandacea/agent-backend/internal/security"
+)
+
+// reloader re-reads config.yaml, security.yaml, the policy rules and the
+// local product catalog and applies them without a restart. Lease policy,
+// economic parameters, pricing and products take effect immediately;
+// changes to other settings are logged as needing a restart.
+type reloader struct {
+	mu           sync.Mutex
+	configPath   string
+	profile      string
+	securityPath string
+	current      *config.Config
+	pricer       *pricing.Pricer
+	policy       *policy.Reloadable
+	security     *security.SecurityService
+	catalog      *api.Server // Nil when products come from remote configuration
+	logger       *slog.Logger
+}
+
+// paths returns the files whose changes trigger a reload
+func (r *reloader) paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	paths := []string{r.configPath, r.securityPath, r.current.Policy.RegoPath, r.current.Policy.Bundle}
+	if r.catalog != nil {
+		paths = append(paths, r.catalog.CatalogPath(), r.catalog.CatalogPath()+".sig")
+	}
+	return paths
+}
+
+// run reloads on every change reported by changes and every SIGHUP until
+// ctx is done. A failure is logged once, not again on every trigger that
+// fails the same way, until a reload succeeds or fails differently.
+func (r *reloader) run(ctx context.Context, changes <-chan string, hup <-chan os.Signal) {
+	var lastErr string
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case path, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			trigger = path
+		case sig := <-hup:
+			trigger = sig.String()
+		}
+		if err := r.reload(ctx); err != nil {
+			if err.Error() == lastErr {
+				r.logger.Debug("configuration reload still rejected", "trigger", trigger, "error", err)
+				continue
+			}
+			lastErr = err.Error()
+			r.logger.Error("configuration reload rejected, keeping current configuration", "trigger", trigger, "error", err)
+			continue
+		}
+		lastErr = ""
+		r.logger.Info("configuration reloaded", "trigger", trigger)
+	}
+}
+
+// reload loads and checks every file before applying any of them, so an
+// invalid file leaves the running configuration untouched
+func (r *reloader) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.configPath, r.profile)
+	if err != nil {
+		return err
+	}
+	evaluator, err := policy.NewEvaluator(ctx, r.logger, next, r.pricer)
+	if err != nil {
+		return fmt.Errorf("invalid policy configuration: %w", err)
+	}
+	if _, err := pricing.ParseMinPrice(next.Server.MinPrice); err != nil {
+		return fmt.Errorf("invalid min_price: %w", err)
+	}
+	var products []api.DataProduct
+	if r.catalog != nil {
+		if products, err = r.catalog.ReadCatalog(); err != nil {
+			return fmt.Errorf("invalid product catalog: %w", err)
+		}
+	}
+	securityConfig, err := r.security.ReadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Every file has been checked, so none of the steps below can fail
+	// part way through
+	if err := r.pricer.Reconfigure(next.Server.MinPrice, next.Pricing); err != nil {
+		return fmt.Errorf("invalid min_price: %w", err)
+	}
+	r.security.SetConfig(securityConfig)
+	r.policy.Swap(evaluator)
+	if r.catalog != nil {
+		r.catalog.ReplaceProducts(products)
+	}
+
+	running := *r.current
+	running.Policy = next.Policy
+	applyEconomics(&running.Server, next.Server)
+	refresh := running.Pricing.RefreshSeconds
+	running.Pricing = next.Pricing
+	running.Pricing.RefreshSeconds = refresh
+	if sections := changedSections(&running, next); len(sections) > 0 {
+		r.logger.Warn("configuration changes need a restart to take effect", "sections", strings.Join(sections, ", "))
+	}
+	r.current = &running
+	return nil
+}
+
+// applyEconomics copies the server settings the policy engine and pricer
+// apply on reload from next to running
+func applyEconomics(running *config.ServerConfig, next config.ServerConfig) {
+	running.MinPrice = next.MinPrice
+	running.SaboteurCooldown = next.SaboteurCooldown
+	running.CollusionSpendFraction = next.CollusionSpendFraction
+	running.CollusionBonusDivisor = next.CollusionBonusDivisor
+	running.MinReputation = next.MinReputation
+	running.MaxLeaseDuration = next.MaxLeaseDuration
+	running.ProductMaxLeaseDurations = next.ProductMaxLeaseDurations
+	running.AllowLeaseTransfers = next.AllowLeaseTransfers
+}
+
+// changedSections names the top-level config sections that differ between
+// running and next
+func changedSections(running, next *config.Config) []string {
+	var sections []string
+	a, b := reflect.ValueOf(running).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
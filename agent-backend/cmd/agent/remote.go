@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/remotecfg"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// Names that remote configuration files are signed under
+const (
+	remoteProducts = "products"
+	remoteSecurity = "security"
+)
+
+// startRemoteConfig applies the signed remote products and security files,
+// then keeps them refreshed until ctx is done. A file that cannot be fetched
+// or verified at startup leaves the local copy in use.
+func startRemoteConfig(ctx context.Context, cfg config.RemoteConfig, ipfsAPIURL string, logger *slog.Logger, applyProducts, applySecurity func([]byte) error) error {
+	keys, err := remotecfg.ParseKeys(cfg.SignerKeys)
+	if err != nil {
+		return err
+	}
+	fetcher, err := remotecfg.NewFetcher(nil, ipfsAPIURL, keys)
+	if err != nil {
+		return err
+	}
+	if cfg.RefreshSeconds <= 0 {
+		return fmt.Errorf("remote.refresh_seconds must be positive")
+	}
+
+	sources := []struct {
+		source remotecfg.Source
+		apply  func([]byte) error
+	}{
+		{remotecfg.Source{Name: remoteProducts, URL: cfg.ProductsURL, SignatureURL: cfg.ProductsSignatureURL}, applyProducts},
+		{remotecfg.Source{Name: remoteSecurity, URL: cfg.SecurityURL, SignatureURL: cfg.SecuritySignatureURL}, applySecurity},
+	}
+	for _, s := range sources {
+		if s.source.URL == "" {
+			continue
+		}
+		syncer := remotecfg.NewSyncer(fetcher, s.source, s.apply, logger)
+		if _, err := syncer.Sync(ctx); err != nil {
+			logger.Error("failed to load remote configuration, keeping local copy", "name", s.source.Name, "url", s.source.URL, "error", err)
+		} else {
+			logger.Info("remote configuration loaded", "name", s.source.Name, "url", s.source.URL)
+		}
+		go syncer.Run(ctx, time.Duration(cfg.RefreshSeconds)*time.Second)
+	}
+	return nil
+}
+
+// runSignConfig writes a detached signature for a configuration file to
+// path.sig and prints the signer's public key for remote.signer_keys. The
+// signing key is a libp2p private key file, created as Ed25519 if missing.
+func runSignConfig(path, name, keyPath, walletKeyPath string, out io.Writer) error {
+	if name != remoteProducts && name != remoteSecurity {
+		return fmt.Errorf("--config-name must be %q or %q", remoteProducts, remoteSecurity)
+	}
+	if (keyPath == "") == (walletKeyPath == "") {
+		return fmt.Errorf("one of --signing-key or --wallet-key is required")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var sig, signer string
+	if walletKeyPath != "" {
+		key, err := ethcrypto.LoadECDSA(walletKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to read wallet key: %w", err)
+		}
+		if sig, err = remotecfg.SignWallet(key, name, signingVersion(), content); err != nil {
+			return err
+		}
+		signer = "signer wallet: " + ethcrypto.PubkeyToAddress(key.PublicKey).Hex()
+	} else {
+		priv, err := loadOrCreateSigningKey(keyPath)
+		if err != nil {
+			return err
+		}
+		if sig, err = remotecfg.Sign(priv, name, signingVersion(), content); err != nil {
+			return err
+		}
+		pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+		if err != nil {
+			return fmt.Errorf("failed to marshal public key: %w", err)
+		}
+		signer = "signer key: " + base64.StdEncoding.EncodeToString(pub)
+	}
+	if err := os.WriteFile(path+".sig", []byte(sig+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+
+	fmt.Fprintf(out, "signed %s as %q: %s.sig\n", path, name, path)
+	fmt.Fprintln(out, signer)
+	return nil
+}
+
+// signingVersion returns the version new configuration signatures carry:
+// the signing time in Unix seconds, so each signature supersedes the last
+func signingVersion() uint64 {
+	return uint64(time.Now().Unix())
+}
+
+// loadOrCreateSigningKey reads a marshalled libp2p private key, generating
+// and saving an Ed25519 key if the file does not exist
+func loadOrCreateSigningKey(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		priv, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		return priv, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	data, err = crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create signing key directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return priv, nil
+}
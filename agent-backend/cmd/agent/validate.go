@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/policy"
+)
+
+// runValidateConfig loads the configuration and builds the policy engine
+// from it without starting the agent. Every invalid field is printed on its
+// own line; the returned error reports whether there were any.
+func runValidateConfig(configPath, profile string, out io.Writer) error {
+	cfg, err := config.Load(configPath, profile)
+	var invalid *config.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		for _, field := range invalid.Fields {
+			fmt.Fprintf(out, "%s: %v\n", field.Field, field.Err)
+		}
+		return fmt.Errorf("%d invalid configuration settings", len(invalid.Fields))
+	case err != nil:
+		return err
+	}
+
+	// Rego policies are only compiled by the engine
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := policy.NewEvaluator(context.Background(), logger, cfg, nil); err != nil {
+		fmt.Fprintf(out, "policy: %v\n", err)
+		return fmt.Errorf("invalid policy configuration")
+	}
+
+	fmt.Fprintf(out, "configuration is valid (profile %s)\n", cfg.Profile)
+	return nil
+}
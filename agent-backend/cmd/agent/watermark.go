@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/watermark"
+)
+
+// newWatermarker loads the watermark key, creating it on first use
+func newWatermarker(cfg config.WatermarkConfig) (*watermark.Marker, error) {
+	key, err := watermark.LoadOrCreateKey(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return watermark.NewMarker(key)
+}
+
+// runVerifyWatermark checks a suspected leak against each candidate lease or
+// job ID and prints one line per candidate. It reports whether any candidate
+// matched.
+func runVerifyWatermark(cfg config.WatermarkConfig, path, ids string, out io.Writer) (bool, error) {
+	var candidates []string
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return false, fmt.Errorf("--watermark-ids lists no lease or job IDs")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	// Verification never creates a key: a fresh key matches nothing
+	key, err := watermark.LoadKey(cfg.KeyFile)
+	if err != nil {
+		return false, err
+	}
+	marker, err := watermark.NewMarker(key)
+	if err != nil {
+		return false, err
+	}
+
+	matched := false
+	for _, id := range candidates {
+		detection := marker.Detect(id, data)
+		verdict := "no match"
+		switch {
+		case detection.Match:
+			verdict = "MATCH"
+			matched = true
+		case detection.Values < watermark.MinValues:
+			verdict = "inconclusive (too few numbers)"
+		}
+		fmt.Fprintf(out, "%s\tvalues=%d\tmatches=%d\tscore=%.1f\t%s\n",
+			id, detection.Values, detection.Matches, detection.Score, verdict)
+	}
+	return matched, nil
+}
@@ -0,0 +1,24 @@
+# Example lease policy for the Rego policy engine.
+# Enable with policy.engine: rego and policy.rego_path pointing at this file.
+package pandacea.lease
+
+import rego.v1
+
+default allow := false
+
+# Accept leases of up to 30 days
+allow if {
+	input.duration_seconds <= 30 * 24 * 60 * 60
+}
+
+# Require a minimum reputation when the requester's score is known
+deny contains "requester reputation is below 0.2" if {
+	input.reputation < 0.2
+}
+
+# Only serve long leases outside peak hours (agent local time)
+deny contains "leases over 7 days are only accepted between 22:00 and 06:00" if {
+	input.duration_seconds > 7 * 24 * 60 * 60
+	input.time.hour >= 6
+	input.time.hour < 22
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit event types for operator actions
+const (
+	AuditAdminBan        = "admin.ban"
+	AuditAdminUnblock    = "admin.unblock"
+	AuditAdminQuotaReset = "admin.quota_reset"
+	AuditAdminExport     = "admin.audit_export"
+)
+
+// BanRequest represents a request to ban an IP
+type BanRequest struct {
+	IP              string `json:"ip"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+}
+
+// BanResponse represents an active ban
+type BanResponse struct {
+	IP    string    `json:"ip"`
+	Until time.Time `json:"until"`
+}
+
+// adminOnly restricts routes to peers listed in the security admin config.
+// It runs after signature verification, so the peer ID header is authentic.
+func (server *Server) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.securityService == nil {
+			server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeInternalError, "Security service unavailable")
+			return
+		}
+
+		peerID := r.Header.Get(reqsig.HeaderPeerID)
+		if !server.securityService.IsAdmin(peerID) {
+			server.logger.Warn("admin access denied", "peer_id", peerID, "path", r.URL.Path)
+			server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Admin access required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGetSecurityState handles GET /api/v1/admin/security
+func (server *Server) handleGetSecurityState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(server.securityService.Snapshot()); err != nil {
+		server.logger.Error("failed to encode security state", "error", err)
+	}
+}
+
+// handleBanIP handles POST /api/v1/admin/security/bans
+func (server *Server) handleBanIP(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.IP == "" || req.DurationSeconds < 0 {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "ip is required and duration_seconds must not be negative")
+		return
+	}
+
+	until, err := server.securityService.BanIP(r.Context(), req.IP, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		server.logger.Error("failed to ban IP", "ip", req.IP, "error", err)
+		server.sendError(w, r, err, "Failed to ban IP")
+		return
+	}
+
+	server.recordAudit(AuditAdminBan, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"ip":    req.IP,
+		"until": until,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(BanResponse{IP: req.IP, Until: until})
+}
+
+// handleUnbanIP handles DELETE /api/v1/admin/security/bans/{ip}
+func (server *Server) handleUnbanIP(w http.ResponseWriter, r *http.Request) {
+	server.unblock(w, r, security.BlockListBan)
+}
+
+// handleUngreylistIP handles DELETE /api/v1/admin/security/greylist/{ip}
+func (server *Server) handleUngreylistIP(w http.ResponseWriter, r *http.Request) {
+	server.unblock(w, r, security.BlockListGreylist)
+}
+
+// unblock removes the IP in the URL from a block list
+func (server *Server) unblock(w http.ResponseWriter, r *http.Request, list string) {
+	ip := chi.URLParam(r, "ip")
+	if ip == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Missing IP")
+		return
+	}
+
+	if _, err := server.securityService.Unblock(r.Context(), list, ip); err != nil {
+		server.logger.Error("failed to unblock IP", "ip", ip, "list", list, "error", err)
+		server.sendErrorResponse(w, r, http.StatusBadGateway, ErrorCodeInternalError, "Failed to update shared rate limit store")
+		return
+	}
+
+	server.recordAudit(AuditAdminUnblock, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"ip":   ip,
+		"list": list,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResetQuotas handles DELETE /api/v1/admin/security/quotas and
+// DELETE /api/v1/admin/security/quotas/{identity}
+func (server *Server) handleResetQuotas(w http.ResponseWriter, r *http.Request) {
+	identity := chi.URLParam(r, "identity")
+	server.securityService.ResetQuota(identity)
+
+	server.recordAudit(AuditAdminQuotaReset, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"identity": identity,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// handleGetResultArtifact handles GET
+// /api/v1/privacy/results/{computation_id}/artifacts/{name}. It sends one
+// artifact as raw bytes with an ETag of its SHA-256, so downloads can be
+// resumed with Range and If-Range and revalidated with If-None-Match.
+// Clients that accept gzip and ask for the whole artifact get it gzipped,
+// under an ETag of its own. The artifact is sent unbuffered with a detached
+// signature of its bytes in place of the response signature.
+func (server *Server) handleGetResultArtifact(w http.ResponseWriter, r *http.Request) {
+	computationID := chi.URLParam(r, "computation_id")
+	name := chi.URLParam(r, "*")
+	if computationID == "" || name == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Computation ID and artifact name are required")
+		return
+	}
+
+	result, err := server.ownComputationResult(r.Context(), computationID, r.Header.Get(reqsig.HeaderPeerID))
+	if err != nil {
+		server.sendError(w, r, err, "Failed to get computation result")
+		return
+	}
+	if result.Results == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Computation has no results yet")
+		return
+	}
+	if result.Results.Sealed != nil {
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeResultsSealed,
+			"Results are sealed to the spender; read them from the result instead")
+		return
+	}
+	encoded, exists := result.Results.Artifacts[name]
+	if !exists {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Artifact not found")
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		server.logger.Error("failed to decode artifact", "computation_id", computationID, "artifact", name, "error", err)
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to read artifact")
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := hex.EncodeToString(sum[:])
+	if server.responseSigner != nil {
+		sig, err := server.responseSigner.SignArtifactHash(respsig.ResultArtifactID(computationID, name), etag)
+		if err != nil {
+			server.logger.Error("failed to sign artifact", "computation_id", computationID, "artifact", name, "error", err)
+			server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to sign artifact")
+			return
+		}
+		skipResponseSignature(w)
+		respsig.SetArtifactHeaders(w.Header(), sig)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	h.Set("Cache-Control", "private, no-cache")
+	h.Set("Accept-Ranges", "bytes")
+	h.Add("Vary", "Accept-Encoding")
+
+	if r.Header.Get("Range") == "" && negotiateEncoding(r.Header.Get("Accept-Encoding"), []string{"gzip"}) == "gzip" {
+		server.sendGzippedArtifact(w, r, `"`+etag+`-gzip"`, data)
+		return
+	}
+	h.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// sendGzippedArtifact streams an artifact gzipped. Its length is not known
+// up front, so it is sent without a Content-Length.
+func (server *Server) sendGzippedArtifact(w http.ResponseWriter, r *http.Request, etag string, data []byte) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	enc := encoderPools["gzip"].Get().(encoder)
+	defer encoderPools["gzip"].Put(enc)
+	enc.Reset(w)
+	h.Set("Content-Encoding", "gzip")
+	h.Set("X-Pandacea-Artifact-Size", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := enc.Write(data); err != nil {
+		server.logger.Warn("artifact download interrupted", "error", err)
+		return
+	}
+	if err := enc.Close(); err != nil {
+		server.logger.Warn("artifact download interrupted", "error", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using
+// the weak comparison RFC 9110 prescribes for it
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// artifactPrivacyService returns a completed result with one artifact
+type artifactPrivacyService struct {
+	MockPrivacyService
+	artifact []byte
+	sealed   bool
+}
+
+func (m *artifactPrivacyService) GetComputationResult(ctx context.Context, computationID string) (*privacy.ComputationResult, error) {
+	results := &privacy.ComputationResults{Artifacts: map[string]string{
+		"model/weights.bin": base64.StdEncoding.EncodeToString(m.artifact),
+	}}
+	if m.sealed {
+		results = &privacy.ComputationResults{Sealed: &envelope.Envelope{Version: "1", KeyType: "X25519", Ciphertext: []byte("sealed")}}
+	}
+	return &privacy.ComputationResult{Status: "completed", Owner: "12D3KooWSpender", Results: results}, nil
+}
+
+func TestServer_handleGetResultArtifact(t *testing.T) {
+	artifact := bytes.Repeat([]byte("weights "), 4096)
+	privacyService := &artifactPrivacyService{artifact: artifact}
+	server := NewServer(denyEvaluator{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, privacyService, nil)
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+	router := chi.NewRouter()
+	router.Use(server.signResponseMiddleware)
+	router.Get("/results/{computation_id}/artifacts/*", server.handleGetResultArtifact)
+	get := func(name string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/results/comp_1/artifacts/"+name, nil)
+		req.Header.Set(reqsig.HeaderPeerID, "12D3KooWSpender")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("model/weights.bin", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, artifact, w.Body.Bytes())
+	assert.Equal(t, "32768", w.Header().Get("Content-Length"))
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// The artifact carries a detached signature of its bytes instead of a
+	// response signature
+	id := respsig.ResultArtifactID("comp_1", "model/weights.bin")
+	assert.Empty(t, w.Header().Get(respsig.HeaderSignature))
+	_, err := respsig.VerifyArtifact(respsig.ArtifactFromHeaders(w.Header(), id), artifact, signer.PeerID())
+	assert.NoError(t, err)
+
+	// A download resumes from where it stopped while the artifact is unchanged
+	w = get("model/weights.bin", map[string]string{"Range": "bytes=100-", "If-Range": etag})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, artifact[100:], w.Body.Bytes())
+	assert.Equal(t, "bytes 100-32767/32768", w.Header().Get("Content-Range"))
+	w = get("model/weights.bin", map[string]string{"Range": "bytes=100-", "If-Range": `"stale"`})
+	assert.Equal(t, http.StatusOK, w.Code, "a changed artifact is sent whole")
+
+	w = get("model/weights.bin", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Whole downloads are gzipped for clients that accept it
+	w = get("model/weights.bin", map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Less(t, w.Body.Len(), len(artifact))
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	unzipped, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, artifact, unzipped)
+	_, err = respsig.VerifyArtifact(respsig.ArtifactFromHeaders(w.Header(), id), unzipped, signer.PeerID())
+	assert.NoError(t, err, "the signature covers the artifact, not its gzipped bytes")
+	gzipETag := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, gzipETag)
+	w = get("model/weights.bin", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": gzipETag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Errors are still signed as responses
+	w = get("missing.bin", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	_, err = respsig.Verify(w.Header(), httptest.NewRequest(http.MethodGet, "/results/comp_1/artifacts/missing.bin", nil), w.Code, w.Body.Bytes(), signer.PeerID())
+	assert.NoError(t, err)
+
+	privacyService.sealed = true
+	w = get("model/weights.bin", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeResultsSealed)
+}
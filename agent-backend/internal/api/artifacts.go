@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/respsig"
+)
+
+// ArtifactSig is the hash and agent signature of a training artifact
+type ArtifactSig = respsig.ArtifactSignature
+
+// ArtifactVerifyRequest is a training artifact signature to check
+type ArtifactVerifyRequest struct {
+	Integrity respsig.ArtifactSignature `json:"integrity"`          // As returned in the job status or the artifact's .sig file
+	Artifact  string                    `json:"artifact,omitempty"` // Base64 artifact bytes; when set they must match the signed hash
+	PeerID    string                    `json:"peer_id,omitempty"`  // Require the artifact to be signed by this agent
+}
+
+// ArtifactVerifyResponse reports whether an artifact signature is valid
+type ArtifactVerifyResponse struct {
+	Valid     bool   `json:"valid"`
+	PeerID    string `json:"peer_id,omitempty"` // Agent that signed the artifact
+	ThisAgent bool   `json:"this_agent"`        // The signer is the agent answering the request
+	Error     string `json:"error,omitempty"`   // Why the signature is invalid
+}
+
+// SetKeyring encrypts training artifacts at rest with k once they are
+// finished, and mounts inputs encrypted at rest decrypted
+func (server *Server) SetKeyring(k *atrest.Keyring) {
+	server.keyring = k
+	if decrypter, ok := server.privacyService.(privacy.DataDecrypter); ok {
+		decrypter.UseKeyring(k)
+	} else {
+		server.logger.Warn("privacy service cannot decrypt inputs; computations on encrypted inputs will fail")
+	}
+}
+
+// encryptArtifact encrypts a finished training artifact in place, if
+// encryption at rest is enabled. Its signature stays in plaintext.
+func (server *Server) encryptArtifact(aggregatePath string) error {
+	if server.keyring == nil {
+		return nil
+	}
+	_, err := server.keyring.EncryptFile(aggregatePath)
+	return err
+}
+
+// signArtifact hashes a finished training artifact and signs it with the
+// agent's key, writing the signature next to it as <artifact>.sig. It
+// returns nil when the agent has no key.
+func (server *Server) signArtifact(jobID, aggregatePath string) (*respsig.ArtifactSignature, error) {
+	if server.responseSigner == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(aggregatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	sig, err := server.responseSigner.SignArtifact(jobID, data)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode artifact signature: %w", err)
+	}
+	if err := os.WriteFile(aggregatePath+".sig", encoded, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write artifact signature: %w", err)
+	}
+	server.logger.Info("training artifact signed", "job_id", jobID, "sha256", sig.SHA256)
+	return sig, nil
+}
+
+// handleVerifyArtifact handles POST /api/v1/artifacts/verify
+func (server *Server) handleVerifyArtifact(w http.ResponseWriter, r *http.Request) {
+	var req ArtifactVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	var data []byte
+	if req.Artifact != "" {
+		decoded, err := base64.StdEncoding.DecodeString(req.Artifact)
+		if err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "artifact must be base64")
+			return
+		}
+		data = decoded
+	}
+
+	var response ArtifactVerifyResponse
+	peerID, err := respsig.VerifyArtifact(req.Integrity, data, req.PeerID)
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Valid, response.PeerID = true, peerID
+		response.ThisAgent = server.responseSigner != nil && peerID == server.responseSigner.PeerID()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode artifact verification", "error", err)
+	}
+}
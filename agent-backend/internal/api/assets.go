@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit event types for the asset registry
+const (
+	AuditAdminAssetRegistered = "admin.asset_registered"
+	AuditAdminAssetRemoved    = "admin.asset_removed"
+)
+
+// AssetsResponse lists registered data assets
+type AssetsResponse struct {
+	Data []assets.Asset `json:"data"`
+}
+
+// ProductProfileResponse is the profiling of each of a product's assets.
+// Profiles still being computed are listed as pending.
+type ProductProfileResponse struct {
+	ProductID string              `json:"productId"`
+	Assets    []assets.ProfileJob `json:"assets"`
+}
+
+// SetAssets makes registry the source of the files behind data products.
+// The catalog lists each product's assets, and a privacy service that
+// supports it mounts computation inputs from the registry.
+func (server *Server) SetAssets(registry *assets.Registry) {
+	server.assets = registry
+	if mounter, ok := server.privacyService.(privacy.AssetMounter); ok {
+		mounter.UseAssets(registry)
+	}
+}
+
+// maxPreviewCallers is how many callers' preview limits are tracked before
+// idle ones are forgotten
+const maxPreviewCallers = 10000
+
+// SetPreviews serves asset previews from previewer, allowing each caller
+// perMinute previews a minute
+func (server *Server) SetPreviews(previewer *assets.Previewer, perMinute int) {
+	server.previewMutex.Lock()
+	defer server.previewMutex.Unlock()
+	server.previews = previewer
+	server.previewRate = perMinute
+	server.previewBuckets = make(map[string]*security.TokenBucket)
+}
+
+// SetProfiles serves the profiles of registered assets from profiler
+func (server *Server) SetProfiles(profiler *assets.Profiler) {
+	server.profiles = profiler
+}
+
+// takePreview reports whether identity may fetch another preview
+func (server *Server) takePreview(identity string) bool {
+	server.previewMutex.Lock()
+	bucket, ok := server.previewBuckets[identity]
+	if !ok {
+		// Full buckets belong to callers idle for a minute or more, so they
+		// can be dropped without letting anyone preview more
+		if len(server.previewBuckets) >= maxPreviewCallers {
+			for id, b := range server.previewBuckets {
+				if b.Tokens() >= float64(server.previewRate) {
+					delete(server.previewBuckets, id)
+				}
+			}
+		}
+		bucket = security.NewTokenBucket(float64(server.previewRate), float64(server.previewRate)/60)
+		server.previewBuckets[identity] = bucket
+	}
+	server.previewMutex.Unlock()
+	return bucket.Take()
+}
+
+// assetProduct returns the product a computation input belongs to. Inputs
+// that are neither registered nor erased assets are taken to be product
+// IDs.
+func (server *Server) assetProduct(assetID string) string {
+	if server.assets != nil {
+		if a, ok := server.assets.Get(assetID); ok {
+			return a.ProductID
+		}
+	}
+	if productID, ok := server.erasedAssetProduct(assetID); ok {
+		return productID
+	}
+	return assetID
+}
+
+// handleRegisterAsset handles POST /api/v1/admin/security/assets. The
+// asset's file is read in full and must match whatever metadata the
+// request declares.
+func (server *Server) handleRegisterAsset(w http.ResponseWriter, r *http.Request) {
+	if server.assets == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset registry is not enabled")
+		return
+	}
+	var req assets.Asset
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	a, err := server.assets.Register(r.Context(), req)
+	if err != nil {
+		server.logger.Warn("asset registration refused", "error", err, "asset_id", req.ID)
+		server.sendError(w, r, err, "Failed to register asset")
+		return
+	}
+	server.recordAudit(AuditAdminAssetRegistered, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"asset_id":   a.ID,
+		"product_id": a.ProductID,
+		"format":     a.Format,
+		"rows":       a.Rows,
+		"sha256":     a.SHA256,
+	})
+	server.logger.Info("asset registered", "asset_id", a.ID, "product_id", a.ProductID, "rows", a.Rows, "size", a.Size)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		server.logger.Error("failed to encode asset", "error", err)
+	}
+}
+
+// handleListAssets handles GET /api/v1/admin/security/assets
+func (server *Server) handleListAssets(w http.ResponseWriter, r *http.Request) {
+	if server.assets == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset registry is not enabled")
+		return
+	}
+	response := AssetsResponse{Data: server.assets.List(r.URL.Query().Get("product"))}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode assets", "error", err)
+	}
+}
+
+// handleRemoveAsset handles DELETE /api/v1/admin/security/assets/{assetId}.
+// Computations already queued on the asset fail when they mount it.
+func (server *Server) handleRemoveAsset(w http.ResponseWriter, r *http.Request) {
+	if server.assets == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset registry is not enabled")
+		return
+	}
+	assetID := chi.URLParam(r, "assetId")
+	if err := server.assets.Remove(assetID); err != nil {
+		server.sendError(w, r, err, "Failed to remove asset")
+		return
+	}
+	server.recordAudit(AuditAdminAssetRemoved, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"asset_id": assetID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetAssetPreview handles GET /api/v1/assets/{assetId}/preview. It
+// serves a sample of a registered asset, with suppressed columns left out,
+// so spenders can inspect the data before leasing it.
+func (server *Server) handleGetAssetPreview(w http.ResponseWriter, r *http.Request) {
+	if server.previews == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset previews are not enabled")
+		return
+	}
+	assetID := chi.URLParam(r, "assetId")
+	a, ok := server.assets.Get(assetID)
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset not found")
+		return
+	}
+	if server.rejectQuarantined(w, r, a.ProductID, map[string]any{"asset_id": assetID}) {
+		return
+	}
+	identity := costIdentity(r)
+	if !server.takePreview(identity) {
+		server.logger.Warn("asset preview rate limited", "identity", identity, "asset_id", assetID)
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", (time.Minute/time.Duration(server.previewRate)).Seconds()))
+		server.sendErrorResponse(w, r, http.StatusTooManyRequests, ErrorCodeRateLimited, "Preview rate limit exceeded")
+		return
+	}
+
+	preview, cached, err := server.previews.Preview(r.Context(), assetID)
+	if err != nil {
+		server.logger.Error("failed to generate asset preview", "error", err, "asset_id", assetID)
+		server.sendError(w, r, err, "Failed to generate preview")
+		return
+	}
+	server.logger.Info("asset preview served", "asset_id", assetID, "identity", identity, "cached", cached)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		server.logger.Error("failed to encode asset preview", "error", err)
+	}
+}
+
+// handleGetProductProfile handles GET /api/v1/products/{productId}/profile.
+// It serves the column statistics of each of the product's assets, starting
+// a sandboxed profiling job for any without a current profile, and answers
+// 202 Accepted until every job has finished.
+func (server *Server) handleGetProductProfile(w http.ResponseWriter, r *http.Request) {
+	productID, ok := strings.CutSuffix(chi.URLParam(r, "*"), "/profile")
+	if !ok || productID == "" {
+		server.handleNotFound(w, r)
+		return
+	}
+	if server.profiles == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Asset profiles are not enabled")
+		return
+	}
+	if server.rejectQuarantined(w, r, productID, nil) {
+		return
+	}
+	registered := server.assets.List(productID)
+	if len(registered) == 0 {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Product has no registered assets")
+		return
+	}
+
+	response := ProductProfileResponse{ProductID: productID, Assets: make([]assets.ProfileJob, 0, len(registered))}
+	status := http.StatusOK
+	for _, a := range registered {
+		job, err := server.profiles.Profile(a.ID)
+		if err != nil {
+			// Removed since it was listed
+			continue
+		}
+		if job.Status == assets.ProfilePending {
+			status = http.StatusAccepted
+		}
+		response.Assets = append(response.Assets, job)
+	}
+	server.logger.Info("product profile served", "product_id", productID, "identity", costIdentity(r), "complete", status == http.StatusOK)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode product profile", "error", err)
+	}
+}
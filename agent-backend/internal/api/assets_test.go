@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_assets(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	const productID = "did:pandacea:earner:123/abc-456"
+	server.products.Replace([]DataProduct{{ProductID: productID}})
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Post("/assets", server.handleRegisterAsset)
+	router.Get("/assets", server.handleListAssets)
+	router.Delete("/assets/{assetId}", server.handleRemoveAsset)
+	register := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body)))
+		return w
+	}
+
+	w := register(`{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, "the registry is off unless configured")
+
+	registry, err := assets.NewRegistry("", "")
+	require.NoError(t, err)
+	server.SetAssets(registry)
+	source := filepath.Join(t.TempDir(), "scans.csv")
+	require.NoError(t, os.WriteFile(source, []byte("id,depth\n1,0.5\n2,0.7\n"), 0600))
+
+	w = register(`{"assetId":"scans","productId":"` + productID + `","source":"` + source + `","format":"csv","rows":3}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "declared rows must match the file")
+	assert.Contains(t, w.Body.String(), ErrorCodeValidationError)
+
+	w = register(`{"assetId":"scans","productId":"` + productID + `","source":"` + source + `","format":"csv",
+		"schema":[{"name":"id","type":"integer"},{"name":"depth","type":"number"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var registered assets.Asset
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&registered))
+	assert.Equal(t, int64(2), registered.Rows)
+	assert.Len(t, registered.SHA256, 64)
+
+	// The catalog describes the product's assets without revealing where
+	// they are kept
+	w = httptest.NewRecorder()
+	server.handleGetProducts(w, httptest.NewRequest(http.MethodGet, "/api/v1/products", nil))
+	var products ProductsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&products))
+	require.Len(t, products.Data[0].Assets, 1)
+	assert.Equal(t, "scans", products.Data[0].Assets[0].ID)
+	assert.Len(t, products.Data[0].Assets[0].Schema, 2)
+	assert.NotContains(t, w.Body.String(), source)
+
+	assert.Equal(t, productID, server.assetProduct("scans"))
+	assert.Equal(t, "other", server.assetProduct("other"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/assets/scans", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/assets/scans", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServer_assetPreview(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Get("/assets/{assetId}/preview", server.handleGetAssetPreview)
+	preview := func(assetID, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/assets/"+assetID+"/preview", nil)
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, preview("patients", "peer-a").Code, "previews are off unless configured")
+
+	registry, err := assets.NewRegistry("", "")
+	require.NoError(t, err)
+	source := filepath.Join(t.TempDir(), "patients.csv")
+	require.NoError(t, os.WriteFile(source, []byte("id,age,name\n1,34,Ada\n2,51,Grace\n3,29,Alan\n"), 0600))
+	_, err = registry.Register(context.Background(), assets.Asset{ID: "patients", ProductID: "product-1", Source: source, Format: assets.FormatCSV})
+	require.NoError(t, err)
+	server.SetAssets(registry)
+	server.SetPreviews(assets.NewPreviewer(registry, assets.PreviewOptions{
+		Rows:     2,
+		Suppress: map[string][]string{"patients": {"name"}},
+		CacheTTL: time.Minute,
+	}), 2)
+
+	w := preview("patients", "peer-a")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got assets.Preview
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, []string{"id", "age"}, got.Columns)
+	assert.Equal(t, []string{"name"}, got.Suppressed)
+	assert.Len(t, got.Rows, 2)
+	assert.Equal(t, int64(3), got.TotalRows)
+	assert.NotContains(t, w.Body.String(), "Ada")
+
+	assert.Equal(t, http.StatusNotFound, preview("unknown", "peer-a").Code)
+
+	// Each caller has its own allowance
+	assert.Equal(t, http.StatusOK, preview("patients", "peer-a").Code)
+	w = preview("patients", "peer-a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, preview("patients", "peer-b").Code)
+
+	server.quarantined["product-1"] = &Quarantine{ProductID: "product-1", Reason: "consent withdrawn"}
+	assert.Equal(t, http.StatusConflict, preview("patients", "peer-c").Code)
+}
+
+func TestServer_productProfile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Get("/products/*", server.handleGetProductProfile)
+	profile := func(productID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/"+productID+"/profile", nil))
+		return w
+	}
+
+	const productID = "did:pandacea:earner:123/abc-456"
+	assert.Equal(t, http.StatusNotFound, profile(productID).Code, "profiles are off unless configured")
+
+	registry, err := assets.NewRegistry("", "")
+	require.NoError(t, err)
+	source := filepath.Join(t.TempDir(), "scans.csv")
+	require.NoError(t, os.WriteFile(source, []byte("id,depth\n1,0.5\n2,0.7\n"), 0600))
+	_, err = registry.Register(context.Background(), assets.Asset{ID: "scans", ProductID: productID, Source: source, Format: assets.FormatCSV})
+	require.NoError(t, err)
+	server.SetAssets(registry)
+	profiler := assets.NewProfiler(registry, func(ctx context.Context, a assets.Asset, bins, top int) (assets.Profile, error) {
+		return assets.Profile{Rows: 2, Columns: []assets.ColumnProfile{{Name: "depth", Type: "number", Min: 0.5, Max: 0.7}}}, nil
+	}, assets.ProfileOptions{Bins: 10, MinCount: 1, CacheTTL: time.Hour})
+	server.SetProfiles(profiler)
+
+	w := profile(productID)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	profiler.Wait()
+	w = profile(productID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got ProductProfileResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Len(t, got.Assets, 1)
+	assert.Equal(t, assets.ProfileCompleted, got.Assets[0].Status)
+	assert.Equal(t, int64(2), got.Assets[0].Profile.Rows)
+	assert.Equal(t, productID, got.Assets[0].Profile.ProductID)
+	assert.NotContains(t, w.Body.String(), source)
+
+	assert.Equal(t, http.StatusNotFound, profile("product-2").Code, "products without assets have no profile")
+
+	server.quarantined[productID] = &Quarantine{ProductID: productID, Reason: "consent withdrawn"}
+	assert.Equal(t, http.StatusConflict, profile(productID).Code)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pandacea/agent-backend/internal/attest"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+)
+
+// AuditComputationAttested is recorded when a completed computation's
+// attestation is signed
+const AuditComputationAttested = "computation.attested"
+
+// AttestationResponse is a computation's attestation and, if its hash is
+// anchored, the transaction carrying it
+type AttestationResponse struct {
+	attest.Attestation
+	Transaction *txmgr.Record `json:"transaction,omitempty"`
+}
+
+// AttestationVerifyRequest is a computation attestation to check
+type AttestationVerifyRequest struct {
+	Attestation attest.Attestation `json:"attestation"`       // As returned by GET /privacy/results/{computation_id}/attestation
+	PeerID      string             `json:"peer_id,omitempty"` // Require the attestation to be signed by this agent
+}
+
+// AttestationVerifyResponse reports whether an attestation is valid
+type AttestationVerifyResponse struct {
+	Valid     bool   `json:"valid"`
+	PeerID    string `json:"peer_id,omitempty"` // Agent that signed the attestation
+	ThisAgent bool   `json:"this_agent"`        // The signer is the agent answering the request
+	Hash      string `json:"hash,omitempty"`    // Statement hash, as anchored on-chain
+	Error     string `json:"error,omitempty"`   // Why the attestation is invalid
+}
+
+// SetAttestations signs a statement of what each completed computation ran
+// and keeps it in store, if the privacy service supports it. With anchor
+// set, each statement's hash is also sent on-chain as the data of a
+// transaction to anchorTo, or to the agent's own address if it is nil.
+// Anchoring needs the transaction manager to be set first.
+func (server *Server) SetAttestations(store *attest.Store, anchor bool, anchorTo *common.Address) {
+	if server.responseSigner == nil {
+		server.logger.Warn("computation attestations need a node key to sign with")
+		return
+	}
+	attester, ok := server.privacyService.(privacy.ComputationAttester)
+	if !ok {
+		server.logger.Warn("privacy service cannot attest computations")
+		return
+	}
+	if anchor && server.transactions == nil {
+		server.logger.Warn("anchoring attestations needs transaction sending; attestations will not be anchored")
+		anchor = false
+	}
+	server.attestations = store
+	if anchor {
+		to := server.transactions.Address()
+		if anchorTo != nil {
+			to = *anchorTo
+		}
+		server.anchorTo = &to
+	}
+	attester.AttestComputations(server.attestComputation)
+}
+
+// attestComputation signs and stores a computation's statement, and
+// anchors its hash if anchoring is enabled
+func (server *Server) attestComputation(statement attest.Statement) {
+	a, err := attest.Sign(server.responseSigner, statement)
+	if err != nil {
+		server.logger.Error("failed to sign computation attestation", "error", err, "computation_id", statement.ComputationID)
+		return
+	}
+	if err := server.attestations.Add(*a); err != nil {
+		server.logger.Error("failed to store computation attestation", "error", err, "computation_id", statement.ComputationID)
+		return
+	}
+
+	fields := map[string]any{
+		"computation_id": statement.ComputationID,
+		"lease_id":       statement.LeaseID,
+		"hash":           a.Signature.SHA256,
+	}
+	if server.anchorTo != nil {
+		if anchor, err := server.anchorAttestation(*a); err != nil {
+			server.logger.Warn("failed to anchor computation attestation", "error", err, "computation_id", statement.ComputationID)
+		} else {
+			fields["tx_id"] = anchor.TxID
+		}
+	}
+	server.recordAudit(AuditComputationAttested, "", fields)
+}
+
+// anchorAttestation queues a transaction whose data is a's statement hash
+// and records it as a's anchor
+func (server *Server) anchorAttestation(a attest.Attestation) (attest.Anchor, error) {
+	hash := a.Hash()
+	rec, err := server.transactions.Submit(txmgr.Request{
+		Action:    TxActionAnchorAttestation,
+		Reference: a.Statement.ComputationID,
+		To:        *server.anchorTo,
+		Data:      hash.Bytes(),
+	})
+	if err != nil {
+		return attest.Anchor{}, err
+	}
+	server.recordAudit(AuditTransactionQueued, "", map[string]any{
+		"tx_id":          rec.ID,
+		"action":         TxActionAnchorAttestation,
+		"computation_id": a.Statement.ComputationID,
+	})
+	anchor := attest.Anchor{TxID: rec.ID, To: rec.To}
+	return anchor, server.attestations.SetAnchor(a.Statement.ComputationID, anchor)
+}
+
+// handleGetAttestation handles GET /api/v1/privacy/results/{computation_id}/attestation
+func (server *Server) handleGetAttestation(w http.ResponseWriter, r *http.Request) {
+	if server.attestations == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Computation attestations are not enabled")
+		return
+	}
+
+	a, err := server.attestations.Get(chi.URLParam(r, "computation_id"))
+	if err != nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Computation has no attestation")
+		return
+	}
+	response := AttestationResponse{Attestation: a}
+	if a.Anchor != nil && server.transactions != nil {
+		if rec, ok := server.transactions.Get(a.Anchor.TxID); ok {
+			response.Transaction = &rec
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode attestation", "error", err)
+	}
+}
+
+// handleVerifyAttestation handles POST /api/v1/attestations/verify
+func (server *Server) handleVerifyAttestation(w http.ResponseWriter, r *http.Request) {
+	var req AttestationVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	var response AttestationVerifyResponse
+	peerID, err := attest.Verify(req.Attestation, req.PeerID)
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Valid, response.PeerID = true, peerID
+		response.ThisAgent = server.responseSigner != nil && peerID == server.responseSigner.PeerID()
+		response.Hash = req.Attestation.Hash().Hex()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode attestation verification", "error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/attest"
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attestingPrivacyService hands out the attestation callback it is given
+type attestingPrivacyService struct {
+	MockPrivacyService
+	attest func(attest.Statement)
+}
+
+func (m *attestingPrivacyService) AttestComputations(fn func(attest.Statement)) {
+	m.attest = fn
+}
+
+func TestServer_attestations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	privacyService := &attestingPrivacyService{}
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, privacyService, nil)
+	store, err := attest.NewStore("")
+	require.NoError(t, err)
+
+	// Without a key there is nothing to sign with
+	server.SetAttestations(store, true, nil)
+	assert.Nil(t, privacyService.attest)
+
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		ethcrypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1e18)},
+	})
+	defer backend.Close()
+	chainID, err := backend.Client().ChainID(context.Background())
+	require.NoError(t, err)
+	manager, err := txmgr.New(backend.Client(), key, chainID, txmgr.Config{PollInterval: 10 * time.Millisecond}, "", logger)
+	require.NoError(t, err)
+	server.SetTransactionManager(manager, common.HexToAddress("0x00000000000000000000000000000000000000aa"))
+
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+	server.SetAttestations(store, true, nil)
+	require.NotNil(t, privacyService.attest)
+
+	statement := attest.Statement{
+		Version:       attest.Version,
+		ComputationID: "comp-1",
+		LeaseID:       "lease-1",
+		ScriptCID:     "QmScript",
+		Inputs:        []attest.Input{{AssetID: "sales", SHA256: "aa"}},
+		OutputSHA256:  "bb",
+		StartedAt:     time.Now().UTC(),
+		FinishedAt:    time.Now().UTC(),
+	}
+	privacyService.attest(statement)
+
+	router := chi.NewRouter()
+	router.Get("/privacy/results/{computation_id}/attestation", server.handleGetAttestation)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/privacy/results/comp-1/attestation", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response AttestationResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, "QmScript", response.Statement.ScriptCID)
+	assert.Equal(t, signer.PeerID(), response.Signature.PeerID)
+
+	// The hash is anchored in a transaction to the agent's own address
+	require.NotNil(t, response.Anchor)
+	require.NotNil(t, response.Transaction)
+	assert.Equal(t, TxActionAnchorAttestation, response.Transaction.Action)
+	assert.Equal(t, "comp-1", response.Transaction.Reference)
+	assert.Equal(t, manager.Address().Hex(), response.Transaction.To)
+	assert.Equal(t, response.Attestation.Hash().Bytes(), []byte(response.Transaction.Data))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/privacy/results/comp-2/attestation", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditComputationAttested})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, response.Signature.SHA256, page.Events[0].Fields["hash"])
+	assert.Equal(t, response.Anchor.TxID, page.Events[0].Fields["tx_id"])
+
+	verify := func(req AttestationVerifyRequest) AttestationVerifyResponse {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		server.handleVerifyAttestation(w, httptest.NewRequest(http.MethodPost, "/api/v1/attestations/verify", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response AttestationVerifyResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+	verdict := verify(AttestationVerifyRequest{Attestation: response.Attestation})
+	assert.True(t, verdict.Valid, verdict.Error)
+	assert.True(t, verdict.ThisAgent)
+	assert.Equal(t, response.Attestation.Hash().Hex(), verdict.Hash)
+
+	tampered := response.Attestation
+	tampered.Statement.OutputSHA256 = "cc"
+	verdict = verify(AttestationVerifyRequest{Attestation: tampered})
+	assert.False(t, verdict.Valid)
+	assert.NotEmpty(t, verdict.Error)
+}
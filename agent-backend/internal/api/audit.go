@@ -0,0 +1,273 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Audit event types recorded by the API server
+const (
+	AuditLeaseProposed       = "lease.proposed"
+	AuditLeaseRejected       = "lease.rejected"
+	AuditLeaseExpired        = "lease.expired"
+	AuditLeaseTransferred    = "lease.transferred"
+	AuditConsentIssued       = "lease.consent_issued"
+	AuditDisputeRaised       = "dispute.raised"
+	AuditComputationQueued   = "computation.queued"
+	AuditComputationFinished = "computation.finished"
+	AuditResultDenied        = "computation.result_denied"
+	AuditTrainingQueued      = "training.queued"
+	AuditTrainingResumed     = "training.resumed"
+	AuditModelPromoted       = "model.promoted"
+	AuditAuthVerified        = "auth.verified"
+	AuditAuthFailed          = "auth.failed"
+)
+
+// auditJournalErrors counts audit events that could not be persisted
+var auditJournalErrors = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "pandacea_audit_journal_errors_total",
+	Help: "Audit events that could not be written to the audit journal",
+})
+
+// EventsResponse represents a page of audit or chain events
+type EventsResponse struct {
+	Data       []audit.Event `json:"data"`
+	NextCursor string        `json:"nextCursor"`
+	HasMore    bool          `json:"hasMore"`
+	Watermark  uint64        `json:"watermark"`
+}
+
+// AuditVerifyResponse reports the state of the audit journal's hash chain
+type AuditVerifyResponse struct {
+	Valid   bool   `json:"valid"`
+	Records int    `json:"records"`
+	LastSeq uint64 `json:"lastSeq"`
+	Head    string `json:"head"`            // Hash of the last intact record
+	Error   string `json:"error,omitempty"` // Where the chain breaks
+}
+
+// recordAudit appends an entry to the audit log
+func (server *Server) recordAudit(eventType, actor string, fields map[string]any) {
+	server.auditLog.Append(eventType, actor, fields)
+}
+
+// SetAuditJournal persists the audit log to a hash-chained journal. Events
+// already in the journal are loaded, so audit cursors survive restarts.
+func (server *Server) SetAuditJournal(journal *audit.Journal) error {
+	err := server.auditLog.SetJournal(journal, func(err error) {
+		auditJournalErrors.Inc()
+		server.logger.Error("failed to write audit journal", "error", err)
+	})
+	if err != nil {
+		return err
+	}
+	server.journal = journal
+	return nil
+}
+
+// onComputationStatus audits a computation's status change and streams it
+// to the spender that queued it
+func (server *Server) onComputationStatus(computationID, status string) {
+	server.recordAudit(AuditComputationFinished, "", map[string]any{
+		"computation_id": computationID,
+		"status":         status,
+	})
+	server.publishComputationStatus(computationID, status)
+}
+
+// handleExportAuditJournal handles GET /api/v1/admin/security/audit/export.
+// It streams the journal as newline-delimited records; the chain head in
+// X-Pandacea-Audit-Head pins the export so later truncation is detectable.
+func (server *Server) handleExportAuditJournal(w http.ResponseWriter, r *http.Request) {
+	if server.journal == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Audit journal is not enabled")
+		return
+	}
+
+	var buf bytes.Buffer
+	exported, err := server.journal.Export(&buf)
+	if err != nil {
+		server.logger.Error("failed to export audit journal", "error", err)
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to export audit journal")
+		return
+	}
+	server.recordAudit(AuditAdminExport, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"records": exported.Records,
+		"head":    exported.Head,
+	})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-journal.ndjson"`)
+	w.Header().Set("X-Pandacea-Audit-Head", exported.Head)
+	w.Header().Set("X-Pandacea-Audit-Records", strconv.Itoa(exported.Records))
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		server.logger.Error("failed to write audit export", "error", err)
+	}
+}
+
+// handleVerifyAuditJournal handles GET /api/v1/admin/security/audit/verify.
+// It re-reads the journal from disk and checks every hash.
+func (server *Server) handleVerifyAuditJournal(w http.ResponseWriter, r *http.Request) {
+	if server.journal == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Audit journal is not enabled")
+		return
+	}
+
+	verification, err := server.journal.Verify()
+	response := AuditVerifyResponse{
+		Valid:   err == nil,
+		Records: verification.Records,
+		LastSeq: verification.LastSeq,
+		Head:    verification.Head,
+	}
+	if err != nil {
+		server.logger.Error("audit journal failed verification", "error", err)
+		response.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode audit verification", "error", err)
+	}
+}
+
+// RecordChainEvent indexes a contract event observed by the blockchain listener
+func (server *Server) RecordChainEvent(name string, blockNumber uint64, txHash string, logIndex uint, fields map[string]any) {
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	fields["block_number"] = blockNumber
+	fields["tx_hash"] = txHash
+	fields["log_index"] = logIndex
+	server.chainEvents.Append(name, "", fields)
+
+	// Leases this agent created as a spender are matched to their proposals
+	if name == "LeaseCreated" && server.spender != nil {
+		leaseID, _ := fields["lease_id"].(string)
+		spenderAddr, _ := fields["spender"].(string)
+		server.spender.RecordLeaseCreated(txHash, leaseID, spenderAddr)
+	}
+}
+
+// handleGetAuditEvents handles GET /api/v1/admin/security/audit/events. The
+// log records every peer's actions and failed logins, so only operators may
+// page through it.
+func (server *Server) handleGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query, ok := server.parseEventsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	server.sendEventsPage(w, r, server.auditLog, query)
+}
+
+// handleGetChainEvents handles GET /api/v1/events
+func (server *Server) handleGetChainEvents(w http.ResponseWriter, r *http.Request) {
+	query, ok := server.parseEventsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if fromBlock := r.URL.Query().Get("from_block"); fromBlock != "" {
+		block, err := strconv.ParseUint(fromBlock, 10, 64)
+		if err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "from_block must be a non-negative integer")
+			return
+		}
+		query.Match = func(event audit.Event) bool {
+			n, ok := eventBlockNumber(event)
+			return ok && n >= block
+		}
+	}
+
+	server.sendEventsPage(w, r, server.chainEvents, query)
+}
+
+// eventBlockNumber returns a chain event's block number. It is recorded as
+// a uint64 but decodes as a float64 or json.Number once the event has been
+// through JSON.
+func eventBlockNumber(event audit.Event) (uint64, bool) {
+	switch n := event.Fields["block_number"].(type) {
+	case uint64:
+		return n, true
+	case float64:
+		return uint64(n), n >= 0
+	case json.Number:
+		v, err := strconv.ParseUint(n.String(), 10, 64)
+		return v, err == nil
+	}
+	return 0, false
+}
+
+// parseEventsQuery reads the cursor, since, type and limit query parameters
+func (server *Server) parseEventsQuery(w http.ResponseWriter, r *http.Request) (audit.Query, bool) {
+	params := r.URL.Query()
+	query := audit.Query{
+		Cursor: params.Get("cursor"),
+		Type:   params.Get("type"),
+	}
+
+	if since := params.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "since must be an RFC 3339 timestamp")
+			return query, false
+		}
+		query.Since = t
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > audit.MaxPageSize {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "limit must be between 1 and 500")
+			return query, false
+		}
+		query.Limit = n
+	}
+
+	return query, true
+}
+
+// sendEventsPage lists a page from an event log and writes it as JSON
+func (server *Server) sendEventsPage(w http.ResponseWriter, r *http.Request, log *audit.Log, query audit.Query) {
+	page, err := log.List(query)
+	switch {
+	case errors.Is(err, audit.ErrInvalidCursor):
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Invalid cursor")
+		return
+	case errors.Is(err, audit.ErrCursorExpired):
+		server.sendErrorResponse(w, r, http.StatusGone, ErrorCodeCursorExpired, "Cursor is older than the retained events; restart from a since watermark")
+		return
+	case err != nil:
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to list events")
+		return
+	}
+
+	response := EventsResponse{
+		Data:       page.Events,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+		Watermark:  page.Watermark,
+	}
+	if response.Data == nil {
+		response.Data = []audit.Event{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode events response", "error", err)
+	}
+}
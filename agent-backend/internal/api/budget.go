@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetBudgetLedger enables per-dataset privacy budget accounting for training
+// jobs. Restored jobs that already finished settle their charges, so a
+// ledger set after SetJobStore stays consistent with the restored jobs.
+func (server *Server) SetBudgetLedger(ledger *privacy.BudgetLedger) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	server.budgets = ledger
+	for _, job := range server.jobs {
+		if trainingJobs.IsTerminal(jobs.State(job.Status)) {
+			server.settleBudget(job)
+		}
+	}
+}
+
+// settleBudget charges a finished job's privacy spend to its dataset. A
+// complete job pays the epsilon accounted from its artifact, or its declared
+// epsilon if it was not accounted; a failed job's reservation is released.
+// Caller must hold jobsMutex.
+func (server *Server) settleBudget(job *TrainingJob) {
+	if server.budgets == nil {
+		return
+	}
+
+	var err error
+	if job.Status == string(TrainingStatusComplete) {
+		spent := job.Epsilon
+		if job.DPReport != nil {
+			spent = job.DPReport.Epsilon
+		}
+		err = server.budgets.Settle(job.JobID, spent)
+	} else {
+		err = server.budgets.Release(job.JobID)
+	}
+	if err != nil {
+		server.logger.Error("failed to settle privacy budget", "job_id", job.JobID, "dataset", job.Dataset, "error", err)
+	}
+}
+
+// handleGetPrivacyBudget handles GET /api/v1/privacy/budget/{dataset}
+func (server *Server) handleGetPrivacyBudget(w http.ResponseWriter, r *http.Request) {
+	if server.budgets == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Privacy budget accounting is not enabled")
+		return
+	}
+
+	budget := server.budgets.Budget(chi.URLParam(r, "dataset"))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(budget); err != nil {
+		server.logger.Error("failed to encode privacy budget", "error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/remotecfg"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// catalogName is the name catalog signatures are made under, the same as
+// for a remote products.json
+const catalogName = "products"
+
+// ErrCatalogUnsigned is returned in strict mode for a catalog without a
+// signature file
+var ErrCatalogUnsigned = errors.New("product catalog is not signed")
+
+// catalogPaths are searched for products.json when no path is configured
+var catalogPaths = []string{
+	"products.json",
+	"../products.json",
+	"../../products.json",
+	"./products.json",
+}
+
+// catalogTrust is who may sign the local product catalog. version is the
+// newest signed version seen, which older signatures may not roll back.
+type catalogTrust struct {
+	path    string
+	verify  bool
+	strict  bool
+	keys    []crypto.PubKey
+	wallets []common.Address
+	version uint64
+}
+
+// SetCatalog verifies the product catalog against its detached signature,
+// trusting agentKey and the signers cfg lists, then serves it. In strict
+// mode an unsigned catalog or one that fails verification is not served
+// and its error is returned.
+func (server *Server) SetCatalog(cfg config.CatalogConfig, agentKey crypto.PubKey) error {
+	keys, err := remotecfg.ParseKeys(cfg.SignerKeys)
+	if err != nil {
+		return err
+	}
+	wallets, err := remotecfg.ParseWallets(cfg.SignerWallets)
+	if err != nil {
+		return err
+	}
+	if agentKey != nil {
+		keys = append(keys, agentKey)
+	}
+	server.catalog = catalogTrust{path: cfg.Path, verify: cfg.Verify, strict: cfg.Strict, keys: keys, wallets: wallets, version: server.catalog.version}
+
+	products, err := server.ReadCatalog()
+	if err != nil {
+		server.ReplaceProducts(nil)
+		return err
+	}
+	server.ReplaceProducts(products)
+	return nil
+}
+
+// CatalogPath returns the product catalog's path: the configured one, or
+// the first of the searched paths that exists
+func (server *Server) CatalogPath() string {
+	if server.catalog.path != "" {
+		return server.catalog.path
+	}
+	for _, path := range catalogPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return catalogPaths[0]
+}
+
+// ReadCatalog reads the product catalog and, if verification is on,
+// checks its signature in path.sig. Outside strict mode a catalog that
+// fails verification is logged and returned anyway. A missing catalog is
+// an empty one.
+func (server *Server) ReadCatalog() ([]DataProduct, error) {
+	path := server.CatalogPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		server.logger.Warn("products.json not found in any expected location, starting with empty product list", "path", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product catalog: %w", err)
+	}
+	server.logger.Info("found products.json at", "path", path)
+
+	if server.catalog.verify {
+		if err := server.verifyCatalog(path, data); err != nil {
+			if server.catalog.strict {
+				return nil, err
+			}
+			server.logger.Warn("serving product catalog that failed verification", "path", path, "error", err)
+		} else {
+			server.logger.Info("product catalog signature verified", "path", path)
+		}
+	}
+	return parseProducts(data)
+}
+
+// verifyCatalog checks the detached signature next to the catalog at path
+// and that it is not older than the newest version already verified
+func (server *Server) verifyCatalog(path string, data []byte) error {
+	signature, err := os.ReadFile(path + ".sig")
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s.sig does not exist", ErrCatalogUnsigned, path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read product catalog signature: %w", err)
+	}
+	version, err := remotecfg.VerifySigners(server.catalog.keys, server.catalog.wallets, catalogName, data, signature)
+	if err != nil {
+		return err
+	}
+	if version < server.catalog.version {
+		return fmt.Errorf("%w: product catalog version %d, already verified %d", remotecfg.ErrStaleVersion, version, server.catalog.version)
+	}
+	server.catalog.version = version
+	return nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/remotecfg"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SetCatalog(t *testing.T) {
+	server := NewServer(denyEvaluator{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+	agentKey, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	wallet, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "products.json")
+	content := []byte(`[{"productId":"did:pandacea:earner:1/a","name":"Sensors"}]`)
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	sign := func(sig string, err error) {
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path+".sig", []byte(sig+"\n"), 0644))
+	}
+	served := server.products.Len
+	cfg := config.CatalogConfig{Path: path, Verify: true, Strict: true, SignerWallets: []string{ethcrypto.PubkeyToAddress(wallet.PublicKey).Hex()}}
+
+	// Strict mode refuses an unsigned catalog
+	err = server.SetCatalog(cfg, agentKey.GetPublic())
+	assert.True(t, errors.Is(err, ErrCatalogUnsigned), "error = %v", err)
+	assert.Zero(t, served())
+
+	// The agent's key and the listed wallets are trusted
+	sign(remotecfg.Sign(agentKey, catalogName, 1, content))
+	require.NoError(t, server.SetCatalog(cfg, agentKey.GetPublic()))
+	assert.Equal(t, 1, served())
+	sign(remotecfg.SignWallet(wallet, catalogName, 2, content))
+	require.NoError(t, server.SetCatalog(cfg, agentKey.GetPublic()))
+
+	// An older signature cannot roll the catalog back
+	sign(remotecfg.Sign(agentKey, catalogName, 1, content))
+	_, err = server.ReadCatalog()
+	assert.True(t, errors.Is(err, remotecfg.ErrStaleVersion), "error = %v", err)
+	sign(remotecfg.Sign(agentKey, catalogName, 2, content))
+
+	// A tampered catalog is refused in strict mode and served with a
+	// warning otherwise
+	require.NoError(t, os.WriteFile(path, []byte(`[]`), 0644))
+	_, err = server.ReadCatalog()
+	assert.True(t, errors.Is(err, remotecfg.ErrInvalidSignature), "error = %v", err)
+	cfg.Strict = false
+	require.NoError(t, server.SetCatalog(cfg, agentKey.GetPublic()))
+	assert.Zero(t, served())
+
+	// Other keys do not vouch for the catalog
+	other, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	sign(remotecfg.Sign(other, catalogName, 3, content))
+	cfg.Strict = true
+	assert.Error(t, server.SetCatalog(cfg, agentKey.GetPublic()))
+}
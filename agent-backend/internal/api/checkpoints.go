@@ -0,0 +1,297 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// checkpointManifestFile lists a job's checkpoints in its checkpoint directory
+const checkpointManifestFile = "manifest.json"
+
+// keptCheckpoints is how many checkpoints a run keeps; older ones are
+// deleted as new ones are written
+const keptCheckpoints = 3
+
+// TrainingCheckpoint is a snapshot of a job's training state a worker
+// wrote at the end of an epoch
+type TrainingCheckpoint struct {
+	Epoch     int       `json:"epoch"`             // Epochs completed when the checkpoint was written
+	File      string    `json:"file"`              // Checkpoint file, relative to the checkpoint directory
+	SHA256    string    `json:"sha256"`            // Hex SHA-256 of the checkpoint file
+	Samples   int       `json:"samples_processed"` // Samples processed up to the checkpoint
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CheckpointManifest lists a job's checkpoints, oldest first. Workers
+// write the checkpoint file before they replace the manifest, so every
+// entry refers to a complete file.
+type CheckpointManifest struct {
+	JobID       string               `json:"job_id"`
+	Checkpoints []TrainingCheckpoint `json:"checkpoints"`
+}
+
+// ResumeResponse is the response of POST /api/v1/train/{jobId}/resume
+type ResumeResponse struct {
+	JobID      string             `json:"job_id"`
+	Checkpoint TrainingCheckpoint `json:"checkpoint"` // Checkpoint the job resumes from
+}
+
+// checkpointDir is where a job's checkpoints are kept
+func (server *Server) checkpointDir(jobID string) string {
+	return filepath.Join(server.productsDir, jobID, "checkpoints")
+}
+
+// readCheckpointManifest reads the manifest in dir. A directory without
+// one has no checkpoints.
+func readCheckpointManifest(dir string) (*CheckpointManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &CheckpointManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest CheckpointManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// latestCheckpoint returns the newest checkpoint in dir whose file is
+// intact, skipping entries whose file is missing or does not match its
+// digest, or nil if there is none
+func latestCheckpoint(dir string) (*TrainingCheckpoint, error) {
+	manifest, err := readCheckpointManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(manifest.Checkpoints) - 1; i >= 0; i-- {
+		checkpoint := manifest.Checkpoints[i]
+		if checkpoint.File == "" || filepath.Base(checkpoint.File) != checkpoint.File {
+			continue
+		}
+		if sum, err := fileSHA256(filepath.Join(dir, checkpoint.File)); err == nil && sum == checkpoint.SHA256 {
+			return &checkpoint, nil
+		}
+	}
+	return nil, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ResumePath is the checkpoint file the run resumes from, or empty for a
+// run that starts fresh
+func (run *TrainingRun) ResumePath() string {
+	if run.Resume == nil || run.CheckpointDir == "" {
+		return ""
+	}
+	return filepath.Join(run.CheckpointDir, run.Resume.File)
+}
+
+// SaveCheckpoint writes state as the checkpoint for a completed epoch, for
+// backends that train in-process. It follows the worker contract: the
+// file is written first, then the manifest is replaced atomically.
+func (run *TrainingRun) SaveCheckpoint(epoch, samples int, state []byte) error {
+	if run.CheckpointDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(run.CheckpointDir, 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	manifest, err := readCheckpointManifest(run.CheckpointDir)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("epoch-%04d.ckpt", epoch)
+	if err := os.WriteFile(filepath.Join(run.CheckpointDir, name), state, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	sum := sha256.Sum256(state)
+	manifest.JobID = run.JobID
+	manifest.Checkpoints = append(manifest.Checkpoints, TrainingCheckpoint{
+		Epoch:     epoch,
+		File:      name,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Samples:   samples,
+		CreatedAt: time.Now().UTC(),
+	})
+	var pruned []TrainingCheckpoint
+	if extra := len(manifest.Checkpoints) - keptCheckpoints; extra > 0 {
+		pruned = manifest.Checkpoints[:extra]
+		manifest.Checkpoints = manifest.Checkpoints[extra:]
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(filepath.Join(run.CheckpointDir, checkpointManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint manifest: %w", err)
+	}
+	for _, old := range pruned {
+		if old.File != name {
+			os.Remove(filepath.Join(run.CheckpointDir, filepath.Base(old.File)))
+		}
+	}
+	return nil
+}
+
+// recordCheckpoint notes the latest intact checkpoint of a job whose run
+// ended, so its status shows whether it can be resumed
+func (server *Server) recordCheckpoint(jobID string) {
+	checkpoint, err := latestCheckpoint(server.checkpointDir(jobID))
+	if err != nil {
+		server.logger.Warn("failed to read training checkpoints", "job_id", jobID, "error", err)
+	}
+
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	if job, exists := server.jobs[jobID]; exists {
+		job.Checkpoint = checkpoint
+	}
+}
+
+// handleResumeTraining handles POST /api/v1/train/{jobId}/resume. A
+// failed job is queued again and its worker continues from the last
+// intact checkpoint rather than from scratch. Only the peer that queued
+// the job, or an admin, may resume it.
+func (server *Server) handleResumeTraining(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobId")
+	peerID := r.Header.Get(reqsig.HeaderPeerID)
+
+	server.jobsMutex.RLock()
+	job, exists := server.jobs[jobID]
+	var snapshot TrainingJob
+	if exists {
+		snapshot = *job
+	}
+	server.jobsMutex.RUnlock()
+	if !exists {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Job not found")
+		return
+	}
+	if snapshot.owner != "" && snapshot.owner != peerID && (server.securityService == nil || !server.securityService.IsAdmin(peerID)) {
+		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Only the peer that queued the job may resume it")
+		return
+	}
+	if snapshot.Federation != nil || snapshot.FederationID != "" {
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeNotResumable, "Federated jobs and their rounds cannot be resumed")
+		return
+	}
+	if snapshot.Status != string(TrainingStatusFailed) {
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeNotResumable, "Only failed jobs can be resumed")
+		return
+	}
+	if server.rejectQuarantined(w, r, snapshot.Dataset, map[string]any{"task": snapshot.Task, "job_id": jobID}) {
+		return
+	}
+
+	checkpoint, err := latestCheckpoint(server.checkpointDir(jobID))
+	if err != nil {
+		server.logger.Error("failed to read training checkpoints", "job_id", jobID, "error", err)
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to read the job's checkpoints")
+		return
+	}
+	if checkpoint == nil {
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeNoCheckpoint, "Job has no checkpoint to resume from")
+		return
+	}
+
+	// The failed run released its reservation, so the resumed run holds
+	// the job's epsilon again until it finishes
+	if server.budgets != nil {
+		if err := server.budgets.Reserve(snapshot.Dataset, jobID, snapshot.Epsilon); err != nil {
+			server.logger.Warn("training job resume rejected by privacy budget", "job_id", jobID, "dataset", snapshot.Dataset, "error", err)
+			server.sendError(w, r, err, "Failed to reserve privacy budget")
+			return
+		}
+	}
+
+	server.jobsMutex.Lock()
+	if job.Status != string(TrainingStatusFailed) {
+		// Resumed by a concurrent request
+		server.jobsMutex.Unlock()
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeNotResumable, "Only failed jobs can be resumed")
+		return
+	}
+	status, err := trainingJobs.Restart(jobs.State(job.Status))
+	if err != nil {
+		server.jobsMutex.Unlock()
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeNotResumable, "Job cannot be resumed")
+		return
+	}
+	previous := *job
+	job.Status = string(status)
+	job.Error = ""
+	job.CompletedAt = nil
+	job.UpdatedAt = time.Now()
+	job.Checkpoint = checkpoint
+	job.Resumes++
+	if job.GPU != nil {
+		job.GPU = &gpu.Allocation{Count: job.GPU.Count}
+	}
+	job.trace = trace.SpanContextFromContext(r.Context())
+	job.startedAt, job.cpuTime, job.peakMemory = time.Time{}, 0, 0
+	if err := server.queueTrainingJob(job, requestIdentity(r), server.leasePriority(job.leaseID), nil); err != nil {
+		trainingJobs.Forget(status)
+		trainingJobs.Restore(jobs.State(previous.Status))
+		*job = previous
+		server.jobsMutex.Unlock()
+		if server.budgets != nil {
+			if err := server.budgets.Release(jobID); err != nil {
+				server.logger.Error("failed to release privacy budget", "job_id", jobID, "error", err)
+			}
+		}
+		server.logger.Warn("resumed training job rejected by scheduler", "job_id", jobID, "error", err)
+		server.sendError(w, r, err, "Failed to queue training job")
+		return
+	}
+	log := server.jobLog(jobID)
+	log.reopen()
+	log.append("stdout", fmt.Sprintf("Resuming from checkpoint at epoch %d", checkpoint.Epoch))
+	server.persistJob(job)
+	server.publishJobProgress(job)
+	server.jobsMutex.Unlock()
+
+	server.recordAudit(AuditTrainingResumed, peerID, map[string]any{
+		"job_id":  jobID,
+		"dataset": snapshot.Dataset,
+		"epoch":   checkpoint.Epoch,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ResumeResponse{JobID: jobID, Checkpoint: *checkpoint})
+
+	server.logger.Info("training job resumed", "job_id", jobID, "epoch", checkpoint.Epoch)
+}
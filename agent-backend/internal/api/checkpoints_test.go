@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resumingBackend records the checkpoint each run starts from
+type resumingBackend struct {
+	resumed chan *TrainingCheckpoint
+}
+
+func (resumingBackend) Name() string                 { return "resuming" }
+func (resumingBackend) Health(context.Context) error { return nil }
+
+func (b resumingBackend) Train(_ context.Context, run *TrainingRun) error {
+	b.resumed <- run.Resume
+	return os.WriteFile(run.AggregatePath(), []byte(`{"n": 1000, "training_params": {"epochs": 4, "batch_size": 10}}`), 0644)
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	run := &TrainingRun{JobID: "job-1", CheckpointDir: filepath.Join(t.TempDir(), "checkpoints")}
+	for epoch := 1; epoch <= 5; epoch++ {
+		require.NoError(t, run.SaveCheckpoint(epoch, epoch*100, []byte{byte(epoch)}))
+	}
+
+	// Only the newest checkpoints are kept
+	manifest, err := readCheckpointManifest(run.CheckpointDir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Checkpoints, keptCheckpoints)
+	assert.Equal(t, 3, manifest.Checkpoints[0].Epoch)
+	_, err = os.Stat(filepath.Join(run.CheckpointDir, "epoch-0001.ckpt"))
+	assert.True(t, os.IsNotExist(err), "pruned checkpoint files are removed")
+
+	// A checkpoint that no longer matches its digest is skipped
+	require.NoError(t, os.WriteFile(filepath.Join(run.CheckpointDir, "epoch-0005.ckpt"), []byte("torn"), 0644))
+	latest, err := latestCheckpoint(run.CheckpointDir)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, 4, latest.Epoch)
+	assert.Equal(t, 400, latest.Samples)
+
+	none, err := latestCheckpoint(t.TempDir())
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestServer_resumeTraining(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	server.SetProductsDir(t.TempDir())
+	backend := resumingBackend{resumed: make(chan *TrainingCheckpoint, 1)}
+	server.SetTrainingBackend(backend)
+
+	now := time.Now()
+	addJob := func(jobID, status string) *TrainingJob {
+		job := &TrainingJob{JobID: jobID, Status: status, Dataset: "mnist", Task: "classify", CreatedAt: now, UpdatedAt: now, owner: "peer-1"}
+		server.jobs[jobID] = job
+		return job
+	}
+	failed := addJob("job-resume-failed", string(TrainingStatusFailed))
+	failed.Error = "worker killed"
+	addJob("job-resume-bare", string(TrainingStatusFailed))
+	addJob("job-resume-done", string(TrainingStatusComplete))
+	round := addJob("job-resume-round", string(TrainingStatusFailed))
+	round.FederationID = "fed-1"
+
+	run := &TrainingRun{JobID: failed.JobID, CheckpointDir: server.checkpointDir(failed.JobID)}
+	for epoch := 1; epoch <= 2; epoch++ {
+		require.NoError(t, run.SaveCheckpoint(epoch, epoch*1000, []byte(`{"epoch": 1}`)))
+	}
+
+	resume := func(jobID, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/train/"+jobID+"/resume", nil)
+		req.Header.Set(reqsig.HeaderPeerID, peerID)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobId", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleResumeTraining(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, resume("job-missing", "peer-1").Code)
+	assert.Equal(t, http.StatusForbidden, resume(failed.JobID, "peer-2").Code)
+	w := resume("job-resume-done", "peer-1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeNotResumable)
+	assert.Equal(t, http.StatusConflict, resume(round.JobID, "peer-1").Code)
+	w = resume("job-resume-bare", "peer-1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeNoCheckpoint)
+
+	w = resume(failed.JobID, "peer-1")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response ResumeResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, 2, response.Checkpoint.Epoch)
+
+	select {
+	case checkpoint := <-backend.resumed:
+		require.NotNil(t, checkpoint)
+		assert.Equal(t, 2, checkpoint.Epoch)
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed job was not run")
+	}
+	// A completed job's checkpoints are removed
+	require.Eventually(t, func() bool {
+		server.jobsMutex.RLock()
+		defer server.jobsMutex.RUnlock()
+		_, err := os.Stat(server.checkpointDir(failed.JobID))
+		return failed.Status == string(TrainingStatusComplete) && os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	server.jobsMutex.RLock()
+	assert.Equal(t, 1, failed.Resumes)
+	assert.Empty(t, failed.Error)
+	server.jobsMutex.RUnlock()
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// peerIDURIScheme is the scheme of the URI SAN, libp2p:<peer ID>, that
+// names the peer a client certificate was issued to
+const peerIDURIScheme = "libp2p"
+
+// clientCertKey is the request context key for the identity a client
+// certificate authenticated
+type clientCertKey struct{}
+
+// certIdentity returns the identity a verified client certificate
+// authenticates: the one http.client_identities maps its fingerprint or
+// subject common name to, or else the peer ID in a libp2p: URI SAN. A
+// common name alone names a holder, not a peer, so such certificates
+// authenticate nobody and their requests must still be signed.
+func (server *Server) certIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	identities := server.httpConfig.ClientIdentities
+	sum := sha256.Sum256(cert.Raw)
+	if identity, ok := identities["sha256:"+hex.EncodeToString(sum[:])]; ok {
+		return identity, true
+	}
+	if identity, ok := identities[cert.Subject.CommonName]; ok {
+		return identity, true
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme != peerIDURIScheme {
+			continue
+		}
+		if id, err := peer.Decode(uri.Opaque); err == nil {
+			return id.String(), true
+		}
+	}
+	return "", false
+}
+
+// clientCertIdentity returns the identity a client certificate
+// authenticated for r, if clientCertMiddleware found one
+func clientCertIdentity(r *http.Request) (string, bool) {
+	identity, ok := r.Context().Value(clientCertKey{}).(string)
+	return identity, ok
+}
+
+// clientCertMiddleware authenticates callers that present a verified client
+// certificate as the certificate's identity. The identity becomes the
+// request's peer ID, so quotas, ownership and audit records follow the
+// certificate; a conflicting peer ID header is refused.
+func (server *Server) clientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := server.certIdentity(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if claimed := r.Header.Get(reqsig.HeaderPeerID); claimed != "" && claimed != identity {
+			server.logger.Warn("peer ID header does not match client certificate", "peer_id", claimed, "certificate_identity", identity)
+			server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Peer ID header does not match the client certificate")
+			return
+		}
+		r.Header.Set(reqsig.HeaderPeerID, identity)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientCertKey{}, identity)))
+	})
+}
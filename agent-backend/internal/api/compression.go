@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"pandacea/agent-backend/internal/config"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var compressedResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "pandacea_http_compressed_responses_total",
+	Help: "Responses sent with a Content-Encoding, by encoding",
+}, []string{"encoding"})
+
+// encoderPools reuse compressors across responses, keyed by encoding name
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+	"zstd": {New: func() any {
+		// Concurrency 1 keeps each pooled encoder to a single goroutine
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// encoder is the part of the gzip and zstd writers the middleware needs
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// SetCompressionConfig sets which responses are compressed. Compression is
+// disabled until this is called.
+func (server *Server) SetCompressionConfig(cfg config.CompressionConfig) {
+	server.compression = cfg
+}
+
+// compressionMiddleware compresses responses for clients that accept one of
+// the configured encodings. Responses below the size threshold, with a media
+// type outside the allowlist, or already encoded are sent unchanged.
+func (server *Server) compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := server.compression
+		if !cfg.Enabled || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the first of supported that acceptEncoding allows
+// with a non-zero quality, or "" if none is
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		if _, ok := encoderPools[encoding]; !ok {
+			continue
+		}
+		q, ok := quality[encoding]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// the response is large enough and of a type worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      config.CompressionConfig
+	encoding string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf.Write(p)
+		if cw.buf.Len() < cw.cfg.MinSizeBytes {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Flush sends what has been written so far, deciding on compression early
+// if needed, so streaming handlers keep working
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(cw.buf.Len() >= cw.cfg.MinSizeBytes)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the response once the handler returns
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing was written; let net/http send its default response
+			return
+		}
+		cw.decide(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// decide writes the headers and buffered bytes, compressing them if large
+// is set and the response is eligible
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if large && cw.compressible(h) {
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		compressedResponsesTotal.WithLabelValues(cw.encoding).Inc()
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// compressible reports whether the response's status and headers allow it
+// to be compressed
+func (cw *compressWriter) compressible(h http.Header) bool {
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, allowed := range cw.cfg.ContentTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_negotiateEncoding(t *testing.T) {
+	supported := []string{"zstd", "gzip"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"zstd;q=0.5, gzip", "gzip"},
+		{"zstd;q=0, gzip;q=0", ""},
+		{"*", "zstd"},
+		{"br", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateEncoding(tt.accept, supported), "Accept-Encoding %q", tt.accept)
+	}
+}
+
+func TestServer_compressionMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetCompressionConfig(config.CompressionConfig{
+		Enabled:      true,
+		MinSizeBytes: 100,
+		Encodings:    []string{"zstd", "gzip"},
+		ContentTypes: []string{"application/json"},
+	})
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+
+	large := `{"data":"` + strings.Repeat("a", 500) + `"}`
+	router := chi.NewRouter()
+	router.Use(server.compressionMiddleware)
+	router.With(server.signResponseMiddleware).Get("/api/v1/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, large)
+	})
+	router.Get("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{}`)
+	})
+	router.Get("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, large)
+	})
+
+	decode := map[string]func(io.Reader) ([]byte, error){
+		"gzip": func(r io.Reader) ([]byte, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(zr)
+		},
+		"zstd": func(r io.Reader) ([]byte, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		},
+	}
+
+	for _, encoding := range []string{"gzip", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/large", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.Less(t, w.Body.Len(), len(large))
+			body, err := decode[encoding](w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, large, string(body))
+
+			// The signature covers the uncompressed body
+			_, err = respsig.Verify(w.Header(), req, w.Code, body, signer.PeerID())
+			assert.NoError(t, err)
+		})
+	}
+
+	for name, path := range map[string]string{"below threshold": "/small", "type not allowed": "/binary"} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Empty(t, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		})
+	}
+
+	t.Run("client without accept-encoding", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/large", nil))
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+}
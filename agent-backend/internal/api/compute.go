@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"pandacea/agent-backend/internal/compute"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// QueueComputation implements compute.Handler. It queues a computation
+// sent over libp2p with the same checks as POST /api/v1/privacy/execute;
+// the stream's remote peer stands in for the request signature's peer.
+func (server *Server) QueueComputation(ctx context.Context, caller peer.ID, req compute.Request) (*privacy.ComputationResponse, error) {
+	if req.SpenderAddress == "" {
+		return nil, &compute.Error{Code: ErrorCodeUnauthorized, Message: "Spender address is required"}
+	}
+	if server.securityService != nil && server.securityService.CheckBackpressure() {
+		return nil, &compute.Error{Code: ErrorCodeBackpressure, Message: "Service temporarily unavailable due to high load"}
+	}
+
+	for _, input := range req.Computation.Inputs {
+		productID := server.assetProduct(input.AssetID)
+		if q, quarantined := server.quarantine(productID); quarantined {
+			server.logger.Warn("computation on quarantined product refused", "product_id", productID, "peer_id", caller.String())
+			server.recordAudit(AuditQuarantined, caller.String(), map[string]any{
+				"product_id": productID,
+				"lease_id":   req.Computation.LeaseID,
+				"protocol":   string(compute.ProtocolID),
+			})
+			if q.Erased {
+				return nil, &compute.Error{Code: ErrorCodeDataErased, Message: fmt.Sprintf("%s's data has been erased", productID)}
+			}
+			return nil, &compute.Error{Code: ErrorCodeQuarantined, Message: fmt.Sprintf("%s is quarantined: %s", productID, q.Reason)}
+		}
+	}
+
+	response, err := server.startComputation(ctx, req.Computation, req.SpenderAddress, caller.String(), req.Network)
+	if err != nil {
+		return nil, streamError(err)
+	}
+	return response, nil
+}
+
+// ComputationResult implements compute.Handler, returning the result of a
+// computation the caller queued
+func (server *Server) ComputationResult(ctx context.Context, caller peer.ID, computationID string) (*privacy.ComputationResult, error) {
+	result, err := server.ownComputationResult(ctx, computationID, caller.String())
+	if err != nil {
+		return nil, streamError(err)
+	}
+	return result, nil
+}
+
+// streamError returns the compute protocol error for err, with the code
+// it would get over HTTP. Unmapped errors are internal and returned as
+// they are, so the caller sees no details.
+func streamError(err error) error {
+	if _, code, ok := errorResponseFor(err); ok {
+		return &compute.Error{Code: code, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/compute"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ComputeStream(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &ownedPrivacyService{owners: make(map[string]string)}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+
+	newPeer := func() peer.ID {
+		_, pub, err := crypto.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+		id, err := peer.IDFromPublicKey(pub)
+		require.NoError(t, err)
+		return id
+	}
+	caller, other := newPeer(), newPeer()
+
+	queued, err := server.QueueComputation(context.Background(), caller, compute.Request{
+		Op:             compute.OpExecute,
+		Computation:    &privacy.ComputationRequest{LeaseID: "lease-1"},
+		SpenderAddress: "0xspender",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, caller.String(), privacyService.owners[queued.ComputationID], "the stream's peer owns the computation")
+
+	result, err := server.ComputationResult(context.Background(), caller, queued.ComputationID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", result.Status)
+
+	// Other peers are told the computation does not exist, in the API's terms
+	var refused *compute.Error
+	_, err = server.ComputationResult(context.Background(), other, queued.ComputationID)
+	require.True(t, errors.As(err, &refused), "error = %v", err)
+	assert.Equal(t, ErrorCodeNotFound, refused.Code)
+
+	_, err = server.QueueComputation(context.Background(), caller, compute.Request{Op: compute.OpExecute, Computation: &privacy.ComputationRequest{LeaseID: "lease-1"}})
+	require.True(t, errors.As(err, &refused), "error = %v", err)
+	assert.Equal(t, ErrorCodeUnauthorized, refused.Code)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/consent"
+)
+
+// maxPurposeLength bounds the free-text purpose of a lease request
+const maxPurposeLength = 500
+
+// SetConsent sets whether lease requests must declare a purpose and what
+// consent receipts state about the agent's jurisdiction and policy
+func (server *Server) SetConsent(cfg config.ConsentConfig) {
+	server.consent = cfg
+}
+
+// validateLeasePurpose checks the purpose a lease request declares
+func (server *Server) validateLeasePurpose(purpose, category string) error {
+	if category == "" {
+		if server.consent.RequirePurpose {
+			return fmt.Errorf("purposeCategory is required, one of: %s", strings.Join(consent.Categories, ", "))
+		}
+		if purpose != "" {
+			return fmt.Errorf("purpose needs a purposeCategory")
+		}
+		return nil
+	}
+	if !consent.ValidCategory(category) {
+		return fmt.Errorf("purposeCategory must be one of: %s", strings.Join(consent.Categories, ", "))
+	}
+	if len(purpose) > maxPurposeLength {
+		return fmt.Errorf("purpose must be at most %d characters", maxPurposeLength)
+	}
+	return nil
+}
+
+// setLeasePurpose records the purpose a lease is requested for
+func (server *Server) setLeasePurpose(leaseProposalID, purpose, category string) {
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	if state, exists := server.pendingLeases[leaseProposalID]; exists {
+		state.Purpose = purpose
+		state.PurposeCategory = category
+	}
+}
+
+// leasePurpose returns the purpose category a lease was granted for, by
+// proposal or on-chain lease ID, or "" if it was granted for none
+func (server *Server) leasePurpose(leaseID string) string {
+	if leaseID == "" {
+		return ""
+	}
+	server.leasesMutex.RLock()
+	defer server.leasesMutex.RUnlock()
+	state, exists := server.pendingLeases[leaseID]
+	if !exists {
+		state, exists = server.pendingLeases[chainLeaseProposalID(leaseID)]
+	}
+	if !exists {
+		return ""
+	}
+	return state.PurposeCategory
+}
+
+// issueConsentReceipt signs a consent receipt for a lease approved at
+// approvedAt, unless it declared no purpose, already has one or there is
+// no signer. Caller must hold leasesMutex.
+func (server *Server) issueConsentReceipt(leaseProposalID string, state *LeaseProposalState, approvedAt time.Time) {
+	if state.PurposeCategory == "" || state.ConsentReceipt != nil || server.responseSigner == nil {
+		return
+	}
+	receipt, err := consent.NewReceipt(consent.Grant{
+		ReceiptID:    leaseProposalID,
+		Earner:       state.EarnerAddr,
+		Spender:      state.SpenderAddr,
+		SpenderPeer:  state.owner,
+		ProductID:    state.ProductID,
+		Purpose:      state.Purpose,
+		Category:     state.PurposeCategory,
+		Jurisdiction: server.consent.Jurisdiction,
+		PolicyURL:    server.consent.PolicyURL,
+		Language:     server.consent.Language,
+		GrantedAt:    approvedAt,
+		ExpiresAt:    state.ExpiresAt,
+	})
+	if err == nil {
+		state.ConsentReceipt, err = consent.Sign(server.responseSigner, receipt)
+	}
+	if err != nil {
+		server.logger.Error("failed to issue consent receipt", "error", err, "lease_proposal_id", leaseProposalID)
+		return
+	}
+	server.recordAudit(AuditConsentIssued, state.SpenderAddr, map[string]any{
+		"lease_proposal_id": leaseProposalID,
+		"purpose_category":  state.PurposeCategory,
+		"receipt_sha256":    state.ConsentReceipt.Signature.SHA256,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/consent"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_leaseConsent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &recordingPrivacyService{}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+	server.SetConsent(config.ConsentConfig{RequirePurpose: true, Jurisdiction: "EU", Language: "en"})
+
+	propose := func(purpose, category string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(LeaseRequest{ProductID: "did:pandacea:earner:123/abc-456", MaxPrice: "0.01", Duration: "24h",
+			Purpose: purpose, PurposeCategory: category})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/leases", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		server.handleCreateLease(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, propose("", "").Code, "purpose is required")
+	assert.Equal(t, http.StatusBadRequest, propose("Churn study", "resale").Code)
+	w := propose("Churn study", consent.CategoryResearch)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var lease LeaseResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&lease))
+
+	// Approval issues a receipt for the declared purpose, signed by the agent
+	server.UpdateLeaseStatus(lease.LeaseProposalID, "approved", nil, "0xspender", "0xearner", nil)
+	receipt := server.pendingLeases[lease.LeaseProposalID].ConsentReceipt
+	require.NotNil(t, receipt)
+	peerID, err := consent.Verify(*receipt, signer.PeerID())
+	require.NoError(t, err)
+	assert.Equal(t, signer.PeerID(), peerID)
+	assert.Equal(t, "0xearner", receipt.Receipt.Principal)
+	assert.Equal(t, "EU", receipt.Receipt.Jurisdiction)
+	assert.Equal(t, []string{consent.CategoryResearch}, receipt.Receipt.Services[0].Purposes[0].Categories)
+
+	// Computations under the lease are bound to its purpose
+	execute := func(purpose string) *httptest.ResponseRecorder {
+		body := `{"lease_id":"` + lease.LeaseProposalID + `","purpose":"` + purpose + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute", strings.NewReader(body))
+		req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+		req.Header.Set(reqsig.HeaderPeerID, "12D3KooWSpender")
+		w := httptest.NewRecorder()
+		server.handleExecuteComputation(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusAccepted, execute(consent.CategoryMarketing).Code)
+	assert.Equal(t, consent.CategoryResearch, privacyService.last.LeasePurpose)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+
+	"pandacea/agent-backend/internal/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StakeReader reads the PGT stake raising a dispute against a lease takes
+type StakeReader interface {
+	RequiredStake(ctx context.Context, leaseID [32]byte) (*big.Int, error)
+}
+
+// SetContract sets the LeaseAgreement interface the default network's
+// contract speaks, as found by contracts.Probe. Endpoints relying on
+// methods it lacks answer 501 instead of sending calls that would revert.
+// stakes, if set, reads the stake disputes take.
+func (server *Server) SetContract(d contracts.Deployment, stakes StakeReader) {
+	server.contract = d
+	server.stakes = stakes
+}
+
+// requireContractMethod tells whether the contract has method. If not it
+// responds with 501 and returns false.
+func (server *Server) requireContractMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if server.contract.Supports(method) {
+		return true
+	}
+	server.sendErrorResponse(w, r, http.StatusNotImplemented, ErrorCodeUnsupported,
+		"The LeaseAgreement contract does not support "+method)
+	return false
+}
+
+// requiredStake returns the stake, in wei, a dispute against leaseID takes,
+// or "" if the contract cannot tell or leaseID is not an on-chain lease ID
+func (server *Server) requiredStake(ctx context.Context, leaseID string) string {
+	raw := common.FromHex(leaseID)
+	if server.stakes == nil || !server.contract.Supports("getRequiredStake") || len(raw) != 32 {
+		return ""
+	}
+	stake, err := server.stakes.RequiredStake(ctx, [32]byte(raw))
+	if err != nil {
+		server.logger.Warn("failed to read required dispute stake", "error", err, "lease_id", leaseID)
+		return ""
+	}
+	return stake.String()
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedStake reports the same stake for every lease
+type fixedStake int64
+
+func (s fixedStake) RequiredStake(ctx context.Context, leaseID [32]byte) (*big.Int, error) {
+	return big.NewInt(int64(s)), nil
+}
+
+func TestServer_ContractVersions(t *testing.T) {
+	server := NewServer(denyEvaluator{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+	router := chi.NewRouter()
+	router.Post("/leases/{leaseId}/finalize", server.handleFinalizeLease)
+	router.Post("/leases/{leaseId}/dispute", server.handleRaiseDispute)
+	leaseID := "0x" + strings.Repeat("ab", 32)
+	server.pendingLeases[chainLeaseProposalID(leaseID)] = &LeaseProposalState{Status: "approved", owner: "spender-peer"}
+	raise := func() DisputeResponse {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/leases/"+leaseID+"/dispute", strings.NewReader(`{"reason":"bad data"}`))
+		req.Header.Set(reqsig.HeaderPeerID, "spender-peer")
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp DisputeResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("v2 contract", func(t *testing.T) {
+		server.SetContract(contracts.Deployment{Version: 2}, fixedStake(250))
+		assert.Equal(t, "250", raise().RequiredStake)
+
+		// Finalizing is supported, so it only fails for want of a sender
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/leases/"+leaseID+"/finalize", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("v1 contract", func(t *testing.T) {
+		server.SetContract(contracts.Deployment{Version: 1, Missing: []string{"finalizeLease", "getRequiredStake"}}, fixedStake(250))
+		assert.Empty(t, raise().RequiredStake)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/leases/"+leaseID+"/finalize", nil))
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assert.Contains(t, w.Body.String(), ErrorCodeUnsupported)
+	})
+}
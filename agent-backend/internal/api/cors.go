@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+// SetCORSConfig sets which browser origins may call the API. Cross-origin
+// requests get no CORS headers until this is called.
+func (server *Server) SetCORSConfig(cfg config.CORSConfig) {
+	server.cors = cfg
+}
+
+// corsMiddleware adds CORS headers for allowed origins and answers their
+// preflight requests, which browsers send without signatures, before any
+// other middleware sees them. Requests from other origins get no CORS
+// headers, so browsers keep scripts from reading the responses.
+func (server *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := server.cors
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(cfg.AllowedOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !originAllowed(origin, cfg.AllowedOrigins) {
+			if preflight {
+				server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Origin is not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		if cfg.AllowCredentials || !slices.Contains(cfg.AllowedOrigins, "*") {
+			header.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !containsFold(cfg.AllowedMethods, method) {
+				server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Method "+method+" is not allowed")
+				return
+			}
+			header.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			if len(cfg.AllowedHeaders) > 0 {
+				header.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			}
+			if cfg.MaxAgeSeconds > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAgeSeconds))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if len(cfg.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originAllowed reports whether origin matches one of allowed. A pattern
+// may contain one "*", which matches any run of characters, so
+// "https://*.example.com" allows every subdomain of example.com.
+func originAllowed(origin string, allowed []string) bool {
+	for _, pattern := range allowed {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if !wildcard {
+			if strings.EqualFold(origin, pattern) {
+				return true
+			}
+			continue
+		}
+		if len(origin) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
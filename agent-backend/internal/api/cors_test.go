@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_originAllowed(t *testing.T) {
+	allowed := []string{"https://dashboard.example.org", "https://*.pandacea.io"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://dashboard.example.org", true},
+		{"HTTPS://Dashboard.Example.org", true},
+		{"https://app.pandacea.io", true},
+		{"https://a.b.pandacea.io", true},
+		{"https://pandacea.io", false},
+		{"http://app.pandacea.io", false},
+		{"https://evil.example.org", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, originAllowed(tt.origin, allowed), "origin %q", tt.origin)
+	}
+}
+
+func TestServer_corsMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetCORSConfig(config.CORSConfig{
+		AllowedOrigins:   []string{"https://dashboard.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "X-Pandacea-Signature"},
+		ExposedHeaders:   []string{"X-API-Version"},
+		AllowCredentials: true,
+		MaxAgeSeconds:    600,
+	})
+
+	reached := false
+	router := chi.NewRouter()
+	router.Use(server.corsMiddleware)
+	router.Get("/api/v1/products", func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+	serve := func(method, origin, requestMethod string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/products", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Preflights are answered without reaching the handler
+	w := serve(http.MethodOptions, "https://dashboard.example.org", "POST")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://dashboard.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, X-Pandacea-Signature", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.False(t, reached)
+
+	w = serve(http.MethodOptions, "https://dashboard.example.org", "DELETE")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(http.MethodOptions, "https://evil.example.org", "GET")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = serve(http.MethodGet, "https://dashboard.example.org", "")
+	assert.True(t, reached)
+	assert.Equal(t, "https://dashboard.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-API-Version", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	// Other origins reach the handler but get no CORS headers
+	reached = false
+	w = serve(http.MethodGet, "https://evil.example.org", "")
+	assert.True(t, reached)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Cost headers sent on every /api/v1 response
+const (
+	headerCost          = "X-Pandacea-Cost"
+	headerCostSpent     = "X-Pandacea-Cost-Spent"
+	headerCostRemaining = "X-Pandacea-Cost-Remaining"
+	headerCostReset     = "X-Pandacea-Cost-Reset"
+)
+
+var requestCostUnits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "pandacea_request_cost_units_total",
+	Help: "Cost units charged to callers, by route",
+}, []string{"route"})
+
+// costIdentity returns who a request's cost is charged to: its peer ID,
+// which verifySignatureMiddleware or a client certificate has
+// authenticated by the time costMiddleware runs, or else the client IP
+func costIdentity(r *http.Request) string {
+	if peerID := r.Header.Get(reqsig.HeaderPeerID); peerID != "" {
+		return peerID
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// costMiddleware refuses callers who have spent their cost budget, then
+// charges each request's cost to its caller and reports the cost and the
+// caller's spend in response headers. It runs after the request signature
+// is verified, so nobody can spend another peer's budget by claiming its
+// peer ID. The cost is settled when the headers are written, from the
+// handling time so far and the declared Content-Length; bytes streamed
+// beyond that are charged once the handler returns.
+func (server *Server) costMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.securityService == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Expensive downloads use up a budget that cheap status polls
+		// barely touch
+		identity := costIdentity(r)
+		if allowed, retryAfter := server.securityService.CheckCostBudget(identity); !allowed {
+			server.securityService.LogRefusedRequest(r, identity, "cost_budget_exceeded")
+			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()))
+			server.sendErrorResponse(w, r, http.StatusTooManyRequests, ErrorCodeQuotaExceeded, "Cost budget exceeded")
+			return
+		}
+
+		cw := &costWriter{ResponseWriter: w, server: server, r: r, identity: identity, start: time.Now()}
+		next.ServeHTTP(cw, r)
+		cw.settle()
+	})
+}
+
+// costWriter counts the bytes a handler writes and charges them to identity
+type costWriter struct {
+	http.ResponseWriter
+	server   *Server
+	r        *http.Request
+	identity string
+	start    time.Time
+
+	written     int64
+	charged     int64
+	wroteHeader bool
+}
+
+func (cw *costWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.charge()
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *costWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *costWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Flush implements http.Flusher for streaming handlers
+func (cw *costWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// charge records the request's cost and sets the cost headers
+func (cw *costWriter) charge() {
+	if length, err := strconv.ParseInt(cw.Header().Get("Content-Length"), 10, 64); err == nil {
+		cw.charged = length
+	}
+	cost := cw.server.securityService.RequestCost(time.Since(cw.start), cw.charged)
+	usage := cw.server.securityService.RecordCost(cw.identity, cost)
+	requestCostUnits.WithLabelValues(routePattern(cw.r)).Add(cost)
+
+	h := cw.Header()
+	h.Set(headerCost, formatCost(cost))
+	h.Set(headerCostSpent, formatCost(usage.Spent))
+	h.Set(headerCostReset, strconv.FormatInt(usage.ResetsAt.Unix(), 10))
+	if remaining := usage.Remaining(); remaining >= 0 {
+		h.Set(headerCostRemaining, formatCost(remaining))
+	}
+}
+
+// settle charges for a response that wrote nothing, or for bytes streamed
+// past the declared length
+func (cw *costWriter) settle() {
+	if !cw.wroteHeader {
+		cw.charge()
+		return
+	}
+	if extra := cw.written - cw.charged; extra > 0 {
+		// Only the byte component applies; time was charged with the headers
+		svc := cw.server.securityService
+		cost := svc.RequestCost(0, extra) - svc.RequestCost(0, 0)
+		svc.AddCost(cw.identity, cost)
+		requestCostUnits.WithLabelValues(routePattern(cw.r)).Add(cost)
+	}
+}
+
+// routePattern returns the matched chi route, or "unmatched"
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
+// formatCost formats cost units for a header
+func formatCost(units float64) string {
+	return strconv.FormatFloat(units, 'f', 4, 64)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_costMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+rate_limits:
+  per_ip_rps: 100
+  per_identity_rps: 100
+  burst: 100
+backpressure:
+  mem_high_watermark_mb: 100000
+quotas:
+  cost_budget: 20
+  cost_window_seconds: 60
+costs:
+  base_units: 1
+  megabyte_units: 10
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+	router := chi.NewRouter()
+	router.Use(server.securityMiddleware, server.verifySignatureMiddleware, server.costMiddleware)
+	router.Get("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"pending"}`))
+	})
+	download := strings.Repeat("x", 1024*1024)
+	router.Get("/result", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(download)))
+		w.Write([]byte(download))
+	})
+
+	peerKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	peerA, err := peer.IDFromPrivateKey(peerKey)
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		require.NoError(t, reqsig.Sign(peerKey, req, nil, time.Now()))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Requests merely claiming a peer ID are refused before they are
+	// charged to it
+	forged := httptest.NewRequest("GET", "/result", nil)
+	forged.Header.Set(reqsig.HeaderPeerID, peerA.String())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, forged)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, securityService.Snapshot().Costs)
+	cost := func(w *httptest.ResponseRecorder, header string) float64 {
+		v, err := strconv.ParseFloat(w.Header().Get(header), 64)
+		require.NoError(t, err, header)
+		return v
+	}
+
+	poll := get("/status")
+	assert.Equal(t, http.StatusOK, poll.Code)
+	assert.InDelta(t, 1, cost(poll, headerCost), 0.01)
+	assert.InDelta(t, 19, cost(poll, headerCostRemaining), 0.01)
+	assert.NotEmpty(t, poll.Header().Get(headerCostReset))
+
+	result := get("/result")
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.InDelta(t, 11, cost(result, headerCost), 0.01)
+	assert.InDelta(t, 12, cost(result, headerCostSpent), 0.01)
+
+	// A second download spends the rest of the budget and the next request is refused
+	get("/result")
+	refused := get("/status")
+	assert.Equal(t, http.StatusTooManyRequests, refused.Code)
+	assert.NotEmpty(t, refused.Header().Get("Retry-After"))
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(refused.Body.Bytes(), &resp))
+	assert.Equal(t, "QUOTA_EXCEEDED", resp.Error.Code)
+
+	usages := securityService.Snapshot().Costs
+	require.Len(t, usages, 1)
+	assert.Equal(t, peerA.String(), usages[0].Identity)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Audit event types for lease deliveries
+const (
+	AuditDeliveryStarted   = "delivery.started"
+	AuditDeliveryCompleted = "delivery.completed"
+	AuditDeliveryPinned    = "delivery.pinned"
+)
+
+// SetDelivery lets spenders execute their approved leases through the
+// agent and receive the leased product, prepared by preparer and tracked
+// in store. products looks up a lease's product on the default network.
+// Execute requests wait up to wait for executeLease to confirm. Sending
+// needs the transaction manager.
+func (server *Server) SetDelivery(store *delivery.Store, preparer *delivery.Preparer, products chain.LeaseProductReader, wait time.Duration) {
+	server.deliveries = store
+	server.preparer = preparer
+	server.leaseProducts = products
+	server.deliveryWait = wait
+}
+
+// handleDeliverLease executes a lease for its spender. The lease must be
+// approved, held by the caller's spender address, and requested here by
+// the caller's verified peer, which is the only peer the product is
+// released to. The product is checked before executeLease is sent, and
+// sealed to the spender, pinned and released once the transaction
+// confirms. Repeating the request resumes the lease's delivery rather than
+// executing it again.
+func (server *Server) handleDeliverLease(w http.ResponseWriter, r *http.Request) {
+	if server.transactions == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Sending transactions is not enabled")
+		return
+	}
+	id, ok := server.leaseIDParam(w, r)
+	if !ok {
+		return
+	}
+	leaseID := common.Hash(id).Hex()
+	spender := r.Header.Get("X-Pandacea-Spender-Address")
+	if spender == "" {
+		server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Spender address not found in request")
+		return
+	}
+	caller := r.Header.Get(reqsig.HeaderPeerID)
+
+	// Once executed the lease no longer verifies, so a repeated request
+	// picks up the delivery where it is
+	if d, exists := server.deliveries.Get(leaseID); exists && d.Status != delivery.StatusFailed {
+		if d.PeerID == "" || d.PeerID != caller || !strings.EqualFold(d.Spender, spender) {
+			server.logger.Warn("delivery refused to another peer", "lease_id", leaseID, "peer_id", caller)
+			server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Lease is delivered to another spender")
+			return
+		}
+		server.finishDelivery(w, r, d)
+		return
+	}
+
+	// The spender address is public on-chain, so the lease is bound to the
+	// peer that requested it here, as computation results are
+	owner := server.leaseSpenderPeer(leaseID)
+	if owner == "" || owner != caller {
+		server.logger.Warn("delivery refused to a peer that does not hold the lease", "lease_id", leaseID, "peer_id", caller)
+		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Lease is not held by the calling peer")
+		return
+	}
+	if _, err := server.deliverySealer(leaseID, owner); err != nil {
+		server.logger.Warn("cannot seal delivery to spender", "error", err, "lease_id", leaseID)
+		server.sendError(w, r, err, "Cannot seal the data product to the spender")
+		return
+	}
+
+	ctx, err := server.leaseContext(r, "")
+	if err == nil {
+		err = server.privacyService.VerifyLease(ctx, leaseID, spender)
+	}
+	if err != nil {
+		server.logger.Warn("lease execution refused", "error", err, "lease_id", leaseID, "spender", spender)
+		server.sendError(w, r, err, "Lease verification failed")
+		return
+	}
+	productID, err := server.leaseProducts.LeaseProduct(r.Context(), id)
+	if err != nil {
+		server.logger.Error("failed to read leased product", "error", err, "lease_id", leaseID)
+		server.sendError(w, r, err, "Failed to read lease")
+		return
+	}
+	if server.rejectQuarantined(w, r, productID, map[string]any{"lease_id": leaseID}) {
+		return
+	}
+
+	// Check the product first, so a lease is never executed without
+	// something to deliver
+	artifact, err := server.preparer.Prepare(r.Context(), productID)
+	if err != nil {
+		server.logger.Error("failed to prepare data product", "error", err, "lease_id", leaseID, "product_id", productID)
+		if errors.Is(err, delivery.ErrNoSource) {
+			server.sendError(w, r, err, "Failed to prepare data product")
+			return
+		}
+		server.sendErrorResponse(w, r, http.StatusBadGateway, ErrorCodeDeliveryFailed, "Failed to prepare data product")
+		return
+	}
+
+	rec, err := server.queueLeaseTransaction(r, TxActionExecuteLease, "executeLease", id)
+	if err != nil {
+		server.sendError(w, r, err, "Failed to queue transaction")
+		return
+	}
+	d, started, err := server.deliveries.Start(leaseID, productID, spender, owner, rec.ID, artifact)
+	if err != nil {
+		server.logger.Error("failed to record delivery", "error", err, "lease_id", leaseID)
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to record delivery")
+		return
+	}
+	if started {
+		server.recordAudit(AuditDeliveryStarted, spender, map[string]any{
+			"lease_id":   leaseID,
+			"product_id": productID,
+			"tx_id":      rec.ID,
+			"cid":        artifact.CID,
+		})
+	}
+	server.finishDelivery(w, r, d)
+}
+
+// finishDelivery waits for d's executeLease transaction and responds with
+// the delivery: 200 with the artifact once the transaction confirms and
+// the artifact is pinned, or 202 without it if the transaction is still
+// pending when the wait ends
+func (server *Server) finishDelivery(w http.ResponseWriter, r *http.Request, d delivery.Delivery) {
+	if d.Status == delivery.StatusExecuting {
+		ctx, cancel := context.WithTimeout(r.Context(), server.deliveryWait)
+		rec, err := server.transactions.Wait(ctx, d.TxID)
+		cancel()
+		switch {
+		case err == nil, errors.Is(err, context.DeadlineExceeded):
+			settled, moved, err := server.deliveries.Settle(d.LeaseID, rec)
+			if err != nil {
+				server.logger.Error("failed to record delivery", "error", err, "lease_id", d.LeaseID)
+				break
+			}
+			d = settled
+			if moved && d.Status == delivery.StatusDelivered {
+				server.recordAudit(AuditDeliveryCompleted, d.Spender, map[string]any{
+					"lease_id":   d.LeaseID,
+					"product_id": d.ProductID,
+					"tx_hash":    d.TxHash,
+				})
+			}
+		case errors.Is(err, txmgr.ErrNotFound):
+			server.logger.Warn("delivery transaction has no record", "lease_id", d.LeaseID, "tx_id", d.TxID)
+		}
+	}
+
+	// A file source is only sealed and pinned once the lease is executed.
+	// If pinning fails the next request tries again.
+	if d.Status == delivery.StatusDelivered && d.Artifact != nil && !d.Artifact.Pinned() {
+		released, err := server.releaseArtifact(r.Context(), d)
+		if err != nil {
+			server.logger.Error("failed to release data product", "error", err, "lease_id", d.LeaseID, "product_id", d.ProductID)
+			server.sendErrorResponse(w, r, http.StatusBadGateway, ErrorCodeDeliveryFailed, "Lease was executed but the data product could not be published; try again")
+			return
+		}
+		d = released
+	}
+
+	status := http.StatusAccepted
+	switch d.Status {
+	case delivery.StatusDelivered:
+		status = http.StatusOK
+	case delivery.StatusFailed:
+		server.sendErrorResponse(w, r, http.StatusBadGateway, ErrorCodeDeliveryFailed, "Lease was not executed: "+d.Error)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(d.Released()); err != nil {
+		server.logger.Error("failed to encode delivery", "error", err)
+	}
+}
+
+// releaseArtifact seals an executed lease's product to its spender, pins
+// it and records it on the delivery
+func (server *Server) releaseArtifact(ctx context.Context, d delivery.Delivery) (delivery.Delivery, error) {
+	seal, err := server.deliverySealer(d.LeaseID, d.PeerID)
+	if err != nil {
+		return d, err
+	}
+	artifact, err := server.preparer.Seal(ctx, d.ProductID, seal)
+	if err != nil {
+		return d, err
+	}
+	released, err := server.deliveries.Attach(d.LeaseID, artifact)
+	if err != nil {
+		return d, err
+	}
+	server.recordAudit(AuditDeliveryPinned, d.Spender, map[string]any{
+		"lease_id":   d.LeaseID,
+		"product_id": d.ProductID,
+		"cid":        artifact.CID,
+	})
+	return released, nil
+}
+
+// deliverySealer returns how a lease's product is sealed for its spender:
+// to the X25519 key handed over with the lease if there is one, otherwise
+// to the spender's peer key
+func (server *Server) deliverySealer(leaseID, spenderPeerID string) (delivery.SealFunc, error) {
+	if key := server.leaseEncryptionKey(leaseID); key != nil {
+		return func(plaintext []byte) (*envelope.Envelope, error) {
+			return envelope.SealX25519(key, plaintext)
+		}, nil
+	}
+	pubKey, err := resultRecipient(spenderPeerID)
+	if err != nil {
+		return nil, err
+	}
+	return func(plaintext []byte) (*envelope.Envelope, error) {
+		return envelope.Seal(pubKey, plaintext)
+	}, nil
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leaseProducts looks up leased products from a fixed table
+type leaseProducts map[common.Hash]string
+
+func (p leaseProducts) LeaseProduct(ctx context.Context, leaseID [32]byte) (string, error) {
+	return p[leaseID], nil
+}
+
+func TestServer_deliverLease(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	registry, err := privacy.NewAssignmentRegistry("")
+	require.NoError(t, err)
+	const spender = "0x1111111111111111111111111111111111111111"
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &holderPrivacyService{spender: spender, assignments: registry}, nil)
+
+	// Transactions to an account without code succeed, which is all the
+	// handshake needs
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		ethcrypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1e18)},
+	})
+	defer backend.Close()
+	chainID, err := backend.Client().ChainID(context.Background())
+	require.NoError(t, err)
+	manager, err := txmgr.New(backend.Client(), key, chainID, txmgr.Config{PollInterval: 10 * time.Millisecond}, "", logger)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+	server.SetTransactionManager(manager, common.HexToAddress("0x00000000000000000000000000000000000000aa"))
+
+	leaseID := common.Hash{0xab}
+	fileLease := common.Hash{0xef}
+	unsourced := common.Hash{0xcd}
+
+	// The file source is pinned, sealed, only after its lease is executed
+	var pinned [][]byte
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		content, _ := io.ReadAll(file)
+		pinned = append(pinned, content)
+		fmt.Fprint(w, `{"Hash":"bafysealed"}`)
+	}))
+	defer ipfs.Close()
+	source := filepath.Join(t.TempDir(), "scans.csv")
+	require.NoError(t, os.WriteFile(source, []byte("id,scan\n1"), 0600))
+
+	store, err := delivery.NewStore("")
+	require.NoError(t, err)
+	server.SetDelivery(store, delivery.NewPreparer(ipfs.URL, map[string]string{"product-1": "ipfs://bafyscans", "product-3": source}),
+		leaseProducts{leaseID: "product-1", unsourced: "product-2", fileLease: "product-3"}, 50*time.Millisecond)
+
+	// Each lease was requested here by the spender's peer
+	spenderKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	spenderPeer, err := peer.IDFromPrivateKey(spenderKey)
+	require.NoError(t, err)
+	otherKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	otherPeer, err := peer.IDFromPrivateKey(otherKey)
+	require.NoError(t, err)
+	for _, lease := range []common.Hash{leaseID, fileLease, unsourced} {
+		server.pendingLeases[chainLeaseProposalID(lease.Hex())] = &LeaseProposalState{Status: "approved", owner: spenderPeer.String()}
+	}
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Post("/leases/{leaseId}/execute", server.handleExecuteLease)
+	execute := func(lease common.Hash, caller string, callerPeer peer.ID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/leases/"+lease.Hex()+"/execute", nil)
+		req.Header.Set("X-Pandacea-Spender-Address", caller)
+		req.Header.Set(reqsig.HeaderPeerID, callerPeer.String())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := execute(leaseID, "0x2222222222222222222222222222222222222222", spenderPeer)
+	assert.Equal(t, http.StatusForbidden, w.Code, "only the lease holder may execute it")
+	w = execute(leaseID, spender, otherPeer)
+	assert.Equal(t, http.StatusForbidden, w.Code, "the spender's public address does not identify the caller")
+	w = execute(unsourced, spender, spenderPeer)
+	assert.Equal(t, http.StatusNotFound, w.Code, "a product without a source is not executed")
+	assert.Empty(t, manager.List(txmgr.Query{}))
+
+	// Unmined, the request ends with the artifact still withheld
+	w = execute(leaseID, spender, spenderPeer)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var pending delivery.Delivery
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&pending))
+	assert.Equal(t, delivery.StatusExecuting, pending.Status)
+	assert.Equal(t, "product-1", pending.ProductID)
+	assert.Nil(t, pending.Artifact)
+	assert.NotContains(t, w.Body.String(), "bafyscans")
+
+	// Once executeLease confirms a repeated request receives the product
+	// without sending executeLease again
+	require.Eventually(t, func() bool {
+		rec, _ := manager.Get(pending.TxID)
+		return rec.Status == txmgr.StatusPending
+	}, 5*time.Second, 10*time.Millisecond)
+	backend.Commit()
+	var delivered delivery.Delivery
+	require.Eventually(t, func() bool {
+		w := execute(leaseID, spender, spenderPeer)
+		json.NewDecoder(w.Body).Decode(&delivered)
+		return w.Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, delivery.StatusDelivered, delivered.Status)
+	require.NotNil(t, delivered.Artifact)
+	assert.Equal(t, "ipfs://bafyscans", delivered.Artifact.URI)
+	assert.NotEmpty(t, delivered.TxHash)
+	assert.Len(t, manager.List(txmgr.Query{Action: TxActionExecuteLease}), 1)
+
+	assert.Equal(t, http.StatusForbidden, execute(leaseID, "0x2222222222222222222222222222222222222222", spenderPeer).Code)
+	assert.Equal(t, http.StatusForbidden, execute(leaseID, spender, otherPeer).Code, "a delivered lease is released only to its spender's peer")
+
+	// A file source is not pinned until executeLease confirms, and is then
+	// pinned sealed to the spender's peer key
+	w = execute(fileLease, spender, spenderPeer)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&pending))
+	assert.Empty(t, pinned)
+	require.Eventually(t, func() bool {
+		rec, _ := manager.Get(pending.TxID)
+		return rec.Status == txmgr.StatusPending
+	}, 5*time.Second, 10*time.Millisecond)
+	backend.Commit()
+	require.Eventually(t, func() bool {
+		w := execute(fileLease, spender, spenderPeer)
+		json.NewDecoder(w.Body).Decode(&delivered)
+		return w.Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotNil(t, delivered.Artifact)
+	assert.True(t, delivered.Artifact.Sealed)
+	assert.Equal(t, "ipfs://bafysealed", delivered.Artifact.URI)
+	require.Len(t, pinned, 1)
+	var sealed envelope.Envelope
+	require.NoError(t, json.Unmarshal(pinned[0], &sealed))
+	plaintext, err := envelope.Open(spenderKey, &sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "id,scan\n1", string(plaintext))
+
+	// Repeating the request does not pin it again
+	require.Equal(t, http.StatusOK, execute(fileLease, spender, spenderPeer).Code)
+	assert.Len(t, pinned, 1)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DisputesResponse lists dispute records
+type DisputesResponse struct {
+	Data []dispute.Record `json:"data"`
+}
+
+// SetDisputes keeps dispute records in store so arbitrators can retrieve
+// them, and enables evidence on raised disputes, pinned through the IPFS
+// API at ipfsAPIURL. Evidence needs the node's key to sign manifests, so
+// it stays disabled without one.
+func (server *Server) SetDisputes(store *dispute.Store, ipfsAPIURL string) {
+	server.disputes = store
+	if server.responseSigner != nil {
+		server.evidence = dispute.NewPackager(ipfsAPIURL, server.responseSigner)
+	}
+}
+
+// packageDispute pins claim's evidence, if any, and returns its record
+func (server *Server) packageDispute(ctx context.Context, claim dispute.Claim) (*dispute.Record, error) {
+	if server.evidence == nil {
+		return dispute.NewRecord(claim, time.Now()), nil
+	}
+	return server.evidence.Package(ctx, claim)
+}
+
+// mayReadDisputes reports whether the caller may read the disputes on a
+// lease: its spender, or an admin peer
+func (server *Server) mayReadDisputes(r *http.Request, leaseID string) bool {
+	peerID := r.Header.Get(reqsig.HeaderPeerID)
+	if owner := server.leaseSpenderPeer(leaseID); owner != "" && owner == peerID {
+		return true
+	}
+	return server.securityService != nil && server.securityService.IsAdmin(peerID)
+}
+
+// handleGetLeaseDisputes handles GET /api/v1/leases/{leaseId}/disputes
+func (server *Server) handleGetLeaseDisputes(w http.ResponseWriter, r *http.Request) {
+	if server.disputes == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Dispute records are not enabled")
+		return
+	}
+
+	leaseID := chi.URLParam(r, "leaseId")
+	if !server.mayReadDisputes(r, leaseID) {
+		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Lease is not held by the calling peer")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DisputesResponse{Data: server.disputes.ForLease(leaseID)})
+}
+
+// handleListDisputes handles GET /api/v1/disputes
+func (server *Server) handleListDisputes(w http.ResponseWriter, r *http.Request) {
+	if server.disputes == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Dispute records are not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	query := dispute.Query{LeaseID: params.Get("lease"), Network: params.Get("network")}
+	if raw := params.Get("status"); raw != "" {
+		status, err := dispute.ParseStatus(raw)
+		if err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "status must be pending, raised, upheld or rejected")
+			return
+		}
+		query.Status = status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DisputesResponse{Data: server.disputes.List(query)})
+}
+
+// handleGetDispute handles GET /api/v1/disputes/{disputeId}
+func (server *Server) handleGetDispute(w http.ResponseWriter, r *http.Request) {
+	if server.disputes == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Dispute records are not enabled")
+		return
+	}
+
+	// Disputes on other peers' leases are answered as if they did not
+	// exist, so IDs cannot be probed
+	record, ok := server.disputes.Get(chi.URLParam(r, "disputeId"))
+	if !ok || !server.mayReadDisputes(r, record.LeaseID) {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Dispute not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_disputes(t *testing.T) {
+	var pinned [][]byte
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		content, _ := io.ReadAll(file)
+		pinned = append(pinned, content)
+		fmt.Fprintf(w, `{"Hash":"bafy%d"}`, len(pinned))
+	}))
+	defer ipfs.Close()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("admin:\n  peer_ids:\n    - 12D3KooWAdmin\n"), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, nil, securityService)
+	router := chi.NewRouter()
+	router.Post("/leases/{leaseId}/dispute", server.handleRaiseDispute)
+	router.Get("/leases/{leaseId}/disputes", server.handleGetLeaseDisputes)
+	router.With(server.adminOnly).Get("/disputes", server.handleListDisputes)
+	router.Get("/disputes/{disputeId}", server.handleGetDispute)
+	get := func(peerID, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set(reqsig.HeaderPeerID, peerID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	server.pendingLeases[chainLeaseProposalID("0xabc")] = &LeaseProposalState{Status: "approved", owner: "spender-peer"}
+	raiseAs := func(peerID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/leases/0xabc/dispute", strings.NewReader(body))
+		req.Header.Set(reqsig.HeaderPeerID, peerID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	raise := func(body string) *httptest.ResponseRecorder {
+		return raiseAs("spender-peer", body)
+	}
+
+	t.Run("only the spender may dispute", func(t *testing.T) {
+		w := raiseAs("other-peer", `{"reason":"bad data"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("evidence needs dispute records", func(t *testing.T) {
+		w := raise(`{"reason":"bad data","evidence":[{"name":"a","uri":"ipfs://bafyx"}]}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), ErrorCodeNotFound)
+	})
+
+	store, err := dispute.NewStore("")
+	require.NoError(t, err)
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+	server.SetDisputes(store, ipfs.URL)
+
+	var raised DisputeResponse
+	t.Run("evidence is pinned in a signed bundle", func(t *testing.T) {
+		w := raise(`{"reason":"bad data","evidence":[{"name":"diff.csv","content":"MSwyLDMK"},{"name":"log","uri":"https://example.com/log"}]}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raised))
+		assert.Equal(t, "bafy2", raised.EvidenceCID)
+		assert.Equal(t, "bad data [evidence: ipfs://bafy2]", raised.ChainReason)
+
+		require.Len(t, pinned, 2)
+		assert.Equal(t, "1,2,3\n", string(pinned[0]))
+		var bundle dispute.Bundle
+		require.NoError(t, json.Unmarshal(pinned[1], &bundle))
+		_, err := respsig.VerifyEvidence(bundle.Signature, bundle.Manifest, signer.PeerID())
+		assert.NoError(t, err)
+	})
+
+	t.Run("disputes raised together get distinct IDs", func(t *testing.T) {
+		var second DisputeResponse
+		w := raise(`{"reason":"still bad"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+		assert.NotEqual(t, raised.DisputeID, second.DisputeID)
+		_, ok := store.Get(raised.DisputeID)
+		assert.True(t, ok, "second dispute replaced the first")
+	})
+
+	t.Run("only the spender and admins read disputes", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("other-peer", "/disputes/"+raised.DisputeID).Code)
+		assert.Equal(t, http.StatusForbidden, get("other-peer", "/leases/0xabc/disputes").Code)
+		assert.Equal(t, http.StatusForbidden, get("spender-peer", "/disputes").Code)
+		assert.Equal(t, http.StatusOK, get("12D3KooWAdmin", "/disputes/"+raised.DisputeID).Code)
+	})
+
+	t.Run("arbitrators retrieve the record", func(t *testing.T) {
+		w := get("spender-peer", "/disputes/"+raised.DisputeID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var record dispute.Record
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		assert.Equal(t, "bafy2", record.EvidenceCID)
+		require.Len(t, record.Items, 2)
+		assert.Equal(t, "ipfs://bafy1", record.Items[0].URI)
+		assert.Equal(t, signer.PeerID(), record.Signature.PeerID)
+
+		w = get("spender-peer", "/leases/0xabc/disputes")
+		require.Equal(t, http.StatusOK, w.Code)
+		var list DisputesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list.Data, 2)
+		assert.Equal(t, raised.DisputeID, list.Data[0].DisputeID)
+
+		w = get("spender-peer", "/disputes/dispute_unknown")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("list by status", func(t *testing.T) {
+		var list DisputesResponse
+		w := get("12D3KooWAdmin", "/disputes?status=pending&lease=0xABC")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list.Data, 2)
+		assert.Equal(t, dispute.OriginAgent, list.Data[0].Origin)
+
+		w = get("12D3KooWAdmin", "/disputes?status=upheld")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Empty(t, list.Data)
+
+		w = get("12D3KooWAdmin", "/disputes?status=closed")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid evidence", func(t *testing.T) {
+		w := raise(`{"reason":"bad data","evidence":[{"name":"a","uri":"ftp://example.com/a"}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), ErrorCodeValidationError)
+	})
+
+	t.Run("IPFS failure", func(t *testing.T) {
+		server.SetDisputes(store, "http://127.0.0.1:1")
+		w := raise(`{"reason":"bad data","evidence":[{"name":"a","content":"eA=="}]}`)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Contains(t, w.Body.String(), ErrorCodeEvidencePin)
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"pandacea/agent-backend/internal/earnings"
+)
+
+// EarningsResponse is the earner's payouts over the requested range
+type EarningsResponse struct {
+	From     *time.Time        `json:"from,omitempty"`
+	To       *time.Time        `json:"to,omitempty"`
+	Interval earnings.Interval `json:"interval,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	earnings.Report
+}
+
+// SetEarnings enables GET /api/v1/earnings over ledger
+func (server *Server) SetEarnings(ledger *earnings.Ledger) {
+	server.earnings = ledger
+}
+
+// handleGetEarnings handles GET /api/v1/earnings. Payouts are the
+// operator's business, so only admin peers may read them. On an agent
+// hosting several earners, ?tenant= narrows the report to one of them.
+func (server *Server) handleGetEarnings(w http.ResponseWriter, r *http.Request) {
+	if server.earnings == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Earnings tracking is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	var resp EarningsResponse
+	var query earnings.Query
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+		dest  **time.Time
+	}{
+		{"from", &query.From, &resp.From},
+		{"to", &query.To, &resp.To},
+	} {
+		raw := params.Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, bound.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*bound.value = t
+		*bound.dest = &t
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "from must be before to")
+		return
+	}
+	interval, err := earnings.ParseInterval(params.Get("interval"))
+	if err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, err.Error())
+		return
+	}
+	query.Interval = interval
+	query.ProductID = params.Get("product")
+	if id := params.Get("tenant"); id != "" {
+		owner, ok := server.lookupTenant(id)
+		if !ok {
+			server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Unknown tenant")
+			return
+		}
+		query.Earner = owner.Earner
+		resp.Tenant = owner.ID
+	}
+
+	resp.Interval = interval
+	resp.Report = server.earnings.Report(query)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode earnings", "error", err)
+	}
+}
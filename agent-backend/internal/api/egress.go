@@ -0,0 +1,48 @@
+package api
+
+import (
+	"pandacea/agent-backend/internal/egress"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+// Audit event types for computation network egress
+const (
+	AuditEgressGranted = "egress.granted"
+	AuditEgressAllowed = "egress.allowed"
+	AuditEgressDenied  = "egress.denied"
+)
+
+// egressAuditTypes maps proxy events to the audit events recording them
+var egressAuditTypes = map[string]string{
+	egress.EventGranted: AuditEgressGranted,
+	egress.EventAllowed: AuditEgressAllowed,
+	egress.EventDenied:  AuditEgressDenied,
+}
+
+// SetEgress lets computations reach the hosts policy allows for their
+// products through proxy, if the privacy service supports it. Every grant
+// and every request the proxy relays or refuses is audited.
+func (server *Server) SetEgress(proxy *egress.Proxy, policy egress.Policy) {
+	proxy.OnEvent(server.recordEgress)
+	if granter, ok := server.privacyService.(privacy.EgressGranter); ok {
+		granter.UseEgress(proxy, policy)
+	}
+}
+
+// recordEgress audits an egress proxy event
+func (server *Server) recordEgress(e egress.Event) {
+	fields := make(map[string]any, len(e.Labels)+3)
+	for name, value := range e.Labels {
+		fields[name] = value
+	}
+	if e.Host != "" {
+		fields["host"] = e.Host
+	}
+	if len(e.Hosts) > 0 {
+		fields["hosts"] = e.Hosts
+	}
+	if e.Reason != "" {
+		fields["reason"] = e.Reason
+	}
+	server.recordAudit(egressAuditTypes[e.Kind], "", fields)
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/pinning"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AuditAdminDataErased is recorded with the report of each erasure
+const AuditAdminDataErased = "admin.data_erased"
+
+// erasedReason is the reason given for the quarantine erasure places
+const erasedReason = "data erased"
+
+// ErasureReport is what erasing a product's data destroyed, and the leases
+// and jobs that had used it
+type ErasureReport struct {
+	ProductID    string          `json:"product_id"`
+	ErasedBy     string          `json:"erased_by"`
+	ErasedAt     time.Time       `json:"erased_at"`
+	Assets       []assets.Erased `json:"assets"`        // Assets unregistered, with their sources deleted or unpinned
+	Leases       []string        `json:"leases"`        // Lease proposals and on-chain leases on the product
+	TrainingJobs []string        `json:"training_jobs"` // Training jobs on the product; none of their artifacts are kept
+	Computations []string        `json:"computations"`  // Computations that read the product; none of their results are kept
+	Models       []string        `json:"models"`        // Models removed from the registry
+	Unpinned     []string        `json:"unpinned"`      // CIDs of published job outputs released from IPFS
+	// Running are jobs and computations still running on the data. They
+	// are left to finish; erasing again purges them.
+	Running []string `json:"running,omitempty"`
+	// Errors are steps that failed. Erasing again retries them.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// erasedAssetProduct returns the product an erased asset belonged to, so
+// computations naming it are refused like ones naming the product
+func (server *Server) erasedAssetProduct(assetID string) (string, bool) {
+	server.quarantineMutex.RLock()
+	defer server.quarantineMutex.RUnlock()
+	for productID, q := range server.quarantined {
+		if q.Erased && slices.Contains(q.Assets, assetID) {
+			return productID, true
+		}
+	}
+	return "", false
+}
+
+// quarantineErased places a permanent quarantine on a product whose data
+// is being erased, covering its assets, and notifies the holders of its
+// active leases the first time
+func (server *Server) quarantineErased(productID, actor string, assetIDs []string) error {
+	server.quarantineMutex.Lock()
+	q, exists := server.quarantined[productID]
+	notify := !exists || !q.Erased
+	if !exists {
+		q = &Quarantine{ProductID: productID, QuarantinedBy: actor, QuarantinedAt: time.Now().UTC()}
+		server.quarantined[productID] = q
+	}
+	q.Reason = erasedReason
+	q.Erased = true
+	for _, id := range assetIDs {
+		if !slices.Contains(q.Assets, id) {
+			q.Assets = append(q.Assets, id)
+		}
+	}
+	snapshot := *q
+	err := server.saveQuarantines()
+	server.quarantineMutex.Unlock()
+
+	if notify {
+		server.notifyQuarantine(&snapshot)
+	}
+	return err
+}
+
+// erasureScope returns the leases, training jobs and computations that used
+// a product's data, from the lineage graph and the agent's own records
+func (server *Server) erasureScope(productID string, assetIDs []string) (leases, jobIDs, computations []string) {
+	seen := make(map[lineage.Node]bool)
+	if server.lineage != nil {
+		roots := []lineage.Node{{Kind: lineage.KindProduct, ID: productID}}
+		for _, id := range assetIDs {
+			roots = append(roots, lineage.Node{Kind: lineage.KindAsset, ID: id})
+		}
+		for _, root := range roots {
+			sub, err := server.lineage.Walk(root, lineage.Downstream)
+			if err != nil {
+				continue
+			}
+			for _, n := range sub.Nodes {
+				seen[n] = true
+			}
+		}
+	}
+
+	server.leasesMutex.RLock()
+	for leaseProposalID, state := range server.pendingLeases {
+		if state.ProductID == productID {
+			seen[lineage.Node{Kind: lineage.KindLease, ID: leaseProposalID}] = true
+		}
+	}
+	server.leasesMutex.RUnlock()
+	server.jobsMutex.RLock()
+	for id, job := range server.jobs {
+		if job.Dataset == productID {
+			seen[lineage.Node{Kind: lineage.KindJob, ID: id}] = true
+		}
+	}
+	server.jobsMutex.RUnlock()
+
+	for n := range seen {
+		switch n.Kind {
+		case lineage.KindLease:
+			leases = append(leases, n.ID)
+		case lineage.KindJob:
+			jobIDs = append(jobIDs, n.ID)
+		case lineage.KindComputation:
+			computations = append(computations, n.ID)
+		}
+	}
+	for _, ids := range [][]string{leases, jobIDs, computations} {
+		sort.Strings(ids)
+	}
+	return leases, jobIDs, computations
+}
+
+// eraseTrainingJob drops a finished training job trained on erased data,
+// with its artifacts, model and published output. It reports false if the
+// job is still running.
+func (server *Server) eraseTrainingJob(r *http.Request, jobID string, report *ErasureReport) bool {
+	server.jobsMutex.Lock()
+	job, exists := server.jobs[jobID]
+	if exists && !trainingJobs.IsTerminal(jobs.State(job.Status)) {
+		server.jobsMutex.Unlock()
+		return false
+	}
+	if exists {
+		server.dropTrainingJob(jobID)
+	}
+	server.jobsMutex.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(server.productsDir, jobID)); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if server.models != nil {
+		if _, registered := server.models.Get(jobID); registered {
+			if err := server.models.Remove(jobID); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			} else {
+				report.Models = append(report.Models, jobID)
+			}
+		}
+	}
+	server.releasePin(r, pinning.KindTraining, jobID, report)
+	return true
+}
+
+// releasePin unpins a job's published output, if it was published
+func (server *Server) releasePin(r *http.Request, kind, sourceID string, report *ErasureReport) {
+	if server.publisher == nil {
+		return
+	}
+	cid, err := server.publisher.Release(r.Context(), kind, sourceID)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return
+	}
+	if cid != "" {
+		report.Unpinned = append(report.Unpinned, cid)
+	}
+}
+
+// handleEraseProductData handles DELETE /api/v1/products/{productId}/data.
+// It quarantines the product for good, so new leases, computations and
+// training on it or its assets are refused, then unregisters its assets,
+// deletes or unpins their sources, and drops the finished training jobs and
+// computations that used it with their artifacts, results, models and
+// published outputs. The report lists everything affected. Product IDs
+// contain a slash, so the route uses a wildcard.
+func (server *Server) handleEraseProductData(w http.ResponseWriter, r *http.Request) {
+	productID, ok := strings.CutSuffix(chi.URLParam(r, "*"), "/data")
+	if !ok || productID == "" {
+		server.handleNotFound(w, r)
+		return
+	}
+	actor := r.Header.Get(reqsig.HeaderPeerID)
+	report := ErasureReport{ProductID: productID, ErasedBy: actor, ErasedAt: time.Now().UTC(), Assets: []assets.Erased{},
+		TrainingJobs: []string{}, Computations: []string{}, Models: []string{}, Unpinned: []string{}}
+
+	var registered []assets.Asset
+	if server.assets != nil {
+		registered = server.assets.List(productID)
+	}
+	assetIDs := make([]string, 0, len(registered))
+	for _, a := range registered {
+		assetIDs = append(assetIDs, a.ID)
+	}
+	if err := server.quarantineErased(productID, actor, assetIDs); err != nil {
+		server.logger.Error("failed to persist erasure quarantine", "product_id", productID, "error", err)
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	// Work out what used the data before the records of it go
+	leases, jobIDs, computations := server.erasureScope(productID, assetIDs)
+	report.Leases = append([]string{}, leases...)
+
+	for _, a := range registered {
+		erased, err := server.assets.Erase(r.Context(), a.ID)
+		if erased.AssetID != "" {
+			report.Assets = append(report.Assets, erased)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		server.previewMutex.Lock()
+		if server.previews != nil {
+			server.previews.Forget(a.ID)
+		}
+		server.previewMutex.Unlock()
+		if server.profiles != nil {
+			server.profiles.Forget(a.ID)
+		}
+	}
+
+	if eraser, ok := server.privacyService.(privacy.JobEraser); ok {
+		erased, running := eraser.EraseJobs(append([]string{productID}, assetIDs...))
+		report.Running = append(report.Running, running...)
+		for _, id := range erased {
+			if !slices.Contains(computations, id) {
+				computations = append(computations, id)
+			}
+		}
+	}
+	for _, id := range computations {
+		if slices.Contains(report.Running, id) {
+			continue
+		}
+		report.Computations = append(report.Computations, id)
+		server.releasePin(r, pinning.KindComputation, id, &report)
+	}
+	for _, id := range jobIDs {
+		if !server.eraseTrainingJob(r, id, &report) {
+			report.Running = append(report.Running, id)
+			continue
+		}
+		report.TrainingJobs = append(report.TrainingJobs, id)
+	}
+	sort.Strings(report.Computations)
+	sort.Strings(report.Running)
+
+	server.recordAudit(AuditAdminDataErased, actor, map[string]any{
+		"product_id":    productID,
+		"assets":        assetIDs,
+		"leases":        report.Leases,
+		"training_jobs": report.TrainingJobs,
+		"computations":  report.Computations,
+		"models":        report.Models,
+		"unpinned":      report.Unpinned,
+		"running":       report.Running,
+		"errors":        report.Errors,
+	})
+	server.logger.Warn("product data erased", "product_id", productID, "assets", len(report.Assets),
+		"training_jobs", len(report.TrainingJobs), "computations", len(report.Computations), "errors", len(report.Errors))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		server.logger.Error("failed to encode erasure report", "error", err)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// erasingPrivacyService drops every computation on the erased inputs
+type erasingPrivacyService struct {
+	MockPrivacyService
+	inputs []string
+}
+
+var _ privacy.JobEraser = (*erasingPrivacyService)(nil)
+
+func (m *erasingPrivacyService) EraseJobs(inputs []string) (erased, running []string) {
+	m.inputs = inputs
+	return []string{"comp-1"}, []string{"comp-2"}
+}
+
+func TestServer_eraseProductData(t *testing.T) {
+	productsDir := t.TempDir()
+	quarantineFile := filepath.Join(t.TempDir(), "quarantine.json")
+
+	const productID = "did:pandacea:earner:123/abc-456"
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	privacyService := &erasingPrivacyService{}
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, privacyService, nil)
+	server.SetProductsDir(productsDir)
+	require.NoError(t, server.SetQuarantineFile(quarantineFile))
+	registry, err := assets.NewRegistry("", "")
+	require.NoError(t, err)
+	server.SetAssets(registry)
+	modelRegistry, err := models.NewRegistry("")
+	require.NoError(t, err)
+	server.SetModels(modelRegistry)
+	graph, err := lineage.NewGraph("")
+	require.NoError(t, err)
+	server.SetLineage(graph)
+
+	source := filepath.Join(t.TempDir(), "scans.csv")
+	require.NoError(t, os.WriteFile(source, []byte("id,depth\n1,0.5\n"), 0600))
+	_, err = registry.Register(context.Background(), assets.Asset{ID: "scans", ProductID: productID, Source: source, Format: "csv"})
+	require.NoError(t, err)
+	server.pendingLeases["lease_prop_1"] = &LeaseProposalState{Status: "approved", ProductID: productID}
+
+	// A finished training job on the product, with its artifact and model
+	now := time.Now()
+	job := &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), Dataset: productID, Task: "forecast",
+		CreatedAt: now, UpdatedAt: now}
+	server.jobs[job.JobID] = job
+	server.recordTrainingLineage(job.JobID, job.Dataset, "")
+	dir := filepath.Join(productsDir, job.JobID)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aggregate.json"), []byte(`{"n": 10}`), 0644))
+	server.finishTrainingJob(job.JobID, job, filepath.Join(dir, "aggregate.json"))
+	_, registered := modelRegistry.Get("job-1")
+	require.True(t, registered)
+
+	erase := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/products/"+path, nil)
+		req.Header.Set("X-Pandacea-Peer-ID", "admin-peer")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("*", path)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleEraseProductData(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, erase(productID).Code, "the path must end in /data")
+
+	w := erase(productID + "/data")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report ErasureReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	require.Len(t, report.Assets, 1)
+	assert.True(t, report.Assets[0].Deleted)
+	assert.Equal(t, []string{"lease_prop_1"}, report.Leases)
+	assert.Equal(t, []string{"job-1"}, report.TrainingJobs)
+	assert.Equal(t, []string{"job-1"}, report.Models)
+	assert.Equal(t, []string{"comp-1"}, report.Computations)
+	assert.Equal(t, []string{"comp-2"}, report.Running)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, []string{productID, "scans"}, privacyService.inputs)
+
+	_, err = os.Stat(source)
+	assert.True(t, os.IsNotExist(err), "the local copy is deleted")
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "the job's artifacts are deleted")
+	_, registered = modelRegistry.Get("job-1")
+	assert.False(t, registered)
+
+	// Computations naming the product or its erased asset are refused, and
+	// the quarantine cannot be lifted
+	assert.Equal(t, productID, server.assetProduct("scans"))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute",
+		strings.NewReader(`{"lease_id":"lease_prop_1","inputs":[{"asset_id":"scans"}]}`))
+	req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+	w = httptest.NewRecorder()
+	server.handleExecuteComputation(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeDataErased)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/admin/security/quarantine/"+productID, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("*", productID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	server.handleLiftQuarantine(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// The erasure survives a restart
+	restarted := NewServer(denyEvaluator{}, logger, &p2p.Node{}, privacyService, nil)
+	require.NoError(t, restarted.SetQuarantineFile(quarantineFile))
+	assert.Equal(t, productID, restarted.assetProduct("scans"))
+}
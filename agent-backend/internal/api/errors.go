@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/federation"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/revocation"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/spender"
+	"pandacea/agent-backend/internal/txmgr"
+)
+
+// errUnsealable is returned when results must be sealed to the caller's
+// key and the caller's peer ID embeds no usable one
+var errUnsealable = errors.New("cannot seal results")
+
+// errNoResultOwner is returned when a computation would be queued with no
+// spender peer to bind its results to
+var errNoResultOwner = errors.New("computation results have no owner")
+
+// errNetworkConflict is returned when a request names a network other than
+// the one the operator pinned the leased product to
+var errNetworkConflict = errors.New("network does not match the product's network")
+
+// errorMapping is the HTTP response for a sentinel error
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings maps sentinel errors from internal packages to HTTP
+// responses. The first match wins, so more specific errors come first.
+var errorMappings = []errorMapping{
+	{privacy.ErrInvalidRequest, http.StatusBadRequest, ErrorCodeValidationError},
+	{errUnsealable, http.StatusBadRequest, ErrorCodeValidationError},
+	{errNoResultOwner, http.StatusUnauthorized, ErrorCodeUnauthorized},
+	{errNetworkConflict, http.StatusBadRequest, ErrorCodeValidationError},
+	{privacy.ErrInvalidLeaseID, http.StatusBadRequest, ErrorCodeValidationError},
+	{privacy.ErrInvalidDPParameters, http.StatusBadRequest, ErrorCodeValidationError},
+	{privacy.ErrLeaseNotFound, http.StatusNotFound, ErrorCodeLeaseNotFound},
+	{privacy.ErrLeaseNotApproved, http.StatusForbidden, ErrorCodeLeaseNotApproved},
+	{privacy.ErrLeaseExecuted, http.StatusConflict, ErrorCodeLeaseExecuted},
+	{privacy.ErrLeaseDisputed, http.StatusConflict, ErrorCodeLeaseDisputed},
+	{privacy.ErrSpenderMismatch, http.StatusForbidden, ErrorCodeForbidden},
+	{privacy.ErrPurposeMismatch, http.StatusForbidden, ErrorCodePurposeMismatch},
+	{privacy.ErrComputationNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{privacy.ErrPoolExhausted, http.StatusServiceUnavailable, ErrorCodePoolExhausted},
+	{privacy.ErrBudgetExceeded, http.StatusUnprocessableEntity, ErrorCodeBudgetExceeded},
+	{privacy.ErrStaleAssignment, http.StatusConflict, ErrorCodeStaleAssignment},
+	{privacy.ErrUnknownNetwork, http.StatusBadRequest, ErrorCodeValidationError},
+	{scheduler.ErrQueueFull, http.StatusServiceUnavailable, ErrorCodeQueueFull},
+	{p2p.ErrInvalidPeer, http.StatusBadRequest, ErrorCodeValidationError},
+	{market.ErrInvalidQuery, http.StatusBadRequest, ErrorCodeValidationError},
+	{p2p.ErrInvalidAddr, http.StatusBadRequest, ErrorCodeValidationError},
+	{p2p.ErrConnectFailed, http.StatusBadGateway, ErrorCodePeerUnreachable},
+	{spender.ErrInvalidRequest, http.StatusBadRequest, ErrorCodeValidationError},
+	{spender.ErrNoOffer, http.StatusNotFound, ErrorCodeNotFound},
+	{spender.ErrUnreachable, http.StatusBadGateway, ErrorCodePeerUnreachable},
+	{txmgr.ErrQueueFull, http.StatusServiceUnavailable, ErrorCodeQueueFull},
+	{txmgr.ErrInvalidRequest, http.StatusBadRequest, ErrorCodeValidationError},
+	{scheduler.ErrIdentityQueueFull, http.StatusTooManyRequests, ErrorCodeTooManyQueued},
+	{scheduler.ErrClosed, http.StatusServiceUnavailable, ErrorCodeQueueFull},
+	{security.ErrTooManyChallenges, http.StatusTooManyRequests, ErrorCodeTooManyChallenges},
+	{security.ErrInvalidBan, http.StatusBadRequest, ErrorCodeValidationError},
+	{security.ErrUnknownBlockList, http.StatusBadRequest, ErrorCodeValidationError},
+	{security.ErrReplayedNonce, http.StatusUnauthorized, ErrorCodeReplayDetected},
+	{federation.ErrInvalidPlan, http.StatusBadRequest, ErrorCodeValidationError},
+	{federation.ErrNoParticipants, http.StatusConflict, ErrorCodeNoParticipants},
+	{gpu.ErrUnavailable, http.StatusConflict, ErrorCodeGPUUnavailable},
+	{models.ErrNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{models.ErrInvalidRequest, http.StatusBadRequest, ErrorCodeValidationError},
+	{lineage.ErrInvalidQuery, http.StatusBadRequest, ErrorCodeValidationError},
+	{dispute.ErrInvalidEvidence, http.StatusBadRequest, ErrorCodeValidationError},
+	{delivery.ErrNoSource, http.StatusNotFound, ErrorCodeNotFound},
+	{assets.ErrInvalidAsset, http.StatusBadRequest, ErrorCodeValidationError},
+	{assets.ErrNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{assets.ErrNoPreview, http.StatusUnprocessableEntity, ErrorCodeValidationError},
+	{policy.ErrInvalidDuration, http.StatusBadRequest, ErrorCodeValidationError},
+	{reqsig.ErrStaleTimestamp, http.StatusUnauthorized, ErrorCodeStaleRequest},
+	{reqsig.ErrUnsupportedVersion, http.StatusBadRequest, ErrorCodeInvalidRequest},
+	{reqsig.ErrInvalidHeaders, http.StatusUnauthorized, ErrorCodeUnauthorized},
+	{revocation.ErrRevoked, http.StatusForbidden, ErrorCodeRevoked},
+	{revocation.ErrInvalidIdentity, http.StatusBadRequest, ErrorCodeValidationError},
+	{revocation.ErrNotRevoked, http.StatusNotFound, ErrorCodeNotFound},
+}
+
+// errorResponseFor returns the status and code for err, or false if err does
+// not wrap a known sentinel
+func errorResponseFor(err error) (int, string, bool) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code, true
+		}
+	}
+	return 0, "", false
+}
+
+// sendError sends the mapped response for err with its message. Unmapped
+// errors are internal, so the client only sees fallback.
+func (server *Server) sendError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	if status, code, ok := errorResponseFor(err); ok {
+		server.sendErrorResponse(w, r, status, code, err.Error())
+		return
+	}
+	server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, fallback)
+}
+
+// handleNotFound answers requests for routes that do not exist
+func (server *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "No route for "+r.URL.Path)
+}
+
+// handleMethodNotAllowed answers requests using a method the route does not
+// accept
+func (server *Server) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	server.sendErrorResponse(w, r, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
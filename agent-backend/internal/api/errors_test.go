@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/scheduler"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponseFor(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantOK     bool
+	}{
+		{"sentinel", privacy.ErrLeaseNotApproved, http.StatusForbidden, ErrorCodeLeaseNotApproved, true},
+		{"wrapped sentinel", fmt.Errorf("%w: lease 7", privacy.ErrLeaseNotFound), http.StatusNotFound, ErrorCodeLeaseNotFound, true},
+		{"joined sentinel", errors.Join(errors.New("busy"), scheduler.ErrQueueFull), http.StatusServiceUnavailable, ErrorCodeQueueFull, true},
+		{"unmapped", errors.New("disk on fire"), 0, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, code, ok := errorResponseFor(tc.err)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantStatus, status)
+			assert.Equal(t, tc.wantCode, code)
+		})
+	}
+}
+
+func TestServer_sendError(t *testing.T) {
+	server := NewServer(&policy.Engine{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+
+	send := func(err error) (int, ErrorResponse) {
+		w := httptest.NewRecorder()
+		server.sendError(w, httptest.NewRequest("GET", "/", nil), err, "Something went wrong")
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		return w.Code, resp
+	}
+
+	status, resp := send(fmt.Errorf("%w: epsilon 3 exceeds 1", privacy.ErrBudgetExceeded))
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, ErrorCodeBudgetExceeded, resp.Error.Code)
+	assert.Contains(t, resp.Error.Message, "epsilon 3 exceeds 1")
+
+	// Internal errors never leak their message
+	status, resp = send(errors.New("open /var/lib/pandacea: permission denied"))
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, ErrorCodeInternalError, resp.Error.Code)
+	assert.Equal(t, "Something went wrong", resp.Error.Message)
+}
+
+// TestServer_errorEnvelope checks that legacy routes and the router's own
+// rejections answer with the standard error envelope
+func TestServer_errorEnvelope(t *testing.T) {
+	server := NewServer(&policy.Engine{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"malformed train body", "POST", "/train", "{", http.StatusBadRequest, ErrorCodeInvalidRequest},
+		{"train without dataset", "POST", "/train", `{"task":"classification"}`, http.StatusBadRequest, ErrorCodeValidationError},
+		{"train with bad epsilon", "POST", "/train", `{"dataset":"d","task":"t","dp":{"enabled":true,"epsilon":-1}}`, http.StatusBadRequest, ErrorCodeValidationError},
+		{"unknown job", "GET", "/aggregate/job_missing", "", http.StatusNotFound, ErrorCodeNotFound},
+		{"unsigned train", "POST", "/train", "", http.StatusUnauthorized, ErrorCodeUnauthorized},
+		{"unknown route", "GET", "/no/such/route", "", http.StatusNotFound, ErrorCodeNotFound},
+		{"wrong method", "GET", "/train", "", http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			// Legacy routes authenticate callers like /api/v1
+			if tc.wantCode != ErrorCodeUnauthorized {
+				require.NoError(t, reqsig.Sign(priv, req, []byte(tc.body), time.Now()))
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+			assert.Equal(t, tc.wantCode, resp.Error.Code)
+			assert.NotEmpty(t, resp.Error.Message)
+			assert.NotEmpty(t, resp.Error.RequestID)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/reqsig"
+)
+
+// Status event types pushed to their owners on /api/v1/events/stream
+const (
+	EventLeaseStatus          = "lease.status"
+	EventJobProgress          = "job.progress"
+	EventComputationCompleted = "computation.completed"
+	EventProductQuarantined   = "product.quarantined" // Sent to active lease holders on the product
+	EventLeaseTransferred     = "lease.transferred"   // Sent to the previous and new holder
+)
+
+// eventStreamPath is the full path of the status event stream. Streamed
+// responses cannot be buffered, so they are not signed.
+const eventStreamPath = "/api/v1/events/stream"
+
+// Event stream timing
+const (
+	eventStreamRetry     = 3 * time.Second
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+// publishStatus records a status event for owner. Events without an owner
+// would never be delivered, so they are dropped.
+func (server *Server) publishStatus(eventType, owner string, fields map[string]any) {
+	if owner == "" {
+		return
+	}
+	server.statusEvents.Append(eventType, owner, fields)
+}
+
+// setLeaseOwner records who proposed a lease so its status transitions are
+// streamed to them, and publishes its current status
+func (server *Server) setLeaseOwner(leaseProposalID, owner string) {
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	state, exists := server.pendingLeases[leaseProposalID]
+	if !exists {
+		return
+	}
+	state.owner = owner
+	server.publishLeaseStatus(leaseProposalID, state)
+}
+
+// publishLeaseStatus streams a lease's status to its owner. Caller must hold leasesMutex.
+func (server *Server) publishLeaseStatus(leaseProposalID string, state *LeaseProposalState) {
+	fields := map[string]any{
+		"lease_proposal_id": leaseProposalID,
+		"status":            state.Status,
+	}
+	if state.LeaseID != nil {
+		fields["lease_id"] = *state.LeaseID
+	}
+	if state.ExpiresAt != nil {
+		fields["expires_at"] = *state.ExpiresAt
+	}
+	server.publishStatus(EventLeaseStatus, state.owner, fields)
+}
+
+// publishJobProgress streams a training job's status to its owner. Caller must hold jobsMutex.
+func (server *Server) publishJobProgress(job *TrainingJob) {
+	fields := map[string]any{
+		"job_id": job.JobID,
+		"status": job.Status,
+	}
+	if job.ArtifactPath != "" {
+		fields["artifact_path"] = job.ArtifactPath
+	}
+	if job.Error != "" {
+		fields["error"] = job.Error
+	}
+	if job.Federation != nil {
+		fields["completed_rounds"] = job.Federation.CompletedRounds
+		fields["rounds"] = job.Federation.Rounds
+	}
+	if job.Progress != nil {
+		fields["epoch"] = job.Progress.Epoch
+		fields["epochs"] = job.Progress.Epochs
+		fields["samples_processed"] = job.Progress.Samples
+		if job.Progress.Loss != nil {
+			fields["loss"] = *job.Progress.Loss
+		}
+	}
+	server.publishStatus(EventJobProgress, job.owner, fields)
+}
+
+// setComputationOwner records who queued a computation so its completion is
+// streamed to them
+func (server *Server) setComputationOwner(computationID, owner string) {
+	server.ownersMutex.Lock()
+	defer server.ownersMutex.Unlock()
+	server.computations[computationID] = owner
+}
+
+// dropComputationOwner forgets the owner of a computation that was not queued
+func (server *Server) dropComputationOwner(computationID string) {
+	server.ownersMutex.Lock()
+	defer server.ownersMutex.Unlock()
+	delete(server.computations, computationID)
+}
+
+// publishComputationStatus is registered with the privacy service and streams
+// terminal computation statuses to the computation's owner
+func (server *Server) publishComputationStatus(computationID, status string) {
+	server.ownersMutex.Lock()
+	owner, exists := server.computations[computationID]
+	delete(server.computations, computationID)
+	server.ownersMutex.Unlock()
+	if !exists {
+		return
+	}
+
+	server.publishStatus(EventComputationCompleted, owner, map[string]any{
+		"computation_id": computationID,
+		"status":         status,
+	})
+}
+
+// handleStreamEvents handles GET /api/v1/events/stream. It pushes the
+// caller's lease, training job and computation status events as server-sent
+// events. The optional type parameter takes a comma-separated list of event
+// types; clients resume after a disconnect with Last-Event-ID or cursor.
+func (server *Server) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	identity := r.Header.Get(reqsig.HeaderPeerID)
+	if identity == "" {
+		server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Missing peer ID header")
+		return
+	}
+
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("cursor")
+	}
+
+	var types map[string]bool
+	if param := r.URL.Query().Get("type"); param != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(param, ",") {
+			switch t = strings.TrimSpace(t); t {
+			case EventLeaseStatus, EventJobProgress, EventComputationCompleted, EventProductQuarantined, EventLeaseTransferred:
+				types[t] = true
+			default:
+				server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, fmt.Sprintf("Unknown event type %q", t))
+				return
+			}
+		}
+	}
+
+	query := audit.Query{
+		Cursor: cursor,
+		Match: func(event audit.Event) bool {
+			return event.Actor == identity && (types == nil || types[event.Type])
+		},
+	}
+
+	// Check the cursor before committing to a streamed response
+	if _, err := server.statusEvents.List(audit.Query{Cursor: cursor, Limit: 1}); err != nil {
+		switch {
+		case errors.Is(err, audit.ErrInvalidCursor):
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Invalid cursor")
+		case errors.Is(err, audit.ErrCursorExpired):
+			server.sendErrorResponse(w, r, http.StatusGone, ErrorCodeCursorExpired, "Cursor is older than the retained events; reconnect without one")
+		default:
+			server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to list events")
+		}
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		server.logger.Error("event stream not supported by response writer", "error", err)
+		return
+	}
+
+	server.logger.Info("event stream opened", "peer_id", identity)
+	defer server.logger.Info("event stream closed", "peer_id", identity)
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		appended := server.statusEvents.Appended()
+
+		page, err := server.statusEvents.List(query)
+		if err != nil {
+			// Events were evicted while the client was too slow to read them
+			server.logger.Warn("event stream fell behind", "peer_id", identity, "error", err)
+			return
+		}
+		for _, event := range page.Events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				server.logger.Error("failed to encode stream event", "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", audit.Cursor(event.Seq), event.Type, data)
+		}
+		query.Cursor = page.NextCursor
+		if page.HasMore {
+			continue
+		}
+		if len(page.Events) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-appended:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
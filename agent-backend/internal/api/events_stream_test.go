@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamEvent is one server-sent event read from a stream
+type streamEvent struct {
+	id    string
+	event string
+	data  audit.Event
+}
+
+// readStreamEvent reads the next event from an SSE stream, skipping the
+// retry field and comments
+func readStreamEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+	var ev streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data))
+		}
+	}
+}
+
+func TestServer_handleStreamEvents(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/events/stream", server.handleStreamEvents)
+	ts := httptest.NewServer(router)
+	// Registered first so open streams are closed before the server waits on them
+	t.Cleanup(ts.Close)
+
+	open := func(peerID, query, lastEventID string) (*http.Response, *bufio.Reader) {
+		req, err := http.NewRequest("GET", ts.URL+"/api/v1/events/stream"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, alice := open("alice", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	_, aliceJobs := open("alice", "?type=job.progress", "")
+
+	// Another identity's lease is never delivered to alice
+	server.UpdateLeaseStatus("lease_bob", "pending", nil, "", "", nil)
+	server.setLeaseOwner("lease_bob", "bob")
+
+	server.UpdateLeaseStatus("lease_alice", "pending", nil, "", "", nil)
+	server.setLeaseOwner("lease_alice", "alice")
+	first := readStreamEvent(t, alice)
+	assert.Equal(t, EventLeaseStatus, first.event)
+	assert.Equal(t, "alice", first.data.Actor)
+	assert.Equal(t, "lease_alice", first.data.Fields["lease_proposal_id"])
+	assert.Equal(t, "pending", first.data.Fields["status"])
+
+	leaseID := uint64(7)
+	server.UpdateLeaseStatus("lease_alice", "approved", &leaseID, "", "", nil)
+	approved := readStreamEvent(t, alice)
+	assert.Equal(t, "approved", approved.data.Fields["status"])
+	assert.Equal(t, float64(7), approved.data.Fields["lease_id"])
+
+	server.jobsMutex.Lock()
+	job := &TrainingJob{JobID: "job_1", Status: "pending", CreatedAt: time.Now(), owner: "alice"}
+	server.jobs[job.JobID] = job
+	server.jobsMutex.Unlock()
+	server.updateJobStatus("job_1", "running", "", "")
+
+	progress := readStreamEvent(t, aliceJobs)
+	assert.Equal(t, EventJobProgress, progress.event)
+	assert.Equal(t, "running", progress.data.Fields["status"])
+	assert.Equal(t, EventJobProgress, readStreamEvent(t, alice).event)
+
+	server.setComputationOwner("comp_1", "alice")
+	server.publishComputationStatus("comp_1", "completed")
+	completed := readStreamEvent(t, alice)
+	assert.Equal(t, EventComputationCompleted, completed.event)
+	assert.Equal(t, "comp_1", completed.data.Fields["computation_id"])
+
+	t.Run("resumes after Last-Event-ID", func(t *testing.T) {
+		_, resumed := open("alice", "", first.id)
+		assert.Equal(t, "approved", readStreamEvent(t, resumed).data.Fields["status"])
+	})
+
+	t.Run("rejects unknown types and cursors", func(t *testing.T) {
+		resp, _ := open("alice", "?type=chain.block", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _ = open("alice", "?cursor=garbage", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("requires an identity", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/events/stream", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
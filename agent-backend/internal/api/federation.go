@@ -0,0 +1,530 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/federation"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/usage"
+)
+
+// mockModelSize is the number of weights in models trained by mock rounds
+const mockModelSize = 10
+
+// FederationRequest represents a request to train a model across earner agents
+type FederationRequest struct {
+	Dataset      string   `json:"dataset"`
+	Task         string   `json:"task"`
+	Participants []string `json:"participants"`  // Earner peer IDs or multiaddrs ending in /p2p/<peer ID>
+	Select       int      `json:"select"`        // With no participants, how many to pick from the participant registry
+	Rounds       int      `json:"rounds"`        // Rounds of local training and aggregation
+	MinUpdates   int      `json:"min_updates"`   // Updates each round needs (0 requires every participant)
+	LocalEpsilon float64  `json:"local_epsilon"` // DP budget each participant spends per round (0 trains without local DP)
+	Secure       bool     `json:"secure"`        // Mask updates so only their sum is seen (needs 2+ updates per round)
+	DP           struct {
+		Enabled  bool    `json:"enabled"`
+		Epsilon  float64 `json:"epsilon"`   // Central budget for the aggregate over all rounds
+		ClipNorm float64 `json:"clip_norm"` // Bound on each participant's change to the model (default 1)
+	} `json:"dp"`
+}
+
+// Federation is the round-by-round progress of a federated training job
+// this agent coordinates
+type Federation struct {
+	Participants    []string                 `json:"participants"`
+	Rounds          int                      `json:"rounds"`
+	MinUpdates      int                      `json:"min_updates"`
+	Secure          bool                     `json:"secure,omitempty"`
+	CompletedRounds int                      `json:"completed_rounds"`
+	RoundReports    []federation.RoundReport `json:"round_reports,omitempty"`
+}
+
+// SetFederation enables federated training. A transport makes this agent a
+// coordinator; participants also register the server as their
+// federation.Trainer with federation.Serve.
+func (server *Server) SetFederation(cfg config.FederationConfig, transport federation.Transport) {
+	server.federated = cfg
+	if transport != nil {
+		server.coordinator = federation.NewCoordinator(transport, server.logger)
+	}
+}
+
+// ParticipantsResponse lists the participants that announced themselves
+// to this coordinator
+type ParticipantsResponse struct {
+	Data []federation.Participant `json:"data"`
+}
+
+// SetParticipantRegistry lets federated jobs that name no participants
+// pick them from registry
+func (server *Server) SetParticipantRegistry(registry *federation.Registry) {
+	server.participants = registry
+}
+
+// FederationCapabilities returns what this agent announces to the
+// coordinators it trains rounds for: the products it holds that are not
+// quarantined and how it trains them
+func (server *Server) FederationCapabilities() federation.Capabilities {
+	caps := federation.Capabilities{
+		CPUs:       runtime.NumCPU(),
+		Backend:    server.trainingBackend.Name(),
+		LocalDP:    true,
+		SecureOnly: server.federated.RequireSecure,
+	}
+	for _, product := range server.products.List() {
+		if _, quarantined := server.quarantine(product.ProductID); !quarantined {
+			caps.Datasets = append(caps.Datasets, product.ProductID)
+		}
+	}
+	if server.scheduler != nil {
+		caps.Queued, _ = server.scheduler.Stats()
+	}
+	return caps
+}
+
+// handleListParticipants handles GET /api/v1/federation/participants
+func (server *Server) handleListParticipants(w http.ResponseWriter, r *http.Request) {
+	response := ParticipantsResponse{Data: []federation.Participant{}}
+	if server.participants != nil {
+		response.Data = server.participants.List()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode federation participants", "error", err)
+	}
+}
+
+// handleCreateFederation handles POST /api/v1/federation. The job is
+// tracked like any training job, with round progress under its federation
+// field.
+func (server *Server) handleCreateFederation(w http.ResponseWriter, r *http.Request) {
+	if server.coordinator == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Federation coordinator mode is not enabled")
+		return
+	}
+
+	var req FederationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if limit, tooLarge := bodyTooLarge(err); tooLarge {
+			server.rejectBodyTooLarge(w, r, limit)
+			return
+		}
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if req.Dataset == "" || req.Task == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "dataset and task are required")
+		return
+	}
+	if req.Rounds > server.federated.MaxRounds {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError,
+			fmt.Sprintf("rounds cannot exceed %d", server.federated.MaxRounds))
+		return
+	}
+	if req.LocalEpsilon < 0 || (req.DP.Enabled && req.DP.Epsilon <= 0) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "DP epsilon must be positive")
+		return
+	}
+	if len(req.Participants) == 0 && req.Select > 0 {
+		if server.participants == nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "participant registry is not enabled; list the participants")
+			return
+		}
+		selected, err := server.participants.Select(federation.Criteria{
+			Dataset:       req.Dataset,
+			Count:         req.Select,
+			LocalDP:       req.LocalEpsilon > 0,
+			Secure:        req.Secure,
+			MinReputation: server.federated.MinParticipantReputation,
+		})
+		if err != nil {
+			server.sendError(w, r, err, "Failed to select participants")
+			return
+		}
+		req.Participants = selected
+		server.logger.Info("federation participants selected", "dataset", req.Dataset, "participants", selected)
+	}
+	for _, participant := range req.Participants {
+		if _, err := federation.ParticipantID(participant); err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, err.Error())
+			return
+		}
+	}
+
+	jobID := fmt.Sprintf("job_%d", time.Now().UnixNano())
+	plan := federation.Plan{
+		FederationID: jobID,
+		Participants: req.Participants,
+		Rounds:       req.Rounds,
+		MinUpdates:   req.MinUpdates,
+		Dataset:      req.Dataset,
+		Task:         req.Task,
+		Epsilon:      req.LocalEpsilon,
+		RoundTimeout: time.Duration(server.federated.RoundTimeoutSeconds) * time.Second,
+		Secure:       req.Secure,
+	}
+
+	// Every participant contributes to every round, so the central mechanism
+	// is accounted as one full-batch step per round
+	var dp *privacy.DPParameters
+	if req.DP.Enabled {
+		dp = &privacy.DPParameters{ClippingNorm: req.DP.ClipNorm, SampleRate: 1, Steps: req.Rounds, Delta: privacy.DefaultDPDelta}
+		if dp.ClippingNorm == 0 {
+			dp.ClippingNorm = 1
+		}
+		if req.Rounds > 0 {
+			sigma, err := privacy.CalibrateNoiseMultiplier(req.DP.Epsilon, dp.SampleRate, dp.Steps, dp.Delta)
+			if err != nil {
+				server.sendError(w, r, err, "Failed to calibrate DP noise")
+				return
+			}
+			dp.NoiseMultiplier = sigma
+		}
+		plan.Noise = &federation.NoiseConfig{ClipNorm: dp.ClippingNorm, NoiseMultiplier: dp.NoiseMultiplier}
+	}
+	if err := plan.Validate(); err != nil {
+		server.sendError(w, r, err, "Invalid federation plan")
+		return
+	}
+
+	now := time.Now()
+	job := &TrainingJob{
+		JobID:     jobID,
+		Status:    string(trainingJobs.Start()),
+		Dataset:   req.Dataset,
+		Task:      req.Task,
+		Epsilon:   req.DP.Epsilon,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Federation: &Federation{
+			Participants: req.Participants,
+			Rounds:       req.Rounds,
+			MinUpdates:   req.MinUpdates,
+			Secure:       req.Secure,
+		},
+		owner: r.Header.Get(reqsig.HeaderPeerID),
+	}
+
+	server.jobsMutex.Lock()
+	server.jobs[jobID] = job
+	if err := server.queueFederation(job, plan, dp); err != nil {
+		delete(server.jobs, jobID)
+		server.jobsMutex.Unlock()
+		server.logger.Warn("federated training job rejected by scheduler", "dataset", req.Dataset, "error", err)
+		server.sendError(w, r, err, "Failed to queue federated training job")
+		return
+	}
+	server.persistJob(job)
+	server.publishJobProgress(job)
+	server.jobsMutex.Unlock()
+
+	server.recordTrainingLineage(jobID, req.Dataset, "")
+	server.recordUsage(job.owner, usage.Counters{JobsStarted: 1})
+	server.recordAudit(AuditTrainingQueued, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"job_id":       jobID,
+		"dataset":      req.Dataset,
+		"task":         req.Task,
+		"epsilon":      req.DP.Epsilon,
+		"participants": req.Participants,
+		"rounds":       req.Rounds,
+		"secure":       req.Secure,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(TrainResponse{JobID: jobID}); err != nil {
+		server.logger.Error("failed to encode federation response", "error", err)
+	}
+
+	server.logger.Info("federated training job queued", "job_id", jobID, "dataset", req.Dataset, "participants", len(req.Participants), "rounds", req.Rounds)
+}
+
+// queueFederation runs a federated job on the scheduler, queued under its
+// owner's identity like the rounds participants train, or at once without
+// one. Caller must hold jobsMutex.
+func (server *Server) queueFederation(job *TrainingJob, plan federation.Plan, dp *privacy.DPParameters) error {
+	run := func() {
+		server.jobsMutex.RLock()
+		pending := job.Status == string(TrainingStatusPending)
+		server.jobsMutex.RUnlock()
+		if pending {
+			server.runFederation(job, plan, dp)
+		}
+	}
+	if server.scheduler == nil {
+		go run()
+		return nil
+	}
+	job.Priority = scheduler.PriorityNormal.String()
+	return server.scheduler.Submit(scheduler.Task{
+		ID:       job.JobID,
+		Identity: job.owner,
+		Priority: scheduler.PriorityNormal,
+		Run:      run,
+		Drop: func() {
+			server.updateJobStatus(job.JobID, string(TrainingStatusFailed), "", "Agent stopped before the job started")
+		},
+	})
+}
+
+// runFederation coordinates a federated job's rounds and writes the final
+// global model as the job's artifact
+func (server *Server) runFederation(job *TrainingJob, plan federation.Plan, dp *privacy.DPParameters) {
+	jobID := job.JobID
+	server.updateJobStatus(jobID, "running", "", "")
+
+	result, err := server.coordinator.Run(context.Background(), plan, func(report federation.RoundReport) {
+		server.recordRound(job, report)
+	})
+	if err != nil {
+		if result != nil && len(result.Rounds) > 0 {
+			server.recordRound(job, result.Rounds[len(result.Rounds)-1])
+		}
+		server.logger.Error("federated training failed", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Federated training failed: %v", err))
+		return
+	}
+
+	outputDir := filepath.Join(server.productsDir, jobID)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		server.logger.Error("failed to create output directory", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to create output directory: %v", err))
+		return
+	}
+
+	last := result.Rounds[len(result.Rounds)-1]
+	artifact := map[string]interface{}{
+		"job_id":       jobID,
+		"dataset":      plan.Dataset,
+		"task":         plan.Task,
+		"model":        encodeModel(result.Model),
+		"n":            last.Samples,
+		"participants": plan.Participants,
+		"rounds":       result.Rounds,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	if dp != nil {
+		report, err := privacy.NewDPReport(*dp, job.Epsilon)
+		if err != nil {
+			server.logger.Error("DP accounting failed", "error", err, "job_id", jobID)
+			server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("DP accounting failed: %v", err))
+			return
+		}
+		server.jobsMutex.Lock()
+		job.DPReport = report
+		server.jobsMutex.Unlock()
+
+		artifact["dp"] = map[string]interface{}{
+			"enabled":          true,
+			"epsilon":          report.Epsilon,
+			"clip":             dp.ClippingNorm,
+			"noise_multiplier": dp.NoiseMultiplier,
+			"delta":            dp.Delta,
+		}
+	}
+
+	aggregatePath := fmt.Sprintf("%s/aggregate.json", outputDir)
+	data, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		server.logger.Error("failed to marshal federated model", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to marshal result: %v", err))
+		return
+	}
+	if err := os.WriteFile(aggregatePath, data, 0644); err != nil {
+		server.logger.Error("failed to write federated model", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to write result: %v", err))
+		return
+	}
+
+	server.finishTrainingJob(jobID, job, aggregatePath)
+	server.logger.Info("federated training job completed", "job_id", jobID, "rounds", len(result.Rounds), "output", aggregatePath)
+}
+
+// recordRound adds a round report to a federated job and streams the
+// progress to the job's owner. The progress is replaced rather than
+// modified, so snapshots taken by readers stay consistent.
+func (server *Server) recordRound(job *TrainingJob, report federation.RoundReport) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	progress := *job.Federation
+	progress.RoundReports = append(slices.Clip(progress.RoundReports), report)
+	if report.Updates > 0 {
+		progress.CompletedRounds++
+	}
+	job.Federation = &progress
+	job.UpdatedAt = time.Now()
+	server.persistJob(job)
+	server.publishJobProgress(job)
+}
+
+// TrainRound trains one round of another agent's federation through the
+// local training pipeline, so the round is subject to quarantines, privacy
+// budgets and DP accounting like any training job. It implements
+// federation.Trainer.
+func (server *Server) TrainRound(ctx context.Context, coordinator string, req federation.RoundRequest) (federation.RoundUpdate, error) {
+	if !server.federated.Participant || !slices.Contains(server.federated.AllowedCoordinators, coordinator) {
+		return federation.RoundUpdate{}, fmt.Errorf("%w: %s", federation.ErrNotAllowed, coordinator)
+	}
+	if req.Dataset == "" || req.Task == "" || req.Epsilon < 0 {
+		return federation.RoundUpdate{}, fmt.Errorf("round request needs a dataset, a task and a non-negative epsilon")
+	}
+	if server.federated.RequireSecure && req.Phase != federation.PhaseMasked {
+		return federation.RoundUpdate{}, fmt.Errorf("%w: rounds must use secure aggregation", federation.ErrNotAllowed)
+	}
+	if q, quarantined := server.quarantine(req.Dataset); quarantined {
+		server.recordAudit(AuditQuarantined, coordinator, map[string]any{
+			"product_id":    req.Dataset,
+			"federation_id": req.FederationID,
+			"round":         req.Round,
+		})
+		return federation.RoundUpdate{}, fmt.Errorf("%s is quarantined: %s", req.Dataset, q.Reason)
+	}
+
+	jobID := fmt.Sprintf("job_%d", time.Now().UnixNano())
+	if server.budgets != nil {
+		if err := server.budgets.Reserve(req.Dataset, jobID, req.Epsilon); err != nil {
+			return federation.RoundUpdate{}, err
+		}
+	}
+
+	now := time.Now()
+	job := &TrainingJob{
+		JobID:        jobID,
+		Status:       string(trainingJobs.Start()),
+		Dataset:      req.Dataset,
+		Task:         req.Task,
+		Epsilon:      req.Epsilon,
+		FederationID: req.FederationID,
+		Round:        req.Round,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		owner:        coordinator,
+		model:        req.Model,
+	}
+
+	// Rounds share the scheduler with local jobs, queued under the
+	// coordinator's identity
+	finished := make(chan struct{})
+	server.jobsMutex.Lock()
+	server.jobs[jobID] = job
+	if err := server.queueTrainingJob(job, coordinator, scheduler.PriorityNormal, func() { close(finished) }); err != nil {
+		delete(server.jobs, jobID)
+		server.jobsMutex.Unlock()
+		if server.budgets != nil {
+			if err := server.budgets.Release(jobID); err != nil {
+				server.logger.Error("failed to release privacy budget", "job_id", jobID, "error", err)
+			}
+		}
+		return federation.RoundUpdate{}, err
+	}
+	server.persistJob(job)
+	server.publishJobProgress(job)
+	server.jobsMutex.Unlock()
+
+	server.recordTrainingLineage(jobID, req.Dataset, "")
+	server.recordAudit(AuditTrainingQueued, coordinator, map[string]any{
+		"job_id":        jobID,
+		"dataset":       req.Dataset,
+		"task":          req.Task,
+		"epsilon":       req.Epsilon,
+		"federation_id": req.FederationID,
+		"round":         req.Round,
+	})
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		if server.scheduler != nil && server.scheduler.Cancel(jobID) {
+			server.updateJobStatus(jobID, string(TrainingStatusFailed), "", "Round cancelled before it started")
+		}
+		return federation.RoundUpdate{}, ctx.Err()
+	}
+
+	server.jobsMutex.RLock()
+	status, artifactPath, jobErr := job.Status, job.ArtifactPath, job.Error
+	server.jobsMutex.RUnlock()
+	if status != string(TrainingStatusComplete) {
+		return federation.RoundUpdate{}, fmt.Errorf("round training failed: %s", jobErr)
+	}
+	return server.readRoundUpdate(artifactPath)
+}
+
+// readRoundUpdate reads the trained model and sample count from a training
+// artifact, which may be encrypted at rest
+func (server *Server) readRoundUpdate(aggregatePath string) (federation.RoundUpdate, error) {
+	data, err := server.keyring.ReadFile(aggregatePath)
+	if err != nil {
+		return federation.RoundUpdate{}, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	var artifact struct {
+		Model string `json:"model"`
+		N     int    `json:"n"`
+	}
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return federation.RoundUpdate{}, fmt.Errorf("failed to parse artifact: %w", err)
+	}
+	weights, err := decodeModel(artifact.Model)
+	if err != nil {
+		return federation.RoundUpdate{}, err
+	}
+	return federation.RoundUpdate{Weights: weights, Samples: artifact.N}, nil
+}
+
+// encodeModel encodes weights as base64 little-endian float32s, the format
+// the training worker writes models in
+func encodeModel(weights []float64) string {
+	buf := make([]byte, 4*len(weights))
+	for i, w := range weights {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(w)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeModel decodes weights written by encodeModel or the training worker
+func decodeModel(encoded string) ([]float64, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("artifact model is not base64: %w", err)
+	}
+	if len(buf) == 0 || len(buf)%4 != 0 {
+		return nil, fmt.Errorf("artifact model is not a float32 array")
+	}
+	weights := make([]float64, len(buf)/4)
+	for i := range weights {
+		weights[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return weights, nil
+}
+
+// mockRoundModel simulates a round of local training by nudging the global
+// model, starting from zeros when there is none yet
+func mockRoundModel(global []float64) []float64 {
+	model := make([]float64, mockModelSize)
+	if len(global) > 0 {
+		model = make([]float64, len(global))
+		copy(model, global)
+	}
+	for i := range model {
+		model[i] += 0.01 * rand.NormFloat64()
+	}
+	return model
+}
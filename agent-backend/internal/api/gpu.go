@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/scheduler"
+)
+
+// GPURuntime is the GPU queue's current load and how busy the GPUs are
+type GPURuntime struct {
+	Devices     int         `json:"devices"`
+	Queued      int         `json:"queued"`
+	Running     int         `json:"running"`
+	Utilization []gpu.Usage `json:"utilization,omitempty"`
+}
+
+// SetGPUs lets training jobs and computations request GPUs. GPU jobs wait
+// on s, a queue separate from the scheduler's, and hold GPUs from devices
+// while they run. A non-nil monitor supplies the utilization job status
+// reports.
+func (server *Server) SetGPUs(s *scheduler.Scheduler, devices *gpu.Allocator, monitor *gpu.Monitor, indexes []int) {
+	server.gpuScheduler = s
+	server.gpus = devices
+	server.gpuMonitor = monitor
+	server.gpuIndexes = indexes
+	if gpuScheduler, ok := server.privacyService.(privacy.GPUScheduler); ok {
+		gpuScheduler.UseGPUs(s, devices, monitor)
+	}
+}
+
+// gpuRequest checks a job's GPU request and returns the GPUs it needs,
+// zero for a CPU job
+func (server *Server) gpuRequest(requested bool, count int) (int, error) {
+	if count < 0 || (count > 0 && !requested) {
+		return 0, fmt.Errorf("gpu_count must be positive and requires gpu")
+	}
+	if !requested {
+		return 0, nil
+	}
+	count = max(count, 1)
+	if server.gpus == nil {
+		return 0, fmt.Errorf("%w: this agent offers no GPUs", gpu.ErrUnavailable)
+	}
+	if count > server.gpus.Total() {
+		return 0, fmt.Errorf("%w: %d requested, %d on this agent", gpu.ErrUnavailable, count, server.gpus.Total())
+	}
+	return count, nil
+}
+
+// jobQueue returns the queue a training job waits on: the GPU queue for
+// GPU jobs, else the scheduler. It is nil if jobs start at once.
+func (server *Server) jobQueue(job *TrainingJob) *scheduler.Scheduler {
+	if job.GPU != nil {
+		return server.gpuScheduler
+	}
+	return server.scheduler
+}
+
+// gpuStatus returns a training job's GPU allocation with the latest
+// utilization of its GPUs while it runs. Caller must hold jobsMutex.
+func (server *Server) gpuStatus(job *TrainingJob) *gpu.Allocation {
+	if job.GPU == nil {
+		return nil
+	}
+	status := *job.GPU
+	if server.gpuMonitor != nil && len(status.Devices) > 0 && job.Status == string(TrainingStatusRunning) {
+		status.Utilization = server.gpuMonitor.Usage(status.Devices)
+	}
+	return &status
+}
+
+// gpuRuntime returns the GPU queue's load, or nil without GPUs
+func (server *Server) gpuRuntime() *GPURuntime {
+	if server.gpus == nil {
+		return nil
+	}
+	runtime := &GPURuntime{Devices: server.gpus.Total()}
+	if server.gpuScheduler != nil {
+		runtime.Queued, runtime.Running = server.gpuScheduler.Stats()
+	}
+	if server.gpuMonitor != nil {
+		runtime.Utilization = server.gpuMonitor.Usage(server.gpuIndexes)
+	}
+	return runtime
+}
+
+// acquireJobGPUs waits for the GPUs a training job requested and records
+// them on the job. It returns nil for a CPU job.
+func (server *Server) acquireJobGPUs(jobID string) ([]int, error) {
+	server.jobsMutex.RLock()
+	job := server.jobs[jobID]
+	var count int
+	if job != nil && job.GPU != nil {
+		count = job.GPU.Count
+	}
+	server.jobsMutex.RUnlock()
+	if count == 0 {
+		return nil, nil
+	}
+	if server.gpus == nil {
+		return nil, fmt.Errorf("%w: this agent offers no GPUs", gpu.ErrUnavailable)
+	}
+
+	devices, err := server.gpus.Acquire(context.Background(), count)
+	if err != nil {
+		return nil, err
+	}
+	server.jobsMutex.Lock()
+	job.GPU = &gpu.Allocation{Count: count, Devices: devices}
+	server.persistJob(job)
+	server.jobsMutex.Unlock()
+	return devices, nil
+}
+
+// releaseJobGPUs keeps the last utilization of a finished job's GPUs in
+// its status and returns them to the allocator
+func (server *Server) releaseJobGPUs(jobID string, devices []int) {
+	if len(devices) == 0 {
+		return
+	}
+	if server.gpuMonitor != nil {
+		usage := server.gpuMonitor.Usage(devices)
+		server.jobsMutex.Lock()
+		if job, exists := server.jobs[jobID]; exists && job.GPU != nil {
+			job.GPU.Utilization = usage
+			server.persistJob(job)
+		}
+		server.jobsMutex.Unlock()
+	}
+	server.gpus.Release(devices)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gpuBackend reports the GPUs each run holds and trains until released
+type gpuBackend struct {
+	started chan []int
+	release chan struct{}
+}
+
+func (gpuBackend) Name() string                 { return "gpu" }
+func (gpuBackend) Health(context.Context) error { return nil }
+
+func (b gpuBackend) Train(_ context.Context, run *TrainingRun) error {
+	b.started <- run.GPUs
+	<-b.release
+	return os.WriteFile(run.AggregatePath(), []byte(`{"n": 10}`), 0644)
+}
+
+func TestServer_gpuTrainingJobs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, nil, nil)
+	server.SetProductsDir(t.TempDir())
+	backend := gpuBackend{started: make(chan []int, 1), release: make(chan struct{})}
+	server.SetTrainingBackend(backend)
+
+	train := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/train", strings.NewReader(body))
+		req.Header.Set("X-Pandacea-Spender-Address", "0xalice")
+		w := httptest.NewRecorder()
+		server.handleTrain(w, req)
+		return w
+	}
+
+	// Without GPUs on the agent, GPU jobs are refused
+	w := train(`{"dataset":"ds","task":"classification","gpu":true}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeGPUUnavailable)
+	w = train(`{"dataset":"ds","task":"classification","gpu_count":2}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Occupy the only CPU worker; GPU jobs have their own queue
+	cpuScheduler := scheduler.New(1, 4, 4, logger)
+	defer cpuScheduler.Close()
+	server.SetScheduler(cpuScheduler, config.SchedulerConfig{})
+	blocked := make(chan struct{})
+	defer close(blocked)
+	require.NoError(t, cpuScheduler.Submit(scheduler.Task{ID: "blocker", Run: func() { <-blocked }}))
+
+	gpuScheduler := scheduler.New(1, 4, 4, logger)
+	defer gpuScheduler.Close()
+	devices := gpu.NewAllocator([]int{0, 1})
+	server.SetGPUs(gpuScheduler, devices, gpu.NewMonitor(logger), []int{0, 1})
+
+	w = train(`{"dataset":"ds","task":"classification","gpu":true,"gpu_count":3}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "more GPUs than the agent has")
+
+	w = train(`{"dataset":"ds","task":"classification","gpu":true,"gpu_count":2}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response TrainResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+	select {
+	case held := <-backend.started:
+		assert.Equal(t, []int{0, 1}, held)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GPU job did not start while the CPU queue was busy")
+	}
+
+	aggregate := func() TrainingJob {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/aggregate/"+response.JobID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobId", response.JobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleAggregate(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var job TrainingJob
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+		return job
+	}
+	job := aggregate()
+	assert.Equal(t, string(TrainingStatusRunning), job.Status)
+	require.NotNil(t, job.GPU)
+	assert.Equal(t, 2, job.GPU.Count)
+	assert.Equal(t, []int{0, 1}, job.GPU.Devices)
+
+	close(backend.release)
+	require.Eventually(t, func() bool {
+		return aggregate().Status == string(TrainingStatusComplete)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The finished job's GPUs are free again
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	freed, err := devices.Acquire(ctx, 2)
+	require.NoError(t, err)
+	devices.Release(freed)
+}
@@ -3,17 +3,23 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
+	"pandacea/agent-backend/internal/config"
 	"pandacea/agent-backend/internal/policy"
 	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
 
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,14 +62,35 @@ func setupTestServer(t *testing.T) *Server {
 	policyEngine := &policy.Engine{}
 	privacyService := &MockPrivacyService{}
 
-	server := NewServer(policyEngine, nil, nil, privacyService, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := NewServer(policyEngine, logger, nil, privacyService, nil)
 
-	// Set MOCK_DP environment variable for testing
-	os.Setenv("MOCK_DP", "1")
+	server.SetTrainingConfig(config.TrainingConfig{ExecutionMode: config.ExecutionModeMock})
+	server.SetProductsDir(testProductsDir(t))
 
 	return server
 }
 
+// testProductsDir returns a directory for the artifacts of training jobs a
+// test queues. Jobs outlive the request that queued them, so unlike
+// t.TempDir it is removed without failing the test if one is still writing.
+func testProductsDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "pandacea-products-")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// signRequest signs req with a fresh peer key, as the middleware requires
+// of every /api/v1 and legacy request
+func signRequest(t *testing.T, req *http.Request, body []byte) {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, reqsig.Sign(priv, req, body, time.Now()))
+}
+
 // TestAPIVersionHeader tests that API v1 endpoints set the correct version header
 func TestAPIVersionHeader(t *testing.T) {
 	server := setupTestServer(t)
@@ -100,12 +127,13 @@ func TestTrainEndpoint(t *testing.T) {
 
 	req := httptest.NewRequest("POST", "/api/v1/train", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	signRequest(t, req, reqBody)
 	w := httptest.NewRecorder()
 
 	server.router.ServeHTTP(w, req)
 
 	// Check response
-	assert.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
 	assert.Equal(t, "v1", w.Header().Get("X-API-Version"))
 
 	// Parse response
@@ -134,6 +162,7 @@ func TestAggregateEndpoint(t *testing.T) {
 
 	// Create a test job
 	jobID := "test-job-123"
+	completedAt := time.Now().UTC()
 	job := &TrainingJob{
 		JobID:        jobID,
 		Status:       "complete",
@@ -141,66 +170,38 @@ func TestAggregateEndpoint(t *testing.T) {
 		Task:         "classification",
 		Epsilon:      2.0,
 		ArtifactPath: "./data/products/test-job-123/aggregate.json",
-		CreatedAt:    time.Now(),
-		CompletedAt:  &time.Time{},
+		CreatedAt:    completedAt.Add(-time.Minute),
+		UpdatedAt:    completedAt,
+		CompletedAt:  &completedAt,
 	}
 
 	server.jobsMutex.Lock()
 	server.jobs[jobID] = job
 	server.jobsMutex.Unlock()
 
-	// Create the output directory and file
-	outputDir := "./data/products/test-job-123"
-	err := os.MkdirAll(outputDir, 0755)
-	require.NoError(t, err)
-
-	// Create a mock aggregate result
-	aggregateResult := map[string]interface{}{
-		"job_id":                jobID,
-		"dataset":               "test_dataset",
-		"task":                  "classification",
-		"epsilon_used":          2.0,
-		"model_accuracy":        0.85,
-		"samples_processed":     1000,
-		"training_time_seconds": 30.5,
-		"dp_noise_scale":        0.5,
-		"timestamp":             time.Now().Format(time.RFC3339),
-	}
-
-	resultBytes, err := json.MarshalIndent(aggregateResult, "", "  ")
-	require.NoError(t, err)
-
-	err = os.WriteFile(job.ArtifactPath, resultBytes, 0644)
-	require.NoError(t, err)
-
 	// Test the aggregate endpoint
 	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/aggregate/%s", jobID), nil)
+	signRequest(t, req, nil)
 	w := httptest.NewRecorder()
 
 	server.router.ServeHTTP(w, req)
 
 	// Check response
-	assert.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
 	assert.Equal(t, "v1", w.Header().Get("X-API-Version"))
 
-	// Parse response and validate schema
+	// The response is the job's status and where its artifact is
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	require.NoError(t, err)
 
-	// Check required fields exist
-	assert.Contains(t, response, "job_id")
-	assert.Contains(t, response, "dataset")
-	assert.Contains(t, response, "task")
-	assert.Contains(t, response, "epsilon_used")
-	assert.Contains(t, response, "model_accuracy")
-	assert.Contains(t, response, "samples_processed")
-	assert.Contains(t, response, "training_time_seconds")
-	assert.Contains(t, response, "dp_noise_scale")
-	assert.Contains(t, response, "timestamp")
-
-	// Clean up
-	os.RemoveAll(outputDir)
+	assert.Equal(t, jobID, response["job_id"])
+	assert.Equal(t, "complete", response["status"])
+	assert.Equal(t, "test_dataset", response["dataset"])
+	assert.Equal(t, "classification", response["task"])
+	assert.Equal(t, 2.0, response["epsilon"])
+	assert.Equal(t, job.ArtifactPath, response["artifact_path"])
+	assert.Contains(t, response, "completed_at")
 }
 
 // TestAggregateEndpointNotFound tests the aggregate endpoint for non-existent jobs
@@ -208,6 +209,7 @@ func TestAggregateEndpointNotFound(t *testing.T) {
 	server := setupTestServer(t)
 
 	req := httptest.NewRequest("GET", "/api/v1/aggregate/non-existent-job", nil)
+	signRequest(t, req, nil)
 	w := httptest.NewRecorder()
 
 	server.router.ServeHTTP(w, req)
@@ -278,6 +280,7 @@ func TestTrainEndpointInvalidRequest(t *testing.T) {
 
 			req := httptest.NewRequest("POST", "/api/v1/train", bytes.NewBuffer(reqBody))
 			req.Header.Set("Content-Type", "application/json")
+			signRequest(t, req, reqBody)
 			w := httptest.NewRecorder()
 
 			server.router.ServeHTTP(w, req)
@@ -287,11 +290,11 @@ func TestTrainEndpointInvalidRequest(t *testing.T) {
 	}
 }
 
-// TestLegacyEndpoints tests that legacy unversioned endpoints still work
+// TestLegacyEndpoints tests that legacy unversioned endpoints are served,
+// deprecated or retired as configured
 func TestLegacyEndpoints(t *testing.T) {
 	server := setupTestServer(t)
 
-	// Test legacy /train endpoint
 	trainReq := TrainRequest{
 		Dataset: "test_dataset",
 		Task:    "classification",
@@ -303,25 +306,45 @@ func TestLegacyEndpoints(t *testing.T) {
 			Epsilon: 2.0,
 		},
 	}
-
 	reqBody, err := json.Marshal(trainReq)
 	require.NoError(t, err)
 
-	req := httptest.NewRequest("POST", "/train", bytes.NewBuffer(reqBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-
-	server.router.ServeHTTP(w, req)
+	testCases := []struct {
+		mode           string
+		wantStatus     int
+		wantDeprecated bool
+	}{
+		{config.LegacyRoutesAllow, http.StatusAccepted, false},
+		{config.LegacyRoutesWarn, http.StatusAccepted, true},
+		{config.LegacyRoutesBlock, http.StatusGone, false},
+	}
 
-	// Legacy endpoint should still work but may log deprecation warning
-	assert.Equal(t, http.StatusCreated, w.Code)
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			server.SetLegacyRoutes(tc.mode)
+			req := httptest.NewRequest("POST", "/train", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			signRequest(t, req, reqBody)
+			w := httptest.NewRecorder()
 
-	// Parse response
-	var response TrainResponse
-	err = json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
+			server.router.ServeHTTP(w, req)
 
-	assert.NotEmpty(t, response.JobID)
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantDeprecated {
+				assert.Equal(t, "true", w.Header().Get("Deprecation"))
+				assert.Contains(t, w.Header().Get("X-API-Deprecation-Warning"), "/api/v1/train")
+			} else {
+				assert.Empty(t, w.Header().Get("Deprecation"))
+			}
+			if tc.wantStatus == http.StatusGone {
+				assert.Contains(t, w.Body.String(), ErrorCodeEndpointRetired)
+				return
+			}
+			var response TrainResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.NotEmpty(t, response.JobID)
+		})
+	}
 }
 
 // TestHealthEndpoint tests the health check endpoint
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/netutil"
+)
+
+var (
+	httpConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_http_connections",
+		Help: "Open HTTP connections by state (new, active, idle)",
+	}, []string{"state"})
+	httpConnectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_http_connections_total",
+		Help: "Total HTTP connections accepted",
+	})
+	httpConnectionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pandacea_http_connection_duration_seconds",
+		Help:    "How long HTTP connections stayed open",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	})
+)
+
+// connTracker publishes connection counts and lifetimes from http.Server's
+// ConnState hook
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]trackedConn
+}
+
+// trackedConn is the last known state of an open connection
+type trackedConn struct {
+	state  http.ConnState
+	opened time.Time
+}
+
+// newConnTracker creates an empty connection tracker
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]trackedConn)}
+}
+
+// track implements http.Server.ConnState
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, known := t.conns[conn]
+	if known {
+		httpConnections.WithLabelValues(tracked.state.String()).Dec()
+	}
+
+	switch state {
+	case http.StateNew:
+		httpConnectionsTotal.Inc()
+		tracked = trackedConn{opened: time.Now()}
+	case http.StateClosed, http.StateHijacked:
+		if known {
+			httpConnectionDuration.Observe(time.Since(tracked.opened).Seconds())
+		}
+		delete(t.conns, conn)
+		return
+	}
+
+	tracked.state = state
+	t.conns[conn] = tracked
+	httpConnections.WithLabelValues(state.String()).Inc()
+}
+
+// SetHTTPConfig sets the listener, TLS and keep-alive settings used by Start
+func (server *Server) SetHTTPConfig(cfg config.HTTPConfig) {
+	server.httpConfig = cfg
+}
+
+// newHTTPServer builds the HTTP server for the configured settings
+func (server *Server) newHTTPServer() (*http.Server, error) {
+	cfg := server.httpConfig
+	hs := &http.Server{
+		Handler:           server.router,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeoutSeconds) * time.Second,
+		ConnState:         newConnTracker().track,
+	}
+	hs.SetKeepAlivesEnabled(cfg.KeepAlive)
+
+	if !server.tlsEnabled() {
+		return hs, nil
+	}
+	tlsConfig, err := server.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	hs.TLSConfig = tlsConfig
+	if !cfg.EnableHTTP2 {
+		// A non-nil empty map stops net/http from negotiating HTTP/2
+		hs.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		return hs, nil
+	}
+	if err := http2.ConfigureServer(hs, &http2.Server{
+		MaxConcurrentStreams: cfg.MaxConcurrentStreams,
+		IdleTimeout:          hs.IdleTimeout,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+	}
+	return hs, nil
+}
+
+// tlsEnabled reports whether a certificate and key or ACME domains are configured
+func (server *Server) tlsEnabled() bool {
+	return server.httpConfig.TLSEnabled()
+}
+
+// tlsConfig builds the TLS settings: certificates from an ACME CA when
+// acme_domains is set, and client certificate verification for mutual TLS
+func (server *Server) tlsConfig() (*tls.Config, error) {
+	cfg := server.httpConfig
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(cfg.ACMEDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+			Email:      cfg.ACMEEmail,
+		}
+		if cfg.ACMECacheDir != "" {
+			manager.Cache = autocert.DirCache(cfg.ACMECacheDir)
+		}
+		if cfg.ACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+		}
+		tlsConfig.GetCertificate = manager.GetCertificate
+		// Answer TLS-ALPN-01 challenges on the HTTPS listener itself
+		tlsConfig.NextProtos = []string{acme.ALPNProto}
+	}
+
+	switch cfg.ClientAuth {
+	case config.ClientAuthOptional, config.ClientAuthRequire:
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.ClientAuth == config.ClientAuthRequire {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return tlsConfig, nil
+}
+
+// Start starts the HTTP server
+func (server *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return server.Serve(ln)
+}
+
+// Serve accepts connections on ln until Shutdown is called. It serves HTTPS,
+// with HTTP/2 unless disabled, when a TLS certificate or ACME domains are
+// configured.
+func (server *Server) Serve(ln net.Listener) error {
+	hs, err := server.newHTTPServer()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	if server.httpConfig.MaxConnections > 0 {
+		ln = netutil.LimitListener(ln, server.httpConfig.MaxConnections)
+	}
+
+	server.httpMutex.Lock()
+	server.httpServer = hs
+	server.httpMutex.Unlock()
+
+	server.logger.Info("starting HTTP server",
+		"addr", ln.Addr().String(),
+		"tls", server.tlsEnabled(),
+		"acme", len(server.httpConfig.ACMEDomains) > 0,
+		"client_auth", server.httpConfig.ClientAuth,
+		"http2", server.tlsEnabled() && server.httpConfig.EnableHTTP2,
+		"keep_alive", server.httpConfig.KeepAlive,
+		"max_connections", server.httpConfig.MaxConnections,
+	)
+	if server.tlsEnabled() {
+		err = hs.ServeTLS(ln, server.httpConfig.TLSCertFile, server.httpConfig.TLSKeyFile)
+	} else {
+		err = hs.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
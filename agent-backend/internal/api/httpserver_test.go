@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_certIdentity(t *testing.T) {
+	const peerID = "12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK"
+	server := &Server{httpConfig: config.HTTPConfig{ClientIdentities: map[string]string{"dashboard": "12D3KooWDashboard"}}}
+	identify := func(cert *x509.Certificate) (string, bool) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return server.certIdentity(r)
+	}
+	san := func(uri string) []*url.URL {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		return []*url.URL{u}
+	}
+
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		want   string
+		wantOK bool
+	}{
+		{"mapped common name", &x509.Certificate{Subject: pkix.Name{CommonName: "dashboard"}}, "12D3KooWDashboard", true},
+		{"peer ID URI SAN", &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}, URIs: san("libp2p:" + peerID)}, peerID, true},
+		{"unmapped common name", &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}, "", false},
+		{"peer ID as common name", &x509.Certificate{Subject: pkix.Name{CommonName: peerID}}, "", false},
+		{"invalid peer ID SAN", &x509.Certificate{URIs: san("libp2p:alice")}, "", false},
+		{"other URI SAN", &x509.Certificate{URIs: san("spiffe://pandacea/" + peerID)}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, ok := identify(tt.cert)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, identity)
+		})
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pandacea-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// serveTestServer serves server on a random port and returns its address
+func serveTestServer(t *testing.T, server *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ln) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, server.Shutdown(ctx))
+		assert.NoError(t, <-done)
+	})
+
+	// Serve publishes the http.Server before accepting connections
+	require.Eventually(t, func() bool {
+		server.httpMutex.Lock()
+		defer server.httpMutex.Unlock()
+		return server.httpServer != nil
+	}, time.Second, time.Millisecond)
+	return ln.Addr().String()
+}
+
+func newHTTPTestServer(t *testing.T, cfg config.HTTPConfig) *Server {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetHTTPConfig(cfg)
+	return server
+}
+
+func TestServer_ServeHTTP2OverTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	for _, enableHTTP2 := range []bool{true, false} {
+		server := newHTTPTestServer(t, config.HTTPConfig{
+			TLSCertFile:          certFile,
+			TLSKeyFile:           keyFile,
+			EnableHTTP2:          enableHTTP2,
+			MaxConcurrentStreams: 10,
+			KeepAlive:            true,
+			IdleTimeoutSeconds:   30,
+		})
+		addr := serveTestServer(t, server)
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + addr + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		if enableHTTP2 {
+			assert.Equal(t, 2, resp.ProtoMajor)
+		} else {
+			assert.Equal(t, 1, resp.ProtoMajor)
+		}
+		client.CloseIdleConnections()
+	}
+}
+
+func TestServer_ConnectionMetrics(t *testing.T) {
+	server := newHTTPTestServer(t, config.HTTPConfig{KeepAlive: true, MaxConnections: 4})
+	addr := serveTestServer(t, server)
+
+	before := testutil.ToFloat64(httpConnectionsTotal)
+	client := &http.Client{Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://" + addr + "/healthz")
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Keep-alive reuses a single connection for sequential requests
+	assert.Equal(t, before+1, testutil.ToFloat64(httpConnectionsTotal))
+
+	client.CloseIdleConnections()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(httpConnections.WithLabelValues(http.StateIdle.String())) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServer_ClientCertificates(t *testing.T) {
+	// The self-signed certificate serves as the server's certificate, the
+	// client's certificate and the CA the client certificate must chain to
+	certFile, keyFile := writeTestCertificate(t)
+	server := newHTTPTestServer(t, config.HTTPConfig{
+		TLSCertFile:      certFile,
+		TLSKeyFile:       keyFile,
+		ClientAuth:       config.ClientAuthRequire,
+		ClientCAFile:     certFile,
+		ClientIdentities: map[string]string{"pandacea-test": "12D3KooWDashboard"},
+	})
+	var identity string
+	server.router.Get("/whoami", server.clientCertMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = r.Header.Get(reqsig.HeaderPeerID)
+	})).ServeHTTP)
+	addr := serveTestServer(t, server)
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	_, err := anonymous.Get("https://" + addr + "/healthz")
+	assert.Error(t, err, "handshake without a client certificate succeeded")
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{cert},
+	}}}
+	resp, err := client.Get("https://" + addr + "/whoami")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "12D3KooWDashboard", identity)
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/whoami", nil)
+	require.NoError(t, err)
+	req.Header.Set(reqsig.HeaderPeerID, "12D3KooWSomeoneElse")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	client.CloseIdleConnections()
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"pandacea/agent-backend/internal/policy"
+)
+
+// LeaseStatusExpired marks a lease whose duration has elapsed
+const LeaseStatusExpired = "expired"
+
+// setLeaseTerm records a lease's duration. Until the lease is approved it
+// expires one term after the proposal was created.
+func (server *Server) setLeaseTerm(leaseProposalID, duration string) {
+	term, err := policy.ParseDuration(duration)
+	if err != nil {
+		server.logger.Warn("lease duration not recorded", "lease_proposal_id", leaseProposalID, "error", err)
+		return
+	}
+
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	state, exists := server.pendingLeases[leaseProposalID]
+	if !exists {
+		return
+	}
+	state.Duration = duration
+	state.term = term
+	expiresAt := state.CreatedAt.Add(term)
+	state.ExpiresAt = &expiresAt
+}
+
+// setLeaseProduct records which product a lease is for, so the lease's
+// holder can be notified if the product is quarantined
+func (server *Server) setLeaseProduct(leaseProposalID, productID string) {
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	if state, exists := server.pendingLeases[leaseProposalID]; exists {
+		state.ProductID = productID
+	}
+}
+
+// RunLeaseExpirer marks leases expired once their duration has elapsed,
+// checking every interval until ctx is cancelled
+func (server *Server) RunLeaseExpirer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			server.expireLeases(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expireLeases transitions leases whose expiry is at or before now and
+// returns how many expired
+func (server *Server) expireLeases(now time.Time) int {
+	server.leasesMutex.Lock()
+	var expired []string
+	for id, state := range server.pendingLeases {
+		if state.ExpiresAt == nil || state.ExpiresAt.After(now) {
+			continue
+		}
+		// Disputed leases stay disputed for review
+		if state.Status == LeaseStatusExpired || state.Status == "disputed" {
+			continue
+		}
+		state.Status = LeaseStatusExpired
+		state.UpdatedAt = now
+		server.publishLeaseStatus(id, state)
+		expired = append(expired, id)
+	}
+	server.leasesMutex.Unlock()
+
+	for _, id := range expired {
+		server.logger.Info("lease expired", "lease_proposal_id", id)
+		server.recordAudit(AuditLeaseExpired, "", map[string]any{
+			"lease_proposal_id": id,
+		})
+	}
+	return len(expired)
+}
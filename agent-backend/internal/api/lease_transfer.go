@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// LeaseTransferRequest represents a signed assignment of a lease to a new holder
+type LeaseTransferRequest struct {
+	From      string `json:"from"`      // Current holder's address
+	To        string `json:"to"`        // New holder's address
+	ToPeerID  string `json:"toPeerId"`  // Peer that holds the lease's deliveries, results and events from now on
+	Nonce     int    `json:"nonce"`     // Number of earlier assignments of the lease
+	Signature string `json:"signature"` // From's personal_sign signature of the assignment message
+}
+
+// SetLeaseAssignments enables lease transfers. Assignments are recorded in
+// registry, and the privacy service accepts a lease's assigned holder in
+// place of its on-chain spender.
+func (server *Server) SetLeaseAssignments(registry *privacy.AssignmentRegistry) {
+	server.assignments = registry
+	if assigner, ok := server.privacyService.(privacy.LeaseAssigner); ok {
+		assigner.UseAssignments(registry)
+	}
+}
+
+// chainLeaseProposalID returns the local state key of an on-chain lease,
+// matching how LeaseCreated events are recorded
+func chainLeaseProposalID(leaseID string) string {
+	return "lease_prop_" + strings.TrimPrefix(strings.ToLower(leaseID), "0x")
+}
+
+// handleTransferLease handles POST /api/v1/leases/{leaseId}/transfer. The
+// lease must be active and held by From, the assignment must be signed by
+// From, and the earner's policy must accept the new holder. Anyone may
+// submit the signed assignment, so the new holder can present it.
+func (server *Server) handleTransferLease(w http.ResponseWriter, r *http.Request) {
+	if server.assignments == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Lease transfers are not enabled")
+		return
+	}
+	leaseID := chi.URLParam(r, "leaseId")
+
+	var req LeaseTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if !common.IsHexAddress(req.From) || !common.IsHexAddress(req.To) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "from and to must be Ethereum addresses")
+		return
+	}
+	if strings.EqualFold(req.From, req.To) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "A lease cannot be transferred to its current holder")
+		return
+	}
+	// Deliveries, results and events follow the lease's peer, so the new
+	// holder must name one or the old holder would keep them
+	if _, err := peer.Decode(req.ToPeerID); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "toPeerId must be the new holder's peer ID")
+		return
+	}
+
+	// The lease must still be usable, and From must hold it on-chain or by
+	// its latest assignment
+	ctx, err := server.leaseContext(r, "")
+	if err == nil {
+		err = server.privacyService.VerifyLease(ctx, leaseID, req.From)
+	}
+	if err != nil {
+		server.logger.Warn("lease transfer refused", "error", err, "lease_id", leaseID, "from", req.From)
+		server.sendError(w, r, err, "Lease verification failed")
+		return
+	}
+
+	assignment := privacy.Assignment{
+		LeaseID:    leaseID,
+		From:       common.HexToAddress(req.From).Hex(),
+		To:         common.HexToAddress(req.To).Hex(),
+		ToPeerID:   req.ToPeerID,
+		Nonce:      req.Nonce,
+		Signature:  req.Signature,
+		AssignedAt: time.Now().UTC(),
+	}
+	signer, err := security.RecoverPersonalSignAddress(assignment.Message(), req.Signature)
+	if err != nil || signer != common.HexToAddress(req.From) {
+		server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Assignment is not signed by the current holder")
+		return
+	}
+
+	proposalID := chainLeaseProposalID(leaseID)
+	server.leasesMutex.RLock()
+	var productID, price, duration string
+	if state, exists := server.pendingLeases[proposalID]; exists {
+		productID, duration = state.ProductID, state.Duration
+		if state.Price != nil {
+			price = *state.Price
+		}
+	}
+	server.leasesMutex.RUnlock()
+
+	if productID != "" && server.rejectQuarantined(w, r, productID, map[string]any{"lease_id": leaseID}) {
+		return
+	}
+	if server.rejectRevoked(w, r, assignment.From, assignment.To, assignment.ToPeerID) {
+		return
+	}
+
+	policyReq := &policy.Request{
+		ProductID: productID,
+		MaxPrice:  price,
+		Duration:  duration,
+		Requester: assignment.To,
+		Transfer:  true,
+	}
+	if server.reputation != nil {
+		score := server.reputation.ScoreOrPrior(assignment.To)
+		policyReq.Reputation = &score
+	}
+	if evaluation := server.evaluatePolicy(r.Context(), policyReq); !evaluation.Allowed {
+		server.logger.Warn("lease transfer rejected by policy", "lease_id", leaseID, "to", assignment.To, "reason", evaluation.Reason)
+		server.recordAudit(AuditLeaseRejected, assignment.From, map[string]any{
+			"lease_id": leaseID,
+			"to":       assignment.To,
+			"transfer": true,
+			"reason":   evaluation.Reason,
+		})
+		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodePolicyRejection, evaluation.Reason)
+		return
+	}
+
+	if err := server.assignments.Record(assignment); err != nil {
+		server.logger.Warn("failed to record lease assignment", "error", err, "lease_id", leaseID)
+		server.sendError(w, r, err, "Failed to record lease assignment")
+		return
+	}
+
+	server.transferLeaseState(proposalID, assignment)
+	server.recordAudit(AuditLeaseTransferred, assignment.From, map[string]any{
+		"lease_id":   leaseID,
+		"from":       assignment.From,
+		"to":         assignment.To,
+		"to_peer_id": assignment.ToPeerID,
+		"nonce":      assignment.Nonce,
+		"signature":  assignment.Signature,
+	})
+	server.logger.Info("lease transferred", "lease_id", leaseID, "from", assignment.From, "to", assignment.To)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(assignment); err != nil {
+		server.logger.Error("failed to encode lease assignment", "error", err)
+	}
+}
+
+// transferLeaseState points the lease's local state at its new holder and
+// notifies both holders
+func (server *Server) transferLeaseState(leaseProposalID string, assignment privacy.Assignment) {
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	state, exists := server.pendingLeases[leaseProposalID]
+	if !exists {
+		return
+	}
+
+	fields := map[string]any{
+		"lease_proposal_id": leaseProposalID,
+		"lease_id":          assignment.LeaseID,
+		"from":              assignment.From,
+		"to":                assignment.To,
+	}
+	server.publishStatus(EventLeaseTransferred, state.owner, fields)
+
+	state.SpenderAddr = assignment.To
+	state.UpdatedAt = time.Now()
+	// The old holder's encryption key must not read the new holder's
+	// results, which are sealed to the new holder's peer key instead
+	state.EncryptionKey = ""
+	// The old holder loses the lease even if no new peer was named
+	previous := state.owner
+	state.owner = assignment.ToPeerID
+	if state.owner != "" && state.owner != previous {
+		server.publishStatus(EventLeaseTransferred, state.owner, fields)
+	}
+}
+
+// handleGetLeaseAssignments handles GET /api/v1/leases/{leaseId}/assignments
+func (server *Server) handleGetLeaseAssignments(w http.ResponseWriter, r *http.Request) {
+	if server.assignments == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Lease transfers are not enabled")
+		return
+	}
+
+	response := LeaseAssignmentsResponse{Data: server.assignments.History(chi.URLParam(r, "leaseId"))}
+	if response.Data == nil {
+		response.Data = []privacy.Assignment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode lease assignments", "error", err)
+	}
+}
+
+// LeaseAssignmentsResponse lists a lease's assignments, oldest first
+type LeaseAssignmentsResponse struct {
+	Data []privacy.Assignment `json:"data"`
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+// legacyRoutes maps each deprecated route prefix to its /api/v1 replacement
+var legacyRoutes = map[string]string{
+	"/train":     "/api/v1/train",
+	"/aggregate": "/api/v1/aggregate/{jobId}",
+}
+
+// SetLegacyRoutes sets how the deprecated /train and /aggregate/{jobId}
+// routes are served: config.LegacyRoutesAllow, LegacyRoutesWarn or
+// LegacyRoutesBlock. They are served with a warning until this is called.
+func (server *Server) SetLegacyRoutes(mode string) {
+	server.legacyRoutes = mode
+}
+
+// legacyRoutesMiddleware refuses legacy routes when they are blocked and
+// marks them deprecated when they warn. It runs ahead of authentication, so
+// blocked routes are refused without checking the caller.
+func (server *Server) legacyRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replacement := legacyRoutes["/"+strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]]
+
+		switch server.legacyRoutes {
+		case config.LegacyRoutesAllow:
+		case config.LegacyRoutesBlock:
+			server.logger.Warn("blocked legacy endpoint", "path", r.URL.Path, "replacement", replacement)
+			server.sendErrorResponse(w, r, http.StatusGone, ErrorCodeEndpointRetired,
+				"This endpoint is retired. Use "+replacement+" instead.")
+			return
+		default:
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("X-API-Deprecation-Warning", "This endpoint is deprecated. Use "+replacement+" instead.")
+			server.logger.Warn("legacy endpoint used", "path", r.URL.Path, "recommendation", "Use "+replacement+" instead")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_legacyRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+rate_limits:
+  per_ip_rps: 100
+  per_identity_rps: 100
+  burst: 100
+backpressure:
+  mem_high_watermark_mb: 100000
+queue:
+  max_size: 10
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+	server := NewServer(&policy.Engine{}, logger, &p2p.Node{}, nil, securityService)
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	get := func(signed bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/aggregate/job_missing", nil)
+		if signed {
+			require.NoError(t, reqsig.Sign(priv, req, nil, time.Now()))
+		}
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Legacy routes are authenticated like /api/v1
+	w := get(false)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+
+	w = get(true)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("X-API-Deprecation-Warning"), "/api/v1/aggregate/{jobId}")
+
+	server.SetLegacyRoutes(config.LegacyRoutesAllow)
+	w = get(true)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	server.SetLegacyRoutes(config.LegacyRoutesBlock)
+	w = get(true)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeEndpointRetired)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LineageResponse is the part of the lineage graph reachable from a node,
+// with the leases in it listed for convenience
+type LineageResponse struct {
+	lineage.Subgraph
+	Leases []string `json:"leases"` // Leases in the subgraph, such as those a model's data came from
+}
+
+// lineageKinds maps the kinds in lineage query paths to node kinds
+var lineageKinds = map[string]string{
+	"products":     lineage.KindProduct,
+	"assets":       lineage.KindAsset,
+	"leases":       lineage.KindLease,
+	"computations": lineage.KindComputation,
+	"jobs":         lineage.KindJob,
+	"models":       lineage.KindModel,
+}
+
+// SetLineage records which products, assets and leases every computation
+// and training job consumed, and which job produced each model, in graph
+func (server *Server) SetLineage(graph *lineage.Graph) {
+	server.lineage = graph
+}
+
+// leaseNode is the lineage node of a lease. Hex lease IDs are compared
+// without regard to case.
+func leaseNode(leaseID string) lineage.Node {
+	return lineage.Node{Kind: lineage.KindLease, ID: strings.ToLower(leaseID)}
+}
+
+// leaseProductID returns the product a lease was proposed for, if the
+// agent has seen it
+func (server *Server) leaseProductID(leaseID string) string {
+	server.leasesMutex.RLock()
+	defer server.leasesMutex.RUnlock()
+	if state, exists := server.pendingLeases[chainLeaseProposalID(leaseID)]; exists {
+		return state.ProductID
+	}
+	return ""
+}
+
+// recordLineage adds edges to the lineage graph, if there is one
+func (server *Server) recordLineage(edges ...lineage.Edge) {
+	if server.lineage == nil {
+		return
+	}
+	if err := server.lineage.Record(edges...); err != nil {
+		server.logger.Error("failed to save lineage graph", "error", err)
+	}
+}
+
+// leaseEdges are the edges from a lease's product to the lease and from
+// the lease to a job or computation that ran under it
+func (server *Server) leaseEdges(leaseID string, consumer lineage.Node) []lineage.Edge {
+	if leaseID == "" {
+		return nil
+	}
+	lease := leaseNode(leaseID)
+	edges := []lineage.Edge{{From: lease, To: consumer, Relation: lineage.RelationAuthorized}}
+	if productID := server.leaseProductID(leaseID); productID != "" {
+		edges = append(edges, lineage.Edge{From: lineage.Node{Kind: lineage.KindProduct, ID: productID}, To: lease, Relation: lineage.RelationLeased})
+	}
+	return edges
+}
+
+// recordComputationLineage records the lease a computation ran under and
+// the assets it read, with the products they belong to
+func (server *Server) recordComputationLineage(computationID string, req *privacy.ComputationRequest) {
+	if server.lineage == nil {
+		return
+	}
+	computation := lineage.Node{Kind: lineage.KindComputation, ID: computationID}
+	edges := server.leaseEdges(req.LeaseID, computation)
+	for _, input := range req.Inputs {
+		asset := lineage.Node{Kind: lineage.KindAsset, ID: input.AssetID}
+		edges = append(edges, lineage.Edge{From: asset, To: computation, Relation: lineage.RelationInput})
+		if productID := server.assetProduct(input.AssetID); productID != input.AssetID {
+			edges = append(edges, lineage.Edge{From: lineage.Node{Kind: lineage.KindProduct, ID: productID}, To: asset, Relation: lineage.RelationContains})
+		}
+	}
+	server.recordLineage(edges...)
+}
+
+// recordTrainingLineage records the dataset a training job reads and the
+// lease it runs under
+func (server *Server) recordTrainingLineage(jobID, dataset, leaseID string) {
+	if server.lineage == nil {
+		return
+	}
+	job := lineage.Node{Kind: lineage.KindJob, ID: jobID}
+	edges := server.leaseEdges(leaseID, job)
+	edges = append(edges, lineage.Edge{From: lineage.Node{Kind: lineage.KindProduct, ID: dataset}, To: job, Relation: lineage.RelationInput})
+	server.recordLineage(edges...)
+}
+
+// handleGetLineage handles GET /api/v1/lineage/{kind}/{id}. It walks the
+// graph upstream from the node by default, or downstream with
+// ?direction=downstream.
+func (server *Server) handleGetLineage(w http.ResponseWriter, r *http.Request) {
+	if server.lineage == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Lineage tracking is not enabled")
+		return
+	}
+	kind, ok := lineageKinds[chi.URLParam(r, "kind")]
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Unknown lineage node kind")
+		return
+	}
+	node := lineage.Node{Kind: kind, ID: chi.URLParam(r, "*")}
+	if kind == lineage.KindLease {
+		node = leaseNode(node.ID)
+	}
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = lineage.Upstream
+	}
+
+	sub, err := server.lineage.Walk(node, direction)
+	if err != nil {
+		server.sendError(w, r, err, "Invalid lineage query")
+		return
+	}
+	resp := LineageResponse{Subgraph: sub, Leases: sub.Of(lineage.KindLease)}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode lineage", "error", err)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_lineage(t *testing.T) {
+	productsDir := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, nil, nil)
+	server.SetProductsDir(productsDir)
+	registry, err := models.NewRegistry("")
+	require.NoError(t, err)
+	server.SetModels(registry)
+	graph, err := lineage.NewGraph("")
+	require.NoError(t, err)
+	server.SetLineage(graph)
+	server.pendingLeases[chainLeaseProposalID("0xAB")] = &LeaseProposalState{Status: "approved", ProductID: "weather"}
+
+	// A training job under a lease on the product, and a computation
+	// under the same lease
+	now := time.Now()
+	job := &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), Dataset: "weather", Task: "forecast",
+		CreatedAt: now, UpdatedAt: now, leaseID: "0xAB"}
+	server.jobs[job.JobID] = job
+	server.recordTrainingLineage(job.JobID, job.Dataset, job.leaseID)
+	dir := filepath.Join(productsDir, job.JobID)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aggregate.json"), []byte(`{"n": 10}`), 0644))
+	server.finishTrainingJob(job.JobID, job, filepath.Join(dir, "aggregate.json"))
+	server.recordComputationLineage("comp-1", &privacy.ComputationRequest{LeaseID: "0xab", Inputs: []privacy.DataInput{{AssetID: "weather"}}})
+
+	get := func(kind, id, query string) (int, LineageResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/lineage/"+kind+"/"+id+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("kind", kind)
+		rctx.URLParams.Add("*", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleGetLineage(w, req)
+		var resp LineageResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		}
+		return w.Code, resp
+	}
+
+	code, resp := get("models", "job-1", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"0xab"}, resp.Leases, "the model's lease")
+	assert.Contains(t, resp.Nodes, lineage.Node{Kind: lineage.KindProduct, ID: "weather"})
+
+	code, resp = get("leases", "0xAB", "?direction=downstream")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, resp.Nodes, lineage.Node{Kind: lineage.KindModel, ID: "job-1"})
+	assert.Contains(t, resp.Nodes, lineage.Node{Kind: lineage.KindComputation, ID: "comp-1"})
+
+	code, _ = get("datasets", "weather", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("models", "job-1", "?direction=sideways")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"pandacea/agent-backend/internal/market"
+)
+
+// SetMarket enables network product search through searcher
+func (server *Server) SetMarket(searcher *market.Searcher) {
+	server.market = searcher
+}
+
+// SetListingContact tells spender agents searching the network to propose
+// leases on this agent's products at apiURL and pay earner, or the earner
+// of the tenant owning the product
+func (server *Server) SetListingContact(apiURL, earner string) {
+	server.listingURL = strings.TrimSuffix(apiURL, "/")
+	server.listingEarner = earner
+}
+
+// Listings returns this agent's products that match q with their current
+// price, leaving out quarantined products. It makes the server the
+// market.Catalog other agents query.
+func (server *Server) Listings(q market.Query) []market.Listing {
+	products := server.products.Find("", q.DataType)
+
+	listings := []market.Listing{}
+	for _, product := range products {
+		if _, quarantined := server.quarantine(product.ProductID); quarantined {
+			continue
+		}
+		listing := market.Listing{
+			ProductID: product.ProductID,
+			Name:      product.Name,
+			DataType:  product.DataType,
+			Keywords:  product.Keywords,
+			APIURL:    server.listingURL,
+			Earner:    server.listingEarner,
+		}
+		if owner, ok := server.productTenant(product.ProductID); ok {
+			listing.Earner = owner.Earner
+		}
+		if !q.Matches(listing) {
+			continue
+		}
+		if server.pricer != nil {
+			listing.Price = server.pricer.Quote(product.ProductID).EffectivePrice
+		}
+		listings = append(listings, listing)
+	}
+	return listings
+}
+
+// handleSearchNetworkProducts handles GET /api/v1/network/products
+func (server *Server) handleSearchNetworkProducts(w http.ResponseWriter, r *http.Request) {
+	if server.market == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Network product search is not enabled")
+		return
+	}
+
+	query := market.Query{
+		Text:     r.URL.Query().Get("q"),
+		DataType: r.URL.Query().Get("dataType"),
+	}
+	result, err := server.market.Search(r.Context(), query, r.URL.Query().Get("sort"))
+	if err != nil {
+		server.sendError(w, r, err, "Failed to search network products")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		server.logger.Error("failed to encode network products", "error", err)
+	}
+}
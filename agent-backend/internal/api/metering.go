@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// SetMetering bills training jobs and computations at rates and books each
+// bill against the job's lease in ledger, for GET
+// /api/v1/leases/{leaseId}/metering
+func (server *Server) SetMetering(rates metering.Rates, ledger *metering.Ledger) {
+	server.rates = &rates
+	server.meteringLedger = ledger
+	if biller, ok := server.privacyService.(privacy.ComputationBiller); ok {
+		biller.BillComputations(rates)
+	} else {
+		server.logger.Warn("privacy service cannot bill computations; only training jobs are billed")
+	}
+	if meter, ok := server.privacyService.(privacy.ComputationMeter); ok {
+		meter.MeterComputations(server.meterComputation)
+	} else {
+		server.logger.Warn("privacy service cannot meter computations; their bills are not booked against leases")
+	}
+}
+
+// recordWorkerUsage keeps the CPU time and peak memory of a finished
+// training worker for the job's bill
+func (server *Server) recordWorkerUsage(jobID string, state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+	var peak int64
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		peak = int64(rusage.Maxrss) << 10 // Maxrss is in KiB
+	}
+
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	if job, exists := server.jobs[jobID]; exists {
+		job.cpuTime = state.UserTime() + state.SystemTime()
+		job.peakMemory = peak
+	}
+}
+
+// billTrainingJob bills a finished job if jobs are billed, booking the bill
+// against its lease. Its worker's peak memory is billed as held for the
+// whole run. Caller must hold jobsMutex.
+func (server *Server) billTrainingJob(job *TrainingJob, finished time.Time) {
+	if server.rates == nil {
+		return
+	}
+	var wall time.Duration
+	if !job.startedAt.IsZero() {
+		wall = finished.Sub(job.startedAt)
+	}
+	bill := server.rates.Price(metering.Measure(job.cpuTime, job.peakMemory, wall))
+	job.Metering = &bill
+	server.recordCharge(job.leaseID, job.JobID, metering.KindTraining, bill)
+}
+
+// recordCharge books a job's bill against its lease, if it ran under one
+func (server *Server) recordCharge(leaseID, jobID, kind string, bill metering.Bill) {
+	if server.meteringLedger == nil || leaseID == "" {
+		return
+	}
+	if err := server.meteringLedger.Record(leaseID, jobID, kind, bill); err != nil {
+		server.logger.Error("failed to record job bill", "error", err, "lease_id", leaseID, "job_id", jobID)
+	}
+}
+
+// handleGetLeaseMetering handles GET /api/v1/leases/{leaseId}/metering.
+// What jobs cost to run is the operator's business, so only admin peers
+// may read it.
+func (server *Server) handleGetLeaseMetering(w http.ResponseWriter, r *http.Request) {
+	if server.meteringLedger == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Metering is not enabled")
+		return
+	}
+
+	leaseID := chi.URLParam(r, "leaseId")
+	var price *decimal.Decimal
+	if p, known := server.leasePrice(leaseID); known {
+		price = &p
+	}
+	rec := server.meteringLedger.Reconcile(leaseID, price)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		server.logger.Error("failed to encode lease metering", "error", err)
+	}
+}
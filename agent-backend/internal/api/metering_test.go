@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_leaseMetering(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("admin:\n  peer_ids:\n    - 12D3KooWAdmin\n"), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	privacyService := &meteringPrivacyService{}
+	server := NewServer(&policy.Engine{}, logger, &p2p.Node{}, privacyService, securityService)
+	ledger, err := metering.NewLedger("")
+	require.NoError(t, err)
+	server.SetMetering(metering.Rates{CPUSecond: decimal.NewFromInt(100), WallSecond: decimal.NewFromInt(10)}, ledger)
+	require.NotNil(t, privacyService.meter)
+
+	price := "1000"
+	server.UpdateLeaseStatus("lease_prop_ab", "approved", nil, "", "", &price)
+
+	// A training job is billed for its worker's CPU time and its running time
+	started := time.Now()
+	job := &TrainingJob{JobID: "job-1", leaseID: "0xAB", startedAt: started, cpuTime: 4 * time.Second}
+	server.billTrainingJob(job, started.Add(5*time.Second))
+	require.NotNil(t, job.Metering)
+	assert.Equal(t, "450", job.Metering.Cost)
+
+	// A computation's bill arrives with its metering
+	bill := metering.Bill{Resources: metering.Resources{WallSeconds: 60}, Cost: "600"}
+	privacyService.meter(privacy.Metering{ComputationID: "comp-1", LeaseID: "0xab", Bill: &bill})
+
+	// The route sits behind signature verification, so call its handler
+	// with the admin check directly
+	var handler http.HandlerFunc
+	for _, rt := range server.routes() {
+		if rt.operationID == "getLeaseMetering" {
+			handler = rt.handler
+		}
+	}
+	require.NotNil(t, handler)
+	router := chi.NewRouter()
+	router.Get("/api/v1/leases/{leaseId}/metering", handler)
+	serve := func(leaseID, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/leases/"+leaseID+"/metering", nil)
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve("0xab", "12D3KooWOther").Code)
+
+	w := serve("0xab", "12D3KooWAdmin")
+	require.Equal(t, http.StatusOK, w.Code)
+	var rec metering.Reconciliation
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rec))
+	assert.Equal(t, "1000", rec.Price)
+	assert.Equal(t, "1050", rec.Metered)
+	assert.Equal(t, "-50", rec.Margin, "the jobs cost more than the lease paid")
+	assert.Len(t, rec.Charges, 2)
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ModelsResponse lists registered models, newest first
+type ModelsResponse struct {
+	Data []models.Model `json:"data"`
+}
+
+// PromoteModelRequest moves a model to a stage
+type PromoteModelRequest struct {
+	Stage string `json:"stage"` // staging, production, archived, or empty to clear the stage
+}
+
+// TagModelRequest adds and removes a model's tags
+type TagModelRequest struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// modelArtifact is the subset of a training artifact the registry records
+type modelArtifact struct {
+	N        int                `json:"n"`
+	Accuracy *float64           `json:"accuracy"`
+	Loss     *float64           `json:"loss"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+// SetModels registers the model of every training job that completes on
+// this agent in registry
+func (server *Server) SetModels(registry *models.Registry) {
+	server.models = registry
+}
+
+// modelEntry describes the model of a finished job from its artifact, for
+// registerModel once the job completes. It returns nil for federation
+// rounds, whose updates are not models of their own, and without a
+// registry. Caller must not hold jobsMutex.
+func (server *Server) modelEntry(job *TrainingJob, aggregatePath string) *models.Model {
+	if server.models == nil || job.FederationID != "" {
+		return nil
+	}
+	content, err := os.ReadFile(aggregatePath)
+	if err != nil {
+		server.logger.Error("failed to read training artifact to register", "error", err, "job_id", job.JobID)
+		return nil
+	}
+	var artifact modelArtifact
+	if err := json.Unmarshal(content, &artifact); err != nil {
+		server.logger.Warn("training artifact has no readable metrics", "error", err, "job_id", job.JobID)
+	}
+	metrics := artifact.Metrics
+	for name, value := range map[string]*float64{"accuracy": artifact.Accuracy, "loss": artifact.Loss} {
+		if value == nil {
+			continue
+		}
+		if metrics == nil {
+			metrics = make(map[string]float64)
+		}
+		metrics[name] = *value
+	}
+
+	server.jobsMutex.RLock()
+	defer server.jobsMutex.RUnlock()
+	model := &models.Model{
+		ID:       job.JobID,
+		Name:     models.Name(job.Dataset, job.Task),
+		Task:     job.Task,
+		Metrics:  metrics,
+		Epsilon:  job.Epsilon,
+		CID:      job.CID,
+		Artifact: aggregatePath,
+		Lineage: models.Lineage{
+			Dataset: job.Dataset,
+			LeaseID: job.leaseID,
+			Samples: artifact.N,
+			Resumes: job.Resumes,
+		},
+	}
+	if job.DPReport != nil {
+		model.Epsilon = job.DPReport.Epsilon
+	}
+	if job.Integrity != nil {
+		model.SHA256 = job.Integrity.SHA256
+	} else {
+		sum := sha256.Sum256(content)
+		model.SHA256 = hex.EncodeToString(sum[:])
+	}
+	if job.Federation != nil {
+		model.Lineage.Participants = append([]string(nil), job.Federation.Participants...)
+		model.Lineage.Rounds = job.Federation.CompletedRounds
+	}
+	return model
+}
+
+// registerModel adds a completed job's model to the registry
+func (server *Server) registerModel(model *models.Model) {
+	if model == nil {
+		return
+	}
+	registered, err := server.models.Register(*model)
+	if err != nil {
+		server.logger.Error("failed to save model registry", "error", err, "job_id", model.ID)
+	}
+	server.recordLineage(lineage.Edge{
+		From:     lineage.Node{Kind: lineage.KindJob, ID: model.ID},
+		To:       lineage.Node{Kind: lineage.KindModel, ID: model.ID},
+		Relation: lineage.RelationProduced,
+	})
+	server.logger.Info("model registered", "job_id", registered.ID, "name", registered.Name, "version", registered.Version)
+}
+
+// handleListModels handles GET /api/v1/models
+func (server *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	if server.models == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Model registry is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	resp := ModelsResponse{Data: server.models.List(models.Query{
+		Name:    params.Get("name"),
+		Dataset: params.Get("dataset"),
+		Stage:   params.Get("stage"),
+		Tag:     params.Get("tag"),
+	})}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode models", "error", err)
+	}
+}
+
+// handleGetModel handles GET /api/v1/models/{modelId}
+func (server *Server) handleGetModel(w http.ResponseWriter, r *http.Request) {
+	if server.models == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Model registry is not enabled")
+		return
+	}
+	model, ok := server.models.Get(chi.URLParam(r, "modelId"))
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Model not found")
+		return
+	}
+	server.writeModel(w, model)
+}
+
+// handlePromoteModel handles POST /api/v1/models/{modelId}/promote
+func (server *Server) handlePromoteModel(w http.ResponseWriter, r *http.Request) {
+	if server.models == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Model registry is not enabled")
+		return
+	}
+	var req PromoteModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	modelID := chi.URLParam(r, "modelId")
+	model, err := server.models.Promote(modelID, req.Stage)
+	if err != nil {
+		server.sendError(w, r, err, "Failed to promote model")
+		return
+	}
+	server.recordAudit(AuditModelPromoted, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"model_id": modelID,
+		"name":     model.Name,
+		"version":  model.Version,
+		"stage":    model.Stage,
+	})
+	server.logger.Info("model promoted", "model_id", modelID, "name", model.Name, "version", model.Version, "stage", model.Stage)
+	server.writeModel(w, model)
+}
+
+// handleTagModel handles POST /api/v1/models/{modelId}/tags
+func (server *Server) handleTagModel(w http.ResponseWriter, r *http.Request) {
+	if server.models == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Model registry is not enabled")
+		return
+	}
+	var req TagModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	model, err := server.models.Tag(chi.URLParam(r, "modelId"), req.Add, req.Remove)
+	if err != nil {
+		server.sendError(w, r, err, "Failed to tag model")
+		return
+	}
+	server.writeModel(w, model)
+}
+
+// writeModel sends a model as the response
+func (server *Server) writeModel(w http.ResponseWriter, model models.Model) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model); err != nil {
+		server.logger.Error("failed to encode model", "error", err)
+	}
+}
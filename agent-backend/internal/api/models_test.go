@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_modelRegistry(t *testing.T) {
+	productsDir := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, nil, nil)
+	server.SetProductsDir(productsDir)
+	registry, err := models.NewRegistry("")
+	require.NoError(t, err)
+	server.SetModels(registry)
+
+	complete := func(jobID string, finishedAgo time.Duration, artifact string) {
+		finished := time.Now().Add(-finishedAgo)
+		job := &TrainingJob{JobID: jobID, Status: string(TrainingStatusRunning), Dataset: "mnist", Task: "classify",
+			Epsilon: 1.5, CreatedAt: finished, UpdatedAt: finished, leaseID: "0xlease"}
+		server.jobs[jobID] = job
+		dir := filepath.Join(productsDir, jobID)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		aggregatePath := filepath.Join(dir, "aggregate.json")
+		require.NoError(t, os.WriteFile(aggregatePath, []byte(artifact), 0644))
+		server.finishTrainingJob(jobID, job, aggregatePath)
+		job.UpdatedAt, job.CompletedAt = finished, &finished
+	}
+	complete("job-old", 48*time.Hour, `{"n": 600, "accuracy": 0.91}`)
+	complete("job-new", 47*time.Hour, `{"n": 800, "accuracy": 0.94, "metrics": {"f1": 0.9}}`)
+
+	call := func(handler http.HandlerFunc, method, target, modelID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("modelId", modelID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	w := call(server.handleListModels, http.MethodGet, "/api/v1/models?name=mnist/classify", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ModelsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Data, 2)
+	latest := list.Data[0]
+	assert.Equal(t, "job-new", latest.ID)
+	assert.Equal(t, 2, latest.Version)
+	assert.Equal(t, map[string]float64{"accuracy": 0.94, "f1": 0.9}, latest.Metrics)
+	assert.Equal(t, 1.5, latest.Epsilon)
+	assert.Equal(t, models.Lineage{Dataset: "mnist", LeaseID: "0xlease", Samples: 800}, latest.Lineage)
+	assert.Len(t, latest.SHA256, 64)
+
+	assert.Equal(t, http.StatusNotFound, call(server.handleGetModel, http.MethodGet, "/api/v1/models/job-x", "job-x", "").Code)
+	w = call(server.handlePromoteModel, http.MethodPost, "/api/v1/models/job-new/promote", "job-new", `{"stage":"live"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = call(server.handlePromoteModel, http.MethodPost, "/api/v1/models/job-new/promote", "job-new", `{"stage":"production"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = call(server.handleTagModel, http.MethodPost, "/api/v1/models/job-new/tags", "job-new", `{"add":["baseline"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = call(server.handleGetModel, http.MethodGet, "/api/v1/models/job-new", "job-new", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var model models.Model
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&model))
+	assert.Equal(t, models.StageProduction, model.Stage)
+	assert.Equal(t, []string{"baseline"}, model.Tags)
+
+	// Retention keeps the production model and forgets the other
+	server.SetRetention(config.RetentionConfig{Enabled: true, MaxAgeHours: 24})
+	report := server.applyRetention(time.Now())
+	assert.Equal(t, 1, report.TrainingJobs)
+	_, kept := registry.Get("job-new")
+	_, dropped := registry.Get("job-old")
+	assert.True(t, kept)
+	assert.False(t, dropped)
+	_, err = os.Stat(filepath.Join(productsDir, "job-new", "aggregate.json"))
+	assert.NoError(t, err)
+}
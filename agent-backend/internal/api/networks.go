@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+// networkHeader names the blockchain network a request's lease lives on
+const networkHeader = "X-Pandacea-Network"
+
+// SetBlockchain sets the networks leases can be verified on and which
+// network each data product's leases live on
+func (server *Server) SetBlockchain(cfg config.BlockchainConfig) {
+	server.blockchain = cfg
+}
+
+// leaseContext returns r's context carrying the network to verify the
+// lease for productID on, as chosen by leaseNetwork from the network the
+// request names in X-Pandacea-Network
+func (server *Server) leaseContext(r *http.Request, productID string) (context.Context, error) {
+	network, err := server.leaseNetwork(r.Header.Get(networkHeader), productID)
+	if err != nil {
+		return nil, err
+	}
+	return privacy.WithNetwork(r.Context(), network), nil
+}
+
+// leaseNetwork returns the network to verify the lease for productID on.
+// The operator's pin for the product wins, and a request naming another
+// network is refused. Otherwise it is requested, else none so the default
+// network is used.
+func (server *Server) leaseNetwork(requested, productID string) (string, error) {
+	pinned := server.blockchain.ProductNetwork(productID)
+	if pinned == "" {
+		return requested, nil
+	}
+	if requested != "" && requested != pinned {
+		return "", fmt.Errorf("%w: product %s is leased on %s, not %s", errNetworkConflict, productID, pinned, requested)
+	}
+	return pinned, nil
+}
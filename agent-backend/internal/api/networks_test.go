@@ -0,0 +1,34 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_leaseNetwork(t *testing.T) {
+	server := NewServer(denyEvaluator{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+	server.SetBlockchain(config.BlockchainConfig{ProductNetworks: map[string]string{"pinned": "polygon"}})
+
+	tests := []struct {
+		requested, productID, want string
+		conflict                   bool
+	}{
+		{"", "pinned", "polygon", false},
+		{"polygon", "pinned", "polygon", false},
+		{"sepolia", "pinned", "", true},
+		{"sepolia", "unpinned", "sepolia", false},
+		{"", "unpinned", "", false},
+	}
+	for _, tt := range tests {
+		got, err := server.leaseNetwork(tt.requested, tt.productID)
+		assert.Equal(t, tt.conflict, errors.Is(err, errNetworkConflict), "leaseNetwork(%q, %q) error = %v", tt.requested, tt.productID, err)
+		assert.Equal(t, tt.want, got, "leaseNetwork(%q, %q)", tt.requested, tt.productID)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/spender"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AuditOutboundLeaseProposed records a lease the agent proposed to another agent
+const AuditOutboundLeaseProposed = "outbound_lease.proposed"
+
+// OutboundLeasesResponse lists the leases the agent proposed to other agents
+type OutboundLeasesResponse struct {
+	Data []spender.Proposal `json:"data"`
+}
+
+// SetSpender enables leasing products from other agents through m
+func (server *Server) SetSpender(m *spender.Manager) {
+	server.spender = m
+}
+
+// handleProposeOutboundLease handles POST /api/v1/outbound/leases
+func (server *Server) handleProposeOutboundLease(w http.ResponseWriter, r *http.Request) {
+	if server.spender == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Leasing from other agents is not enabled")
+		return
+	}
+
+	var req spender.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	proposal, err := server.spender.Propose(r.Context(), req)
+	if err != nil {
+		server.logger.Warn("failed to propose outbound lease", "error", err, "product_id", req.ProductID)
+		server.sendError(w, r, err, "Failed to propose lease")
+		return
+	}
+	server.recordAudit(AuditOutboundLeaseProposed, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"outbound_lease_id": proposal.ID,
+		"product_id":        proposal.ProductID,
+		"peer_id":           proposal.PeerID,
+		"status":            proposal.Status,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(proposal); err != nil {
+		server.logger.Error("failed to encode outbound lease", "error", err)
+	}
+}
+
+// handleListOutboundLeases handles GET /api/v1/outbound/leases
+func (server *Server) handleListOutboundLeases(w http.ResponseWriter, r *http.Request) {
+	if server.spender == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Leasing from other agents is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	resp := OutboundLeasesResponse{Data: server.spender.List(spender.Query{
+		Status:    spender.Status(params.Get("status")),
+		ProductID: params.Get("product"),
+	})}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode outbound leases", "error", err)
+	}
+}
+
+// handleGetOutboundLease handles GET /api/v1/outbound/leases/{outboundId}
+func (server *Server) handleGetOutboundLease(w http.ResponseWriter, r *http.Request) {
+	if server.spender == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Leasing from other agents is not enabled")
+		return
+	}
+
+	proposal, ok := server.spender.Get(chi.URLParam(r, "outboundId"))
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Outbound lease not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(proposal); err != nil {
+		server.logger.Error("failed to encode outbound lease", "error", err)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit event types for peer list changes
+const (
+	AuditAdminPeerList    = "admin.peer_list"
+	AuditAdminPeerForget  = "admin.peer_forget"
+	AuditAdminPeerConnect = "admin.peer_connect"
+)
+
+// ConnectedPeersResponse lists the peers the P2P node is connected to
+type ConnectedPeersResponse struct {
+	Data []p2p.ConnectedPeer `json:"data"`
+}
+
+// ConnectPeerRequest asks the P2P node to connect to a peer
+type ConnectPeerRequest struct {
+	Addr string `json:"addr"` // Multiaddr ending in /p2p/<peer ID>
+}
+
+// PeersResponse lists the peers the connection gater knows about
+type PeersResponse struct {
+	Data []p2p.PeerState `json:"data"`
+}
+
+// PeerListRequest puts a peer on the allow or deny list
+type PeerListRequest struct {
+	List   string `json:"list"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleGetP2PStatus handles GET /api/v1/p2p/status
+func (server *Server) handleGetP2PStatus(w http.ResponseWriter, r *http.Request) {
+	if server.p2pNode == nil || server.p2pNode.Host() == nil {
+		server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeInternalError, "P2P node unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(server.p2pNode.Status()); err != nil {
+		server.logger.Error("failed to encode P2P status", "error", err)
+	}
+}
+
+// handleListConnectedPeers handles GET /api/v1/p2p/peers
+func (server *Server) handleListConnectedPeers(w http.ResponseWriter, r *http.Request) {
+	if server.p2pNode == nil || server.p2pNode.Host() == nil {
+		server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeInternalError, "P2P node unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ConnectedPeersResponse{Data: server.p2pNode.ConnectedPeers(r.Context())}); err != nil {
+		server.logger.Error("failed to encode connected peers", "error", err)
+	}
+}
+
+// handleConnectPeer handles POST /api/v1/p2p/peers
+func (server *Server) handleConnectPeer(w http.ResponseWriter, r *http.Request) {
+	if server.p2pNode == nil || server.p2pNode.Host() == nil {
+		server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeInternalError, "P2P node unavailable")
+		return
+	}
+
+	var req ConnectPeerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Addr == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "addr is required")
+		return
+	}
+
+	connected, err := server.p2pNode.Connect(r.Context(), req.Addr)
+	if err != nil {
+		server.logger.Warn("failed to connect to peer", "addr", req.Addr, "error", err)
+		server.sendError(w, r, err, "Failed to connect to peer")
+		return
+	}
+
+	server.recordAudit(AuditAdminPeerConnect, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"peer_id": connected.PeerID,
+		"addr":    req.Addr,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(connected); err != nil {
+		server.logger.Error("failed to encode connected peer", "error", err)
+	}
+}
+
+// handleListPeers handles GET /api/v1/admin/security/peers
+func (server *Server) handleListPeers(w http.ResponseWriter, r *http.Request) {
+	gater := server.peerGater()
+	if gater == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Peer gating is not enabled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(PeersResponse{Data: gater.Peers()}); err != nil {
+		server.logger.Error("failed to encode peers", "error", err)
+	}
+}
+
+// handleSetPeerList handles PUT /api/v1/admin/security/peers/{peerId}
+func (server *Server) handleSetPeerList(w http.ResponseWriter, r *http.Request) {
+	if server.peerGater() == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Peer gating is not enabled")
+		return
+	}
+
+	var req PeerListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.List != p2p.ListAllow && req.List != p2p.ListDeny {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "list must be allow or deny")
+		return
+	}
+
+	peerID := chi.URLParam(r, "peerId")
+	state, err := server.p2pNode.SetPeerList(req.List, peerID, req.Reason)
+	if err != nil {
+		server.logger.Error("failed to update peer list", "peer_id", peerID, "list", req.List, "error", err)
+		server.sendError(w, r, err, "Failed to update peer list")
+		return
+	}
+
+	server.recordAudit(AuditAdminPeerList, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"peer_id": state.PeerID,
+		"list":    req.List,
+		"reason":  req.Reason,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		server.logger.Error("failed to encode peer", "error", err)
+	}
+}
+
+// handleForgetPeer handles DELETE /api/v1/admin/security/peers/{peerId}
+func (server *Server) handleForgetPeer(w http.ResponseWriter, r *http.Request) {
+	gater := server.peerGater()
+	if gater == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Peer gating is not enabled")
+		return
+	}
+
+	peerID := chi.URLParam(r, "peerId")
+	found, err := gater.Forget(peerID)
+	if err != nil {
+		server.logger.Error("failed to forget peer", "peer_id", peerID, "error", err)
+		server.sendError(w, r, err, "Failed to forget peer")
+		return
+	}
+	if !found {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Peer not found")
+		return
+	}
+
+	server.recordAudit(AuditAdminPeerForget, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"peer_id": peerID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// peerGater returns the P2P node's connection gater, or nil without one
+func (server *Server) peerGater() *p2p.Gater {
+	if server.p2pNode == nil {
+		return nil
+	}
+	return server.p2pNode.Gater()
+}
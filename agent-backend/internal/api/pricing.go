@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// handleGetPricing handles GET /api/v1/pricing/{productId}. Product IDs
+// contain a slash, so the route uses a wildcard.
+func (server *Server) handleGetPricing(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "*")
+	if !productIDPattern.MatchString(productID) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "productId must conform to did:pandacea format")
+		return
+	}
+	if server.pricer == nil {
+		server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeInternalError, "Pricing unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(server.pricer.Quote(productID)); err != nil {
+		server.logger.Error("failed to encode pricing response", "error", err)
+	}
+}
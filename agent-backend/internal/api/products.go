@@ -0,0 +1,253 @@
+package api
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ProductStore holds the product catalog. Readers load an immutable
+// snapshot without taking a lock, so they never wait on a writer; writers
+// are serialized, build the next snapshot and swap it in. A write copies
+// only the index entries the product appears under, so its cost does not
+// grow with the rest of the catalog's keywords and data types.
+//
+// Lookups return slices shared with the store, as do the Keywords and
+// Assets of each product; callers must copy them before modifying them.
+// The slices are clipped, so appending to one copies it.
+type ProductStore struct {
+	mu       sync.Mutex // Serializes writers
+	snapshot atomic.Pointer[productSnapshot]
+}
+
+// productSnapshot is one version of the catalog with its lookup indexes.
+// It is never modified once stored. The index slices hold products in
+// catalog order.
+type productSnapshot struct {
+	products   []DataProduct            // Catalog order
+	byID       map[string]int           // Product ID to index in products
+	byKeyword  map[string][]DataProduct // Lowercase keyword to its products
+	byDataType map[string][]DataProduct // Lowercase data type to its products
+}
+
+// NewProductStore returns a store holding products
+func NewProductStore(products []DataProduct) *ProductStore {
+	s := &ProductStore{}
+	s.snapshot.Store(newProductSnapshot(products))
+	return s
+}
+
+// newProductSnapshot indexes products, which it takes ownership of
+func newProductSnapshot(products []DataProduct) *productSnapshot {
+	snap := &productSnapshot{
+		products:   products,
+		byID:       make(map[string]int, len(products)),
+		byKeyword:  make(map[string][]DataProduct),
+		byDataType: make(map[string][]DataProduct),
+	}
+	for i, product := range products {
+		snap.byID[product.ProductID] = i
+		dataType, keywords := productIndexKeys(product)
+		if dataType != "" {
+			snap.byDataType[dataType] = append(snap.byDataType[dataType], product)
+		}
+		for _, keyword := range keywords {
+			snap.byKeyword[keyword] = append(snap.byKeyword[keyword], product)
+		}
+	}
+	return snap
+}
+
+// productIndexKeys returns the lowercase data type and the distinct,
+// non-empty lowercase keywords a product is indexed under
+func productIndexKeys(product DataProduct) (string, []string) {
+	keywords := make([]string, 0, len(product.Keywords))
+	for _, keyword := range product.Keywords {
+		keyword = strings.ToLower(keyword)
+		if keyword != "" && !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return strings.ToLower(product.DataType), keywords
+}
+
+// Replace replaces the whole catalog
+func (s *ProductStore) Replace(products []DataProduct) {
+	owned := make([]DataProduct, len(products))
+	copy(owned, products)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.Store(newProductSnapshot(owned))
+}
+
+// Put adds a product, or replaces the one with the same ID in place. It
+// reports whether the product was added.
+func (s *ProductStore) Put(product DataProduct) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	next := &productSnapshot{byID: current.byID}
+	i, exists := current.byID[product.ProductID]
+	var oldType string
+	var oldKeywords []string
+	if exists {
+		oldType, oldKeywords = productIndexKeys(current.products[i])
+		next.products = slices.Clone(current.products)
+		next.products[i] = product
+	} else {
+		i = len(current.products)
+		next.products = append(slices.Clip(current.products), product)
+		next.byID = maps.Clone(current.byID)
+		next.byID[product.ProductID] = i
+	}
+
+	newType, newKeywords := productIndexKeys(product)
+	next.byDataType = next.reindex(current.byDataType, product, i, nonEmpty(oldType), nonEmpty(newType))
+	next.byKeyword = next.reindex(current.byKeyword, product, i, oldKeywords, newKeywords)
+	s.snapshot.Store(next)
+	return !exists
+}
+
+// Delete removes a product and reports whether it was in the catalog
+func (s *ProductStore) Delete(productID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	i, exists := current.byID[productID]
+	if !exists {
+		return false
+	}
+	next := &productSnapshot{
+		products: slices.Delete(slices.Clone(current.products), i, i+1),
+		byID:     maps.Clone(current.byID),
+	}
+	delete(next.byID, productID)
+	for j := i; j < len(next.products); j++ {
+		next.byID[next.products[j].ProductID] = j
+	}
+
+	oldType, oldKeywords := productIndexKeys(current.products[i])
+	next.byDataType = next.reindex(current.byDataType, current.products[i], i, nonEmpty(oldType), nil)
+	next.byKeyword = next.reindex(current.byKeyword, current.products[i], i, oldKeywords, nil)
+	s.snapshot.Store(next)
+	return true
+}
+
+// reindex returns a copy of index with product, at catalog position pos,
+// moved from the entries under oldKeys to those under newKeys. Only those
+// entries are copied; the others are shared with index. It reads catalog
+// positions from snap, which must already hold the write.
+func (snap *productSnapshot) reindex(index map[string][]DataProduct, product DataProduct, pos int, oldKeys, newKeys []string) map[string][]DataProduct {
+	if len(oldKeys) == 0 && len(newKeys) == 0 {
+		return index
+	}
+	next := maps.Clone(index)
+	for _, key := range oldKeys {
+		if slices.Contains(newKeys, key) {
+			continue
+		}
+		entries := slices.DeleteFunc(slices.Clone(index[key]), func(p DataProduct) bool {
+			return p.ProductID == product.ProductID
+		})
+		if len(entries) == 0 {
+			delete(next, key)
+		} else {
+			next[key] = entries
+		}
+	}
+	for _, key := range newKeys {
+		entries := slices.Clone(index[key])
+		if slices.Contains(oldKeys, key) {
+			j := slices.IndexFunc(entries, func(p DataProduct) bool { return p.ProductID == product.ProductID })
+			entries[j] = product
+		} else {
+			j, _ := slices.BinarySearchFunc(entries, pos, func(p DataProduct, pos int) int {
+				return cmp.Compare(snap.byID[p.ProductID], pos)
+			})
+			entries = slices.Insert(entries, j, product)
+		}
+		next[key] = entries
+	}
+	return next
+}
+
+// nonEmpty returns key as a one-element list, or nil if it is empty
+func nonEmpty(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return []string{key}
+}
+
+// Len returns the number of products in the catalog
+func (s *ProductStore) Len() int {
+	return len(s.snapshot.Load().products)
+}
+
+// Get returns the product with the given ID
+func (s *ProductStore) Get(productID string) (DataProduct, bool) {
+	snap := s.snapshot.Load()
+	i, exists := snap.byID[productID]
+	if !exists {
+		return DataProduct{}, false
+	}
+	return snap.products[i], true
+}
+
+// List returns the whole catalog in catalog order
+func (s *ProductStore) List() []DataProduct {
+	return slices.Clip(s.snapshot.Load().products)
+}
+
+// ByKeyword returns the products tagged with keyword, ignoring case
+func (s *ProductStore) ByKeyword(keyword string) []DataProduct {
+	return slices.Clip(s.snapshot.Load().byKeyword[strings.ToLower(keyword)])
+}
+
+// ByDataType returns the products of a data type, ignoring case
+func (s *ProductStore) ByDataType(dataType string) []DataProduct {
+	return slices.Clip(s.snapshot.Load().byDataType[strings.ToLower(dataType)])
+}
+
+// Find returns the products matching both a keyword and a data type,
+// either of which may be empty to match any product
+func (s *ProductStore) Find(keyword, dataType string) []DataProduct {
+	switch {
+	case keyword == "" && dataType == "":
+		return s.List()
+	case keyword == "":
+		return s.ByDataType(dataType)
+	case dataType == "":
+		return s.ByKeyword(keyword)
+	}
+
+	// Walk the shorter index and check the other condition directly
+	snap := s.snapshot.Load()
+	byKeyword := snap.byKeyword[strings.ToLower(keyword)]
+	byDataType := snap.byDataType[strings.ToLower(dataType)]
+	if len(byKeyword) <= len(byDataType) {
+		var matched []DataProduct
+		for _, product := range byKeyword {
+			if strings.EqualFold(product.DataType, dataType) {
+				matched = append(matched, product)
+			}
+		}
+		return matched
+	}
+	var matched []DataProduct
+	for _, product := range byDataType {
+		for _, k := range product.Keywords {
+			if strings.EqualFold(k, keyword) {
+				matched = append(matched, product)
+				break
+			}
+		}
+	}
+	return matched
+}
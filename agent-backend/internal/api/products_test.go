@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductStore(t *testing.T) {
+	store := NewProductStore([]DataProduct{
+		{ProductID: "p1", DataType: "Tabular", Keywords: []string{"weather", "Hourly"}},
+		{ProductID: "p2", DataType: "image", Keywords: []string{"scans", "hourly"}},
+		{ProductID: "p3", DataType: "tabular", Keywords: []string{"scans"}},
+	})
+
+	product, ok := store.Get("p2")
+	require.True(t, ok)
+	assert.Equal(t, "image", product.DataType)
+	_, ok = store.Get("missing")
+	assert.False(t, ok)
+
+	ids := func(products []DataProduct) []string {
+		ids := make([]string, len(products))
+		for i, p := range products {
+			ids[i] = p.ProductID
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"p1", "p2"}, ids(store.ByKeyword("HOURLY")))
+	assert.Equal(t, []string{"p1", "p3"}, ids(store.ByDataType("tabular")))
+	assert.Equal(t, []string{"p3"}, ids(store.Find("scans", "Tabular")))
+	assert.Equal(t, []string{"p1", "p2", "p3"}, ids(store.Find("", "")))
+	assert.Empty(t, store.Find("nothing", ""))
+
+	// Writes replace the indexes without disturbing earlier reads
+	before := store.List()
+	assert.False(t, store.Put(DataProduct{ProductID: "p1", DataType: "image", Keywords: []string{"weather"}}))
+	assert.True(t, store.Put(DataProduct{ProductID: "p4", DataType: "image"}))
+	assert.Equal(t, []string{"p1", "p2", "p4"}, ids(store.ByDataType("image")))
+	assert.Equal(t, []string{"p1"}, ids(store.ByKeyword("weather")))
+	assert.Equal(t, []string{"p2"}, ids(store.ByKeyword("hourly")))
+	assert.Equal(t, "Tabular", before[0].DataType)
+
+	assert.True(t, store.Delete("p2"))
+	assert.False(t, store.Delete("p2"))
+	assert.Equal(t, []string{"p1", "p3", "p4"}, ids(store.List()))
+	assert.Equal(t, []string{"p1", "p4"}, ids(store.ByDataType("image")))
+	assert.Equal(t, 3, store.Len())
+
+	// Products moving between index entries keep their catalog order
+	assert.True(t, store.Put(DataProduct{ProductID: "p5", DataType: "image", Keywords: []string{"scans"}}))
+	assert.False(t, store.Put(DataProduct{ProductID: "p3", DataType: "Image", Keywords: []string{"weather"}}))
+	assert.Equal(t, []string{"p1", "p3", "p4", "p5"}, ids(store.ByDataType("image")))
+	assert.Empty(t, store.ByDataType("tabular"))
+	assert.Equal(t, []string{"p5"}, ids(store.ByKeyword("scans")))
+	assert.Equal(t, []string{"p1", "p3"}, ids(store.ByKeyword("weather")))
+	product, ok = store.Get("p5")
+	require.True(t, ok)
+	assert.Equal(t, "p5", product.ProductID)
+}
+
+func TestProductStoreConcurrent(t *testing.T) {
+	store := NewProductStore(benchmarkProducts(1000))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprintf("writer-%d-%d", w, i)
+				store.Put(DataProduct{ProductID: id, DataType: "tabular", Keywords: []string{"new"}})
+				if i%2 == 0 {
+					store.Delete(id)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, ok := store.Get("product-42"); !ok {
+					t.Error("existing product disappeared during writes")
+					return
+				}
+				store.Find("new", "tabular")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000+4*100, store.Len())
+	assert.Len(t, store.ByKeyword("new"), 4*100)
+}
+
+// benchmarkProducts returns n products spread over 10 data types and 1000
+// keywords
+func benchmarkProducts(n int) []DataProduct {
+	products := make([]DataProduct, n)
+	for i := range products {
+		products[i] = DataProduct{
+			ProductID: fmt.Sprintf("product-%d", i),
+			Name:      fmt.Sprintf("Product %d", i),
+			DataType:  fmt.Sprintf("type-%d", i%10),
+			Keywords:  []string{fmt.Sprintf("keyword-%d", i%1000), fmt.Sprintf("region-%d", i%7)},
+		}
+	}
+	return products
+}
+
+// The benchmarks read a 100k product catalog
+func BenchmarkProductStoreGet(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			store.Get(fmt.Sprintf("product-%d", i%100_000))
+			i++
+		}
+	})
+}
+
+func BenchmarkProductStoreByKeyword(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.ByKeyword("keyword-42")
+	}
+}
+
+func BenchmarkProductStoreByDataType(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.ByDataType("type-3")
+	}
+}
+
+func BenchmarkProductStoreFind(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Find("keyword-42", "type-2")
+	}
+}
+
+func BenchmarkProductStorePut(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Put(DataProduct{ProductID: fmt.Sprintf("product-%d", i%100_000), DataType: fmt.Sprintf("type-%d", i%10)})
+	}
+}
+
+// BenchmarkProductStoreGetDuringWrites reads while a writer keeps
+// replacing products, which readers never wait on
+func BenchmarkProductStoreGetDuringWrites(b *testing.B) {
+	store := NewProductStore(benchmarkProducts(100_000))
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				store.Put(DataProduct{ProductID: fmt.Sprintf("product-%d", i%100_000), DataType: "type-1"})
+			}
+		}
+	}()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Get(fmt.Sprintf("product-%d", i%100_000))
+	}
+}
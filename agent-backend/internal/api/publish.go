@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/pinning"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+// PinsResponse lists the content the agent published to IPFS
+type PinsResponse struct {
+	Data []pinning.Pin `json:"data"`
+}
+
+// SetPublisher publishes the job outputs cfg selects to IPFS through p.
+// Their pins are released as retention drops the jobs.
+func (server *Server) SetPublisher(p *pinning.Publisher, cfg config.PublishConfig) {
+	server.publisher = p
+	server.publish = cfg
+}
+
+// PublishedArtifact is what a training artifact is published as once its
+// owner opens the envelope: the artifact with its signature
+type PublishedArtifact struct {
+	Artifact  []byte       `json:"artifact"`
+	Integrity *ArtifactSig `json:"integrity,omitempty"`
+}
+
+// publishTrainingArtifact publishes a finished, signed training artifact
+// and records its CID on the job. Anyone can fetch content from IPFS, so
+// like computation results the artifact is only published sealed to the
+// job's owner, together with its signature. The job completes whether or
+// not it is published.
+func (server *Server) publishTrainingArtifact(jobID string, job *TrainingJob, aggregatePath string) {
+	if server.publisher == nil || !server.publish.Training {
+		return
+	}
+	server.jobsMutex.RLock()
+	owner, integrity := job.owner, job.Integrity
+	server.jobsMutex.RUnlock()
+	recipient, err := resultRecipient(owner)
+	if err != nil {
+		server.logger.Warn("training artifact not published, as it cannot be sealed to its owner", "error", err, "job_id", jobID)
+		return
+	}
+	content, err := os.ReadFile(aggregatePath)
+	if err != nil {
+		server.logger.Error("failed to read training artifact to publish", "error", err, "job_id", jobID)
+		return
+	}
+	plaintext, err := json.Marshal(PublishedArtifact{Artifact: content, Integrity: integrity})
+	if err != nil {
+		server.logger.Error("failed to encode training artifact to publish", "error", err, "job_id", jobID)
+		return
+	}
+	sealed, err := envelope.Seal(recipient, plaintext)
+	if err != nil {
+		server.logger.Error("failed to seal training artifact to publish", "error", err, "job_id", jobID)
+		return
+	}
+	if content, err = json.Marshal(sealed); err != nil {
+		server.logger.Error("failed to encode sealed training artifact", "error", err, "job_id", jobID)
+		return
+	}
+	pin, err := server.publisher.Publish(context.Background(), pinning.KindTraining, jobID, filepath.Base(aggregatePath)+".sealed", content)
+	if err != nil {
+		server.logger.Error("failed to publish training artifact", "error", err, "job_id", jobID)
+		return
+	}
+	server.jobsMutex.Lock()
+	job.CID = pin.CID
+	server.jobsMutex.Unlock()
+	server.logger.Info("training artifact published", "job_id", jobID, "cid", pin.CID, "status", pin.Status)
+}
+
+// publishComputationResult publishes the results of a completed
+// computation the first time they are read and sets their CID. Only
+// sealed results are published, as anyone can fetch content from IPFS.
+func (server *Server) publishComputationResult(ctx context.Context, computationID string, result *privacy.ComputationResult) {
+	if server.publisher == nil || !server.publish.Computations || result.Status != string(jobs.StateCompleted) {
+		return
+	}
+	if result.Results == nil || result.Results.Sealed == nil {
+		server.logger.Debug("unsealed computation results are not published", "computation_id", computationID)
+		return
+	}
+	content, err := json.Marshal(result.Results)
+	if err != nil {
+		server.logger.Error("failed to encode computation results to publish", "error", err, "computation_id", computationID)
+		return
+	}
+	pin, err := server.publisher.Publish(ctx, pinning.KindComputation, computationID, computationID+".json", content)
+	if err != nil {
+		server.logger.Error("failed to publish computation results", "error", err, "computation_id", computationID)
+		return
+	}
+	result.CID = pin.CID
+}
+
+// collectPins releases the pins of jobs retention dropped
+func (server *Server) collectPins(ctx context.Context) int {
+	if server.publisher == nil {
+		return 0
+	}
+	return server.publisher.Collect(ctx, func(pin pinning.Pin) bool {
+		return server.pinSourceExists(ctx, pin)
+	})
+}
+
+// pinSourceExists reports whether the job pin was published for is still
+// kept. Jobs that cannot be looked up are assumed to exist.
+func (server *Server) pinSourceExists(ctx context.Context, pin pinning.Pin) bool {
+	switch pin.Kind {
+	case pinning.KindTraining:
+		server.jobsMutex.RLock()
+		defer server.jobsMutex.RUnlock()
+		_, exists := server.jobs[pin.SourceID]
+		return exists
+	case pinning.KindComputation:
+		if server.privacyService == nil {
+			return true
+		}
+		_, err := server.privacyService.GetComputationResult(ctx, pin.SourceID)
+		return !errors.Is(err, privacy.ErrComputationNotFound)
+	}
+	return true
+}
+
+// handleListPins handles GET /api/v1/pins
+func (server *Server) handleListPins(w http.ResponseWriter, r *http.Request) {
+	if server.publisher == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Publishing to IPFS is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	resp := PinsResponse{Data: server.publisher.List(pinning.Query{
+		Kind:   params.Get("kind"),
+		Status: pinning.Status(params.Get("status")),
+	})}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode pins", "error", err)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/pinning"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sealingPrivacyService returns sealed results for the computations in
+// sealed and the mock's plaintext results for the rest of known
+type sealingPrivacyService struct {
+	MockPrivacyService
+	known  map[string]bool
+	sealed map[string]bool
+}
+
+func (m *sealingPrivacyService) GetComputationResult(ctx context.Context, computationID string) (*privacy.ComputationResult, error) {
+	if !m.known[computationID] {
+		return nil, fmt.Errorf("%w: %s", privacy.ErrComputationNotFound, computationID)
+	}
+	if m.sealed[computationID] {
+		return &privacy.ComputationResult{Status: "completed", Owner: "12D3KooWSpender", Results: &privacy.ComputationResults{
+			Sealed: &envelope.Envelope{Version: "1", KeyType: "X25519", Ciphertext: []byte(computationID)},
+		}}, nil
+	}
+	result, err := m.MockPrivacyService.GetComputationResult(ctx, computationID)
+	if err != nil {
+		return nil, err
+	}
+	result.Owner = "12D3KooWSpender"
+	return result, nil
+}
+
+func TestServer_Publish(t *testing.T) {
+	// An IPFS node naming content after its length
+	var mu sync.Mutex
+	pinned := make(map[string]bool)
+	contents := make(map[string][]byte)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		var content bytes.Buffer
+		content.ReadFrom(file)
+		cid := fmt.Sprintf("bafy%d", content.Len())
+		mu.Lock()
+		pinned[cid] = true
+		contents[cid] = content.Bytes()
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"Hash": cid})
+	})
+	mux.HandleFunc("POST /api/v0/pin/rm", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delete(pinned, r.URL.Query().Get("arg"))
+		mu.Unlock()
+	})
+	node := httptest.NewServer(mux)
+	defer node.Close()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	privacyService := &sealingPrivacyService{known: map[string]bool{"comp-sealed": true, "comp-plain": true}, sealed: map[string]bool{"comp-sealed": true}}
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, privacyService, nil)
+	publisher, err := pinning.New(pinning.NewNode(node.URL), "", logger)
+	require.NoError(t, err)
+	server.SetPublisher(publisher, config.PublishConfig{Enabled: true, Training: true, Computations: true})
+	ctx := context.Background()
+
+	// Training artifacts are published as their job finishes, sealed with
+	// their signature to the job's owner. Jobs whose owner cannot be
+	// sealed to are not published.
+	artifact := filepath.Join(t.TempDir(), "aggregate.json")
+	require.NoError(t, os.WriteFile(artifact, []byte(`{"n":10}`), 0644))
+	unowned := &TrainingJob{JobID: "job-0", Status: "running"}
+	server.publishTrainingArtifact("job-0", unowned, artifact)
+	assert.Empty(t, unowned.CID)
+
+	ownerKey, ownerPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	owner, err := peer.IDFromPublicKey(ownerPub)
+	require.NoError(t, err)
+	integrity := &ArtifactSig{JobID: "job-1", SHA256: "abc"}
+	job := &TrainingJob{JobID: "job-1", Status: "running", Integrity: integrity, owner: owner.String()}
+	server.jobs["job-1"] = job
+	server.publishTrainingArtifact("job-1", job, artifact)
+	require.NotEmpty(t, job.CID)
+
+	var sealed envelope.Envelope
+	require.NoError(t, json.Unmarshal(contents[job.CID], &sealed))
+	plaintext, err := envelope.Open(ownerKey, &sealed)
+	require.NoError(t, err)
+	var published PublishedArtifact
+	require.NoError(t, json.Unmarshal(plaintext, &published))
+	assert.Equal(t, `{"n":10}`, string(published.Artifact))
+	assert.Equal(t, integrity, published.Integrity)
+
+	// Sealed results are published as they are read; plaintext ones never are
+	result, err := server.ownComputationResult(ctx, "comp-sealed", "12D3KooWSpender")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.CID)
+	result, err = server.ownComputationResult(ctx, "comp-plain", "12D3KooWSpender")
+	require.NoError(t, err)
+	assert.Empty(t, result.CID)
+	assert.Len(t, publisher.List(pinning.Query{}), 2)
+
+	w := httptest.NewRecorder()
+	server.handleListPins(w, httptest.NewRequest("GET", "/api/v1/pins?kind=training", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed PinsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Data, 1)
+	assert.Equal(t, "job-1", listed.Data[0].SourceID)
+
+	// Pins go once retention drops their job
+	assert.Zero(t, server.collectPins(ctx))
+	delete(server.jobs, "job-1")
+	privacyService.known["comp-sealed"] = false
+	assert.Equal(t, 2, server.collectPins(ctx))
+	assert.Empty(t, pinned)
+	assert.Empty(t, publisher.List(pinning.Query{}))
+}
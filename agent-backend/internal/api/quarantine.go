@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit event types for product quarantines
+const (
+	AuditAdminQuarantine = "admin.quarantine"
+	AuditAdminRelease    = "admin.quarantine_lifted"
+	AuditQuarantined     = "quarantine.refused"
+)
+
+// QuarantineRequest represents a request to quarantine a product or data asset
+type QuarantineRequest struct {
+	ProductID string `json:"product_id"`
+	Reason    string `json:"reason"`
+}
+
+// Quarantine is an active quarantine on a product or data asset. New leases,
+// computations and training jobs on it are refused until it is lifted.
+type Quarantine struct {
+	ProductID      string    `json:"product_id"`
+	Reason         string    `json:"reason"`
+	QuarantinedBy  string    `json:"quarantined_by"`
+	QuarantinedAt  time.Time `json:"quarantined_at"`
+	NotifiedLeases []string  `json:"notified_leases,omitempty"` // Lease proposals whose holders were notified
+	// Erased is set once the product's data has been erased. Such a
+	// quarantine is permanent, and also covers the erased Assets.
+	Erased bool     `json:"erased,omitempty"`
+	Assets []string `json:"assets,omitempty"`
+}
+
+// QuarantinesResponse lists active quarantines
+type QuarantinesResponse struct {
+	Data []Quarantine `json:"data"`
+}
+
+// SetQuarantineFile persists quarantines to path and restores the ones
+// already saved there, so a quarantine survives agent restarts
+func (server *Server) SetQuarantineFile(path string) error {
+	server.quarantineMutex.Lock()
+	defer server.quarantineMutex.Unlock()
+
+	server.quarantineFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read quarantine state: %w", err)
+	}
+	var saved map[string]*Quarantine
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse quarantine state: %w", err)
+	}
+	for id, q := range saved {
+		server.quarantined[id] = q
+	}
+	return nil
+}
+
+// saveQuarantines writes the active quarantines to disk. Caller must hold quarantineMutex.
+func (server *Server) saveQuarantines() error {
+	if server.quarantineFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(server.quarantined)
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(server.quarantineFile), 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine state directory: %w", err)
+	}
+	if err := fsutil.WriteFile(server.quarantineFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write quarantine state: %w", err)
+	}
+	return nil
+}
+
+// quarantine returns the active quarantine on a product, if any
+func (server *Server) quarantine(productID string) (Quarantine, bool) {
+	server.quarantineMutex.RLock()
+	defer server.quarantineMutex.RUnlock()
+
+	q, exists := server.quarantined[productID]
+	if !exists {
+		return Quarantine{}, false
+	}
+	return *q, true
+}
+
+// rejectQuarantined refuses a request on a quarantined product, auditing the
+// attempt. It reports whether the request was rejected.
+func (server *Server) rejectQuarantined(w http.ResponseWriter, r *http.Request, productID string, fields map[string]any) bool {
+	q, quarantined := server.quarantine(productID)
+	if !quarantined {
+		return false
+	}
+
+	server.logger.Warn("request on quarantined product refused", "product_id", productID, "path", r.URL.Path)
+	audited := map[string]any{"product_id": productID, "path": r.URL.Path}
+	for k, v := range fields {
+		audited[k] = v
+	}
+	server.recordAudit(AuditQuarantined, r.Header.Get(reqsig.HeaderPeerID), audited)
+	if q.Erased {
+		server.sendErrorResponse(w, r, http.StatusGone, ErrorCodeDataErased, fmt.Sprintf("%s's data has been erased", productID))
+		return true
+	}
+	server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeQuarantined,
+		fmt.Sprintf("%s is quarantined: %s", productID, q.Reason))
+	return true
+}
+
+// handleQuarantineProduct handles POST /api/v1/admin/security/quarantine. It
+// takes effect immediately and notifies the holders of active leases on the
+// product. Leases, jobs and results already on record are left in place as
+// evidence.
+func (server *Server) handleQuarantineProduct(w http.ResponseWriter, r *http.Request) {
+	var req QuarantineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.ProductID == "" || req.Reason == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "product_id and reason are required")
+		return
+	}
+
+	actor := r.Header.Get(reqsig.HeaderPeerID)
+	q := &Quarantine{
+		ProductID:     req.ProductID,
+		Reason:        req.Reason,
+		QuarantinedBy: actor,
+		QuarantinedAt: time.Now().UTC(),
+	}
+
+	server.quarantineMutex.Lock()
+	if existing, exists := server.quarantined[req.ProductID]; exists {
+		response := *existing
+		server.quarantineMutex.Unlock()
+		server.writeQuarantine(w, http.StatusOK, &response)
+		return
+	}
+	server.quarantined[req.ProductID] = q
+	server.quarantineMutex.Unlock()
+
+	// New requests are refused from here on, so the leases found now are
+	// all the leases the quarantine affects
+	leases := server.notifyQuarantine(q)
+
+	server.quarantineMutex.Lock()
+	for _, lease := range leases {
+		q.NotifiedLeases = append(q.NotifiedLeases, lease["lease_proposal_id"].(string))
+	}
+	if err := server.saveQuarantines(); err != nil {
+		// The quarantine still applies until restart; losing it then is
+		// safer than refusing to quarantine now
+		server.logger.Error("failed to persist quarantine", "product_id", req.ProductID, "error", err)
+	}
+	response := *q
+	server.quarantineMutex.Unlock()
+
+	server.recordAudit(AuditAdminQuarantine, actor, map[string]any{
+		"product_id": req.ProductID,
+		"reason":     req.Reason,
+		"leases":     leases,
+	})
+	server.logger.Warn("product quarantined", "product_id", req.ProductID, "reason", req.Reason, "notified_leases", len(leases))
+
+	server.writeQuarantine(w, http.StatusCreated, &response)
+}
+
+// notifyQuarantine streams the quarantine to the holders of active leases on
+// the product and returns a snapshot of those leases for the audit record
+func (server *Server) notifyQuarantine(q *Quarantine) []map[string]any {
+	server.leasesMutex.RLock()
+	defer server.leasesMutex.RUnlock()
+
+	var leases []map[string]any
+	for leaseProposalID, state := range server.pendingLeases {
+		if state.ProductID != q.ProductID || state.Status == LeaseStatusExpired || state.Status == "disputed" {
+			continue
+		}
+		lease := map[string]any{
+			"lease_proposal_id": leaseProposalID,
+			"status":            state.Status,
+			"owner":             state.owner,
+		}
+		if state.SpenderAddr != "" {
+			lease["spender"] = state.SpenderAddr
+		}
+		leases = append(leases, lease)
+
+		server.publishStatus(EventProductQuarantined, state.owner, map[string]any{
+			"lease_proposal_id": leaseProposalID,
+			"product_id":        q.ProductID,
+			"reason":            q.Reason,
+		})
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i]["lease_proposal_id"].(string) < leases[j]["lease_proposal_id"].(string)
+	})
+	return leases
+}
+
+// writeQuarantine encodes a quarantine response
+func (server *Server) writeQuarantine(w http.ResponseWriter, status int, q *Quarantine) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(q); err != nil {
+		server.logger.Error("failed to encode quarantine", "error", err)
+	}
+}
+
+// handleListQuarantines handles GET /api/v1/admin/security/quarantine
+func (server *Server) handleListQuarantines(w http.ResponseWriter, r *http.Request) {
+	server.quarantineMutex.RLock()
+	response := QuarantinesResponse{Data: make([]Quarantine, 0, len(server.quarantined))}
+	for _, q := range server.quarantined {
+		response.Data = append(response.Data, *q)
+	}
+	server.quarantineMutex.RUnlock()
+	sort.Slice(response.Data, func(i, j int) bool {
+		return response.Data[i].QuarantinedAt.Before(response.Data[j].QuarantinedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode quarantines", "error", err)
+	}
+}
+
+// handleLiftQuarantine handles DELETE /api/v1/admin/security/quarantine/{productId}.
+// Product IDs contain a slash, so the route uses a wildcard.
+func (server *Server) handleLiftQuarantine(w http.ResponseWriter, r *http.Request) {
+	productID := chi.URLParam(r, "*")
+
+	server.quarantineMutex.Lock()
+	q, exists := server.quarantined[productID]
+	if exists && q.Erased {
+		server.quarantineMutex.Unlock()
+		server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeDataErased, "The product's data has been erased; its quarantine cannot be lifted")
+		return
+	}
+	if exists {
+		delete(server.quarantined, productID)
+		if err := server.saveQuarantines(); err != nil {
+			server.quarantined[productID] = q
+			server.quarantineMutex.Unlock()
+			server.logger.Error("failed to persist lifted quarantine", "product_id", productID, "error", err)
+			server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to lift quarantine")
+			return
+		}
+	}
+	server.quarantineMutex.Unlock()
+
+	if !exists {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Product is not quarantined")
+		return
+	}
+
+	server.recordAudit(AuditAdminRelease, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"product_id":     productID,
+		"reason":         q.Reason,
+		"quarantined_at": q.QuarantinedAt,
+	})
+	server.logger.Info("product quarantine lifted", "product_id", productID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+// defaultProductsDir is where training jobs write their artifacts until
+// SetProductsDir is called
+const defaultProductsDir = "./data/products"
+
+var (
+	retentionEvictedJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_retention_evicted_jobs_total",
+		Help: "Finished jobs dropped by retention, by kind (training, computation)",
+	}, []string{"kind"})
+	retentionReclaimedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_retention_reclaimed_bytes_total",
+		Help: "Bytes of training artifacts deleted by retention",
+	})
+	retentionArtifactBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_retention_artifact_bytes",
+		Help: "Bytes of training artifacts kept after the last retention run",
+	})
+)
+
+// RetentionReport is what a retention run dropped
+type RetentionReport struct {
+	TrainingJobs   int   `json:"training_jobs"`
+	Computations   int   `json:"computations"`
+	Artifacts      int   `json:"artifacts"` // Artifact directories deleted, including ones without a job
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+	KeptBytes      int64 `json:"kept_bytes"`
+	Pins           int   `json:"pins"` // IPFS pins of dropped jobs released
+}
+
+// retained is a finished job or artifact directory retention may drop
+type retained struct {
+	jobID      string
+	finishedAt time.Time
+	hasJob     bool
+	hasDir     bool
+	bytes      int64
+}
+
+// SetRetention bounds how many finished jobs are kept, for how long, and
+// how much space their artifacts take. RunRetention applies it.
+func (server *Server) SetRetention(cfg config.RetentionConfig) {
+	server.retention = cfg
+	if _, ok := server.privacyService.(privacy.JobCollector); !ok && server.privacyService != nil {
+		server.logger.Warn("privacy service cannot drop finished computations; retention covers training jobs only")
+	}
+}
+
+// RunRetention applies retention every interval until ctx is cancelled
+func (server *Server) RunRetention(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			report := server.applyRetention(time.Now())
+			report.Pins = server.collectPins(ctx)
+			if report.TrainingJobs+report.Computations+report.Artifacts+report.Pins > 0 {
+				server.logger.Info("retention applied", "training_jobs", report.TrainingJobs, "computations", report.Computations,
+					"artifacts", report.Artifacts, "reclaimed_bytes", report.ReclaimedBytes, "kept_bytes", report.KeptBytes, "pins", report.Pins)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRetention drops finished jobs and artifacts past the retention
+// limits, oldest first. A training job and its artifacts go together.
+// Artifact directories without a job, such as those of jobs that were not
+// persisted across a restart, age from their last modification.
+func (server *Server) applyRetention(now time.Time) RetentionReport {
+	var report RetentionReport
+	var cutoff time.Time
+	if server.retention.MaxAgeHours > 0 {
+		cutoff = now.Add(-time.Duration(server.retention.MaxAgeHours) * time.Hour)
+	}
+
+	if collector, ok := server.privacyService.(privacy.JobCollector); ok {
+		report.Computations = collector.CollectJobs(cutoff, server.retention.MaxJobs)
+		retentionEvictedJobs.WithLabelValues("computation").Add(float64(report.Computations))
+	}
+
+	// Sizing artifacts walks the disk, so it is done before taking the lock
+	dirs := server.artifactDirs()
+
+	server.jobsMutex.Lock()
+	var candidates []*retained
+	var total int64
+	finishedJobs := 0
+	for id, job := range server.jobs {
+		if !trainingJobs.IsTerminal(jobs.State(job.Status)) || (server.models != nil && server.models.Staged(id)) {
+			// Running jobs and models in staging or production keep their
+			// artifacts, which still count towards the total
+			if dir, exists := dirs[id]; exists {
+				total += dir.bytes
+				delete(dirs, id)
+			}
+			continue
+		}
+		entry := &retained{jobID: id, finishedAt: job.UpdatedAt, hasJob: true}
+		if job.CompletedAt != nil {
+			entry.finishedAt = *job.CompletedAt
+		}
+		if dir, exists := dirs[id]; exists {
+			entry.hasDir = true
+			entry.bytes = dir.bytes
+			delete(dirs, id)
+		}
+		candidates = append(candidates, entry)
+		finishedJobs++
+	}
+	for _, dir := range dirs {
+		candidates = append(candidates, dir)
+	}
+	for _, entry := range candidates {
+		total += entry.bytes
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].finishedAt.Before(candidates[j].finishedAt)
+	})
+
+	maxBytes := int64(server.retention.MaxTotalMB) << 20
+	var drop []*retained
+	for _, entry := range candidates {
+		expired := !cutoff.IsZero() && entry.finishedAt.Before(cutoff)
+		tooMany := entry.hasJob && server.retention.MaxJobs > 0 && finishedJobs > server.retention.MaxJobs
+		tooLarge := entry.hasDir && maxBytes > 0 && total > maxBytes
+		if !expired && !tooMany && !tooLarge {
+			continue
+		}
+		drop = append(drop, entry)
+		total -= entry.bytes
+		if entry.hasJob {
+			finishedJobs--
+			server.dropTrainingJob(entry.jobID)
+		}
+	}
+	server.jobsMutex.Unlock()
+
+	for _, entry := range drop {
+		if entry.hasJob {
+			report.TrainingJobs++
+		}
+		if server.models != nil {
+			if err := server.models.Remove(entry.jobID); err != nil {
+				server.logger.Error("failed to save model registry", "job_id", entry.jobID, "error", err)
+			}
+		}
+		if !entry.hasDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(server.productsDir, entry.jobID)); err != nil {
+			server.logger.Error("failed to delete training artifacts", "job_id", entry.jobID, "error", err)
+			total += entry.bytes
+			continue
+		}
+		report.Artifacts++
+		report.ReclaimedBytes += entry.bytes
+	}
+	report.KeptBytes = total
+
+	retentionEvictedJobs.WithLabelValues("training").Add(float64(report.TrainingJobs))
+	retentionReclaimedBytes.Add(float64(report.ReclaimedBytes))
+	retentionArtifactBytes.Set(float64(total))
+	return report
+}
+
+// dropTrainingJob forgets a finished training job and its log. Caller must
+// hold jobsMutex.
+func (server *Server) dropTrainingJob(jobID string) {
+	job := server.jobs[jobID]
+	delete(server.jobs, jobID)
+	trainingJobs.Forget(jobs.State(job.Status))
+	if server.jobStore != nil {
+		if err := server.jobStore.Delete(jobID); err != nil {
+			server.logger.Error("failed to delete training job", "job_id", jobID, "error", err)
+		}
+	}
+
+	server.jobLogsMutex.Lock()
+	delete(server.jobLogs, jobID)
+	server.jobLogsMutex.Unlock()
+}
+
+// artifactDirs returns the artifact directories in the products directory by job ID,
+// with their size and last modification
+func (server *Server) artifactDirs() map[string]*retained {
+	dirs := make(map[string]*retained)
+	entries, err := os.ReadDir(server.productsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			server.logger.Warn("failed to list training artifacts", "dir", server.productsDir, "error", err)
+		}
+		return dirs
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := &retained{jobID: entry.Name(), hasDir: true}
+		if info, err := entry.Info(); err == nil {
+			dir.finishedAt = info.ModTime()
+		}
+		_ = filepath.WalkDir(filepath.Join(server.productsDir, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			dir.bytes += info.Size()
+			if info.ModTime().After(dir.finishedAt) {
+				dir.finishedAt = info.ModTime()
+			}
+			return nil
+		})
+		dirs[entry.Name()] = dir
+	}
+	return dirs
+}
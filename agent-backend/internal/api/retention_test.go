@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+)
+
+func TestServer_applyRetention(t *testing.T) {
+	productsDir := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetProductsDir(productsDir)
+
+	now := time.Now()
+	addJob := func(id, status string, finishedAgo time.Duration, artifactBytes int) {
+		job := &TrainingJob{JobID: id, Status: status, CreatedAt: now.Add(-finishedAgo - time.Minute)}
+		job.UpdatedAt = now.Add(-finishedAgo)
+		if status != string(TrainingStatusRunning) {
+			completed := job.UpdatedAt
+			job.CompletedAt = &completed
+		}
+		server.jobs[id] = job
+		if artifactBytes > 0 {
+			dir := filepath.Join(productsDir, id)
+			require.NoError(t, os.MkdirAll(dir, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "model.json"), make([]byte, artifactBytes), 0644))
+		}
+	}
+	addJob("expired", string(TrainingStatusComplete), 48*time.Hour, 1024)
+	addJob("running", string(TrainingStatusRunning), 72*time.Hour, 1<<20)
+	addJob("oldest", string(TrainingStatusFailed), 3*time.Hour, 0)
+	addJob("older", string(TrainingStatusComplete), 2*time.Hour, 1024)
+	addJob("newest", string(TrainingStatusComplete), time.Hour, 1024)
+
+	// An artifact left behind by a job the agent no longer knows about
+	orphan := filepath.Join(productsDir, "orphan")
+	require.NoError(t, os.MkdirAll(orphan, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(orphan, "model.json"), make([]byte, 512), 0644))
+	old := now.Add(-30 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(orphan, "model.json"), old, old))
+	require.NoError(t, os.Chtimes(orphan, old, old))
+
+	server.SetRetention(config.RetentionConfig{Enabled: true, MaxAgeHours: 24, MaxJobs: 2})
+	report := server.applyRetention(now)
+
+	assert.Equal(t, 2, report.TrainingJobs, "the expired job and the oldest beyond max_jobs")
+	assert.Equal(t, 2, report.Artifacts, "the expired job's artifacts and the orphan")
+	assert.Equal(t, int64(1024+512), report.ReclaimedBytes)
+	assert.Equal(t, int64(1<<20+2*1024), report.KeptBytes)
+	for _, id := range []string{"running", "older", "newest"} {
+		assert.Contains(t, server.jobs, id)
+	}
+	for _, id := range []string{"expired", "oldest"} {
+		assert.NotContains(t, server.jobs, id)
+	}
+	assert.NoDirExists(t, filepath.Join(productsDir, "expired"))
+	assert.NoDirExists(t, orphan)
+	assert.DirExists(t, filepath.Join(productsDir, "running"))
+
+	// Over the size limit the oldest finished artifacts go first, but a
+	// running job's are never touched
+	server.SetRetention(config.RetentionConfig{Enabled: true, MaxTotalMB: 1})
+	report = server.applyRetention(now)
+	assert.Equal(t, 2, report.TrainingJobs)
+	assert.Equal(t, int64(1<<20), report.KeptBytes)
+	assert.Contains(t, server.jobs, "running")
+	assert.NoDirExists(t, filepath.Join(productsDir, "newest"))
+}
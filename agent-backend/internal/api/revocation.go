@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/revocation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit event types for identity revocations
+const (
+	AuditAdminRevoke   = "admin.revoke"
+	AuditAdminUnrevoke = "admin.unrevoke"
+	AuditRevoked       = "revocation.refused"
+)
+
+// RevokeRequest represents a request to revoke a peer ID or spender address
+type RevokeRequest struct {
+	Identity string `json:"identity"`
+	Reason   string `json:"reason"`
+}
+
+// RevocationsResponse lists revoked identities
+type RevocationsResponse struct {
+	Data []revocation.Entry `json:"data"`
+}
+
+// SetRevocations refuses requests from the identities registry revokes.
+// Revocations issued through the admin endpoints are pushed with a
+// non-nil gossip.
+func (server *Server) SetRevocations(registry *revocation.Registry, gossip *revocation.Gossip) {
+	server.revocations = registry
+	server.revocationGossip = gossip
+}
+
+// checkRevoked returns an error wrapping revocation.ErrRevoked if any of
+// identities is revoked, auditing the refusal. Empty identities are skipped.
+func (server *Server) checkRevoked(actor string, fields map[string]any, identities ...string) error {
+	if server.revocations == nil {
+		return nil
+	}
+	for _, identity := range identities {
+		if identity == "" {
+			continue
+		}
+		entry, revoked := server.revocations.Revoked(identity)
+		if !revoked {
+			continue
+		}
+
+		server.logger.Warn("request from revoked identity refused", "identity", entry.Identity, "issuer", entry.Issuer)
+		audited := map[string]any{"identity": entry.Identity, "issuer": entry.Issuer}
+		for k, v := range fields {
+			audited[k] = v
+		}
+		server.recordAudit(AuditRevoked, actor, audited)
+		return fmt.Errorf("%w: %s: %s", revocation.ErrRevoked, entry.Identity, entry.Reason)
+	}
+	return nil
+}
+
+// rejectRevoked refuses a request if its peer ID or spender address, or any
+// of the other identities given, is revoked. It reports whether the
+// request was rejected.
+func (server *Server) rejectRevoked(w http.ResponseWriter, r *http.Request, identities ...string) bool {
+	peerID := r.Header.Get(reqsig.HeaderPeerID)
+	identities = append(identities, peerID, r.Header.Get("X-Pandacea-Spender-Address"))
+	err := server.checkRevoked(peerID, map[string]any{"path": r.URL.Path}, identities...)
+	if err == nil {
+		return false
+	}
+	server.sendError(w, r, err, "Identity revoked")
+	return true
+}
+
+// handleRevokeIdentity handles POST /api/v1/admin/security/revocations. The
+// revocation takes effect immediately and is pushed to connected peers.
+func (server *Server) handleRevokeIdentity(w http.ResponseWriter, r *http.Request) {
+	if server.revocations == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Revocations are not enabled")
+		return
+	}
+	var req RevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Identity == "" || req.Reason == "" {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "identity and reason are required")
+		return
+	}
+
+	entry, err := server.revocations.Revoke(req.Identity, req.Reason)
+	if err != nil {
+		server.logger.Error("failed to revoke identity", "identity", req.Identity, "error", err)
+		server.sendError(w, r, err, "Failed to revoke identity")
+		return
+	}
+	server.publishRevocation(entry)
+
+	server.recordAudit(AuditAdminRevoke, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"identity": entry.Identity,
+		"reason":   entry.Reason,
+	})
+	server.logger.Warn("identity revoked", "identity", entry.Identity, "reason", entry.Reason)
+
+	server.writeRevocation(w, http.StatusCreated, entry)
+}
+
+// handleListRevocations handles GET /api/v1/admin/security/revocations
+func (server *Server) handleListRevocations(w http.ResponseWriter, r *http.Request) {
+	response := RevocationsResponse{Data: []revocation.Entry{}}
+	if server.revocations != nil {
+		response.Data = server.revocations.List()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode revocations", "error", err)
+	}
+}
+
+// handleUnrevokeIdentity handles DELETE
+// /api/v1/admin/security/revocations/{identity}. The unrevocation is pushed
+// to connected peers like a revocation, so it overrides the revocation
+// wherever that was applied.
+func (server *Server) handleUnrevokeIdentity(w http.ResponseWriter, r *http.Request) {
+	if server.revocations == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Revocations are not enabled")
+		return
+	}
+	identity := chi.URLParam(r, "identity")
+	previous, _ := server.revocations.Revoked(identity)
+
+	entry, err := server.revocations.Unrevoke(identity)
+	if err != nil {
+		server.sendError(w, r, err, "Failed to unrevoke identity")
+		return
+	}
+	server.publishRevocation(entry)
+
+	server.recordAudit(AuditAdminUnrevoke, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"identity":   entry.Identity,
+		"reason":     previous.Reason,
+		"issuer":     previous.Issuer,
+		"revoked_at": previous.IssuedAt,
+	})
+	server.logger.Info("identity unrevoked", "identity", entry.Identity)
+
+	server.writeRevocation(w, http.StatusOK, entry)
+}
+
+// publishRevocation pushes an entry this agent issued to connected peers
+func (server *Server) publishRevocation(entry revocation.Entry) {
+	if server.revocationGossip == nil {
+		return
+	}
+	go server.revocationGossip.Publish(context.Background(), entry)
+}
+
+// writeRevocation encodes a revocation response
+func (server *Server) writeRevocation(w http.ResponseWriter, status int, entry revocation.Entry) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		server.logger.Error("failed to encode revocation", "error", err)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/compute"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/revocation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_revocations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	agentKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	registry, err := revocation.New(agentKey, nil, "", logger)
+	require.NoError(t, err)
+	server.SetRevocations(registry, nil)
+
+	router := chi.NewRouter()
+	router.Post("/revocations", server.handleRevokeIdentity)
+	router.Get("/revocations", server.handleListRevocations)
+	router.Delete("/revocations/{identity}", server.handleUnrevokeIdentity)
+	router.With(server.verifySignatureMiddleware).Post("/leases", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	spenderKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	spender, err := peer.IDFromPrivateKey(spenderKey)
+	require.NoError(t, err)
+	propose := func(address string) int {
+		body := []byte(`{"productId":"p"}`)
+		req := httptest.NewRequest(http.MethodPost, "/leases", bytes.NewReader(body))
+		req.Header.Set("X-Pandacea-Spender-Address", address)
+		require.NoError(t, reqsig.Sign(spenderKey, req, body, time.Now()))
+		return serve(req).Code
+	}
+	const address = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+	require.Equal(t, http.StatusNoContent, propose(address))
+
+	w := serve(httptest.NewRequest(http.MethodPost, "/revocations", strings.NewReader(`{"identity":"nobody","reason":"leaked"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(httptest.NewRequest(http.MethodPost, "/revocations", strings.NewReader(`{"identity":"`+spender.String()+`","reason":"key leaked"}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var entry revocation.Entry
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&entry))
+	assert.True(t, entry.Revoked)
+	assert.NoError(t, entry.Verify())
+
+	// A valid signature from the revoked key is refused
+	assert.Equal(t, http.StatusForbidden, propose(address))
+
+	// Revoked spender addresses are refused over libp2p too, where the
+	// HTTP middleware does not run
+	w = serve(httptest.NewRequest(http.MethodDelete, "/revocations/"+spender.String(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusNoContent, propose(address))
+	w = serve(httptest.NewRequest(http.MethodPost, "/revocations", strings.NewReader(`{"identity":"`+address+`","reason":"wallet drained"}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, propose(strings.ToLower(address)))
+	_, err = server.QueueComputation(context.Background(), spender, compute.Request{
+		Computation:    &privacy.ComputationRequest{LeaseID: "lease-1"},
+		SpenderAddress: address,
+	})
+	var refused *compute.Error
+	require.True(t, errors.As(err, &refused), "error = %v", err)
+	assert.Equal(t, ErrorCodeRevoked, refused.Code)
+
+	w = serve(httptest.NewRequest(http.MethodGet, "/revocations", nil))
+	var listed RevocationsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Len(t, listed.Data, 1)
+	assert.Equal(t, strings.ToLower(address), listed.Data[0].Identity)
+
+	w = serve(httptest.NewRequest(http.MethodDelete, "/revocations/"+spender.String(), nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "the peer ID is no longer revoked")
+}
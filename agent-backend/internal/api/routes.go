@@ -0,0 +1,468 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/models"
+	"pandacea/agent-backend/internal/openapi"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/pricing"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/revocation"
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/spender"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// adminPrefix is where operator routes are mounted under /api/v1
+const adminPrefix = "/admin/security"
+
+// route describes an /api/v1 endpoint. The same table mounts the handlers
+// and generates the OpenAPI document, so the two cannot drift apart.
+type route struct {
+	method  string
+	pattern string // chi pattern relative to /api/v1
+	handler http.HandlerFunc
+
+	operationID string
+	summary     string
+	tag         string
+	// wildcard names the path parameter a trailing * stands for, and
+	// suffix is a fixed path the handler expects the parameter to end with
+	wildcard string
+	suffix   string
+	query    []openapi.Parameter
+	request  any // decoded request body, nil if the route takes none
+	status   int // success status
+	response any // encoded success body, nil if the route sends none
+	// stream is the media type of a response that streams response values,
+	// such as text/event-stream
+	stream string
+	// binary is the media type of a response of raw bytes
+	binary string
+}
+
+// eventsQuery are the query parameters shared by the paged event endpoints
+var eventsQuery = []openapi.Parameter{
+	queryParam("cursor", "nextCursor from the previous page; resumes immediately after it"),
+	queryParam("since", "RFC 3339 timestamp to start from when no cursor is held"),
+	queryParam("type", "Only return events of this type"),
+	{Name: "limit", In: "query", Description: "Page size, 1-500 (default 500)", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+// queryParam describes an optional string query parameter
+func queryParam(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+// routes returns every /api/v1 endpoint. Routes under adminPrefix are
+// restricted to admin peer IDs.
+func (server *Server) routes() []route {
+	return []route{
+		{method: "POST", pattern: "/auth/challenge", handler: server.handleAuthChallenge,
+			operationID: "createAuthChallenge", summary: "Create an authentication challenge", tag: "auth",
+			request: AuthChallengeRequest{}, status: http.StatusCreated, response: AuthChallengeResponse{}},
+		{method: "POST", pattern: "/auth/verify", handler: server.handleAuthVerify,
+			operationID: "verifyAuthChallenge", summary: "Verify a signed authentication challenge", tag: "auth",
+			request: AuthVerifyRequest{}, status: http.StatusOK, response: AuthVerifyResponse{}},
+		{method: "GET", pattern: "/version", handler: server.handleGetVersion,
+			operationID: "getVersion", summary: "Get the API version and deployment", tag: "meta",
+			status: http.StatusOK, response: VersionResponse{}},
+		{method: "GET", pattern: "/p2p/status", handler: server.handleGetP2PStatus,
+			operationID: "getP2PStatus", summary: "Get the P2P node's NAT reachability, relay addresses and bootstrap connections", tag: "meta",
+			status: http.StatusOK, response: p2p.Status{}},
+		{method: "GET", pattern: "/p2p/peers", handler: server.adminOnly(http.HandlerFunc(server.handleListConnectedPeers)).ServeHTTP,
+			operationID: "listConnectedPeers", summary: "List connected P2P peers with agent version, ping latency, direction and protocols", tag: "meta",
+			status: http.StatusOK, response: ConnectedPeersResponse{}},
+		{method: "POST", pattern: "/p2p/peers", handler: server.adminOnly(http.HandlerFunc(server.handleConnectPeer)).ServeHTTP,
+			operationID: "connectPeer", summary: "Connect the P2P node to a peer multiaddr", tag: "meta",
+			request: ConnectPeerRequest{}, status: http.StatusOK, response: p2p.ConnectedPeer{}},
+		{method: "GET", pattern: "/products", handler: server.handleGetProducts,
+			operationID: "listProducts", summary: "List available data products", tag: "products",
+			query: []openapi.Parameter{
+				queryParam("keyword", "Only products tagged with this keyword, ignoring case"),
+				queryParam("dataType", "Only products of this data type, ignoring case"),
+			},
+			status: http.StatusOK, response: ProductsResponse{}},
+		{method: "DELETE", pattern: "/products/*", handler: server.adminOnly(http.HandlerFunc(server.handleEraseProductData)).ServeHTTP, wildcard: "productId", suffix: "/data",
+			operationID: "eraseProductData", summary: "Erase a product's data and everything derived from it, and report the leases and jobs affected", tag: "products",
+			status: http.StatusOK, response: ErasureReport{}},
+		{method: "GET", pattern: "/products/*", handler: server.handleGetProductProfile, wildcard: "productId", suffix: "/profile",
+			operationID: "getProductProfile", summary: "Get column statistics of a product's data assets to assess their quality before leasing", tag: "products",
+			status: http.StatusOK, response: ProductProfileResponse{}},
+		{method: "GET", pattern: "/network/products", handler: server.handleSearchNetworkProducts,
+			operationID: "searchNetworkProducts", summary: "Search products offered across the network, ranked by price or reputation", tag: "products",
+			query: []openapi.Parameter{
+				queryParam("q", "Text matched against product names, IDs and keywords"),
+				queryParam("dataType", "Only products of this data type"),
+				queryParam("sort", "price (default) or reputation"),
+			},
+			status: http.StatusOK, response: market.Result{}},
+		{method: "POST", pattern: "/leases", handler: server.handleCreateLease,
+			operationID: "createLease", summary: "Propose a lease", tag: "leases",
+			request: LeaseRequest{}, status: http.StatusAccepted, response: LeaseResponse{}},
+		{method: "GET", pattern: "/leases/{leaseProposalId}", handler: server.handleGetLeaseStatus,
+			operationID: "getLeaseStatus", summary: "Get a lease proposal's status", tag: "leases",
+			status: http.StatusOK, response: LeaseProposalState{}},
+		{method: "GET", pattern: "/pricing/*", handler: server.handleGetPricing, wildcard: "productId",
+			operationID: "getPricing", summary: "Get a product's effective minimum price", tag: "leases",
+			status: http.StatusOK, response: pricing.Quote{}},
+		{method: "POST", pattern: "/leases/{leaseId}/dispute", handler: server.handleRaiseDispute,
+			operationID: "raiseDispute", summary: "Raise a dispute against a lease", tag: "leases",
+			request: DisputeRequest{}, status: http.StatusCreated, response: DisputeResponse{}},
+		{method: "GET", pattern: "/leases/{leaseId}/disputes", handler: server.handleGetLeaseDisputes,
+			operationID: "getLeaseDisputes", summary: "List a lease's dispute records with their evidence, for its spender or an admin", tag: "leases",
+			status: http.StatusOK, response: DisputesResponse{}},
+		{method: "GET", pattern: "/disputes", handler: server.adminOnly(http.HandlerFunc(server.handleListDisputes)).ServeHTTP,
+			operationID: "listDisputes", summary: "List disputes, newest first, as tracked from the agent and chain events; admin only", tag: "leases",
+			query: []openapi.Parameter{
+				queryParam("status", "Only disputes in this status: pending, raised, upheld or rejected"),
+				queryParam("lease", "Only disputes on this lease ID"),
+				queryParam("network", "Only disputes whose events came from this network"),
+			},
+			status: http.StatusOK, response: DisputesResponse{}},
+		{method: "GET", pattern: "/disputes/{disputeId}", handler: server.handleGetDispute,
+			operationID: "getDispute", summary: "Get a dispute record with its evidence bundle CID and manifest, for the lease's spender or an admin", tag: "leases",
+			status: http.StatusOK, response: dispute.Record{}},
+		{method: "POST", pattern: "/leases/{leaseId}/transfer", handler: server.handleTransferLease,
+			operationID: "transferLease", summary: "Assign an active lease to a new holder", tag: "leases",
+			request: LeaseTransferRequest{}, status: http.StatusCreated, response: privacy.Assignment{}},
+		{method: "POST", pattern: "/leases/{leaseId}/approve", handler: server.adminOnly(http.HandlerFunc(server.handleApproveLease)).ServeHTTP,
+			operationID: "approveLease", summary: "Send a transaction approving a lease as its earner", tag: "transactions",
+			status: http.StatusAccepted, response: txmgr.Record{}},
+		{method: "POST", pattern: "/leases/{leaseId}/execute", handler: server.handleExecuteLease,
+			operationID: "executeLease", summary: "Execute an approved lease and deliver its data product to the spender", tag: "transactions",
+			status: http.StatusOK, response: delivery.Delivery{}},
+		{method: "POST", pattern: "/leases/{leaseId}/finalize", handler: server.adminOnly(http.HandlerFunc(server.handleFinalizeLease)).ServeHTTP,
+			operationID: "finalizeLease", summary: "Send a transaction finalizing an executed lease after its dispute window", tag: "transactions",
+			status: http.StatusAccepted, response: txmgr.Record{}},
+		{method: "GET", pattern: "/transactions", handler: server.adminOnly(http.HandlerFunc(server.handleListTransactions)).ServeHTTP,
+			operationID: "listTransactions", summary: "List transactions the agent sent, newest first", tag: "transactions",
+			query: []openapi.Parameter{
+				queryParam("action", "Only transactions sent for this action, e.g. lease.approve"),
+				queryParam("reference", "Only transactions for this lease ID"),
+				queryParam("status", "Only transactions with this status: queued, pending, confirmed, reverted or failed"),
+			},
+			status: http.StatusOK, response: TransactionsResponse{}},
+		{method: "GET", pattern: "/transactions/{txId}", handler: server.adminOnly(http.HandlerFunc(server.handleGetTransaction)).ServeHTTP,
+			operationID: "getTransaction", summary: "Get a transaction's status and receipt", tag: "transactions",
+			status: http.StatusOK, response: txmgr.Record{}},
+		{method: "POST", pattern: "/outbound/leases", handler: server.adminOnly(http.HandlerFunc(server.handleProposeOutboundLease)).ServeHTTP,
+			operationID: "proposeOutboundLease", summary: "Lease a product from another agent: propose the lease and pay for it with createLease", tag: "outbound",
+			request: spender.Request{}, status: http.StatusAccepted, response: spender.Proposal{}},
+		{method: "GET", pattern: "/outbound/leases", handler: server.adminOnly(http.HandlerFunc(server.handleListOutboundLeases)).ServeHTTP,
+			operationID: "listOutboundLeases", summary: "List leases proposed to other agents, newest first", tag: "outbound",
+			query: []openapi.Parameter{
+				queryParam("status", "Only leases with this status: rejected, submitted, created, approved, expired or failed"),
+				queryParam("product", "Only leases of this product"),
+			},
+			status: http.StatusOK, response: OutboundLeasesResponse{}},
+		{method: "GET", pattern: "/outbound/leases/{outboundId}", handler: server.adminOnly(http.HandlerFunc(server.handleGetOutboundLease)).ServeHTTP,
+			operationID: "getOutboundLease", summary: "Get a lease proposed to another agent", tag: "outbound",
+			status: http.StatusOK, response: spender.Proposal{}},
+		{method: "GET", pattern: "/pins", handler: server.adminOnly(http.HandlerFunc(server.handleListPins)).ServeHTTP,
+			operationID: "listPins", summary: "List training artifacts and computation results published to IPFS, newest first", tag: "privacy",
+			query: []openapi.Parameter{
+				queryParam("kind", "Only pins of this kind: training or computation"),
+				queryParam("status", "Only pins with this status: queued, pinning, pinned or failed"),
+			},
+			status: http.StatusOK, response: PinsResponse{}},
+		{method: "GET", pattern: "/models", handler: server.adminOnly(http.HandlerFunc(server.handleListModels)).ServeHTTP,
+			operationID: "listModels", summary: "List the models completed training jobs produced, newest first", tag: "training",
+			query: []openapi.Parameter{
+				queryParam("name", "Only versions of this model, named <dataset>/<task>"),
+				queryParam("dataset", "Only models trained on this dataset"),
+				queryParam("stage", "Only models in this stage: staging, production or archived"),
+				queryParam("tag", "Only models with this tag"),
+			},
+			status: http.StatusOK, response: ModelsResponse{}},
+		{method: "GET", pattern: "/models/{modelId}", handler: server.adminOnly(http.HandlerFunc(server.handleGetModel)).ServeHTTP,
+			operationID: "getModel", summary: "Get a registered model with its metrics and lineage", tag: "training",
+			status: http.StatusOK, response: models.Model{}},
+		{method: "POST", pattern: "/models/{modelId}/promote", handler: server.adminOnly(http.HandlerFunc(server.handlePromoteModel)).ServeHTTP,
+			operationID: "promoteModel", summary: "Move a model to staging or production, archiving the version it replaces", tag: "training",
+			request: PromoteModelRequest{}, status: http.StatusOK, response: models.Model{}},
+		{method: "POST", pattern: "/models/{modelId}/tags", handler: server.adminOnly(http.HandlerFunc(server.handleTagModel)).ServeHTTP,
+			operationID: "tagModel", summary: "Add and remove a model's tags", tag: "training",
+			request: TagModelRequest{}, status: http.StatusOK, response: models.Model{}},
+		{method: "GET", pattern: "/lineage/{kind}/*", handler: server.adminOnly(http.HandlerFunc(server.handleGetLineage)).ServeHTTP, wildcard: "id",
+			operationID: "getLineage", summary: "Get what a product, asset, lease, computation, job or model was derived from, or what was derived from it", tag: "admin",
+			query: []openapi.Parameter{
+				queryParam("direction", "upstream (default) for what the node was derived from, downstream for what was derived from it"),
+			},
+			status: http.StatusOK, response: LineageResponse{}},
+		{method: "GET", pattern: "/leases/{leaseId}/assignments", handler: server.handleGetLeaseAssignments,
+			operationID: "getLeaseAssignments", summary: "List a lease's assignments", tag: "leases",
+			status: http.StatusOK, response: LeaseAssignmentsResponse{}},
+		{method: "POST", pattern: "/privacy/execute", handler: server.handleExecuteComputation,
+			operationID: "executeComputation", summary: "Queue a privacy-preserving computation", tag: "privacy",
+			request: privacy.ComputationRequest{}, status: http.StatusAccepted, response: privacy.ComputationResponse{}},
+		{method: "GET", pattern: "/assets/{assetId}/preview", handler: server.handleGetAssetPreview,
+			operationID: "getAssetPreview", summary: "Get a sample of a data asset to inspect before leasing", tag: "products",
+			status: http.StatusOK, response: assets.Preview{}},
+		{method: "GET", pattern: "/privacy/results/{computation_id}", handler: server.handleGetComputationResult,
+			operationID: "getComputationResult", summary: "Get a computation's result", tag: "privacy",
+			status: http.StatusOK, response: privacy.ComputationResult{}},
+		{method: "GET", pattern: "/privacy/results/{computation_id}/artifacts/*", handler: server.handleGetResultArtifact, wildcard: "name",
+			operationID: "getComputationArtifact", summary: "Download one artifact of a computation's result as raw bytes, resumable with Range", tag: "privacy",
+			status: http.StatusOK, binary: "application/octet-stream"},
+		{method: "GET", pattern: "/privacy/results/{computation_id}/attestation", handler: server.handleGetAttestation,
+			operationID: "getComputationAttestation", summary: "Get the signed statement of what a computation ran", tag: "privacy",
+			status: http.StatusOK, response: AttestationResponse{}},
+		{method: "POST", pattern: "/attestations/verify", handler: server.handleVerifyAttestation,
+			operationID: "verifyAttestation", summary: "Check which agent signed a computation attestation", tag: "privacy",
+			request: AttestationVerifyRequest{}, status: http.StatusOK, response: AttestationVerifyResponse{}},
+		{method: "GET", pattern: "/privacy/budget/{dataset}", handler: server.handleGetPrivacyBudget,
+			operationID: "getPrivacyBudget", summary: "Get a dataset's remaining differential privacy budget", tag: "privacy",
+			status: http.StatusOK, response: privacy.Budget{}},
+		{method: "GET", pattern: "/earnings", handler: server.adminOnly(http.HandlerFunc(server.handleGetEarnings)).ServeHTTP,
+			operationID: "getEarnings", summary: "Get payouts from executed leases, by product and period", tag: "earnings",
+			query: []openapi.Parameter{
+				queryParam("from", "RFC 3339 timestamp; only leases executed at or after it"),
+				queryParam("to", "RFC 3339 timestamp; only leases executed before it"),
+				queryParam("product", "Only this product's payouts"),
+				queryParam("interval", "Also total payouts per hour, day, week or month"),
+				queryParam("tenant", "Only payouts to this tenant's earner address"),
+			},
+			status: http.StatusOK, response: EarningsResponse{}},
+		{method: "GET", pattern: "/leases/{leaseId}/metering", handler: server.adminOnly(http.HandlerFunc(server.handleGetLeaseMetering)).ServeHTTP,
+			operationID: "getLeaseMetering", summary: "Reconcile the metered cost of a lease's jobs against its price", tag: "earnings",
+			status: http.StatusOK, response: metering.Reconciliation{}},
+		{method: "GET", pattern: "/usage", handler: server.handleGetUsage,
+			operationID: "getUsage", summary: "Get the caller's daily and monthly usage and where it stands against its quotas", tag: "meta",
+			query: []openapi.Parameter{
+				{Name: "days", In: "query", Description: "Daily rollups to return, newest first (default 30)", Schema: &openapi.Schema{Type: "integer"}},
+				{Name: "months", In: "query", Description: "Monthly rollups to return, newest first (default 12)", Schema: &openapi.Schema{Type: "integer"}},
+				queryParam("identity", "Another identity's usage; admins only"),
+			},
+			status: http.StatusOK, response: UsageResponse{}},
+		{method: "POST", pattern: "/train", handler: server.handleTrain,
+			operationID: "createTrainingJob", summary: "Queue a training job", tag: "training",
+			request: TrainRequest{}, status: http.StatusAccepted, response: TrainResponse{}},
+		{method: "POST", pattern: "/federation", handler: server.handleCreateFederation,
+			operationID: "createFederatedJob", summary: "Queue a federated training job across earner agents", tag: "training",
+			request: FederationRequest{}, status: http.StatusAccepted, response: TrainResponse{}},
+		{method: "GET", pattern: "/federation/participants", handler: server.handleListParticipants,
+			operationID: "listFederationParticipants", summary: "List the earner agents announcing themselves for federated training", tag: "training",
+			status: http.StatusOK, response: ParticipantsResponse{}},
+		{method: "POST", pattern: "/train/{jobId}/resume", handler: server.handleResumeTraining,
+			operationID: "resumeTrainingJob", summary: "Resume a failed training job from its last checkpoint", tag: "training",
+			status: http.StatusAccepted, response: ResumeResponse{}},
+		{method: "GET", pattern: "/train/{jobId}/logs", handler: server.handleTrainingLogs,
+			operationID: "getTrainingLogs", summary: "Get or follow a training job's worker output", tag: "training",
+			status: http.StatusOK, response: JobLogsResponse{}},
+		{method: "GET", pattern: "/aggregate/{jobId}", handler: server.handleAggregate,
+			operationID: "getTrainingJob", summary: "Get a training job's status and results", tag: "training",
+			status: http.StatusOK, response: TrainingJob{}},
+		{method: "POST", pattern: "/artifacts/verify", handler: server.handleVerifyArtifact,
+			operationID: "verifyArtifact", summary: "Check which agent signed a training artifact", tag: "training",
+			request: ArtifactVerifyRequest{}, status: http.StatusOK, response: ArtifactVerifyResponse{}},
+		{method: "GET", pattern: "/events", handler: server.handleGetChainEvents,
+			operationID: "listChainEvents", summary: "Page through indexed chain events", tag: "events",
+			query: append(eventsQuery[:len(eventsQuery):len(eventsQuery)],
+				openapi.Parameter{Name: "from_block", In: "query", Description: "Skip events from earlier blocks", Schema: &openapi.Schema{Type: "integer", Format: "int64"}}),
+			status: http.StatusOK, response: EventsResponse{}},
+		{method: "GET", pattern: "/events/stream", handler: server.handleStreamEvents,
+			operationID: "streamStatusEvents", summary: "Stream the caller's lease, job and computation status events", tag: "events",
+			query: []openapi.Parameter{
+				queryParam("type", "Comma-separated event types to receive"),
+				queryParam("cursor", "Resume after this event ID; Last-Event-ID takes precedence"),
+			},
+			status: http.StatusOK, response: audit.Event{}, stream: "text/event-stream"},
+
+		{method: "GET", pattern: adminPrefix, handler: server.handleGetSecurityState,
+			operationID: "getSecurityState", summary: "Get rate limit, block list and quota state", tag: "admin",
+			status: http.StatusOK, response: security.SecuritySnapshot{}},
+		{method: "POST", pattern: adminPrefix + "/bans", handler: server.handleBanIP,
+			operationID: "banIP", summary: "Ban an IP address", tag: "admin",
+			request: BanRequest{}, status: http.StatusCreated, response: BanResponse{}},
+		{method: "DELETE", pattern: adminPrefix + "/bans/{ip}", handler: server.handleUnbanIP,
+			operationID: "unbanIP", summary: "Lift a ban", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "DELETE", pattern: adminPrefix + "/greylist/{ip}", handler: server.handleUngreylistIP,
+			operationID: "ungreylistIP", summary: "Remove an IP from the greylist", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "POST", pattern: adminPrefix + "/quarantine", handler: server.handleQuarantineProduct,
+			operationID: "quarantineProduct", summary: "Quarantine a product, refusing new leases, computations and training on it", tag: "admin",
+			request: QuarantineRequest{}, status: http.StatusCreated, response: Quarantine{}},
+		{method: "GET", pattern: adminPrefix + "/quarantine", handler: server.handleListQuarantines,
+			operationID: "listQuarantines", summary: "List quarantined products", tag: "admin",
+			status: http.StatusOK, response: QuarantinesResponse{}},
+		{method: "DELETE", pattern: adminPrefix + "/quarantine/*", handler: server.handleLiftQuarantine, wildcard: "productId",
+			operationID: "liftQuarantine", summary: "Lift a product quarantine", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "POST", pattern: adminPrefix + "/revocations", handler: server.handleRevokeIdentity,
+			operationID: "revokeIdentity", summary: "Revoke a peer ID or spender address and gossip the revocation to peers", tag: "admin",
+			request: RevokeRequest{}, status: http.StatusCreated, response: revocation.Entry{}},
+		{method: "GET", pattern: adminPrefix + "/revocations", handler: server.handleListRevocations,
+			operationID: "listRevocations", summary: "List revoked identities, including ones gossiped by trusted agents", tag: "admin",
+			status: http.StatusOK, response: RevocationsResponse{}},
+		{method: "DELETE", pattern: adminPrefix + "/revocations/{identity}", handler: server.handleUnrevokeIdentity,
+			operationID: "unrevokeIdentity", summary: "Lift the revocation of a peer ID or spender address", tag: "admin",
+			status: http.StatusOK, response: revocation.Entry{}},
+		{method: "POST", pattern: adminPrefix + "/assets", handler: server.handleRegisterAsset,
+			operationID: "registerAsset", summary: "Register the file behind a data product after checking it against its metadata", tag: "admin",
+			request: assets.Asset{}, status: http.StatusCreated, response: assets.Asset{}},
+		{method: "GET", pattern: adminPrefix + "/assets", handler: server.handleListAssets,
+			operationID: "listAssets", summary: "List registered data assets", tag: "admin",
+			query:  []openapi.Parameter{queryParam("product", "Only this product's assets")},
+			status: http.StatusOK, response: AssetsResponse{}},
+		{method: "DELETE", pattern: adminPrefix + "/assets/{assetId}", handler: server.handleRemoveAsset,
+			operationID: "removeAsset", summary: "Remove a data asset from the registry", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "GET", pattern: adminPrefix + "/audit/events", handler: server.handleGetAuditEvents,
+			operationID: "listAuditEvents", summary: "Page through the audit log", tag: "admin",
+			query: eventsQuery, status: http.StatusOK, response: EventsResponse{}},
+		{method: "GET", pattern: adminPrefix + "/audit/export", handler: server.handleExportAuditJournal,
+			operationID: "exportAuditJournal", summary: "Export the hash-chained audit journal as NDJSON", tag: "admin",
+			status: http.StatusOK, response: audit.Record{}, stream: "application/x-ndjson"},
+		{method: "GET", pattern: adminPrefix + "/audit/verify", handler: server.handleVerifyAuditJournal,
+			operationID: "verifyAuditJournal", summary: "Check the audit journal's hash chain", tag: "admin",
+			status: http.StatusOK, response: AuditVerifyResponse{}},
+		{method: "GET", pattern: adminPrefix + "/peers", handler: server.handleListPeers,
+			operationID: "listPeers", summary: "List P2P peers with their reputation and allow or deny listing", tag: "admin",
+			status: http.StatusOK, response: PeersResponse{}},
+		{method: "PUT", pattern: adminPrefix + "/peers/{peerId}", handler: server.handleSetPeerList,
+			operationID: "setPeerList", summary: "Put a P2P peer on the allow or deny list", tag: "admin",
+			request: PeerListRequest{}, status: http.StatusOK, response: p2p.PeerState{}},
+		{method: "DELETE", pattern: adminPrefix + "/peers/{peerId}", handler: server.handleForgetPeer,
+			operationID: "forgetPeer", summary: "Take a P2P peer off both lists and clear its reputation", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "GET", pattern: adminPrefix + "/runtime", handler: server.handleGetRuntime,
+			operationID: "getRuntime", summary: "Get job scheduler load and the container pool's load forecast", tag: "admin",
+			status: http.StatusOK, response: RuntimeResponse{}},
+		{method: "DELETE", pattern: adminPrefix + "/quotas", handler: server.handleResetQuotas,
+			operationID: "resetAllQuotas", summary: "Reset every identity's quotas", tag: "admin",
+			status: http.StatusNoContent},
+		{method: "DELETE", pattern: adminPrefix + "/quotas/{identity}", handler: server.handleResetQuotas,
+			operationID: "resetQuotas", summary: "Reset one identity's quotas", tag: "admin",
+			status: http.StatusNoContent},
+	}
+}
+
+// mountRoutes registers the route table on the /api/v1 router
+func (server *Server) mountRoutes(r chi.Router) {
+	var admin []route
+	for _, rt := range server.routes() {
+		if strings.HasPrefix(rt.pattern, adminPrefix) {
+			admin = append(admin, rt)
+			continue
+		}
+		r.Method(rt.method, rt.pattern, rt.handler)
+	}
+
+	// Operator endpoints, restricted to admin peer IDs
+	r.Route(adminPrefix, func(r chi.Router) {
+		r.Use(server.adminOnly)
+		for _, rt := range admin {
+			pattern := strings.TrimPrefix(rt.pattern, adminPrefix)
+			if pattern == "" {
+				pattern = "/"
+			}
+			r.Method(rt.method, pattern, rt.handler)
+		}
+	})
+}
+
+// pathParamPattern matches chi path parameters such as {jobId}
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIDocument builds the OpenAPI document for the route table
+func (server *Server) openAPIDocument() *openapi.Document {
+	gen := openapi.NewGenerator()
+	errorSchema := gen.SchemaFor(ErrorResponse{})
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Pandacea Agent API",
+			Version:     "v1",
+			Description: "Earner agent API. Requests are signed with the caller's libp2p key; see the README for the canonical request format.",
+		},
+		Servers: []openapi.Server{{URL: "/api/v1"}},
+		Paths:   make(map[string]*openapi.PathItem),
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"peerId":    {Type: "apiKey", In: "header", Name: reqsig.HeaderPeerID, Description: "Caller's libp2p peer ID"},
+				"signature": {Type: "apiKey", In: "header", Name: reqsig.HeaderSignature, Description: "Base64 signature of the canonical request"},
+			},
+		},
+		Security: []map[string][]string{{"peerId": {}, "signature": {}}},
+	}
+
+	for _, rt := range server.routes() {
+		path := rt.pattern
+		if rt.wildcard != "" {
+			path = strings.TrimSuffix(path, "*") + "{" + rt.wildcard + "}" + rt.suffix
+		}
+
+		op := &openapi.Operation{
+			OperationID: rt.operationID,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Responses: map[string]*openapi.Response{
+				"default": {Description: "Error", Content: openapi.JSON(errorSchema)},
+			},
+		}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, rt.query...)
+		if rt.request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(gen.SchemaFor(rt.request))}
+		}
+
+		success := &openapi.Response{Description: http.StatusText(rt.status)}
+		switch {
+		case rt.stream != "":
+			success.Content = map[string]*openapi.MediaType{
+				rt.stream: {Schema: &openapi.Schema{Type: "array", Items: gen.SchemaFor(rt.response)}},
+			}
+		case rt.binary != "":
+			success.Content = map[string]*openapi.MediaType{
+				rt.binary: {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			}
+		case rt.response != nil:
+			success.Content = openapi.JSON(gen.SchemaFor(rt.response))
+		}
+		op.Responses[strconv.Itoa(rt.status)] = success
+
+		// Strip chi regexp constraints, which OpenAPI paths cannot carry
+		path = pathParamPattern.ReplaceAllString(path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(rt.method)] = op
+	}
+
+	doc.Components.Schemas = gen.Schemas()
+	return doc
+}
+
+// handleGetOpenAPI handles GET /api/v1/openapi.json. It is served without
+// signature checks so SDK generators can fetch it.
+func (server *Server) handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(server.openAPIDocument()); err != nil {
+		server.logger.Error("failed to encode OpenAPI document", "error", err)
+	}
+}
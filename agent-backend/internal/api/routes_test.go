@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/openapi"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_handleGetOpenAPI(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	// Served by the full router without a signature
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	// Every mounted /api/v1 route is documented with the same method
+	params := regexp.MustCompile(`\{[^}]*\}|\*`)
+	normalize := func(path string) string {
+		path = params.ReplaceAllString(path, "{}")
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		return path
+	}
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range *item {
+			documented[strings.ToUpper(method)+" "+normalize(path)] = true
+		}
+	}
+	mounted := 0
+	err = chi.Walk(server.router, func(method, pattern string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path, ok := strings.CutPrefix(pattern, "/api/v1")
+		if !ok || path == "/openapi.json" {
+			return nil
+		}
+		mounted++
+		key := method + " " + normalize(path)
+		if strings.HasSuffix(pattern, "*") && !documented[key] {
+			// A wildcard may be documented with the fixed path its handler
+			// expects after it
+			for documentedKey := range documented {
+				if strings.HasPrefix(documentedKey, key+"/") {
+					key = documentedKey
+					break
+				}
+			}
+		}
+		assert.True(t, documented[key], "%s %s is not in the OpenAPI document", method, pattern)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, len(documented), mounted, "document lists routes that are not mounted")
+
+	// Schemas come from the request and response structs
+	lease := doc.Components.Schemas["LeaseRequest"]
+	require.NotNil(t, lease)
+	assert.ElementsMatch(t, []string{"productId", "maxPrice", "duration"}, lease.Required)
+	pricing := (*doc.Paths["/pricing/{productId}"])["get"]
+	require.NotNil(t, pricing)
+	assert.Equal(t, "path", pricing.Parameters[0].In)
+	assert.Equal(t, "#/components/schemas/Quote", pricing.Responses["200"].Content["application/json"].Schema.Ref)
+}
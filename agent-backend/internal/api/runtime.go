@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pandacea/agent-backend/internal/autoscale"
+)
+
+// RuntimeResponse is the agent's current job capacity and load forecast
+type RuntimeResponse struct {
+	Scheduler *SchedulerRuntime `json:"scheduler,omitempty"`
+	Pool      *autoscale.Status `json:"pool,omitempty"` // Container pool size and load forecast; absent with autoscale off
+	GPU       *GPURuntime       `json:"gpu,omitempty"`  // GPU queue load and utilization; absent without GPUs
+}
+
+// SchedulerRuntime is the job scheduler's current load
+type SchedulerRuntime struct {
+	Queued  int `json:"queued"`
+	Running int `json:"running"`
+}
+
+// SetPoolAutoscaler exposes the container pool's load forecast on the
+// admin runtime endpoint
+func (server *Server) SetPoolAutoscaler(controller *autoscale.Controller) {
+	server.autoscaler = controller
+}
+
+// handleGetRuntime handles GET /api/v1/admin/security/runtime
+func (server *Server) handleGetRuntime(w http.ResponseWriter, r *http.Request) {
+	var response RuntimeResponse
+	if server.scheduler != nil {
+		queued, running := server.scheduler.Stats()
+		response.Scheduler = &SchedulerRuntime{Queued: queued, Running: running}
+	}
+	if server.autoscaler != nil {
+		status := server.autoscaler.Status()
+		response.Pool = &status
+	}
+	response.GPU = server.gpuRuntime()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode runtime state", "error", err)
+	}
+}
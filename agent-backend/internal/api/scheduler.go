@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/scheduler"
+
+	"github.com/shopspring/decimal"
+)
+
+// SetScheduler queues training jobs, federation rounds and computations on
+// s instead of starting them at once. Jobs on leases priced at or above the
+// configured thresholds get a higher priority.
+func (server *Server) SetScheduler(s *scheduler.Scheduler, cfg config.SchedulerConfig) {
+	server.scheduler = s
+	server.highPrice = parsePriorityPrice(cfg.HighPriorityPrice)
+	server.normalPrice = parsePriorityPrice(cfg.NormalPriorityPrice)
+	if jobScheduler, ok := server.privacyService.(privacy.JobScheduler); ok {
+		jobScheduler.UseScheduler(s)
+	}
+}
+
+// parsePriorityPrice parses a priority threshold; empty or invalid
+// thresholds are never met
+func parsePriorityPrice(price string) *decimal.Decimal {
+	if price == "" {
+		return nil
+	}
+	p, err := decimal.NewFromString(price)
+	if err != nil {
+		return nil
+	}
+	return &p
+}
+
+// leasePrice returns the price a lease was created at, if the agent has
+// seen it
+func (server *Server) leasePrice(leaseID string) (decimal.Decimal, bool) {
+	server.leasesMutex.RLock()
+	defer server.leasesMutex.RUnlock()
+	if state, exists := server.pendingLeases[chainLeaseProposalID(leaseID)]; exists && state.Price != nil {
+		if p, err := decimal.NewFromString(*state.Price); err == nil {
+			return p, true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// leasePriority returns the scheduling class of a job run under a lease,
+// from the price the lease was created at. Jobs without a lease run as low
+// priority.
+func (server *Server) leasePriority(leaseID string) scheduler.Priority {
+	if leaseID == "" {
+		return scheduler.PriorityLow
+	}
+	price, _ := server.leasePrice(leaseID)
+
+	switch {
+	case server.highPrice != nil && price.GreaterThanOrEqual(*server.highPrice):
+		return scheduler.PriorityHigh
+	case server.normalPrice == nil || price.GreaterThanOrEqual(*server.normalPrice):
+		return scheduler.PriorityNormal
+	default:
+		return scheduler.PriorityLow
+	}
+}
+
+// requestIdentity returns who a request queues jobs for: the caller's
+// verified peer ID. The spender address header is not signed, so keying on
+// it would let a caller take another spender's fair share.
+func requestIdentity(r *http.Request) string {
+	return r.Header.Get(reqsig.HeaderPeerID)
+}
+
+// queueTrainingJob runs a stored training job on the scheduler, or on the
+// GPU queue if it requested GPUs, or at once without one. Caller must hold
+// jobsMutex, so the job cannot start before the caller has finished
+// storing it.
+func (server *Server) queueTrainingJob(job *TrainingJob, identity string, priority scheduler.Priority, done func()) error {
+	run := func() {
+		server.runQueuedTrainingJob(job.JobID)
+		if done != nil {
+			done()
+		}
+	}
+	queue := server.jobQueue(job)
+	if queue == nil {
+		go run()
+		return nil
+	}
+	job.Priority = priority.String()
+	return queue.Submit(scheduler.Task{
+		ID:       job.JobID,
+		Identity: identity,
+		Priority: priority,
+		Run:      run,
+		Drop: func() {
+			server.updateJobStatus(job.JobID, string(TrainingStatusFailed), "", "Agent stopped before the job started")
+			if done != nil {
+				done()
+			}
+		},
+	})
+}
+
+// runQueuedTrainingJob runs a training job the scheduler has started,
+// unless it failed while it waited
+func (server *Server) runQueuedTrainingJob(jobID string) {
+	server.jobsMutex.RLock()
+	job, exists := server.jobs[jobID]
+	pending := exists && job.Status == string(TrainingStatusPending)
+	server.jobsMutex.RUnlock()
+	if pending {
+		server.runTrainingJob(jobID)
+	}
+}
+
+// queuePosition returns a pending job's place in its queue, or 0
+func (server *Server) queuePosition(jobID string) int {
+	for _, queue := range []*scheduler.Scheduler{server.scheduler, server.gpuScheduler} {
+		if queue == nil {
+			continue
+		}
+		if position, queued := queue.Position(jobID); queued {
+			return position
+		}
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+)
+
+// parseEncryptionKey decodes the base64 X25519 key a spender hands over
+// with a lease
+func parseEncryptionKey(encoded string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryptionKey must be base64")
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("encryptionKey must be a 32-byte X25519 public key")
+	}
+	return key, nil
+}
+
+// setLeaseEncryptionKey records the X25519 key a lease's computation
+// results are sealed to
+func (server *Server) setLeaseEncryptionKey(leaseProposalID, encoded string) {
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	if state, exists := server.pendingLeases[leaseProposalID]; exists {
+		state.EncryptionKey = encoded
+	}
+}
+
+// leaseEncryptionKey returns the X25519 key handed over with a lease, by
+// proposal or on-chain lease ID, or nil if its spender gave none
+func (server *Server) leaseEncryptionKey(leaseID string) *ecdh.PublicKey {
+	if leaseID == "" {
+		return nil
+	}
+	server.leasesMutex.RLock()
+	state, exists := server.pendingLeases[leaseID]
+	if !exists {
+		state, exists = server.pendingLeases[chainLeaseProposalID(leaseID)]
+	}
+	var encoded string
+	if exists {
+		encoded = state.EncryptionKey
+	}
+	server.leasesMutex.RUnlock()
+
+	if encoded == "" {
+		return nil
+	}
+	// Keys are checked when the lease is requested
+	key, _ := parseEncryptionKey(encoded)
+	return key
+}
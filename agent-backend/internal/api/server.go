@@ -4,59 +4,173 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/attest"
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/autoscale"
+	"pandacea/agent-backend/internal/buildinfo"
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/consent"
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/delivery"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/federation"
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/lineage"
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/models"
 	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/pinning"
 	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/pricing"
 	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reputation"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+	"pandacea/agent-backend/internal/revocation"
+	"pandacea/agent-backend/internal/scheduler"
 	"pandacea/agent-backend/internal/security"
-
+	"pandacea/agent-backend/internal/spender"
+	"pandacea/agent-backend/internal/telemetry"
+	"pandacea/agent-backend/internal/tenant"
+	"pandacea/agent-backend/internal/txmgr"
+	"pandacea/agent-backend/internal/usage"
+	"pandacea/agent-backend/internal/watermark"
+
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // LeaseProposalState represents the state of a lease proposal
 type LeaseProposalState struct {
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	LeaseID     *uint64   `json:"leaseId,omitempty"`
-	SpenderAddr string    `json:"spenderAddr,omitempty"`
-	EarnerAddr  string    `json:"earnerAddr,omitempty"`
-	Price       *string   `json:"price,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	LeaseID     *uint64    `json:"leaseId,omitempty"`
+	SpenderAddr string     `json:"spenderAddr,omitempty"`
+	EarnerAddr  string     `json:"earnerAddr,omitempty"`
+	Price       *string    `json:"price,omitempty"`
+	Duration    string     `json:"duration,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	ProductID   string     `json:"productId,omitempty"`
+	Tenant      string     `json:"tenant,omitempty"`
+	// EncryptionKey is the base64 X25519 key computation results under the
+	// lease are sealed to
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// Purpose and PurposeCategory are what the spender declared the data
+	// will be used for. Computations under the lease must declare the
+	// same category.
+	Purpose         string `json:"purpose,omitempty"`
+	PurposeCategory string `json:"purposeCategory,omitempty"`
+	// ConsentReceipt is issued when a lease with a purpose is approved
+	ConsentReceipt *consent.SignedReceipt `json:"consentReceipt,omitempty"`
+
+	// term is the parsed duration; the lease runs for term from approval
+	term time.Duration
+	// owner is the peer ID that proposed the lease and receives its status events
+	owner string
 }
 
+// Training job statuses as reported by the aggregate endpoint
+const (
+	TrainingStatusPending  = jobs.StatePending
+	TrainingStatusRunning  = jobs.StateRunning
+	TrainingStatusComplete = jobs.State("complete")
+	TrainingStatusFailed   = jobs.StateFailed
+)
+
+// trainingJobs governs training job status transitions
+var trainingJobs = jobs.NewMachine(jobs.Definition{
+	Kind:    "training",
+	Initial: TrainingStatusPending,
+	Transitions: map[jobs.State][]jobs.State{
+		TrainingStatusPending: {TrainingStatusRunning, TrainingStatusFailed},
+		TrainingStatusRunning: {TrainingStatusComplete, TrainingStatusFailed},
+	},
+	Timeouts: map[jobs.State]time.Duration{
+		TrainingStatusPending: 10 * time.Minute,
+		TrainingStatusRunning: 2 * time.Hour,
+	},
+	TimeoutState: TrainingStatusFailed,
+	Restartable:  []jobs.State{TrainingStatusFailed},
+})
+
 // TrainingJob represents the state of a federated learning job
 type TrainingJob struct {
-	JobID        string     `json:"job_id"`
-	Status       string     `json:"status"` // pending, running, complete, failed
-	Dataset      string     `json:"dataset"`
-	Task         string     `json:"task"`
-	Epsilon      float64    `json:"epsilon"`
-	ArtifactPath string     `json:"artifact_path,omitempty"`
-	Error        string     `json:"error,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	JobID        string              `json:"job_id"`
+	Status       string              `json:"status"` // pending, running, complete, failed
+	Dataset      string              `json:"dataset"`
+	Task         string              `json:"task"`
+	Epsilon      float64             `json:"epsilon"`
+	ArtifactPath string              `json:"artifact_path,omitempty"`
+	DPReport     *privacy.DPReport   `json:"dp_report,omitempty"`
+	Watermarked  bool                `json:"watermarked,omitempty"`   // The artifact carries the job's leak-tracing watermark
+	Integrity    *ArtifactSig        `json:"integrity,omitempty"`     // SHA-256 and agent signature of the finished artifact
+	CID          string              `json:"cid,omitempty"`           // IPFS CID the artifact is published under
+	Federation   *Federation         `json:"federation,omitempty"`    // Round progress of a federated job this agent coordinates
+	FederationID string              `json:"federation_id,omitempty"` // Set on rounds trained for another agent's federation
+	Round        int                 `json:"round,omitempty"`
+	Progress     *TrainingProgress   `json:"progress,omitempty"`       // Latest progress the worker reported
+	Checkpoint   *TrainingCheckpoint `json:"checkpoint,omitempty"`     // Checkpoint the job resumes from, or the last one a failed job left
+	Resumes      int                 `json:"resumes,omitempty"`        // Times the job was resumed after failing
+	GPU          *gpu.Allocation     `json:"gpu,omitempty"`            // GPUs the job requested and holds, with their utilization
+	Priority     string              `json:"priority,omitempty"`       // Scheduling class: low, normal or high
+	Position     int                 `json:"queue_position,omitempty"` // Place in the scheduler queue while the job waits
+	Error        string              `json:"error,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	CompletedAt  *time.Time          `json:"completed_at,omitempty"`
+	Metering     *metering.Bill      `json:"metering,omitempty"` // What the finished job used and cost, if jobs are billed
+
+	// owner is the peer ID that queued the job and receives its progress events
+	owner string
+	// model is the global model a federation round starts from
+	model []float64
+	// trace is the span of the request that queued the job, which the
+	// job's run continues
+	trace trace.SpanContext
+	// startedAt is when the job started running, for usage accounting
+	startedAt time.Time
+	// leaseID is the lease the job's bill is booked against
+	leaseID string
+	// cpuTime and peakMemory are what the job's worker process used, if
+	// it ran one
+	cpuTime    time.Duration
+	peakMemory int64
 }
 
 // Server represents the HTTP API server
 type Server struct {
 	router          *chi.Mux
-	policy          *policy.Engine
+	policy          policy.Evaluator
 	logger          *slog.Logger
-	products        []DataProduct
+	products        *ProductStore
 	p2pNode         *p2p.Node
 	pendingLeases   map[string]*LeaseProposalState
 	leasesMutex     sync.RWMutex
@@ -64,15 +178,99 @@ type Server struct {
 	securityService *security.SecurityService
 	jobs            map[string]*TrainingJob
 	jobsMutex       sync.RWMutex
+	jobStore        jobs.Store
+	computations    map[string]string
+	ownersMutex     sync.Mutex
+	auditLog        *audit.Log
+	journal         *audit.Journal
+	chainEvents     *audit.Log
+	statusEvents    *audit.Log
+	listenerStatus  []*chain.ListenerStatus
+	blockchain      config.BlockchainConfig
+	reputation      *reputation.Tracker
+	pricer          *pricing.Pricer
+	responseSigner  *respsig.Signer
+	disputes        *dispute.Store
+	evidence        *dispute.Packager
+	attestations    *attest.Store
+	anchorTo        *common.Address
+	compression     config.CompressionConfig
+	cors            config.CORSConfig
+	legacyRoutes    string
+	usage           *usage.Store
+	rates           *metering.Rates
+	keyring         *atrest.Keyring
+	retention       config.RetentionConfig
+	publisher       *pinning.Publisher
+	catalog         catalogTrust
+	publish         config.PublishConfig
+	models          *models.Registry
+	lineage         *lineage.Graph
+	consent         config.ConsentConfig
+	tenants         *tenant.Registry
+	listingURL      string
+	listingEarner   string
+	spender         *spender.Manager
+	meteringLedger  *metering.Ledger
+	httpConfig      config.HTTPConfig
+	profile         string
+	hardening       config.HardeningConfig
+	training        config.TrainingConfig
+	trainingBackend TrainingBackend
+	productsDir     string // Training artifacts, one directory per job
+	marker          *watermark.Marker
+	budgets         *privacy.BudgetLedger
+	earnings        *earnings.Ledger
+	transactions    *txmgr.Manager
+	txContract      common.Address
+	contract        contracts.Deployment
+	stakes          StakeReader
+	deliveries      *delivery.Store
+	preparer        *delivery.Preparer
+	leaseProducts   chain.LeaseProductReader
+	deliveryWait    time.Duration
+	assets          *assets.Registry
+	previews        *assets.Previewer
+	previewRate     int
+	previewBuckets  map[string]*security.TokenBucket
+	previewMutex    sync.Mutex
+	profiles        *assets.Profiler
+	quarantined     map[string]*Quarantine
+	quarantineMutex sync.RWMutex
+	quarantineFile  string
+	federated       config.FederationConfig
+	coordinator     *federation.Coordinator
+	participants    *federation.Registry
+	market          *market.Searcher
+	assignments     *privacy.AssignmentRegistry
+	scheduler       *scheduler.Scheduler
+	highPrice       *decimal.Decimal
+	normalPrice     *decimal.Decimal
+	gpuScheduler    *scheduler.Scheduler
+	gpus            *gpu.Allocator
+	gpuMonitor      *gpu.Monitor
+	gpuIndexes      []int
+	autoscaler      *autoscale.Controller
+	jobLogs         map[string]*jobLog
+	jobLogsMutex    sync.Mutex
+	httpServer      *http.Server
+	httpMutex       sync.Mutex
 	startTime       time.Time
+
+	// Revoked peer IDs and spender addresses (nil accepts every identity)
+	revocations      *revocation.Registry
+	revocationGossip *revocation.Gossip
 }
 
 // DataProduct represents a data product as per API specification
 type DataProduct struct {
-	ProductID string   `json:"productId"`
-	Name      string   `json:"name"`
-	DataType  string   `json:"dataType"`
-	Keywords  []string `json:"keywords"`
+	ProductID   string   `json:"productId"`
+	Name        string   `json:"name"`
+	DataType    string   `json:"dataType"`
+	Keywords    []string `json:"keywords"`
+	Quarantined bool     `json:"quarantined,omitempty"` // Set while an operator has the product quarantined
+	// Assets are the files behind the product, without their sources
+	Assets []assets.Asset `json:"assets,omitempty"`
 }
 
 // ProductsResponse represents the response for the products endpoint
@@ -83,9 +281,14 @@ type ProductsResponse struct {
 
 // LeaseRequest represents a lease request as per API specification
 type LeaseRequest struct {
-	ProductID string `json:"productId"`
-	MaxPrice  string `json:"maxPrice"`
-	Duration  string `json:"duration"`
+	ProductID     string `json:"productId"`
+	MaxPrice      string `json:"maxPrice"`
+	Duration      string `json:"duration"`
+	EncryptionKey string `json:"encryptionKey,omitempty"` // Base64 X25519 key to seal computation results to
+	// PurposeCategory is one of the consent purpose categories, such as
+	// research or model-training, and Purpose describes it
+	Purpose         string `json:"purpose,omitempty"`
+	PurposeCategory string `json:"purposeCategory,omitempty"`
 }
 
 // LeaseResponse represents the response for the lease endpoint
@@ -95,13 +298,19 @@ type LeaseResponse struct {
 
 // DisputeRequest represents a dispute request
 type DisputeRequest struct {
-	Reason string `json:"reason"`
+	Reason   string             `json:"reason"`
+	Evidence []dispute.Evidence `json:"evidence,omitempty"` // Pinned to IPFS in a signed bundle
 }
 
 // DisputeResponse represents the response for the dispute endpoint
 type DisputeResponse struct {
-	DisputeID string `json:"disputeId"`
-	Status    string `json:"status"`
+	DisputeID   string `json:"disputeId"`
+	Status      string `json:"status"`
+	EvidenceCID string `json:"evidenceCid,omitempty"` // Evidence bundle, if evidence was attached
+	ChainReason string `json:"chainReason,omitempty"` // Reason to pass to raiseDispute, referencing the bundle
+	// RequiredStake is the PGT stake, in wei, raiseDispute will take, when
+	// the contract reports it
+	RequiredStake string `json:"requiredStake,omitempty"`
 }
 
 // ErrorResponse represents a standardized error response as per API specification
@@ -115,12 +324,49 @@ type ErrorResponse struct {
 
 // Error codes for standardized error responses
 const (
-	ErrorCodeValidationError = "VALIDATION_ERROR"
-	ErrorCodePolicyRejection = "POLICY_REJECTION"
-	ErrorCodeUnauthorized    = "UNAUTHORIZED"
-	ErrorCodeForbidden       = "FORBIDDEN"
-	ErrorCodeInternalError   = "INTERNAL_ERROR"
-	ErrorCodeInvalidRequest  = "INVALID_REQUEST"
+	ErrorCodeValidationError   = "VALIDATION_ERROR"
+	ErrorCodePolicyRejection   = "POLICY_REJECTION"
+	ErrorCodeUnauthorized      = "UNAUTHORIZED"
+	ErrorCodeForbidden         = "FORBIDDEN"
+	ErrorCodeInternalError     = "INTERNAL_ERROR"
+	ErrorCodeInvalidRequest    = "INVALID_REQUEST"
+	ErrorCodeEntityTooLarge    = "REQUEST_ENTITY_TOO_LARGE"
+	ErrorCodeTooManyChallenges = "TOO_MANY_CHALLENGES"
+	ErrorCodeNotFound          = "NOT_FOUND"
+	ErrorCodeLeaseNotFound     = "LEASE_NOT_FOUND"
+	ErrorCodeLeaseNotApproved  = "LEASE_NOT_APPROVED"
+	ErrorCodeLeaseExecuted     = "LEASE_ALREADY_EXECUTED"
+	ErrorCodeLeaseDisputed     = "LEASE_DISPUTED"
+	ErrorCodePoolExhausted     = "POOL_EXHAUSTED"
+	ErrorCodeBudgetExceeded    = "BUDGET_EXCEEDED"
+	ErrorCodeStaleRequest      = "STALE_REQUEST"
+	ErrorCodeReplayDetected    = "REPLAY_DETECTED"
+	ErrorCodeQuarantined       = "PRODUCT_QUARANTINED"
+	ErrorCodeRevoked           = "IDENTITY_REVOKED"
+	ErrorCodeStaleAssignment   = "STALE_ASSIGNMENT"
+	ErrorCodeQueueFull         = "QUEUE_FULL"
+	ErrorCodeTooManyQueued     = "TOO_MANY_QUEUED_JOBS"
+	ErrorCodePeerUnreachable   = "PEER_UNREACHABLE"
+	ErrorCodeBackpressure      = "BACKPRESSURE"
+	ErrorCodeRateLimited       = "RATE_LIMITED"
+	ErrorCodeQuotaExceeded     = "QUOTA_EXCEEDED"
+	ErrorCodeCursorExpired     = "CURSOR_EXPIRED"
+	ErrorCodeMissingAddress    = "MISSING_ADDRESS"
+	ErrorCodeInvalidAddress    = "INVALID_ADDRESS"
+	ErrorCodeMissingFields     = "MISSING_FIELDS"
+	ErrorCodeChallengeFailed   = "CHALLENGE_CREATION_FAILED"
+	ErrorCodeMethodNotAllowed  = "METHOD_NOT_ALLOWED"
+	ErrorCodeEvidencePin       = "EVIDENCE_PIN_FAILED"
+	ErrorCodeDeliveryFailed    = "DELIVERY_FAILED"
+	ErrorCodeEndpointRetired   = "ENDPOINT_RETIRED"
+	ErrorCodeUnsupported       = "UNSUPPORTED_BY_CONTRACT"
+	ErrorCodeResultsSealed     = "RESULTS_SEALED"
+	ErrorCodeNoParticipants    = "NOT_ENOUGH_PARTICIPANTS"
+	ErrorCodeNotResumable      = "JOB_NOT_RESUMABLE"
+	ErrorCodeNoCheckpoint      = "NO_CHECKPOINT"
+	ErrorCodeGPUUnavailable    = "GPU_UNAVAILABLE"
+	ErrorCodePurposeMismatch   = "PURPOSE_MISMATCH"
+	ErrorCodeDataErased        = "DATA_ERASED"
 )
 
 // sendErrorResponse sends a standardized error response
@@ -146,16 +392,17 @@ func (server *Server) sendErrorResponse(w http.ResponseWriter, r *http.Request,
 }
 
 // NewServer creates a new API server
-func NewServer(policyEngine *policy.Engine, logger *slog.Logger, p2pNode *p2p.Node, privacyService privacy.PrivacyService, securityService *security.SecurityService) *Server {
+func NewServer(policyEngine policy.Evaluator, logger *slog.Logger, p2pNode *p2p.Node, privacyService privacy.PrivacyService, securityService *security.SecurityService) *Server {
 	router := chi.NewRouter()
 
 	// Add middleware
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
+	// Trace ahead of request logging so log lines carry the trace ID
+	router.Use(tracingMiddleware)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Timeout(60 * time.Second))
-	// Note: HTTP tracing is enabled via upstream otel propagator and logging middleware
 
 	// Add structured logging middleware with trace correlation
 	router.Use(func(next http.Handler) http.Handler {
@@ -179,13 +426,32 @@ func NewServer(policyEngine *policy.Engine, logger *slog.Logger, p2pNode *p2p.No
 		router:          router,
 		policy:          policyEngine,
 		logger:          logger,
-		products:        []DataProduct{},
+		products:        NewProductStore(nil),
 		p2pNode:         p2pNode,
 		pendingLeases:   make(map[string]*LeaseProposalState),
 		privacyService:  privacyService,
 		securityService: securityService,
 		jobs:            make(map[string]*TrainingJob),
+		jobLogs:         make(map[string]*jobLog),
+		auditLog:        audit.NewLog(audit.DefaultCapacity),
+		chainEvents:     audit.NewLog(audit.DefaultCapacity),
+		statusEvents:    audit.NewLog(audit.DefaultCapacity),
+		computations:    make(map[string]string),
+		quarantined:     make(map[string]*Quarantine),
 		startTime:       time.Now(),
+		// Match net/http's defaults until SetHTTPConfig is called
+		httpConfig:  config.HTTPConfig{EnableHTTP2: true, KeepAlive: true},
+		training:    config.TrainingConfig{ExecutionMode: config.ExecutionModeLocal},
+		productsDir: defaultProductsDir,
+	}
+	server.trainingBackend = NewTrainingBackend(server.training)
+
+	// Sign responses with the node's identity key
+	server.responseSigner = server.newResponseSigner()
+
+	// Stream computation completions to the spenders that queued them
+	if notifier, ok := privacyService.(privacy.StatusNotifier); ok {
+		notifier.OnStatusChange(server.onComputationStatus)
 	}
 
 	// Load products from JSON file
@@ -199,39 +465,38 @@ func NewServer(policyEngine *policy.Engine, logger *slog.Logger, p2pNode *p2p.No
 
 // loadProducts loads products from the products.json file
 func (server *Server) loadProducts() {
-	// Try multiple paths for products.json
-	paths := []string{
-		"products.json",
-		"../products.json",
-		"../../products.json",
-		"./products.json",
-	}
-
-	var data []byte
-	var err error
-
-	for _, path := range paths {
-		data, err = os.ReadFile(path)
-		if err == nil {
-			server.logger.Info("found products.json at", "path", path)
-			break
-		}
+	products, err := server.ReadCatalog()
+	if err != nil {
+		server.logger.Error("failed to load products.json", "error", err)
+		return
 	}
+	server.ReplaceProducts(products)
+}
 
+// SetProducts replaces the product catalog with a products.json document
+func (server *Server) SetProducts(data []byte) error {
+	products, err := parseProducts(data)
 	if err != nil {
-		server.logger.Warn("products.json not found in any expected location, starting with empty product list", "error", err)
-		return
+		return err
 	}
+	server.ReplaceProducts(products)
+	return nil
+}
+
+// ReplaceProducts replaces the product catalog
+func (server *Server) ReplaceProducts(products []DataProduct) {
+	server.products.Replace(products)
+
+	server.logger.Info("loaded products", "count", len(products))
+}
 
-	// Parse the JSON data
+// parseProducts parses a products.json document
+func parseProducts(data []byte) ([]DataProduct, error) {
 	var products []DataProduct
 	if err := json.Unmarshal(data, &products); err != nil {
-		server.logger.Error("failed to parse products.json", "error", err)
-		return
+		return nil, fmt.Errorf("failed to parse products: %w", err)
 	}
-
-	server.products = products
-	server.logger.Info("loaded products from file", "count", len(products))
+	return products, nil
 }
 
 // setupRoutes configures the API routes
@@ -239,30 +504,31 @@ func (server *Server) setupRoutes() {
 	// Add version header middleware to all responses
 	server.router.Use(server.addVersionHeader)
 
+	// Answer CORS preflights before they reach signature checks
+	server.router.Use(server.corsMiddleware)
+
+	// Enforce request body limits before any handler reads the body
+	server.router.Use(server.bodyLimitMiddleware)
+
+	// Compress outside response signing so signatures cover the plain body
+	server.router.Use(server.compressionMiddleware)
+
 	// API v1 routes with signature verification
 	server.router.Route("/api/v1", func(r chi.Router) {
-		// Add security middleware to all API routes
-		r.Use(server.securityMiddleware)
-		r.Use(server.verifySignatureMiddleware)
-
-		// Authentication endpoints (no signature required)
-		r.Post("/auth/challenge", server.handleAuthChallenge)
-		r.Post("/auth/verify", server.handleAuthVerify)
-
-		// Protected endpoints
-		r.Get("/products", server.handleGetProducts)
-		r.Post("/leases", server.handleCreateLease)
-		r.Get("/leases/{leaseProposalId}", server.handleGetLeaseStatus)
-		r.Post("/leases/{leaseId}/dispute", server.handleRaiseDispute)
-		r.Post("/privacy/execute", server.handleExecuteComputation)
-		r.Get("/privacy/results/{computation_id}", server.handleGetComputationResult)
-		r.Post("/train", server.handleTrain)
-		r.Get("/aggregate/{jobId}", server.handleAggregate)
+		server.useAPIMiddleware(r)
+		server.mountRoutes(r)
 	})
 
+	// The API description is public, so it bypasses signature checks
+	server.router.Get("/api/v1/openapi.json", server.handleGetOpenAPI)
+
 	// Legacy endpoints (deprecated, will be removed in v2)
-	server.router.Post("/train", server.handleTrainLegacy)
-	server.router.Get("/aggregate/{jobId}", server.handleAggregateLegacy)
+	server.router.Group(func(r chi.Router) {
+		r.Use(server.legacyRoutesMiddleware)
+		server.useAPIMiddleware(r)
+		r.Post("/train", server.handleTrain)
+		r.Get("/aggregate/{jobId}", server.handleAggregate)
+	})
 
 	// Health and readiness (no signature required)
 	server.router.Get("/health", server.handleHealth)   // legacy
@@ -271,6 +537,30 @@ func (server *Server) setupRoutes() {
 
 	// Metrics endpoint
 	server.router.Handle("/metrics", promhttp.Handler())
+
+	// Unknown routes get the error envelope rather than chi's plain text
+	server.router.NotFound(server.handleNotFound)
+	server.router.MethodNotAllowed(server.handleMethodNotAllowed)
+}
+
+// useAPIMiddleware adds the middleware every authenticated route goes
+// through
+func (server *Server) useAPIMiddleware(r chi.Router) {
+	// Identify callers with a client certificate before anything reads
+	// their peer ID
+	r.Use(server.clientCertMiddleware)
+
+	// Sign every response, including rejections from later middleware
+	r.Use(server.signResponseMiddleware)
+
+	// Add security middleware to all API routes
+	r.Use(server.securityMiddleware)
+	r.Use(server.verifySignatureMiddleware)
+
+	// Charge each request's cost to, and count it against, its
+	// authenticated caller
+	r.Use(server.costMiddleware)
+	r.Use(server.usageMiddleware)
 }
 
 // addVersionHeader adds the API version header to all responses
@@ -281,18 +571,62 @@ func (server *Server) addVersionHeader(next http.Handler) http.Handler {
 	})
 }
 
+// bodyLimitMiddleware rejects requests whose body exceeds the configured
+// limit. Declared lengths are checked up front; other bodies are wrapped so
+// that reading past the limit fails.
+func (server *Server) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.securityService == nil || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := server.securityService.MaxBodyBytes(r)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			server.rejectBodyTooLarge(w, r, limit)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyTooLarge returns the exceeded limit if err came from reading past it
+func bodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
+
+// rejectBodyTooLarge logs and rejects a request whose body exceeds limit bytes
+func (server *Server) rejectBodyTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	if server.securityService != nil {
+		server.securityService.LogRefusedRequest(r, r.Header.Get(reqsig.HeaderPeerID), "body_too_large")
+	}
+	server.sendErrorResponse(w, r, http.StatusRequestEntityTooLarge, ErrorCodeEntityTooLarge,
+		fmt.Sprintf("Request body exceeds the %d byte limit", limit))
+}
+
 // securityMiddleware applies security controls (rate limiting, backpressure, etc.)
 func (server *Server) securityMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip security checks for authentication endpoints
-		if r.URL.Path == "/api/v1/auth/challenge" || r.URL.Path == "/api/v1/auth/verify" {
+		if server.securityService == nil || r.URL.Path == "/api/v1/auth/challenge" || r.URL.Path == "/api/v1/auth/verify" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Extract identity from signature (simplified for now)
-		identity := ""
-		if signature := r.Header.Get("X-Signature"); signature != "" {
+		// Extract identity from the client certificate or signature (simplified for now)
+		identity, _ := clientCertIdentity(r)
+		if signature := r.Header.Get("X-Signature"); identity == "" && signature != "" {
 			// In a real implementation, you'd extract the identity from the signature
 			identity = "authenticated_user"
 		}
@@ -301,7 +635,7 @@ func (server *Server) securityMiddleware(next http.Handler) http.Handler {
 		if !server.securityService.CheckRequestQueue() {
 			server.securityService.LogRefusedRequest(r, identity, "queue_full")
 			w.Header().Set("Retry-After", "5")
-			server.sendErrorResponse(w, r, http.StatusServiceUnavailable, "QUEUE_FULL", "Service temporarily unavailable due to high load")
+			server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeQueueFull, "Service temporarily unavailable due to high load")
 			return
 		}
 		// Release queue slot when request completes
@@ -311,7 +645,7 @@ func (server *Server) securityMiddleware(next http.Handler) http.Handler {
 		if server.securityService.CheckBackpressure() {
 			server.securityService.LogRefusedRequest(r, identity, "backpressure")
 			w.Header().Set("Retry-After", "30")
-			server.sendErrorResponse(w, r, http.StatusServiceUnavailable, "BACKPRESSURE", "Service temporarily unavailable due to high load")
+			server.sendErrorResponse(w, r, http.StatusServiceUnavailable, ErrorCodeBackpressure, "Service temporarily unavailable due to high load")
 			return
 		}
 
@@ -320,15 +654,15 @@ func (server *Server) securityMiddleware(next http.Handler) http.Handler {
 		if !allowed {
 			server.securityService.LogRefusedRequest(r, identity, "rate_limited")
 			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()))
-			server.sendErrorResponse(w, r, http.StatusTooManyRequests, "RATE_LIMITED", "Rate limit exceeded")
+			server.sendErrorResponse(w, r, http.StatusTooManyRequests, ErrorCodeRateLimited, "Rate limit exceeded")
 			return
 		}
 
 		// Check concurrency quota for training endpoints
-		if r.URL.Path == "/api/v1/train" && identity != "" {
+		if (r.URL.Path == "/api/v1/train" || r.URL.Path == "/train") && identity != "" {
 			if !server.securityService.CheckConcurrencyQuota(identity) {
 				server.securityService.LogRefusedRequest(r, identity, "quota_exceeded")
-				server.sendErrorResponse(w, r, http.StatusConflict, "QUOTA_EXCEEDED", "Concurrent job limit exceeded")
+				server.sendErrorResponse(w, r, http.StatusConflict, ErrorCodeQuotaExceeded, "Concurrent job limit exceeded")
 				return
 			}
 			// Release quota when request completes
@@ -339,11 +673,24 @@ func (server *Server) securityMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// verifySignatureMiddleware verifies the cryptographic signature of incoming requests
+// verifySignatureMiddleware verifies the cryptographic signature of incoming
+// requests. v2 signatures cover the canonical request and are checked for a
+// fresh timestamp and an unused nonce; v1 signatures are accepted only when
+// the security config allows them. Callers authenticated by a client
+// certificate need no signature unless the profile requires signatures.
+// Requests from a revoked peer ID or spender address are refused either way.
 func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := clientCertIdentity(r); ok && !server.hardening.RequireSignatures {
+			if server.rejectRevoked(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Extract signature from header
-		signature := r.Header.Get("X-Pandacea-Signature")
+		signature := r.Header.Get(reqsig.HeaderSignature)
 		if signature == "" {
 			server.logger.Error("missing signature header", "path", r.URL.Path)
 			server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Missing signature header")
@@ -351,7 +698,7 @@ func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler
 		}
 
 		// Extract peer ID from header
-		peerIDStr := r.Header.Get("X-Pandacea-Peer-ID")
+		peerIDStr := r.Header.Get(reqsig.HeaderPeerID)
 		if peerIDStr == "" {
 			server.logger.Error("missing peer ID header", "path", r.URL.Path)
 			server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Missing peer ID header")
@@ -366,6 +713,23 @@ func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler
 			return
 		}
 
+		// Read the signing scheme and check the timestamp before doing any
+		// cryptographic work
+		params, err := reqsig.Parse(r.Header, time.Now(), server.signatureMaxSkew())
+		if err != nil {
+			server.logger.Warn("rejected request signature parameters", "peer_id", peerIDStr, "error", err)
+			server.sendError(w, r, err, "Invalid signature headers")
+			return
+		}
+		if params.Version == reqsig.VersionLegacy {
+			if !server.allowLegacySignatures() {
+				server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized,
+					"Signature version "+reqsig.VersionCanonical+" is required")
+				return
+			}
+			server.logger.Warn("accepted deprecated v1 request signature", "peer_id", peerIDStr, "path", r.URL.Path)
+		}
+
 		// Get the public key from the peer ID
 		// Note: In a real implementation, you would need to store/retrieve public keys
 		// associated with peer IDs. For now, we'll use a simplified approach.
@@ -379,6 +743,10 @@ func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler
 
 		// Read request body for signature verification
 		body, err := io.ReadAll(r.Body)
+		if limit, tooLarge := bodyTooLarge(err); tooLarge {
+			server.rejectBodyTooLarge(w, r, limit)
+			return
+		}
 		if err != nil {
 			server.logger.Error("failed to read request body", "error", err)
 			server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to read request body")
@@ -396,20 +764,8 @@ func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler
 			return
 		}
 
-		// Verify the signature
-		// For GET requests, we'll sign an empty string or a canonical representation
-		// For POST requests, we'll sign the request body
-		var dataToVerify []byte
-		if r.Method == "GET" {
-			// For GET requests, sign a canonical representation of the request
-			dataToVerify = []byte(fmt.Sprintf("%s %s", r.Method, r.URL.Path))
-		} else {
-			// For POST requests, sign the request body
-			dataToVerify = body
-		}
-
 		// Verify the signature using the public key
-		verified, err := pubKey.Verify(dataToVerify, signatureBytes)
+		verified, err := pubKey.Verify(reqsig.SignedData(r, body, params), signatureBytes)
 		if err != nil {
 			server.logger.Error("signature verification failed", "error", err, "peer_id", peerIDStr)
 			server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Signature verification failed")
@@ -422,18 +778,92 @@ func (server *Server) verifySignatureMiddleware(next http.Handler) http.Handler
 			return
 		}
 
-		server.logger.Info("signature verified successfully", "peer_id", peerIDStr, "path", r.URL.Path)
+		// Only record the nonce once the signature proves the peer sent it,
+		// so nobody else can burn a peer's nonces
+		if params.Version == reqsig.VersionCanonical && server.securityService != nil {
+			if err := server.securityService.UseRequestNonce(r.Context(), peerIDStr, params.Nonce, params.Timestamp); err != nil {
+				server.securityService.LogRefusedRequest(r, peerIDStr, "replayed_nonce")
+				server.sendError(w, r, err, "Replayed request")
+				return
+			}
+		}
+
+		// A valid signature from a revoked key proves nothing
+		if server.rejectRevoked(w, r) {
+			return
+		}
+
+		server.logger.Info("signature verified successfully", "peer_id", peerIDStr, "path", r.URL.Path, "signature_version", params.Version)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// signatureMaxSkew returns how far a request timestamp may be from server time
+func (server *Server) signatureMaxSkew() time.Duration {
+	if server.securityService == nil {
+		return 5 * time.Minute
+	}
+	return server.securityService.SignatureMaxSkew()
+}
+
+// allowLegacySignatures reports whether v1 request signatures are accepted.
+// Hardened profiles never accept them, and neither does a server without a
+// security service to say otherwise.
+func (server *Server) allowLegacySignatures() bool {
+	if server.hardening.RequireSignatures || server.securityService == nil {
+		return false
+	}
+	return server.securityService.AllowLegacySignatures()
+}
+
+// SetProfile records the deployment profile and applies its security switches
+func (server *Server) SetProfile(profile string, hardening config.HardeningConfig) {
+	server.profile = profile
+	server.hardening = hardening
+}
+
+// SetTrainingConfig sets how training jobs run, selecting the backend for
+// its execution mode. Jobs run with the local PySyft worker until this is
+// called.
+func (server *Server) SetTrainingConfig(cfg config.TrainingConfig) {
+	server.training = cfg
+	server.trainingBackend = NewTrainingBackend(cfg)
+}
+
+// SetProductsDir sets where training jobs write their artifacts, in a
+// directory named after each job
+func (server *Server) SetProductsDir(dir string) {
+	server.productsDir = dir
+}
+
+// SetWatermarker enables leak-tracing watermarks. Computation results are
+// marked with their lease ID and training artifacts with their job ID.
+func (server *Server) SetWatermarker(marker *watermark.Marker) {
+	server.marker = marker
+	if watermarker, ok := server.privacyService.(privacy.ResultWatermarker); ok {
+		watermarker.WatermarkResults(marker)
+	}
+}
+
 // handleGetProducts handles GET /api/v1/products
 func (server *Server) handleGetProducts(w http.ResponseWriter, r *http.Request) {
 	server.logger.Info("products request received")
 
-	// Return products from the loaded list
+	// Return products from the loaded list, flagging quarantined ones. The
+	// store shares its slice, so flag a copy.
+	products := append([]DataProduct{}, server.products.Find(r.URL.Query().Get("keyword"), r.URL.Query().Get("dataType"))...)
+	for i := range products {
+		_, products[i].Quarantined = server.quarantine(products[i].ProductID)
+		if server.assets != nil {
+			// Assets are shared with the store, so appending must copy
+			products[i].Assets = slices.Clip(products[i].Assets)
+			for _, a := range server.assets.List(products[i].ProductID) {
+				products[i].Assets = append(products[i].Assets, a.Described())
+			}
+		}
+	}
 	response := ProductsResponse{
-		Data:       server.products,
+		Data:       products,
 		NextCursor: "cursor_def456",
 	}
 
@@ -446,7 +876,7 @@ func (server *Server) handleGetProducts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	server.logger.Info("products response sent", "count", len(server.products))
+	server.logger.Info("products response sent", "count", len(products))
 }
 
 // handleCreateLease handles POST /api/v1/leases
@@ -468,16 +898,34 @@ func (server *Server) handleCreateLease(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if server.rejectQuarantined(w, r, req.ProductID, map[string]any{"max_price": req.MaxPrice}) {
+		return
+	}
+
+	// The requester is the verified peer; the spender address header is not
+	// signed, so anyone could borrow a reputable address with it
+	requester := r.Header.Get(reqsig.HeaderPeerID)
+
 	// Call policy engine for evaluation
 	policyReq := &policy.Request{
 		ProductID: req.ProductID,
 		MaxPrice:  req.MaxPrice,
 		Duration:  req.Duration,
+		Requester: requester,
+	}
+	if server.reputation != nil {
+		score := server.reputation.ScoreOrPrior(requester)
+		policyReq.Reputation = &score
 	}
 
-	evaluation := server.policy.EvaluateRequest(r.Context(), policyReq)
+	evaluation := server.evaluatePolicy(r.Context(), policyReq)
 	if !evaluation.Allowed {
 		server.logger.Error("lease request rejected by policy", "reason", evaluation.Reason)
+		server.recordAudit(AuditLeaseRejected, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+			"product_id": req.ProductID,
+			"max_price":  req.MaxPrice,
+			"reason":     evaluation.Reason,
+		})
 		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodePolicyRejection, evaluation.Reason)
 		return
 	}
@@ -485,8 +933,33 @@ func (server *Server) handleCreateLease(w http.ResponseWriter, r *http.Request)
 	// Generate a lease proposal ID (in a real implementation, this would be more sophisticated)
 	leaseProposalID := fmt.Sprintf("lease_prop_%d", time.Now().UnixNano())
 
-	// Create initial lease state
-	server.UpdateLeaseStatus(leaseProposalID, "pending", nil, "", "", nil)
+	// Create initial lease state, within the quota of the tenant selling the product
+	if owner, ok := server.reserveLease(leaseProposalID, req.ProductID); !ok {
+		server.logger.Warn("lease request over tenant quota", "tenant", owner.ID, "max_active_leases", owner.MaxActiveLeases)
+		server.sendErrorResponse(w, r, http.StatusTooManyRequests, ErrorCodeQuotaExceeded, "Too many active leases for this product's earner")
+		return
+	}
+	server.setLeaseTerm(leaseProposalID, req.Duration)
+	server.setLeaseProduct(leaseProposalID, req.ProductID)
+	server.setLeaseOwner(leaseProposalID, r.Header.Get(reqsig.HeaderPeerID))
+	// Only accepted leases count towards demand, so requests policy rejects
+	// cannot raise the price for everyone else
+	if server.pricer != nil {
+		server.pricer.RecordLease(req.ProductID)
+	}
+	if server.reputation != nil {
+		if err := server.reputation.RecordLease(leaseProposalID, requester); err != nil {
+			server.logger.Error("failed to record lease for reputation", "error", err, "lease_proposal_id", leaseProposalID)
+		}
+	}
+	server.setLeaseEncryptionKey(leaseProposalID, req.EncryptionKey)
+	server.setLeasePurpose(leaseProposalID, req.Purpose, req.PurposeCategory)
+	server.recordAudit(AuditLeaseProposed, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"lease_proposal_id": leaseProposalID,
+		"product_id":        req.ProductID,
+		"max_price":         req.MaxPrice,
+		"purpose_category":  req.PurposeCategory,
+	})
 
 	// Return success response
 	response := LeaseResponse{
@@ -505,6 +978,9 @@ func (server *Server) handleCreateLease(w http.ResponseWriter, r *http.Request)
 	server.logger.Info("lease response sent", "lease_proposal_id", response.LeaseProposalID)
 }
 
+// productIDPattern matches did:pandacea product identifiers
+var productIDPattern = regexp.MustCompile(`^did:pandacea:[^:]+:[^/]+/[^/]+$`)
+
 // validateLeaseRequest performs strict schema-based input validation
 func (server *Server) validateLeaseRequest(req *LeaseRequest) error {
 	// Check for required fields
@@ -519,8 +995,7 @@ func (server *Server) validateLeaseRequest(req *LeaseRequest) error {
 	}
 
 	// Validate productId format (did:pandacea format)
-	didPattern := regexp.MustCompile(`^did:pandacea:[^:]+:[^/]+/[^/]+$`)
-	if !didPattern.MatchString(req.ProductID) {
+	if !productIDPattern.MatchString(req.ProductID) {
 		return fmt.Errorf("productId must conform to did:pandacea format")
 	}
 
@@ -536,6 +1011,16 @@ func (server *Server) validateLeaseRequest(req *LeaseRequest) error {
 		return fmt.Errorf("duration must be in format: <number>[d|h|m|s] (e.g., 24h, 30m)")
 	}
 
+	if req.EncryptionKey != "" {
+		if _, err := parseEncryptionKey(req.EncryptionKey); err != nil {
+			return err
+		}
+	}
+
+	if err := server.validateLeasePurpose(req.Purpose, req.PurposeCategory); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -546,6 +1031,27 @@ func (server *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// VersionResponse describes the running agent's build, API version and
+// deployment
+type VersionResponse struct {
+	buildinfo.Info
+	APIVersion    string `json:"apiVersion"`
+	Profile       string `json:"profile,omitempty"`
+	ExecutionMode string `json:"executionMode"`
+}
+
+// handleGetVersion handles GET /api/v1/version
+func (server *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VersionResponse{
+		Info:          buildinfo.Get(),
+		APIVersion:    "v1",
+		Profile:       server.profile,
+		ExecutionMode: server.training.ExecutionMode,
+	})
+}
+
 // handleHealthz is a lightweight liveness probe
 func (server *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -606,24 +1112,83 @@ func (server *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
 		checks = append(checks, check{Name: "evm_rpc", Status: "unknown", Detail: "not configured"})
 	}
 
-	// PySyft readiness (mock vs real)
-	if os.Getenv("MOCK_DP") == "1" {
-		checks = append(checks, check{Name: "pysyft", Status: "ready", Detail: "mock mode"})
-	} else if server.privacyService != nil {
-		checks = append(checks, check{Name: "pysyft", Status: "ready"})
+	// Blockchain event listeners: not ready until the first subscription is
+	// established, degraded while disconnected or lagging behind the head.
+	// With several networks each gets its own check.
+	degraded := false
+	var listenerStates []chain.ListenerState
+	for _, status := range server.listenerStatus {
+		state := status.Snapshot()
+		listenerStates = append(listenerStates, state)
+		name := "event_listener"
+		if len(server.listenerStatus) > 1 {
+			name += ":" + state.Network
+		}
+		detail := fmt.Sprintf("%s: last_block=%d head=%d lag=%d", state.State, state.LastBlock, state.HeadBlock, state.Lag)
+		switch state.State {
+		case chain.StateConnecting:
+			overallReady = false
+			checks = append(checks, check{Name: name, Status: "not_ready", Detail: detail})
+		case chain.StateDegraded:
+			degraded = true
+			checks = append(checks, check{Name: name, Status: "degraded", Detail: detail})
+		default:
+			checks = append(checks, check{Name: name, Status: "ready", Detail: detail})
+		}
+	}
+	if len(server.listenerStatus) == 0 {
+		checks = append(checks, check{Name: "event_listener", Status: "unknown", Detail: "not configured"})
+	}
+
+	// Backpressure: the agent refuses API requests while the host is under
+	// pressure, so it is not ready to take more traffic
+	var pressure *security.BackpressureState
+	if server.securityService != nil {
+		state := server.securityService.BackpressureState()
+		pressure = &state
+		if state.Active {
+			overallReady = false
+			checks = append(checks, check{Name: "backpressure", Status: "not_ready", Detail: strings.Join(state.Reasons, ", ")})
+		} else {
+			checks = append(checks, check{Name: "backpressure", Status: "ready"})
+		}
+	}
+
+	// Training backend readiness. Training is not required for the rest of
+	// the API, so an unhealthy backend degrades the agent rather than
+	// taking it out of service.
+	backend := server.trainingBackend
+	if err := backend.Health(r.Context()); err != nil {
+		degraded = true
+		checks = append(checks, check{Name: "training", Status: "degraded", Detail: backend.Name() + ": " + err.Error()})
 	} else {
-		// Not strictly required for API readiness, mark unknown
-		checks = append(checks, check{Name: "pysyft", Status: "unknown", Detail: "not configured"})
+		checks = append(checks, check{Name: "training", Status: "ready", Detail: backend.Name()})
 	}
 
+	status := "ready"
+	if degraded {
+		status = "degraded"
+	}
 	payload := map[string]any{
 		"ready":  overallReady,
 		"checks": checks,
 	}
 	code := http.StatusOK
 	if !overallReady {
+		status = "not_ready"
 		code = http.StatusServiceUnavailable
 	}
+	payload["status"] = status
+	if pressure != nil {
+		payload["backpressure"] = pressure
+	}
+	switch len(listenerStates) {
+	case 0:
+	case 1:
+		payload["event_listener"] = listenerStates[0]
+	default:
+		payload["event_listeners"] = listenerStates
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	_ = json.NewEncoder(w).Encode(payload)
@@ -637,8 +1202,13 @@ func (server *Server) handleGetLeaseStatus(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Copy the state so the expirer cannot change it while it is encoded
 	server.leasesMutex.RLock()
 	leaseState, exists := server.pendingLeases[leaseProposalID]
+	if exists {
+		snapshot := *leaseState
+		leaseState = &snapshot
+	}
 	server.leasesMutex.RUnlock()
 
 	if !exists {
@@ -670,10 +1240,22 @@ func (server *Server) UpdateLeaseStatus(leaseProposalID string, status string, l
 		}
 		if earnerAddr != "" {
 			existingState.EarnerAddr = earnerAddr
+			if existingState.Tenant == "" {
+				existingState.Tenant = server.earnerTenant(earnerAddr)
+			}
 		}
 		if price != nil {
 			existingState.Price = price
 		}
+		// The lease term starts once the lease is approved on-chain
+		if status == "approved" && existingState.term > 0 {
+			expiresAt := now.Add(existingState.term)
+			existingState.ExpiresAt = &expiresAt
+		}
+		if status == "approved" {
+			server.issueConsentReceipt(leaseProposalID, existingState, now)
+		}
+		server.publishLeaseStatus(leaseProposalID, existingState)
 	} else {
 		// Create new state
 		server.pendingLeases[leaseProposalID] = &LeaseProposalState{
@@ -684,6 +1266,7 @@ func (server *Server) UpdateLeaseStatus(leaseProposalID string, status string, l
 			SpenderAddr: spenderAddr,
 			EarnerAddr:  earnerAddr,
 			Price:       price,
+			Tenant:      server.earnerTenant(earnerAddr),
 		}
 	}
 
@@ -694,19 +1277,18 @@ func (server *Server) UpdateLeaseStatus(leaseProposalID string, status string, l
 	)
 }
 
-// Start starts the HTTP server
-func (server *Server) Start(addr string) error {
-	server.logger.Info("starting HTTP server", "addr", addr)
-	// Note: the actual otelhttp wrapping occurs in main to ensure global providers are initialized
-	return http.ListenAndServe(addr, server.router)
-}
-
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server, waiting for in-flight
+// requests until ctx is done
 func (server *Server) Shutdown(ctx context.Context) error {
 	server.logger.Info("shutting down HTTP server")
-	// For a simple server, we just return nil
-	// In a production environment, you'd want to implement proper shutdown
-	return nil
+
+	server.httpMutex.Lock()
+	hs := server.httpServer
+	server.httpMutex.Unlock()
+	if hs == nil {
+		return nil
+	}
+	return hs.Shutdown(ctx)
 }
 
 // handleExecuteComputation handles privacy-preserving computation requests
@@ -725,18 +1307,15 @@ func (server *Server) handleExecuteComputation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Verify lease is valid and authorized
-	if err := server.privacyService.VerifyLease(r.Context(), req.LeaseID, spenderAddr); err != nil {
-		server.logger.Error("lease verification failed", "error", err, "lease_id", req.LeaseID, "spender", spenderAddr)
-		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, fmt.Sprintf("Lease verification failed: %v", err))
-		return
+	for _, input := range req.Inputs {
+		if server.rejectQuarantined(w, r, server.assetProduct(input.AssetID), map[string]any{"lease_id": req.LeaseID}) {
+			return
+		}
 	}
 
-	// Start the asynchronous computation
-	response, err := server.privacyService.ExecuteComputation(r.Context(), &req)
+	response, err := server.startComputation(r.Context(), &req, spenderAddr, r.Header.Get(reqsig.HeaderPeerID), r.Header.Get(networkHeader))
 	if err != nil {
-		server.logger.Error("computation execution failed", "error", err, "lease_id", req.LeaseID)
-		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Computation execution failed")
+		server.sendError(w, r, err, "Computation execution failed")
 		return
 	}
 
@@ -748,6 +1327,126 @@ func (server *Server) handleExecuteComputation(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// startComputation verifies that spenderAddr holds the lease of req and
+// queues req for the lease's spender, which alone may read its results.
+// The spender is the peer the lease was requested by or transferred to,
+// else the verified caller peerID if the lease was not requested here. The
+// lease is verified on the leased product's pinned network, else on
+// network; naming a network other than the pin is an error.
+// Results are sealed to the X25519 key handed over with the lease if there
+// is one, otherwise, when results must be sealed, to the spender's key.
+func (server *Server) startComputation(ctx context.Context, req *privacy.ComputationRequest, spenderAddr, peerID, network string) (*privacy.ComputationResponse, error) {
+	var productID string
+	if len(req.Inputs) > 0 {
+		productID = server.assetProduct(req.Inputs[0].AssetID)
+	}
+	// Computations over libp2p skip the HTTP middleware, so revocations are
+	// checked here too
+	if err := server.checkRevoked(peerID, map[string]any{"lease_id": req.LeaseID}, peerID, spenderAddr); err != nil {
+		return nil, err
+	}
+	network, err := server.leaseNetwork(network, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.privacyService.VerifyLease(privacy.WithNetwork(ctx, network), req.LeaseID, spenderAddr); err != nil {
+		server.logger.Error("lease verification failed", "error", err, "lease_id", req.LeaseID, "spender", spenderAddr)
+		return nil, err
+	}
+
+	// Bind the results to the lease's spender. A computation nobody may
+	// read is refused rather than left open to anyone.
+	owner := server.leaseSpenderPeer(req.LeaseID)
+	if owner == "" {
+		owner = peerID
+	}
+	if owner == "" {
+		server.logger.Warn("computation refused without a spender peer", "lease_id", req.LeaseID, "spender", spenderAddr)
+		return nil, fmt.Errorf("%w: lease %s was not requested here, so sign the request to bind its results to you", errNoResultOwner, req.LeaseID)
+	}
+
+	// Queue the computation fairly against the caller's other jobs, keyed
+	// on the verified peer rather than the spender address it names
+	req.Identity = peerID
+	if req.Identity == "" {
+		req.Identity = owner
+	}
+	req.Priority = server.leasePriority(req.LeaseID)
+	req.Owner = owner
+	req.LeasePurpose = server.leasePurpose(req.LeaseID)
+
+	// Seal results to the spender so neither the operator nor whoever learns
+	// the computation ID can read them: to the X25519 key handed over with
+	// the lease if there is one, otherwise to the spender's peer key
+	if key := server.leaseEncryptionKey(req.LeaseID); key != nil {
+		req.RecipientX25519 = key
+	} else if server.hardening.SealResults {
+		pubKey, err := resultRecipient(owner)
+		if err != nil {
+			server.logger.Warn("cannot seal results to caller", "error", err, "lease_id", req.LeaseID)
+			return nil, err
+		}
+		req.Recipient = pubKey
+	}
+
+	// Record the owner before the computation is queued, so one that
+	// finishes at once still streams its completion to them
+	req.ID = privacy.NewComputationID()
+	server.setComputationOwner(req.ID, owner)
+
+	// Start the asynchronous computation
+	response, err := server.privacyService.ExecuteComputation(ctx, req)
+	if err != nil {
+		server.dropComputationOwner(req.ID)
+		server.logger.Error("computation execution failed", "error", err, "lease_id", req.LeaseID)
+		return nil, err
+	}
+
+	server.recordComputationLineage(response.ComputationID, req)
+	server.recordUsage(peerID, usage.Counters{JobsStarted: 1})
+	server.recordAudit(AuditComputationQueued, spenderAddr, map[string]any{
+		"lease_id":       req.LeaseID,
+		"computation_id": response.ComputationID,
+	})
+	return response, nil
+}
+
+// leaseSpenderPeer returns the peer ID of a lease's spender: the peer that
+// requested the lease here or the one it was last transferred to. It is
+// empty for leases not requested through this agent.
+func (server *Server) leaseSpenderPeer(leaseID string) string {
+	if leaseID == "" {
+		return ""
+	}
+	server.leasesMutex.RLock()
+	defer server.leasesMutex.RUnlock()
+	state, exists := server.pendingLeases[leaseID]
+	if !exists {
+		state, exists = server.pendingLeases[chainLeaseProposalID(leaseID)]
+	}
+	if !exists {
+		return ""
+	}
+	return state.owner
+}
+
+// resultRecipient returns the public key results are sealed to: the key
+// embedded in the spender's peer ID
+func resultRecipient(spenderPeerID string) (crypto.PubKey, error) {
+	peerID, err := peer.Decode(spenderPeerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: results are sealed to the spender's key, which needs a valid peer ID", errUnsealable)
+	}
+	pubKey, err := peerID.ExtractPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("%w: results are sealed to the spender's key, which peer ID %s does not embed", errUnsealable, peerID)
+	}
+	if pubKey.Type() != crypto.Ed25519 && pubKey.Type() != crypto.Secp256k1 {
+		return nil, fmt.Errorf("%w: results cannot be sealed to %s keys; use an Ed25519 or Secp256k1 peer ID", errUnsealable, pubKey.Type())
+	}
+	return pubKey, nil
+}
+
 // handleGetComputationResult handles requests to get computation results
 func (server *Server) handleGetComputationResult(w http.ResponseWriter, r *http.Request) {
 	// Extract computation ID from URL parameters
@@ -757,11 +1456,9 @@ func (server *Server) handleGetComputationResult(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Get the computation result
-	result, err := server.privacyService.GetComputationResult(r.Context(), computationID)
+	result, err := server.ownComputationResult(r.Context(), computationID, r.Header.Get(reqsig.HeaderPeerID))
 	if err != nil {
-		server.logger.Error("failed to get computation result", "error", err, "computation_id", computationID)
-		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeInvalidRequest, fmt.Sprintf("Computation result not found: %v", err))
+		server.sendError(w, r, err, "Failed to get computation result")
 		return
 	}
 
@@ -773,6 +1470,25 @@ func (server *Server) handleGetComputationResult(w http.ResponseWriter, r *http.
 	}
 }
 
+// ownComputationResult returns the result of computationID for the peer
+// caller. Only the lease's spender, bound when the computation was queued,
+// may read it; results bound to no one are refused to everyone. Others are
+// answered as if it did not exist, so IDs cannot be probed.
+func (server *Server) ownComputationResult(ctx context.Context, computationID, caller string) (*privacy.ComputationResult, error) {
+	result, err := server.privacyService.GetComputationResult(ctx, computationID)
+	if err != nil {
+		server.logger.Error("failed to get computation result", "error", err, "computation_id", computationID)
+		return nil, err
+	}
+	if result.Owner == "" || caller != result.Owner {
+		server.logger.Warn("computation result refused to another peer", "computation_id", computationID, "peer_id", caller)
+		server.recordAudit(AuditResultDenied, caller, map[string]any{"computation_id": computationID})
+		return nil, fmt.Errorf("%w: %s", privacy.ErrComputationNotFound, computationID)
+	}
+	server.publishComputationResult(ctx, computationID, result)
+	return result, nil
+}
+
 // handleRaiseDispute handles the dispute creation endpoint
 func (server *Server) handleRaiseDispute(w http.ResponseWriter, r *http.Request) {
 	leaseID := chi.URLParam(r, "leaseId")
@@ -784,6 +1500,10 @@ func (server *Server) handleRaiseDispute(w http.ResponseWriter, r *http.Request)
 	// Parse request body
 	var req DisputeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if limit, tooLarge := bodyTooLarge(err); tooLarge {
+			server.rejectBodyTooLarge(w, r, limit)
+			return
+		}
 		server.logger.Error("failed to decode dispute request", "error", err)
 		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Invalid request body")
 		return
@@ -794,20 +1514,86 @@ func (server *Server) handleRaiseDispute(w http.ResponseWriter, r *http.Request)
 		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Dispute reason is required")
 		return
 	}
+	if len(req.Evidence) > 0 && server.evidence == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Dispute evidence is not enabled")
+		return
+	}
+
+	// Only the lease's spender may dispute it, since a dispute counts
+	// against the lease in reputation
+	peerID := r.Header.Get(reqsig.HeaderPeerID)
+	if owner := server.leaseSpenderPeer(leaseID); owner == "" || owner != peerID {
+		server.logger.Warn("dispute refused from a peer that does not hold the lease", "lease_id", leaseID, "peer_id", peerID)
+		server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Lease is not held by the calling peer")
+		return
+	}
+
+	// Pin the evidence first, so the reason sent on chain can reference it
+	disputeID, err := dispute.NewID(leaseID)
+	if err != nil {
+		server.logger.Error("failed to create dispute ID", "error", err)
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeInternalError, "Failed to raise dispute")
+		return
+	}
+	record, err := server.packageDispute(r.Context(), dispute.Claim{
+		DisputeID: disputeID,
+		LeaseID:   leaseID,
+		Reason:    req.Reason,
+		RaisedBy:  peerID,
+		Evidence:  req.Evidence,
+	})
+	if errors.Is(err, dispute.ErrInvalidEvidence) {
+		server.sendError(w, r, err, "Invalid dispute evidence")
+		return
+	}
+	if err != nil {
+		server.logger.Error("failed to package dispute evidence", "error", err, "lease_id", leaseID)
+		server.sendErrorResponse(w, r, http.StatusBadGateway, ErrorCodeEvidencePin, "Failed to pin dispute evidence to IPFS")
+		return
+	}
+
+	requiredStake := server.requiredStake(r.Context(), leaseID)
 
 	// TODO: Implement blockchain interaction to raise dispute with dynamic stake
 	// This would involve:
-	// 1. Calling getRequiredStake(leaseId) to get the calculated stake amount
-	// 2. Verifying the spender has sufficient PGT tokens
-	// 3. Checking PGT allowance for the LeaseAgreement contract
-	// 4. Calling the raiseDispute function on the smart contract
+	// 1. Verifying the spender has requiredStake PGT tokens
+	// 2. Checking PGT allowance for the LeaseAgreement contract
+	// 3. Calling the raiseDispute function on the smart contract with
+	//    record.ChainReason
 	// For now, we'll return a mock response
-	server.logger.Info("dynamic stake-based dispute raised", "lease_id", leaseID, "reason", req.Reason)
+	server.logger.Info("dynamic stake-based dispute raised", "lease_id", leaseID, "reason", req.Reason, "evidence_cid", record.EvidenceCID, "required_stake", requiredStake)
+
+	if server.reputation != nil {
+		if _, err := server.reputation.RecordOutcome(leaseID, reputation.OutcomeDisputed); err != nil {
+			server.logger.Error("failed to record dispute for reputation", "error", err, "lease_id", leaseID)
+		}
+	}
+
+	if server.disputes != nil {
+		// The dispute may already be on chain, in which case its record
+		// is completed rather than duplicated
+		stored, err := server.disputes.Add(record)
+		if err != nil {
+			server.logger.Error("failed to save dispute record", "error", err, "dispute_id", disputeID)
+		}
+		record = &stored
+	}
 
 	response := DisputeResponse{
-		DisputeID: fmt.Sprintf("dispute_%s_%d", leaseID, time.Now().Unix()),
-		Status:    "pending",
+		DisputeID:     record.DisputeID,
+		Status:        string(record.Status),
+		EvidenceCID:   record.EvidenceCID,
+		ChainReason:   record.ChainReason,
+		RequiredStake: requiredStake,
+	}
+	auditData := map[string]any{
+		"lease_id":   leaseID,
+		"dispute_id": response.DisputeID,
 	}
+	if record.EvidenceCID != "" {
+		auditData["evidence_cid"] = record.EvidenceCID
+	}
+	server.recordAudit(AuditDisputeRaised, peerID, auditData)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -822,6 +1608,9 @@ type TrainRequest struct {
 		Enabled bool    `json:"enabled"`
 		Epsilon float64 `json:"epsilon"`
 	} `json:"dp"`
+	LeaseID  string `json:"lease_id,omitempty"`  // Lease the job runs under; its price sets the job's priority
+	GPU      bool   `json:"gpu,omitempty"`       // Run the job on GPUs, queued separately from CPU jobs
+	GPUCount int    `json:"gpu_count,omitempty"` // GPUs the job needs; defaults to 1 with gpu set
 }
 
 // TrainResponse represents the response for the train endpoint
@@ -836,41 +1625,115 @@ func (server *Server) handleTrain(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req TrainRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if limit, tooLarge := bodyTooLarge(err); tooLarge {
+			server.rejectBodyTooLarge(w, r, limit)
+			return
+		}
 		server.logger.Error("failed to decode train request", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Validate request
 	if req.Dataset == "" {
-		http.Error(w, "Dataset is required", http.StatusBadRequest)
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Dataset is required")
 		return
 	}
 	if req.Task == "" {
-		http.Error(w, "Task is required", http.StatusBadRequest)
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Task is required")
+		return
+	}
+	if req.DP.Enabled && req.DP.Epsilon <= 0 {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "DP epsilon must be positive")
 		return
 	}
+	gpus, err := server.gpuRequest(req.GPU, req.GPUCount)
+	if err != nil {
+		if errors.Is(err, gpu.ErrUnavailable) {
+			server.sendError(w, r, err, "GPUs unavailable")
+		} else {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, err.Error())
+		}
+		return
+	}
+	if server.rejectQuarantined(w, r, req.Dataset, map[string]any{"task": req.Task}) {
+		return
+	}
+	if req.LeaseID != "" {
+		ctx, err := server.leaseContext(r, req.Dataset)
+		if err == nil {
+			err = server.privacyService.VerifyLease(ctx, req.LeaseID, r.Header.Get("X-Pandacea-Spender-Address"))
+		}
+		if err != nil {
+			server.logger.Warn("training lease verification failed", "error", err, "lease_id", req.LeaseID)
+			server.sendError(w, r, err, "Lease verification failed")
+			return
+		}
+	}
 
 	// Generate job ID
 	jobID := fmt.Sprintf("job_%d", time.Now().UnixNano())
 
+	// Hold the job's epsilon against the dataset's budget until it finishes
+	if server.budgets != nil {
+		var epsilon float64
+		if req.DP.Enabled {
+			epsilon = req.DP.Epsilon
+		}
+		if err := server.budgets.Reserve(req.Dataset, jobID, epsilon); err != nil {
+			server.logger.Warn("training job rejected by privacy budget", "dataset", req.Dataset, "epsilon", epsilon, "error", err)
+			server.sendError(w, r, err, "Failed to reserve privacy budget")
+			return
+		}
+	}
+
 	// Create training job
+	now := time.Now()
 	job := &TrainingJob{
 		JobID:     jobID,
-		Status:    "pending",
+		Status:    string(trainingJobs.Start()),
 		Dataset:   req.Dataset,
 		Task:      req.Task,
 		Epsilon:   req.DP.Epsilon,
-		CreatedAt: time.Now(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		owner:     r.Header.Get(reqsig.HeaderPeerID),
+		trace:     trace.SpanContextFromContext(r.Context()),
+		leaseID:   req.LeaseID,
+	}
+	if gpus > 0 {
+		job.GPU = &gpu.Allocation{Count: gpus}
 	}
 
-	// Store job
+	// Store and queue the job. A job the scheduler rejects is never
+	// persisted and its budget reservation is released.
 	server.jobsMutex.Lock()
 	server.jobs[jobID] = job
+	if err := server.queueTrainingJob(job, requestIdentity(r), server.leasePriority(req.LeaseID), nil); err != nil {
+		delete(server.jobs, jobID)
+		server.jobsMutex.Unlock()
+		if server.budgets != nil {
+			if err := server.budgets.Release(jobID); err != nil {
+				server.logger.Error("failed to release privacy budget", "job_id", jobID, "error", err)
+			}
+		}
+		server.logger.Warn("training job rejected by scheduler", "dataset", req.Dataset, "error", err)
+		server.sendError(w, r, err, "Failed to queue training job")
+		return
+	}
+	server.persistJob(job)
+	server.publishJobProgress(job)
 	server.jobsMutex.Unlock()
 
-	// Start the training job asynchronously
-	go server.runTrainingJob(jobID)
+	server.recordTrainingLineage(jobID, req.Dataset, req.LeaseID)
+	server.recordUsage(job.owner, usage.Counters{JobsStarted: 1})
+	server.recordAudit(AuditTrainingQueued, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"job_id":   jobID,
+		"dataset":  req.Dataset,
+		"task":     req.Task,
+		"epsilon":  req.DP.Epsilon,
+		"priority": job.Priority,
+	})
 
 	// Return job ID
 	response := TrainResponse{
@@ -888,35 +1751,55 @@ func (server *Server) handleTrain(w http.ResponseWriter, r *http.Request) {
 func (server *Server) handleAggregate(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "jobId")
 	if jobID == "" {
-		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Job ID is required")
 		return
 	}
 
-	server.jobsMutex.RLock()
+	server.jobsMutex.Lock()
 	job, exists := server.jobs[jobID]
-	server.jobsMutex.RUnlock()
+	if exists && trainingJobs.TimedOut(jobs.State(job.Status), job.UpdatedAt, time.Now()) {
+		server.setJobStatus(job, string(trainingJobs.TimeoutState()), "", "Training job timed out")
+	}
+	var snapshot TrainingJob
+	if exists {
+		snapshot = *job
+		snapshot.GPU = server.gpuStatus(job)
+	}
+	server.jobsMutex.Unlock()
+	if snapshot.Status == string(TrainingStatusPending) {
+		snapshot.Position = server.queuePosition(jobID)
+	}
 
 	if !exists {
-		http.Error(w, "Job not found", http.StatusNotFound)
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Job not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(job)
+	json.NewEncoder(w).Encode(snapshot)
 
 	server.logger.Info("aggregate status requested", "job_id", jobID, "status", job.Status)
 }
 
-// runTrainingJob executes the training job by calling a Python worker
+// runTrainingJob executes the training job with the configured backend
 func (server *Server) runTrainingJob(jobID string) {
 	server.logger.Info("starting training job", "job_id", jobID)
 
+	// A GPU job waits for its GPUs before it counts as running
+	devices, err := server.acquireJobGPUs(jobID)
+	if err != nil {
+		server.logger.Error("failed to acquire GPUs", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to acquire GPUs: %v", err))
+		return
+	}
+	defer server.releaseJobGPUs(jobID, devices)
+
 	// Update job status to running
 	server.updateJobStatus(jobID, "running", "", "")
 
 	// Create output directory
-	outputDir := fmt.Sprintf("./data/products/%s", jobID)
+	outputDir := filepath.Join(server.productsDir, jobID)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		server.logger.Error("failed to create output directory", "error", err, "job_id", jobID)
 		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to create output directory: %v", err))
@@ -928,218 +1811,209 @@ func (server *Server) runTrainingJob(jobID string) {
 	job := server.jobs[jobID]
 	server.jobsMutex.RUnlock()
 
-	// Check if Docker execution is enabled
-	useDocker := os.Getenv("USE_DOCKER") == "1"
-
-	if useDocker {
-		server.runTrainingJobDocker(jobID, job, outputDir)
-	} else {
-		server.runTrainingJobLocal(jobID, job, outputDir)
-	}
-}
-
-func (server *Server) runTrainingJobDocker(jobID string, job *TrainingJob, outputDir string) {
-	server.logger.Info("running training job with Docker", "job_id", jobID)
-
-	// Prepare job payload for Docker container
-	jobPayload := map[string]interface{}{
-		"job_id":     jobID,
-		"dataset":    job.Dataset,
-		"task":       job.Task,
-		"epsilon":    job.Epsilon,
-		"output_dir": "/app/data",
-	}
+	// The job outlives the request that queued it, so its span continues
+	// that request's trace without its cancellation
+	backend := server.trainingBackend
+	ctx, span := telemetry.StartSpan(trace.ContextWithSpanContext(context.Background(), job.trace), "training.run",
+		attribute.String("pandacea.job_id", jobID),
+		attribute.String("pandacea.dataset", job.Dataset),
+		attribute.String("pandacea.execution_mode", backend.Name()),
+	)
+	defer span.End()
 
-	payloadBytes, err := json.Marshal(jobPayload)
-	if err != nil {
-		server.logger.Error("failed to marshal job payload", "error", err, "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to marshal job payload: %v", err))
+	run := &TrainingRun{
+		JobID:     jobID,
+		Dataset:   job.Dataset,
+		Task:      job.Task,
+		Epsilon:   job.Epsilon,
+		Federated: job.FederationID != "",
+		Model:     job.model,
+		OutputDir: outputDir,
+		GPUs:      devices,
+		server:    server,
+	}
+	if job.FederationID == "" {
+		run.CheckpointDir = server.checkpointDir(jobID)
+		run.Resume = job.Checkpoint
+	}
+	// Fail a budget no noise can meet now rather than after the run
+	if err := run.calibrateNoise(); err != nil {
+		server.logger.Error("DP calibration failed", "error", err, "job_id", jobID, "epsilon", job.Epsilon)
+		server.updateJobStatus(jobID, "failed", "", err.Error())
 		return
 	}
-
-	// Execute Docker container
-	cmd := exec.Command("docker", "compose", "-f", "docker-compose.pysyft.yml", "run", "--rm", "pysyft-worker")
-	cmd.Stdin = strings.NewReader(string(payloadBytes))
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		server.logger.Error("Docker execution failed", "error", err, "output", string(output), "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Docker execution failed: %v", err))
+	server.logger.Info("running training job", "job_id", jobID, "backend", backend.Name())
+	if err := backend.Train(ctx, run); err != nil {
+		server.logger.Error("training backend failed", "error", err, "job_id", jobID, "backend", backend.Name())
+		server.recordCheckpoint(jobID)
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("%s training failed: %v", backend.Name(), err))
 		return
 	}
 
-	server.logger.Info("Docker execution completed", "output", string(output), "job_id", jobID)
-
 	// Check for output file
-	aggregatePath := fmt.Sprintf("%s/aggregate.json", outputDir)
+	aggregatePath := run.AggregatePath()
 	if _, err := os.Stat(aggregatePath); os.IsNotExist(err) {
-		server.logger.Error("aggregate file not found after Docker execution", "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", "Aggregate file not found after Docker execution")
+		server.logger.Error("aggregate file not found after training", "job_id", jobID, "backend", backend.Name())
+		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Aggregate file not found after %s training", backend.Name()))
 		return
 	}
 
-	// Update job status to complete
-	server.updateJobStatus(jobID, "complete", aggregatePath, "")
-	server.logger.Info("Docker training job completed", "job_id", jobID, "output", aggregatePath)
+	server.completeTrainingJob(jobID, job, aggregatePath)
+	if run.CheckpointDir != "" {
+		// A finished job is never resumed, so its checkpoints only take space
+		if err := os.RemoveAll(run.CheckpointDir); err != nil {
+			server.logger.Warn("failed to remove training checkpoints", "job_id", jobID, "error", err)
+		}
+	}
+	server.logger.Info("training job completed", "job_id", jobID, "backend", backend.Name(), "output", aggregatePath)
 }
 
-func (server *Server) runTrainingJobLocal(jobID string, job *TrainingJob, outputDir string) {
-	server.logger.Info("running training job locally", "job_id", jobID)
-
-	// Check if MOCK_DP is enabled
-	mockDP := os.Getenv("MOCK_DP") == "1"
+// trainingArtifact is the subset of the worker's aggregate.json needed for DP accounting
+type trainingArtifact struct {
+	N  int `json:"n"`
+	DP struct {
+		Clip            float64 `json:"clip"`
+		NoiseMultiplier float64 `json:"noise_multiplier"`
+	} `json:"dp"`
+	TrainingParams struct {
+		Epochs    int `json:"epochs"`
+		BatchSize int `json:"batch_size"`
+	} `json:"training_params"`
+}
 
-	if mockDP {
-		// Use the existing mock implementation
-		server.runTrainingJobMock(jobID, job, outputDir)
-	} else {
-		// Use the real PySyft worker
-		server.runTrainingJobReal(jobID, job, outputDir)
-	}
-}
-
-func (server *Server) runTrainingJobMock(jobID string, job *TrainingJob, outputDir string) {
-	server.logger.Info("running mock training job", "job_id", jobID)
-
-	// Prepare Python worker command
-	// This will run a simple Python script that simulates DP-SGD training
-	pythonScript := fmt.Sprintf(`
-import json
-import numpy as np
-import os
-from datetime import datetime
-
-# Simulate differential privacy parameters
-epsilon = %f
-dataset = "%s"
-task = "%s"
-
-# Create mock training result
-result = {
-    "job_id": "%s",
-    "dataset": dataset,
-    "task": task,
-    "epsilon_used": epsilon,
-    "model_accuracy": 0.85 + np.random.normal(0, 0.05),
-    "samples_processed": 1000,
-    "training_time_seconds": 30.5,
-    "dp_noise_scale": 1.0 / epsilon,
-    "timestamp": datetime.now().isoformat()
-}
-
-# Save result to output file
-output_path = os.path.join("%s", "aggregate.json")
-with open(output_path, "w") as f:
-    json.dump(result, f, indent=2)
-
-print(f"Training completed. Epsilon used: {epsilon}")
-print(f"Output saved to: {output_path}")
-`, job.Epsilon, job.Dataset, job.Task, jobID, outputDir)
-
-	// Write Python script to temporary file
-	scriptPath := fmt.Sprintf("%s/worker.py", outputDir)
-	if err := os.WriteFile(scriptPath, []byte(pythonScript), 0644); err != nil {
-		server.logger.Error("failed to write Python script", "error", err, "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to write Python script: %v", err))
+// completeTrainingJob recomputes the privacy spend of a finished job from the
+// mechanism parameters in its artifact, rather than trusting the epsilon the
+// worker reports, and fails the job if it exceeds the declared budget.
+// The artifact is watermarked first, so no unmarked copy is left on disk
+// when the job fails and the report covers the values released.
+func (server *Server) completeTrainingJob(jobID string, job *TrainingJob, aggregatePath string) {
+	if !server.watermarkTrainingJob(jobID, job, aggregatePath) {
 		return
 	}
-
-	// Execute Python script
-	cmd := fmt.Sprintf("python %s", scriptPath)
-	server.logger.Info("executing Python worker", "command", cmd, "job_id", jobID)
-
-	// For demo purposes, just sleep and create the output
-	time.Sleep(10 * time.Second) // Simulate training time
-
-	// Create the aggregate.json file
-	aggregatePath := fmt.Sprintf("%s/aggregate.json", outputDir)
-	result := map[string]interface{}{
-		"job_id":                jobID,
-		"dataset":               job.Dataset,
-		"task":                  job.Task,
-		"epsilon_used":          job.Epsilon,
-		"model_accuracy":        0.85 + (float64(time.Now().UnixNano()%100) / 1000.0), // Random accuracy
-		"samples_processed":     1000,
-		"training_time_seconds": 10.0,
-		"dp_noise_scale":        1.0 / job.Epsilon,
-		"timestamp":             time.Now().Format(time.RFC3339),
+	if job.Epsilon <= 0 {
+		server.finishTrainingJob(jobID, job, aggregatePath)
+		return
 	}
 
-	resultBytes, err := json.MarshalIndent(result, "", "  ")
+	report, err := server.accountTrainingArtifact(aggregatePath, job.Epsilon)
 	if err != nil {
-		server.logger.Error("failed to marshal training result", "error", err, "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to marshal result: %v", err))
+		server.logger.Error("DP accounting failed", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", aggregatePath, fmt.Sprintf("DP accounting failed: %v", err))
 		return
 	}
 
-	if err := os.WriteFile(aggregatePath, resultBytes, 0644); err != nil {
-		server.logger.Error("failed to write aggregate result", "error", err, "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Failed to write result: %v", err))
+	server.jobsMutex.Lock()
+	job.DPReport = report
+	server.jobsMutex.Unlock()
+
+	if !report.WithinBudget {
+		server.logger.Error("training job exceeded declared privacy budget",
+			"job_id", jobID,
+			"epsilon", report.Epsilon,
+			"declared_epsilon", report.DeclaredEpsilon,
+		)
+		server.updateJobStatus(jobID, "failed", aggregatePath, report.Err().Error())
 		return
 	}
 
-	// Update job status to complete
-	server.updateJobStatus(jobID, "complete", aggregatePath, "")
-	server.logger.Info("mock training job completed", "job_id", jobID, "output", aggregatePath)
+	server.finishTrainingJob(jobID, job, aggregatePath)
 }
 
-func (server *Server) runTrainingJobReal(jobID string, job *TrainingJob, outputDir string) {
-	server.logger.Info("running real PySyft training job", "job_id", jobID)
-
-	// Execute the real PySyft worker
-	workerPath := "./worker/train_worker.py"
-	cmd := exec.Command("python", workerPath,
-		"--job-id", jobID,
-		"--dataset", job.Dataset,
-		"--task", job.Task,
-		"--epsilon", fmt.Sprintf("%f", job.Epsilon),
-		"--output-dir", outputDir,
-	)
+// finishTrainingJob watermarks a training artifact, if enabled and not
+// already done, signs it, encrypts it if encryption at rest is enabled and
+// marks the job complete
+func (server *Server) finishTrainingJob(jobID string, job *TrainingJob, aggregatePath string) {
+	if !server.watermarkTrainingJob(jobID, job, aggregatePath) {
+		return
+	}
 
-	output, err := cmd.CombinedOutput()
+	integrity, err := server.signArtifact(jobID, aggregatePath)
 	if err != nil {
-		server.logger.Error("real PySyft execution failed", "error", err, "output", string(output), "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", fmt.Sprintf("Real PySyft execution failed: %v", err))
+		server.logger.Error("failed to sign training artifact", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", aggregatePath, fmt.Sprintf("Failed to sign artifact: %v", err))
 		return
 	}
+	server.jobsMutex.Lock()
+	job.Integrity = integrity
+	server.jobsMutex.Unlock()
+	server.publishTrainingArtifact(jobID, job, aggregatePath)
+	// The registry reads the artifact's metrics before it is encrypted
+	model := server.modelEntry(job, aggregatePath)
 
-	server.logger.Info("real PySyft execution completed", "output", string(output), "job_id", jobID)
-
-	// Check for output file
-	aggregatePath := fmt.Sprintf("%s/aggregate.json", outputDir)
-	if _, err := os.Stat(aggregatePath); os.IsNotExist(err) {
-		server.logger.Error("aggregate file not found after real PySyft execution", "job_id", jobID)
-		server.updateJobStatus(jobID, "failed", "", "Aggregate file not found after real PySyft execution")
+	if err := server.encryptArtifact(aggregatePath); err != nil {
+		server.logger.Error("failed to encrypt training artifact", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", aggregatePath, fmt.Sprintf("Failed to encrypt artifact: %v", err))
 		return
 	}
-
-	// Update job status to complete
+	server.registerModel(model)
 	server.updateJobStatus(jobID, "complete", aggregatePath, "")
-	server.logger.Info("real PySyft training job completed", "job_id", jobID, "output", aggregatePath)
 }
 
-// handleTrainLegacy handles legacy /train endpoint with deprecation warning
-func (server *Server) handleTrainLegacy(w http.ResponseWriter, r *http.Request) {
-	// Add deprecation warning header
-	w.Header().Set("X-API-Deprecation-Warning", "This endpoint is deprecated. Use /api/v1/train instead.")
+// watermarkTrainingJob watermarks the job's artifact once, if watermarking
+// is enabled. It fails the job and returns false if marking fails.
+func (server *Server) watermarkTrainingJob(jobID string, job *TrainingJob, aggregatePath string) bool {
+	if server.marker == nil {
+		return true
+	}
+	server.jobsMutex.RLock()
+	done := job.Watermarked
+	server.jobsMutex.RUnlock()
+	if done {
+		return true
+	}
 
-	// Log deprecation warning
-	server.logger.Warn("legacy endpoint used", "endpoint", "/train", "recommendation", "Use /api/v1/train instead")
+	if err := server.watermarkArtifact(jobID, aggregatePath); err != nil {
+		server.logger.Error("failed to watermark training artifact", "error", err, "job_id", jobID)
+		server.updateJobStatus(jobID, "failed", aggregatePath, fmt.Sprintf("Failed to watermark artifact: %v", err))
+		return false
+	}
+	server.jobsMutex.Lock()
+	job.Watermarked = true
+	server.jobsMutex.Unlock()
+	return true
+}
 
-	// Call the original handler
-	server.handleTrain(w, r)
+// watermarkArtifact marks the numbers in a JSON training artifact with the
+// job's watermark, rewriting it in place
+func (server *Server) watermarkArtifact(jobID, aggregatePath string) error {
+	data, err := os.ReadFile(aggregatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read artifact: %w", err)
+	}
+	marked, count, err := server.marker.EmbedJSON(jobID, data)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(aggregatePath, marked, 0600); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	server.logger.Info("training artifact watermarked", "job_id", jobID, "values", count)
+	return nil
 }
 
-// handleAggregateLegacy handles legacy /aggregate/{jobId} endpoint with deprecation warning
-func (server *Server) handleAggregateLegacy(w http.ResponseWriter, r *http.Request) {
-	// Add deprecation warning header
-	w.Header().Set("X-API-Deprecation-Warning", "This endpoint is deprecated. Use /api/v1/aggregate/{jobId} instead.")
+// accountTrainingArtifact builds a DP report from a training artifact on disk
+func (server *Server) accountTrainingArtifact(aggregatePath string, declaredEpsilon float64) (*privacy.DPReport, error) {
+	data, err := os.ReadFile(aggregatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	var artifact trainingArtifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact: %w", err)
+	}
 
-	// Log deprecation warning
-	server.logger.Warn("legacy endpoint used", "endpoint", "/aggregate/{jobId}", "recommendation", "Use /api/v1/aggregate/{jobId} instead")
+	if artifact.N <= 0 || artifact.TrainingParams.BatchSize <= 0 || artifact.TrainingParams.Epochs <= 0 {
+		return nil, fmt.Errorf("artifact is missing sample count, batch size or epochs")
+	}
 
-	// Call the original handler
-	server.handleAggregate(w, r)
+	return privacy.NewDPReport(privacy.DPParameters{
+		NoiseMultiplier: artifact.DP.NoiseMultiplier,
+		ClippingNorm:    artifact.DP.Clip,
+		SampleRate:      float64(artifact.TrainingParams.BatchSize) / float64(artifact.N),
+		Steps:           artifact.TrainingParams.Epochs * (artifact.N / artifact.TrainingParams.BatchSize),
+		Delta:           privacy.DefaultDPDelta,
+	}, declaredEpsilon)
 }
 
 // updateJobStatus updates the status of a training job
@@ -1153,7 +2027,19 @@ func (server *Server) updateJobStatus(jobID, status, artifactPath, errorMsg stri
 		return
 	}
 
+	server.setJobStatus(job, status, artifactPath, errorMsg)
+}
+
+// setJobStatus applies a validated status transition. Caller must hold jobsMutex.
+func (server *Server) setJobStatus(job *TrainingJob, status, artifactPath, errorMsg string) {
+	if err := trainingJobs.Transition(jobs.State(job.Status), jobs.State(status), job.CreatedAt); err != nil {
+		server.logger.Warn("rejected job status update", "job_id", job.JobID, "error", err)
+		return
+	}
+
+	now := time.Now()
 	job.Status = status
+	job.UpdatedAt = now
 	if artifactPath != "" {
 		job.ArtifactPath = artifactPath
 	}
@@ -1161,12 +2047,78 @@ func (server *Server) updateJobStatus(jobID, status, artifactPath, errorMsg stri
 		job.Error = errorMsg
 	}
 
-	if status == "complete" || status == "failed" {
-		now := time.Now()
+	if status == string(TrainingStatusRunning) {
+		job.startedAt = now
+	}
+	if trainingJobs.IsTerminal(jobs.State(status)) {
 		job.CompletedAt = &now
+		server.meterTrainingJob(job, now)
+		server.billTrainingJob(job, now)
+		server.settleBudget(job)
+		server.finishJobLog(job.JobID)
 	}
+	server.persistJob(job)
+	server.publishJobProgress(job)
+
+	server.logger.Info("job status updated", "job_id", job.JobID, "status", status)
+}
+
+// persistJob saves a job snapshot if a job store is configured. Caller must hold jobsMutex.
+func (server *Server) persistJob(job *TrainingJob) {
+	if server.jobStore == nil {
+		return
+	}
+	if err := server.jobStore.Save(job.JobID, job); err != nil {
+		server.logger.Error("failed to persist training job", "job_id", job.JobID, "error", err)
+	}
+}
+
+// SetReputationTracker feeds counterparty reputation into lease policy
+// decisions and records disputes against it
+func (server *Server) SetReputationTracker(tracker *reputation.Tracker) {
+	server.reputation = tracker
+}
+
+// SetPricer exposes dynamic price floors and records lease demand against them
+func (server *Server) SetPricer(pricer *pricing.Pricer) {
+	server.pricer = pricer
+}
 
-	server.logger.Info("job status updated", "job_id", jobID, "status", status)
+// AddListenerStatus lets readiness checks report on the blockchain event
+// listener for one network
+func (server *Server) AddListenerStatus(status *chain.ListenerStatus) {
+	server.listenerStatus = append(server.listenerStatus, status)
+}
+
+// SetJobStore enables persistence of training jobs and restores previously
+// saved jobs. Jobs that were still running when the agent stopped cannot be
+// resumed and are marked failed.
+func (server *Server) SetJobStore(store jobs.Store) error {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	server.jobStore = store
+	return store.LoadAll(func(id string, data []byte) error {
+		var job TrainingJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			server.logger.Warn("skipping unreadable training job", "job_id", id, "error", err)
+			return nil
+		}
+
+		if !trainingJobs.IsTerminal(jobs.State(job.Status)) {
+			now := time.Now()
+			job.Status = string(trainingJobs.TimeoutState())
+			job.Error = "Training job interrupted by agent restart"
+			job.UpdatedAt = now
+			job.CompletedAt = &now
+			server.persistJob(&job)
+			server.settleBudget(&job)
+		}
+
+		trainingJobs.Restore(jobs.State(job.Status))
+		server.jobs[job.JobID] = &job
+		return nil
+	})
 }
 
 // AuthChallengeRequest represents a request to create an authentication challenge
@@ -1197,19 +2149,29 @@ type AuthVerifyResponse struct {
 func (server *Server) handleAuthChallenge(w http.ResponseWriter, r *http.Request) {
 	var req AuthChallengeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		server.sendErrorResponse(w, r, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	if req.Address == "" {
-		server.sendErrorResponse(w, r, http.StatusBadRequest, "MISSING_ADDRESS", "Address is required")
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeMissingAddress, "Address is required")
+		return
+	}
+
+	if !common.IsHexAddress(req.Address) {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidAddress, "Address must be a hex-encoded Ethereum address")
 		return
 	}
 
 	challenge, err := server.securityService.CreateChallenge(req.Address)
+	if errors.Is(err, security.ErrTooManyChallenges) {
+		server.securityService.LogRefusedRequest(r, req.Address, "too_many_challenges")
+		server.sendError(w, r, err, "")
+		return
+	}
 	if err != nil {
 		server.logger.Error("failed to create challenge", "error", err, "address", req.Address)
-		server.sendErrorResponse(w, r, http.StatusInternalServerError, "CHALLENGE_CREATION_FAILED", "Failed to create challenge")
+		server.sendErrorResponse(w, r, http.StatusInternalServerError, ErrorCodeChallengeFailed, "Failed to create challenge")
 		return
 	}
 
@@ -1228,16 +2190,21 @@ func (server *Server) handleAuthChallenge(w http.ResponseWriter, r *http.Request
 func (server *Server) handleAuthVerify(w http.ResponseWriter, r *http.Request) {
 	var req AuthVerifyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		server.sendErrorResponse(w, r, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	if req.Nonce == "" || req.Signature == "" {
-		server.sendErrorResponse(w, r, http.StatusBadRequest, "MISSING_FIELDS", "Nonce and signature are required")
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeMissingFields, "Nonce and signature are required")
 		return
 	}
 
 	address, valid := server.securityService.VerifyChallenge(req.Nonce, req.Signature)
+	if valid {
+		server.recordAudit(AuditAuthVerified, address, nil)
+	} else {
+		server.recordAudit(AuditAuthFailed, address, map[string]any{"remote_addr": r.RemoteAddr})
+	}
 
 	response := AuthVerifyResponse{
 		Address: address,
@@ -18,7 +18,7 @@ func FuzzHandleCreateLease(f *testing.F) {
 	testConfig := createTestServerConfig()
 	policyEngine, _ := policy.NewEngine(logger, testConfig)
 	mockP2PNode := &p2p.Node{}
-	server := NewServer(policyEngine, logger, mockP2PNode)
+	server := NewServer(policyEngine, logger, mockP2PNode, nil, nil)
 
 	// Seed with a few valid LeaseRequest payloads
 	validPayloads := [][]byte{
@@ -3,18 +3,54 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"log/slog"
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/autoscale"
+	"pandacea/agent-backend/internal/buildinfo"
 	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/federation"
 	"pandacea/agent-backend/internal/p2p"
 	"pandacea/agent-backend/internal/policy"
-
+	"pandacea/agent-backend/internal/pricing"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/txmgr"
+	"pandacea/agent-backend/internal/watermark"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // createTestServerConfig creates a ServerConfig for testing
@@ -69,6 +105,12 @@ func TestServer_handleGetProducts(t *testing.T) {
 	assert.Equal(t, "RoboticSensorData", response.Data[0].DataType)
 	assert.Equal(t, []string{"robotics", "3d-scan", "lidar"}, response.Data[0].Keywords)
 	assert.Equal(t, "cursor_def456", response.NextCursor)
+
+	// No matches still encode as an empty list
+	w = httptest.NewRecorder()
+	server.handleGetProducts(w, httptest.NewRequest("GET", "/api/v1/products?dataType=none", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
 }
 
 func TestMetricsEndpoint(t *testing.T) {
@@ -324,6 +366,27 @@ func TestServer_handleHealth(t *testing.T) {
 	assert.Equal(t, "healthy", response["status"])
 }
 
+func TestServer_handleGetVersion(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetProfile(config.ProfileStaging, config.HardeningConfig{RequireSignatures: true})
+	server.SetTrainingConfig(config.TrainingConfig{ExecutionMode: config.ExecutionModeDocker})
+
+	w := httptest.NewRecorder()
+	server.handleGetVersion(w, httptest.NewRequest("GET", "/api/v1/version", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response VersionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, VersionResponse{Info: buildinfo.Get(), APIVersion: "v1", Profile: "staging", ExecutionMode: "docker"}, response)
+
+	// Hardened profiles reject v1 signatures regardless of security.yaml
+	assert.False(t, server.allowLegacySignatures())
+}
+
 func TestServer_validateLeaseRequest(t *testing.T) {
 	// Create test logger
 	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
@@ -544,3 +607,1449 @@ func TestServer_UpdateLeaseStatus(t *testing.T) {
 		assert.Equal(t, updatedStatus, leaseState.Status)
 	})
 }
+
+func TestServer_handleGetChainEvents(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	for block := uint64(100); block < 105; block++ {
+		server.RecordChainEvent("LeaseCreated", block, "0xabc", 0, nil)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/events?from_block=102&limit=2", nil)
+	w := httptest.NewRecorder()
+	server.handleGetChainEvents(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page EventsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, float64(102), page.Data[0].Fields["block_number"])
+	assert.True(t, page.HasMore)
+	assert.Equal(t, uint64(5), page.Watermark)
+
+	req = httptest.NewRequest("GET", "/api/v1/events?from_block=102&cursor="+page.NextCursor, nil)
+	w = httptest.NewRecorder()
+	server.handleGetChainEvents(w, req)
+
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, float64(104), page.Data[0].Fields["block_number"])
+	assert.False(t, page.HasMore)
+
+	// Events decoded from JSON carry the block number as a float64
+	server.chainEvents.Append("LeaseCreated", "", map[string]any{"block_number": float64(110)})
+	req = httptest.NewRequest("GET", "/api/v1/events?from_block=110", nil)
+	w = httptest.NewRecorder()
+	server.handleGetChainEvents(w, req)
+
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
+
+	req = httptest.NewRequest("GET", "/api/v1/admin/security/audit/events?cursor=bogus", nil)
+	w = httptest.NewRecorder()
+	server.handleGetAuditEvents(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The audit log is for operators only; a signed peer that is not an
+	// admin cannot read it
+	server.recordAudit(AuditLeaseRejected, "peer-1", map[string]any{"reason": "price below minimum"})
+	req = httptest.NewRequest("GET", "/api/v1/admin/security/audit/events", nil)
+	signRequest(t, req, nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "price below minimum")
+}
+
+func TestServer_bodyLimitMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`
+request_limits:
+  max_body_size_mb: 1
+  routes:
+    - path: /api/v1/auth
+      max_body_size_kb: 1
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+rate_limits:
+  per_ip_rps: 100
+  per_identity_rps: 100
+  burst: 100
+backpressure:
+  mem_high_watermark_mb: 100000
+queue:
+  max_size: 10
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	assert.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		streamed bool // Hide the length, so the limit is enforced while reading
+		wantCode int
+	}{
+		{"declared length over global limit", "/api/v1/train", strings.Repeat("a", 2*1024*1024), false, http.StatusRequestEntityTooLarge},
+		{"declared length over route limit", "/api/v1/auth/challenge", strings.Repeat("a", 2048), false, http.StatusRequestEntityTooLarge},
+		{"streamed body over limit", "/train", `{"dataset":"` + strings.Repeat("a", 2*1024*1024), true, http.StatusRequestEntityTooLarge},
+		{"body within limit", "/train", `{"dataset":`, false, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.streamed {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest("POST", tt.path, body)
+			require.NoError(t, reqsig.Sign(priv, req, []byte(tt.body), time.Now()))
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusRequestEntityTooLarge {
+				var resp ErrorResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, ErrorCodeEntityTooLarge, resp.Error.Code)
+			}
+		})
+	}
+}
+
+func TestServer_adminSecurityEndpoints(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(`
+bans:
+  temp_ban_seconds: 60
+admin:
+  peer_ids:
+    - 12D3KooWAdmin
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	assert.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+
+	// The admin routes sit behind signature verification, so exercise the
+	// admin check and handlers directly
+	router := chi.NewRouter()
+	router.Route("/api/v1/admin/security", func(r chi.Router) {
+		r.Use(server.adminOnly)
+		r.Get("/", server.handleGetSecurityState)
+		r.Post("/bans", server.handleBanIP)
+		r.Delete("/bans/{ip}", server.handleUnbanIP)
+	})
+
+	serve := func(method, path, peerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if peerID != "" {
+			req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/api/v1/admin/security/", "12D3KooWOther", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve("POST", "/api/v1/admin/security/bans", "12D3KooWAdmin", `{"ip":"10.0.0.1","duration_seconds":120}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve("GET", "/api/v1/admin/security/", "12D3KooWAdmin", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var snapshot security.SecuritySnapshot
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	if assert.Len(t, snapshot.Bans, 1) {
+		assert.Equal(t, "10.0.0.1", snapshot.Bans[0].IP)
+	}
+
+	w = serve("DELETE", "/api/v1/admin/security/bans/10.0.0.1", "12D3KooWAdmin", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, securityService.Snapshot().Bans)
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditAdminBan})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+}
+
+func TestServer_handleGetPricing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/pricing/*", server.handleGetPricing)
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	path := "/api/v1/pricing/did:pandacea:earner:123/abc-456"
+	assert.Equal(t, http.StatusServiceUnavailable, serve(path).Code)
+
+	pricer, err := pricing.NewPricer(logger, "0.001", config.PricingConfig{DemandThreshold: 1, DemandStep: 0.5, MaxMultiplier: 3})
+	assert.NoError(t, err)
+	server.SetPricer(pricer)
+	pricer.RecordLease("did:pandacea:earner:123/abc-456")
+	pricer.RecordLease("did:pandacea:earner:123/abc-456")
+
+	w := serve(path)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var quote pricing.Quote
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&quote))
+	assert.Equal(t, "did:pandacea:earner:123/abc-456", quote.ProductID)
+	assert.Equal(t, "0.0015", quote.EffectivePrice)
+	assert.Equal(t, 2, quote.RecentRequests)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/api/v1/pricing/not-a-product").Code)
+}
+
+func TestServer_leaseExpiry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	server.UpdateLeaseStatus("lease_short", "pending", nil, "", "", nil)
+	server.setLeaseTerm("lease_short", "1h")
+	server.UpdateLeaseStatus("lease_long", "pending", nil, "", "", nil)
+	server.setLeaseTerm("lease_long", "7d")
+	server.UpdateLeaseStatus("lease_open", "pending", nil, "", "", nil)
+
+	// Approval restarts the term from the approval time
+	server.UpdateLeaseStatus("lease_long", "approved", nil, "0xspender", "0xearner", nil)
+
+	state := server.pendingLeases["lease_short"]
+	assert.Equal(t, "1h", state.Duration)
+	assert.NotNil(t, state.ExpiresAt)
+	assert.Equal(t, state.CreatedAt.Add(time.Hour), *state.ExpiresAt)
+
+	assert.Equal(t, 0, server.expireLeases(time.Now()))
+	assert.Equal(t, 1, server.expireLeases(time.Now().Add(2*time.Hour)))
+	assert.Equal(t, LeaseStatusExpired, server.pendingLeases["lease_short"].Status)
+	assert.Equal(t, "approved", server.pendingLeases["lease_long"].Status)
+	assert.Equal(t, "pending", server.pendingLeases["lease_open"].Status)
+
+	// Expired leases are not expired again
+	assert.Equal(t, 1, server.expireLeases(time.Now().Add(8*24*time.Hour)))
+	assert.Equal(t, LeaseStatusExpired, server.pendingLeases["lease_long"].Status)
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditLeaseExpired})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 2)
+}
+
+func TestServer_sendErrorMapsSentinels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	tests := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{privacy.ErrLeaseNotApproved, http.StatusForbidden, ErrorCodeLeaseNotApproved, "lease is not approved"},
+		{fmt.Errorf("validation error: %w", fmt.Errorf("%w: lease_id is required", privacy.ErrInvalidRequest)), http.StatusBadRequest, ErrorCodeValidationError, "validation error: invalid computation request: lease_id is required"},
+		{fmt.Errorf("%w: comp-1", privacy.ErrComputationNotFound), http.StatusNotFound, ErrorCodeNotFound, "computation job not found: comp-1"},
+		{security.ErrTooManyChallenges, http.StatusTooManyRequests, ErrorCodeTooManyChallenges, "too many outstanding challenges for address"},
+		{errors.New("rpc: connection refused"), http.StatusInternalServerError, ErrorCodeInternalError, "Lease verification failed"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		server.sendError(w, httptest.NewRequest(http.MethodGet, "/", nil), tt.err, "Lease verification failed")
+
+		var response ErrorResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, tt.status, w.Code, tt.err.Error())
+		assert.Equal(t, tt.code, response.Error.Code)
+		assert.Equal(t, tt.message, response.Error.Message)
+	}
+}
+
+func TestServer_watermarkTrainingArtifact(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	marker, err := watermark.NewMarker(bytes.Repeat([]byte{1}, watermark.KeySize))
+	require.NoError(t, err)
+	server.SetWatermarker(marker)
+
+	weights := make([]float64, 64)
+	for i := range weights {
+		weights[i] = 0.01 + float64(i)*0.0173
+	}
+	artifact, err := json.Marshal(map[string]any{"n": 1000, "weights": weights})
+	require.NoError(t, err)
+	aggregatePath := filepath.Join(t.TempDir(), "aggregate.json")
+	require.NoError(t, os.WriteFile(aggregatePath, artifact, 0644))
+
+	now := time.Now()
+	job := &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), CreatedAt: now, UpdatedAt: now}
+	server.jobs[job.JobID] = job
+	server.completeTrainingJob(job.JobID, job, aggregatePath)
+
+	assert.Equal(t, string(TrainingStatusComplete), job.Status)
+	assert.True(t, job.Watermarked)
+
+	marked, err := os.ReadFile(aggregatePath)
+	require.NoError(t, err)
+	assert.True(t, marker.Detect("job-1", marked).Match)
+	assert.False(t, marker.Detect("job-2", marked).Match)
+	assert.False(t, marker.Detect("job-1", artifact).Match)
+	info, err := os.Stat(aggregatePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// An artifact that fails accounting is still marked on disk
+	require.NoError(t, os.WriteFile(aggregatePath, artifact, 0644))
+	failed := &TrainingJob{JobID: "job-2", Status: string(TrainingStatusRunning), Epsilon: 1, CreatedAt: now, UpdatedAt: now}
+	server.jobs[failed.JobID] = failed
+	server.completeTrainingJob(failed.JobID, failed, aggregatePath)
+
+	assert.Equal(t, "failed", failed.Status)
+	marked, err = os.ReadFile(aggregatePath)
+	require.NoError(t, err)
+	assert.True(t, marker.Detect("job-2", marked).Match)
+}
+
+// recordingPrivacyService records the last computation request it queued
+type recordingPrivacyService struct {
+	MockPrivacyService
+	last *privacy.ComputationRequest
+}
+
+func (m *recordingPrivacyService) ExecuteComputation(ctx context.Context, req *privacy.ComputationRequest) (*privacy.ComputationResponse, error) {
+	m.last = req
+	return m.MockPrivacyService.ExecuteComputation(ctx, req)
+}
+
+func TestServer_handleExecuteComputationSealsResults(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &recordingPrivacyService{}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+	server.SetProfile(config.ProfileProduction, config.HardeningConfig{SealResults: true})
+
+	priv, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	require.NoError(t, err)
+	peerID, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+
+	execute := func(peerHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute", strings.NewReader(`{"lease_id":"lease-1"}`))
+		req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+		req.Header.Set("X-Pandacea-Peer-ID", peerHeader)
+		w := httptest.NewRecorder()
+		server.handleExecuteComputation(w, req)
+		return w
+	}
+
+	w := execute(peerID.String())
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NotNil(t, privacyService.last)
+	assert.True(t, pub.Equals(privacyService.last.Recipient))
+
+	// Results sealed to the recipient only open with its private key
+	results := &privacy.ComputationResults{Output: "mean=4.2", Artifacts: map[string]string{"model.json": "e30="}}
+	require.NoError(t, results.Seal(privacyService.last.Recipient))
+	assert.Empty(t, results.Output)
+	assert.Nil(t, results.Artifacts)
+	require.NoError(t, results.Open(priv))
+	assert.Equal(t, "mean=4.2", results.Output)
+	assert.Equal(t, "e30=", results.Artifacts["model.json"])
+
+	// Callers without a key to seal to are refused
+	privacyService.last = nil
+	w = execute("not-a-peer-id")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Nil(t, privacyService.last)
+}
+
+// instantPrivacyService finishes computations before returning their IDs
+type instantPrivacyService struct {
+	MockPrivacyService
+	server *Server
+}
+
+func (m *instantPrivacyService) ExecuteComputation(ctx context.Context, req *privacy.ComputationRequest) (*privacy.ComputationResponse, error) {
+	m.server.onComputationStatus(req.ID, "completed")
+	return &privacy.ComputationResponse{ComputationID: req.ID}, nil
+}
+
+func TestServer_handleExecuteComputationOwnsBeforeQueueing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &instantPrivacyService{}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+	privacyService.server = server
+
+	_, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	require.NoError(t, err)
+	peerID, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute", strings.NewReader(`{"lease_id":"lease-1"}`))
+	req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+	req.Header.Set("X-Pandacea-Peer-ID", peerID.String())
+	w := httptest.NewRecorder()
+	server.handleExecuteComputation(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	// The completion reached the owner and left nothing behind
+	page, err := server.statusEvents.List(audit.Query{Match: func(e audit.Event) bool { return e.Type == EventComputationCompleted }})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, peerID.String(), page.Events[0].Actor)
+	server.ownersMutex.Lock()
+	assert.Empty(t, server.computations)
+	server.ownersMutex.Unlock()
+}
+
+func TestServer_handleExecuteComputationSealsToLeaseKey(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &recordingPrivacyService{}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	encoded := base64.StdEncoding.EncodeToString(priv.PublicKey().Bytes())
+
+	// Keys are checked when the lease is requested
+	req := &LeaseRequest{ProductID: "did:pandacea:earner:123/abc-456", MaxPrice: "0.01", Duration: "24h", EncryptionKey: "c2hvcnQ="}
+	assert.ErrorContains(t, server.validateLeaseRequest(req), "X25519")
+	req.EncryptionKey = encoded
+	assert.NoError(t, server.validateLeaseRequest(req))
+
+	server.UpdateLeaseStatus("lease_prop_ab", "approved", nil, "", "", nil)
+	server.setLeaseEncryptionKey("lease_prop_ab", encoded)
+
+	// Results under the lease are sealed to its key even without
+	// hardening, and callers need no peer key
+	httpReq := httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute", strings.NewReader(`{"lease_id":"0xAB"}`))
+	httpReq.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+	httpReq.Header.Set(reqsig.HeaderPeerID, "12D3KooWSpender")
+	w := httptest.NewRecorder()
+	server.handleExecuteComputation(w, httpReq)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NotNil(t, privacyService.last.RecipientX25519)
+	assert.True(t, priv.PublicKey().Equal(privacyService.last.RecipientX25519))
+
+	results := &privacy.ComputationResults{Output: "mean=4.2", Artifacts: map[string]string{"model.json": "e30="}}
+	require.NoError(t, results.SealX25519(privacyService.last.RecipientX25519))
+	assert.Nil(t, results.Artifacts)
+	require.NoError(t, results.OpenX25519(priv))
+	assert.Equal(t, "e30=", results.Artifacts["model.json"])
+
+	// A transferred lease drops its key, and without a new peer nobody holds it
+	server.transferLeaseState("lease_prop_ab", privacy.Assignment{LeaseID: "0xab", To: "0xnew"})
+	assert.Nil(t, server.leaseEncryptionKey("0xAB"))
+	assert.Empty(t, server.leaseSpenderPeer("0xAB"))
+}
+
+// ownedPrivacyService binds the computations it queues to their owner
+type ownedPrivacyService struct {
+	MockPrivacyService
+	owners map[string]string
+}
+
+func (m *ownedPrivacyService) ExecuteComputation(ctx context.Context, req *privacy.ComputationRequest) (*privacy.ComputationResponse, error) {
+	id := fmt.Sprintf("comp-%d", len(m.owners)+1)
+	m.owners[id] = req.Owner
+	return &privacy.ComputationResponse{ComputationID: id}, nil
+}
+
+func (m *ownedPrivacyService) GetComputationResult(ctx context.Context, computationID string) (*privacy.ComputationResult, error) {
+	owner, exists := m.owners[computationID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", privacy.ErrComputationNotFound, computationID)
+	}
+	result, err := m.MockPrivacyService.GetComputationResult(ctx, computationID)
+	if err != nil {
+		return nil, err
+	}
+	result.Owner = owner
+	return result, nil
+}
+
+func TestServer_computationResultOwner(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	privacyService := &ownedPrivacyService{owners: make(map[string]string)}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/privacy/execute", server.handleExecuteComputation)
+	router.Get("/api/v1/privacy/results/{computation_id}", server.handleGetComputationResult)
+	serve := func(method, path, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"lease_id":"lease-1"}`))
+		req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+		if peerID != "" {
+			req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/api/v1/privacy/execute", "12D3KooWSpender")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var queued privacy.ComputationResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&queued))
+	assert.Equal(t, "12D3KooWSpender", privacyService.owners[queued.ComputationID])
+
+	path := "/api/v1/privacy/results/" + queued.ComputationID
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, path, "12D3KooWSpender").Code)
+
+	// Other peers, or none, are told the computation does not exist
+	notFound := serve(http.MethodGet, "/api/v1/privacy/results/comp-404", "12D3KooWSpender")
+	require.Equal(t, http.StatusNotFound, notFound.Code)
+	for _, peerID := range []string{"12D3KooWOther", ""} {
+		w := serve(http.MethodGet, path, peerID)
+		assert.Equal(t, http.StatusNotFound, w.Code, "peer %q", peerID)
+		assert.NotContains(t, w.Body.String(), "mock output")
+	}
+
+	// Computations with no spender peer to bind to are refused, and results
+	// bound to no one are readable by no one
+	w = serve(http.MethodPost, "/api/v1/privacy/execute", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	privacyService.owners["comp-unowned"] = ""
+	for _, peerID := range []string{"12D3KooWOther", ""} {
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/v1/privacy/results/comp-unowned", peerID).Code, "peer %q", peerID)
+	}
+
+	// Results are bound to the peer that requested the lease, whoever
+	// queues the computation
+	server.UpdateLeaseStatus("lease-1", "approved", nil, "", "", nil)
+	server.setLeaseOwner("lease-1", "12D3KooWHolder")
+	for _, peerID := range []string{"", "12D3KooWOther"} {
+		w = serve(http.MethodPost, "/api/v1/privacy/execute", peerID)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&queued))
+		path = "/api/v1/privacy/results/" + queued.ComputationID
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, path, "12D3KooWHolder").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, path, "12D3KooWOther").Code)
+	}
+}
+
+func TestServer_auditJournal(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	// Without a journal the endpoints report it is disabled
+	w := httptest.NewRecorder()
+	server.handleExportAuditJournal(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/security/audit/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	journal, err := audit.OpenJournal(filepath.Join(t.TempDir(), "journal.ndjson"))
+	require.NoError(t, err)
+	t.Cleanup(func() { journal.Close() })
+	require.NoError(t, server.SetAuditJournal(journal))
+
+	server.recordAudit(AuditLeaseRejected, "peer-1", map[string]any{"reason": "price below minimum"})
+	server.onComputationStatus("comp-1", "completed")
+
+	w = httptest.NewRecorder()
+	server.handleExportAuditJournal(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/security/audit/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "2", w.Header().Get("X-Pandacea-Audit-Records"))
+
+	verification, err := audit.Verify(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 2, verification.Records)
+	assert.Equal(t, w.Header().Get("X-Pandacea-Audit-Head"), verification.Head)
+
+	// The export itself is audited
+	w = httptest.NewRecorder()
+	server.handleVerifyAuditJournal(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/security/audit/verify", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response AuditVerifyResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.True(t, response.Valid)
+	assert.Equal(t, 3, response.Records)
+	assert.Equal(t, uint64(3), response.LastSeq)
+}
+
+func TestServer_privacyBudget(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	getBudget := func(dataset string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/privacy/budget/"+dataset, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("dataset", dataset)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleGetPrivacyBudget(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, getBudget("mnist").Code)
+
+	ledger, err := privacy.NewBudgetLedger(0, map[string]float64{"mnist": 2}, "")
+	require.NoError(t, err)
+	server.SetBudgetLedger(ledger)
+
+	// A completed job is charged the epsilon accounted from its artifact
+	require.NoError(t, ledger.Reserve("mnist", "job-1", 1.5))
+	now := time.Now()
+	job := &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), Dataset: "mnist", Epsilon: 1.5,
+		DPReport: &privacy.DPReport{Epsilon: 1.2, DeclaredEpsilon: 1.5, WithinBudget: true}, CreatedAt: now, UpdatedAt: now}
+	server.jobs[job.JobID] = job
+	server.updateJobStatus(job.JobID, string(TrainingStatusComplete), "", "")
+
+	w := getBudget("mnist")
+	require.Equal(t, http.StatusOK, w.Code)
+	var budget privacy.Budget
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&budget))
+	assert.True(t, budget.Limited)
+	assert.InDelta(t, 1.2, budget.Spent, 1e-9)
+	assert.InDelta(t, 0.8, budget.Remaining, 1e-9)
+
+	// Jobs that would overdraw the dataset are refused before they are queued
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/train",
+		strings.NewReader(`{"dataset":"mnist","task":"classification","dp":{"enabled":true,"epsilon":1.0}}`))
+	w = httptest.NewRecorder()
+	server.handleTrain(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeBudgetExceeded)
+	assert.Len(t, server.jobs, 1)
+}
+
+func TestServer_quarantineProduct(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	quarantineFile := filepath.Join(t.TempDir(), "quarantine.json")
+	require.NoError(t, server.SetQuarantineFile(quarantineFile))
+
+	const productID = "did:pandacea:earner:123/abc-456"
+	server.UpdateLeaseStatus("lease-1", "approved", nil, "0xspender", "", nil)
+	server.setLeaseProduct("lease-1", productID)
+	server.setLeaseOwner("lease-1", "peer-1")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/security/quarantine",
+		strings.NewReader(`{"product_id":"`+productID+`","reason":"consent withdrawn"}`))
+	req.Header.Set("X-Pandacea-Peer-ID", "admin-peer")
+	w := httptest.NewRecorder()
+	server.handleQuarantineProduct(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var q Quarantine
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&q))
+	assert.Equal(t, []string{"lease-1"}, q.NotifiedLeases)
+
+	// The lease holder is told, and the quarantine is on the audit record
+	page, err := server.statusEvents.List(audit.Query{Match: func(e audit.Event) bool { return e.Type == EventProductQuarantined }})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "peer-1", page.Events[0].Actor)
+	page, err = server.auditLog.List(audit.Query{Match: func(e audit.Event) bool { return e.Type == AuditAdminQuarantine }})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "admin-peer", page.Events[0].Actor)
+
+	// New leases, computations and training on the product are refused
+	w = httptest.NewRecorder()
+	server.handleCreateLease(w, httptest.NewRequest(http.MethodPost, "/api/v1/leases",
+		strings.NewReader(`{"productId":"`+productID+`","maxPrice":"0.01","duration":"24h"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeQuarantined)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/privacy/execute",
+		strings.NewReader(`{"lease_id":"lease-2","inputs":[{"asset_id":"`+productID+`","variable_name":"df"}]}`))
+	req.Header.Set("X-Pandacea-Spender-Address", "0xspender")
+	w = httptest.NewRecorder()
+	server.handleExecuteComputation(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	server.handleTrain(w, httptest.NewRequest(http.MethodPost, "/api/v1/train",
+		strings.NewReader(`{"dataset":"`+productID+`","task":"classification"}`)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, server.jobs)
+
+	// The catalog flags the product, and the quarantine survives a restart
+	server.products.Replace([]DataProduct{{ProductID: productID}, {ProductID: "did:pandacea:earner:123/other"}})
+	w = httptest.NewRecorder()
+	server.handleGetProducts(w, httptest.NewRequest(http.MethodGet, "/api/v1/products", nil))
+	var products ProductsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&products))
+	assert.True(t, products.Data[0].Quarantined)
+	assert.False(t, products.Data[1].Quarantined)
+
+	restarted := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	require.NoError(t, restarted.SetQuarantineFile(quarantineFile))
+	_, quarantined := restarted.quarantine(productID)
+	assert.True(t, quarantined)
+
+	// Lifting the quarantine lets requests through again
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/admin/security/quarantine/"+productID, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("*", productID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w = httptest.NewRecorder()
+	server.handleLiftQuarantine(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, quarantined = server.quarantine(productID)
+	assert.False(t, quarantined)
+}
+
+// fixedRoundTransport answers every participant's round with their fixed weights
+type fixedRoundTransport map[string][]float64
+
+func (f fixedRoundTransport) RequestRound(ctx context.Context, participant string, req federation.RoundRequest) (federation.RoundUpdate, error) {
+	weights, exists := f[participant]
+	if !exists {
+		return federation.RoundUpdate{}, errors.New("participant unreachable")
+	}
+	return federation.RoundUpdate{Weights: weights, Samples: 100}, nil
+}
+
+func TestServer_federatedTraining(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetProductsDir(t.TempDir())
+
+	participants := make([]string, 2)
+	for i := range participants {
+		_, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+		require.NoError(t, err)
+		id, err := peer.IDFromPublicKey(pub)
+		require.NoError(t, err)
+		participants[i] = id.String()
+	}
+	body := fmt.Sprintf(`{"dataset":"mnist","task":"classification","participants":["%s","%s"],"rounds":2}`, participants[0], participants[1])
+	createJob := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/federation", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.handleCreateFederation(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, createJob(body).Code)
+
+	server.SetFederation(config.FederationConfig{Coordinator: true, RoundTimeoutSeconds: 5, MaxRounds: 2}, fixedRoundTransport{
+		participants[0]: {1, 2},
+		participants[1]: {3, 4},
+	})
+	// The coordinator's job shares the scheduler with local jobs
+	jobScheduler := scheduler.New(1, 2, 1, logger)
+	defer jobScheduler.Close()
+	server.SetScheduler(jobScheduler, config.SchedulerConfig{})
+	w := createJob(strings.Replace(body, `"rounds":2`, `"rounds":3`, 1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeValidationError)
+
+	w = createJob(body)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var response TrainResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+	var job TrainingJob
+	require.Eventually(t, func() bool {
+		server.jobsMutex.RLock()
+		defer server.jobsMutex.RUnlock()
+		job = *server.jobs[response.JobID]
+		return job.Status == string(TrainingStatusComplete) || job.Status == string(TrainingStatusFailed)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, string(TrainingStatusComplete), job.Status, job.Error)
+	assert.Equal(t, scheduler.PriorityNormal.String(), job.Priority)
+
+	// Round progress is reported through the jobs API
+	require.NotNil(t, job.Federation)
+	assert.Equal(t, 2, job.Federation.CompletedRounds)
+	require.Len(t, job.Federation.RoundReports, 2)
+	assert.Equal(t, 2, job.Federation.RoundReports[0].Updates)
+
+	update, err := server.readRoundUpdate(job.ArtifactPath)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{2, 3}, update.Weights)
+	assert.Equal(t, 200, update.Samples)
+
+	// Participants only train rounds for the coordinators they allow
+	_, err = server.TrainRound(context.Background(), participants[0], federation.RoundRequest{Dataset: "mnist", Task: "classification"})
+	assert.ErrorIs(t, err, federation.ErrNotAllowed)
+
+	// and, with require_secure, only rounds whose updates will be masked
+	server.SetFederation(config.FederationConfig{Participant: true, AllowedCoordinators: participants[:1], RequireSecure: true}, nil)
+	_, err = server.TrainRound(context.Background(), participants[0], federation.RoundRequest{Dataset: "mnist", Task: "classification"})
+	assert.ErrorIs(t, err, federation.ErrNotAllowed)
+}
+
+// holderPrivacyService verifies leases against a fixed on-chain spender and
+// the assignments it is given
+type holderPrivacyService struct {
+	MockPrivacyService
+	spender     string
+	assignments *privacy.AssignmentRegistry
+}
+
+func (m *holderPrivacyService) UseAssignments(registry *privacy.AssignmentRegistry) {
+	m.assignments = registry
+}
+
+func (m *holderPrivacyService) VerifyLease(ctx context.Context, leaseID string, spenderAddr string) error {
+	holder := m.spender
+	if assignment, assigned := m.assignments.Holder(leaseID); assigned {
+		holder = assignment.To
+	}
+	if !strings.EqualFold(holder, spenderAddr) {
+		return fmt.Errorf("%w: lease is held by %s", privacy.ErrSpenderMismatch, holder)
+	}
+	return nil
+}
+
+func TestServer_transferLease(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	cfg := createTestServerConfig()
+	cfg.AllowLeaseTransfers = true
+	policyEngine, err := policy.NewEngine(logger, cfg)
+	require.NoError(t, err)
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	addrs := make([]string, 3)
+	for i := range keys {
+		keys[i], err = ethcrypto.GenerateKey()
+		require.NoError(t, err)
+		addrs[i] = ethcrypto.PubkeyToAddress(keys[i].PublicKey).Hex()
+	}
+	privacyService := &holderPrivacyService{spender: addrs[0]}
+	server := NewServer(policyEngine, logger, &p2p.Node{}, privacyService, nil)
+
+	const leaseID = "0x00000000000000000000000000000000000000000000000000000000000000ab"
+	proposalID := chainLeaseProposalID(leaseID)
+	server.pendingLeases[proposalID] = &LeaseProposalState{Status: "approved", SpenderAddr: addrs[0], ProductID: "did:pandacea:earner:public/1", owner: "peer-a"}
+
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	newPeer, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	transferTo := func(signer *ecdsa.PrivateKey, from, to, toPeerID string, nonce int) *httptest.ResponseRecorder {
+		sig, err := ethcrypto.Sign(accounts.TextHash(privacy.AssignmentMessage(leaseID, from, to, toPeerID, nonce)), signer)
+		require.NoError(t, err)
+		body := fmt.Sprintf(`{"from":%q,"to":%q,"toPeerId":%q,"nonce":%d,"signature":"0x%x"}`, from, to, toPeerID, nonce, sig)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/leases/"+leaseID+"/transfer", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("leaseId", leaseID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleTransferLease(w, req)
+		return w
+	}
+	transfer := func(signer *ecdsa.PrivateKey, from, to string, nonce int) *httptest.ResponseRecorder {
+		return transferTo(signer, from, to, newPeer.String(), nonce)
+	}
+
+	assert.Equal(t, http.StatusNotFound, transfer(keys[0], addrs[0], addrs[1], 0).Code)
+
+	registry, err := privacy.NewAssignmentRegistry(filepath.Join(t.TempDir(), "assignments.json"))
+	require.NoError(t, err)
+	server.SetLeaseAssignments(registry)
+	require.Same(t, registry, privacyService.assignments)
+
+	// Only the holder's own signature assigns the lease, and it must name
+	// the new holder's peer
+	assert.Equal(t, http.StatusUnauthorized, transfer(keys[1], addrs[0], addrs[1], 0).Code)
+	assert.Equal(t, http.StatusBadRequest, transferTo(keys[0], addrs[0], addrs[1], "", 0).Code)
+
+	w := transfer(keys[0], addrs[0], addrs[1], 0)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var assignment privacy.Assignment
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&assignment))
+	assert.Equal(t, addrs[1], assignment.To)
+	assert.Equal(t, addrs[1], server.pendingLeases[proposalID].SpenderAddr)
+	assert.Equal(t, newPeer.String(), server.leaseSpenderPeer(leaseID))
+
+	// The previous holder can no longer use or assign the lease, and the
+	// new holder's assignment must carry the next nonce
+	assert.Error(t, privacyService.VerifyLease(context.Background(), leaseID, addrs[0]))
+	assert.NoError(t, privacyService.VerifyLease(context.Background(), leaseID, addrs[1]))
+	assert.Equal(t, http.StatusForbidden, transfer(keys[0], addrs[0], addrs[2], 1).Code)
+	assert.Equal(t, http.StatusConflict, transfer(keys[1], addrs[1], addrs[2], 0).Code)
+	require.Equal(t, http.StatusCreated, transfer(keys[1], addrs[1], addrs[2], 1).Code)
+	assert.Len(t, registry.History(leaseID), 2)
+
+	// Earners that do not accept transfers reject them by policy
+	server.policy, err = policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	w = transfer(keys[2], addrs[2], addrs[0], 2)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodePolicyRejection)
+}
+
+func TestServer_trainingLogs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	now := time.Now()
+	server.jobs["job-1"] = &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), CreatedAt: now, UpdatedAt: now, owner: "peer-1"}
+
+	getLogs := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/train/job-1/logs?"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobId", "job-1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleTrainingLogs(w, req)
+		return w
+	}
+
+	// Progress lines update the job; every line is kept as its log
+	cmd := exec.Command("sh", "-c", `echo starting; echo '{"type":"progress","epoch":2,"epochs":5,"loss":0.25,"samples_processed":640}' >&2; echo done`)
+	require.NoError(t, server.runWorker("job-1", cmd))
+	progress := server.jobs["job-1"].Progress
+	require.NotNil(t, progress)
+	assert.Equal(t, 2, progress.Epoch)
+	assert.Equal(t, 640, progress.Samples)
+	assert.InDelta(t, 0.25, *progress.Loss, 1e-9)
+
+	page, err := server.statusEvents.List(audit.Query{Match: func(e audit.Event) bool { return e.Type == EventJobProgress }})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.EqualValues(t, 2, page.Events[0].Fields["epoch"])
+
+	w := getLogs("limit=2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var logs JobLogsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&logs))
+	require.Len(t, logs.Lines, 2)
+	assert.False(t, logs.Done)
+
+	w = getLogs(fmt.Sprintf("since=%d", logs.Next))
+	logs = JobLogsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&logs))
+	require.Len(t, logs.Lines, 1)
+	assert.False(t, logs.Done, "a running job's log may still grow")
+
+	// Following streams the remaining lines and ends when the job does
+	server.jobLog("job-1").append("stdout", "saving artifact")
+	go server.updateJobStatus("job-1", string(TrainingStatusComplete), "", "")
+	w = getLogs(fmt.Sprintf("since=%d&follow=true", logs.Next))
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "id: 4\nevent: log\n")
+	assert.Contains(t, body, "saving artifact")
+	assert.True(t, strings.HasSuffix(body, "event: end\ndata: {}\n\n"))
+
+	assert.Equal(t, http.StatusBadRequest, getLogs("since=abc").Code)
+}
+
+func TestServer_readLinesTruncatesLongLines(t *testing.T) {
+	var lines []string
+	readLines(strings.NewReader("short\n"+strings.Repeat("x", maxLogLineBytes+10)+"\nlast"), func(line string) {
+		lines = append(lines, line)
+	})
+	require.Len(t, lines, 3)
+	assert.Equal(t, "short", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], " [truncated]"))
+	assert.Len(t, lines[1], maxLogLineBytes+len(" [truncated]"))
+	assert.Equal(t, "last", lines[2])
+}
+
+func TestServer_schedulesTrainingJobs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	jobScheduler := scheduler.New(1, 2, 1, logger)
+	server.SetScheduler(jobScheduler, config.SchedulerConfig{HighPriorityPrice: "1000"})
+	price := "1500"
+	server.pendingLeases[chainLeaseProposalID("0xabc")] = &LeaseProposalState{Status: "approved", Price: &price}
+
+	assert.Equal(t, scheduler.PriorityHigh, server.leasePriority("0xABC"))
+	assert.Equal(t, scheduler.PriorityNormal, server.leasePriority("0xdef"), "leases below the high threshold are normal")
+	assert.Equal(t, scheduler.PriorityLow, server.leasePriority(""))
+
+	// Occupy the only worker so training jobs stay queued
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, jobScheduler.Submit(scheduler.Task{ID: "blocker", Run: func() {
+		close(started)
+		<-release
+	}}))
+	<-started
+
+	train := func(peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/train", strings.NewReader(`{"dataset":"ds","task":"classification"}`))
+		req.Header.Set(reqsig.HeaderPeerID, peerID)
+		req.Header.Set("X-Pandacea-Spender-Address", "0x"+peerID)
+		w := httptest.NewRecorder()
+		server.handleTrain(w, req)
+		return w
+	}
+	aggregate := func(jobID string) TrainingJob {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/aggregate/"+jobID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobId", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		server.handleAggregate(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var job TrainingJob
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+		return job
+	}
+	jobID := func(w *httptest.ResponseRecorder) string {
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response TrainResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response.JobID
+	}
+
+	first := jobID(train("peer-alice"))
+	job := aggregate(first)
+	assert.Equal(t, string(TrainingStatusPending), job.Status)
+	assert.Equal(t, "low", job.Priority)
+	assert.Equal(t, 1, job.Position)
+
+	// One peer cannot fill the queue, even by naming another spender
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/train", strings.NewReader(`{"dataset":"ds","task":"classification"}`))
+	req.Header.Set(reqsig.HeaderPeerID, "peer-alice")
+	req.Header.Set("X-Pandacea-Spender-Address", "0xSomeoneElse")
+	server.handleTrain(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeTooManyQueued)
+
+	second := jobID(train("peer-bob"))
+	assert.Equal(t, 2, aggregate(second).Position)
+
+	w = train("peer-carol")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeQueueFull)
+	server.jobsMutex.RLock()
+	assert.Len(t, server.jobs, 2, "rejected jobs are not kept")
+	server.jobsMutex.RUnlock()
+
+	// Jobs still queued at shutdown fail instead of staying pending
+	closed := make(chan struct{})
+	go func() {
+		jobScheduler.Close()
+		close(closed)
+	}()
+	for queued, _ := jobScheduler.Stats(); queued > 0; queued, _ = jobScheduler.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-closed
+	job = aggregate(first)
+	assert.Equal(t, string(TrainingStatusFailed), job.Status)
+	assert.Zero(t, job.Position)
+}
+
+// fixedPool is a container pool that keeps its size
+type fixedPool int
+
+func (p fixedPool) PoolSize() int        { return int(p) }
+func (p fixedPool) ResizePool(int) error { return nil }
+
+func TestServer_runtimeReportsLoadForecast(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+
+	history, err := autoscale.LoadHistory("")
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 40; i++ {
+		history.RecordArrival(now)
+	}
+	history.RecordHold(6 * time.Minute)
+	controller := autoscale.NewController(history, fixedPool(2), autoscale.Config{Mode: autoscale.ModeHint, MaxSize: 10, Lead: time.Hour}, logger)
+	controller.Evaluate(now)
+	server.SetPoolAutoscaler(controller)
+	jobScheduler := scheduler.New(1, 10, 10, logger)
+	defer jobScheduler.Close()
+	server.SetScheduler(jobScheduler, config.SchedulerConfig{})
+
+	w := httptest.NewRecorder()
+	server.handleGetRuntime(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/security/runtime", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var runtime RuntimeResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&runtime))
+	require.NotNil(t, runtime.Scheduler)
+	require.NotNil(t, runtime.Pool)
+	assert.Equal(t, "hint", runtime.Pool.Mode)
+	assert.Equal(t, 2, runtime.Pool.Size)
+	assert.Equal(t, 4, runtime.Pool.Recommended, "40 arrivals an hour holding containers for 6 minutes need 4")
+	assert.Len(t, runtime.Pool.Forecast, 2)
+}
+
+func TestServer_signsTrainingArtifact(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+
+	artifact := []byte(`{"n":1000,"weights":[0.25,0.5]}`)
+	aggregatePath := filepath.Join(t.TempDir(), "aggregate.json")
+	require.NoError(t, os.WriteFile(aggregatePath, artifact, 0644))
+
+	now := time.Now()
+	job := &TrainingJob{JobID: "job-1", Status: string(TrainingStatusRunning), CreatedAt: now, UpdatedAt: now}
+	server.jobs[job.JobID] = job
+	server.completeTrainingJob(job.JobID, job, aggregatePath)
+
+	assert.Equal(t, string(TrainingStatusComplete), job.Status)
+	require.NotNil(t, job.Integrity)
+	sum := sha256.Sum256(artifact)
+	assert.Equal(t, hex.EncodeToString(sum[:]), job.Integrity.SHA256)
+	assert.Equal(t, signer.PeerID(), job.Integrity.PeerID)
+
+	sidecar, err := os.ReadFile(aggregatePath + ".sig")
+	require.NoError(t, err)
+	var written ArtifactSig
+	require.NoError(t, json.Unmarshal(sidecar, &written))
+	assert.Equal(t, *job.Integrity, written)
+
+	verify := func(req ArtifactVerifyRequest) ArtifactVerifyResponse {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		server.handleVerifyArtifact(w, httptest.NewRequest(http.MethodPost, "/api/v1/artifacts/verify", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response ArtifactVerifyResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+
+	response := verify(ArtifactVerifyRequest{Integrity: written, Artifact: base64.StdEncoding.EncodeToString(artifact)})
+	assert.True(t, response.Valid, response.Error)
+	assert.True(t, response.ThisAgent)
+	assert.Equal(t, signer.PeerID(), response.PeerID)
+
+	response = verify(ArtifactVerifyRequest{Integrity: written, Artifact: base64.StdEncoding.EncodeToString([]byte(`{"n":1000,"weights":[0.25,0.6]}`))})
+	assert.False(t, response.Valid)
+	assert.NotEmpty(t, response.Error)
+
+	other := newTestResponseSigner(t)
+	response = verify(ArtifactVerifyRequest{Integrity: written, PeerID: other.PeerID()})
+	assert.False(t, response.Valid)
+}
+
+func TestServer_getEarnings(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+admin:
+  peer_ids:
+    - 12D3KooWAdmin
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+
+	ledger, err := earnings.NewLedger(0.25, "")
+	require.NoError(t, err)
+	day := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+	for i, product := range []string{"mnist", "mnist", "cifar"} {
+		id := fmt.Sprintf("0x%02d", i)
+		require.NoError(t, ledger.RecordLease(id, "0xearner", "0xspender", big.NewInt(1000)))
+		_, err := ledger.RecordExecution(id, product, day.Add(time.Duration(i)*24*time.Hour))
+		require.NoError(t, err)
+	}
+	server.SetEarnings(ledger)
+
+	// The route sits behind signature verification, so call its handler
+	// with the admin check directly
+	var handler http.HandlerFunc
+	for _, rt := range server.routes() {
+		if rt.operationID == "getEarnings" {
+			handler = rt.handler
+		}
+	}
+	require.NotNil(t, handler)
+	serve := func(target, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve("/api/v1/earnings", "12D3KooWOther").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/api/v1/earnings?interval=fortnight", "12D3KooWAdmin").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/api/v1/earnings?from=yesterday", "12D3KooWAdmin").Code)
+
+	w := serve("/api/v1/earnings?from=2026-05-05T00:00:00Z&to=2026-05-07T00:00:00Z&interval=day", "12D3KooWAdmin")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp EarningsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, earnings.Totals{Leases: 2, Gross: "2000", Royalty: "500", Net: "1500"}, resp.Total)
+	assert.Len(t, resp.Products, 2)
+	assert.Len(t, resp.Periods, 2)
+	require.NotNil(t, resp.From)
+	assert.True(t, resp.From.Equal(day.Add(24*time.Hour)))
+
+	w = serve("/api/v1/earnings?product=mnist", "12D3KooWAdmin")
+	var mnist EarningsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&mnist))
+	assert.Equal(t, 2, mnist.Total.Leases)
+	assert.Empty(t, mnist.Periods)
+}
+
+func TestServer_leaseTransactions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+admin:
+  peer_ids:
+    - 12D3KooWAdmin
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+
+	// Transactions to an account without code succeed, which is all the
+	// lifecycle needs
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		ethcrypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1e18)},
+	})
+	defer backend.Close()
+	chainID, err := backend.Client().ChainID(context.Background())
+	require.NoError(t, err)
+	manager, err := txmgr.New(backend.Client(), key, chainID, txmgr.Config{PollInterval: 10 * time.Millisecond}, "", logger)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Run(ctx)
+	server.SetTransactionManager(manager, common.HexToAddress("0x00000000000000000000000000000000000000aa"))
+
+	// The routes sit behind signature verification, so mount them without it
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	for _, rt := range server.routes() {
+		switch rt.operationID {
+		case "approveLease", "executeLease", "listTransactions", "getTransaction":
+			router.Method(rt.method, rt.pattern, rt.handler)
+		}
+	}
+	serve := func(method, target, peerID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	leaseID := "0x" + strings.Repeat("ab", 32)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/leases/"+leaseID+"/approve", "12D3KooWOther").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/leases/0x1234/approve", "12D3KooWAdmin").Code)
+
+	w := serve(http.MethodPost, "/leases/"+leaseID+"/approve", "12D3KooWAdmin")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var queued txmgr.Record
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&queued))
+	assert.Equal(t, TxActionApproveLease, queued.Action)
+	assert.Equal(t, leaseID, queued.Reference)
+	assert.NotEmpty(t, queued.RequestID)
+
+	// Mine once it is sent, then wait for the receipt
+	require.Eventually(t, func() bool {
+		rec, _ := manager.Get(queued.ID)
+		return rec.Status == txmgr.StatusPending
+	}, 5*time.Second, 10*time.Millisecond)
+	backend.Commit()
+	var final txmgr.Record
+	require.Eventually(t, func() bool {
+		w := serve(http.MethodGet, "/transactions/"+queued.ID, "12D3KooWAdmin")
+		var rec txmgr.Record
+		json.NewDecoder(w.Body).Decode(&rec)
+		final = rec
+		return rec.Status == txmgr.StatusConfirmed
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotNil(t, final.Receipt)
+	assert.True(t, final.Receipt.Succeeded)
+	assert.Equal(t, queued.RequestID, final.RequestID)
+
+	w = serve(http.MethodGet, "/transactions?action=lease.approve&reference="+leaseID, "12D3KooWAdmin")
+	var list TransactionsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	assert.Len(t, list.Data, 1)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/transactions/tx_missing", "12D3KooWAdmin").Code)
+}
+
+func TestServer_peerLists(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte("admin:\n  peer_ids:\n    - 12D3KooWAdmin\n"), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	assert.NoError(t, err)
+	defer securityService.Shutdown()
+
+	gater, err := p2p.NewGater(p2p.GaterConfig{MinScore: -50, HalfLife: time.Hour}, logger)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node, err := p2p.NewNode(ctx, p2p.NodeConfig{Gater: gater}, logger)
+	assert.NoError(t, err)
+	defer node.Close()
+	server := NewServer(policyEngine, logger, node, nil, securityService)
+
+	router := chi.NewRouter()
+	router.Route("/api/v1/admin/security", func(r chi.Router) {
+		r.Use(server.adminOnly)
+		r.Get("/peers", server.handleListPeers)
+		r.Put("/peers/{peerId}", server.handleSetPeerList)
+		r.Delete("/peers/{peerId}", server.handleForgetPeer)
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Pandacea-Peer-ID", "12D3KooWAdmin")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	const peerID = "12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK"
+	w := serve("PUT", "/api/v1/admin/security/peers/"+peerID, `{"list":"deny","reason":"spam"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var state p2p.PeerState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, p2p.ListDeny, state.List)
+	assert.False(t, state.Allowed)
+
+	w = serve("PUT", "/api/v1/admin/security/peers/not-a-peer", `{"list":"deny"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("PUT", "/api/v1/admin/security/peers/"+peerID, `{"list":"maybe"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve("GET", "/api/v1/admin/security/peers", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var peers PeersResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &peers))
+	if assert.Len(t, peers.Data, 1) {
+		assert.Equal(t, "spam", peers.Data[0].Reason)
+	}
+
+	w = serve("DELETE", "/api/v1/admin/security/peers/"+peerID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve("DELETE", "/api/v1/admin/security/peers/"+peerID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditAdminPeerList})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+}
+
+func TestServer_connectedPeers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	assert.NoError(t, err)
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte("admin:\n  peer_ids:\n    - 12D3KooWAdmin\n"), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	assert.NoError(t, err)
+	defer securityService.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listen := map[string][]string{p2p.TransportTCP: {"/ip4/127.0.0.1/tcp/0"}}
+	node, err := p2p.NewNode(ctx, p2p.NodeConfig{Transports: []string{p2p.TransportTCP}, ListenAddrs: listen}, logger)
+	assert.NoError(t, err)
+	defer node.Close()
+	other, err := p2p.NewNode(ctx, p2p.NodeConfig{Transports: []string{p2p.TransportTCP}, ListenAddrs: listen}, logger)
+	assert.NoError(t, err)
+	defer other.Close()
+	server := NewServer(policyEngine, logger, node, nil, securityService)
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/p2p/peers", server.adminOnly(http.HandlerFunc(server.handleListConnectedPeers)).ServeHTTP)
+	router.Post("/api/v1/p2p/peers", server.adminOnly(http.HandlerFunc(server.handleConnectPeer)).ServeHTTP)
+	serve := func(method, peerID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/p2p/peers", strings.NewReader(body))
+		req.Header.Set("X-Pandacea-Peer-ID", peerID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "12D3KooWSomeoneElse", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve("POST", "12D3KooWAdmin", `{"addr":"/ip4/127.0.0.1/tcp/4001"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	addr := other.GetListenAddrs()[0].String() + "/p2p/" + other.GetPeerID()
+	w = serve("POST", "12D3KooWAdmin", `{"addr":"`+addr+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var connected p2p.ConnectedPeer
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &connected))
+	assert.Equal(t, other.GetPeerID(), connected.PeerID)
+
+	w = serve("GET", "12D3KooWAdmin", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var peers ConnectedPeersResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &peers))
+	if assert.Len(t, peers.Data, 1) {
+		assert.Equal(t, "outbound", peers.Data[0].Direction)
+	}
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditAdminPeerConnect})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+}
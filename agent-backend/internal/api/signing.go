@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"pandacea/agent-backend/internal/respsig"
+)
+
+// SetResponseSigner sets the signer for /api/v1 responses. NewServer uses the
+// P2P node's identity key when it has one; a nil signer disables signing.
+func (server *Server) SetResponseSigner(signer *respsig.Signer) {
+	server.responseSigner = signer
+}
+
+// newResponseSigner returns a signer for the node's identity key, or nil if
+// the node has no key
+func (server *Server) newResponseSigner() *respsig.Signer {
+	if server.p2pNode == nil || server.p2pNode.PrivateKey() == nil {
+		return nil
+	}
+	signer, err := respsig.NewSigner(server.p2pNode.PrivateKey())
+	if err != nil {
+		server.logger.Error("failed to create response signer, responses will be unsigned", "error", err)
+		return nil
+	}
+	return signer
+}
+
+// maxSignedResponseBytes caps how much of a response is held for signing.
+// Larger responses are sent unsigned, which verifiers reject, rather than
+// held in memory.
+const maxSignedResponseBytes = 32 << 20
+
+// bufferedResponse holds a response until it can be signed. Once a handler
+// calls skipResponseSignature, or the response outgrows
+// maxSignedResponseBytes, writes go straight to the client instead.
+type bufferedResponse struct {
+	w        http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	unsigned bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.w.Header()
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.unsigned {
+		b.w.WriteHeader(status)
+		return
+	}
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.unsigned {
+		return b.w.Write(p)
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if b.body.Len()+len(p) > maxSignedResponseBytes {
+		b.unsigned = true
+		b.w.WriteHeader(b.status)
+		if _, err := b.w.Write(b.body.Bytes()); err != nil {
+			return 0, err
+		}
+		b.body = bytes.Buffer{}
+		return b.w.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (b *bufferedResponse) Unwrap() http.ResponseWriter {
+	return b.w
+}
+
+// skipResponseSignature sends the rest of a response unbuffered and
+// unsigned. Handlers call it before writing a body that carries its own
+// detached signature, such as a large artifact, so the body is neither
+// held in memory nor signed after transfer encoding. Error responses
+// written before the call are still signed.
+func skipResponseSignature(w http.ResponseWriter) {
+	for {
+		switch rw := w.(type) {
+		case *bufferedResponse:
+			rw.unsigned = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// signResponseMiddleware buffers each response and signs its canonical
+// digest with the agent's key, so spenders can verify it with respsig. The
+// event stream and responses whose handler calls skipResponseSignature are
+// passed through unsigned.
+func (server *Server) signResponseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signer := server.responseSigner
+		if signer == nil || r.URL.Path == eventStreamPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{w: w}
+		next.ServeHTTP(buf, r)
+		if buf.unsigned {
+			return
+		}
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+
+		body := buf.body.Bytes()
+		if err := signer.Sign(w.Header(), r, buf.status, body); err != nil {
+			server.logger.Error("failed to sign response", "path", r.URL.Path, "error", err)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buf.status)
+		w.Write(body)
+	})
+}
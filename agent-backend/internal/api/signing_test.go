@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+	"pandacea/agent-backend/internal/security"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponseSigner(t *testing.T) *respsig.Signer {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	signer, err := respsig.NewSigner(priv)
+	require.NoError(t, err)
+	return signer
+}
+
+func TestServer_signResponseMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("auth:\n  challenge_timeout_seconds: 300\n  nonce_length: 32\n"), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+	assert.Nil(t, server.responseSigner, "a node without a key should not sign")
+
+	signer := newTestResponseSigner(t)
+	server.SetResponseSigner(signer)
+
+	t.Run("handler response", func(t *testing.T) {
+		router := chi.NewRouter()
+		router.With(server.signResponseMiddleware).Get("/api/v1/products", server.handleGetProducts)
+
+		req := httptest.NewRequest("GET", "/api/v1/products?category=health", nil)
+		req.Header.Set(reqsig.HeaderNonce, "nonce-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		peerID, err := respsig.Verify(w.Header(), req, w.Code, w.Body.Bytes(), signer.PeerID())
+		assert.NoError(t, err)
+		assert.Equal(t, signer.PeerID(), peerID)
+
+		// The response answers this request only
+		replayed := httptest.NewRequest("GET", "/api/v1/products?category=health", nil)
+		replayed.Header.Set(reqsig.HeaderNonce, "nonce-2")
+		_, err = respsig.Verify(w.Header(), replayed, w.Code, w.Body.Bytes(), signer.PeerID())
+		assert.ErrorIs(t, err, respsig.ErrInvalidSignature, "the nonce is part of the signed digest")
+	})
+
+	t.Run("oversized response is sent unsigned", func(t *testing.T) {
+		large := bytes.Repeat([]byte("a"), 1024)
+		router := chi.NewRouter()
+		router.With(server.signResponseMiddleware).Get("/api/v1/large", func(w http.ResponseWriter, r *http.Request) {
+			for written := 0; written <= maxSignedResponseBytes; written += len(large) {
+				_, _ = w.Write(large)
+			}
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/large", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Greater(t, w.Body.Len(), maxSignedResponseBytes)
+		assert.Empty(t, w.Header().Get(respsig.HeaderSignature))
+	})
+
+	t.Run("middleware rejection", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/products", nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+
+		require.GreaterOrEqual(t, w.Code, http.StatusBadRequest)
+		_, err := respsig.Verify(w.Header(), req, w.Code, w.Body.Bytes(), signer.PeerID())
+		assert.NoError(t, err)
+
+		_, err = respsig.Verify(w.Header(), req, http.StatusOK, w.Body.Bytes(), signer.PeerID())
+		assert.ErrorIs(t, err, respsig.ErrInvalidSignature, "the status is part of the signed digest")
+	})
+
+	t.Run("unversioned routes are not signed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+		assert.Empty(t, w.Header().Get(respsig.HeaderSignature))
+	})
+}
+
+func TestServer_verifySignatureMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+  signature_max_skew_seconds: 60
+  allow_legacy_signatures: false
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, securityService)
+	router := chi.NewRouter()
+	router.With(server.verifySignatureMiddleware).Post("/api/v1/leases", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	body := []byte(`{"productId":"p"}`)
+	signed := func(target string, at time.Time) *http.Request {
+		req := httptest.NewRequest("POST", target, bytes.NewReader(body))
+		require.NoError(t, reqsig.Sign(priv, req, body, at))
+		return req
+	}
+	serve := func(req *http.Request) (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error.Code
+	}
+
+	fresh := signed("/api/v1/leases?b=2&a=1", time.Now())
+	replay := fresh.Clone(fresh.Context())
+	replay.Body = io.NopCloser(bytes.NewReader(body))
+
+	code, _ := serve(fresh)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	code, errCode := serve(replay)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, ErrorCodeReplayDetected, errCode)
+
+	code, errCode = serve(signed("/api/v1/leases", time.Now().Add(-5*time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, ErrorCodeStaleRequest, errCode)
+
+	tampered := signed("/api/v1/leases?b=2&a=1", time.Now())
+	tampered.URL.RawQuery = "a=1&b=3"
+	code, _ = serve(tampered)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	legacy := signed("/api/v1/leases", time.Now())
+	legacy.Header.Del(reqsig.HeaderVersion)
+	code, errCode = serve(legacy)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, ErrorCodeUnauthorized, errCode)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"time"
+
+	"pandacea/agent-backend/internal/tenant"
+)
+
+// SetTenants hosts several earners on this agent: lease proposals are
+// attributed to the tenant owning their product, count towards its lease
+// quota, and GET /api/v1/earnings can be narrowed to one tenant
+func (server *Server) SetTenants(registry *tenant.Registry) {
+	server.tenants = registry
+}
+
+// lookupTenant returns the tenant with id, if tenants are configured
+func (server *Server) lookupTenant(id string) (tenant.Tenant, bool) {
+	if server.tenants == nil {
+		return tenant.Tenant{}, false
+	}
+	return server.tenants.Get(id)
+}
+
+// productTenant returns the tenant owning productID, if tenants are configured
+func (server *Server) productTenant(productID string) (tenant.Tenant, bool) {
+	if server.tenants == nil {
+		return tenant.Tenant{}, false
+	}
+	return server.tenants.ForProduct(productID)
+}
+
+// earnerTenant returns the ID of the tenant paid at earnerAddr, or "" if
+// there is none
+func (server *Server) earnerTenant(earnerAddr string) string {
+	if server.tenants == nil || earnerAddr == "" {
+		return ""
+	}
+	owner, _ := server.tenants.ForEarner(earnerAddr)
+	return owner.ID
+}
+
+// reserveLease creates the pending state of a new lease proposal for
+// productID. It refuses, returning the tenant, when the tenant owning the
+// product already has max_active_leases pending or approved leases.
+func (server *Server) reserveLease(leaseProposalID, productID string) (tenant.Tenant, bool) {
+	owner, _ := server.productTenant(productID)
+
+	server.leasesMutex.Lock()
+	defer server.leasesMutex.Unlock()
+
+	// Counting and creating under one lock keeps concurrent requests from
+	// overshooting the quota
+	if owner.MaxActiveLeases > 0 {
+		active := 0
+		for _, state := range server.pendingLeases {
+			if state.Tenant == owner.ID && (state.Status == "pending" || state.Status == "approved") {
+				active++
+			}
+		}
+		if active >= owner.MaxActiveLeases {
+			return owner, false
+		}
+	}
+
+	now := time.Now()
+	server.pendingLeases[leaseProposalID] = &LeaseProposalState{
+		Status:    "pending",
+		CreatedAt: now,
+		UpdatedAt: now,
+		Tenant:    owner.ID,
+	}
+	server.logger.Info("lease status updated",
+		"lease_proposal_id", leaseProposalID,
+		"status", "pending",
+		"tenant", owner.ID,
+	)
+	return owner, true
+}
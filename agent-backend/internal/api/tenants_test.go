@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/tenant"
+)
+
+func TestServer_Tenants(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetTenants(tenant.NewRegistry([]config.TenantConfig{
+		{ID: "alice", Earner: "0xaaaa000000000000000000000000000000000001", Namespaces: []string{"did:pandacea:earner:alice"}, MaxActiveLeases: 1},
+		{ID: "bob", Earner: "0xbbbb000000000000000000000000000000000002", Namespaces: []string{"did:pandacea:earner:bob"}},
+	}))
+
+	createLease := func(productID string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(LeaseRequest{ProductID: productID, MaxPrice: "0.01", Duration: "24h"})
+		w := httptest.NewRecorder()
+		server.handleCreateLease(w, httptest.NewRequest("POST", "/api/v1/leases", bytes.NewBuffer(body)))
+		return w
+	}
+
+	w := createLease("did:pandacea:earner:alice/weather")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var created LeaseResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "alice", server.pendingLeases[created.LeaseProposalID].Tenant)
+
+	// Alice is at her quota, Bob has none
+	w = createLease("did:pandacea:earner:alice/traffic")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), ErrorCodeQuotaExceeded)
+	assert.Equal(t, http.StatusAccepted, createLease("did:pandacea:earner:bob/traffic").Code)
+	assert.Equal(t, http.StatusAccepted, createLease("did:pandacea:earner:bob/weather").Code)
+
+	// An expired lease frees its slot
+	server.UpdateLeaseStatus(created.LeaseProposalID, "expired", nil, "", "", nil)
+	assert.Equal(t, http.StatusAccepted, createLease("did:pandacea:earner:alice/traffic").Code)
+
+	// Leases approved on-chain are attributed by earner
+	server.UpdateLeaseStatus("lease_prop_01", "approved", nil, "0xspender", "0xBBBB000000000000000000000000000000000002", nil)
+	assert.Equal(t, "bob", server.pendingLeases["lease_prop_01"].Tenant)
+}
+
+func TestServer_handleGetEarnings_Tenant(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	policyEngine, err := policy.NewEngine(logger, createTestServerConfig())
+	require.NoError(t, err)
+	server := NewServer(policyEngine, logger, &p2p.Node{}, nil, nil)
+	server.SetTenants(tenant.NewRegistry([]config.TenantConfig{
+		{ID: "alice", Earner: "0xaaaa000000000000000000000000000000000001", Namespaces: []string{"did:pandacea:earner:alice"}},
+	}))
+	ledger, err := earnings.NewLedger(0, "")
+	require.NoError(t, err)
+	server.SetEarnings(ledger)
+
+	w := httptest.NewRecorder()
+	server.handleGetEarnings(w, httptest.NewRequest("GET", "/api/v1/earnings?tenant=alice", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp EarningsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "alice", resp.Tenant)
+
+	w = httptest.NewRecorder()
+	server.handleGetEarnings(w, httptest.NewRequest("GET", "/api/v1/earnings?tenant=carol", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
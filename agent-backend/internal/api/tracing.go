@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths are polled by probes and scrapers, so tracing them would
+// only bury request traces
+var untracedPaths = map[string]bool{
+	"/metrics": true,
+	"/health":  true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// tracingMiddleware starts a server span for each request, continuing the
+// caller's trace from its traceparent header. Once chi has matched the
+// route the span is renamed after its pattern, so job and lease IDs in
+// paths do not make every span name unique.
+func tracingMiddleware(next http.Handler) http.Handler {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(attribute.String("http.route", pattern))
+			}
+		}
+	})
+	return otelhttp.NewHandler(routed, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+	)
+}
+
+// evaluatePolicy evaluates req with the policy engine in a span of its own
+func (server *Server) evaluatePolicy(ctx context.Context, req *policy.Request) *policy.EvaluationResult {
+	ctx, span := telemetry.StartSpan(ctx, "policy.evaluate",
+		attribute.String("pandacea.product_id", req.ProductID),
+		attribute.Bool("pandacea.transfer", req.Transfer),
+	)
+	defer span.End()
+
+	evaluation := server.policy.EvaluateRequest(ctx, req)
+	span.SetAttributes(attribute.Bool("pandacea.policy.allowed", evaluation.Allowed))
+	if !evaluation.Allowed {
+		span.SetAttributes(attribute.String("pandacea.policy.reason", evaluation.Reason))
+	}
+	return evaluation
+}
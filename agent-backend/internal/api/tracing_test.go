@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// denyEvaluator rejects every request with a fixed reason
+type denyEvaluator struct{}
+
+func (denyEvaluator) EvaluateRequest(ctx context.Context, req *policy.Request) *policy.EvaluationResult {
+	return &policy.EvaluationResult{Allowed: false, Reason: "price below minimum"}
+}
+
+func TestServer_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	server := NewServer(denyEvaluator{}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &p2p.Node{}, nil, nil)
+
+	t.Run("request span continues the caller's trace", func(t *testing.T) {
+		recorder.Reset()
+		const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		req := httptest.NewRequest("GET", "/aggregate/job_42", nil)
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		server.router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET /aggregate/{jobId}", spans[0].Name())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+		assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+		assert.Contains(t, spans[0].Attributes(), attribute.String("http.route", "/aggregate/{jobId}"))
+	})
+
+	t.Run("probes are not traced", func(t *testing.T) {
+		recorder.Reset()
+		server.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+		assert.Empty(t, recorder.Ended())
+	})
+
+	t.Run("policy evaluation span", func(t *testing.T) {
+		recorder.Reset()
+		evaluation := server.evaluatePolicy(context.Background(), &policy.Request{ProductID: "did:pandacea:earner:1"})
+		assert.False(t, evaluation.Allowed)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "policy.evaluate", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), attribute.String("pandacea.product_id", "did:pandacea:earner:1"))
+		assert.Contains(t, spans[0].Attributes(), attribute.Bool("pandacea.policy.allowed", false))
+		assert.Contains(t, spans[0].Attributes(), attribute.String("pandacea.policy.reason", "price below minimum"))
+	})
+}
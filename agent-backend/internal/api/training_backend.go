@@ -0,0 +1,494 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/telemetry"
+)
+
+// maxRemoteArtifactBytes caps the artifact a remote training service returns
+const maxRemoteArtifactBytes = 64 << 20
+
+// initialModelFile is where the local worker reads a federation round's
+// global model from, in the run's output directory
+const initialModelFile = "initial_model.b64"
+
+// TrainingBackend runs training jobs. A backend writes the job's artifact
+// to aggregate.json in the run's output directory; the server then
+// accounts, watermarks, signs and publishes it the same way whichever
+// backend produced it.
+type TrainingBackend interface {
+	// Name identifies the backend in job failures, traces and /readyz
+	Name() string
+	// Train runs a job to completion
+	Train(ctx context.Context, run *TrainingRun) error
+	// Health reports whether the backend can run jobs now
+	Health(ctx context.Context) error
+}
+
+// TrainingRun is a training job handed to a TrainingBackend, with the
+// hooks a backend reports the job's output and progress through
+type TrainingRun struct {
+	JobID   string
+	Dataset string
+	Task    string
+	Epsilon float64
+	// NoiseMultiplier is the DP-SGD noise the worker adds, calibrated so the
+	// training schedule spends no more than Epsilon; zero without DP
+	NoiseMultiplier float64
+	Federated       bool      // The job trains a round of a federation
+	Model           []float64 // Global model a federation round starts from; nil for a fresh model
+	OutputDir       string    // Where the backend writes aggregate.json
+	// CheckpointDir is where the worker keeps checkpoints and their
+	// manifest; empty for runs that are not checkpointed
+	CheckpointDir string
+	// Resume is the checkpoint the run continues from; nil to start fresh
+	Resume *TrainingCheckpoint
+	// GPUs are the indexes of the GPUs the run holds; nil for a CPU job
+	GPUs []int
+
+	server *Server
+}
+
+// The DP-SGD schedule every worker trains with. The server calibrates a
+// run's noise to it before the run starts and accounts the artifact against
+// the same numbers, so the two must not drift apart.
+const (
+	trainingSamples   = 1000
+	trainingBatchSize = 32
+	trainingEpochs    = 10
+)
+
+// calibrateNoise sets the noise multiplier that keeps the training schedule
+// within the run's declared epsilon
+func (run *TrainingRun) calibrateNoise() error {
+	if run.Epsilon <= 0 {
+		run.NoiseMultiplier = 0
+		return nil
+	}
+	sigma, err := privacy.CalibrateNoiseMultiplier(run.Epsilon, float64(trainingBatchSize)/trainingSamples,
+		trainingEpochs*(trainingSamples/trainingBatchSize), privacy.DefaultDPDelta)
+	if err != nil {
+		return fmt.Errorf("failed to calibrate DP noise: %w", err)
+	}
+	run.NoiseMultiplier = sigma
+	return nil
+}
+
+// dp is the DP-SGD configuration the workers read
+func (run *TrainingRun) dp() map[string]any {
+	return map[string]any{
+		"enabled":          run.Epsilon > 0,
+		"epsilon":          run.Epsilon,
+		"clip":             1.0,
+		"noise_multiplier": run.NoiseMultiplier,
+		"delta":            privacy.DefaultDPDelta,
+	}
+}
+
+// AggregatePath is where the run's artifact is written
+func (run *TrainingRun) AggregatePath() string {
+	return filepath.Join(run.OutputDir, "aggregate.json")
+}
+
+// Log appends a line to the job's log
+func (run *TrainingRun) Log(stream, line string) {
+	run.server.jobLog(run.JobID).append(stream, line)
+}
+
+// Progress records the job's latest progress and streams it to its owner
+func (run *TrainingRun) Progress(p TrainingProgress) {
+	run.server.recordProgress(run.JobID, p)
+}
+
+// RunWorker runs a worker process to completion in a span of its own. Its
+// output becomes the job's log and the progress lines it prints update the
+// job.
+func (run *TrainingRun) RunWorker(ctx context.Context, cmd *exec.Cmd) error {
+	return run.server.runTracedWorker(ctx, run.JobID, cmd)
+}
+
+// RecordUsage keeps the CPU time and peak memory of a worker process that
+// ran the job on this host, for the job's bill
+func (run *TrainingRun) RecordUsage(state *os.ProcessState) {
+	run.server.recordWorkerUsage(run.JobID, state)
+}
+
+// payload is the job as the Docker worker and remote services read it
+func (run *TrainingRun) payload(outputDir string) map[string]any {
+	payload := map[string]any{
+		"job_id":     run.JobID,
+		"dataset":    run.Dataset,
+		"task":       run.Task,
+		"epsilon":    run.Epsilon,
+		"dp":         run.dp(),
+		"epochs":     trainingEpochs,
+		"batch_size": trainingBatchSize,
+		"output_dir": outputDir,
+	}
+	if run.Federated {
+		payload["federated"] = true
+	}
+	if run.Model != nil {
+		payload["initial_model"] = encodeModel(run.Model)
+	}
+	if len(run.GPUs) > 0 {
+		payload["gpus"] = run.GPUs
+	}
+	return payload
+}
+
+// checkpointPayload adds where the Docker worker keeps checkpoints and
+// the one it resumes from, with dataDir the container path of ./data
+func (run *TrainingRun) checkpointPayload(payload map[string]any, dataDir string) {
+	if run.CheckpointDir == "" {
+		return
+	}
+	rel, err := filepath.Rel("data", filepath.Clean(run.CheckpointDir))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	dir := path.Join(dataDir, filepath.ToSlash(rel))
+	payload["checkpoint_dir"] = dir
+	if run.Resume != nil {
+		payload["resume_from"] = path.Join(dir, run.Resume.File)
+	}
+}
+
+// NewTrainingBackend returns the backend cfg.ExecutionMode selects, or the
+// local Python backend for an unknown mode
+func NewTrainingBackend(cfg config.TrainingConfig) TrainingBackend {
+	switch cfg.ExecutionMode {
+	case config.ExecutionModeMock:
+		return MockTrainingBackend{}
+	case config.ExecutionModeDocker:
+		return DockerTrainingBackend{ComposeFile: cfg.ComposeFile, Service: cfg.ComposeService}
+	case config.ExecutionModeRemote:
+		return RemoteTrainingBackend{
+			URL:     cfg.Remote.URL,
+			Token:   cfg.Remote.Token,
+			Timeout: time.Duration(cfg.Remote.TimeoutMinutes) * time.Minute,
+		}
+	default:
+		return LocalTrainingBackend{Python: cfg.Python, Worker: cfg.Worker}
+	}
+}
+
+// SetTrainingBackend runs training jobs with backend from now on, for ML
+// runtimes that have no execution mode of their own
+func (server *Server) SetTrainingBackend(backend TrainingBackend) {
+	server.trainingBackend = backend
+}
+
+// MockTrainingBackend writes synthetic results without PySyft, simulating
+// a second of training per epoch and checkpointing after each; for
+// development only
+type MockTrainingBackend struct{}
+
+// Name implements TrainingBackend
+func (MockTrainingBackend) Name() string { return config.ExecutionModeMock }
+
+// Health implements TrainingBackend
+func (MockTrainingBackend) Health(context.Context) error { return nil }
+
+// Train implements TrainingBackend
+func (MockTrainingBackend) Train(ctx context.Context, run *TrainingRun) error {
+	const samples, epochs = trainingSamples, trainingEpochs
+
+	start := 1
+	if run.Resume != nil {
+		start = run.Resume.Epoch + 1
+		run.Log("stdout", fmt.Sprintf("Resuming at epoch %d from %s", start, run.Resume.File))
+	}
+	run.Log("stdout", fmt.Sprintf("Simulating %d epochs on %s", epochs, run.Dataset))
+	for epoch := start; epoch <= epochs; epoch++ {
+		time.Sleep(mockEpochDelay)
+		loss := 0.7 / float64(epoch)
+		run.Log("stdout", fmt.Sprintf("Epoch %d/%d, Loss: %.4f", epoch, epochs, loss))
+		run.Progress(TrainingProgress{
+			Epoch:     epoch,
+			Epochs:    epochs,
+			Loss:      &loss,
+			Samples:   epoch * samples,
+			UpdatedAt: time.Now().UTC(),
+		})
+		state, _ := json.Marshal(map[string]any{"epoch": epoch, "loss": loss})
+		if err := run.SaveCheckpoint(epoch, epoch*samples, state); err != nil {
+			return err
+		}
+	}
+	result := map[string]interface{}{
+		"job_id":                run.JobID,
+		"dataset":               run.Dataset,
+		"task":                  run.Task,
+		"epsilon_used":          run.Epsilon,
+		"model_accuracy":        0.85 + (float64(time.Now().UnixNano()%100) / 1000.0), // Random accuracy
+		"samples_processed":     samples,
+		"training_time_seconds": 10.0,
+		"dp_noise_scale":        run.NoiseMultiplier,
+		"timestamp":             time.Now().Format(time.RFC3339),
+		"n":                     samples,
+		"dp":                    run.dp(),
+		"training_params": map[string]interface{}{
+			"epochs":     epochs,
+			"batch_size": trainingBatchSize,
+		},
+	}
+	if run.Federated {
+		result["model"] = encodeModel(mockRoundModel(run.Model))
+	}
+
+	resultBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if err := os.WriteFile(run.AggregatePath(), resultBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// LocalTrainingBackend runs the PySyft worker with the local Python
+type LocalTrainingBackend struct {
+	Python string // Defaults to python
+	Worker string // Defaults to ./worker/train_worker.py
+}
+
+// Name implements TrainingBackend
+func (LocalTrainingBackend) Name() string { return config.ExecutionModeLocal }
+
+// command returns the interpreter and worker script, defaulted
+func (b LocalTrainingBackend) command() (python, worker string) {
+	python, worker = b.Python, b.Worker
+	if python == "" {
+		python = "python"
+	}
+	if worker == "" {
+		worker = "./worker/train_worker.py"
+	}
+	return python, worker
+}
+
+// Health implements TrainingBackend
+func (b LocalTrainingBackend) Health(context.Context) error {
+	python, worker := b.command()
+	if _, err := exec.LookPath(python); err != nil {
+		return fmt.Errorf("python interpreter not found: %w", err)
+	}
+	if _, err := os.Stat(worker); err != nil {
+		return fmt.Errorf("worker script not found: %w", err)
+	}
+	return nil
+}
+
+// Train implements TrainingBackend
+func (b LocalTrainingBackend) Train(ctx context.Context, run *TrainingRun) error {
+	python, worker := b.command()
+	cmd := exec.Command(python, worker,
+		"--job-id", run.JobID,
+		"--dataset", run.Dataset,
+		"--task", run.Task,
+		"--epsilon", fmt.Sprintf("%f", run.Epsilon),
+		"--noise-multiplier", strconv.FormatFloat(run.NoiseMultiplier, 'g', -1, 64),
+		"--epochs", strconv.Itoa(trainingEpochs),
+		"--batch-size", strconv.Itoa(trainingBatchSize),
+		"--output-dir", run.OutputDir,
+	)
+	if run.CheckpointDir != "" {
+		cmd.Args = append(cmd.Args, "--checkpoint-dir", run.CheckpointDir)
+	}
+	if resume := run.ResumePath(); resume != "" {
+		cmd.Args = append(cmd.Args, "--resume-from", resume)
+	}
+	if run.Federated {
+		cmd.Args = append(cmd.Args, "--federated")
+	}
+	if run.Model != nil {
+		// The global model is too large to pass as an argument. It is not
+		// part of the job's output, so it is removed once the worker exits.
+		path := filepath.Join(run.OutputDir, initialModelFile)
+		if err := fsutil.WriteFile(path, []byte(encodeModel(run.Model)), 0600); err != nil {
+			return fmt.Errorf("failed to write initial model: %w", err)
+		}
+		defer os.Remove(path)
+		cmd.Args = append(cmd.Args, "--initial-model-file", path)
+	}
+	cmd.Env = append(os.Environ(), telemetry.Environ(ctx)...)
+	if len(run.GPUs) > 0 {
+		cmd.Env = append(cmd.Env, "CUDA_VISIBLE_DEVICES="+gpu.VisibleDevices(run.GPUs))
+	}
+
+	err := run.RunWorker(ctx, cmd)
+	run.RecordUsage(cmd.ProcessState)
+	return err
+}
+
+// DockerTrainingBackend runs the PySyft worker with docker compose, handing
+// it the job on stdin
+type DockerTrainingBackend struct {
+	ComposeFile string // Defaults to docker-compose.pysyft.yml
+	Service     string // Defaults to pysyft-worker
+}
+
+// Name implements TrainingBackend
+func (DockerTrainingBackend) Name() string { return config.ExecutionModeDocker }
+
+// compose returns the compose file and worker service, defaulted
+func (b DockerTrainingBackend) compose() (file, service string) {
+	file, service = b.ComposeFile, b.Service
+	if file == "" {
+		file = "docker-compose.pysyft.yml"
+	}
+	if service == "" {
+		service = "pysyft-worker"
+	}
+	return file, service
+}
+
+// Health implements TrainingBackend. It checks the compose file parses
+// and defines the worker service.
+func (b DockerTrainingBackend) Health(ctx context.Context) error {
+	file, service := b.compose()
+	output, err := exec.CommandContext(ctx, "docker", "compose", "-f", file, "config", "--services").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker compose unavailable: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	if !slices.Contains(strings.Fields(string(output)), service) {
+		return fmt.Errorf("%s defines no %s service", file, service)
+	}
+	return nil
+}
+
+// Train implements TrainingBackend
+func (b DockerTrainingBackend) Train(ctx context.Context, run *TrainingRun) error {
+	payload := run.payload("/app/data")
+	run.checkpointPayload(payload, "/app/data")
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	// Hand the container the trace context so the worker's spans join the
+	// job's trace
+	file, service := b.compose()
+	args := []string{"compose", "-f", file, "run", "--rm"}
+	for _, env := range telemetry.Environ(ctx) {
+		args = append(args, "-e", env)
+	}
+	if len(run.GPUs) > 0 {
+		// The NVIDIA runtime exposes only the GPUs the job holds
+		args = append(args, "-e", "NVIDIA_VISIBLE_DEVICES="+gpu.VisibleDevices(run.GPUs))
+	}
+	cmd := exec.Command("docker", append(args, service)...)
+	cmd.Stdin = bytes.NewReader(payloadBytes)
+	return run.RunWorker(ctx, cmd)
+}
+
+// RemoteTrainingBackend sends jobs to a training service over HTTP. The
+// job is POSTed to <URL>/train as the JSON the Docker worker reads on
+// stdin, and the service answers once the job is done with the artifact
+// as the response body. GET <URL>/health answering 200 means it is up.
+type RemoteTrainingBackend struct {
+	URL     string
+	Token   string        // Sent as a bearer token if set
+	Timeout time.Duration // Longest a job may take; zero waits indefinitely
+	Client  *http.Client  // Defaults to a client that propagates the trace context
+}
+
+// Name implements TrainingBackend
+func (RemoteTrainingBackend) Name() string { return config.ExecutionModeRemote }
+
+// client returns the HTTP client requests are sent with
+func (b RemoteTrainingBackend) client() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+	return &http.Client{Transport: telemetry.Transport(nil)}
+}
+
+// request builds a request to the service
+func (b RemoteTrainingBackend) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(b.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+	return req, nil
+}
+
+// Health implements TrainingBackend
+func (b RemoteTrainingBackend) Health(ctx context.Context) error {
+	req, err := b.request(ctx, http.MethodGet, "/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("training service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("training service health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Train implements TrainingBackend. The service shares no filesystem with
+// the agent, so remote jobs are not checkpointed and cannot be resumed.
+func (b RemoteTrainingBackend) Train(ctx context.Context, run *TrainingRun) error {
+	payloadBytes, err := json.Marshal(run.payload(""))
+	if err != nil {
+		return fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	req, err := b.request(ctx, http.MethodPost, "/train", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	run.Log("stdout", "Sent job to training service "+b.URL)
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("training service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	artifact, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteArtifactBytes+1))
+	if err != nil {
+		return fmt.Errorf("failed to read training service response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("training service returned %s: %s", resp.Status, strings.TrimSpace(string(artifact)))
+	}
+	if len(artifact) > maxRemoteArtifactBytes {
+		return fmt.Errorf("training service artifact exceeds %d bytes", maxRemoteArtifactBytes)
+	}
+	if !json.Valid(artifact) {
+		return fmt.Errorf("training service returned an artifact that is not JSON")
+	}
+	run.Log("stdout", fmt.Sprintf("Received %d byte artifact from training service", len(artifact)))
+	return os.WriteFile(run.AggregatePath(), artifact, 0644)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrainingBackend(t *testing.T) {
+	tests := map[string]string{
+		config.ExecutionModeMock:   "api.MockTrainingBackend",
+		config.ExecutionModeLocal:  "api.LocalTrainingBackend",
+		config.ExecutionModeDocker: "api.DockerTrainingBackend",
+		config.ExecutionModeRemote: "api.RemoteTrainingBackend",
+	}
+	for mode, want := range tests {
+		backend := NewTrainingBackend(config.TrainingConfig{ExecutionMode: mode})
+		assert.Equal(t, mode, backend.Name())
+		assert.Equal(t, want, fmt.Sprintf("%T", backend))
+	}
+	assert.NoError(t, MockTrainingBackend{}.Health(context.Background()))
+	assert.Error(t, LocalTrainingBackend{Python: "python", Worker: "./no/such/worker.py"}.Health(context.Background()))
+}
+
+func TestRemoteTrainingBackend(t *testing.T) {
+	var received map[string]any
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/train":
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if received["dataset"] == "broken" {
+				http.Error(w, "dataset unavailable", http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"n": 1000, "training_params": {"epochs": 1, "batch_size": 10}}`))
+		}
+	}))
+	defer service.Close()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	server.SetTrainingConfig(config.TrainingConfig{
+		ExecutionMode: config.ExecutionModeRemote,
+		Remote:        config.RemoteTrainingConfig{URL: service.URL + "/", Token: "secret", TimeoutMinutes: 1},
+	})
+	backend := server.trainingBackend
+	require.NoError(t, backend.Health(context.Background()))
+
+	run := &TrainingRun{JobID: "job-1", Dataset: "mnist", Task: "classify", Epsilon: 1, Model: []float64{0.5}, OutputDir: t.TempDir(), server: server}
+	require.NoError(t, backend.Train(context.Background(), run))
+	assert.Equal(t, "job-1", received["job_id"])
+	assert.Equal(t, encodeModel([]float64{0.5}), received["initial_model"])
+	artifact, err := os.ReadFile(run.AggregatePath())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n": 1000, "training_params": {"epochs": 1, "batch_size": 10}}`, string(artifact))
+	lines, _, _ := server.jobLog("job-1").since(0, 10)
+	assert.NotEmpty(t, lines, "the job log records the exchange with the service")
+
+	run.Dataset = "broken"
+	assert.ErrorContains(t, backend.Train(context.Background(), run), "dataset unavailable")
+
+	unauthorized := RemoteTrainingBackend{URL: service.URL, Timeout: time.Minute}
+	assert.Error(t, unauthorized.Health(context.Background()))
+}
+
+func TestLocalTrainingBackendCalibratesNoise(t *testing.T) {
+	// The worker stands in for train_worker.py, writing an artifact with the
+	// DP-SGD parameters it was started with
+	dir := t.TempDir()
+	worker := filepath.Join(dir, "worker.sh")
+	require.NoError(t, os.WriteFile(worker, []byte(`
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --noise-multiplier) sigma=$2 ;;
+    --epochs) epochs=$2 ;;
+    --batch-size) batch=$2 ;;
+    --output-dir) out=$2 ;;
+  esac
+  shift 2
+done
+printf '{"n": 1000, "dp": {"clip": 1.0, "noise_multiplier": %s}, "training_params": {"epochs": %s, "batch_size": %s}}' \
+  "$sigma" "$epochs" "$batch" > "$out/aggregate.json"
+`), 0755))
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	for _, epsilon := range []float64{0.5, 3} {
+		run := &TrainingRun{JobID: "job-1", Dataset: "mnist", Task: "classify", Epsilon: epsilon, OutputDir: t.TempDir(), server: server}
+		require.NoError(t, run.calibrateNoise())
+		assert.Greater(t, run.NoiseMultiplier, 0.5, "the worker's default noise overspends epsilon %v", epsilon)
+		require.NoError(t, LocalTrainingBackend{Python: "sh", Worker: worker}.Train(context.Background(), run))
+
+		report, err := server.accountTrainingArtifact(run.AggregatePath(), epsilon)
+		require.NoError(t, err)
+		assert.True(t, report.WithinBudget, "epsilon %v: spent %v", epsilon, report.Epsilon)
+		assert.InDelta(t, epsilon, report.Epsilon, 0.01, "the calibrated noise spends close to the whole budget")
+	}
+
+	// A federation round hands the local worker the global model in a file
+	federated := filepath.Join(dir, "federated.sh")
+	require.NoError(t, os.WriteFile(federated, []byte(`
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --federated) shift; continue ;;
+    --initial-model-file) model=$(cat "$2") ;;
+    --output-dir) out=$2 ;;
+  esac
+  shift 2
+done
+printf '{"model": "%s", "n": 1000}' "$model" > "$out/aggregate.json"
+`), 0755))
+	round := &TrainingRun{JobID: "job-3", Federated: true, Model: []float64{1, 2}, OutputDir: t.TempDir(), server: server}
+	require.NoError(t, LocalTrainingBackend{Python: "sh", Worker: federated}.Train(context.Background(), round))
+	update, err := server.readRoundUpdate(round.AggregatePath())
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, update.Weights)
+	assert.NoFileExists(t, filepath.Join(round.OutputDir, initialModelFile), "the global model is not left in the job's output")
+
+	// The Docker and remote workers read the same parameters from the payload
+	run := &TrainingRun{JobID: "job-2", Epsilon: 1}
+	require.NoError(t, run.calibrateNoise())
+	dp := run.payload("/app/data")["dp"].(map[string]any)
+	assert.Equal(t, run.NoiseMultiplier, dp["noise_multiplier"])
+	assert.Equal(t, 1.0, dp["epsilon"])
+	payload := round.payload("/app/data")
+	assert.Equal(t, true, payload["federated"])
+	assert.Equal(t, encodeModel(round.Model), payload["initial_model"])
+}
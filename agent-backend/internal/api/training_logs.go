@@ -0,0 +1,381 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// maxJobLogLines is the number of recent worker output lines kept per job
+	maxJobLogLines = 5000
+	// maxLogLineBytes truncates longer output lines, such as artifacts
+	// printed on a single line
+	maxLogLineBytes = 8 << 10
+	// maxJobLogPage is the most lines returned by one logs request
+	maxJobLogPage = 1000
+)
+
+// mockEpochDelay is how long mock training spends on each epoch
+var mockEpochDelay = time.Second
+
+// TrainingProgress is the latest progress a training worker reported.
+// Workers report it by printing a single-line JSON object with "type"
+// set to "progress" on stdout or stderr.
+type TrainingProgress struct {
+	Epoch     int       `json:"epoch"`
+	Epochs    int       `json:"epochs,omitempty"`
+	Loss      *float64  `json:"loss,omitempty"`
+	Samples   int       `json:"samples_processed"` // Samples processed so far
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobLogLine is one line of a training worker's output
+type JobLogLine struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"` // stdout or stderr
+	Line   string    `json:"line"`
+}
+
+// JobLogsResponse is a page of a training job's worker output
+type JobLogsResponse struct {
+	Lines []JobLogLine `json:"lines"`
+	Next  uint64       `json:"next"` // Pass as since to get the lines after this page
+	Done  bool         `json:"done"` // The job has finished and no lines follow
+}
+
+// jobLog keeps the recent output of one job's worker
+type jobLog struct {
+	mu       sync.Mutex
+	lines    []JobLogLine
+	seq      uint64
+	done     bool
+	appended chan struct{}
+}
+
+// append adds a line and wakes followers
+func (l *jobLog) append(stream, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	l.lines = append(l.lines, JobLogLine{Seq: l.seq, Time: time.Now().UTC(), Stream: stream, Line: line})
+	if len(l.lines) > maxJobLogLines {
+		l.lines = append(l.lines[:0], l.lines[len(l.lines)-maxJobLogLines:]...)
+	}
+	l.wake()
+}
+
+// finish marks the log complete and wakes followers
+func (l *jobLog) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = true
+	l.wake()
+}
+
+// reopen marks a finished log live again, for a job that was resumed
+func (l *jobLog) reopen() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = false
+}
+
+// wake closes the appended channel. Caller must hold mu.
+func (l *jobLog) wake() {
+	if l.appended != nil {
+		close(l.appended)
+		l.appended = nil
+	}
+}
+
+// since returns up to limit lines after seq, whether the log is complete,
+// and a channel closed when the log next changes. Lines that have been
+// evicted are skipped.
+func (l *jobLog) since(seq uint64, limit int) ([]JobLogLine, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var page []JobLogLine
+	for _, line := range l.lines {
+		if line.Seq <= seq {
+			continue
+		}
+		if len(page) == limit {
+			return page, false, nil
+		}
+		page = append(page, line)
+	}
+	if l.appended == nil {
+		l.appended = make(chan struct{})
+	}
+	return page, l.done, l.appended
+}
+
+// jobLog returns a job's log, creating it on first use
+func (server *Server) jobLog(jobID string) *jobLog {
+	server.jobLogsMutex.Lock()
+	defer server.jobLogsMutex.Unlock()
+
+	log, exists := server.jobLogs[jobID]
+	if !exists {
+		log = &jobLog{}
+		server.jobLogs[jobID] = log
+	}
+	return log
+}
+
+// runTracedWorker runs a training worker with runWorker in a span of its own
+func (server *Server) runTracedWorker(ctx context.Context, jobID string, cmd *exec.Cmd) error {
+	_, span := telemetry.StartSpan(ctx, "training.worker",
+		attribute.String("pandacea.job_id", jobID),
+		attribute.StringSlice("process.command_args", cmd.Args),
+	)
+	err := server.runWorker(jobID, cmd)
+	telemetry.EndSpan(span, err)
+	return err
+}
+
+// runWorker runs a training worker to completion. Its output becomes the
+// job's log, and the progress lines it prints are sent to a progress
+// channel that updates the job.
+func (server *Server) runWorker(jobID string, cmd *exec.Cmd) error {
+	log := server.jobLog(jobID)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture worker output: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture worker output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start worker: %w", err)
+	}
+
+	progress := make(chan TrainingProgress, 16)
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		for p := range progress {
+			server.recordProgress(jobID, p)
+		}
+	}()
+
+	var readers sync.WaitGroup
+	for stream, r := range map[string]io.Reader{"stdout": stdout, "stderr": stderr} {
+		readers.Add(1)
+		go func(stream string, r io.Reader) {
+			defer readers.Done()
+			readLines(r, func(line string) {
+				log.append(stream, line)
+				if p, ok := parseProgress(line); ok {
+					progress <- p
+				}
+			})
+		}(stream, r)
+	}
+
+	// The pipes must be drained before Wait closes them
+	readers.Wait()
+	close(progress)
+	<-recorded
+	return cmd.Wait()
+}
+
+// readLines calls fn with each line read from r, truncating long lines
+func readLines(r io.Reader, fn func(string)) {
+	reader := bufio.NewReaderSize(r, maxLogLineBytes)
+	for {
+		line, err := reader.ReadSlice('\n')
+		text := strings.TrimRight(string(line), "\r\n")
+		if errors.Is(err, bufio.ErrBufferFull) {
+			text += " [truncated]"
+			// Discard the rest of the line
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = reader.ReadSlice('\n')
+			}
+		}
+		if text != "" || err == nil {
+			fn(text)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseProgress reads a worker progress line
+func parseProgress(line string) (TrainingProgress, bool) {
+	if !strings.HasPrefix(line, "{") || !strings.Contains(line, `"progress"`) {
+		return TrainingProgress{}, false
+	}
+	var event struct {
+		Type string `json:"type"`
+		TrainingProgress
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Type != "progress" || event.Epoch <= 0 {
+		return TrainingProgress{}, false
+	}
+	event.UpdatedAt = time.Now().UTC()
+	return event.TrainingProgress, true
+}
+
+// recordProgress stores a job's latest progress and streams it to the
+// job's owner
+func (server *Server) recordProgress(jobID string, p TrainingProgress) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+
+	job, exists := server.jobs[jobID]
+	if !exists || trainingJobs.IsTerminal(jobs.State(job.Status)) {
+		return
+	}
+	job.Progress = &p
+	server.persistJob(job)
+	server.publishJobProgress(job)
+}
+
+// finishJobLog wakes followers of a job that has finished
+func (server *Server) finishJobLog(jobID string) {
+	server.jobLogsMutex.Lock()
+	log, exists := server.jobLogs[jobID]
+	server.jobLogsMutex.Unlock()
+	if exists {
+		log.finish()
+	}
+}
+
+// handleTrainingLogs handles GET /api/v1/train/{jobId}/logs. It returns a
+// page of the worker's output after since, or with follow=true streams the
+// output as server-sent events until the job finishes.
+func (server *Server) handleTrainingLogs(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobId")
+	server.jobsMutex.RLock()
+	job, exists := server.jobs[jobID]
+	finished := exists && trainingJobs.IsTerminal(jobs.State(job.Status))
+	server.jobsMutex.RUnlock()
+	if !exists {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Job not found")
+		return
+	}
+
+	query := r.URL.Query()
+	since := query.Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		since = id
+	}
+	var seq uint64
+	if since != "" {
+		var err error
+		if seq, err = strconv.ParseUint(since, 10, 64); err != nil {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "since must be a line number")
+			return
+		}
+	}
+	limit := maxJobLogPage
+	if param := query.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > maxJobLogPage {
+			server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError,
+				fmt.Sprintf("limit must be between 1 and %d", maxJobLogPage))
+			return
+		}
+		limit = n
+	}
+
+	log := server.jobLog(jobID)
+	if finished {
+		// Jobs restored from the store, or failed before their worker ran,
+		// have no log to wait for
+		log.finish()
+	}
+
+	if query.Get("follow") != "true" {
+		lines, done, _ := log.since(seq, limit)
+		response := JobLogsResponse{Lines: lines, Next: seq, Done: done}
+		if response.Lines == nil {
+			response.Lines = []JobLogLine{}
+		}
+		if len(lines) > 0 {
+			response.Next = lines[len(lines)-1].Seq
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			server.logger.Error("failed to encode job logs", "error", err, "job_id", jobID)
+		}
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		server.logger.Error("log stream not supported by response writer", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		lines, done, changed := log.since(seq, limit)
+		for _, line := range lines {
+			data, err := json.Marshal(line)
+			if err != nil {
+				server.logger.Error("failed to encode log line", "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", line.Seq, data)
+			seq = line.Seq
+		}
+		if changed == nil {
+			// More lines are waiting
+			continue
+		}
+		if done {
+			fmt.Fprint(w, "event: end\ndata: {}\n\n")
+			rc.Flush()
+			return
+		}
+		if len(lines) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditTransactionQueued records a transaction the agent queued
+const AuditTransactionQueued = "transaction.queued"
+
+// Transaction actions, recorded with each transaction they send
+const (
+	TxActionApproveLease  = "lease.approve"
+	TxActionExecuteLease  = "lease.execute"
+	TxActionFinalizeLease = "lease.finalize"
+
+	TxActionAnchorAttestation = "attestation.anchor"
+)
+
+// TransactionsResponse lists the agent's transactions
+type TransactionsResponse struct {
+	Data []txmgr.Record `json:"data"`
+}
+
+// SetTransactionManager lets operators approve and execute leases on the
+// LeaseAgreement contract at contract, sending through m
+func (server *Server) SetTransactionManager(m *txmgr.Manager, contract common.Address) {
+	server.transactions = m
+	server.txContract = contract
+}
+
+// handleApproveLease handles POST /api/v1/leases/{leaseId}/approve. Leases
+// proposed by a spender revoked since are not approved.
+func (server *Server) handleApproveLease(w http.ResponseWriter, r *http.Request) {
+	server.leasesMutex.RLock()
+	var spender string
+	if state, exists := server.pendingLeases[chainLeaseProposalID(chi.URLParam(r, "leaseId"))]; exists {
+		spender = state.SpenderAddr
+	}
+	server.leasesMutex.RUnlock()
+	if server.rejectRevoked(w, r, spender) {
+		return
+	}
+
+	server.sendLeaseTransaction(w, r, TxActionApproveLease, "approveLease")
+}
+
+// handleExecuteLease handles POST /api/v1/leases/{leaseId}/execute. When
+// products are delivered the lease's spender calls it and receives the
+// product; otherwise only admins may, to send executeLease.
+func (server *Server) handleExecuteLease(w http.ResponseWriter, r *http.Request) {
+	if server.deliveries != nil {
+		server.handleDeliverLease(w, r)
+		return
+	}
+	server.adminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.sendLeaseTransaction(w, r, TxActionExecuteLease, "executeLease")
+	})).ServeHTTP(w, r)
+}
+
+// handleFinalizeLease handles POST /api/v1/leases/{leaseId}/finalize,
+// sending finalizeLease once the lease's dispute window has passed
+func (server *Server) handleFinalizeLease(w http.ResponseWriter, r *http.Request) {
+	if !server.requireContractMethod(w, r, "finalizeLease") {
+		return
+	}
+	server.sendLeaseTransaction(w, r, TxActionFinalizeLease, "finalizeLease")
+}
+
+// sendLeaseTransaction queues a call of a LeaseAgreement method that takes
+// only the lease ID, and responds with the transaction's record
+func (server *Server) sendLeaseTransaction(w http.ResponseWriter, r *http.Request, action, method string) {
+	if server.transactions == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Sending transactions is not enabled")
+		return
+	}
+	id, ok := server.leaseIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	rec, err := server.queueLeaseTransaction(r, action, method, id)
+	if err != nil {
+		server.sendError(w, r, err, "Failed to queue transaction")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		server.logger.Error("failed to encode transaction", "error", err)
+	}
+}
+
+// leaseIDParam returns the on-chain lease ID in the request path. If it is
+// not 32 bytes of hex it responds with the error and returns false.
+func (server *Server) leaseIDParam(w http.ResponseWriter, r *http.Request) ([32]byte, bool) {
+	var id [32]byte
+	raw := common.FromHex(chi.URLParam(r, "leaseId"))
+	if len(raw) != 32 {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "Lease ID must be 32 bytes of hex")
+		return id, false
+	}
+	copy(id[:], raw)
+	return id, true
+}
+
+// queueLeaseTransaction queues a call of a LeaseAgreement method that takes
+// only the lease ID and records it in the audit log
+func (server *Server) queueLeaseTransaction(r *http.Request, action, method string, id [32]byte) (txmgr.Record, error) {
+	// The v2 ABI has every method of v1 under the same selectors
+	parsed, err := contracts.LeaseAgreementV2MetaData.GetAbi()
+	if err != nil {
+		server.logger.Error("failed to parse lease agreement ABI", "error", err)
+		return txmgr.Record{}, fmt.Errorf("failed to parse lease agreement ABI: %w", err)
+	}
+	data, err := parsed.Pack(method, id)
+	if err != nil {
+		server.logger.Error("failed to encode lease transaction", "error", err, "method", method)
+		return txmgr.Record{}, fmt.Errorf("failed to encode %s: %w", method, err)
+	}
+
+	rec, err := server.transactions.Submit(txmgr.Request{
+		Action:    action,
+		Reference: common.Hash(id).Hex(),
+		RequestID: middleware.GetReqID(r.Context()),
+		To:        server.txContract,
+		Data:      data,
+	})
+	if err != nil {
+		server.logger.Warn("failed to queue lease transaction", "error", err, "action", action, "lease_id", common.Hash(id).Hex())
+		return txmgr.Record{}, err
+	}
+	server.recordAudit(AuditTransactionQueued, r.Header.Get(reqsig.HeaderPeerID), map[string]any{
+		"tx_id":    rec.ID,
+		"action":   action,
+		"lease_id": rec.Reference,
+	})
+	return rec, nil
+}
+
+// handleListTransactions handles GET /api/v1/transactions
+func (server *Server) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	if server.transactions == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Sending transactions is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	resp := TransactionsResponse{Data: server.transactions.List(txmgr.Query{
+		Action:    params.Get("action"),
+		Reference: params.Get("reference"),
+		Status:    txmgr.Status(params.Get("status")),
+	})}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		server.logger.Error("failed to encode transactions", "error", err)
+	}
+}
+
+// handleGetTransaction handles GET /api/v1/transactions/{txId}
+func (server *Server) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	if server.transactions == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Sending transactions is not enabled")
+		return
+	}
+
+	rec, ok := server.transactions.Get(chi.URLParam(r, "txId"))
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Transaction not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		server.logger.Error("failed to encode transaction", "error", err)
+	}
+}
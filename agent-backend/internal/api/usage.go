@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/usage"
+)
+
+// Rollups GET /api/v1/usage returns unless days or months is given
+const (
+	defaultUsageDays   = 30
+	defaultUsageMonths = 12
+)
+
+// UsageResponse is an identity's usage and where it stands against its
+// quotas
+type UsageResponse struct {
+	Identity string `json:"identity"`
+	usage.Report
+	Quotas *security.IdentityQuotas `json:"quotas,omitempty"` // Omitted when the security service is not running
+}
+
+// SetUsage accounts each identity's requests, jobs, compute time and
+// artifact bytes in store
+func (server *Server) SetUsage(store *usage.Store) {
+	server.usage = store
+	if meter, ok := server.privacyService.(privacy.ComputationMeter); ok {
+		meter.MeterComputations(server.meterComputation)
+	} else {
+		server.logger.Warn("privacy service cannot meter computations; their compute time and output are not accounted")
+	}
+}
+
+// recordUsage adds c to identity's usage, if usage is accounted
+func (server *Server) recordUsage(identity string, c usage.Counters) {
+	if server.usage != nil {
+		server.usage.Add(identity, c)
+	}
+}
+
+// usageMiddleware counts each authenticated request against its caller
+func (server *Server) usageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.recordUsage(r.Header.Get(reqsig.HeaderPeerID), usage.Counters{Requests: 1})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// meterComputation charges what a computation used to the spender that
+// queued it and books its bill against its lease
+func (server *Server) meterComputation(m privacy.Metering) {
+	server.ownersMutex.Lock()
+	owner := server.computations[m.ComputationID]
+	server.ownersMutex.Unlock()
+
+	server.recordUsage(owner, usage.Counters{ComputeSeconds: m.Compute.Seconds(), ArtifactBytes: m.OutputBytes})
+	if m.Bill != nil {
+		server.recordCharge(m.LeaseID, m.ComputationID, metering.KindComputation, *m.Bill)
+	}
+}
+
+// meterTrainingJob charges a finished job's running time and artifact to
+// its owner. Caller must hold jobsMutex.
+func (server *Server) meterTrainingJob(job *TrainingJob, finished time.Time) {
+	var c usage.Counters
+	if !job.startedAt.IsZero() {
+		c.ComputeSeconds = finished.Sub(job.startedAt).Seconds()
+	}
+	if job.Status == string(TrainingStatusComplete) && job.ArtifactPath != "" {
+		if info, err := os.Stat(job.ArtifactPath); err == nil {
+			c.ArtifactBytes = info.Size()
+		}
+	}
+	server.recordUsage(job.owner, c)
+}
+
+// handleGetUsage handles GET /api/v1/usage. Callers see their own usage;
+// admins may pass identity to see another's.
+func (server *Server) handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	if server.usage == nil {
+		server.sendErrorResponse(w, r, http.StatusNotFound, ErrorCodeNotFound, "Usage accounting is not enabled")
+		return
+	}
+
+	identity := r.Header.Get(reqsig.HeaderPeerID)
+	if other := r.URL.Query().Get("identity"); other != "" && other != identity {
+		if server.securityService == nil || !server.securityService.IsAdmin(identity) {
+			server.sendErrorResponse(w, r, http.StatusForbidden, ErrorCodeForbidden, "Only admins may see another identity's usage")
+			return
+		}
+		identity = other
+	}
+	if identity == "" {
+		server.sendErrorResponse(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Missing peer ID header")
+		return
+	}
+
+	days, ok := usageLimit(r, "days", defaultUsageDays)
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "days must be a non-negative integer")
+		return
+	}
+	months, ok := usageLimit(r, "months", defaultUsageMonths)
+	if !ok {
+		server.sendErrorResponse(w, r, http.StatusBadRequest, ErrorCodeValidationError, "months must be a non-negative integer")
+		return
+	}
+
+	response := UsageResponse{Identity: identity, Report: server.usage.Report(identity, days, months)}
+	if server.securityService != nil {
+		quotas := server.securityService.Quotas(identity)
+		response.Quotas = &quotas
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.logger.Error("failed to encode usage", "error", err)
+	}
+}
+
+// usageLimit parses a rollup count query parameter
+func usageLimit(r *http.Request, name string, fallback int) (int, bool) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(param)
+	return n, err == nil && n >= 0
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/usage"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// meteringPrivacyService hands out the metering callback it is given
+type meteringPrivacyService struct {
+	MockPrivacyService
+	meter func(privacy.Metering)
+}
+
+func (m *meteringPrivacyService) MeterComputations(fn func(privacy.Metering)) {
+	m.meter = fn
+}
+
+func TestServer_usage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	configPath := filepath.Join(t.TempDir(), "security.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+rate_limits:
+  per_ip_rps: 100
+  per_identity_rps: 100
+  burst: 100
+backpressure:
+  mem_high_watermark_mb: 100000
+queue:
+  max_size: 10
+quotas:
+  concurrent_jobs_per_identity: 2
+  cost_budget: 1000
+`), 0644))
+	securityService, err := security.NewSecurityService(configPath, logger)
+	require.NoError(t, err)
+	defer securityService.Shutdown()
+
+	privacyService := &meteringPrivacyService{}
+	server := NewServer(&policy.Engine{}, logger, &p2p.Node{}, privacyService, securityService)
+	store, err := usage.NewStore("")
+	require.NoError(t, err)
+	server.SetUsage(store)
+	require.NotNil(t, privacyService.meter)
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	caller := id.String()
+
+	get := func(path string) (*httptest.ResponseRecorder, UsageResponse) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, reqsig.Sign(priv, req, nil, time.Now()))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		var response UsageResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		}
+		return w, response
+	}
+
+	// A computation's compute time and output go to the spender that queued it
+	server.setComputationOwner("comp-1", caller)
+	privacyService.meter(privacy.Metering{ComputationID: "comp-1", Compute: 2 * time.Second, OutputBytes: 512})
+
+	// A training job's running time and artifact go to its owner
+	artifact := filepath.Join(t.TempDir(), "aggregate.json")
+	require.NoError(t, os.WriteFile(artifact, make([]byte, 1024), 0644))
+	started := time.Now()
+	server.meterTrainingJob(&TrainingJob{Status: string(TrainingStatusComplete), ArtifactPath: artifact, owner: caller, startedAt: started}, started.Add(3*time.Second))
+
+	get("/api/v1/usage")
+	w, response := get("/api/v1/usage?days=7")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, caller, response.Identity)
+	assert.Equal(t, int64(2), response.Today.Requests, "both usage requests are counted")
+	assert.Equal(t, 5.0, response.Today.ComputeSeconds)
+	assert.Equal(t, int64(1536), response.ThisMonth.ArtifactBytes)
+	assert.Len(t, response.Daily, 1)
+	require.NotNil(t, response.Quotas)
+	assert.Equal(t, 2, response.Quotas.MaxConcurrentJobs)
+	assert.Equal(t, 1000.0, response.Quotas.Cost.Budget)
+	assert.Positive(t, response.Quotas.Cost.Spent)
+
+	w, _ = get("/api/v1/usage?days=-1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only admins see other identities' usage
+	w, _ = get("/api/v1/usage?identity=someone-else")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+// AuditComputationVerified is recorded when a re-executed computation's
+// result has been compared
+const AuditComputationVerified = "computation.verified"
+
+// SetVerification re-executes fraction of completed computations, if the
+// privacy service supports it, and audits each verdict. With escalate set,
+// a computation whose re-execution produced a different result gets a
+// dispute against its lease.
+func (server *Server) SetVerification(fraction float64, escalate bool) {
+	verifier, ok := server.privacyService.(privacy.ResultVerifier)
+	if !ok {
+		server.logger.Warn("privacy service cannot verify computation results")
+		return
+	}
+	verifier.VerifyResults(fraction, func(computationID, leaseID string, v privacy.Verification) {
+		server.onComputationVerified(computationID, leaseID, v, escalate)
+	})
+}
+
+// onComputationVerified audits a verification verdict and escalates a
+// mismatch to a dispute if asked to
+func (server *Server) onComputationVerified(computationID, leaseID string, v privacy.Verification, escalate bool) {
+	fields := map[string]any{
+		"computation_id": computationID,
+		"lease_id":       leaseID,
+		"status":         v.Status,
+		"result_digest":  v.ResultDigest,
+	}
+	if v.ReplayDigest != "" {
+		fields["replay_digest"] = v.ReplayDigest
+	}
+	if v.Error != "" {
+		fields["error"] = v.Error
+	}
+	server.recordAudit(AuditComputationVerified, "", fields)
+
+	if v.Status != privacy.VerificationMismatched || !escalate {
+		return
+	}
+	if err := server.escalateMismatch(computationID, leaseID, v); err != nil {
+		server.logger.Error("failed to raise dispute for mismatched computation", "error", err, "computation_id", computationID, "lease_id", leaseID)
+	}
+}
+
+// escalateMismatch records a dispute against the lease of a computation
+// whose re-execution produced a different result. The verification is
+// attached as evidence when evidence is enabled.
+func (server *Server) escalateMismatch(computationID, leaseID string, v privacy.Verification) error {
+	report, err := json.Marshal(struct {
+		ComputationID string `json:"computationId"`
+		privacy.Verification
+	}{computationID, v})
+	if err != nil {
+		return err
+	}
+	disputeID, err := dispute.NewID(leaseID)
+	if err != nil {
+		return err
+	}
+	claim := dispute.Claim{
+		DisputeID: disputeID,
+		LeaseID:   leaseID,
+		Reason:    fmt.Sprintf("computation %s re-executed with a different result", computationID),
+	}
+	if server.evidence != nil {
+		claim.Evidence = []dispute.Evidence{{Name: "verification.json", MediaType: "application/json", Content: report}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	record, err := server.packageDispute(ctx, claim)
+	if err != nil {
+		return err
+	}
+	if server.disputes != nil {
+		stored, err := server.disputes.Add(record)
+		if err != nil {
+			return err
+		}
+		record = &stored
+	}
+
+	fields := map[string]any{
+		"lease_id":       leaseID,
+		"dispute_id":     record.DisputeID,
+		"computation_id": computationID,
+	}
+	if record.EvidenceCID != "" {
+		fields["evidence_cid"] = record.EvidenceCID
+	}
+	server.recordAudit(AuditDisputeRaised, "", fields)
+	server.logger.Warn("raised dispute for mismatched computation", "computation_id", computationID, "lease_id", leaseID, "dispute_id", record.DisputeID)
+	return nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/audit"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_onComputationVerified(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	server := NewServer(denyEvaluator{}, logger, &p2p.Node{}, &MockPrivacyService{}, nil)
+	store, err := dispute.NewStore("")
+	require.NoError(t, err)
+	server.SetDisputes(store, "")
+
+	matched := privacy.Verification{Status: privacy.VerificationMatched, ResultDigest: "aa", ReplayDigest: "aa"}
+	mismatched := privacy.Verification{Status: privacy.VerificationMismatched, ResultDigest: "aa", ReplayDigest: "bb"}
+
+	server.onComputationVerified("comp-1", "lease-1", matched, true)
+	server.onComputationVerified("comp-2", "lease-2", mismatched, false)
+	assert.Empty(t, store.ForLease("lease-1"))
+	assert.Empty(t, store.ForLease("lease-2"), "mismatches are only escalated if configured")
+
+	server.onComputationVerified("comp-3", "lease-3", mismatched, true)
+	disputes := store.ForLease("lease-3")
+	require.Len(t, disputes, 1)
+	assert.Contains(t, disputes[0].Reason, "comp-3")
+	assert.Equal(t, dispute.OriginAgent, disputes[0].Origin)
+
+	page, err := server.auditLog.List(audit.Query{Type: AuditComputationVerified})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 3)
+	assert.Equal(t, privacy.VerificationMismatched, page.Events[2].Fields["status"])
+	assert.Equal(t, "bb", page.Events[2].Fields["replay_digest"])
+
+	page, err = server.auditLog.List(audit.Query{Type: AuditDisputeRaised})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "comp-3", page.Events[0].Fields["computation_id"])
+}
@@ -0,0 +1,431 @@
+// Package assets records the files behind each data product. An asset maps
+// a product to a local file, an IPFS CID or an S3 object together with its format,
+// schema, row count, size and checksum. Assets are inspected when they are
+// registered, so a computation never runs on data that does not match what
+// the catalog describes, and checked again each time they are mounted.
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidAsset is returned when an asset is malformed or its content
+// does not match its declared metadata
+var ErrInvalidAsset = errors.New("invalid data asset")
+
+// ErrNotFound is returned for assets that are not registered
+var ErrNotFound = errors.New("data asset not found")
+
+// Format is the encoding of an asset's file
+type Format string
+
+// Supported asset formats
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"   // One JSON object per line
+	FormatJSON    Format = "json"    // A JSON array of objects
+	FormatParquet Format = "parquet" // Rows are taken as declared
+	FormatArrow   Format = "arrow"   // An Arrow IPC file, as Feather v2 writes; rows are taken as declared
+)
+
+// Column describes one field of an asset's records
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // string, integer, number, boolean or datetime
+}
+
+// Asset is a file backing a data product
+type Asset struct {
+	ID           string    `json:"assetId"`
+	ProductID    string    `json:"productId"`
+	Source       string    `json:"source,omitempty"` // Local path, ipfs://<cid> or s3://<bucket>/<key>; withheld from the catalog
+	Format       Format    `json:"format"`
+	Schema       []Column  `json:"schema,omitempty"`
+	Partitions   []string  `json:"partitions,omitempty"` // Schema columns computations may select rows by
+	Rows         int64     `json:"rows"`
+	Size         int64     `json:"size"`   // Bytes
+	SHA256       string    `json:"sha256"` // Hex hash of the file
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// Described returns a as the catalog shows it: without its source
+func (a Asset) Described() Asset {
+	a.Source = ""
+	return a
+}
+
+// Column returns the schema column called name
+func (a Asset) Column(name string) (Column, bool) {
+	for _, col := range a.Schema {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// Extension is the file extension a mounted copy of the asset gets
+func (a Asset) Extension() string {
+	return "." + string(a.Format)
+}
+
+var (
+	// idPattern keeps asset IDs usable as file names and in loader scripts
+	idPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	columnTypes = map[string]bool{"": true, "string": true, "integer": true, "number": true, "boolean": true, "datetime": true}
+	formats     = map[Format]bool{FormatCSV: true, FormatJSONL: true, FormatJSON: true, FormatParquet: true, FormatArrow: true}
+)
+
+// Registry keeps the registered assets. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	path    string
+	assets  map[string]*Asset
+	drivers map[string]Storage // By source scheme
+	now     func() time.Time
+
+	// Encrypts local sources at rest; nil leaves them as they are
+	keyring *atrest.Keyring
+}
+
+// NewRegistry creates a registry that persists assets to path unless it is
+// empty, restoring any already saved there. Local paths are read from disk
+// and ipfs:// sources through the IPFS HTTP API at ipfsAPIURL; other
+// schemes need a driver from UseStorage.
+func NewRegistry(path, ipfsAPIURL string) (*Registry, error) {
+	r := &Registry{
+		path:   path,
+		assets: make(map[string]*Asset),
+		now:    time.Now,
+	}
+	r.drivers = map[string]Storage{
+		SchemeLocal: &localStorage{keyring: r.currentKeyring},
+		SchemeIPFS: &ipfsStorage{
+			apiURL: strings.TrimRight(ipfsAPIURL, "/"),
+			client: &http.Client{Timeout: 10 * time.Minute, Transport: telemetry.Transport(nil)},
+		},
+	}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.assets); err != nil {
+		return nil, fmt.Errorf("failed to parse asset registry: %w", err)
+	}
+	if r.assets == nil {
+		r.assets = make(map[string]*Asset)
+	}
+	return r, nil
+}
+
+// UseKeyring encrypts the local sources of assets registered from now on in
+// place, and decrypts local sources when they are read. Sources that are
+// not encrypted are still read as they are.
+func (r *Registry) UseKeyring(k *atrest.Keyring) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keyring = k
+}
+
+// currentKeyring returns the keyring local sources are encrypted with
+func (r *Registry) currentKeyring() *atrest.Keyring {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keyring
+}
+
+// UseStorage reads and deletes sources with scheme through s, replacing
+// the driver for the scheme if there is one. Call it before the registry
+// is used.
+func (r *Registry) UseStorage(scheme string, s Storage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drivers[scheme] = s
+}
+
+// Register inspects a's source and records it, replacing any asset with
+// the same ID. Size, checksum, row count and schema are taken from the
+// content; any of them a declares must match it.
+func (r *Registry) Register(ctx context.Context, a Asset) (_ Asset, err error) {
+	if err := validate(a); err != nil {
+		return Asset{}, err
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "assets.register", attribute.String("pandacea.asset_id", a.ID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	src, err := r.open(ctx, a.Source)
+	if err != nil {
+		return Asset{}, fmt.Errorf("%w: %s: %v", ErrInvalidAsset, a.ID, err)
+	}
+	defer src.Close()
+	found, err := inspect(src, a.Format, a.Schema)
+	if err != nil {
+		return Asset{}, fmt.Errorf("%w: %s: %v", ErrInvalidAsset, a.ID, err)
+	}
+
+	if a.SHA256 != "" && !strings.EqualFold(a.SHA256, found.sha256) {
+		return Asset{}, fmt.Errorf("%w: %s: checksum is %s, declared %s", ErrInvalidAsset, a.ID, found.sha256, a.SHA256)
+	}
+	if a.Size != 0 && a.Size != found.size {
+		return Asset{}, fmt.Errorf("%w: %s: size is %d bytes, declared %d", ErrInvalidAsset, a.ID, found.size, a.Size)
+	}
+	if found.rows >= 0 {
+		if a.Rows != 0 && a.Rows != found.rows {
+			return Asset{}, fmt.Errorf("%w: %s: has %d rows, declared %d", ErrInvalidAsset, a.ID, found.rows, a.Rows)
+		}
+		a.Rows = found.rows
+	}
+	if len(a.Schema) == 0 {
+		for _, name := range found.columns {
+			a.Schema = append(a.Schema, Column{Name: name})
+		}
+	}
+	for _, name := range a.Partitions {
+		if _, ok := a.Column(name); !ok {
+			return Asset{}, fmt.Errorf("%w: %s: partition column %s is not in the schema", ErrInvalidAsset, a.ID, name)
+		}
+	}
+	a.SHA256 = found.sha256
+	a.Size = found.size
+	a.RegisteredAt = r.now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keyring != nil && a.Local() {
+		if _, err := r.keyring.EncryptFile(a.Source); err != nil {
+			return Asset{}, fmt.Errorf("failed to encrypt asset %s: %w", a.ID, err)
+		}
+	}
+	previous, replaced := r.assets[a.ID]
+	r.assets[a.ID] = &a
+	if err := r.save(); err != nil {
+		if replaced {
+			r.assets[a.ID] = previous
+		} else {
+			delete(r.assets, a.ID)
+		}
+		return Asset{}, err
+	}
+	return a, nil
+}
+
+// Get returns a registered asset
+func (r *Registry) Get(id string) (Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.assets[id]
+	if !ok {
+		return Asset{}, false
+	}
+	return a.copy(), true
+}
+
+// List returns the registered assets ordered by ID, only productID's if it
+// is not empty
+func (r *Registry) List(productID string) []Asset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Asset, 0, len(r.assets))
+	for _, a := range r.assets {
+		if productID == "" || a.ProductID == productID {
+			list = append(list, a.copy())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Remove forgets an asset
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.assets[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(r.assets, id)
+	if err := r.save(); err != nil {
+		r.assets[id] = a
+		return err
+	}
+	return nil
+}
+
+// Erased is what erasing an asset destroyed
+type Erased struct {
+	AssetID     string `json:"assetId"`
+	ProductID   string `json:"productId"`
+	Source      string `json:"source"`
+	Deleted     bool   `json:"deleted"`               // The local file or S3 object is gone
+	UnpinnedCID string `json:"unpinnedCid,omitempty"` // The IPFS source was unpinned from the node
+}
+
+// Erase forgets an asset and destroys its source: a local file or S3 object
+// is deleted and an IPFS source is unpinned from the node. The asset is forgotten even
+// if its source cannot be destroyed, so it is never mounted again, and the
+// error says what is left.
+func (r *Registry) Erase(ctx context.Context, id string) (Erased, error) {
+	a, ok := r.Get(id)
+	if !ok {
+		return Erased{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := r.Remove(id); err != nil {
+		return Erased{}, err
+	}
+
+	erased := Erased{AssetID: a.ID, ProductID: a.ProductID, Source: a.Source}
+	driver, err := r.driver(a.Source)
+	if err != nil {
+		return erased, err
+	}
+	if err := driver.Delete(ctx, a.Source); err != nil {
+		return erased, fmt.Errorf("failed to delete asset %s: %w", id, err)
+	}
+	if cid, ok := strings.CutPrefix(a.Source, "ipfs://"); ok {
+		erased.UnpinnedCID = cid
+	} else {
+		erased.Deleted = true
+	}
+	return erased, nil
+}
+
+// Mount copies an asset into dir as <id>.<format> and returns the file
+// name. The copy must still match the checksum recorded at registration.
+func (r *Registry) Mount(ctx context.Context, id, dir string) (_ string, err error) {
+	a, ok := r.Get(id)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "assets.mount", attribute.String("pandacea.asset_id", id))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	src, err := r.open(ctx, a.Source)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	name := a.ID + a.Extension()
+	path := filepath.Join(dir, name)
+	dest, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create mounted asset: %w", err)
+	}
+	defer dest.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dest, hash), src); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to copy asset %s: %w", id, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != a.SHA256 {
+		os.Remove(path)
+		return "", fmt.Errorf("%w: %s changed since it was registered", ErrInvalidAsset, id)
+	}
+	return name, nil
+}
+
+// open streams an asset's source
+func (r *Registry) open(ctx context.Context, source string) (io.ReadCloser, error) {
+	driver, err := r.driver(source)
+	if err != nil {
+		return nil, err
+	}
+	return driver.Open(ctx, source)
+}
+
+// driver returns the storage driver for source's scheme
+func (r *Registry) driver(source string) (Storage, error) {
+	scheme := Scheme(source)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	driver, ok := r.drivers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no storage driver for %s:// sources", scheme)
+	}
+	return driver, nil
+}
+
+// save writes the registry to disk. Caller must hold r.mu.
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.assets)
+	if err != nil {
+		return fmt.Errorf("failed to encode asset registry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create asset registry directory: %w", err)
+	}
+	if err := fsutil.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write asset registry: %w", err)
+	}
+	return nil
+}
+
+// copy returns a copy of a that shares no slices with it
+func (a *Asset) copy() Asset {
+	c := *a
+	c.Schema = append([]Column(nil), a.Schema...)
+	c.Partitions = append([]string(nil), a.Partitions...)
+	return c
+}
+
+// validate checks the fields an asset must declare
+func validate(a Asset) error {
+	switch {
+	case !idPattern.MatchString(a.ID):
+		return fmt.Errorf("%w: assetId must be 1-128 letters, digits, '.', '_' or '-'", ErrInvalidAsset)
+	case a.ProductID == "":
+		return fmt.Errorf("%w: productId is required", ErrInvalidAsset)
+	case a.Source == "" || strings.HasSuffix(a.Source, "://"):
+		return fmt.Errorf("%w: source is required", ErrInvalidAsset)
+	case !formats[a.Format]:
+		return fmt.Errorf("%w: format must be csv, jsonl, json, parquet or arrow", ErrInvalidAsset)
+	case a.Rows < 0 || a.Size < 0:
+		return fmt.Errorf("%w: rows and size cannot be negative", ErrInvalidAsset)
+	}
+	seen := make(map[string]bool, len(a.Schema))
+	for _, col := range a.Schema {
+		if col.Name == "" || seen[col.Name] {
+			return fmt.Errorf("%w: schema column names must be unique and not empty", ErrInvalidAsset)
+		}
+		if !columnTypes[col.Type] {
+			return fmt.Errorf("%w: column %s has unknown type %q", ErrInvalidAsset, col.Name, col.Type)
+		}
+		seen[col.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/atrest"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRegister(t *testing.T) {
+	const scans = "id,scan\n1,a\n2,b\n"
+	sum := sha256.Sum256([]byte(scans))
+	path := writeFile(t, "scans.csv", scans)
+	statePath := filepath.Join(t.TempDir(), "state", "assets.json")
+	registry, err := NewRegistry(statePath, "")
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	a, err := registry.Register(context.Background(), Asset{
+		ID:        "scans-2024",
+		ProductID: "product-1",
+		Source:    path,
+		Format:    FormatCSV,
+		SHA256:    hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if a.Rows != 2 || a.Size != int64(len(scans)) || len(a.Schema) != 2 || a.Schema[1].Name != "scan" || a.RegisteredAt.IsZero() {
+		t.Errorf("registered asset = %+v", a)
+	}
+	if described := a.Described(); described.Source != "" {
+		t.Error("described asset reveals its source")
+	}
+
+	restored, err := NewRegistry(statePath, "")
+	if err != nil {
+		t.Fatalf("NewRegistry after restart: %v", err)
+	}
+	if list := restored.List("product-1"); len(list) != 1 || list[0].SHA256 != a.SHA256 {
+		t.Errorf("restored assets = %+v", list)
+	}
+	if list := restored.List("product-2"); len(list) != 0 {
+		t.Errorf("assets of another product = %+v", list)
+	}
+}
+
+func TestRegisterRejectsMismatches(t *testing.T) {
+	registry, _ := NewRegistry("", "")
+	csvPath := writeFile(t, "scans.csv", "id,scan\n1,a\n")
+	jsonlPath := writeFile(t, "scans.jsonl", "{\"id\":1,\"scan\":\"a\"}\n{\"id\":2}\n")
+
+	tests := []struct {
+		name  string
+		asset Asset
+	}{
+		{"unsafe id", Asset{ID: "../scans", ProductID: "p", Source: csvPath, Format: FormatCSV}},
+		{"no product", Asset{ID: "scans", Source: csvPath, Format: FormatCSV}},
+		{"unknown format", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: "xlsx"}},
+		{"unknown column type", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatCSV,
+			Schema: []Column{{Name: "id", Type: "uuid"}}}},
+		{"checksum", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatCSV, SHA256: "00"}},
+		{"size", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatCSV, Size: 3}},
+		{"rows", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatCSV, Rows: 5}},
+		{"header", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatCSV,
+			Schema: []Column{{Name: "scan"}, {Name: "id"}}}},
+		{"missing field", Asset{ID: "scans", ProductID: "p", Source: jsonlPath, Format: FormatJSONL,
+			Schema: []Column{{Name: "id", Type: "integer"}, {Name: "scan", Type: "string"}}}},
+		{"wrong format", Asset{ID: "scans", ProductID: "p", Source: csvPath, Format: FormatParquet}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := registry.Register(context.Background(), tt.asset); !errors.Is(err, ErrInvalidAsset) {
+				t.Errorf("Register() error = %v, want %v", err, ErrInvalidAsset)
+			}
+		})
+	}
+	if list := registry.List(""); len(list) != 0 {
+		t.Errorf("rejected assets were registered: %+v", list)
+	}
+}
+
+func TestRegisterFormats(t *testing.T) {
+	registry, _ := NewRegistry("", "")
+	tests := []struct {
+		format  Format
+		content string
+		rows    int64
+	}{
+		{FormatJSONL, "{\"id\":1}\n\n{\"id\":2}", 2},
+		{FormatJSON, `[{"id":1},{"id":2},{"id":3}]`, 3},
+		{FormatParquet, "PAR1 footer PAR1", 7}, // Declared rows are kept
+		{FormatArrow, "ARROW1\x00\x00 footer ARROW1", 7},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, "asset."+string(tt.format), tt.content)
+		a, err := registry.Register(context.Background(), Asset{ID: "asset-" + string(tt.format), ProductID: "p", Source: path, Format: tt.format, Rows: 7})
+		if tt.format != FormatParquet && tt.format != FormatArrow {
+			// Declared rows must match the file
+			if !errors.Is(err, ErrInvalidAsset) {
+				t.Errorf("%s: Register() with wrong rows error = %v", tt.format, err)
+			}
+			a, err = registry.Register(context.Background(), Asset{ID: "asset-" + string(tt.format), ProductID: "p", Source: path, Format: tt.format})
+		}
+		if err != nil || a.Rows != tt.rows {
+			t.Errorf("%s: Register() = %+v, %v", tt.format, a, err)
+		}
+	}
+
+	path := writeFile(t, "truncated.arrow", "ARROW1\x00\x00 footer")
+	if _, err := registry.Register(context.Background(), Asset{ID: "truncated", ProductID: "p", Source: path, Format: FormatArrow}); !errors.Is(err, ErrInvalidAsset) {
+		t.Errorf("Register() of a truncated Arrow file error = %v", err)
+	}
+
+	// Rows can only be selected by partition columns in the schema
+	path = writeFile(t, "sales.csv", "region,amount\neu,1\n")
+	if _, err := registry.Register(context.Background(), Asset{ID: "sales", ProductID: "p", Source: path, Format: FormatCSV, Partitions: []string{"year"}}); !errors.Is(err, ErrInvalidAsset) {
+		t.Errorf("Register() with an unknown partition column error = %v", err)
+	}
+	if a, err := registry.Register(context.Background(), Asset{ID: "sales", ProductID: "p", Source: path, Format: FormatCSV, Partitions: []string{"region"}}); err != nil || a.Partitions[0] != "region" {
+		t.Errorf("Register() with partitions = %+v, %v", a, err)
+	}
+}
+
+func TestMount(t *testing.T) {
+	const content = "[{\"id\":1}]"
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/cat" || r.URL.Query().Get("arg") != "bafyscans" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer ipfs.Close()
+
+	registry, _ := NewRegistry("", ipfs.URL)
+	path := writeFile(t, "local.csv", "id\n1\n")
+	for _, a := range []Asset{
+		{ID: "remote", ProductID: "p", Source: "ipfs://bafyscans", Format: FormatJSON},
+		{ID: "local", ProductID: "p", Source: path, Format: FormatCSV},
+	} {
+		if _, err := registry.Register(context.Background(), a); err != nil {
+			t.Fatalf("Register(%s) error = %v", a.ID, err)
+		}
+	}
+
+	dir := t.TempDir()
+	name, err := registry.Mount(context.Background(), "remote", dir)
+	if err != nil || name != "remote.json" {
+		t.Fatalf("Mount(remote) = %q, %v", name, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != content {
+		t.Errorf("mounted %q, want the IPFS content", data)
+	}
+
+	// A file changed after registration is not mounted
+	if err := os.WriteFile(path, []byte("id\n2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Mount(context.Background(), "local", dir); !errors.Is(err, ErrInvalidAsset) {
+		t.Errorf("Mount(changed) error = %v, want %v", err, ErrInvalidAsset)
+	}
+	if _, err := registry.Mount(context.Background(), "missing", dir); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Mount(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestErase(t *testing.T) {
+	var unpinned []string
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v0/cat":
+			fmt.Fprint(w, "[{\"id\":1}]")
+		case "/api/v0/pin/rm":
+			unpinned = append(unpinned, r.URL.Query().Get("arg"))
+			fmt.Fprint(w, `{"Pins":[]}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer ipfs.Close()
+
+	registry, _ := NewRegistry("", ipfs.URL)
+	path := writeFile(t, "local.csv", "id\n1\n")
+	for _, a := range []Asset{
+		{ID: "remote", ProductID: "p", Source: "ipfs://bafyscans", Format: FormatJSON},
+		{ID: "local", ProductID: "p", Source: path, Format: FormatCSV},
+	} {
+		if _, err := registry.Register(context.Background(), a); err != nil {
+			t.Fatalf("Register(%s) error = %v", a.ID, err)
+		}
+	}
+
+	erased, err := registry.Erase(context.Background(), "local")
+	if err != nil || !erased.Deleted || erased.ProductID != "p" {
+		t.Fatalf("Erase(local) = %+v, %v", erased, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("local source still exists: %v", err)
+	}
+	erased, err = registry.Erase(context.Background(), "remote")
+	if err != nil || erased.UnpinnedCID != "bafyscans" || !slices.Equal(unpinned, []string{"bafyscans"}) {
+		t.Fatalf("Erase(remote) = %+v, %v; unpinned %v", erased, err, unpinned)
+	}
+	if list := registry.List("p"); len(list) != 0 {
+		t.Errorf("erased assets still registered: %+v", list)
+	}
+	if _, err := registry.Erase(context.Background(), "local"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Erase() again error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestMountEncrypted(t *testing.T) {
+	keyring, err := atrest.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, _ := NewRegistry("", "")
+	registry.UseKeyring(keyring)
+	path := writeFile(t, "local.csv", "id\n1\n")
+	registered, err := registry.Register(context.Background(), Asset{ID: "local", ProductID: "p", Source: path, Format: FormatCSV})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if registered.Rows != 1 {
+		t.Errorf("rows = %d, want 1", registered.Rows)
+	}
+
+	// The source is encrypted in place and mounted decrypted
+	if stored, _ := os.ReadFile(path); strings.Contains(string(stored), "id\n1") {
+		t.Errorf("source left in plaintext: %q", stored)
+	}
+	dir := t.TempDir()
+	name, err := registry.Mount(context.Background(), "local", dir)
+	if err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != "id\n1\n" {
+		t.Errorf("mounted %q", data)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	registry, _ := NewRegistry("", "")
+	path := writeFile(t, "patients.csv", "id,age,zip\n1,34,10001\n2,51,10002\n3,29,10003\n")
+	if _, err := registry.Register(context.Background(), Asset{ID: "patients", ProductID: "p", Source: path, Format: FormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+	suppress := map[string][]string{"patients": {"zip"}}
+
+	head := NewPreviewer(registry, PreviewOptions{Rows: 2, Suppress: suppress, CacheTTL: time.Minute})
+	preview, cached, err := head.Preview(context.Background(), "patients")
+	if err != nil || cached {
+		t.Fatalf("Preview() = %+v, cached %v, %v", preview, cached, err)
+	}
+	if len(preview.Rows) != 2 || preview.Rows[1]["age"] != "51" || preview.TotalRows != 3 {
+		t.Errorf("head preview = %+v", preview)
+	}
+	if _, ok := preview.Rows[0]["zip"]; ok || len(preview.Suppressed) != 1 || slices.Contains(preview.Columns, "zip") {
+		t.Errorf("suppressed column in preview: %+v", preview)
+	}
+	if _, cached, _ := head.Preview(context.Background(), "patients"); !cached {
+		t.Error("second preview was not served from the cache")
+	}
+
+	// Registering different content invalidates the cached preview
+	if err := os.WriteFile(path, []byte("id,age,zip\n9,70,10009\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(context.Background(), Asset{ID: "patients", ProductID: "p", Source: path, Format: FormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+	if preview, cached, _ := head.Preview(context.Background(), "patients"); cached || preview.Rows[0]["id"] != "9" {
+		t.Errorf("preview after re-registration = %+v, cached %v", preview, cached)
+	}
+
+	synthetic := NewPreviewer(registry, PreviewOptions{Rows: 5, Mode: PreviewSynthetic, Suppress: suppress})
+	first, _, err := synthetic.Preview(context.Background(), "patients")
+	if err != nil || len(first.Rows) != 5 || first.Rows[0]["age"] != "70" {
+		t.Fatalf("synthetic preview = %+v, %v", first, err)
+	}
+	if again, _, _ := synthetic.Preview(context.Background(), "patients"); !reflect.DeepEqual(again.Rows, first.Rows) {
+		t.Error("synthetic previews of the same content differ")
+	}
+
+	if _, _, err := head.Preview(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Preview(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	registry, _ := NewRegistry("", "")
+	path := writeFile(t, "patients.csv", "id,age\n1,34\n2,51\n")
+	if _, err := registry.Register(context.Background(), Asset{ID: "patients", ProductID: "p", Source: path, Format: FormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs int
+	fail := true
+	run := func(ctx context.Context, a Asset, bins, top int) (Profile, error) {
+		runs++
+		if fail {
+			return Profile{}, errors.New("sandbox unavailable")
+		}
+		return Profile{Rows: 12, Columns: []ColumnProfile{{
+			Name:      "age",
+			Histogram: []Bin{{Low: 20, High: 40, Count: 9}, {Low: 40, High: 60, Count: 3}},
+			TopValues: []ValueCount{{Value: "34", Count: 2}},
+		}}}, nil
+	}
+	profiler := NewProfiler(registry, run, ProfileOptions{Bins: 2, TopValues: 1, MinCount: 5, CacheTTL: time.Hour})
+	now := time.Now()
+	profiler.now = func() time.Time { return now }
+
+	job, err := profiler.Profile("patients")
+	if err != nil || job.Status != ProfilePending {
+		t.Fatalf("Profile() = %+v, %v", job, err)
+	}
+	profiler.Wait()
+	if job, _ := profiler.Profile("patients"); job.Status != ProfileFailed || job.Error == "" || runs != 1 {
+		t.Errorf("failed job = %+v after %d runs", job, runs)
+	}
+
+	// A failed job is retried once profileRetryAfter has passed
+	fail = false
+	now = now.Add(profileRetryAfter)
+	profiler.Profile("patients")
+	profiler.Wait()
+	job, _ = profiler.Profile("patients")
+	if job.Status != ProfileCompleted || runs != 2 || job.Profile.SHA256 == "" || job.Profile.ProductID != "p" {
+		t.Fatalf("completed job = %+v after %d runs", job, runs)
+	}
+	bins := job.Profile.Columns[0].Histogram
+	if bins[0].Count != 9 || bins[1].Count != 0 || !bins[1].Suppressed || job.Profile.Columns[0].TopValues != nil {
+		t.Errorf("profile withheld %+v", job.Profile.Columns[0])
+	}
+
+	// Registering different content profiles the asset again
+	if err := os.WriteFile(path, []byte("id,age\n9,70\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(context.Background(), Asset{ID: "patients", ProductID: "p", Source: path, Format: FormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := profiler.Profile("patients"); job.Status != ProfilePending {
+		t.Errorf("profile after re-registration = %+v", job)
+	}
+	profiler.Wait()
+	if runs != 3 {
+		t.Errorf("profiled %d times, want 3", runs)
+	}
+
+	if _, err := profiler.Profile("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Profile(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,214 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Magic numbers that open and close every file of a binary format
+var (
+	parquetMagic = []byte("PAR1")
+	arrowMagic   = []byte("ARROW1")
+)
+
+// contents is what inspecting an asset's file found
+type contents struct {
+	sha256  string
+	size    int64
+	rows    int64    // -1 if the format does not give it away cheaply
+	columns []string // Field names found in the file
+}
+
+// countingHash hashes and counts the bytes written to it
+type countingHash struct {
+	hash.Hash
+	total int64
+}
+
+func (c *countingHash) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	return c.Hash.Write(p)
+}
+
+// inspect reads src to its end, checking it is well formed format data
+// whose records carry the schema's columns
+func inspect(src io.Reader, format Format, schema []Column) (contents, error) {
+	counter := &countingHash{Hash: sha256.New()}
+	r := io.TeeReader(src, counter)
+
+	var found contents
+	var err error
+	switch format {
+	case FormatCSV:
+		found, err = inspectCSV(r, schema)
+	case FormatJSONL:
+		found, err = inspectJSONL(r, schema)
+	case FormatJSON:
+		found, err = inspectJSON(r, schema)
+	case FormatParquet:
+		found, err = inspectMagic(r, parquetMagic, "Parquet")
+	case FormatArrow:
+		found, err = inspectMagic(r, arrowMagic, "an Arrow IPC file")
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return contents{}, err
+	}
+	// Hash whatever the format reader left unread
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return contents{}, fmt.Errorf("failed to read asset: %w", err)
+	}
+	found.sha256 = hex.EncodeToString(counter.Sum(nil))
+	found.size = counter.total
+	return found, nil
+}
+
+// inspectCSV counts the data rows under a header, which must name the
+// schema's columns in order
+func inspectCSV(r io.Reader, schema []Column) (contents, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return contents{}, errors.New("file is empty")
+	}
+	if err != nil {
+		return contents{}, fmt.Errorf("malformed CSV header: %v", err)
+	}
+	found := contents{columns: slices.Clone(header)}
+	if len(schema) > 0 {
+		names := make([]string, len(schema))
+		for i, col := range schema {
+			names[i] = col.Name
+		}
+		if !slices.Equal(names, found.columns) {
+			return contents{}, fmt.Errorf("header %s does not match the schema %s",
+				strings.Join(found.columns, ","), strings.Join(names, ","))
+		}
+	}
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return found, nil
+		}
+		if err != nil {
+			return contents{}, fmt.Errorf("malformed CSV: %v", err)
+		}
+		found.rows++
+	}
+}
+
+// inspectJSONL counts the objects in a file holding one per line
+func inspectJSONL(r io.Reader, schema []Column) (contents, error) {
+	reader := bufio.NewReader(r)
+	var found contents
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return contents{}, fmt.Errorf("failed to read asset: %w", err)
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			var record map[string]json.RawMessage
+			if err := json.Unmarshal(trimmed, &record); err != nil {
+				return contents{}, fmt.Errorf("line %d is not a JSON object", line)
+			}
+			if err := found.add(record, schema); err != nil {
+				return contents{}, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		if err != nil {
+			if found.rows == 0 {
+				return contents{}, errors.New("file is empty")
+			}
+			return found, nil
+		}
+	}
+}
+
+// inspectJSON counts the objects in a file holding a JSON array of them
+func inspectJSON(r io.Reader, schema []Column) (contents, error) {
+	decoder := json.NewDecoder(r)
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return contents{}, errors.New("file is not a JSON array")
+	}
+	var found contents
+	for decoder.More() {
+		var record map[string]json.RawMessage
+		if err := decoder.Decode(&record); err != nil {
+			return contents{}, fmt.Errorf("element %d is not a JSON object", found.rows)
+		}
+		if err := found.add(record, schema); err != nil {
+			return contents{}, fmt.Errorf("element %d: %v", found.rows, err)
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return contents{}, errors.New("JSON array is not terminated")
+	}
+	return found, nil
+}
+
+// add counts a JSON record, which must have every schema column. The
+// first record's fields are taken as the file's columns.
+func (c *contents) add(record map[string]json.RawMessage, schema []Column) error {
+	for _, col := range schema {
+		if _, ok := record[col.Name]; !ok {
+			return fmt.Errorf("missing column %s", col.Name)
+		}
+	}
+	if c.rows == 0 {
+		for name := range record {
+			c.columns = append(c.columns, name)
+		}
+		sort.Strings(c.columns)
+	}
+	c.rows++
+	return nil
+}
+
+// inspectMagic checks the magic numbers around a Parquet or Arrow IPC
+// file. Its rows and columns sit in a footer that is not decoded, so any
+// schema and row count declared at registration are kept as they are.
+func inspectMagic(r io.Reader, magic []byte, name string) (contents, error) {
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, head); err != nil || !bytes.Equal(head, magic) {
+		return contents{}, fmt.Errorf("file is not %s", name)
+	}
+	var tail tailWriter
+	if _, err := io.Copy(&tail, r); err != nil {
+		return contents{}, fmt.Errorf("failed to read asset: %w", err)
+	}
+	if tail.n < len(magic) || !bytes.Equal(tail.last[len(tail.last)-len(magic):], magic) {
+		return contents{}, fmt.Errorf("file is not %s", name)
+	}
+	return contents{rows: -1}, nil
+}
+
+// tailWriter keeps the last bytes written to it, enough for any magic
+// number
+type tailWriter struct {
+	last [8]byte
+	n    int
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	if len(p) >= len(t.last) {
+		copy(t.last[:], p[len(p)-len(t.last):])
+	} else {
+		copy(t.last[:], t.last[len(p):])
+		copy(t.last[len(t.last)-len(p):], p)
+	}
+	t.n += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,277 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNoPreview is returned for assets whose format cannot be previewed
+var ErrNoPreview = errors.New("data asset cannot be previewed")
+
+// PreviewMode is how preview rows are drawn from an asset
+type PreviewMode string
+
+// Preview modes
+const (
+	// PreviewHead shows the asset's first rows as they are
+	PreviewHead PreviewMode = "head"
+	// PreviewSynthetic draws each column's values independently from the
+	// asset's leading rows, so a preview row does not describe any one
+	// record
+	PreviewSynthetic PreviewMode = "synthetic"
+)
+
+// syntheticPool is how many leading rows synthetic previews sample from
+const syntheticPool = 1000
+
+// Preview is a sample of an asset a spender can inspect before leasing
+type Preview struct {
+	AssetID     string           `json:"assetId"`
+	ProductID   string           `json:"productId"`
+	Mode        PreviewMode      `json:"mode"`
+	Columns     []string         `json:"columns"`
+	Suppressed  []string         `json:"suppressed,omitempty"` // Columns withheld from the preview
+	Rows        []map[string]any `json:"rows"`
+	TotalRows   int64            `json:"totalRows"` // Rows in the whole asset
+	GeneratedAt time.Time        `json:"generatedAt"`
+}
+
+// PreviewOptions configures a Previewer
+type PreviewOptions struct {
+	Rows     int                 // Rows per preview
+	Mode     PreviewMode         // Defaults to PreviewHead
+	Suppress map[string][]string // Asset ID to columns left out of its previews
+	CacheTTL time.Duration       // How long a preview is served before it is generated again
+}
+
+// Previewer generates asset previews and caches them. A cached preview is
+// dropped once its asset is registered again with different content. It
+// is safe for concurrent use.
+type Previewer struct {
+	registry *Registry
+	opts     PreviewOptions
+
+	mu    sync.Mutex
+	cache map[string]Preview // By asset ID
+	now   func() time.Time
+}
+
+// NewPreviewer creates a previewer for the assets in registry
+func NewPreviewer(registry *Registry, opts PreviewOptions) *Previewer {
+	if opts.Mode == "" {
+		opts.Mode = PreviewHead
+	}
+	return &Previewer{registry: registry, opts: opts, cache: make(map[string]Preview), now: time.Now}
+}
+
+// Preview returns a preview of a registered asset, and whether it came
+// from the cache
+func (p *Previewer) Preview(ctx context.Context, id string) (_ Preview, cached bool, err error) {
+	a, ok := p.registry.Get(id)
+	if !ok {
+		return Preview{}, false, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	key := a.ID + "|" + a.SHA256
+	now := p.now()
+	p.mu.Lock()
+	preview, hit := p.cache[key]
+	p.mu.Unlock()
+	if hit && now.Sub(preview.GeneratedAt) < p.opts.CacheTTL {
+		return preview, true, nil
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "assets.preview", attribute.String("pandacea.asset_id", id))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	preview, err = p.generate(ctx, a)
+	if err != nil {
+		return Preview{}, false, err
+	}
+	preview.GeneratedAt = now.UTC()
+	p.mu.Lock()
+	for k := range p.cache {
+		if strings.HasPrefix(k, a.ID+"|") {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = preview
+	p.mu.Unlock()
+	return preview, false, nil
+}
+
+// Forget drops the cached previews of an asset, such as one whose data was
+// erased
+func (p *Previewer) Forget(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k := range p.cache {
+		if strings.HasPrefix(k, id+"|") {
+			delete(p.cache, k)
+		}
+	}
+}
+
+// generate reads a preview from an asset's source
+func (p *Previewer) generate(ctx context.Context, a Asset) (Preview, error) {
+	if a.Format == FormatParquet || a.Format == FormatArrow {
+		return Preview{}, fmt.Errorf("%w: %s is %s", ErrNoPreview, a.ID, a.Format)
+	}
+	src, err := p.registry.open(ctx, a.Source)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer src.Close()
+
+	limit := p.opts.Rows
+	if p.opts.Mode == PreviewSynthetic {
+		limit = syntheticPool
+	}
+	columns, rows, err := readRecords(src, a.Format, limit)
+	if err != nil {
+		return Preview{}, fmt.Errorf("failed to read asset %s: %w", a.ID, err)
+	}
+
+	preview := Preview{AssetID: a.ID, ProductID: a.ProductID, Mode: p.opts.Mode, TotalRows: a.Rows}
+	suppressed := p.opts.Suppress[a.ID]
+	for _, col := range columns {
+		if slices.Contains(suppressed, col) {
+			preview.Suppressed = append(preview.Suppressed, col)
+		} else {
+			preview.Columns = append(preview.Columns, col)
+		}
+	}
+	if p.opts.Mode == PreviewSynthetic {
+		rows = synthesize(rows, preview.Columns, p.opts.Rows, a.SHA256)
+	}
+	preview.Rows = make([]map[string]any, len(rows))
+	for i, row := range rows {
+		out := make(map[string]any, len(preview.Columns))
+		for _, col := range preview.Columns {
+			if v, ok := row[col]; ok {
+				out[col] = v
+			}
+		}
+		preview.Rows[i] = out
+	}
+	return preview, nil
+}
+
+// synthesize builds n rows whose values are each drawn from the same
+// column of a random row in pool. The draw is seeded by the asset's
+// checksum, so the same content always gives the same preview.
+func synthesize(pool []map[string]any, columns []string, n int, checksum string) []map[string]any {
+	if len(pool) == 0 {
+		return nil
+	}
+	var seed [16]byte
+	sum, _ := hex.DecodeString(checksum)
+	copy(seed[:], sum)
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(seed[:8]), binary.BigEndian.Uint64(seed[8:])))
+
+	rows := make([]map[string]any, n)
+	for i := range rows {
+		row := make(map[string]any, len(columns))
+		for _, col := range columns {
+			if v, ok := pool[rng.IntN(len(pool))][col]; ok {
+				row[col] = v
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// readRecords reads up to limit records and the columns they have. CSV
+// values are strings; JSON values keep their JSON types.
+func readRecords(r io.Reader, format Format, limit int) ([]string, []map[string]any, error) {
+	var rows []map[string]any
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		header, err := reader.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("malformed CSV header: %v", err)
+		}
+		for len(rows) < limit {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("malformed CSV: %v", err)
+			}
+			row := make(map[string]any, len(header))
+			for i, col := range header {
+				row[col] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		return header, rows, nil
+
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for len(rows) < limit && scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var row map[string]any
+			if err := json.Unmarshal(line, &row); err != nil {
+				return nil, nil, errors.New("line is not a JSON object")
+			}
+			rows = append(rows, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+			return nil, nil, errors.New("file is not a JSON array")
+		}
+		for len(rows) < limit && decoder.More() {
+			var row map[string]any
+			if err := decoder.Decode(&row); err != nil {
+				return nil, nil, errors.New("element is not a JSON object")
+			}
+			rows = append(rows, row)
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("%w: format %s", ErrNoPreview, format)
+	}
+
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				columns = append(columns, col)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns, rows, nil
+}
@@ -0,0 +1,232 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// profileRetryAfter is how long a failed profiling job is reported before
+// the next request runs it again
+const profileRetryAfter = time.Minute
+
+// Profile summarizes an asset's columns so spenders can judge its quality
+// before leasing it
+type Profile struct {
+	AssetID     string          `json:"assetId"`
+	ProductID   string          `json:"productId"`
+	SHA256      string          `json:"sha256"` // Content the profile describes
+	Rows        int64           `json:"rows"`
+	Columns     []ColumnProfile `json:"columns"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+}
+
+// ColumnProfile describes one column as pandas loads it
+type ColumnProfile struct {
+	Name      string       `json:"name"`
+	Type      string       `json:"type"` // integer, number, boolean, datetime or string
+	Nulls     int64        `json:"nulls"`
+	NullRate  float64      `json:"nullRate"`
+	Distinct  int64        `json:"distinct"`      // Distinct values other than null
+	Min       any          `json:"min,omitempty"` // Numbers and datetimes only
+	Max       any          `json:"max,omitempty"`
+	Histogram []Bin        `json:"histogram,omitempty"` // Numbers only
+	TopValues []ValueCount `json:"topValues,omitempty"` // Most common values of other columns
+}
+
+// Bin counts the values of a numeric column in [Low, High)
+type Bin struct {
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	Count      int64   `json:"count"`
+	Suppressed bool    `json:"suppressed,omitempty"` // Too few rows to show; Count is zero
+}
+
+// ValueCount is how many rows hold a value
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ProfileFunc computes the profile of a registered asset, with numeric
+// columns in histograms of bins bins and up to top most common values of
+// the others. The privacy service runs it in a sandbox.
+type ProfileFunc func(ctx context.Context, a Asset, bins, top int) (Profile, error)
+
+// ProfileOptions configures a Profiler
+type ProfileOptions struct {
+	Bins      int           // Histogram bins per numeric column
+	TopValues int           // Most common values listed per other column
+	MinCount  int           // Bins and values counting fewer rows are withheld
+	CacheTTL  time.Duration // How long a profile is served before it is computed again
+	Timeout   time.Duration // Longest a profiling job may run
+}
+
+// ProfileStatus is the state of a profiling job
+type ProfileStatus string
+
+// Profiling job states
+const (
+	ProfilePending   ProfileStatus = "pending"
+	ProfileCompleted ProfileStatus = "completed"
+	ProfileFailed    ProfileStatus = "failed"
+)
+
+// ProfileJob is the profiling of one asset and, once it completes, the
+// profile
+type ProfileJob struct {
+	AssetID    string        `json:"assetId"`
+	Status     ProfileStatus `json:"status"`
+	Profile    *Profile      `json:"profile,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// Profiler runs profiling jobs and caches their profiles. A cached profile
+// is dropped once its asset is registered again with different content. It
+// is safe for concurrent use.
+type Profiler struct {
+	registry *Registry
+	run      ProfileFunc
+	opts     ProfileOptions
+
+	mu   sync.Mutex
+	jobs map[string]*ProfileJob // By asset ID and checksum
+	wg   sync.WaitGroup
+	now  func() time.Time
+}
+
+// NewProfiler creates a profiler for the assets in registry that computes
+// profiles with run
+func NewProfiler(registry *Registry, run ProfileFunc, opts ProfileOptions) *Profiler {
+	return &Profiler{registry: registry, run: run, opts: opts, jobs: make(map[string]*ProfileJob), now: time.Now}
+}
+
+// Profile returns the profiling job of a registered asset. A job is
+// started in the background unless the asset's content has a fresh
+// profile, a job is still running on it, or one failed within the last
+// minute.
+func (p *Profiler) Profile(id string) (ProfileJob, error) {
+	a, ok := p.registry.Get(id)
+	if !ok {
+		return ProfileJob{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	key := a.ID + "|" + a.SHA256
+	now := p.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if job, ok := p.jobs[key]; ok {
+		switch {
+		case job.Status == ProfilePending,
+			job.Status == ProfileCompleted && now.Sub(job.Profile.GeneratedAt) < p.opts.CacheTTL,
+			job.Status == ProfileFailed && now.Sub(*job.FinishedAt) < profileRetryAfter:
+			return job.copy(), nil
+		}
+	}
+
+	p.forget(a.ID)
+	job := &ProfileJob{AssetID: a.ID, Status: ProfilePending, StartedAt: now.UTC()}
+	p.jobs[key] = job
+	p.wg.Add(1)
+	go p.profile(key, a)
+	return job.copy(), nil
+}
+
+// profile runs a profiling job and records how it ended
+func (p *Profiler) profile(key string, a Asset) {
+	defer p.wg.Done()
+	ctx := context.Background()
+	if p.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+		defer cancel()
+	}
+	profile, err := p.run(ctx, a, p.opts.Bins, p.opts.TopValues)
+
+	finished := p.now().UTC()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.jobs[key]
+	if !ok {
+		// Forgotten while it ran
+		return
+	}
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status = ProfileFailed
+		job.Error = err.Error()
+		return
+	}
+	profile.AssetID = a.ID
+	profile.ProductID = a.ProductID
+	profile.SHA256 = a.SHA256
+	profile.GeneratedAt = finished
+	p.withhold(&profile)
+	job.Status = ProfileCompleted
+	job.Profile = &profile
+}
+
+// withhold zeroes histogram bins and drops common values counting fewer
+// rows than the minimum, so a profile does not single out a few records
+func (p *Profiler) withhold(profile *Profile) {
+	minCount := int64(p.opts.MinCount)
+	for i := range profile.Columns {
+		col := &profile.Columns[i]
+		for j := range col.Histogram {
+			if bin := &col.Histogram[j]; bin.Count > 0 && bin.Count < minCount {
+				bin.Count = 0
+				bin.Suppressed = true
+			}
+		}
+		kept := col.TopValues[:0]
+		for _, v := range col.TopValues {
+			if v.Count >= minCount {
+				kept = append(kept, v)
+			}
+		}
+		col.TopValues = kept
+		if len(col.TopValues) == 0 {
+			col.TopValues = nil
+		}
+	}
+}
+
+// Forget drops the profiles of an asset, such as one whose data was erased
+func (p *Profiler) Forget(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forget(id)
+}
+
+// forget drops an asset's jobs. Caller must hold p.mu.
+func (p *Profiler) forget(id string) {
+	for k := range p.jobs {
+		if strings.HasPrefix(k, id+"|") {
+			delete(p.jobs, k)
+		}
+	}
+}
+
+// Wait blocks until the profiling jobs running have finished
+func (p *Profiler) Wait() {
+	p.wg.Wait()
+}
+
+// copy returns a copy of job to hand out once p.mu is released. A
+// completed profile is never changed, so its columns are shared.
+func (job *ProfileJob) copy() ProfileJob {
+	c := *job
+	if job.Profile != nil {
+		profile := *job.Profile
+		c.Profile = &profile
+	}
+	if job.FinishedAt != nil {
+		finished := *job.FinishedAt
+		c.FinishedAt = &finished
+	}
+	return c
+}
@@ -0,0 +1,208 @@
+package assets
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/telemetry"
+)
+
+// emptySHA256 is the hex hash of an empty request body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options configures the driver for s3://<bucket>/<key> sources
+type S3Options struct {
+	Endpoint string // Base URL of an S3-compatible store; empty uses AWS S3 in Region
+	Region   string
+	// PathStyle addresses buckets as <endpoint>/<bucket> rather than
+	// <bucket>.<endpoint>, as MinIO and most other stores expect
+	PathStyle       bool
+	AccessKeyID     string // Empty sends requests unsigned, for public buckets
+	SecretAccessKey string
+	SessionToken    string // For temporary credentials
+}
+
+// S3Storage reads and deletes objects in an S3-compatible store, signing
+// requests with AWS Signature Version 4
+type S3Storage struct {
+	opts   S3Options
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Storage creates a driver for s3:// sources
+func NewS3Storage(opts S3Options) *S3Storage {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	opts.Endpoint = strings.TrimRight(opts.Endpoint, "/")
+	return &S3Storage{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Minute, Transport: telemetry.Transport(nil)},
+		now:    time.Now,
+	}
+}
+
+// Open streams an object
+func (s *S3Storage) Open(ctx context.Context, source string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object. S3 reports success for objects that do not
+// exist.
+func (s *S3Storage) Delete(ctx context.Context, source string) error {
+	resp, err := s.do(ctx, http.MethodDelete, source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// do sends a bodiless request for the object at source
+func (s *S3Storage) do(ctx context.Context, method, source string) (*http.Response, error) {
+	bucket, key, err := parseS3Source(source)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := url.Parse(s.opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	u := *endpoint
+	object := "/" + key
+	if s.opts.PathStyle {
+		object = "/" + bucket + object
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	// Keys are escaped as SigV4 canonicalizes them, which is stricter than
+	// net/url
+	u.RawPath = endpoint.EscapedPath() + s3Escape(object)
+	u.Path += object
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	s.sign(req, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach S3: %w", err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers for a bodiless request to req,
+// covering its host and every header already set on it
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	if s.opts.AccessKeyID == "" {
+		return
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if s.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), date)
+	for _, part := range []string{s.opts.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// parseS3Source splits s3://<bucket>/<key>
+func parseS3Source(source string) (bucket, key string, err error) {
+	path, ok := strings.CutPrefix(source, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%q is not an s3:// source", source)
+	}
+	bucket, key, _ = strings.Cut(path, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("%q must be s3://<bucket>/<key>", source)
+	}
+	return bucket, key, nil
+}
+
+// s3Escape percent-encodes every byte of path but unreserved characters
+// and slashes
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error reads the error an S3 response carries
+func s3Error(resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+}
@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"pandacea/agent-backend/internal/atrest"
+)
+
+// Storage keeps asset sources of one kind. The registry picks the driver
+// by the source's scheme: ipfs://<cid> and s3://<bucket>/<key> go to their
+// drivers, and anything without a scheme is a local path.
+type Storage interface {
+	// Open streams the content of source. The registry hashes what it reads,
+	// so drivers need not verify it.
+	Open(ctx context.Context, source string) (io.ReadCloser, error)
+	// Delete destroys source. A source that is already gone is not an
+	// error.
+	Delete(ctx context.Context, source string) error
+}
+
+// Storage schemes the registry knows without configuration
+const (
+	SchemeLocal = ""
+	SchemeIPFS  = "ipfs"
+	SchemeS3    = "s3"
+)
+
+// Scheme returns the scheme of an asset source, or SchemeLocal for a path
+func Scheme(source string) string {
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok || scheme == "" || strings.ContainsAny(scheme, `/\.`) {
+		return SchemeLocal
+	}
+	return strings.ToLower(scheme)
+}
+
+// Local reports whether a's source is a file on the agent's disk
+func (a Asset) Local() bool {
+	return Scheme(a.Source) == SchemeLocal
+}
+
+// localStorage reads files on the agent's disk, decrypting them with the
+// registry's keyring if they were encrypted at rest
+type localStorage struct {
+	keyring func() *atrest.Keyring
+}
+
+func (s *localStorage) Open(ctx context.Context, source string) (io.ReadCloser, error) {
+	f, err := s.keyring().Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset: %w", err)
+	}
+	return f, nil
+}
+
+func (s *localStorage) Delete(ctx context.Context, source string) error {
+	if err := os.Remove(source); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ipfsStorage reads ipfs://<cid> sources through a node's HTTP API
+type ipfsStorage struct {
+	apiURL string
+	client *http.Client
+}
+
+func (s *ipfsStorage) Open(ctx context.Context, source string) (io.ReadCloser, error) {
+	resp, err := s.call(ctx, "cat", strings.TrimPrefix(source, "ipfs://"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("IPFS API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return resp.Body, nil
+}
+
+// Delete releases the node's pin on the CID. Content that is not pinned is
+// not an error.
+func (s *ipfsStorage) Delete(ctx context.Context, source string) error {
+	resp, err := s.call(ctx, "pin/rm", strings.TrimPrefix(source, "ipfs://"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if strings.Contains(string(detail), "not pinned") {
+			return nil
+		}
+		return fmt.Errorf("IPFS API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// call POSTs an IPFS API command on a CID
+func (s *ipfsStorage) call(ctx context.Context, command, cid string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.apiURL+"/api/v0/"+command+"?arg="+url.QueryEscape(cid), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IPFS request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach IPFS: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,452 @@
+// Package atrest encrypts the files the agent keeps on disk: data assets
+// and training artifacts. Each file is encrypted with its own random data
+// key under AES-256-GCM, in chunks so large files stream, and the data key
+// is wrapped with a master key from a Keyring. Rotating the master key only
+// rewraps each file's data key; the data is not encrypted again.
+//
+// An encrypted file is the magic string, a length-prefixed JSON header
+// naming the master key and holding the wrapped data key, then chunks of at
+// most 64 KiB of plaintext, each prefixed with its ciphertext length. The
+// length's top bit marks the final chunk. Each chunk's index and final flag
+// are authenticated, so chunks cannot be reordered, dropped or truncated.
+package atrest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// magic starts every encrypted file
+const magic = "PANDACEA-ATREST1"
+
+const (
+	chunkSize  = 64 << 10
+	finalBit   = 1 << 31
+	maxHeader  = 4 << 10
+	dataKeyLen = 32
+)
+
+var (
+	// ErrCorrupt is returned for encrypted files that fail authentication
+	// or end early
+	ErrCorrupt = errors.New("encrypted file is corrupt or truncated")
+	// ErrUnknownKey is returned for files wrapped with a master key the
+	// keyring does not hold
+	ErrUnknownKey = errors.New("file is encrypted with a master key not in the keyring")
+	// ErrNoKeyring is returned when an encrypted file is read without a
+	// keyring
+	ErrNoKeyring = errors.New("file is encrypted but encryption at rest is not enabled")
+)
+
+// header is the JSON header of an encrypted file
+type header struct {
+	KeyID      string `json:"key_id"`      // Master key the data key is wrapped with
+	WrappedKey []byte `json:"wrapped_key"` // Nonce and AES-GCM sealed data key
+}
+
+// Encrypt returns a writer that encrypts what is written to it into w
+// under a new data key. Close writes the final chunk; it does not close w.
+func (k *Keyring) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	id, masterKey := k.currentKey()
+	wrapped, err := wrap(masterKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHeader(w, header{KeyID: id, WrappedKey: wrapped}); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Decrypt returns a reader of the plaintext of an encrypted stream
+func (k *Keyring) Decrypt(r io.Reader) (io.Reader, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{r: r, aead: aead}, nil
+}
+
+// Open opens a file for reading, decrypting it if it is encrypted. Files
+// that are not encrypted are read as they are, so data stored before
+// encryption was enabled stays readable. A nil keyring can open only files
+// that are not encrypted.
+func (k *Keyring) Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	if !isEncrypted(br) {
+		return readCloser{br, f}, nil
+	}
+	if k == nil {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNoKeyring, path)
+	}
+	plain, err := k.Decrypt(br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return readCloser{plain, f}, nil
+}
+
+// ReadFile reads a file, decrypting it if it is encrypted
+func (k *Keyring) ReadFile(path string) ([]byte, error) {
+	f, err := k.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile writes data to path, encrypted unless the keyring is nil
+func (k *Keyring) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if k == nil {
+		return os.WriteFile(path, data, perm)
+	}
+	return replace(path, perm, func(w io.Writer) error {
+		enc, err := k.Encrypt(w)
+		if err != nil {
+			return err
+		}
+		if _, err := enc.Write(data); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+}
+
+// EncryptFile encrypts a file in place. It reports false if the file was
+// already encrypted.
+func (k *Keyring) EncryptFile(path string) (bool, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return false, err
+	}
+	br := bufio.NewReader(src)
+	if isEncrypted(br) {
+		return false, nil
+	}
+	return true, replace(path, info.Mode().Perm(), func(w io.Writer) error {
+		enc, err := k.Encrypt(w)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(enc, br); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+}
+
+// Rewrap wraps an encrypted file's data key with the current master key.
+// Only the header is rewritten. It reports false if the file is not
+// encrypted or already uses the current key.
+func (k *Keyring) Rewrap(path string) (bool, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return false, err
+	}
+	br := bufio.NewReader(src)
+	if !isEncrypted(br) {
+		return false, nil
+	}
+	h, err := readHeader(br)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	id, masterKey := k.currentKey()
+	if h.KeyID == id {
+		return false, nil
+	}
+	dataKey, err := k.unwrap(h)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	wrapped, err := wrap(masterKey, dataKey)
+	if err != nil {
+		return false, err
+	}
+	return true, replace(path, info.Mode().Perm(), func(w io.Writer) error {
+		if err := writeHeader(w, header{KeyID: id, WrappedKey: wrapped}); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, br)
+		return err
+	})
+}
+
+// KeyID returns the ID of the master key an encrypted file's data key is
+// wrapped with, or false if the file is not encrypted
+func KeyID(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if !isEncrypted(br) {
+		return "", false, nil
+	}
+	h, err := readHeader(br)
+	if err != nil {
+		return "", true, fmt.Errorf("%s: %w", path, err)
+	}
+	return h.KeyID, true, nil
+}
+
+// isEncrypted reports whether a stream starts with the magic string
+func isEncrypted(br *bufio.Reader) bool {
+	start, err := br.Peek(len(magic))
+	return err == nil && string(start) == magic
+}
+
+// unwrap recovers a file's data key
+func (k *Keyring) unwrap(h header) ([]byte, error) {
+	masterKey, ok := k.key(h.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, h.KeyID)
+	}
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.WrappedKey) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	nonce, sealed := h.WrappedKey[:aead.NonceSize()], h.WrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(magic))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return dataKey, nil
+}
+
+// wrap seals a data key with a master key
+func wrap(masterKey, dataKey []byte) ([]byte, error) {
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(magic)), nil
+}
+
+// writeHeader writes the magic string and header
+func writeHeader(w io.Writer, h header) error {
+	encoded, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to encode header: %w", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(encoded)))
+	buf.Write(encoded)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// readHeader reads the magic string and header
+func readHeader(r io.Reader) (header, error) {
+	var h header
+	prefix := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil || string(prefix[:len(magic)]) != magic {
+		return h, ErrCorrupt
+	}
+	size := binary.BigEndian.Uint32(prefix[len(magic):])
+	if size > maxHeader {
+		return h, ErrCorrupt
+	}
+	encoded := make([]byte, size)
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return h, ErrCorrupt
+	}
+	if err := json.Unmarshal(encoded, &h); err != nil {
+		return h, ErrCorrupt
+	}
+	return h, nil
+}
+
+// newGCM creates an AES-256-GCM cipher
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce and chunkAAD bind a chunk to its place in the file. Each
+// file has its own data key, so the index alone makes nonces unique.
+func chunkNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+func chunkAAD(index uint64, final bool) []byte {
+	aad := binary.BigEndian.AppendUint64(nil, index)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// writer encrypts chunks as they fill. A full chunk is held back until
+// more data arrives, so Close can mark the last one final.
+type writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) Close() error {
+	return w.flush(true)
+}
+
+// flush encrypts and writes the buffered chunk
+func (w *writer) flush(final bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.aead, w.index), w.buf, chunkAAD(w.index, final))
+	length := uint32(len(sealed))
+	if final {
+		length |= finalBit
+	}
+	if err := binary.Write(w.w, binary.BigEndian, length); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// reader decrypts chunks as they are read
+type reader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+	done  bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk
+func (r *reader) next() error {
+	var length uint32
+	if err := binary.Read(r.r, binary.BigEndian, &length); err != nil {
+		return ErrCorrupt
+	}
+	final := length&finalBit != 0
+	length &^= finalBit
+	if length > chunkSize+uint32(r.aead.Overhead()) {
+		return ErrCorrupt
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return ErrCorrupt
+	}
+	plain, err := r.aead.Open(sealed[:0], chunkNonce(r.aead, r.index), sealed, chunkAAD(r.index, final))
+	if err != nil {
+		return ErrCorrupt
+	}
+	if final {
+		// Nothing may follow the final chunk
+		if n, _ := r.r.Read(make([]byte, 1)); n > 0 {
+			return ErrCorrupt
+		}
+		r.done = true
+	}
+	r.index++
+	r.buf = plain
+	return nil
+}
+
+// readCloser reads from a decrypting reader and closes the file under it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// replace writes a file through a temporary file in the same directory,
+// so readers never see it half written
+func replace(path string, perm os.FileMode, write func(io.Writer) error) error {
+	if err := fsutil.Replace(path, perm, write); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
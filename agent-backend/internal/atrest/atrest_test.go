@@ -0,0 +1,127 @@
+package atrest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptFileRoundTrip(t *testing.T) {
+	k, err := NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	// Sizes around the chunk boundary, where the final chunk may be empty
+	for _, size := range []int{0, 100, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		data := make([]byte, size)
+		rand.Read(data)
+		path := filepath.Join(dir, "asset.csv")
+		if err := os.WriteFile(path, data, 0640); err != nil {
+			t.Fatal(err)
+		}
+
+		if encrypted, err := k.EncryptFile(path); err != nil || !encrypted {
+			t.Fatalf("EncryptFile(%d bytes) = %v, %v", size, encrypted, err)
+		}
+		if encrypted, _ := k.EncryptFile(path); encrypted {
+			t.Errorf("%d bytes: an encrypted file was encrypted again", size)
+		}
+		if stored, _ := os.ReadFile(path); size > 0 && bytes.Contains(stored, data) {
+			t.Errorf("%d bytes: plaintext stored", size)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+			t.Errorf("%d bytes: mode = %v", size, info.Mode().Perm())
+		}
+		got, err := k.ReadFile(path)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: ReadFile = %d bytes, %v", size, len(got), err)
+		}
+	}
+
+	// Files that are not encrypted are read as they are, even without a keyring
+	plain := filepath.Join(dir, "plain.csv")
+	os.WriteFile(plain, []byte("a,b\n"), 0644)
+	var none *Keyring
+	if got, err := none.ReadFile(plain); err != nil || string(got) != "a,b\n" {
+		t.Errorf("ReadFile(plaintext) = %q, %v", got, err)
+	}
+	if _, err := none.ReadFile(filepath.Join(dir, "asset.csv")); !errors.Is(err, ErrNoKeyring) {
+		t.Errorf("ReadFile without keyring error = %v, want %v", err, ErrNoKeyring)
+	}
+}
+
+func TestTamperingDetected(t *testing.T) {
+	k, err := NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "aggregate.json")
+	data := bytes.Repeat([]byte("x"), 2*chunkSize+10)
+	if err := k.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := os.ReadFile(path)
+
+	for name, tampered := range map[string][]byte{
+		"flipped bit": func() []byte {
+			b := bytes.Clone(stored)
+			b[len(b)-20] ^= 1
+			return b
+		}(),
+		"truncated": stored[:len(stored)-chunkSize],
+		"appended":  append(bytes.Clone(stored), 0),
+	} {
+		os.WriteFile(path, tampered, 0644)
+		if _, err := k.ReadFile(path); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: ReadFile error = %v, want %v", name, err, ErrCorrupt)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.json")
+	k, err := LoadOrCreateKeyring(keyringPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := k.Current()
+	path := filepath.Join(dir, "asset.csv")
+	if err := k.WriteFile(path, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := k.Rotate()
+	if err != nil || current == old {
+		t.Fatalf("Rotate() = %s, %v", current, err)
+	}
+	// Before rewrapping, the old key is still in use
+	if retired := k.Retire(map[string]bool{old: true}); len(retired) != 0 {
+		t.Errorf("retired %v while in use", retired)
+	}
+	if rewrapped, err := k.Rewrap(path); err != nil || !rewrapped {
+		t.Fatalf("Rewrap() = %v, %v", rewrapped, err)
+	}
+	if id, encrypted, err := KeyID(path); err != nil || !encrypted || id != current {
+		t.Errorf("KeyID() = %s, %v, %v", id, encrypted, err)
+	}
+	if retired := k.Retire(nil); len(retired) != 1 || retired[0] != old {
+		t.Errorf("Retire() = %v", retired)
+	}
+	if err := k.Save(keyringPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadKeyring(keyringPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reloaded.ReadFile(path); err != nil || string(got) != "secret" {
+		t.Errorf("ReadFile after rotation = %q, %v", got, err)
+	}
+}
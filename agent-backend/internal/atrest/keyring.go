@@ -0,0 +1,204 @@
+package atrest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// KeySize is the length of a master key
+const KeySize = 32
+
+// Keyring holds the master keys data keys are wrapped with. New files are
+// wrapped with the current key; older keys are kept until no file uses
+// them. It is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// keyringFile is the stored form of a keyring
+type keyringFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"` // Base64 keys by ID
+}
+
+// NewKeyring creates a keyring with one new master key
+func NewKeyring() (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	if _, err := k.Rotate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ParseKeyring parses a stored keyring
+func ParseKeyring(data []byte) (*Keyring, error) {
+	var stored keyringFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	k := &Keyring{current: stored.Current, keys: make(map[string][]byte)}
+	for id, encoded := range stored.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("master key %s is not %d base64 bytes", id, KeySize)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[k.current]; !ok {
+		return nil, fmt.Errorf("keyring's current key %q is not in it", stored.Current)
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the keyring at path. A leading ~ is expanded to the
+// home directory.
+func LoadKeyring(path string) (*Keyring, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	return ParseKeyring(data)
+}
+
+// LoadOrCreateKeyring reads the keyring at path, generating and saving a
+// new one if the file does not exist
+func LoadOrCreateKeyring(path string) (*Keyring, error) {
+	k, err := LoadKeyring(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return k, err
+	}
+	if k, err = NewKeyring(); err != nil {
+		return nil, err
+	}
+	if err := k.Save(path); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Marshal returns the keyring's stored form
+func (k *Keyring) Marshal() ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	stored := keyringFile{Current: k.current, Keys: make(map[string]string, len(k.keys))}
+	for id, key := range k.keys {
+		stored.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	return json.MarshalIndent(stored, "", "  ")
+}
+
+// Save writes the keyring to path, readable only by its owner
+func (k *Keyring) Save(path string) error {
+	path, err := expandHome(path)
+	if err != nil {
+		return err
+	}
+	data, err := k.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode keyring: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+	if err := fsutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
+
+// Current returns the ID of the key new files are wrapped with
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// IDs returns the IDs of the keyring's keys
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Rotate adds a new master key and makes it current. Files keep their
+// data keys wrapped with the old key until they are rewrapped.
+func (k *Keyring) Rotate() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate master key: %w", err)
+	}
+	sum := sha256.Sum256(key)
+	id := hex.EncodeToString(sum[:8])
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = key
+	k.current = id
+	return id, nil
+}
+
+// Retire drops the keys other than the current one that no file uses and
+// returns their IDs
+func (k *Keyring) Retire(inUse map[string]bool) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var retired []string
+	for id := range k.keys {
+		if id != k.current && !inUse[id] {
+			delete(k.keys, id)
+			retired = append(retired, id)
+		}
+	}
+	sort.Strings(retired)
+	return retired
+}
+
+// key returns the master key with id
+func (k *Keyring) key(id string) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// currentKey returns the current master key and its ID
+func (k *Keyring) currentKey() (string, []byte) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current, k.keys[k.current]
+}
+
+// expandHome expands a leading ~ in path to the home directory
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,95 @@
+// Package attest records signed statements of what each computation ran:
+// the script, the inputs, the sandbox image and a hash of the result. Only
+// content IDs and hashes are included, so a third party can audit what ran
+// against leased data without seeing the data. The hash of each statement
+// can be anchored on-chain so the agent cannot rewrite it later.
+package attest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Version is the statement format this package writes
+const Version = 1
+
+// Input is a data asset a computation read
+type Input struct {
+	AssetID string `json:"asset_id"`
+	CID     string `json:"cid,omitempty"` // Set for assets registered from IPFS
+	SHA256  string `json:"sha256"`        // Hex SHA-256 of the file the computation was given
+}
+
+// Statement describes one completed computation. Its compact JSON encoding,
+// with the fields in the order declared here, is what is hashed and signed.
+type Statement struct {
+	Version       int       `json:"version"`
+	ComputationID string    `json:"computation_id"`
+	LeaseID       string    `json:"lease_id"`
+	ScriptCID     string    `json:"script_cid"`
+	ScriptSHA256  string    `json:"script_sha256"`
+	Inputs        []Input   `json:"inputs"`
+	ImageDigest   string    `json:"image_digest,omitempty"` // Of the sandbox the computation ran in, if the backend reports one
+	OutputSHA256  string    `json:"output_sha256"`          // Of the output and artifacts, before watermarking and sealing
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+// Encode returns the bytes of s that are hashed and signed
+func (s Statement) Encode() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode attestation statement: %w", err)
+	}
+	return data, nil
+}
+
+// Anchor is the transaction carrying an attestation's hash on-chain
+type Anchor struct {
+	TxID string `json:"tx_id"` // Transaction manager record of the transaction
+	To   string `json:"to"`    // Address the transaction is sent to; its data is the hash
+}
+
+// Attestation is a signed statement and where its hash is anchored
+type Attestation struct {
+	Statement Statement                    `json:"statement"`
+	Signature respsig.AttestationSignature `json:"signature"`
+	Anchor    *Anchor                      `json:"anchor,omitempty"`
+}
+
+// Sign encodes statement and signs it with signer
+func Sign(signer *respsig.Signer, statement Statement) (*Attestation, error) {
+	data, err := statement.Encode()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.SignAttestation(statement.ComputationID, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Attestation{Statement: statement, Signature: *sig}, nil
+}
+
+// Verify checks that a's signature covers its statement and, if
+// expectedPeerID is not empty, that the peer signed it. It returns the peer
+// ID that signed the statement.
+func Verify(a Attestation, expectedPeerID string) (string, error) {
+	if a.Signature.ComputationID != a.Statement.ComputationID {
+		return "", fmt.Errorf("%w: signature is for computation %s", respsig.ErrInvalidSignature, a.Signature.ComputationID)
+	}
+	data, err := a.Statement.Encode()
+	if err != nil {
+		return "", err
+	}
+	return respsig.VerifyAttestation(a.Signature, data, expectedPeerID)
+}
+
+// Hash returns the statement hash that is anchored on-chain
+func (a Attestation) Hash() common.Hash {
+	return common.HexToHash(a.Signature.SHA256)
+}
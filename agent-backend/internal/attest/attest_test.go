@@ -0,0 +1,109 @@
+package attest
+
+import (
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func newSigner(t *testing.T) *respsig.Signer {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	signer, err := respsig.NewSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return signer
+}
+
+func testStatement() Statement {
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Statement{
+		Version:       Version,
+		ComputationID: "comp_1",
+		LeaseID:       "lease_1",
+		ScriptCID:     "QmScript",
+		ScriptSHA256:  "aa",
+		Inputs:        []Input{{AssetID: "sales", CID: "QmSales", SHA256: "bb"}},
+		ImageDigest:   "sha256:cc",
+		OutputSHA256:  "dd",
+		StartedAt:     started,
+		FinishedAt:    started.Add(time.Minute),
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newSigner(t)
+	a, err := Sign(signer, testStatement())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if got, err := Verify(*a, signer.PeerID()); err != nil || got != signer.PeerID() {
+		t.Fatalf("Verify() = %s, %v; want %s", got, err, signer.PeerID())
+	}
+	if a.Hash().Hex() != "0x"+a.Signature.SHA256 {
+		t.Errorf("Hash() = %s, want the signed SHA-256 %s", a.Hash().Hex(), a.Signature.SHA256)
+	}
+
+	tampered := *a
+	tampered.Statement.Inputs = []Input{{AssetID: "sales", SHA256: "ee"}}
+	if _, err := Verify(tampered, ""); !errors.Is(err, respsig.ErrInvalidSignature) {
+		t.Errorf("Verify() of changed inputs error = %v, want ErrInvalidSignature", err)
+	}
+	moved := *a
+	moved.Statement.ComputationID = "comp_2"
+	if _, err := Verify(moved, ""); !errors.Is(err, respsig.ErrInvalidSignature) {
+		t.Errorf("Verify() of another computation error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attestations.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	a, err := Sign(newSigner(t), testStatement())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := store.Add(*a); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := store.Add(*a); err == nil {
+		t.Error("Add() of an attested computation succeeded")
+	}
+	if err := store.SetAnchor("comp_1", Anchor{TxID: "tx_1", To: "0x01"}); err != nil {
+		t.Fatalf("SetAnchor() error = %v", err)
+	}
+	if err := store.SetAnchor("comp_2", Anchor{TxID: "tx_2"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAnchor() of unknown computation error = %v, want ErrNotFound", err)
+	}
+
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() restore error = %v", err)
+	}
+	got, err := restored.Get("comp_1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Anchor == nil || got.Anchor.TxID != "tx_1" {
+		t.Errorf("restored anchor = %+v, want tx_1", got.Anchor)
+	}
+	if _, err := Verify(got, ""); err != nil {
+		t.Errorf("Verify() of restored attestation error = %v", err)
+	}
+	if _, err := restored.Get("comp_2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of unknown computation error = %v, want ErrNotFound", err)
+	}
+}
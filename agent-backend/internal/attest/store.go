@@ -0,0 +1,102 @@
+package attest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// ErrNotFound is returned for computations without an attestation
+var ErrNotFound = errors.New("attestation not found")
+
+// Store keeps attestations by computation ID. It is safe for concurrent
+// use.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	records map[string]*Attestation
+}
+
+// NewStore creates a store that persists attestations to path unless it is
+// empty, restoring any already saved there
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, records: make(map[string]*Attestation)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attestations: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("failed to parse attestations: %w", err)
+	}
+	if s.records == nil {
+		s.records = make(map[string]*Attestation)
+	}
+	return s, nil
+}
+
+// Add stores a computation's attestation
+func (s *Store) Add(a Attestation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[a.Statement.ComputationID]; exists {
+		return fmt.Errorf("computation %s is already attested", a.Statement.ComputationID)
+	}
+	s.records[a.Statement.ComputationID] = &a
+	return s.save()
+}
+
+// Get returns a computation's attestation
+func (s *Store) Get(computationID string) (Attestation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.records[computationID]
+	if !ok {
+		return Attestation{}, fmt.Errorf("%w: %s", ErrNotFound, computationID)
+	}
+	return *a, nil
+}
+
+// SetAnchor records the transaction anchoring a computation's attestation
+func (s *Store) SetAnchor(computationID string, anchor Anchor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.records[computationID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, computationID)
+	}
+	a.Anchor = &anchor
+	return s.save()
+}
+
+// save writes the attestations to disk. Caller must hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return fmt.Errorf("failed to encode attestations: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create attestations directory: %w", err)
+	}
+	if err := fsutil.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write attestations: %w", err)
+	}
+	return nil
+}
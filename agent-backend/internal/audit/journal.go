@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GenesisHash is the previous hash of a journal's first record
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// ErrChainBroken is returned when a journal record does not chain to the one
+// before it, meaning the journal was edited, reordered or truncated
+var ErrChainBroken = errors.New("audit journal hash chain is broken")
+
+// errTornRecord marks a last record that is not valid JSON, as a crash
+// mid-write can leave when the file grew before its data reached disk
+var errTornRecord = errors.New("last audit record is torn")
+
+// Record is one line of a journal. Hash covers the previous record's hash and
+// the event exactly as written, so changing, removing or reordering any
+// record breaks every hash after it.
+type Record struct {
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Verification summarises a journal whose hash chain is intact
+type Verification struct {
+	Records int    `json:"records"`
+	LastSeq uint64 `json:"last_seq"`
+	Head    string `json:"head"` // Hash of the last record
+}
+
+// Journal is an append-only file of hash-chained events
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	records int
+	head    string
+	lastSeq uint64
+}
+
+// recordHash returns the hash that chains event to the record hashed prevHash
+func recordHash(prevHash string, event []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(prevHash))
+	sum.Write([]byte("\n"))
+	sum.Write(event)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// OpenJournal opens or creates the journal at path and verifies its hash
+// chain. An unterminated or unparseable last line, left by a crash
+// mid-write, is discarded. A last record that parses but does not chain
+// is still an error, since only tampering leaves one.
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit journal directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit journal: %w", err)
+	}
+
+	j := &Journal{file: file}
+	verification, complete, err := verify(file, nil)
+	if errors.Is(err, errTornRecord) {
+		err = nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(complete); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to discard partial audit record: %w", err)
+	}
+	if _, err := file.Seek(complete, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek audit journal: %w", err)
+	}
+
+	j.size = complete
+	j.records = verification.Records
+	j.head = verification.Head
+	j.lastSeq = verification.LastSeq
+	return j, nil
+}
+
+// Verify checks the hash chain of a journal read from r
+func Verify(r io.Reader) (Verification, error) {
+	verification, _, err := verify(r, nil)
+	return verification, err
+}
+
+// verify walks the records in r, calling fn for each, and returns the offset
+// just past the last complete line. A last line that is not valid JSON is
+// reported as errTornRecord, with the offset of its start.
+func verify(r io.Reader, fn func(Event)) (Verification, int64, error) {
+	verification := Verification{Head: GenesisHash}
+	reader := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A final line without a newline was never fully written
+			return verification, offset, nil
+		}
+		if err != nil {
+			return verification, offset, fmt.Errorf("failed to read audit journal: %w", err)
+		}
+		start := offset
+		offset += int64(len(line))
+
+		var record Record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			err = fmt.Errorf("%w: record %d is not valid JSON", ErrChainBroken, verification.Records+1)
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return verification, start, fmt.Errorf("%w: %w", errTornRecord, err)
+			}
+			return verification, offset, err
+		}
+		if record.PrevHash != verification.Head || record.Hash != recordHash(record.PrevHash, record.Event) {
+			return verification, offset, fmt.Errorf("%w at record %d", ErrChainBroken, verification.Records+1)
+		}
+
+		var event Event
+		if err := json.Unmarshal(record.Event, &event); err != nil {
+			return verification, offset, fmt.Errorf("%w: record %d has an invalid event", ErrChainBroken, verification.Records+1)
+		}
+		if verification.Records > 0 && event.Seq <= verification.LastSeq {
+			return verification, offset, fmt.Errorf("%w: record %d repeats seq %d", ErrChainBroken, verification.Records+1, event.Seq)
+		}
+
+		verification.Records++
+		verification.LastSeq = event.Seq
+		verification.Head = record.Hash
+		if fn != nil {
+			event.Hash = record.Hash
+			fn(event)
+		}
+	}
+}
+
+// write appends event to the journal and returns the record's hash
+func (j *Journal) write(event Event) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record := Record{PrevHash: j.head, Hash: recordHash(j.head, data), Event: data}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := j.file.Write(line); err != nil {
+		return "", fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync audit journal: %w", err)
+	}
+
+	j.size += int64(len(line))
+	j.records++
+	j.head = record.Hash
+	j.lastSeq = event.Seq
+	return record.Hash, nil
+}
+
+// replay calls fn for every event in the journal
+func (j *Journal) replay(fn func(Event)) error {
+	j.mu.Lock()
+	size := j.size
+	j.mu.Unlock()
+
+	_, _, err := verify(io.NewSectionReader(j.file, 0, size), fn)
+	return err
+}
+
+// Export writes the journal as newline-delimited records and returns the
+// chain head the export ends at, which callers should publish alongside it
+func (j *Journal) Export(w io.Writer) (Verification, error) {
+	j.mu.Lock()
+	size := j.size
+	exported := Verification{Records: j.records, LastSeq: j.lastSeq, Head: j.head}
+	j.mu.Unlock()
+
+	if _, err := io.Copy(w, io.NewSectionReader(j.file, 0, size)); err != nil {
+		return Verification{}, fmt.Errorf("failed to export audit journal: %w", err)
+	}
+	return exported, nil
+}
+
+// Verify re-reads the journal from disk and checks its hash chain
+func (j *Journal) Verify() (Verification, error) {
+	j.mu.Lock()
+	size := j.size
+	j.mu.Unlock()
+
+	return Verify(io.NewSectionReader(j.file, 0, size))
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
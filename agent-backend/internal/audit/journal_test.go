@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestJournal(t *testing.T, path string) *Journal {
+	t.Helper()
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	return journal
+}
+
+func TestJournalSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "journal.ndjson")
+
+	log := NewLog(100)
+	if err := log.SetJournal(openTestJournal(t, path), nil); err != nil {
+		t.Fatalf("SetJournal() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if event := log.Append("lease.proposed", "peer", map[string]any{"i": i}); event.Hash == "" {
+			t.Fatalf("Append() = %+v, want a journal hash", event)
+		}
+	}
+
+	// A restarted log continues the sequence and still lists old events
+	restarted := NewLog(100)
+	if err := restarted.SetJournal(openTestJournal(t, path), nil); err != nil {
+		t.Fatalf("SetJournal() after restart error = %v", err)
+	}
+	if event := restarted.Append("auth.verified", "peer", nil); event.Seq != 4 {
+		t.Errorf("Append() after restart seq = %d, want 4", event.Seq)
+	}
+	page, err := restarted.List(Query{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page.Events) != 4 || page.Events[0].Type != "lease.proposed" || page.Events[0].Hash == "" {
+		t.Errorf("List() after restart = %+v, want the 4 journaled events", page.Events)
+	}
+
+	verification, err := Verify(bytes.NewReader(readFile(t, path)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if verification.Records != 4 || verification.LastSeq != 4 || verification.Head != page.Events[3].Hash {
+		t.Errorf("Verify() = %+v, want 4 records ending at %s", verification, page.Events[3].Hash)
+	}
+}
+
+func TestJournalDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.ndjson")
+	log := NewLog(100)
+	if err := log.SetJournal(openTestJournal(t, path), nil); err != nil {
+		t.Fatalf("SetJournal() error = %v", err)
+	}
+	log.Append("lease.rejected", "peer", map[string]any{"reason": "price too low"})
+	log.Append("lease.proposed", "peer", nil)
+
+	original := readFile(t, path)
+	tampered := bytes.Replace(original, []byte("price too low"), []byte("price was fine"), 1)
+	if _, err := Verify(bytes.NewReader(tampered)); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("Verify() of an edited record error = %v, want ErrChainBroken", err)
+	}
+
+	// Dropping the first record breaks the chain of the second
+	_, rest, _ := bytes.Cut(original, []byte("\n"))
+	if _, err := Verify(bytes.NewReader(rest)); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("Verify() with a removed record error = %v, want ErrChainBroken", err)
+	}
+
+	if err := os.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := OpenJournal(path); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("OpenJournal() of a tampered journal error = %v, want ErrChainBroken", err)
+	}
+}
+
+func TestJournalDiscardsPartialRecord(t *testing.T) {
+	for name, tail := range map[string]string{
+		"unterminated": `{"prev_hash":"`,
+		"torn":         "{\"prev_hash\":\"\x00\x00\x00\x00\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "journal.ndjson")
+			log := NewLog(100)
+			if err := log.SetJournal(openTestJournal(t, path), nil); err != nil {
+				t.Fatalf("SetJournal() error = %v", err)
+			}
+			log.Append("auth.verified", "peer", nil)
+
+			// Simulate a crash part-way through writing the next record
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			file.WriteString(tail)
+			file.Close()
+			if _, err := Verify(bytes.NewReader(readFile(t, path))); name == "torn" && !errors.Is(err, ErrChainBroken) {
+				t.Errorf("Verify() of a torn journal error = %v, want ErrChainBroken", err)
+			}
+
+			restarted := NewLog(100)
+			journal := openTestJournal(t, path)
+			if err := restarted.SetJournal(journal, nil); err != nil {
+				t.Fatalf("SetJournal() error = %v", err)
+			}
+			restarted.Append("auth.failed", "peer", nil)
+
+			var exported bytes.Buffer
+			verification, err := journal.Export(&exported)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if verification.Records != 2 {
+				t.Errorf("Export() = %+v, want 2 records", verification)
+			}
+			if _, err := Verify(&exported); err != nil {
+				t.Errorf("Verify() after recovering from a partial record error = %v", err)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return data
+}
@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the number of events retained when no capacity is given
+const DefaultCapacity = 10000
+
+// MaxPageSize bounds the number of events returned by a single List call
+const MaxPageSize = 500
+
+var (
+	// ErrInvalidCursor is returned for cursors that were not issued by this log
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorExpired is returned when events after the cursor have already
+	// been evicted, so resuming from it would leave a gap
+	ErrCursorExpired = errors.New("cursor has expired")
+)
+
+// Event is a single entry in an append-only log. Seq is assigned on append and
+// is strictly increasing, which gives every reader the same stable order.
+type Event struct {
+	Seq    uint64         `json:"seq"`
+	Time   time.Time      `json:"time"`
+	Type   string         `json:"type"`
+	Actor  string         `json:"actor,omitempty"`
+	Fields map[string]any `json:"fields,omitempty"`
+	// Hash is the event's journal record hash, set when the log is journaled
+	Hash string `json:"hash,omitempty"`
+}
+
+// Query selects a page of events
+type Query struct {
+	// Cursor resumes after the last event of a previous page
+	Cursor string
+	// Since skips events recorded before this time when no cursor is given
+	Since time.Time
+	// Type restricts results to a single event type
+	Type string
+	// Match optionally restricts results to events it returns true for
+	Match func(Event) bool
+	// Limit is the maximum number of events to return
+	Limit int
+}
+
+// Page is a window of events in sequence order
+type Page struct {
+	Events     []Event
+	NextCursor string
+	HasMore    bool
+	// Watermark is the sequence number of the newest event in the log when the
+	// page was read; a reader is caught up once its cursor reaches it
+	Watermark uint64
+}
+
+// Log is a bounded, in-memory, append-only event log
+type Log struct {
+	mu       sync.RWMutex
+	events   []Event
+	capacity int
+	nextSeq  uint64
+	now      func() time.Time
+	// appended is closed on the next Append to wake readers waiting for events
+	appended chan struct{}
+	// journal persists appended events; journalError reports write failures
+	journal      *Journal
+	journalError func(error)
+}
+
+// NewLog creates an event log retaining at most capacity events
+func NewLog(capacity int) *Log {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Log{
+		capacity: capacity,
+		nextSeq:  1,
+		now:      time.Now,
+	}
+}
+
+// Append records an event and returns it with its sequence number assigned
+func (l *Log) Append(eventType, actor string, fields map[string]any) Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event := Event{
+		Seq:    l.nextSeq,
+		Time:   l.now().UTC(),
+		Type:   eventType,
+		Actor:  actor,
+		Fields: fields,
+	}
+	l.nextSeq++
+
+	if l.journal != nil {
+		hash, err := l.journal.write(event)
+		if err != nil && l.journalError != nil {
+			l.journalError(err)
+		}
+		event.Hash = hash
+	}
+
+	l.retain(event)
+
+	if l.appended != nil {
+		close(l.appended)
+		l.appended = nil
+	}
+
+	return event
+}
+
+// SetJournal persists every event appended from now on to j, calling onError
+// if a write fails. The journal's newest events are loaded and sequence
+// numbers continue from its last record, so cursors survive restarts.
+func (l *Log) SetJournal(j *Journal, onError func(error)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = nil
+	if err := j.replay(l.retain); err != nil {
+		return err
+	}
+	if len(l.events) > 0 {
+		l.nextSeq = l.events[len(l.events)-1].Seq + 1
+	}
+	l.journal = j
+	l.journalError = onError
+	return nil
+}
+
+// retain stores an event, evicting the oldest when the log is full. Caller
+// must hold mu.
+func (l *Log) retain(event Event) {
+	if len(l.events) >= l.capacity {
+		// Evict the oldest tenth in one copy rather than shifting on every append
+		evict := l.capacity/10 + 1
+		if evict > len(l.events) {
+			evict = len(l.events)
+		}
+		l.events = append(l.events[:0:0], l.events[evict:]...)
+	}
+	l.events = append(l.events, event)
+}
+
+// Appended returns a channel that is closed when the next event is appended.
+// Take it before listing so an event appended in between is not missed.
+func (l *Log) Appended() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.appended == nil {
+		l.appended = make(chan struct{})
+	}
+	return l.appended
+}
+
+// Cursor returns the cursor that resumes after the event with sequence seq
+func Cursor(seq uint64) string {
+	return encodeCursor(seq)
+}
+
+// List returns events after the query cursor in sequence order
+func (l *Log) List(q Query) (Page, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	page := Page{Watermark: l.nextSeq - 1}
+
+	// A cursor that points before the oldest retained event means events were
+	// evicted that the reader never saw
+	if q.Cursor != "" && len(l.events) > 0 && after+1 < l.events[0].Seq {
+		return Page{}, ErrCursorExpired
+	}
+
+	// Events are stored in Seq order, so the first unread index is a direct offset
+	start := 0
+	if len(l.events) > 0 && after >= l.events[0].Seq {
+		start = int(after - l.events[0].Seq + 1)
+	}
+
+	lastSeq := after
+	for i := start; i < len(l.events); i++ {
+		event := l.events[i]
+		if q.Cursor == "" && !q.Since.IsZero() && event.Time.Before(q.Since) {
+			lastSeq = event.Seq
+			continue
+		}
+		if (q.Type != "" && event.Type != q.Type) || (q.Match != nil && !q.Match(event)) {
+			lastSeq = event.Seq
+			continue
+		}
+		if len(page.Events) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Events = append(page.Events, event)
+		lastSeq = event.Seq
+	}
+
+	// Always return a cursor so readers can resume without rescanning filtered events
+	if lastSeq > 0 {
+		page.NextCursor = encodeCursor(lastSeq)
+	} else {
+		page.NextCursor = q.Cursor
+	}
+
+	return page, nil
+}
+
+// encodeCursor makes an opaque cursor from a sequence number
+func encodeCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("seq:" + strconv.FormatUint(seq, 10)))
+}
+
+// decodeCursor returns the sequence number encoded in a cursor
+func decodeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 5 || string(raw[:4]) != "seq:" {
+		return 0, ErrInvalidCursor
+	}
+
+	seq, err := strconv.ParseUint(string(raw[4:]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return seq, nil
+}
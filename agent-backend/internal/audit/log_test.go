@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListPaginatesInOrder(t *testing.T) {
+	log := NewLog(100)
+	for i := 0; i < 25; i++ {
+		log.Append("test", "", map[string]any{"i": i})
+	}
+
+	var seen []uint64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := log.List(Query{Cursor: cursor, Limit: 10})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		for _, event := range page.Events {
+			seen = append(seen, event.Seq)
+		}
+		cursor = page.NextCursor
+		if !page.HasMore {
+			if page.Watermark != 25 {
+				t.Errorf("Watermark = %d, want 25", page.Watermark)
+			}
+			break
+		}
+	}
+
+	if len(seen) != 25 {
+		t.Fatalf("saw %d events, want 25", len(seen))
+	}
+	for i, seq := range seen {
+		if seq != uint64(i+1) {
+			t.Fatalf("event %d has seq %d, want %d", i, seq, i+1)
+		}
+	}
+
+	// Events appended after catching up are returned from the saved cursor
+	log.Append("test", "", nil)
+	page, err := log.List(Query{Cursor: cursor})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Seq != 26 {
+		t.Errorf("List() after catch-up = %+v, want only seq 26", page.Events)
+	}
+}
+
+func TestListSinceAndType(t *testing.T) {
+	log := NewLog(100)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	log.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		eventType := "a"
+		if i%2 == 1 {
+			eventType = "b"
+		}
+		log.Append(eventType, "", nil)
+		now = now.Add(time.Minute)
+	}
+
+	page, err := log.List(Query{Since: start.Add(2 * time.Minute), Type: "a"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[0].Seq != 3 || page.Events[1].Seq != 5 {
+		t.Errorf("List() = %+v, want seqs 3 and 5", page.Events)
+	}
+}
+
+func TestListCursorErrors(t *testing.T) {
+	log := NewLog(10)
+	for i := 0; i < 5; i++ {
+		log.Append("test", "", nil)
+	}
+
+	page, err := log.List(Query{Limit: 2})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	stale := page.NextCursor
+
+	// Push enough events through to evict the ones after the stale cursor
+	for i := 0; i < 20; i++ {
+		log.Append("test", "", nil)
+	}
+
+	if _, err := log.List(Query{Cursor: stale}); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("List() with evicted cursor error = %v, want ErrCursorExpired", err)
+	}
+	if _, err := log.List(Query{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List() with garbage cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestAppendedWakesWaiters(t *testing.T) {
+	log := NewLog(10)
+	first := log.Appended()
+	if second := log.Appended(); second != first {
+		t.Fatal("Appended() returned a new channel before any append")
+	}
+
+	select {
+	case <-first:
+		t.Fatal("Appended() channel closed before an append")
+	default:
+	}
+
+	event := log.Append("test", "", nil)
+	select {
+	case <-first:
+	default:
+		t.Fatal("Appended() channel not closed by Append")
+	}
+
+	page, err := log.List(Query{Cursor: Cursor(event.Seq)})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page.Events) != 0 {
+		t.Errorf("List() after Cursor(last) returned %d events, want 0", len(page.Events))
+	}
+}
@@ -0,0 +1,163 @@
+package autoscale
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Autoscaling modes
+const (
+	ModeOff  = "off"  // Keep the configured pool size and forecast nothing
+	ModeHint = "hint" // Forecast and recommend a pool size without applying it
+	ModeAuto = "auto" // Resize the pool to the recommendation
+)
+
+var (
+	poolRecommendedSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_pool_recommended_size",
+		Help: "Container pool size recommended for the forecast load",
+	})
+	poolForecastPeak = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_pool_forecast_peak_arrivals",
+		Help: "Most computations expected in one hour within the forecast lead time",
+	})
+)
+
+// Pool is a container pool the controller can resize
+type Pool interface {
+	PoolSize() int
+	ResizePool(size int) error
+}
+
+// Config bounds the pool sizes the controller recommends
+type Config struct {
+	Mode     string
+	MinSize  int
+	MaxSize  int
+	Lead     time.Duration // How far ahead to provision for forecast load
+	Headroom float64       // Multiplier on the containers the forecast needs
+	Interval time.Duration // How often to forecast
+}
+
+// Status is the controller's latest forecast and recommendation
+type Status struct {
+	Mode        string         `json:"mode"`
+	Size        int            `json:"size"`        // Current pool size
+	Recommended int            `json:"recommended"` // Pool size for the peak within the lead time
+	MinSize     int            `json:"min_size"`
+	MaxSize     int            `json:"max_size"`
+	PeakAt      *time.Time     `json:"peak_at,omitempty"` // Start of the busiest forecast hour
+	PeakLoad    float64        `json:"peak_arrivals"`     // Arrivals forecast for that hour
+	HoldSeconds float64        `json:"hold_seconds"`      // Average time a computation holds a container
+	Forecast    []HourForecast `json:"forecast"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Controller periodically forecasts load and, in auto mode, resizes the pool
+type Controller struct {
+	history *History
+	pool    Pool
+	cfg     Config
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewController creates a controller for pool. The minimum size defaults
+// to 1 and the maximum to the pool's current size.
+func NewController(history *History, pool Pool, cfg Config, logger *slog.Logger) *Controller {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1
+	}
+	if cfg.MaxSize < cfg.MinSize {
+		cfg.MaxSize = max(pool.PoolSize(), cfg.MinSize)
+	}
+	if cfg.Headroom <= 0 {
+		cfg.Headroom = 1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	return &Controller{history: history, pool: pool, cfg: cfg, logger: logger}
+}
+
+// Run forecasts every interval until ctx is done, saving the history each
+// time
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		c.Evaluate(time.Now())
+		if err := c.history.Save(); err != nil {
+			c.logger.Error("failed to save load history", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate forecasts the load from now and updates the recommendation,
+// resizing the pool in auto mode
+func (c *Controller) Evaluate(now time.Time) Status {
+	forecast := c.history.Forecast(now, c.cfg.Lead)
+	hold := c.history.HoldTime()
+
+	status := Status{
+		Mode:        c.cfg.Mode,
+		Size:        c.pool.PoolSize(),
+		MinSize:     c.cfg.MinSize,
+		MaxSize:     c.cfg.MaxSize,
+		HoldSeconds: hold.Seconds(),
+		Forecast:    forecast,
+		UpdatedAt:   now.UTC(),
+	}
+	for _, hour := range forecast {
+		if hour.Arrivals > status.PeakLoad {
+			at := hour.Hour
+			status.PeakAt, status.PeakLoad = &at, hour.Arrivals
+		}
+	}
+	status.Recommended = c.recommend(status.PeakLoad, hold)
+	poolRecommendedSize.Set(float64(status.Recommended))
+	poolForecastPeak.Set(status.PeakLoad)
+
+	if c.cfg.Mode == ModeAuto && status.Recommended != status.Size {
+		if err := c.pool.ResizePool(status.Recommended); err != nil {
+			c.logger.Error("failed to resize container pool", "error", err, "size", status.Recommended)
+		} else {
+			c.logger.Info("resized container pool for forecast load",
+				"from", status.Size, "to", status.Recommended, "peak_arrivals", status.PeakLoad)
+			status.Size = c.pool.PoolSize()
+		}
+	}
+
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+	return status
+}
+
+// Status returns the latest forecast and recommendation
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// recommend returns the containers needed to serve arrivals per hour with
+// the given hold time, within the configured bounds
+func (c *Controller) recommend(arrivals float64, hold time.Duration) int {
+	busy := arrivals / 3600 * hold.Seconds()
+	size := int(math.Ceil(busy * c.cfg.Headroom))
+	return max(c.cfg.MinSize, min(size, c.cfg.MaxSize))
+}
@@ -0,0 +1,55 @@
+package autoscale
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type fakePool struct {
+	size int
+}
+
+func (p *fakePool) PoolSize() int { return p.size }
+
+func (p *fakePool) ResizePool(size int) error {
+	p.size = size
+	return nil
+}
+
+func TestControllerProvisionsAheadOfPeaks(t *testing.T) {
+	h, err := LoadHistory("")
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	// 120 computations an hour, each holding a container for 3 minutes,
+	// keep 6 containers busy
+	peak := monday.Add(time.Hour)
+	for i := 0; i < 120; i++ {
+		h.RecordArrival(peak.Add(time.Duration(i) * 30 * time.Second))
+	}
+	h.RecordHold(3 * time.Minute)
+
+	pool := &fakePool{size: 2}
+	hint := NewController(h, pool, Config{Mode: ModeHint, MinSize: 1, MaxSize: 8, Lead: time.Hour, Headroom: 1.25}, slog.Default())
+	status := hint.Evaluate(monday.Add(7 * 24 * time.Hour))
+	if status.Recommended != 8 || status.PeakLoad != 120 {
+		t.Errorf("Evaluate() = recommended %d for peak %v, want 8 for 120", status.Recommended, status.PeakLoad)
+	}
+	if pool.size != 2 {
+		t.Errorf("hint mode resized the pool to %d", pool.size)
+	}
+	if got := hint.Status(); got.Recommended != 8 || got.PeakAt == nil {
+		t.Errorf("Status() = %+v", got)
+	}
+
+	auto := NewController(h, pool, Config{Mode: ModeAuto, MinSize: 1, MaxSize: 6, Lead: time.Hour}, slog.Default())
+	if status := auto.Evaluate(monday.Add(7 * 24 * time.Hour)); status.Size != 6 || pool.size != 6 {
+		t.Errorf("auto mode size = %d, pool = %d; want 6", status.Size, pool.size)
+	}
+
+	// Long after the peak, with no load forecast, the pool shrinks to its minimum
+	if status := auto.Evaluate(monday.Add(7*24*time.Hour + 6*time.Hour)); pool.size != 1 {
+		t.Errorf("auto mode size after the peak = %d, want 1 (%+v)", pool.size, status)
+	}
+}
@@ -0,0 +1,186 @@
+// Package autoscale forecasts computation load from its history and sizes
+// the container pool ahead of recurring peaks.
+//
+// Load is learned per hour of the week, so a peak every weekday morning or
+// every Sunday night is anticipated once it has been seen. Each slot keeps
+// an exponentially weighted average of the computations that arrived in
+// that hour, and the history keeps an average of how long a computation
+// holds a container. By Little's law, the containers a forecast hour needs
+// are its arrival rate times the hold time.
+package autoscale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// weekHours is the number of hour-of-week slots
+const weekHours = 7 * 24
+
+// historyWeight is how much the latest week counts in a slot's average,
+// and the latest hold time in the hold time average
+const historyWeight = 0.3
+
+// slot is the load history of one hour of the week
+type slot struct {
+	Arrivals float64 `json:"arrivals"` // Weighted average arrivals in the hour
+	Weeks    int     `json:"weeks"`    // Weeks the hour has been observed
+}
+
+// History records computation arrivals and container hold times
+type History struct {
+	mu    sync.Mutex
+	path  string
+	state historyState
+}
+
+// historyState is the persisted form of a History
+type historyState struct {
+	Slots       [weekHours]slot `json:"slots"`
+	HoldSeconds float64         `json:"hold_seconds"` // Weighted average container hold time
+	Hour        int64           `json:"hour"`         // Hours since the Unix epoch of the hour being counted
+	Count       int             `json:"count"`        // Arrivals so far in that hour
+}
+
+// HourForecast is the load expected in one hour
+type HourForecast struct {
+	Hour     time.Time `json:"hour"`
+	Arrivals float64   `json:"arrivals"`
+}
+
+// LoadHistory creates a history persisted to path, restoring the history
+// saved there. An empty path keeps the history in memory only.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read load history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.state); err != nil {
+		return nil, fmt.Errorf("failed to parse load history: %w", err)
+	}
+	return h, nil
+}
+
+// RecordArrival counts a computation that arrived at t
+func (h *History) RecordArrival(t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.roll(t)
+	h.state.Count++
+}
+
+// RecordHold records how long a computation held a container
+func (h *History) RecordHold(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.state.HoldSeconds == 0 {
+		h.state.HoldSeconds = d.Seconds()
+		return
+	}
+	h.state.HoldSeconds += historyWeight * (d.Seconds() - h.state.HoldSeconds)
+}
+
+// HoldTime returns the average time a computation holds a container
+func (h *History) HoldTime() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.state.HoldSeconds * float64(time.Second))
+}
+
+// Forecast returns the arrivals expected in each hour from now's hour
+// through now+lead. Hours never observed forecast no arrivals. The current
+// hour forecasts at least the arrivals already seen in it.
+func (h *History) Forecast(now time.Time, lead time.Duration) []HourForecast {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.roll(now)
+	start := now.UTC().Truncate(time.Hour)
+	hours := int(lead.Hours()) + 1
+	forecast := make([]HourForecast, 0, hours)
+	for i := 0; i < hours && i < weekHours; i++ {
+		hour := start.Add(time.Duration(i) * time.Hour)
+		arrivals := h.state.Slots[slotOf(hour)].Arrivals
+		if i == 0 {
+			arrivals = max(arrivals, float64(h.state.Count))
+		}
+		forecast = append(forecast, HourForecast{Hour: hour, Arrivals: arrivals})
+	}
+	return forecast
+}
+
+// Save writes the history to disk
+func (h *History) Save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(h.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode load history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create load history directory: %w", err)
+	}
+	if err := fsutil.WriteFile(h.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write load history: %w", err)
+	}
+	return nil
+}
+
+// roll folds the hours that ended before t into their slots. Hours without
+// arrivals count as zero. Caller must hold mu.
+func (h *History) roll(t time.Time) {
+	hour := t.Unix() / 3600
+	if h.state.Hour == 0 {
+		h.state.Hour = hour
+		return
+	}
+	if hour <= h.state.Hour {
+		return
+	}
+	h.fold(h.state.Hour, float64(h.state.Count))
+	// The hours since had no arrivals. A gap of more than a week updates
+	// every slot once.
+	for ended := max(h.state.Hour+1, hour-weekHours); ended < hour; ended++ {
+		h.fold(ended, 0)
+	}
+	h.state.Hour = hour
+	h.state.Count = 0
+}
+
+// fold adds the arrivals of an ended hour to its slot. Caller must hold mu.
+func (h *History) fold(hour int64, count float64) {
+	s := &h.state.Slots[slotOf(time.Unix(hour*3600, 0))]
+	if s.Weeks == 0 {
+		s.Arrivals = count
+	} else {
+		s.Arrivals += historyWeight * (count - s.Arrivals)
+	}
+	s.Weeks++
+}
+
+// slotOf returns the hour-of-week slot of t, counted from Sunday 00:00 UTC
+func slotOf(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
@@ -0,0 +1,70 @@
+package autoscale
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// monday is a Monday 09:00 UTC
+var monday = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+func TestHistoryLearnsWeeklyPeaks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+
+	// Two weeks with a burst every Monday at 10:00
+	for week := 0; week < 2; week++ {
+		peak := monday.Add(time.Duration(week)*7*24*time.Hour + time.Hour)
+		for i := 0; i < 30; i++ {
+			h.RecordArrival(peak.Add(time.Duration(i) * time.Minute))
+		}
+		h.RecordArrival(peak.Add(3 * time.Hour))
+	}
+	h.RecordHold(2 * time.Minute)
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	restored, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if got := restored.HoldTime(); got != 2*time.Minute {
+		t.Errorf("HoldTime() = %v, want 2m", got)
+	}
+
+	// At 09:00 the following Monday, the 10:00 peak is within an hour's lead
+	forecast := restored.Forecast(monday.Add(14*24*time.Hour), time.Hour)
+	if len(forecast) != 2 {
+		t.Fatalf("Forecast() returned %d hours, want 2", len(forecast))
+	}
+	if forecast[0].Arrivals != 0 || forecast[1].Arrivals != 30 {
+		t.Errorf("Forecast() = %+v, want 0 then 30 arrivals", forecast)
+	}
+	if !forecast[1].Hour.Equal(monday.Add(14*24*time.Hour + time.Hour)) {
+		t.Errorf("peak hour = %v", forecast[1].Hour)
+	}
+}
+
+func TestHistoryDecaysQuietWeeks(t *testing.T) {
+	h, err := LoadHistory("")
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		h.RecordArrival(monday)
+	}
+	// A week later the hour passes without arrivals
+	forecast := h.Forecast(monday.Add(7*24*time.Hour+2*time.Hour), 7*24*time.Hour)
+	var peak float64
+	for _, hour := range forecast {
+		peak = max(peak, hour.Arrivals)
+	}
+	if want := 10 * (1 - historyWeight); peak < want-1e-9 || peak > want+1e-9 {
+		t.Errorf("peak after a quiet week = %v, want %v", peak, want)
+	}
+}
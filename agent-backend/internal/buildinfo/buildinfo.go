@@ -0,0 +1,75 @@
+// Package buildinfo reports which agent build is running. Release builds
+// set the version, commit and date with
+//
+//	-ldflags "-X pandacea/agent-backend/internal/buildinfo.Version=v1.2.0
+//	          -X pandacea/agent-backend/internal/buildinfo.Commit=<sha>
+//	          -X pandacea/agent-backend/internal/buildinfo.Date=<RFC 3339>"
+//
+// Builds without them fall back to the VCS stamp the Go toolchain embeds.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time with -ldflags -X
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// product names Pandacea agents in their libp2p identify user agent
+const product = "pandacea-agent"
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+}
+
+// Get returns the running build's information
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// UserAgent is the user agent the agent announces to peers over identify,
+// pandacea-agent/<version>
+func UserAgent() string {
+	return product + "/" + Version
+}
+
+// ParseUserAgent returns the version in a peer's identify user agent, and
+// false if the peer is not a Pandacea agent. Agents from before versions
+// were announced report just pandacea-agent and an empty version.
+func ParseUserAgent(userAgent string) (string, bool) {
+	if userAgent == product {
+		return "", true
+	}
+	if version, ok := strings.CutPrefix(userAgent, product+"/"); ok {
+		return version, true
+	}
+	return "", false
+}
@@ -0,0 +1,30 @@
+package buildinfo
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		version   string
+		ok        bool
+	}{
+		{UserAgent(), Version, true},
+		{"pandacea-agent/v1.4.0", "v1.4.0", true},
+		{"pandacea-agent", "", true},
+		{"kubo/0.29.0", "", false},
+		{"pandacea-agentx/v1", "", false},
+	}
+	for _, tt := range tests {
+		version, ok := ParseUserAgent(tt.userAgent)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("ParseUserAgent(%q) = %q, %v, want %q, %v", tt.userAgent, version, ok, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != Version || info.GoVersion == "" {
+		t.Errorf("Get() = %+v", info)
+	}
+}
@@ -0,0 +1,265 @@
+package chain
+
+import (
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Dispatcher defaults used when the config leaves them unset
+const (
+	DefaultEventWorkers   = 4
+	DefaultEventQueueSize = 256
+	DefaultEventMaxParked = 1024
+)
+
+// ErrEventDropped is returned when a worker's queue and parking area are full
+var ErrEventDropped = errors.New("event dropped: worker queue and parking area are full")
+
+var (
+	dispatchQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_event_dispatch_queue_depth",
+		Help: "Blockchain events accepted by the dispatcher and not yet handled",
+	})
+	dispatchParked = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_event_dispatch_parked",
+		Help: "Blockchain events parked because their worker queue was full",
+	})
+	dispatchParkedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_event_dispatch_parked_total",
+		Help: "Total blockchain events parked because their worker queue was full",
+	})
+	dispatchDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_event_dispatch_dropped_total",
+		Help: "Total blockchain events dropped because their worker was saturated",
+	})
+	dispatchHandleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pandacea_event_handle_duration_seconds",
+		Help:    "Time spent handling a single blockchain event",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// dispatchItem is one event waiting for a worker
+type dispatchItem struct {
+	block uint64
+	fn    func()
+}
+
+// dispatchWorker handles the events for one partition of keys in order
+type dispatchWorker struct {
+	queue  chan dispatchItem
+	mu     sync.Mutex
+	parked []dispatchItem
+}
+
+// Dispatcher runs event handlers on a bounded pool of workers. Events with
+// the same key always go to the same worker, so events for one lease are
+// handled in the order they were submitted. When a worker's queue is full,
+// events are parked behind it up to a limit and dropped beyond that.
+type Dispatcher struct {
+	workers   []*dispatchWorker
+	maxParked int
+	progress  func(block uint64)
+	logger    *slog.Logger
+	inflight  sync.WaitGroup
+	running   sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[uint64]int
+	highest uint64
+	limit   uint64
+	dropped bool
+}
+
+// NewDispatcher starts workers goroutines, each with a queue of queueSize
+// events and room to park maxParked more. progress, if set, is called with
+// the highest block whose events have all been handled.
+func NewDispatcher(workers, queueSize, maxParked int, progress func(block uint64), logger *slog.Logger) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultEventWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultEventQueueSize
+	}
+	if maxParked < 0 {
+		maxParked = 0
+	}
+
+	d := &Dispatcher{
+		workers:   make([]*dispatchWorker, workers),
+		maxParked: maxParked,
+		progress:  progress,
+		logger:    logger,
+		pending:   make(map[uint64]int),
+	}
+	for i := range d.workers {
+		w := &dispatchWorker{queue: make(chan dispatchItem, queueSize)}
+		d.workers[i] = w
+		d.running.Add(1)
+		go d.run(w)
+	}
+	return d
+}
+
+// Submit queues fn to be handled after every earlier event with the same key.
+// It never blocks; if the key's worker is saturated the event is dropped and
+// ErrEventDropped is returned. Progress is not reported past a dropped
+// event's block, so replaying from the last reported block recovers it.
+func (d *Dispatcher) Submit(key string, block uint64, fn func()) error {
+	item := dispatchItem{block: block, fn: fn}
+	w := d.workers[partition(key, len(d.workers))]
+
+	d.track(block)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Parked events are older than anything new, so only enqueue directly
+	// when nothing is parked
+	if len(w.parked) == 0 {
+		select {
+		case w.queue <- item:
+			return nil
+		default:
+		}
+	}
+	if len(w.parked) < d.maxParked {
+		w.parked = append(w.parked, item)
+		dispatchParked.Inc()
+		dispatchParkedTotal.Inc()
+		return nil
+	}
+
+	d.drop(block)
+	dispatchDroppedTotal.Inc()
+	return ErrEventDropped
+}
+
+// Close waits for every accepted event to be handled and stops the workers.
+// No events may be submitted after Close.
+func (d *Dispatcher) Close() {
+	d.inflight.Wait()
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.running.Wait()
+}
+
+// Idle reports whether every accepted event has been handled
+func (d *Dispatcher) Idle() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending) == 0
+}
+
+// run handles a worker's events, refilling its queue from the parking area
+func (d *Dispatcher) run(w *dispatchWorker) {
+	defer d.running.Done()
+	for item := range w.queue {
+		d.handle(item)
+
+		w.mu.Lock()
+		for len(w.parked) > 0 {
+			select {
+			case w.queue <- w.parked[0]:
+				w.parked = w.parked[1:]
+				dispatchParked.Dec()
+				continue
+			default:
+			}
+			break
+		}
+		w.mu.Unlock()
+	}
+}
+
+// handle runs one event handler, recovering from panics so a bad event
+// cannot stop its worker
+func (d *Dispatcher) handle(item dispatchItem) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			d.logger.Error("event handler panicked", "block_number", item.block, "panic", r)
+		}
+		dispatchHandleDuration.Observe(time.Since(start).Seconds())
+		d.done(item.block)
+	}()
+	item.fn()
+}
+
+// track records an accepted event as pending
+func (d *Dispatcher) track(block uint64) {
+	d.inflight.Add(1)
+	dispatchQueueDepth.Inc()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[block]++
+	d.highest = max(d.highest, block)
+}
+
+// drop undoes track for an event that was not accepted and stops progress
+// from passing its block
+func (d *Dispatcher) drop(block uint64) {
+	d.mu.Lock()
+	d.release(block)
+	if !d.dropped || block < d.limit {
+		d.limit = block
+		d.dropped = true
+	}
+	d.mu.Unlock()
+
+	dispatchQueueDepth.Dec()
+	d.inflight.Done()
+}
+
+// done marks a handled event and reports progress
+func (d *Dispatcher) done(block uint64) {
+	d.mu.Lock()
+	d.release(block)
+	processed, ok := d.processed()
+	d.mu.Unlock()
+
+	dispatchQueueDepth.Dec()
+	if ok && d.progress != nil {
+		d.progress(processed)
+	}
+	d.inflight.Done()
+}
+
+// release removes one pending event for block. Caller must hold d.mu.
+func (d *Dispatcher) release(block uint64) {
+	if d.pending[block]--; d.pending[block] <= 0 {
+		delete(d.pending, block)
+	}
+}
+
+// processed returns the highest block whose events have all been handled.
+// The highest submitted block may still receive events, so it only counts
+// once a later block is seen. Caller must hold d.mu.
+func (d *Dispatcher) processed() (uint64, bool) {
+	next := d.highest
+	for block := range d.pending {
+		next = min(next, block)
+	}
+	if d.dropped {
+		next = min(next, d.limit)
+	}
+	if next == 0 {
+		return 0, false
+	}
+	return next - 1, true
+}
+
+// partition maps a key to a worker index
+func partition(key string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,150 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func newTestDispatcher(workers, queueSize, maxParked int, progress func(uint64)) *Dispatcher {
+	return NewDispatcher(workers, queueSize, maxParked, progress, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestDispatcherOrdersEventsPerKey(t *testing.T) {
+	d := newTestDispatcher(4, 2, 1000, nil)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("lease-%d", i%5)
+		i := i
+		if err := d.Submit(key, uint64(i+1), func() {
+			mu.Lock()
+			seen[key] = append(seen[key], i)
+			mu.Unlock()
+		}); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	d.Close()
+
+	for key, order := range seen {
+		if len(order) != 40 {
+			t.Errorf("%s handled %d events, want 40", key, len(order))
+		}
+		for j := 1; j < len(order); j++ {
+			if order[j] < order[j-1] {
+				t.Fatalf("%s handled out of order: %v", key, order)
+			}
+		}
+	}
+}
+
+func TestDispatcherParksThenDrops(t *testing.T) {
+	d := newTestDispatcher(1, 1, 1, nil)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocked := func() {
+		close(started)
+		<-release
+	}
+	if err := d.Submit("a", 1, blocked); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+
+	var handled []int
+	record := func(n int) func() { return func() { handled = append(handled, n) } }
+
+	// One event fills the queue and one is parked; the next has nowhere to go
+	if err := d.Submit("a", 2, record(2)); err != nil {
+		t.Fatalf("Submit() queued error = %v", err)
+	}
+	if err := d.Submit("a", 3, record(3)); err != nil {
+		t.Fatalf("Submit() parked error = %v", err)
+	}
+	if err := d.Submit("a", 4, record(4)); !errors.Is(err, ErrEventDropped) {
+		t.Fatalf("Submit() on saturated worker error = %v, want ErrEventDropped", err)
+	}
+
+	close(release)
+	d.Close()
+	if fmt.Sprint(handled) != "[2 3]" {
+		t.Errorf("handled = %v, want [2 3]", handled)
+	}
+}
+
+func TestDispatcherProgress(t *testing.T) {
+	var mu sync.Mutex
+	var reported []uint64
+	d := newTestDispatcher(2, 4, 4, func(block uint64) {
+		mu.Lock()
+		reported = append(reported, block)
+		mu.Unlock()
+	})
+
+	release := make(chan struct{})
+	d.Submit("slow", 10, func() { <-release })
+	d.Submit("fast", 11, func() {})
+	d.Submit("fast", 12, func() {})
+
+	// Block 10 is still in flight, so nothing at or past it is complete
+	mu.Lock()
+	for _, block := range reported {
+		if block >= 10 {
+			t.Errorf("progress %d reported before block 10 was handled", block)
+		}
+	}
+	mu.Unlock()
+	if d.Idle() {
+		t.Error("Idle() = true with an event in flight")
+	}
+
+	close(release)
+	d.Close()
+
+	// The newest block may still receive events, so progress stops short of it
+	mu.Lock()
+	defer mu.Unlock()
+	if last := reported[len(reported)-1]; last != 11 {
+		t.Errorf("final progress = %d, want 11", last)
+	}
+	if !d.Idle() {
+		t.Error("Idle() = false after Close")
+	}
+}
+
+func TestDispatcherProgressStopsAtDrop(t *testing.T) {
+	var mu sync.Mutex
+	var last uint64
+	d := newTestDispatcher(1, 1, 0, func(block uint64) {
+		mu.Lock()
+		last = max(last, block)
+		mu.Unlock()
+	})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	d.Submit("a", 5, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	d.Submit("a", 6, func() {})
+	if err := d.Submit("a", 7, func() {}); !errors.Is(err, ErrEventDropped) {
+		t.Fatalf("Submit() error = %v, want ErrEventDropped", err)
+	}
+
+	close(release)
+	d.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if last != 6 {
+		t.Errorf("progress = %d, want 6 so block 7 is replayed", last)
+	}
+}
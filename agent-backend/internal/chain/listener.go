@@ -0,0 +1,665 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/reputation"
+	"pandacea/agent-backend/internal/tenant"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxListenerBackoff caps the delay between event listener reconnects
+const maxListenerBackoff = time.Minute
+
+// ErrChainIDMismatch is returned when an RPC endpoint serves a different
+// chain than its network is configured for
+var ErrChainIDMismatch = errors.New("chain ID mismatch")
+
+// CheckChainID verifies that client serves chain want. A want of 0 skips
+// the check.
+func CheckChainID(ctx context.Context, client ethereum.ChainIDReader, want uint64) error {
+	if want == 0 {
+		return nil
+	}
+	got, err := client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chain ID: %w", err)
+	}
+	if !got.IsUint64() || got.Uint64() != want {
+		return fmt.Errorf("%w: endpoint serves chain %s, want %d", ErrChainIDMismatch, got, want)
+	}
+	return nil
+}
+
+// Client is the part of an Ethereum client the event listener uses. Clients
+// with a Close method are closed when their connection is dropped.
+type Client interface {
+	ethereum.LogFilterer
+	ethereum.BlockNumberReader
+}
+
+// HeaderReader reads block headers, for the time of events whose logs carry
+// no block timestamp
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// DialFunc connects to the chain. The listener dials again after each
+// disconnect.
+type DialFunc func(ctx context.Context) (Client, error)
+
+// LeaseEventSink receives the lease events the listener handles
+type LeaseEventSink interface {
+	RecordChainEvent(name string, blockNumber uint64, txHash string, logIndex uint, fields map[string]any)
+	UpdateLeaseStatus(leaseProposalID string, status string, leaseID *uint64, spenderAddr, earnerAddr string, price *string)
+}
+
+// LeaseProductReader looks up the data product a lease was created for
+type LeaseProductReader interface {
+	LeaseProduct(ctx context.Context, leaseID [32]byte) (string, error)
+}
+
+// DecodeProductID returns an on-chain data product ID as a string: the text
+// of IDs encoded as right-padded ASCII, or 0x-prefixed hex otherwise
+func DecodeProductID(id [32]byte) string {
+	text := bytes.TrimRight(id[:], "\x00")
+	if len(text) == 0 {
+		return fmt.Sprintf("0x%x", id)
+	}
+	for _, c := range text {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Sprintf("0x%x", id)
+		}
+	}
+	return string(text)
+}
+
+// EncodeProductID returns the on-chain form of a data product ID, the
+// inverse of DecodeProductID: 0x-prefixed hex of 32 bytes as is, and other
+// IDs as right-padded ASCII, which must fit in 32 bytes
+func EncodeProductID(productID string) ([32]byte, error) {
+	var id [32]byte
+	if strings.HasPrefix(productID, "0x") && len(productID) == 66 {
+		if raw, err := hex.DecodeString(productID[2:]); err == nil {
+			copy(id[:], raw)
+			return id, nil
+		}
+	}
+	if productID == "" || len(productID) > len(id) {
+		return id, fmt.Errorf("product ID %q does not fit in 32 bytes", productID)
+	}
+	for _, c := range []byte(productID) {
+		if c < 0x20 || c > 0x7e {
+			return id, fmt.Errorf("product ID %q is not printable ASCII", productID)
+		}
+	}
+	copy(id[:], productID)
+	return id, nil
+}
+
+// Listener follows LeaseAgreement events on one network and passes them to
+// a sink and the reputation tracker
+type Listener struct {
+	cfg     config.BlockchainConfig
+	dial    DialFunc
+	handler *leaseEventHandler
+	status  *ListenerStatus
+	logger  *slog.Logger
+}
+
+// NewListener creates a listener for the contract in cfg, which must be on
+// the chain cfg.ChainID if set. Events are labelled with the status's
+// network. The tracker may be nil.
+func NewListener(cfg config.BlockchainConfig, dial DialFunc, sink LeaseEventSink, tracker *reputation.Tracker, status *ListenerStatus, logger *slog.Logger) (*Listener, error) {
+	logger = logger.With("network", status.Network())
+	handler, err := newLeaseEventHandler(common.HexToAddress(cfg.ContractAddress), sink, tracker, logger)
+	if err != nil {
+		return nil, err
+	}
+	handler.network = status.Network()
+	return &Listener{cfg: cfg, dial: dial, handler: handler, status: status, logger: logger}, nil
+}
+
+// SetEarnings books executed leases' payouts in ledger, looking up their
+// products with products. Call it before Run.
+func (l *Listener) SetEarnings(ledger *earnings.Ledger, products LeaseProductReader) {
+	l.handler.earnings = ledger
+	l.handler.products = products
+}
+
+// SetDisputes tracks disputes through their DisputeRaised and
+// DisputeResolved events in store. Call it before Run.
+func (l *Listener) SetDisputes(store *dispute.Store) {
+	l.handler.disputes = store
+}
+
+// SetTenants routes LeaseCreated events by earner, so that an agent hosting
+// several earners only books leases paying one of them. Call it before Run.
+func (l *Listener) SetTenants(registry *tenant.Registry) {
+	l.handler.tenants = registry
+}
+
+// SetEarner sets the address the agent's own leases pay. The contract is
+// shared, so without it or tenants the agent cannot tell its leases from
+// other earners' and books none. Call it before Run.
+func (l *Listener) SetEarner(address common.Address) {
+	l.handler.earner = address
+}
+
+// Run listens for blockchain events until ctx is cancelled, reconnecting
+// with backoff and replaying missed blocks after each disconnect
+func (l *Listener) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := l.run(ctx)
+		if ctx.Err() != nil {
+			l.logger.Info("shutting down event listener")
+			return
+		}
+
+		// A listener that got as far as subscribing starts over with a short delay
+		if l.status.Snapshot().Connected {
+			backoff = time.Second
+		}
+		l.status.SetConnected(false, err)
+		l.logger.Error("event listener disconnected, reconnecting", "error", err, "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			l.logger.Info("shutting down event listener")
+			return
+		}
+		backoff = min(backoff*2, maxListenerBackoff)
+	}
+}
+
+// run connects, catches up on missed blocks and processes live events
+// until the subscription fails or ctx is cancelled
+func (l *Listener) run(ctx context.Context) error {
+	client, err := l.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to blockchain: %w", err)
+	}
+	if closer, ok := client.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+	if l.cfg.ChainID != 0 {
+		reader, ok := client.(ethereum.ChainIDReader)
+		if !ok {
+			return fmt.Errorf("cannot verify chain ID %d: client does not report one", l.cfg.ChainID)
+		}
+		if err := CheckChainID(ctx, reader, l.cfg.ChainID); err != nil {
+			return err
+		}
+	}
+
+	l.logger.Info("blockchain connection established", "contract_address", l.cfg.ContractAddress)
+	if headers, ok := client.(HeaderReader); ok {
+		l.handler.setHeaders(headers)
+	}
+
+	// Subscribe before catching up so no event falls between the two
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{common.HexToAddress(l.cfg.ContractAddress)},
+		Topics:    [][]common.Hash{l.handler.topics()},
+	}
+	logs := make(chan types.Log, 64)
+	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to lease events: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chain head: %w", err)
+	}
+	l.status.ObserveHead(head)
+	l.status.SetConnected(true, nil)
+	l.logger.Info("subscribed to lease events", "head", head)
+
+	// Replay blocks missed while disconnected, or from the configured start
+	// block on first connect. Without either, start at the current head.
+	from := head + 1
+	if last, ok := l.status.LastBlock(); ok {
+		from = last + 1
+	} else if l.cfg.StartBlock > 0 {
+		from = l.cfg.StartBlock
+	} else {
+		l.status.ObserveBlock(head)
+	}
+	replayed := from <= head
+	if replayed {
+		if err := l.catchUp(ctx, client, query, from, head); err != nil {
+			return err
+		}
+	}
+
+	pollInterval := time.Duration(l.cfg.HeadPollSeconds) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = 15 * time.Second
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// Live events are handled off the subscription loop so a slow handler
+	// cannot stall it. Progress only advances once a block's events are done.
+	dispatcher := NewDispatcher(
+		l.cfg.EventWorkers,
+		l.cfg.EventQueueSize,
+		l.cfg.EventMaxParked,
+		l.status.ObserveBlock,
+		l.logger,
+	)
+	defer dispatcher.Close()
+
+	// Process events
+	for {
+		select {
+		case err := <-sub.Err():
+			if err == nil {
+				err = fmt.Errorf("subscription closed")
+			}
+			return err
+		case log := <-logs:
+			// Events up to head were already handled while catching up
+			if replayed && log.BlockNumber <= head {
+				continue
+			}
+			if err := dispatcher.Submit(l.handler.key(log), log.BlockNumber, func() { l.handler.handle(log) }); err != nil {
+				// Reconnecting replays from the last fully handled block
+				return fmt.Errorf("failed to dispatch event in block %d: %w", log.BlockNumber, err)
+			}
+		case <-ticker.C:
+			latest, err := client.BlockNumber(ctx)
+			if err != nil {
+				l.logger.Warn("failed to poll chain head", "error", err)
+				continue
+			}
+			l.status.ObserveHead(latest)
+			// With no events queued or in flight the listener is up to date with the head
+			if len(logs) == 0 && dispatcher.Idle() {
+				l.status.ObserveBlock(latest)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// catchUp processes lease events in [from, to] in batches, recording
+// progress after each batch
+func (l *Listener) catchUp(ctx context.Context, client Client, query ethereum.FilterQuery, from, to uint64) error {
+	batchSize := l.cfg.CatchUpBatchSize
+	if batchSize == 0 {
+		batchSize = 2000
+	}
+
+	l.status.SetCatchingUp(true)
+	defer l.status.SetCatchingUp(false)
+	l.logger.Info("catching up on missed blocks", "from", from, "to", to)
+
+	for start := from; start <= to; start += batchSize {
+		end := min(start+batchSize-1, to)
+		query.FromBlock = new(big.Int).SetUint64(start)
+		query.ToBlock = new(big.Int).SetUint64(end)
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to query lease events in blocks %d-%d: %w", start, end, err)
+		}
+		for _, log := range logs {
+			l.handler.handle(log)
+		}
+		l.status.ObserveBlock(end)
+	}
+
+	l.logger.Info("caught up with chain head", "head", to)
+	return nil
+}
+
+// leaseEventHandler dispatches LeaseAgreement logs to the sink and the
+// reputation tracker
+type leaseEventHandler struct {
+	contract   *contracts.LeaseAgreementFilterer
+	sink       LeaseEventSink
+	tracker    *reputation.Tracker
+	earnings   *earnings.Ledger
+	products   LeaseProductReader
+	disputes   *dispute.Store
+	tenants    *tenant.Registry
+	earner     common.Address
+	network    string
+	logger     *slog.Logger
+	createdID  common.Hash
+	approvedID common.Hash
+	executedID common.Hash
+	raisedID   common.Hash
+	resolvedID common.Hash
+
+	headersMu sync.Mutex
+	headers   HeaderReader
+}
+
+// newLeaseEventHandler resolves the event topics from the contract ABI
+func newLeaseEventHandler(address common.Address, sink LeaseEventSink, tracker *reputation.Tracker, logger *slog.Logger) (*leaseEventHandler, error) {
+	parsed, err := contracts.LeaseAgreementMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lease agreement ABI: %w", err)
+	}
+	// Parsing logs needs no backend
+	contract, err := contracts.NewLeaseAgreementFilterer(address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind lease agreement contract: %w", err)
+	}
+
+	return &leaseEventHandler{
+		contract:   contract,
+		sink:       sink,
+		tracker:    tracker,
+		logger:     logger,
+		createdID:  parsed.Events["LeaseCreated"].ID,
+		approvedID: parsed.Events["LeaseApproved"].ID,
+		executedID: parsed.Events["LeaseExecuted"].ID,
+		raisedID:   parsed.Events["DisputeRaised"].ID,
+		resolvedID: parsed.Events["DisputeResolved"].ID,
+	}, nil
+}
+
+// topics returns the event signatures the listener subscribes to
+func (h *leaseEventHandler) topics() []common.Hash {
+	return []common.Hash{h.createdID, h.approvedID, h.executedID, h.raisedID, h.resolvedID}
+}
+
+// key returns the lease ID a log belongs to, so events for one lease are
+// handled in order
+func (h *leaseEventHandler) key(log types.Log) string {
+	// Every lease event indexes the lease ID as its first topic
+	if len(log.Topics) > 1 {
+		return log.Topics[1].Hex()
+	}
+	return log.TxHash.Hex()
+}
+
+// handle decodes a log and processes it by event type
+func (h *leaseEventHandler) handle(log types.Log) {
+	if len(log.Topics) == 0 {
+		return
+	}
+
+	switch log.Topics[0] {
+	case h.createdID:
+		event, err := h.contract.ParseLeaseCreated(log)
+		if err != nil {
+			h.logger.Error("failed to parse LeaseCreated event", "error", err, "tx_hash", log.TxHash.Hex())
+			return
+		}
+		h.handleCreated(event)
+	case h.approvedID:
+		event, err := h.contract.ParseLeaseApproved(log)
+		if err != nil {
+			h.logger.Error("failed to parse LeaseApproved event", "error", err, "tx_hash", log.TxHash.Hex())
+			return
+		}
+		h.handleOutcome("LeaseApproved", event.LeaseId, reputation.OutcomeApproved, log)
+	case h.executedID:
+		event, err := h.contract.ParseLeaseExecuted(log)
+		if err != nil {
+			h.logger.Error("failed to parse LeaseExecuted event", "error", err, "tx_hash", log.TxHash.Hex())
+			return
+		}
+		h.handleOutcome("LeaseExecuted", event.LeaseId, reputation.OutcomeExecuted, log)
+		h.bookPayout(event.LeaseId, log)
+	case h.raisedID:
+		event, err := h.contract.ParseDisputeRaised(log)
+		if err != nil {
+			h.logger.Error("failed to parse DisputeRaised event", "error", err, "tx_hash", log.TxHash.Hex())
+			return
+		}
+		h.handleDisputeRaised(event)
+	case h.resolvedID:
+		event, err := h.contract.ParseDisputeResolved(log)
+		if err != nil {
+			h.logger.Error("failed to parse DisputeResolved event", "error", err, "tx_hash", log.TxHash.Hex())
+			return
+		}
+		h.handleDisputeResolved(event)
+	}
+}
+
+// disputeEvent locates a dispute event for the dispute store
+func (h *leaseEventHandler) disputeEvent(leaseID [32]byte, log types.Log) dispute.ChainEvent {
+	return dispute.ChainEvent{
+		LeaseID:     fmt.Sprintf("0x%x", leaseID),
+		Network:     h.network,
+		TxHash:      log.TxHash.Hex(),
+		BlockNumber: log.BlockNumber,
+	}
+}
+
+// handleDisputeRaised records a DisputeRaised event and moves the
+// dispute's record to raised
+func (h *leaseEventHandler) handleDisputeRaised(event *contracts.LeaseAgreementDisputeRaised) {
+	located := h.disputeEvent(event.LeaseId, event.Raw)
+	h.logger.Info("received DisputeRaised event", "lease_id", located.LeaseID, "spender", event.Spender.Hex(), "stake", event.StakeAmount.String())
+
+	h.sink.RecordChainEvent("DisputeRaised", event.Raw.BlockNumber, event.Raw.TxHash.Hex(), event.Raw.Index, map[string]any{
+		"lease_id":     located.LeaseID,
+		"spender":      event.Spender.Hex(),
+		"earner":       event.Earner.Hex(),
+		"reason":       event.Reason,
+		"stake_amount": event.StakeAmount.String(),
+		"network":      h.network,
+	})
+
+	if h.disputes == nil {
+		return
+	}
+	if _, err := h.disputes.Raised(located, event.Spender.Hex(), event.Earner.Hex(), event.Reason, event.StakeAmount); err != nil {
+		h.logger.Error("failed to record raised dispute", "error", err, "lease_id", located.LeaseID)
+	}
+}
+
+// handleDisputeResolved records a DisputeResolved event and moves the
+// dispute's record to upheld or rejected
+func (h *leaseEventHandler) handleDisputeResolved(event *contracts.LeaseAgreementDisputeResolved) {
+	located := h.disputeEvent(event.LeaseId, event.Raw)
+	h.logger.Info("received DisputeResolved event", "lease_id", located.LeaseID, "valid", event.IsDisputeValid)
+
+	h.sink.RecordChainEvent("DisputeResolved", event.Raw.BlockNumber, event.Raw.TxHash.Hex(), event.Raw.Index, map[string]any{
+		"lease_id":         located.LeaseID,
+		"is_dispute_valid": event.IsDisputeValid,
+		"stake_amount":     event.StakeAmount.String(),
+		"network":          h.network,
+	})
+
+	if h.disputes == nil {
+		return
+	}
+	if _, err := h.disputes.Resolved(located, event.IsDisputeValid, event.StakeAmount); err != nil {
+		h.logger.Error("failed to record resolved dispute", "error", err, "lease_id", located.LeaseID)
+	}
+}
+
+// setHeaders sets the reader block times are looked up with
+func (h *leaseEventHandler) setHeaders(reader HeaderReader) {
+	h.headersMu.Lock()
+	defer h.headersMu.Unlock()
+	h.headers = reader
+}
+
+// blockTime returns when log's block was mined: the timestamp the node sent
+// with the log or, from nodes that send none, the block header's
+func (h *leaseEventHandler) blockTime(log types.Log) (time.Time, error) {
+	if log.BlockTimestamp != 0 {
+		return time.Unix(int64(log.BlockTimestamp), 0), nil
+	}
+	h.headersMu.Lock()
+	reader := h.headers
+	h.headersMu.Unlock()
+	if reader == nil {
+		return time.Time{}, fmt.Errorf("log carries no block timestamp and the client cannot read headers")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	header, err := reader.HeaderByNumber(ctx, new(big.Int).SetUint64(log.BlockNumber))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read block %d: %w", log.BlockNumber, err)
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
+
+// knowsEarners reports whether the agent can tell which leases pay it
+func (h *leaseEventHandler) knowsEarners() bool {
+	return h.tenants != nil || h.earner != (common.Address{})
+}
+
+// hosts reports whether a lease paying earner pays the agent: one of its
+// tenants' earners or its own address. Without either every lease is
+// reported as hosted, for the reputation tracker and status sink only.
+func (h *leaseEventHandler) hosts(earner common.Address) (tenantID string, hosted bool) {
+	if h.tenants != nil {
+		if owner, ok := h.tenants.ForEarner(earner.Hex()); ok {
+			return owner.ID, true
+		}
+	}
+	if h.earner != (common.Address{}) {
+		return "", earner == h.earner
+	}
+	return "", h.tenants == nil
+}
+
+// bookPayout records an executed lease's payout against its product at the
+// time of the block that executed it
+func (h *leaseEventHandler) bookPayout(leaseID [32]byte, log types.Log) {
+	if h.earnings == nil || !h.knowsEarners() {
+		return
+	}
+	id := fmt.Sprintf("0x%x", leaseID)
+
+	var product string
+	if h.products != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var err error
+		product, err = h.products.LeaseProduct(ctx, leaseID)
+		cancel()
+		if err != nil {
+			h.logger.Error("failed to look up leased product", "error", err, "lease_id", id)
+		}
+	}
+
+	executedAt, err := h.blockTime(log)
+	if err != nil {
+		h.logger.Warn("failed to read lease execution time, booking it at the current time", "error", err, "lease_id", id)
+		executedAt = time.Now()
+	}
+	known, err := h.earnings.RecordExecution(id, product, executedAt)
+	if err != nil {
+		h.logger.Error("failed to book lease payout", "error", err, "lease_id", id)
+	} else if !known {
+		h.logger.Debug("executed lease has no recorded terms paying this agent", "lease_id", id)
+	}
+}
+
+// handleOutcome records a lease outcome event and credits the spender
+func (h *leaseEventHandler) handleOutcome(name string, leaseID [32]byte, outcome reputation.Outcome, log types.Log) {
+	id := fmt.Sprintf("0x%x", leaseID)
+	h.logger.Info("received lease outcome event", "event", name, "lease_id", id)
+
+	h.sink.RecordChainEvent(name, log.BlockNumber, log.TxHash.Hex(), log.Index, map[string]any{
+		"lease_id": id,
+		"network":  h.network,
+	})
+
+	if h.tracker == nil {
+		return
+	}
+	known, err := h.tracker.RecordOutcome(id, outcome)
+	if err != nil {
+		h.logger.Error("failed to record lease outcome", "error", err, "lease_id", id, "outcome", outcome)
+	} else if !known {
+		h.logger.Warn("lease outcome for unknown lease", "lease_id", id, "outcome", outcome)
+	}
+}
+
+// handleCreated processes a LeaseCreated event
+func (h *leaseEventHandler) handleCreated(event *contracts.LeaseAgreementLeaseCreated) {
+	h.logger.Info("received LeaseCreated event",
+		"lease_id", event.LeaseId,
+		"spender", event.Spender.Hex(),
+		"earner", event.Earner.Hex(),
+		"price", event.Price.String(),
+	)
+
+	// Convert price to string
+	priceStr := event.Price.String()
+
+	fields := map[string]any{
+		"lease_id": fmt.Sprintf("0x%x", event.LeaseId),
+		"spender":  event.Spender.Hex(),
+		"earner":   event.Earner.Hex(),
+		"price":    priceStr,
+		"network":  h.network,
+	}
+	tenantID, hosted := h.hosts(event.Earner)
+	if tenantID != "" {
+		fields["tenant"] = tenantID
+	}
+	h.sink.RecordChainEvent("LeaseCreated", event.Raw.BlockNumber, event.Raw.TxHash.Hex(), event.Raw.Index, fields)
+
+	if h.tracker != nil {
+		if err := h.tracker.RecordLease(fmt.Sprintf("0x%x", event.LeaseId), event.Spender.Hex()); err != nil {
+			h.logger.Error("failed to record lease for reputation", "error", err)
+		}
+	}
+
+	// The contract is shared, so leases paying earners hosted elsewhere still
+	// count towards the spender's reputation but are not booked or approved here
+	if !hosted {
+		h.logger.Info("lease pays an earner this agent does not host",
+			"lease_id", fmt.Sprintf("0x%x", event.LeaseId),
+			"earner", event.Earner.Hex(),
+		)
+		return
+	}
+	if h.earnings != nil && h.knowsEarners() {
+		if err := h.earnings.RecordLease(fmt.Sprintf("0x%x", event.LeaseId), event.Earner.Hex(), event.Spender.Hex(), event.Price); err != nil {
+			h.logger.Error("failed to record lease terms for earnings", "error", err)
+		}
+	}
+
+	// Convert lease ID to uint64 for storage
+	// Note: This is a simplified approach. In production, you might want to store the full bytes32
+	leaseID := uint64(0) // We'll use 0 for now since we don't have a direct mapping
+
+	// For now, we'll use a simple mapping from lease ID to proposal ID
+	// In a real implementation, you might want to maintain a mapping table
+	leaseProposalID := fmt.Sprintf("lease_prop_%x", event.LeaseId)
+
+	// Update the lease status in the API server
+	h.sink.UpdateLeaseStatus(
+		leaseProposalID,
+		"approved",
+		&leaseID,
+		event.Spender.Hex(),
+		event.Earner.Hex(),
+		&priceStr,
+	)
+}
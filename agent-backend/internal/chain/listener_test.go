@@ -0,0 +1,179 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/dispute"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/tenant"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// recordingSink collects the chain events the handler records
+type recordingSink struct {
+	events   []string
+	fields   []map[string]any
+	statuses map[string]string
+}
+
+func (s *recordingSink) RecordChainEvent(name string, blockNumber uint64, txHash string, logIndex uint, fields map[string]any) {
+	s.events = append(s.events, name)
+	s.fields = append(s.fields, fields)
+}
+
+func (s *recordingSink) UpdateLeaseStatus(leaseProposalID string, status string, leaseID *uint64, spenderAddr, earnerAddr string, price *string) {
+	if s.statuses == nil {
+		s.statuses = make(map[string]string)
+	}
+	s.statuses[leaseProposalID] = status
+}
+
+// disputeLog builds a log for a dispute event on leaseID
+func disputeLog(t *testing.T, name string, leaseID common.Hash, block uint64, tx byte, topics []common.Hash, args ...any) types.Log {
+	t.Helper()
+	parsed, err := contracts.LeaseAgreementMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("GetAbi() error = %v", err)
+	}
+	event := parsed.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		t.Fatalf("Pack(%s) error = %v", name, err)
+	}
+	return types.Log{
+		Topics:      append([]common.Hash{event.ID, leaseID}, topics...),
+		Data:        data,
+		BlockNumber: block,
+		TxHash:      common.Hash{tx},
+	}
+}
+
+func TestLeaseEventHandlerDisputes(t *testing.T) {
+	sink := &recordingSink{}
+	handler, err := newLeaseEventHandler(common.Address{}, sink, nil, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("newLeaseEventHandler() error = %v", err)
+	}
+	handler.network = "sepolia"
+	store, _ := dispute.NewStore("")
+	handler.disputes = store
+
+	leaseID := common.Hash{0xab}
+	spender := common.BytesToHash(common.HexToAddress("0x1111111111111111111111111111111111111111").Bytes())
+	earner := common.BytesToHash(common.HexToAddress("0x2222222222222222222222222222222222222222").Bytes())
+
+	handler.handle(disputeLog(t, "DisputeRaised", leaseID, 10, 1, []common.Hash{spender, earner},
+		"bad data [evidence: ipfs://bafybundle]", big.NewInt(500)))
+	handler.handle(disputeLog(t, "DisputeResolved", leaseID, 12, 2, nil, false, big.NewInt(500)))
+
+	if len(sink.events) != 2 || sink.events[0] != "DisputeRaised" || sink.events[1] != "DisputeResolved" {
+		t.Errorf("recorded events = %v", sink.events)
+	}
+	records := store.List(dispute.Query{LeaseID: leaseID.Hex()})
+	if len(records) != 1 {
+		t.Fatalf("dispute records = %d, want 1", len(records))
+	}
+	record := records[0]
+	if record.Status != dispute.StatusRejected || record.Network != "sepolia" || record.EvidenceCID != "bafybundle" ||
+		record.Spender != "0x1111111111111111111111111111111111111111" || record.StakeAmount != "500" {
+		t.Errorf("dispute record = %+v", record)
+	}
+	if len(record.History) != 2 || record.History[0].BlockNumber != 10 || record.History[1].Status != dispute.StatusRejected {
+		t.Errorf("dispute history = %+v", record.History)
+	}
+}
+
+func TestLeaseEventHandlerTenants(t *testing.T) {
+	sink := &recordingSink{}
+	handler, err := newLeaseEventHandler(common.Address{}, sink, nil, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("newLeaseEventHandler() error = %v", err)
+	}
+	ledger, err := earnings.NewLedger(0, "")
+	if err != nil {
+		t.Fatalf("NewLedger() error = %v", err)
+	}
+	handler.earnings = ledger
+	handler.tenants = tenant.NewRegistry([]config.TenantConfig{
+		{ID: "alice", Earner: "0x2222222222222222222222222222222222222222", Namespaces: []string{"did:pandacea:mainnet:alice"}},
+	})
+
+	spender := common.BytesToHash(common.HexToAddress("0x1111111111111111111111111111111111111111").Bytes())
+	hosted := common.BytesToHash(common.HexToAddress("0x2222222222222222222222222222222222222222").Bytes())
+	foreign := common.BytesToHash(common.HexToAddress("0x3333333333333333333333333333333333333333").Bytes())
+	handler.handle(disputeLog(t, "LeaseCreated", common.Hash{0x01}, 10, 1, []common.Hash{spender, hosted}, big.NewInt(1000)))
+	handler.handle(disputeLog(t, "LeaseCreated", common.Hash{0x02}, 11, 2, []common.Hash{spender, foreign}, big.NewInt(1000)))
+
+	if len(sink.events) != 2 {
+		t.Fatalf("recorded events = %v, want both leases", sink.events)
+	}
+	if sink.fields[0]["tenant"] != "alice" {
+		t.Errorf("hosted lease fields = %v, want tenant alice", sink.fields[0])
+	}
+	if _, ok := sink.fields[1]["tenant"]; ok {
+		t.Errorf("foreign lease fields = %v, want no tenant", sink.fields[1])
+	}
+	if len(sink.statuses) != 1 || sink.statuses[fmt.Sprintf("lease_prop_%x", common.Hash{0x01})] != "approved" {
+		t.Errorf("lease statuses = %v, want only the hosted lease approved", sink.statuses)
+	}
+	if _, err := ledger.RecordExecution(fmt.Sprintf("0x%x", common.Hash{0x02}), "", time.Now()); err != nil {
+		t.Fatalf("RecordExecution() error = %v", err)
+	}
+	if report := ledger.Report(earnings.Query{}); report.Total.Leases != 0 {
+		t.Errorf("ledger booked %d leases for a foreign earner", report.Total.Leases)
+	}
+}
+
+func TestLeaseEventHandlerEarner(t *testing.T) {
+	sink := &recordingSink{}
+	handler, err := newLeaseEventHandler(common.Address{}, sink, nil, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("newLeaseEventHandler() error = %v", err)
+	}
+	ledger, err := earnings.NewLedger(0, "")
+	if err != nil {
+		t.Fatalf("NewLedger() error = %v", err)
+	}
+	handler.earnings = ledger
+
+	spender := common.BytesToHash(common.HexToAddress("0x1111111111111111111111111111111111111111").Bytes())
+	own := common.BytesToHash(common.HexToAddress("0x2222222222222222222222222222222222222222").Bytes())
+	foreign := common.BytesToHash(common.HexToAddress("0x3333333333333333333333333333333333333333").Bytes())
+	executedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	execute := func(leaseID common.Hash, block uint64) {
+		log := disputeLog(t, "LeaseExecuted", leaseID, block, byte(block), nil)
+		log.BlockTimestamp = uint64(executedAt.Unix())
+		handler.handle(log)
+	}
+
+	// Without tenants or an earner address no lease can be told apart, so
+	// none is booked
+	handler.handle(disputeLog(t, "LeaseCreated", common.Hash{0x01}, 10, 1, []common.Hash{spender, foreign}, big.NewInt(1000)))
+	execute(common.Hash{0x01}, 11)
+	if report := ledger.Report(earnings.Query{}); report.Total.Leases != 0 {
+		t.Errorf("ledger booked %d leases without an earner address", report.Total.Leases)
+	}
+
+	handler.earner = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	handler.handle(disputeLog(t, "LeaseCreated", common.Hash{0x02}, 12, 2, []common.Hash{spender, own}, big.NewInt(1000)))
+	handler.handle(disputeLog(t, "LeaseCreated", common.Hash{0x03}, 13, 3, []common.Hash{spender, foreign}, big.NewInt(1000)))
+	execute(common.Hash{0x02}, 14)
+	execute(common.Hash{0x03}, 15)
+
+	if len(sink.statuses) != 2 || sink.statuses[fmt.Sprintf("lease_prop_%x", common.Hash{0x03})] != "" {
+		t.Errorf("lease statuses = %v, want the foreign lease not approved", sink.statuses)
+	}
+	report := ledger.Report(earnings.Query{From: executedAt, To: executedAt.Add(time.Second)})
+	if report.Total.Leases != 1 || report.Total.Gross != "1000" {
+		t.Errorf("report at the block time = %+v, want only the own lease", report.Total)
+	}
+}
@@ -0,0 +1,198 @@
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultMaxLagBlocks is the lag beyond which the listener reports degraded
+const DefaultMaxLagBlocks = 20
+
+// Listener states reported by Snapshot
+const (
+	StateConnecting = "connecting"
+	StateCatchingUp = "catching_up"
+	StateSynced     = "synced"
+	StateDegraded   = "degraded"
+)
+
+var (
+	listenerConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_event_listener_connected",
+		Help: "Whether the blockchain event listener has a live subscription (1) or not (0)",
+	}, []string{"network"})
+	listenerLastBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_event_listener_last_block",
+		Help: "Last block processed by the blockchain event listener",
+	}, []string{"network"})
+	listenerHeadBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_event_listener_head_block",
+		Help: "Latest chain head seen by the blockchain event listener",
+	}, []string{"network"})
+	listenerLagBlocks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_event_listener_lag_blocks",
+		Help: "Blocks between the chain head and the last processed block",
+	}, []string{"network"})
+	listenerReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_event_listener_reconnects_total",
+		Help: "Number of times the blockchain event listener reconnected",
+	}, []string{"network"})
+)
+
+// ListenerState is a point-in-time view of the event listener
+type ListenerState struct {
+	Network    string    `json:"network,omitempty"`
+	State      string    `json:"state"`
+	Connected  bool      `json:"connected"`
+	CatchingUp bool      `json:"catching_up"`
+	LastBlock  uint64    `json:"last_block"`
+	HeadBlock  uint64    `json:"head_block"`
+	Lag        uint64    `json:"lag"`
+	MaxLag     uint64    `json:"max_lag"`
+	LastError  string    `json:"last_error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListenerStatus tracks the blockchain event listener's connection and
+// progress so readiness checks and metrics can report on it
+type ListenerStatus struct {
+	mu         sync.RWMutex
+	network    string
+	maxLag     uint64
+	connected  bool
+	catchingUp bool
+	seenBlock  bool
+	lastBlock  uint64
+	headBlock  uint64
+	lastError  string
+	updatedAt  time.Time
+}
+
+// NewListenerStatus creates a status tracker for the listener on network
+// that reports degraded once it falls more than maxLag blocks behind the
+// chain head
+func NewListenerStatus(network string, maxLag uint64) *ListenerStatus {
+	if maxLag == 0 {
+		maxLag = DefaultMaxLagBlocks
+	}
+	return &ListenerStatus{network: network, maxLag: maxLag, updatedAt: time.Now()}
+}
+
+// Network returns the name of the network the listener follows
+func (s *ListenerStatus) Network() string {
+	return s.network
+}
+
+// SetConnected records whether the listener has a live subscription. A
+// disconnect records err as the last error.
+func (s *ListenerStatus) SetConnected(connected bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if connected && !s.connected && s.seenBlock {
+		listenerReconnects.WithLabelValues(s.network).Inc()
+	}
+	s.connected = connected
+	if err != nil {
+		s.lastError = err.Error()
+	} else if connected {
+		s.lastError = ""
+	}
+	s.updatedAt = time.Now()
+
+	if connected {
+		listenerConnected.WithLabelValues(s.network).Set(1)
+	} else {
+		listenerConnected.WithLabelValues(s.network).Set(0)
+	}
+}
+
+// SetCatchingUp records whether the listener is replaying missed blocks
+func (s *ListenerStatus) SetCatchingUp(catchingUp bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.catchingUp = catchingUp
+	s.updatedAt = time.Now()
+}
+
+// ObserveBlock records that every block up to and including block has been
+// processed
+func (s *ListenerStatus) ObserveBlock(block uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.seenBlock || block > s.lastBlock {
+		s.lastBlock = block
+		s.seenBlock = true
+	}
+	s.headBlock = max(s.headBlock, block)
+	s.updatedAt = time.Now()
+	s.updateGauges()
+}
+
+// ObserveHead records the latest chain head
+func (s *ListenerStatus) ObserveHead(head uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.headBlock = max(s.headBlock, head)
+	s.updatedAt = time.Now()
+	s.updateGauges()
+}
+
+// LastBlock returns the last processed block and whether any block has been
+// processed yet
+func (s *ListenerStatus) LastBlock() (uint64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastBlock, s.seenBlock
+}
+
+// Snapshot returns the current listener state
+func (s *ListenerStatus) Snapshot() ListenerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := ListenerState{
+		Network:    s.network,
+		Connected:  s.connected,
+		CatchingUp: s.catchingUp,
+		LastBlock:  s.lastBlock,
+		HeadBlock:  s.headBlock,
+		Lag:        s.lag(),
+		MaxLag:     s.maxLag,
+		LastError:  s.lastError,
+		UpdatedAt:  s.updatedAt,
+	}
+
+	switch {
+	case !s.connected && !s.seenBlock:
+		state.State = StateConnecting
+	case !s.connected || state.Lag > s.maxLag:
+		state.State = StateDegraded
+	case s.catchingUp:
+		state.State = StateCatchingUp
+	default:
+		state.State = StateSynced
+	}
+	return state
+}
+
+// lag returns how far the last processed block trails the head. Caller must
+// hold s.mu.
+func (s *ListenerStatus) lag() uint64 {
+	if !s.seenBlock || s.headBlock < s.lastBlock {
+		return 0
+	}
+	return s.headBlock - s.lastBlock
+}
+
+// updateGauges publishes block progress. Caller must hold s.mu.
+func (s *ListenerStatus) updateGauges() {
+	listenerLastBlock.WithLabelValues(s.network).Set(float64(s.lastBlock))
+	listenerHeadBlock.WithLabelValues(s.network).Set(float64(s.headBlock))
+	listenerLagBlocks.WithLabelValues(s.network).Set(float64(s.lag()))
+}
@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestListenerStatusStates(t *testing.T) {
+	status := NewListenerStatus("default", 10)
+
+	if got := status.Snapshot().State; got != StateConnecting {
+		t.Fatalf("initial state = %q, want %q", got, StateConnecting)
+	}
+
+	status.ObserveHead(100)
+	status.SetConnected(true, nil)
+	status.ObserveBlock(100)
+	if state := status.Snapshot(); state.State != StateSynced || state.Lag != 0 {
+		t.Fatalf("after subscribing: %+v", state)
+	}
+
+	// Lag within the threshold while replaying is still healthy
+	status.SetCatchingUp(true)
+	status.ObserveHead(105)
+	if state := status.Snapshot(); state.State != StateCatchingUp || state.Lag != 5 {
+		t.Errorf("catching up: %+v", state)
+	}
+
+	status.ObserveHead(150)
+	if state := status.Snapshot(); state.State != StateDegraded || state.Lag != 50 {
+		t.Errorf("lagging: %+v", state)
+	}
+
+	status.ObserveBlock(150)
+	status.SetCatchingUp(false)
+	if state := status.Snapshot(); state.State != StateSynced || state.LastBlock != 150 {
+		t.Errorf("caught up: %+v", state)
+	}
+
+	status.SetConnected(false, errors.New("connection reset"))
+	state := status.Snapshot()
+	if state.State != StateDegraded || state.LastError != "connection reset" {
+		t.Errorf("disconnected: %+v", state)
+	}
+	if last, ok := status.LastBlock(); !ok || last != 150 {
+		t.Errorf("LastBlock() = %d, %v, want 150, true", last, ok)
+	}
+
+	status.SetConnected(true, nil)
+	if state := status.Snapshot(); state.State != StateSynced || state.LastError != "" {
+		t.Errorf("reconnected: %+v", state)
+	}
+}
+
+func TestListenerStatusIgnoresOlderBlocks(t *testing.T) {
+	status := NewListenerStatus("default", 0)
+	status.ObserveBlock(20)
+	status.ObserveBlock(10)
+
+	if last, _ := status.LastBlock(); last != 20 {
+		t.Errorf("LastBlock() = %d, want 20", last)
+	}
+	if state := status.Snapshot(); state.MaxLag != DefaultMaxLagBlocks {
+		t.Errorf("MaxLag = %d, want default %d", state.MaxLag, DefaultMaxLagBlocks)
+	}
+}
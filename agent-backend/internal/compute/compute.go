@@ -0,0 +1,194 @@
+// Package compute carries computation requests over libp2p, so spenders can
+// queue computations on an earner agent and read their results without
+// reaching its HTTP port, for instance when the earner is behind NAT and
+// only reachable through a relay. Each stream carries one request and its
+// response; the remote peer of the stream is the caller, authenticated by
+// the libp2p handshake in place of a request signature.
+package compute
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ProtocolID is the libp2p protocol computation requests are sent over
+const ProtocolID protocol.ID = "/pandacea/compute/1.0.0"
+
+// MaxRequestSize is the largest request that is read
+const MaxRequestSize = 1 << 20
+
+// MaxResponseSize is the largest response that is read, which bounds the
+// results returned over a stream
+const MaxResponseSize = 64 << 20
+
+// Operations a request asks for
+const (
+	OpExecute = "execute" // Queue Computation, returning its computation ID
+	OpResult  = "result"  // Return the status and results of ComputationID
+)
+
+// Error codes Serve itself answers with, the same as the API's
+const (
+	CodeInvalidRequest = "INVALID_REQUEST"
+	CodeInternalError  = "INTERNAL_ERROR"
+)
+
+// ErrProtocolViolation marks requests no well-behaved spender sends, such as
+// unknown operations. Serve reports their senders.
+var ErrProtocolViolation = errors.New("compute protocol violation")
+
+// Request is one operation on the earner agent
+type Request struct {
+	Op string `json:"op"`
+
+	// OpExecute: the computation and the spender holding its lease
+	Computation    *privacy.ComputationRequest `json:"computation,omitempty"`
+	SpenderAddress string                      `json:"spender_address,omitempty"`
+	Network        string                      `json:"network,omitempty"` // Network to verify the lease on (empty picks as over HTTP)
+
+	// OpResult
+	ComputationID string `json:"computation_id,omitempty"`
+}
+
+// Response answers a request with either its outcome or an error
+type Response struct {
+	ComputationID string                     `json:"computation_id,omitempty"`
+	Result        *privacy.ComputationResult `json:"result,omitempty"`
+	Error         *Error                     `json:"error,omitempty"`
+}
+
+// Error is a refused or failed request. Codes are the API's error codes,
+// so spenders handle them as they would over HTTP.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Handler runs requests for callers. Errors returned as *Error are sent as
+// they are; any other error is sent as an internal error without details.
+type Handler interface {
+	QueueComputation(ctx context.Context, caller peer.ID, req Request) (*privacy.ComputationResponse, error)
+	ComputationResult(ctx context.Context, caller peer.ID, computationID string) (*privacy.ComputationResult, error)
+}
+
+// PeerReporter lowers the reputation of peers that misbehave on the
+// compute protocol
+type PeerReporter interface {
+	ReportPeer(id peer.ID, offense p2p.Offense)
+}
+
+// Serve registers the compute protocol on h so spenders can send requests
+// to handler. Spenders that send malformed requests or break the protocol
+// are reported to a non-nil reporter.
+func Serve(h host.Host, handler Handler, reporter PeerReporter, logger *slog.Logger) {
+	h.SetStreamHandler(ProtocolID, func(s network.Stream) {
+		handleStream(s, handler, reporter, logger)
+	})
+}
+
+// handleStream answers one request
+func handleStream(s network.Stream, handler Handler, reporter PeerReporter, logger *slog.Logger) {
+	defer s.Close()
+	remote := s.Conn().RemotePeer()
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(s, MaxRequestSize)).Decode(&req); err != nil {
+		logger.Warn("failed to decode compute request", "peer_id", remote.String(), "error", err)
+		if reporter != nil {
+			reporter.ReportPeer(remote, p2p.OffenseInvalidMessage)
+		}
+		s.Reset()
+		return
+	}
+
+	resp, err := serve(context.Background(), handler, remote, req)
+	if err != nil {
+		logger.Warn("compute request failed", "peer_id", remote.String(), "op", req.Op, "error", err)
+		if reporter != nil && errors.Is(err, ErrProtocolViolation) {
+			reporter.ReportPeer(remote, p2p.OffenseProtocolViolation)
+		}
+		var refused *Error
+		if !errors.As(err, &refused) {
+			refused = &Error{Code: CodeInternalError, Message: "Request failed"}
+		}
+		resp = Response{Error: refused}
+	}
+	if err := json.NewEncoder(s).Encode(resp); err != nil {
+		logger.Error("failed to send compute response", "peer_id", remote.String(), "error", err)
+		s.Reset()
+	}
+}
+
+// serve runs req for caller
+func serve(ctx context.Context, handler Handler, caller peer.ID, req Request) (Response, error) {
+	switch req.Op {
+	case OpExecute:
+		if req.Computation == nil {
+			return Response{}, &Error{Code: CodeInvalidRequest, Message: "execute needs a computation"}
+		}
+		queued, err := handler.QueueComputation(ctx, caller, req)
+		if err != nil {
+			return Response{}, err
+		}
+		return Response{ComputationID: queued.ComputationID}, nil
+	case OpResult:
+		if req.ComputationID == "" {
+			return Response{}, &Error{Code: CodeInvalidRequest, Message: "result needs a computation_id"}
+		}
+		result, err := handler.ComputationResult(ctx, caller, req.ComputationID)
+		if err != nil {
+			return Response{}, err
+		}
+		return Response{ComputationID: req.ComputationID, Result: result}, nil
+	default:
+		return Response{}, fmt.Errorf("%w: unknown op %q", ErrProtocolViolation, req.Op)
+	}
+}
+
+// Send sends req to the earner agent id over a new stream from h and
+// returns its response. A refused request is returned as an *Error.
+func Send(ctx context.Context, h host.Host, id peer.ID, req Request) (Response, error) {
+	s, err := h.NewStream(ctx, id, ProtocolID)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(req); err != nil {
+		s.Reset()
+		return Response{}, fmt.Errorf("failed to send compute request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return Response{}, fmt.Errorf("failed to send compute request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(io.LimitReader(s, MaxResponseSize)).Decode(&resp); err != nil {
+		s.Reset()
+		return Response{}, fmt.Errorf("failed to read compute response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp, resp.Error
+	}
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package compute
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// recordingHandler queues computations for the caller that sent them
+type recordingHandler struct {
+	mu     sync.Mutex
+	owners map[string]peer.ID
+}
+
+func (h *recordingHandler) QueueComputation(ctx context.Context, caller peer.ID, req Request) (*privacy.ComputationResponse, error) {
+	if req.SpenderAddress == "" {
+		return nil, &Error{Code: "UNAUTHORIZED", Message: "Spender address is required"}
+	}
+	if req.Computation.LeaseID == "broken" {
+		return nil, errors.New("container pool exploded")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := fmt.Sprintf("comp_%d", len(h.owners)+1)
+	h.owners[id] = caller
+	return &privacy.ComputationResponse{ComputationID: id}, nil
+}
+
+func (h *recordingHandler) ComputationResult(ctx context.Context, caller peer.ID, computationID string) (*privacy.ComputationResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if owner, ok := h.owners[computationID]; !ok || owner != caller {
+		return nil, &Error{Code: "NOT_FOUND", Message: "computation not found"}
+	}
+	return &privacy.ComputationResult{Status: "completed"}, nil
+}
+
+// recordingReporter remembers the last offense reported for each peer
+type recordingReporter struct {
+	mu       sync.Mutex
+	reported map[peer.ID]p2p.Offense
+}
+
+func (r *recordingReporter) ReportPeer(id peer.ID, offense p2p.Offense) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reported[id] = offense
+}
+
+func TestServeRoundTrip(t *testing.T) {
+	earner, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create earner host: %v", err)
+	}
+	defer earner.Close()
+	spender, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create spender host: %v", err)
+	}
+	defer spender.Close()
+
+	handler := &recordingHandler{owners: make(map[string]peer.ID)}
+	reporter := &recordingReporter{reported: make(map[peer.ID]p2p.Offense)}
+	Serve(earner, handler, reporter, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := spender.Connect(ctx, peer.AddrInfo{ID: earner.ID(), Addrs: earner.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	queued, err := Send(ctx, spender, earner.ID(), Request{
+		Op:             OpExecute,
+		Computation:    &privacy.ComputationRequest{LeaseID: "0xabc", ComputationCid: "bafy"},
+		SpenderAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+	})
+	if err != nil {
+		t.Fatalf("Send(execute) error = %v", err)
+	}
+	if queued.ComputationID != "comp_1" || handler.owners["comp_1"] != spender.ID() {
+		t.Errorf("execute queued %q for %v, want comp_1 for the stream's remote peer", queued.ComputationID, handler.owners["comp_1"])
+	}
+
+	result, err := Send(ctx, spender, earner.ID(), Request{Op: OpResult, ComputationID: queued.ComputationID})
+	if err != nil {
+		t.Fatalf("Send(result) error = %v", err)
+	}
+	if result.Result == nil || result.Result.Status != "completed" {
+		t.Errorf("result = %+v, want the completed computation", result)
+	}
+
+	// Refusals keep their code; internal failures hide their details
+	var refused *Error
+	_, err = Send(ctx, spender, earner.ID(), Request{Op: OpExecute, Computation: &privacy.ComputationRequest{LeaseID: "0xabc"}})
+	if !errors.As(err, &refused) || refused.Code != "UNAUTHORIZED" {
+		t.Errorf("execute without a spender error = %v, want UNAUTHORIZED", err)
+	}
+	_, err = Send(ctx, spender, earner.ID(), Request{Op: OpExecute, Computation: &privacy.ComputationRequest{LeaseID: "broken"}, SpenderAddress: "0x1"})
+	if !errors.As(err, &refused) || refused.Code != CodeInternalError || refused.Message != "Request failed" {
+		t.Errorf("failed execute error = %v, want an internal error without details", err)
+	}
+	_, err = Send(ctx, spender, earner.ID(), Request{Op: OpExecute})
+	if !errors.As(err, &refused) || refused.Code != CodeInvalidRequest {
+		t.Errorf("execute without a computation error = %v, want INVALID_REQUEST", err)
+	}
+	if len(reporter.reported) != 0 {
+		t.Errorf("refused requests reported %v, want no offenses", reporter.reported)
+	}
+
+	// Operations outside the protocol are reported against the spender
+	if _, err := Send(ctx, spender, earner.ID(), Request{Op: "delete"}); err == nil {
+		t.Fatal("Send with an unknown op succeeded")
+	}
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if got := reporter.reported[spender.ID()]; got != p2p.OffenseProtocolViolation {
+		t.Errorf("reported offense = %q, want a protocol violation by the spender", got)
+	}
+}
@@ -2,18 +2,65 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 
+	"pandacea/agent-backend/internal/egress"
+	"pandacea/agent-backend/internal/privacy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server     ServerConfig     `yaml:"server"`
-	P2P        P2PConfig        `yaml:"p2p"`
-	Blockchain BlockchainConfig `yaml:"blockchain"`
-	IPFS       IPFSConfig       `yaml:"ipfs"`
+	// Profile is the deployment profile the hardening defaults came from
+	Profile   string          `yaml:"-"`
+	Hardening HardeningConfig `yaml:"hardening"`
+
+	Server       ServerConfig       `yaml:"server"`
+	P2P          P2PConfig          `yaml:"p2p"`
+	Blockchain   BlockchainConfig   `yaml:"blockchain"`
+	IPFS         IPFSConfig         `yaml:"ipfs"`
+	Policy       PolicyConfig       `yaml:"policy"`
+	Pricing      PricingConfig      `yaml:"pricing"`
+	HTTP         HTTPConfig         `yaml:"http"`
+	Training     TrainingConfig     `yaml:"training"`
+	Watermark    WatermarkConfig    `yaml:"watermark"`
+	Verification VerificationConfig `yaml:"verification"`
+	Attestation  AttestationConfig  `yaml:"attestation"`
+	Audit        AuditConfig        `yaml:"audit"`
+	Privacy      PrivacyConfig      `yaml:"privacy"`
+	Incident     IncidentConfig     `yaml:"incident"`
+	Earnings     EarningsConfig     `yaml:"earnings"`
+	Disputes     DisputesConfig     `yaml:"disputes"`
+	Usage        UsageConfig        `yaml:"usage"`
+	Models       ModelsConfig       `yaml:"models"`
+	Lineage      LineageConfig      `yaml:"lineage"`
+	Consent      ConsentConfig      `yaml:"consent"`
+	Metering     MeteringConfig     `yaml:"metering"`
+	Delivery     DeliveryConfig     `yaml:"delivery"`
+	Assets       AssetsConfig       `yaml:"assets"`
+	Encryption   EncryptionConfig   `yaml:"encryption"`
+	Retention    RetentionConfig    `yaml:"retention"`
+	Publish      PublishConfig      `yaml:"publish"`
+	Transactions TransactionsConfig `yaml:"transactions"`
+	Remote       RemoteConfig       `yaml:"remote"`
+	Catalog      CatalogConfig      `yaml:"catalog"`
+	Federation   FederationConfig   `yaml:"federation"`
+	Scheduler    SchedulerConfig    `yaml:"scheduler"`
+	GPU          GPUConfig          `yaml:"gpu"`
+	Pool         PoolConfig         `yaml:"container_pool"`
+	ScriptScan   ScriptScanConfig   `yaml:"script_scan"`
+	Market       MarketConfig       `yaml:"market"`
+	Spender      SpenderConfig      `yaml:"spender"`
+	Reload       ReloadConfig       `yaml:"reload"`
+	Tenants      []TenantConfig     `yaml:"tenants"`
 }
 
 // ServerConfig contains HTTP server configuration
@@ -28,18 +75,159 @@ type ServerConfig struct {
 	ReputationDecayRate    float64 `yaml:"reputation_decay_rate"`
 	CollusionSpendFraction float64 `yaml:"collusion_spend_fraction"`
 	CollusionBonusDivisor  int     `yaml:"collusion_bonus_divisor"`
+
+	// MinReputation rejects lease requests from peers whose reputation is
+	// below this score (0 disables the check). Peers with no history score
+	// the neutral 0.5, so a minimum above that turns them away too.
+	MinReputation float64 `yaml:"min_reputation"`
+
+	// MaxLeaseDuration caps lease durations such as "30d" (empty means no
+	// cap). ProductMaxLeaseDurations overrides it for individual products.
+	MaxLeaseDuration         string            `yaml:"max_lease_duration"`
+	ProductMaxLeaseDurations map[string]string `yaml:"product_max_lease_durations"`
+
+	// AllowLeaseTransfers lets spenders assign active leases to a new
+	// holder with a signed assignment record. LeaseAssignmentsPath persists
+	// the records (empty keeps them in memory only).
+	AllowLeaseTransfers  bool   `yaml:"allow_lease_transfers"`
+	LeaseAssignmentsPath string `yaml:"lease_assignments_path"`
+
+	// Compression controls compression of API responses
+	Compression CompressionConfig `yaml:"compression"`
+
+	// CORS lets browser clients served from other origins call the API
+	CORS CORSConfig `yaml:"cors"`
+
+	// LegacyRoutes decides how the deprecated /train and /aggregate/{jobId}
+	// routes are served: allow, warn or block. Allowed routes still go
+	// through the same authentication and rate limits as /api/v1.
+	LegacyRoutes string `yaml:"legacy_routes"`
+}
+
+// Legacy route modes
+const (
+	LegacyRoutesAllow = "allow" // Serve them like their /api/v1 counterparts
+	LegacyRoutesWarn  = "warn"  // Serve them with a deprecation warning
+	LegacyRoutesBlock = "block" // Refuse them with 410 Gone
+)
+
+// CORSConfig controls cross-origin requests from browsers. CORS is disabled
+// while AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // Exact origins, patterns such as "https://*.example.com", or "*" for any
+	AllowedMethods   []string `yaml:"allowed_methods"`   // Methods cross-origin requests may use
+	AllowedHeaders   []string `yaml:"allowed_headers"`   // Request headers browsers may send
+	ExposedHeaders   []string `yaml:"exposed_headers"`   // Response headers scripts may read
+	AllowCredentials bool     `yaml:"allow_credentials"` // Let browsers send cookies and client certificates
+	MaxAgeSeconds    int      `yaml:"max_age_seconds"`   // How long browsers may cache a preflight response
+}
+
+// validate checks the origins can be combined with the credentials setting
+func (c CORSConfig) validate(errs *problems) {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" && c.AllowCredentials {
+			errs.add("server.cors.allowed_origins", `"*" cannot be combined with allow_credentials; list the origins instead`)
+		}
+		if strings.Count(origin, "*") > 1 {
+			errs.add("server.cors.allowed_origins", "%q has more than one wildcard", origin)
+		}
+	}
+	if c.MaxAgeSeconds < 0 {
+		errs.add("server.cors.max_age_seconds", "must not be negative")
+	}
+}
+
+// CompressionConfig controls response compression
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	MinSizeBytes int      `yaml:"min_size_bytes"` // Responses smaller than this are sent uncompressed
+	Encodings    []string `yaml:"encodings"`      // Supported encodings in order of preference ("zstd", "gzip")
+	ContentTypes []string `yaml:"content_types"`  // Media types that are compressed
 }
 
 // P2PConfig contains P2P node configuration
 type P2PConfig struct {
 	ListenPort  int    `yaml:"listen_port"`
 	KeyFilePath string `yaml:"key_file_path"`
+	// KeyType is the type of identity key generated when key_file_path
+	// holds none: ed25519, secp256k1 or rsa. Existing keys keep their type.
+	KeyType string `yaml:"key_type"`
+
+	// Transports the node dials and listens on: tcp, quic, websocket and
+	// webtransport. Each listens on all interfaces at listen_port unless
+	// listen_addrs gives it multiaddrs.
+	Transports  []string            `yaml:"transports"`
+	ListenAddrs map[string][]string `yaml:"listen_addrs"`
+
+	// Reaching peers beyond the LAN
+	BootstrapPeers []string `yaml:"bootstrap_peers"` // /p2p/ multiaddrs to join the network through (empty = public IPFS bootstrap peers)
+	StaticRelays   []string `yaml:"static_relays"`   // Circuit relay v2 relays to reserve a slot on when behind NAT
+	HolePunching   bool     `yaml:"hole_punching"`   // Upgrade relayed connections to direct ones
+	RelayService   bool     `yaml:"relay_service"`   // Relay connections for other agents; for publicly reachable agents
+
+	// ServeComputations takes computation requests over the
+	// /pandacea/compute/1.0.0 stream protocol as well as over HTTP, so
+	// spenders can reach an agent whose HTTP port is behind NAT
+	ServeComputations bool `yaml:"serve_computations"`
+
+	// Peer reputation. Offenses lower a peer's score, which recovers
+	// toward 0; peers below min_score are refused until it does.
+	MinPeerScore         float64  `yaml:"min_peer_score"`
+	ScoreHalfLifeMinutes int      `yaml:"score_half_life_minutes"` // Time for a penalty to halve
+	AllowPeers           []string `yaml:"allow_peers"`             // Peer IDs that are never refused
+	DenyPeers            []string `yaml:"deny_peers"`              // Peer IDs that are always refused
+	PeersPath            string   `yaml:"peers_path"`              // Persisted lists and scores (empty keeps them in memory only)
+}
+
+// validate checks the key type, transports and peer reputation settings
+func (p P2PConfig) validate(errs *problems) {
+	switch p.KeyType {
+	case "ed25519", "secp256k1", "rsa":
+	default:
+		errs.add("p2p.key_type", "unknown key type %q", p.KeyType)
+	}
+	transports := make(map[string]bool, len(p.Transports))
+	for _, transport := range p.Transports {
+		switch transport {
+		case "tcp", "quic", "websocket", "webtransport":
+			transports[transport] = true
+		default:
+			errs.add("p2p.transports", "unknown transport %q", transport)
+		}
+	}
+	for _, transport := range sortedKeys(p.ListenAddrs) {
+		if !transports[transport] {
+			errs.add("p2p.listen_addrs", "transport %q is not in p2p.transports", transport)
+		}
+	}
+	if p.MinPeerScore >= 0 {
+		errs.add("p2p.min_peer_score", "%v must be negative", p.MinPeerScore)
+	}
+	if p.ScoreHalfLifeMinutes <= 0 {
+		errs.add("p2p.score_half_life_minutes", "must be positive")
+	}
 }
 
 // BlockchainConfig contains blockchain configuration
 type BlockchainConfig struct {
 	RPCURL          string `yaml:"rpc_url"`
 	ContractAddress string `yaml:"contract_address"`
+	ChainID         uint64 `yaml:"chain_id"` // Chain ID rpc_url must report (0 = not checked)
+
+	// Further LeaseAgreement deployments. The rpc_url and contract_address
+	// above, when both set, form the network named "default".
+	Networks        []NetworkConfig   `yaml:"networks"`
+	DefaultNetwork  string            `yaml:"default_network"`  // Network used when a request names none (empty = first)
+	ProductNetworks map[string]string `yaml:"product_networks"` // Data product ID to the network its leases live on
+
+	// Event listener settings
+	StartBlock       uint64 `yaml:"start_block"`         // First block to replay on startup (0 = start at head)
+	MaxLagBlocks     uint64 `yaml:"max_lag_blocks"`      // Lag beyond which readiness reports degraded
+	HeadPollSeconds  int    `yaml:"head_poll_seconds"`   // How often to poll the chain head
+	CatchUpBatchSize uint64 `yaml:"catch_up_batch_size"` // Blocks per log query while catching up
+	EventWorkers     int    `yaml:"event_workers"`       // Workers handling live events in parallel
+	EventQueueSize   int    `yaml:"event_queue_size"`    // Events queued per worker before parking
+	EventMaxParked   int    `yaml:"event_max_parked"`    // Events parked per worker before dropping
 }
 
 // IPFSConfig contains IPFS configuration
@@ -47,8 +235,963 @@ type IPFSConfig struct {
 	APIURL string `yaml:"api_url"`
 }
 
-// Load loads configuration from file and environment variables
-func Load(configPath string) (*Config, error) {
+// PolicyConfig selects the lease policy engine
+type PolicyConfig struct {
+	Engine   string `yaml:"engine"`    // "static" (default) or "rego"
+	RegoPath string `yaml:"rego_path"` // .rego/.json file or directory of policies
+	Bundle   string `yaml:"bundle"`    // OPA bundle directory or .tar.gz
+	Query    string `yaml:"query"`     // Rego query that yields the decision
+
+	// Shadow runs a second policy configuration alongside this one
+	Shadow *ShadowPolicyConfig `yaml:"shadow"`
+}
+
+// ShadowPolicyConfig is a candidate policy configuration that is evaluated
+// for every lease request without affecting the decision, so its decisions
+// can be compared with the live engine's before it is rolled out
+type ShadowPolicyConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Engine   string `yaml:"engine"`
+	RegoPath string `yaml:"rego_path"`
+	Bundle   string `yaml:"bundle"`
+	Query    string `yaml:"query"`
+
+	// Server overrides economic parameters of the live server config, such
+	// as min_price or max_lease_duration. Unset keys keep the live values.
+	Server yaml.Node `yaml:"server"`
+}
+
+// PolicyConfig returns the shadow's engine selection as a PolicyConfig
+func (s *ShadowPolicyConfig) PolicyConfig() PolicyConfig {
+	return PolicyConfig{Engine: s.Engine, RegoPath: s.RegoPath, Bundle: s.Bundle, Query: s.Query}
+}
+
+// ServerConfig returns live with the shadow's overrides applied
+func (s *ShadowPolicyConfig) ServerConfig(live ServerConfig) (ServerConfig, error) {
+	shadow, err := overrideServer(live, s.Server)
+	if err != nil {
+		return ServerConfig{}, fmt.Errorf("invalid shadow server overrides: %w", err)
+	}
+	return shadow, nil
+}
+
+// overrideServer returns live with the server settings in overrides applied
+func overrideServer(live ServerConfig, overrides yaml.Node) (ServerConfig, error) {
+	out := live
+	// Copy the map so overrides do not leak into the live config
+	out.ProductMaxLeaseDurations = make(map[string]string, len(live.ProductMaxLeaseDurations))
+	for productID, duration := range live.ProductMaxLeaseDurations {
+		out.ProductMaxLeaseDurations[productID] = duration
+	}
+	if overrides.Kind == 0 {
+		return out, nil
+	}
+	if err := overrides.Decode(&out); err != nil {
+		return ServerConfig{}, err
+	}
+	return out, nil
+}
+
+// TenantConfig is one earner whose data products this agent hosts. Leases
+// of products in the tenant's namespaces pay its earner address and are
+// evaluated with its policy parameters.
+type TenantConfig struct {
+	ID         string   `yaml:"id"`
+	Earner     string   `yaml:"earner"`     // Earner address the tenant's leases pay
+	Namespaces []string `yaml:"namespaces"` // Product ID prefixes, such as did:pandacea:mainnet:alice
+
+	// Server overrides economic parameters of the server config, such as
+	// min_price or max_lease_duration, for the tenant's products. Unset
+	// keys keep the server's values.
+	Server yaml.Node `yaml:"server"`
+
+	// MaxActiveLeases caps the tenant's pending and approved leases (0 is
+	// no cap)
+	MaxActiveLeases int `yaml:"max_active_leases"`
+}
+
+// ServerConfig returns live with the tenant's overrides applied
+func (t *TenantConfig) ServerConfig(live ServerConfig) (ServerConfig, error) {
+	out, err := overrideServer(live, t.Server)
+	if err != nil {
+		return ServerConfig{}, fmt.Errorf("invalid server overrides for tenant %s: %w", t.ID, err)
+	}
+	return out, nil
+}
+
+// PricingConfig controls dynamic minimum pricing
+type PricingConfig struct {
+	RefreshSeconds      int     `yaml:"refresh_seconds"`       // How often to read MIN_PRICE from the contract
+	DemandWindowSeconds int     `yaml:"demand_window_seconds"` // Window over which lease requests are counted
+	DemandThreshold     int     `yaml:"demand_threshold"`      // Requests per window before the floor rises (0 disables)
+	DemandStep          float64 `yaml:"demand_step"`           // Floor increase per request above the threshold
+	MaxMultiplier       float64 `yaml:"max_multiplier"`        // Cap on the demand multiplier
+}
+
+// Training execution modes
+const (
+	ExecutionModeMock   = "mock"   // Synthetic results, no PySyft
+	ExecutionModeLocal  = "local"  // PySyft worker run with the local Python
+	ExecutionModeDocker = "docker" // PySyft worker run in the pysyft container
+	ExecutionModeRemote = "remote" // Jobs sent to a training service over HTTP
+)
+
+// TrainingConfig controls how training jobs run. The execution mode picks
+// the backend; the other fields configure the backend of the same name and
+// fall back to the defaults noted when empty.
+type TrainingConfig struct {
+	ExecutionMode  string               `yaml:"execution_mode"`  // mock, local, docker or remote; the default comes from the profile
+	Python         string               `yaml:"python"`          // local: interpreter the worker runs with (python)
+	Worker         string               `yaml:"worker"`          // local: worker script (./worker/train_worker.py)
+	ComposeFile    string               `yaml:"compose_file"`    // docker: compose file (docker-compose.pysyft.yml)
+	ComposeService string               `yaml:"compose_service"` // docker: worker service (pysyft-worker)
+	Remote         RemoteTrainingConfig `yaml:"remote"`
+}
+
+// RemoteTrainingConfig points the remote backend at a training service.
+// Its bearer token is read from the PANDACEA_TRAINING_TOKEN environment
+// variable.
+type RemoteTrainingConfig struct {
+	URL            string `yaml:"url"`             // Base URL; jobs are POSTed to <url>/train
+	TimeoutMinutes int    `yaml:"timeout_minutes"` // Longest a job may take (0 waits as long as the job may run)
+	Token          string `yaml:"-"`
+}
+
+// validate checks the execution mode and what it needs
+func (t TrainingConfig) validate(errs *problems) {
+	switch t.ExecutionMode {
+	case ExecutionModeMock, ExecutionModeLocal, ExecutionModeDocker:
+	case ExecutionModeRemote:
+		if u, err := url.Parse(t.Remote.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("training.remote.url", "%q is not an http:// or https:// URL", t.Remote.URL)
+		}
+	default:
+		errs.add("training.execution_mode", "unknown mode %q (want %s, %s, %s or %s)",
+			t.ExecutionMode, ExecutionModeMock, ExecutionModeLocal, ExecutionModeDocker, ExecutionModeRemote)
+	}
+	if t.Remote.TimeoutMinutes < 0 {
+		errs.add("training.remote.timeout_minutes", "must not be negative")
+	}
+}
+
+// WatermarkConfig controls leak-tracing watermarks on computation results
+// and training artifacts
+type WatermarkConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	KeyFile string `yaml:"key_file"` // Secret watermark key; generated on first start if missing
+}
+
+// VerificationConfig controls re-execution of completed computations to
+// check that their results are reproducible. Every computation runs with a
+// seed pinned from its ID, so a re-execution with the same inputs should
+// produce the same output and artifacts.
+type VerificationConfig struct {
+	Fraction float64 `yaml:"fraction"` // Share of completed computations re-executed, from 0 (off) to 1
+	Escalate bool    `yaml:"escalate"` // Raise a dispute against the lease of a computation whose result differs
+}
+
+// validate checks the re-executed fraction
+func (v VerificationConfig) validate(errs *problems) {
+	if v.Fraction < 0 || v.Fraction > 1 {
+		errs.add("verification.fraction", "%g is not between 0 and 1", v.Fraction)
+	}
+}
+
+// AttestationConfig controls signed attestations of what each computation
+// ran: its script, inputs and sandbox image, and a hash of its result
+type AttestationConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	RecordsPath   string `yaml:"records_path"`   // Persisted attestations (empty keeps them in memory only)
+	Anchor        bool   `yaml:"anchor"`         // Also send each attestation's hash on-chain; needs transactions.key_file
+	AnchorAddress string `yaml:"anchor_address"` // Address the anchoring transactions are sent to (empty = the agent's own)
+}
+
+// validate checks the anchor address
+func (a AttestationConfig) validate(errs *problems) {
+	if a.AnchorAddress != "" && !common.IsHexAddress(a.AnchorAddress) {
+		errs.add("attestation.anchor_address", "%q is not a hex address", a.AnchorAddress)
+	}
+}
+
+// AuditConfig controls the persistent audit journal
+type AuditConfig struct {
+	JournalPath string `yaml:"journal_path"` // Append-only, hash-chained audit file (empty keeps the audit log in memory only)
+}
+
+// PrivacyConfig caps the cumulative differential privacy budget that
+// training jobs may spend on each dataset
+type PrivacyConfig struct {
+	DefaultEpsilon float64            `yaml:"default_epsilon"` // Budget for datasets without an override (0 = tracked but not capped)
+	DatasetEpsilon map[string]float64 `yaml:"dataset_epsilon"` // Per-dataset budgets
+	LedgerPath     string             `yaml:"ledger_path"`     // Persisted epsilon ledger (empty keeps it in memory only)
+}
+
+// EarningsConfig controls the earnings ledger
+type EarningsConfig struct {
+	LedgerPath string `yaml:"ledger_path"` // Persisted lease payouts (empty keeps them in memory only)
+}
+
+// DisputesConfig controls the off-chain dispute records
+type DisputesConfig struct {
+	RecordsPath string `yaml:"records_path"` // Persisted dispute records and their evidence CIDs (empty keeps them in memory only)
+}
+
+// UsageConfig controls per-identity usage accounting
+type UsageConfig struct {
+	RecordsPath string `yaml:"records_path"` // Persisted daily and monthly usage (empty keeps it in memory only)
+	SaveSeconds int    `yaml:"save_seconds"` // How often usage is saved
+}
+
+// ModelsConfig controls the registry of models completed training jobs
+// produced
+type ModelsConfig struct {
+	RegistryPath string `yaml:"registry_path"` // Persisted models, stages and tags (empty keeps them in memory only)
+}
+
+// LineageConfig controls the graph of which products, assets and leases
+// computations, training jobs and models were derived from
+type LineageConfig struct {
+	GraphPath string `yaml:"graph_path"` // Persisted lineage edges (empty keeps them in memory only)
+}
+
+// ConsentConfig controls the purpose leases are granted for and the
+// consent receipts issued when they are approved
+type ConsentConfig struct {
+	RequirePurpose bool   `yaml:"require_purpose"` // Reject lease requests that declare no purpose category
+	Jurisdiction   string `yaml:"jurisdiction"`    // Jurisdiction stated on receipts, e.g. "EU"
+	PolicyURL      string `yaml:"policy_url"`      // Privacy policy receipts link to
+	Language       string `yaml:"language"`        // Language of receipts
+}
+
+// validate checks the policy URL
+func (c ConsentConfig) validate(errs *problems) {
+	if c.PolicyURL == "" {
+		return
+	}
+	if u, err := url.Parse(c.PolicyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("consent.policy_url", "%q is not an http:// or https:// URL", c.PolicyURL)
+	}
+}
+
+// MeteringConfig prices what training jobs and computations consume and
+// books each job's bill against its lease. Prices are in wei per unit; an
+// empty price is free.
+type MeteringConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	CPUSecondPrice    string `yaml:"cpu_second_price"`     // Per second of CPU time
+	MemoryGBHourPrice string `yaml:"memory_gb_hour_price"` // Per GiB of memory held for an hour
+	WallSecondPrice   string `yaml:"wall_second_price"`    // Per second from start to finish
+	LedgerPath        string `yaml:"ledger_path"`          // Persisted bills by lease (empty keeps them in memory only)
+}
+
+// validate checks that the prices are amounts in wei
+func (m MeteringConfig) validate(errs *problems) {
+	for _, rate := range []struct{ name, price string }{
+		{"cpu_second_price", m.CPUSecondPrice},
+		{"memory_gb_hour_price", m.MemoryGBHourPrice},
+		{"wall_second_price", m.WallSecondPrice},
+	} {
+		if rate.price == "" {
+			continue
+		}
+		if p, err := decimal.NewFromString(rate.price); err != nil || p.IsNegative() {
+			errs.add("metering."+rate.name, "%q is not a non-negative price in wei", rate.price)
+		}
+	}
+}
+
+// DeliveryConfig controls how leased data products reach their spenders
+type DeliveryConfig struct {
+	Sources     map[string]string `yaml:"sources"`      // Product ID to ipfs://<cid> or a file pinned on delivery (empty = executions are admin-only)
+	WaitSeconds int               `yaml:"wait_seconds"` // How long an execute request waits for executeLease to confirm
+	RecordsPath string            `yaml:"records_path"` // Persisted deliveries (empty keeps them in memory only)
+}
+
+// validate checks every delivery source names a CID or a file
+func (d DeliveryConfig) validate(errs *problems) {
+	for _, product := range sortedKeys(d.Sources) {
+		if source := d.Sources[product]; source == "" || source == "ipfs://" {
+			errs.add("delivery.sources."+product, "must be ipfs://<cid> or a file path")
+		}
+	}
+	if len(d.Sources) > 0 && d.WaitSeconds <= 0 {
+		errs.add("delivery.wait_seconds", "must be positive")
+	}
+}
+
+// EncryptionConfig controls encryption at rest of stored assets and
+// training artifacts. The keyring holding the master keys is read from the
+// PANDACEA_ATREST_KEYRING environment variable if it is set, as secrets
+// managers inject it, and from KeyringFile otherwise.
+type EncryptionConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	KeyringFile string   `yaml:"keyring_file"` // Master keys; generated on first start if missing
+	Dirs        []string `yaml:"dirs"`         // Directories agent encryption encrypt and rotate cover, besides registered assets
+}
+
+// validate checks the keyring has somewhere to live
+func (e EncryptionConfig) validate(errs *problems) {
+	if e.Enabled && e.KeyringFile == "" {
+		errs.add("encryption.keyring_file", "is required when encryption is enabled")
+	}
+}
+
+// RetentionConfig bounds how many finished training jobs and computations
+// the agent keeps, and for how long, and the disk space training artifacts
+// under data/products may take. A zero limit is off.
+type RetentionConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	MaxAgeHours     int  `yaml:"max_age_hours"`    // Finished jobs are dropped this long after they finish
+	MaxTotalMB      int  `yaml:"max_total_mb"`     // Oldest artifacts are deleted until they fit
+	MaxJobs         int  `yaml:"max_jobs"`         // Finished jobs kept of each kind, newest first
+	IntervalMinutes int  `yaml:"interval_minutes"` // How often retention is applied
+}
+
+// validate checks the limits and the collection interval
+func (r RetentionConfig) validate(errs *problems) {
+	if r.MaxAgeHours < 0 || r.MaxTotalMB < 0 || r.MaxJobs < 0 {
+		errs.add("retention", "max_age_hours, max_total_mb and max_jobs must not be negative")
+	}
+	if r.Enabled && r.IntervalMinutes <= 0 {
+		errs.add("retention.interval_minutes", "must be positive")
+	}
+}
+
+// PublishConfig controls publishing training artifacts and computation
+// results to IPFS, pinned on the node at ipfs.api_url or through a remote
+// pinning service. The remote service's access token is read from the
+// PANDACEA_PINNING_TOKEN environment variable.
+type PublishConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Training     bool   `yaml:"training"`     // Publish training artifacts as their job completes
+	Computations bool   `yaml:"computations"` // Publish sealed computation results as they are first read
+	Service      string `yaml:"service"`      // local or remote
+	RemoteURL    string `yaml:"remote_url"`   // Pinning Service API endpoint, e.g. https://api.pinata.cloud/psa
+	PinsPath     string `yaml:"pins_path"`    // Persisted pins (empty keeps them in memory only)
+	PollSeconds  int    `yaml:"poll_seconds"` // How often the status of unfinished pins is refreshed
+}
+
+// validate checks the pinning service of enabled publishing
+func (p PublishConfig) validate(errs *problems) {
+	if !p.Enabled {
+		return
+	}
+	switch p.Service {
+	case "local":
+	case "remote":
+		if p.RemoteURL == "" {
+			errs.add("publish.remote_url", "is required for a remote pinning service")
+		}
+	default:
+		errs.add("publish.service", "%q is not local or remote", p.Service)
+	}
+	if p.PollSeconds <= 0 {
+		errs.add("publish.poll_seconds", "must be positive")
+	}
+}
+
+// AssetsConfig controls the registry of files behind data products
+type AssetsConfig struct {
+	Enabled      bool               `yaml:"enabled"`       // Mount computation inputs from registered assets only
+	RegistryPath string             `yaml:"registry_path"` // Persisted registry (empty keeps it in memory only)
+	S3           S3Config           `yaml:"s3"`
+	Preview      PreviewConfig      `yaml:"preview"`
+	Profile      AssetProfileConfig `yaml:"profile"`
+}
+
+// S3Config points s3://<bucket>/<key> asset sources at an S3-compatible
+// object store. Its credentials are read from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables;
+// without them requests are sent unsigned.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // Base URL of the store (empty uses AWS S3 in region)
+	Region    string `yaml:"region"`     // Region requests are signed for
+	PathStyle bool   `yaml:"path_style"` // Address buckets as <endpoint>/<bucket>, as MinIO expects
+}
+
+// PreviewConfig controls the samples of registered assets spenders can
+// inspect before leasing
+type PreviewConfig struct {
+	Enabled           bool                `yaml:"enabled"`
+	Rows              int                 `yaml:"rows"`                // Rows per preview
+	Mode              string              `yaml:"mode"`                // head (first rows) or synthetic (each column sampled independently)
+	SuppressColumns   map[string][]string `yaml:"suppress_columns"`    // Asset ID to columns never shown
+	CacheSeconds      int                 `yaml:"cache_seconds"`       // How long a generated preview is reused
+	RequestsPerMinute int                 `yaml:"requests_per_minute"` // Previews each caller may fetch
+}
+
+// AssetProfileConfig controls the column statistics of registered assets
+// spenders can inspect before leasing. Profiles are computed in the sandbox.
+type AssetProfileConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	Bins           int  `yaml:"bins"`            // Histogram bins per numeric column
+	TopValues      int  `yaml:"top_values"`      // Most common values listed per other column
+	MinCount       int  `yaml:"min_count"`       // Bins and values counting fewer rows are withheld
+	CacheSeconds   int  `yaml:"cache_seconds"`   // How long a profile is served before it is computed again
+	TimeoutSeconds int  `yaml:"timeout_seconds"` // Longest a profiling job may run
+}
+
+// maxPreviewRows bounds how much of an asset a preview can reveal
+const maxPreviewRows = 100
+
+// maxProfileBuckets bounds the histogram bins and common values a profile
+// lists per column
+const maxProfileBuckets = 100
+
+// validate checks the S3 store and the preview and profile settings of
+// whichever are enabled
+func (a AssetsConfig) validate(errs *problems) {
+	if a.S3.Endpoint != "" {
+		if u, err := url.Parse(a.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("assets.s3.endpoint", "%q is not an http:// or https:// URL", a.S3.Endpoint)
+		}
+	}
+	if a.S3.Region == "" {
+		errs.add("assets.s3.region", "is required")
+	}
+
+	if p := a.Preview; p.Enabled {
+		if !a.Enabled {
+			errs.add("assets.preview.enabled", "previews require assets.enabled")
+		}
+		if p.Rows < 1 || p.Rows > maxPreviewRows {
+			errs.add("assets.preview.rows", "must be between 1 and %d", maxPreviewRows)
+		}
+		if p.Mode != "head" && p.Mode != "synthetic" {
+			errs.add("assets.preview.mode", "must be head or synthetic, got %q", p.Mode)
+		}
+		if p.CacheSeconds < 0 {
+			errs.add("assets.preview.cache_seconds", "must not be negative")
+		}
+		if p.RequestsPerMinute <= 0 {
+			errs.add("assets.preview.requests_per_minute", "must be positive")
+		}
+	}
+
+	if p := a.Profile; p.Enabled {
+		if !a.Enabled {
+			errs.add("assets.profile.enabled", "profiles require assets.enabled")
+		}
+		if p.Bins < 1 || p.Bins > maxProfileBuckets {
+			errs.add("assets.profile.bins", "must be between 1 and %d", maxProfileBuckets)
+		}
+		if p.TopValues < 0 || p.TopValues > maxProfileBuckets {
+			errs.add("assets.profile.top_values", "must be between 0 and %d", maxProfileBuckets)
+		}
+		if p.MinCount < 1 {
+			errs.add("assets.profile.min_count", "must be at least 1")
+		}
+		if p.CacheSeconds < 0 {
+			errs.add("assets.profile.cache_seconds", "must not be negative")
+		}
+		if p.TimeoutSeconds <= 0 {
+			errs.add("assets.profile.timeout_seconds", "must be positive")
+		}
+	}
+}
+
+// TransactionsConfig controls the transactions the agent sends itself on
+// the default network
+type TransactionsConfig struct {
+	KeyFile       string `yaml:"key_file"`      // Hex secp256k1 key transactions are signed with (empty = sending disabled)
+	Confirmations uint64 `yaml:"confirmations"` // Blocks, counting the one it is in, before a transaction is final
+	PollSeconds   int    `yaml:"poll_seconds"`  // How often pending transactions are checked
+	StallSeconds  int    `yaml:"stall_seconds"` // How long a transaction may go unmined before its fees are bumped
+	BumpPercent   int    `yaml:"bump_percent"`  // Fee increase per bump; nodes require at least 10
+	MaxFeeGwei    string `yaml:"max_fee_gwei"`  // Highest fee cap per gas (empty = unbounded)
+	QueueSize     int    `yaml:"queue_size"`    // Requests waiting to be sent
+	RecordsPath   string `yaml:"records_path"`  // Persisted transaction records (empty keeps them in memory only)
+}
+
+// validate checks the fee and polling settings
+func (t TransactionsConfig) validate(errs *problems) {
+	if t.BumpPercent < 10 {
+		errs.add("transactions.bump_percent", "%d is below the 10 nodes require", t.BumpPercent)
+	}
+	if t.MaxFeeGwei != "" {
+		if fee, err := decimal.NewFromString(t.MaxFeeGwei); err != nil || !fee.IsPositive() {
+			errs.add("transactions.max_fee_gwei", "%q is not a positive amount in gwei", t.MaxFeeGwei)
+		}
+	}
+	if t.KeyFile != "" && (t.PollSeconds <= 0 || t.StallSeconds <= 0 || t.QueueSize <= 0) {
+		errs.add("transactions", "poll_seconds, stall_seconds and queue_size must be positive")
+	}
+}
+
+// IncidentConfig controls operator incident response
+type IncidentConfig struct {
+	QuarantinePath string `yaml:"quarantine_path"` // Persisted product quarantines (empty keeps them in memory only)
+
+	// RevocationsPath persists revoked peer IDs and spender addresses
+	// (empty keeps them in memory only). RevocationIssuers are the peer IDs
+	// of agents whose revocations, gossiped over P2P, are applied here.
+	RevocationsPath       string   `yaml:"revocations_path"`
+	RevocationIssuers     []string `yaml:"revocation_issuers"`
+	GossipRevocations     bool     `yaml:"gossip_revocations"`      // Exchange revocations with connected peers
+	GossipIntervalMinutes int      `yaml:"gossip_interval_minutes"` // How often held revocations are pushed to connected peers again
+}
+
+// validate checks revocations have a gossip interval
+func (i IncidentConfig) validate(errs *problems) {
+	if i.GossipRevocations && i.GossipIntervalMinutes <= 0 {
+		errs.add("incident.gossip_interval_minutes", "%d must be positive", i.GossipIntervalMinutes)
+	}
+}
+
+// RemoteConfig sources products.json and security.yaml from HTTPS URLs or
+// ipfs:// CIDs. Files are only applied if signed by one of SignerKeys.
+type RemoteConfig struct {
+	ProductsURL          string   `yaml:"products_url"`
+	ProductsSignatureURL string   `yaml:"products_signature_url"` // Defaults to products_url + ".sig" for HTTPS
+	SecurityURL          string   `yaml:"security_url"`
+	SecuritySignatureURL string   `yaml:"security_signature_url"` // Defaults to security_url + ".sig" for HTTPS
+	SignerKeys           []string `yaml:"signer_keys"`            // Base64 libp2p public keys trusted to sign
+	RefreshSeconds       int      `yaml:"refresh_seconds"`        // How often remote files are re-fetched
+}
+
+// CatalogConfig controls verification of the local product catalog against
+// its detached signature, products.json.sig. The agent's own identity key
+// is always trusted to sign it.
+type CatalogConfig struct {
+	Path          string   `yaml:"path"`           // products.json (empty searches the working directory and its parents)
+	Verify        bool     `yaml:"verify"`         // Check the signature on load and reload
+	Strict        bool     `yaml:"strict"`         // Refuse to serve a catalog that is unsigned or fails verification
+	SignerKeys    []string `yaml:"signer_keys"`    // Base64 libp2p public keys also trusted to sign
+	SignerWallets []string `yaml:"signer_wallets"` // Ethereum addresses, such as the earner's, trusted to sign with personal_sign
+}
+
+// validate checks strict mode has signatures to check and the signer
+// wallets are addresses
+func (c CatalogConfig) validate(errs *problems) {
+	if c.Strict && !c.Verify {
+		errs.add("catalog.strict", "requires catalog.verify")
+	}
+	for i, wallet := range c.SignerWallets {
+		if !common.IsHexAddress(wallet) {
+			errs.add(fmt.Sprintf("catalog.signer_wallets[%d]", i), "%q is not an address", wallet)
+		}
+	}
+}
+
+// FederationConfig enables multi-round federated training across agents.
+// A coordinator orchestrates rounds on earner agents over P2P; a participant
+// trains rounds for the coordinators it allows.
+type FederationConfig struct {
+	Coordinator         bool     `yaml:"coordinator"`           // Accept federated training jobs and orchestrate their rounds
+	Participant         bool     `yaml:"participant"`           // Train rounds for allowed coordinators
+	AllowedCoordinators []string `yaml:"allowed_coordinators"`  // Peer IDs that may request rounds from this agent
+	RoundTimeoutSeconds int      `yaml:"round_timeout_seconds"` // How long a round waits for participant updates
+	MaxRounds           int      `yaml:"max_rounds"`            // Upper bound on rounds per federated job
+	RequireSecure       bool     `yaml:"require_secure"`        // Only train rounds whose updates are securely aggregated
+
+	// Participants announce their capabilities to allowed coordinators
+	// every heartbeat_seconds. Coordinators count a participant available
+	// for participant_ttl_seconds after its last heartbeat and select
+	// available ones for jobs that name no participants, skipping those
+	// whose peer score is below min_participant_reputation.
+	HeartbeatSeconds         int     `yaml:"heartbeat_seconds"`
+	ParticipantTTLSeconds    int     `yaml:"participant_ttl_seconds"`
+	MinParticipantReputation float64 `yaml:"min_participant_reputation"`
+}
+
+// SchedulerConfig bounds how many training and computation jobs run at
+// once and how the queue behind them is shared. Jobs on leases priced at or
+// above high_priority_price run as high priority, at or above
+// normal_priority_price as normal, and the rest as low. Prices are in wei;
+// an empty threshold is never met, except that with no normal threshold
+// every leased job is at least normal.
+type SchedulerConfig struct {
+	Workers              int    `yaml:"workers"`                 // Jobs run at once
+	MaxQueued            int    `yaml:"max_queued"`              // Jobs waiting for a worker before new ones are rejected
+	MaxQueuedPerIdentity int    `yaml:"max_queued_per_identity"` // Jobs one spender or coordinator may have waiting
+	HighPriorityPrice    string `yaml:"high_priority_price"`     // Lease price for high priority
+	NormalPriorityPrice  string `yaml:"normal_priority_price"`   // Lease price for normal priority
+}
+
+// GPUConfig lets training jobs and computations request NVIDIA GPUs. GPU
+// jobs wait in a queue of their own, separate from the scheduler's, and
+// each holds the GPUs it requested while it runs. GPUs are only offered if
+// nvidia-smi finds some and Docker has the NVIDIA container runtime.
+type GPUConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	Workers       int  `yaml:"workers"`        // GPU jobs run at once
+	MaxQueued     int  `yaml:"max_queued"`     // GPU jobs waiting before new ones are rejected
+	SampleSeconds int  `yaml:"sample_seconds"` // How often GPU utilization is sampled
+}
+
+// PoolConfig sizes the computation container pool. With autoscale set to
+// hint or auto, the agent forecasts load from its history per hour of the
+// week and recommends a size between min_size and max_size; auto applies it.
+type PoolConfig struct {
+	Size            int           `yaml:"size"`             // Containers started with the agent
+	Autoscale       string        `yaml:"autoscale"`        // off, hint or auto
+	MinSize         int           `yaml:"min_size"`         // Smallest recommended size
+	MaxSize         int           `yaml:"max_size"`         // Largest recommended size
+	LeadMinutes     int           `yaml:"lead_minutes"`     // How far ahead to provision for forecast peaks
+	Headroom        float64       `yaml:"headroom"`         // Multiplier on the containers the forecast needs
+	IntervalSeconds int           `yaml:"interval_seconds"` // How often to forecast
+	HistoryPath     string        `yaml:"history_path"`     // Persisted load history (empty keeps it in memory only)
+	Sandbox         SandboxConfig `yaml:"sandbox"`
+}
+
+// SandboxConfig selects what computations run in, trading isolation
+// against start-up time and overhead
+type SandboxConfig struct {
+	Backend     string            `yaml:"backend"`      // docker, gvisor, firecracker or wasm
+	Image       string            `yaml:"image"`        // OCI image for docker, gvisor and firecracker (empty uses the PySyft datasite image)
+	MemoryMB    int               `yaml:"memory_mb"`    // Memory per container or microVM
+	CPUs        float64           `yaml:"cpus"`         // CPUs per container; microVMs round up to whole vCPUs
+	KernelImage string            `yaml:"kernel_image"` // Firecracker kernel image (empty uses ignite's default)
+	WorkDir     string            `yaml:"work_dir"`     // Where WASM sandboxes keep their files
+	Modules     map[string]string `yaml:"modules"`      // WASM only: command, e.g. python, to the WASI module run for it
+	Egress      EgressConfig      `yaml:"egress"`
+	ImagePolicy ImagePolicyConfig `yaml:"image_policy"`
+}
+
+// ImagePolicyConfig pins the image containers run to allowed digests.
+// The image pulled locally is checked before the pool is created, and the
+// agent refuses to start the pool if its digest, or with a cosign key its
+// signature, does not match.
+type ImagePolicyConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Allowed   []string `yaml:"allowed"`    // Images as repository@sha256:digest
+	CosignKey string   `yaml:"cosign_key"` // Public key the image must be signed with; empty skips signature checks
+	Cosign    string   `yaml:"cosign"`     // cosign binary
+}
+
+// EgressConfig lets computations on chosen products reach allowlisted
+// hosts, such as a model registry, through a proxy the agent runs.
+// Containers stay on no network unless every product a computation reads
+// allowlists a host; then they join network, on which the proxy is all
+// they can reach.
+type EgressConfig struct {
+	Enabled  bool                `yaml:"enabled"`
+	Network  string              `yaml:"network"`   // Internal Docker network (docker network create --internal)
+	Listen   string              `yaml:"listen"`    // Address the proxy listens on, on that network's gateway
+	ProxyURL string              `yaml:"proxy_url"` // The proxy as containers reach it, e.g. http://172.30.0.1:3128
+	Products map[string][]string `yaml:"products"`  // Product ID to hosts or *.domain patterns its computations may reach
+}
+
+// validate checks the backend and what it needs
+func (s SandboxConfig) validate(errs *problems) {
+	switch s.Backend {
+	case "docker", "gvisor":
+	case "firecracker":
+		errs.add("container_pool.sandbox.backend", "firecracker cannot isolate computations from the network: ignite attaches every microVM to its CNI bridge")
+	case "wasm":
+		if len(s.Modules) == 0 {
+			errs.add("container_pool.sandbox.modules", "the wasm backend needs a module for python")
+		}
+	default:
+		errs.add("container_pool.sandbox.backend", "unknown backend %q (want docker, gvisor, firecracker or wasm)", s.Backend)
+	}
+	for _, command := range sortedKeys(s.Modules) {
+		if s.Modules[command] == "" {
+			errs.add("container_pool.sandbox.modules."+command, "must be a .wasm file")
+		}
+	}
+	if s.MemoryMB <= 0 || s.CPUs <= 0 {
+		errs.add("container_pool.sandbox", "memory_mb and cpus must be positive")
+	}
+	if s.Egress.Enabled {
+		s.Egress.validate(s.Backend, errs)
+	}
+	if s.ImagePolicy.Enabled {
+		s.ImagePolicy.validate(s.Backend, errs)
+	}
+}
+
+// validate checks the allowed images are pinned by digest. Only images
+// Docker pulls can be checked.
+func (p ImagePolicyConfig) validate(backend string, errs *problems) {
+	if backend != "docker" && backend != "gvisor" {
+		errs.add("container_pool.sandbox.image_policy.enabled", "the %s backend cannot check image digests (want docker or gvisor)", backend)
+	}
+	if len(p.Allowed) == 0 {
+		errs.add("container_pool.sandbox.image_policy.allowed", "needs at least one image")
+	}
+	for i, ref := range p.Allowed {
+		if err := privacy.ValidatePinnedImage(ref); err != nil {
+			errs.add(fmt.Sprintf("container_pool.sandbox.image_policy.allowed[%d]", i), "%v", err)
+		}
+	}
+}
+
+// validate checks the egress proxy's addresses and the allowlisted hosts.
+// Only Docker containers can join the egress network.
+func (e EgressConfig) validate(backend string, errs *problems) {
+	if backend != "docker" && backend != "gvisor" {
+		errs.add("container_pool.sandbox.egress.enabled", "the %s backend cannot reach the egress proxy (want docker or gvisor)", backend)
+	}
+	if e.Network == "" {
+		errs.add("container_pool.sandbox.egress.network", "is required")
+	}
+	if _, _, err := net.SplitHostPort(e.Listen); err != nil {
+		errs.add("container_pool.sandbox.egress.listen", "%q is not host:port", e.Listen)
+	}
+	if u, err := url.Parse(e.ProxyURL); err != nil || u.Scheme != "http" || u.Host == "" {
+		errs.add("container_pool.sandbox.egress.proxy_url", "%q is not an http:// URL", e.ProxyURL)
+	}
+	for _, product := range sortedKeys(e.Products) {
+		for _, host := range e.Products[product] {
+			if !egress.ValidPattern(host) {
+				errs.add("container_pool.sandbox.egress.products."+product, "%q is not a host name or *.domain", host)
+			}
+		}
+	}
+}
+
+// ScriptScanConfig checks computation scripts before they run. Scripts are
+// parsed with the python interpreter on the agent's host, never executed,
+// and refused if they import denied modules, call out of the sandbox or
+// exceed the size and complexity budget. Products can tighten the policy.
+type ScriptScanConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	Python             string `yaml:"python"` // Interpreter used to parse scripts
+	ScriptPolicyConfig `yaml:",inline"`
+	Products           map[string]ScriptPolicyConfig `yaml:"products"` // Product ID to a policy its computations must also meet
+}
+
+// ScriptPolicyConfig is what a computation script may import and how large
+// it may be. subprocess, socket, ctypes and other modules that reach
+// outside the sandbox are always denied.
+type ScriptPolicyConfig struct {
+	DenyModules  []string `yaml:"deny_modules"`  // Also refused, with their submodules
+	AllowModules []string `yaml:"allow_modules"` // If set, the only modules scripts may import
+	MaxBytes     int      `yaml:"max_bytes"`     // Largest script
+	MaxNodes     int      `yaml:"max_nodes"`     // Most Python syntax tree nodes
+	MaxDepth     int      `yaml:"max_depth"`     // Deepest syntax tree nesting
+}
+
+// validate checks the base and product policies
+func (s ScriptScanConfig) validate(errs *problems) {
+	if !s.Enabled {
+		return
+	}
+	if s.MaxBytes <= 0 || s.MaxNodes <= 0 || s.MaxDepth <= 0 {
+		errs.add("script_scan", "max_bytes, max_nodes and max_depth must be positive")
+	}
+	s.ScriptPolicyConfig.validate("script_scan", errs)
+	for _, product := range sortedKeys(s.Products) {
+		s.Products[product].validate("script_scan.products."+product, errs)
+	}
+}
+
+// validate checks a policy's module names and budgets
+func (p ScriptPolicyConfig) validate(field string, errs *problems) {
+	for _, module := range slices.Concat(p.DenyModules, p.AllowModules) {
+		if module == "" || strings.ContainsAny(module, " /\\") || strings.HasPrefix(module, ".") || strings.HasSuffix(module, ".") {
+			errs.add(field, "%q is not a module name", module)
+		}
+	}
+	if p.MaxBytes < 0 || p.MaxNodes < 0 || p.MaxDepth < 0 {
+		errs.add(field, "budgets must not be negative")
+	}
+}
+
+// validate checks the pool size and autoscaling mode and bounds
+func (p PoolConfig) validate(errs *problems) {
+	p.Sandbox.validate(errs)
+	if p.Size < 0 {
+		errs.add("container_pool.size", "%d must not be negative", p.Size)
+	}
+	switch p.Autoscale {
+	case "off":
+		return
+	case "hint", "auto":
+	default:
+		errs.add("container_pool.autoscale", "unknown mode %q (want off, hint or auto)", p.Autoscale)
+	}
+	if p.MinSize < 0 || p.MinSize > p.MaxSize {
+		errs.add("container_pool.min_size", "%d is not between 0 and max_size %d", p.MinSize, p.MaxSize)
+	}
+	if p.IntervalSeconds <= 0 || p.LeadMinutes < 0 || p.Headroom <= 0 {
+		errs.add("container_pool", "interval_seconds and headroom must be positive and lead_minutes must not be negative")
+	}
+}
+
+// validate checks the queue bounds and that the priority thresholds are
+// prices in wei
+func (s SchedulerConfig) validate(errs *problems) {
+	if s.Workers <= 0 {
+		errs.add("scheduler.workers", "%d must be positive", s.Workers)
+	}
+	if s.MaxQueued < 0 || s.MaxQueuedPerIdentity < 0 {
+		errs.add("scheduler", "max_queued and max_queued_per_identity must not be negative")
+	}
+	for _, threshold := range []struct{ name, price string }{
+		{"high_priority_price", s.HighPriorityPrice},
+		{"normal_priority_price", s.NormalPriorityPrice},
+	} {
+		if threshold.price == "" {
+			continue
+		}
+		if p, err := decimal.NewFromString(threshold.price); err != nil || p.IsNegative() {
+			errs.add("scheduler."+threshold.name, "%q is not a non-negative price in wei", threshold.price)
+		}
+	}
+}
+
+// validate checks the GPU queue bounds
+func (g GPUConfig) validate(errs *problems) {
+	if !g.Enabled {
+		return
+	}
+	if g.Workers <= 0 {
+		errs.add("gpu.workers", "%d must be positive", g.Workers)
+	}
+	if g.MaxQueued <= 0 {
+		errs.add("gpu.max_queued", "%d must be positive", g.MaxQueued)
+	}
+	if g.SampleSeconds <= 0 {
+		errs.add("gpu.sample_seconds", "%d must be positive", g.SampleSeconds)
+	}
+}
+
+// MarketConfig lets the agent answer product queries from other agents and
+// search the network's products for spenders. Agents advertise in the DHT
+// that they list products so searches can find them beyond direct peers.
+type MarketConfig struct {
+	Enabled                  bool `yaml:"enabled"`
+	AdvertiseIntervalMinutes int  `yaml:"advertise_interval_minutes"` // How often the DHT record is renewed
+	MaxPeers                 int  `yaml:"max_peers"`                  // Agents queried per search
+	PeerTimeoutSeconds       int  `yaml:"peer_timeout_seconds"`       // How long each agent has to answer
+	CacheTTLSeconds          int  `yaml:"cache_ttl_seconds"`          // How long search results are reused (0 disables caching)
+	// Sent with listings so spender agents can lease them; without api_url
+	// they can find the products but not propose leases on them
+	APIURL        string `yaml:"api_url"`        // Public base URL of this agent's API
+	EarnerAddress string `yaml:"earner_address"` // Address leases on products outside every tenant pay
+}
+
+// validate checks the search bounds of an enabled market
+func (m MarketConfig) validate(errs *problems) {
+	if !m.Enabled {
+		return
+	}
+	if m.AdvertiseIntervalMinutes <= 0 || m.MaxPeers <= 0 || m.PeerTimeoutSeconds <= 0 {
+		errs.add("market", "advertise_interval_minutes, max_peers and peer_timeout_seconds must be positive")
+	}
+	if m.CacheTTLSeconds < 0 {
+		errs.add("market.cache_ttl_seconds", "must not be negative")
+	}
+	if m.APIURL != "" {
+		if u, err := url.Parse(m.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("market.api_url", "%q is not an http or https URL", m.APIURL)
+		}
+	}
+	if m.EarnerAddress != "" && !common.IsHexAddress(m.EarnerAddress) {
+		errs.add("market.earner_address", "%q is not a hex address", m.EarnerAddress)
+	}
+}
+
+// SpenderConfig lets the agent lease products from other agents. It finds
+// their offers through the market, proposes leases to their APIs and pays
+// for the leases with createLease from the transactions account.
+type SpenderConfig struct {
+	Enabled               bool   `yaml:"enabled"`
+	MaxPrice              string `yaml:"max_price"`               // Most one lease may pay, in ether (empty = unbounded)
+	PollSeconds           int    `yaml:"poll_seconds"`            // How often outbound leases are followed up
+	RequestTimeoutSeconds int    `yaml:"request_timeout_seconds"` // How long an earner agent has to answer
+	RecordsPath           string `yaml:"records_path"`            // Persisted outbound leases (empty keeps them in memory only)
+}
+
+// validate checks the price cap and polling of an enabled spender
+func (s SpenderConfig) validate(errs *problems) {
+	if !s.Enabled {
+		return
+	}
+	if s.PollSeconds <= 0 || s.RequestTimeoutSeconds <= 0 {
+		errs.add("spender", "poll_seconds and request_timeout_seconds must be positive")
+	}
+	if s.MaxPrice != "" {
+		if price, err := decimal.NewFromString(s.MaxPrice); err != nil || !price.IsPositive() {
+			errs.add("spender.max_price", "%q is not a positive price in ether", s.MaxPrice)
+		}
+	}
+}
+
+// ReloadConfig controls when the configuration is reloaded without a
+// restart. SIGHUP always triggers a reload.
+type ReloadConfig struct {
+	Watch bool `yaml:"watch"` // Reload when config.yaml, security.yaml or the policy rules change on disk
+}
+
+// HTTPConfig tunes the HTTP listener
+type HTTPConfig struct {
+	TLSCertFile              string `yaml:"tls_cert_file"`               // Serve HTTPS when set together with tls_key_file
+	TLSKeyFile               string `yaml:"tls_key_file"`                // Private key for tls_cert_file
+	EnableHTTP2              bool   `yaml:"enable_http2"`                // Negotiate HTTP/2 over TLS
+	MaxConcurrentStreams     uint32 `yaml:"max_concurrent_streams"`      // HTTP/2 streams per connection
+	KeepAlive                bool   `yaml:"keep_alive"`                  // Reuse HTTP/1.1 connections between requests
+	IdleTimeoutSeconds       int    `yaml:"idle_timeout_seconds"`        // Close idle keep-alive connections after this long
+	ReadHeaderTimeoutSeconds int    `yaml:"read_header_timeout_seconds"` // Time allowed to read request headers
+	MaxConnections           int    `yaml:"max_connections"`             // Concurrent connections accepted (0 = unlimited)
+
+	// ACME obtains and renews certificates for these host names from an
+	// ACME CA such as Let's Encrypt, instead of tls_cert_file, using the
+	// TLS-ALPN-01 challenge on the HTTPS port
+	ACMEDomains      []string `yaml:"acme_domains"`
+	ACMEEmail        string   `yaml:"acme_email"`         // Contact address for the ACME account
+	ACMECacheDir     string   `yaml:"acme_cache_dir"`     // Where the account key and issued certificates are kept
+	ACMEDirectoryURL string   `yaml:"acme_directory_url"` // ACME directory (empty = Let's Encrypt production)
+
+	// Mutual TLS. client_auth is none, optional (verify certificates that
+	// are presented) or require. Verified certificates authenticate the
+	// caller as the identity client_identities maps their subject common
+	// name or "sha256:<hex fingerprint>" to, or else as the peer ID in a
+	// libp2p:<peer ID> URI SAN. Other certificates authenticate nobody.
+	ClientAuth       string            `yaml:"client_auth"`
+	ClientCAFile     string            `yaml:"client_ca_file"` // PEM CA certificates client certificates must chain to
+	ClientIdentities map[string]string `yaml:"client_identities"`
+}
+
+// Client certificate modes for http.client_auth
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSEnabled reports whether the API is served over HTTPS
+func (h HTTPConfig) TLSEnabled() bool {
+	return (h.TLSCertFile != "" && h.TLSKeyFile != "") || len(h.ACMEDomains) > 0
+}
+
+// validate checks the certificate source and client certificate settings
+func (h HTTPConfig) validate(errs *problems) {
+	// Half a certificate pair would otherwise serve plaintext
+	switch {
+	case h.TLSCertFile != "" && h.TLSKeyFile == "":
+		errs.add("http.tls_key_file", "is required when tls_cert_file is set")
+	case h.TLSKeyFile != "" && h.TLSCertFile == "":
+		errs.add("http.tls_cert_file", "is required when tls_key_file is set")
+	}
+	if len(h.ACMEDomains) > 0 && h.TLSCertFile != "" {
+		errs.add("http.acme_domains", "cannot be combined with tls_cert_file")
+	}
+	if h.IdleTimeoutSeconds < 0 || h.ReadHeaderTimeoutSeconds < 0 || h.MaxConnections < 0 {
+		errs.add("http", "idle_timeout_seconds, read_header_timeout_seconds and max_connections must not be negative")
+	}
+	switch h.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if !h.TLSEnabled() {
+			errs.add("http.client_auth", "%s needs tls_cert_file and tls_key_file or acme_domains", h.ClientAuth)
+		}
+		if h.ClientCAFile == "" {
+			errs.add("http.client_ca_file", "is required when client_auth is %s", h.ClientAuth)
+		}
+	default:
+		errs.add("http.client_auth", "unknown mode %q (want none, optional or require)", h.ClientAuth)
+	}
+}
+
+// Load loads configuration for a deployment profile from file and
+// environment variables. An empty profile falls back to PANDACEA_PROFILE and
+// then to dev. The profile sets the hardening defaults, which the file and
+// environment may override; the production profile refuses overrides that
+// weaken it.
+func Load(configPath, profile string) (*Config, error) {
 	// Default configuration
 	config := &Config{
 		Server: ServerConfig{
@@ -60,44 +1203,268 @@ func Load(configPath string) (*Config, error) {
 			ReputationDecayRate:    0.0005,
 			CollusionSpendFraction: 0.005,
 			CollusionBonusDivisor:  200,
+			LeaseAssignmentsPath:   "./state/lease_assignments.json",
+			LegacyRoutes:           LegacyRoutesWarn,
+			Compression: CompressionConfig{
+				Enabled:      true,
+				MinSizeBytes: 1024,
+				Encodings:    []string{"zstd", "gzip"},
+				ContentTypes: []string{"application/json", "application/x-ndjson", "text/plain", "text/csv"},
+			},
+			CORS: CORSConfig{
+				AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders: []string{
+					"Content-Type", "X-Pandacea-Peer-ID", "X-Pandacea-Signature",
+					"X-Pandacea-Signature-Version", "X-Pandacea-Timestamp", "X-Pandacea-Nonce",
+				},
+				ExposedHeaders: []string{
+					"X-API-Version", "Retry-After", "X-Pandacea-Peer-ID", "X-Pandacea-Signature", "X-Pandacea-Public-Key",
+				},
+				MaxAgeSeconds: 600,
+			},
 		},
 		P2P: P2PConfig{
-			ListenPort: 0, // Let libp2p choose a random port
+			ListenPort:           0, // Let libp2p choose a random port
+			KeyType:              "ed25519",
+			Transports:           []string{"tcp", "quic"},
+			HolePunching:         true,
+			MinPeerScore:         -50,
+			ScoreHalfLifeMinutes: 60,
+			PeersPath:            "./state/peers.json",
 		},
 		Blockchain: BlockchainConfig{
 			RPCURL:          "http://127.0.0.1:8545", // Default Anvil RPC URL
 			ContractAddress: "",                      // Must be set via environment variable
+			MaxLagBlocks:    20,
+			HeadPollSeconds: 15,
+			// Most RPC providers cap eth_getLogs ranges at a few thousand blocks
+			CatchUpBatchSize: 2000,
+			EventWorkers:     4,
+			EventQueueSize:   256,
+			EventMaxParked:   1024,
 		},
 		IPFS: IPFSConfig{
 			APIURL: "http://127.0.0.1:5001", // Default IPFS API URL
 		},
+		Policy: PolicyConfig{
+			Engine: "static",
+			Query:  "data.pandacea.lease",
+		},
+		Pricing: PricingConfig{
+			RefreshSeconds:      60,
+			DemandWindowSeconds: 3600,
+			DemandThreshold:     10,
+			DemandStep:          0.05,
+			MaxMultiplier:       3,
+		},
+		HTTP: HTTPConfig{
+			EnableHTTP2:              true,
+			MaxConcurrentStreams:     250,
+			KeepAlive:                true,
+			IdleTimeoutSeconds:       120,
+			ReadHeaderTimeoutSeconds: 10,
+			ACMECacheDir:             "./state/acme",
+			ClientAuth:               ClientAuthNone,
+		},
+		Watermark: WatermarkConfig{
+			KeyFile: "~/.pandacea/watermark.key",
+		},
+		Audit: AuditConfig{
+			JournalPath: "./state/audit/journal.ndjson",
+		},
+		Privacy: PrivacyConfig{
+			LedgerPath: "./state/privacy/budget.json",
+		},
+		Incident: IncidentConfig{
+			QuarantinePath:        "./state/quarantine.json",
+			RevocationsPath:       "./state/revocations.json",
+			GossipRevocations:     true,
+			GossipIntervalMinutes: 10,
+		},
+		Earnings: EarningsConfig{
+			LedgerPath: "./state/earnings.json",
+		},
+		Disputes: DisputesConfig{
+			RecordsPath: "./state/disputes.json",
+		},
+		Usage: UsageConfig{
+			RecordsPath: "./state/usage.json",
+			SaveSeconds: 60,
+		},
+		Models: ModelsConfig{
+			RegistryPath: "./state/models.json",
+		},
+		Lineage: LineageConfig{
+			GraphPath: "./state/lineage.json",
+		},
+		Consent: ConsentConfig{
+			Language: "en",
+		},
+		Metering: MeteringConfig{
+			LedgerPath: "./state/metering.json",
+		},
+		Attestation: AttestationConfig{
+			RecordsPath: "./state/attestations.json",
+		},
+		Delivery: DeliveryConfig{
+			WaitSeconds: 120,
+			RecordsPath: "./state/deliveries.json",
+		},
+		Encryption: EncryptionConfig{
+			KeyringFile: "~/.pandacea/atrest-keyring.json",
+			Dirs:        []string{"./data"},
+		},
+		Retention: RetentionConfig{
+			MaxAgeHours:     720,
+			MaxJobs:         1000,
+			IntervalMinutes: 10,
+		},
+		Publish: PublishConfig{
+			Service:     "local",
+			PinsPath:    "./state/pins.json",
+			PollSeconds: 60,
+		},
+		Assets: AssetsConfig{
+			RegistryPath: "./state/assets.json",
+			S3:           S3Config{Region: "us-east-1"},
+			Preview: PreviewConfig{
+				Rows:              10,
+				Mode:              "head",
+				CacheSeconds:      300,
+				RequestsPerMinute: 6,
+			},
+			Profile: AssetProfileConfig{
+				Bins:           10,
+				TopValues:      10,
+				MinCount:       5,
+				CacheSeconds:   86400,
+				TimeoutSeconds: 300,
+			},
+		},
+		Transactions: TransactionsConfig{
+			Confirmations: 2,
+			PollSeconds:   3,
+			StallSeconds:  90,
+			BumpPercent:   15,
+			QueueSize:     64,
+			RecordsPath:   "./state/transactions.json",
+		},
+		Remote: RemoteConfig{
+			RefreshSeconds: 300,
+		},
+		Federation: FederationConfig{
+			RoundTimeoutSeconds:      1800,
+			MaxRounds:                100,
+			HeartbeatSeconds:         60,
+			ParticipantTTLSeconds:    180,
+			MinParticipantReputation: -20,
+		},
+		Scheduler: SchedulerConfig{
+			Workers:              2,
+			MaxQueued:            256,
+			MaxQueuedPerIdentity: 16,
+		},
+		GPU: GPUConfig{
+			Workers:       1,
+			MaxQueued:     32,
+			SampleSeconds: 15,
+		},
+		Market: MarketConfig{
+			Enabled:                  true,
+			AdvertiseIntervalMinutes: 60,
+			MaxPeers:                 20,
+			PeerTimeoutSeconds:       5,
+			CacheTTLSeconds:          60,
+		},
+		Spender: SpenderConfig{
+			PollSeconds:           15,
+			RequestTimeoutSeconds: 10,
+			RecordsPath:           "./state/outbound_leases.json",
+		},
+		Reload: ReloadConfig{
+			Watch: true,
+		},
+		Pool: PoolConfig{
+			Size:            3,
+			Autoscale:       "hint",
+			MinSize:         1,
+			MaxSize:         10,
+			LeadMinutes:     60,
+			Headroom:        1.5,
+			IntervalSeconds: 300,
+			HistoryPath:     "./state/pool/load_history.json",
+			Sandbox: SandboxConfig{
+				Backend:  "docker",
+				MemoryMB: 512,
+				CPUs:     1,
+				WorkDir:  "./state/sandboxes",
+				ImagePolicy: ImagePolicyConfig{
+					Cosign: "cosign",
+				},
+			},
+		},
+		ScriptScan: ScriptScanConfig{
+			Python: "python3",
+			ScriptPolicyConfig: ScriptPolicyConfig{
+				MaxBytes: 256 << 10,
+				MaxNodes: 50000,
+				MaxDepth: 64,
+			},
+		},
+	}
+
+	if profile == "" {
+		profile = os.Getenv("PANDACEA_PROFILE")
+	}
+	if profile == "" {
+		profile = ProfileDev
+	}
+	if err := applyProfile(config, profile); err != nil {
+		return nil, err
 	}
 
 	// Load from config file if it exists
+	sealResultsSet := false
 	if configPath != "" {
-		if err := loadFromFile(config, configPath); err != nil {
+		var err error
+		if sealResultsSet, err = loadFromFile(config, configPath); err != nil {
 			return nil, fmt.Errorf("failed to load config file: %w", err)
 		}
 	}
 
 	// Override with environment variables
 	loadFromEnv(config)
+	applyMockDefaults(config, sealResultsSet)
+
+	config.applyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
 
-// loadFromFile loads configuration from a YAML file
-func loadFromFile(config *Config, configPath string) error {
+// loadFromFile loads configuration from a YAML file and reports whether it
+// sets hardening.seal_results
+func loadFromFile(config *Config, configPath string) (bool, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if err := yaml.Unmarshal(data, config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+		return false, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	return nil
+	var set struct {
+		Hardening struct {
+			SealResults *bool `yaml:"seal_results"`
+		} `yaml:"hardening"`
+	}
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return set.Hardening.SealResults != nil, nil
 }
 
 // loadFromEnv loads configuration from environment variables
@@ -109,6 +1476,13 @@ func loadFromEnv(config *Config) {
 		}
 	}
 
+	if certFile := os.Getenv("HTTP_TLS_CERT_FILE"); certFile != "" {
+		config.HTTP.TLSCertFile = certFile
+	}
+	if keyFile := os.Getenv("HTTP_TLS_KEY_FILE"); keyFile != "" {
+		config.HTTP.TLSKeyFile = keyFile
+	}
+
 	// P2P configuration
 	if portStr := os.Getenv("P2P_PORT"); portStr != "" {
 		if port, err := strconv.Atoi(portStr); err == nil {
@@ -119,6 +1493,18 @@ func loadFromEnv(config *Config) {
 	if keyFilePath := os.Getenv("P2P_KEY_FILE"); keyFilePath != "" {
 		config.P2P.KeyFilePath = keyFilePath
 	}
+	if keyType := os.Getenv("P2P_KEY_TYPE"); keyType != "" {
+		config.P2P.KeyType = keyType
+	}
+	if transports := os.Getenv("P2P_TRANSPORTS"); transports != "" {
+		config.P2P.Transports = strings.Split(transports, ",")
+	}
+	if peers := os.Getenv("P2P_BOOTSTRAP_PEERS"); peers != "" {
+		config.P2P.BootstrapPeers = strings.Split(peers, ",")
+	}
+	if relays := os.Getenv("P2P_STATIC_RELAYS"); relays != "" {
+		config.P2P.StaticRelays = strings.Split(relays, ",")
+	}
 
 	// Blockchain configuration
 	if rpcURL := os.Getenv("RPC_URL"); rpcURL != "" {
@@ -128,6 +1514,63 @@ func loadFromEnv(config *Config) {
 	if contractAddress := os.Getenv("CONTRACT_ADDRESS"); contractAddress != "" {
 		config.Blockchain.ContractAddress = contractAddress
 	}
+
+	if chainIDStr := os.Getenv("CHAIN_ID"); chainIDStr != "" {
+		if chainID, err := strconv.ParseUint(chainIDStr, 10, 64); err == nil {
+			config.Blockchain.ChainID = chainID
+		}
+	}
+
+	if network := os.Getenv("DEFAULT_NETWORK"); network != "" {
+		config.Blockchain.DefaultNetwork = network
+	}
+
+	if keyFile := os.Getenv("TX_KEY_FILE"); keyFile != "" {
+		config.Transactions.KeyFile = keyFile
+	}
+
+	if lagStr := os.Getenv("EVENT_LISTENER_MAX_LAG_BLOCKS"); lagStr != "" {
+		if lag, err := strconv.ParseUint(lagStr, 10, 64); err == nil {
+			config.Blockchain.MaxLagBlocks = lag
+		}
+	}
+
+	if workersStr := os.Getenv("EVENT_LISTENER_WORKERS"); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil {
+			config.Blockchain.EventWorkers = workers
+		}
+	}
+
+	// Policy configuration
+	if engine := os.Getenv("POLICY_ENGINE"); engine != "" {
+		config.Policy.Engine = engine
+	}
+
+	if regoPath := os.Getenv("POLICY_REGO_PATH"); regoPath != "" {
+		config.Policy.RegoPath = regoPath
+	}
+
+	if bundle := os.Getenv("POLICY_BUNDLE"); bundle != "" {
+		config.Policy.Bundle = bundle
+	}
+
+	// Training configuration. MOCK_DP and USE_DOCKER predate execution_mode
+	// and are still honoured; USE_DOCKER wins as it always has.
+	switch os.Getenv("MOCK_DP") {
+	case "1":
+		config.Training.ExecutionMode = ExecutionModeMock
+	case "0":
+		if config.Training.ExecutionMode == ExecutionModeMock {
+			config.Training.ExecutionMode = ExecutionModeLocal
+		}
+	}
+	if os.Getenv("USE_DOCKER") == "1" {
+		config.Training.ExecutionMode = ExecutionModeDocker
+	}
+	if mode := os.Getenv("TRAINING_EXECUTION_MODE"); mode != "" {
+		config.Training.ExecutionMode = mode
+	}
+	config.Training.Remote.Token = os.Getenv("PANDACEA_TRAINING_TOKEN")
 }
 
 // GetServerAddr returns the server address string
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultNetworkName names the network formed by blockchain.rpc_url and
+// blockchain.contract_address
+const DefaultNetworkName = "default"
+
+// NetworkConfig is one LeaseAgreement deployment, such as a local Anvil
+// chain, a testnet or mainnet
+type NetworkConfig struct {
+	Name            string `yaml:"name"`
+	RPCURL          string `yaml:"rpc_url"`
+	ContractAddress string `yaml:"contract_address"`
+	ChainID         uint64 `yaml:"chain_id"` // Chain ID rpc_url must report (0 = not checked)
+}
+
+// AllNetworks returns the configured deployments: the default network, if
+// rpc_url and contract_address are both set, followed by networks
+func (b BlockchainConfig) AllNetworks() []NetworkConfig {
+	var all []NetworkConfig
+	if b.RPCURL != "" && b.ContractAddress != "" {
+		all = append(all, NetworkConfig{
+			Name:            DefaultNetworkName,
+			RPCURL:          b.RPCURL,
+			ContractAddress: b.ContractAddress,
+			ChainID:         b.ChainID,
+		})
+	}
+	return append(all, b.Networks...)
+}
+
+// Network looks up a deployment by name. An empty name selects the default
+// network: default_network if set, otherwise the first one configured.
+func (b BlockchainConfig) Network(name string) (NetworkConfig, bool) {
+	all := b.AllNetworks()
+	if name == "" {
+		name = b.DefaultNetwork
+	}
+	for _, n := range all {
+		if name == "" || n.Name == name {
+			return n, true
+		}
+	}
+	return NetworkConfig{}, false
+}
+
+// ProductNetwork returns the network productID's leases live on, or "" if
+// the product is not mapped to one
+func (b BlockchainConfig) ProductNetwork(productID string) string {
+	return b.ProductNetworks[productID]
+}
+
+// ForNetwork returns the listener settings for n: these settings with the
+// RPC URL, contract and chain ID replaced by n's
+func (b BlockchainConfig) ForNetwork(n NetworkConfig) BlockchainConfig {
+	b.RPCURL = n.RPCURL
+	b.ContractAddress = n.ContractAddress
+	b.ChainID = n.ChainID
+	b.Networks = nil
+	b.DefaultNetwork = n.Name
+	b.ProductNetworks = nil
+	return b
+}
+
+// validate checks network names are unique and that every name referenced
+// by default_network and product_networks is configured
+func (b BlockchainConfig) validate(errs *problems) {
+	if b.ContractAddress != "" && !common.IsHexAddress(b.ContractAddress) {
+		errs.add("blockchain.contract_address", "invalid contract_address %q", b.ContractAddress)
+	}
+
+	for i, n := range b.Networks {
+		if n.Name == "" {
+			errs.add(fmt.Sprintf("blockchain.networks[%d].name", i), "network has no name")
+		}
+	}
+	names := make(map[string]bool)
+	for _, n := range b.AllNetworks() {
+		switch {
+		case n.Name == "":
+			continue
+		case names[n.Name]:
+			errs.add("blockchain.networks", "network %q is configured more than once", n.Name)
+		case n.RPCURL == "":
+			errs.add("blockchain.networks", "network %q has no rpc_url", n.Name)
+		case !common.IsHexAddress(n.ContractAddress):
+			errs.add("blockchain.networks", "network %q has invalid contract_address %q", n.Name, n.ContractAddress)
+		}
+		names[n.Name] = true
+	}
+
+	if b.DefaultNetwork != "" && !names[b.DefaultNetwork] {
+		errs.add("blockchain.default_network", "network %q is not configured", b.DefaultNetwork)
+	}
+	for _, product := range sortedKeys(b.ProductNetworks) {
+		if network := b.ProductNetworks[product]; !names[network] {
+			errs.add("blockchain.product_networks."+product, "maps to unconfigured network %q", network)
+		}
+	}
+}
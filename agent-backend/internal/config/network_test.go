@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadNetworks(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("RPC_URL", "")
+	t.Setenv("CONTRACT_ADDRESS", "")
+	t.Setenv("CHAIN_ID", "")
+	t.Setenv("DEFAULT_NETWORK", "")
+	path := writeConfig(t, `
+blockchain:
+  contract_address: "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+  chain_id: 31337
+  default_network: amoy
+  networks:
+    - name: amoy
+      rpc_url: https://rpc-amoy.polygon.technology
+      contract_address: "0x9fE46736679d2D9a65F0992F2272dE9f3c7fa6e0"
+      chain_id: 80002
+  product_networks:
+    dataset-1: default
+`)
+
+	cfg, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	all := cfg.Blockchain.AllNetworks()
+	if len(all) != 2 || all[0].Name != DefaultNetworkName || all[0].ChainID != 31337 || all[1].Name != "amoy" {
+		t.Fatalf("AllNetworks() = %+v", all)
+	}
+	if n, ok := cfg.Blockchain.Network(""); !ok || n.Name != "amoy" {
+		t.Errorf("Network(\"\") = %+v, %v, want amoy", n, ok)
+	}
+	if _, ok := cfg.Blockchain.Network("mainnet"); ok {
+		t.Error("Network(mainnet) found an unconfigured network")
+	}
+	if got := cfg.Blockchain.ProductNetwork("dataset-1"); got != DefaultNetworkName {
+		t.Errorf("ProductNetwork(dataset-1) = %q", got)
+	}
+
+	listener := cfg.Blockchain.ForNetwork(all[1])
+	if listener.RPCURL != all[1].RPCURL || listener.ChainID != 80002 || listener.CatchUpBatchSize != cfg.Blockchain.CatchUpBatchSize {
+		t.Errorf("ForNetwork() = %+v", listener)
+	}
+}
+
+func TestLoadRejectsInvalidNetworks(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("RPC_URL", "")
+	t.Setenv("CONTRACT_ADDRESS", "")
+	t.Setenv("DEFAULT_NETWORK", "")
+	const contract = `"0x5FbDB2315678afecb367f032d93F642f64180aa3"`
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"unnamed", "networks:\n    - rpc_url: http://x\n      contract_address: " + contract, "has no name"},
+		{"duplicate", "contract_address: " + contract + "\n  networks:\n    - name: default\n      rpc_url: http://x\n      contract_address: " + contract, "more than once"},
+		{"no contract", "networks:\n    - name: amoy\n      rpc_url: http://x", "invalid contract_address"},
+		{"unknown default", "contract_address: " + contract + "\n  default_network: mainnet", "default_network"},
+		{"unknown product network", "contract_address: " + contract + "\n  product_networks:\n    dataset-1: mainnet", "product_networks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, "blockchain:\n  "+tt.yaml+"\n"), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Deployment profiles selected with --profile or PANDACEA_PROFILE
+const (
+	ProfileDev        = "dev"
+	ProfileStaging    = "staging"
+	ProfileProduction = "production"
+)
+
+var (
+	// ErrUnknownProfile is returned for a profile name that is not defined
+	ErrUnknownProfile = errors.New("unknown profile")
+	// ErrUnsafeConfig is returned when the production profile is combined
+	// with settings that weaken it
+	ErrUnsafeConfig = errors.New("unsafe configuration for production")
+)
+
+// HardeningConfig holds the security switches whose defaults come from the
+// deployment profile
+type HardeningConfig struct {
+	RequireTLS        bool `yaml:"require_tls"`        // Refuse to start without tls_cert_file and tls_key_file
+	RequireSignatures bool `yaml:"require_signatures"` // Accept only v2 request signatures, whatever security.yaml allows
+	SealResults       bool `yaml:"seal_results"`       // Encrypt computation results to the spender's key before storing them
+}
+
+// profileDefaults are the settings a profile presets
+type profileDefaults struct {
+	hardening     HardeningConfig
+	executionMode string
+}
+
+// profiles are the presets applied before the config file is read. No
+// profile defaults to mock training: synthetic results are only produced
+// when the operator asks for them.
+var profiles = map[string]profileDefaults{
+	ProfileDev:        {hardening: HardeningConfig{SealResults: true}, executionMode: ExecutionModeLocal},
+	ProfileStaging:    {hardening: HardeningConfig{RequireTLS: true, RequireSignatures: true, SealResults: true}, executionMode: ExecutionModeLocal},
+	ProfileProduction: {hardening: HardeningConfig{RequireTLS: true, RequireSignatures: true, SealResults: true}, executionMode: ExecutionModeLocal},
+}
+
+// applyProfile sets the defaults for the named profile
+func applyProfile(config *Config, profile string) error {
+	defaults, ok := profiles[profile]
+	if !ok {
+		return fmt.Errorf("%w %q (want %s, %s or %s)", ErrUnknownProfile, profile, ProfileDev, ProfileStaging, ProfileProduction)
+	}
+	config.Profile = profile
+	config.Hardening = defaults.hardening
+	config.Training.ExecutionMode = defaults.executionMode
+	return nil
+}
+
+// applyMockDefaults turns off the result sealing a profile presets when
+// training is mocked and the config file leaves seal_results unset. Mock
+// runs serve test clients, which rarely hold a key to seal to.
+func applyMockDefaults(config *Config, sealResultsSet bool) {
+	if config.Training.ExecutionMode == ExecutionModeMock && !sealResultsSet {
+		config.Hardening.SealResults = false
+	}
+}
+
+// Hazards lists settings that weaken the production defaults. They are fatal
+// under the production profile and should be reviewed under staging.
+func (c *Config) Hazards() []string {
+	var hazards []string
+	if !c.Hardening.RequireTLS {
+		hazards = append(hazards, "hardening.require_tls is disabled")
+	}
+	if !c.Hardening.RequireSignatures {
+		hazards = append(hazards, "hardening.require_signatures is disabled")
+	}
+	if !c.Hardening.SealResults {
+		hazards = append(hazards, "hardening.seal_results is disabled, so computation results are stored readable by the operator")
+	}
+	if c.Training.ExecutionMode == ExecutionModeMock {
+		hazards = append(hazards, "training.execution_mode is mock, so training results are synthetic")
+	}
+	if len(c.Blockchain.AllNetworks()) == 0 {
+		hazards = append(hazards, "no blockchain network has a contract_address, so leases cannot be verified on-chain")
+	}
+	return hazards
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("MOCK_DP", "")
+	t.Setenv("USE_DOCKER", "")
+	t.Setenv("TRAINING_EXECUTION_MODE", "")
+	hardened := writeConfig(t, `
+http:
+  tls_cert_file: /etc/pandacea/tls.crt
+  tls_key_file: /etc/pandacea/tls.key
+blockchain:
+  contract_address: "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+`)
+
+	tests := []struct {
+		name     string
+		path     string
+		profile  string
+		want     HardeningConfig
+		wantMode string
+		wantErr  error
+	}{
+		{"dev by default", "", "", HardeningConfig{SealResults: true}, ExecutionModeLocal, nil},
+		{"production", hardened, ProfileProduction, HardeningConfig{RequireTLS: true, RequireSignatures: true, SealResults: true}, ExecutionModeLocal, nil},
+		{"staging", hardened, ProfileStaging, HardeningConfig{RequireTLS: true, RequireSignatures: true, SealResults: true}, ExecutionModeLocal, nil},
+		{"unknown", "", "qa", HardeningConfig{}, "", ErrUnknownProfile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.path, tt.profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.Hardening != tt.want {
+				t.Errorf("Load() hardening = %+v, want %+v", cfg.Hardening, tt.want)
+			}
+			if cfg.Training.ExecutionMode != tt.wantMode {
+				t.Errorf("Load() execution mode = %q, want %q", cfg.Training.ExecutionMode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestLoadRefusesUnsafeProduction(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("MOCK_DP", "")
+	t.Setenv("USE_DOCKER", "")
+	t.Setenv("TRAINING_EXECUTION_MODE", "")
+
+	if _, err := Load("", ProfileProduction); err == nil {
+		t.Error("Load() accepted production without TLS files")
+	}
+
+	weakened := writeConfig(t, `
+hardening:
+  require_signatures: false
+http:
+  tls_cert_file: /etc/pandacea/tls.crt
+  tls_key_file: /etc/pandacea/tls.key
+blockchain:
+  contract_address: "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+`)
+	if _, err := Load(weakened, ProfileProduction); !errors.Is(err, ErrUnsafeConfig) {
+		t.Errorf("Load() with signatures disabled error = %v, want ErrUnsafeConfig", err)
+	}
+
+	// The same overrides are allowed, with hazards reported, outside production
+	cfg, err := Load(weakened, ProfileStaging)
+	if err != nil {
+		t.Fatalf("Load() staging error = %v", err)
+	}
+	if len(cfg.Hazards()) != 1 {
+		t.Errorf("Hazards() = %v, want one hazard", cfg.Hazards())
+	}
+
+	t.Setenv("MOCK_DP", "1")
+	if _, err := Load(weakened, ProfileProduction); !errors.Is(err, ErrUnsafeConfig) {
+		t.Errorf("Load() with MOCK_DP=1 error = %v, want ErrUnsafeConfig", err)
+	}
+}
+
+func TestLoadExecutionMode(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("MOCK_DP", "")
+	t.Setenv("USE_DOCKER", "")
+	t.Setenv("TRAINING_EXECUTION_MODE", "")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"profile default", nil, ExecutionModeLocal, false},
+		{"MOCK_DP=1 opts in to mock", map[string]string{"MOCK_DP": "1"}, ExecutionModeMock, false},
+		{"MOCK_DP=0", map[string]string{"MOCK_DP": "0"}, ExecutionModeLocal, false},
+		{"USE_DOCKER wins over MOCK_DP", map[string]string{"MOCK_DP": "1", "USE_DOCKER": "1"}, ExecutionModeDocker, false},
+		{"explicit mode", map[string]string{"USE_DOCKER": "1", "TRAINING_EXECUTION_MODE": "local"}, ExecutionModeLocal, false},
+		{"invalid mode", map[string]string{"TRAINING_EXECUTION_MODE": "kubernetes"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load("", ProfileDev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Training.ExecutionMode != tt.want {
+				t.Errorf("Load() execution mode = %q, want %q", cfg.Training.ExecutionMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSealsResultsOutsideMockMode(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("USE_DOCKER", "")
+	t.Setenv("TRAINING_EXECUTION_MODE", "")
+	t.Setenv("MOCK_DP", "1")
+
+	cfg, err := Load("", ProfileDev)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Hardening.SealResults {
+		t.Error("Load() sealed results in mock mode without being asked to")
+	}
+
+	// An explicit setting holds in mock mode too
+	cfg, err = Load(writeConfig(t, "hardening:\n  seal_results: true\n"), ProfileDev)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.Hardening.SealResults {
+		t.Error("Load() dropped seal_results set in the config file")
+	}
+}
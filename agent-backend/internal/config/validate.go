@@ -0,0 +1,295 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultMinPrice is the lease price floor in ether used when
+// server.min_price is unset. It matches the contract's MIN_PRICE.
+const DefaultMinPrice = "0.001"
+
+// FieldError is a problem with one configuration field
+type FieldError struct {
+	Field string // YAML path of the field, such as server.port
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every invalid field of a configuration
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Error()
+	}
+	return "invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// Unwrap lets errors.Is and errors.As match the errors of individual fields
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, field := range e.Fields {
+		errs[i] = field
+	}
+	return errs
+}
+
+// problems collects the field errors found by a validation pass
+type problems []*FieldError
+
+// add records a problem with field
+func (p *problems) add(field, format string, args ...any) {
+	*p = append(*p, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+// applyDefaults fills in settings left empty or zero where that has no
+// meaning of its own, so only values that were set wrongly fail Validate
+func (c *Config) applyDefaults() {
+	if c.Server.MinPrice == "" {
+		c.Server.MinPrice = DefaultMinPrice
+	}
+	if c.P2P.KeyType == "" {
+		c.P2P.KeyType = "ed25519"
+	}
+	if len(c.P2P.Transports) == 0 {
+		c.P2P.Transports = []string{"tcp", "quic"}
+	}
+	if c.Policy.Engine == "" {
+		c.Policy.Engine = "static"
+	}
+	if c.Policy.Query == "" {
+		c.Policy.Query = "data.pandacea.lease"
+	}
+	if c.Pricing.RefreshSeconds == 0 {
+		c.Pricing.RefreshSeconds = 60
+	}
+	if c.Pricing.DemandWindowSeconds == 0 {
+		c.Pricing.DemandWindowSeconds = 3600
+	}
+	if c.HTTP.ClientAuth == "" {
+		c.HTTP.ClientAuth = ClientAuthNone
+	}
+	if c.Pool.Autoscale == "" {
+		c.Pool.Autoscale = "hint"
+	}
+}
+
+// Validate checks every setting and returns a *ValidationError listing all
+// invalid fields, or nil if there are none. Under the production profile
+// settings that weaken it are invalid too.
+func (c *Config) Validate() error {
+	var errs problems
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs.add("server.port", "%d is not a port between 1 and 65535", c.Server.Port)
+	}
+	c.Server.validate(&errs)
+	if c.P2P.ListenPort < 0 || c.P2P.ListenPort > 65535 {
+		errs.add("p2p.listen_port", "%d is not a port between 0 and 65535", c.P2P.ListenPort)
+	}
+	c.Training.validate(&errs)
+	c.HTTP.validate(&errs)
+	if c.Hardening.RequireTLS && !c.HTTP.TLSEnabled() {
+		errs.add("hardening.require_tls", "is set but neither http.tls_cert_file and http.tls_key_file nor http.acme_domains are")
+	}
+	c.Policy.validate(&errs)
+	c.Pricing.validate(&errs)
+	if c.Privacy.DefaultEpsilon < 0 {
+		errs.add("privacy.default_epsilon", "%v must not be negative", c.Privacy.DefaultEpsilon)
+	}
+	for _, dataset := range sortedKeys(c.Privacy.DatasetEpsilon) {
+		if epsilon := c.Privacy.DatasetEpsilon[dataset]; epsilon < 0 {
+			errs.add("privacy.dataset_epsilon."+dataset, "%v must not be negative", epsilon)
+		}
+	}
+	if (c.Remote.ProductsURL != "" || c.Remote.SecurityURL != "") && c.Remote.RefreshSeconds <= 0 {
+		errs.add("remote.refresh_seconds", "must be positive")
+	}
+	if c.Usage.RecordsPath != "" && c.Usage.SaveSeconds <= 0 {
+		errs.add("usage.save_seconds", "must be positive")
+	}
+	if (c.Federation.Coordinator || c.Federation.Participant) && (c.Federation.RoundTimeoutSeconds <= 0 || c.Federation.MaxRounds <= 0) {
+		errs.add("federation", "round_timeout_seconds and max_rounds must be positive")
+	}
+	if c.Federation.Participant && len(c.Federation.AllowedCoordinators) > 0 && c.Federation.HeartbeatSeconds <= 0 {
+		errs.add("federation.heartbeat_seconds", "must be positive")
+	}
+	if c.Federation.Coordinator && c.Federation.ParticipantTTLSeconds <= 0 {
+		errs.add("federation.participant_ttl_seconds", "must be positive")
+	}
+	c.Scheduler.validate(&errs)
+	c.GPU.validate(&errs)
+	c.Pool.validate(&errs)
+	c.ScriptScan.validate(&errs)
+	c.Market.validate(&errs)
+	c.Spender.validate(&errs)
+	c.P2P.validate(&errs)
+	c.Blockchain.validate(&errs)
+	c.Transactions.validate(&errs)
+	c.Delivery.validate(&errs)
+	c.Assets.validate(&errs)
+	c.Encryption.validate(&errs)
+	c.Retention.validate(&errs)
+	c.Publish.validate(&errs)
+	c.Catalog.validate(&errs)
+	c.Incident.validate(&errs)
+	validateTenants(c.Tenants, c.Server, &errs)
+	c.Verification.validate(&errs)
+	c.Attestation.validate(&errs)
+	c.Consent.validate(&errs)
+	c.Metering.validate(&errs)
+	if len(c.Delivery.Sources) > 0 && c.Transactions.KeyFile == "" {
+		errs.add("delivery.sources", "delivering products requires transactions.key_file to execute leases")
+	}
+	if c.Attestation.Enabled && c.Attestation.Anchor && c.Transactions.KeyFile == "" {
+		errs.add("attestation.anchor", "anchoring attestations requires transactions.key_file to send them")
+	}
+	if c.Spender.Enabled && (!c.Market.Enabled || c.Transactions.KeyFile == "") {
+		errs.add("spender.enabled", "leasing from other agents requires market.enabled to find them and transactions.key_file to pay")
+	}
+	if c.Profile == ProfileProduction {
+		if hazards := c.Hazards(); len(hazards) > 0 {
+			errs = append(errs, &FieldError{Field: "profile", Err: fmt.Errorf("%w: %s", ErrUnsafeConfig, strings.Join(hazards, "; "))})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// validate checks the lease price floor and economic parameters
+func (s ServerConfig) validate(errs *problems) {
+	if price, err := decimal.NewFromString(s.MinPrice); err != nil || price.IsNegative() {
+		errs.add("server.min_price", "%q is not a non-negative price in ether", s.MinPrice)
+	}
+	if s.RoyaltyPercentage < 0 || s.RoyaltyPercentage > 1 {
+		errs.add("server.royalty_percentage", "%v is not a fraction between 0 and 1", s.RoyaltyPercentage)
+	}
+	if s.CollusionSpendFraction < 0 || s.CollusionSpendFraction > 1 {
+		errs.add("server.collusion_spend_fraction", "%v is not a fraction between 0 and 1", s.CollusionSpendFraction)
+	}
+	if s.CollusionBonusDivisor <= 0 {
+		errs.add("server.collusion_bonus_divisor", "%d must be positive", s.CollusionBonusDivisor)
+	}
+	s.CORS.validate(errs)
+	switch s.LegacyRoutes {
+	case LegacyRoutesAllow, LegacyRoutesWarn, LegacyRoutesBlock:
+	default:
+		errs.add("server.legacy_routes", "%q is not %s, %s or %s", s.LegacyRoutes, LegacyRoutesAllow, LegacyRoutesWarn, LegacyRoutesBlock)
+	}
+	if s.SaboteurCooldown < 0 || s.MinReputation < 0 {
+		errs.add("server", "saboteur_cooldown and min_reputation must not be negative")
+	}
+	// The reputation tracker refuses anything else, so catch it here
+	if s.ReputationWeight <= 0 {
+		errs.add("server.reputation_weight", "%v must be positive", s.ReputationWeight)
+	}
+	if s.ReputationDecayRate < 0 || s.ReputationDecayRate >= 1 {
+		errs.add("server.reputation_decay_rate", "%v is not a rate in [0, 1)", s.ReputationDecayRate)
+	}
+}
+
+// validate checks the policy engine can be built from these settings
+func (p PolicyConfig) validate(errs *problems) {
+	switch p.Engine {
+	case "static":
+	case "rego":
+		if p.RegoPath == "" && p.Bundle == "" {
+			errs.add("policy.rego_path", "rego engine requires rego_path or bundle")
+		}
+	default:
+		errs.add("policy.engine", "unknown engine %q (want static or rego)", p.Engine)
+	}
+}
+
+// validate checks the demand pricing settings
+func (p PricingConfig) validate(errs *problems) {
+	if p.RefreshSeconds < 0 || p.DemandWindowSeconds < 0 {
+		errs.add("pricing", "refresh_seconds and demand_window_seconds must not be negative")
+	}
+	if p.DemandThreshold < 0 || p.DemandStep < 0 {
+		errs.add("pricing", "demand_threshold and demand_step must not be negative")
+	}
+	if p.MaxMultiplier < 1 {
+		errs.add("pricing.max_multiplier", "%v is below 1, which would lower prices under demand", p.MaxMultiplier)
+	}
+}
+
+// sortedKeys returns the keys of m in order, so problems are reported in
+// the same order on every run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateTenants checks each tenant has an earner address and namespaces
+// of its own, and that its server overrides are valid server settings
+func validateTenants(tenants []TenantConfig, live ServerConfig, errs *problems) {
+	ids := make(map[string]bool)
+	earners := make(map[string]bool)
+	namespaces := make(map[string]string)
+	for i, t := range tenants {
+		field := fmt.Sprintf("tenants[%d]", i)
+		if t.ID == "" {
+			errs.add(field+".id", "is required")
+		} else if ids[t.ID] {
+			errs.add(field+".id", "%q is used by another tenant", t.ID)
+		}
+		ids[t.ID] = true
+
+		if !common.IsHexAddress(t.Earner) {
+			errs.add(field+".earner", "%q is not a hex address", t.Earner)
+		} else if earner := strings.ToLower(t.Earner); earners[earner] {
+			errs.add(field+".earner", "%s is used by another tenant", t.Earner)
+		} else {
+			earners[earner] = true
+		}
+
+		if len(t.Namespaces) == 0 {
+			errs.add(field+".namespaces", "at least one product namespace is required")
+		}
+		for _, ns := range t.Namespaces {
+			if !strings.HasPrefix(ns, "did:pandacea:") {
+				errs.add(field+".namespaces", "%q is not a did:pandacea product ID prefix", ns)
+			} else if other, taken := namespaces[ns]; taken {
+				errs.add(field+".namespaces", "%q is already a namespace of tenant %s", ns, other)
+			}
+			namespaces[ns] = t.ID
+		}
+
+		if t.MaxActiveLeases < 0 {
+			errs.add(field+".max_active_leases", "must not be negative")
+		}
+		serverCfg, err := t.ServerConfig(live)
+		if err != nil {
+			errs.add(field+".server", "%v", err)
+			continue
+		}
+		var serverErrs problems
+		serverCfg.validate(&serverErrs)
+		for _, e := range serverErrs {
+			errs.add(field+"."+e.Field, "%v", e.Err)
+		}
+	}
+}
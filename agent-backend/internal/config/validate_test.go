@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadListsEveryInvalidField(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("HTTP_PORT", "")
+	t.Setenv("P2P_PORT", "")
+	path := writeConfig(t, `
+server:
+  port: -1
+  min_price: "cheap"
+  royalty_percentage: 1.5
+  reputation_weight: 0
+p2p:
+  listen_port: 70000
+policy:
+  engine: rego
+pricing:
+  max_multiplier: 0.5
+scheduler:
+  workers: 0
+`)
+
+	_, err := Load(path, "")
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+	want := []string{
+		"server.port",
+		"server.min_price",
+		"server.royalty_percentage",
+		"server.reputation_weight",
+		"p2p.listen_port",
+		"policy.rego_path",
+		"pricing.max_multiplier",
+		"scheduler.workers",
+	}
+	if len(invalid.Fields) != len(want) {
+		t.Fatalf("Load() found %d problems, want %d: %v", len(invalid.Fields), len(want), err)
+	}
+	for i, field := range want {
+		if invalid.Fields[i].Field != field {
+			t.Errorf("problem %d is with %s, want %s", i, invalid.Fields[i].Field, field)
+		}
+	}
+}
+
+func TestLoadRefusesHalfConfiguredTLS(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("HTTP_TLS_CERT_FILE", "")
+	t.Setenv("HTTP_TLS_KEY_FILE", "")
+
+	tests := []struct {
+		name   string
+		config string
+		field  string
+	}{
+		{"certificate only", "http:\n  tls_cert_file: /etc/pandacea/tls.crt\n", "http.tls_key_file"},
+		{"key only", "http:\n  tls_key_file: /etc/pandacea/tls.key\n", "http.tls_cert_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.config), "")
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Load() error = %v, want *ValidationError", err)
+			}
+			if len(invalid.Fields) != 1 || invalid.Fields[0].Field != tt.field {
+				t.Errorf("Load() error = %v, want a problem with %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	t.Setenv("P2P_KEY_TYPE", "")
+	t.Setenv("P2P_TRANSPORTS", "")
+	path := writeConfig(t, `
+server:
+  min_price: ""
+p2p:
+  key_type: ""
+  transports: []
+policy:
+  engine: ""
+pricing:
+  demand_window_seconds: 0
+`)
+
+	cfg, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.MinPrice != DefaultMinPrice {
+		t.Errorf("server.min_price = %q, want %q", cfg.Server.MinPrice, DefaultMinPrice)
+	}
+	if cfg.P2P.KeyType != "ed25519" || len(cfg.P2P.Transports) != 2 {
+		t.Errorf("p2p defaults = %q, %v", cfg.P2P.KeyType, cfg.P2P.Transports)
+	}
+	if cfg.Policy.Engine != "static" || cfg.Pricing.DemandWindowSeconds != 3600 {
+		t.Errorf("policy engine = %q, pricing window = %d", cfg.Policy.Engine, cfg.Pricing.DemandWindowSeconds)
+	}
+}
+
+func TestLoadRefusesFirecrackerSandbox(t *testing.T) {
+	t.Setenv("PANDACEA_PROFILE", "")
+	path := writeConfig(t, `
+container_pool:
+  sandbox:
+    backend: firecracker
+`)
+
+	_, err := Load(path, "")
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+	if len(invalid.Fields) != 1 || invalid.Fields[0].Field != "container_pool.sandbox.backend" {
+		t.Fatalf("Load() problems = %v, want container_pool.sandbox.backend", err)
+	}
+}
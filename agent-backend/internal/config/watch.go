@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a watched file must stay unchanged before a
+// change is reported, so an editor's several writes trigger one reload
+const watchDebounce = 500 * time.Millisecond
+
+// Watch reports changes to the files at paths until ctx is cancelled. The
+// files' directories are watched rather than the files themselves, so files
+// replaced by renaming a new copy over them, as editors and Kubernetes
+// config maps do, keep being watched. Each burst of changes is reported
+// once, with the path of the last file changed.
+func Watch(ctx context.Context, paths []string, logger *slog.Logger) (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	files := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		files[abs] = true
+		if dir := filepath.Dir(abs); !dirs[dir] {
+			if err := watcher.Add(dir); err != nil {
+				watcher.Close()
+				return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+			}
+			dirs[dir] = true
+		}
+	}
+
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+		defer watcher.Close()
+
+		var pending string
+		debounce := time.NewTimer(watchDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if !files[event.Name] || event.Op == fsnotify.Chmod {
+					continue
+				}
+				pending = event.Name
+				debounce.Reset(watchDebounce)
+			case err := <-watcher.Errors:
+				logger.Warn("config file watcher error", "error", err)
+			case <-debounce.C:
+				select {
+				case changes <- pending:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  min_price: \"0.001\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := Watch(ctx, []string{path}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// Other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case changed := <-changes:
+		t.Fatalf("Watch() reported %s for an unwatched file", changed)
+	case <-time.After(2 * watchDebounce):
+	}
+
+	// Replacing the file by rename, as editors do, is reported once
+	replacement := filepath.Join(dir, "config.yaml.tmp")
+	for _, price := range []string{"0.002", "0.003"} {
+		if err := os.WriteFile(replacement, []byte("server:\n  min_price: \""+price+"\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(replacement, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case changed := <-changes:
+		if changed != path {
+			t.Errorf("Watch() reported %s, want %s", changed, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() did not report the change")
+	}
+	select {
+	case changed := <-changes:
+		t.Errorf("Watch() reported %s twice for one burst of changes", changed)
+	case <-time.After(2 * watchDebounce):
+	}
+
+	cancel()
+	if _, open := <-changes; open {
+		t.Error("Watch() channel still open after ctx was cancelled")
+	}
+}
@@ -0,0 +1,181 @@
+// Package consent issues consent receipts for leases. A receipt follows the
+// Kantara Initiative Consent Receipt v1.1 layout: it records who consented
+// (the earner), who may process the data (the spender), which product the
+// consent covers and the purpose it was given for. The agent signs each
+// receipt, so either party can later prove what was agreed.
+package consent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"pandacea/agent-backend/internal/respsig"
+)
+
+// Version is the receipt format this package writes
+const Version = "KI-CR-v1.1.0"
+
+// Purpose categories a lease can be granted for, and computations declare
+const (
+	CategoryResearch       = "research"
+	CategoryAnalytics      = "analytics"
+	CategoryModelTraining  = "model-training"
+	CategoryProductDev     = "product-development"
+	CategoryMarketing      = "marketing"
+	CategorySecurity       = "security"
+	CategoryLegal          = "legal-compliance"
+	CategoryPublicInterest = "public-interest"
+)
+
+// Categories lists the purpose categories in a stable order
+var Categories = []string{
+	CategoryResearch, CategoryAnalytics, CategoryModelTraining, CategoryProductDev,
+	CategoryMarketing, CategorySecurity, CategoryLegal, CategoryPublicInterest,
+}
+
+// ErrInvalidPurpose is returned for unknown purpose categories
+var ErrInvalidPurpose = errors.New("invalid purpose")
+
+// ValidCategory reports whether category is a purpose category
+func ValidCategory(category string) bool {
+	return slices.Contains(Categories, category)
+}
+
+// Controller is a party that processes the data under the consent
+type Controller struct {
+	Name    string `json:"piiController"`
+	Contact string `json:"contact,omitempty"`
+}
+
+// Purpose is what the data may be used for
+type Purpose struct {
+	Purpose              string   `json:"purpose"`
+	Categories           []string `json:"purposeCategory"`
+	ConsentType          string   `json:"consentType"`
+	PrimaryPurpose       bool     `json:"primaryPurpose"`
+	Termination          string   `json:"termination"`
+	ThirdPartyDisclosure bool     `json:"thirdPartyDisclosure"`
+}
+
+// Service is a data product the consent covers
+type Service struct {
+	Service  string    `json:"service"`
+	Purposes []Purpose `json:"purposes"`
+}
+
+// Receipt is a consent receipt. Its compact JSON encoding, with the fields
+// in the order declared here, is what is hashed and signed.
+type Receipt struct {
+	Version          string       `json:"version"`
+	Jurisdiction     string       `json:"jurisdiction"`
+	ConsentTimestamp int64        `json:"consentTimestamp"` // Unix seconds
+	CollectionMethod string       `json:"collectionMethod"`
+	ReceiptID        string       `json:"consentReceiptID"`
+	Language         string       `json:"language"`
+	Principal        string       `json:"piiPrincipalId"` // Earner that consented
+	Controllers      []Controller `json:"piiControllers"`
+	PolicyURL        string       `json:"policyUrl"`
+	Services         []Service    `json:"services"`
+	Sensitive        bool         `json:"sensitive"`
+}
+
+// Grant is what a lease approval consents to
+type Grant struct {
+	ReceiptID    string // Lease proposal ID
+	Earner       string
+	Spender      string
+	SpenderPeer  string // Peer ID that proposed the lease, if known
+	ProductID    string
+	Purpose      string // Free-text description
+	Category     string
+	Jurisdiction string
+	PolicyURL    string
+	Language     string
+	GrantedAt    time.Time
+	ExpiresAt    *time.Time // When the lease, and so the consent, ends
+}
+
+// NewReceipt returns the receipt for a grant
+func NewReceipt(g Grant) (Receipt, error) {
+	if !ValidCategory(g.Category) {
+		return Receipt{}, fmt.Errorf("%w: unknown category %q", ErrInvalidPurpose, g.Category)
+	}
+	termination := "when the lease ends"
+	if g.ExpiresAt != nil {
+		termination = "at lease expiry, " + g.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	purpose := g.Purpose
+	if purpose == "" {
+		purpose = g.Category
+	}
+	language := g.Language
+	if language == "" {
+		language = "en"
+	}
+	return Receipt{
+		Version:          Version,
+		Jurisdiction:     g.Jurisdiction,
+		ConsentTimestamp: g.GrantedAt.Unix(),
+		CollectionMethod: "on-chain lease approval",
+		ReceiptID:        g.ReceiptID,
+		Language:         language,
+		Principal:        g.Earner,
+		Controllers:      []Controller{{Name: g.Spender, Contact: g.SpenderPeer}},
+		PolicyURL:        g.PolicyURL,
+		Services: []Service{{
+			Service: g.ProductID,
+			Purposes: []Purpose{{
+				Purpose:        purpose,
+				Categories:     []string{g.Category},
+				ConsentType:    "EXPLICIT",
+				PrimaryPurpose: true,
+				Termination:    termination,
+			}},
+		}},
+	}, nil
+}
+
+// Encode returns the bytes of r that are hashed and signed
+func (r Receipt) Encode() ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode consent receipt: %w", err)
+	}
+	return data, nil
+}
+
+// SignedReceipt is a receipt and the agent's signature over it
+type SignedReceipt struct {
+	Receipt   Receipt                  `json:"receipt"`
+	Signature respsig.ReceiptSignature `json:"signature"`
+}
+
+// Sign encodes receipt and signs it with signer
+func Sign(signer *respsig.Signer, receipt Receipt) (*SignedReceipt, error) {
+	data, err := receipt.Encode()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.SignReceipt(receipt.ReceiptID, data)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedReceipt{Receipt: receipt, Signature: *sig}, nil
+}
+
+// Verify checks that s's signature covers its receipt and, if
+// expectedPeerID is not empty, that the peer signed it. It returns the peer
+// ID that signed the receipt.
+func Verify(s SignedReceipt, expectedPeerID string) (string, error) {
+	if s.Signature.ReceiptID != s.Receipt.ReceiptID {
+		return "", fmt.Errorf("%w: signature is for receipt %s", respsig.ErrInvalidSignature, s.Signature.ReceiptID)
+	}
+	data, err := s.Receipt.Encode()
+	if err != nil {
+		return "", err
+	}
+	return respsig.VerifyReceipt(s.Signature, data, expectedPeerID)
+}
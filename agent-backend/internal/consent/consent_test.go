@@ -0,0 +1,70 @@
+package consent
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func newSigner(t *testing.T) *respsig.Signer {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	signer, err := respsig.NewSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return signer
+}
+
+func TestReceipt(t *testing.T) {
+	granted := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expires := granted.Add(24 * time.Hour)
+	grant := Grant{
+		ReceiptID: "lease_prop_1",
+		Earner:    "0xearner",
+		Spender:   "0xspender",
+		ProductID: "did:pandacea:earner:sales/2026",
+		Purpose:   "Churn study",
+		Category:  CategoryResearch,
+		GrantedAt: granted,
+		ExpiresAt: &expires,
+	}
+	receipt, err := NewReceipt(grant)
+	if err != nil {
+		t.Fatalf("NewReceipt() error = %v", err)
+	}
+	if receipt.Version != Version || receipt.Language != "en" || receipt.ConsentTimestamp != granted.Unix() {
+		t.Errorf("NewReceipt() = %+v", receipt)
+	}
+	purpose := receipt.Services[0].Purposes[0]
+	if purpose.Categories[0] != CategoryResearch || purpose.Termination != "at lease expiry, 2026-03-02T12:00:00Z" {
+		t.Errorf("purpose = %+v", purpose)
+	}
+
+	signer := newSigner(t)
+	signed, err := Sign(signer, receipt)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if got, err := Verify(*signed, signer.PeerID()); err != nil || got != signer.PeerID() {
+		t.Fatalf("Verify() = %s, %v; want %s", got, err, signer.PeerID())
+	}
+	widened := *signed
+	widened.Receipt.Services = []Service{{Service: grant.ProductID, Purposes: []Purpose{{Purpose: "Ads", Categories: []string{CategoryMarketing}}}}}
+	if _, err := Verify(widened, ""); !errors.Is(err, respsig.ErrInvalidSignature) {
+		t.Errorf("Verify() of a changed purpose error = %v, want ErrInvalidSignature", err)
+	}
+
+	grant.Category = "anything"
+	if _, err := NewReceipt(grant); !errors.Is(err, ErrInvalidPurpose) {
+		t.Errorf("NewReceipt() of an unknown category error = %v, want ErrInvalidPurpose", err)
+	}
+}
@@ -0,0 +1,2211 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contracts
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// LeaseAgreementV2Lease is an auto generated low-level Go binding around an user-defined struct.
+type LeaseAgreementV2Lease struct {
+	Spender       common.Address
+	Earner        common.Address
+	DataProductId [32]byte
+	Price         *big.Int
+	MaxPrice      *big.Int
+	IsApproved    bool
+	IsExecuted    bool
+	IsDisputed    bool
+	IsFinalized   bool
+	CreatedAt     *big.Int
+	ExecutedAt    *big.Int
+	DisputeId     *big.Int
+	StakeAmount   *big.Int
+}
+
+// LeaseAgreementV2MetaData contains all meta data concerning the LeaseAgreementV2 contract.
+var LeaseAgreementV2MetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_reputationContract\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"_pgtToken\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"_daoTreasury\",\"type\":\"address\"}],\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"owner\",\"type\":\"address\"}],\"name\":\"OwnableInvalidOwner\",\"type\":\"error\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"OwnableUnauthorizedAccount\",\"type\":\"error\"},{\"inputs\":[],\"name\":\"ReentrancyGuardReentrantCall\",\"type\":\"error\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"spender\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"string\",\"name\":\"reason\",\"type\":\"string\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"stakeAmount\",\"type\":\"uint256\"}],\"name\":\"DisputeRaised\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"indexed\":false,\"internalType\":\"bool\",\"name\":\"isDisputeValid\",\"type\":\"bool\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"stakeAmount\",\"type\":\"uint256\"}],\"name\":\"DisputeResolved\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"oldRate\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"newRate\",\"type\":\"uint256\"}],\"name\":\"DisputeStakeRateUpdated\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"LeaseApproved\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"spender\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"LeaseCreated\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"LeaseExecuted\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"reputationReward\",\"type\":\"uint256\"}],\"name\":\"LeaseFinalized\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"previousOwner\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"newOwner\",\"type\":\"address\"}],\"name\":\"OwnershipTransferred\",\"type\":\"event\"},{\"inputs\":[],\"name\":\"DISPUTE_WINDOW\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"MIN_PRICE\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"VERSION\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"approveLease\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"internalType\":\"bytes32\",\"name\":\"dataProductId\",\"type\":\"bytes32\"},{\"internalType\":\"uint256\",\"name\":\"maxPrice\",\"type\":\"uint256\"}],\"name\":\"createLease\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"daoTreasury\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"disputeStakeRate\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"emergencyPause\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"executeLease\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"finalizeLease\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"getDisputeInfo\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"disputeId\",\"type\":\"uint256\"},{\"internalType\":\"address\",\"name\":\"spender\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"leaseIdUint\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"timestamp\",\"type\":\"uint256\"},{\"internalType\":\"string\",\"name\":\"reason\",\"type\":\"string\"},{\"internalType\":\"bool\",\"name\":\"resolved\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"inFavorOfSpender\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"getLease\",\"outputs\":[{\"components\":[{\"internalType\":\"address\",\"name\":\"spender\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"internalType\":\"bytes32\",\"name\":\"dataProductId\",\"type\":\"bytes32\"},{\"internalType\":\"uint256\",\"name\":\"price\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxPrice\",\"type\":\"uint256\"},{\"internalType\":\"bool\",\"name\":\"isApproved\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isExecuted\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isDisputed\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isFinalized\",\"type\":\"bool\"},{\"internalType\":\"uint256\",\"name\":\"createdAt\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"executedAt\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"disputeId\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"stakeAmount\",\"type\":\"uint256\"}],\"internalType\":\"structLeaseAgreementV2.Lease\",\"name\":\"\",\"type\":\"tuple\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"}],\"name\":\"getRequiredStake\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"leaseExists\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"\",\"type\":\"bytes32\"}],\"name\":\"leases\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"spender\",\"type\":\"address\"},{\"internalType\":\"address\",\"name\":\"earner\",\"type\":\"address\"},{\"internalType\":\"bytes32\",\"name\":\"dataProductId\",\"type\":\"bytes32\"},{\"internalType\":\"uint256\",\"name\":\"price\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"maxPrice\",\"type\":\"uint256\"},{\"internalType\":\"bool\",\"name\":\"isApproved\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isExecuted\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isDisputed\",\"type\":\"bool\"},{\"internalType\":\"bool\",\"name\":\"isFinalized\",\"type\":\"bool\"},{\"internalType\":\"uint256\",\"name\":\"createdAt\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"executedAt\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"disputeId\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"stakeAmount\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"owner\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"pgtToken\",\"outputs\":[{\"internalType\":\"contractPGT\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"internalType\":\"string\",\"name\":\"reason\",\"type\":\"string\"}],\"name\":\"raiseDispute\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"renounceOwnership\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"reputationContract\",\"outputs\":[{\"internalType\":\"contractReputation\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"leaseId\",\"type\":\"bytes32\"},{\"internalType\":\"bool\",\"name\":\"isDisputeValid\",\"type\":\"bool\"}],\"name\":\"resolveDispute\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"newRate\",\"type\":\"uint256\"}],\"name\":\"setDisputeStakeRate\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"newOwner\",\"type\":\"address\"}],\"name\":\"transferOwnership\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"newDaoTreasury\",\"type\":\"address\"}],\"name\":\"updateDaoTreasury\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"newMinPrice\",\"type\":\"uint256\"}],\"name\":\"updateMinPrice\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"newPgtToken\",\"type\":\"address\"}],\"name\":\"updatePgtToken\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"newReputationContract\",\"type\":\"address\"}],\"name\":\"updateReputationContract\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]",
+}
+
+// LeaseAgreementV2ABI is the input ABI used to generate the binding from.
+// Deprecated: Use LeaseAgreementV2MetaData.ABI instead.
+var LeaseAgreementV2ABI = LeaseAgreementV2MetaData.ABI
+
+// LeaseAgreementV2 is an auto generated Go binding around an Ethereum contract.
+type LeaseAgreementV2 struct {
+	LeaseAgreementV2Caller     // Read-only binding to the contract
+	LeaseAgreementV2Transactor // Write-only binding to the contract
+	LeaseAgreementV2Filterer   // Log filterer for contract events
+}
+
+// LeaseAgreementV2Caller is an auto generated read-only Go binding around an Ethereum contract.
+type LeaseAgreementV2Caller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LeaseAgreementV2Transactor is an auto generated write-only Go binding around an Ethereum contract.
+type LeaseAgreementV2Transactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LeaseAgreementV2Filterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type LeaseAgreementV2Filterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LeaseAgreementV2Session is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type LeaseAgreementV2Session struct {
+	Contract     *LeaseAgreementV2 // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// LeaseAgreementV2CallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type LeaseAgreementV2CallerSession struct {
+	Contract *LeaseAgreementV2Caller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts           // Call options to use throughout this session
+}
+
+// LeaseAgreementV2TransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type LeaseAgreementV2TransactorSession struct {
+	Contract     *LeaseAgreementV2Transactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts           // Transaction auth options to use throughout this session
+}
+
+// LeaseAgreementV2Raw is an auto generated low-level Go binding around an Ethereum contract.
+type LeaseAgreementV2Raw struct {
+	Contract *LeaseAgreementV2 // Generic contract binding to access the raw methods on
+}
+
+// LeaseAgreementV2CallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type LeaseAgreementV2CallerRaw struct {
+	Contract *LeaseAgreementV2Caller // Generic read-only contract binding to access the raw methods on
+}
+
+// LeaseAgreementV2TransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type LeaseAgreementV2TransactorRaw struct {
+	Contract *LeaseAgreementV2Transactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewLeaseAgreementV2 creates a new instance of LeaseAgreementV2, bound to a specific deployed contract.
+func NewLeaseAgreementV2(address common.Address, backend bind.ContractBackend) (*LeaseAgreementV2, error) {
+	contract, err := bindLeaseAgreementV2(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2{LeaseAgreementV2Caller: LeaseAgreementV2Caller{contract: contract}, LeaseAgreementV2Transactor: LeaseAgreementV2Transactor{contract: contract}, LeaseAgreementV2Filterer: LeaseAgreementV2Filterer{contract: contract}}, nil
+}
+
+// NewLeaseAgreementV2Caller creates a new read-only instance of LeaseAgreementV2, bound to a specific deployed contract.
+func NewLeaseAgreementV2Caller(address common.Address, caller bind.ContractCaller) (*LeaseAgreementV2Caller, error) {
+	contract, err := bindLeaseAgreementV2(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2Caller{contract: contract}, nil
+}
+
+// NewLeaseAgreementV2Transactor creates a new write-only instance of LeaseAgreementV2, bound to a specific deployed contract.
+func NewLeaseAgreementV2Transactor(address common.Address, transactor bind.ContractTransactor) (*LeaseAgreementV2Transactor, error) {
+	contract, err := bindLeaseAgreementV2(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2Transactor{contract: contract}, nil
+}
+
+// NewLeaseAgreementV2Filterer creates a new log filterer instance of LeaseAgreementV2, bound to a specific deployed contract.
+func NewLeaseAgreementV2Filterer(address common.Address, filterer bind.ContractFilterer) (*LeaseAgreementV2Filterer, error) {
+	contract, err := bindLeaseAgreementV2(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2Filterer{contract: contract}, nil
+}
+
+// bindLeaseAgreementV2 binds a generic wrapper to an already deployed contract.
+func bindLeaseAgreementV2(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := LeaseAgreementV2MetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_LeaseAgreementV2 *LeaseAgreementV2Raw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _LeaseAgreementV2.Contract.LeaseAgreementV2Caller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_LeaseAgreementV2 *LeaseAgreementV2Raw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.LeaseAgreementV2Transactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_LeaseAgreementV2 *LeaseAgreementV2Raw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.LeaseAgreementV2Transactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _LeaseAgreementV2.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.contract.Transact(opts, method, params...)
+}
+
+// DISPUTEWINDOW is a free data retrieval call binding the contract method 0xf585dc57.
+//
+// Solidity: function DISPUTE_WINDOW() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) DISPUTEWINDOW(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "DISPUTE_WINDOW")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// DISPUTEWINDOW is a free data retrieval call binding the contract method 0xf585dc57.
+//
+// Solidity: function DISPUTE_WINDOW() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) DISPUTEWINDOW() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.DISPUTEWINDOW(&_LeaseAgreementV2.CallOpts)
+}
+
+// DISPUTEWINDOW is a free data retrieval call binding the contract method 0xf585dc57.
+//
+// Solidity: function DISPUTE_WINDOW() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) DISPUTEWINDOW() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.DISPUTEWINDOW(&_LeaseAgreementV2.CallOpts)
+}
+
+// MINPRICE is a free data retrieval call binding the contract method 0xad9f20a6.
+//
+// Solidity: function MIN_PRICE() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) MINPRICE(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "MIN_PRICE")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// MINPRICE is a free data retrieval call binding the contract method 0xad9f20a6.
+//
+// Solidity: function MIN_PRICE() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) MINPRICE() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.MINPRICE(&_LeaseAgreementV2.CallOpts)
+}
+
+// MINPRICE is a free data retrieval call binding the contract method 0xad9f20a6.
+//
+// Solidity: function MIN_PRICE() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) MINPRICE() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.MINPRICE(&_LeaseAgreementV2.CallOpts)
+}
+
+// VERSION is a free data retrieval call binding the contract method 0xffa1ad74.
+//
+// Solidity: function VERSION() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) VERSION(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "VERSION")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// VERSION is a free data retrieval call binding the contract method 0xffa1ad74.
+//
+// Solidity: function VERSION() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) VERSION() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.VERSION(&_LeaseAgreementV2.CallOpts)
+}
+
+// VERSION is a free data retrieval call binding the contract method 0xffa1ad74.
+//
+// Solidity: function VERSION() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) VERSION() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.VERSION(&_LeaseAgreementV2.CallOpts)
+}
+
+// DaoTreasury is a free data retrieval call binding the contract method 0x79022a9f.
+//
+// Solidity: function daoTreasury() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) DaoTreasury(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "daoTreasury")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// DaoTreasury is a free data retrieval call binding the contract method 0x79022a9f.
+//
+// Solidity: function daoTreasury() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) DaoTreasury() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.DaoTreasury(&_LeaseAgreementV2.CallOpts)
+}
+
+// DaoTreasury is a free data retrieval call binding the contract method 0x79022a9f.
+//
+// Solidity: function daoTreasury() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) DaoTreasury() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.DaoTreasury(&_LeaseAgreementV2.CallOpts)
+}
+
+// DisputeStakeRate is a free data retrieval call binding the contract method 0x9daf6bff.
+//
+// Solidity: function disputeStakeRate() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) DisputeStakeRate(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "disputeStakeRate")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// DisputeStakeRate is a free data retrieval call binding the contract method 0x9daf6bff.
+//
+// Solidity: function disputeStakeRate() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) DisputeStakeRate() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.DisputeStakeRate(&_LeaseAgreementV2.CallOpts)
+}
+
+// DisputeStakeRate is a free data retrieval call binding the contract method 0x9daf6bff.
+//
+// Solidity: function disputeStakeRate() view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) DisputeStakeRate() (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.DisputeStakeRate(&_LeaseAgreementV2.CallOpts)
+}
+
+// GetDisputeInfo is a free data retrieval call binding the contract method 0x658977ca.
+//
+// Solidity: function getDisputeInfo(bytes32 leaseId) view returns(uint256 disputeId, address spender, address earner, uint256 leaseIdUint, uint256 timestamp, string reason, bool resolved, bool inFavorOfSpender)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) GetDisputeInfo(opts *bind.CallOpts, leaseId [32]byte) (struct {
+	DisputeId        *big.Int
+	Spender          common.Address
+	Earner           common.Address
+	LeaseIdUint      *big.Int
+	Timestamp        *big.Int
+	Reason           string
+	Resolved         bool
+	InFavorOfSpender bool
+}, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "getDisputeInfo", leaseId)
+
+	outstruct := new(struct {
+		DisputeId        *big.Int
+		Spender          common.Address
+		Earner           common.Address
+		LeaseIdUint      *big.Int
+		Timestamp        *big.Int
+		Reason           string
+		Resolved         bool
+		InFavorOfSpender bool
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.DisputeId = *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+	outstruct.Spender = *abi.ConvertType(out[1], new(common.Address)).(*common.Address)
+	outstruct.Earner = *abi.ConvertType(out[2], new(common.Address)).(*common.Address)
+	outstruct.LeaseIdUint = *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+	outstruct.Timestamp = *abi.ConvertType(out[4], new(*big.Int)).(**big.Int)
+	outstruct.Reason = *abi.ConvertType(out[5], new(string)).(*string)
+	outstruct.Resolved = *abi.ConvertType(out[6], new(bool)).(*bool)
+	outstruct.InFavorOfSpender = *abi.ConvertType(out[7], new(bool)).(*bool)
+
+	return *outstruct, err
+
+}
+
+// GetDisputeInfo is a free data retrieval call binding the contract method 0x658977ca.
+//
+// Solidity: function getDisputeInfo(bytes32 leaseId) view returns(uint256 disputeId, address spender, address earner, uint256 leaseIdUint, uint256 timestamp, string reason, bool resolved, bool inFavorOfSpender)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) GetDisputeInfo(leaseId [32]byte) (struct {
+	DisputeId        *big.Int
+	Spender          common.Address
+	Earner           common.Address
+	LeaseIdUint      *big.Int
+	Timestamp        *big.Int
+	Reason           string
+	Resolved         bool
+	InFavorOfSpender bool
+}, error) {
+	return _LeaseAgreementV2.Contract.GetDisputeInfo(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// GetDisputeInfo is a free data retrieval call binding the contract method 0x658977ca.
+//
+// Solidity: function getDisputeInfo(bytes32 leaseId) view returns(uint256 disputeId, address spender, address earner, uint256 leaseIdUint, uint256 timestamp, string reason, bool resolved, bool inFavorOfSpender)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) GetDisputeInfo(leaseId [32]byte) (struct {
+	DisputeId        *big.Int
+	Spender          common.Address
+	Earner           common.Address
+	LeaseIdUint      *big.Int
+	Timestamp        *big.Int
+	Reason           string
+	Resolved         bool
+	InFavorOfSpender bool
+}, error) {
+	return _LeaseAgreementV2.Contract.GetDisputeInfo(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// GetLease is a free data retrieval call binding the contract method 0x2d54ad30.
+//
+// Solidity: function getLease(bytes32 leaseId) view returns((address,address,bytes32,uint256,uint256,bool,bool,bool,bool,uint256,uint256,uint256,uint256))
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) GetLease(opts *bind.CallOpts, leaseId [32]byte) (LeaseAgreementV2Lease, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "getLease", leaseId)
+
+	if err != nil {
+		return *new(LeaseAgreementV2Lease), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(LeaseAgreementV2Lease)).(*LeaseAgreementV2Lease)
+
+	return out0, err
+
+}
+
+// GetLease is a free data retrieval call binding the contract method 0x2d54ad30.
+//
+// Solidity: function getLease(bytes32 leaseId) view returns((address,address,bytes32,uint256,uint256,bool,bool,bool,bool,uint256,uint256,uint256,uint256))
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) GetLease(leaseId [32]byte) (LeaseAgreementV2Lease, error) {
+	return _LeaseAgreementV2.Contract.GetLease(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// GetLease is a free data retrieval call binding the contract method 0x2d54ad30.
+//
+// Solidity: function getLease(bytes32 leaseId) view returns((address,address,bytes32,uint256,uint256,bool,bool,bool,bool,uint256,uint256,uint256,uint256))
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) GetLease(leaseId [32]byte) (LeaseAgreementV2Lease, error) {
+	return _LeaseAgreementV2.Contract.GetLease(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// GetRequiredStake is a free data retrieval call binding the contract method 0x1b72bfbd.
+//
+// Solidity: function getRequiredStake(bytes32 leaseId) view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) GetRequiredStake(opts *bind.CallOpts, leaseId [32]byte) (*big.Int, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "getRequiredStake", leaseId)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// GetRequiredStake is a free data retrieval call binding the contract method 0x1b72bfbd.
+//
+// Solidity: function getRequiredStake(bytes32 leaseId) view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) GetRequiredStake(leaseId [32]byte) (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.GetRequiredStake(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// GetRequiredStake is a free data retrieval call binding the contract method 0x1b72bfbd.
+//
+// Solidity: function getRequiredStake(bytes32 leaseId) view returns(uint256)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) GetRequiredStake(leaseId [32]byte) (*big.Int, error) {
+	return _LeaseAgreementV2.Contract.GetRequiredStake(&_LeaseAgreementV2.CallOpts, leaseId)
+}
+
+// LeaseExists is a free data retrieval call binding the contract method 0x39056e4e.
+//
+// Solidity: function leaseExists(bytes32 ) view returns(bool)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) LeaseExists(opts *bind.CallOpts, arg0 [32]byte) (bool, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "leaseExists", arg0)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// LeaseExists is a free data retrieval call binding the contract method 0x39056e4e.
+//
+// Solidity: function leaseExists(bytes32 ) view returns(bool)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) LeaseExists(arg0 [32]byte) (bool, error) {
+	return _LeaseAgreementV2.Contract.LeaseExists(&_LeaseAgreementV2.CallOpts, arg0)
+}
+
+// LeaseExists is a free data retrieval call binding the contract method 0x39056e4e.
+//
+// Solidity: function leaseExists(bytes32 ) view returns(bool)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) LeaseExists(arg0 [32]byte) (bool, error) {
+	return _LeaseAgreementV2.Contract.LeaseExists(&_LeaseAgreementV2.CallOpts, arg0)
+}
+
+// Leases is a free data retrieval call binding the contract method 0x1839a5a3.
+//
+// Solidity: function leases(bytes32 ) view returns(address spender, address earner, bytes32 dataProductId, uint256 price, uint256 maxPrice, bool isApproved, bool isExecuted, bool isDisputed, bool isFinalized, uint256 createdAt, uint256 executedAt, uint256 disputeId, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) Leases(opts *bind.CallOpts, arg0 [32]byte) (struct {
+	Spender       common.Address
+	Earner        common.Address
+	DataProductId [32]byte
+	Price         *big.Int
+	MaxPrice      *big.Int
+	IsApproved    bool
+	IsExecuted    bool
+	IsDisputed    bool
+	IsFinalized   bool
+	CreatedAt     *big.Int
+	ExecutedAt    *big.Int
+	DisputeId     *big.Int
+	StakeAmount   *big.Int
+}, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "leases", arg0)
+
+	outstruct := new(struct {
+		Spender       common.Address
+		Earner        common.Address
+		DataProductId [32]byte
+		Price         *big.Int
+		MaxPrice      *big.Int
+		IsApproved    bool
+		IsExecuted    bool
+		IsDisputed    bool
+		IsFinalized   bool
+		CreatedAt     *big.Int
+		ExecutedAt    *big.Int
+		DisputeId     *big.Int
+		StakeAmount   *big.Int
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.Spender = *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+	outstruct.Earner = *abi.ConvertType(out[1], new(common.Address)).(*common.Address)
+	outstruct.DataProductId = *abi.ConvertType(out[2], new([32]byte)).(*[32]byte)
+	outstruct.Price = *abi.ConvertType(out[3], new(*big.Int)).(**big.Int)
+	outstruct.MaxPrice = *abi.ConvertType(out[4], new(*big.Int)).(**big.Int)
+	outstruct.IsApproved = *abi.ConvertType(out[5], new(bool)).(*bool)
+	outstruct.IsExecuted = *abi.ConvertType(out[6], new(bool)).(*bool)
+	outstruct.IsDisputed = *abi.ConvertType(out[7], new(bool)).(*bool)
+	outstruct.IsFinalized = *abi.ConvertType(out[8], new(bool)).(*bool)
+	outstruct.CreatedAt = *abi.ConvertType(out[9], new(*big.Int)).(**big.Int)
+	outstruct.ExecutedAt = *abi.ConvertType(out[10], new(*big.Int)).(**big.Int)
+	outstruct.DisputeId = *abi.ConvertType(out[11], new(*big.Int)).(**big.Int)
+	outstruct.StakeAmount = *abi.ConvertType(out[12], new(*big.Int)).(**big.Int)
+
+	return *outstruct, err
+
+}
+
+// Leases is a free data retrieval call binding the contract method 0x1839a5a3.
+//
+// Solidity: function leases(bytes32 ) view returns(address spender, address earner, bytes32 dataProductId, uint256 price, uint256 maxPrice, bool isApproved, bool isExecuted, bool isDisputed, bool isFinalized, uint256 createdAt, uint256 executedAt, uint256 disputeId, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) Leases(arg0 [32]byte) (struct {
+	Spender       common.Address
+	Earner        common.Address
+	DataProductId [32]byte
+	Price         *big.Int
+	MaxPrice      *big.Int
+	IsApproved    bool
+	IsExecuted    bool
+	IsDisputed    bool
+	IsFinalized   bool
+	CreatedAt     *big.Int
+	ExecutedAt    *big.Int
+	DisputeId     *big.Int
+	StakeAmount   *big.Int
+}, error) {
+	return _LeaseAgreementV2.Contract.Leases(&_LeaseAgreementV2.CallOpts, arg0)
+}
+
+// Leases is a free data retrieval call binding the contract method 0x1839a5a3.
+//
+// Solidity: function leases(bytes32 ) view returns(address spender, address earner, bytes32 dataProductId, uint256 price, uint256 maxPrice, bool isApproved, bool isExecuted, bool isDisputed, bool isFinalized, uint256 createdAt, uint256 executedAt, uint256 disputeId, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) Leases(arg0 [32]byte) (struct {
+	Spender       common.Address
+	Earner        common.Address
+	DataProductId [32]byte
+	Price         *big.Int
+	MaxPrice      *big.Int
+	IsApproved    bool
+	IsExecuted    bool
+	IsDisputed    bool
+	IsFinalized   bool
+	CreatedAt     *big.Int
+	ExecutedAt    *big.Int
+	DisputeId     *big.Int
+	StakeAmount   *big.Int
+}, error) {
+	return _LeaseAgreementV2.Contract.Leases(&_LeaseAgreementV2.CallOpts, arg0)
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) Owner(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "owner")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) Owner() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.Owner(&_LeaseAgreementV2.CallOpts)
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) Owner() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.Owner(&_LeaseAgreementV2.CallOpts)
+}
+
+// PgtToken is a free data retrieval call binding the contract method 0x44a503d1.
+//
+// Solidity: function pgtToken() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) PgtToken(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "pgtToken")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// PgtToken is a free data retrieval call binding the contract method 0x44a503d1.
+//
+// Solidity: function pgtToken() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) PgtToken() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.PgtToken(&_LeaseAgreementV2.CallOpts)
+}
+
+// PgtToken is a free data retrieval call binding the contract method 0x44a503d1.
+//
+// Solidity: function pgtToken() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) PgtToken() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.PgtToken(&_LeaseAgreementV2.CallOpts)
+}
+
+// ReputationContract is a free data retrieval call binding the contract method 0x87bc1425.
+//
+// Solidity: function reputationContract() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Caller) ReputationContract(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _LeaseAgreementV2.contract.Call(opts, &out, "reputationContract")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// ReputationContract is a free data retrieval call binding the contract method 0x87bc1425.
+//
+// Solidity: function reputationContract() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) ReputationContract() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.ReputationContract(&_LeaseAgreementV2.CallOpts)
+}
+
+// ReputationContract is a free data retrieval call binding the contract method 0x87bc1425.
+//
+// Solidity: function reputationContract() view returns(address)
+func (_LeaseAgreementV2 *LeaseAgreementV2CallerSession) ReputationContract() (common.Address, error) {
+	return _LeaseAgreementV2.Contract.ReputationContract(&_LeaseAgreementV2.CallOpts)
+}
+
+// ApproveLease is a paid mutator transaction binding the contract method 0x9657e610.
+//
+// Solidity: function approveLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) ApproveLease(opts *bind.TransactOpts, leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "approveLease", leaseId)
+}
+
+// ApproveLease is a paid mutator transaction binding the contract method 0x9657e610.
+//
+// Solidity: function approveLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) ApproveLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ApproveLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// ApproveLease is a paid mutator transaction binding the contract method 0x9657e610.
+//
+// Solidity: function approveLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) ApproveLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ApproveLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// CreateLease is a paid mutator transaction binding the contract method 0x0971b7a5.
+//
+// Solidity: function createLease(address earner, bytes32 dataProductId, uint256 maxPrice) payable returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) CreateLease(opts *bind.TransactOpts, earner common.Address, dataProductId [32]byte, maxPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "createLease", earner, dataProductId, maxPrice)
+}
+
+// CreateLease is a paid mutator transaction binding the contract method 0x0971b7a5.
+//
+// Solidity: function createLease(address earner, bytes32 dataProductId, uint256 maxPrice) payable returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) CreateLease(earner common.Address, dataProductId [32]byte, maxPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.CreateLease(&_LeaseAgreementV2.TransactOpts, earner, dataProductId, maxPrice)
+}
+
+// CreateLease is a paid mutator transaction binding the contract method 0x0971b7a5.
+//
+// Solidity: function createLease(address earner, bytes32 dataProductId, uint256 maxPrice) payable returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) CreateLease(earner common.Address, dataProductId [32]byte, maxPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.CreateLease(&_LeaseAgreementV2.TransactOpts, earner, dataProductId, maxPrice)
+}
+
+// EmergencyPause is a paid mutator transaction binding the contract method 0x51858e27.
+//
+// Solidity: function emergencyPause() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) EmergencyPause(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "emergencyPause")
+}
+
+// EmergencyPause is a paid mutator transaction binding the contract method 0x51858e27.
+//
+// Solidity: function emergencyPause() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) EmergencyPause() (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.EmergencyPause(&_LeaseAgreementV2.TransactOpts)
+}
+
+// EmergencyPause is a paid mutator transaction binding the contract method 0x51858e27.
+//
+// Solidity: function emergencyPause() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) EmergencyPause() (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.EmergencyPause(&_LeaseAgreementV2.TransactOpts)
+}
+
+// ExecuteLease is a paid mutator transaction binding the contract method 0x71f05382.
+//
+// Solidity: function executeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) ExecuteLease(opts *bind.TransactOpts, leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "executeLease", leaseId)
+}
+
+// ExecuteLease is a paid mutator transaction binding the contract method 0x71f05382.
+//
+// Solidity: function executeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) ExecuteLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ExecuteLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// ExecuteLease is a paid mutator transaction binding the contract method 0x71f05382.
+//
+// Solidity: function executeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) ExecuteLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ExecuteLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// FinalizeLease is a paid mutator transaction binding the contract method 0xc9ae606c.
+//
+// Solidity: function finalizeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) FinalizeLease(opts *bind.TransactOpts, leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "finalizeLease", leaseId)
+}
+
+// FinalizeLease is a paid mutator transaction binding the contract method 0xc9ae606c.
+//
+// Solidity: function finalizeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) FinalizeLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.FinalizeLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// FinalizeLease is a paid mutator transaction binding the contract method 0xc9ae606c.
+//
+// Solidity: function finalizeLease(bytes32 leaseId) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) FinalizeLease(leaseId [32]byte) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.FinalizeLease(&_LeaseAgreementV2.TransactOpts, leaseId)
+}
+
+// RaiseDispute is a paid mutator transaction binding the contract method 0xbe7b8a77.
+//
+// Solidity: function raiseDispute(bytes32 leaseId, string reason) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) RaiseDispute(opts *bind.TransactOpts, leaseId [32]byte, reason string) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "raiseDispute", leaseId, reason)
+}
+
+// RaiseDispute is a paid mutator transaction binding the contract method 0xbe7b8a77.
+//
+// Solidity: function raiseDispute(bytes32 leaseId, string reason) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) RaiseDispute(leaseId [32]byte, reason string) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.RaiseDispute(&_LeaseAgreementV2.TransactOpts, leaseId, reason)
+}
+
+// RaiseDispute is a paid mutator transaction binding the contract method 0xbe7b8a77.
+//
+// Solidity: function raiseDispute(bytes32 leaseId, string reason) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) RaiseDispute(leaseId [32]byte, reason string) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.RaiseDispute(&_LeaseAgreementV2.TransactOpts, leaseId, reason)
+}
+
+// RenounceOwnership is a paid mutator transaction binding the contract method 0x715018a6.
+//
+// Solidity: function renounceOwnership() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) RenounceOwnership(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "renounceOwnership")
+}
+
+// RenounceOwnership is a paid mutator transaction binding the contract method 0x715018a6.
+//
+// Solidity: function renounceOwnership() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) RenounceOwnership() (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.RenounceOwnership(&_LeaseAgreementV2.TransactOpts)
+}
+
+// RenounceOwnership is a paid mutator transaction binding the contract method 0x715018a6.
+//
+// Solidity: function renounceOwnership() returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) RenounceOwnership() (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.RenounceOwnership(&_LeaseAgreementV2.TransactOpts)
+}
+
+// ResolveDispute is a paid mutator transaction binding the contract method 0x43a0e3e6.
+//
+// Solidity: function resolveDispute(bytes32 leaseId, bool isDisputeValid) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) ResolveDispute(opts *bind.TransactOpts, leaseId [32]byte, isDisputeValid bool) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "resolveDispute", leaseId, isDisputeValid)
+}
+
+// ResolveDispute is a paid mutator transaction binding the contract method 0x43a0e3e6.
+//
+// Solidity: function resolveDispute(bytes32 leaseId, bool isDisputeValid) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) ResolveDispute(leaseId [32]byte, isDisputeValid bool) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ResolveDispute(&_LeaseAgreementV2.TransactOpts, leaseId, isDisputeValid)
+}
+
+// ResolveDispute is a paid mutator transaction binding the contract method 0x43a0e3e6.
+//
+// Solidity: function resolveDispute(bytes32 leaseId, bool isDisputeValid) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) ResolveDispute(leaseId [32]byte, isDisputeValid bool) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.ResolveDispute(&_LeaseAgreementV2.TransactOpts, leaseId, isDisputeValid)
+}
+
+// SetDisputeStakeRate is a paid mutator transaction binding the contract method 0x8321d9a9.
+//
+// Solidity: function setDisputeStakeRate(uint256 newRate) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) SetDisputeStakeRate(opts *bind.TransactOpts, newRate *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "setDisputeStakeRate", newRate)
+}
+
+// SetDisputeStakeRate is a paid mutator transaction binding the contract method 0x8321d9a9.
+//
+// Solidity: function setDisputeStakeRate(uint256 newRate) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) SetDisputeStakeRate(newRate *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.SetDisputeStakeRate(&_LeaseAgreementV2.TransactOpts, newRate)
+}
+
+// SetDisputeStakeRate is a paid mutator transaction binding the contract method 0x8321d9a9.
+//
+// Solidity: function setDisputeStakeRate(uint256 newRate) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) SetDisputeStakeRate(newRate *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.SetDisputeStakeRate(&_LeaseAgreementV2.TransactOpts, newRate)
+}
+
+// TransferOwnership is a paid mutator transaction binding the contract method 0xf2fde38b.
+//
+// Solidity: function transferOwnership(address newOwner) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) TransferOwnership(opts *bind.TransactOpts, newOwner common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "transferOwnership", newOwner)
+}
+
+// TransferOwnership is a paid mutator transaction binding the contract method 0xf2fde38b.
+//
+// Solidity: function transferOwnership(address newOwner) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) TransferOwnership(newOwner common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.TransferOwnership(&_LeaseAgreementV2.TransactOpts, newOwner)
+}
+
+// TransferOwnership is a paid mutator transaction binding the contract method 0xf2fde38b.
+//
+// Solidity: function transferOwnership(address newOwner) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) TransferOwnership(newOwner common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.TransferOwnership(&_LeaseAgreementV2.TransactOpts, newOwner)
+}
+
+// UpdateDaoTreasury is a paid mutator transaction binding the contract method 0x0dd4ed1e.
+//
+// Solidity: function updateDaoTreasury(address newDaoTreasury) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) UpdateDaoTreasury(opts *bind.TransactOpts, newDaoTreasury common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "updateDaoTreasury", newDaoTreasury)
+}
+
+// UpdateDaoTreasury is a paid mutator transaction binding the contract method 0x0dd4ed1e.
+//
+// Solidity: function updateDaoTreasury(address newDaoTreasury) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) UpdateDaoTreasury(newDaoTreasury common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateDaoTreasury(&_LeaseAgreementV2.TransactOpts, newDaoTreasury)
+}
+
+// UpdateDaoTreasury is a paid mutator transaction binding the contract method 0x0dd4ed1e.
+//
+// Solidity: function updateDaoTreasury(address newDaoTreasury) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) UpdateDaoTreasury(newDaoTreasury common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateDaoTreasury(&_LeaseAgreementV2.TransactOpts, newDaoTreasury)
+}
+
+// UpdateMinPrice is a paid mutator transaction binding the contract method 0x0539fcd1.
+//
+// Solidity: function updateMinPrice(uint256 newMinPrice) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) UpdateMinPrice(opts *bind.TransactOpts, newMinPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "updateMinPrice", newMinPrice)
+}
+
+// UpdateMinPrice is a paid mutator transaction binding the contract method 0x0539fcd1.
+//
+// Solidity: function updateMinPrice(uint256 newMinPrice) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) UpdateMinPrice(newMinPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateMinPrice(&_LeaseAgreementV2.TransactOpts, newMinPrice)
+}
+
+// UpdateMinPrice is a paid mutator transaction binding the contract method 0x0539fcd1.
+//
+// Solidity: function updateMinPrice(uint256 newMinPrice) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) UpdateMinPrice(newMinPrice *big.Int) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateMinPrice(&_LeaseAgreementV2.TransactOpts, newMinPrice)
+}
+
+// UpdatePgtToken is a paid mutator transaction binding the contract method 0xd8339cb9.
+//
+// Solidity: function updatePgtToken(address newPgtToken) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) UpdatePgtToken(opts *bind.TransactOpts, newPgtToken common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "updatePgtToken", newPgtToken)
+}
+
+// UpdatePgtToken is a paid mutator transaction binding the contract method 0xd8339cb9.
+//
+// Solidity: function updatePgtToken(address newPgtToken) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) UpdatePgtToken(newPgtToken common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdatePgtToken(&_LeaseAgreementV2.TransactOpts, newPgtToken)
+}
+
+// UpdatePgtToken is a paid mutator transaction binding the contract method 0xd8339cb9.
+//
+// Solidity: function updatePgtToken(address newPgtToken) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) UpdatePgtToken(newPgtToken common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdatePgtToken(&_LeaseAgreementV2.TransactOpts, newPgtToken)
+}
+
+// UpdateReputationContract is a paid mutator transaction binding the contract method 0x905baa61.
+//
+// Solidity: function updateReputationContract(address newReputationContract) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Transactor) UpdateReputationContract(opts *bind.TransactOpts, newReputationContract common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.contract.Transact(opts, "updateReputationContract", newReputationContract)
+}
+
+// UpdateReputationContract is a paid mutator transaction binding the contract method 0x905baa61.
+//
+// Solidity: function updateReputationContract(address newReputationContract) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2Session) UpdateReputationContract(newReputationContract common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateReputationContract(&_LeaseAgreementV2.TransactOpts, newReputationContract)
+}
+
+// UpdateReputationContract is a paid mutator transaction binding the contract method 0x905baa61.
+//
+// Solidity: function updateReputationContract(address newReputationContract) returns()
+func (_LeaseAgreementV2 *LeaseAgreementV2TransactorSession) UpdateReputationContract(newReputationContract common.Address) (*types.Transaction, error) {
+	return _LeaseAgreementV2.Contract.UpdateReputationContract(&_LeaseAgreementV2.TransactOpts, newReputationContract)
+}
+
+// LeaseAgreementV2DisputeRaisedIterator is returned from FilterDisputeRaised and is used to iterate over the raw logs and unpacked data for DisputeRaised events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeRaisedIterator struct {
+	Event *LeaseAgreementV2DisputeRaised // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2DisputeRaisedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2DisputeRaised)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2DisputeRaised)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2DisputeRaisedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2DisputeRaisedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2DisputeRaised represents a DisputeRaised event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeRaised struct {
+	LeaseId     [32]byte
+	Spender     common.Address
+	Earner      common.Address
+	Reason      string
+	StakeAmount *big.Int
+	Raw         types.Log // Blockchain specific contextual infos
+}
+
+// FilterDisputeRaised is a free log retrieval operation binding the contract event 0x5e367517f9ce9894d24553a0c409409c1147c685cbee1e9810445f1e4946d7f9.
+//
+// Solidity: event DisputeRaised(bytes32 indexed leaseId, address indexed spender, address indexed earner, string reason, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterDisputeRaised(opts *bind.FilterOpts, leaseId [][32]byte, spender []common.Address, earner []common.Address) (*LeaseAgreementV2DisputeRaisedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var spenderRule []interface{}
+	for _, spenderItem := range spender {
+		spenderRule = append(spenderRule, spenderItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "DisputeRaised", leaseIdRule, spenderRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2DisputeRaisedIterator{contract: _LeaseAgreementV2.contract, event: "DisputeRaised", logs: logs, sub: sub}, nil
+}
+
+// WatchDisputeRaised is a free log subscription operation binding the contract event 0x5e367517f9ce9894d24553a0c409409c1147c685cbee1e9810445f1e4946d7f9.
+//
+// Solidity: event DisputeRaised(bytes32 indexed leaseId, address indexed spender, address indexed earner, string reason, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchDisputeRaised(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2DisputeRaised, leaseId [][32]byte, spender []common.Address, earner []common.Address) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var spenderRule []interface{}
+	for _, spenderItem := range spender {
+		spenderRule = append(spenderRule, spenderItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "DisputeRaised", leaseIdRule, spenderRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2DisputeRaised)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeRaised", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDisputeRaised is a log parse operation binding the contract event 0x5e367517f9ce9894d24553a0c409409c1147c685cbee1e9810445f1e4946d7f9.
+//
+// Solidity: event DisputeRaised(bytes32 indexed leaseId, address indexed spender, address indexed earner, string reason, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseDisputeRaised(log types.Log) (*LeaseAgreementV2DisputeRaised, error) {
+	event := new(LeaseAgreementV2DisputeRaised)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeRaised", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2DisputeResolvedIterator is returned from FilterDisputeResolved and is used to iterate over the raw logs and unpacked data for DisputeResolved events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeResolvedIterator struct {
+	Event *LeaseAgreementV2DisputeResolved // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2DisputeResolvedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2DisputeResolved)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2DisputeResolved)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2DisputeResolvedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2DisputeResolvedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2DisputeResolved represents a DisputeResolved event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeResolved struct {
+	LeaseId        [32]byte
+	IsDisputeValid bool
+	StakeAmount    *big.Int
+	Raw            types.Log // Blockchain specific contextual infos
+}
+
+// FilterDisputeResolved is a free log retrieval operation binding the contract event 0x5a5ac8b853b549bf2485e24863e0628003e891a2b72367623dd0b1c324eba8f9.
+//
+// Solidity: event DisputeResolved(bytes32 indexed leaseId, bool isDisputeValid, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterDisputeResolved(opts *bind.FilterOpts, leaseId [][32]byte) (*LeaseAgreementV2DisputeResolvedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "DisputeResolved", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2DisputeResolvedIterator{contract: _LeaseAgreementV2.contract, event: "DisputeResolved", logs: logs, sub: sub}, nil
+}
+
+// WatchDisputeResolved is a free log subscription operation binding the contract event 0x5a5ac8b853b549bf2485e24863e0628003e891a2b72367623dd0b1c324eba8f9.
+//
+// Solidity: event DisputeResolved(bytes32 indexed leaseId, bool isDisputeValid, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchDisputeResolved(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2DisputeResolved, leaseId [][32]byte) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "DisputeResolved", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2DisputeResolved)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeResolved", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDisputeResolved is a log parse operation binding the contract event 0x5a5ac8b853b549bf2485e24863e0628003e891a2b72367623dd0b1c324eba8f9.
+//
+// Solidity: event DisputeResolved(bytes32 indexed leaseId, bool isDisputeValid, uint256 stakeAmount)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseDisputeResolved(log types.Log) (*LeaseAgreementV2DisputeResolved, error) {
+	event := new(LeaseAgreementV2DisputeResolved)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeResolved", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2DisputeStakeRateUpdatedIterator is returned from FilterDisputeStakeRateUpdated and is used to iterate over the raw logs and unpacked data for DisputeStakeRateUpdated events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeStakeRateUpdatedIterator struct {
+	Event *LeaseAgreementV2DisputeStakeRateUpdated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2DisputeStakeRateUpdatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2DisputeStakeRateUpdated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2DisputeStakeRateUpdated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2DisputeStakeRateUpdatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2DisputeStakeRateUpdatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2DisputeStakeRateUpdated represents a DisputeStakeRateUpdated event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2DisputeStakeRateUpdated struct {
+	OldRate *big.Int
+	NewRate *big.Int
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// FilterDisputeStakeRateUpdated is a free log retrieval operation binding the contract event 0x4c530aed19d20371c2cece19bfe990a112b6db09166d7d5bf00e844f130a5f62.
+//
+// Solidity: event DisputeStakeRateUpdated(uint256 oldRate, uint256 newRate)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterDisputeStakeRateUpdated(opts *bind.FilterOpts) (*LeaseAgreementV2DisputeStakeRateUpdatedIterator, error) {
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "DisputeStakeRateUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2DisputeStakeRateUpdatedIterator{contract: _LeaseAgreementV2.contract, event: "DisputeStakeRateUpdated", logs: logs, sub: sub}, nil
+}
+
+// WatchDisputeStakeRateUpdated is a free log subscription operation binding the contract event 0x4c530aed19d20371c2cece19bfe990a112b6db09166d7d5bf00e844f130a5f62.
+//
+// Solidity: event DisputeStakeRateUpdated(uint256 oldRate, uint256 newRate)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchDisputeStakeRateUpdated(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2DisputeStakeRateUpdated) (event.Subscription, error) {
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "DisputeStakeRateUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2DisputeStakeRateUpdated)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeStakeRateUpdated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDisputeStakeRateUpdated is a log parse operation binding the contract event 0x4c530aed19d20371c2cece19bfe990a112b6db09166d7d5bf00e844f130a5f62.
+//
+// Solidity: event DisputeStakeRateUpdated(uint256 oldRate, uint256 newRate)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseDisputeStakeRateUpdated(log types.Log) (*LeaseAgreementV2DisputeStakeRateUpdated, error) {
+	event := new(LeaseAgreementV2DisputeStakeRateUpdated)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "DisputeStakeRateUpdated", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2LeaseApprovedIterator is returned from FilterLeaseApproved and is used to iterate over the raw logs and unpacked data for LeaseApproved events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseApprovedIterator struct {
+	Event *LeaseAgreementV2LeaseApproved // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2LeaseApprovedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2LeaseApproved)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2LeaseApproved)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2LeaseApprovedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2LeaseApprovedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2LeaseApproved represents a LeaseApproved event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseApproved struct {
+	LeaseId [32]byte
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// FilterLeaseApproved is a free log retrieval operation binding the contract event 0xdc60d58f59485d15206729655c62ed7264bf00381216c4aac797c6e9456c5098.
+//
+// Solidity: event LeaseApproved(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterLeaseApproved(opts *bind.FilterOpts, leaseId [][32]byte) (*LeaseAgreementV2LeaseApprovedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "LeaseApproved", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2LeaseApprovedIterator{contract: _LeaseAgreementV2.contract, event: "LeaseApproved", logs: logs, sub: sub}, nil
+}
+
+// WatchLeaseApproved is a free log subscription operation binding the contract event 0xdc60d58f59485d15206729655c62ed7264bf00381216c4aac797c6e9456c5098.
+//
+// Solidity: event LeaseApproved(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchLeaseApproved(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2LeaseApproved, leaseId [][32]byte) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "LeaseApproved", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2LeaseApproved)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseApproved", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseLeaseApproved is a log parse operation binding the contract event 0xdc60d58f59485d15206729655c62ed7264bf00381216c4aac797c6e9456c5098.
+//
+// Solidity: event LeaseApproved(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseLeaseApproved(log types.Log) (*LeaseAgreementV2LeaseApproved, error) {
+	event := new(LeaseAgreementV2LeaseApproved)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseApproved", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2LeaseCreatedIterator is returned from FilterLeaseCreated and is used to iterate over the raw logs and unpacked data for LeaseCreated events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseCreatedIterator struct {
+	Event *LeaseAgreementV2LeaseCreated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2LeaseCreatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2LeaseCreated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2LeaseCreated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2LeaseCreatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2LeaseCreatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2LeaseCreated represents a LeaseCreated event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseCreated struct {
+	LeaseId [32]byte
+	Spender common.Address
+	Earner  common.Address
+	Price   *big.Int
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// FilterLeaseCreated is a free log retrieval operation binding the contract event 0x57acec708e7a7b4437dd4bb623ed53257e444ac360b3fb2ddf9f157ad13e98a0.
+//
+// Solidity: event LeaseCreated(bytes32 indexed leaseId, address indexed spender, address indexed earner, uint256 price)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterLeaseCreated(opts *bind.FilterOpts, leaseId [][32]byte, spender []common.Address, earner []common.Address) (*LeaseAgreementV2LeaseCreatedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var spenderRule []interface{}
+	for _, spenderItem := range spender {
+		spenderRule = append(spenderRule, spenderItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "LeaseCreated", leaseIdRule, spenderRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2LeaseCreatedIterator{contract: _LeaseAgreementV2.contract, event: "LeaseCreated", logs: logs, sub: sub}, nil
+}
+
+// WatchLeaseCreated is a free log subscription operation binding the contract event 0x57acec708e7a7b4437dd4bb623ed53257e444ac360b3fb2ddf9f157ad13e98a0.
+//
+// Solidity: event LeaseCreated(bytes32 indexed leaseId, address indexed spender, address indexed earner, uint256 price)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchLeaseCreated(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2LeaseCreated, leaseId [][32]byte, spender []common.Address, earner []common.Address) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var spenderRule []interface{}
+	for _, spenderItem := range spender {
+		spenderRule = append(spenderRule, spenderItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "LeaseCreated", leaseIdRule, spenderRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2LeaseCreated)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseCreated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseLeaseCreated is a log parse operation binding the contract event 0x57acec708e7a7b4437dd4bb623ed53257e444ac360b3fb2ddf9f157ad13e98a0.
+//
+// Solidity: event LeaseCreated(bytes32 indexed leaseId, address indexed spender, address indexed earner, uint256 price)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseLeaseCreated(log types.Log) (*LeaseAgreementV2LeaseCreated, error) {
+	event := new(LeaseAgreementV2LeaseCreated)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseCreated", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2LeaseExecutedIterator is returned from FilterLeaseExecuted and is used to iterate over the raw logs and unpacked data for LeaseExecuted events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseExecutedIterator struct {
+	Event *LeaseAgreementV2LeaseExecuted // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2LeaseExecutedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2LeaseExecuted)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2LeaseExecuted)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2LeaseExecutedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2LeaseExecutedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2LeaseExecuted represents a LeaseExecuted event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseExecuted struct {
+	LeaseId [32]byte
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// FilterLeaseExecuted is a free log retrieval operation binding the contract event 0x49f31d6396af3c8a43db622e5ce305ec7c4a0345ba95cc5429c5c36324a228f6.
+//
+// Solidity: event LeaseExecuted(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterLeaseExecuted(opts *bind.FilterOpts, leaseId [][32]byte) (*LeaseAgreementV2LeaseExecutedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "LeaseExecuted", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2LeaseExecutedIterator{contract: _LeaseAgreementV2.contract, event: "LeaseExecuted", logs: logs, sub: sub}, nil
+}
+
+// WatchLeaseExecuted is a free log subscription operation binding the contract event 0x49f31d6396af3c8a43db622e5ce305ec7c4a0345ba95cc5429c5c36324a228f6.
+//
+// Solidity: event LeaseExecuted(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchLeaseExecuted(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2LeaseExecuted, leaseId [][32]byte) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "LeaseExecuted", leaseIdRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2LeaseExecuted)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseExecuted", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseLeaseExecuted is a log parse operation binding the contract event 0x49f31d6396af3c8a43db622e5ce305ec7c4a0345ba95cc5429c5c36324a228f6.
+//
+// Solidity: event LeaseExecuted(bytes32 indexed leaseId)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseLeaseExecuted(log types.Log) (*LeaseAgreementV2LeaseExecuted, error) {
+	event := new(LeaseAgreementV2LeaseExecuted)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseExecuted", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2LeaseFinalizedIterator is returned from FilterLeaseFinalized and is used to iterate over the raw logs and unpacked data for LeaseFinalized events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseFinalizedIterator struct {
+	Event *LeaseAgreementV2LeaseFinalized // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2LeaseFinalizedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2LeaseFinalized)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2LeaseFinalized)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2LeaseFinalizedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2LeaseFinalizedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2LeaseFinalized represents a LeaseFinalized event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2LeaseFinalized struct {
+	LeaseId          [32]byte
+	Earner           common.Address
+	ReputationReward *big.Int
+	Raw              types.Log // Blockchain specific contextual infos
+}
+
+// FilterLeaseFinalized is a free log retrieval operation binding the contract event 0xa8389217136d4f9ff87ed20e0564c248f56ac469870421ee242fad7a421aba66.
+//
+// Solidity: event LeaseFinalized(bytes32 indexed leaseId, address indexed earner, uint256 reputationReward)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterLeaseFinalized(opts *bind.FilterOpts, leaseId [][32]byte, earner []common.Address) (*LeaseAgreementV2LeaseFinalizedIterator, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "LeaseFinalized", leaseIdRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2LeaseFinalizedIterator{contract: _LeaseAgreementV2.contract, event: "LeaseFinalized", logs: logs, sub: sub}, nil
+}
+
+// WatchLeaseFinalized is a free log subscription operation binding the contract event 0xa8389217136d4f9ff87ed20e0564c248f56ac469870421ee242fad7a421aba66.
+//
+// Solidity: event LeaseFinalized(bytes32 indexed leaseId, address indexed earner, uint256 reputationReward)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchLeaseFinalized(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2LeaseFinalized, leaseId [][32]byte, earner []common.Address) (event.Subscription, error) {
+
+	var leaseIdRule []interface{}
+	for _, leaseIdItem := range leaseId {
+		leaseIdRule = append(leaseIdRule, leaseIdItem)
+	}
+	var earnerRule []interface{}
+	for _, earnerItem := range earner {
+		earnerRule = append(earnerRule, earnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "LeaseFinalized", leaseIdRule, earnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2LeaseFinalized)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseFinalized", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseLeaseFinalized is a log parse operation binding the contract event 0xa8389217136d4f9ff87ed20e0564c248f56ac469870421ee242fad7a421aba66.
+//
+// Solidity: event LeaseFinalized(bytes32 indexed leaseId, address indexed earner, uint256 reputationReward)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseLeaseFinalized(log types.Log) (*LeaseAgreementV2LeaseFinalized, error) {
+	event := new(LeaseAgreementV2LeaseFinalized)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "LeaseFinalized", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// LeaseAgreementV2OwnershipTransferredIterator is returned from FilterOwnershipTransferred and is used to iterate over the raw logs and unpacked data for OwnershipTransferred events raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2OwnershipTransferredIterator struct {
+	Event *LeaseAgreementV2OwnershipTransferred // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *LeaseAgreementV2OwnershipTransferredIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(LeaseAgreementV2OwnershipTransferred)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(LeaseAgreementV2OwnershipTransferred)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *LeaseAgreementV2OwnershipTransferredIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *LeaseAgreementV2OwnershipTransferredIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// LeaseAgreementV2OwnershipTransferred represents a OwnershipTransferred event raised by the LeaseAgreementV2 contract.
+type LeaseAgreementV2OwnershipTransferred struct {
+	PreviousOwner common.Address
+	NewOwner      common.Address
+	Raw           types.Log // Blockchain specific contextual infos
+}
+
+// FilterOwnershipTransferred is a free log retrieval operation binding the contract event 0x8be0079c531659141344cd1fd0a4f28419497f9722a3daafe3b4186f6b6457e0.
+//
+// Solidity: event OwnershipTransferred(address indexed previousOwner, address indexed newOwner)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) FilterOwnershipTransferred(opts *bind.FilterOpts, previousOwner []common.Address, newOwner []common.Address) (*LeaseAgreementV2OwnershipTransferredIterator, error) {
+
+	var previousOwnerRule []interface{}
+	for _, previousOwnerItem := range previousOwner {
+		previousOwnerRule = append(previousOwnerRule, previousOwnerItem)
+	}
+	var newOwnerRule []interface{}
+	for _, newOwnerItem := range newOwner {
+		newOwnerRule = append(newOwnerRule, newOwnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.FilterLogs(opts, "OwnershipTransferred", previousOwnerRule, newOwnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseAgreementV2OwnershipTransferredIterator{contract: _LeaseAgreementV2.contract, event: "OwnershipTransferred", logs: logs, sub: sub}, nil
+}
+
+// WatchOwnershipTransferred is a free log subscription operation binding the contract event 0x8be0079c531659141344cd1fd0a4f28419497f9722a3daafe3b4186f6b6457e0.
+//
+// Solidity: event OwnershipTransferred(address indexed previousOwner, address indexed newOwner)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) WatchOwnershipTransferred(opts *bind.WatchOpts, sink chan<- *LeaseAgreementV2OwnershipTransferred, previousOwner []common.Address, newOwner []common.Address) (event.Subscription, error) {
+
+	var previousOwnerRule []interface{}
+	for _, previousOwnerItem := range previousOwner {
+		previousOwnerRule = append(previousOwnerRule, previousOwnerItem)
+	}
+	var newOwnerRule []interface{}
+	for _, newOwnerItem := range newOwner {
+		newOwnerRule = append(newOwnerRule, newOwnerItem)
+	}
+
+	logs, sub, err := _LeaseAgreementV2.contract.WatchLogs(opts, "OwnershipTransferred", previousOwnerRule, newOwnerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(LeaseAgreementV2OwnershipTransferred)
+				if err := _LeaseAgreementV2.contract.UnpackLog(event, "OwnershipTransferred", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseOwnershipTransferred is a log parse operation binding the contract event 0x8be0079c531659141344cd1fd0a4f28419497f9722a3daafe3b4186f6b6457e0.
+//
+// Solidity: event OwnershipTransferred(address indexed previousOwner, address indexed newOwner)
+func (_LeaseAgreementV2 *LeaseAgreementV2Filterer) ParseOwnershipTransferred(log types.Log) (*LeaseAgreementV2OwnershipTransferred, error) {
+	event := new(LeaseAgreementV2OwnershipTransferred)
+	if err := _LeaseAgreementV2.contract.UnpackLog(event, "OwnershipTransferred", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
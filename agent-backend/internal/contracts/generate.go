@@ -0,0 +1,11 @@
+// Package contracts holds the Go bindings of the LeaseAgreement contract.
+//
+// Each interface version of the contract has its ABI under abi/ and a
+// binding generated from it; run `go generate ./internal/contracts` after
+// adding or changing one. LeaseAgreement binds v1, the interface every
+// deployment supports, and LeaseAgreementV2 the stake-based disputes and
+// finalization added in v2. Probe tells which one a deployment speaks.
+package contracts
+
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi abi/LeaseAgreement.v1.json --pkg contracts --type LeaseAgreement --out LeaseAgreement.go
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi abi/LeaseAgreement.v2.json --pkg contracts --type LeaseAgreementV2 --out LeaseAgreementV2.go
@@ -0,0 +1,167 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LatestVersion is the newest LeaseAgreement interface with a binding here
+const LatestVersion = 2
+
+// ErrNoContract is returned by Probe when no code is deployed at the address
+var ErrNoContract = errors.New("no contract deployed")
+
+// implementationSlot is the EIP-1967 storage slot holding a proxy's
+// implementation address
+var implementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// versions lists each interface version with the ABI of its binding, oldest
+// first
+var versions = []struct {
+	version  int
+	metadata *bind.MetaData
+}{
+	{1, LeaseAgreementMetaData},
+	{2, LeaseAgreementV2MetaData},
+}
+
+// ProbeBackend is what Probe needs from a chain client. *ethclient.Client
+// satisfies it.
+type ProbeBackend interface {
+	bind.ContractCaller
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// Deployment describes the LeaseAgreement interface a deployed contract
+// speaks
+type Deployment struct {
+	Address common.Address
+	// Implementation is the contract behind an EIP-1967 proxy at Address,
+	// or the zero address if Address is not a proxy
+	Implementation common.Address
+	// Version is the interface version the contract reports through
+	// VERSION(), or else the newest one whose methods its code dispatches.
+	// It is 0 if the code matches no known version.
+	Version int
+	// Reported tells whether Version was read from VERSION()
+	Reported bool
+	// Missing lists the methods of the latest interface the contract lacks
+	Missing []string
+}
+
+// Supports tells whether the contract has method of the latest interface.
+// A Deployment that was never probed supports everything, so callers
+// without a chain behave as before.
+func (d Deployment) Supports(method string) bool {
+	return !slices.Contains(d.Missing, method)
+}
+
+// Probe finds out which LeaseAgreement interface the contract at address
+// speaks. It asks the contract for its VERSION(), following an EIP-1967
+// proxy to its implementation, and reads the method selectors its code
+// dispatches, so contracts deployed before VERSION() existed are still
+// recognised and missing methods can be disabled rather than reverting.
+func Probe(ctx context.Context, backend ProbeBackend, address common.Address) (Deployment, error) {
+	d := Deployment{Address: address}
+	opts := &bind.CallOpts{Context: ctx}
+
+	code, err := backend.CodeAt(ctx, address, nil)
+	if err != nil {
+		return d, fmt.Errorf("failed to read contract code: %w", err)
+	}
+	if len(code) == 0 {
+		return d, fmt.Errorf("%w at %s", ErrNoContract, address.Hex())
+	}
+
+	// A proxy dispatches nothing itself; its implementation's code does
+	slot, err := backend.StorageAt(ctx, address, implementationSlot, nil)
+	if err != nil {
+		return d, fmt.Errorf("failed to read EIP-1967 implementation slot: %w", err)
+	}
+	if impl := common.BytesToAddress(slot); impl != (common.Address{}) {
+		d.Implementation = impl
+		if code, err = backend.CodeAt(ctx, impl, nil); err != nil {
+			return d, fmt.Errorf("failed to read implementation code: %w", err)
+		}
+	}
+
+	selectors := dispatchedSelectors(code)
+	for _, v := range versions {
+		parsed, err := v.metadata.GetAbi()
+		if err != nil {
+			return d, fmt.Errorf("failed to parse v%d ABI: %w", v.version, err)
+		}
+		if v.version == LatestVersion {
+			d.Missing = missingMethods(parsed, selectors)
+		}
+		if len(missingMethods(parsed, selectors, "VERSION")) == 0 {
+			d.Version = v.version
+		}
+	}
+
+	// Contracts deployed before VERSION() existed are known only by their
+	// selectors
+	if !d.Supports("VERSION") {
+		return d, nil
+	}
+	caller, err := NewLeaseAgreementV2Caller(address, backend)
+	if err != nil {
+		return d, fmt.Errorf("failed to bind LeaseAgreement contract: %w", err)
+	}
+	reported, err := caller.VERSION(opts)
+	if err != nil {
+		return d, fmt.Errorf("failed to call VERSION(): %w", err)
+	}
+	if !reported.IsInt64() {
+		return d, fmt.Errorf("contract reports version %s", reported)
+	}
+	d.Version = int(reported.Int64())
+	d.Reported = true
+	return d, nil
+}
+
+// missingMethods returns the sorted names of parsed's methods whose
+// selectors are not in selectors, other than those in skip
+func missingMethods(parsed *abi.ABI, selectors map[[4]byte]bool, skip ...string) []string {
+	var missing []string
+	for name, method := range parsed.Methods {
+		var id [4]byte
+		copy(id[:], method.ID)
+		if !selectors[id] && !slices.Contains(skip, name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// dispatchedSelectors returns every value of at most 4 bytes that code
+// pushes, which includes the selectors its dispatcher compares calldata
+// against. Push data is skipped so it is not mistaken for opcodes.
+func dispatchedSelectors(code []byte) map[[4]byte]bool {
+	const push1, push4, push32 = 0x60, 0x63, 0x7f
+	selectors := make(map[[4]byte]bool)
+	for pc := 0; pc < len(code); pc++ {
+		op := code[pc]
+		if op < push1 || op > push32 {
+			continue
+		}
+		size := int(op-push1) + 1
+		if op <= push4 && pc+size < len(code) {
+			// Selectors with leading zero bytes are pushed shorter
+			var id [4]byte
+			copy(id[4-size:], code[pc+1:pc+1+size])
+			selectors[id] = true
+		}
+		pc += size
+	}
+	return selectors
+}
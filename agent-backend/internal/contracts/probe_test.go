@@ -0,0 +1,136 @@
+package contracts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeChain serves contract code and storage, and answers VERSION() calls
+type fakeChain struct {
+	code    map[common.Address][]byte
+	storage map[common.Address]map[common.Hash][]byte
+	version *big.Int
+}
+
+func (c *fakeChain) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.code[contract], nil
+}
+
+func (c *fakeChain) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if value, ok := c.storage[account][key]; ok {
+		return value, nil
+	}
+	return make([]byte, 32), nil
+}
+
+func (c *fakeChain) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	parsed, _ := LeaseAgreementV2MetaData.GetAbi()
+	if !bytes.HasPrefix(call.Data, parsed.Methods["VERSION"].ID) {
+		return nil, errors.New("execution reverted")
+	}
+	return parsed.Methods["VERSION"].Outputs.Pack(c.version)
+}
+
+// dispatcher returns code pushing the selector of each method of metadata
+// but those in skip, behind a PUSH32 whose data holds finalizeLease's
+// selector, which must not be read as code
+func dispatcher(t *testing.T, metadata *bind.MetaData, skip ...string) []byte {
+	t.Helper()
+	parsed, err := metadata.GetAbi()
+	if err != nil {
+		t.Fatalf("GetAbi() error = %v", err)
+	}
+	v2, _ := LeaseAgreementV2MetaData.GetAbi()
+	code := append([]byte{0x7f, 0x63}, v2.Methods["finalizeLease"].ID...)
+	code = append(code, make([]byte, 27)...)
+	for name, method := range parsed.Methods {
+		if slices.Contains(skip, name) {
+			continue
+		}
+		code = append(code, 0x63)
+		code = append(code, method.ID...)
+		code = append(code, 0x14) // EQ
+	}
+	return code
+}
+
+func TestProbe(t *testing.T) {
+	contract := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	impl := common.HexToAddress("0xe7f1725E7734CE288F8367e1Bb143E90bb3F0512")
+
+	t.Run("reports its version", func(t *testing.T) {
+		chain := &fakeChain{code: map[common.Address][]byte{contract: dispatcher(t, LeaseAgreementV2MetaData)}, version: big.NewInt(2)}
+		d, err := Probe(context.Background(), chain, contract)
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		if d.Version != 2 || !d.Reported || len(d.Missing) != 0 || !d.Supports("getRequiredStake") {
+			t.Errorf("Probe() = %+v, want a reported v2 contract missing nothing", d)
+		}
+	})
+
+	t.Run("recognises v1 by its selectors", func(t *testing.T) {
+		chain := &fakeChain{code: map[common.Address][]byte{contract: dispatcher(t, LeaseAgreementMetaData)}}
+		d, err := Probe(context.Background(), chain, contract)
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		if d.Version != 1 || d.Reported {
+			t.Errorf("Probe() version = %d (reported %v), want an inferred 1", d.Version, d.Reported)
+		}
+		for _, method := range []string{"VERSION", "finalizeLease", "getRequiredStake", "resolveDispute"} {
+			if d.Supports(method) {
+				t.Errorf("Supports(%q) = true on a v1 contract", method)
+			}
+		}
+		if !d.Supports("approveLease") {
+			t.Errorf("Supports(approveLease) = false on a v1 contract")
+		}
+	})
+
+	t.Run("v2 deployed before VERSION", func(t *testing.T) {
+		chain := &fakeChain{code: map[common.Address][]byte{contract: dispatcher(t, LeaseAgreementV2MetaData, "VERSION")}}
+		d, err := Probe(context.Background(), chain, contract)
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		if d.Version != 2 || d.Reported || !slices.Equal(d.Missing, []string{"VERSION"}) {
+			t.Errorf("Probe() = %+v, want an inferred 2 missing only VERSION", d)
+		}
+	})
+
+	t.Run("follows an EIP-1967 proxy", func(t *testing.T) {
+		chain := &fakeChain{
+			code: map[common.Address][]byte{
+				contract: {0x36, 0x5f, 0x5f, 0x37}, // CALLDATASIZE PUSH0 PUSH0 CALLDATACOPY
+				impl:     dispatcher(t, LeaseAgreementV2MetaData),
+			},
+			storage: map[common.Address]map[common.Hash][]byte{
+				contract: {implementationSlot: common.LeftPadBytes(impl.Bytes(), 32)},
+			},
+			version: big.NewInt(3),
+		}
+		d, err := Probe(context.Background(), chain, contract)
+		if err != nil {
+			t.Fatalf("Probe() error = %v", err)
+		}
+		if d.Implementation != impl || d.Version != 3 || !d.Reported {
+			t.Errorf("Probe() = %+v, want the implementation's reported version", d)
+		}
+	})
+
+	t.Run("no contract", func(t *testing.T) {
+		_, err := Probe(context.Background(), &fakeChain{}, contract)
+		if !errors.Is(err, ErrNoContract) {
+			t.Errorf("Probe() error = %v, want ErrNoContract", err)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNoSource is returned when a product has no delivery source configured
+var ErrNoSource = errors.New("data product has no delivery source")
+
+// Preparer turns a product's delivery source into the artifact its
+// spender receives
+type Preparer struct {
+	ipfsAPIURL string
+	sources    map[string]string
+	client     *http.Client
+	keyring    *atrest.Keyring
+}
+
+// NewPreparer creates a preparer for the products in sources, which maps
+// product IDs to an ipfs://<cid> or a local file. Files are pinned through
+// the IPFS HTTP API at ipfsAPIURL.
+func NewPreparer(ipfsAPIURL string, sources map[string]string) *Preparer {
+	return &Preparer{
+		ipfsAPIURL: strings.TrimRight(ipfsAPIURL, "/"),
+		sources:    sources,
+		client:     &http.Client{Timeout: 5 * time.Minute, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// UseKeyring decrypts source files encrypted at rest with k before they are
+// sealed
+func (p *Preparer) UseKeyring(k *atrest.Keyring) {
+	p.keyring = k
+}
+
+// SealFunc encrypts a product to the spender of the lease it is delivered
+// under
+type SealFunc func(plaintext []byte) (*envelope.Envelope, error)
+
+// Prepare checks that productID can be delivered and returns its artifact.
+// An ipfs:// source is delivered as it is. A file source is read, but not
+// pinned: its artifact has no CID until Seal pins it for the spender, so
+// nothing leaves the agent before the lease is executed.
+func (p *Preparer) Prepare(ctx context.Context, productID string) (_ *Artifact, err error) {
+	source, ok := p.sources[productID]
+	if !ok || source == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoSource, productID)
+	}
+	if cid, ok := strings.CutPrefix(source, "ipfs://"); ok {
+		return &Artifact{CID: cid, URI: source}, nil
+	}
+
+	_, span := telemetry.StartSpan(ctx, "delivery.prepare", attribute.String("pandacea.product_id", productID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	content, err := p.keyring.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data product: %w", err)
+	}
+	return describe(content), nil
+}
+
+// Seal pins a file source for the spender of an executed lease: sealed
+// with seal, so only the spender can read what is pinned. The file is read
+// again, so the spender receives it as it was when the lease was executed.
+func (p *Preparer) Seal(ctx context.Context, productID string, seal SealFunc) (_ *Artifact, err error) {
+	source, ok := p.sources[productID]
+	if !ok || source == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoSource, productID)
+	}
+	if strings.HasPrefix(source, "ipfs://") {
+		return nil, fmt.Errorf("data product %s is already published at %s", productID, source)
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "delivery.seal", attribute.String("pandacea.product_id", productID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	content, err := p.keyring.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data product: %w", err)
+	}
+	sealed, err := seal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal data product: %w", err)
+	}
+	encoded, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sealed data product: %w", err)
+	}
+	cid, err := p.pin(ctx, filepath.Base(source)+".sealed.json", encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin data product: %w", err)
+	}
+	artifact := describe(content)
+	artifact.CID = cid
+	artifact.URI = "ipfs://" + cid
+	artifact.Sealed = true
+	return artifact, nil
+}
+
+// describe returns the hash and size of a file source's content
+func describe(content []byte) *Artifact {
+	sum := sha256.Sum256(content)
+	return &Artifact{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+}
+
+// pin adds content to IPFS, pinned, and returns its CID
+func (p *Preparer) pin(ctx context.Context, name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ipfsAPIURL+"/api/v0/add?pin=true&cid-version=1", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach IPFS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("IPFS API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil || added.Hash == "" {
+		return "", errors.New("IPFS API returned no CID")
+	}
+	return added.Hash, nil
+}
@@ -0,0 +1,230 @@
+// Package delivery hands a leased data product to its spender. When the
+// spender asks for an approved lease to be executed, the agent checks the
+// product can be delivered, sends executeLease, and once the transaction
+// confirms seals the product to the spender, pins it and releases it. Deliveries are kept so a spender whose request
+// ended before the transaction confirmed can ask again.
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/txmgr"
+)
+
+// Status is where a delivery is in the handshake
+type Status string
+
+// Delivery statuses. Delivered and failed are final.
+const (
+	StatusExecuting Status = "executing" // executeLease sent, not yet confirmed
+	StatusDelivered Status = "delivered" // executeLease confirmed; the artifact is released
+	StatusFailed    Status = "failed"    // executeLease reverted or was never sent
+)
+
+// Artifact is what the spender of a lease receives. A file source has no
+// CID until it is sealed and pinned, after the lease is executed.
+type Artifact struct {
+	CID    string `json:"cid,omitempty"`
+	URI    string `json:"uri,omitempty"`    // ipfs://<cid>
+	SHA256 string `json:"sha256,omitempty"` // Hex hash of the plaintext, for file sources
+	Size   int64  `json:"size,omitempty"`   // Plaintext bytes, for file sources
+	Sealed bool   `json:"sealed,omitempty"` // The CID holds an envelope only the spender can open
+}
+
+// Pinned reports whether the artifact has been published under its CID
+func (a *Artifact) Pinned() bool {
+	return a.CID != ""
+}
+
+// Delivery tracks the handshake for one lease
+type Delivery struct {
+	LeaseID     string     `json:"leaseId"`
+	ProductID   string     `json:"productId"`
+	Spender     string     `json:"spender"`
+	PeerID      string     `json:"peerId"` // Spender's peer, the only caller the delivery is released to
+	Status      Status     `json:"status"`
+	TxID        string     `json:"txId"` // Agent transaction sending executeLease
+	TxHash      string     `json:"txHash,omitempty"`
+	BlockNumber uint64     `json:"blockNumber,omitempty"`
+	Artifact    *Artifact  `json:"artifact,omitempty"` // Withheld from the spender until delivered
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+}
+
+// Released returns d as its spender may see it: without the artifact until
+// the lease is executed and the artifact pinned
+func (d Delivery) Released() Delivery {
+	if d.Status != StatusDelivered || d.Artifact == nil || !d.Artifact.Pinned() {
+		d.Artifact = nil
+	}
+	return d
+}
+
+// copy returns a copy of d that shares no pointers with it
+func (d *Delivery) copy() Delivery {
+	c := *d
+	if d.Artifact != nil {
+		artifact := *d.Artifact
+		c.Artifact = &artifact
+	}
+	if d.DeliveredAt != nil {
+		at := *d.DeliveredAt
+		c.DeliveredAt = &at
+	}
+	return c
+}
+
+// Store keeps deliveries, one per lease. It is safe for concurrent use.
+type Store struct {
+	mu         sync.Mutex
+	path       string
+	deliveries map[string]*Delivery
+	now        func() time.Time
+}
+
+// NewStore creates a store that persists deliveries to path unless it is
+// empty, restoring any already saved there
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, deliveries: make(map[string]*Delivery), now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deliveries: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.deliveries); err != nil {
+		return nil, fmt.Errorf("failed to parse deliveries: %w", err)
+	}
+	if s.deliveries == nil {
+		s.deliveries = make(map[string]*Delivery)
+	}
+	return s, nil
+}
+
+// Start records a delivery to spender, whose peer is peerID, whose
+// executeLease transaction was just queued. If the lease already has a
+// delivery that has not failed, that delivery is kept and returned
+// instead, with started false.
+func (s *Store) Start(leaseID, productID, spender, peerID, txID string, artifact *Artifact) (_ Delivery, started bool, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(leaseID)
+	if existing, ok := s.deliveries[key]; ok && existing.Status != StatusFailed {
+		return existing.copy(), false, nil
+	}
+	now := s.now().UTC()
+	d := &Delivery{
+		LeaseID:   leaseID,
+		ProductID: productID,
+		Spender:   spender,
+		PeerID:    peerID,
+		Status:    StatusExecuting,
+		TxID:      txID,
+		Artifact:  artifact,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.deliveries[key] = d
+	return d.copy(), true, s.save()
+}
+
+// Get returns a lease's delivery
+func (s *Store) Get(leaseID string) (Delivery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[strings.ToLower(leaseID)]
+	if !ok {
+		return Delivery{}, false
+	}
+	return d.copy(), true
+}
+
+// Settle moves a lease's executing delivery on from rec, the record of its
+// executeLease transaction: to delivered once it confirms, or failed if it
+// reverted or could not be sent. Deliveries whose transaction is not final
+// are returned unchanged; settled reports whether this call moved it.
+func (s *Store) Settle(leaseID string, rec txmgr.Record) (_ Delivery, settled bool, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[strings.ToLower(leaseID)]
+	if !ok {
+		return Delivery{}, false, fmt.Errorf("no delivery for lease %s", leaseID)
+	}
+	if d.Status != StatusExecuting || rec.ID != d.TxID {
+		return d.copy(), false, nil
+	}
+
+	now := s.now().UTC()
+	switch rec.Status {
+	case txmgr.StatusConfirmed:
+		d.Status = StatusDelivered
+		d.DeliveredAt = &now
+	case txmgr.StatusReverted:
+		d.Status = StatusFailed
+		d.Error = "executeLease reverted"
+	case txmgr.StatusFailed:
+		d.Status = StatusFailed
+		d.Error = "executeLease could not be sent: " + rec.Error
+	default:
+		return d.copy(), false, nil
+	}
+	if rec.Receipt != nil {
+		d.TxHash = rec.Receipt.TxHash
+		d.BlockNumber = rec.Receipt.BlockNumber
+	}
+	d.UpdatedAt = now
+	return d.copy(), true, s.save()
+}
+
+// Attach records the artifact pinned for a delivered lease
+func (s *Store) Attach(leaseID string, artifact *Artifact) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[strings.ToLower(leaseID)]
+	if !ok {
+		return Delivery{}, fmt.Errorf("no delivery for lease %s", leaseID)
+	}
+	if d.Status != StatusDelivered {
+		return d.copy(), fmt.Errorf("lease %s is not delivered", leaseID)
+	}
+	d.Artifact = artifact
+	d.UpdatedAt = s.now().UTC()
+	return d.copy(), s.save()
+}
+
+// save writes the deliveries to disk. Caller must hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.deliveries)
+	if err != nil {
+		return fmt.Errorf("failed to encode deliveries: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create deliveries directory: %w", err)
+	}
+	if err := fsutil.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestPrepare(t *testing.T) {
+	var pinned [][]byte
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/add" || r.URL.Query().Get("pin") != "true" {
+			http.Error(w, "unexpected call", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		pinned = append(pinned, content)
+		fmt.Fprint(w, `{"Name":"scans.csv.sealed.json","Hash":"bafysealed","Size":"9"}`)
+	}))
+	defer ipfs.Close()
+
+	path := filepath.Join(t.TempDir(), "scans.csv")
+	if err := os.WriteFile(path, []byte("id,scan\n1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	preparer := NewPreparer(ipfs.URL+"/", map[string]string{
+		"product-file": path,
+		"product-cid":  "ipfs://bafyready",
+	})
+
+	// Preparing a file only describes it; nothing is published yet
+	artifact, err := preparer.Prepare(context.Background(), "product-file")
+	if err != nil {
+		t.Fatalf("Prepare(file) error = %v", err)
+	}
+	if artifact.Pinned() || artifact.Size != 9 || len(artifact.SHA256) != 64 {
+		t.Errorf("file artifact = %+v", artifact)
+	}
+	if len(pinned) != 0 {
+		t.Errorf("Prepare pinned %d files, want none", len(pinned))
+	}
+
+	// Sealing pins an envelope only the spender's key opens
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := preparer.Seal(context.Background(), "product-file", func(plaintext []byte) (*envelope.Envelope, error) {
+		return envelope.Seal(pub, plaintext)
+	})
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if sealed.CID != "bafysealed" || sealed.URI != "ipfs://bafysealed" || !sealed.Sealed || sealed.SHA256 != artifact.SHA256 {
+		t.Errorf("sealed artifact = %+v", sealed)
+	}
+	if len(pinned) != 1 || bytes.Contains(pinned[0], []byte("id,scan")) {
+		t.Fatalf("pinned %q, want one sealed file", pinned)
+	}
+	var env envelope.Envelope
+	if err := json.Unmarshal(pinned[0], &env); err != nil {
+		t.Fatalf("pinned content is not an envelope: %v", err)
+	}
+	if plaintext, err := envelope.Open(priv, &env); err != nil || string(plaintext) != "id,scan\n1" {
+		t.Errorf("Open() = %q, %v", plaintext, err)
+	}
+
+	if artifact, err := preparer.Prepare(context.Background(), "product-cid"); err != nil || artifact.CID != "bafyready" {
+		t.Errorf("Prepare(cid) = %+v, %v", artifact, err)
+	}
+	if _, err := preparer.Prepare(context.Background(), "product-none"); !errors.Is(err, ErrNoSource) {
+		t.Errorf("Prepare(unknown) error = %v, want %v", err, ErrNoSource)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "deliveries.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	artifact := &Artifact{CID: "bafy", URI: "ipfs://bafy"}
+	d, started, err := store.Start("0xAB", "product-1", "0xSpender", "12D3KooWSpender", "tx_1", artifact)
+	if err != nil || !started || d.Status != StatusExecuting {
+		t.Fatalf("Start() = %+v, %v, %v", d, started, err)
+	}
+	if released := d.Released(); released.Artifact != nil {
+		t.Error("artifact released before the lease was executed")
+	}
+	// A second request for the lease gets the delivery already under way
+	if again, started, _ := store.Start("0xab", "product-1", "0xSpender", "12D3KooWSpender", "tx_2", artifact); started || again.TxID != "tx_1" {
+		t.Errorf("second Start() = %+v, started %v", again, started)
+	}
+
+	if d, settled, _ := store.Settle("0xab", txmgr.Record{ID: "tx_1", Status: txmgr.StatusPending}); settled || d.Status != StatusExecuting {
+		t.Errorf("status while pending = %s", d.Status)
+	}
+	receipt := txmgr.Record{
+		ID:      "tx_1",
+		Status:  txmgr.StatusConfirmed,
+		Receipt: &txmgr.Receipt{TxHash: "0x01", BlockNumber: 12, Succeeded: true},
+	}
+	d, settled, err := store.Settle("0xab", receipt)
+	if err != nil || !settled || d.Status != StatusDelivered || d.TxHash != "0x01" || d.DeliveredAt == nil {
+		t.Fatalf("Settle(confirmed) = %+v, %v", d, err)
+	}
+	if _, settled, _ := store.Settle("0xab", receipt); settled {
+		t.Error("a delivered lease was settled twice")
+	}
+	if released := d.Released(); released.Artifact == nil || released.Artifact.CID != "bafy" {
+		t.Errorf("delivered artifact = %+v", released.Artifact)
+	}
+
+	// A file artifact is released once it is pinned
+	if _, err := store.Attach("0xab", &Artifact{SHA256: "ab", Size: 2}); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+	if d, _ := store.Get("0xab"); d.Released().Artifact != nil {
+		t.Error("unpinned artifact released")
+	}
+	if _, err := store.Attach("0xab", artifact); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore after restart: %v", err)
+	}
+	if d, ok := restored.Get("0xAB"); !ok || d.Status != StatusDelivered || d.Artifact == nil {
+		t.Errorf("restored delivery = %+v, %v", d, ok)
+	}
+}
+
+func TestStoreRetriesFailedDelivery(t *testing.T) {
+	store, _ := NewStore("")
+	store.Start("0xab", "product-1", "0xSpender", "12D3KooWSpender", "tx_1", nil)
+	d, _, err := store.Settle("0xab", txmgr.Record{ID: "tx_1", Status: txmgr.StatusReverted})
+	if err != nil || d.Status != StatusFailed || d.Error == "" {
+		t.Fatalf("Settle(reverted) = %+v, %v", d, err)
+	}
+
+	// A failed delivery may be started again
+	d, started, err := store.Start("0xab", "product-1", "0xSpender", "12D3KooWSpender", "tx_2", nil)
+	if err != nil || !started || d.TxID != "tx_2" || d.Status != StatusExecuting {
+		t.Errorf("Start() after failure = %+v, %v, %v", d, started, err)
+	}
+}
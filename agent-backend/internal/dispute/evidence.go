@@ -0,0 +1,259 @@
+// Package dispute packages the evidence a party attaches to a lease
+// dispute. Inline files are pinned to IPFS, every item is listed in a
+// manifest the agent signs with its libp2p key, and the signed manifest is
+// pinned as the dispute's evidence bundle. The bundle's CID goes into the
+// reason passed to LeaseAgreement.raiseDispute and into an off-chain
+// record arbitrators can retrieve from the agent.
+package dispute
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/respsig"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Limits on the evidence attached to one dispute
+const (
+	MaxEvidenceItems = 16
+	MaxEvidenceBytes = 6 << 20 // Inline content across all items; 8 MiB once base64 encoded
+)
+
+// ManifestVersion identifies the manifest format
+const ManifestVersion = "pandacea-evidence-manifest-v1"
+
+// ErrInvalidEvidence is returned when evidence fails validation. The
+// wrapped message says which item is at fault.
+var ErrInvalidEvidence = errors.New("invalid dispute evidence")
+
+// Evidence is a file or URI attached to a dispute. Exactly one of Content
+// and URI is set.
+type Evidence struct {
+	Name      string `json:"name"`
+	MediaType string `json:"mediaType,omitempty"`
+	Content   []byte `json:"content,omitempty"` // Inline file, base64 in JSON
+	URI       string `json:"uri,omitempty"`     // ipfs:// or https:// reference
+	SHA256    string `json:"sha256,omitempty"`  // Hex hash of the content a URI refers to, if the submitter vouches for one
+}
+
+// Item is a piece of evidence as listed in the manifest
+type Item struct {
+	Name      string `json:"name"`
+	MediaType string `json:"mediaType,omitempty"`
+	URI       string `json:"uri"`              // ipfs://<cid> for inline files
+	CID       string `json:"cid,omitempty"`    // Set for inline files and ipfs:// references
+	SHA256    string `json:"sha256,omitempty"` // Always set for inline files
+	Size      int    `json:"size,omitempty"`   // Bytes, for inline files
+}
+
+// Manifest lists a dispute's evidence. It is signed and pinned as part of
+// a Bundle.
+type Manifest struct {
+	Version   string    `json:"version"`
+	DisputeID string    `json:"disputeId"`
+	LeaseID   string    `json:"leaseId"`
+	Reason    string    `json:"reason"`
+	RaisedBy  string    `json:"raisedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Items     []Item    `json:"items"`
+}
+
+// Bundle is the document pinned to IPFS for a dispute. Manifest holds the
+// exact bytes that were signed, so verifiers hash it as is rather than
+// re-encoding it.
+type Bundle struct {
+	Manifest  json.RawMessage           `json:"manifest"`
+	Signature respsig.EvidenceSignature `json:"signature"`
+}
+
+// Claim is a dispute whose evidence is to be packaged
+type Claim struct {
+	DisputeID string
+	LeaseID   string
+	Reason    string
+	RaisedBy  string
+	Evidence  []Evidence
+}
+
+// Validate checks evidence against the package limits before anything is
+// pinned
+func Validate(evidence []Evidence) error {
+	if len(evidence) > MaxEvidenceItems {
+		return fmt.Errorf("%w: %d items, at most %d allowed", ErrInvalidEvidence, len(evidence), MaxEvidenceItems)
+	}
+	total := 0
+	for i, e := range evidence {
+		if strings.TrimSpace(e.Name) == "" {
+			return fmt.Errorf("%w: item %d has no name", ErrInvalidEvidence, i)
+		}
+		if (len(e.Content) == 0) == (e.URI == "") {
+			return fmt.Errorf("%w: item %q must have exactly one of content and uri", ErrInvalidEvidence, e.Name)
+		}
+		if e.URI != "" {
+			u, err := url.Parse(e.URI)
+			if err != nil || (u.Scheme != "ipfs" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: item %q uri must be an ipfs:// or https:// URL", ErrInvalidEvidence, e.Name)
+			}
+		}
+		if e.SHA256 != "" {
+			if raw, err := hex.DecodeString(e.SHA256); err != nil || len(raw) != sha256.Size {
+				return fmt.Errorf("%w: item %q sha256 must be 64 hex characters", ErrInvalidEvidence, e.Name)
+			}
+		}
+		total += len(e.Content)
+	}
+	if total > MaxEvidenceBytes {
+		return fmt.Errorf("%w: %d bytes of inline content, at most %d allowed", ErrInvalidEvidence, total, MaxEvidenceBytes)
+	}
+	return nil
+}
+
+// ChainReason returns the reason to pass to raiseDispute, with the
+// evidence bundle's CID appended when there is one
+func ChainReason(reason, evidenceCID string) string {
+	if evidenceCID == "" {
+		return reason
+	}
+	return fmt.Sprintf("%s [evidence: ipfs://%s]", reason, evidenceCID)
+}
+
+// Packager pins dispute evidence to an IPFS node
+type Packager struct {
+	ipfsAPIURL string
+	signer     *respsig.Signer
+	client     *http.Client
+	now        func() time.Time
+}
+
+// NewPackager creates a packager that pins through the IPFS HTTP API at
+// ipfsAPIURL and signs manifests with signer
+func NewPackager(ipfsAPIURL string, signer *respsig.Signer) *Packager {
+	return &Packager{
+		ipfsAPIURL: strings.TrimRight(ipfsAPIURL, "/"),
+		signer:     signer,
+		client:     &http.Client{Timeout: 30 * time.Second, Transport: telemetry.Transport(nil)},
+		now:        time.Now,
+	}
+}
+
+// Package pins claim's inline evidence and its signed manifest, and returns
+// the dispute record. A claim without evidence is recorded without
+// contacting IPFS.
+func (p *Packager) Package(ctx context.Context, claim Claim) (_ *Record, err error) {
+	if err := Validate(claim.Evidence); err != nil {
+		return nil, err
+	}
+
+	record := NewRecord(claim, p.now())
+	if len(claim.Evidence) == 0 {
+		return record, nil
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "dispute.package",
+		attribute.String("pandacea.lease_id", claim.LeaseID),
+		attribute.Int("pandacea.evidence_items", len(claim.Evidence)),
+	)
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	items := make([]Item, 0, len(claim.Evidence))
+	for _, e := range claim.Evidence {
+		item := Item{Name: e.Name, MediaType: e.MediaType, URI: e.URI, SHA256: strings.ToLower(e.SHA256)}
+		if len(e.Content) > 0 {
+			cid, err := p.pin(ctx, e.Name, e.Content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to pin evidence %q: %w", e.Name, err)
+			}
+			sum := sha256.Sum256(e.Content)
+			item.URI = "ipfs://" + cid
+			item.CID = cid
+			item.SHA256 = hex.EncodeToString(sum[:])
+			item.Size = len(e.Content)
+		} else if u, _ := url.Parse(e.URI); u.Scheme == "ipfs" {
+			item.CID = u.Host + u.Path
+		}
+		items = append(items, item)
+	}
+
+	manifest, err := json.Marshal(Manifest{
+		Version:   ManifestVersion,
+		DisputeID: claim.DisputeID,
+		LeaseID:   claim.LeaseID,
+		Reason:    claim.Reason,
+		RaisedBy:  claim.RaisedBy,
+		CreatedAt: record.CreatedAt,
+		Items:     items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode evidence manifest: %w", err)
+	}
+	sig, err := p.signer.SignEvidence(claim.DisputeID, manifest)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := json.Marshal(Bundle{Manifest: manifest, Signature: *sig})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode evidence bundle: %w", err)
+	}
+	cid, err := p.pin(ctx, claim.DisputeID+".json", bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin evidence bundle: %w", err)
+	}
+
+	record.EvidenceCID = cid
+	record.ChainReason = ChainReason(claim.Reason, cid)
+	record.Items = items
+	record.Signature = sig
+	return record, nil
+}
+
+// pin adds content to IPFS, pinned, and returns its CID
+func (p *Packager) pin(ctx context.Context, name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ipfsAPIURL+"/api/v0/add?pin=true&cid-version=1", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach IPFS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("IPFS API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil || added.Hash == "" {
+		return "", errors.New("IPFS API returned no CID")
+	}
+	return added.Hash, nil
+}
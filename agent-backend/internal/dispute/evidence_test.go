@@ -0,0 +1,189 @@
+package dispute
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"pandacea/agent-backend/internal/respsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// ipfsStub pins uploads in memory, naming each by the hex hash of its
+// content
+type ipfsStub struct {
+	mu     sync.Mutex
+	pinned map[string][]byte
+}
+
+func newIPFSStub(t *testing.T) (*ipfsStub, string) {
+	stub := &ipfsStub{pinned: make(map[string][]byte)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/add" || r.URL.Query().Get("pin") != "true" {
+			http.Error(w, "unexpected call", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		sum := sha256.Sum256(content)
+		cid := "bafy" + hex.EncodeToString(sum[:8])
+		stub.mu.Lock()
+		stub.pinned[cid] = content
+		stub.mu.Unlock()
+		fmt.Fprintf(w, `{"Name":"file","Hash":%q,"Size":"%d"}`, cid, len(content))
+	}))
+	t.Cleanup(server.Close)
+	return stub, server.URL
+}
+
+func newTestSigner(t *testing.T) *respsig.Signer {
+	t.Helper()
+	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.Ed25519, -1, rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	signer, err := respsig.NewSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return signer
+}
+
+func TestPackage(t *testing.T) {
+	stub, url := newIPFSStub(t)
+	signer := newTestSigner(t)
+	packager := NewPackager(url+"/", signer)
+
+	record, err := packager.Package(context.Background(), Claim{
+		DisputeID: "dispute_1",
+		LeaseID:   "0xabc",
+		Reason:    "results do not match the dataset",
+		RaisedBy:  "peerA",
+		Evidence: []Evidence{
+			{Name: "diff.csv", MediaType: "text/csv", Content: []byte("row,expected,got\n1,3,4\n")},
+			{Name: "trace", URI: "ipfs://bafytrace"},
+			{Name: "report", URI: "https://example.com/report.pdf", SHA256: strings.Repeat("AB", 32)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+
+	if record.EvidenceCID == "" || record.ChainReason != "results do not match the dataset [evidence: ipfs://"+record.EvidenceCID+"]" {
+		t.Errorf("record CID = %q, chain reason = %q", record.EvidenceCID, record.ChainReason)
+	}
+	if len(record.Items) != 3 {
+		t.Fatalf("items = %d, want 3", len(record.Items))
+	}
+	if item := record.Items[0]; item.CID == "" || item.URI != "ipfs://"+item.CID || item.Size != 23 || len(item.SHA256) != 64 {
+		t.Errorf("inline item = %+v", item)
+	}
+	if item := record.Items[1]; item.CID != "bafytrace" || item.SHA256 != "" {
+		t.Errorf("ipfs item = %+v", item)
+	}
+	if item := record.Items[2]; item.CID != "" || item.SHA256 != strings.Repeat("ab", 32) {
+		t.Errorf("https item = %+v", item)
+	}
+
+	// The pinned bundle carries a manifest signed by the agent
+	var bundle Bundle
+	if err := json.Unmarshal(stub.pinned[record.EvidenceCID], &bundle); err != nil {
+		t.Fatalf("pinned bundle is not JSON: %v", err)
+	}
+	if got, err := respsig.VerifyEvidence(bundle.Signature, bundle.Manifest, signer.PeerID()); err != nil || got != signer.PeerID() {
+		t.Fatalf("VerifyEvidence() = %s, %v", got, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(bundle.Manifest, &manifest); err != nil {
+		t.Fatalf("manifest is not JSON: %v", err)
+	}
+	if manifest.Version != ManifestVersion || manifest.LeaseID != "0xabc" || manifest.DisputeID != "dispute_1" || len(manifest.Items) != 3 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if string(stub.pinned[record.Items[0].CID]) != "row,expected,got\n1,3,4\n" {
+		t.Error("inline evidence was not pinned")
+	}
+}
+
+func TestPackageWithoutEvidence(t *testing.T) {
+	// No IPFS node is needed when there is nothing to pin
+	record, err := NewPackager("http://127.0.0.1:0", newTestSigner(t)).Package(context.Background(), Claim{
+		DisputeID: "dispute_1", LeaseID: "0xabc", Reason: "late delivery",
+	})
+	if err != nil {
+		t.Fatalf("Package() error = %v", err)
+	}
+	if record.EvidenceCID != "" || record.ChainReason != "late delivery" || record.Status != StatusPending {
+		t.Errorf("record = %+v", record)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		evidence []Evidence
+	}{
+		{"no name", []Evidence{{Content: []byte("x")}}},
+		{"content and uri", []Evidence{{Name: "a", Content: []byte("x"), URI: "ipfs://bafy"}}},
+		{"neither", []Evidence{{Name: "a"}}},
+		{"http uri", []Evidence{{Name: "a", URI: "http://example.com/a"}}},
+		{"bad hash", []Evidence{{Name: "a", URI: "ipfs://bafy", SHA256: "abc"}}},
+		{"too many", make([]Evidence, MaxEvidenceItems+1)},
+		{"too large", []Evidence{{Name: "a", Content: make([]byte, MaxEvidenceBytes+1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.evidence); !errors.Is(err, ErrInvalidEvidence) {
+				t.Errorf("Validate() error = %v, want %v", err, ErrInvalidEvidence)
+			}
+		})
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "disputes.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	for _, record := range []*Record{
+		{DisputeID: "dispute_2", LeaseID: "0xabc", Status: StatusPending},
+		{DisputeID: "dispute_1", LeaseID: "0xabc", Status: StatusPending, EvidenceCID: "bafy"},
+		{DisputeID: "dispute_3", LeaseID: "0xdef", Status: StatusPending},
+	} {
+		if _, err := store.Add(record); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore after restart: %v", err)
+	}
+	if record, ok := restored.Get("dispute_1"); !ok || record.EvidenceCID != "bafy" {
+		t.Errorf("Get(dispute_1) = %+v, %v", record, ok)
+	}
+	records := restored.ForLease("0xabc")
+	if len(records) != 2 || records[0].DisputeID != "dispute_1" || records[1].DisputeID != "dispute_2" {
+		t.Errorf("ForLease(0xabc) = %+v", records)
+	}
+	if _, ok := restored.Get("dispute_4"); ok {
+		t.Error("Get found an unknown dispute")
+	}
+}
@@ -0,0 +1,207 @@
+package dispute
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+// Status is where a dispute is in its lifecycle
+type Status string
+
+// Dispute statuses. Upheld and rejected are final.
+const (
+	StatusPending  Status = "pending"  // Recorded by the agent, not yet seen on chain
+	StatusRaised   Status = "raised"   // DisputeRaised seen; awaiting arbitration
+	StatusUpheld   Status = "upheld"   // DisputeResolved with the dispute found valid
+	StatusRejected Status = "rejected" // DisputeResolved with the dispute found invalid
+)
+
+// transitions lists the statuses each status may move to
+var transitions = map[Status][]Status{
+	StatusPending: {StatusRaised},
+	StatusRaised:  {StatusUpheld, StatusRejected},
+}
+
+// ErrInvalidTransition is returned when a chain event would move a
+// dispute to a status its current one cannot reach
+var ErrInvalidTransition = errors.New("invalid dispute status transition")
+
+// ParseStatus returns the status named s
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusPending, StatusRaised, StatusUpheld, StatusRejected:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown dispute status %q", s)
+}
+
+// CanTransition reports whether a dispute may move from one status to
+// another
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is a status change, with the event that caused it
+type Transition struct {
+	Status      Status    `json:"status"`
+	TxHash      string    `json:"txHash"`
+	BlockNumber uint64    `json:"blockNumber"`
+	At          time.Time `json:"at"`
+}
+
+// ChainEvent locates a dispute event on chain
+type ChainEvent struct {
+	LeaseID     string // 0x-prefixed hex
+	Network     string
+	TxHash      string
+	BlockNumber uint64
+}
+
+// evidenceSuffix matches the evidence reference ChainReason appends
+var evidenceSuffix = regexp.MustCompile(`^(.*) \[evidence: ipfs://([A-Za-z0-9]+)\]$`)
+
+// ParseChainReason splits a reason passed to raiseDispute into the reason
+// and the evidence bundle CID that ChainReason appended, if any
+func ParseChainReason(chainReason string) (reason, evidenceCID string) {
+	if m := evidenceSuffix.FindStringSubmatch(chainReason); m != nil {
+		return m[1], m[2]
+	}
+	return chainReason, ""
+}
+
+// Raised records a DisputeRaised event. It moves the lease's pending
+// record with the same reason, or failing that its newest pending record,
+// to raised. A dispute raised without going through the agent gets a new
+// record. Events already applied are ignored, so blocks can be replayed.
+func (s *Store) Raised(event ChainEvent, spender, earner, chainReason string, stake *big.Int) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record := s.applied(event); record != nil {
+		return record.copy(), nil
+	}
+
+	var match *Record
+	for _, record := range s.records {
+		if record.Status != StatusPending || !sameLease(record.LeaseID, event.LeaseID) {
+			continue
+		}
+		if record.ChainReason == chainReason {
+			match = record
+			break
+		}
+		if match == nil || record.CreatedAt.After(match.CreatedAt) {
+			match = record
+		}
+	}
+	if match == nil {
+		match = s.chainRecord(event, chainReason)
+	}
+
+	match.Spender = spender
+	match.Earner = earner
+	match.StakeAmount = stake.String()
+	if err := s.transition(match, StatusRaised, event); err != nil {
+		return Record{}, err
+	}
+	return match.copy(), s.save()
+}
+
+// Resolved records a DisputeResolved event, moving the lease's raised
+// dispute to upheld or rejected. If the DisputeRaised event was never seen
+// the dispute gets a new record. Events already applied are ignored.
+func (s *Store) Resolved(event ChainEvent, valid bool, stake *big.Int) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record := s.applied(event); record != nil {
+		return record.copy(), nil
+	}
+
+	var match *Record
+	for _, record := range s.records {
+		if record.Status == StatusRaised && sameLease(record.LeaseID, event.LeaseID) &&
+			(match == nil || record.CreatedAt.After(match.CreatedAt)) {
+			match = record
+		}
+	}
+	if match == nil {
+		match = s.chainRecord(event, "")
+		match.Status = StatusRaised
+	}
+
+	status := StatusRejected
+	if valid {
+		status = StatusUpheld
+	}
+	if match.StakeAmount == "" {
+		match.StakeAmount = stake.String()
+	}
+	if err := s.transition(match, status, event); err != nil {
+		return Record{}, err
+	}
+	return match.copy(), s.save()
+}
+
+// applied returns the record event was already applied to, if any. Caller
+// must hold s.mu.
+func (s *Store) applied(event ChainEvent) *Record {
+	for _, record := range s.records {
+		if !sameLease(record.LeaseID, event.LeaseID) {
+			continue
+		}
+		for _, t := range record.History {
+			if t.TxHash == event.TxHash {
+				return record
+			}
+		}
+	}
+	return nil
+}
+
+// chainRecord adds a record for a dispute first seen on chain. Caller must
+// hold s.mu.
+func (s *Store) chainRecord(event ChainEvent, chainReason string) *Record {
+	reason, cid := ParseChainReason(chainReason)
+	now := s.now().UTC()
+	record := &Record{
+		DisputeID:   fmt.Sprintf("dispute_%s_b%d", event.LeaseID, event.BlockNumber),
+		LeaseID:     event.LeaseID,
+		Reason:      reason,
+		Origin:      OriginChain,
+		Status:      StatusPending,
+		EvidenceCID: cid,
+		ChainReason: chainReason,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.records[record.DisputeID] = record
+	return record
+}
+
+// transition moves record to status, noting the event that caused it.
+// Caller must hold s.mu.
+func (s *Store) transition(record *Record, status Status, event ChainEvent) error {
+	if !CanTransition(record.Status, status) {
+		return fmt.Errorf("%w: %s to %s for dispute %s", ErrInvalidTransition, record.Status, status, record.DisputeID)
+	}
+	now := s.now().UTC()
+	record.Status = status
+	record.Network = event.Network
+	record.UpdatedAt = now
+	record.History = append(record.History, Transition{
+		Status:      status,
+		TxHash:      event.TxHash,
+		BlockNumber: event.BlockNumber,
+		At:          now,
+	})
+	return nil
+}
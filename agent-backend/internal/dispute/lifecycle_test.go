@@ -0,0 +1,130 @@
+package dispute
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	store, err := NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	start := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return start }
+
+	// Two disputes recorded through the agent; the chain event matches the
+	// one whose reason it carries
+	for _, claim := range []Claim{
+		{DisputeID: "dispute_a", LeaseID: "0xab", Reason: "late"},
+		{DisputeID: "dispute_b", LeaseID: "0xab", Reason: "bad data"},
+	} {
+		record := NewRecord(claim, start)
+		record.ChainReason = ChainReason(claim.Reason, "bafybundle")
+		if _, err := store.Add(record); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	raised := ChainEvent{LeaseID: "0xAB", Network: "sepolia", TxHash: "0x01", BlockNumber: 10}
+	record, err := store.Raised(raised, "0xSpender", "0xEarner", "late [evidence: ipfs://bafybundle]", big.NewInt(500))
+	if err != nil {
+		t.Fatalf("Raised: %v", err)
+	}
+	if record.DisputeID != "dispute_a" || record.Status != StatusRaised || record.StakeAmount != "500" || record.Network != "sepolia" {
+		t.Errorf("raised record = %+v", record)
+	}
+	// Replayed blocks do not apply an event twice
+	if again, err := store.Raised(raised, "0xSpender", "0xEarner", "late [evidence: ipfs://bafybundle]", big.NewInt(500)); err != nil || len(again.History) != 1 {
+		t.Errorf("replayed Raised = %+v, %v", again, err)
+	}
+
+	record, err = store.Resolved(ChainEvent{LeaseID: "0xab", Network: "sepolia", TxHash: "0x02", BlockNumber: 20}, true, big.NewInt(500))
+	if err != nil {
+		t.Fatalf("Resolved: %v", err)
+	}
+	if record.DisputeID != "dispute_a" || record.Status != StatusUpheld || len(record.History) != 2 {
+		t.Errorf("resolved record = %+v", record)
+	}
+
+	if got := store.List(Query{Status: StatusPending}); len(got) != 1 || got[0].DisputeID != "dispute_b" {
+		t.Errorf("pending disputes = %+v", got)
+	}
+	if got := store.List(Query{Network: "sepolia"}); len(got) != 1 || got[0].DisputeID != "dispute_a" {
+		t.Errorf("sepolia disputes = %+v", got)
+	}
+}
+
+func TestLifecycleChainFirst(t *testing.T) {
+	store, _ := NewStore("")
+
+	// A dispute raised on chain before the agent heard of it gets a record,
+	// which the agent's record then completes
+	record, err := store.Raised(ChainEvent{LeaseID: "0xcd", TxHash: "0x01", BlockNumber: 7}, "0xSpender", "0xEarner",
+		"stale results [evidence: ipfs://bafybundle]", big.NewInt(100))
+	if err != nil {
+		t.Fatalf("Raised: %v", err)
+	}
+	if record.Origin != OriginChain || record.Reason != "stale results" || record.EvidenceCID != "bafybundle" {
+		t.Errorf("chain record = %+v", record)
+	}
+
+	agent := NewRecord(Claim{DisputeID: "dispute_cd_1", LeaseID: "0xcd", Reason: "stale results", RaisedBy: "peerA"}, time.Now())
+	agent.ChainReason = "stale results [evidence: ipfs://bafybundle]"
+	agent.Items = []Item{{Name: "diff.csv", URI: "ipfs://bafyitem"}}
+	stored, err := store.Add(agent)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if stored.DisputeID != record.DisputeID || stored.Status != StatusRaised || stored.RaisedBy != "peerA" || len(stored.Items) != 1 {
+		t.Errorf("completed record = %+v", stored)
+	}
+	if got := store.ForLease("0xcd"); len(got) != 1 {
+		t.Errorf("lease has %d records, want 1", len(got))
+	}
+
+	// A resolution whose DisputeRaised was never seen still gets a record
+	resolved, err := store.Resolved(ChainEvent{LeaseID: "0xef", TxHash: "0x03", BlockNumber: 9}, false, big.NewInt(100))
+	if err != nil || resolved.Status != StatusRejected || resolved.Origin != OriginChain {
+		t.Errorf("Resolved without Raised = %+v, %v", resolved, err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusPending, StatusRaised, true},
+		{StatusRaised, StatusUpheld, true},
+		{StatusRaised, StatusRejected, true},
+		{StatusPending, StatusUpheld, false},
+		{StatusUpheld, StatusRejected, false},
+		{StatusRejected, StatusRaised, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	store, _ := NewStore("")
+	record := NewRecord(Claim{DisputeID: "dispute_1", LeaseID: "0xab"}, time.Now())
+	if err := store.transition(record, StatusUpheld, ChainEvent{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("transition() error = %v, want %v", err, ErrInvalidTransition)
+	}
+	if _, err := ParseStatus("closed"); err == nil {
+		t.Error("ParseStatus accepted an unknown status")
+	}
+}
+
+func TestParseChainReason(t *testing.T) {
+	if reason, cid := ParseChainReason(ChainReason("bad data", "bafy123")); reason != "bad data" || cid != "bafy123" {
+		t.Errorf("ParseChainReason() = %q, %q", reason, cid)
+	}
+	if reason, cid := ParseChainReason("bad data"); reason != "bad data" || cid != "" {
+		t.Errorf("ParseChainReason() without evidence = %q, %q", reason, cid)
+	}
+}
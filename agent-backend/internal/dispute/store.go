@@ -0,0 +1,221 @@
+package dispute
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/respsig"
+)
+
+// Where a record came from
+const (
+	OriginAgent = "agent" // Raised through the agent's API
+	OriginChain = "chain" // Only known from DisputeRaised or DisputeResolved events
+)
+
+// Record is the off-chain record of a dispute that arbitrators retrieve
+// from the agent. Its evidence fields are empty for disputes raised
+// without evidence, and its chain fields are empty until the dispute's
+// events are seen.
+type Record struct {
+	DisputeID   string                     `json:"disputeId"`
+	LeaseID     string                     `json:"leaseId"`
+	Network     string                     `json:"network,omitempty"` // Network the dispute's events came from
+	Reason      string                     `json:"reason"`
+	RaisedBy    string                     `json:"raisedBy,omitempty"` // Peer ID of the caller that raised it
+	Origin      string                     `json:"origin"`
+	Status      Status                     `json:"status"`
+	EvidenceCID string                     `json:"evidenceCid,omitempty"` // Pinned Bundle
+	ChainReason string                     `json:"chainReason"`           // Reason to pass to raiseDispute
+	Items       []Item                     `json:"items,omitempty"`
+	Signature   *respsig.EvidenceSignature `json:"signature,omitempty"` // Over the pinned manifest
+	Spender     string                     `json:"spender,omitempty"`
+	Earner      string                     `json:"earner,omitempty"`
+	StakeAmount string                     `json:"stakeAmount,omitempty"` // PGT staked by the disputer, in wei
+	History     []Transition               `json:"history,omitempty"`
+	CreatedAt   time.Time                  `json:"createdAt"`
+	UpdatedAt   time.Time                  `json:"updatedAt"`
+}
+
+// copy returns a copy of r that shares no slices with it
+func (r *Record) copy() Record {
+	c := *r
+	c.Items = append([]Item(nil), r.Items...)
+	c.History = append([]Transition(nil), r.History...)
+	return c
+}
+
+// NewID returns a fresh ID for a dispute raised against leaseID. The
+// random suffix keeps disputes raised against a lease in the same second
+// apart.
+func NewID(leaseID string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate dispute ID: %w", err)
+	}
+	return fmt.Sprintf("dispute_%s_%s", leaseID, hex.EncodeToString(suffix)), nil
+}
+
+// NewRecord returns the pending record for claim, before any evidence is
+// packaged
+func NewRecord(claim Claim, now time.Time) *Record {
+	return &Record{
+		DisputeID:   claim.DisputeID,
+		LeaseID:     claim.LeaseID,
+		Reason:      claim.Reason,
+		RaisedBy:    claim.RaisedBy,
+		Origin:      OriginAgent,
+		Status:      StatusPending,
+		ChainReason: claim.Reason,
+		CreatedAt:   now.UTC(),
+		UpdatedAt:   now.UTC(),
+	}
+}
+
+// Query filters the records returned by List. Empty fields match any
+// record.
+type Query struct {
+	LeaseID string
+	Network string
+	Status  Status
+}
+
+// Store keeps dispute records. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	records map[string]*Record
+	now     func() time.Time
+}
+
+// NewStore creates a store that persists records to path unless it is
+// empty, restoring any already saved there
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, records: make(map[string]*Record), now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispute records: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("failed to parse dispute records: %w", err)
+	}
+	if s.records == nil {
+		s.records = make(map[string]*Record)
+	}
+	return s, nil
+}
+
+// Add stores a dispute raised through the agent and returns the stored
+// record. If the lease's DisputeRaised event for the same reason was seen
+// first, the record made from the event is completed with the agent's
+// details instead, keeping its ID and status.
+func (s *Store) Add(record *Record) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.records {
+		if existing.Origin != OriginChain || !sameLease(existing.LeaseID, record.LeaseID) || existing.ChainReason != record.ChainReason {
+			continue
+		}
+		existing.Origin = OriginAgent
+		existing.RaisedBy = record.RaisedBy
+		existing.Items = record.Items
+		existing.Signature = record.Signature
+		if existing.EvidenceCID == "" {
+			existing.EvidenceCID = record.EvidenceCID
+		}
+		existing.UpdatedAt = s.now().UTC()
+		return existing.copy(), s.save()
+	}
+
+	s.records[record.DisputeID] = record
+	return record.copy(), s.save()
+}
+
+// Get returns the record for a dispute
+func (s *Store) Get(disputeID string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[disputeID]
+	if !ok {
+		return Record{}, false
+	}
+	return record.copy(), true
+}
+
+// ForLease returns a lease's dispute records, oldest first
+func (s *Store) ForLease(leaseID string) []Record {
+	records := s.List(Query{LeaseID: leaseID})
+	slices.Reverse(records)
+	return records
+}
+
+// List returns the records matching q, newest first
+func (s *Store) List(q Query) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []Record{}
+	for _, record := range s.records {
+		if q.LeaseID != "" && !sameLease(record.LeaseID, q.LeaseID) {
+			continue
+		}
+		if q.Network != "" && record.Network != q.Network {
+			continue
+		}
+		if q.Status != "" && record.Status != q.Status {
+			continue
+		}
+		records = append(records, record.copy())
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].DisputeID > records[j].DisputeID
+		}
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	return records
+}
+
+// sameLease reports whether two lease IDs are the same, ignoring the case
+// of hex IDs
+func sameLease(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// save writes the records to disk. Caller must hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return fmt.Errorf("failed to encode dispute records: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create dispute records directory: %w", err)
+	}
+	if err := fsutil.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write dispute records: %w", err)
+	}
+	return nil
+}
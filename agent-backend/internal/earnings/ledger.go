@@ -0,0 +1,329 @@
+// Package earnings tracks what an earner is paid for executed leases. Lease
+// terms are recorded from LeaseCreated events; when a lease's LeaseExecuted
+// event arrives its price is split between the royalty pool and the earner
+// and booked against its data product.
+package earnings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+
+	"github.com/shopspring/decimal"
+)
+
+// leaseRetention is how long the terms of a lease that has not executed
+// are kept
+const leaseRetention = 90 * 24 * time.Hour
+
+// Entry is the payout for one executed lease. Amounts are in wei.
+type Entry struct {
+	LeaseID    string    `json:"leaseId"`
+	ProductID  string    `json:"productId"`
+	Earner     string    `json:"earner"`
+	Spender    string    `json:"spender"`
+	Price      string    `json:"price"`   // Paid by the spender
+	Royalty    string    `json:"royalty"` // Allocated to the royalty pool
+	Net        string    `json:"net"`     // Left to the earner
+	ExecutedAt time.Time `json:"executedAt"`
+}
+
+// terms are what a lease was created with
+type terms struct {
+	Earner    string    `json:"earner"`
+	Spender   string    `json:"spender"`
+	Price     string    `json:"price"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// state is the on-disk format
+type state struct {
+	Leases  map[string]*terms `json:"leases"`
+	Entries []Entry           `json:"entries"`
+}
+
+// Ledger books lease payouts. It is safe for concurrent use.
+type Ledger struct {
+	mu       sync.Mutex
+	royalty  decimal.Decimal
+	path     string
+	state    state
+	executed map[string]bool
+	now      func() time.Time
+}
+
+// NewLedger creates a ledger that allocates royaltyPercentage, a fraction
+// in [0, 1], of each lease's price to the royalty pool. Entries are
+// persisted to path unless it is empty.
+func NewLedger(royaltyPercentage float64, path string) (*Ledger, error) {
+	if royaltyPercentage < 0 || royaltyPercentage > 1 {
+		return nil, fmt.Errorf("royalty percentage must be in [0, 1]")
+	}
+
+	l := &Ledger{
+		royalty:  decimal.NewFromFloat(royaltyPercentage),
+		path:     path,
+		state:    state{Leases: make(map[string]*terms)},
+		executed: make(map[string]bool),
+		now:      time.Now,
+	}
+
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read earnings ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.state); err != nil {
+		return nil, fmt.Errorf("failed to parse earnings ledger: %w", err)
+	}
+	if l.state.Leases == nil {
+		l.state.Leases = make(map[string]*terms)
+	}
+	for _, entry := range l.state.Entries {
+		l.executed[entry.LeaseID] = true
+	}
+
+	return l, nil
+}
+
+// RecordLease remembers a lease's parties and price until it executes
+func (l *Ledger) RecordLease(leaseID, earner, spender string, price *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	leaseID = normalize(leaseID)
+	if _, exists := l.state.Leases[leaseID]; exists || l.executed[leaseID] {
+		return nil
+	}
+	l.state.Leases[leaseID] = &terms{
+		Earner:    normalize(earner),
+		Spender:   normalize(spender),
+		Price:     price.String(),
+		CreatedAt: l.now(),
+	}
+	return l.save()
+}
+
+// RecordExecution books the payout for an executed lease against
+// productID. Each lease is booked once. It reports false if the lease's
+// terms are unknown.
+func (l *Ledger) RecordExecution(leaseID, productID string, executedAt time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	leaseID = normalize(leaseID)
+	if l.executed[leaseID] {
+		return true, nil
+	}
+	t, exists := l.state.Leases[leaseID]
+	if !exists {
+		return false, nil
+	}
+
+	price, err := decimal.NewFromString(t.Price)
+	if err != nil {
+		return true, fmt.Errorf("invalid price %q for lease %s: %w", t.Price, leaseID, err)
+	}
+	royalty := price.Mul(l.royalty).Floor()
+	l.state.Entries = append(l.state.Entries, Entry{
+		LeaseID:    leaseID,
+		ProductID:  productID,
+		Earner:     t.Earner,
+		Spender:    t.Spender,
+		Price:      price.String(),
+		Royalty:    royalty.String(),
+		Net:        price.Sub(royalty).String(),
+		ExecutedAt: executedAt.UTC(),
+	})
+	l.executed[leaseID] = true
+	delete(l.state.Leases, leaseID)
+
+	return true, l.save()
+}
+
+// save prunes stale lease terms and writes the state atomically. Caller
+// must hold l.mu.
+func (l *Ledger) save() error {
+	cutoff := l.now().Add(-leaseRetention)
+	for id, t := range l.state.Leases {
+		if t.CreatedAt.Before(cutoff) {
+			delete(l.state.Leases, id)
+		}
+	}
+
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode earnings ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create earnings ledger directory: %w", err)
+	}
+	if err := fsutil.WriteFile(l.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write earnings ledger: %w", err)
+	}
+	return nil
+}
+
+// normalize makes addresses and lease IDs case-insensitive
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Interval groups a report's totals into periods
+type Interval string
+
+// Report intervals. Periods start at midnight UTC, weeks on Monday.
+const (
+	IntervalNone  Interval = ""
+	IntervalHour  Interval = "hour"
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// ParseInterval validates an interval name
+func ParseInterval(s string) (Interval, error) {
+	switch interval := Interval(strings.ToLower(s)); interval {
+	case IntervalNone, IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+		return interval, nil
+	default:
+		return "", fmt.Errorf("interval must be hour, day, week or month")
+	}
+}
+
+// start returns the start of the period t falls in
+func (i Interval) start(t time.Time) time.Time {
+	t = t.UTC()
+	switch i {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+// Query selects the entries a report covers. Zero fields do not filter.
+type Query struct {
+	From      time.Time // Inclusive
+	To        time.Time // Exclusive
+	ProductID string
+	Earner    string // Address the payouts went to
+	Interval  Interval
+}
+
+// Totals sums payouts. Amounts are in wei.
+type Totals struct {
+	Leases  int    `json:"leases"`
+	Gross   string `json:"gross"`
+	Royalty string `json:"royalty"`
+	Net     string `json:"net"`
+}
+
+// ProductTotals holds the totals for one data product
+type ProductTotals struct {
+	ProductID string `json:"productId"`
+	Totals
+}
+
+// Period holds the totals for the interval starting at Start
+type Period struct {
+	Start time.Time `json:"start"`
+	Totals
+}
+
+// Report aggregates payouts over a time range
+type Report struct {
+	Total    Totals          `json:"total"`
+	Products []ProductTotals `json:"products"`
+	Periods  []Period        `json:"periods,omitempty"` // Set when an interval is requested
+}
+
+// sums accumulates totals
+type sums struct {
+	leases              int
+	gross, royalty, net decimal.Decimal
+}
+
+func (s *sums) add(e Entry) {
+	s.leases++
+	s.gross = s.gross.Add(decimal.RequireFromString(e.Price))
+	s.royalty = s.royalty.Add(decimal.RequireFromString(e.Royalty))
+	s.net = s.net.Add(decimal.RequireFromString(e.Net))
+}
+
+func (s *sums) totals() Totals {
+	return Totals{Leases: s.leases, Gross: s.gross.String(), Royalty: s.royalty.String(), Net: s.net.String()}
+}
+
+// Report aggregates the entries q selects by product and, if q sets an
+// interval, by period. Periods without payouts are left out.
+func (l *Ledger) Report(q Query) Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var total sums
+	products := make(map[string]*sums)
+	periods := make(map[time.Time]*sums)
+	for _, e := range l.state.Entries {
+		if !q.From.IsZero() && e.ExecutedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !e.ExecutedAt.Before(q.To) {
+			continue
+		}
+		if q.ProductID != "" && e.ProductID != q.ProductID {
+			continue
+		}
+		if q.Earner != "" && e.Earner != normalize(q.Earner) {
+			continue
+		}
+
+		total.add(e)
+		if products[e.ProductID] == nil {
+			products[e.ProductID] = &sums{}
+		}
+		products[e.ProductID].add(e)
+		if q.Interval != IntervalNone {
+			start := q.Interval.start(e.ExecutedAt)
+			if periods[start] == nil {
+				periods[start] = &sums{}
+			}
+			periods[start].add(e)
+		}
+	}
+
+	report := Report{Total: total.totals(), Products: make([]ProductTotals, 0, len(products))}
+	for id, s := range products {
+		report.Products = append(report.Products, ProductTotals{ProductID: id, Totals: s.totals()})
+	}
+	sort.Slice(report.Products, func(i, j int) bool { return report.Products[i].ProductID < report.Products[j].ProductID })
+	for start, s := range periods {
+		report.Periods = append(report.Periods, Period{Start: start, Totals: s.totals()})
+	}
+	sort.Slice(report.Periods, func(i, j int) bool { return report.Periods[i].Start.Before(report.Periods[j].Start) })
+	return report
+}
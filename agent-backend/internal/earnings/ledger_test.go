@@ -0,0 +1,122 @@
+package earnings
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordExecutionSplitsRoyalty(t *testing.T) {
+	ledger, err := NewLedger(0.2, "")
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+
+	if err := ledger.RecordLease("0xAB", "0xEarner", "0xSpender", big.NewInt(1_000_000_000_000_000_001)); err != nil {
+		t.Fatalf("RecordLease: %v", err)
+	}
+	known, err := ledger.RecordExecution("0xab", "mnist", time.Now())
+	if err != nil || !known {
+		t.Fatalf("RecordExecution = %v, %v", known, err)
+	}
+	// Replayed events are booked once
+	if known, err := ledger.RecordExecution("0xab", "mnist", time.Now()); err != nil || !known {
+		t.Fatalf("replayed RecordExecution = %v, %v", known, err)
+	}
+	if known, _ := ledger.RecordExecution("0xcd", "mnist", time.Now()); known {
+		t.Error("execution of an unknown lease was booked")
+	}
+
+	total := ledger.Report(Query{}).Total
+	want := Totals{Leases: 1, Gross: "1000000000000000001", Royalty: "200000000000000000", Net: "800000000000000001"}
+	if total != want {
+		t.Errorf("total = %+v, want %+v", total, want)
+	}
+}
+
+func TestReportAggregatesByRangeProductAndInterval(t *testing.T) {
+	ledger, err := NewLedger(0.5, "")
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // A Monday
+	for i, exec := range []struct {
+		product string
+		at      time.Time
+	}{
+		{"mnist", day.Add(time.Hour)},
+		{"mnist", day.Add(26 * time.Hour)},
+		{"cifar", day.Add(27 * time.Hour)},
+		{"cifar", day.AddDate(0, 0, 8)},
+	} {
+		id := string(rune('a' + i))
+		if err := ledger.RecordLease(id, "earner", "spender", big.NewInt(100)); err != nil {
+			t.Fatalf("RecordLease: %v", err)
+		}
+		if _, err := ledger.RecordExecution(id, exec.product, exec.at); err != nil {
+			t.Fatalf("RecordExecution: %v", err)
+		}
+	}
+
+	report := ledger.Report(Query{From: day.Add(time.Hour), To: day.AddDate(0, 0, 7), Interval: IntervalDay})
+	if report.Total.Leases != 3 || report.Total.Net != "150" {
+		t.Errorf("total = %+v, want 3 leases netting 150", report.Total)
+	}
+	if len(report.Products) != 2 || report.Products[0].ProductID != "cifar" || report.Products[1].Leases != 2 {
+		t.Errorf("products = %+v", report.Products)
+	}
+	if len(report.Periods) != 2 || !report.Periods[0].Start.Equal(day) || report.Periods[1].Leases != 2 {
+		t.Errorf("periods = %+v", report.Periods)
+	}
+
+	weekly := ledger.Report(Query{ProductID: "cifar", Interval: IntervalWeek})
+	if len(weekly.Periods) != 2 || !weekly.Periods[1].Start.Equal(day.AddDate(0, 0, 7)) {
+		t.Errorf("weekly periods = %+v", weekly.Periods)
+	}
+
+	// Earners are matched case-insensitively
+	if got := ledger.Report(Query{Earner: "EARNER"}).Total.Leases; got != 4 {
+		t.Errorf("leases paid to earner = %d, want 4", got)
+	}
+	if got := ledger.Report(Query{Earner: "other"}).Total.Leases; got != 0 {
+		t.Errorf("leases paid to another earner = %d, want 0", got)
+	}
+}
+
+func TestLedgerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "earnings.json")
+	ledger, err := NewLedger(0.2, path)
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	ledger.RecordLease("executed", "earner", "spender", big.NewInt(10))
+	ledger.RecordLease("pending", "earner", "spender", big.NewInt(20))
+	ledger.RecordExecution("executed", "mnist", time.Now())
+
+	restored, err := NewLedger(0.2, path)
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	if total := restored.Report(Query{}).Total; total.Leases != 1 || total.Gross != "10" {
+		t.Errorf("restored total = %+v", total)
+	}
+	if known, _ := restored.RecordExecution("pending", "mnist", time.Now()); !known {
+		t.Error("pending lease terms were not restored")
+	}
+	// Booked leases are not recorded again by replayed LeaseCreated events
+	restored.RecordLease("executed", "earner", "spender", big.NewInt(10))
+	restored.RecordExecution("executed", "mnist", time.Now())
+	if total := restored.Report(Query{}).Total; total.Leases != 2 {
+		t.Errorf("total leases = %d, want 2", total.Leases)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	if interval, err := ParseInterval("Day"); err != nil || interval != IntervalDay {
+		t.Errorf("ParseInterval(Day) = %q, %v", interval, err)
+	}
+	if _, err := ParseInterval("fortnight"); err == nil {
+		t.Error("ParseInterval accepted fortnight")
+	}
+}
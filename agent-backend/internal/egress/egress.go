@@ -0,0 +1,69 @@
+// Package egress lets computations reach allowlisted hosts and nothing
+// else. Sandboxes have no network by default. A computation on products
+// whose policy allowlists hosts gets a grant on the agent's egress proxy,
+// and its container is connected to a network on which the proxy is the
+// only thing it can reach. The proxy relays requests to the grant's hosts
+// and refuses all others, reporting each decision for the audit log.
+package egress
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Policy maps product IDs to the hosts computations on them may reach. A
+// pattern is a host name, or *.domain for any subdomain of domain.
+// Products without an entry may reach nothing.
+type Policy map[string][]string
+
+// Allowed returns the hosts a computation on all of products may reach:
+// those every product's policy allows. Computations on no products may
+// reach nothing.
+func (p Policy) Allowed(products []string) []string {
+	if len(products) == 0 {
+		return nil
+	}
+	allowed := slices.Clone(p[products[0]])
+	for _, product := range products[1:] {
+		allowed = slices.DeleteFunc(allowed, func(host string) bool {
+			return !slices.Contains(p[product], host)
+		})
+	}
+	slices.Sort(allowed)
+	return slices.Compact(allowed)
+}
+
+// matches reports whether host is allowed by one of patterns
+func matches(patterns []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether pattern is a host name or *.domain
+func ValidPattern(pattern string) bool {
+	name, wildcard := strings.CutPrefix(pattern, "*.")
+	if name == "" || strings.ContainsAny(name, "*/:@ ") {
+		return false
+	}
+	return !wildcard || net.ParseIP(name) == nil
+}
+
+// newToken returns a random grant token
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
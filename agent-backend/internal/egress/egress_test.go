@@ -0,0 +1,145 @@
+package egress
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	policy := Policy{
+		"weights": {"huggingface.co", "*.hf.co"},
+		"scans":   {"*.hf.co", "pypi.org"},
+	}
+	tests := []struct {
+		products []string
+		want     []string
+	}{
+		{[]string{"weights"}, []string{"*.hf.co", "huggingface.co"}},
+		{[]string{"weights", "scans"}, []string{"*.hf.co"}},
+		{[]string{"weights", "unlisted"}, []string{}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		got := policy.Allowed(tt.products)
+		if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Allowed(%v) = %v, want %v", tt.products, got, tt.want)
+		}
+	}
+
+	if !matches([]string{"*.hf.co"}, "cdn-lfs.HF.co.") || matches([]string{"*.hf.co"}, "hf.co") {
+		t.Errorf("wildcards must match subdomains only")
+	}
+	for pattern, want := range map[string]bool{"huggingface.co": true, "*.hf.co": true, "10.0.0.1": true, "*": false, "*.10.0.0.1": false, "host:443": false, "": false} {
+		if got := ValidPattern(pattern); got != want {
+			t.Errorf("ValidPattern(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Errorf("proxy credentials leaked upstream")
+		}
+		io.WriteString(w, "weights")
+	}))
+	defer upstream.Close()
+	// Computations ask for http:// URLs, which the proxy fetches over HTTPS
+	plainURL := strings.Replace(upstream.URL, "https://", "http://", 1)
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	proxy, err := NewProxy("http://127.0.0.1:3128", logger)
+	if err != nil {
+		t.Fatalf("NewProxy: %v", err)
+	}
+	proxy.allowPrivate = true
+	proxy.transport.TLSClientConfig = upstream.Client().Transport.(*http.Transport).TLSClientConfig
+	var mu sync.Mutex
+	var events []Event
+	proxy.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	grant := proxy.Grant([]string{"127.0.0.1"}, map[string]string{"computation_id": "comp-1"})
+	through := func(g *Grant, method, target string, body io.Reader) (int, string) {
+		proxyURL, _ := url.Parse(front.URL)
+		if g != nil {
+			granted, _ := url.Parse(g.URL())
+			proxyURL.User = granted.User
+		}
+		client := &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		req, _ := http.NewRequest(method, target, body)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if status, body := through(grant, http.MethodGet, plainURL, nil); status != http.StatusOK || body != "weights" {
+		t.Errorf("allowed request = %d %q", status, body)
+	}
+	if status, _ := through(grant, http.MethodHead, plainURL, nil); status != http.StatusOK {
+		t.Errorf("allowed HEAD request = %d", status)
+	}
+
+	// Nothing that could carry data out is relayed: no uploads, and no
+	// tunnels the proxy cannot see into
+	if status, _ := through(grant, http.MethodPost, plainURL, strings.NewReader("rows")); status != http.StatusMethodNotAllowed {
+		t.Errorf("upload = %d, want 405", status)
+	}
+	if status, body := through(grant, http.MethodGet, upstream.URL, nil); status != 0 || !strings.Contains(body, "Method Not Allowed") {
+		t.Errorf("CONNECT tunnel = %d %q, want it refused", status, body)
+	}
+
+	if status, _ := through(grant, http.MethodGet, strings.Replace(plainURL, "127.0.0.1", "localhost", 1), nil); status != http.StatusForbidden {
+		t.Errorf("request to a host not allowlisted = %d, want 403", status)
+	}
+	if status, _ := through(nil, http.MethodGet, plainURL, nil); status != http.StatusProxyAuthRequired {
+		t.Errorf("request without a grant = %d, want 407", status)
+	}
+	grant.Revoke()
+	if status, _ := through(grant, http.MethodGet, plainURL, nil); status != http.StatusProxyAuthRequired {
+		t.Errorf("request on a revoked grant = %d, want 407", status)
+	}
+
+	// Allowlisted names resolving to the agent's own networks are refused
+	proxy.allowPrivate = false
+	proxy.transport.CloseIdleConnections()
+	grant = proxy.Grant([]string{"127.0.0.1"}, nil)
+	if status, _ := through(grant, http.MethodGet, plainURL, nil); status != http.StatusBadGateway {
+		t.Errorf("request to a loopback address = %d, want 502", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{EventGranted, EventAllowed, EventAllowed, EventDenied, EventDenied, EventDenied, EventDenied, EventDenied, EventGranted, EventDenied}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("events = %v, want %v", kinds, want)
+	}
+	if events[1].Labels["computation_id"] != "comp-1" || events[1].Host != "127.0.0.1" {
+		t.Errorf("allowed event = %+v", events[1])
+	}
+}
@@ -0,0 +1,238 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Kinds of Event
+const (
+	EventGranted = "granted" // A computation may reach hosts
+	EventAllowed = "allowed" // A request to an allowed host was relayed
+	EventDenied  = "denied"  // A request was refused
+)
+
+// Event is an egress decision worth auditing
+type Event struct {
+	Kind   string
+	Host   string            // Host requested; empty for grants
+	Hosts  []string          // Hosts the grant allows
+	Labels map[string]string // Labels of the grant, such as the computation it is for
+	Reason string            // Why a request was denied
+}
+
+// Grant lets one computation's requests through the proxy
+type Grant struct {
+	proxy  *Proxy
+	token  string
+	hosts  []string
+	labels map[string]string
+}
+
+// URL returns the proxy URL the computation sends requests through, with
+// the grant's token as its credentials
+func (g *Grant) URL() string {
+	u := *g.proxy.url
+	u.User = url.UserPassword("computation", g.token)
+	return u.String()
+}
+
+// Revoke refuses the grant's requests from now on
+func (g *Grant) Revoke() {
+	g.proxy.mu.Lock()
+	defer g.proxy.mu.Unlock()
+	delete(g.proxy.grants, g.token)
+}
+
+// Proxy is an HTTP proxy relaying granted computations' requests to the
+// hosts they may reach. Computations run with their inputs mounted, so the
+// proxy only lets them fetch: it relays GET and HEAD requests without a
+// body and refuses CONNECT, since a tunnel or an upload could carry the
+// data out. Computations request http:// URLs and the proxy fetches them
+// over HTTPS. Hosts resolving to loopback, private or link-local addresses
+// are never reached, so an allowlisted name cannot be pointed at the agent
+// or its network.
+type Proxy struct {
+	url       *url.URL
+	logger    *slog.Logger
+	transport *http.Transport
+
+	mu     sync.RWMutex
+	grants map[string]*Grant
+	record func(Event)
+
+	allowPrivate bool // Tests relay to local servers
+}
+
+// NewProxy creates a proxy sandboxes reach at proxyURL
+func NewProxy(proxyURL string, logger *slog.Logger) (*Proxy, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("egress proxy URL must be http://host:port, got %q", proxyURL)
+	}
+	p := &Proxy{url: u, logger: logger, grants: make(map[string]*Grant)}
+	p.transport = &http.Transport{
+		Proxy:               nil,
+		DialContext:         p.dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return p, nil
+}
+
+// OnEvent calls fn with each grant and each request allowed or denied
+func (p *Proxy) OnEvent(fn func(Event)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record = fn
+}
+
+// Grant lets requests carrying the returned grant's token reach hosts
+// until it is revoked. labels identify the grant in events.
+func (p *Proxy) Grant(hosts []string, labels map[string]string) *Grant {
+	g := &Grant{proxy: p, token: newToken(), hosts: slices.Clone(hosts), labels: labels}
+	p.mu.Lock()
+	p.grants[g.token] = g
+	p.mu.Unlock()
+	p.emit(Event{Kind: EventGranted, Hosts: g.hosts, Labels: labels})
+	return g
+}
+
+// maxRequestHeaderBytes bounds a relayed request's URL and headers, which
+// are all a computation can send
+const maxRequestHeaderBytes = 8 << 10
+
+// ListenAndServe serves the proxy on addr until ctx is done
+func (p *Proxy) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: p, ReadHeaderTimeout: 10 * time.Second, MaxHeaderBytes: maxRequestHeaderBytes}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP relays a fetch whose grant allows its host
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Host
+	if r.Method == http.MethodConnect {
+		host = r.Host
+	} else if !r.URL.IsAbs() || (r.URL.Scheme != "http" && r.URL.Scheme != "https") {
+		http.Error(w, "only proxy requests for absolute URLs are served", http.StatusBadRequest)
+		return
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	grant := p.grant(r)
+	if grant == nil {
+		p.emit(Event{Kind: EventDenied, Host: hostname, Reason: "no valid grant"})
+		w.Header().Set("Proxy-Authenticate", `Basic realm="pandacea-egress"`)
+		http.Error(w, "egress requires a grant", http.StatusProxyAuthRequired)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		p.emit(Event{Kind: EventDenied, Host: hostname, Labels: grant.labels, Reason: "method " + r.Method + " not allowed"})
+		http.Error(w, "only GET and HEAD are relayed; request http:// URLs and the proxy fetches them over HTTPS", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.ContentLength != 0 || len(r.TransferEncoding) > 0 {
+		p.emit(Event{Kind: EventDenied, Host: hostname, Labels: grant.labels, Reason: "request has a body"})
+		http.Error(w, "request bodies are not relayed", http.StatusBadRequest)
+		return
+	}
+	if !matches(grant.hosts, hostname) {
+		p.emit(Event{Kind: EventDenied, Host: hostname, Labels: grant.labels, Reason: "host not allowlisted"})
+		http.Error(w, "host is not allowlisted", http.StatusForbidden)
+		return
+	}
+
+	p.forward(w, r, grant, hostname)
+}
+
+// grant returns the grant named by a request's proxy credentials, or nil
+func (p *Proxy) grant(r *http.Request) *Grant {
+	fake := http.Request{Header: http.Header{"Authorization": r.Header.Values("Proxy-Authorization")}}
+	_, token, ok := fake.BasicAuth()
+	if !ok {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.grants[token]
+}
+
+// forward fetches a request's URL over HTTPS and relays the response
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, grant *Grant, hostname string) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.URL.Scheme = "https"
+	out.Body = nil
+	for _, header := range []string{"Proxy-Authorization", "Proxy-Connection", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(header)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.emit(Event{Kind: EventDenied, Host: hostname, Labels: grant.labels, Reason: err.Error()})
+		http.Error(w, "failed to reach host", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	p.emit(Event{Kind: EventAllowed, Host: hostname, Labels: grant.labels})
+
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// dial connects to addr unless it resolves to an address on the agent's
+// own networks
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if !p.allowPrivate && (ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+				return fmt.Errorf("%s resolves to a non-public address", addr)
+			}
+			return nil
+		},
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// emit reports an event to the OnEvent callback and the log
+func (p *Proxy) emit(e Event) {
+	if e.Kind == EventDenied {
+		p.logger.Warn("egress denied", "host", e.Host, "reason", e.Reason, "labels", e.Labels)
+	}
+	p.mu.RLock()
+	record := p.record
+	p.mu.RUnlock()
+	if record != nil {
+		record(e)
+	}
+}
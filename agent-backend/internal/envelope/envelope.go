@@ -0,0 +1,277 @@
+// Package envelope seals data to a libp2p public key so only the holder of
+// the matching private key can read it.
+//
+// Sealing uses an ephemeral Diffie-Hellman exchange with the recipient's
+// key: X25519 for Ed25519 keys, converted to their Montgomery form, and ECDH
+// on secp256k1 for Secp256k1 keys. The shared secret is expanded with
+// HKDF-SHA256 into an AES-256-GCM key. Peer IDs embed Ed25519 and Secp256k1
+// public keys, so a spender's peer ID is all that is needed to seal to it.
+// Spenders whose peer ID embeds another key type can hand over a plain
+// X25519 key instead, sealed to with SealX25519.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Version identifies the sealing scheme
+const Version = "pandacea-sealed-v1"
+
+// KeyTypeX25519 is the key type of envelopes sealed to a plain X25519 key
+const KeyTypeX25519 = "X25519"
+
+var (
+	// ErrUnsupportedKey is returned for key types that cannot be sealed to
+	ErrUnsupportedKey = errors.New("unsupported key type for sealing")
+	// ErrOpen is returned when an envelope cannot be decrypted with a key
+	ErrOpen = errors.New("failed to open envelope")
+)
+
+// Envelope is data sealed to one recipient's public key
+type Envelope struct {
+	Version      string `json:"version"`
+	KeyType      string `json:"key_type"`      // Recipient key type, "Ed25519", "Secp256k1" or "X25519"
+	EphemeralKey []byte `json:"ephemeral_key"` // Sender's one-time public key
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"` // AES-256-GCM ciphertext and tag
+}
+
+// Seal encrypts plaintext so only the holder of recipient's private key can
+// read it
+func Seal(recipient crypto.PubKey, plaintext []byte) (*Envelope, error) {
+	recipientRaw, err := recipient.Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipient key: %w", err)
+	}
+
+	env := &Envelope{Version: Version, KeyType: recipient.Type().String()}
+	var shared []byte
+	switch recipient.Type() {
+	case pb.KeyType_Ed25519:
+		montgomery, err := ed25519ToX25519(recipientRaw)
+		if err != nil {
+			return nil, err
+		}
+		remote, err := ecdh.X25519().NewPublicKey(montgomery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient key: %w", err)
+		}
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+		}
+		if shared, err = ephemeral.ECDH(remote); err != nil {
+			return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+		}
+		env.EphemeralKey = ephemeral.PublicKey().Bytes()
+	case pb.KeyType_Secp256k1:
+		remote, err := secp256k1.ParsePubKey(recipientRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient key: %w", err)
+		}
+		ephemeral, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+		}
+		shared = secp256k1.GenerateSharedSecret(ephemeral, remote)
+		env.EphemeralKey = ephemeral.PubKey().SerializeCompressed()
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, recipient.Type())
+	}
+
+	aead, err := newAEAD(shared, env.EphemeralKey, recipientRaw)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, env.additionalData())
+	return env, nil
+}
+
+// SealX25519 encrypts plaintext so only the holder of the X25519 private
+// key matching recipient can read it
+func SealX25519(recipient *ecdh.PublicKey, plaintext []byte) (*Envelope, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+
+	env := &Envelope{Version: Version, KeyType: KeyTypeX25519, EphemeralKey: ephemeral.PublicKey().Bytes()}
+	aead, err := newAEAD(shared, env.EphemeralKey, recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, env.additionalData())
+	return env, nil
+}
+
+// OpenX25519 decrypts an envelope sealed with SealX25519
+func OpenX25519(recipient *ecdh.PrivateKey, env *Envelope) ([]byte, error) {
+	if env.Version != Version {
+		return nil, fmt.Errorf("%w: unknown version %q", ErrOpen, env.Version)
+	}
+	if env.KeyType != KeyTypeX25519 {
+		return nil, fmt.Errorf("%w: sealed to a %s key, not %s", ErrOpen, env.KeyType, KeyTypeX25519)
+	}
+	remote, err := ecdh.X25519().NewPublicKey(env.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ephemeral key", ErrOpen)
+	}
+	shared, err := recipient.ECDH(remote)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpen, err)
+	}
+
+	aead, err := newAEAD(shared, env.EphemeralKey, recipient.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrOpen)
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpen, err)
+	}
+	return plaintext, nil
+}
+
+// Open decrypts an envelope with the recipient's private key
+func Open(recipient crypto.PrivKey, env *Envelope) ([]byte, error) {
+	if env.Version != Version {
+		return nil, fmt.Errorf("%w: unknown version %q", ErrOpen, env.Version)
+	}
+	if env.KeyType != recipient.Type().String() {
+		return nil, fmt.Errorf("%w: sealed to a %s key, not %s", ErrOpen, env.KeyType, recipient.Type())
+	}
+	recipientRaw, err := recipient.GetPublic().Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipient key: %w", err)
+	}
+	privateRaw, err := recipient.Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	var shared []byte
+	switch recipient.Type() {
+	case pb.KeyType_Ed25519:
+		// The X25519 scalar of an Ed25519 key is the hashed seed; ecdh
+		// clamps it
+		digest := sha512.Sum512(privateRaw[:32])
+		local, err := ecdh.X25519().NewPrivateKey(digest[:32])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert private key: %w", err)
+		}
+		remote, err := ecdh.X25519().NewPublicKey(env.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid ephemeral key", ErrOpen)
+		}
+		if shared, err = local.ECDH(remote); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrOpen, err)
+		}
+	case pb.KeyType_Secp256k1:
+		remote, err := secp256k1.ParsePubKey(env.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid ephemeral key", ErrOpen)
+		}
+		shared = secp256k1.GenerateSharedSecret(secp256k1.PrivKeyFromBytes(privateRaw), remote)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, recipient.Type())
+	}
+
+	aead, err := newAEAD(shared, env.EphemeralKey, recipientRaw)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrOpen)
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpen, err)
+	}
+	return plaintext, nil
+}
+
+// additionalData binds the ciphertext to the scheme and key type
+func (env *Envelope) additionalData() []byte {
+	return []byte(env.Version + "\n" + env.KeyType)
+}
+
+// newAEAD derives the content key from the shared secret, salted with both
+// public keys so it is unique to this envelope and recipient
+func newAEAD(shared, ephemeralKey, recipientKey []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte(nil), ephemeralKey...), recipientKey...)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(Version)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive content key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// curve25519P is the field prime 2^255 - 19
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// ed25519ToX25519 maps an Ed25519 public key to the X25519 public key of
+// the same secret, u = (1 + y) / (1 - y) mod p
+func ed25519ToX25519(publicKey []byte) ([]byte, error) {
+	if len(publicKey) != 32 {
+		return nil, fmt.Errorf("invalid Ed25519 public key length %d", len(publicKey))
+	}
+
+	// Keys are little-endian; the top bit is the sign of x, which u ignores
+	le := make([]byte, 32)
+	copy(le, publicKey)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverse(le))
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return nil, fmt.Errorf("invalid Ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, curve25519P))
+	u.Mod(u, curve25519P)
+
+	return reverse(u.FillBytes(make([]byte, 32))), nil
+}
+
+// reverse returns b in reverse order
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestSealOpen(t *testing.T) {
+	for _, keyType := range []int{crypto.Ed25519, crypto.Secp256k1} {
+		priv, pub, err := crypto.GenerateKeyPairWithReader(keyType, 2048, rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPair() error = %v", err)
+		}
+		other, _, err := crypto.GenerateKeyPairWithReader(keyType, 2048, rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKeyPair() error = %v", err)
+		}
+
+		plaintext := []byte(`{"output":"mean=4.2"}`)
+		env, err := Seal(pub, plaintext)
+		if err != nil {
+			t.Fatalf("Seal(%s) error = %v", pub.Type(), err)
+		}
+		if bytes.Contains(env.Ciphertext, plaintext) {
+			t.Errorf("Seal(%s) ciphertext contains the plaintext", pub.Type())
+		}
+
+		got, err := Open(priv, env)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", pub.Type(), err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Open(%s) = %q, want %q", pub.Type(), got, plaintext)
+		}
+
+		if _, err := Open(other, env); !errors.Is(err, ErrOpen) {
+			t.Errorf("Open(%s) with another key error = %v, want ErrOpen", pub.Type(), err)
+		}
+
+		env.Ciphertext[0] ^= 1
+		if _, err := Open(priv, env); !errors.Is(err, ErrOpen) {
+			t.Errorf("Open(%s) of tampered envelope error = %v, want ErrOpen", pub.Type(), err)
+		}
+	}
+}
+
+func TestSealOpenX25519(t *testing.T) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	plaintext := []byte(`{"artifacts":{"model.pt":"AAEC"}}`)
+	env, err := SealX25519(priv.PublicKey(), plaintext)
+	if err != nil {
+		t.Fatalf("SealX25519() error = %v", err)
+	}
+	if env.KeyType != KeyTypeX25519 {
+		t.Errorf("KeyType = %q, want %q", env.KeyType, KeyTypeX25519)
+	}
+
+	got, err := OpenX25519(priv, env)
+	if err != nil {
+		t.Fatalf("OpenX25519() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("OpenX25519() = %q, want %q", got, plaintext)
+	}
+	if _, err := OpenX25519(other, env); !errors.Is(err, ErrOpen) {
+		t.Errorf("OpenX25519() with another key error = %v, want ErrOpen", err)
+	}
+
+	// An envelope sealed to a peer key does not open as X25519
+	edPriv, edPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	peerEnv, err := Seal(edPub, plaintext)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := OpenX25519(priv, peerEnv); !errors.Is(err, ErrOpen) {
+		t.Errorf("OpenX25519() of an Ed25519 envelope error = %v, want ErrOpen", err)
+	}
+	if _, err := Open(edPriv, env); !errors.Is(err, ErrOpen) {
+		t.Errorf("Open() of an X25519 envelope error = %v, want ErrOpen", err)
+	}
+}
+
+func TestSealUnsupportedKey(t *testing.T) {
+	_, pub, err := crypto.GenerateKeyPairWithReader(crypto.ECDSA, 0, rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	if _, err := Seal(pub, []byte("result")); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("Seal(ECDSA) error = %v, want ErrUnsupportedKey", err)
+	}
+}
@@ -0,0 +1,341 @@
+package federation
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NoiseConfig makes aggregation differentially private. Each participant's
+// change to the global model is clipped to ClipNorm, the clipped changes are
+// averaged without sample weighting, and Gaussian noise with standard
+// deviation NoiseMultiplier*ClipNorm/updates is added to every weight.
+type NoiseConfig struct {
+	ClipNorm        float64
+	NoiseMultiplier float64
+}
+
+// Plan describes a federated training run
+type Plan struct {
+	FederationID string
+	Participants []string
+	Rounds       int
+	MinUpdates   int // Updates a round needs to be aggregated (0 requires every participant)
+	Dataset      string
+	Task         string
+	Epsilon      float64   // Local DP budget each participant spends per round
+	Model        []float64 // Initial global model (empty lets the first round's updates define it)
+	RoundTimeout time.Duration
+	Noise        *NoiseConfig // Nil aggregates without central DP
+	Secure       bool         // Aggregate with pairwise masking so only the sum of updates is seen
+}
+
+// RoundReport records the outcome of one round
+type RoundReport struct {
+	Round       int               `json:"round"`
+	Updates     int               `json:"updates"`          // Participant updates aggregated
+	Samples     int               `json:"samples"`          // Samples the aggregated updates were trained on
+	Failed      map[string]string `json:"failed,omitempty"` // Errors by participant
+	Clipped     int               `json:"clipped,omitempty"`
+	UpdateNorm  float64           `json:"update_norm"` // L2 norm of the change to the global model
+	StartedAt   time.Time         `json:"started_at"`
+	CompletedAt time.Time         `json:"completed_at"`
+}
+
+// Result is the outcome of a completed federated training run
+type Result struct {
+	Model  []float64
+	Rounds []RoundReport
+}
+
+// Coordinator runs federated training plans over a transport
+type Coordinator struct {
+	transport Transport
+	logger    *slog.Logger
+	rngMutex  sync.Mutex
+	rng       *rand.Rand
+}
+
+// NewCoordinator creates a coordinator that reaches participants through transport
+func NewCoordinator(transport Transport, logger *slog.Logger) *Coordinator {
+	var seed [32]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(fmt.Sprintf("federation: failed to seed noise source: %v", err))
+	}
+	return &Coordinator{
+		transport: transport,
+		logger:    logger,
+		rng:       rand.New(rand.NewChaCha8(seed)),
+	}
+}
+
+// Validate checks a plan before it is run
+func (p *Plan) Validate() error {
+	switch {
+	case p.FederationID == "":
+		return fmt.Errorf("%w: federation ID is required", ErrInvalidPlan)
+	case len(p.Participants) == 0:
+		return fmt.Errorf("%w: at least one participant is required", ErrInvalidPlan)
+	case p.Rounds <= 0:
+		return fmt.Errorf("%w: rounds must be positive", ErrInvalidPlan)
+	case p.MinUpdates < 0 || p.MinUpdates > len(p.Participants):
+		return fmt.Errorf("%w: min updates cannot exceed the number of participants", ErrInvalidPlan)
+	case p.RoundTimeout <= 0:
+		return fmt.Errorf("%w: round timeout must be positive", ErrInvalidPlan)
+	}
+	seen := make(map[string]bool, len(p.Participants))
+	for _, participant := range p.Participants {
+		if seen[participant] {
+			return fmt.Errorf("%w: participant %s is listed twice", ErrInvalidPlan, participant)
+		}
+		seen[participant] = true
+	}
+	if p.Noise != nil && (p.Noise.ClipNorm <= 0 || p.Noise.NoiseMultiplier < 0) {
+		return fmt.Errorf("%w: clip norm must be positive and noise multiplier non-negative", ErrInvalidPlan)
+	}
+	if p.Secure && (len(p.Participants) < minSecureParticipants || (p.MinUpdates > 0 && p.MinUpdates < minSecureParticipants)) {
+		return fmt.Errorf("%w: secure aggregation needs at least %d updates per round", ErrInvalidPlan, minSecureParticipants)
+	}
+	return nil
+}
+
+// Run executes every round of a plan, calling progress after each round
+// that is aggregated. It stops at the first round with fewer than
+// MinUpdates valid updates, returning the rounds run so far, including the
+// failed one, alongside the error.
+func (c *Coordinator) Run(ctx context.Context, plan Plan, progress func(RoundReport)) (*Result, error) {
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	minUpdates := plan.MinUpdates
+	if minUpdates == 0 {
+		minUpdates = len(plan.Participants)
+	}
+
+	result := &Result{Model: append([]float64(nil), plan.Model...)}
+	for round := 1; round <= plan.Rounds; round++ {
+		report := RoundReport{Round: round, StartedAt: time.Now().UTC()}
+		model, err := c.runRound(ctx, plan, round, result.Model, minUpdates, &report)
+		if err != nil {
+			report.CompletedAt = time.Now().UTC()
+			result.Rounds = append(result.Rounds, report)
+			return result, err
+		}
+
+		report.UpdateNorm = distance(model, result.Model)
+		report.CompletedAt = time.Now().UTC()
+		result.Model = model
+		result.Rounds = append(result.Rounds, report)
+
+		c.logger.Info("federation round aggregated",
+			"federation_id", plan.FederationID,
+			"round", round,
+			"updates", report.Updates,
+			"failed", len(report.Failed),
+			"update_norm", report.UpdateNorm,
+		)
+		if progress != nil {
+			progress(report)
+		}
+	}
+	return result, nil
+}
+
+// runRound collects a round's updates and returns the next global model
+func (c *Coordinator) runRound(ctx context.Context, plan Plan, round int, model []float64, minUpdates int, report *RoundReport) ([]float64, error) {
+	if plan.Secure {
+		return c.secureRound(ctx, plan, round, model, minUpdates, report)
+	}
+
+	updates := c.collect(ctx, plan, round, model, report)
+	if len(updates) < minUpdates {
+		return nil, fmt.Errorf("%w: round %d got %d of the %d required", ErrTooFewUpdates, round, len(updates), minUpdates)
+	}
+	return c.aggregate(model, updates, plan.Noise, report), nil
+}
+
+// participantUpdate is an update tagged with the participant that sent it
+type participantUpdate struct {
+	participant string
+	update      RoundUpdate
+}
+
+// collect requests a round from every participant concurrently and returns
+// the valid updates, sorted by participant so aggregation is repeatable.
+// Failures are recorded on the report.
+func (c *Coordinator) collect(ctx context.Context, plan Plan, round int, model []float64, report *RoundReport) []participantUpdate {
+	roundCtx, cancel := context.WithTimeout(ctx, plan.RoundTimeout)
+	defer cancel()
+
+	req := RoundRequest{
+		FederationID: plan.FederationID,
+		Round:        round,
+		Dataset:      plan.Dataset,
+		Task:         plan.Task,
+		Epsilon:      plan.Epsilon,
+		Model:        model,
+	}
+
+	failed := make(map[string]string)
+	var updates []participantUpdate
+	for participant, update := range c.gather(roundCtx, plan, plan.Participants, req, failed) {
+		if err := checkUpdate(update, model); err != nil {
+			failed[participant] = err.Error()
+			c.logger.Warn("federation participant failed", "federation_id", plan.FederationID, "round", round, "participant", participant, "error", err)
+			continue
+		}
+		updates = append(updates, participantUpdate{participant: participant, update: update})
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].participant < updates[j].participant })
+
+	// Without a global model yet, the first update fixes the model size
+	if len(model) == 0 && len(updates) > 0 {
+		size := len(updates[0].update.Weights)
+		valid := updates[:0]
+		for _, u := range updates {
+			if len(u.update.Weights) != size {
+				failed[u.participant] = fmt.Sprintf("%v: has %d weights, expected %d", ErrInvalidUpdate, len(u.update.Weights), size)
+				continue
+			}
+			valid = append(valid, u)
+		}
+		updates = valid
+	}
+
+	if len(failed) > 0 {
+		report.Failed = failed
+	}
+	return updates
+}
+
+// gather sends req to participants concurrently and returns their replies
+// by participant. Failures are added to failed.
+func (c *Coordinator) gather(ctx context.Context, plan Plan, participants []string, req RoundRequest, failed map[string]string) map[string]RoundUpdate {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	replies := make(map[string]RoundUpdate, len(participants))
+	for _, participant := range participants {
+		wg.Add(1)
+		go func(participant string) {
+			defer wg.Done()
+			update, err := c.transport.RequestRound(ctx, participant, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[participant] = err.Error()
+				c.logger.Warn("federation participant failed", "federation_id", plan.FederationID, "round", req.Round, "phase", req.Phase, "participant", participant, "error", err)
+				return
+			}
+			replies[participant] = update
+		}(participant)
+	}
+	wg.Wait()
+	return replies
+}
+
+// checkUpdate rejects updates that cannot be aggregated with the global model
+func checkUpdate(update RoundUpdate, model []float64) error {
+	if len(update.Weights) == 0 {
+		return fmt.Errorf("%w: no weights", ErrInvalidUpdate)
+	}
+	if update.Samples <= 0 {
+		return fmt.Errorf("%w: sample count must be positive", ErrInvalidUpdate)
+	}
+	if len(model) > 0 && len(update.Weights) != len(model) {
+		return fmt.Errorf("%w: has %d weights, expected %d", ErrInvalidUpdate, len(update.Weights), len(model))
+	}
+	for _, w := range update.Weights {
+		if math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("%w: weights must be finite", ErrInvalidUpdate)
+		}
+	}
+	return nil
+}
+
+// aggregate combines a round's updates into the next global model. Without
+// noise it is the sample-weighted mean of the participants' weights.
+func (c *Coordinator) aggregate(model []float64, updates []participantUpdate, noise *NoiseConfig, report *RoundReport) []float64 {
+	size := len(updates[0].update.Weights)
+	report.Updates = len(updates)
+	for _, u := range updates {
+		report.Samples += u.update.Samples
+	}
+
+	next := make([]float64, size)
+	if noise == nil {
+		for _, u := range updates {
+			weight := float64(u.update.Samples) / float64(report.Samples)
+			for i, w := range u.update.Weights {
+				next[i] += weight * w
+			}
+		}
+		return next
+	}
+
+	// Central DP bounds each participant's influence, so sample counts,
+	// which participants report themselves, are not used as weights
+	base := model
+	if len(base) == 0 {
+		base = make([]float64, size)
+	}
+	delta := make([]float64, size)
+	for _, u := range updates {
+		for i, w := range u.update.Weights {
+			delta[i] = w - base[i]
+		}
+		scale := 1.0
+		if n := norm(delta); n > noise.ClipNorm {
+			scale = noise.ClipNorm / n
+			report.Clipped++
+		}
+		for i := range next {
+			next[i] += scale * delta[i] / float64(len(updates))
+		}
+	}
+
+	for i := range next {
+		next[i] += base[i]
+	}
+	c.addNoise(next, noise.NoiseMultiplier*noise.ClipNorm/float64(len(updates)))
+	return next
+}
+
+// addNoise adds Gaussian noise with standard deviation stddev to every weight
+func (c *Coordinator) addNoise(model []float64, stddev float64) {
+	c.rngMutex.Lock()
+	defer c.rngMutex.Unlock()
+	for i := range model {
+		model[i] += stddev * c.rng.NormFloat64()
+	}
+}
+
+// norm returns the L2 norm of v
+func norm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// distance returns the L2 distance between two models, treating a missing
+// model as all zeros
+func distance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		var y float64
+		if i < len(b) {
+			y = b[i]
+		}
+		sum += (a[i] - y) * (a[i] - y)
+	}
+	return math.Sqrt(sum)
+}
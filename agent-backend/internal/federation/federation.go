@@ -0,0 +1,228 @@
+// Package federation runs multi-round federated training across agents. A
+// coordinator sends each participating earner agent the current global
+// model over libp2p, collects the weights they train locally and combines
+// them with federated averaging, optionally clipping the updates and adding
+// Gaussian noise so the aggregate is differentially private. With secure
+// aggregation the participants mask their updates so the coordinator only
+// learns their sum.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ProtocolID is the libp2p protocol round requests are sent over
+const ProtocolID protocol.ID = "/pandacea/federation/1.0.0"
+
+// MaxMessageSize is the largest round request or update that is read
+const MaxMessageSize = 64 << 20
+
+// Errors returned by coordinators and participants. Callers branch on them
+// with errors.Is; the wrapped messages carry the details.
+var (
+	ErrNotAllowed    = errors.New("coordinator is not allowed")
+	ErrInvalidPlan   = errors.New("invalid federation plan")
+	ErrInvalidUpdate = errors.New("invalid model update")
+	ErrTooFewUpdates = errors.New("too few participant updates")
+	ErrSecureRound   = errors.New("secure aggregation failed")
+
+	// ErrProtocolViolation marks requests no well-behaved coordinator
+	// sends, such as unknown phases and replayed secure aggregation
+	// phases. Serve reports their senders.
+	ErrProtocolViolation = errors.New("federation protocol violation")
+)
+
+// Round phases. Plain rounds leave the phase empty; a round with secure
+// aggregation runs the phases in order, skipping PhaseUnmask when every
+// participant that sent a key also sent its update.
+const (
+	PhaseKeys   = "keys"   // The participant returns a fresh public key for the round
+	PhaseMasked = "masked" // The participant trains and returns its masked update
+	PhaseUnmask = "unmask" // Survivors reveal their masks with dropped participants
+)
+
+// RoundRequest asks a participant to train one round from the global model
+type RoundRequest struct {
+	FederationID string    `json:"federation_id"`
+	Round        int       `json:"round"`
+	Dataset      string    `json:"dataset"`
+	Task         string    `json:"task"`
+	Epsilon      float64   `json:"epsilon,omitempty"` // Local DP budget for the round (0 trains without local DP)
+	Model        []float64 `json:"model,omitempty"`   // Global model; empty in an unseeded first round
+
+	// Secure aggregation
+	Phase    string            `json:"phase,omitempty"`
+	Keys     map[string][]byte `json:"keys,omitempty"`      // Public keys by participant, sent with PhaseMasked and PhaseUnmask
+	Dropped  []string          `json:"dropped,omitempty"`   // Participants whose masks PhaseUnmask reveals
+	ClipNorm float64           `json:"clip_norm,omitempty"` // Bound participants clip their change to the model to before masking
+}
+
+// RoundUpdate is a participant's locally trained model for a round, or its
+// reply to a secure aggregation phase
+type RoundUpdate struct {
+	Weights   []float64 `json:"weights,omitempty"`
+	Samples   int       `json:"samples"`
+	PublicKey []byte    `json:"public_key,omitempty"` // Reply to PhaseKeys
+	Masked    []uint64  `json:"masked,omitempty"`     // Reply to PhaseMasked and PhaseUnmask
+	Error     string    `json:"error,omitempty"`
+}
+
+// Trainer trains rounds on a participant for a coordinator peer. With
+// secure aggregation it sees the PhaseMasked request.
+type Trainer interface {
+	TrainRound(ctx context.Context, coordinator string, req RoundRequest) (RoundUpdate, error)
+}
+
+// PeerReporter lowers the reputation of peers that misbehave on the
+// federation protocol
+type PeerReporter interface {
+	ReportPeer(id peer.ID, offense p2p.Offense)
+}
+
+// Transport delivers a round request to a participant and returns its update
+type Transport interface {
+	RequestRound(ctx context.Context, participant string, req RoundRequest) (RoundUpdate, error)
+}
+
+// Serve registers the federation protocol on h so coordinators can request
+// rounds from trainer. The coordinator passed to the trainer is the
+// authenticated remote peer of the stream. Secure aggregation phases are
+// answered here, and only the training is passed to trainer. Coordinators
+// that send malformed requests or break the protocol are reported to a
+// non-nil reporter.
+func Serve(h host.Host, trainer Trainer, reporter PeerReporter, logger *slog.Logger) {
+	p := newParticipant(trainer)
+	h.SetStreamHandler(ProtocolID, func(s network.Stream) {
+		handleStream(s, p, reporter, logger)
+	})
+}
+
+// handleStream answers one round request
+func handleStream(s network.Stream, p *participant, reporter PeerReporter, logger *slog.Logger) {
+	defer s.Close()
+	remote := s.Conn().RemotePeer()
+	coordinator := remote.String()
+
+	var req RoundRequest
+	if err := json.NewDecoder(io.LimitReader(s, MaxMessageSize)).Decode(&req); err != nil {
+		logger.Warn("failed to decode federation round request", "coordinator", coordinator, "error", err)
+		if reporter != nil {
+			reporter.ReportPeer(remote, p2p.OffenseInvalidMessage)
+		}
+		s.Reset()
+		return
+	}
+
+	logger.Info("federation round requested", "coordinator", coordinator, "federation_id", req.FederationID, "round", req.Round, "phase", req.Phase)
+	update, err := p.handle(context.Background(), coordinator, req)
+	if err != nil {
+		logger.Warn("federation round failed", "coordinator", coordinator, "federation_id", req.FederationID, "round", req.Round, "error", err)
+		if reporter != nil && errors.Is(err, ErrProtocolViolation) {
+			reporter.ReportPeer(remote, p2p.OffenseProtocolViolation)
+		}
+		update = RoundUpdate{Error: err.Error()}
+	}
+	if err := json.NewEncoder(s).Encode(update); err != nil {
+		logger.Error("failed to send federation round update", "coordinator", coordinator, "error", err)
+		s.Reset()
+	}
+}
+
+// P2PTransport sends round requests to participants over libp2p
+type P2PTransport struct {
+	host host.Host
+}
+
+// NewP2PTransport creates a transport that opens streams from h
+func NewP2PTransport(h host.Host) *P2PTransport {
+	return &P2PTransport{host: h}
+}
+
+// RequestRound sends a round request and waits for the participant's
+// update. Participants are peer IDs of peers the host can already reach,
+// or multiaddrs ending in /p2p/<peer ID>, which are dialled first.
+func (t *P2PTransport) RequestRound(ctx context.Context, participant string, req RoundRequest) (RoundUpdate, error) {
+	id, err := t.resolve(ctx, participant)
+	if err != nil {
+		return RoundUpdate{}, err
+	}
+
+	s, err := t.host.NewStream(ctx, id, ProtocolID)
+	if err != nil {
+		return RoundUpdate{}, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(req); err != nil {
+		s.Reset()
+		return RoundUpdate{}, fmt.Errorf("failed to send round request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return RoundUpdate{}, fmt.Errorf("failed to send round request: %w", err)
+	}
+
+	var update RoundUpdate
+	if err := json.NewDecoder(io.LimitReader(s, MaxMessageSize)).Decode(&update); err != nil {
+		s.Reset()
+		return RoundUpdate{}, fmt.Errorf("failed to read round update: %w", err)
+	}
+	if update.Error != "" {
+		return RoundUpdate{}, fmt.Errorf("participant failed the round: %s", update.Error)
+	}
+	return update, nil
+}
+
+// resolve turns a participant into a peer ID, connecting to it first if it
+// was given as a multiaddr
+func (t *P2PTransport) resolve(ctx context.Context, participant string) (peer.ID, error) {
+	if !strings.HasPrefix(participant, "/") {
+		id, err := peer.Decode(participant)
+		if err != nil {
+			return "", fmt.Errorf("invalid participant peer ID: %w", err)
+		}
+		return id, nil
+	}
+
+	info, err := peer.AddrInfoFromString(participant)
+	if err != nil {
+		return "", fmt.Errorf("invalid participant address: %w", err)
+	}
+	if err := t.host.Connect(ctx, *info); err != nil {
+		return "", fmt.Errorf("failed to connect to participant: %w", err)
+	}
+	return info.ID, nil
+}
+
+// ParticipantID returns the peer ID of a participant given as a peer ID or
+// a /p2p/ multiaddr
+func ParticipantID(participant string) (string, error) {
+	if !strings.HasPrefix(participant, "/") {
+		id, err := peer.Decode(participant)
+		if err != nil {
+			return "", fmt.Errorf("invalid participant peer ID: %w", err)
+		}
+		return id.String(), nil
+	}
+	info, err := peer.AddrInfoFromString(participant)
+	if err != nil {
+		return "", fmt.Errorf("invalid participant address: %w", err)
+	}
+	return info.ID.String(), nil
+}
@@ -0,0 +1,388 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// fakeTransport answers rounds from per-participant functions
+type fakeTransport struct {
+	mu       sync.Mutex
+	train    map[string]func(RoundRequest) (RoundUpdate, error)
+	requests []RoundRequest
+}
+
+func (f *fakeTransport) RequestRound(ctx context.Context, participant string, req RoundRequest) (RoundUpdate, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	train := f.train[participant]
+	f.mu.Unlock()
+	return train(req)
+}
+
+// fixed returns a trainer that always sends the same weights
+func fixed(samples int, weights ...float64) func(RoundRequest) (RoundUpdate, error) {
+	return func(RoundRequest) (RoundUpdate, error) {
+		return RoundUpdate{Weights: weights, Samples: samples}, nil
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func testPlan(participants ...string) Plan {
+	return Plan{
+		FederationID: "fed_test",
+		Participants: participants,
+		Rounds:       2,
+		Dataset:      "dataset",
+		Task:         "task",
+		RoundTimeout: time.Second,
+	}
+}
+
+func assertModel(t *testing.T, got []float64, want ...float64) {
+	t.Helper()
+	assertModelWithin(t, 1e-9, got, want...)
+}
+
+// assertModelWithin allows for the fixed-point rounding of secure aggregation
+func assertModelWithin(t *testing.T, tolerance float64, got []float64, want ...float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("model = %v, want %v", got, want)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > tolerance {
+			t.Fatalf("model = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCoordinatorWeightsUpdatesBySamples(t *testing.T) {
+	transport := &fakeTransport{train: map[string]func(RoundRequest) (RoundUpdate, error){
+		"a": fixed(100, 1, 0),
+		"b": fixed(300, 5, 4),
+	}}
+	var reports []RoundReport
+	result, err := NewCoordinator(transport, testLogger()).Run(context.Background(), testPlan("a", "b"), func(r RoundReport) {
+		reports = append(reports, r)
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	assertModel(t, result.Model, 4, 3)
+	if len(reports) != 2 || reports[0].Updates != 2 || reports[0].Samples != 400 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	if reports[1].UpdateNorm != 0 {
+		t.Errorf("second round changed the model by %v", reports[1].UpdateNorm)
+	}
+	// The second round starts from the first round's aggregate
+	for _, req := range transport.requests {
+		if req.Round == 2 {
+			assertModel(t, req.Model, 4, 3)
+		}
+	}
+}
+
+func TestCoordinatorToleratesFailuresDownToMinUpdates(t *testing.T) {
+	transport := &fakeTransport{train: map[string]func(RoundRequest) (RoundUpdate, error){
+		"a": fixed(10, 2, 2),
+		"b": func(RoundRequest) (RoundUpdate, error) { return RoundUpdate{}, errors.New("offline") },
+		"c": fixed(10, 1),
+	}}
+	plan := testPlan("a", "b", "c")
+	plan.MinUpdates = 1
+	plan.Model = []float64{0, 0}
+
+	result, err := NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertModel(t, result.Model, 2, 2)
+	failed := result.Rounds[0].Failed
+	if len(failed) != 2 || failed["b"] == "" || failed["c"] == "" {
+		t.Fatalf("failures = %v, want b offline and c the wrong size", failed)
+	}
+
+	plan.MinUpdates = 2
+	result, err = NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if !errors.Is(err, ErrTooFewUpdates) {
+		t.Fatalf("Run error = %v, want ErrTooFewUpdates", err)
+	}
+	if len(result.Rounds) != 1 || result.Rounds[0].Updates != 0 {
+		t.Fatalf("failed round not reported: %+v", result.Rounds)
+	}
+}
+
+func TestCoordinatorClipsUpdatesForCentralDP(t *testing.T) {
+	transport := &fakeTransport{train: map[string]func(RoundRequest) (RoundUpdate, error){
+		"a": fixed(1, 3, 4),    // Change of norm 5, clipped to 1
+		"b": fixed(1000, 0, 0), // Unchanged; sample counts are not used as weights
+	}}
+	plan := testPlan("a", "b")
+	plan.Rounds = 1
+	plan.Model = []float64{0, 0}
+	plan.Noise = &NoiseConfig{ClipNorm: 1}
+
+	result, err := NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertModel(t, result.Model, 0.3, 0.4)
+	if result.Rounds[0].Clipped != 1 {
+		t.Errorf("clipped = %d, want 1", result.Rounds[0].Clipped)
+	}
+
+	plan.Noise.NoiseMultiplier = 1
+	noisy, err := NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if noisy.Model[0] == 0.3 && noisy.Model[1] == 0.4 {
+		t.Error("noise was not added to the aggregate")
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	for name, mutate := range map[string]func(*Plan){
+		"no participants":      func(p *Plan) { p.Participants = nil },
+		"no rounds":            func(p *Plan) { p.Rounds = 0 },
+		"duplicate":            func(p *Plan) { p.Participants = []string{"a", "a"} },
+		"min above count":      func(p *Plan) { p.MinUpdates = 3 },
+		"no timeout":           func(p *Plan) { p.RoundTimeout = 0 },
+		"noise without a clip": func(p *Plan) { p.Noise = &NoiseConfig{NoiseMultiplier: 1} },
+		"secure with one":      func(p *Plan) { p.Secure, p.MinUpdates = true, 1 },
+	} {
+		plan := testPlan("a", "b")
+		mutate(&plan)
+		if err := plan.Validate(); !errors.Is(err, ErrInvalidPlan) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidPlan", name, err)
+		}
+	}
+}
+
+// recordingTrainer trains rounds by adding one to every weight
+type recordingTrainer struct {
+	mu          sync.Mutex
+	coordinator string
+}
+
+func (r *recordingTrainer) TrainRound(ctx context.Context, coordinator string, req RoundRequest) (RoundUpdate, error) {
+	r.mu.Lock()
+	r.coordinator = coordinator
+	r.mu.Unlock()
+	if req.Dataset == "refused" {
+		return RoundUpdate{}, fmt.Errorf("%w: %s", ErrNotAllowed, coordinator)
+	}
+	weights := make([]float64, len(req.Model))
+	for i, w := range req.Model {
+		weights[i] = w + 1
+	}
+	return RoundUpdate{Weights: weights, Samples: 10}, nil
+}
+
+func TestP2PTransportRoundTrip(t *testing.T) {
+	coordinator, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create coordinator host: %v", err)
+	}
+	defer coordinator.Close()
+	participant, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create participant host: %v", err)
+	}
+	defer participant.Close()
+
+	trainer := &recordingTrainer{}
+	reporter := &recordingReporter{}
+	Serve(participant, trainer, reporter, testLogger())
+
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: participant.ID(), Addrs: participant.Addrs()})
+	if err != nil {
+		t.Fatalf("failed to build participant address: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	transport := NewP2PTransport(coordinator)
+	update, err := transport.RequestRound(ctx, addrs[0].String(), RoundRequest{FederationID: "fed_test", Round: 1, Model: []float64{1, 2}})
+	if err != nil {
+		t.Fatalf("RequestRound: %v", err)
+	}
+	assertModel(t, update.Weights, 2, 3)
+	trainer.mu.Lock()
+	seen := trainer.coordinator
+	trainer.mu.Unlock()
+	if seen != coordinator.ID().String() {
+		t.Errorf("trainer saw coordinator %q, want %q", seen, coordinator.ID())
+	}
+
+	// Now connected, the participant can be addressed by peer ID alone
+	_, err = transport.RequestRound(ctx, participant.ID().String(), RoundRequest{FederationID: "fed_test", Round: 2, Dataset: "refused"})
+	if err == nil || !strings.Contains(err.Error(), ErrNotAllowed.Error()) {
+		t.Fatalf("RequestRound error = %v, want the participant's refusal", err)
+	}
+	if got := reporter.offenses(); len(got) != 0 {
+		t.Errorf("a refused round reported %v, want no offenses", got)
+	}
+
+	// Phases outside the protocol are reported against the coordinator
+	if _, err = transport.RequestRound(ctx, participant.ID().String(), RoundRequest{FederationID: "fed_test", Round: 3, Phase: "bogus"}); err == nil {
+		t.Fatal("RequestRound with an unknown phase succeeded")
+	}
+	if got := reporter.offenses(); len(got) != 1 || got[coordinator.ID()] != p2p.OffenseProtocolViolation {
+		t.Errorf("reported offenses = %v, want a protocol violation by the coordinator", got)
+	}
+}
+
+// recordingReporter remembers the last offense reported for each peer
+type recordingReporter struct {
+	mu       sync.Mutex
+	reported map[peer.ID]p2p.Offense
+}
+
+func (r *recordingReporter) ReportPeer(id peer.ID, offense p2p.Offense) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reported == nil {
+		r.reported = make(map[peer.ID]p2p.Offense)
+	}
+	r.reported[id] = offense
+}
+
+func (r *recordingReporter) offenses() map[peer.ID]p2p.Offense {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[peer.ID]p2p.Offense, len(r.reported))
+	for id, offense := range r.reported {
+		out[id] = offense
+	}
+	return out
+}
+
+// trainerFunc adapts a function to Trainer
+type trainerFunc func(RoundRequest) (RoundUpdate, error)
+
+func (f trainerFunc) TrainRound(ctx context.Context, coordinator string, req RoundRequest) (RoundUpdate, error) {
+	return f(req)
+}
+
+// participantTransport runs the participant protocol in process and keeps
+// every reply the coordinator received
+type participantTransport struct {
+	mu           sync.Mutex
+	participants map[string]*participant
+	replies      []RoundUpdate
+}
+
+func newParticipantTransport(trainers map[string]func(RoundRequest) (RoundUpdate, error)) *participantTransport {
+	t := &participantTransport{participants: make(map[string]*participant)}
+	for name, train := range trainers {
+		t.participants[name] = newParticipant(trainerFunc(train))
+	}
+	return t
+}
+
+func (t *participantTransport) RequestRound(ctx context.Context, participant string, req RoundRequest) (RoundUpdate, error) {
+	update, err := t.participants[participant].handle(ctx, "coordinator", req)
+	if err == nil {
+		t.mu.Lock()
+		t.replies = append(t.replies, update)
+		t.mu.Unlock()
+	}
+	return update, err
+}
+
+func TestSecureAggregationMatchesFederatedAveraging(t *testing.T) {
+	transport := newParticipantTransport(map[string]func(RoundRequest) (RoundUpdate, error){
+		"a": fixed(100, 1, 0),
+		"b": fixed(300, 5, 4),
+		"c": func(req RoundRequest) (RoundUpdate, error) {
+			if req.Phase != PhaseMasked {
+				t.Errorf("trainer saw phase %q", req.Phase)
+			}
+			return RoundUpdate{Weights: []float64{2, 2}, Samples: 100}, nil
+		},
+	})
+	plan := testPlan("a", "b", "c")
+	plan.Secure = true
+
+	result, err := NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertModelWithin(t, 1e-6, result.Model, 3.6, 2.8)
+	if report := result.Rounds[0]; report.Updates != 3 || report.Samples != 500 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for _, reply := range transport.replies {
+		if len(reply.Weights) > 0 {
+			t.Fatalf("coordinator received plain weights: %+v", reply)
+		}
+	}
+}
+
+func TestSecureAggregationRecoversFromDropouts(t *testing.T) {
+	transport := newParticipantTransport(map[string]func(RoundRequest) (RoundUpdate, error){
+		"a": fixed(1, 3, 4), // Change of norm 5, clipped to 1
+		"b": fixed(1, 0, 0),
+		"c": fixed(1, 0, 0),
+		"d": func(RoundRequest) (RoundUpdate, error) { return RoundUpdate{}, errors.New("offline") },
+	})
+	plan := testPlan("a", "b", "c", "d")
+	plan.Rounds = 1
+	plan.MinUpdates = 3
+	plan.Model = []float64{0, 0}
+	plan.Noise = &NoiseConfig{ClipNorm: 1}
+	plan.Secure = true
+
+	result, err := NewCoordinator(transport, testLogger()).Run(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertModelWithin(t, 1e-6, result.Model, 0.2, 0.8/3)
+	report := result.Rounds[0]
+	if report.Updates != 3 || report.Clipped != 1 || report.Failed["d"] == "" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// Participants refuse to reveal masks that would leave one update exposed
+	p := transport.participants["a"]
+	req := RoundRequest{FederationID: "fed_other", Round: 1, Phase: PhaseKeys}
+	keys := map[string][]byte{}
+	for _, name := range []string{"a", "b"} {
+		update, err := transport.participants[name].handle(context.Background(), "coordinator", req)
+		if err != nil {
+			t.Fatalf("keys phase: %v", err)
+		}
+		keys[name] = update.PublicKey
+	}
+	req.Phase, req.Keys, req.Model = PhaseMasked, keys, []float64{0, 0}
+	if _, err := p.handle(context.Background(), "coordinator", req); err != nil {
+		t.Fatalf("masked phase: %v", err)
+	}
+	if _, err := p.handle(context.Background(), "coordinator", req); !errors.Is(err, ErrSecureRound) {
+		t.Errorf("second masked update error = %v, want ErrSecureRound", err)
+	}
+	req.Phase, req.Dropped = PhaseUnmask, []string{"b"}
+	if _, err := p.handle(context.Background(), "coordinator", req); !errors.Is(err, ErrSecureRound) {
+		t.Errorf("unmask leaving one update error = %v, want ErrSecureRound", err)
+	}
+}
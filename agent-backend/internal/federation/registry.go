@@ -0,0 +1,344 @@
+package federation
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// AnnounceProtocolID is the libp2p protocol participants send heartbeats
+// announcing their capabilities to coordinators over
+const AnnounceProtocolID protocol.ID = "/pandacea/federation/announce/1.0.0"
+
+const (
+	// maxAnnouncementSize is the largest announcement that is read
+	maxAnnouncementSize = 256 << 10
+	// maxAnnouncedDatasets bounds the datasets one participant may list
+	maxAnnouncedDatasets = 1000
+	// maxRegistered bounds the participants a registry tracks, so peers
+	// announcing under fresh identities cannot grow it without limit
+	maxRegistered = 10000
+	// staleFactor is how many TTLs a silent participant stays listed,
+	// unavailable, before it is forgotten
+	staleFactor = 10
+)
+
+// ErrNoParticipants is returned when too few registered participants are
+// available for a job
+var ErrNoParticipants = errors.New("not enough available participants")
+
+// Capabilities are what a participant announces it can train
+type Capabilities struct {
+	Datasets   []string `json:"datasets"`              // Datasets, by product ID, the participant trains rounds on
+	CPUs       int      `json:"cpus"`                  // CPUs on the participant's host
+	Queued     int      `json:"queued"`                // Jobs waiting on the participant's scheduler
+	Backend    string   `json:"backend"`               // Training backend rounds run with, e.g. local or docker
+	LocalDP    bool     `json:"local_dp"`              // Rounds may spend a local DP budget
+	SecureOnly bool     `json:"secure_only,omitempty"` // Only rounds with secure aggregation are trained
+}
+
+// Announcement is the heartbeat a participant sends its coordinators
+type Announcement struct {
+	Capabilities
+	Addrs []string `json:"addrs,omitempty"` // Multiaddrs the participant listens on
+}
+
+// announceReply acknowledges an announcement
+type announceReply struct {
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // How long the participant counts as available
+	Error      string `json:"error,omitempty"`
+}
+
+// Participant is a registered participant as a coordinator sees it
+type Participant struct {
+	PeerID string `json:"peer_id"`
+	Capabilities
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`  // Last heartbeat
+	Available  bool      `json:"available"`  // Heartbeat received within the registry's TTL
+	Reputation float64   `json:"reputation"` // Peer score, 0 without offenses and negative after them
+}
+
+// PeerScorer rates peers by the offenses they committed on the network
+type PeerScorer interface {
+	PeerScore(id peer.ID) float64
+}
+
+// Criteria select participants for a federated job
+type Criteria struct {
+	Dataset       string  // Required dataset
+	Count         int     // Participants wanted
+	LocalDP       bool    // Rounds spend a local DP budget
+	Secure        bool    // Rounds use secure aggregation
+	MinReputation float64 // Participants scoring lower are skipped
+}
+
+// Registry tracks the participants that announce themselves to a
+// coordinator. A participant is available while its heartbeats keep
+// arriving within the TTL.
+type Registry struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	scorer       PeerScorer
+	participants map[string]*Participant
+	now          func() time.Time
+}
+
+// NewRegistry creates a registry in which participants stay available for
+// ttl after each heartbeat. A non-nil scorer supplies their reputation.
+func NewRegistry(ttl time.Duration, scorer PeerScorer) *Registry {
+	return &Registry{
+		ttl:          ttl,
+		scorer:       scorer,
+		participants: make(map[string]*Participant),
+		now:          time.Now,
+	}
+}
+
+// Record registers a heartbeat from a participant. It reports false if
+// the registry is full and the participant is not already in it.
+func (r *Registry) Record(peerID string, caps Capabilities) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now().UTC()
+	r.prune(now)
+	p, exists := r.participants[peerID]
+	if !exists {
+		if len(r.participants) >= maxRegistered {
+			return false
+		}
+		p = &Participant{PeerID: peerID, FirstSeen: now}
+		r.participants[peerID] = p
+	}
+	caps.Datasets = slices.Clone(caps.Datasets)
+	p.Capabilities = caps
+	p.LastSeen = now
+	return true
+}
+
+// prune forgets participants silent for staleFactor TTLs. Caller must hold r.mu.
+func (r *Registry) prune(now time.Time) {
+	for id, p := range r.participants {
+		if now.Sub(p.LastSeen) > staleFactor*r.ttl {
+			delete(r.participants, id)
+		}
+	}
+}
+
+// snapshot copies out a participant with its availability and reputation.
+// Caller must hold r.mu.
+func (r *Registry) snapshot(p *Participant, now time.Time) Participant {
+	s := *p
+	s.Datasets = slices.Clone(p.Datasets)
+	s.Available = now.Sub(p.LastSeen) <= r.ttl
+	if r.scorer != nil {
+		if id, err := peer.Decode(p.PeerID); err == nil {
+			s.Reputation = r.scorer.PeerScore(id)
+		}
+	}
+	return s
+}
+
+// List returns every registered participant, the most recently heard
+// from first
+func (r *Registry) List() []Participant {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now().UTC()
+	r.prune(now)
+	list := make([]Participant, 0, len(r.participants))
+	for _, p := range r.participants {
+		list = append(list, r.snapshot(p, now))
+	}
+	slices.SortFunc(list, func(a, b Participant) int {
+		return cmp.Or(b.LastSeen.Compare(a.LastSeen), cmp.Compare(a.PeerID, b.PeerID))
+	})
+	return list
+}
+
+// Select picks c.Count available participants that can train the job,
+// preferring the best reputation and then the freshest heartbeat
+func (r *Registry) Select(c Criteria) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now().UTC()
+	var eligible []Participant
+	for _, p := range r.participants {
+		s := r.snapshot(p, now)
+		if !s.Available || !slices.Contains(s.Datasets, c.Dataset) || s.Reputation < c.MinReputation {
+			continue
+		}
+		if (c.LocalDP && !s.LocalDP) || (s.SecureOnly && !c.Secure) {
+			continue
+		}
+		eligible = append(eligible, s)
+	}
+	if len(eligible) < c.Count {
+		return nil, fmt.Errorf("%w: %d of %d for %s", ErrNoParticipants, len(eligible), c.Count, c.Dataset)
+	}
+	slices.SortFunc(eligible, func(a, b Participant) int {
+		return cmp.Or(
+			cmp.Compare(b.Reputation, a.Reputation),
+			b.LastSeen.Compare(a.LastSeen),
+			cmp.Compare(a.PeerID, b.PeerID),
+		)
+	})
+	selected := make([]string, c.Count)
+	for i := range selected {
+		selected[i] = eligible[i].PeerID
+	}
+	return selected, nil
+}
+
+// ServeRegistry registers the announce protocol on h so participants can
+// join registry. The addresses a participant announces are added to h's
+// peerstore so rounds can reach it by peer ID. Participants that send
+// malformed announcements are reported to a non-nil reporter.
+func ServeRegistry(h host.Host, registry *Registry, reporter PeerReporter, logger *slog.Logger) {
+	h.SetStreamHandler(AnnounceProtocolID, func(s network.Stream) {
+		defer s.Close()
+		remote := s.Conn().RemotePeer()
+
+		var a Announcement
+		err := json.NewDecoder(io.LimitReader(s, maxAnnouncementSize)).Decode(&a)
+		if err == nil && len(a.Datasets) > maxAnnouncedDatasets {
+			err = fmt.Errorf("%d datasets listed, at most %d allowed", len(a.Datasets), maxAnnouncedDatasets)
+		}
+		if err != nil {
+			logger.Warn("invalid federation announcement", "peer_id", remote.String(), "error", err)
+			if reporter != nil {
+				reporter.ReportPeer(remote, p2p.OffenseInvalidMessage)
+			}
+			s.Reset()
+			return
+		}
+
+		reply := announceReply{TTLSeconds: int(registry.ttl.Seconds())}
+		if registry.Record(remote.String(), a.Capabilities) {
+			for _, addr := range a.Addrs {
+				if ma, err := multiaddr.NewMultiaddr(addr); err == nil {
+					h.Peerstore().AddAddr(remote, ma, staleFactor*registry.ttl)
+				}
+			}
+			logger.Debug("federation participant heartbeat", "peer_id", remote.String(), "datasets", len(a.Datasets))
+		} else {
+			reply = announceReply{Error: "participant registry is full"}
+			logger.Warn("federation participant registry full", "peer_id", remote.String())
+		}
+		if err := json.NewEncoder(s).Encode(reply); err != nil {
+			s.Reset()
+		}
+	})
+}
+
+// Announcer sends heartbeats with a participant's capabilities to the
+// coordinators it trains rounds for
+type Announcer struct {
+	host         host.Host
+	coordinators []string
+	capabilities func() Capabilities
+	logger       *slog.Logger
+}
+
+// NewAnnouncer creates an announcer that tells coordinators, given as
+// peer IDs or /p2p/ multiaddrs, what capabilities reports
+func NewAnnouncer(h host.Host, coordinators []string, capabilities func() Capabilities, logger *slog.Logger) *Announcer {
+	return &Announcer{host: h, coordinators: coordinators, capabilities: capabilities, logger: logger}
+}
+
+// Announce sends one heartbeat to every coordinator and returns how many
+// accepted it
+func (a *Announcer) Announce(ctx context.Context) int {
+	announcement := Announcement{Capabilities: a.capabilities()}
+	for _, addr := range a.host.Addrs() {
+		announcement.Addrs = append(announcement.Addrs, addr.String())
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	transport := NewP2PTransport(a.host)
+	for _, coordinator := range a.coordinators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.announce(ctx, transport, coordinator, announcement); err != nil {
+				a.logger.Debug("federation heartbeat failed", "coordinator", coordinator, "error", err)
+				return
+			}
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return accepted
+}
+
+// announce sends a heartbeat to one coordinator
+func (a *Announcer) announce(ctx context.Context, transport *P2PTransport, coordinator string, announcement Announcement) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	id, err := transport.resolve(ctx, coordinator)
+	if err != nil {
+		return err
+	}
+	s, err := a.host.NewStream(ctx, id, AnnounceProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+	if err := json.NewEncoder(s).Encode(announcement); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send announcement: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send announcement: %w", err)
+	}
+	var reply announceReply
+	if err := json.NewDecoder(io.LimitReader(s, maxAnnouncementSize)).Decode(&reply); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to read reply: %w", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("coordinator refused the announcement: %s", reply.Error)
+	}
+	return nil
+}
+
+// Run sends heartbeats every interval until ctx is done, starting at once
+func (a *Announcer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		accepted := a.Announce(ctx)
+		a.logger.Debug("federation heartbeats sent", "coordinators", len(a.coordinators), "accepted", accepted)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// scores is a PeerScorer with fixed scores
+type scores map[peer.ID]float64
+
+func (s scores) PeerScore(id peer.ID) float64 { return s[id] }
+
+func newPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("IDFromPrivateKey: %v", err)
+	}
+	return id
+}
+
+func TestRegistrySelect(t *testing.T) {
+	trusted, fresh, stale, offender, secureOnly, noDP := newPeerID(t), newPeerID(t), newPeerID(t), newPeerID(t), newPeerID(t), newPeerID(t)
+	registry := NewRegistry(time.Minute, scores{offender: -50, fresh: -5})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	caps := Capabilities{Datasets: []string{"mnist"}, LocalDP: true}
+	registry.Record(stale.String(), caps)
+	now = now.Add(2 * time.Minute)
+	for _, id := range []peer.ID{trusted, offender} {
+		registry.Record(id.String(), caps)
+	}
+	registry.Record(secureOnly.String(), Capabilities{Datasets: []string{"mnist"}, LocalDP: true, SecureOnly: true})
+	registry.Record(noDP.String(), Capabilities{Datasets: []string{"mnist"}})
+	now = now.Add(30 * time.Second)
+	registry.Record(fresh.String(), caps)
+
+	// The stale participant missed its heartbeats and the offender's score
+	// is too low; the rest are ordered by reputation before freshness
+	selected, err := registry.Select(Criteria{Dataset: "mnist", Count: 2, LocalDP: true, MinReputation: -20})
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if want := []string{trusted.String(), fresh.String()}; !slices.Equal(selected, want) {
+		t.Errorf("Select() = %v, want %v", selected, want)
+	}
+	if _, err := registry.Select(Criteria{Dataset: "mnist", Count: 3, LocalDP: true, MinReputation: -20}); !errors.Is(err, ErrNoParticipants) {
+		t.Errorf("Select(3) error = %v, want ErrNoParticipants", err)
+	}
+	selected, err = registry.Select(Criteria{Dataset: "mnist", Count: 4, Secure: true, MinReputation: -20})
+	if err != nil {
+		t.Fatalf("Select(secure, no DP): %v", err)
+	}
+	if !slices.Contains(selected, secureOnly.String()) || !slices.Contains(selected, noDP.String()) {
+		t.Errorf("Select(secure, no DP) = %v, want the secure-only and non-DP participants too", selected)
+	}
+
+	list := registry.List()
+	if len(list) != 6 || list[0].PeerID != fresh.String() || list[len(list)-1].Available {
+		t.Errorf("List() = %+v, want 6 participants, freshest first and the stale one last", list)
+	}
+
+	// Participants silent for long enough are forgotten
+	now = now.Add((staleFactor - 1) * time.Minute)
+	if got := len(registry.List()); got != 5 {
+		t.Errorf("List() after silence has %d participants, want 5", got)
+	}
+}
+
+func TestAnnouncer(t *testing.T) {
+	coordinator, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create coordinator host: %v", err)
+	}
+	defer coordinator.Close()
+	participant, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create participant host: %v", err)
+	}
+	defer participant.Close()
+
+	registry := NewRegistry(time.Minute, nil)
+	ServeRegistry(coordinator, registry, &recordingReporter{}, testLogger())
+
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: coordinator.ID(), Addrs: coordinator.Addrs()})
+	if err != nil {
+		t.Fatalf("failed to build coordinator address: %v", err)
+	}
+	announcer := NewAnnouncer(participant, []string{addrs[0].String()}, func() Capabilities {
+		return Capabilities{Datasets: []string{"mnist"}, CPUs: 8, Backend: "docker", LocalDP: true}
+	}, testLogger())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if accepted := announcer.Announce(ctx); accepted != 1 {
+		t.Fatalf("Announce() accepted by %d coordinators, want 1", accepted)
+	}
+	list := registry.List()
+	if len(list) != 1 || list[0].PeerID != participant.ID().String() || !list[0].Available || list[0].CPUs != 8 {
+		t.Fatalf("List() = %+v, want the participant available", list)
+	}
+
+	// The coordinator learned where to reach the participant for rounds
+	if len(coordinator.Peerstore().Addrs(participant.ID())) == 0 {
+		t.Error("participant addresses were not added to the coordinator's peerstore")
+	}
+}
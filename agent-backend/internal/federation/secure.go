@@ -0,0 +1,373 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fl"
+)
+
+// minSecureParticipants is the fewest updates a participant lets its update
+// be summed with; a sum of one update is the update
+const minSecureParticipants = 2
+
+// sessionTTL bounds how long a participant keeps a round's key waiting for
+// the coordinator's next phase
+const sessionTTL = 2 * time.Hour
+
+// participant answers round requests, running the secure aggregation phases
+// around its trainer
+type participant struct {
+	trainer  Trainer
+	mu       sync.Mutex
+	sessions map[string]*roundSession
+}
+
+// roundSession is a participant's secure aggregation state for one round
+type roundSession struct {
+	session    *fl.Session
+	federation string // Coordinator and federation ID
+	round      int
+	keys       map[string][]byte // Keys the update was masked with; nil until then
+	length     int
+	createdAt  time.Time
+}
+
+func newParticipant(trainer Trainer) *participant {
+	return &participant{trainer: trainer, sessions: make(map[string]*roundSession)}
+}
+
+// sessionID identifies a coordinator's round
+func sessionID(coordinator string, req RoundRequest) string {
+	return fmt.Sprintf("%s/%s/%d", coordinator, req.FederationID, req.Round)
+}
+
+// maskContext binds masks to one round of one federation
+func maskContext(req RoundRequest) []byte {
+	return []byte(fmt.Sprintf("%s/%d", req.FederationID, req.Round))
+}
+
+// handle answers one round request from coordinator
+func (p *participant) handle(ctx context.Context, coordinator string, req RoundRequest) (RoundUpdate, error) {
+	switch req.Phase {
+	case "":
+		return p.trainer.TrainRound(ctx, coordinator, req)
+	case PhaseKeys:
+		return p.startSession(coordinator, req)
+	case PhaseMasked:
+		return p.maskedUpdate(ctx, coordinator, req)
+	case PhaseUnmask:
+		return p.unmask(coordinator, req)
+	default:
+		return RoundUpdate{}, fmt.Errorf("%w: %w: unknown phase %q", ErrSecureRound, ErrProtocolViolation, req.Phase)
+	}
+}
+
+// startSession creates the round's key. Sessions of the federation's
+// earlier rounds, and sessions coordinators abandoned, are dropped.
+func (p *participant) startSession(coordinator string, req RoundRequest) (RoundUpdate, error) {
+	session, err := fl.NewSession()
+	if err != nil {
+		return RoundUpdate{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, s := range p.sessions {
+		if (s.federation == coordinator+"/"+req.FederationID && s.round < req.Round) || time.Since(s.createdAt) > sessionTTL {
+			delete(p.sessions, id)
+		}
+	}
+	id := sessionID(coordinator, req)
+	if _, exists := p.sessions[id]; exists {
+		return RoundUpdate{}, fmt.Errorf("%w: %w: round %d already has a key", ErrSecureRound, ErrProtocolViolation, req.Round)
+	}
+	p.sessions[id] = &roundSession{
+		session:    session,
+		federation: coordinator + "/" + req.FederationID,
+		round:      req.Round,
+		createdAt:  time.Now(),
+	}
+	return RoundUpdate{PublicKey: session.PublicKey()}, nil
+}
+
+// maskedUpdate trains the round and masks the update with the keys the
+// coordinator relayed. Each session masks at most one update.
+func (p *participant) maskedUpdate(ctx context.Context, coordinator string, req RoundRequest) (RoundUpdate, error) {
+	id := sessionID(coordinator, req)
+	p.mu.Lock()
+	rs, exists := p.sessions[id]
+	claimed := exists && rs.keys == nil
+	if claimed {
+		// Claimed before training so a repeated request cannot mask twice
+		rs.keys = req.Keys
+	}
+	p.mu.Unlock()
+	if !exists {
+		return RoundUpdate{}, fmt.Errorf("%w: no key for round %d", ErrSecureRound, req.Round)
+	}
+	if !claimed {
+		return RoundUpdate{}, fmt.Errorf("%w: %w: round %d was already masked", ErrSecureRound, ErrProtocolViolation, req.Round)
+	}
+	if err := checkKeys(req.Keys, rs.session.PublicKey()); err != nil {
+		return RoundUpdate{}, err
+	}
+
+	update, err := p.trainer.TrainRound(ctx, coordinator, req)
+	if err != nil {
+		return RoundUpdate{}, err
+	}
+	if err := checkUpdate(update, req.Model); err != nil {
+		return RoundUpdate{}, err
+	}
+
+	vector := fl.Encode(secureVector(update, req.Model, req.ClipNorm))
+	masked, err := rs.session.Mask(vector, keyList(req.Keys), maskContext(req))
+	if err != nil {
+		return RoundUpdate{}, fmt.Errorf("%w: %v", ErrSecureRound, err)
+	}
+	p.mu.Lock()
+	rs.length = len(masked)
+	p.mu.Unlock()
+	return RoundUpdate{Masked: masked}, nil
+}
+
+// unmask reveals the masks shared with dropped participants and ends the
+// session
+func (p *participant) unmask(coordinator string, req RoundRequest) (RoundUpdate, error) {
+	id := sessionID(coordinator, req)
+	p.mu.Lock()
+	rs, exists := p.sessions[id]
+	if exists && rs.length > 0 {
+		delete(p.sessions, id)
+	}
+	p.mu.Unlock()
+	if !exists || rs.length == 0 {
+		return RoundUpdate{}, fmt.Errorf("%w: no masked update for round %d", ErrSecureRound, req.Round)
+	}
+
+	own := rs.session.PublicKey()
+	dropped := make([][]byte, 0, len(req.Dropped))
+	for _, name := range req.Dropped {
+		key, ok := rs.keys[name]
+		if !ok || bytes.Equal(key, own) {
+			return RoundUpdate{}, fmt.Errorf("%w: %s was not a peer in round %d", ErrSecureRound, name, req.Round)
+		}
+		dropped = append(dropped, key)
+	}
+	if len(rs.keys)-len(dropped) < minSecureParticipants {
+		return RoundUpdate{}, fmt.Errorf("%w: fewer than %d updates would remain", ErrSecureRound, minSecureParticipants)
+	}
+
+	correction, err := rs.session.Unmask(rs.length, dropped, maskContext(req))
+	if err != nil {
+		return RoundUpdate{}, fmt.Errorf("%w: %v", ErrSecureRound, err)
+	}
+	return RoundUpdate{Masked: correction}, nil
+}
+
+// checkKeys requires enough peers and the participant's own key among them
+func checkKeys(keys map[string][]byte, own []byte) error {
+	if len(keys) < minSecureParticipants {
+		return fmt.Errorf("%w: %d keys, at least %d required", ErrSecureRound, len(keys), minSecureParticipants)
+	}
+	for _, key := range keys {
+		if bytes.Equal(key, own) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: own key is missing", ErrSecureRound)
+}
+
+// keyList returns the keys in participant order
+func keyList(keys map[string][]byte) [][]byte {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([][]byte, len(names))
+	for i, name := range names {
+		list[i] = keys[name]
+	}
+	return list
+}
+
+// secureVector lays out a participant's share of a secure sum: its weights
+// scaled by its sample count, or with clipNorm set its change to the model
+// clipped to clipNorm, followed by the sample count and 1 if the change was
+// clipped. Summing the vectors gives the coordinator everything it needs to
+// aggregate.
+func secureVector(update RoundUpdate, model []float64, clipNorm float64) []float64 {
+	size := len(update.Weights)
+	vector := make([]float64, size+2)
+	vector[size] = float64(update.Samples)
+	if clipNorm <= 0 {
+		for i, w := range update.Weights {
+			vector[i] = w * float64(update.Samples)
+		}
+		return vector
+	}
+
+	for i, w := range update.Weights {
+		vector[i] = w
+		if i < len(model) {
+			vector[i] -= model[i]
+		}
+	}
+	if n := norm(vector[:size]); n > clipNorm {
+		for i := range vector[:size] {
+			vector[i] *= clipNorm / n
+		}
+		vector[size+1] = 1
+	}
+	return vector
+}
+
+// secureRound runs a round with secure aggregation and returns the next
+// global model. The coordinator sees only masked updates and their sum.
+func (c *Coordinator) secureRound(ctx context.Context, plan Plan, round int, model []float64, minUpdates int, report *RoundReport) ([]float64, error) {
+	roundCtx, cancel := context.WithTimeout(ctx, plan.RoundTimeout)
+	defer cancel()
+
+	base := RoundRequest{
+		FederationID: plan.FederationID,
+		Round:        round,
+		Dataset:      plan.Dataset,
+		Task:         plan.Task,
+		Epsilon:      plan.Epsilon,
+	}
+	failed := make(map[string]string)
+	defer func() {
+		if len(failed) > 0 {
+			report.Failed = failed
+		}
+	}()
+
+	// Phase 1: collect a fresh key from each participant
+	req := base
+	req.Phase = PhaseKeys
+	replies := c.gather(roundCtx, plan, plan.Participants, req, failed)
+	keys := make(map[string][]byte, len(replies))
+	seen := make(map[string]bool, len(replies))
+	for _, name := range sortedNames(replies) {
+		key := replies[name].PublicKey
+		if len(key) == 0 || seen[string(key)] {
+			failed[name] = fmt.Sprintf("%v: missing or duplicate public key", ErrSecureRound)
+			continue
+		}
+		seen[string(key)] = true
+		keys[name] = key
+	}
+	if len(keys) < minUpdates {
+		return nil, fmt.Errorf("%w: round %d got %d keys of the %d required", ErrTooFewUpdates, round, len(keys), minUpdates)
+	}
+
+	// Phase 2: collect masked updates from the participants that sent keys
+	req = base
+	req.Phase = PhaseMasked
+	req.Keys = keys
+	req.Model = model
+	if plan.Noise != nil {
+		req.ClipNorm = plan.Noise.ClipNorm
+	}
+	replies = c.gather(roundCtx, plan, sortedNames(keys), req, failed)
+	length := len(model) + 2
+	if len(model) == 0 && len(replies) > 0 {
+		// Without a global model yet, the first update fixes the model size
+		length = len(replies[sortedNames(replies)[0]].Masked)
+	}
+	var masked [][]uint64
+	survivors := make(map[string]bool, len(replies))
+	for _, name := range sortedNames(replies) {
+		if len(replies[name].Masked) != length || length < 3 {
+			failed[name] = fmt.Sprintf("%v: masked update has %d values, expected %d", ErrInvalidUpdate, len(replies[name].Masked), length)
+			continue
+		}
+		masked = append(masked, replies[name].Masked)
+		survivors[name] = true
+	}
+	if len(masked) < minUpdates {
+		return nil, fmt.Errorf("%w: round %d got %d of the %d required", ErrTooFewUpdates, round, len(masked), minUpdates)
+	}
+	sum, err := fl.Sum(masked...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSecureRound, err)
+	}
+
+	// Phase 3: cancel the masks of participants that dropped after sending
+	// their keys. Every survivor must answer, or its masks stay in the sum.
+	var dropped []string
+	for name := range keys {
+		if !survivors[name] {
+			dropped = append(dropped, name)
+		}
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		req = base
+		req.Phase = PhaseUnmask
+		req.Keys = keys
+		req.Dropped = dropped
+		unmaskFailed := make(map[string]string)
+		corrections := c.gather(roundCtx, plan, sortedNames(survivors), req, unmaskFailed)
+		for name, reason := range unmaskFailed {
+			failed[name] = reason
+		}
+		if len(corrections) != len(survivors) {
+			return nil, fmt.Errorf("%w: %d of %d participants did not reveal their masks for dropped participants", ErrSecureRound, len(survivors)-len(corrections), len(survivors))
+		}
+		for _, correction := range corrections {
+			if err := fl.Subtract(sum, correction.Masked); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrSecureRound, err)
+			}
+		}
+	}
+
+	return c.aggregateSum(model, fl.Decode(sum), len(masked), plan.Noise, report)
+}
+
+// aggregateSum turns the decoded sum of secure vectors into the next global
+// model
+func (c *Coordinator) aggregateSum(model, sum []float64, updates int, noise *NoiseConfig, report *RoundReport) ([]float64, error) {
+	size := len(sum) - 2
+	samples := sum[size]
+	report.Updates = updates
+	report.Samples = int(math.Round(samples))
+	report.Clipped = int(math.Round(sum[size+1]))
+
+	next := make([]float64, size)
+	if noise == nil {
+		if samples <= 0 {
+			return nil, fmt.Errorf("%w: updates were trained on no samples", ErrInvalidUpdate)
+		}
+		for i := range next {
+			next[i] = sum[i] / samples
+		}
+		return next, nil
+	}
+
+	for i := range next {
+		next[i] = sum[i] / float64(updates)
+		if i < len(model) {
+			next[i] += model[i]
+		}
+	}
+	c.addNoise(next, noise.NoiseMultiplier*noise.ClipNorm/float64(updates))
+	return next, nil
+}
+
+// sortedNames returns a map's keys in order
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
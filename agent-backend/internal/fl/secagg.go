@@ -0,0 +1,230 @@
+// Package fl implements secure aggregation of federated learning updates,
+// so a coordinator learns the sum of the participants' updates but none of
+// the individual updates.
+//
+// It uses pairwise masking. For each round every participant generates an
+// ephemeral X25519 key, and the coordinator relays the public keys. Each
+// pair of participants derives a shared secret, expanded with HKDF-SHA256
+// and ChaCha20 into a mask as long as the update. Of each pair, the
+// participant with the lower public key adds the mask to its update and the
+// other subtracts it, so every mask cancels in the sum.
+//
+// Updates are encoded as fixed-point integers and summed modulo 2^64, which
+// makes the cancellation exact. A masked update is uniformly random to
+// anyone without the pairwise secrets.
+//
+// If participants drop out after the keys are exchanged, their masks no
+// longer cancel. The survivors then reveal the masks they share with the
+// dropped participants, which the coordinator subtracts. This assumes an
+// honest-but-curious coordinator: one that falsely reports a participant as
+// dropped after receiving its masked update could unmask that update.
+package fl
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+// FractionalBits is the fixed-point precision of encoded values. Values are
+// rounded to multiples of 2^-FractionalBits, and sums must stay below
+// 2^(63-FractionalBits) in magnitude.
+const FractionalBits = 24
+
+// maskInfo separates mask keys from other uses of the shared secrets
+const maskInfo = "pandacea-secagg-mask-v1"
+
+var (
+	// ErrInvalidKey is returned for malformed, duplicate or missing public keys
+	ErrInvalidKey = errors.New("invalid secure aggregation key")
+	// ErrLengthMismatch is returned when vectors of different lengths are combined
+	ErrLengthMismatch = errors.New("vector length mismatch")
+)
+
+// Encode converts values to fixed-point integers that can be masked and
+// summed modulo 2^64
+func Encode(values []float64) []uint64 {
+	encoded := make([]uint64, len(values))
+	for i, v := range values {
+		encoded[i] = uint64(int64(math.Round(math.Ldexp(v, FractionalBits))))
+	}
+	return encoded
+}
+
+// Decode converts a fixed-point sum back to floating point
+func Decode(encoded []uint64) []float64 {
+	values := make([]float64, len(encoded))
+	for i, v := range encoded {
+		values[i] = math.Ldexp(float64(int64(v)), -FractionalBits)
+	}
+	return values
+}
+
+// Sum adds vectors element-wise modulo 2^64
+func Sum(vectors ...[]uint64) ([]uint64, error) {
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	sum := make([]uint64, len(vectors[0]))
+	for _, v := range vectors {
+		if len(v) != len(sum) {
+			return nil, fmt.Errorf("%w: %d and %d", ErrLengthMismatch, len(v), len(sum))
+		}
+		for i := range v {
+			sum[i] += v[i]
+		}
+	}
+	return sum, nil
+}
+
+// Subtract removes v from sum in place, modulo 2^64
+func Subtract(sum, v []uint64) error {
+	if len(v) != len(sum) {
+		return fmt.Errorf("%w: %d and %d", ErrLengthMismatch, len(v), len(sum))
+	}
+	for i := range v {
+		sum[i] -= v[i]
+	}
+	return nil
+}
+
+// Session is one participant's part in one aggregation. Sessions must not
+// be reused: masks derived from the same keys and context repeat.
+type Session struct {
+	key *ecdh.PrivateKey
+}
+
+// NewSession generates a session with a fresh X25519 key
+func NewSession() (*Session, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	return &Session{key: key}, nil
+}
+
+// PublicKey returns the key the coordinator relays to the other participants
+func (s *Session) PublicKey() []byte {
+	return s.key.PublicKey().Bytes()
+}
+
+// Mask returns the update masked against every peer. peers are the public
+// keys of all participants in the aggregation and may include this
+// session's own key, which is skipped. context binds the masks to one
+// aggregation, e.g. a federation ID and round, and every participant must
+// use the same context.
+func (s *Session) Mask(update []uint64, peers [][]byte, context []byte) ([]uint64, error) {
+	if err := s.checkPeers(peers); err != nil {
+		return nil, err
+	}
+	masked := append([]uint64(nil), update...)
+	if err := s.applyMasks(masked, peers, context); err != nil {
+		return nil, err
+	}
+	return masked, nil
+}
+
+// Unmask returns the net mask this session added for the dropped peers.
+// The coordinator subtracts it from the sum of the surviving updates,
+// cancelling the masks the dropped peers' missing updates would have.
+func (s *Session) Unmask(length int, dropped [][]byte, context []byte) ([]uint64, error) {
+	if err := s.checkPeers(dropped); err != nil {
+		return nil, err
+	}
+	own := s.PublicKey()
+	for _, peer := range dropped {
+		if bytes.Equal(peer, own) {
+			return nil, fmt.Errorf("%w: cannot unmask against own key", ErrInvalidKey)
+		}
+	}
+	correction := make([]uint64, length)
+	if err := s.applyMasks(correction, dropped, context); err != nil {
+		return nil, err
+	}
+	return correction, nil
+}
+
+// checkPeers rejects duplicate and malformed public keys
+func (s *Session) checkPeers(peers [][]byte) error {
+	seen := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		if seen[string(peer)] {
+			return fmt.Errorf("%w: duplicate public key", ErrInvalidKey)
+		}
+		seen[string(peer)] = true
+		if _, err := ecdh.X25519().NewPublicKey(peer); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+	}
+	return nil
+}
+
+// applyMasks adds this session's pairwise mask with each peer to v in place
+func (s *Session) applyMasks(v []uint64, peers [][]byte, context []byte) error {
+	own := s.PublicKey()
+	for _, peer := range peers {
+		order := bytes.Compare(own, peer)
+		if order == 0 {
+			continue
+		}
+		mask, err := s.pairMask(peer, order < 0, len(v), context)
+		if err != nil {
+			return err
+		}
+		for i := range v {
+			if order < 0 {
+				v[i] += mask[i]
+			} else {
+				v[i] -= mask[i]
+			}
+		}
+	}
+	return nil
+}
+
+// pairMask expands the secret shared with peer into a mask of length
+// values. Both sides derive the same mask, binding the pair's keys in a
+// fixed order.
+func (s *Session) pairMask(peer []byte, ownFirst bool, length int, context []byte) ([]uint64, error) {
+	remote, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	shared, err := s.key.ECDH(remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive pairwise secret: %w", err)
+	}
+
+	low, high := s.PublicKey(), peer
+	if !ownFirst {
+		low, high = high, low
+	}
+	info := append([]byte(maskInfo), low...)
+	info = append(info, high...)
+	info = append(info, context...)
+	key := make([]byte, chacha20.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, info), key); err != nil {
+		return nil, fmt.Errorf("failed to derive mask key: %w", err)
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(key, make([]byte, chacha20.NonceSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mask stream: %w", err)
+	}
+	raw := make([]byte, 8*length)
+	stream.XORKeyStream(raw, raw)
+	mask := make([]uint64, length)
+	for i := range mask {
+		mask[i] = binary.LittleEndian.Uint64(raw[8*i:])
+	}
+	return mask, nil
+}
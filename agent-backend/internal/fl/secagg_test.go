@@ -0,0 +1,109 @@
+package fl
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func newSessions(t *testing.T, n int) ([]*Session, [][]byte) {
+	t.Helper()
+	sessions := make([]*Session, n)
+	keys := make([][]byte, n)
+	for i := range sessions {
+		s, err := NewSession()
+		if err != nil {
+			t.Fatalf("NewSession() error = %v", err)
+		}
+		sessions[i], keys[i] = s, s.PublicKey()
+	}
+	return sessions, keys
+}
+
+func assertValues(t *testing.T, got []float64, want ...float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("values = %v, want %v", got, want)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-6 {
+			t.Fatalf("values = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMasksCancelInTheSum(t *testing.T) {
+	sessions, keys := newSessions(t, 3)
+	updates := [][]float64{{1.5, -2}, {0.25, 3}, {-4, 0.125}}
+	context := []byte("fed_test/1")
+
+	masked := make([][]uint64, len(sessions))
+	for i, s := range sessions {
+		var err error
+		masked[i], err = s.Mask(Encode(updates[i]), keys, context)
+		if err != nil {
+			t.Fatalf("Mask() error = %v", err)
+		}
+		// A masked update reveals nothing of the update it hides
+		if values := Decode(masked[i]); math.Abs(values[0]-updates[i][0]) < 1 {
+			t.Errorf("masked update %d is close to its update: %v", i, values)
+		}
+	}
+
+	sum, err := Sum(masked...)
+	if err != nil {
+		t.Fatalf("Sum() error = %v", err)
+	}
+	assertValues(t, Decode(sum), -2.25, 1.125)
+}
+
+func TestUnmaskRecoversFromDropouts(t *testing.T) {
+	sessions, keys := newSessions(t, 4)
+	context := []byte("fed_test/2")
+
+	// Participants 2 and 3 exchanged keys but never sent their updates
+	var masked [][]uint64
+	for i, s := range sessions[:2] {
+		m, err := s.Mask(Encode([]float64{float64(i + 1)}), keys, context)
+		if err != nil {
+			t.Fatalf("Mask() error = %v", err)
+		}
+		masked = append(masked, m)
+	}
+	sum, err := Sum(masked...)
+	if err != nil {
+		t.Fatalf("Sum() error = %v", err)
+	}
+
+	for _, s := range sessions[:2] {
+		correction, err := s.Unmask(1, keys[2:], context)
+		if err != nil {
+			t.Fatalf("Unmask() error = %v", err)
+		}
+		if err := Subtract(sum, correction); err != nil {
+			t.Fatalf("Subtract() error = %v", err)
+		}
+	}
+	assertValues(t, Decode(sum), 3)
+
+	if _, err := sessions[0].Unmask(1, keys[:1], context); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Unmask() against own key error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestMaskRejectsBadKeys(t *testing.T) {
+	sessions, keys := newSessions(t, 2)
+	if _, err := sessions[0].Mask(Encode([]float64{1}), [][]byte{keys[1], keys[1]}, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Mask() with duplicate keys error = %v, want ErrInvalidKey", err)
+	}
+	if _, err := sessions[0].Mask(Encode([]float64{1}), [][]byte{[]byte("short")}, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Mask() with a malformed key error = %v, want ErrInvalidKey", err)
+	}
+	if _, err := Sum([]uint64{1}, []uint64{1, 2}); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Sum() error = %v, want ErrLengthMismatch", err)
+	}
+}
+
+func TestEncodeRoundTripsNegativeValues(t *testing.T) {
+	assertValues(t, Decode(Encode([]float64{-3.75, 0, 1e6})), -3.75, 0, 1e6)
+}
@@ -0,0 +1,111 @@
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	if err := WriteFile(path, []byte("first"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "second" {
+		t.Fatalf("contents = %q, want %q", data, "second")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	assertOnlyFile(t, path)
+}
+
+func TestReplaceKeepsOldContentsOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	failed := errors.New("encode failed")
+	err := Replace(path, 0600, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Replace error = %v, want %v", err, failed)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "old" {
+		t.Fatalf("contents = %q, want %q", data, "old")
+	}
+	assertOnlyFile(t, path)
+}
+
+func TestWriteFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	contents := []string{"aaaa", "bbbb", "cccc", "dddd"}
+
+	var wg sync.WaitGroup
+	for _, c := range contents {
+		wg.Add(1)
+		go func(c string) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := WriteFile(path, []byte(c), 0600); err != nil {
+					t.Errorf("WriteFile: %v", err)
+					return
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// Concurrent writers each use their own temporary file, so the result
+	// is one writer's contents whole
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	found := false
+	for _, c := range contents {
+		found = found || string(data) == c
+	}
+	if !found {
+		t.Fatalf("contents = %q, want one writer's contents", data)
+	}
+	assertOnlyFile(t, path)
+}
+
+// assertOnlyFile checks that no temporary files were left next to path
+func assertOnlyFile(t *testing.T, path string) {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(path) {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		t.Fatalf("directory holds %v, want only %s", names, filepath.Base(path))
+	}
+}
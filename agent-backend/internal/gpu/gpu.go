@@ -0,0 +1,319 @@
+// Package gpu finds the NVIDIA GPUs on an earner's host, hands them out to
+// the jobs that request them and samples how busy they are.
+//
+// GPUs are found with nvidia-smi. Containers can only use them if Docker
+// has the NVIDIA container runtime, so a host without it has no GPUs as far
+// as jobs are concerned.
+package gpu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrUnavailable is returned for jobs that request more GPUs than the
+// host has, including hosts without any
+var ErrUnavailable = errors.New("not enough GPUs")
+
+var (
+	gpuUtilization = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_gpu_utilization_percent",
+		Help: "GPU utilization at the last sample, by device index.",
+	}, []string{"device"})
+	gpuMemoryUsed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_gpu_memory_used_bytes",
+		Help: "GPU memory in use at the last sample, by device index.",
+	}, []string{"device"})
+	gpusAllocated = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_gpus_allocated",
+		Help: "GPUs held by running jobs.",
+	})
+)
+
+// Device is a GPU on the host
+type Device struct {
+	Index    int    `json:"index"`
+	Name     string `json:"name"`
+	MemoryMB int    `json:"memory_mb"`
+}
+
+// Usage is how busy a GPU was when it was last sampled
+type Usage struct {
+	Index              int       `json:"index"`
+	UtilizationPercent float64   `json:"utilization_percent"`
+	MemoryUsedMB       int       `json:"memory_used_mb"`
+	SampledAt          time.Time `json:"sampled_at"`
+}
+
+// Allocation is the GPUs a job requested and was given, as job status
+// reports them
+type Allocation struct {
+	Count       int     `json:"count"`                 // GPUs requested
+	Devices     []int   `json:"devices,omitempty"`     // Indexes of the GPUs the job holds or held
+	Utilization []Usage `json:"utilization,omitempty"` // Latest samples of those GPUs
+}
+
+// Info is what Detect found
+type Info struct {
+	Devices []Device `json:"devices"`
+	Runtime bool     `json:"runtime"` // Docker has the NVIDIA container runtime
+}
+
+// Available reports whether jobs can use the GPUs found
+func (i Info) Available() bool {
+	return len(i.Devices) > 0 && i.Runtime
+}
+
+// Indexes returns the indexes of the devices found
+func (i Info) Indexes() []int {
+	indexes := make([]int, len(i.Devices))
+	for n, d := range i.Devices {
+		indexes[n] = d.Index
+	}
+	return indexes
+}
+
+// command runs a CLI and returns its output; replaced in tests
+var command = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// Detect lists the host's GPUs and checks for the NVIDIA container
+// runtime. A host without nvidia-smi has no GPUs and is not an error.
+func Detect(ctx context.Context) (Info, error) {
+	var info Info
+	output, err := command(ctx, "nvidia-smi", "--query-gpu=index,name,memory.total", "--format=csv,noheader,nounits")
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return info, nil
+		}
+		return info, fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	if info.Devices, err = parseDevices(string(output)); err != nil {
+		return info, err
+	}
+
+	output, err = command(ctx, "docker", "info", "--format", "{{json .Runtimes}}")
+	if err != nil {
+		return info, fmt.Errorf("failed to list docker runtimes: %w", err)
+	}
+	var runtimes map[string]json.RawMessage
+	if err := json.Unmarshal(output, &runtimes); err != nil {
+		return info, fmt.Errorf("failed to parse docker runtimes: %w", err)
+	}
+	_, info.Runtime = runtimes["nvidia"]
+	return info, nil
+}
+
+// parseDevices parses nvidia-smi's CSV listing of index, name and total memory
+func parseDevices(output string) ([]Device, error) {
+	var devices []Device
+	for _, fields := range csvLines(output) {
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", strings.Join(fields, ", "))
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU index %q", fields[0])
+		}
+		memory, _ := strconv.Atoi(fields[2])
+		devices = append(devices, Device{Index: index, Name: fields[1], MemoryMB: memory})
+	}
+	return devices, nil
+}
+
+// parseUsage parses nvidia-smi's CSV listing of index, utilization and
+// used memory
+func parseUsage(output string, now time.Time) ([]Usage, error) {
+	var usage []Usage
+	for _, fields := range csvLines(output) {
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", strings.Join(fields, ", "))
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU index %q", fields[0])
+		}
+		// Fields a GPU cannot report read [N/A] and are left zero
+		utilization, _ := strconv.ParseFloat(fields[1], 64)
+		memory, _ := strconv.Atoi(fields[2])
+		usage = append(usage, Usage{Index: index, UtilizationPercent: utilization, MemoryUsedMB: memory, SampledAt: now})
+	}
+	return usage, nil
+}
+
+// csvLines splits nvidia-smi CSV output into trimmed fields per line
+func csvLines(output string) [][]string {
+	var lines [][]string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+// VisibleDevices formats device indexes for CUDA_VISIBLE_DEVICES,
+// NVIDIA_VISIBLE_DEVICES and docker's --gpus device=
+func VisibleDevices(devices []int) string {
+	ids := make([]string, len(devices))
+	for i, d := range devices {
+		ids[i] = strconv.Itoa(d)
+	}
+	return strings.Join(ids, ",")
+}
+
+// Allocator hands GPUs out to jobs so no two jobs share one
+type Allocator struct {
+	mu      sync.Mutex
+	devices []int
+	free    map[int]bool
+	changed chan struct{}
+}
+
+// NewAllocator creates an allocator of the GPUs with the given indexes
+func NewAllocator(devices []int) *Allocator {
+	a := &Allocator{devices: slices.Clone(devices), free: make(map[int]bool, len(devices))}
+	for _, d := range devices {
+		a.free[d] = true
+	}
+	return a
+}
+
+// Total returns how many GPUs the allocator hands out
+func (a *Allocator) Total() int {
+	return len(a.devices)
+}
+
+// Acquire waits until n GPUs are free and takes them, lowest index first.
+// It fails at once if the host has fewer than n GPUs.
+func (a *Allocator) Acquire(ctx context.Context, n int) ([]int, error) {
+	if n <= 0 || n > len(a.devices) {
+		return nil, fmt.Errorf("%w: %d requested, %d on this host", ErrUnavailable, n, len(a.devices))
+	}
+	for {
+		a.mu.Lock()
+		var taken []int
+		for _, d := range a.devices {
+			if a.free[d] && len(taken) < n {
+				taken = append(taken, d)
+			}
+		}
+		if len(taken) == n {
+			for _, d := range taken {
+				a.free[d] = false
+			}
+			a.mu.Unlock()
+			gpusAllocated.Add(float64(n))
+			return taken, nil
+		}
+		if a.changed == nil {
+			a.changed = make(chan struct{})
+		}
+		changed := a.changed
+		a.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release returns GPUs taken with Acquire
+func (a *Allocator) Release(devices []int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, d := range devices {
+		a.free[d] = true
+	}
+	gpusAllocated.Sub(float64(len(devices)))
+	if a.changed != nil {
+		close(a.changed)
+		a.changed = nil
+	}
+}
+
+// Monitor samples GPU utilization with nvidia-smi and keeps the latest
+// sample of each GPU
+type Monitor struct {
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	latest map[int]Usage
+}
+
+// NewMonitor creates a monitor; call Run to start sampling
+func NewMonitor(logger *slog.Logger) *Monitor {
+	return &Monitor{logger: logger, latest: make(map[int]Usage)}
+}
+
+// Sample reads every GPU's utilization once
+func (m *Monitor) Sample(ctx context.Context) error {
+	output, err := command(ctx, "nvidia-smi", "--query-gpu=index,utilization.gpu,memory.used", "--format=csv,noheader,nounits")
+	if err != nil {
+		return fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	usage, err := parseUsage(string(output), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range usage {
+		m.latest[u.Index] = u
+		device := strconv.Itoa(u.Index)
+		gpuUtilization.WithLabelValues(device).Set(u.UtilizationPercent)
+		gpuMemoryUsed.WithLabelValues(device).Set(float64(u.MemoryUsedMB) * (1 << 20))
+	}
+	return nil
+}
+
+// Usage returns the latest samples of the given GPUs; GPUs not sampled
+// yet are left out
+func (m *Monitor) Usage(devices []int) []Usage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var usage []Usage
+	for _, d := range devices {
+		if u, ok := m.latest[d]; ok {
+			usage = append(usage, u)
+		}
+	}
+	return usage
+}
+
+// Run samples every interval until ctx is done, starting at once
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Sample(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Warn("failed to sample GPU utilization", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
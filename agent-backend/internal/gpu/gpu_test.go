@@ -0,0 +1,117 @@
+package gpu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCommands answers CLI invocations by command name
+func fakeCommands(t *testing.T, outputs map[string]string) {
+	t.Helper()
+	original := command
+	command = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		output, ok := outputs[name+" "+args[0]]
+		if !ok {
+			return nil, exec.ErrNotFound
+		}
+		return []byte(output), nil
+	}
+	t.Cleanup(func() { command = original })
+}
+
+func TestDetect(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"nvidia-smi --query-gpu=index,name,memory.total": "0, NVIDIA A100-SXM4-40GB, 40960\n1, NVIDIA A100-SXM4-40GB, 40960\n",
+		"docker info": `{"io.containerd.runc.v2":{"path":"runc"},"nvidia":{"path":"nvidia-container-runtime"}}`,
+	})
+	info, err := Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if !info.Available() || len(info.Devices) != 2 || info.Devices[1].MemoryMB != 40960 {
+		t.Errorf("Detect() = %+v, want two available A100s", info)
+	}
+	if got := VisibleDevices(info.Indexes()); got != "0,1" {
+		t.Errorf("VisibleDevices() = %q, want 0,1", got)
+	}
+
+	// GPUs Docker cannot hand to containers are not available
+	fakeCommands(t, map[string]string{
+		"nvidia-smi --query-gpu=index,name,memory.total": "0, Tesla T4, 15360",
+		"docker info": `{"runc":{"path":"runc"}}`,
+	})
+	if info, err := Detect(context.Background()); err != nil || info.Available() {
+		t.Errorf("Detect() without the NVIDIA runtime = %+v, %v, want unavailable", info, err)
+	}
+
+	fakeCommands(t, nil)
+	if info, err := Detect(context.Background()); err != nil || len(info.Devices) != 0 {
+		t.Errorf("Detect() without nvidia-smi = %+v, %v, want no GPUs", info, err)
+	}
+}
+
+func TestAllocator(t *testing.T) {
+	a := NewAllocator([]int{0, 1, 2})
+	ctx := context.Background()
+
+	first, err := a.Acquire(ctx, 2)
+	if err != nil || !slices.Equal(first, []int{0, 1}) {
+		t.Fatalf("Acquire(2) = %v, %v, want [0 1]", first, err)
+	}
+	if _, err := a.Acquire(ctx, 4); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Acquire(4) error = %v, want ErrUnavailable", err)
+	}
+
+	// A job that needs more GPUs than are free waits for them
+	acquired := make(chan []int)
+	go func() {
+		devices, _ := a.Acquire(ctx, 2)
+		acquired <- devices
+	}()
+	select {
+	case devices := <-acquired:
+		t.Fatalf("Acquire(2) = %v while only one GPU was free", devices)
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Release(first)
+	if devices := <-acquired; len(devices) != 2 {
+		t.Errorf("Acquire(2) after release = %v, want two GPUs", devices)
+	}
+
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.Acquire(cancelled, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() with busy GPUs error = %v, want the context's error", err)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"nvidia-smi --query-gpu=index,utilization.gpu,memory.used": "0, 87, 30210\n1, [N/A], 0\n",
+	})
+	m := NewMonitor(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if got := m.Usage([]int{0}); len(got) != 0 {
+		t.Errorf("Usage() before sampling = %v, want none", got)
+	}
+	if err := m.Sample(context.Background()); err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	usage := m.Usage([]int{0, 1, 5})
+	if len(usage) != 2 || usage[0].UtilizationPercent != 87 || usage[0].MemoryUsedMB != 30210 || usage[1].UtilizationPercent != 0 {
+		t.Errorf("Usage() = %+v, want GPU 0 at 87%% and GPU 1 unreported", usage)
+	}
+
+	fakeCommands(t, map[string]string{
+		"nvidia-smi --query-gpu=index,utilization.gpu,memory.used": "garbage",
+	})
+	if err := m.Sample(context.Background()); err == nil || !strings.Contains(err.Error(), "unexpected") {
+		t.Errorf("Sample() of malformed output error = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+// Common job states shared by job types
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// ErrInvalidTransition is returned when a job is moved to a state that is not
+// reachable from its current state
+var ErrInvalidTransition = errors.New("invalid job state transition")
+
+var (
+	transitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_job_transitions_total",
+		Help: "Job state transitions by job kind.",
+	}, []string{"kind", "from", "to"})
+
+	jobsInState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_jobs",
+		Help: "Number of tracked jobs by job kind and state.",
+	}, []string{"kind", "state"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pandacea_job_duration_seconds",
+		Help:    "Time from job creation to reaching a terminal state.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 14),
+	}, []string{"kind", "state"})
+)
+
+// Definition describes the states a job type may move through
+type Definition struct {
+	// Kind labels metrics and errors, e.g. "training" or "computation"
+	Kind string
+	// Initial is the state new jobs start in
+	Initial State
+	// Transitions lists the states reachable from each state. States with no
+	// outgoing transitions are terminal.
+	Transitions map[State][]State
+	// Timeouts bounds how long a job may stay in a non-terminal state
+	Timeouts map[State]time.Duration
+	// TimeoutState is the terminal state used for jobs that exceed a timeout
+	TimeoutState State
+	// Restartable lists the terminal states a job may be restarted from,
+	// returning it to Initial
+	Restartable []State
+}
+
+// Machine enforces a Definition and records job metrics
+type Machine struct {
+	def         Definition
+	transitions map[State]map[State]bool
+	restartable map[State]bool
+}
+
+// NewMachine creates a state machine for a job type
+func NewMachine(def Definition) *Machine {
+	transitions := make(map[State]map[State]bool, len(def.Transitions))
+	for from, targets := range def.Transitions {
+		transitions[from] = make(map[State]bool, len(targets))
+		for _, to := range targets {
+			transitions[from][to] = true
+		}
+	}
+
+	restartable := make(map[State]bool, len(def.Restartable))
+	for _, s := range def.Restartable {
+		restartable[s] = true
+	}
+
+	return &Machine{def: def, transitions: transitions, restartable: restartable}
+}
+
+// Kind returns the job kind this machine governs
+func (m *Machine) Kind() string {
+	return m.def.Kind
+}
+
+// Initial returns the state new jobs start in
+func (m *Machine) Initial() State {
+	return m.def.Initial
+}
+
+// IsTerminal reports whether no further transitions are allowed from s
+func (m *Machine) IsTerminal(s State) bool {
+	return len(m.transitions[s]) == 0
+}
+
+// CanTransition reports whether a job may move from one state to another
+func (m *Machine) CanTransition(from, to State) bool {
+	return m.transitions[from][to]
+}
+
+// Start records the creation of a job in the initial state
+func (m *Machine) Start() State {
+	jobsInState.WithLabelValues(m.def.Kind, string(m.def.Initial)).Inc()
+	return m.def.Initial
+}
+
+// Transition validates a state change and records it. createdAt is used to
+// observe total job duration when the job reaches a terminal state.
+func (m *Machine) Transition(from, to State, createdAt time.Time) error {
+	if !m.CanTransition(from, to) {
+		return fmt.Errorf("%w: %s job cannot move from %q to %q", ErrInvalidTransition, m.def.Kind, from, to)
+	}
+
+	transitionsTotal.WithLabelValues(m.def.Kind, string(from), string(to)).Inc()
+	jobsInState.WithLabelValues(m.def.Kind, string(from)).Dec()
+	jobsInState.WithLabelValues(m.def.Kind, string(to)).Inc()
+	if m.IsTerminal(to) && !createdAt.IsZero() {
+		jobDuration.WithLabelValues(m.def.Kind, string(to)).Observe(time.Since(createdAt).Seconds())
+	}
+
+	return nil
+}
+
+// Restart moves a finished job in state from back to the initial state,
+// e.g. to resume a failed job. Only Restartable states may be restarted.
+func (m *Machine) Restart(from State) (State, error) {
+	if !m.restartable[from] {
+		return from, fmt.Errorf("%w: %s job cannot be restarted from %q", ErrInvalidTransition, m.def.Kind, from)
+	}
+
+	transitionsTotal.WithLabelValues(m.def.Kind, string(from), string(m.def.Initial)).Inc()
+	jobsInState.WithLabelValues(m.def.Kind, string(from)).Dec()
+	jobsInState.WithLabelValues(m.def.Kind, string(m.def.Initial)).Inc()
+	return m.def.Initial, nil
+}
+
+// Restore records a job loaded from persistence in state s without a transition
+func (m *Machine) Restore(s State) {
+	jobsInState.WithLabelValues(m.def.Kind, string(s)).Inc()
+}
+
+// Forget removes a job in state s from the gauges, e.g. when it is purged
+func (m *Machine) Forget(s State) {
+	jobsInState.WithLabelValues(m.def.Kind, string(s)).Dec()
+}
+
+// TimedOut reports whether a job that entered state s at enteredAt has
+// exceeded the configured timeout for that state
+func (m *Machine) TimedOut(s State, enteredAt, now time.Time) bool {
+	timeout, ok := m.def.Timeouts[s]
+	if !ok || timeout <= 0 || m.IsTerminal(s) {
+		return false
+	}
+	return now.Sub(enteredAt) > timeout
+}
+
+// TimeoutState returns the state timed-out jobs are moved to
+func (m *Machine) TimeoutState() State {
+	if m.def.TimeoutState == "" {
+		return StateFailed
+	}
+	return m.def.TimeoutState
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testMachine() *Machine {
+	return NewMachine(Definition{
+		Kind:    "test",
+		Initial: StatePending,
+		Transitions: map[State][]State{
+			StatePending: {StateRunning, StateFailed},
+			StateRunning: {StateCompleted, StateFailed},
+		},
+		Timeouts: map[State]time.Duration{
+			StateRunning: time.Minute,
+		},
+		Restartable: []State{StateFailed},
+	})
+}
+
+func TestMachineTransitions(t *testing.T) {
+	m := testMachine()
+
+	if got := m.Start(); got != StatePending {
+		t.Fatalf("Start() = %q, want %q", got, StatePending)
+	}
+	if err := m.Transition(StatePending, StateRunning, time.Now()); err != nil {
+		t.Fatalf("pending -> running: %v", err)
+	}
+	if err := m.Transition(StateRunning, StateCompleted, time.Now()); err != nil {
+		t.Fatalf("running -> completed: %v", err)
+	}
+
+	// Terminal states accept no further transitions
+	err := m.Transition(StateCompleted, StateFailed, time.Now())
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("completed -> failed error = %v, want ErrInvalidTransition", err)
+	}
+	if err := m.Transition(StatePending, StateCompleted, time.Now()); err == nil {
+		t.Error("expected pending -> completed to be rejected")
+	}
+
+	if !m.IsTerminal(StateCompleted) || !m.IsTerminal(StateFailed) {
+		t.Error("completed and failed should be terminal")
+	}
+	if m.IsTerminal(StateRunning) {
+		t.Error("running should not be terminal")
+	}
+
+	// Failed jobs may be restarted, completed ones may not
+	if got, err := m.Restart(StateFailed); err != nil || got != StatePending {
+		t.Errorf("Restart(failed) = %q, %v, want %q", got, err, StatePending)
+	}
+	if _, err := m.Restart(StateCompleted); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Restart(completed) error = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestMachineTimedOut(t *testing.T) {
+	m := testMachine()
+	now := time.Now()
+
+	if !m.TimedOut(StateRunning, now.Add(-2*time.Minute), now) {
+		t.Error("expected running job to time out")
+	}
+	if m.TimedOut(StateRunning, now.Add(-30*time.Second), now) {
+		t.Error("running job should not time out before its deadline")
+	}
+	if m.TimedOut(StatePending, now.Add(-time.Hour), now) {
+		t.Error("pending has no timeout configured")
+	}
+	if m.TimedOut(StateFailed, now.Add(-time.Hour), now) {
+		t.Error("terminal states never time out")
+	}
+	if m.TimeoutState() != StateFailed {
+		t.Errorf("TimeoutState() = %q, want %q", m.TimeoutState(), StateFailed)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	type job struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+
+	if err := store.Save("job_1", job{ID: "job_1", Status: "running"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save("job_1", job{ID: "job_1", Status: "completed"}); err != nil {
+		t.Fatalf("Save() overwrite error = %v", err)
+	}
+	if err := store.Save("job_2", job{ID: "job_2", Status: "pending"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save("../escape", job{}); err == nil {
+		t.Error("expected path traversal ID to be rejected")
+	}
+	if err := store.Delete("job_2"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	loaded := map[string]job{}
+	err = store.LoadAll(func(id string, data []byte) error {
+		var j job
+		if err := json.Unmarshal(data, &j); err != nil {
+			return err
+		}
+		loaded[id] = j
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+
+	if len(loaded) != 1 || loaded["job_1"].Status != "completed" {
+		t.Errorf("LoadAll() = %v, want only job_1 completed", loaded)
+	}
+}
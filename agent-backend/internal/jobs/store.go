@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Store persists job snapshots so job state survives agent restarts
+type Store interface {
+	Save(id string, job any) error
+	Delete(id string) error
+	LoadAll(fn func(id string, data []byte) error) error
+}
+
+// validJobID restricts IDs to characters that are safe as file names
+var validJobID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// FileStore stores each job as a JSON file in a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file-backed job store rooted at dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save atomically writes the job snapshot for id
+func (fs *FileStore) Save(id string, job any) error {
+	if !validJobID.MatchString(id) {
+		return fmt.Errorf("invalid job ID: %q", id)
+	}
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	if err := fsutil.WriteFile(filepath.Join(fs.dir, id+".json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the snapshot for id
+func (fs *FileStore) Delete(id string) error {
+	if !validJobID.MatchString(id) {
+		return fmt.Errorf("invalid job ID: %q", id)
+	}
+	err := os.Remove(filepath.Join(fs.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// LoadAll calls fn with every stored job snapshot
+func (fs *FileStore) LoadAll(fn func(id string, data []byte) error) error {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read job store: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(fs.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read job %s: %w", entry.Name(), err)
+		}
+		if err := fn(strings.TrimSuffix(entry.Name(), ".json"), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package lineage records how data flows through the agent: which products
+// and assets each lease covers, which leases and assets each computation
+// and training job consumed, and which job produced each model. The edges
+// form a graph that answers questions such as which leases contributed to
+// a model, or which models a lease's data ended up in.
+package lineage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Kinds of node
+const (
+	KindProduct     = "product"
+	KindAsset       = "asset"
+	KindLease       = "lease"
+	KindComputation = "computation"
+	KindJob         = "job" // Training job
+	KindModel       = "model"
+)
+
+// Relations an edge records. Edges point the way data flows, from what
+// was consumed to what consumed it.
+const (
+	RelationContains   = "contains"   // A product's asset
+	RelationLeased     = "leased"     // A product leased under a lease
+	RelationAuthorized = "authorized" // A lease a computation or job ran under
+	RelationInput      = "input"      // An asset or product a computation or job read
+	RelationProduced   = "produced"   // A model a job trained
+)
+
+// Directions a graph is walked from a node
+const (
+	Upstream   = "upstream"   // What the node was derived from
+	Downstream = "downstream" // What was derived from the node
+)
+
+// ErrInvalidQuery is returned for unknown node kinds and directions
+var ErrInvalidQuery = errors.New("invalid lineage query")
+
+// Node is a product, asset, lease, computation, training job or model
+type Node struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// Edge records that data flowed from one node to another
+type Edge struct {
+	From      Node      `json:"from"`
+	To        Node      `json:"to"`
+	Relation  string    `json:"relation"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subgraph is the part of the graph reachable from Root in one direction
+type Subgraph struct {
+	Root      Node   `json:"root"`
+	Direction string `json:"direction"`
+	Nodes     []Node `json:"nodes"` // Reachable nodes, excluding Root
+	Edges     []Edge `json:"edges"`
+}
+
+// Of returns the IDs of the subgraph's nodes of one kind, sorted
+func (s Subgraph) Of(kind string) []string {
+	ids := []string{}
+	for _, n := range s.Nodes {
+		if n.Kind == kind {
+			ids = append(ids, n.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ValidKind reports whether kind is a kind of node
+func ValidKind(kind string) bool {
+	switch kind {
+	case KindProduct, KindAsset, KindLease, KindComputation, KindJob, KindModel:
+		return true
+	}
+	return false
+}
+
+// edgeKey identifies an edge regardless of when it was recorded
+type edgeKey struct {
+	from, to Node
+	relation string
+}
+
+// Graph keeps lineage edges. It is safe for concurrent use.
+type Graph struct {
+	mu    sync.RWMutex
+	path  string
+	edges []Edge
+	seen  map[edgeKey]bool
+	out   map[Node][]int // Indexes into edges by From
+	in    map[Node][]int // Indexes into edges by To
+	now   func() time.Time
+}
+
+// NewGraph creates a graph that persists its edges to path unless it is
+// empty, restoring any already saved there
+func NewGraph(path string) (*Graph, error) {
+	g := &Graph{path: path, seen: make(map[edgeKey]bool), out: make(map[Node][]int), in: make(map[Node][]int), now: time.Now}
+	if path == "" {
+		return g, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lineage graph: %w", err)
+	}
+	var edges []Edge
+	if err := json.Unmarshal(data, &edges); err != nil {
+		return nil, fmt.Errorf("failed to parse lineage graph: %w", err)
+	}
+	for _, e := range edges {
+		g.add(e)
+	}
+	return g, nil
+}
+
+// add indexes an edge unless it is already recorded. Caller must hold
+// g.mu or own g.
+func (g *Graph) add(e Edge) bool {
+	key := edgeKey{e.From, e.To, e.Relation}
+	if g.seen[key] {
+		return false
+	}
+	g.seen[key] = true
+	g.edges = append(g.edges, e)
+	g.out[e.From] = append(g.out[e.From], len(g.edges)-1)
+	g.in[e.To] = append(g.in[e.To], len(g.edges)-1)
+	return true
+}
+
+// Record adds edges, skipping ones already recorded and ones with an
+// empty end
+func (g *Graph) Record(edges ...Edge) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now().UTC()
+	added := false
+	for _, e := range edges {
+		if e.From.ID == "" || e.To.ID == "" || e.From == e.To {
+			continue
+		}
+		e.CreatedAt = now
+		if g.add(e) {
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+	return g.save()
+}
+
+// Walk returns the nodes and edges reachable from root in direction
+func (g *Graph) Walk(root Node, direction string) (Subgraph, error) {
+	if !ValidKind(root.Kind) {
+		return Subgraph{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidQuery, root.Kind)
+	}
+	index, next := g.in, func(e Edge) Node { return e.From }
+	switch direction {
+	case Upstream:
+	case Downstream:
+		index, next = g.out, func(e Edge) Node { return e.To }
+	default:
+		return Subgraph{}, fmt.Errorf("%w: unknown direction %q", ErrInvalidQuery, direction)
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	sub := Subgraph{Root: root, Direction: direction, Nodes: []Node{}, Edges: []Edge{}}
+	visited := map[Node]bool{root: true}
+	queue := []Node{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, i := range index[node] {
+			e := g.edges[i]
+			sub.Edges = append(sub.Edges, e)
+			if n := next(e); !visited[n] {
+				visited[n] = true
+				sub.Nodes = append(sub.Nodes, n)
+				queue = append(queue, n)
+			}
+		}
+	}
+	return sub, nil
+}
+
+// save writes the edges to disk. Caller must hold g.mu.
+func (g *Graph) save() error {
+	if g.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(g.edges)
+	if err != nil {
+		return fmt.Errorf("failed to encode lineage graph: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), 0700); err != nil {
+		return fmt.Errorf("failed to create lineage graph directory: %w", err)
+	}
+	if err := fsutil.WriteFile(g.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write lineage graph: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package lineage
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lineage.json")
+	g, err := NewGraph(path)
+	if err != nil {
+		t.Fatalf("NewGraph: %v", err)
+	}
+
+	product := Node{KindProduct, "weather"}
+	leaseA, leaseB := Node{KindLease, "0xa"}, Node{KindLease, "0xb"}
+	job := Node{KindJob, "job-1"}
+	model := Node{KindModel, "job-1"}
+	computation := Node{KindComputation, "comp-1"}
+	if err := g.Record(
+		Edge{From: product, To: leaseA, Relation: RelationLeased},
+		Edge{From: product, To: leaseB, Relation: RelationLeased},
+		Edge{From: leaseA, To: job, Relation: RelationAuthorized},
+		Edge{From: product, To: job, Relation: RelationInput},
+		Edge{From: job, To: model, Relation: RelationProduced},
+		Edge{From: leaseB, To: computation, Relation: RelationAuthorized},
+		Edge{From: leaseB, To: Node{KindJob, ""}, Relation: RelationAuthorized},
+	); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	// Recording an edge again does not duplicate it
+	if err := g.Record(Edge{From: job, To: model, Relation: RelationProduced}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	up, err := g.Walk(model, Upstream)
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if got := up.Of(KindLease); !slices.Equal(got, []string{"0xa"}) {
+		t.Errorf("leases upstream of the model = %v, want only the job's lease", got)
+	}
+	if len(up.Edges) != 4 {
+		t.Errorf("upstream edges = %d, want 4", len(up.Edges))
+	}
+
+	// Edges survive a restart
+	restored, err := NewGraph(path)
+	if err != nil {
+		t.Fatalf("NewGraph: %v", err)
+	}
+	down, err := restored.Walk(leaseB, Downstream)
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(down.Nodes) != 1 || down.Nodes[0] != computation {
+		t.Errorf("downstream of lease B = %v, want its computation", down.Nodes)
+	}
+	down, _ = restored.Walk(product, Downstream)
+	if got := down.Of(KindModel); !slices.Equal(got, []string{"job-1"}) {
+		t.Errorf("models downstream of the product = %v", got)
+	}
+
+	if _, err := g.Walk(Node{"dataset", "x"}, Upstream); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Walk() of an unknown kind error = %v", err)
+	}
+	if _, err := g.Walk(model, "sideways"); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Walk() in an unknown direction error = %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+// Package market gives spenders a marketplace view of the network from a
+// single agent. Agents advertise in the DHT that they list products and
+// answer catalog queries over libp2p; a search fans the query out to the
+// advertised and connected agents, merges their listings with the local
+// catalog, ranks them by price and the reputation of the offering
+// earners and caches the result.
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/shopspring/decimal"
+)
+
+// ProtocolID is the libp2p protocol catalog queries are sent over
+const ProtocolID protocol.ID = "/pandacea/products/1.0.0"
+
+// Namespace is the DHT key agents listing products advertise under
+const Namespace = "pandacea/products/v1"
+
+// MaxMessageSize is the largest query or catalog that is read
+const MaxMessageSize = 4 << 20
+
+// maxCachedSearches bounds how many search results are cached at once
+const maxCachedSearches = 256
+
+// Sort orders for search results
+const (
+	SortPrice      = "price"      // Cheapest first, then by reputation
+	SortReputation = "reputation" // Most reputable first, then by price
+)
+
+// ErrInvalidQuery is returned for searches with an unknown sort order
+var ErrInvalidQuery = errors.New("invalid product search")
+
+// Query filters listings. Empty fields match everything.
+type Query struct {
+	Text     string `json:"text,omitempty"`      // Matched case-insensitively against name, product ID and keywords
+	DataType string `json:"data_type,omitempty"` // Exact data type
+}
+
+// Matches reports whether a listing passes the query
+func (q Query) Matches(l Listing) bool {
+	if q.DataType != "" && !strings.EqualFold(l.DataType, q.DataType) {
+		return false
+	}
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+	if text == "" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(l.Name), text) || strings.Contains(strings.ToLower(l.ProductID), text) {
+		return true
+	}
+	for _, keyword := range l.Keywords {
+		if strings.Contains(strings.ToLower(keyword), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Listing is a product offered by an agent
+type Listing struct {
+	PeerID    string   `json:"peer_id"` // Agent offering the product
+	ProductID string   `json:"product_id"`
+	Name      string   `json:"name"`
+	DataType  string   `json:"data_type"`
+	Keywords  []string `json:"keywords"`
+	Price     string   `json:"price"` // Agent's current minimum lease price in wei
+	// APIURL is where the offering agent takes lease proposals, and Earner
+	// the address its leases pay; both are empty unless the agent sets them
+	APIURL string `json:"api_url,omitempty"`
+	Earner string `json:"earner,omitempty"`
+	// Reputation is the searching agent's lease reputation for the earner,
+	// or for the offering peer if it lists none, in [0, 1]
+	Reputation float64 `json:"reputation"`
+	Local      bool    `json:"local,omitempty"` // Offered by the searching agent
+}
+
+// Catalog lists an agent's own products that match a query
+type Catalog interface {
+	Listings(q Query) []Listing
+}
+
+// Network is the P2P node a search runs on
+type Network interface {
+	Host() host.Host
+	FindProviders(ctx context.Context, namespace string, limit int) ([]peer.AddrInfo, error)
+}
+
+// Reputation scores counterparties by the outcomes of their leases
+type Reputation interface {
+	ScoreOrPrior(counterparty string) float64
+}
+
+// Serve registers the catalog protocol on h so other agents can query
+// catalog. Listings are stamped with this host's peer ID.
+func Serve(h host.Host, catalog Catalog, logger *slog.Logger) {
+	h.SetStreamHandler(ProtocolID, func(s network.Stream) {
+		defer s.Close()
+		remote := s.Conn().RemotePeer().String()
+
+		var q Query
+		if err := json.NewDecoder(io.LimitReader(s, MaxMessageSize)).Decode(&q); err != nil {
+			logger.Warn("failed to decode product query", "peer_id", remote, "error", err)
+			s.Reset()
+			return
+		}
+		listings := catalog.Listings(q)
+		for i := range listings {
+			listings[i].PeerID = h.ID().String()
+			listings[i].Local = false
+		}
+		if err := json.NewEncoder(s).Encode(listings); err != nil {
+			logger.Warn("failed to send product listings", "peer_id", remote, "error", err)
+			s.Reset()
+		}
+	})
+}
+
+// Config tunes searches
+type Config struct {
+	MaxPeers    int           // Agents queried per search, and at once across searches
+	PeerTimeout time.Duration // How long each agent has to answer
+	CacheTTL    time.Duration // How long results are reused (0 disables caching)
+}
+
+// Result is a ranked, deduplicated search result
+type Result struct {
+	Listings []Listing `json:"data"`
+	Queried  int       `json:"peers_queried"` // Remote agents asked
+	Answered int       `json:"peers_answered"`
+	Cached   bool      `json:"cached"`
+	// SearchedAt is when the listings were gathered, earlier than now for
+	// cached results
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+// Searcher searches the network for products
+type Searcher struct {
+	network    Network
+	catalog    Catalog
+	reputation Reputation
+	cfg        Config
+	logger     *slog.Logger
+	now        func() time.Time
+
+	// slots holds a token for each catalog query in flight
+	slots chan struct{}
+
+	mu    sync.Mutex
+	cache map[Query]cacheEntry
+}
+
+// cacheEntry is a search's listings before ranking
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+// NewSearcher creates a searcher that queries agents reachable from
+// network, includes the local catalog and scores listings by reputation
+func NewSearcher(network Network, catalog Catalog, reputation Reputation, cfg Config, logger *slog.Logger) *Searcher {
+	return &Searcher{
+		network:    network,
+		catalog:    catalog,
+		reputation: reputation,
+		cfg:        cfg,
+		logger:     logger,
+		now:        time.Now,
+		slots:      make(chan struct{}, max(cfg.MaxPeers, 1)),
+		cache:      make(map[Query]cacheEntry),
+	}
+}
+
+// Search returns the listings matching q across the network, ordered by
+// sortBy (empty means SortPrice)
+func (s *Searcher) Search(ctx context.Context, q Query, sortBy string) (Result, error) {
+	if sortBy == "" {
+		sortBy = SortPrice
+	}
+	if sortBy != SortPrice && sortBy != SortReputation {
+		return Result{}, fmt.Errorf("%w: sort must be %s or %s", ErrInvalidQuery, SortPrice, SortReputation)
+	}
+	q.Text = strings.ToLower(strings.TrimSpace(q.Text))
+	q.DataType = strings.ToLower(q.DataType)
+
+	result, ok := s.cached(q)
+	if !ok {
+		result = s.gather(ctx, q)
+		s.store(q, result)
+	}
+	result.Listings = append([]Listing(nil), result.Listings...)
+	rank(result.Listings, sortBy)
+	return result, nil
+}
+
+// cached returns an unexpired result for q
+func (s *Searcher) cached(q Query) (Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cache[q]
+	if !ok || !s.now().Before(entry.expires) {
+		delete(s.cache, q)
+		return Result{}, false
+	}
+	result := entry.result
+	result.Cached = true
+	return result, true
+}
+
+// store caches a result for the configured TTL, dropping expired entries.
+// When the cache is full the entry closest to expiry makes way.
+func (s *Searcher) store(q Query, result Result) {
+	if s.cfg.CacheTTL <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, entry := range s.cache {
+		if !now.Before(entry.expires) {
+			delete(s.cache, key)
+		}
+	}
+	if _, ok := s.cache[q]; !ok && len(s.cache) >= maxCachedSearches {
+		var oldest Query
+		first := true
+		for key, entry := range s.cache {
+			if first || entry.expires.Before(s.cache[oldest].expires) {
+				oldest, first = key, false
+			}
+		}
+		delete(s.cache, oldest)
+	}
+	s.cache[q] = cacheEntry{result: result, expires: now.Add(s.cfg.CacheTTL)}
+}
+
+// gather queries the local catalog and remote agents, deduplicating
+// listings by agent and product
+func (s *Searcher) gather(ctx context.Context, q Query) Result {
+	result := Result{SearchedAt: s.now()}
+	seen := make(map[string]bool)
+	add := func(l Listing) {
+		key := l.PeerID + "|" + l.ProductID
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		result.Listings = append(result.Listings, l)
+	}
+
+	self := s.network.Host().ID()
+	for _, l := range s.catalog.Listings(q) {
+		l.PeerID, l.Local = self.String(), true
+		l.Reputation = s.score(l)
+		add(l)
+	}
+
+	peers := s.candidates(ctx)
+	result.Queried = len(peers)
+	answers := make([][]Listing, len(peers))
+	var wg sync.WaitGroup
+	for i, info := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case s.slots <- struct{}{}:
+				defer func() { <-s.slots }()
+			case <-ctx.Done():
+				return
+			}
+			listings, err := s.query(ctx, info, q)
+			if err != nil {
+				s.logger.Debug("product query failed", "peer_id", info.ID.String(), "error", err)
+				return
+			}
+			answers[i] = listings
+		}()
+	}
+	wg.Wait()
+
+	for i, listings := range answers {
+		if listings == nil {
+			continue
+		}
+		result.Answered++
+		for _, l := range listings {
+			// Agents may only list their own products
+			if l.PeerID != peers[i].ID.String() || l.ProductID == "" || !q.Matches(l) {
+				continue
+			}
+			l.Local = false
+			l.Reputation = s.score(l)
+			add(l)
+		}
+	}
+	return result
+}
+
+// score returns the reputation of the listing's earner, or of the offering
+// peer if it lists no earner
+func (s *Searcher) score(l Listing) float64 {
+	if l.Earner != "" {
+		return s.reputation.ScoreOrPrior(l.Earner)
+	}
+	return s.reputation.ScoreOrPrior(l.PeerID)
+}
+
+// candidates returns up to MaxPeers agents to query: connected peers that
+// speak the catalog protocol, then agents advertised in the DHT
+func (s *Searcher) candidates(ctx context.Context) []peer.AddrInfo {
+	h := s.network.Host()
+	seen := map[peer.ID]bool{h.ID(): true}
+	var peers []peer.AddrInfo
+	for _, id := range h.Network().Peers() {
+		if len(peers) >= s.cfg.MaxPeers {
+			return peers
+		}
+		if supported, err := h.Peerstore().SupportsProtocols(id, ProtocolID); err != nil || len(supported) == 0 {
+			continue
+		}
+		seen[id] = true
+		peers = append(peers, peer.AddrInfo{ID: id})
+	}
+
+	findCtx, cancel := context.WithTimeout(ctx, s.cfg.PeerTimeout)
+	defer cancel()
+	providers, err := s.network.FindProviders(findCtx, Namespace, s.cfg.MaxPeers)
+	if err != nil {
+		s.logger.Debug("failed to find product providers", "error", err)
+	}
+	for _, info := range providers {
+		if len(peers) >= s.cfg.MaxPeers {
+			break
+		}
+		if !seen[info.ID] {
+			seen[info.ID] = true
+			peers = append(peers, info)
+		}
+	}
+	return peers
+}
+
+// query asks one agent for its matching listings
+func (s *Searcher) query(ctx context.Context, info peer.AddrInfo, q Query) ([]Listing, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.PeerTimeout)
+	defer cancel()
+
+	h := s.network.Host()
+	if len(info.Addrs) > 0 {
+		if err := h.Connect(ctx, info); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	stream, err := h.NewStream(ctx, info.ID, ProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(stream).Encode(q); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+	listings := []Listing{}
+	if err := json.NewDecoder(io.LimitReader(stream, MaxMessageSize)).Decode(&listings); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("failed to read listings: %w", err)
+	}
+	return listings, nil
+}
+
+// rank orders listings in place. Unparseable prices sort last.
+func rank(listings []Listing, sortBy string) {
+	price := func(l Listing) (decimal.Decimal, bool) {
+		d, err := decimal.NewFromString(l.Price)
+		return d, err == nil
+	}
+	byPrice := func(a, b Listing) int {
+		pa, oka := price(a)
+		pb, okb := price(b)
+		switch {
+		case oka && okb:
+			return pa.Cmp(pb)
+		case oka:
+			return -1
+		case okb:
+			return 1
+		}
+		return 0
+	}
+	byReputation := func(a, b Listing) int {
+		switch {
+		case a.Reputation > b.Reputation:
+			return -1
+		case a.Reputation < b.Reputation:
+			return 1
+		}
+		return 0
+	}
+
+	first, second := byPrice, byReputation
+	if sortBy == SortReputation {
+		first, second = byReputation, byPrice
+	}
+	sort.SliceStable(listings, func(i, j int) bool {
+		if c := first(listings[i], listings[j]); c != 0 {
+			return c < 0
+		}
+		if c := second(listings[i], listings[j]); c != 0 {
+			return c < 0
+		}
+		if listings[i].PeerID != listings[j].PeerID {
+			return listings[i].PeerID < listings[j].PeerID
+		}
+		return listings[i].ProductID < listings[j].ProductID
+	})
+}
+
+// Advertiser records in the DHT that an agent provides a namespace
+type Advertiser interface {
+	Advertise(ctx context.Context, namespace string) error
+}
+
+// Advertise announces that this agent lists products now and every
+// interval until ctx is done. DHT records expire, so the interval should
+// be well under their lifetime.
+func Advertise(ctx context.Context, a Advertiser, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.Advertise(ctx, Namespace); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to advertise product listings", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("failed to create host: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// staticCatalog filters a fixed set of listings
+type staticCatalog []Listing
+
+func (c staticCatalog) Listings(q Query) []Listing {
+	var out []Listing
+	for _, l := range c {
+		if q.Matches(l) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// testNetwork finds a fixed set of providers
+type testNetwork struct {
+	host      host.Host
+	providers []peer.AddrInfo
+	finds     int
+}
+
+func (n *testNetwork) Host() host.Host { return n.host }
+
+func (n *testNetwork) FindProviders(context.Context, string, int) ([]peer.AddrInfo, error) {
+	n.finds++
+	return n.providers, nil
+}
+
+// testReputation scores counterparties from a map, 0.5 when unknown
+type testReputation map[string]float64
+
+func (r testReputation) ScoreOrPrior(counterparty string) float64 {
+	if score, ok := r[counterparty]; ok {
+		return score
+	}
+	return 0.5
+}
+
+func TestSearchMergesRanksAndCachesListings(t *testing.T) {
+	cheap, pricey := newTestHost(t), newTestHost(t)
+	Serve(cheap, staticCatalog{
+		{ProductID: "did:pandacea:earner:weather", Name: "Weather", DataType: "timeseries", Keywords: []string{"climate"}, Price: "100", Earner: "0xcheap"},
+		{ProductID: "did:pandacea:earner:traffic", Name: "Traffic", DataType: "timeseries", Price: "5", Earner: "0xcheap"},
+	}, testLogger())
+	Serve(pricey, staticCatalog{
+		{ProductID: "did:pandacea:earner:weather", Name: "Weather", DataType: "timeseries", Keywords: []string{"climate"}, Price: "300"},
+	}, testLogger())
+
+	searching := newTestHost(t)
+	// pricey is both connected and advertised, so it must only be queried once
+	if err := searching.Connect(context.Background(), peer.AddrInfo{ID: pricey.ID(), Addrs: pricey.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	network := &testNetwork{
+		host: searching,
+		providers: []peer.AddrInfo{
+			{ID: cheap.ID(), Addrs: cheap.Addrs()},
+			{ID: pricey.ID(), Addrs: pricey.Addrs()},
+		},
+	}
+	local := staticCatalog{{ProductID: "did:pandacea:earner:climate-local", Name: "Local climate", DataType: "tabular", Price: "200"}}
+	searcher := NewSearcher(network, local, testReputation{"0xcheap": 0.1}, Config{MaxPeers: 10, PeerTimeout: 5 * time.Second, CacheTTL: time.Minute}, testLogger())
+	now := time.Now()
+	searcher.now = func() time.Time { return now }
+
+	result, err := searcher.Search(context.Background(), Query{Text: "Climate"}, "")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Queried != 2 || result.Answered != 2 || result.Cached {
+		t.Errorf("queried %d, answered %d, cached %v; want 2 fresh answers", result.Queried, result.Answered, result.Cached)
+	}
+	var order []string
+	for _, l := range result.Listings {
+		order = append(order, l.Price)
+	}
+	if len(order) != 3 || order[0] != "100" || order[1] != "200" || order[2] != "300" {
+		t.Fatalf("prices by price = %v, want [100 200 300]", order)
+	}
+	if result.Listings[0].PeerID != cheap.ID().String() || result.Listings[0].Reputation != 0.1 {
+		t.Errorf("cheapest listing = %+v, want cheap earner scored 0.1", result.Listings[0])
+	}
+	if !result.Listings[1].Local || result.Listings[1].Reputation != 0.5 {
+		t.Errorf("local listing = %+v, want it flagged local with the prior score", result.Listings[1])
+	}
+
+	result, err = searcher.Search(context.Background(), Query{Text: "climate"}, SortReputation)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !result.Cached || network.finds != 1 {
+		t.Errorf("cached = %v after %d provider lookups, want a cached result", result.Cached, network.finds)
+	}
+	if last := result.Listings[len(result.Listings)-1]; last.PeerID != cheap.ID().String() {
+		t.Errorf("least reputable listing = %s, want the cheap peer last", last.PeerID)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if result, _ = searcher.Search(context.Background(), Query{Text: "climate"}, ""); result.Cached {
+		t.Error("expired result served from the cache")
+	}
+
+	if _, err := searcher.Search(context.Background(), Query{}, "newest"); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unknown sort error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestRankSortsUnpricedListingsLast(t *testing.T) {
+	listings := []Listing{
+		{PeerID: "a", ProductID: "x"},
+		{PeerID: "b", ProductID: "x", Price: "20"},
+		{PeerID: "c", ProductID: "x", Price: "3"},
+	}
+	rank(listings, SortPrice)
+	if listings[0].PeerID != "c" || listings[1].PeerID != "b" || listings[2].PeerID != "a" {
+		t.Errorf("ranked = %+v, want c, b, then unpriced a", listings)
+	}
+}
+
+func TestStoreEvictsSoonestExpiryWhenFull(t *testing.T) {
+	searcher := NewSearcher(nil, staticCatalog{}, testReputation{}, Config{MaxPeers: 1, CacheTTL: time.Minute}, testLogger())
+	now := time.Now()
+	searcher.now = func() time.Time { return now }
+
+	for i := 0; i <= maxCachedSearches; i++ {
+		searcher.store(Query{Text: fmt.Sprint(i)}, Result{})
+		now = now.Add(time.Millisecond)
+	}
+	if len(searcher.cache) != maxCachedSearches {
+		t.Errorf("cache holds %d searches, want at most %d", len(searcher.cache), maxCachedSearches)
+	}
+	if _, ok := searcher.cached(Query{Text: "0"}); ok {
+		t.Error("search closest to expiry still cached after the cache filled")
+	}
+	if _, ok := searcher.cached(Query{Text: fmt.Sprint(maxCachedSearches)}); !ok {
+		t.Error("newest search not cached")
+	}
+}
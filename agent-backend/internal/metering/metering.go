@@ -0,0 +1,231 @@
+// Package metering measures what compute jobs consume and prices it at
+// configured rates. Each job's bill is booked against the lease it ran
+// under, so what a lease's jobs cost to run can be reconciled against what
+// the lease paid.
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+
+	"github.com/shopspring/decimal"
+)
+
+// chargeRetention is how long charges are kept
+const chargeRetention = 90 * 24 * time.Hour
+
+// gigabyte is the unit memory is billed in
+const gigabyte = 1 << 30
+
+// Job kinds
+const (
+	KindComputation = "computation"
+	KindTraining    = "training"
+)
+
+// Resources are what a job consumed
+type Resources struct {
+	CPUSeconds    float64 `json:"cpu_seconds"`     // CPU time across all cores
+	MemoryGBHours float64 `json:"memory_gb_hours"` // Memory held, in GiB, times the hours it was held
+	WallSeconds   float64 `json:"wall_seconds"`    // Time from start to finish
+}
+
+// Measure returns the resources of a job that used cpu and held
+// memoryBytes for wall
+func Measure(cpu time.Duration, memoryBytes int64, wall time.Duration) Resources {
+	return Resources{
+		CPUSeconds:    cpu.Seconds(),
+		MemoryGBHours: float64(memoryBytes) / gigabyte * wall.Hours(),
+		WallSeconds:   wall.Seconds(),
+	}
+}
+
+// add adds o to r
+func (r *Resources) add(o Resources) {
+	r.CPUSeconds += o.CPUSeconds
+	r.MemoryGBHours += o.MemoryGBHours
+	r.WallSeconds += o.WallSeconds
+}
+
+// Rates are the prices of each resource unit, in wei
+type Rates struct {
+	CPUSecond    decimal.Decimal
+	MemoryGBHour decimal.Decimal
+	WallSecond   decimal.Decimal
+}
+
+// ParseRates parses rates given as decimal wei amounts. Empty rates are
+// zero.
+func ParseRates(cpuSecond, memoryGBHour, wallSecond string) (Rates, error) {
+	var rates Rates
+	for _, rate := range []struct {
+		name  string
+		value string
+		dest  *decimal.Decimal
+	}{
+		{"cpu second", cpuSecond, &rates.CPUSecond},
+		{"memory GB-hour", memoryGBHour, &rates.MemoryGBHour},
+		{"wall second", wallSecond, &rates.WallSecond},
+	} {
+		if rate.value == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(rate.value)
+		if err != nil || d.IsNegative() {
+			return Rates{}, fmt.Errorf("%s rate %q is not a non-negative amount of wei", rate.name, rate.value)
+		}
+		*rate.dest = d
+	}
+	return rates, nil
+}
+
+// Bill is what a job consumed and what it cost
+type Bill struct {
+	Resources
+	Cost string `json:"cost"` // In wei, rounded down
+}
+
+// Price bills res at r
+func (r Rates) Price(res Resources) Bill {
+	cost := r.CPUSecond.Mul(decimal.NewFromFloat(res.CPUSeconds)).
+		Add(r.MemoryGBHour.Mul(decimal.NewFromFloat(res.MemoryGBHours))).
+		Add(r.WallSecond.Mul(decimal.NewFromFloat(res.WallSeconds)))
+	return Bill{Resources: res, Cost: cost.Floor().String()}
+}
+
+// Charge is a job's bill booked against a lease
+type Charge struct {
+	JobID      string    `json:"job_id"`
+	Kind       string    `json:"kind"` // computation or training
+	RecordedAt time.Time `json:"recorded_at"`
+	Bill
+}
+
+// Reconciliation compares what a lease's jobs cost to run with what the
+// lease paid. Amounts are in wei.
+type Reconciliation struct {
+	LeaseID   string    `json:"lease_id"`
+	Price     string    `json:"price,omitempty"`  // What the lease paid; empty if the agent has not seen it
+	Metered   string    `json:"metered"`          // Total cost of the lease's jobs
+	Margin    string    `json:"margin,omitempty"` // Price less the metered cost; negative when the jobs cost more than the lease paid
+	Resources Resources `json:"resources"`        // Total resources of the lease's jobs
+	Charges   []Charge  `json:"charges"`          // Oldest first
+}
+
+// Ledger books job bills by lease. It is safe for concurrent use.
+type Ledger struct {
+	mu      sync.Mutex
+	path    string
+	charges map[string][]Charge
+	now     func() time.Time
+}
+
+// NewLedger creates a ledger that persists charges to path unless it is
+// empty, restoring any already saved there
+func NewLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, charges: make(map[string][]Charge), now: time.Now}
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metering ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.charges); err != nil {
+		return nil, fmt.Errorf("failed to parse metering ledger: %w", err)
+	}
+	if l.charges == nil {
+		l.charges = make(map[string][]Charge)
+	}
+	return l, nil
+}
+
+// Record books a job's bill against leaseID
+func (l *Ledger) Record(leaseID, jobID, kind string, bill Bill) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	leaseID = normalize(leaseID)
+	l.charges[leaseID] = append(l.charges[leaseID], Charge{
+		JobID:      jobID,
+		Kind:       kind,
+		RecordedAt: l.now().UTC(),
+		Bill:       bill,
+	})
+	return l.save()
+}
+
+// Reconcile totals the charges against leaseID and, if price is known,
+// compares them with it
+func (l *Ledger) Reconcile(leaseID string, price *decimal.Decimal) Reconciliation {
+	l.mu.Lock()
+	charges := append([]Charge{}, l.charges[normalize(leaseID)]...)
+	l.mu.Unlock()
+
+	sort.SliceStable(charges, func(i, j int) bool { return charges[i].RecordedAt.Before(charges[j].RecordedAt) })
+	rec := Reconciliation{LeaseID: leaseID, Charges: charges}
+	metered := decimal.Zero
+	for _, c := range charges {
+		rec.Resources.add(c.Resources)
+		if cost, err := decimal.NewFromString(c.Cost); err == nil {
+			metered = metered.Add(cost)
+		}
+	}
+	rec.Metered = metered.String()
+	if price != nil {
+		rec.Price = price.String()
+		rec.Margin = price.Sub(metered).String()
+	}
+	return rec
+}
+
+// save drops expired charges and writes the rest. Caller must hold mu.
+func (l *Ledger) save() error {
+	cutoff := l.now().Add(-chargeRetention)
+	for leaseID, charges := range l.charges {
+		kept := charges[:0]
+		for _, c := range charges {
+			if !c.RecordedAt.Before(cutoff) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == 0 {
+			delete(l.charges, leaseID)
+		} else {
+			l.charges[leaseID] = kept
+		}
+	}
+
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.charges)
+	if err != nil {
+		return fmt.Errorf("failed to encode metering ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create metering ledger directory: %w", err)
+	}
+	if err := fsutil.WriteFile(l.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metering ledger: %w", err)
+	}
+	return nil
+}
+
+// normalize makes lease IDs comparable however they were written
+func normalize(leaseID string) string {
+	return strings.TrimPrefix(strings.ToLower(leaseID), "0x")
+}
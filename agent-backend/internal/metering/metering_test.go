@@ -0,0 +1,58 @@
+package metering
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPrice(t *testing.T) {
+	rates, err := ParseRates("1000", "3600000", "")
+	if err != nil {
+		t.Fatalf("ParseRates: %v", err)
+	}
+	// 2 CPU seconds and 2 GiB held for half an hour
+	res := Measure(2*time.Second, 2*gigabyte, 30*time.Minute)
+	if res.MemoryGBHours != 1 || res.WallSeconds != 1800 {
+		t.Errorf("resources = %+v", res)
+	}
+	if bill := rates.Price(res); bill.Cost != "3602000" {
+		t.Errorf("cost = %s, want 3602000", bill.Cost)
+	}
+
+	for _, bad := range [][3]string{{"-1", "", ""}, {"", "lots", ""}} {
+		if _, err := ParseRates(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("ParseRates(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metering.json")
+	ledger, err := NewLedger(path)
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	if err := ledger.Record("0xAB", "comp-1", KindComputation, Bill{Resources: Resources{CPUSeconds: 2}, Cost: "600"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := ledger.Record("ab", "job-1", KindTraining, Bill{Resources: Resources{CPUSeconds: 3}, Cost: "500"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// Charges survive a restart
+	ledger, err = NewLedger(path)
+	if err != nil {
+		t.Fatalf("NewLedger: %v", err)
+	}
+	price := decimal.NewFromInt(1000)
+	rec := ledger.Reconcile("0xab", &price)
+	if len(rec.Charges) != 2 || rec.Metered != "1100" || rec.Margin != "-100" || rec.Resources.CPUSeconds != 5 {
+		t.Errorf("reconciliation = %+v", rec)
+	}
+	if rec := ledger.Reconcile("0xcd", nil); rec.Metered != "0" || rec.Price != "" || len(rec.Charges) != 0 {
+		t.Errorf("unknown lease = %+v", rec)
+	}
+}
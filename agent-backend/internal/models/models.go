@@ -0,0 +1,275 @@
+// Package models keeps a registry of the models completed training jobs
+// produced. Each model is a version of the model named after the dataset
+// and task it was trained for, recorded with its metrics, the epsilon it
+// spent, where its data came from and the hash of its artifact. Operators
+// promote versions to staging or production and tag them freely.
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Stages a version can be promoted to. A model has at most one version in
+// staging and one in production; promoting another archives it.
+const (
+	StageNone       = ""
+	StageStaging    = "staging"
+	StageProduction = "production"
+	StageArchived   = "archived" // Replaced in staging or production, or retired by hand
+)
+
+// ErrNotFound is returned for model versions that are not registered
+var ErrNotFound = errors.New("model not found")
+
+// ErrInvalidRequest is returned for promotions to unknown stages and
+// invalid tags
+var ErrInvalidRequest = errors.New("invalid model request")
+
+// Lineage is where a model's training data came from
+type Lineage struct {
+	Dataset      string   `json:"dataset"`
+	LeaseID      string   `json:"lease_id,omitempty"`     // Lease the training job ran under
+	Participants []string `json:"participants,omitempty"` // Agents that trained rounds of a federated model
+	Rounds       int      `json:"rounds,omitempty"`       // Federation rounds the model was averaged over
+	Samples      int      `json:"samples,omitempty"`      // Training samples, as the artifact reports them
+	Resumes      int      `json:"resumes,omitempty"`      // Times training was resumed from a checkpoint
+}
+
+// Model is a registered version of a model
+type Model struct {
+	ID        string             `json:"id"`      // Training job that produced it
+	Name      string             `json:"name"`    // <dataset>/<task>
+	Version   int                `json:"version"` // 1 for a name's first model, counting up
+	Task      string             `json:"task"`
+	Metrics   map[string]float64 `json:"metrics,omitempty"`
+	Epsilon   float64            `json:"epsilon"`                 // Privacy spent, 0 without differential privacy
+	Lineage   Lineage            `json:"lineage"`                 // Where the training data came from
+	SHA256    string             `json:"sha256"`                  // Hex SHA-256 of the artifact
+	CID       string             `json:"cid,omitempty"`           // IPFS CID the artifact is published under
+	Artifact  string             `json:"artifact_path,omitempty"` // Artifact on the agent's disk
+	Stage     string             `json:"stage,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// copy returns a copy of m that shares no slices or maps with it
+func (m *Model) copy() Model {
+	c := *m
+	c.Metrics = maps.Clone(m.Metrics)
+	c.Lineage.Participants = slices.Clone(m.Lineage.Participants)
+	c.Tags = slices.Clone(m.Tags)
+	return c
+}
+
+// Name returns the name of the model trained for a task on a dataset
+func Name(dataset, task string) string {
+	return dataset + "/" + task
+}
+
+// Query filters the models returned by List. Empty fields match any model.
+type Query struct {
+	Name    string
+	Dataset string
+	Stage   string
+	Tag     string
+}
+
+// Registry keeps registered models. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	path   string
+	models map[string]*Model // By ID
+	now    func() time.Time
+}
+
+// NewRegistry creates a registry that persists models to path unless it
+// is empty, restoring any already saved there
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path, models: make(map[string]*Model), now: time.Now}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.models); err != nil {
+		return nil, fmt.Errorf("failed to parse model registry: %w", err)
+	}
+	if r.models == nil {
+		r.models = make(map[string]*Model)
+	}
+	return r, nil
+}
+
+// Register adds a completed job's model as the next version of its name
+// and returns it. A job registered before keeps its version.
+func (r *Registry) Register(model Model) (Model, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.models[model.ID]; ok {
+		return existing.copy(), nil
+	}
+	model.Version = 1
+	for _, m := range r.models {
+		if m.Name == model.Name && m.Version >= model.Version {
+			model.Version = m.Version + 1
+		}
+	}
+	now := r.now().UTC()
+	model.Stage = StageNone
+	model.CreatedAt, model.UpdatedAt = now, now
+	r.models[model.ID] = &model
+	return model.copy(), r.save()
+}
+
+// Get returns a registered model
+func (r *Registry) Get(id string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	model, ok := r.models[id]
+	if !ok {
+		return Model{}, false
+	}
+	return model.copy(), true
+}
+
+// List returns the models matching q, newest first
+func (r *Registry) List(q Query) []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := []Model{}
+	for _, model := range r.models {
+		if q.Name != "" && model.Name != q.Name {
+			continue
+		}
+		if q.Dataset != "" && model.Lineage.Dataset != q.Dataset {
+			continue
+		}
+		if q.Stage != "" && model.Stage != q.Stage {
+			continue
+		}
+		if q.Tag != "" && !slices.Contains(model.Tags, q.Tag) {
+			continue
+		}
+		models = append(models, model.copy())
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].CreatedAt.Equal(models[j].CreatedAt) {
+			return models[i].Version > models[j].Version
+		}
+		return models[i].CreatedAt.After(models[j].CreatedAt)
+	})
+	return models
+}
+
+// Promote moves a model to stage. The version of the same name that held
+// staging or production before is archived. Promoting to StageNone takes
+// a model out of its stage.
+func (r *Registry) Promote(id, stage string) (Model, error) {
+	switch stage {
+	case StageNone, StageStaging, StageProduction, StageArchived:
+	default:
+		return Model{}, fmt.Errorf("%w: unknown stage %q", ErrInvalidRequest, stage)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	model, ok := r.models[id]
+	if !ok {
+		return Model{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	now := r.now().UTC()
+	if stage == StageStaging || stage == StageProduction {
+		for _, m := range r.models {
+			if m != model && m.Name == model.Name && m.Stage == stage {
+				m.Stage = StageArchived
+				m.UpdatedAt = now
+			}
+		}
+	}
+	model.Stage = stage
+	model.UpdatedAt = now
+	return model.copy(), r.save()
+}
+
+// Tag adds and removes a model's tags
+func (r *Registry) Tag(id string, add, remove []string) (Model, error) {
+	for _, tag := range add {
+		if tag == "" || len(tag) > 64 {
+			return Model{}, fmt.Errorf("%w: tags must be 1-64 characters", ErrInvalidRequest)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	model, ok := r.models[id]
+	if !ok {
+		return Model{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	model.Tags = slices.DeleteFunc(model.Tags, func(tag string) bool { return slices.Contains(remove, tag) })
+	for _, tag := range add {
+		if !slices.Contains(model.Tags, tag) {
+			model.Tags = append(model.Tags, tag)
+		}
+	}
+	sort.Strings(model.Tags)
+	model.UpdatedAt = r.now().UTC()
+	return model.copy(), r.save()
+}
+
+// Staged reports whether a model is in staging or production, which keeps
+// its artifact from retention
+func (r *Registry) Staged(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	model, ok := r.models[id]
+	return ok && (model.Stage == StageStaging || model.Stage == StageProduction)
+}
+
+// Remove forgets a model, such as one whose job retention dropped
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.models[id]; !ok {
+		return nil
+	}
+	delete(r.models, id)
+	return r.save()
+}
+
+// save writes the models to disk. Caller must hold r.mu.
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.models)
+	if err != nil {
+		return fmt.Errorf("failed to encode model registry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create model registry directory: %w", err)
+	}
+	if err := fsutil.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write model registry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	r, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	register := func(id, dataset string) Model {
+		t.Helper()
+		now = now.Add(time.Minute)
+		m, err := r.Register(Model{ID: id, Name: Name(dataset, "classify"), Task: "classify", Lineage: Lineage{Dataset: dataset}})
+		if err != nil {
+			t.Fatalf("Register(%s): %v", id, err)
+		}
+		return m
+	}
+	first := register("job-1", "mnist")
+	second := register("job-2", "mnist")
+	other := register("job-3", "cifar")
+	if first.Version != 1 || second.Version != 2 || other.Version != 1 {
+		t.Errorf("versions = %d, %d, %d, want 1, 2 and 1 for another name", first.Version, second.Version, other.Version)
+	}
+	if again := register("job-1", "mnist"); again.Version != 1 {
+		t.Errorf("registering a job again gave version %d, want its original 1", again.Version)
+	}
+
+	// Promoting a version archives the one it replaces
+	if _, err := r.Promote("job-1", StageProduction); err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	if _, err := r.Promote("job-2", StageProduction); err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	if m, _ := r.Get("job-1"); m.Stage != StageArchived {
+		t.Errorf("replaced version stage = %q, want archived", m.Stage)
+	}
+	if !r.Staged("job-2") || r.Staged("job-1") {
+		t.Error("only the production version is staged")
+	}
+	if _, err := r.Promote("job-2", "live"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Promote() to an unknown stage error = %v", err)
+	}
+	if _, err := r.Promote("job-9", StageStaging); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Promote() of an unknown model error = %v", err)
+	}
+
+	if _, err := r.Tag("job-3", []string{"candidate", "baseline"}, nil); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	tagged, err := r.Tag("job-3", nil, []string{"candidate"})
+	if err != nil || !slices.Equal(tagged.Tags, []string{"baseline"}) {
+		t.Errorf("Tag() = %v, %v, want only baseline", tagged.Tags, err)
+	}
+
+	if got := r.List(Query{Name: "mnist/classify"}); len(got) != 2 || got[0].ID != "job-2" {
+		t.Errorf("List(name) = %+v, want job-2 then job-1", got)
+	}
+	if got := r.List(Query{Tag: "baseline"}); len(got) != 1 || got[0].ID != "job-3" {
+		t.Errorf("List(tag) = %+v, want job-3", got)
+	}
+
+	// Models survive a restart
+	if err := r.Remove("job-1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	restored, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if got := restored.List(Query{}); len(got) != 2 {
+		t.Errorf("restored %d models, want 2", len(got))
+	}
+	if m, ok := restored.Get("job-2"); !ok || m.Stage != StageProduction {
+		t.Errorf("restored job-2 = %+v, want it in production", m)
+	}
+}
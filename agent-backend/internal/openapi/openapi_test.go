@@ -0,0 +1,65 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testNode struct {
+	Name     string            `json:"name"`
+	Parent   *testNode         `json:"parent,omitempty"`
+	Children []testNode        `json:"children"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created"`
+	Count    *uint64           `json:"count"`
+	Payload  any               `json:"payload,omitempty"`
+	Skipped  string            `json:"-"`
+	internal string
+	testMeta
+}
+
+type testMeta struct {
+	Version int `json:"version"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	gen := NewGenerator()
+	ref := gen.SchemaFor(testNode{})
+	if ref.Ref != "#/components/schemas/testNode" {
+		t.Fatalf("SchemaFor() = %+v, want a component reference", ref)
+	}
+
+	schema := gen.Schemas()["testNode"]
+	if schema == nil {
+		t.Fatal("testNode was not added to the component schemas")
+	}
+	wantRequired := []string{"name", "children", "created", "count", "version"}
+	if !reflect.DeepEqual(schema.Required, wantRequired) {
+		t.Errorf("Required = %v, want %v", schema.Required, wantRequired)
+	}
+	if _, ok := schema.Properties["Skipped"]; ok {
+		t.Error("field tagged json:\"-\" is documented")
+	}
+	if _, ok := schema.Properties["internal"]; ok {
+		t.Error("unexported field is documented")
+	}
+
+	tests := map[string]Schema{
+		"parent":  {Ref: "#/components/schemas/testNode"},
+		"created": {Type: "string", Format: "date-time"},
+		"count":   {Type: "integer", Format: "int64", Nullable: true},
+		"version": {Type: "integer", Format: "int32"},
+	}
+	for name, want := range tests {
+		if got := schema.Properties[name]; got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("Properties[%q] = %+v, want %+v", name, got, want)
+		}
+	}
+	if items := schema.Properties["children"].Items; items == nil || items.Ref != ref.Ref {
+		t.Errorf("children items = %+v, want a reference to testNode", items)
+	}
+	if labels := schema.Properties["labels"]; labels.AdditionalProperties == nil || labels.AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v, want a string map", labels)
+	}
+}
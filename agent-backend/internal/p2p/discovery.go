@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// namespaceCID is the DHT key peers providing namespace are recorded under
+func namespaceCID(namespace string) (cid.Cid, error) {
+	hash, err := multihash.Sum([]byte(namespace), multihash.SHA2_256, -1)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to hash namespace: %w", err)
+	}
+	return cid.NewCidV1(cid.Raw, hash), nil
+}
+
+// Advertise records in the DHT that this node provides namespace. Records
+// expire, so callers re-advertise periodically.
+func (n *Node) Advertise(ctx context.Context, namespace string) error {
+	key, err := namespaceCID(namespace)
+	if err != nil {
+		return err
+	}
+	if err := n.dht.Provide(ctx, key, true); err != nil {
+		return fmt.Errorf("failed to advertise %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// FindProviders returns up to limit peers, other than this node, that
+// advertised namespace in the DHT
+func (n *Node) FindProviders(ctx context.Context, namespace string, limit int) ([]peer.AddrInfo, error) {
+	key, err := namespaceCID(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var providers []peer.AddrInfo
+	for info := range n.dht.FindProvidersAsync(ctx, key, limit+1) {
+		if info.ID == n.host.ID() {
+			continue
+		}
+		providers = append(providers, info)
+		if len(providers) == limit {
+			break
+		}
+	}
+	return providers, nil
+}
+
+// PeerScore returns a peer's reputation score, 0 for peers without
+// offenses or without a gater
+func (n *Node) PeerScore(id peer.ID) float64 {
+	if n.gater == nil {
+		return 0
+	}
+	return n.gater.Score(id)
+}
@@ -0,0 +1,417 @@
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Offense is peer misbehaviour that lowers its reputation
+type Offense string
+
+// Offenses reported by the node and the protocols it serves
+const (
+	OffenseInvalidMessage    Offense = "invalid_message"
+	OffenseProtocolViolation Offense = "protocol_violation"
+)
+
+// offensePenalty is how far each offense lowers a peer's score. A peer
+// starts at 0 and is refused once its score falls below the gater's
+// minimum, so flaky networks cost far less than deliberate abuse.
+var offensePenalty = map[Offense]float64{
+	OffenseInvalidMessage:    20,
+	OffenseProtocolViolation: 50,
+}
+
+// Peer lists
+const (
+	ListAllow = "allow"
+	ListDeny  = "deny"
+)
+
+// Refusal reasons, used as metric labels
+const (
+	refusedDenied        = "denied"
+	refusedLowReputation = "low_reputation"
+)
+
+// ErrInvalidPeer is returned for peer IDs that do not parse
+var ErrInvalidPeer = errors.New("invalid peer ID")
+
+var (
+	peerOffenses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_p2p_peer_offenses_total",
+		Help: "Peer offenses reported to the connection gater, by offense",
+	}, []string{"offense"})
+	connectionsRefused = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_p2p_connections_refused_total",
+		Help: "Connections the gater refused, by reason",
+	}, []string{"reason"})
+)
+
+// GaterConfig configures peer scoring and the allow and deny lists
+type GaterConfig struct {
+	MinScore float64       // Peers scoring below this are refused
+	HalfLife time.Duration // Time for a penalty to halve
+	Allow    []string      // Peers that are never refused
+	Deny     []string      // Peers that are always refused
+	Path     string        // Where lists and scores persist; empty keeps them in memory only
+}
+
+// PeerScore is a peer's reputation
+type PeerScore struct {
+	Score     float64         `json:"score"`
+	Offenses  map[Offense]int `json:"offenses,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// PeerEntry is a peer on the allow or deny list
+type PeerEntry struct {
+	Reason  string    `json:"reason,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// PeerState describes one peer the gater knows about
+type PeerState struct {
+	PeerID   string          `json:"peer_id"`
+	List     string          `json:"list,omitempty"`
+	Reason   string          `json:"reason,omitempty"`
+	Score    float64         `json:"score"`
+	Offenses map[Offense]int `json:"offenses,omitempty"`
+	Allowed  bool            `json:"allowed"`
+}
+
+// gaterState is the on-disk format
+type gaterState struct {
+	Allow  map[peer.ID]*PeerEntry `json:"allow"`
+	Deny   map[peer.ID]*PeerEntry `json:"deny"`
+	Scores map[peer.ID]*PeerScore `json:"scores"`
+}
+
+// Gater refuses connections from denied peers and from peers whose score
+// has fallen below the minimum. Scores recover toward 0 with the
+// configured half-life, so a refused peer is let back in once its
+// offenses are old enough. Allowed peers are never refused.
+type Gater struct {
+	mu       sync.Mutex
+	minScore float64
+	halfLife time.Duration
+	path     string
+	state    gaterState
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewGater creates a gater, restoring lists and scores from cfg.Path and
+// adding the peers listed in cfg
+func NewGater(cfg GaterConfig, logger *slog.Logger) (*Gater, error) {
+	if cfg.HalfLife <= 0 {
+		return nil, fmt.Errorf("peer score half-life must be positive")
+	}
+
+	g := &Gater{
+		minScore: cfg.MinScore,
+		halfLife: cfg.HalfLife,
+		path:     cfg.Path,
+		state: gaterState{
+			Allow:  make(map[peer.ID]*PeerEntry),
+			Deny:   make(map[peer.ID]*PeerEntry),
+			Scores: make(map[peer.ID]*PeerScore),
+		},
+		logger: logger,
+		now:    time.Now,
+	}
+
+	if cfg.Path != "" {
+		data, err := os.ReadFile(cfg.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read peer state: %w", err)
+		default:
+			if err := json.Unmarshal(data, &g.state); err != nil {
+				return nil, fmt.Errorf("failed to parse peer state: %w", err)
+			}
+		}
+		if g.state.Allow == nil {
+			g.state.Allow = make(map[peer.ID]*PeerEntry)
+		}
+		if g.state.Deny == nil {
+			g.state.Deny = make(map[peer.ID]*PeerEntry)
+		}
+		if g.state.Scores == nil {
+			g.state.Scores = make(map[peer.ID]*PeerScore)
+		}
+	}
+
+	for list, ids := range map[string][]string{ListAllow: cfg.Allow, ListDeny: cfg.Deny} {
+		for _, s := range ids {
+			id, err := peer.Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w in %s list: %s", ErrInvalidPeer, list, s)
+			}
+			g.putOnList(list, id, &PeerEntry{Reason: "config", AddedAt: g.now()})
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.save(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// putOnList puts id on list, taking it off the other one. Caller must hold g.mu
+// or own g exclusively.
+func (g *Gater) putOnList(list string, id peer.ID, entry *PeerEntry) {
+	if list == ListAllow {
+		delete(g.state.Deny, id)
+		g.state.Allow[id] = entry
+	} else {
+		delete(g.state.Allow, id)
+		g.state.Deny[id] = entry
+	}
+}
+
+// Report lowers id's score for offense and reports whether the peer is
+// still allowed to connect
+func (g *Gater) Report(id peer.ID, offense Offense) bool {
+	penalty, ok := offensePenalty[offense]
+	if !ok {
+		return g.Allowed(id)
+	}
+	peerOffenses.WithLabelValues(string(offense)).Inc()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	score := g.decayed(id, now)
+	score.Score -= penalty
+	score.Offenses[offense]++
+	g.state.Scores[id] = score
+
+	allowed := g.allowed(id, now)
+	if !allowed {
+		g.logger.Warn("peer reputation fell below the minimum", "peer_id", id.String(), "score", score.Score, "offense", offense)
+	}
+	if err := g.save(); err != nil {
+		g.logger.Error("failed to save peer state", "error", err)
+	}
+	return allowed
+}
+
+// Allowed reports whether connections with id are permitted
+func (g *Gater) Allowed(id peer.ID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.allowed(id, g.now())
+}
+
+// allowed applies the lists and the score. Caller must hold g.mu.
+func (g *Gater) allowed(id peer.ID, now time.Time) bool {
+	return g.refusal(id, now) == ""
+}
+
+// refusal returns why id is refused, or "" if it is not. Caller must hold g.mu.
+func (g *Gater) refusal(id peer.ID, now time.Time) string {
+	if _, ok := g.state.Allow[id]; ok {
+		return ""
+	}
+	if _, ok := g.state.Deny[id]; ok {
+		return refusedDenied
+	}
+	if _, ok := g.state.Scores[id]; ok && g.decayed(id, now).Score < g.minScore {
+		return refusedLowReputation
+	}
+	return ""
+}
+
+// decayed returns a copy of id's score recovered toward 0 as of now. Caller
+// must hold g.mu.
+func (g *Gater) decayed(id peer.ID, now time.Time) *PeerScore {
+	score := &PeerScore{Offenses: make(map[Offense]int), UpdatedAt: now}
+	existing, ok := g.state.Scores[id]
+	if !ok {
+		return score
+	}
+	score.Score = existing.Score
+	for offense, n := range existing.Offenses {
+		score.Offenses[offense] = n
+	}
+	if elapsed := now.Sub(existing.UpdatedAt); elapsed > 0 {
+		score.Score *= math.Pow(0.5, float64(elapsed)/float64(g.halfLife))
+	}
+	return score
+}
+
+// Score returns id's current score, recovered toward 0 since its last offense
+func (g *Gater) Score(id peer.ID) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.decayed(id, g.now()).Score
+}
+
+// SetList puts a peer on the allow or deny list, taking it off the other
+func (g *Gater) SetList(list, peerID, reason string) (PeerState, error) {
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return PeerState{}, fmt.Errorf("%w: %v", ErrInvalidPeer, err)
+	}
+	if list != ListAllow && list != ListDeny {
+		return PeerState{}, fmt.Errorf("unknown peer list %q", list)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.putOnList(list, id, &PeerEntry{Reason: reason, AddedAt: g.now()})
+	if err := g.save(); err != nil {
+		return PeerState{}, err
+	}
+	return g.peerState(id, g.now()), nil
+}
+
+// Forget takes a peer off both lists and clears its score, reporting
+// whether the gater knew about it
+func (g *Gater) Forget(peerID string) (bool, error) {
+	id, err := peer.Decode(peerID)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidPeer, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, allowed := g.state.Allow[id]
+	_, denied := g.state.Deny[id]
+	_, scored := g.state.Scores[id]
+	if !allowed && !denied && !scored {
+		return false, nil
+	}
+	delete(g.state.Allow, id)
+	delete(g.state.Deny, id)
+	delete(g.state.Scores, id)
+	return true, g.save()
+}
+
+// Peers lists every peer on a list or with a score, sorted by peer ID
+func (g *Gater) Peers() []PeerState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+
+	ids := make(map[peer.ID]bool)
+	for id := range g.state.Allow {
+		ids[id] = true
+	}
+	for id := range g.state.Deny {
+		ids[id] = true
+	}
+	for id := range g.state.Scores {
+		ids[id] = true
+	}
+	peers := make([]PeerState, 0, len(ids))
+	for id := range ids {
+		peers = append(peers, g.peerState(id, now))
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].PeerID < peers[j].PeerID })
+	return peers
+}
+
+// peerState describes id as of now. Caller must hold g.mu.
+func (g *Gater) peerState(id peer.ID, now time.Time) PeerState {
+	ps := PeerState{PeerID: id.String(), Allowed: g.allowed(id, now)}
+	if entry, ok := g.state.Allow[id]; ok {
+		ps.List, ps.Reason = ListAllow, entry.Reason
+	} else if entry, ok := g.state.Deny[id]; ok {
+		ps.List, ps.Reason = ListDeny, entry.Reason
+	}
+	if _, ok := g.state.Scores[id]; ok {
+		score := g.decayed(id, now)
+		ps.Score, ps.Offenses = score.Score, score.Offenses
+	}
+	return ps
+}
+
+// gate checks id for a connection attempt, counting and logging refusals
+func (g *Gater) gate(id peer.ID) bool {
+	g.mu.Lock()
+	reason := g.refusal(id, g.now())
+	g.mu.Unlock()
+	if reason == "" {
+		return true
+	}
+	connectionsRefused.WithLabelValues(reason).Inc()
+	g.logger.Debug("refused peer connection", "peer_id", id.String(), "reason", reason)
+	return false
+}
+
+// InterceptPeerDial implements connmgr.ConnectionGater
+func (g *Gater) InterceptPeerDial(id peer.ID) bool {
+	return g.gate(id)
+}
+
+// InterceptAddrDial implements connmgr.ConnectionGater. The peer was
+// already checked by InterceptPeerDial.
+func (g *Gater) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool {
+	return true
+}
+
+// InterceptAccept implements connmgr.ConnectionGater. Inbound peers are
+// not known until the security handshake, so they are checked by
+// InterceptSecured.
+func (g *Gater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured implements connmgr.ConnectionGater
+func (g *Gater) InterceptSecured(_ network.Direction, id peer.ID, _ network.ConnMultiaddrs) bool {
+	return g.gate(id)
+}
+
+// InterceptUpgraded implements connmgr.ConnectionGater
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// save prunes scores that have recovered and writes the state atomically.
+// Caller must hold g.mu.
+func (g *Gater) save() error {
+	now := g.now()
+	for id := range g.state.Scores {
+		if math.Abs(g.decayed(id, now).Score) < 0.01 {
+			delete(g.state.Scores, id)
+		}
+	}
+
+	if g.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(g.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode peer state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), 0700); err != nil {
+		return fmt.Errorf("failed to create peer state directory: %w", err)
+	}
+	if err := fsutil.WriteFile(g.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write peer state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package p2p
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestHost(t *testing.T, opts ...libp2p.Option) host.Host {
+	t.Helper()
+	h, err := libp2p.New(append([]libp2p.Option{libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create host: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestGaterRefusesLowReputationPeersUntilTheyRecover(t *testing.T) {
+	g, err := NewGater(GaterConfig{MinScore: -50, HalfLife: time.Hour}, testLogger())
+	if err != nil {
+		t.Fatalf("NewGater: %v", err)
+	}
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	id := peer.ID("peer-a")
+
+	if !g.Report(id, OffenseInvalidMessage) || !g.Report(id, OffenseInvalidMessage) {
+		t.Fatal("peer refused after two invalid messages, want allowed at -40")
+	}
+	if g.Report(id, OffenseProtocolViolation) {
+		t.Fatal("peer allowed at -90, want refused")
+	}
+	if g.InterceptPeerDial(id) || g.InterceptSecured(0, id, nil) {
+		t.Error("gater let a refused peer connect")
+	}
+
+	// One half-life later the score is -45
+	now = now.Add(time.Hour)
+	if !g.Allowed(id) {
+		t.Error("peer still refused after its score recovered")
+	}
+	peers := g.Peers()
+	if len(peers) != 1 || peers[0].Offenses[OffenseInvalidMessage] != 2 || peers[0].Score > -44 || peers[0].Score < -46 {
+		t.Errorf("Peers() = %+v", peers)
+	}
+}
+
+func TestGaterListsPersistAndOverrideScores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	denied, trusted := newTestHost(t), newTestHost(t)
+
+	g, err := NewGater(GaterConfig{MinScore: -10, HalfLife: time.Hour, Deny: []string{denied.ID().String()}, Path: path}, testLogger())
+	if err != nil {
+		t.Fatalf("NewGater: %v", err)
+	}
+	if _, err := g.SetList(ListAllow, trusted.ID().String(), "operator"); err != nil {
+		t.Fatalf("SetList: %v", err)
+	}
+	if _, err := g.SetList(ListDeny, "not-a-peer", ""); err == nil {
+		t.Error("SetList accepted an invalid peer ID")
+	}
+	g.Report(trusted.ID(), OffenseProtocolViolation)
+
+	restored, err := NewGater(GaterConfig{MinScore: -10, HalfLife: time.Hour, Path: path}, testLogger())
+	if err != nil {
+		t.Fatalf("NewGater after restart: %v", err)
+	}
+	if restored.Allowed(denied.ID()) {
+		t.Error("denied peer allowed after restart")
+	}
+	if !restored.Allowed(trusted.ID()) {
+		t.Error("allowed peer refused for its score")
+	}
+
+	// The gated host neither dials nor accepts the denied peer
+	gated := newTestHost(t, libp2p.ConnectionGater(restored))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := gated.Connect(ctx, peer.AddrInfo{ID: denied.ID(), Addrs: denied.Addrs()}); err == nil {
+		t.Error("gated host dialled a denied peer")
+	}
+	if err := denied.Connect(ctx, peer.AddrInfo{ID: gated.ID(), Addrs: gated.Addrs()}); err == nil && len(gated.Network().ConnsToPeer(denied.ID())) > 0 {
+		t.Error("gated host accepted a denied peer")
+	}
+	if err := trusted.Connect(ctx, peer.AddrInfo{ID: gated.ID(), Addrs: gated.Addrs()}); err != nil {
+		t.Errorf("allowed peer failed to connect: %v", err)
+	}
+
+	if ok, err := restored.Forget(denied.ID().String()); !ok || err != nil {
+		t.Fatalf("Forget = %v, %v", ok, err)
+	}
+	if !restored.Allowed(denied.ID()) {
+		t.Error("forgotten peer still refused")
+	}
+}
@@ -0,0 +1,214 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Key types a node identity can use
+const (
+	KeyTypeEd25519   = "ed25519"
+	KeyTypeSecp256k1 = "secp256k1"
+	KeyTypeRSA       = "rsa" // Identities created before Ed25519 became the default
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// GenerateKey creates a private key of keyType (empty means Ed25519)
+func GenerateKey(keyType string) (crypto.PrivKey, error) {
+	var libp2pType, bits int
+	switch keyType {
+	case KeyTypeEd25519, "":
+		libp2pType = crypto.Ed25519
+	case KeyTypeSecp256k1:
+		libp2pType = crypto.Secp256k1
+	case KeyTypeRSA:
+		libp2pType, bits = crypto.RSA, rsaKeyBits
+	default:
+		return nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+	priv, _, err := crypto.GenerateKeyPairWithReader(libp2pType, bits, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key pair: %w", keyType, err)
+	}
+	return priv, nil
+}
+
+// KeyType returns the name of a key's type
+func KeyType(priv crypto.PrivKey) string {
+	return strings.ToLower(priv.Type().String())
+}
+
+// expandKeyPath expands a leading tilde to the user's home directory
+func expandKeyPath(path string) (string, error) {
+	if path == "" || path[0] != '~' {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// readKeyFile reads a marshalled private key. Key files of any type keep
+// working, so identities created as RSA survive the switch to Ed25519.
+func readKeyFile(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := crypto.UnmarshalPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal key from %s: %w", path, err)
+	}
+	return priv, nil
+}
+
+// writeKeyFile saves a private key readable only by its owner
+func writeKeyFile(path string, priv crypto.PrivKey) error {
+	data, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save private key: %w", err)
+	}
+	return nil
+}
+
+// loadOrCreateIdentity loads the node's key from path, generating and
+// saving one of keyType if there is none. A key of another type is kept
+// so the node's peer ID does not change; RotateIdentity replaces it.
+func loadOrCreateIdentity(path, keyType string, logger *slog.Logger) (crypto.PrivKey, error) {
+	path, err := expandKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		priv, err := readKeyFile(path)
+		switch {
+		case err == nil:
+			logger.Info("loaded existing private key from file", "path", path, "key_type", KeyType(priv))
+			if keyType != "" && KeyType(priv) != keyType {
+				logger.Warn("identity key type differs from p2p.key_type; rotate the key to change it",
+					"key_type", KeyType(priv), "configured", keyType)
+			}
+			return priv, nil
+		case errors.Is(err, os.ErrNotExist):
+		default:
+			logger.Warn("failed to load key file, generating new key", "error", err)
+		}
+	}
+
+	priv, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := writeKeyFile(path, priv); err != nil {
+			logger.Warn("failed to save private key to file", "error", err, "path", path)
+		} else {
+			logger.Info("saved new private key to file", "path", path, "key_type", KeyType(priv))
+		}
+	}
+	return priv, nil
+}
+
+// CreateIdentity generates a key of keyType and saves it to path, refusing
+// to replace an existing key; RotateIdentity does that
+func CreateIdentity(path, keyType string) (crypto.PrivKey, error) {
+	path, err := expandKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no key file to create")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already holds a key; rotate it to replace it", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to check key file: %w", err)
+	}
+
+	priv, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(path, priv); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// ReadIdentity reads the key saved at path
+func ReadIdentity(path string) (crypto.PrivKey, error) {
+	path, err := expandKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return readKeyFile(path)
+}
+
+// Rotation reports an identity rotation
+type Rotation struct {
+	OldPeerID  peer.ID
+	NewPeerID  peer.ID
+	NewKey     crypto.PrivKey
+	BackupPath string // Where the old key was moved
+}
+
+// RotateIdentity replaces the key at path with a new key of keyType. The old
+// key is kept next to it, named after its peer ID, so the rotation can be
+// undone by moving it back.
+func RotateIdentity(path, keyType string) (*Rotation, error) {
+	path, err := expandKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no key file to rotate")
+	}
+
+	old, err := readKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current key: %w", err)
+	}
+	oldID, err := peer.IDFromPrivateKey(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive current peer ID: %w", err)
+	}
+	priv, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	newID, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive new peer ID: %w", err)
+	}
+
+	backup := path + "." + oldID.String()
+	if err := os.Rename(path, backup); err != nil {
+		return nil, fmt.Errorf("failed to back up current key: %w", err)
+	}
+	if err := writeKeyFile(path, priv); err != nil {
+		if restoreErr := os.Rename(backup, path); restoreErr != nil {
+			return nil, fmt.Errorf("%w (and failed to restore the old key from %s: %v)", err, backup, restoreErr)
+		}
+		return nil, err
+	}
+	return &Rotation{OldPeerID: oldID, NewPeerID: newID, NewKey: priv, BackupPath: backup}, nil
+}
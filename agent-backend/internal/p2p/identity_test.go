@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestLoadOrCreateIdentityKeepsExistingKeyTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.key")
+
+	priv, err := loadOrCreateIdentity(path, "", testLogger())
+	if err != nil {
+		t.Fatalf("loadOrCreateIdentity: %v", err)
+	}
+	if priv.Type() != crypto.Ed25519 {
+		t.Errorf("new key type = %v, want Ed25519 by default", priv.Type())
+	}
+
+	// A key file written before Ed25519 became the default keeps its peer ID
+	legacy, err := GenerateKey(KeyTypeRSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if err := writeKeyFile(path, legacy); err != nil {
+		t.Fatalf("writeKeyFile: %v", err)
+	}
+	loaded, err := loadOrCreateIdentity(path, KeyTypeEd25519, testLogger())
+	if err != nil {
+		t.Fatalf("loadOrCreateIdentity: %v", err)
+	}
+	if !loaded.Equals(legacy) {
+		t.Error("existing RSA key was replaced")
+	}
+}
+
+func TestRotateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.key")
+	old, err := loadOrCreateIdentity(path, KeyTypeRSA, testLogger())
+	if err != nil {
+		t.Fatalf("loadOrCreateIdentity: %v", err)
+	}
+	oldID, _ := peer.IDFromPrivateKey(old)
+
+	rotation, err := RotateIdentity(path, KeyTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("RotateIdentity: %v", err)
+	}
+	if rotation.OldPeerID != oldID || rotation.NewPeerID == oldID {
+		t.Errorf("rotation = %s -> %s, want from %s to a new ID", rotation.OldPeerID, rotation.NewPeerID, oldID)
+	}
+	if KeyType(rotation.NewKey) != KeyTypeSecp256k1 {
+		t.Errorf("new key type = %s, want secp256k1", KeyType(rotation.NewKey))
+	}
+
+	current, err := readKeyFile(path)
+	if err != nil || !current.Equals(rotation.NewKey) {
+		t.Fatalf("key file does not hold the new key: %v", err)
+	}
+	backup, err := readKeyFile(rotation.BackupPath)
+	if err != nil || !backup.Equals(old) {
+		t.Fatalf("backup does not hold the old key: %v", err)
+	}
+
+	if _, err := RotateIdentity(filepath.Join(t.TempDir(), "missing.key"), ""); err == nil {
+		t.Error("RotateIdentity created an identity where there was none")
+	}
+	if _, err := RotateIdentity(path, "dsa"); err == nil {
+		t.Error("RotateIdentity accepted an unknown key type")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("failed rotation removed the key file: %v", err)
+	}
+}
+
+func TestCreateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "agent.key")
+	priv, err := CreateIdentity(path, KeyTypeSecp256k1)
+	if err != nil {
+		t.Fatalf("CreateIdentity: %v", err)
+	}
+	if KeyType(priv) != KeyTypeSecp256k1 {
+		t.Errorf("key type = %s, want secp256k1", KeyType(priv))
+	}
+	read, err := ReadIdentity(path)
+	if err != nil || !read.Equals(priv) {
+		t.Fatalf("ReadIdentity() = %v; want the created key", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if _, err := CreateIdentity(path, ""); err == nil {
+		t.Error("CreateIdentity replaced an existing key")
+	}
+	if read, _ := ReadIdentity(path); !read.Equals(priv) {
+		t.Error("refused CreateIdentity changed the key file")
+	}
+}
@@ -2,12 +2,11 @@ package p2p
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
-	"io/ioutil"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"time"
+
+	"pandacea/agent-backend/internal/buildinfo"
 
 	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
@@ -18,103 +17,110 @@ import (
 	"github.com/multiformats/go-multiaddr"
 )
 
+// bootstrapTimeout bounds each connection attempt to a bootstrap peer
+const bootstrapTimeout = 30 * time.Second
+
+// NodeConfig configures a P2P node
+type NodeConfig struct {
+	ListenPort  int
+	KeyFilePath string
+	KeyType     string // Type of key generated when KeyFilePath holds none (empty means Ed25519)
+
+	// Transports the node dials and listens on (empty uses
+	// DefaultTransports). ListenAddrs overrides a transport's listen
+	// multiaddrs, keyed by transport name.
+	Transports  []string
+	ListenAddrs map[string][]string
+
+	// BootstrapPeers are multiaddrs ending in /p2p/<peer ID> the node
+	// connects to and seeds its DHT with. Empty uses the public IPFS
+	// bootstrap peers.
+	BootstrapPeers []string
+	// StaticRelays are circuit relay v2 relays, as /p2p/ multiaddrs, the
+	// node reserves a slot on when it is not publicly reachable
+	StaticRelays []string
+	HolePunching bool // Upgrade relayed connections to direct ones with DCUtR
+	RelayService bool // Relay connections for other peers
+
+	Gater *Gater // Decides which peers the node may connect with (nil allows all)
+}
+
 // Node represents a P2P node
 type Node struct {
-	host   host.Host
-	dht    *dht.IpfsDHT
-	logger *slog.Logger
+	host      host.Host
+	dht       *dht.IpfsDHT
+	priv      crypto.PrivKey
+	gater     *Gater
+	bootstrap []peer.AddrInfo
+	relays    []peer.AddrInfo
+	cfg       NodeConfig
+	status    *reachability
+	logger    *slog.Logger
 }
 
 // NewNode creates and initializes a new P2P node
-func NewNode(ctx context.Context, listenPort int, keyFilePath string, logger *slog.Logger) (*Node, error) {
-	var priv crypto.PrivKey
-	var err error
-
-	// Expand tilde in file path if present
-	if keyFilePath != "" {
-		if keyFilePath[0] == '~' {
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get home directory: %w", err)
-			}
-			keyFilePath = filepath.Join(homeDir, keyFilePath[1:])
-		}
+func NewNode(ctx context.Context, cfg NodeConfig, logger *slog.Logger) (*Node, error) {
+	bootstrap, err := parsePeerAddrs(cfg.BootstrapPeers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap peer: %w", err)
 	}
-
-	// Try to load existing key from file
-	if keyFilePath != "" {
-		if _, err := os.Stat(keyFilePath); err == nil {
-			// File exists, try to load the key
-			keyData, err := ioutil.ReadFile(keyFilePath)
-			if err != nil {
-				logger.Warn("failed to read key file, generating new key", "error", err)
-			} else {
-				priv, err = crypto.UnmarshalPrivateKey(keyData)
-				if err != nil {
-					logger.Warn("failed to unmarshal key from file, generating new key", "error", err)
-				} else {
-					logger.Info("loaded existing private key from file", "path", keyFilePath)
-				}
-			}
-		}
+	relays, err := parsePeerAddrs(cfg.StaticRelays)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static relay: %w", err)
+	}
+	transportOpts, err := transportOptions(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate new key if we don't have one
-	if priv == nil {
-		priv, _, err = crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, rand.Reader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate key pair: %w", err)
-		}
-
-		// Save the new key to file if path is specified
-		if keyFilePath != "" {
-			// Ensure directory exists
-			keyDir := filepath.Dir(keyFilePath)
-			if err := os.MkdirAll(keyDir, 0700); err != nil {
-				logger.Warn("failed to create key directory", "error", err, "path", keyDir)
-			} else {
-				// Marshal and save the key
-				keyData, err := crypto.MarshalPrivateKey(priv)
-				if err != nil {
-					logger.Warn("failed to marshal private key", "error", err)
-				} else {
-					if err := ioutil.WriteFile(keyFilePath, keyData, 0600); err != nil {
-						logger.Warn("failed to save private key to file", "error", err, "path", keyFilePath)
-					} else {
-						logger.Info("saved new private key to file", "path", keyFilePath)
-					}
-				}
-			}
-		}
+	priv, err := loadOrCreateIdentity(cfg.KeyFilePath, cfg.KeyType, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create libp2p host
 	var opts []libp2p.Option
 
-	opts = append(opts, libp2p.Identity(priv))
+	opts = append(opts, libp2p.Identity(priv), libp2p.UserAgent(buildinfo.UserAgent()))
 
-	if listenPort > 0 {
-		listenAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create listen address: %w", err)
-		}
-		opts = append(opts, libp2p.ListenAddrs(listenAddr))
-	}
+	opts = append(opts, transportOpts...)
 
 	opts = append(opts,
-		libp2p.DefaultTransports,
 		libp2p.DefaultMuxers,
 		libp2p.DefaultSecurity,
 		libp2p.NATPortMap(),
+		libp2p.EnableRelay(),
 	)
+	if len(relays) > 0 {
+		opts = append(opts, libp2p.EnableAutoRelayWithStaticRelays(relays))
+	}
+	if cfg.HolePunching {
+		opts = append(opts, libp2p.EnableHolePunching())
+	}
+	if cfg.RelayService {
+		opts = append(opts, libp2p.EnableRelayService(), libp2p.EnableNATService())
+	}
+	if cfg.Gater != nil {
+		opts = append(opts, libp2p.ConnectionGater(cfg.Gater))
+	}
 
 	host, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
 	}
 
-	// Create KAD-DHT
-	kadDHT, err := dht.New(ctx, host, dht.Mode(dht.ModeServer))
+	status, err := watchReachability(host)
+	if err != nil {
+		host.Close()
+		return nil, err
+	}
+
+	// Create KAD-DHT, seeded with the configured bootstrap peers
+	dhtOpts := []dht.Option{dht.Mode(dht.ModeServer)}
+	if len(bootstrap) > 0 {
+		dhtOpts = append(dhtOpts, dht.BootstrapPeers(bootstrap...))
+	}
+	kadDHT, err := dht.New(ctx, host, dhtOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DHT: %w", err)
 	}
@@ -124,14 +130,21 @@ func NewNode(ctx context.Context, listenPort int, keyFilePath string, logger *sl
 		return nil, fmt.Errorf("failed to bootstrap DHT: %w", err)
 	}
 
-	// Set up local peer discovery
-	mdns.NewMdnsService(host, "pandacea-agent", &discoveryNotifee{host: host})
-
 	node := &Node{
-		host:   host,
-		dht:    kadDHT,
-		logger: logger,
+		host:      host,
+		dht:       kadDHT,
+		priv:      priv,
+		gater:     cfg.Gater,
+		bootstrap: bootstrap,
+		relays:    relays,
+		cfg:       cfg,
+		status:    status,
+		logger:    logger,
 	}
+	go node.connectBootstrapPeers(ctx)
+
+	// Set up local peer discovery
+	mdns.NewMdnsService(host, "pandacea-agent", &discoveryNotifee{node: node})
 
 	// Log the peer ID for discovery
 	logger.Info("P2P node initialized",
@@ -147,6 +160,59 @@ func (n *Node) GetPeerID() string {
 	return n.host.ID().String()
 }
 
+// PrivateKey returns the node's identity key, or nil if it has none
+func (n *Node) PrivateKey() crypto.PrivKey {
+	return n.priv
+}
+
+// Host returns the node's libp2p host, for registering protocol handlers
+func (n *Node) Host() host.Host {
+	return n.host
+}
+
+// Gater returns the node's connection gater, or nil if it has none
+func (n *Node) Gater() *Gater {
+	return n.gater
+}
+
+// ReportPeer lowers a peer's reputation for offense, disconnecting it if
+// the gater no longer allows it
+func (n *Node) ReportPeer(id peer.ID, offense Offense) {
+	if n.gater == nil {
+		return
+	}
+	if !n.gater.Report(id, offense) {
+		n.disconnect(id)
+	}
+}
+
+// SetPeerList puts a peer on the gater's allow or deny list, disconnecting
+// it if it is now refused
+func (n *Node) SetPeerList(list, peerID, reason string) (PeerState, error) {
+	if n.gater == nil {
+		return PeerState{}, fmt.Errorf("peer gating is not enabled")
+	}
+	state, err := n.gater.SetList(list, peerID, reason)
+	if err != nil {
+		return PeerState{}, err
+	}
+	if !state.Allowed {
+		id, _ := peer.Decode(state.PeerID)
+		n.disconnect(id)
+	}
+	return state, nil
+}
+
+// disconnect closes every connection to a refused peer
+func (n *Node) disconnect(id peer.ID) {
+	if n.host == nil {
+		return
+	}
+	if err := n.host.Network().ClosePeer(id); err != nil {
+		n.logger.Warn("failed to disconnect refused peer", "peer_id", id.String(), "error", err)
+	}
+}
+
 // GetListenAddrs returns the listen addresses of this node
 func (n *Node) GetListenAddrs() []multiaddr.Multiaddr {
 	return n.host.Addrs()
@@ -156,6 +222,8 @@ func (n *Node) GetListenAddrs() []multiaddr.Multiaddr {
 func (n *Node) Close() error {
 	n.logger.Info("shutting down P2P node")
 
+	n.status.close()
+
 	if err := n.dht.Close(); err != nil {
 		n.logger.Error("failed to close DHT", "error", err)
 	}
@@ -168,18 +236,62 @@ func (n *Node) Close() error {
 	return nil
 }
 
+// connectBootstrapPeers dials the configured bootstrap peers so the node
+// joins the network beyond its LAN. The DHT redials them whenever its
+// routing table runs low.
+func (n *Node) connectBootstrapPeers(ctx context.Context) {
+	for _, info := range n.bootstrap {
+		go func() {
+			dialCtx, cancel := context.WithTimeout(ctx, bootstrapTimeout)
+			defer cancel()
+			if err := n.host.Connect(dialCtx, info); err != nil {
+				n.logger.Warn("failed to connect to bootstrap peer", "peer_id", info.ID.String(), "error", err)
+				return
+			}
+			n.logger.Info("connected to bootstrap peer", "peer_id", info.ID.String())
+		}()
+	}
+}
+
+// parsePeerAddrs parses /p2p/ multiaddrs, merging addresses of the same peer
+func parsePeerAddrs(addrs []string) ([]peer.AddrInfo, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	parsed := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, s := range addrs {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+		parsed = append(parsed, addr)
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(parsed...)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
 // discoveryNotifee handles peer discovery events
 type discoveryNotifee struct {
-	host host.Host
+	node *Node
 }
 
 func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
-	// Connect to discovered peers
-	if err := n.host.Connect(context.Background(), pi); err != nil {
-		slog.Error("failed to connect to discovered peer",
+	// Skip peers the gater would refuse rather than failing to dial them
+	if gater := n.node.gater; gater != nil && !gater.Allowed(pi.ID) {
+		n.node.logger.Debug("skipping refused discovered peer", "peer_id", pi.ID.String())
+		return
+	}
+
+	// Connect to discovered peers. mDNS announcements are unauthenticated
+	// and often stale, so a failed dial is not held against the peer.
+	if err := n.node.host.Connect(context.Background(), pi); err != nil {
+		n.node.logger.Warn("failed to connect to discovered peer",
 			"peer_id", pi.ID.String(),
 			"error", err)
 	} else {
-		slog.Info("connected to discovered peer", "peer_id", pi.ID.String())
+		n.node.logger.Info("connected to discovered peer", "peer_id", pi.ID.String())
 	}
 }
@@ -0,0 +1,158 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/buildinfo"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// pingTimeout bounds each latency probe
+const pingTimeout = 5 * time.Second
+
+// connectTimeout bounds a manual connection attempt
+const connectTimeout = 30 * time.Second
+
+// ErrInvalidAddr is returned for multiaddrs that do not parse or name no peer
+var ErrInvalidAddr = errors.New("invalid peer address")
+
+// ErrConnectFailed is returned when a manual connection attempt fails
+var ErrConnectFailed = errors.New("failed to connect to peer")
+
+// ConnectedPeer describes a peer the node is connected to
+type ConnectedPeer struct {
+	PeerID       string `json:"peer_id"`
+	AgentVersion string `json:"agent_version,omitempty"` // From identify; empty until it completes
+	// PandaceaVersion is the release a Pandacea agent peer announces in
+	// AgentVersion, for checking the network's compatibility
+	PandaceaVersion string `json:"pandacea_version,omitempty"`
+	// LatencyMs is the round trip of a ping sent for this listing, or the
+	// node's running average when the peer did not answer
+	LatencyMs   float64   `json:"latency_ms,omitempty"`
+	Direction   string    `json:"direction"` // inbound or outbound
+	Addr        string    `json:"addr"`      // Remote address of the connection
+	Relayed     bool      `json:"relayed"`
+	Protocols   []string  `json:"protocols"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// ConnectedPeers lists the peers the node is connected to, pinging each
+// concurrently to measure latency
+func (n *Node) ConnectedPeers(ctx context.Context) []ConnectedPeer {
+	ids := n.host.Network().Peers()
+	peers := make([]ConnectedPeer, len(ids))
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		peers[i] = n.describePeer(id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rtt, ok := n.ping(ctx, id); ok {
+				peers[i].LatencyMs = float64(rtt.Microseconds()) / 1000
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].PeerID < peers[j].PeerID })
+	return peers
+}
+
+// describePeer reports a connected peer from its oldest connection and the
+// peerstore, taking latency from the running average
+func (n *Node) describePeer(id peer.ID) ConnectedPeer {
+	info := ConnectedPeer{PeerID: id.String(), Protocols: []string{}}
+
+	conns := n.host.Network().ConnsToPeer(id)
+	sort.Slice(conns, func(i, j int) bool { return conns[i].Stat().Opened.Before(conns[j].Stat().Opened) })
+	if len(conns) > 0 {
+		stat := conns[0].Stat()
+		info.Direction = directionName(stat.Direction)
+		info.Addr = conns[0].RemoteMultiaddr().String()
+		info.Relayed = isRelayAddr(conns[0].RemoteMultiaddr())
+		info.ConnectedAt = stat.Opened
+	}
+
+	store := n.host.Peerstore()
+	if version, err := store.Get(id, "AgentVersion"); err == nil {
+		info.AgentVersion, _ = version.(string)
+		info.PandaceaVersion, _ = buildinfo.ParseUserAgent(info.AgentVersion)
+	}
+	if protocols, err := store.GetProtocols(id); err == nil {
+		for _, p := range protocols {
+			info.Protocols = append(info.Protocols, string(p))
+		}
+		sort.Strings(info.Protocols)
+	}
+	if rtt := store.LatencyEWMA(id); rtt > 0 {
+		info.LatencyMs = float64(rtt.Microseconds()) / 1000
+	}
+	return info
+}
+
+// ping measures one round trip to a peer
+func (n *Node) ping(ctx context.Context, id peer.ID) (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	select {
+	case result := <-ping.Ping(ctx, n.host, id):
+		if result.Error != nil {
+			n.logger.Debug("ping failed", "peer_id", id.String(), "error", result.Error)
+			return 0, false
+		}
+		return result.RTT, true
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+// Connect dials a peer at a multiaddr ending in /p2p/<peer ID> and reports
+// the connection. The connection gater still applies.
+func (n *Node) Connect(ctx context.Context, addr string) (ConnectedPeer, error) {
+	maddr, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return ConnectedPeer{}, fmt.Errorf("%w: %v", ErrInvalidAddr, err)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		return ConnectedPeer{}, fmt.Errorf("%w: %v", ErrInvalidAddr, err)
+	}
+	if info.ID == n.host.ID() {
+		return ConnectedPeer{}, fmt.Errorf("%w: address is this node's own", ErrInvalidAddr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if err := n.host.Connect(ctx, *info); err != nil {
+		return ConnectedPeer{}, fmt.Errorf("%w %s: %v", ErrConnectFailed, info.ID, err)
+	}
+	n.logger.Info("connected to peer", "peer_id", info.ID.String(), "addr", addr)
+
+	connected := n.describePeer(info.ID)
+	if rtt, ok := n.ping(ctx, info.ID); ok {
+		connected.LatencyMs = float64(rtt.Microseconds()) / 1000
+	}
+	return connected, nil
+}
+
+// directionName names a connection's direction
+func directionName(dir network.Direction) string {
+	switch dir {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
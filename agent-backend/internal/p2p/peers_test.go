@@ -0,0 +1,54 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pandacea/agent-backend/internal/buildinfo"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestConnectAndListConnectedPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listen := map[string][]string{TransportTCP: {"/ip4/127.0.0.1/tcp/0"}}
+	a, err := NewNode(ctx, NodeConfig{Transports: []string{TransportTCP}, ListenAddrs: listen}, testLogger())
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	defer a.Close()
+	b, err := NewNode(ctx, NodeConfig{Transports: []string{TransportTCP}, ListenAddrs: listen}, testLogger())
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	defer b.Close()
+
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: b.Host().ID(), Addrs: b.GetListenAddrs()})
+	if err != nil {
+		t.Fatalf("AddrInfoToP2pAddrs: %v", err)
+	}
+	connected, err := a.Connect(ctx, addrs[0].String())
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if connected.PeerID != b.GetPeerID() || connected.Direction != "outbound" {
+		t.Errorf("connected = %+v, want outbound to %s", connected, b.GetPeerID())
+	}
+	if connected.LatencyMs <= 0 {
+		t.Errorf("latency = %v, want a measured ping", connected.LatencyMs)
+	}
+
+	peers := b.ConnectedPeers(ctx)
+	if len(peers) != 1 || peers[0].PeerID != a.GetPeerID() {
+		t.Fatalf("peers = %+v, want only %s", peers, a.GetPeerID())
+	}
+	if peers[0].Direction != "inbound" || peers[0].AgentVersion != buildinfo.UserAgent() || peers[0].PandaceaVersion != buildinfo.Version || len(peers[0].Protocols) == 0 {
+		t.Errorf("peer = %+v, want inbound pandacea agent with protocols", peers[0])
+	}
+
+	if _, err := a.Connect(ctx, "/ip4/127.0.0.1/tcp/4001"); !errors.Is(err, ErrInvalidAddr) {
+		t.Errorf("Connect without peer ID error = %v, want ErrInvalidAddr", err)
+	}
+}
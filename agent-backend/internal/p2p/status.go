@@ -0,0 +1,137 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Status reports how reachable the node is and how it is connected
+type Status struct {
+	PeerID string `json:"peer_id"`
+	// Reachability is what AutoNAT has learned: unknown until enough peers
+	// have dialled back, then public or private
+	Reachability string `json:"reachability"`
+	// NATDeviceTypes is cone or symmetric by transport, once known.
+	// Hole punching only works through cone NATs.
+	NATDeviceTypes map[string]string `json:"nat_device_types,omitempty"`
+	ListenAddrs    []string          `json:"listen_addrs"`
+	RelayAddrs     []string          `json:"relay_addrs,omitempty"` // Addresses reachable through a relay reservation
+	ConnectedPeers int               `json:"connected_peers"`
+	BootstrapPeers []PeerLink        `json:"bootstrap_peers,omitempty"`
+	StaticRelays   []PeerLink        `json:"static_relays,omitempty"`
+	HolePunching   bool              `json:"hole_punching"`
+	RelayService   bool              `json:"relay_service"`
+}
+
+// PeerLink reports whether the node is connected to a configured peer
+type PeerLink struct {
+	PeerID    string `json:"peer_id"`
+	Connected bool   `json:"connected"`
+}
+
+// reachability tracks the host's AutoNAT reachability and NAT device types
+type reachability struct {
+	mu      sync.RWMutex
+	current network.Reachability
+	natType map[network.NATTransportProtocol]network.NATDeviceType
+	sub     event.Subscription
+}
+
+// watchReachability subscribes to the host's reachability and NAT events
+func watchReachability(h host.Host) (*reachability, error) {
+	sub, err := h.EventBus().Subscribe([]any{
+		new(event.EvtLocalReachabilityChanged),
+		new(event.EvtNATDeviceTypeChanged),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to reachability events: %w", err)
+	}
+
+	r := &reachability{
+		natType: make(map[network.NATTransportProtocol]network.NATDeviceType),
+		sub:     sub,
+	}
+	go func() {
+		for evt := range sub.Out() {
+			r.mu.Lock()
+			switch e := evt.(type) {
+			case event.EvtLocalReachabilityChanged:
+				r.current = e.Reachability
+			case event.EvtNATDeviceTypeChanged:
+				r.natType[e.TransportProtocol] = e.NatDeviceType
+			}
+			r.mu.Unlock()
+		}
+	}()
+	return r, nil
+}
+
+// close stops watching for events
+func (r *reachability) close() {
+	if r != nil {
+		r.sub.Close()
+	}
+}
+
+// Status reports the node's reachability and connections
+func (n *Node) Status() Status {
+	status := Status{
+		PeerID:       n.host.ID().String(),
+		Reachability: strings.ToLower(network.ReachabilityUnknown.String()),
+		ListenAddrs:  []string{},
+		HolePunching: n.cfg.HolePunching,
+		RelayService: n.cfg.RelayService,
+	}
+
+	if n.status != nil {
+		n.status.mu.RLock()
+		status.Reachability = strings.ToLower(n.status.current.String())
+		for transport, deviceType := range n.status.natType {
+			if deviceType == network.NATDeviceTypeUnknown {
+				continue
+			}
+			if status.NATDeviceTypes == nil {
+				status.NATDeviceTypes = make(map[string]string)
+			}
+			status.NATDeviceTypes[strings.ToLower(transport.String())] = strings.ToLower(deviceType.String())
+		}
+		n.status.mu.RUnlock()
+	}
+
+	for _, addr := range n.host.Addrs() {
+		if isRelayAddr(addr) {
+			status.RelayAddrs = append(status.RelayAddrs, addr.String())
+		} else {
+			status.ListenAddrs = append(status.ListenAddrs, addr.String())
+		}
+	}
+	status.ConnectedPeers = len(n.host.Network().Peers())
+	status.BootstrapPeers = n.links(n.bootstrap)
+	status.StaticRelays = n.links(n.relays)
+	return status
+}
+
+// links reports the node's connectedness to each peer
+func (n *Node) links(peers []peer.AddrInfo) []PeerLink {
+	var links []PeerLink
+	for _, info := range peers {
+		links = append(links, PeerLink{
+			PeerID:    info.ID.String(),
+			Connected: n.host.Network().Connectedness(info.ID) == network.Connected,
+		})
+	}
+	return links
+}
+
+// isRelayAddr reports whether addr goes through a circuit relay
+func isRelayAddr(addr multiaddr.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT)
+	return err == nil
+}
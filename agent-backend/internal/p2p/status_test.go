@@ -0,0 +1,51 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNodeConnectsToBootstrapPeersAndReportsThem(t *testing.T) {
+	boot := newTestHost(t)
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: boot.ID(), Addrs: boot.Addrs()})
+	if err != nil {
+		t.Fatalf("AddrInfoToP2pAddrs: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node, err := NewNode(ctx, NodeConfig{BootstrapPeers: []string{addrs[0].String()}}, testLogger())
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	defer node.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	var status Status
+	for {
+		status = node.Status()
+		if len(status.BootstrapPeers) == 1 && status.BootstrapPeers[0].Connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("bootstrap peer not connected: %+v", status.BootstrapPeers)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if status.BootstrapPeers[0].PeerID != boot.ID().String() {
+		t.Errorf("bootstrap peer = %s, want %s", status.BootstrapPeers[0].PeerID, boot.ID())
+	}
+	if status.Reachability != "unknown" {
+		t.Errorf("reachability = %q, want unknown before AutoNAT has run", status.Reachability)
+	}
+}
+
+func TestNewNodeRejectsBootstrapPeersWithoutPeerID(t *testing.T) {
+	_, err := NewNode(context.Background(), NodeConfig{BootstrapPeers: []string{"/ip4/127.0.0.1/tcp/4001"}}, testLogger())
+	if err == nil {
+		t.Fatal("NewNode accepted a bootstrap address without /p2p/")
+	}
+}
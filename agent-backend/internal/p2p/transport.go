@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p"
+	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	ws "github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Transports a node can dial and listen on
+const (
+	TransportTCP          = "tcp"
+	TransportQUIC         = "quic"
+	TransportWebSocket    = "websocket"
+	TransportWebTransport = "webtransport"
+)
+
+// DefaultTransports are used when a node's config names none
+var DefaultTransports = []string{TransportTCP, TransportQUIC}
+
+// transportOption returns the libp2p option that adds a transport
+func transportOption(name string) (libp2p.Option, error) {
+	switch name {
+	case TransportTCP:
+		return libp2p.Transport(tcp.NewTCPTransport), nil
+	case TransportQUIC:
+		return libp2p.Transport(quic.NewTransport), nil
+	case TransportWebSocket:
+		return libp2p.Transport(ws.New), nil
+	case TransportWebTransport:
+		return libp2p.Transport(webtransport.New), nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q", name)
+	}
+}
+
+// defaultListenAddr is where a transport listens on all interfaces at port
+func defaultListenAddr(name string, port int) string {
+	switch name {
+	case TransportQUIC:
+		return fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", port)
+	case TransportWebSocket:
+		return fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", port)
+	case TransportWebTransport:
+		return fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1/webtransport", port)
+	default:
+		return fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port)
+	}
+}
+
+// transportOptions builds the options that enable cfg's transports and
+// their listen addresses. Transports without listen addresses in
+// cfg.ListenAddrs listen on all interfaces at cfg.ListenPort.
+func transportOptions(cfg NodeConfig) ([]libp2p.Option, error) {
+	names := cfg.Transports
+	if len(names) == 0 {
+		names = DefaultTransports
+	}
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+	for name := range cfg.ListenAddrs {
+		if !enabled[name] {
+			return nil, fmt.Errorf("listen addresses given for transport %q, which is not enabled", name)
+		}
+	}
+
+	var opts []libp2p.Option
+	var listenAddrs []multiaddr.Multiaddr
+	for _, name := range names {
+		opt, err := transportOption(name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+
+		addrs := cfg.ListenAddrs[name]
+		if len(addrs) == 0 {
+			addrs = []string{defaultListenAddr(name, cfg.ListenPort)}
+		}
+		for _, s := range addrs {
+			addr, err := multiaddr.NewMultiaddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s listen address %s: %w", name, s, err)
+			}
+			listenAddrs = append(listenAddrs, addr)
+		}
+	}
+	opts = append(opts, libp2p.ListenAddrs(listenAddrs...))
+
+	// TCP and WebSocket listen on the same port by default
+	if enabled[TransportTCP] && enabled[TransportWebSocket] && cfg.ListenPort > 0 {
+		opts = append(opts, libp2p.ShareTCPListener())
+	}
+	return opts, nil
+}
@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ws "github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestNodeListensOnConfiguredTransports(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node, err := NewNode(ctx, NodeConfig{
+		Transports: []string{TransportQUIC, TransportWebSocket},
+		ListenAddrs: map[string][]string{
+			TransportQUIC:      {"/ip4/127.0.0.1/udp/0/quic-v1"},
+			TransportWebSocket: {"/ip4/127.0.0.1/tcp/0/ws"},
+		},
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	defer node.Close()
+
+	var wsAddr multiaddr.Multiaddr
+	var sawQUIC bool
+	for _, addr := range node.GetListenAddrs() {
+		s := addr.String()
+		switch {
+		case strings.HasSuffix(s, "/ws"):
+			wsAddr = addr
+		case strings.HasSuffix(s, "/quic-v1"):
+			sawQUIC = true
+		case strings.Contains(s, "/tcp/"):
+			t.Errorf("node listens on plain TCP at %s without it being enabled", s)
+		}
+	}
+	if wsAddr == nil || !sawQUIC {
+		t.Fatalf("listen addrs = %v, want QUIC and WebSocket", node.GetListenAddrs())
+	}
+
+	client := newTestHost(t, libp2p.Transport(ws.New), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0/ws"))
+	dialCtx, dialCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer dialCancel()
+	if err := client.Connect(dialCtx, peer.AddrInfo{ID: node.Host().ID(), Addrs: []multiaddr.Multiaddr{wsAddr}}); err != nil {
+		t.Fatalf("failed to connect over WebSocket: %v", err)
+	}
+}
+
+func TestTransportOptionsRejectUnknownTransports(t *testing.T) {
+	if _, err := transportOptions(NodeConfig{Transports: []string{"tcp", "carrier-pigeon"}}); err == nil {
+		t.Error("transportOptions accepted an unknown transport")
+	}
+	if _, err := transportOptions(NodeConfig{ListenAddrs: map[string][]string{TransportWebSocket: {"/ip4/0.0.0.0/tcp/0/ws"}}}); err == nil {
+		t.Error("transportOptions accepted listen addresses for a disabled transport")
+	}
+}
@@ -0,0 +1,126 @@
+package pinning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/telemetry"
+)
+
+// Node pins content on an IPFS node through its HTTP API
+type Node struct {
+	apiURL string
+	client *http.Client
+}
+
+// NewNode creates a service pinning on the IPFS node whose HTTP API is at
+// apiURL
+func NewNode(apiURL string) *Node {
+	return &Node{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		client: &http.Client{Timeout: 5 * time.Minute, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// Name implements Service
+func (n *Node) Name() string {
+	return "local"
+}
+
+// Pin implements Service. The node pins content as it adds it, so the pin
+// is tracked by its CID and is pinned at once.
+func (n *Node) Pin(ctx context.Context, name string, content []byte) (Receipt, error) {
+	cid, err := n.add(ctx, name, content)
+	if err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{CID: cid, RequestID: cid, Status: StatusPinned}, nil
+}
+
+// Status implements Service, reporting whether the node still pins the CID
+// requestID
+func (n *Node) Status(ctx context.Context, requestID string) (Status, error) {
+	if _, err := n.call(ctx, "pin/ls", url.Values{"arg": {requestID}, "type": {"recursive"}}); err != nil {
+		if strings.Contains(err.Error(), "not pinned") {
+			return StatusFailed, nil
+		}
+		return "", err
+	}
+	return StatusPinned, nil
+}
+
+// Unpin implements Service
+func (n *Node) Unpin(ctx context.Context, cid, requestID string) error {
+	if _, err := n.call(ctx, "pin/rm", url.Values{"arg": {cid}}); err != nil && !strings.Contains(err.Error(), "not pinned") {
+		return err
+	}
+	return nil
+}
+
+// add adds content to the node, pinned, and returns its CID
+func (n *Node) add(ctx context.Context, name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create IPFS upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.apiURL+"/api/v0/add?pin=true&cid-version=1", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IPFS request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	data, err := n.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.Unmarshal(data, &added); err != nil || added.Hash == "" {
+		return "", errors.New("IPFS API returned no CID")
+	}
+	return added.Hash, nil
+}
+
+// call sends a command without a body to the node's HTTP API
+func (n *Node) call(ctx context.Context, command string, args url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.apiURL+"/api/v0/"+command+"?"+args.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IPFS request: %w", err)
+	}
+	return n.do(req)
+}
+
+// do sends req and returns the response body of a successful request
+func (n *Node) do(req *http.Request) ([]byte, error) {
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach IPFS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("IPFS API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IPFS response: %w", err)
+	}
+	return data, nil
+}
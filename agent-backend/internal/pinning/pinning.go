@@ -0,0 +1,316 @@
+// Package pinning publishes computation results and training artifacts to
+// IPFS so spenders can fetch them by CID. Content is pinned on the agent's
+// own IPFS node or through a remote pinning service such as Pinata or
+// web3.storage. The publisher tracks each pin's status and releases pins
+// once the job they were published for is gone.
+package pinning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Kinds of jobs content is published for
+const (
+	KindComputation = "computation"
+	KindTraining    = "training"
+)
+
+// Status is where a pin is in its lifecycle. The values are the Pinning
+// Service API's.
+type Status string
+
+// Pin statuses. Content pinned on the local node is pinned at once.
+const (
+	StatusQueued  Status = "queued"  // The service accepted the pin but has not started it
+	StatusPinning Status = "pinning" // The service is fetching the content
+	StatusPinned  Status = "pinned"
+	StatusFailed  Status = "failed" // The service could not fetch the content
+)
+
+// final reports whether s no longer changes
+func (s Status) final() bool {
+	return s == StatusPinned || s == StatusFailed
+}
+
+// Receipt is what a service returns for content it was asked to pin
+type Receipt struct {
+	CID       string
+	RequestID string // What the service tracks the pin by
+	Status    Status
+}
+
+// Service pins content on IPFS
+type Service interface {
+	// Name identifies the service in pin records
+	Name() string
+	// Pin adds content under name and pins it
+	Pin(ctx context.Context, name string, content []byte) (Receipt, error)
+	// Status returns the status of the pin requestID
+	Status(ctx context.Context, requestID string) (Status, error)
+	// Unpin releases a pin. Pins that are already gone are not an error.
+	Unpin(ctx context.Context, cid, requestID string) error
+}
+
+// Pin is content published for a job
+type Pin struct {
+	Kind      string    `json:"kind"`      // computation or training
+	SourceID  string    `json:"source_id"` // Computation or training job ID
+	Name      string    `json:"name"`
+	CID       string    `json:"cid"`
+	URI       string    `json:"uri"` // ipfs://<cid>
+	Size      int64     `json:"size"`
+	Service   string    `json:"service"` // local or remote
+	RequestID string    `json:"request_id,omitempty"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Query filters listed pins. Empty fields match every pin.
+type Query struct {
+	Kind   string
+	Status Status
+}
+
+// Publisher pins job outputs through a service and keeps a record of them
+type Publisher struct {
+	service Service
+	path    string
+	logger  *slog.Logger
+
+	mu   sync.Mutex
+	pins map[string]*Pin // By kind and source ID
+}
+
+// New creates a publisher pinning through service. Pins are persisted to
+// path, and the ones there are restored; an empty path keeps them in
+// memory only.
+func New(service Service, path string, logger *slog.Logger) (*Publisher, error) {
+	p := &Publisher{
+		service: service,
+		path:    path,
+		logger:  logger,
+		pins:    make(map[string]*Pin),
+	}
+
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+	var pins []*Pin
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to parse pins: %w", err)
+	}
+	for _, pin := range pins {
+		p.pins[key(pin.Kind, pin.SourceID)] = pin
+	}
+	return p, nil
+}
+
+// key identifies the pin of a job
+func key(kind, sourceID string) string {
+	return kind + "/" + sourceID
+}
+
+// Publish pins content for a job and returns its pin. Content already
+// published for the job is not pinned again, unless its pin failed.
+func (p *Publisher) Publish(ctx context.Context, kind, sourceID, name string, content []byte) (Pin, error) {
+	if pin, ok := p.Get(kind, sourceID); ok && pin.Status != StatusFailed {
+		return pin, nil
+	}
+
+	receipt, err := p.service.Pin(ctx, name, content)
+	if err != nil {
+		return Pin{}, fmt.Errorf("failed to pin %s %s: %w", kind, sourceID, err)
+	}
+	now := time.Now().UTC()
+	pin := &Pin{
+		Kind:      kind,
+		SourceID:  sourceID,
+		Name:      name,
+		CID:       receipt.CID,
+		URI:       "ipfs://" + receipt.CID,
+		Size:      int64(len(content)),
+		Service:   p.service.Name(),
+		RequestID: receipt.RequestID,
+		Status:    receipt.Status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pins[key(kind, sourceID)] = pin
+	if err := p.save(); err != nil {
+		p.logger.Error("failed to save pins", "error", err)
+	}
+	return *pin, nil
+}
+
+// Get returns the pin published for a job
+func (p *Publisher) Get(kind, sourceID string) (Pin, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pin, ok := p.pins[key(kind, sourceID)]
+	if !ok {
+		return Pin{}, false
+	}
+	return *pin, true
+}
+
+// Release unpins the content published for a job and forgets its pin. It
+// returns the CID released, or "" if nothing was published for the job.
+func (p *Publisher) Release(ctx context.Context, kind, sourceID string) (string, error) {
+	pin, ok := p.Get(kind, sourceID)
+	if !ok {
+		return "", nil
+	}
+	if err := p.service.Unpin(ctx, pin.CID, pin.RequestID); err != nil {
+		return "", fmt.Errorf("failed to unpin %s: %w", pin.CID, err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pins, key(kind, sourceID))
+	if err := p.save(); err != nil {
+		p.logger.Error("failed to save pins", "error", err)
+	}
+	return pin.CID, nil
+}
+
+// List returns the pins matching q, newest first
+func (p *Publisher) List(q Query) []Pin {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pins := make([]Pin, 0, len(p.pins))
+	for _, pin := range p.pins {
+		if (q.Kind == "" || pin.Kind == q.Kind) && (q.Status == "" || pin.Status == q.Status) {
+			pins = append(pins, *pin)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].CreatedAt.After(pins[j].CreatedAt) })
+	return pins
+}
+
+// Run refreshes the status of unfinished pins every interval until ctx is
+// cancelled
+func (p *Publisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh asks the service for the status of every pin that is not yet
+// pinned or failed
+func (p *Publisher) Refresh(ctx context.Context) {
+	p.mu.Lock()
+	var open []Pin
+	for _, pin := range p.pins {
+		if !pin.Status.final() {
+			open = append(open, *pin)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, pin := range open {
+		status, err := p.service.Status(ctx, pin.RequestID)
+		if err != nil {
+			p.logger.Debug("failed to get pin status", "cid", pin.CID, "request_id", pin.RequestID, "error", err)
+			continue
+		}
+		if status == pin.Status {
+			continue
+		}
+		if status == StatusFailed {
+			p.logger.Warn("pinning service failed to pin content", "kind", pin.Kind, "source_id", pin.SourceID, "cid", pin.CID)
+		}
+		p.mu.Lock()
+		if current, ok := p.pins[key(pin.Kind, pin.SourceID)]; ok && current.CID == pin.CID {
+			current.Status = status
+			current.UpdatedAt = time.Now().UTC()
+			if err := p.save(); err != nil {
+				p.logger.Error("failed to save pins", "error", err)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Collect unpins the content of jobs for which exists reports false, such
+// as jobs retention dropped, and forgets their pins. Pins that cannot be
+// released are kept and retried on the next run. It returns the number of
+// pins released.
+func (p *Publisher) Collect(ctx context.Context, exists func(Pin) bool) int {
+	p.mu.Lock()
+	var gone []Pin
+	for _, pin := range p.pins {
+		if !exists(*pin) {
+			gone = append(gone, *pin)
+		}
+	}
+	p.mu.Unlock()
+
+	released := 0
+	for _, pin := range gone {
+		if err := p.service.Unpin(ctx, pin.CID, pin.RequestID); err != nil {
+			p.logger.Warn("failed to unpin content", "kind", pin.Kind, "source_id", pin.SourceID, "cid", pin.CID, "error", err)
+			continue
+		}
+		p.mu.Lock()
+		delete(p.pins, key(pin.Kind, pin.SourceID))
+		if err := p.save(); err != nil {
+			p.logger.Error("failed to save pins", "error", err)
+		}
+		p.mu.Unlock()
+		released++
+	}
+	return released
+}
+
+// save writes the pins atomically. Caller must hold p.mu.
+func (p *Publisher) save() error {
+	if p.path == "" {
+		return nil
+	}
+
+	pins := make([]*Pin, 0, len(p.pins))
+	for _, pin := range p.pins {
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].CreatedAt.Before(pins[j].CreatedAt) })
+	data, err := json.Marshal(pins)
+	if err != nil {
+		return fmt.Errorf("failed to encode pins: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+		return fmt.Errorf("failed to create pins directory: %w", err)
+	}
+	if err := fsutil.WriteFile(p.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pins: %w", err)
+	}
+	return nil
+}
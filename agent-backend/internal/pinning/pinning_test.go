@@ -0,0 +1,196 @@
+package pinning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIPFS is an IPFS node and a pinning service in one. The node names
+// content after its size; the service pins what it was asked to once its
+// status has been polled.
+type fakeIPFS struct {
+	mu      sync.Mutex
+	local   map[string]bool   // CIDs pinned on the node
+	remote  map[string]string // Request IDs to the CIDs the service pins
+	polled  map[string]bool
+	tokens  []string
+	removed []string
+}
+
+func newFakeIPFS(t *testing.T) (*fakeIPFS, *httptest.Server) {
+	f := &fakeIPFS{local: make(map[string]bool), remote: make(map[string]string), polled: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var content bytes.Buffer
+		content.ReadFrom(file)
+		cid := "bafy" + strings.Repeat("a", content.Len())
+		f.mu.Lock()
+		f.local[cid] = true
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"Hash": cid})
+	})
+	mux.HandleFunc("POST /api/v0/pin/rm", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if !f.local[r.URL.Query().Get("arg")] {
+			http.Error(w, `{"Message":"not pinned or pinned indirectly"}`, http.StatusInternalServerError)
+			return
+		}
+		delete(f.local, r.URL.Query().Get("arg"))
+	})
+	mux.HandleFunc("POST /psa/pins", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ CID string }
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+		id := "req-" + req.CID
+		f.remote[id] = req.CID
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(pinStatus{RequestID: id, Status: StatusQueued})
+	})
+	mux.HandleFunc("GET /psa/pins/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		id := r.PathValue("id")
+		if _, ok := f.remote[id]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		status := StatusPinning
+		if f.polled[id] {
+			status = StatusPinned
+		}
+		f.polled[id] = true
+		json.NewEncoder(w).Encode(pinStatus{RequestID: id, Status: status})
+	})
+	mux.HandleFunc("DELETE /psa/pins/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.remote, r.PathValue("id"))
+		f.removed = append(f.removed, r.PathValue("id"))
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestPublisher_Remote(t *testing.T) {
+	fake, server := newFakeIPFS(t)
+	service := NewRemote(NewNode(server.URL), server.URL+"/psa", "secret")
+	path := filepath.Join(t.TempDir(), "pins.json")
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	publisher, err := New(service, path, logger)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	pin, err := publisher.Publish(ctx, KindTraining, "job_1", "aggregate.json", []byte("model"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if pin.CID != "bafyaaaaa" || pin.URI != "ipfs://bafyaaaaa" || pin.Status != StatusQueued || pin.Service != "remote" {
+		t.Errorf("pin = %+v, want bafyaaaaa queued on the remote service", pin)
+	}
+	if len(fake.tokens) != 1 || fake.tokens[0] != "Bearer secret" {
+		t.Errorf("pinning service saw tokens %v, want the bearer token", fake.tokens)
+	}
+
+	// Publishing the same job again reuses its pin
+	if _, err := publisher.Publish(ctx, KindTraining, "job_1", "aggregate.json", []byte("other model")); err != nil {
+		t.Fatalf("Publish() again error = %v", err)
+	}
+	if len(fake.remote) != 1 {
+		t.Errorf("service pins %v, want the job pinned once", fake.remote)
+	}
+
+	publisher.Refresh(ctx)
+	if pin, _ := publisher.Get(KindTraining, "job_1"); pin.Status != StatusPinning {
+		t.Errorf("status after one refresh = %q, want pinning", pin.Status)
+	}
+	publisher.Refresh(ctx)
+	if pin, _ := publisher.Get(KindTraining, "job_1"); pin.Status != StatusPinned {
+		t.Errorf("status after two refreshes = %q, want pinned", pin.Status)
+	}
+
+	if _, err := publisher.Publish(ctx, KindComputation, "comp_1", "comp_1.json", []byte("{}")); err != nil {
+		t.Fatalf("Publish(computation) error = %v", err)
+	}
+	if pins := publisher.List(Query{Status: StatusPinned}); len(pins) != 1 || pins[0].SourceID != "job_1" {
+		t.Errorf("List(pinned) = %+v, want the training job", pins)
+	}
+
+	// Pins survive a restart
+	restored, err := New(service, path, logger)
+	if err != nil {
+		t.Fatalf("New() restoring error = %v", err)
+	}
+	if pins := restored.List(Query{}); len(pins) != 2 || pins[0].SourceID != "comp_1" {
+		t.Errorf("restored pins = %+v, want both, newest first", pins)
+	}
+
+	// Pins of jobs that are gone are released on the service and the node
+	released := restored.Collect(ctx, func(pin Pin) bool { return pin.Kind == KindComputation })
+	if released != 1 {
+		t.Errorf("Collect() = %d, want 1", released)
+	}
+	if _, ok := restored.Get(KindTraining, "job_1"); ok {
+		t.Error("collected pin is still recorded")
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != "req-bafyaaaaa" || fake.local["bafyaaaaa"] {
+		t.Errorf("removed %v with local pins %v, want the training pin released everywhere", fake.removed, fake.local)
+	}
+
+	// Releasing what is already gone is not an error
+	if err := service.Unpin(ctx, "bafyaaaaa", "req-bafyaaaaa"); err != nil {
+		t.Errorf("Unpin() of a released pin error = %v", err)
+	}
+}
+
+func TestPublisher_Node(t *testing.T) {
+	fake, server := newFakeIPFS(t)
+	publisher, err := New(NewNode(server.URL), "", slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	pin, err := publisher.Publish(ctx, KindComputation, "comp_1", "comp_1.json", []byte("{}"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if pin.Status != StatusPinned || pin.Service != "local" || !fake.local[pin.CID] {
+		t.Errorf("pin = %+v, want pinned on the node at once", pin)
+	}
+
+	if released := publisher.Collect(ctx, func(Pin) bool { return false }); released != 1 || fake.local[pin.CID] {
+		t.Errorf("Collect() = %d with local pins %v, want the pin removed", released, fake.local)
+	}
+
+	// A single job's content can be released directly
+	pin, err = publisher.Publish(ctx, KindTraining, "job_1", "aggregate.json", []byte(`{"n":1}`))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if cid, err := publisher.Release(ctx, KindTraining, "job_1"); err != nil || cid != pin.CID || fake.local[pin.CID] {
+		t.Errorf("Release() = %q, %v with local pins %v, want %s removed", cid, err, fake.local, pin.CID)
+	}
+	if cid, err := publisher.Release(ctx, KindTraining, "job_1"); err != nil || cid != "" {
+		t.Errorf("Release() of a released job = %q, %v", cid, err)
+	}
+}
@@ -0,0 +1,127 @@
+package pinning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/telemetry"
+)
+
+// Remote pins content through a remote pinning service implementing the
+// IPFS Pinning Service API, such as Pinata or web3.storage. Content is
+// added to the local node, which serves it while the service fetches it.
+type Remote struct {
+	node     *Node
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewRemote creates a service pinning through the Pinning Service API at
+// endpoint, authenticated with the access token. Content is added to node
+// first.
+func NewRemote(node *Node, endpoint, token string) *Remote {
+	return &Remote{
+		node:     node,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		token:    token,
+		client:   &http.Client{Timeout: 30 * time.Second, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// pinStatus is the Pinning Service API's record of a pin request
+type pinStatus struct {
+	RequestID string `json:"requestid"`
+	Status    Status `json:"status"`
+}
+
+// statusError is a pinning service response with an unexpected status
+type statusError struct {
+	code   int
+	detail string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("pinning service returned status %d: %s", e.code, e.detail)
+}
+
+// Name implements Service
+func (r *Remote) Name() string {
+	return "remote"
+}
+
+// Pin implements Service. The service usually queues the pin, so its
+// status is followed with Status.
+func (r *Remote) Pin(ctx context.Context, name string, content []byte) (Receipt, error) {
+	cid, err := r.node.add(ctx, name, content)
+	if err != nil {
+		return Receipt{}, err
+	}
+	body, err := json.Marshal(map[string]string{"cid": cid, "name": name})
+	if err != nil {
+		return Receipt{}, fmt.Errorf("failed to encode pin request: %w", err)
+	}
+	var status pinStatus
+	if err := r.do(ctx, http.MethodPost, "/pins", body, http.StatusAccepted, &status); err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{CID: cid, RequestID: status.RequestID, Status: status.Status}, nil
+}
+
+// Status implements Service
+func (r *Remote) Status(ctx context.Context, requestID string) (Status, error) {
+	var status pinStatus
+	if err := r.do(ctx, http.MethodGet, "/pins/"+url.PathEscape(requestID), nil, http.StatusOK, &status); err != nil {
+		return "", err
+	}
+	return status.Status, nil
+}
+
+// Unpin implements Service, removing the pin from the service and the
+// content from the local node
+func (r *Remote) Unpin(ctx context.Context, cid, requestID string) error {
+	err := r.do(ctx, http.MethodDelete, "/pins/"+url.PathEscape(requestID), nil, http.StatusAccepted, nil)
+	var refused *statusError
+	if err != nil && !(errors.As(err, &refused) && refused.code == http.StatusNotFound) {
+		return err
+	}
+	return r.node.Unpin(ctx, cid, cid)
+}
+
+// do sends a request to the pinning service and decodes its response into
+// v if it answers with want
+func (r *Remote) do(ctx context.Context, method, path string, body []byte, want int, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, r.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create pinning service request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach pinning service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, detail: strings.TrimSpace(string(detail))}
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode pinning service response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidDuration is returned for lease durations ParseDuration rejects
+var ErrInvalidDuration = errors.New("invalid lease duration")
+
+// durationUnits maps lease duration suffixes to their length
+var durationUnits = map[byte]time.Duration{
+	'd': 24 * time.Hour,
+	'h': time.Hour,
+	'm': time.Minute,
+	's': time.Second,
+}
+
+// ParseDuration parses a lease duration such as "24h" or "7d": a whole
+// number followed by one of d, h, m or s
+func ParseDuration(duration string) (time.Duration, error) {
+	if len(duration) < 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, duration)
+	}
+	unit, ok := durationUnits[duration[len(duration)-1]]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown unit in %q", ErrInvalidDuration, duration)
+	}
+	n, err := strconv.ParseInt(duration[:len(duration)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, duration)
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return 0, fmt.Errorf("%w: %q is too long", ErrInvalidDuration, duration)
+	}
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,21 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{"30s": 30 * time.Second, "15m": 15 * time.Minute, "24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour}
+	for duration, want := range tests {
+		if got, err := ParseDuration(duration); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", duration, got, err, want)
+		}
+	}
+	for _, duration := range []string{"", "h", "10w", "-1h", "1.5h", "9999999999999d"} {
+		if _, err := ParseDuration(duration); !errors.Is(err, ErrInvalidDuration) {
+			t.Errorf("ParseDuration(%q) error = %v, want ErrInvalidDuration", duration, err)
+		}
+	}
+}
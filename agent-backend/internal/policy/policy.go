@@ -2,10 +2,13 @@ package policy
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/pricing"
 )
 
 // Request represents a lease request to be evaluated
@@ -13,6 +16,13 @@ type Request struct {
 	ProductID string `json:"productId"`
 	MaxPrice  string `json:"maxPrice"`
 	Duration  string `json:"duration"`
+	// Requester is the peer ID or address of the party requesting the lease
+	Requester string `json:"requester,omitempty"`
+	// Reputation is the requester's reputation score, nil when unknown
+	Reputation *float64 `json:"reputation,omitempty"`
+	// Transfer marks a request to assign an existing lease to Requester.
+	// The lease's price and duration were accepted when it was created.
+	Transfer bool `json:"transfer,omitempty"`
 }
 
 // EvaluationResult represents the result of a policy evaluation
@@ -21,6 +31,11 @@ type EvaluationResult struct {
 	Reason  string `json:"reason,omitempty"`
 }
 
+// Evaluator decides whether a lease request may proceed
+type Evaluator interface {
+	EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult
+}
+
 // Engine represents the policy evaluation engine
 type Engine struct {
 	logger                 *slog.Logger
@@ -31,6 +46,11 @@ type Engine struct {
 	reputationDecayRate    float64
 	collusionSpendFraction float64
 	collusionBonusDivisor  int
+	minReputation          float64
+	maxDuration            time.Duration
+	productMaxDurations    map[string]time.Duration
+	allowTransfers         bool
+	pricer                 *pricing.Pricer
 }
 
 // NewEngine creates a new policy engine
@@ -40,6 +60,19 @@ func NewEngine(logger *slog.Logger, cfg config.ServerConfig) (*Engine, error) {
 		return nil, err
 	}
 
+	var maxDuration time.Duration
+	if cfg.MaxLeaseDuration != "" {
+		if maxDuration, err = ParseDuration(cfg.MaxLeaseDuration); err != nil {
+			return nil, fmt.Errorf("invalid max_lease_duration: %w", err)
+		}
+	}
+	productMaxDurations := make(map[string]time.Duration, len(cfg.ProductMaxLeaseDurations))
+	for productID, duration := range cfg.ProductMaxLeaseDurations {
+		if productMaxDurations[productID], err = ParseDuration(duration); err != nil {
+			return nil, fmt.Errorf("invalid max lease duration for %s: %w", productID, err)
+		}
+	}
+
 	return &Engine{
 		logger:                 logger,
 		minPrice:               minPrice,
@@ -49,17 +82,52 @@ func NewEngine(logger *slog.Logger, cfg config.ServerConfig) (*Engine, error) {
 		reputationDecayRate:    cfg.ReputationDecayRate,
 		collusionSpendFraction: cfg.CollusionSpendFraction,
 		collusionBonusDivisor:  cfg.CollusionBonusDivisor,
+		minReputation:          cfg.MinReputation,
+		maxDuration:            maxDuration,
+		productMaxDurations:    productMaxDurations,
+		allowTransfers:         cfg.AllowLeaseTransfers,
 	}, nil
 }
 
+// SetPricer replaces the static minimum price with the pricer's per-product
+// dynamic floor
+func (e *Engine) SetPricer(pricer *pricing.Pricer) {
+	e.pricer = pricer
+}
+
+// minPriceFor returns the price floor that applies to productID. The
+// engine's own minimum price stands in for the pricer's configured one, so
+// a shadow engine with a different min_price still sees on-chain and
+// demand adjustments.
+func (e *Engine) minPriceFor(productID string) decimal.Decimal {
+	if e.pricer == nil {
+		return e.minPrice
+	}
+	return e.pricer.Quote(productID).EffectiveFor(e.minPrice)
+}
+
+// maxDurationFor returns the longest lease allowed for productID, or 0 if
+// there is no cap
+func (e *Engine) maxDurationFor(productID string) time.Duration {
+	if limit, ok := e.productMaxDurations[productID]; ok {
+		return limit
+	}
+	return e.maxDuration
+}
+
 // EvaluateRequest evaluates a lease request according to the Guiding Principles
 // Implements Dynamic Minimum Pricing (DMP) validation
 func (e *Engine) EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult {
+	if req.Transfer {
+		return e.evaluateTransfer(req)
+	}
+
+	minPrice := e.minPriceFor(req.ProductID)
 	e.logger.Info("policy evaluation started",
 		"product_id", req.ProductID,
 		"max_price", req.MaxPrice,
 		"duration", req.Duration,
-		"min_price", e.minPrice.String(),
+		"min_price", minPrice.String(),
 	)
 
 	// Parse the request's max price
@@ -77,7 +145,7 @@ func (e *Engine) EvaluateRequest(ctx context.Context, req *Request) *EvaluationR
 	}
 
 	// Check if the price meets the minimum requirement (DMP validation)
-	if requestPrice.LessThan(e.minPrice) {
+	if requestPrice.LessThan(minPrice) {
 		result := &EvaluationResult{
 			Allowed: false,
 			Reason:  "Proposed maxPrice is below the dynamic minimum price.",
@@ -89,6 +157,47 @@ func (e *Engine) EvaluateRequest(ctx context.Context, req *Request) *EvaluationR
 		return result
 	}
 
+	// Enforce the product's maximum lease duration
+	duration, err := ParseDuration(req.Duration)
+	if err != nil {
+		result := &EvaluationResult{
+			Allowed: false,
+			Reason:  "Invalid duration format",
+		}
+		e.logger.Info("policy evaluation completed",
+			"allowed", result.Allowed,
+			"reason", result.Reason,
+		)
+		return result
+	}
+	if maxDuration := e.maxDurationFor(req.ProductID); maxDuration > 0 && duration > maxDuration {
+		result := &EvaluationResult{
+			Allowed: false,
+			Reason:  "Requested duration exceeds the maximum allowed for this product.",
+		}
+		e.logger.Info("policy evaluation completed",
+			"allowed", result.Allowed,
+			"reason", result.Reason,
+			"max_duration", maxDuration.String(),
+		)
+		return result
+	}
+
+	// Reject counterparties with a poor track record. Unknown counterparties
+	// have no score and are not affected.
+	if req.Reputation != nil && *req.Reputation < e.minReputation {
+		result := &EvaluationResult{
+			Allowed: false,
+			Reason:  "Requester reputation is below the minimum required.",
+		}
+		e.logger.Info("policy evaluation completed",
+			"allowed", result.Allowed,
+			"reason", result.Reason,
+			"reputation", *req.Reputation,
+		)
+		return result
+	}
+
 	result := &EvaluationResult{
 		Allowed: true,
 		Reason:  "Policy evaluation passed - price meets minimum requirement",
@@ -101,3 +210,27 @@ func (e *Engine) EvaluateRequest(ctx context.Context, req *Request) *EvaluationR
 
 	return result
 }
+
+// evaluateTransfer evaluates the assignment of an existing lease. Transfers
+// must be enabled, and the new holder must meet the reputation minimum a
+// new lease request would.
+func (e *Engine) evaluateTransfer(req *Request) *EvaluationResult {
+	result := &EvaluationResult{
+		Allowed: true,
+		Reason:  "Policy evaluation passed - lease transfer accepted",
+	}
+	switch {
+	case !e.allowTransfers:
+		result = &EvaluationResult{Allowed: false, Reason: "Lease transfers are not accepted."}
+	case req.Reputation != nil && *req.Reputation < e.minReputation:
+		result = &EvaluationResult{Allowed: false, Reason: "New holder reputation is below the minimum required."}
+	}
+
+	e.logger.Info("policy evaluation completed",
+		"product_id", req.ProductID,
+		"transfer", true,
+		"allowed", result.Allowed,
+		"reason", result.Reason,
+	)
+	return result
+}
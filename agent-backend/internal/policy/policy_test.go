@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/pricing"
+)
+
+func TestEngineMinReputation(t *testing.T) {
+	engine, err := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), config.ServerConfig{MinPrice: "0.001", MinReputation: 0.3})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	low, high := 0.2, 0.6
+	tests := []struct {
+		name       string
+		reputation *float64
+		allowed    bool
+	}{
+		{"unknown counterparty", nil, true},
+		{"good reputation", &high, true},
+		{"poor reputation", &low, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := engine.EvaluateRequest(context.Background(), &Request{
+				ProductID:  "did:pandacea:earner:public/1",
+				MaxPrice:   "0.01",
+				Duration:   "1h",
+				Reputation: tt.reputation,
+			})
+			if result.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v (reason %q)", result.Allowed, tt.allowed, result.Reason)
+			}
+		})
+	}
+}
+
+func TestEngineDynamicMinPrice(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	engine, err := NewEngine(logger, config.ServerConfig{MinPrice: "0.001"})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	pricer, err := pricing.NewPricer(logger, "0.001", config.PricingConfig{DemandThreshold: 1, DemandStep: 1, MaxMultiplier: 3})
+	if err != nil {
+		t.Fatalf("NewPricer() error = %v", err)
+	}
+	engine.SetPricer(pricer)
+
+	req := &Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.0015", Duration: "1h"}
+	if result := engine.EvaluateRequest(context.Background(), req); !result.Allowed {
+		t.Fatalf("request rejected without demand: %s", result.Reason)
+	}
+
+	// Two requests over the threshold doubles the floor
+	for i := 0; i < 3; i++ {
+		pricer.RecordLease(req.ProductID)
+	}
+	if result := engine.EvaluateRequest(context.Background(), req); result.Allowed {
+		t.Error("request below the demand-adjusted floor was allowed")
+	}
+}
+
+func TestEngineMaxDuration(t *testing.T) {
+	engine, err := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), config.ServerConfig{
+		MinPrice:                 "0.001",
+		MaxLeaseDuration:         "30d",
+		ProductMaxLeaseDurations: map[string]string{"did:pandacea:earner:public/short": "24h"},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	tests := []struct {
+		product  string
+		duration string
+		allowed  bool
+	}{
+		{"did:pandacea:earner:public/1", "30d", true},
+		{"did:pandacea:earner:public/1", "31d", false},
+		{"did:pandacea:earner:public/short", "24h", true},
+		{"did:pandacea:earner:public/short", "2d", false},
+		{"did:pandacea:earner:public/1", "1w", false},
+	}
+	for _, tt := range tests {
+		result := engine.EvaluateRequest(context.Background(), &Request{ProductID: tt.product, MaxPrice: "0.01", Duration: tt.duration})
+		if result.Allowed != tt.allowed {
+			t.Errorf("%s for %s: Allowed = %v, want %v (reason %q)", tt.duration, tt.product, result.Allowed, tt.allowed, result.Reason)
+		}
+	}
+
+	if _, err := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), config.ServerConfig{MinPrice: "0.001", MaxLeaseDuration: "forever"}); err == nil {
+		t.Error("NewEngine() should reject an invalid max_lease_duration")
+	}
+}
+
+func TestEngineLeaseTransfer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	low, high := 0.2, 0.6
+	transfer := func(engine *Engine, reputation *float64) *EvaluationResult {
+		// Price and duration were agreed when the lease was created, so they
+		// are not evaluated again
+		return engine.EvaluateRequest(context.Background(), &Request{
+			ProductID:  "did:pandacea:earner:public/1",
+			Requester:  "0xnewholder",
+			Reputation: reputation,
+			Transfer:   true,
+		})
+	}
+
+	disabled, err := NewEngine(logger, config.ServerConfig{MinPrice: "0.001"})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	if result := transfer(disabled, &high); result.Allowed {
+		t.Error("transfer allowed without allow_lease_transfers")
+	}
+
+	engine, err := NewEngine(logger, config.ServerConfig{MinPrice: "0.001", MinReputation: 0.3, AllowLeaseTransfers: true})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	for _, reputation := range []*float64{nil, &high} {
+		if result := transfer(engine, reputation); !result.Allowed {
+			t.Errorf("transfer rejected: %s", result.Reason)
+		}
+	}
+	if result := transfer(engine, &low); result.Allowed {
+		t.Error("transfer allowed to a holder below the reputation minimum")
+	}
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/shopspring/decimal"
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/pricing"
+	"pandacea/agent-backend/internal/tenant"
+)
+
+// DefaultRegoQuery is evaluated when the policy config does not set a query
+const DefaultRegoQuery = "data.pandacea.lease"
+
+// RegoEngine evaluates lease requests against Rego policies. The static
+// minimum price check always runs first, so policies can only tighten it.
+type RegoEngine struct {
+	static *Engine
+	query  rego.PreparedEvalQuery
+	source string
+	now    func() time.Time
+}
+
+// NewEvaluator returns the policy engine selected by cfg.Policy. A non-nil
+// pricer supplies dynamic price floors. Each tenant's products are evaluated
+// with the tenant's server overrides. When a shadow policy is enabled the
+// returned evaluator also runs it and reports where it disagrees.
+func NewEvaluator(ctx context.Context, logger *slog.Logger, cfg *config.Config, pricer *pricing.Pricer) (Evaluator, error) {
+	live, err := newEvaluator(ctx, logger, cfg.Server, cfg.Policy, pricer)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Tenants) > 0 {
+		engines := make(map[string]Evaluator, len(cfg.Tenants))
+		for i := range cfg.Tenants {
+			t := &cfg.Tenants[i]
+			serverCfg, err := t.ServerConfig(cfg.Server)
+			if err != nil {
+				return nil, err
+			}
+			if engines[t.ID], err = newEvaluator(ctx, logger.With("tenant", t.ID), serverCfg, cfg.Policy, pricer); err != nil {
+				return nil, fmt.Errorf("failed to create policy engine for tenant %s: %w", t.ID, err)
+			}
+		}
+		live = NewTenantEvaluator(tenant.NewRegistry(cfg.Tenants), engines, live)
+	}
+
+	shadowCfg := cfg.Policy.Shadow
+	if shadowCfg == nil || !shadowCfg.Enabled {
+		return live, nil
+	}
+	serverCfg, err := shadowCfg.ServerConfig(cfg.Server)
+	if err != nil {
+		return nil, err
+	}
+	shadowLogger := logger.With("policy", "shadow")
+	shadow, err := newEvaluator(ctx, shadowLogger, serverCfg, shadowCfg.PolicyConfig(), pricer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shadow policy engine: %w", err)
+	}
+	logger.Info("shadow policy engine enabled", "engine", shadowCfg.Engine)
+	return NewShadowEvaluator(live, shadow, logger), nil
+}
+
+// newEvaluator builds the engine selected by policyCfg
+func newEvaluator(ctx context.Context, logger *slog.Logger, serverCfg config.ServerConfig, policyCfg config.PolicyConfig, pricer *pricing.Pricer) (Evaluator, error) {
+	switch policyCfg.Engine {
+	case "", "static":
+		engine, err := NewEngine(logger, serverCfg)
+		if err != nil {
+			return nil, err
+		}
+		engine.SetPricer(pricer)
+		return engine, nil
+	case "rego":
+		engine, err := NewRegoEngine(ctx, logger, serverCfg, policyCfg)
+		if err != nil {
+			return nil, err
+		}
+		engine.static.SetPricer(pricer)
+		return engine, nil
+	default:
+		return nil, fmt.Errorf("unknown policy engine: %q", policyCfg.Engine)
+	}
+}
+
+// NewRegoEngine compiles the policies at policyCfg.RegoPath or policyCfg.Bundle
+func NewRegoEngine(ctx context.Context, logger *slog.Logger, serverCfg config.ServerConfig, policyCfg config.PolicyConfig) (*RegoEngine, error) {
+	static, err := NewEngine(logger, serverCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	query := policyCfg.Query
+	if query == "" {
+		query = DefaultRegoQuery
+	}
+
+	options := []func(*rego.Rego){rego.Query(query)}
+	var source string
+	switch {
+	case policyCfg.Bundle != "":
+		options = append(options, rego.LoadBundle(policyCfg.Bundle))
+		source = policyCfg.Bundle
+	case policyCfg.RegoPath != "":
+		options = append(options, rego.Load([]string{policyCfg.RegoPath}, nil))
+		source = policyCfg.RegoPath
+	default:
+		return nil, fmt.Errorf("rego policy engine requires rego_path or bundle")
+	}
+
+	prepared, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile rego policies from %s: %w", source, err)
+	}
+
+	logger.Info("rego policy engine initialized", "source", source, "query", query)
+
+	return &RegoEngine{
+		static: static,
+		query:  prepared,
+		source: source,
+		now:    time.Now,
+	}, nil
+}
+
+// EvaluateRequest applies the minimum price check and then the Rego policies.
+// Evaluation errors and undefined decisions reject the request.
+func (e *RegoEngine) EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult {
+	if result := e.static.EvaluateRequest(ctx, req); !result.Allowed {
+		return result
+	}
+
+	result := e.evaluate(ctx, req)
+	e.static.logger.Info("rego policy evaluation completed",
+		"product_id", req.ProductID,
+		"source", e.source,
+		"allowed", result.Allowed,
+		"reason", result.Reason,
+	)
+	return result
+}
+
+// evaluate runs the prepared query against the request
+func (e *RegoEngine) evaluate(ctx context.Context, req *Request) *EvaluationResult {
+	results, err := e.query.Eval(ctx, rego.EvalInput(e.input(req)))
+	if err != nil {
+		e.static.logger.Error("rego policy evaluation failed", "error", err)
+		return &EvaluationResult{Allowed: false, Reason: "Policy evaluation failed"}
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return &EvaluationResult{Allowed: false, Reason: "No policy decision for request"}
+	}
+
+	return decision(results[0].Expressions[0].Value)
+}
+
+// input builds the document policies see as `input`
+func (e *RegoEngine) input(req *Request) map[string]any {
+	now := e.now()
+	input := map[string]any{
+		"product_id": req.ProductID,
+		"max_price":  req.MaxPrice,
+		"min_price":  e.static.minPriceFor(req.ProductID).String(),
+		"duration":   req.Duration,
+		"requester":  req.Requester,
+		"transfer":   req.Transfer,
+		"time": map[string]any{
+			"hour":    now.Hour(),
+			"minute":  now.Minute(),
+			"weekday": now.Weekday().String(),
+			"unix":    now.Unix(),
+		},
+	}
+
+	// Numeric forms let policies compare without parsing strings
+	if price, err := decimal.NewFromString(req.MaxPrice); err == nil {
+		input["max_price_value"] = price.InexactFloat64()
+	}
+	if duration, err := ParseDuration(req.Duration); err == nil {
+		input["duration_seconds"] = int64(duration / time.Second)
+	}
+	if req.Reputation != nil {
+		input["reputation"] = *req.Reputation
+	}
+
+	return input
+}
+
+// decision interprets a query result. A boolean is the decision itself; an
+// object may set allow, reason and a deny set of messages, where any deny
+// message rejects the request.
+func decision(value any) *EvaluationResult {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return &EvaluationResult{Allowed: true, Reason: "Allowed by policy"}
+		}
+		return &EvaluationResult{Allowed: false, Reason: "Denied by policy"}
+	case map[string]any:
+		var denials []string
+		if deny, ok := v["deny"].([]any); ok {
+			for _, msg := range deny {
+				denials = append(denials, fmt.Sprint(msg))
+			}
+		}
+		if len(denials) > 0 {
+			sort.Strings(denials)
+			return &EvaluationResult{Allowed: false, Reason: denials[0]}
+		}
+
+		allowed, _ := v["allow"].(bool)
+		reason, _ := v["reason"].(string)
+		if reason == "" {
+			reason = decision(allowed).Reason
+		}
+		return &EvaluationResult{Allowed: allowed, Reason: reason}
+	default:
+		return &EvaluationResult{Allowed: false, Reason: "No policy decision for request"}
+	}
+}
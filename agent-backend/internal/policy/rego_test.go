@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+const testLeasePolicy = `package pandacea.lease
+
+import rego.v1
+
+default allow := false
+
+allow if {
+	input.duration_seconds <= 7 * 24 * 60 * 60
+	input.time.hour >= 8
+	input.time.hour < 20
+}
+
+deny contains "product is not for lease" if {
+	startswith(input.product_id, "did:pandacea:earner:private/")
+}
+
+deny contains "requester reputation too low" if {
+	input.reputation < 0.5
+}
+`
+
+func newTestRegoEngine(t *testing.T, hour int) *RegoEngine {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "lease.rego")
+	if err := os.WriteFile(path, []byte(testLeasePolicy), 0644); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	engine, err := NewRegoEngine(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config.ServerConfig{MinPrice: "0.001"}, config.PolicyConfig{RegoPath: path})
+	if err != nil {
+		t.Fatalf("NewRegoEngine() error = %v", err)
+	}
+	engine.now = func() time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC) }
+
+	return engine
+}
+
+func TestRegoEngineEvaluateRequest(t *testing.T) {
+	low, high := 0.2, 0.9
+
+	tests := []struct {
+		name    string
+		hour    int
+		req     Request
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "allowed during business hours",
+			hour:    10,
+			req:     Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.01", Duration: "24h", Reputation: &high},
+			allowed: true,
+		},
+		{
+			name:   "below minimum price",
+			hour:   10,
+			req:    Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.0001", Duration: "24h"},
+			reason: "Proposed maxPrice is below the dynamic minimum price.",
+		},
+		{
+			name:   "outside business hours",
+			hour:   22,
+			req:    Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.01", Duration: "24h"},
+			reason: "Denied by policy",
+		},
+		{
+			name:   "duration too long",
+			hour:   10,
+			req:    Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.01", Duration: "30d"},
+			reason: "Denied by policy",
+		},
+		{
+			name:   "denied product",
+			hour:   10,
+			req:    Request{ProductID: "did:pandacea:earner:private/1", MaxPrice: "0.01", Duration: "1h"},
+			reason: "product is not for lease",
+		},
+		{
+			name:   "low reputation",
+			hour:   10,
+			req:    Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.01", Duration: "1h", Reputation: &low},
+			reason: "requester reputation too low",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestRegoEngine(t, tt.hour)
+			result := engine.EvaluateRequest(context.Background(), &tt.req)
+
+			if result.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v (reason %q)", result.Allowed, tt.allowed, result.Reason)
+			}
+			if tt.reason != "" && result.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", result.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewRegoEngineErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	serverCfg := config.ServerConfig{MinPrice: "0.001"}
+
+	if _, err := NewRegoEngine(context.Background(), logger, serverCfg, config.PolicyConfig{}); err == nil {
+		t.Error("expected error without rego_path or bundle")
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.rego")
+	if err := os.WriteFile(path, []byte("package pandacea.lease\nallow {"), 0644); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	if _, err := NewRegoEngine(context.Background(), logger, serverCfg, config.PolicyConfig{RegoPath: path}); err == nil {
+		t.Error("expected compile error for invalid policy")
+	}
+
+	cfg := &config.Config{Server: serverCfg, Policy: config.PolicyConfig{Engine: "wasm"}}
+	if _, err := NewEvaluator(context.Background(), logger, cfg, nil); err == nil {
+		t.Error("expected error for unknown engine")
+	}
+}
@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Reloadable is an Evaluator whose underlying engine can be replaced while
+// requests are being evaluated, so policy and economic parameter changes
+// apply without a restart
+type Reloadable struct {
+	current atomic.Pointer[Evaluator]
+}
+
+// NewReloadable creates a reloadable evaluator deciding with evaluator
+func NewReloadable(evaluator Evaluator) *Reloadable {
+	r := &Reloadable{}
+	r.Swap(evaluator)
+	return r
+}
+
+// Swap makes evaluator decide every request evaluated from now on.
+// Evaluations already in progress finish with the previous engine.
+func (r *Reloadable) Swap(evaluator Evaluator) {
+	r.current.Store(&evaluator)
+}
+
+// EvaluateRequest evaluates req with the current engine
+func (r *Reloadable) EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult {
+	return (*r.current.Load()).EvaluateRequest(ctx, req)
+}
@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+func TestReloadableSwap(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cheap, err := NewEngine(logger, config.ServerConfig{MinPrice: "0.001"})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	dear, err := NewEngine(logger, config.ServerConfig{MinPrice: "0.1"})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	req := &Request{ProductID: "did:pandacea:earner:public/1", MaxPrice: "0.01", Duration: "1h"}
+	evaluator := NewReloadable(cheap)
+	if result := evaluator.EvaluateRequest(context.Background(), req); !result.Allowed {
+		t.Fatalf("EvaluateRequest() rejected before swap: %s", result.Reason)
+	}
+
+	evaluator.Swap(dear)
+	if result := evaluator.EvaluateRequest(context.Background(), req); result.Allowed {
+		t.Error("EvaluateRequest() still used the old minimum price after Swap()")
+	}
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Shadow evaluation limits
+const (
+	// shadowConcurrency caps shadow evaluations in flight; requests beyond it
+	// skip the shadow rather than queue behind it
+	shadowConcurrency = 32
+	shadowTimeout     = 5 * time.Second
+)
+
+var (
+	shadowEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_policy_shadow_evaluations_total",
+		Help: "Lease requests evaluated by the shadow policy engine, by outcome (match, divergence, skipped)",
+	}, []string{"outcome"})
+	shadowDivergences = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_policy_shadow_divergences_total",
+		Help: "Lease requests where the shadow policy engine's decision differed from the live engine's",
+	}, []string{"live_allowed", "shadow_allowed"})
+)
+
+// ShadowEvaluator returns the live engine's decisions while evaluating every
+// request with a shadow engine in the background. Decisions that differ are
+// logged with the full request and counted, so a candidate policy or
+// economic parameter change can be checked against real traffic before it
+// goes live.
+type ShadowEvaluator struct {
+	live    Evaluator
+	shadow  Evaluator
+	logger  *slog.Logger
+	slots   chan struct{}
+	pending sync.WaitGroup
+}
+
+// NewShadowEvaluator creates an evaluator that decides with live and
+// compares against shadow
+func NewShadowEvaluator(live, shadow Evaluator, logger *slog.Logger) *ShadowEvaluator {
+	return &ShadowEvaluator{
+		live:   live,
+		shadow: shadow,
+		logger: logger,
+		slots:  make(chan struct{}, shadowConcurrency),
+	}
+}
+
+// EvaluateRequest returns the live decision and starts the shadow comparison
+func (s *ShadowEvaluator) EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult {
+	result := s.live.EvaluateRequest(ctx, req)
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		shadowEvaluations.WithLabelValues("skipped").Inc()
+		return result
+	}
+
+	// The shadow must not outlive its budget or see the request's cancellation
+	shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	reqCopy := *req
+	liveResult := *result
+	s.pending.Add(1)
+	go func() {
+		defer func() {
+			cancel()
+			<-s.slots
+			s.pending.Done()
+		}()
+		s.compare(&reqCopy, &liveResult, s.shadow.EvaluateRequest(shadowCtx, &reqCopy))
+	}()
+
+	return result
+}
+
+// Wait blocks until every started shadow evaluation has finished
+func (s *ShadowEvaluator) Wait() {
+	s.pending.Wait()
+}
+
+// compare records whether the shadow agreed with the live decision
+func (s *ShadowEvaluator) compare(req *Request, live, shadow *EvaluationResult) {
+	if live.Allowed == shadow.Allowed {
+		shadowEvaluations.WithLabelValues("match").Inc()
+		return
+	}
+
+	shadowEvaluations.WithLabelValues("divergence").Inc()
+	shadowDivergences.WithLabelValues(strconv.FormatBool(live.Allowed), strconv.FormatBool(shadow.Allowed)).Inc()
+
+	attrs := []any{
+		"product_id", req.ProductID,
+		"max_price", req.MaxPrice,
+		"duration", req.Duration,
+		"requester", req.Requester,
+		"live_allowed", live.Allowed,
+		"live_reason", live.Reason,
+		"shadow_allowed", shadow.Allowed,
+		"shadow_reason", shadow.Reason,
+	}
+	if req.Reputation != nil {
+		attrs = append(attrs, "reputation", *req.Reputation)
+	}
+	s.logger.Warn("shadow policy decision diverged", attrs...)
+}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/yaml.v3"
+)
+
+func TestShadowEvaluatorReportsDivergence(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+server:
+  min_price: "0.001"
+policy:
+  engine: static
+  shadow:
+    enabled: true
+    engine: static
+    server:
+      min_price: "0.01"
+`), &cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	evaluator, err := NewEvaluator(context.Background(), logger, &cfg, nil)
+	if err != nil {
+		t.Fatalf("NewEvaluator() error = %v", err)
+	}
+	shadow, ok := evaluator.(*ShadowEvaluator)
+	if !ok {
+		t.Fatalf("NewEvaluator() = %T, want *ShadowEvaluator", evaluator)
+	}
+	if cfg.Server.MinPrice != "0.001" {
+		t.Fatalf("shadow overrides changed the live config: min_price = %s", cfg.Server.MinPrice)
+	}
+
+	diverged := testutil.ToFloat64(shadowDivergences.WithLabelValues("true", "false"))
+	matched := testutil.ToFloat64(shadowEvaluations.WithLabelValues("match"))
+
+	// Live decisions are returned unchanged
+	result := shadow.EvaluateRequest(context.Background(), &Request{ProductID: "p1", MaxPrice: "0.005", Duration: "1h"})
+	if !result.Allowed {
+		t.Errorf("live decision = %+v, want allowed at the live min_price", result)
+	}
+	shadow.EvaluateRequest(context.Background(), &Request{ProductID: "p1", MaxPrice: "0.05", Duration: "1h"})
+	shadow.Wait()
+
+	if got := testutil.ToFloat64(shadowDivergences.WithLabelValues("true", "false")) - diverged; got != 1 {
+		t.Errorf("divergences = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(shadowEvaluations.WithLabelValues("match")) - matched; got != 1 {
+		t.Errorf("matches = %v, want 1", got)
+	}
+	for _, want := range []string{"shadow policy decision diverged", "max_price=0.005", "shadow_allowed=false"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q", want)
+		}
+	}
+}
+
+func TestShadowServerConfigDefaultsToLive(t *testing.T) {
+	live := config.ServerConfig{MinPrice: "0.001", ProductMaxLeaseDurations: map[string]string{"p1": "7d"}}
+	var shadow config.ShadowPolicyConfig
+	if err := yaml.Unmarshal([]byte("server:\n  product_max_lease_durations:\n    p2: 1d\n"), &shadow); err != nil {
+		t.Fatalf("failed to parse shadow config: %v", err)
+	}
+
+	got, err := shadow.ServerConfig(live)
+	if err != nil {
+		t.Fatalf("ServerConfig() error = %v", err)
+	}
+	if got.MinPrice != "0.001" || got.ProductMaxLeaseDurations["p1"] != "7d" || got.ProductMaxLeaseDurations["p2"] != "1d" {
+		t.Errorf("ServerConfig() = %+v, want live values with the override merged in", got)
+	}
+	if _, leaked := live.ProductMaxLeaseDurations["p2"]; leaked {
+		t.Error("shadow override leaked into the live config")
+	}
+}
@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"context"
+
+	"pandacea/agent-backend/internal/tenant"
+)
+
+// TenantEvaluator evaluates lease requests for a tenant's products with the
+// tenant's own engine, and requests for other products with a default one
+type TenantEvaluator struct {
+	tenants  *tenant.Registry
+	engines  map[string]Evaluator
+	fallback Evaluator
+}
+
+// NewTenantEvaluator creates an evaluator routing requests by product
+// namespace to engines, keyed by tenant ID. Tenants without an engine and
+// products outside every namespace are evaluated by fallback.
+func NewTenantEvaluator(tenants *tenant.Registry, engines map[string]Evaluator, fallback Evaluator) *TenantEvaluator {
+	return &TenantEvaluator{tenants: tenants, engines: engines, fallback: fallback}
+}
+
+// EvaluateRequest evaluates req with the engine of the tenant owning its product
+func (t *TenantEvaluator) EvaluateRequest(ctx context.Context, req *Request) *EvaluationResult {
+	if owner, ok := t.tenants.ForProduct(req.ProductID); ok {
+		if engine, ok := t.engines[owner.ID]; ok {
+			return engine.EvaluateRequest(ctx, req)
+		}
+	}
+	return t.fallback.EvaluateRequest(ctx, req)
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewEvaluatorTenants(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+server:
+  min_price: "0.001"
+  collusion_bonus_divisor: 2
+policy:
+  engine: static
+tenants:
+  - id: alice
+    earner: "0xaaaa000000000000000000000000000000000001"
+    namespaces: ["did:pandacea:mainnet:alice"]
+    server:
+      min_price: "0.01"
+      max_lease_duration: 1d
+  - id: bob
+    earner: "0xbbbb000000000000000000000000000000000002"
+    namespaces: ["did:pandacea:mainnet:bob"]
+`), &cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	evaluator, err := NewEvaluator(context.Background(), logger, &cfg, nil)
+	if err != nil {
+		t.Fatalf("NewEvaluator() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		productID string
+		maxPrice  string
+		duration  string
+		want      bool
+	}{
+		{"tenant min_price applies", "did:pandacea:mainnet:alice/weather", "0.005", "1h", false},
+		{"above tenant min_price", "did:pandacea:mainnet:alice/weather", "0.02", "1h", true},
+		{"tenant max_lease_duration applies", "did:pandacea:mainnet:alice/weather", "0.02", "2d", false},
+		{"tenant without overrides uses server settings", "did:pandacea:mainnet:bob/traffic", "0.005", "30d", true},
+		{"product outside every namespace", "did:pandacea:mainnet:carol/x", "0.005", "30d", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evaluator.EvaluateRequest(context.Background(), &Request{ProductID: tt.productID, MaxPrice: tt.maxPrice, Duration: tt.duration})
+			if result.Allowed != tt.want {
+				t.Errorf("EvaluateRequest() = %+v, want allowed %v", result, tt.want)
+			}
+		})
+	}
+}
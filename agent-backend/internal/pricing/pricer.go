@@ -0,0 +1,231 @@
+package pricing
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"pandacea/agent-backend/internal/config"
+)
+
+// weiDecimals converts on-chain wei amounts to the ether units used in config
+const weiDecimals = 18
+
+// MinPriceReader reads the protocol minimum price in wei
+type MinPriceReader interface {
+	MinPrice(ctx context.Context) (*big.Int, error)
+}
+
+// Quote is the current effective price floor for a product
+type Quote struct {
+	ProductID        string    `json:"productId"`
+	ConfigMinPrice   string    `json:"configMinPrice"`
+	OnChainMinPrice  string    `json:"onChainMinPrice,omitempty"`
+	BaseMinPrice     string    `json:"baseMinPrice"`
+	DemandMultiplier float64   `json:"demandMultiplier"`
+	RecentRequests   int       `json:"recentRequests"`
+	EffectivePrice   string    `json:"effectivePrice"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+
+	effective  decimal.Decimal
+	onChain    *decimal.Decimal
+	multiplier decimal.Decimal
+}
+
+// Effective returns the effective price as a decimal
+func (q Quote) Effective() decimal.Decimal {
+	return q.effective
+}
+
+// EffectiveFor returns the effective price if configMin replaced the
+// configured minimum, keeping the on-chain minimum and demand multiplier
+func (q Quote) EffectiveFor(configMin decimal.Decimal) decimal.Decimal {
+	base := configMin
+	if q.onChain != nil {
+		base = decimal.Max(base, *q.onChain)
+	}
+	return base.Mul(q.multiplier)
+}
+
+// Pricer computes per-product price floors. The base floor is the higher of
+// the configured minimum and the contract's MIN_PRICE; how often a product
+// was leased recently raises its floor above the base.
+type Pricer struct {
+	mu            sync.Mutex
+	logger        *slog.Logger
+	configMin     decimal.Decimal
+	onChainMin    *decimal.Decimal
+	window        time.Duration
+	threshold     int
+	step          float64
+	maxMultiplier float64
+	requests      map[string][]time.Time
+	now           func() time.Time
+}
+
+// NewPricer creates a pricer with the configured minimum price and demand settings
+func NewPricer(logger *slog.Logger, minPrice string, cfg config.PricingConfig) (*Pricer, error) {
+	p := &Pricer{
+		logger:   logger,
+		requests: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+	if err := p.Reconfigure(minPrice, cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseMinPrice parses a configured minimum price, in ether
+func ParseMinPrice(minPrice string) (decimal.Decimal, error) {
+	return decimal.NewFromString(minPrice)
+}
+
+// Reconfigure replaces the configured minimum price and demand settings.
+// Recorded demand and the on-chain minimum are kept. An invalid minPrice
+// leaves the pricer unchanged.
+func (p *Pricer) Reconfigure(minPrice string, cfg config.PricingConfig) error {
+	configMin, err := ParseMinPrice(minPrice)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.configMin = configMin
+	p.window = time.Duration(cfg.DemandWindowSeconds) * time.Second
+	p.threshold = cfg.DemandThreshold
+	p.step = cfg.DemandStep
+	p.maxMultiplier = cfg.MaxMultiplier
+	if p.window <= 0 {
+		p.window = time.Hour
+	}
+	if p.maxMultiplier < 1 {
+		p.maxMultiplier = 1
+	}
+	return nil
+}
+
+// RecordLease counts a lease accepted for productID towards its demand
+func (p *Pricer) RecordLease(productID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.requests[productID] = append(p.prune(productID, now), now)
+}
+
+// SetOnChainMinPrice records the contract's MIN_PRICE in wei
+func (p *Pricer) SetOnChainMinPrice(wei *big.Int) {
+	price := decimal.NewFromBigInt(wei, -weiDecimals)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.onChainMin == nil || !p.onChainMin.Equal(price) {
+		p.logger.Info("on-chain minimum price updated", "min_price", price.String())
+	}
+	p.onChainMin = &price
+}
+
+// Quote returns the current effective price floor for productID
+func (p *Pricer) Quote(productID string) Quote {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	recent := len(p.prune(productID, now))
+
+	base := p.configMin
+	quote := Quote{
+		ProductID:      productID,
+		ConfigMinPrice: p.configMin.String(),
+		RecentRequests: recent,
+		UpdatedAt:      now,
+	}
+	if p.onChainMin != nil {
+		quote.OnChainMinPrice = p.onChainMin.String()
+		quote.onChain = p.onChainMin
+		base = decimal.Max(base, *p.onChainMin)
+	}
+
+	multiplier := 1.0
+	if excess := recent - p.threshold; p.threshold > 0 && excess > 0 {
+		multiplier = min(1+p.step*float64(excess), p.maxMultiplier)
+	}
+
+	quote.BaseMinPrice = base.String()
+	quote.DemandMultiplier = multiplier
+	quote.multiplier = decimal.NewFromFloat(multiplier)
+	quote.effective = base.Mul(quote.multiplier)
+	quote.EffectivePrice = quote.effective.String()
+	return quote
+}
+
+// Run polls the on-chain minimum price and sweeps stale demand history
+// every interval until ctx is cancelled. Failed reads keep the last known
+// value. With a nil reader only demand history is swept.
+func (p *Pricer) Run(ctx context.Context, reader MinPriceReader, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	refresh := func() {
+		if reader == nil {
+			return
+		}
+		readCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		wei, err := reader.MinPrice(readCtx)
+		if err != nil {
+			p.logger.Warn("failed to read on-chain minimum price", "error", err)
+			return
+		}
+		p.SetOnChainMinPrice(wei)
+	}
+
+	refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			refresh()
+			p.sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweep drops demand history for products with no recent requests
+func (p *Pricer) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for productID := range p.requests {
+		p.prune(productID, now)
+	}
+}
+
+// prune drops requests older than the demand window. Caller must hold p.mu.
+func (p *Pricer) prune(productID string, now time.Time) []time.Time {
+	times := p.requests[productID]
+	cutoff := now.Add(-p.window)
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(p.requests, productID)
+		return nil
+	}
+	p.requests[productID] = times
+	return times
+}
@@ -0,0 +1,154 @@
+package pricing
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/big"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+type staticMinPrice struct{ wei *big.Int }
+
+func (s staticMinPrice) MinPrice(context.Context) (*big.Int, error) {
+	return s.wei, nil
+}
+
+func newTestPricer(t *testing.T, now *time.Time) *Pricer {
+	t.Helper()
+	pricer, err := NewPricer(slog.New(slog.NewTextHandler(io.Discard, nil)), "0.001", config.PricingConfig{
+		DemandWindowSeconds: 60,
+		DemandThreshold:     2,
+		DemandStep:          0.5,
+		MaxMultiplier:       2,
+	})
+	if err != nil {
+		t.Fatalf("NewPricer() error = %v", err)
+	}
+	pricer.now = func() time.Time { return *now }
+	return pricer
+}
+
+func TestQuoteDemandMultiplier(t *testing.T) {
+	now := time.Now()
+	pricer := newTestPricer(t, &now)
+	product := "did:pandacea:earner:123/abc-456"
+
+	if q := pricer.Quote(product); q.EffectivePrice != "0.001" || q.DemandMultiplier != 1 {
+		t.Errorf("Quote() without demand = %+v", q)
+	}
+
+	for i := 0; i < 3; i++ {
+		pricer.RecordLease(product)
+	}
+	if q := pricer.Quote(product); q.EffectivePrice != "0.0015" || q.RecentRequests != 3 {
+		t.Errorf("Quote() above threshold = %+v, want 0.0015", q)
+	}
+
+	for i := 0; i < 10; i++ {
+		pricer.RecordLease(product)
+	}
+	if q := pricer.Quote(product); q.EffectivePrice != "0.002" {
+		t.Errorf("Quote() effective price = %s, want capped at 0.002", q.EffectivePrice)
+	}
+	if q := pricer.Quote("did:pandacea:earner:999/other"); q.EffectivePrice != "0.001" {
+		t.Errorf("demand leaked to another product: %+v", q)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if q := pricer.Quote(product); q.RecentRequests != 0 || q.EffectivePrice != "0.001" {
+		t.Errorf("Quote() after window = %+v", q)
+	}
+}
+
+func TestQuoteOnChainMinPrice(t *testing.T) {
+	now := time.Now()
+	pricer := newTestPricer(t, &now)
+	product := "did:pandacea:earner:123/abc-456"
+
+	// 0.002 ether is above the configured floor
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pricer.Run(ctx, staticMinPrice{wei: big.NewInt(2_000_000_000_000_000)}, time.Hour)
+		close(done)
+	}()
+	deadline := time.After(time.Second)
+	for pricer.Quote(product).OnChainMinPrice == "" {
+		select {
+		case <-deadline:
+			t.Fatal("on-chain minimum price was not read")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	if q := pricer.Quote(product); q.BaseMinPrice != "0.002" || q.EffectivePrice != "0.002" {
+		t.Errorf("Quote() = %+v, want on-chain floor", q)
+	}
+
+	// A lower on-chain price does not undercut the configured minimum
+	pricer.SetOnChainMinPrice(big.NewInt(1))
+	if q := pricer.Quote(product); q.BaseMinPrice != "0.001" {
+		t.Errorf("Quote() base = %s, want config minimum", q.BaseMinPrice)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	now := time.Now()
+	pricer := newTestPricer(t, &now)
+	product := "did:pandacea:earner:123/abc-456"
+	for i := 0; i < 3; i++ {
+		pricer.RecordLease(product)
+	}
+
+	if err := pricer.Reconfigure("not-a-price", config.PricingConfig{}); err == nil {
+		t.Fatal("Reconfigure() accepted an invalid minimum price")
+	}
+	if q := pricer.Quote(product); q.ConfigMinPrice != "0.001" {
+		t.Errorf("invalid Reconfigure() changed the minimum price to %s", q.ConfigMinPrice)
+	}
+
+	// Demand recorded before the change counts against the new threshold
+	err := pricer.Reconfigure("0.01", config.PricingConfig{
+		DemandWindowSeconds: 60,
+		DemandThreshold:     1,
+		DemandStep:          0.5,
+		MaxMultiplier:       3,
+	})
+	if err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if q := pricer.Quote(product); q.ConfigMinPrice != "0.01" || q.EffectivePrice != "0.02" {
+		t.Errorf("Quote() after Reconfigure() = %+v, want 0.02", q)
+	}
+}
+
+func TestRunSweepsWithoutReader(t *testing.T) {
+	now := time.Now()
+	pricer := newTestPricer(t, &now)
+	pricer.RecordLease("did:pandacea:earner:123/abc-456")
+	now = now.Add(2 * time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pricer.Run(ctx, nil, time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pricer.mu.Lock()
+		remaining := len(pricer.requests)
+		pricer.mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale demand history not swept without a min price reader")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
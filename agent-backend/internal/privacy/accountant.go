@@ -0,0 +1,197 @@
+package privacy
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultDPDelta is the delta used for (epsilon, delta) accounting of training runs
+const DefaultDPDelta = 1e-5
+
+// rdpOrders are the integer Rényi orders evaluated by the accountant
+var rdpOrders = []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 14, 16, 20, 24, 28, 32, 40, 48, 56, 64, 80, 96, 128, 256}
+
+// DPParameters describes the DP-SGD mechanism applied by a training run
+type DPParameters struct {
+	NoiseMultiplier float64
+	ClippingNorm    float64
+	SampleRate      float64
+	Steps           int
+	Delta           float64
+}
+
+// DPReport is the server-side privacy accounting of a training run
+type DPReport struct {
+	Accountant      string  `json:"accountant"`
+	NoiseMultiplier float64 `json:"noise_multiplier"`
+	ClippingNorm    float64 `json:"clipping_norm"`
+	SampleRate      float64 `json:"sample_rate"`
+	Steps           int     `json:"steps"`
+	Epsilon         float64 `json:"epsilon"`
+	Delta           float64 `json:"delta"`
+	RDPOrder        int     `json:"rdp_order"`
+	DeclaredEpsilon float64 `json:"declared_epsilon"`
+	WithinBudget    bool    `json:"within_budget"`
+}
+
+// Validate checks that the parameters describe a well-formed DP-SGD run
+func (p DPParameters) Validate() error {
+	if p.NoiseMultiplier <= 0 {
+		return fmt.Errorf("%w: noise multiplier must be positive", ErrInvalidDPParameters)
+	}
+	if p.ClippingNorm <= 0 {
+		return fmt.Errorf("%w: clipping norm must be positive", ErrInvalidDPParameters)
+	}
+	if p.SampleRate <= 0 || p.SampleRate > 1 {
+		return fmt.Errorf("%w: sample rate must be in (0, 1]", ErrInvalidDPParameters)
+	}
+	if p.Steps <= 0 {
+		return fmt.Errorf("%w: steps must be positive", ErrInvalidDPParameters)
+	}
+	if p.Delta <= 0 || p.Delta >= 1 {
+		return fmt.Errorf("%w: delta must be in (0, 1)", ErrInvalidDPParameters)
+	}
+	return nil
+}
+
+// ComputeEpsilon returns the epsilon spent by the sampled Gaussian mechanism
+// composed over p.Steps, using an RDP accountant, along with the optimal order
+func ComputeEpsilon(p DPParameters) (float64, int, error) {
+	if err := p.Validate(); err != nil {
+		return 0, 0, err
+	}
+
+	bestEps := math.Inf(1)
+	bestOrder := 0
+	for _, order := range rdpOrders {
+		rdp := sampledGaussianRDP(p.SampleRate, p.NoiseMultiplier, order)
+		eps := float64(p.Steps)*rdp + math.Log(1/p.Delta)/float64(order-1)
+		if eps < bestEps {
+			bestEps = eps
+			bestOrder = order
+		}
+	}
+
+	if math.IsInf(bestEps, 1) || math.IsNaN(bestEps) {
+		return 0, 0, fmt.Errorf("%w: epsilon is unbounded for the given parameters", ErrInvalidDPParameters)
+	}
+
+	return bestEps, bestOrder, nil
+}
+
+// CalibrateNoiseMultiplier finds the smallest noise multiplier that keeps a
+// run with the given sample rate and steps within targetEpsilon
+func CalibrateNoiseMultiplier(targetEpsilon, sampleRate float64, steps int, delta float64) (float64, error) {
+	if targetEpsilon <= 0 {
+		return 0, fmt.Errorf("%w: target epsilon must be positive", ErrInvalidDPParameters)
+	}
+
+	epsilonAt := func(sigma float64) (float64, error) {
+		eps, _, err := ComputeEpsilon(DPParameters{
+			NoiseMultiplier: sigma,
+			ClippingNorm:    1,
+			SampleRate:      sampleRate,
+			Steps:           steps,
+			Delta:           delta,
+		})
+		return eps, err
+	}
+
+	low, high := 0.01, 1000.0
+	eps, err := epsilonAt(high)
+	if err != nil {
+		return 0, err
+	}
+	if eps > targetEpsilon {
+		return 0, fmt.Errorf("%w: target epsilon %.4f is unreachable with %d steps", ErrBudgetExceeded, targetEpsilon, steps)
+	}
+
+	// Epsilon decreases monotonically with the noise multiplier
+	for i := 0; i < 100 && high-low > 1e-4; i++ {
+		mid := (low + high) / 2
+		eps, err := epsilonAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if eps > targetEpsilon {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return high, nil
+}
+
+// Err returns an error wrapping ErrBudgetExceeded if the run spent more than
+// its declared budget
+func (r *DPReport) Err() error {
+	if r.WithinBudget {
+		return nil
+	}
+	return fmt.Errorf("%w: epsilon %.4f > declared %.4f", ErrBudgetExceeded, r.Epsilon, r.DeclaredEpsilon)
+}
+
+// NewDPReport accounts for a training run and checks it against the declared budget
+func NewDPReport(p DPParameters, declaredEpsilon float64) (*DPReport, error) {
+	eps, order, err := ComputeEpsilon(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DPReport{
+		Accountant:      "rdp",
+		NoiseMultiplier: p.NoiseMultiplier,
+		ClippingNorm:    p.ClippingNorm,
+		SampleRate:      p.SampleRate,
+		Steps:           p.Steps,
+		Epsilon:         eps,
+		Delta:           p.Delta,
+		RDPOrder:        order,
+		DeclaredEpsilon: declaredEpsilon,
+		WithinBudget:    eps <= declaredEpsilon,
+	}, nil
+}
+
+// sampledGaussianRDP computes the RDP of the Poisson-sampled Gaussian mechanism
+// at an integer order (Mironov et al., 2019), evaluated in log space
+func sampledGaussianRDP(q, sigma float64, order int) float64 {
+	if q == 1 {
+		return float64(order) / (2 * sigma * sigma)
+	}
+
+	alpha := float64(order)
+	logA := math.Inf(-1)
+	for k := 0; k <= order; k++ {
+		kf := float64(k)
+		term := logBinomial(order, k) +
+			kf*math.Log(q) +
+			(alpha-kf)*math.Log1p(-q) +
+			(kf*kf-kf)/(2*sigma*sigma)
+		logA = logAddExp(logA, term)
+	}
+
+	return logA / (alpha - 1)
+}
+
+// logBinomial returns log(n choose k)
+func logBinomial(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// logAddExp returns log(exp(a) + exp(b)) without overflow
+func logAddExp(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
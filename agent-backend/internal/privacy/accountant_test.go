@@ -0,0 +1,122 @@
+package privacy
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestComputeEpsilonFullBatchGaussian(t *testing.T) {
+	// Without subsampling the RDP of the Gaussian mechanism is alpha/(2*sigma^2),
+	// so the accountant must match the closed form minimised over its orders
+	params := DPParameters{NoiseMultiplier: 4, ClippingNorm: 1, SampleRate: 1, Steps: 1, Delta: 1e-5}
+
+	want := math.Inf(1)
+	for _, order := range rdpOrders {
+		alpha := float64(order)
+		eps := alpha/(2*16) + math.Log(1/params.Delta)/(alpha-1)
+		want = math.Min(want, eps)
+	}
+
+	got, _, err := ComputeEpsilon(params)
+	if err != nil {
+		t.Fatalf("ComputeEpsilon() error = %v", err)
+	}
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("ComputeEpsilon() = %v, want %v", got, want)
+	}
+}
+
+func TestComputeEpsilonMonotonic(t *testing.T) {
+	base := DPParameters{NoiseMultiplier: 1.1, ClippingNorm: 1, SampleRate: 0.032, Steps: 310, Delta: 1e-5}
+
+	eps, _, err := ComputeEpsilon(base)
+	if err != nil {
+		t.Fatalf("ComputeEpsilon() error = %v", err)
+	}
+
+	moreNoise := base
+	moreNoise.NoiseMultiplier = 2
+	if e, _, _ := ComputeEpsilon(moreNoise); e >= eps {
+		t.Errorf("more noise should spend less epsilon: %v >= %v", e, eps)
+	}
+
+	moreSteps := base
+	moreSteps.Steps = 1000
+	if e, _, _ := ComputeEpsilon(moreSteps); e <= eps {
+		t.Errorf("more steps should spend more epsilon: %v <= %v", e, eps)
+	}
+
+	// Subsampling must amplify privacy relative to full-batch training
+	fullBatch := base
+	fullBatch.SampleRate = 1
+	if e, _, _ := ComputeEpsilon(fullBatch); e <= eps {
+		t.Errorf("subsampling should spend less epsilon: %v <= %v", eps, e)
+	}
+}
+
+func TestComputeEpsilonInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		params DPParameters
+	}{
+		{"zero noise", DPParameters{NoiseMultiplier: 0, ClippingNorm: 1, SampleRate: 0.1, Steps: 10, Delta: 1e-5}},
+		{"zero clip", DPParameters{NoiseMultiplier: 1, ClippingNorm: 0, SampleRate: 0.1, Steps: 10, Delta: 1e-5}},
+		{"sample rate above one", DPParameters{NoiseMultiplier: 1, ClippingNorm: 1, SampleRate: 1.5, Steps: 10, Delta: 1e-5}},
+		{"no steps", DPParameters{NoiseMultiplier: 1, ClippingNorm: 1, SampleRate: 0.1, Steps: 0, Delta: 1e-5}},
+		{"delta of one", DPParameters{NoiseMultiplier: 1, ClippingNorm: 1, SampleRate: 0.1, Steps: 10, Delta: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ComputeEpsilon(tt.params); !errors.Is(err, ErrInvalidDPParameters) {
+				t.Errorf("ComputeEpsilon() error = %v, want ErrInvalidDPParameters", err)
+			}
+		})
+	}
+}
+
+func TestCalibrateNoiseMultiplier(t *testing.T) {
+	for _, target := range []float64{0.5, 1, 2, 8} {
+		sigma, err := CalibrateNoiseMultiplier(target, 0.032, 310, DefaultDPDelta)
+		if err != nil {
+			t.Fatalf("CalibrateNoiseMultiplier(%v) error = %v", target, err)
+		}
+
+		report, err := NewDPReport(DPParameters{
+			NoiseMultiplier: sigma,
+			ClippingNorm:    1,
+			SampleRate:      0.032,
+			Steps:           310,
+			Delta:           DefaultDPDelta,
+		}, target)
+		if err != nil {
+			t.Fatalf("NewDPReport() error = %v", err)
+		}
+		if !report.WithinBudget {
+			t.Errorf("calibrated noise %v spends %v, over target %v", sigma, report.Epsilon, target)
+		}
+		if report.Epsilon < target*0.95 {
+			t.Errorf("calibrated noise %v is too conservative: %v for target %v", sigma, report.Epsilon, target)
+		}
+	}
+}
+
+func TestNewDPReportOverBudget(t *testing.T) {
+	report, err := NewDPReport(DPParameters{
+		NoiseMultiplier: 0.5,
+		ClippingNorm:    1,
+		SampleRate:      0.032,
+		Steps:           310,
+		Delta:           DefaultDPDelta,
+	}, 1.0)
+	if err != nil {
+		t.Fatalf("NewDPReport() error = %v", err)
+	}
+	if report.WithinBudget {
+		t.Errorf("expected report with epsilon %v to exceed declared budget", report.Epsilon)
+	}
+	if err := report.Err(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Err() = %v, want ErrBudgetExceeded", err)
+	}
+}
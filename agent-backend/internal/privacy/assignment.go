@@ -0,0 +1,141 @@
+package privacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Assignment transfers a lease's spender rights from one holder to the
+// next. It is signed off-chain by the holder giving up the lease; the
+// LeaseAgreement contract keeps its original spender.
+type Assignment struct {
+	LeaseID    string    `json:"lease_id"`
+	From       string    `json:"from"`                 // Holder giving up the lease
+	To         string    `json:"to"`                   // New holder
+	ToPeerID   string    `json:"to_peer_id,omitempty"` // Peer that receives the lease's events
+	Nonce      int       `json:"nonce"`                // Earlier assignments of the lease
+	Signature  string    `json:"signature"`            // From's personal_sign signature of AssignmentMessage
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// Message returns the text the assignment's signature covers
+func (a Assignment) Message() []byte {
+	return AssignmentMessage(a.LeaseID, a.From, a.To, a.ToPeerID, a.Nonce)
+}
+
+// AssignmentMessage returns the text a holder signs with personal_sign
+// (EIP-191) to assign a lease. The nonce is the number of earlier
+// assignments of the lease, so a signature cannot be replayed after the
+// lease moves on.
+func AssignmentMessage(leaseID, from, to, toPeerID string, nonce int) []byte {
+	return []byte(fmt.Sprintf("Pandacea lease assignment\nLease: %s\nFrom: %s\nTo: %s\nPeer: %s\nNonce: %d",
+		normalizeLeaseID(leaseID), strings.ToLower(from), strings.ToLower(to), toPeerID, nonce))
+}
+
+// normalizeLeaseID returns a bytes32 lease ID as lowercase 0x-prefixed hex
+func normalizeLeaseID(leaseID string) string {
+	return "0x" + strings.TrimPrefix(strings.ToLower(leaseID), "0x")
+}
+
+// AssignmentRegistry keeps the assignment history of each lease. The last
+// assignment names the lease's current holder.
+type AssignmentRegistry struct {
+	mu          sync.RWMutex
+	path        string
+	assignments map[string][]Assignment
+}
+
+// NewAssignmentRegistry creates a registry persisted to path, restoring the
+// assignments saved there. An empty path keeps assignments in memory only.
+func NewAssignmentRegistry(path string) (*AssignmentRegistry, error) {
+	r := &AssignmentRegistry{path: path, assignments: make(map[string][]Assignment)}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease assignments: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.assignments); err != nil {
+		return nil, fmt.Errorf("failed to parse lease assignments: %w", err)
+	}
+	return r, nil
+}
+
+// Holder returns the latest assignment of a lease, or false if it was never
+// assigned and its on-chain spender still holds it
+func (r *AssignmentRegistry) Holder(leaseID string) (Assignment, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := r.assignments[normalizeLeaseID(leaseID)]
+	if len(history) == 0 {
+		return Assignment{}, false
+	}
+	return history[len(history)-1], true
+}
+
+// History returns every assignment of a lease, oldest first
+func (r *AssignmentRegistry) History(leaseID string) []Assignment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Assignment(nil), r.assignments[normalizeLeaseID(leaseID)]...)
+}
+
+// Record appends an assignment whose signature the caller has verified. It
+// returns an error wrapping ErrStaleAssignment if the nonce does not follow
+// the lease's history, or ErrSpenderMismatch if From is not the holder named
+// by the last assignment. The first assignment's From must be checked
+// against the chain by the caller.
+func (r *AssignmentRegistry) Record(a Assignment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a.LeaseID = normalizeLeaseID(a.LeaseID)
+	history := r.assignments[a.LeaseID]
+	if a.Nonce != len(history) {
+		return fmt.Errorf("%w: lease %s has %d assignments, so the next nonce is %d", ErrStaleAssignment, a.LeaseID, len(history), len(history))
+	}
+	if len(history) > 0 && !strings.EqualFold(history[len(history)-1].To, a.From) {
+		return fmt.Errorf("%w: lease %s is held by %s", ErrSpenderMismatch, a.LeaseID, history[len(history)-1].To)
+	}
+
+	r.assignments[a.LeaseID] = append(history, a)
+	if err := r.save(); err != nil {
+		r.assignments[a.LeaseID] = history
+		return err
+	}
+	return nil
+}
+
+// save writes the assignments to disk. Caller must hold mu.
+func (r *AssignmentRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.assignments)
+	if err != nil {
+		return fmt.Errorf("failed to encode lease assignments: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create lease assignments directory: %w", err)
+	}
+	if err := fsutil.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write lease assignments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package privacy
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignmentRegistryChainsHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assignments.json")
+	registry, err := NewAssignmentRegistry(path)
+	if err != nil {
+		t.Fatalf("NewAssignmentRegistry() error = %v", err)
+	}
+
+	const alice, bob, carol = "0xAAaa000000000000000000000000000000000001", "0xbbbb000000000000000000000000000000000002", "0xcccc000000000000000000000000000000000003"
+	if _, assigned := registry.Holder("0xABCD"); assigned {
+		t.Fatal("Holder() reported an assignment before any was recorded")
+	}
+	if err := registry.Record(Assignment{LeaseID: "0xABCD", From: alice, To: bob, Nonce: 0}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// The same signature cannot be recorded twice
+	if err := registry.Record(Assignment{LeaseID: "abcd", From: alice, To: bob, Nonce: 0}); !errors.Is(err, ErrStaleAssignment) {
+		t.Errorf("Record() replay error = %v, want ErrStaleAssignment", err)
+	}
+	// Only the current holder can assign the lease onwards
+	if err := registry.Record(Assignment{LeaseID: "0xabcd", From: alice, To: carol, Nonce: 1}); !errors.Is(err, ErrSpenderMismatch) {
+		t.Errorf("Record() from a previous holder error = %v, want ErrSpenderMismatch", err)
+	}
+	if err := registry.Record(Assignment{LeaseID: "0xabcd", From: bob, To: carol, Nonce: 1}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	restored, err := NewAssignmentRegistry(path)
+	if err != nil {
+		t.Fatalf("NewAssignmentRegistry() restore error = %v", err)
+	}
+	holder, assigned := restored.Holder("0xABCD")
+	if !assigned || holder.To != carol {
+		t.Errorf("Holder() = %+v, %v, want %s", holder, assigned, carol)
+	}
+	if history := restored.History("abcd"); len(history) != 2 || history[0].To != bob {
+		t.Errorf("History() = %+v, want the assignments to bob and then carol", history)
+	}
+}
+
+func TestAssignmentMessageNormalizesIdentifiers(t *testing.T) {
+	a := AssignmentMessage("ABCD", "0xAA", "0xBB", "peer", 1)
+	b := AssignmentMessage("0xabcd", "0xaa", "0xbb", "peer", 1)
+	if string(a) != string(b) {
+		t.Errorf("messages differ:\n%s\n%s", a, b)
+	}
+	if string(a) == string(AssignmentMessage("0xabcd", "0xaa", "0xbb", "peer", 2)) {
+		t.Error("message does not cover the nonce")
+	}
+}
@@ -0,0 +1,103 @@
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pandacea/agent-backend/internal/attest"
+)
+
+// AttestComputations implements ComputationAttester
+func (ps *privacyService) AttestComputations(fn func(attest.Statement)) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.onAttest = fn
+}
+
+// attestJob states what a completed computation ran on, if attestation is
+// enabled. digest is the hash of its result before watermarking, which a
+// re-execution reproduces.
+func (ps *privacyService) attestJob(computationID string, req *ComputationRequest, run *execution, digest string) {
+	ps.jobsMutex.RLock()
+	onAttest := ps.onAttest
+	ps.jobsMutex.RUnlock()
+	if onAttest == nil {
+		return
+	}
+	onAttest(attest.Statement{
+		Version:       attest.Version,
+		ComputationID: computationID,
+		LeaseID:       req.LeaseID,
+		ScriptCID:     req.ComputationCid,
+		ScriptSHA256:  run.scriptSHA256,
+		Inputs:        run.inputs,
+		ImageDigest:   run.imageDigest,
+		OutputSHA256:  digest,
+		StartedAt:     run.startedAt,
+		FinishedAt:    run.finishedAt,
+	})
+}
+
+// attestedInputs hashes the files a computation is given in dataDir, and
+// names the IPFS CID of those registered from IPFS. Inputs that cannot be
+// read are listed without a hash.
+func (ps *privacyService) attestedInputs(dataDir string, inputs []mountedInput) []attest.Input {
+	registry := ps.registry()
+	attested := make([]attest.Input, len(inputs))
+	for i, input := range inputs {
+		attested[i].AssetID = input.AssetID
+		if registry != nil {
+			if asset, ok := registry.Get(input.AssetID); ok {
+				if cid, ok := strings.CutPrefix(asset.Source, "ipfs://"); ok {
+					attested[i].CID = cid
+				}
+			}
+		}
+		hash, err := fileSHA256(filepath.Join(dataDir, input.file))
+		if err != nil {
+			ps.logger.Warn("failed to hash computation input", "asset_id", input.AssetID, "error", err)
+			continue
+		}
+		attested[i].SHA256 = hash
+	}
+	return attested
+}
+
+// imageDigest returns the digest of the image container runs, or "" if the
+// runtime cannot report it
+func (ps *privacyService) imageDigest(container *DockerContainer) string {
+	digester, ok := ps.runtime.(ImageDigester)
+	if !ok {
+		return ""
+	}
+	digest, err := digester.ImageDigest(container.ID)
+	if err != nil {
+		ps.logger.Warn("failed to read sandbox image digest", "container_id", container.ID, "error", err)
+		return ""
+	}
+	return digest
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
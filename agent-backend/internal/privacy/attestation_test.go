@@ -0,0 +1,73 @@
+package privacy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pandacea/agent-backend/internal/attest"
+)
+
+func TestAttestJob(t *testing.T) {
+	script := "print(sum(df))"
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, script)
+	}))
+	defer ipfs.Close()
+
+	dataDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, "sales.csv"), []byte("a\n1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var runs int
+	var env []string
+	ps := &privacyService{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		runtime:       replayRuntime{outputs: []string{"42"}, runs: &runs, env: &env},
+		httpClient:    ipfs.Client(),
+		ipfsAPIURL:    ipfs.URL,
+		dataDir:       dataDir,
+		containerPool: make(chan *DockerContainer, 1),
+		poolSize:      1,
+		live:          1,
+	}
+	ps.containerPool <- &DockerContainer{ID: "sandbox-1", IsActive: true}
+	req := &ComputationRequest{LeaseID: "lease-1", ComputationCid: "QmScript", Inputs: []DataInput{{AssetID: "sales", VariableName: "df"}}}
+
+	run, err := ps.runComputation(context.Background(), "comp-1", req)
+	if err != nil {
+		t.Fatalf("runComputation() error = %v", err)
+	}
+	digest := resultDigest(run.output, run.artifacts)
+
+	// Nothing is attested unless attestation is on
+	ps.attestJob("comp-1", req, run, digest)
+
+	var statements []attest.Statement
+	ps.AttestComputations(func(s attest.Statement) { statements = append(statements, s) })
+	ps.attestJob("comp-1", req, run, digest)
+	if len(statements) != 1 {
+		t.Fatalf("attested %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.Version != attest.Version || s.ComputationID != "comp-1" || s.LeaseID != "lease-1" || s.ScriptCID != "QmScript" {
+		t.Errorf("statement = %+v", s)
+	}
+	if s.ScriptSHA256 != sha256Hex([]byte(script)) || s.OutputSHA256 != digest {
+		t.Errorf("statement hashes script %s and output %s", s.ScriptSHA256, s.OutputSHA256)
+	}
+	if len(s.Inputs) != 1 || s.Inputs[0].AssetID != "sales" || s.Inputs[0].SHA256 != sha256Hex([]byte("a\n1\n")) || s.Inputs[0].CID != "" {
+		t.Errorf("statement inputs = %+v", s.Inputs)
+	}
+	if s.ImageDigest != "" {
+		t.Errorf("image digest %q from a runtime that reports none", s.ImageDigest)
+	}
+	if s.StartedAt.IsZero() || s.FinishedAt.Before(s.StartedAt) {
+		t.Errorf("statement ran from %v to %v", s.StartedAt, s.FinishedAt)
+	}
+}
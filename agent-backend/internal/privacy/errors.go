@@ -0,0 +1,25 @@
+package privacy
+
+import "errors"
+
+// Errors returned by the privacy service and accountant. Callers branch on
+// them with errors.Is; the wrapped messages carry the details.
+var (
+	ErrInvalidRequest      = errors.New("invalid computation request")
+	ErrInvalidLeaseID      = errors.New("invalid lease ID format")
+	ErrLeaseNotFound       = errors.New("lease does not exist")
+	ErrLeaseNotApproved    = errors.New("lease is not approved")
+	ErrLeaseExecuted       = errors.New("lease has already been executed")
+	ErrLeaseDisputed       = errors.New("lease is disputed")
+	ErrSpenderMismatch     = errors.New("spender address mismatch")
+	ErrPurposeMismatch     = errors.New("computation purpose does not match the lease")
+	ErrComputationNotFound = errors.New("computation job not found")
+	ErrPoolExhausted       = errors.New("no container available in pool")
+	ErrInvalidDPParameters = errors.New("invalid DP parameters")
+	ErrBudgetExceeded      = errors.New("privacy budget exceeded")
+	ErrStaleAssignment     = errors.New("lease assignment is out of date")
+	ErrUnknownNetwork      = errors.New("blockchain network is not configured")
+	ErrImageNotAllowed     = errors.New("sandbox image is not allowed")
+	ErrImageDigest         = errors.New("sandbox image digest does not match")
+	ErrImageSignature      = errors.New("sandbox image signature is not valid")
+)
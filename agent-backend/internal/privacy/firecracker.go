@@ -0,0 +1,88 @@
+package privacy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FirecrackerRuntime runs computations in Firecracker microVMs with the
+// ignite CLI. Each VM boots its own kernel from the sandbox image, so a
+// computation that escapes Python still has to escape a hypervisor.
+//
+// ignite cannot start a VM without a network, so computations are refused
+// on this runtime until it can; see NetworkedRuntime. ignite itself is no
+// longer maintained.
+type FirecrackerRuntime struct {
+	Image       string  // Defaults to pandacea/pysyft-datasite:latest
+	KernelImage string  // Empty uses ignite's default kernel
+	MemoryMB    int     // Defaults to 512
+	CPUs        float64 // Rounded up to whole vCPUs; defaults to 1
+}
+
+// Create implements ContainerRuntime. The VM's name is its ID.
+func (f FirecrackerRuntime) Create() (string, error) {
+	image := f.Image
+	if image == "" {
+		image = defaultSandboxImage
+	}
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to name microVM: %w", err)
+	}
+	name := "pandacea-" + hex.EncodeToString(suffix)
+
+	memoryMB, cpus := sandboxLimits(f.MemoryMB, f.CPUs)
+	args := []string{"run", image,
+		"--name", name,
+		"--cpus", strconv.Itoa(int(math.Ceil(cpus))),
+		"--memory", fmt.Sprintf("%dMB", memoryMB),
+		"--ssh", // ignite exec and cp reach the VM over SSH
+	}
+	if f.KernelImage != "" {
+		args = append(args, "--kernel-image", f.KernelImage)
+	}
+	if output, err := exec.Command("ignite", args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to create microVM: %w, output: %s", err, string(output))
+	}
+	return name, nil
+}
+
+// AlwaysNetworked implements NetworkedRuntime. ignite attaches every VM
+// to its CNI bridge, and exec and cp reach the VM over SSH on it, so there
+// is no way to start one isolated.
+func (FirecrackerRuntime) AlwaysNetworked() bool {
+	return true
+}
+
+// Remove implements ContainerRuntime
+func (FirecrackerRuntime) Remove(id string) error {
+	return exec.Command("ignite", "rm", "-f", id).Run()
+}
+
+// Clean implements ContainerRuntime
+func (FirecrackerRuntime) Clean(id string) error {
+	return exec.Command("ignite", "exec", id, "rm -rf /workspace/* /data/*").Run()
+}
+
+// CopyTo implements ContainerRuntime
+func (FirecrackerRuntime) CopyTo(id, srcPath, destPath string) error {
+	return exec.Command("ignite", "cp", srcPath, id+":"+destPath).Run()
+}
+
+// Exec implements ContainerRuntime. ignite runs the command through the
+// VM's shell, so the trace context is set with env and each argument is
+// quoted.
+func (FirecrackerRuntime) Exec(ctx context.Context, id string, args ...string) ([]byte, error) {
+	command := append([]string{"env"}, sandboxEnviron(ctx)...)
+	command = append(command, args...)
+	for i, arg := range command {
+		command[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return exec.Command("ignite", "exec", id, strings.Join(command, " ")).CombinedOutput()
+}
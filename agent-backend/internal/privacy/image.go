@@ -0,0 +1,145 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// digestPattern matches the content digest in a pinned image reference
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// ImagePolicy restricts the images sandboxes run to ones pinned by digest,
+// since a tag such as latest can be moved to other content at any time
+type ImagePolicy struct {
+	// Allowed are the images sandboxes may run, as repository@sha256:digest
+	Allowed []string
+
+	// CosignKey is the public key the image must be signed with; empty
+	// skips signature verification. Cosign is the cosign binary, which
+	// defaults to cosign on the PATH.
+	CosignKey string
+	Cosign    string
+
+	// repoDigests and verifySignature default to the docker and cosign CLIs
+	repoDigests     func(ctx context.Context, image string) ([]string, error)
+	verifySignature func(ctx context.Context, key, ref string) error
+}
+
+// ParseImageRef splits an image reference into its repository, tag and
+// digest, any of the last two of which may be empty
+func ParseImageRef(ref string) (repository, tag, digest string) {
+	repository = ref
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	// A colon after the last slash separates the tag; one before it is
+	// a registry port
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+// ValidatePinnedImage checks that ref is a repository@sha256:digest reference
+func ValidatePinnedImage(ref string) error {
+	repository, tag, digest := ParseImageRef(ref)
+	if repository == "" || tag != "" || !digestPattern.MatchString(digest) {
+		return fmt.Errorf("%q is not pinned (want repository@sha256:<64 hex digits>)", ref)
+	}
+	return nil
+}
+
+// normalizeRepository names Docker Hub repositories the same way whether or
+// not they are written with their registry and library/ prefix
+func normalizeRepository(repository string) string {
+	for _, prefix := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		repository = strings.TrimPrefix(repository, prefix)
+	}
+	return strings.TrimPrefix(repository, "library/")
+}
+
+// Verify checks image against the policy before sandboxes are created from
+// it: its repository must be allowed, the image pulled locally must have an
+// allowed digest and, with a CosignKey, that digest must be signed. It
+// returns the image pinned to its digest, which sandboxes should run so
+// the tag cannot be moved under the pool.
+func (p ImagePolicy) Verify(ctx context.Context, image string) (string, error) {
+	if image == "" {
+		image = defaultSandboxImage
+	}
+	repository, _, digest := ParseImageRef(image)
+	allowed := make(map[string]bool)
+	for _, ref := range p.Allowed {
+		allowedRepository, _, allowedDigest := ParseImageRef(ref)
+		if normalizeRepository(allowedRepository) == normalizeRepository(repository) {
+			allowed[allowedDigest] = true
+		}
+	}
+	if len(allowed) == 0 {
+		return "", fmt.Errorf("%w: %s is not in the allowed images", ErrImageNotAllowed, repository)
+	}
+	if digest != "" && !allowed[digest] {
+		return "", fmt.Errorf("%w: %s is pinned to %s, which is not allowed", ErrImageNotAllowed, repository, digest)
+	}
+
+	repoDigests := p.repoDigests
+	if repoDigests == nil {
+		repoDigests = dockerRepoDigests
+	}
+	local, err := repoDigests(ctx, image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect sandbox image %s: %w", image, err)
+	}
+	pinned := ""
+	for _, ref := range local {
+		localRepository, _, localDigest := ParseImageRef(ref)
+		if normalizeRepository(localRepository) == normalizeRepository(repository) && allowed[localDigest] {
+			pinned = repository + "@" + localDigest
+			break
+		}
+	}
+	if pinned == "" {
+		return "", fmt.Errorf("%w: %s has digests %v, none of them allowed", ErrImageDigest, image, local)
+	}
+
+	if p.CosignKey != "" {
+		verifySignature := p.verifySignature
+		if verifySignature == nil {
+			verifySignature = p.cosignVerify
+		}
+		if err := verifySignature(ctx, p.CosignKey, pinned); err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrImageSignature, pinned, err)
+		}
+	}
+	return pinned, nil
+}
+
+// dockerRepoDigests returns the repository@digest references of a local image
+func dockerRepoDigests(ctx context.Context, image string) ([]string, error) {
+	output, err := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", image).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	var digests []string
+	if err := json.Unmarshal(output, &digests); err != nil {
+		return nil, fmt.Errorf("unexpected docker output: %w", err)
+	}
+	return digests, nil
+}
+
+// cosignVerify checks ref's signature with the cosign CLI
+func (p ImagePolicy) cosignVerify(ctx context.Context, key, ref string) error {
+	cosign := p.Cosign
+	if cosign == "" {
+		cosign = "cosign"
+	}
+	output, err := exec.CommandContext(ctx, cosign, "verify", "--key", key, ref).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImagePolicy(t *testing.T) {
+	good := "sha256:" + strings.Repeat("a", 64)
+	moved := "sha256:" + strings.Repeat("b", 64)
+	local := map[string][]string{
+		"pandacea/pysyft-datasite:latest": {"docker.io/pandacea/pysyft-datasite@" + good},
+		"pandacea/pysyft-datasite:next":   {"pandacea/pysyft-datasite@" + moved},
+	}
+	var signed []string
+	policy := ImagePolicy{
+		Allowed: []string{"docker.io/pandacea/pysyft-datasite@" + good},
+		repoDigests: func(_ context.Context, image string) ([]string, error) {
+			if digests, ok := local[image]; ok {
+				return digests, nil
+			}
+			return nil, errors.New("no such image")
+		},
+	}
+
+	pinned, err := policy.Verify(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Verify(default image) error = %v", err)
+	}
+	if want := "pandacea/pysyft-datasite@" + good; pinned != want {
+		t.Errorf("Verify() = %q, want %q", pinned, want)
+	}
+
+	tests := []struct {
+		image string
+		want  error
+	}{
+		{"pandacea/pysyft-datasite:next", ErrImageDigest},
+		{"pandacea/pysyft-datasite@" + moved, ErrImageNotAllowed},
+		{"evil/datasite:latest", ErrImageNotAllowed},
+	}
+	for _, tt := range tests {
+		if _, err := policy.Verify(context.Background(), tt.image); !errors.Is(err, tt.want) {
+			t.Errorf("Verify(%q) error = %v, want %v", tt.image, err, tt.want)
+		}
+	}
+
+	// With a key the pinned digest must be signed
+	policy.CosignKey = "cosign.pub"
+	policy.verifySignature = func(_ context.Context, key, ref string) error {
+		signed = append(signed, ref)
+		return errors.New("no matching signatures")
+	}
+	if _, err := policy.Verify(context.Background(), "pandacea/pysyft-datasite:latest"); !errors.Is(err, ErrImageSignature) {
+		t.Errorf("Verify(unsigned) error = %v, want ErrImageSignature", err)
+	}
+	if len(signed) != 1 || signed[0] != pinned {
+		t.Errorf("cosign verified %v, want the pinned image %s", signed, pinned)
+	}
+}
+
+func TestValidatePinnedImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0", 64)
+	for ref, valid := range map[string]bool{
+		"pandacea/pysyft-datasite@" + digest:              true,
+		"registry.local:5000/pysyft-datasite@" + digest:   true,
+		"pandacea/pysyft-datasite:latest":                 false,
+		"pandacea/pysyft-datasite:1.0@" + digest:          false,
+		"pandacea/pysyft-datasite@sha256:" + "0123456789": false,
+	} {
+		if err := ValidatePinnedImage(ref); (err == nil) != valid {
+			t.Errorf("ValidatePinnedImage(%q) error = %v, want valid %v", ref, err, valid)
+		}
+	}
+}
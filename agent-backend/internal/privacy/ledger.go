@@ -0,0 +1,211 @@
+package privacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// budgetTolerance absorbs floating point error when summing epsilons
+const budgetTolerance = 1e-9
+
+// Budget is the privacy budget of one dataset. Reserved epsilon belongs to
+// jobs that have not finished; it counts against the budget until they do.
+type Budget struct {
+	Dataset   string  `json:"dataset"`
+	Limited   bool    `json:"limited"`   // False if the dataset has no cap
+	Epsilon   float64 `json:"epsilon"`   // Total budget, 0 if not limited
+	Spent     float64 `json:"spent"`     // Epsilon of finished jobs
+	Reserved  float64 `json:"reserved"`  // Epsilon held by running jobs
+	Remaining float64 `json:"remaining"` // Epsilon left for new jobs, 0 if not limited
+	Jobs      int     `json:"jobs"`      // Jobs charged to the dataset
+}
+
+// charge is the persisted epsilon held or spent by one job
+type charge struct {
+	Dataset   string    `json:"dataset"`
+	Epsilon   float64   `json:"epsilon"`
+	Settled   bool      `json:"settled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ledgerState is the on-disk format
+type ledgerState struct {
+	Charges map[string]*charge `json:"charges"`
+}
+
+// BudgetLedger accounts the cumulative epsilon spent against each dataset
+// under sequential composition. Jobs reserve their declared epsilon before
+// they run, settle at the accounted epsilon when they complete and release
+// the reservation if they fail, since a failed job's output is never served.
+type BudgetLedger struct {
+	mu            sync.Mutex
+	defaultBudget float64
+	budgets       map[string]float64
+	path          string
+	state         ledgerState
+	now           func() time.Time
+}
+
+// NewBudgetLedger creates a ledger. Datasets without an entry in budgets get
+// defaultBudget; a budget of 0 tracks spend without capping it. Charges are
+// persisted to path unless it is empty.
+func NewBudgetLedger(defaultBudget float64, budgets map[string]float64, path string) (*BudgetLedger, error) {
+	if defaultBudget < 0 {
+		return nil, fmt.Errorf("%w: default epsilon budget must not be negative", ErrInvalidDPParameters)
+	}
+	for dataset, budget := range budgets {
+		if budget < 0 {
+			return nil, fmt.Errorf("%w: epsilon budget for %q must not be negative", ErrInvalidDPParameters, dataset)
+		}
+	}
+
+	l := &BudgetLedger{
+		defaultBudget: defaultBudget,
+		budgets:       budgets,
+		path:          path,
+		state:         ledgerState{Charges: make(map[string]*charge)},
+		now:           time.Now,
+	}
+
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read privacy budget ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.state); err != nil {
+		return nil, fmt.Errorf("failed to parse privacy budget ledger: %w", err)
+	}
+	if l.state.Charges == nil {
+		l.state.Charges = make(map[string]*charge)
+	}
+
+	return l, nil
+}
+
+// Reserve holds epsilon of the dataset's budget for a job. It returns an
+// error wrapping ErrBudgetExceeded if the job would overdraw the budget, or
+// if the dataset is capped and the job does not use differential privacy.
+// Reserving again for the same job is a no-op.
+func (l *BudgetLedger) Reserve(dataset, jobID string, epsilon float64) error {
+	if epsilon < 0 {
+		return fmt.Errorf("%w: epsilon must not be negative", ErrInvalidDPParameters)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.state.Charges[jobID]; exists {
+		return nil
+	}
+
+	budget := l.budget(dataset)
+	if budget.Limited {
+		if epsilon == 0 {
+			return fmt.Errorf("%w: dataset %q has a privacy budget, so training on it must use differential privacy", ErrBudgetExceeded, dataset)
+		}
+		if epsilon > budget.Remaining+budgetTolerance {
+			return fmt.Errorf("%w: dataset %q has epsilon %.4f of %.4f remaining, job needs %.4f",
+				ErrBudgetExceeded, dataset, budget.Remaining, budget.Epsilon, epsilon)
+		}
+	}
+
+	l.state.Charges[jobID] = &charge{Dataset: dataset, Epsilon: epsilon, CreatedAt: l.now()}
+	if err := l.save(); err != nil {
+		delete(l.state.Charges, jobID)
+		return err
+	}
+	return nil
+}
+
+// Settle charges a finished job the epsilon it actually spent, which may be
+// less than it reserved. Unknown jobs are ignored.
+func (l *BudgetLedger) Settle(jobID string, epsilon float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, exists := l.state.Charges[jobID]
+	if !exists || c.Settled {
+		return nil
+	}
+	c.Epsilon = epsilon
+	c.Settled = true
+	return l.save()
+}
+
+// Release returns a job's unsettled reservation to its dataset's budget.
+// Unknown and settled jobs are ignored.
+func (l *BudgetLedger) Release(jobID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, exists := l.state.Charges[jobID]
+	if !exists || c.Settled {
+		return nil
+	}
+	delete(l.state.Charges, jobID)
+	return l.save()
+}
+
+// Budget returns the dataset's current budget
+func (l *BudgetLedger) Budget(dataset string) Budget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.budget(dataset)
+}
+
+// budget totals the charges against a dataset. Caller must hold l.mu.
+func (l *BudgetLedger) budget(dataset string) Budget {
+	limit, ok := l.budgets[dataset]
+	if !ok {
+		limit = l.defaultBudget
+	}
+
+	b := Budget{Dataset: dataset, Limited: limit > 0, Epsilon: limit}
+	for _, c := range l.state.Charges {
+		if c.Dataset != dataset {
+			continue
+		}
+		b.Jobs++
+		if c.Settled {
+			b.Spent += c.Epsilon
+		} else {
+			b.Reserved += c.Epsilon
+		}
+	}
+	if b.Limited {
+		b.Remaining = max(0, limit-b.Spent-b.Reserved)
+	}
+	return b
+}
+
+// save writes the ledger to disk. Caller must hold l.mu.
+func (l *BudgetLedger) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode privacy budget ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create privacy budget ledger directory: %w", err)
+	}
+	if err := fsutil.WriteFile(l.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write privacy budget ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package privacy
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestBudgetLedgerEnforcesBudget(t *testing.T) {
+	ledger, err := NewBudgetLedger(0, map[string]float64{"mnist": 3}, "")
+	if err != nil {
+		t.Fatalf("NewBudgetLedger() error = %v", err)
+	}
+
+	if err := ledger.Reserve("mnist", "job_1", 2); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := ledger.Reserve("mnist", "job_2", 1.5); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Reserve() over budget error = %v, want ErrBudgetExceeded", err)
+	}
+	if err := ledger.Reserve("mnist", "job_3", 0); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Reserve() without DP error = %v, want ErrBudgetExceeded", err)
+	}
+
+	// Settling at the accounted epsilon refunds the unspent reservation
+	if err := ledger.Settle("job_1", 1.25); err != nil {
+		t.Fatalf("Settle() error = %v", err)
+	}
+	if err := ledger.Reserve("mnist", "job_2", 1.5); err != nil {
+		t.Fatalf("Reserve() after settling error = %v", err)
+	}
+
+	budget := ledger.Budget("mnist")
+	if budget.Spent != 1.25 || budget.Reserved != 1.5 || math.Abs(budget.Remaining-0.25) > 1e-9 || budget.Jobs != 2 {
+		t.Errorf("Budget() = %+v, want 1.25 spent, 1.5 reserved, 0.25 remaining", budget)
+	}
+
+	// A failed job's reservation is returned; a settled charge is not
+	ledger.Release("job_2")
+	ledger.Release("job_1")
+	if budget := ledger.Budget("mnist"); budget.Reserved != 0 || budget.Spent != 1.25 || budget.Remaining != 1.75 {
+		t.Errorf("Budget() after release = %+v, want 1.75 remaining", budget)
+	}
+
+	// Datasets without a budget are tracked but not capped
+	if err := ledger.Reserve("cifar", "job_4", 50); err != nil {
+		t.Fatalf("Reserve() on an uncapped dataset error = %v", err)
+	}
+	if budget := ledger.Budget("cifar"); budget.Limited || budget.Reserved != 50 {
+		t.Errorf("Budget() of an uncapped dataset = %+v", budget)
+	}
+}
+
+func TestBudgetLedgerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "privacy", "budget.json")
+	ledger, err := NewBudgetLedger(4, nil, path)
+	if err != nil {
+		t.Fatalf("NewBudgetLedger() error = %v", err)
+	}
+	if err := ledger.Reserve("mnist", "job_1", 1); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := ledger.Settle("job_1", 0.8); err != nil {
+		t.Fatalf("Settle() error = %v", err)
+	}
+	if err := ledger.Reserve("mnist", "job_2", 2); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+
+	reloaded, err := NewBudgetLedger(4, nil, path)
+	if err != nil {
+		t.Fatalf("NewBudgetLedger() reload error = %v", err)
+	}
+	budget := reloaded.Budget("mnist")
+	if budget.Spent != 0.8 || budget.Reserved != 2 || math.Abs(budget.Remaining-1.2) > 1e-9 {
+		t.Errorf("Budget() after reload = %+v, want 0.8 spent and 2 reserved", budget)
+	}
+	if err := reloaded.Reserve("mnist", "job_3", 1.5); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Reserve() after reload error = %v, want ErrBudgetExceeded", err)
+	}
+}
@@ -0,0 +1,243 @@
+package privacy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pandacea/agent-backend/internal/assets"
+)
+
+// loaderChunkRows is how many rows of a CSV or JSONL input are held at
+// once while rows are selected from it
+const loaderChunkRows = 100000
+
+// pandasReaders load each asset format into a DataFrame
+var pandasReaders = map[assets.Format]string{
+	assets.FormatCSV:     "pd.read_csv(data_path)",
+	assets.FormatJSONL:   "pd.read_json(data_path, lines=True)",
+	assets.FormatJSON:    "pd.read_json(data_path)",
+	assets.FormatParquet: "pd.read_parquet(data_path)",
+	assets.FormatArrow:   "pd.read_feather(data_path)",
+}
+
+// datasetFormats name the binary formats for pyarrow.dataset, which reads
+// only the columns and row groups a selection needs
+var datasetFormats = map[assets.Format]string{
+	assets.FormatParquet: "parquet",
+	assets.FormatArrow:   "ipc",
+}
+
+// partitionFilter selects the rows whose column holds one of values, as
+// Python literals
+type partitionFilter struct {
+	column string
+	values []string
+}
+
+// validateSelection checks the columns and partitions an input selects
+// against the asset it reads
+func validateSelection(input DataInput, asset assets.Asset) error {
+	for i, column := range input.Columns {
+		if column == "" || slices.Contains(input.Columns[:i], column) {
+			return fmt.Errorf("%w: columns of %s must be unique and not empty", ErrInvalidRequest, input.AssetID)
+		}
+		if _, ok := asset.Column(column); !ok && len(asset.Schema) > 0 {
+			return fmt.Errorf("%w: data asset %s has no column %s", ErrInvalidRequest, input.AssetID, column)
+		}
+	}
+	for column, values := range input.Partitions {
+		if !slices.Contains(asset.Partitions, column) {
+			return fmt.Errorf("%w: %s is not a partition column of data asset %s", ErrInvalidRequest, column, input.AssetID)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("%w: partition %s of %s selects no values", ErrInvalidRequest, column, input.AssetID)
+		}
+		col, _ := asset.Column(column)
+		for _, value := range values {
+			if _, err := pyLiteral(value, col.Type); err != nil {
+				return fmt.Errorf("%w: partition %s of %s: %v", ErrInvalidRequest, column, input.AssetID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadStatements returns the Python statements that load an input from
+// data_path into its variable. Selected columns and partitions are pushed
+// into the reader where the format allows it, so the container never holds
+// more of the asset than the computation asked for.
+func loadStatements(input mountedInput) []string {
+	v := input.VariableName
+	filters := input.filters()
+	if len(input.Columns) == 0 && len(filters) == 0 {
+		return []string{v + " = " + pandasReaders[input.format]}
+	}
+	columns := pyStrings(input.Columns)
+
+	switch input.format {
+	case assets.FormatParquet, assets.FormatArrow:
+		var args []string
+		if len(input.Columns) > 0 {
+			args = append(args, "columns="+columns)
+		}
+		if len(filters) > 0 {
+			args = append(args, "filter="+mask(filters, func(column string) string { return "ds.field(" + pyString(column) + ")" }))
+		}
+		return []string{
+			"import pyarrow.dataset as ds",
+			fmt.Sprintf("%s = ds.dataset(data_path, format='%s', partitioning='hive').to_table(%s).to_pandas()",
+				v, datasetFormats[input.format], strings.Join(args, ", ")),
+		}
+
+	case assets.FormatCSV, assets.FormatJSONL:
+		reader := fmt.Sprintf("pd.read_json(data_path, lines=True, chunksize=%d)", loaderChunkRows)
+		if input.format == assets.FormatCSV {
+			var args []string
+			if len(input.Columns) > 0 {
+				read := slices.Clone(input.Columns)
+				for _, f := range filters {
+					if !slices.Contains(read, f.column) {
+						read = append(read, f.column)
+					}
+				}
+				args = append(args, "usecols="+pyStrings(read))
+			}
+			if dtypes := input.textPartitions(filters); dtypes != "" {
+				args = append(args, "dtype="+dtypes)
+			}
+			if len(filters) == 0 {
+				// Reading only the columns asked for needs no chunks
+				return []string{fmt.Sprintf("%s = pd.read_csv(data_path, %s)[%s]", v, strings.Join(args, ", "), columns)}
+			}
+			args = append(args, fmt.Sprintf("chunksize=%d", loaderChunkRows))
+			reader = "pd.read_csv(data_path, " + strings.Join(args, ", ") + ")"
+		}
+		selected := "chunk"
+		if len(filters) > 0 {
+			selected += "[" + mask(filters, func(column string) string { return "chunk[" + pyString(column) + "]" }) + "]"
+		}
+		if len(input.Columns) > 0 {
+			selected += "[" + columns + "]"
+		}
+		return []string{fmt.Sprintf("%s = pd.concat([%s for chunk in %s], ignore_index=True)", v, selected, reader)}
+
+	default:
+		// A JSON array is parsed whole before rows can be selected
+		statements := []string{v + " = " + pandasReaders[input.format]}
+		if len(filters) > 0 {
+			statements = append(statements, fmt.Sprintf("%s = %s[%s]", v, v,
+				mask(filters, func(column string) string { return v + "[" + pyString(column) + "]" })))
+		}
+		if len(input.Columns) > 0 {
+			statements = append(statements, fmt.Sprintf("%s = %s[%s]", v, v, columns))
+		}
+		return statements
+	}
+}
+
+// filters returns the input's partition selection ordered by column, with
+// values typed by the asset's schema
+func (input mountedInput) filters() []partitionFilter {
+	filters := make([]partitionFilter, 0, len(input.Partitions))
+	for column, values := range input.Partitions {
+		f := partitionFilter{column: column}
+		for _, value := range values {
+			literal, err := pyLiteral(value, input.columnType(column))
+			if err != nil {
+				// validateSelection refused it; compare it as text
+				literal = pyString(value)
+			}
+			f.values = append(f.values, literal)
+		}
+		filters = append(filters, f)
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].column < filters[j].column })
+	return filters
+}
+
+// textPartitions returns a pandas dtype mapping that keeps the text
+// partition columns of a CSV input as text, so values like 007 match, or ""
+// if there are none
+func (input mountedInput) textPartitions(filters []partitionFilter) string {
+	var text []string
+	for _, f := range filters {
+		switch input.columnType(f.column) {
+		case "integer", "number", "boolean":
+		default:
+			text = append(text, pyString(f.column)+": str")
+		}
+	}
+	if len(text) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(text, ", ") + "}"
+}
+
+// columnType returns the schema type of one of the input's columns
+func (input mountedInput) columnType(name string) string {
+	for _, col := range input.schema {
+		if col.Name == name {
+			return col.Type
+		}
+	}
+	return ""
+}
+
+// mask combines filters into a boolean Python expression over field(column)
+func mask(filters []partitionFilter, field func(column string) string) string {
+	terms := make([]string, len(filters))
+	for i, f := range filters {
+		terms[i] = "(" + field(f.column) + ".isin([" + strings.Join(f.values, ", ") + "]))"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// pyLiteral renders a partition value as a Python literal of a schema
+// column type. Datetime and string values stay text.
+func pyLiteral(value, columnType string) (string, error) {
+	switch columnType {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("%q is not a finite number", value)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a boolean", value)
+		}
+		if b {
+			return "True", nil
+		}
+		return "False", nil
+	default:
+		return pyString(value), nil
+	}
+}
+
+// pyString renders s as a Python string literal. A JSON string is one.
+func pyString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// pyStrings renders a Python list of string literals
+func pyStrings(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = pyString(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,66 @@
+package privacy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"pandacea/agent-backend/internal/assets"
+)
+
+func TestLoadStatements(t *testing.T) {
+	schema := []assets.Column{{Name: "region", Type: "string"}, {Name: "year", Type: "integer"}, {Name: "amount", Type: "number"}}
+	selected := DataInput{VariableName: "df", Columns: []string{"amount"}, Partitions: map[string][]string{"year": {"2024"}, "region": {"eu", "007"}}}
+	tests := []struct {
+		name   string
+		format assets.Format
+		input  DataInput
+		want   []string
+	}{
+		{"whole", assets.FormatCSV, DataInput{VariableName: "df"}, []string{"df = pd.read_csv(data_path)"}},
+		{"arrow", assets.FormatArrow, DataInput{VariableName: "df"}, []string{"df = pd.read_feather(data_path)"}},
+		{"csv columns", assets.FormatCSV, DataInput{VariableName: "df", Columns: []string{"year", "amount"}},
+			[]string{`df = pd.read_csv(data_path, usecols=["year", "amount"])[["year", "amount"]]`}},
+		{"csv partitions", assets.FormatCSV, selected, []string{
+			`df = pd.concat([chunk[(chunk["region"].isin(["eu", "007"])) & (chunk["year"].isin([2024]))][["amount"]] ` +
+				`for chunk in pd.read_csv(data_path, usecols=["amount", "region", "year"], dtype={"region": str}, chunksize=100000)], ignore_index=True)`}},
+		{"jsonl", assets.FormatJSONL, DataInput{VariableName: "df", Columns: []string{"amount"}},
+			[]string{`df = pd.concat([chunk[["amount"]] for chunk in pd.read_json(data_path, lines=True, chunksize=100000)], ignore_index=True)`}},
+		{"json", assets.FormatJSON, selected, []string{
+			"df = pd.read_json(data_path)",
+			`df = df[(df["region"].isin(["eu", "007"])) & (df["year"].isin([2024]))]`,
+			`df = df[["amount"]]`}},
+		{"parquet", assets.FormatParquet, selected, []string{
+			"import pyarrow.dataset as ds",
+			`df = ds.dataset(data_path, format='parquet', partitioning='hive').to_table(columns=["amount"], ` +
+				`filter=(ds.field("region").isin(["eu", "007"])) & (ds.field("year").isin([2024]))).to_pandas()`}},
+	}
+	for _, tt := range tests {
+		got := loadStatements(mountedInput{DataInput: tt.input, format: tt.format, schema: schema})
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: loadStatements() =\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestValidateSelection(t *testing.T) {
+	asset := assets.Asset{ID: "sales", Schema: []assets.Column{{Name: "region"}, {Name: "year", Type: "integer"}}, Partitions: []string{"year"}}
+	tests := []struct {
+		name  string
+		input DataInput
+		ok    bool
+	}{
+		{"columns and partitions", DataInput{Columns: []string{"region"}, Partitions: map[string][]string{"year": {"2024"}}}, true},
+		{"unknown column", DataInput{Columns: []string{"amount"}}, false},
+		{"repeated column", DataInput{Columns: []string{"region", "region"}}, false},
+		{"not a partition", DataInput{Partitions: map[string][]string{"region": {"eu"}}}, false},
+		{"no values", DataInput{Partitions: map[string][]string{"year": {}}}, false},
+		{"mistyped value", DataInput{Partitions: map[string][]string{"year": {"2024'); import os; ('"}}}, false},
+	}
+	for _, tt := range tests {
+		err := validateSelection(tt.input, asset)
+		if tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidRequest)) {
+			t.Errorf("%s: validateSelection() error = %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package privacy
+
+import (
+	"time"
+
+	"pandacea/agent-backend/internal/metering"
+)
+
+// MeterComputations implements ComputationMeter
+func (ps *privacyService) MeterComputations(fn func(Metering)) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.onMeter = fn
+}
+
+// BillComputations implements ComputationBiller
+func (ps *privacyService) BillComputations(rates metering.Rates) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.rates = &rates
+}
+
+// billing reports whether computations are billed
+func (ps *privacyService) billing() bool {
+	ps.jobsMutex.RLock()
+	defer ps.jobsMutex.RUnlock()
+	return ps.rates != nil
+}
+
+// meterJob measures what a computation used, bills it to the job if
+// computations are billed, and reports it if metering is enabled
+func (ps *privacyService) meterJob(computationID string, req *ComputationRequest, run *execution) {
+	wall := run.finishedAt.Sub(run.startedAt)
+	m := Metering{
+		ComputationID: computationID,
+		LeaseID:       req.LeaseID,
+		Compute:       wall,
+		OutputBytes:   int64(len(run.output)),
+		Resources:     metering.Measure(run.cpuTime, run.memoryBytes, wall),
+	}
+	for _, data := range run.artifacts {
+		m.OutputBytes += int64(len(data))
+	}
+
+	ps.jobsMutex.Lock()
+	onMeter := ps.onMeter
+	if ps.rates != nil {
+		bill := ps.rates.Price(m.Resources)
+		m.Bill = &bill
+		if job, exists := ps.jobs[computationID]; exists {
+			job.Metering = &bill
+		}
+	}
+	ps.jobsMutex.Unlock()
+
+	if onMeter != nil {
+		onMeter(m)
+	}
+}
+
+// sandboxCPUTime returns the CPU time container has used, or false if
+// computations are not billed or the runtime cannot report it
+func (ps *privacyService) sandboxCPUTime(container *DockerContainer) (time.Duration, bool) {
+	reporter, ok := ps.runtime.(ResourceReporter)
+	if !ok || !ps.billing() {
+		return 0, false
+	}
+	cpu, err := reporter.CPUTime(container.ID)
+	if err != nil {
+		ps.logger.Warn("failed to read sandbox CPU time", "container_id", container.ID, "error", err)
+		return 0, false
+	}
+	return cpu, true
+}
+
+// sandboxMemory returns the memory each sandbox is allotted, or zero if
+// computations are not billed or the runtime cannot report it
+func (ps *privacyService) sandboxMemory() int64 {
+	reporter, ok := ps.runtime.(ResourceReporter)
+	if !ok || !ps.billing() {
+		return 0
+	}
+	return reporter.MemoryLimit()
+}
@@ -0,0 +1,70 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+
+	"pandacea/agent-backend/internal/contracts"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NetworkRegistry is implemented by privacy services that can verify
+// leases on more than one LeaseAgreement deployment
+type NetworkRegistry interface {
+	// AddNetwork lets VerifyLease check leases against the contract at
+	// address on backend when the request context names network
+	AddNetwork(network string, backend bind.ContractBackend, address common.Address) error
+}
+
+// networkKey is the context key WithNetwork stores the network name under
+type networkKey struct{}
+
+// WithNetwork returns a copy of ctx that makes VerifyLease check leases on
+// network instead of the service's default deployment
+func WithNetwork(ctx context.Context, network string) context.Context {
+	if network == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, networkKey{}, network)
+}
+
+// NetworkFromContext returns the network WithNetwork stored in ctx, or ""
+func NetworkFromContext(ctx context.Context) string {
+	network, _ := ctx.Value(networkKey{}).(string)
+	return network
+}
+
+// AddNetwork implements NetworkRegistry
+func (ps *privacyService) AddNetwork(network string, backend bind.ContractBackend, address common.Address) error {
+	contract, err := contracts.NewLeaseAgreement(address, backend)
+	if err != nil {
+		return fmt.Errorf("failed to create contract instance for network %s: %w", network, err)
+	}
+
+	ps.networksMutex.Lock()
+	defer ps.networksMutex.Unlock()
+	if ps.networks == nil {
+		ps.networks = make(map[string]*contracts.LeaseAgreement)
+	}
+	ps.networks[network] = contract
+	return nil
+}
+
+// leaseContract returns the contract leases are verified against for ctx's
+// network
+func (ps *privacyService) leaseContract(ctx context.Context) (*contracts.LeaseAgreement, error) {
+	network := NetworkFromContext(ctx)
+	if network == "" {
+		return ps.contract, nil
+	}
+
+	ps.networksMutex.RLock()
+	defer ps.networksMutex.RUnlock()
+	contract, ok := ps.networks[network]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNetwork, network)
+	}
+	return contract, nil
+}
@@ -0,0 +1,139 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// profileMarker starts the output line a profiling script prints its
+// profile on
+const profileMarker = "PANDACEA_PROFILE "
+
+// AssetProfiler is implemented by privacy services that can profile
+// registered assets in the sandbox
+type AssetProfiler interface {
+	// ProfileAsset loads a registered asset in a pooled sandbox and returns
+	// the profile computed there, with numeric columns in histograms of bins
+	// bins and up to top most common values of the others. It has the
+	// signature of assets.ProfileFunc.
+	ProfileAsset(ctx context.Context, a assets.Asset, bins, top int) (assets.Profile, error)
+}
+
+// profileScript computes a profile of df and prints it as JSON
+const profileScript = `
+import json
+import numpy as np
+
+def column_type(series):
+    if pd.api.types.is_bool_dtype(series):
+        return 'boolean'
+    if pd.api.types.is_integer_dtype(series):
+        return 'integer'
+    if pd.api.types.is_numeric_dtype(series):
+        return 'number'
+    if pd.api.types.is_datetime64_any_dtype(series):
+        return 'datetime'
+    return 'string'
+
+columns = []
+for name in df.columns:
+    series = df[name]
+    values = series.dropna()
+    column = {
+        'name': str(name),
+        'type': column_type(series),
+        'nulls': int(series.isna().sum()),
+        'nullRate': float(series.isna().mean()) if len(series) else 0.0,
+        'distinct': int(values.nunique()),
+    }
+    finite = values[np.isfinite(values.astype(float))] if column['type'] in ('integer', 'number') else values
+    if column['type'] in ('integer', 'number') and len(finite):
+        column['min'], column['max'] = finite.agg(['min', 'max']).tolist()
+        counts, edges = np.histogram(finite.astype(float), bins=BINS)
+        column['histogram'] = [{'low': float(edges[i]), 'high': float(edges[i + 1]), 'count': int(count)}
+                               for i, count in enumerate(counts)]
+    elif column['type'] == 'datetime' and len(values):
+        column['min'] = values.min().isoformat()
+        column['max'] = values.max().isoformat()
+    elif len(values) and TOP > 0:
+        top = values.astype(str).value_counts().head(TOP)
+        column['topValues'] = [{'value': value, 'count': int(count)} for value, count in top.items()]
+    columns.append(column)
+
+print(MARKER + json.dumps({'rows': int(len(df)), 'columns': columns}, allow_nan=False))
+`
+
+// ProfileAsset implements AssetProfiler
+func (ps *privacyService) ProfileAsset(ctx context.Context, a assets.Asset, bins, top int) (_ assets.Profile, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "assets.profile", attribute.String("pandacea.asset_id", a.ID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	if ps.registry() == nil {
+		return assets.Profile{}, fmt.Errorf("%w: profiles need the asset registry", ErrInvalidRequest)
+	}
+	container, err := ps.acquireContainer()
+	if err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to acquire container: %w", err)
+	}
+	defer ps.releaseContainer(container)
+
+	tempDir, err := os.MkdirTemp("", "pandacea-profile-*")
+	if err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	dataDir, inputs, err := ps.mountInputs(ctx, []DataInput{{AssetID: a.ID, VariableName: "df"}})
+	if err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to mount data asset: %w", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	var script strings.Builder
+	script.WriteString("import os\nimport pandas as pd\n\n")
+	script.WriteString(fmt.Sprintf("BINS = %d\nTOP = %d\nMARKER = %s\n", max(bins, 1), max(top, 0), pyString(profileMarker)))
+	script.WriteString(fmt.Sprintf("data_path = os.path.join('/data', %s)\n", pyString(inputs[0].file)))
+	for _, statement := range loadStatements(inputs[0]) {
+		script.WriteString(statement + "\n")
+	}
+	script.WriteString(profileScript)
+	if err := os.WriteFile(filepath.Join(tempDir, "profile.py"), []byte(script.String()), 0644); err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to write profiling script: %w", err)
+	}
+
+	if err := ps.copyToContainer(container.ID, tempDir, "/workspace"); err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to copy files to container: %w", err)
+	}
+	if err := ps.copyToContainer(container.ID, dataDir, "/data"); err != nil {
+		return assets.Profile{}, fmt.Errorf("failed to copy data to container: %w", err)
+	}
+	output, err := ps.runtime.Exec(ctx, container.ID, "python", "/workspace/profile.py")
+	if err != nil {
+		return assets.Profile{}, fmt.Errorf("profiling failed: %w", err)
+	}
+	return parseProfile(string(output))
+}
+
+// parseProfile reads the profile a profiling script printed
+func parseProfile(output string) (assets.Profile, error) {
+	for _, line := range strings.Split(output, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), strings.TrimSpace(profileMarker))
+		if !ok {
+			continue
+		}
+		var profile assets.Profile
+		if err := json.Unmarshal([]byte(data), &profile); err != nil {
+			return assets.Profile{}, fmt.Errorf("failed to parse profile: %w", err)
+		}
+		return profile, nil
+	}
+	return assets.Profile{}, fmt.Errorf("profiling script printed no profile")
+}
@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pandacea/agent-backend/internal/assets"
+)
+
+func TestProfileAsset(t *testing.T) {
+	registry, err := assets.NewRegistry("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(t.TempDir(), "scans.csv")
+	if err := os.WriteFile(source, []byte("depth\n0.5\n0.7\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := registry.Register(context.Background(), assets.Asset{ID: "scans", ProductID: "product-1", Source: source, Format: assets.FormatCSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runs int
+	var env []string
+	runtime := replayRuntime{outputs: []string{"warming up\nPANDACEA_PROFILE {\"rows\":2,\"columns\":[{\"name\":\"depth\",\"type\":\"number\",\"min\":0.5,\"max\":0.7," +
+		"\"histogram\":[{\"low\":0.5,\"high\":0.7,\"count\":2}]}]}\n"}, runs: &runs, env: &env}
+	ps := &privacyService{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		runtime:       runtime,
+		containerPool: make(chan *DockerContainer, 1),
+		poolSize:      1,
+		live:          1,
+	}
+	ps.containerPool <- &DockerContainer{ID: "sandbox-1", IsActive: true}
+	if _, err := ps.ProfileAsset(context.Background(), a, 10, 5); err == nil {
+		t.Fatal("ProfileAsset() without a registry succeeded")
+	}
+	ps.UseAssets(registry)
+
+	profile, err := ps.ProfileAsset(context.Background(), a, 10, 5)
+	if err != nil {
+		t.Fatalf("ProfileAsset() error = %v", err)
+	}
+	if runs != 1 || profile.Rows != 2 || profile.Columns[0].Histogram[0].Count != 2 {
+		t.Errorf("ProfileAsset() = %+v after %d runs", profile, runs)
+	}
+	if len(ps.containerPool) != 1 {
+		t.Error("the sandbox was not returned to the pool")
+	}
+
+	if _, err := parseProfile("Traceback (most recent call last):\n"); err == nil {
+		t.Error("parseProfile() of output without a profile succeeded")
+	}
+}
@@ -0,0 +1,75 @@
+package privacy
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"pandacea/agent-backend/internal/jobs"
+)
+
+// CollectJobs drops finished computations from memory and the job store.
+// It implements JobCollector. A computation's last update is when it
+// finished.
+func (ps *privacyService) CollectJobs(cutoff time.Time, keep int) int {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+
+	var finished []*ComputationJob
+	for _, job := range ps.jobs {
+		if computationJobs.IsTerminal(jobs.State(job.Status)) {
+			finished = append(finished, job)
+		}
+	}
+	// Newest first, so everything past keep is the oldest
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].UpdatedAt.After(finished[j].UpdatedAt)
+	})
+
+	dropped := 0
+	for i, job := range finished {
+		expired := !cutoff.IsZero() && job.UpdatedAt.Before(cutoff)
+		if !expired && (keep <= 0 || i < keep) {
+			continue
+		}
+		delete(ps.jobs, job.ID)
+		computationJobs.Forget(jobs.State(job.Status))
+		if ps.jobStore != nil {
+			if err := ps.jobStore.Delete(job.ID); err != nil {
+				ps.logger.Error("failed to delete computation job", "computation_id", job.ID, "error", err)
+			}
+		}
+		dropped++
+	}
+	return dropped
+}
+
+// EraseJobs drops the finished computations that read any of inputs, with
+// their results, from memory and the job store. It implements JobEraser.
+func (ps *privacyService) EraseJobs(inputs []string) (erased, running []string) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+
+	for _, job := range ps.jobs {
+		if job.Request == nil || !slices.ContainsFunc(job.Request.Inputs, func(in DataInput) bool {
+			return slices.Contains(inputs, in.AssetID)
+		}) {
+			continue
+		}
+		if !computationJobs.IsTerminal(jobs.State(job.Status)) {
+			running = append(running, job.ID)
+			continue
+		}
+		delete(ps.jobs, job.ID)
+		computationJobs.Forget(jobs.State(job.Status))
+		if ps.jobStore != nil {
+			if err := ps.jobStore.Delete(job.ID); err != nil {
+				ps.logger.Error("failed to delete computation job", "computation_id", job.ID, "error", err)
+			}
+		}
+		erased = append(erased, job.ID)
+	}
+	sort.Strings(erased)
+	sort.Strings(running)
+	return erased, running
+}
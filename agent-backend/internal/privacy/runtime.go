@@ -0,0 +1,244 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/telemetry"
+)
+
+// ContainerRuntime starts and drives the sandboxes computations run in:
+// containers, microVMs or WASM runtimes. NewSandbox picks one by backend.
+type ContainerRuntime interface {
+	// Create starts an idle container and returns its ID
+	Create() (string, error)
+	// Remove stops and deletes a container
+	Remove(id string) error
+	// Clean empties a container's workspace for reuse
+	Clean(id string) error
+	// CopyTo copies a host file or directory into a container
+	CopyTo(id, srcPath, destPath string) error
+	// Exec runs a command in a container and returns its combined output.
+	// ctx carries the trace context the command continues.
+	Exec(ctx context.Context, id string, args ...string) ([]byte, error)
+}
+
+// GPURuntime is implemented by runtimes whose sandboxes can be given GPUs
+type GPURuntime interface {
+	// CreateWithGPUs starts an idle container that can use the GPUs with
+	// the given indexes and returns its ID
+	CreateWithGPUs(devices []int) (string, error)
+}
+
+// ContainerRunner is implemented by privacy services whose containers can
+// run on a runtime other than the local Docker daemon
+type ContainerRunner interface {
+	// UseContainerRuntime creates containers with rt from now on. Call it
+	// before Start so the initial pool is created with rt.
+	UseContainerRuntime(rt ContainerRuntime)
+}
+
+// EgressAttacher is implemented by runtimes whose sandboxes can be let out
+// of their network isolation to reach the egress proxy
+type EgressAttacher interface {
+	// AttachEgress connects a sandbox to the egress network
+	AttachEgress(id string) error
+	// DetachEgress returns a sandbox to having no network
+	DetachEgress(id string) error
+}
+
+// NetworkedRuntime is implemented by runtimes that cannot start a sandbox
+// without a network. Computations are refused on them, since a computation
+// could reach whatever that network does.
+type NetworkedRuntime interface {
+	// AlwaysNetworked reports whether every sandbox has a network
+	AlwaysNetworked() bool
+}
+
+// ImageDigester is implemented by runtimes that can identify the image a
+// sandbox runs, for computation attestations
+type ImageDigester interface {
+	// ImageDigest returns the content digest of the image sandbox id runs
+	ImageDigest(id string) (string, error)
+}
+
+// ResourceReporter is implemented by runtimes that can report what their
+// sandboxes consume, for billing computations
+type ResourceReporter interface {
+	// CPUTime returns the CPU time sandbox id has used since it started
+	CPUTime(id string) (time.Duration, error)
+	// MemoryLimit returns the bytes of memory each sandbox is allotted
+	MemoryLimit() int64
+}
+
+// envKey is the context key for environment variables added with withEnv
+type envKey struct{}
+
+// withEnv returns a context whose sandbox commands also get env
+func withEnv(ctx context.Context, env ...string) context.Context {
+	return context.WithValue(ctx, envKey{}, slices.Concat(sandboxEnviron(ctx), env))
+}
+
+// sandboxEnviron returns the environment of commands run with ctx: its
+// trace context and anything added with withEnv
+func sandboxEnviron(ctx context.Context) []string {
+	if env, ok := ctx.Value(envKey{}).([]string); ok {
+		return env
+	}
+	return telemetry.Environ(ctx)
+}
+
+// DockerRuntime runs computation containers with the docker CLI
+type DockerRuntime struct {
+	Image      string  // Defaults to pandacea/pysyft-datasite:latest
+	OCIRuntime string  // Container runtime Docker starts containers with, e.g. runsc for gVisor; empty uses Docker's default
+	MemoryMB   int     // Defaults to 512
+	CPUs       float64 // Defaults to 1
+
+	// EgressNetwork is the internal Docker network on which containers can
+	// reach only the egress proxy; empty keeps every container off the network
+	EgressNetwork string
+}
+
+// Create implements ContainerRuntime
+func (d DockerRuntime) Create() (string, error) {
+	return d.create(nil)
+}
+
+// CreateWithGPUs implements GPURuntime. The container needs the NVIDIA
+// container runtime on the host.
+func (d DockerRuntime) CreateWithGPUs(devices []int) (string, error) {
+	return d.create(devices)
+}
+
+// create starts an idle container, with the given GPUs if any
+func (d DockerRuntime) create(gpus []int) (string, error) {
+	image := d.Image
+	if image == "" {
+		image = defaultSandboxImage
+	}
+	args := []string{"run", "-d", "--network", "none"}
+	if d.OCIRuntime != "" {
+		args = append(args, "--runtime", d.OCIRuntime)
+	}
+	if len(gpus) > 0 {
+		// The device list is quoted since --gpus splits its value on commas
+		args = append(args, "--gpus", `"device=`+gpu.VisibleDevices(gpus)+`"`)
+	}
+	memoryMB, cpus := sandboxLimits(d.MemoryMB, d.CPUs)
+	args = append(args,
+		"--memory", fmt.Sprintf("%dm", memoryMB),
+		"--cpus", strconv.FormatFloat(cpus, 'f', -1, 64),
+		image,
+		"tail", "-f", "/dev/null") // Keep container running
+
+	output, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w, output: %s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Remove implements ContainerRuntime
+func (DockerRuntime) Remove(id string) error {
+	return exec.Command("docker", "rm", "-f", id).Run()
+}
+
+// Clean implements ContainerRuntime
+func (DockerRuntime) Clean(id string) error {
+	return exec.Command("docker", "exec", id, "rm", "-rf", "/workspace/*").Run()
+}
+
+// CopyTo implements ContainerRuntime
+func (DockerRuntime) CopyTo(id, srcPath, destPath string) error {
+	return exec.Command("docker", "cp", srcPath, id+":"+destPath).Run()
+}
+
+// Exec implements ContainerRuntime. The trace context is passed to the
+// command as TRACEPARENT and TRACESTATE environment variables, along with
+// any the computation was given, such as its egress proxy.
+func (DockerRuntime) Exec(ctx context.Context, id string, args ...string) ([]byte, error) {
+	dockerArgs := []string{"exec"}
+	for _, env := range sandboxEnviron(ctx) {
+		dockerArgs = append(dockerArgs, "-e", env)
+	}
+	dockerArgs = append(dockerArgs, id)
+	return exec.Command("docker", append(dockerArgs, args...)...).CombinedOutput()
+}
+
+// ImageDigest implements ImageDigester
+func (DockerRuntime) ImageDigest(id string) (string, error) {
+	output, err := exec.Command("docker", "inspect", "--format", "{{.Image}}", id).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w, output: %s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CPUTime implements ResourceReporter from the container's cgroup
+func (DockerRuntime) CPUTime(id string) (time.Duration, error) {
+	output, err := exec.Command("docker", "exec", id, "sh", "-c",
+		"cat /sys/fs/cgroup/cpu.stat 2>/dev/null || cat /sys/fs/cgroup/cpuacct/cpuacct.usage").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read container CPU usage: %w, output: %s", err, string(output))
+	}
+	return parseCPUUsage(string(output))
+}
+
+// parseCPUUsage parses a cgroup v2 cpu.stat, whose usage_usec is in
+// microseconds, or a cgroup v1 cpuacct.usage in nanoseconds
+func parseCPUUsage(stat string) (time.Duration, error) {
+	for _, line := range strings.Split(stat, "\n") {
+		if usec, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(usec), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid usage_usec %q", usec)
+			}
+			return time.Duration(n) * time.Microsecond, nil
+		}
+	}
+	ns, err := strconv.ParseInt(strings.TrimSpace(stat), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized cgroup CPU usage %q", stat)
+	}
+	return time.Duration(ns), nil
+}
+
+// MemoryLimit implements ResourceReporter
+func (d DockerRuntime) MemoryLimit() int64 {
+	memoryMB, _ := sandboxLimits(d.MemoryMB, d.CPUs)
+	return int64(memoryMB) << 20
+}
+
+// AttachEgress implements EgressAttacher. Containers start with no network,
+// which Docker will not combine with another, so it is swapped out for the
+// egress network.
+func (d DockerRuntime) AttachEgress(id string) error {
+	if d.EgressNetwork == "" {
+		return fmt.Errorf("no egress network is configured")
+	}
+	if output, err := exec.Command("docker", "network", "disconnect", "none", id).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to take container off the none network: %w, output: %s", err, string(output))
+	}
+	if output, err := exec.Command("docker", "network", "connect", d.EgressNetwork, id).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to connect container to egress network: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// DetachEgress implements EgressAttacher
+func (d DockerRuntime) DetachEgress(id string) error {
+	if output, err := exec.Command("docker", "network", "disconnect", d.EgressNetwork, id).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to disconnect container from egress network: %w, output: %s", err, string(output))
+	}
+	if output, err := exec.Command("docker", "network", "connect", "none", id).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to isolate container: %w, output: %s", err, string(output))
+	}
+	return nil
+}
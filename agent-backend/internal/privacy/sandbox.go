@@ -0,0 +1,76 @@
+package privacy
+
+import (
+	"fmt"
+)
+
+// Sandbox backends computations can run in, from the lightest isolation to
+// the strongest
+const (
+	SandboxDocker      = "docker"      // Docker containers sharing the host kernel
+	SandboxGVisor      = "gvisor"      // Docker containers under gVisor's runsc, which intercepts system calls
+	SandboxFirecracker = "firecracker" // Firecracker microVMs with their own kernel, driven by ignite
+	SandboxWASM        = "wasm"        // WebAssembly modules run by wasmtime; no native code runs
+)
+
+// Resource limits sandboxes get unless configured otherwise
+const (
+	defaultSandboxImage    = "pandacea/pysyft-datasite:latest"
+	defaultSandboxMemoryMB = 512
+	defaultSandboxCPUs     = 1
+)
+
+// SandboxOptions selects and configures the sandbox computations run in
+type SandboxOptions struct {
+	Backend  string  // One of the Sandbox* backends; empty is SandboxDocker
+	Image    string  // OCI image for container and microVM backends
+	MemoryMB int     // Memory per sandbox
+	CPUs     float64 // CPUs per sandbox
+
+	// KernelImage is the OCI image holding the microVM kernel; empty uses
+	// ignite's default
+	KernelImage string
+
+	// EgressNetwork is the internal Docker network containers join to reach
+	// the egress proxy; docker and gvisor only
+	EgressNetwork string
+
+	// WorkDir holds WASM sandboxes' directories. Modules maps the commands
+	// computations run, such as python, to WASI modules implementing them.
+	WorkDir string
+	Modules map[string]string
+}
+
+// NewSandbox returns the ContainerRuntime for opts.Backend. Every backend
+// runs a computation the same way: its workspace copied to /workspace and
+// its inputs to /data, then python /workspace/datasite.py executed. They
+// trade start-up time and overhead against how much of the host a
+// computation can reach.
+func NewSandbox(opts SandboxOptions) (ContainerRuntime, error) {
+	switch opts.Backend {
+	case "", SandboxDocker:
+		return DockerRuntime{Image: opts.Image, MemoryMB: opts.MemoryMB, CPUs: opts.CPUs, EgressNetwork: opts.EgressNetwork}, nil
+	case SandboxGVisor:
+		return DockerRuntime{Image: opts.Image, OCIRuntime: "runsc", MemoryMB: opts.MemoryMB, CPUs: opts.CPUs, EgressNetwork: opts.EgressNetwork}, nil
+	case SandboxFirecracker:
+		return FirecrackerRuntime{Image: opts.Image, KernelImage: opts.KernelImage, MemoryMB: opts.MemoryMB, CPUs: opts.CPUs}, nil
+	case SandboxWASM:
+		if len(opts.Modules) == 0 {
+			return nil, fmt.Errorf("wasm sandbox needs at least one module")
+		}
+		return NewWASMRuntime(opts.WorkDir, opts.Modules)
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q", opts.Backend)
+	}
+}
+
+// sandboxLimits fills in the default memory and CPU limits
+func sandboxLimits(memoryMB int, cpus float64) (int, float64) {
+	if memoryMB <= 0 {
+		memoryMB = defaultSandboxMemoryMB
+	}
+	if cpus <= 0 {
+		cpus = defaultSandboxCPUs
+	}
+	return memoryMB, cpus
+}
@@ -0,0 +1,111 @@
+package privacy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSandbox(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "python.wasm")
+	if err := os.WriteFile(module, []byte("\x00asm"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts SandboxOptions
+		want string
+	}{
+		{SandboxOptions{}, "privacy.DockerRuntime"},
+		{SandboxOptions{Backend: SandboxGVisor}, "privacy.DockerRuntime"},
+		{SandboxOptions{Backend: SandboxFirecracker}, "privacy.FirecrackerRuntime"},
+		{SandboxOptions{Backend: SandboxWASM, WorkDir: t.TempDir(), Modules: map[string]string{"python": module}}, "*privacy.WASMRuntime"},
+	}
+	for _, tt := range tests {
+		rt, err := NewSandbox(tt.opts)
+		if err != nil {
+			t.Fatalf("NewSandbox(%q) error = %v", tt.opts.Backend, err)
+		}
+		if got := fmt.Sprintf("%T", rt); got != tt.want {
+			t.Errorf("NewSandbox(%q) = %s, want %s", tt.opts.Backend, got, tt.want)
+		}
+	}
+	if rt, _ := NewSandbox(SandboxOptions{Backend: SandboxGVisor}); rt.(DockerRuntime).OCIRuntime != "runsc" {
+		t.Error("gvisor sandbox does not run containers under runsc")
+	}
+
+	for _, opts := range []SandboxOptions{
+		{Backend: "qemu"},
+		{Backend: SandboxWASM},
+		{Backend: SandboxWASM, Modules: map[string]string{"python": filepath.Join(t.TempDir(), "missing.wasm")}},
+	} {
+		if _, err := NewSandbox(opts); err == nil {
+			t.Errorf("NewSandbox(%+v) succeeded", opts)
+		}
+	}
+}
+
+func TestWASMRuntime(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "python.wasm")
+	if err := os.WriteFile(module, []byte("\x00asm"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	rt, err := NewWASMRuntime(dir, map[string]string{"python": module})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.binary = "echo" // Shows the wasmtime command line instead of running it
+
+	id, err := rt.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "datasite.py"), []byte("print(1)"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.CopyTo(id, workspace, "/workspace"); err != nil {
+		t.Fatalf("CopyTo(/workspace) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, id, "workspace", "datasite.py")); err != nil {
+		t.Errorf("workspace not staged: %v", err)
+	}
+	if err := rt.CopyTo(id, workspace, "/etc"); err == nil {
+		t.Error("CopyTo outside the preopened directories succeeded")
+	}
+
+	output, err := rt.Exec(context.Background(), id, "python", "/workspace/datasite.py")
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	want := "run --dir " + filepath.Join(dir, id, "workspace") + "::/workspace --dir " + filepath.Join(dir, id, "data") + "::/data " + module + " /workspace/datasite.py"
+	if got := strings.TrimSpace(string(output)); got != want {
+		t.Errorf("wasmtime invoked as\n%s\nwant\n%s", got, want)
+	}
+	if _, err := rt.Exec(context.Background(), id, "bash", "-c", "id"); err == nil {
+		t.Error("command without a module ran")
+	}
+	sum := sha256.Sum256([]byte("\x00asm"))
+	if digest, err := rt.ImageDigest(id); err != nil || digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Errorf("ImageDigest() = %s, %v; want the python module's SHA-256", digest, err)
+	}
+
+	if err := rt.Clean(id); err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, id, "workspace")); len(entries) != 0 {
+		t.Errorf("workspace not cleaned: %v", entries)
+	}
+	if err := rt.Remove(id); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := rt.Remove("../" + id); err == nil {
+		t.Error("Remove accepted a path outside the sandbox directory")
+	}
+}
@@ -2,22 +2,40 @@ package privacy
 
 import (
 	"context"
+	"crypto/ecdh"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/attest"
+	"pandacea/agent-backend/internal/autoscale"
 	"pandacea/agent-backend/internal/contracts"
-
+	"pandacea/agent-backend/internal/egress"
+	"pandacea/agent-backend/internal/envelope"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/scriptscan"
+	"pandacea/agent-backend/internal/telemetry"
+	"pandacea/agent-backend/internal/watermark"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // PrivacyService defines the interface for privacy-preserving computations
@@ -29,29 +47,240 @@ type PrivacyService interface {
 	Stop() error
 }
 
+// StatusNotifier is implemented by privacy services that can report
+// computation status transitions as they happen
+type StatusNotifier interface {
+	// OnStatusChange registers fn to be called after each transition. fn runs
+	// with the service's job lock held and must not call back into the service.
+	OnStatusChange(fn func(computationID, status string))
+}
+
+// ResultWatermarker is implemented by privacy services that can watermark
+// computation results for leak tracing
+type ResultWatermarker interface {
+	// WatermarkResults marks the JSON output and artifacts of computations
+	// completed from now on with their lease's watermark
+	WatermarkResults(marker *watermark.Marker)
+}
+
+// LeaseAssigner is implemented by privacy services that honour off-chain
+// lease assignments
+type LeaseAssigner interface {
+	// UseAssignments makes VerifyLease accept the holder named by a lease's
+	// latest assignment in place of its on-chain spender
+	UseAssignments(registry *AssignmentRegistry)
+}
+
+// PoolAutoscaler is implemented by privacy services whose container pool
+// can be sized from a forecast of computation load
+type PoolAutoscaler interface {
+	autoscale.Pool
+	// UseLoadHistory records computation arrivals and container hold times
+	// into h from now on
+	UseLoadHistory(h *autoscale.History)
+}
+
+// JobScheduler is implemented by privacy services that can queue
+// computations on a shared scheduler instead of starting them at once
+type JobScheduler interface {
+	// UseScheduler runs computations submitted from now on through s
+	UseScheduler(s *scheduler.Scheduler)
+}
+
+// GPUScheduler is implemented by privacy services that can run
+// computations on GPUs
+type GPUScheduler interface {
+	// UseGPUs queues GPU computations submitted from now on on s and gives
+	// them GPUs from devices. A non-nil monitor supplies the utilization
+	// their status reports.
+	UseGPUs(s *scheduler.Scheduler, devices *gpu.Allocator, monitor *gpu.Monitor)
+}
+
+// AssetMounter is implemented by privacy services that mount computation
+// inputs from an asset registry
+type AssetMounter interface {
+	// UseAssets refuses computations on assets not in registry from now on,
+	// and mounts each input from its registered source in its own format
+	UseAssets(registry *assets.Registry)
+}
+
+// EgressGranter is implemented by privacy services whose computations can
+// reach allowlisted hosts through an egress proxy
+type EgressGranter interface {
+	// UseEgress lets computations started from now on reach the hosts policy
+	// allows for all of their products, through proxy. Computations on other
+	// products keep no network.
+	UseEgress(proxy *egress.Proxy, policy egress.Policy)
+}
+
+// ResultVerifier is implemented by privacy services that can re-execute
+// completed computations to check their results
+type ResultVerifier interface {
+	// VerifyResults re-executes fraction of the computations completed from
+	// now on, with the same inputs and seed, and compares the results. fn, if
+	// not nil, is called with each verdict.
+	VerifyResults(fraction float64, fn func(computationID, leaseID string, v Verification))
+}
+
+// DataDecrypter is implemented by privacy services that can mount inputs
+// encrypted at rest
+type DataDecrypter interface {
+	// UseKeyring decrypts inputs read from the data directory with k when
+	// they are mounted, from now on. Inputs from an asset registry are
+	// decrypted by the registry.
+	UseKeyring(k *atrest.Keyring)
+}
+
+// ComputationAttester is implemented by privacy services that can state
+// what each completed computation ran, for signed attestations
+type ComputationAttester interface {
+	// AttestComputations calls fn with the statement of each computation
+	// completed from now on. fn runs on the computation's goroutine.
+	AttestComputations(fn func(attest.Statement))
+}
+
+// ComputationMeter is implemented by privacy services that report what each
+// computation used, for usage accounting
+type ComputationMeter interface {
+	// MeterComputations calls fn for each computation that ran from now on,
+	// before its status changes. fn runs on the computation's goroutine.
+	MeterComputations(fn func(Metering))
+}
+
+// ComputationBiller is implemented by privacy services that can price
+// what each computation used
+type ComputationBiller interface {
+	// BillComputations prices each computation that runs from now on at
+	// rates. The bill is kept with the job and returned with its result.
+	BillComputations(rates metering.Rates)
+}
+
+// JobCollector is implemented by privacy services that can drop finished
+// computations
+type JobCollector interface {
+	// CollectJobs drops the finished computations that finished before
+	// cutoff, then the oldest finished ones beyond the newest keep, and
+	// returns how many it dropped. A zero cutoff or keep is no limit.
+	CollectJobs(cutoff time.Time, keep int) int
+}
+
+// JobEraser is implemented by privacy services that can drop the
+// computations run on erased data
+type JobEraser interface {
+	// EraseJobs drops the finished computations that read any of inputs,
+	// asset or product IDs, with their results. It returns the IDs of those
+	// it dropped, and of those still running, which it leaves alone.
+	EraseJobs(inputs []string) (erased, running []string)
+}
+
+// Metering is what a computation used
+type Metering struct {
+	ComputationID string
+	LeaseID       string
+	Compute       time.Duration // Time the computation ran in its sandbox
+	OutputBytes   int64         // Size of its output and artifacts
+	Resources     metering.Resources
+	Bill          *metering.Bill // Set if computations are billed
+}
+
+// ScriptScanner is implemented by privacy services that check computation
+// scripts before running them
+type ScriptScanner interface {
+	// UseScriptScanner fails computations whose script s rejects from now on
+	UseScriptScanner(s *scriptscan.Scanner)
+}
+
 // privacyService implements the PrivacyService interface
 type privacyService struct {
 	logger          *slog.Logger
-	ethClient       *ethclient.Client
+	ethClient       bind.ContractBackend
 	contractAddress common.Address
 	contract        *contracts.LeaseAgreement
 	dataDir         string
 
+	// Further deployments leases can be verified on, by network name
+	networks      map[string]*contracts.LeaseAgreement
+	networksMutex sync.RWMutex
+
 	// IPFS client configuration
 	ipfsAPIURL string
 	httpClient *http.Client
 
+	// Runs the pool's containers; Docker unless replaced before Start
+	runtime ContainerRuntime
+
 	// Asynchronous job management
 	jobs      map[string]*ComputationJob
 	jobsMutex sync.RWMutex
-
-	// Container pool
+	jobStore  jobs.Store
+	onStatus  func(computationID, status string)
+	marker    *watermark.Marker
+
+	// Off-chain lease assignments
+	assignments *AssignmentRegistry
+
+	// Queue shared with training jobs; nil starts computations at once
+	scheduler *scheduler.Scheduler
+	// Queue, GPUs and utilization samples of GPU computations; nil gpus
+	// refuses them
+	gpuScheduler *scheduler.Scheduler
+	gpus         *gpu.Allocator
+	gpuMonitor   *gpu.Monitor
+
+	// Registered data assets; nil loads /data/<asset_id>.csv from dataDir
+	assetRegistry *assets.Registry
+	// Decrypts inputs in dataDir; nil mounts dataDir as it is
+	keyring *atrest.Keyring
+
+	// Egress proxy and the hosts each product allows; nil keeps every
+	// computation off the network
+	egressProxy  *egress.Proxy
+	egressPolicy egress.Policy
+
+	// Checks scripts before they run; nil runs them unchecked
+	scanner *scriptscan.Scanner
+
+	// Called with the statement of each completed computation; nil skips
+	// attestation
+	onAttest func(attest.Statement)
+
+	// Called with what each computation that ran used; nil skips metering
+	onMeter func(Metering)
+	// Prices what each computation used; nil leaves computations unbilled
+	rates *metering.Rates
+
+	// Share of completed computations re-executed to verify their results
+	verifyFraction float64
+	onVerified     func(computationID, leaseID string, v Verification)
+
+	// Container pool. poolSize is the target size and live counts the
+	// containers idle in the pool or held by computations.
 	containerPool chan *DockerContainer
 	poolSize      int
+	live          int
+	poolClosed    bool
+	poolMutex     sync.Mutex
+	loadHistory   *autoscale.History
 	stopChan      chan struct{}
 	wg            sync.WaitGroup
 }
 
+// maxPoolSize bounds how far the container pool can be resized
+const maxPoolSize = 64
+
+// computationJobs governs computation job status transitions
+var computationJobs = jobs.NewMachine(jobs.Definition{
+	Kind:    "computation",
+	Initial: jobs.StatePending,
+	Transitions: map[jobs.State][]jobs.State{
+		jobs.StatePending: {jobs.StateCompleted, jobs.StateFailed},
+	},
+	Timeouts: map[jobs.State]time.Duration{
+		jobs.StatePending: 30 * time.Minute,
+	},
+	TimeoutState: jobs.StateFailed,
+})
+
 // ComputationJob represents an asynchronous computation job
 type ComputationJob struct {
 	ID        string              `json:"id"`
@@ -61,13 +290,32 @@ type ComputationJob struct {
 	Request   *ComputationRequest `json:"request,omitempty"`
 	Results   *ComputationResults `json:"results,omitempty"`
 	Error     string              `json:"error,omitempty"`
+
+	// Verification is set if the computation was picked for re-execution
+	Verification *Verification `json:"verification,omitempty"`
+	// Metering is what the computation used and cost, if computations are billed
+	Metering *metering.Bill `json:"metering,omitempty"`
+	// Owner is the peer that queued the computation and alone may read its
+	// results
+	Owner string `json:"owner,omitempty"`
+	// GPU is the GPUs a GPU computation requested and ran on
+	GPU *gpu.Allocation `json:"gpu,omitempty"`
 }
 
 // ComputationResult represents the result of a computation job
 type ComputationResult struct {
-	Status  string              `json:"status"`
-	Results *ComputationResults `json:"results,omitempty"`
-	Error   string              `json:"error,omitempty"`
+	Status        string              `json:"status"`
+	QueuePosition int                 `json:"queue_position,omitempty"` // Set while the computation waits for a worker
+	Results       *ComputationResults `json:"results,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	Verification  *Verification       `json:"verification,omitempty"` // Set for completed computations picked for re-execution
+	Metering      *metering.Bill      `json:"metering,omitempty"`     // Set for completed computations if computations are billed
+	CID           string              `json:"cid,omitempty"`          // Set for completed computations whose sealed results are published to IPFS
+	GPU           *gpu.Allocation     `json:"gpu,omitempty"`          // GPUs a GPU computation requested, with their latest utilization
+
+	// Owner is the peer that queued the computation, empty if it was
+	// queued without one. It is for the API to enforce, not to return.
+	Owner string `json:"-"`
 }
 
 // DockerContainer represents a container in the pool
@@ -78,15 +326,48 @@ type DockerContainer struct {
 
 // ComputationRequest represents a request to execute privacy-preserving computation
 type ComputationRequest struct {
+	// ID is the ID to queue the computation under, generated when empty.
+	// Callers set it to track the computation before it can finish.
+	ID             string      `json:"-"`
 	LeaseID        string      `json:"lease_id"`
 	ComputationCid string      `json:"computationCid"` // IPFS Content ID pointing to the computation script
 	Inputs         []DataInput `json:"inputs"`
+	// Purpose is the purpose category the spender declares for the
+	// computation. It must match the purpose the lease was granted for.
+	Purpose string `json:"purpose,omitempty"`
+
+	// LeasePurpose is the purpose category the lease was granted for, if
+	// it was granted for one
+	LeasePurpose string `json:"-"`
+
+	// Recipient is the spender's public key. When set, results are sealed
+	// to it before they are stored.
+	Recipient crypto.PubKey `json:"-"`
+	// RecipientX25519 is an X25519 key the spender handed over with the
+	// lease. When set, results are sealed to it instead of Recipient.
+	RecipientX25519 *ecdh.PublicKey `json:"-"`
+
+	// Identity and Priority place the computation in the scheduler's queue
+	Identity string             `json:"-"`
+	Priority scheduler.Priority `json:"-"`
+
+	// Owner is the verified peer ID that queued the computation. Only it
+	// may read the results.
+	Owner string `json:"-"`
+
+	// GPU requests GPUCount GPUs, one if unset, for the computation. GPU
+	// computations wait in the GPU queue and run in a sandbox of their own
+	// rather than a pooled one.
+	GPU      bool `json:"gpu,omitempty"`
+	GPUCount int  `json:"gpu_count,omitempty"`
 }
 
 // DataInput represents a data asset input for computation
 type DataInput struct {
-	AssetID      string `json:"asset_id"`
-	VariableName string `json:"variable_name"`
+	AssetID      string              `json:"asset_id"`
+	VariableName string              `json:"variable_name"`
+	Columns      []string            `json:"columns,omitempty"`    // Only these columns are loaded, in this order
+	Partitions   map[string][]string `json:"partitions,omitempty"` // Only rows whose partition column holds one of the values are loaded
 }
 
 // ComputationResponse represents the response for starting a computation
@@ -96,22 +377,89 @@ type ComputationResponse struct {
 
 // ComputationResults contains the output and artifacts from computation
 type ComputationResults struct {
-	Output    string            `json:"output"`
-	Artifacts map[string]string `json:"artifacts"`
+	Output      string            `json:"output"`
+	Artifacts   map[string]string `json:"artifacts"`
+	Watermarked bool              `json:"watermarked,omitempty"` // Numbers carry the lease's leak-tracing watermark
+
+	// Sealed holds the output and artifacts encrypted to the spender's key.
+	// Output and Artifacts are empty while it is set; Open restores them.
+	Sealed *envelope.Envelope `json:"sealed,omitempty"`
+}
+
+// Seal encrypts the output and artifacts to recipient and clears them
+func (r *ComputationResults) Seal(recipient crypto.PubKey) error {
+	return r.seal(func(plaintext []byte) (*envelope.Envelope, error) {
+		return envelope.Seal(recipient, plaintext)
+	})
+}
+
+// SealX25519 encrypts the output and artifacts to an X25519 recipient and
+// clears them
+func (r *ComputationResults) SealX25519(recipient *ecdh.PublicKey) error {
+	return r.seal(func(plaintext []byte) (*envelope.Envelope, error) {
+		return envelope.SealX25519(recipient, plaintext)
+	})
+}
+
+// seal encrypts the output and artifacts with sealTo and clears them
+func (r *ComputationResults) seal(sealTo func([]byte) (*envelope.Envelope, error)) error {
+	plaintext, err := json.Marshal(ComputationResults{Output: r.Output, Artifacts: r.Artifacts})
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+	sealed, err := sealTo(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to seal results: %w", err)
+	}
+	r.Output, r.Artifacts, r.Sealed = "", nil, sealed
+	return nil
+}
+
+// Open decrypts sealed results with the spender's private key, restoring
+// the output and artifacts
+func (r *ComputationResults) Open(recipient crypto.PrivKey) error {
+	if r.Sealed == nil {
+		return nil
+	}
+	return r.open(envelope.Open(recipient, r.Sealed))
+}
+
+// OpenX25519 decrypts results sealed to the spender's X25519 key,
+// restoring the output and artifacts
+func (r *ComputationResults) OpenX25519(recipient *ecdh.PrivateKey) error {
+	if r.Sealed == nil {
+		return nil
+	}
+	return r.open(envelope.OpenX25519(recipient, r.Sealed))
+}
+
+// open restores the output and artifacts from an opened envelope
+func (r *ComputationResults) open(plaintext []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	var opened ComputationResults
+	if err := json.Unmarshal(plaintext, &opened); err != nil {
+		return fmt.Errorf("failed to decode results: %w", err)
+	}
+	r.Output, r.Artifacts, r.Sealed = opened.Output, opened.Artifacts, nil
+	return nil
 }
 
 // NewPrivacyService creates a new PrivacyService instance
 func NewPrivacyService(
 	logger *slog.Logger,
-	ethClient *ethclient.Client,
+	ethClient bind.ContractBackend,
 	contractAddress common.Address,
 	dataDir string,
 	poolSize int,
 	ipfsAPIURL string,
+	jobStore jobs.Store,
 ) (PrivacyService, error) {
 	if poolSize <= 0 {
 		poolSize = 3 // Default pool size
 	}
+	poolSize = min(poolSize, maxPoolSize)
 
 	contract, err := contracts.NewLeaseAgreement(contractAddress, ethClient)
 	if err != nil {
@@ -135,29 +483,56 @@ func NewPrivacyService(
 		contract:        contract,
 		dataDir:         dataDir,
 		ipfsAPIURL:      ipfsAPIURL,
-		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		httpClient:      &http.Client{Timeout: 30 * time.Second, Transport: telemetry.Transport(nil)},
+		runtime:         DockerRuntime{},
 		jobs:            make(map[string]*ComputationJob),
-		containerPool:   make(chan *DockerContainer, poolSize),
+		jobStore:        jobStore,
+		containerPool:   make(chan *DockerContainer, maxPoolSize),
 		poolSize:        poolSize,
 		stopChan:        make(chan struct{}),
 	}
 
+	if jobStore != nil {
+		if err := service.restoreJobs(); err != nil {
+			return nil, fmt.Errorf("failed to restore computation jobs: %w", err)
+		}
+	}
+
 	return service, nil
 }
 
+// restoreJobs loads persisted computation jobs. Jobs that were still running
+// when the agent stopped cannot be resumed and are marked failed.
+func (ps *privacyService) restoreJobs() error {
+	return ps.jobStore.LoadAll(func(id string, data []byte) error {
+		var job ComputationJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			ps.logger.Warn("skipping unreadable computation job", "computation_id", id, "error", err)
+			return nil
+		}
+
+		state := jobs.State(job.Status)
+		if !computationJobs.IsTerminal(state) {
+			job.Status = string(computationJobs.TimeoutState())
+			job.Error = "computation interrupted by agent restart"
+			job.UpdatedAt = time.Now()
+			if err := ps.jobStore.Save(job.ID, &job); err != nil {
+				ps.logger.Error("failed to persist interrupted computation job", "computation_id", job.ID, "error", err)
+			}
+		}
+
+		computationJobs.Restore(jobs.State(job.Status))
+		ps.jobs[job.ID] = &job
+		return nil
+	})
+}
+
 // Start initializes the container pool and starts background workers
 func (ps *privacyService) Start() error {
 	ps.logger.Info("starting privacy service", "pool_size", ps.poolSize)
 
 	// Initialize container pool
-	for i := 0; i < ps.poolSize; i++ {
-		container, err := ps.createContainer()
-		if err != nil {
-			ps.logger.Error("failed to create container for pool", "error", err, "index", i)
-			continue
-		}
-		ps.containerPool <- container
-	}
+	ps.fillPool()
 
 	ps.logger.Info("privacy service started successfully", "containers_initialized", len(ps.containerPool))
 	return nil
@@ -174,7 +549,10 @@ func (ps *privacyService) Stop() error {
 	ps.wg.Wait()
 
 	// Clean up containers
+	ps.poolMutex.Lock()
+	ps.poolClosed = true
 	close(ps.containerPool)
+	ps.poolMutex.Unlock()
 	for container := range ps.containerPool {
 		ps.destroyContainer(container)
 	}
@@ -194,26 +572,65 @@ func (ps *privacyService) ExecuteComputation(ctx context.Context, req *Computati
 		return nil, fmt.Errorf("validation error: %w", err)
 	}
 
-	// Generate unique computation ID
-	computationID := ps.generateComputationID()
+	computationID := req.ID
+	if computationID == "" {
+		computationID = NewComputationID()
+	}
 
 	// Create job record
 	job := &ComputationJob{
 		ID:        computationID,
-		Status:    "pending",
+		Status:    string(computationJobs.Start()),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Request:   req,
+		Owner:     req.Owner,
+	}
+	if req.GPU {
+		job.GPU = &gpu.Allocation{Count: req.GPUCount}
 	}
 
-	// Store job in memory
+	// The job outlives the request, so it keeps the request's trace but
+	// not its cancellation
+	jobCtx := context.WithoutCancel(ctx)
+
+	// Store job in memory. The lock is held while the job is queued so a
+	// worker cannot start it before it is stored, and a rejected job is
+	// never persisted.
 	ps.jobsMutex.Lock()
+	if _, exists := ps.jobs[computationID]; exists {
+		ps.jobsMutex.Unlock()
+		return nil, fmt.Errorf("computation %s already exists", computationID)
+	}
+	queue := ps.scheduler
+	if req.GPU {
+		queue = ps.gpuScheduler
+	}
 	ps.jobs[computationID] = job
+	ps.wg.Add(1)
+	if queue == nil {
+		go ps.executeJobAsync(jobCtx, computationID, req)
+	} else if err := queue.Submit(scheduler.Task{
+		ID:       computationID,
+		Identity: req.Identity,
+		Priority: req.Priority,
+		Run:      func() { ps.runQueuedJob(jobCtx, computationID, req) },
+		Drop: func() {
+			defer ps.wg.Done()
+			ps.updateJobStatus(computationID, "failed", nil, "agent stopped before the computation started")
+		},
+	}); err != nil {
+		ps.wg.Done()
+		delete(ps.jobs, computationID)
+		ps.jobsMutex.Unlock()
+		return nil, err
+	}
+	ps.persistJob(job)
 	ps.jobsMutex.Unlock()
 
-	// Start asynchronous execution
-	ps.wg.Add(1)
-	go ps.executeJobAsync(computationID, req)
+	if h := ps.history(); h != nil {
+		h.RecordArrival(time.Now())
+	}
 
 	return &ComputationResponse{
 		ComputationID: computationID,
@@ -222,99 +639,277 @@ func (ps *privacyService) ExecuteComputation(ctx context.Context, req *Computati
 
 // GetComputationResult retrieves the result of a computation job
 func (ps *privacyService) GetComputationResult(ctx context.Context, computationID string) (*ComputationResult, error) {
-	ps.jobsMutex.RLock()
-	job, exists := ps.jobs[computationID]
-	ps.jobsMutex.RUnlock()
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
 
+	job, exists := ps.jobs[computationID]
 	if !exists {
-		return nil, fmt.Errorf("computation job not found: %s", computationID)
+		return nil, fmt.Errorf("%w: %s", ErrComputationNotFound, computationID)
+	}
+
+	queue := ps.scheduler
+	if job.GPU != nil {
+		queue = ps.gpuScheduler
+	}
+	if computationJobs.TimedOut(jobs.State(job.Status), job.UpdatedAt, time.Now()) {
+		ps.setJobStatus(job, string(computationJobs.TimeoutState()), nil, "computation timed out")
+		if queue != nil && queue.Cancel(computationID) {
+			ps.wg.Done()
+		}
 	}
 
 	result := &ComputationResult{
 		Status: job.Status,
+		Owner:  job.Owner,
+		GPU:    ps.gpuStatus(job),
+	}
+	if queue != nil {
+		result.QueuePosition, _ = queue.Position(computationID)
 	}
 
-	if job.Status == "completed" {
+	if job.Status == string(jobs.StateCompleted) {
 		result.Results = job.Results
-	} else if job.Status == "failed" {
+		result.Verification = job.Verification
+		result.Metering = job.Metering
+	} else if job.Status == string(jobs.StateFailed) {
 		result.Error = job.Error
 	}
 
 	return result, nil
 }
 
+// runQueuedJob runs a computation the scheduler has started, unless it
+// failed while it waited
+func (ps *privacyService) runQueuedJob(ctx context.Context, computationID string, req *ComputationRequest) {
+	ps.jobsMutex.RLock()
+	job, exists := ps.jobs[computationID]
+	pending := exists && job.Status == string(jobs.StatePending)
+	ps.jobsMutex.RUnlock()
+	if !pending {
+		ps.wg.Done()
+		return
+	}
+	ps.executeJobAsync(ctx, computationID, req)
+}
+
 // executeJobAsync executes a computation job asynchronously
-func (ps *privacyService) executeJobAsync(computationID string, req *ComputationRequest) {
+func (ps *privacyService) executeJobAsync(ctx context.Context, computationID string, req *ComputationRequest) {
 	defer ps.wg.Done()
 
+	ctx, span := telemetry.StartSpan(ctx, "privacy.computation",
+		attribute.String("pandacea.computation_id", computationID),
+		attribute.String("pandacea.lease_id", req.LeaseID),
+	)
+	defer span.End()
+
 	ps.logger.Info("starting async job execution", "computation_id", computationID)
 
-	// Acquire container from pool
-	container := ps.acquireContainer()
-	if container == nil {
-		ps.updateJobStatus(computationID, "failed", nil, "failed to acquire container from pool")
+	run, err := ps.runComputation(ctx, computationID, req)
+	if err != nil {
+		ps.updateJobStatus(computationID, "failed", nil, err.Error())
 		return
 	}
-	defer ps.releaseContainer(container)
+	output, artifacts := run.output, run.artifacts
+	digest := resultDigest(output, artifacts)
+	ps.meterJob(computationID, req, run)
+
+	watermarked, err := ps.watermarkResults(req.LeaseID, &output, artifacts)
+	if err != nil {
+		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to watermark results: %v", err))
+		return
+	}
+
+	// Encode artifacts as base64
+	encodedArtifacts := make(map[string]string)
+	for filename, data := range artifacts {
+		encodedArtifacts[filename] = base64.StdEncoding.EncodeToString(data)
+	}
+
+	// Update job status to completed
+	results := &ComputationResults{
+		Output:      output,
+		Artifacts:   encodedArtifacts,
+		Watermarked: watermarked,
+	}
+	var sealErr error
+	switch {
+	case req.RecipientX25519 != nil:
+		sealErr = results.SealX25519(req.RecipientX25519)
+	case req.Recipient != nil:
+		sealErr = results.Seal(req.Recipient)
+	}
+	if sealErr != nil {
+		ps.updateJobStatus(computationID, "failed", nil, sealErr.Error())
+		return
+	}
+	ps.updateJobStatus(computationID, "completed", results, "")
+
+	ps.logger.Info("async job execution completed", "computation_id", computationID)
+
+	ps.attestJob(computationID, req, run, digest)
+	ps.verifyJob(ctx, computationID, req, digest)
+}
+
+// execution is what a computation produced and what it ran on
+type execution struct {
+	output    string
+	artifacts map[string][]byte
+
+	scriptSHA256 string
+	inputs       []attest.Input
+	imageDigest  string // Empty if the runtime does not report one
+	startedAt    time.Time
+	finishedAt   time.Time
+	cpuTime      time.Duration // Zero unless billed and the runtime reports it
+	memoryBytes  int64         // Memory the sandbox was allotted; zero unless billed and the runtime reports it
+}
+
+// runComputation runs a computation in a pooled container with its seed
+// pinned and returns its output and artifacts. Errors are worded for the
+// job record.
+func (ps *privacyService) runComputation(ctx context.Context, computationID string, req *ComputationRequest) (*execution, error) {
+	container, release, err := ps.computationContainer(ctx, computationID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	// Create temporary directory for this computation
 	tempDir, err := os.MkdirTemp("", "pandacea-computation-*")
 	if err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to create temp directory: %v", err))
-		return
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
 	// Fetch computation script from IPFS
-	computationCode, err := ps.fetchContentFromIPFS(context.Background(), req.ComputationCid)
+	computationCode, err := ps.fetchContentFromIPFS(ctx, req.ComputationCid)
 	if err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to fetch computation script from IPFS: %v", err))
-		return
+		return nil, fmt.Errorf("failed to fetch computation script from IPFS: %w", err)
+	}
+	if err := ps.scanScript(ctx, computationCode, req); err != nil {
+		return nil, err
 	}
 
 	// Create Python script file
 	scriptPath := filepath.Join(tempDir, "computation.py")
 	if err := os.WriteFile(scriptPath, []byte(computationCode), 0644); err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to write script file: %v", err))
-		return
+		return nil, fmt.Errorf("failed to write script file: %w", err)
+	}
+
+	// Mount the inputs the computation reads
+	dataDir, inputs, err := ps.mountInputs(ctx, req.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mount data assets: %w", err)
+	}
+	if dataDir != ps.dataDir {
+		defer os.RemoveAll(dataDir)
 	}
 
 	// Create data loading script
 	dataLoaderPath := filepath.Join(tempDir, "data_loader.py")
-	if err := ps.createDataLoader(dataLoaderPath, req.Inputs); err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to create data loader: %v", err))
-		return
+	if err := ps.createDataLoader(dataLoaderPath, inputs); err != nil {
+		return nil, fmt.Errorf("failed to create data loader: %w", err)
 	}
 
 	// Create PySyft Datasite script
-	datasiteScript := ps.createDatasiteScript(req.Inputs)
+	datasiteScript := ps.createDatasiteScript(inputs)
 	datasitePath := filepath.Join(tempDir, "datasite.py")
 	if err := os.WriteFile(datasitePath, []byte(datasiteScript), 0644); err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("failed to write datasite script: %v", err))
-		return
+		return nil, fmt.Errorf("failed to write datasite script: %w", err)
 	}
 
-	// Execute the computation in the container
-	output, artifacts, err := ps.executeInContainer(container, tempDir, scriptPath)
+	// Let the container reach the hosts its products allow, if any
+	ctx, withdraw, err := ps.grantEgress(ctx, container, computationID, req)
 	if err != nil {
-		ps.updateJobStatus(computationID, "failed", nil, fmt.Sprintf("execution error: %v", err))
-		return
+		return nil, fmt.Errorf("failed to grant network egress: %w", err)
+	}
+	defer withdraw()
+
+	// Execute the computation in the container, seeded so that it can be
+	// re-executed to the same result
+	seed := strconv.FormatUint(uint64(computationSeed(computationID)), 10)
+	ctx = withEnv(ctx, "PANDACEA_SEED="+seed, "PYTHONHASHSEED="+seed)
+	run := &execution{
+		scriptSHA256: sha256Hex([]byte(computationCode)),
+		inputs:       ps.attestedInputs(dataDir, inputs),
+		imageDigest:  ps.imageDigest(container),
+		startedAt:    time.Now().UTC(),
+	}
+	cpuBefore, cpuMetered := ps.sandboxCPUTime(container)
+	run.output, run.artifacts, err = ps.executeInContainer(ctx, container, tempDir, dataDir, scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("execution error: %w", err)
+	}
+	run.finishedAt = time.Now().UTC()
+	if cpuAfter, ok := ps.sandboxCPUTime(container); ok && cpuMetered {
+		run.cpuTime = cpuAfter - cpuBefore
 	}
+	run.memoryBytes = ps.sandboxMemory()
+	return run, nil
+}
 
-	// Encode artifacts as base64
-	encodedArtifacts := make(map[string]string)
-	for filename, data := range artifacts {
-		encodedArtifacts[filename] = base64.StdEncoding.EncodeToString(data)
+// computationContainer returns the container a computation runs in and a
+// func that gives it back: a pooled container, or for a GPU computation a
+// container of its own that can use the GPUs the computation holds
+func (ps *privacyService) computationContainer(ctx context.Context, computationID string, req *ComputationRequest) (*DockerContainer, func(), error) {
+	if !req.GPU {
+		container, err := ps.acquireContainer()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to acquire container: %w", err)
+		}
+		acquired := time.Now()
+		return container, func() {
+			if h := ps.history(); h != nil {
+				h.RecordHold(time.Since(acquired))
+			}
+			ps.releaseContainer(container)
+		}, nil
 	}
 
-	// Update job status to completed
-	results := &ComputationResults{
-		Output:    output,
-		Artifacts: encodedArtifacts,
+	rt, ok := ps.runtime.(GPURuntime)
+	if !ok || ps.gpus == nil {
+		return nil, nil, fmt.Errorf("%w: the sandbox cannot use GPUs", gpu.ErrUnavailable)
 	}
-	ps.updateJobStatus(computationID, "completed", results, "")
+	devices, err := ps.gpus.Acquire(ctx, req.GPUCount)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to acquire GPUs: %w", err)
+	}
+	id, err := rt.CreateWithGPUs(devices)
+	if err != nil {
+		ps.gpus.Release(devices)
+		return nil, nil, fmt.Errorf("failed to create GPU container: %w", err)
+	}
+	ps.jobsMutex.Lock()
+	if job, exists := ps.jobs[computationID]; exists && job.GPU != nil {
+		job.GPU.Devices = devices
+	}
+	ps.jobsMutex.Unlock()
 
-	ps.logger.Info("async job execution completed", "computation_id", computationID)
+	container := &DockerContainer{ID: id, IsActive: true}
+	return container, func() {
+		// Keep the utilization the computation last saw
+		ps.jobsMutex.Lock()
+		if job, exists := ps.jobs[computationID]; exists {
+			job.GPU = ps.gpuStatus(job)
+		}
+		ps.jobsMutex.Unlock()
+		ps.destroyContainer(container)
+		ps.gpus.Release(devices)
+	}, nil
+}
+
+// gpuStatus returns a GPU computation's allocation with the latest
+// utilization of its GPUs while it runs, or nil for other computations.
+// Caller must hold jobsMutex.
+func (ps *privacyService) gpuStatus(job *ComputationJob) *gpu.Allocation {
+	if job.GPU == nil {
+		return nil
+	}
+	status := *job.GPU
+	if ps.gpuMonitor != nil && len(status.Devices) > 0 && job.Status == string(jobs.StatePending) {
+		status.Utilization = ps.gpuMonitor.Usage(status.Devices)
+	}
+	return &status
 }
 
 // updateJobStatus updates the status of a computation job
@@ -322,33 +917,208 @@ func (ps *privacyService) updateJobStatus(computationID, status string, results
 	ps.jobsMutex.Lock()
 	defer ps.jobsMutex.Unlock()
 
-	if job, exists := ps.jobs[computationID]; exists {
-		job.Status = status
-		job.UpdatedAt = time.Now()
-		if results != nil {
-			job.Results = results
+	job, exists := ps.jobs[computationID]
+	if !exists {
+		ps.logger.Error("job not found for status update", "computation_id", computationID)
+		return
+	}
+
+	ps.setJobStatus(job, status, results, errorMsg)
+}
+
+// setJobStatus applies a validated status transition. Caller must hold jobsMutex.
+func (ps *privacyService) setJobStatus(job *ComputationJob, status string, results *ComputationResults, errorMsg string) {
+	if err := computationJobs.Transition(jobs.State(job.Status), jobs.State(status), job.CreatedAt); err != nil {
+		ps.logger.Warn("rejected job status update", "computation_id", job.ID, "error", err)
+		return
+	}
+
+	job.Status = status
+	job.UpdatedAt = time.Now()
+	if results != nil {
+		job.Results = results
+	}
+	if errorMsg != "" {
+		job.Error = errorMsg
+	}
+	ps.persistJob(job)
+
+	if ps.onStatus != nil {
+		ps.onStatus(job.ID, status)
+	}
+
+	ps.logger.Info("job status updated", "computation_id", job.ID, "status", status)
+}
+
+// OnStatusChange implements StatusNotifier
+func (ps *privacyService) OnStatusChange(fn func(computationID, status string)) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.onStatus = fn
+}
+
+// WatermarkResults implements ResultWatermarker
+func (ps *privacyService) WatermarkResults(marker *watermark.Marker) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.marker = marker
+}
+
+// watermarkResults embeds the lease's watermark into output and artifacts
+// that are JSON documents. Other formats are delivered unmarked. It reports
+// whether watermarking is enabled.
+func (ps *privacyService) watermarkResults(leaseID string, output *string, artifacts map[string][]byte) (bool, error) {
+	ps.jobsMutex.RLock()
+	marker := ps.marker
+	ps.jobsMutex.RUnlock()
+	if marker == nil {
+		return false, nil
+	}
+
+	if json.Valid([]byte(*output)) {
+		marked, _, err := marker.EmbedJSON(leaseID, []byte(*output))
+		if err != nil {
+			return false, fmt.Errorf("output: %w", err)
+		}
+		*output = string(marked)
+	}
+	for filename, data := range artifacts {
+		if !json.Valid(data) {
+			continue
+		}
+		marked, _, err := marker.EmbedJSON(leaseID, data)
+		if err != nil {
+			return false, fmt.Errorf("artifact %s: %w", filename, err)
+		}
+		artifacts[filename] = marked
+	}
+	return true, nil
+}
+
+// persistJob saves a job snapshot if a job store is configured. Caller must hold jobsMutex.
+func (ps *privacyService) persistJob(job *ComputationJob) {
+	if ps.jobStore == nil {
+		return
+	}
+	if err := ps.jobStore.Save(job.ID, job); err != nil {
+		ps.logger.Error("failed to persist computation job", "computation_id", job.ID, "error", err)
+	}
+}
+
+// PoolSize returns the container pool's target size. It implements
+// autoscale.Pool.
+func (ps *privacyService) PoolSize() int {
+	ps.poolMutex.Lock()
+	defer ps.poolMutex.Unlock()
+	return ps.poolSize
+}
+
+// ResizePool changes the container pool's target size. Growing starts
+// containers at once; shrinking destroys idle containers at once and busy
+// ones as they are released. It implements autoscale.Pool.
+func (ps *privacyService) ResizePool(size int) error {
+	if size < 1 || size > maxPoolSize {
+		return fmt.Errorf("pool size must be between 1 and %d", maxPoolSize)
+	}
+	ps.poolMutex.Lock()
+	ps.poolSize = size
+	ps.poolMutex.Unlock()
+
+	ps.fillPool()
+	for {
+		ps.poolMutex.Lock()
+		excess := ps.live > ps.poolSize
+		ps.poolMutex.Unlock()
+		if !excess {
+			return nil
 		}
-		if errorMsg != "" {
-			job.Error = errorMsg
+		select {
+		case container := <-ps.containerPool:
+			ps.retireContainer(container)
+		default:
+			// The rest are busy and are destroyed on release
+			return nil
 		}
 	}
+}
+
+// UseLoadHistory implements PoolAutoscaler
+func (ps *privacyService) UseLoadHistory(h *autoscale.History) {
+	ps.poolMutex.Lock()
+	defer ps.poolMutex.Unlock()
+	ps.loadHistory = h
+}
 
-	ps.logger.Info("job status updated", "computation_id", computationID, "status", status)
+// history returns the load history, or nil
+func (ps *privacyService) history() *autoscale.History {
+	ps.poolMutex.Lock()
+	defer ps.poolMutex.Unlock()
+	return ps.loadHistory
+}
+
+// fillPool creates containers until the pool reaches its target size
+func (ps *privacyService) fillPool() {
+	for {
+		ps.poolMutex.Lock()
+		if ps.live >= ps.poolSize {
+			ps.poolMutex.Unlock()
+			return
+		}
+		ps.live++
+		ps.poolMutex.Unlock()
+
+		container, err := ps.createContainer()
+		if err != nil {
+			ps.logger.Error("failed to create container for pool", "error", err)
+			ps.poolMutex.Lock()
+			ps.live--
+			ps.poolMutex.Unlock()
+			return
+		}
+		// The pool's capacity exceeds any target size, so this never blocks
+		ps.poolMutex.Lock()
+		closed := ps.poolClosed
+		if !closed {
+			ps.containerPool <- container
+		}
+		ps.poolMutex.Unlock()
+		if closed {
+			ps.destroyContainer(container)
+			return
+		}
+	}
+}
+
+// retireContainer destroys a container the pool no longer needs
+func (ps *privacyService) retireContainer(container *DockerContainer) {
+	ps.poolMutex.Lock()
+	ps.live--
+	ps.poolMutex.Unlock()
+	ps.destroyContainer(container)
 }
 
 // acquireContainer acquires a container from the pool
-func (ps *privacyService) acquireContainer() *DockerContainer {
+func (ps *privacyService) acquireContainer() (*DockerContainer, error) {
 	select {
 	case container := <-ps.containerPool:
-		return container
+		return container, nil
 	case <-time.After(30 * time.Second):
 		ps.logger.Error("timeout waiting for container from pool")
-		return nil
+		return nil, ErrPoolExhausted
 	}
 }
 
 // releaseContainer returns a container to the pool
 func (ps *privacyService) releaseContainer(container *DockerContainer) {
+	// Containers beyond a shrunk pool's size are destroyed
+	ps.poolMutex.Lock()
+	excess := ps.live > ps.poolSize
+	ps.poolMutex.Unlock()
+	if excess {
+		ps.retireContainer(container)
+		return
+	}
+
 	// Clean the container before returning to pool
 	if err := ps.cleanContainer(container); err != nil {
 		ps.logger.Error("failed to clean container", "container_id", container.ID, "error", err)
@@ -357,6 +1127,9 @@ func (ps *privacyService) releaseContainer(container *DockerContainer) {
 		newContainer, err := ps.createContainer()
 		if err != nil {
 			ps.logger.Error("failed to create replacement container", "error", err)
+			ps.poolMutex.Lock()
+			ps.live--
+			ps.poolMutex.Unlock()
 			return
 		}
 		container = newContainer
@@ -367,26 +1140,16 @@ func (ps *privacyService) releaseContainer(container *DockerContainer) {
 		// Container returned to pool successfully
 	default:
 		// Pool is full, destroy the container
-		ps.destroyContainer(container)
+		ps.retireContainer(container)
 	}
 }
 
-// createContainer creates a new Docker container
+// createContainer creates a new container
 func (ps *privacyService) createContainer() (*DockerContainer, error) {
-	// Create a new PySyft container
-	cmd := exec.Command("docker", "run", "-d",
-		"--network", "none",
-		"--memory", "512m",
-		"--cpus", "1",
-		"pandacea/pysyft-datasite:latest",
-		"tail", "-f", "/dev/null") // Keep container running
-
-	output, err := cmd.CombinedOutput()
+	containerID, err := ps.runtime.Create()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w, output: %s", err, string(output))
+		return nil, err
 	}
-
-	containerID := strings.TrimSpace(string(output))
 	ps.logger.Info("created container", "container_id", containerID)
 
 	return &DockerContainer{
@@ -395,14 +1158,13 @@ func (ps *privacyService) createContainer() (*DockerContainer, error) {
 	}, nil
 }
 
-// destroyContainer destroys a Docker container
+// destroyContainer destroys a container
 func (ps *privacyService) destroyContainer(container *DockerContainer) {
 	if container == nil || !container.IsActive {
 		return
 	}
 
-	cmd := exec.Command("docker", "rm", "-f", container.ID)
-	if err := cmd.Run(); err != nil {
+	if err := ps.runtime.Remove(container.ID); err != nil {
 		ps.logger.Error("failed to destroy container", "container_id", container.ID, "error", err)
 	} else {
 		ps.logger.Info("destroyed container", "container_id", container.ID)
@@ -418,25 +1180,27 @@ func (ps *privacyService) cleanContainer(container *DockerContainer) error {
 	}
 
 	// Clean the workspace directory
-	cmd := exec.Command("docker", "exec", container.ID, "rm", "-rf", "/workspace/*")
-	return cmd.Run()
+	return ps.runtime.Clean(container.ID)
 }
 
-// executeInContainer executes computation in a specific container
-func (ps *privacyService) executeInContainer(container *DockerContainer, tempDir, scriptPath string) (string, map[string][]byte, error) {
+// executeInContainer executes computation in a specific container, with
+// dataDir mounted as /data
+func (ps *privacyService) executeInContainer(ctx context.Context, container *DockerContainer, tempDir, dataDir, scriptPath string) (_ string, _ map[string][]byte, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "container.exec", attribute.String("pandacea.container_id", container.ID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Copy files to container
 	if err := ps.copyToContainer(container.ID, tempDir, "/workspace"); err != nil {
 		return "", nil, fmt.Errorf("failed to copy files to container: %w", err)
 	}
 
 	// Copy data directory to container
-	if err := ps.copyToContainer(container.ID, ps.dataDir, "/data"); err != nil {
+	if err := ps.copyToContainer(container.ID, dataDir, "/data"); err != nil {
 		return "", nil, fmt.Errorf("failed to copy data to container: %w", err)
 	}
 
 	// Execute the computation
-	cmd := exec.Command("docker", "exec", container.ID, "python", "/workspace/datasite.py")
-	output, err := cmd.CombinedOutput()
+	output, err := ps.runtime.Exec(ctx, container.ID, "python", "/workspace/datasite.py")
 	if err != nil {
 		return string(output), nil, fmt.Errorf("container execution failed: %w", err)
 	}
@@ -464,17 +1228,19 @@ func (ps *privacyService) executeInContainer(container *DockerContainer, tempDir
 
 // copyToContainer copies files from host to container
 func (ps *privacyService) copyToContainer(containerID, srcPath, destPath string) error {
-	cmd := exec.Command("docker", "cp", srcPath, containerID+":"+destPath)
-	return cmd.Run()
+	return ps.runtime.CopyTo(containerID, srcPath, destPath)
 }
 
-// generateComputationID generates a unique computation ID
-func (ps *privacyService) generateComputationID() string {
+// NewComputationID generates a unique computation ID
+func NewComputationID() string {
 	return fmt.Sprintf("comp-%d", time.Now().UnixNano())
 }
 
 // VerifyLease verifies that a lease is valid and active
-func (ps *privacyService) VerifyLease(ctx context.Context, leaseID string, spenderAddr string) error {
+func (ps *privacyService) VerifyLease(ctx context.Context, leaseID string, spenderAddr string) (err error) {
+	ctx, span := telemetry.StartSpan(ctx, "chain.verify_lease", attribute.String("pandacea.lease_id", leaseID))
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Convert lease ID to bytes32
 	if !strings.HasPrefix(leaseID, "0x") {
 		leaseID = "0x" + leaseID
@@ -482,75 +1248,348 @@ func (ps *privacyService) VerifyLease(ctx context.Context, leaseID string, spend
 
 	leaseIDBytes := common.FromHex(leaseID)
 	if len(leaseIDBytes) != 32 {
-		return fmt.Errorf("invalid lease ID format")
+		return ErrInvalidLeaseID
 	}
 
 	var leaseIDArray [32]byte
 	copy(leaseIDArray[:], leaseIDBytes)
 
+	contract, err := ps.leaseContract(ctx)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+
 	// Check if lease exists
-	exists, err := ps.contract.LeaseExists(nil, leaseIDArray)
+	exists, err := contract.LeaseExists(opts, leaseIDArray)
 	if err != nil {
 		return fmt.Errorf("failed to check lease existence: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("lease does not exist")
+		return ErrLeaseNotFound
 	}
 
 	// Get lease details
-	lease, err := ps.contract.GetLease(nil, leaseIDArray)
+	lease, err := contract.GetLease(opts, leaseIDArray)
 	if err != nil {
 		return fmt.Errorf("failed to get lease details: %w", err)
 	}
 
 	// Verify lease is approved
 	if !lease.IsApproved {
-		return fmt.Errorf("lease is not approved")
+		return ErrLeaseNotApproved
 	}
 
 	// Verify lease is not executed
 	if lease.IsExecuted {
-		return fmt.Errorf("lease has already been executed")
+		return ErrLeaseExecuted
 	}
 
 	// Verify lease is not disputed
 	if lease.IsDisputed {
-		return fmt.Errorf("lease is disputed")
+		return ErrLeaseDisputed
 	}
 
-	// Verify spender address matches
-	if !strings.EqualFold(lease.Spender.Hex(), spenderAddr) {
-		return fmt.Errorf("spender address mismatch")
+	// Verify spender address matches the lease's current holder
+	holder := lease.Spender.Hex()
+	if ps.assignments != nil {
+		if assignment, assigned := ps.assignments.Holder(leaseID); assigned {
+			holder = assignment.To
+		}
+	}
+	if !strings.EqualFold(holder, spenderAddr) {
+		return ErrSpenderMismatch
 	}
 
 	return nil
 }
 
+// UseAssignments implements LeaseAssigner
+func (ps *privacyService) UseAssignments(registry *AssignmentRegistry) {
+	ps.assignments = registry
+}
+
+// UseContainerRuntime implements ContainerRunner
+func (ps *privacyService) UseContainerRuntime(rt ContainerRuntime) {
+	ps.poolMutex.Lock()
+	defer ps.poolMutex.Unlock()
+	ps.runtime = rt
+}
+
+// UseScheduler implements JobScheduler
+func (ps *privacyService) UseScheduler(s *scheduler.Scheduler) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.scheduler = s
+}
+
+// UseGPUs implements GPUScheduler
+func (ps *privacyService) UseGPUs(s *scheduler.Scheduler, devices *gpu.Allocator, monitor *gpu.Monitor) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.gpuScheduler, ps.gpus, ps.gpuMonitor = s, devices, monitor
+}
+
+// UseAssets implements AssetMounter
+func (ps *privacyService) UseAssets(registry *assets.Registry) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.assetRegistry = registry
+}
+
+// registry returns the asset registry, or nil
+func (ps *privacyService) registry() *assets.Registry {
+	ps.jobsMutex.RLock()
+	defer ps.jobsMutex.RUnlock()
+	return ps.assetRegistry
+}
+
+// UseKeyring implements DataDecrypter
+func (ps *privacyService) UseKeyring(k *atrest.Keyring) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.keyring = k
+}
+
+// UseScriptScanner implements ScriptScanner
+func (ps *privacyService) UseScriptScanner(s *scriptscan.Scanner) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.scanner = s
+}
+
+// scanScript checks a computation's script against the policies of its
+// products, if a scanner is in use
+func (ps *privacyService) scanScript(ctx context.Context, code string, req *ComputationRequest) error {
+	ps.jobsMutex.RLock()
+	scanner := ps.scanner
+	ps.jobsMutex.RUnlock()
+	if scanner == nil {
+		return nil
+	}
+	report, err := scanner.Scan(ctx, code, ps.inputProducts(req.Inputs))
+	if err != nil {
+		return fmt.Errorf("failed to scan computation script: %w", err)
+	}
+	if err := report.Err(); err != nil {
+		ps.logger.Warn("computation script rejected", "lease_id", req.LeaseID, "cid", req.ComputationCid, "findings", len(report.Findings))
+		return err
+	}
+	return nil
+}
+
+// UseEgress implements EgressGranter
+func (ps *privacyService) UseEgress(proxy *egress.Proxy, policy egress.Policy) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.egressProxy = proxy
+	ps.egressPolicy = policy
+}
+
+// inputProducts returns the products a computation's inputs belong to. An
+// input not in the asset registry names its product.
+func (ps *privacyService) inputProducts(inputs []DataInput) []string {
+	registry := ps.registry()
+	products := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		product := input.AssetID
+		if registry != nil {
+			if asset, ok := registry.Get(input.AssetID); ok {
+				product = asset.ProductID
+			}
+		}
+		if !slices.Contains(products, product) {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// grantEgress connects container to the egress network with a grant for
+// the hosts every product of the computation allows. It returns the context
+// to run the computation with, which points it at the proxy, and a function
+// that withdraws the grant. Computations allowed no hosts are left as they
+// are, which requires a runtime whose sandboxes start without a network.
+func (ps *privacyService) grantEgress(ctx context.Context, container *DockerContainer, computationID string, req *ComputationRequest) (context.Context, func(), error) {
+	if networked, ok := ps.runtime.(NetworkedRuntime); ok && networked.AlwaysNetworked() {
+		return ctx, nil, fmt.Errorf("the sandbox backend cannot isolate computations from the network")
+	}
+	ps.jobsMutex.RLock()
+	proxy, policy := ps.egressProxy, ps.egressPolicy
+	ps.jobsMutex.RUnlock()
+	if proxy == nil {
+		return ctx, func() {}, nil
+	}
+	products := ps.inputProducts(req.Inputs)
+	hosts := policy.Allowed(products)
+	if len(hosts) == 0 {
+		return ctx, func() {}, nil
+	}
+	attacher, ok := ps.runtime.(EgressAttacher)
+	if !ok {
+		return ctx, nil, fmt.Errorf("the sandbox backend cannot reach the egress proxy")
+	}
+
+	grant := proxy.Grant(hosts, map[string]string{
+		"computation_id": computationID,
+		"lease_id":       req.LeaseID,
+		"products":       strings.Join(products, ","),
+	})
+	if err := attacher.AttachEgress(container.ID); err != nil {
+		grant.Revoke()
+		return ctx, nil, err
+	}
+	ps.logger.Info("granted network egress", "computation_id", computationID, "hosts", hosts)
+
+	proxyURL := grant.URL()
+	ctx = withEnv(ctx,
+		"HTTP_PROXY="+proxyURL, "HTTPS_PROXY="+proxyURL,
+		"http_proxy="+proxyURL, "https_proxy="+proxyURL)
+	return ctx, func() {
+		grant.Revoke()
+		if err := attacher.DetachEgress(container.ID); err != nil {
+			// A container left on the egress network is not reused
+			ps.logger.Error("failed to detach container from egress network", "container_id", container.ID, "error", err)
+			ps.destroyContainer(container)
+		}
+	}, nil
+}
+
+// mountedInput is a computation input as the container loads it
+type mountedInput struct {
+	DataInput
+	file   string // Name under /data
+	format assets.Format
+	schema []assets.Column // Types partition values; nil for unregistered inputs
+}
+
+// mountInputs returns the directory to mount as /data and where each input
+// is found in it. Registered assets are copied into a directory of their
+// own; without a registry the whole data directory is mounted and inputs
+// are read as <asset_id>.csv.
+func (ps *privacyService) mountInputs(ctx context.Context, inputs []DataInput) (string, []mountedInput, error) {
+	mounted := make([]mountedInput, len(inputs))
+	registry := ps.registry()
+	if registry == nil {
+		for i, input := range inputs {
+			mounted[i] = mountedInput{DataInput: input, file: input.AssetID + ".csv", format: assets.FormatCSV}
+		}
+		ps.jobsMutex.RLock()
+		keyring := ps.keyring
+		ps.jobsMutex.RUnlock()
+		if keyring == nil {
+			return ps.dataDir, mounted, nil
+		}
+		dir, err := ps.decryptInputs(keyring, mounted)
+		return dir, mounted, err
+	}
+
+	dir, err := os.MkdirTemp("", "pandacea-data-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	for i, input := range inputs {
+		asset, ok := registry.Get(input.AssetID)
+		if !ok {
+			os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("%w: %s", assets.ErrNotFound, input.AssetID)
+		}
+		file, err := registry.Mount(ctx, input.AssetID, dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+		mounted[i] = mountedInput{DataInput: input, file: file, format: asset.Format, schema: asset.Schema}
+	}
+	return dir, mounted, nil
+}
+
+// decryptInputs copies inputs from the data directory into a new directory,
+// decrypting those encrypted at rest, so the sandbox never sees ciphertext
+func (ps *privacyService) decryptInputs(keyring *atrest.Keyring, inputs []mountedInput) (string, error) {
+	dir, err := os.MkdirTemp("", "pandacea-data-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	for _, input := range inputs {
+		data, err := keyring.ReadFile(filepath.Join(ps.dataDir, input.file))
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to read input %s: %w", input.AssetID, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, input.file), data, 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to mount input %s: %w", input.AssetID, err)
+		}
+	}
+	return dir, nil
+}
+
 // validateComputationRequest validates the computation request
 func (ps *privacyService) validateComputationRequest(req *ComputationRequest) error {
 	if req.LeaseID == "" {
-		return fmt.Errorf("lease_id is required")
+		return fmt.Errorf("%w: lease_id is required", ErrInvalidRequest)
 	}
 
 	if req.ComputationCid == "" {
-		return fmt.Errorf("computationCid is required")
+		return fmt.Errorf("%w: computationCid is required", ErrInvalidRequest)
 	}
 
 	// Basic CID validation
 	if len(req.ComputationCid) != 46 || req.ComputationCid[0] != 'Q' { // IPFS CID is 46 characters long and starts with 'Q'
-		return fmt.Errorf("invalid IPFS CID format")
+		return fmt.Errorf("%w: invalid IPFS CID format", ErrInvalidRequest)
 	}
 
 	if len(req.Inputs) == 0 {
-		return fmt.Errorf("at least one input is required")
+		return fmt.Errorf("%w: at least one input is required", ErrInvalidRequest)
+	}
+
+	if req.LeasePurpose != "" && req.Purpose != req.LeasePurpose {
+		if req.Purpose == "" {
+			return fmt.Errorf("%w: the lease was granted for %s, declare it as purpose", ErrPurposeMismatch, req.LeasePurpose)
+		}
+		return fmt.Errorf("%w: declared %s, the lease was granted for %s", ErrPurposeMismatch, req.Purpose, req.LeasePurpose)
+	}
+
+	if req.GPUCount < 0 || (req.GPUCount > 0 && !req.GPU) {
+		return fmt.Errorf("%w: gpu_count must be positive and requires gpu", ErrInvalidRequest)
+	}
+	if req.GPU {
+		req.GPUCount = max(req.GPUCount, 1)
+		ps.jobsMutex.RLock()
+		devices := ps.gpus
+		ps.jobsMutex.RUnlock()
+		if _, ok := ps.runtime.(GPURuntime); !ok || devices == nil {
+			return fmt.Errorf("%w: this agent offers no GPUs to computations", gpu.ErrUnavailable)
+		}
+		if req.GPUCount > devices.Total() {
+			return fmt.Errorf("%w: %d requested, %d on this agent", gpu.ErrUnavailable, req.GPUCount, devices.Total())
+		}
 	}
 
 	for _, input := range req.Inputs {
 		if input.AssetID == "" {
-			return fmt.Errorf("asset_id is required for all inputs")
+			return fmt.Errorf("%w: asset_id is required for all inputs", ErrInvalidRequest)
 		}
 		if input.VariableName == "" {
-			return fmt.Errorf("variable_name is required for all inputs")
+			return fmt.Errorf("%w: variable_name is required for all inputs", ErrInvalidRequest)
+		}
+		registry := ps.registry()
+		if registry == nil {
+			if len(input.Partitions) > 0 {
+				return fmt.Errorf("%w: partitions can only be selected from registered data assets", ErrInvalidRequest)
+			}
+			if err := validateSelection(input, assets.Asset{}); err != nil {
+				return err
+			}
+			continue
+		}
+		asset, ok := registry.Get(input.AssetID)
+		if !ok {
+			return fmt.Errorf("%w: data asset %s is not registered", ErrInvalidRequest, input.AssetID)
+		}
+		if err := validateSelection(input, asset); err != nil {
+			return err
 		}
 	}
 
@@ -558,16 +1597,18 @@ func (ps *privacyService) validateComputationRequest(req *ComputationRequest) er
 }
 
 // createDataLoader creates a Python script to load data assets
-func (ps *privacyService) createDataLoader(scriptPath string, inputs []DataInput) error {
+func (ps *privacyService) createDataLoader(scriptPath string, inputs []mountedInput) error {
 	var dataLoaderCode strings.Builder
 	dataLoaderCode.WriteString("import pandas as pd\n")
 	dataLoaderCode.WriteString("import os\n\n")
 
 	for _, input := range inputs {
 		dataLoaderCode.WriteString(fmt.Sprintf("# Load %s\n", input.AssetID))
-		dataLoaderCode.WriteString(fmt.Sprintf("data_path = os.path.join('/data', '%s.csv')\n", input.AssetID))
+		dataLoaderCode.WriteString(fmt.Sprintf("data_path = os.path.join('/data', '%s')\n", input.file))
 		dataLoaderCode.WriteString(fmt.Sprintf("if os.path.exists(data_path):\n"))
-		dataLoaderCode.WriteString(fmt.Sprintf("    %s = pd.read_csv(data_path)\n", input.VariableName))
+		for _, statement := range loadStatements(input) {
+			dataLoaderCode.WriteString(fmt.Sprintf("    %s\n", statement))
+		}
 		dataLoaderCode.WriteString(fmt.Sprintf("else:\n"))
 		dataLoaderCode.WriteString(fmt.Sprintf("    raise FileNotFoundError(f'Data asset {input.AssetID} not found')\n\n"))
 	}
@@ -576,7 +1617,7 @@ func (ps *privacyService) createDataLoader(scriptPath string, inputs []DataInput
 }
 
 // createDatasiteScript creates a PySyft Datasite script
-func (ps *privacyService) createDatasiteScript(inputs []DataInput) string {
+func (ps *privacyService) createDatasiteScript(inputs []mountedInput) string {
 	var script strings.Builder
 
 	script.WriteString(`import syft as sy
@@ -585,6 +1626,19 @@ import pandas as pd
 import os
 import sys
 
+# Pin randomness to the seed the agent chose, so the computation can be
+# re-executed to the same result
+import random
+seed = int(os.environ.get('PANDACEA_SEED', '0'))
+random.seed(seed)
+torch.manual_seed(seed)
+torch.use_deterministic_algorithms(True, warn_only=True)
+try:
+    import numpy
+    numpy.random.seed(seed)
+except ImportError:
+    pass
+
 # Initialize PySyft
 sy.load("pandas")
 sy.load("torch")
@@ -596,9 +1650,11 @@ vm = sy.VirtualMachine(name="pandacea-datasite")
 `)
 
 	for _, input := range inputs {
-		script.WriteString(fmt.Sprintf("data_path = os.path.join('/data', '%s.csv')\n", input.AssetID))
+		script.WriteString(fmt.Sprintf("data_path = os.path.join('/data', '%s')\n", input.file))
 		script.WriteString(fmt.Sprintf("if os.path.exists(data_path):\n"))
-		script.WriteString(fmt.Sprintf("    %s = pd.read_csv(data_path)\n", input.VariableName))
+		for _, statement := range loadStatements(input) {
+			script.WriteString(fmt.Sprintf("    %s\n", statement))
+		}
 		script.WriteString(fmt.Sprintf("    # Convert to PySyft tensor if needed\n"))
 		script.WriteString(fmt.Sprintf("    if isinstance(%s, pd.DataFrame):\n", input.VariableName))
 		script.WriteString(fmt.Sprintf("        %s = torch.tensor(%s.values, dtype=torch.float32)\n", input.VariableName, input.VariableName))
@@ -621,7 +1677,10 @@ print("Computation completed successfully")
 }
 
 // fetchContentFromIPFS fetches content from IPFS using the provided CID
-func (ps *privacyService) fetchContentFromIPFS(ctx context.Context, cid string) (string, error) {
+func (ps *privacyService) fetchContentFromIPFS(ctx context.Context, cid string) (_ string, err error) {
+	ctx, span := telemetry.StartSpan(ctx, "ipfs.fetch", attribute.String("pandacea.cid", cid))
+	defer func() { telemetry.EndSpan(span, err) }()
+
 	// Construct the IPFS API URL for cat operation
 	url := fmt.Sprintf("%s/api/v0/cat?arg=%s", ps.ipfsAPIURL, cid)
 
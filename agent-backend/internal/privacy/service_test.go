@@ -0,0 +1,357 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/assets"
+	"pandacea/agent-backend/internal/atrest"
+	"pandacea/agent-backend/internal/egress"
+	"pandacea/agent-backend/internal/gpu"
+	"pandacea/agent-backend/internal/metering"
+	"pandacea/agent-backend/internal/scriptscan"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMountInputs(t *testing.T) {
+	dataDir := t.TempDir()
+	ps := &privacyService{dataDir: dataDir}
+	inputs := []DataInput{{AssetID: "scans", VariableName: "df"}}
+
+	// Without a registry the data directory is mounted and inputs read as CSV
+	dir, mounted, err := ps.mountInputs(context.Background(), inputs)
+	if err != nil || dir != dataDir || mounted[0].file != "scans.csv" {
+		t.Fatalf("mountInputs() without registry = %q, %+v, %v", dir, mounted, err)
+	}
+
+	// Inputs encrypted at rest are mounted decrypted in a directory of their own
+	keyring, err := atrest.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.WriteFile(filepath.Join(dataDir, "scans.csv"), []byte("id\n1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ps.UseKeyring(keyring)
+	dir, _, err = ps.mountInputs(context.Background(), inputs)
+	if err != nil || dir == dataDir {
+		t.Fatalf("mountInputs() with keyring = %q, %v", dir, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "scans.csv")); string(data) != "id\n1\n" {
+		t.Errorf("mounted %q, want the decrypted input", data)
+	}
+	os.RemoveAll(dir)
+
+	registry, err := assets.NewRegistry("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(t.TempDir(), "scans.jsonl")
+	if err := os.WriteFile(source, []byte("{\"id\":1}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register(context.Background(), assets.Asset{ID: "scans", ProductID: "product-1", Source: source, Format: assets.FormatJSONL}); err != nil {
+		t.Fatal(err)
+	}
+	ps.UseAssets(registry)
+
+	dir, mounted, err = ps.mountInputs(context.Background(), inputs)
+	if err != nil {
+		t.Fatalf("mountInputs() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if dir == dataDir {
+		t.Error("registered assets were read from the shared data directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scans.jsonl")); err != nil {
+		t.Errorf("asset not mounted: %v", err)
+	}
+
+	loader := filepath.Join(t.TempDir(), "data_loader.py")
+	if err := ps.createDataLoader(loader, mounted); err != nil {
+		t.Fatal(err)
+	}
+	script, _ := os.ReadFile(loader)
+	if !strings.Contains(string(script), "'scans.jsonl'") || !strings.Contains(string(script), "df = pd.read_json(data_path, lines=True)") {
+		t.Errorf("loader does not read the asset in its format:\n%s", script)
+	}
+
+	// Unregistered assets are refused before a computation is queued
+	req := &ComputationRequest{
+		LeaseID:        "lease-1",
+		ComputationCid: "Qm" + strings.Repeat("a", 44),
+		Inputs:         []DataInput{{AssetID: "unknown", VariableName: "df"}},
+	}
+	if err := ps.validateComputationRequest(req); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("validateComputationRequest(unregistered) error = %v, want %v", err, ErrInvalidRequest)
+	}
+}
+
+// egressRuntime records which sandboxes are on the egress network
+type egressRuntime struct {
+	attached map[string]bool
+}
+
+func (egressRuntime) Create() (string, error)                                 { return "sandbox-1", nil }
+func (egressRuntime) Remove(string) error                                     { return nil }
+func (egressRuntime) Clean(string) error                                      { return nil }
+func (egressRuntime) CopyTo(string, string, string) error                     { return nil }
+func (egressRuntime) Exec(context.Context, string, ...string) ([]byte, error) { return nil, nil }
+func (r egressRuntime) AttachEgress(id string) error                          { r.attached[id] = true; return nil }
+func (r egressRuntime) DetachEgress(id string) error                          { delete(r.attached, id); return nil }
+
+func TestGrantEgress(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	runtime := egressRuntime{attached: make(map[string]bool)}
+	ps := &privacyService{logger: logger, runtime: runtime}
+	container := &DockerContainer{ID: "sandbox-1", IsActive: true}
+	req := &ComputationRequest{LeaseID: "lease-1", Inputs: []DataInput{{AssetID: "weights"}, {AssetID: "scans"}}}
+
+	// Without a proxy every computation stays off the network
+	ctx, withdraw, err := ps.grantEgress(context.Background(), container, "comp-1", req)
+	if err != nil || len(runtime.attached) > 0 || len(sandboxEnviron(ctx)) > 0 {
+		t.Fatalf("grantEgress() without proxy attached %v, env %v, err %v", runtime.attached, sandboxEnviron(ctx), err)
+	}
+	withdraw()
+
+	proxy, err := egress.NewProxy("http://172.30.0.1:3128", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []egress.Event
+	proxy.OnEvent(func(e egress.Event) { events = append(events, e) })
+	ps.UseEgress(proxy, egress.Policy{"weights": {"huggingface.co"}})
+
+	// Each product of the computation must allow a host
+	if _, withdraw, err = ps.grantEgress(context.Background(), container, "comp-1", req); err != nil || len(runtime.attached) > 0 {
+		t.Fatalf("grantEgress() on a product allowing nothing attached %v, err %v", runtime.attached, err)
+	}
+	withdraw()
+
+	req.Inputs = req.Inputs[:1]
+	ctx, withdraw, err = ps.grantEgress(context.Background(), container, "comp-1", req)
+	if err != nil || !runtime.attached["sandbox-1"] {
+		t.Fatalf("grantEgress() attached %v, err %v", runtime.attached, err)
+	}
+	env := strings.Join(sandboxEnviron(ctx), " ")
+	if !strings.Contains(env, "HTTPS_PROXY=http://computation:") || !strings.Contains(env, "@172.30.0.1:3128") {
+		t.Errorf("computation not pointed at the proxy: %s", env)
+	}
+	if len(events) != 1 || events[0].Kind != egress.EventGranted || events[0].Labels["computation_id"] != "comp-1" || events[0].Hosts[0] != "huggingface.co" {
+		t.Errorf("events = %+v", events)
+	}
+	withdraw()
+	if len(runtime.attached) > 0 {
+		t.Error("container left on the egress network")
+	}
+
+	// Backends that cannot reach the proxy fail rather than run without it
+	ps.runtime = &WASMRuntime{}
+	if _, _, err := ps.grantEgress(context.Background(), container, "comp-1", req); err == nil {
+		t.Error("grantEgress() on a wasm sandbox succeeded")
+	}
+
+	// Backends that cannot isolate a sandbox fail even when egress is denied
+	ps.runtime = FirecrackerRuntime{}
+	ps.UseEgress(nil, nil)
+	if _, _, err := ps.grantEgress(context.Background(), container, "comp-1", req); err == nil {
+		t.Error("grantEgress() on a firecracker sandbox succeeded")
+	}
+}
+
+func TestRunComputationScansScript(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "import socket\nsocket.create_connection(('example.com', 80))\n")
+	}))
+	defer ipfs.Close()
+
+	var runs int
+	var env []string
+	ps := &privacyService{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		runtime:       replayRuntime{outputs: []string{"ok"}, runs: &runs, env: &env},
+		httpClient:    ipfs.Client(),
+		ipfsAPIURL:    ipfs.URL,
+		dataDir:       t.TempDir(),
+		containerPool: make(chan *DockerContainer, 1),
+		poolSize:      1,
+		live:          1,
+	}
+	ps.containerPool <- &DockerContainer{ID: "sandbox-1", IsActive: true}
+	scanner, err := scriptscan.NewScanner("", scriptscan.Policy{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.UseScriptScanner(scanner)
+
+	_, err = ps.runComputation(context.Background(), "comp-1", &ComputationRequest{LeaseID: "lease-1", ComputationCid: "QmScript"})
+	if !errors.Is(err, scriptscan.ErrRejected) || !strings.Contains(err.Error(), "socket") {
+		t.Errorf("runComputation() error = %v, want %v", err, scriptscan.ErrRejected)
+	}
+	if runs != 0 {
+		t.Error("rejected script was executed")
+	}
+	if len(ps.containerPool) != 1 {
+		t.Error("container not returned to the pool")
+	}
+}
+
+func TestMeterJob(t *testing.T) {
+	ps := &privacyService{}
+	started := time.Now()
+	run := &execution{output: "42", artifacts: map[string][]byte{"model.bin": make([]byte, 100)}, startedAt: started, finishedAt: started.Add(1500 * time.Millisecond)}
+
+	req := &ComputationRequest{LeaseID: "lease-1"}
+
+	// Nothing is metered unless metering is on
+	ps.meterJob("comp-1", req, run)
+
+	var meterings []Metering
+	ps.MeterComputations(func(m Metering) { meterings = append(meterings, m) })
+	ps.meterJob("comp-1", req, run)
+	want := Metering{ComputationID: "comp-1", LeaseID: "lease-1", Compute: 1500 * time.Millisecond, OutputBytes: 102, Resources: metering.Resources{WallSeconds: 1.5}}
+	if len(meterings) != 1 || meterings[0] != want {
+		t.Errorf("meterings = %+v", meterings)
+	}
+
+	// Billed computations carry their bill on the job
+	ps.jobs = map[string]*ComputationJob{"comp-1": {ID: "comp-1"}}
+	ps.BillComputations(metering.Rates{WallSecond: decimal.NewFromInt(10)})
+	ps.meterJob("comp-1", req, run)
+	if bill := ps.jobs["comp-1"].Metering; bill == nil || bill.Cost != "15" || meterings[1].Bill != bill {
+		t.Errorf("bill = %+v", bill)
+	}
+}
+
+func TestParseCPUUsage(t *testing.T) {
+	for stat, want := range map[string]time.Duration{
+		"usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n": 2500 * time.Millisecond,
+		"1500000000\n": 1500 * time.Millisecond,
+	} {
+		if got, err := parseCPUUsage(stat); err != nil || got != want {
+			t.Errorf("parseCPUUsage(%q) = %v, %v, want %v", stat, got, err, want)
+		}
+	}
+	if _, err := parseCPUUsage("nothing"); err == nil {
+		t.Error("parseCPUUsage accepted garbage")
+	}
+}
+
+// gpuRuntime records the GPUs its containers are created with
+type gpuRuntime struct {
+	replayRuntime
+	created *[][]int
+}
+
+func (r gpuRuntime) CreateWithGPUs(devices []int) (string, error) {
+	*r.created = append(*r.created, devices)
+	return "gpu-sandbox", nil
+}
+
+func TestGPUComputation(t *testing.T) {
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "print(sum(df))")
+	}))
+	defer ipfs.Close()
+
+	var runs int
+	var env []string
+	var created [][]int
+	ps := &privacyService{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		runtime:       gpuRuntime{replayRuntime{outputs: []string{"42"}, runs: &runs, env: &env}, &created},
+		httpClient:    ipfs.Client(),
+		ipfsAPIURL:    ipfs.URL,
+		dataDir:       t.TempDir(),
+		jobs:          map[string]*ComputationJob{},
+		containerPool: make(chan *DockerContainer, 1),
+	}
+	req := &ComputationRequest{LeaseID: "lease-1", ComputationCid: "Qm" + strings.Repeat("a", 44), Inputs: []DataInput{{AssetID: "scans", VariableName: "df"}}, GPU: true}
+
+	// Agents without GPUs refuse GPU computations
+	if err := ps.validateComputationRequest(req); !errors.Is(err, gpu.ErrUnavailable) {
+		t.Fatalf("validateComputationRequest() without GPUs error = %v, want gpu.ErrUnavailable", err)
+	}
+	ps.UseGPUs(nil, gpu.NewAllocator([]int{0, 1}), nil)
+	if err := ps.validateComputationRequest(&ComputationRequest{LeaseID: req.LeaseID, ComputationCid: req.ComputationCid, Inputs: req.Inputs, GPU: true, GPUCount: 3}); !errors.Is(err, gpu.ErrUnavailable) {
+		t.Errorf("validateComputationRequest(3 GPUs) error = %v, want gpu.ErrUnavailable", err)
+	}
+	if err := ps.validateComputationRequest(req); err != nil || req.GPUCount != 1 {
+		t.Fatalf("validateComputationRequest() = %v with gpu_count %d, want one GPU", err, req.GPUCount)
+	}
+
+	// GPU computations run in a container of their own, not a pooled one
+	ps.jobs["comp-1"] = &ComputationJob{ID: "comp-1", Status: "pending", GPU: &gpu.Allocation{Count: 1}}
+	if _, err := ps.runComputation(context.Background(), "comp-1", req); err != nil {
+		t.Fatalf("runComputation() error = %v", err)
+	}
+	if len(created) != 1 || len(created[0]) != 1 || created[0][0] != 0 {
+		t.Errorf("GPU containers created with %v, want one with GPU 0", created)
+	}
+	if got := ps.jobs["comp-1"].GPU; got == nil || len(got.Devices) != 1 {
+		t.Errorf("job GPU = %+v, want the device it ran on", got)
+	}
+	if len(ps.containerPool) != 0 {
+		t.Error("GPU container was returned to the pool")
+	}
+	if devices, err := ps.gpus.Acquire(context.Background(), 2); err != nil || len(devices) != 2 {
+		t.Errorf("GPUs not released after the computation: %v, %v", devices, err)
+	}
+}
+
+func TestValidateComputationRequest_purpose(t *testing.T) {
+	ps := &privacyService{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), jobs: map[string]*ComputationJob{}}
+	req := func(purpose string) *ComputationRequest {
+		return &ComputationRequest{LeaseID: "lease-1", ComputationCid: "Qm" + strings.Repeat("a", 44),
+			Inputs: []DataInput{{AssetID: "sales", VariableName: "df"}}, Purpose: purpose, LeasePurpose: "research"}
+	}
+
+	if err := ps.validateComputationRequest(req("research")); err != nil {
+		t.Errorf("validateComputationRequest() for the lease's purpose error = %v", err)
+	}
+	for _, purpose := range []string{"marketing", ""} {
+		if err := ps.validateComputationRequest(req(purpose)); !errors.Is(err, ErrPurposeMismatch) {
+			t.Errorf("validateComputationRequest(%q) error = %v, want ErrPurposeMismatch", purpose, err)
+		}
+	}
+	// Leases granted without a purpose do not bind computations to one
+	unbound := req("marketing")
+	unbound.LeasePurpose = ""
+	if err := ps.validateComputationRequest(unbound); err != nil {
+		t.Errorf("validateComputationRequest() under a lease without a purpose error = %v", err)
+	}
+}
+
+func TestEraseJobs(t *testing.T) {
+	ps := &privacyService{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), jobs: map[string]*ComputationJob{
+		"comp-1": {ID: "comp-1", Status: "completed", Request: &ComputationRequest{Inputs: []DataInput{{AssetID: "scans"}}}},
+		"comp-2": {ID: "comp-2", Status: "pending", Request: &ComputationRequest{Inputs: []DataInput{{AssetID: "scans"}}}},
+		"comp-3": {ID: "comp-3", Status: "completed", Request: &ComputationRequest{Inputs: []DataInput{{AssetID: "sales"}}}},
+	}}
+
+	erased, running := ps.EraseJobs([]string{"scans"})
+	if !slices.Equal(erased, []string{"comp-1"}) || !slices.Equal(running, []string{"comp-2"}) {
+		t.Errorf("EraseJobs() = %v, %v; want comp-1 erased and comp-2 still running", erased, running)
+	}
+	if _, ok := ps.jobs["comp-1"]; ok {
+		t.Error("erased computation is still kept")
+	}
+	if _, ok := ps.jobs["comp-3"]; !ok {
+		t.Error("computation on other data was erased")
+	}
+}
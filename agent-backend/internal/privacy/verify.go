@@ -0,0 +1,108 @@
+package privacy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Verification statuses
+const (
+	VerificationPending    = "pending"    // The re-execution is running
+	VerificationMatched    = "matched"    // The re-execution produced the same result
+	VerificationMismatched = "mismatched" // The re-execution produced a different result
+	VerificationFailed     = "failed"     // The re-execution did not complete
+)
+
+// Verification records the re-execution of a completed computation with
+// its inputs and seed
+type Verification struct {
+	Status       string     `json:"status"`
+	Seed         uint32     `json:"seed"`                    // PANDACEA_SEED both runs were given
+	ResultDigest string     `json:"result_digest"`           // SHA-256 of the delivered output and artifacts, before watermarking
+	ReplayDigest string     `json:"replay_digest,omitempty"` // SHA-256 of the re-execution's
+	Error        string     `json:"error,omitempty"`
+	CheckedAt    *time.Time `json:"checked_at,omitempty"`
+}
+
+// VerifyResults implements ResultVerifier
+func (ps *privacyService) VerifyResults(fraction float64, fn func(computationID, leaseID string, v Verification)) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	ps.verifyFraction = fraction
+	ps.onVerified = fn
+}
+
+// verifyJob re-executes a completed computation if it is sampled for
+// verification, and records whether the result was the same
+func (ps *privacyService) verifyJob(ctx context.Context, computationID string, req *ComputationRequest, digest string) {
+	ps.jobsMutex.RLock()
+	fraction, onVerified := ps.verifyFraction, ps.onVerified
+	ps.jobsMutex.RUnlock()
+	if fraction <= 0 || rand.Float64() >= fraction {
+		return
+	}
+
+	v := Verification{Status: VerificationPending, Seed: computationSeed(computationID), ResultDigest: digest}
+	ps.setVerification(computationID, v)
+
+	run, err := ps.runComputation(ctx, computationID, req)
+	now := time.Now().UTC()
+	v.CheckedAt = &now
+	if err != nil {
+		v.Status, v.Error = VerificationFailed, err.Error()
+	} else if v.ReplayDigest = resultDigest(run.output, run.artifacts); v.ReplayDigest == digest {
+		v.Status = VerificationMatched
+	} else {
+		v.Status = VerificationMismatched
+		ps.logger.Warn("re-executed computation produced a different result", "computation_id", computationID, "lease_id", req.LeaseID)
+	}
+	ps.setVerification(computationID, v)
+
+	if onVerified != nil {
+		onVerified(computationID, req.LeaseID, v)
+	}
+}
+
+// setVerification records a computation's verification in its job
+func (ps *privacyService) setVerification(computationID string, v Verification) {
+	ps.jobsMutex.Lock()
+	defer ps.jobsMutex.Unlock()
+	job, exists := ps.jobs[computationID]
+	if !exists {
+		return
+	}
+	job.Verification = &v
+	ps.persistJob(job)
+}
+
+// computationSeed returns the seed a computation runs with. It is derived
+// from the computation ID so that a re-execution gets the same one.
+func computationSeed(computationID string) uint32 {
+	sum := sha256.Sum256([]byte(computationID))
+	return binary.BigEndian.Uint32(sum[:4]) & 0x7fffffff
+}
+
+// resultDigest returns the SHA-256 of a computation's output and artifacts
+func resultDigest(output string, artifacts map[string][]byte) string {
+	h := sha256.New()
+	write := func(b []byte) {
+		binary.Write(h, binary.BigEndian, uint64(len(b)))
+		h.Write(b)
+	}
+	write([]byte(output))
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		write([]byte(name))
+		write(artifacts[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
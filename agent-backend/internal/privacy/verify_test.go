@@ -0,0 +1,109 @@
+package privacy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// replayRuntime prints one of outputs per execution, in turn
+type replayRuntime struct {
+	outputs []string
+	runs    *int
+	env     *[]string
+}
+
+func (replayRuntime) Create() (string, error)             { return "sandbox-1", nil }
+func (replayRuntime) Remove(string) error                 { return nil }
+func (replayRuntime) Clean(string) error                  { return nil }
+func (replayRuntime) CopyTo(string, string, string) error { return nil }
+func (r replayRuntime) Exec(ctx context.Context, _ string, _ ...string) ([]byte, error) {
+	*r.env = sandboxEnviron(ctx)
+	output := r.outputs[*r.runs%len(r.outputs)]
+	*r.runs++
+	return []byte(output), nil
+}
+
+func TestVerifyJob(t *testing.T) {
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "print(sum(df))")
+	}))
+	defer ipfs.Close()
+
+	var runs int
+	var env []string
+	runtime := replayRuntime{outputs: []string{"42", "42", "43"}, runs: &runs, env: &env}
+	ps := &privacyService{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		runtime:       runtime,
+		httpClient:    ipfs.Client(),
+		ipfsAPIURL:    ipfs.URL,
+		dataDir:       t.TempDir(),
+		jobs:          map[string]*ComputationJob{"comp-1": {ID: "comp-1", Status: "completed"}},
+		containerPool: make(chan *DockerContainer, 1),
+		poolSize:      1,
+		live:          1,
+	}
+	ps.containerPool <- &DockerContainer{ID: "sandbox-1", IsActive: true}
+	req := &ComputationRequest{LeaseID: "lease-1", ComputationCid: "QmScript"}
+
+	run, err := ps.runComputation(context.Background(), "comp-1", req)
+	if err != nil {
+		t.Fatalf("runComputation() error = %v", err)
+	}
+	digest := resultDigest(run.output, run.artifacts)
+	seed := "PANDACEA_SEED=" + strconv.FormatUint(uint64(computationSeed("comp-1")), 10)
+	if !slices.Contains(env, seed) {
+		t.Errorf("computation not seeded with %s: %v", seed, env)
+	}
+
+	// Nothing is re-executed unless verification is on
+	ps.verifyJob(context.Background(), "comp-1", req, digest)
+	if runs != 1 || ps.jobs["comp-1"].Verification != nil {
+		t.Fatalf("verifyJob() with verification off ran %d times", runs)
+	}
+
+	var verdicts []Verification
+	ps.VerifyResults(1, func(computationID, leaseID string, v Verification) {
+		if computationID != "comp-1" || leaseID != "lease-1" {
+			t.Errorf("verdict for %s on %s", computationID, leaseID)
+		}
+		verdicts = append(verdicts, v)
+	})
+	ps.verifyJob(context.Background(), "comp-1", req, digest)
+	if got := ps.jobs["comp-1"].Verification; got == nil || got.Status != VerificationMatched || got.ReplayDigest != digest || got.CheckedAt == nil {
+		t.Errorf("verification of a reproducible result = %+v", got)
+	}
+	if !slices.Contains(env, seed) {
+		t.Errorf("re-execution not seeded with %s: %v", seed, env)
+	}
+
+	ps.verifyJob(context.Background(), "comp-1", req, digest)
+	if got := ps.jobs["comp-1"].Verification; got.Status != VerificationMismatched || got.ReplayDigest == digest {
+		t.Errorf("verification of a different result = %+v", got)
+	}
+	if len(verdicts) != 2 || verdicts[0].Status != VerificationMatched || verdicts[1].Status != VerificationMismatched {
+		t.Errorf("verdicts = %+v", verdicts)
+	}
+	if len(ps.containerPool) != 1 {
+		t.Error("container not returned to the pool")
+	}
+}
+
+func TestResultDigest(t *testing.T) {
+	a := resultDigest("out", map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	if b := resultDigest("out", map[string][]byte{"b": []byte("2"), "a": []byte("1")}); a != b {
+		t.Error("digest depends on artifact order")
+	}
+	if b := resultDigest("out", map[string][]byte{"a": []byte("12")}); a == b {
+		t.Error("different artifacts have the same digest")
+	}
+	if b := resultDigest("outa", map[string][]byte{"b": []byte("2")}); a == b {
+		t.Error("digest is ambiguous between output and artifacts")
+	}
+}
@@ -0,0 +1,211 @@
+package privacy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// wasmMounts are the guest directories a WASM sandbox preopens
+var wasmMounts = []string{"/workspace", "/data"}
+
+// WASMRuntime runs computations as WebAssembly modules under the wasmtime
+// CLI. A sandbox is a host directory holding its /workspace and /data,
+// which are the only paths the module can open; it has no network. Only
+// commands with a WASI module configured can run, so computations are
+// limited to what those modules support, such as a WASI build of CPython
+// without native extensions.
+type WASMRuntime struct {
+	dir     string
+	modules map[string]string // Command name to .wasm module
+	binary  string
+}
+
+// NewWASMRuntime creates a runtime keeping sandboxes under dir, or the
+// system temporary directory if dir is empty. modules maps the commands
+// computations run to the WASI modules implementing them.
+func NewWASMRuntime(dir string, modules map[string]string) (*WASMRuntime, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create WASM sandbox directory: %w", err)
+	}
+	for command, module := range modules {
+		if _, err := os.Stat(module); err != nil {
+			return nil, fmt.Errorf("WASM module for %s: %w", command, err)
+		}
+	}
+	return &WASMRuntime{dir: dir, modules: modules, binary: "wasmtime"}, nil
+}
+
+// Create implements ContainerRuntime
+func (w *WASMRuntime) Create() (string, error) {
+	root, err := os.MkdirTemp(w.dir, "pandacea-wasm-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create WASM sandbox: %w", err)
+	}
+	for _, mount := range wasmMounts {
+		if err := os.Mkdir(filepath.Join(root, mount), 0700); err != nil {
+			os.RemoveAll(root)
+			return "", fmt.Errorf("failed to create WASM sandbox: %w", err)
+		}
+	}
+	return filepath.Base(root), nil
+}
+
+// Remove implements ContainerRuntime
+func (w *WASMRuntime) Remove(id string) error {
+	root, err := w.root(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(root)
+}
+
+// Clean implements ContainerRuntime
+func (w *WASMRuntime) Clean(id string) error {
+	root, err := w.root(id)
+	if err != nil {
+		return err
+	}
+	for _, mount := range wasmMounts {
+		dir := filepath.Join(root, mount)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyTo implements ContainerRuntime. destPath must be in one of the
+// sandbox's preopened directories. The contents of a source directory are
+// copied into destPath.
+func (w *WASMRuntime) CopyTo(id, srcPath, destPath string) error {
+	root, err := w.root(id)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Clean("/" + destPath)
+	inMount := false
+	for _, mount := range wasmMounts {
+		if dest == mount || strings.HasPrefix(dest, mount+"/") {
+			inMount = true
+		}
+	}
+	if !inMount {
+		return fmt.Errorf("WASM sandboxes can only receive files in %s", strings.Join(wasmMounts, " or "))
+	}
+	return copyTree(srcPath, filepath.Join(root, dest))
+}
+
+// Exec implements ContainerRuntime. args[0] names the command, which runs
+// as its configured module with the remaining arguments.
+func (w *WASMRuntime) Exec(ctx context.Context, id string, args ...string) ([]byte, error) {
+	root, err := w.root(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+	module, ok := w.modules[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("no WASM module configured for %s", args[0])
+	}
+
+	wasmArgs := []string{"run"}
+	for _, mount := range wasmMounts {
+		wasmArgs = append(wasmArgs, "--dir", filepath.Join(root, mount)+"::"+mount)
+	}
+	for _, env := range sandboxEnviron(ctx) {
+		wasmArgs = append(wasmArgs, "--env", env)
+	}
+	wasmArgs = append(wasmArgs, module)
+	wasmArgs = append(wasmArgs, args[1:]...)
+	return exec.CommandContext(ctx, w.binary, wasmArgs...).CombinedOutput()
+}
+
+// ImageDigest implements ImageDigester. Every sandbox runs computations
+// with the python module, so its digest stands in for an image's.
+func (w *WASMRuntime) ImageDigest(id string) (string, error) {
+	if _, err := w.root(id); err != nil {
+		return "", err
+	}
+	module, ok := w.modules["python"]
+	if !ok {
+		return "", fmt.Errorf("no WASM module configured for python")
+	}
+	f, err := os.Open(module)
+	if err != nil {
+		return "", fmt.Errorf("failed to read WASM module: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read WASM module: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// root returns the host directory of sandbox id
+func (w *WASMRuntime) root(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id || !strings.HasPrefix(id, "pandacea-wasm-") {
+		return "", fmt.Errorf("no such WASM sandbox: %s", id)
+	}
+	root := filepath.Join(w.dir, id)
+	if _, err := os.Stat(root); err != nil {
+		return "", fmt.Errorf("no such WASM sandbox: %s", id)
+	}
+	return root, nil
+}
+
+// copyTree copies a file to dest, or into dest if it is a directory, or
+// the contents of a directory into dest
+func copyTree(src, dest string) error {
+	if info, err := os.Stat(src); err == nil && !info.IsDir() {
+		if info, err := os.Stat(dest); err == nil && info.IsDir() {
+			dest = filepath.Join(dest, filepath.Base(src))
+		}
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		if !d.Type().IsRegular() {
+			return nil // Links and devices stay outside the sandbox
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
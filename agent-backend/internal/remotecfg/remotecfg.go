@@ -0,0 +1,343 @@
+// Package remotecfg fetches configuration files from HTTPS URLs or IPFS and
+// verifies their detached signatures before they are applied, so operators
+// can manage a fleet of agents from one signed copy of each file.
+//
+// A signature covers a canonical digest of the file's name, version and
+// contents:
+//
+//	pandacea-config-v2\n<name>\n<version>\n<hex sha256(contents)>
+//
+// Binding the name stops a signed products.json from being served as
+// security.yaml. The version only ever increases, so an older signed copy
+// cannot be served to roll back a newer one. Signature files are JSON
+// holding the version and the base64 libp2p signature of the digest, or an
+// Ethereum wallet's 0x-prefixed personal_sign signature of it.
+package remotecfg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"pandacea/agent-backend/internal/security"
+	"pandacea/agent-backend/internal/telemetry"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// digestPrefix versions the canonical digest format
+const digestPrefix = "pandacea-config-v2"
+
+// MaxSize is the largest configuration file that is fetched
+const MaxSize = 1 << 20
+
+// Errors returned by the fetcher. Callers branch on them with errors.Is; the
+// wrapped messages carry the details.
+var (
+	ErrInvalidSignature = errors.New("invalid configuration signature")
+	ErrUnsupportedURL   = errors.New("unsupported configuration URL")
+	ErrStaleVersion     = errors.New("configuration version is older than the one in use")
+)
+
+// signatureFile is the JSON layout of a detached signature file
+type signatureFile struct {
+	Version   uint64 `json:"version"`
+	Signature string `json:"signature"`
+}
+
+// Digest returns the canonical bytes signed for version of a configuration
+// file
+func Digest(name string, version uint64, content []byte) []byte {
+	sum := sha256.Sum256(content)
+	return []byte(digestPrefix + "\n" + name + "\n" + strconv.FormatUint(version, 10) + "\n" + hex.EncodeToString(sum[:]))
+}
+
+// Sign returns the detached signature file for version of a configuration
+// file
+func Sign(priv crypto.PrivKey, name string, version uint64, content []byte) (string, error) {
+	sig, err := priv.Sign(Digest(name, version, content))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign configuration: %w", err)
+	}
+	return encodeSignature(version, base64.StdEncoding.EncodeToString(sig))
+}
+
+// SignWallet returns the detached signature file for version of a
+// configuration file by an Ethereum wallet, as personal_sign would produce
+// it
+func SignWallet(key *ecdsa.PrivateKey, name string, version uint64, content []byte) (string, error) {
+	sig, err := ethcrypto.Sign(accounts.TextHash(Digest(name, version, content)), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign configuration: %w", err)
+	}
+	sig[ethcrypto.RecoveryIDOffset] += 27
+	return encodeSignature(version, hexutil.Encode(sig))
+}
+
+// encodeSignature returns the signature file holding sig for version
+func encodeSignature(version uint64, sig string) (string, error) {
+	data, err := json.Marshal(signatureFile{Version: version, Signature: sig})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signature: %w", err)
+	}
+	return string(data), nil
+}
+
+// Verify checks a detached signature file against any of the trusted keys
+// and returns the version it signs
+func Verify(keys []crypto.PubKey, name string, content, signature []byte) (uint64, error) {
+	return VerifySigners(keys, nil, name, content, signature)
+}
+
+// VerifySigners checks a detached signature file against any of the trusted
+// keys or, for a 0x-prefixed personal_sign signature, wallets. It returns
+// the version the file signs.
+func VerifySigners(keys []crypto.PubKey, wallets []common.Address, name string, content, signature []byte) (uint64, error) {
+	var file signatureFile
+	if err := json.Unmarshal(signature, &file); err != nil {
+		return 0, fmt.Errorf("%w: %s signature is not a signature file: %v", ErrInvalidSignature, name, err)
+	}
+	if file.Version == 0 {
+		return 0, fmt.Errorf("%w: %s signature has no version", ErrInvalidSignature, name)
+	}
+	digest := Digest(name, file.Version, content)
+
+	if strings.HasPrefix(file.Signature, "0x") {
+		signer, err := security.RecoverPersonalSignAddress(digest, file.Signature)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		if !slices.Contains(wallets, signer) {
+			return 0, fmt.Errorf("%w: %s is signed by %s, which is not a trusted wallet", ErrInvalidSignature, name, signer.Hex())
+		}
+		return file.Version, nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(file.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature is not base64: %v", ErrInvalidSignature, err)
+	}
+	for _, key := range keys {
+		if ok, err := key.Verify(digest, sig); err == nil && ok {
+			return file.Version, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s is not signed by a trusted key", ErrInvalidSignature, name)
+}
+
+// ParseKeys decodes base64 marshalled libp2p public keys
+func ParseKeys(encoded []string) ([]crypto.PubKey, error) {
+	keys := make([]crypto.PubKey, 0, len(encoded))
+	for i, s := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("signer key %d is not base64: %w", i+1, err)
+		}
+		key, err := crypto.UnmarshalPublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("signer key %d is invalid: %w", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseWallets decodes hex Ethereum addresses
+func ParseWallets(encoded []string) ([]common.Address, error) {
+	wallets := make([]common.Address, 0, len(encoded))
+	for i, s := range encoded {
+		if !common.IsHexAddress(strings.TrimSpace(s)) {
+			return nil, fmt.Errorf("signer wallet %d is not an address: %q", i+1, s)
+		}
+		wallets = append(wallets, common.HexToAddress(strings.TrimSpace(s)))
+	}
+	return wallets, nil
+}
+
+// Source is a remote configuration file and its detached signature. URLs
+// are https:// or ipfs://<cid>. An HTTPS file's signature defaults to the
+// same URL with .sig appended; an IPFS file's must be given.
+type Source struct {
+	Name         string
+	URL          string
+	SignatureURL string
+}
+
+// signatureURL returns where the source's signature is fetched from
+func (src Source) signatureURL() (string, error) {
+	if src.SignatureURL != "" {
+		return src.SignatureURL, nil
+	}
+	if strings.HasPrefix(src.URL, "https://") {
+		return src.URL + ".sig", nil
+	}
+	return "", fmt.Errorf("%w: %s needs a signature URL", ErrUnsupportedURL, src.Name)
+}
+
+// Fetcher downloads and verifies remote configuration files
+type Fetcher struct {
+	client     *http.Client
+	ipfsAPIURL string
+	keys       []crypto.PubKey
+}
+
+// NewFetcher creates a fetcher that trusts files signed by any of keys.
+// IPFS CIDs are read through the IPFS API at ipfsAPIURL. A nil client uses
+// a traced one with a 30 second timeout.
+func NewFetcher(client *http.Client, ipfsAPIURL string, keys []crypto.PubKey) (*Fetcher, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("remote configuration needs at least one signer key")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second, Transport: telemetry.Transport(nil)}
+	}
+	return &Fetcher{client: client, ipfsAPIURL: strings.TrimSuffix(ipfsAPIURL, "/"), keys: keys}, nil
+}
+
+// Fetch downloads a source and its signature and returns the contents and
+// their signed version once the signature is verified
+func (f *Fetcher) Fetch(ctx context.Context, src Source) ([]byte, uint64, error) {
+	sigURL, err := src.signatureURL()
+	if err != nil {
+		return nil, 0, err
+	}
+	content, err := f.get(ctx, src.URL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch %s: %w", src.Name, err)
+	}
+	signature, err := f.get(ctx, sigURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch %s signature: %w", src.Name, err)
+	}
+	version, err := Verify(f.keys, src.Name, content, signature)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, version, nil
+}
+
+// get reads an https:// URL directly or an ipfs:// CID through the IPFS API
+func (f *Fetcher) get(ctx context.Context, location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedURL, err)
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case "https":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	case "ipfs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("%w: %s has no CID", ErrUnsupportedURL, location)
+		}
+		cat := fmt.Sprintf("%s/api/v0/cat?arg=%s", f.ipfsAPIURL, url.QueryEscape(u.Host+u.Path))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, cat, nil)
+	default:
+		return nil, fmt.Errorf("%w: %s must be https:// or ipfs://", ErrUnsupportedURL, location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", location, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", location, MaxSize)
+	}
+	return data, nil
+}
+
+// Syncer keeps one remote file applied. It only calls apply when the
+// verified contents change, so unchanged files are not re-applied on every
+// refresh, and refuses contents signed with an older version than the
+// applied ones.
+type Syncer struct {
+	fetcher *Fetcher
+	source  Source
+	apply   func([]byte) error
+	logger  *slog.Logger
+	last    [sha256.Size]byte
+	version uint64
+	synced  bool
+}
+
+// NewSyncer creates a syncer that passes verified contents of src to apply
+func NewSyncer(fetcher *Fetcher, src Source, apply func([]byte) error, logger *slog.Logger) *Syncer {
+	return &Syncer{fetcher: fetcher, source: src, apply: apply, logger: logger}
+}
+
+// Sync fetches the source and applies it if it changed. It reports whether
+// it was applied. On any error the previously applied contents stay in use.
+func (s *Syncer) Sync(ctx context.Context) (bool, error) {
+	content, version, err := s.fetcher.Fetch(ctx, s.source)
+	if err != nil {
+		return false, err
+	}
+	if version < s.version {
+		return false, fmt.Errorf("%w: %s version %d, applied %d", ErrStaleVersion, s.source.Name, version, s.version)
+	}
+	sum := sha256.Sum256(content)
+	if s.synced && sum == s.last {
+		s.version = version
+		return false, nil
+	}
+	if s.synced && version == s.version {
+		return false, fmt.Errorf("%w: %s changed without a new version", ErrStaleVersion, s.source.Name)
+	}
+	if err := s.apply(content); err != nil {
+		return false, fmt.Errorf("failed to apply %s: %w", s.source.Name, err)
+	}
+	s.last, s.version, s.synced = sum, version, true
+	return true, nil
+}
+
+// Run syncs the source every interval until ctx is done
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			applied, err := s.Sync(ctx)
+			if err != nil {
+				s.logger.Error("failed to refresh remote configuration", "name", s.source.Name, "url", s.source.URL, "error", err)
+				continue
+			}
+			if applied {
+				s.logger.Info("remote configuration updated", "name", s.source.Name, "url", s.source.URL)
+			}
+		}
+	}
+}
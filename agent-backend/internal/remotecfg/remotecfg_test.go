@@ -0,0 +1,178 @@
+package remotecfg
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func newTestKey(t *testing.T) crypto.PrivKey {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	return priv
+}
+
+func TestFetchHTTPS(t *testing.T) {
+	signer, other := newTestKey(t), newTestKey(t)
+	products := []byte(`[{"productId":"did:pandacea:earner:1/a"}]`)
+	sig, err := Sign(signer, "products", 1, products)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	// A valid signature for a different file name must not verify
+	wrongName, _ := Sign(signer, "security", 1, products)
+
+	files := map[string]string{
+		"/products.json":     string(products),
+		"/products.json.sig": sig,
+		"/renamed.json":      string(products),
+		"/renamed.json.sig":  wrongName,
+		"/tampered.json":     `[]`,
+		"/tampered.json.sig": sig,
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	fetcher, err := NewFetcher(server.Client(), "", []crypto.PubKey{other.GetPublic(), signer.GetPublic()})
+	if err != nil {
+		t.Fatalf("NewFetcher() error = %v", err)
+	}
+
+	got, version, err := fetcher.Fetch(context.Background(), Source{Name: "products", URL: server.URL + "/products.json"})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if string(got) != string(products) || version != 1 {
+		t.Errorf("Fetch() = %s, %d, want %s, 1", got, version, products)
+	}
+
+	for _, path := range []string{"/renamed.json", "/tampered.json"} {
+		if _, _, err := fetcher.Fetch(context.Background(), Source{Name: "products", URL: server.URL + path}); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Fetch(%s) error = %v, want ErrInvalidSignature", path, err)
+		}
+	}
+
+	untrusted, _ := NewFetcher(server.Client(), "", []crypto.PubKey{other.GetPublic()})
+	if _, _, err := untrusted.Fetch(context.Background(), Source{Name: "products", URL: server.URL + "/products.json"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Fetch() with untrusted signer error = %v, want ErrInvalidSignature", err)
+	}
+
+	plain := Source{Name: "products", URL: "http://config.example.com/products.json", SignatureURL: "http://config.example.com/products.json.sig"}
+	if _, _, err := fetcher.Fetch(context.Background(), plain); !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("Fetch() over plain HTTP error = %v, want ErrUnsupportedURL", err)
+	}
+}
+
+func TestSyncerIPFS(t *testing.T) {
+	signer := newTestKey(t)
+	content := "rate_limits:\n  burst: 10\n"
+	sig, _ := Sign(signer, "security", 2, []byte(content))
+
+	ipfs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("arg") {
+		case "QmConfig":
+			io.WriteString(w, content)
+		case "QmSignature":
+			io.WriteString(w, sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ipfs.Close()
+
+	fetcher, err := NewFetcher(nil, ipfs.URL, []crypto.PubKey{signer.GetPublic()})
+	if err != nil {
+		t.Fatalf("NewFetcher() error = %v", err)
+	}
+	if _, _, err := fetcher.Fetch(context.Background(), Source{Name: "security", URL: "ipfs://QmConfig"}); !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("Fetch() of a CID without a signature URL error = %v, want ErrUnsupportedURL", err)
+	}
+
+	applied := 0
+	syncer := NewSyncer(fetcher, Source{Name: "security", URL: "ipfs://QmConfig", SignatureURL: "ipfs://QmSignature"},
+		func(data []byte) error {
+			if string(data) != content {
+				t.Errorf("apply() got %q, want %q", data, content)
+			}
+			applied++
+			return nil
+		}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i := 0; i < 2; i++ {
+		if _, err := syncer.Sync(context.Background()); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+	}
+	// Unchanged contents are applied once
+	if applied != 1 {
+		t.Errorf("apply() called %d times, want 1", applied)
+	}
+
+	// An older signed copy cannot roll the file back, and a change must
+	// come with a new version
+	content = "rate_limits:\n  burst: 1000\n"
+	for _, tt := range []struct {
+		version uint64
+		want    error
+	}{{1, ErrStaleVersion}, {2, ErrStaleVersion}, {3, nil}} {
+		sig, _ = Sign(signer, "security", tt.version, []byte(content))
+		if _, err := syncer.Sync(context.Background()); !errors.Is(err, tt.want) {
+			t.Errorf("Sync() of version %d error = %v, want %v", tt.version, err, tt.want)
+		}
+	}
+	if applied != 2 {
+		t.Errorf("apply() called %d times, want 2", applied)
+	}
+}
+
+func TestVerifySignersWallet(t *testing.T) {
+	wallet, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	trusted, err := ParseWallets([]string{ethcrypto.PubkeyToAddress(wallet.PublicKey).Hex()})
+	if err != nil {
+		t.Fatalf("ParseWallets() error = %v", err)
+	}
+	products := []byte(`[{"productId":"did:pandacea:earner:1/a"}]`)
+	sig, err := SignWallet(wallet, "products", 1, products)
+	if err != nil {
+		t.Fatalf("SignWallet() error = %v", err)
+	}
+
+	if _, err := VerifySigners(nil, trusted, "products", products, []byte(sig+"\n")); err != nil {
+		t.Errorf("VerifySigners() error = %v", err)
+	}
+	if _, err := VerifySigners(nil, trusted, "products", []byte(`[]`), []byte(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySigners() of tampered contents error = %v, want ErrInvalidSignature", err)
+	}
+	if _, err := VerifySigners(nil, []common.Address{{1}}, "products", products, []byte(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySigners() by an untrusted wallet error = %v, want ErrInvalidSignature", err)
+	}
+	// Keys do not vouch for wallet signatures
+	if _, err := Verify([]crypto.PubKey{newTestKey(t).GetPublic()}, "products", products, []byte(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of a wallet signature error = %v, want ErrInvalidSignature", err)
+	}
+	if _, err := ParseWallets([]string{"earner"}); err == nil {
+		t.Error("ParseWallets() accepted a malformed address")
+	}
+}
@@ -0,0 +1,248 @@
+package reputation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Outcome is a lease result that affects the spender's reputation
+type Outcome string
+
+// Lease outcomes tracked from chain events and disputes
+const (
+	OutcomeApproved Outcome = "approved"
+	OutcomeExecuted Outcome = "executed"
+	OutcomeDisputed Outcome = "disputed"
+)
+
+// outcomeEvidence is how much positive or negative evidence each outcome
+// contributes before the reputation weight is applied. Disputes weigh more
+// than successful leases so a single dispute is not cancelled out by one
+// good lease.
+var outcomeEvidence = map[Outcome]struct{ positive, negative float64 }{
+	OutcomeApproved: {positive: 0.5},
+	OutcomeExecuted: {positive: 1},
+	OutcomeDisputed: {negative: 2},
+}
+
+// leaseRetention is how long a lease's spender is remembered for later outcomes
+const leaseRetention = 90 * 24 * time.Hour
+
+// record is the persisted evidence for one counterparty
+type record struct {
+	Positive  float64   `json:"positive"`
+	Negative  float64   `json:"negative"`
+	Approved  int       `json:"approved"`
+	Executed  int       `json:"executed"`
+	Disputed  int       `json:"disputed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// lease links a lease to its spender and remembers which outcomes have been
+// counted, so replayed chain events are not counted twice
+type lease struct {
+	Spender   string           `json:"spender"`
+	Outcomes  map[Outcome]bool `json:"outcomes,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// state is the on-disk format
+type state struct {
+	Counterparties map[string]*record `json:"counterparties"`
+	Leases         map[string]*lease  `json:"leases"`
+}
+
+// Tracker computes counterparty reputation from lease outcomes. Scores use a
+// beta reputation model: (positive+1)/(positive+negative+2), so a counterparty
+// with no history scores 0.5. Evidence decays exponentially over time.
+type Tracker struct {
+	mu           sync.Mutex
+	weight       float64
+	decayPerHour float64
+	path         string
+	state        state
+	now          func() time.Time
+}
+
+// NewTracker creates a tracker. weight scales how much each outcome moves a
+// score away from the neutral prior, and decayPerHour is the fraction of
+// evidence lost per hour. Scores are persisted to path unless it is empty.
+func NewTracker(weight, decayPerHour float64, path string) (*Tracker, error) {
+	if weight <= 0 {
+		return nil, fmt.Errorf("reputation weight must be positive")
+	}
+	if decayPerHour < 0 || decayPerHour >= 1 {
+		return nil, fmt.Errorf("reputation decay rate must be in [0, 1)")
+	}
+
+	t := &Tracker{
+		weight:       weight,
+		decayPerHour: decayPerHour,
+		path:         path,
+		state: state{
+			Counterparties: make(map[string]*record),
+			Leases:         make(map[string]*lease),
+		},
+		now: time.Now,
+	}
+
+	if path == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation state: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.state); err != nil {
+		return nil, fmt.Errorf("failed to parse reputation state: %w", err)
+	}
+	if t.state.Counterparties == nil {
+		t.state.Counterparties = make(map[string]*record)
+	}
+	if t.state.Leases == nil {
+		t.state.Leases = make(map[string]*lease)
+	}
+
+	return t, nil
+}
+
+// RecordLease remembers the spender of a lease so later outcomes can be
+// attributed to it
+func (t *Tracker) RecordLease(leaseID, spender string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	leaseID, spender = normalize(leaseID), normalize(spender)
+	if _, exists := t.state.Leases[leaseID]; exists {
+		return nil
+	}
+	t.state.Leases[leaseID] = &lease{Spender: spender, CreatedAt: t.now()}
+	return t.save()
+}
+
+// RecordOutcome attributes an outcome to the lease's spender. Each outcome
+// counts once per lease. It reports false if the lease is unknown.
+func (t *Tracker) RecordOutcome(leaseID string, outcome Outcome) (bool, error) {
+	evidence, ok := outcomeEvidence[outcome]
+	if !ok {
+		return false, fmt.Errorf("unknown lease outcome: %q", outcome)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, exists := t.state.Leases[normalize(leaseID)]
+	if !exists {
+		return false, nil
+	}
+	if l.Outcomes[outcome] {
+		return true, nil
+	}
+	if l.Outcomes == nil {
+		l.Outcomes = make(map[Outcome]bool)
+	}
+	l.Outcomes[outcome] = true
+
+	now := t.now()
+	rec := t.decayed(l.Spender, now)
+	rec.Positive += evidence.positive * t.weight
+	rec.Negative += evidence.negative * t.weight
+	switch outcome {
+	case OutcomeApproved:
+		rec.Approved++
+	case OutcomeExecuted:
+		rec.Executed++
+	case OutcomeDisputed:
+		rec.Disputed++
+	}
+	t.state.Counterparties[l.Spender] = rec
+
+	return true, t.save()
+}
+
+// Score returns the counterparty's current reputation in [0, 1], or false if
+// there is no history for it
+func (t *Tracker) Score(counterparty string) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := normalize(counterparty)
+	if _, exists := t.state.Counterparties[key]; !exists {
+		return 0, false
+	}
+	return score(t.decayed(key, t.now())), true
+}
+
+// ScoreOrPrior returns the counterparty's current reputation, or the score
+// of a counterparty with no history, so policy decides whether unknown
+// counterparties clear its minimum
+func (t *Tracker) ScoreOrPrior(counterparty string) float64 {
+	if s, ok := t.Score(counterparty); ok {
+		return s
+	}
+	return score(&record{})
+}
+
+// decayed returns a copy of the counterparty's record with evidence decayed
+// to now. Caller must hold t.mu.
+func (t *Tracker) decayed(key string, now time.Time) *record {
+	rec := &record{UpdatedAt: now}
+	if existing, ok := t.state.Counterparties[key]; ok {
+		*rec = *existing
+		if hours := now.Sub(existing.UpdatedAt).Hours(); hours > 0 {
+			factor := math.Pow(1-t.decayPerHour, hours)
+			rec.Positive *= factor
+			rec.Negative *= factor
+		}
+		rec.UpdatedAt = now
+	}
+	return rec
+}
+
+// save prunes old leases and writes the state atomically. Caller must hold t.mu.
+func (t *Tracker) save() error {
+	cutoff := t.now().Add(-leaseRetention)
+	for id, l := range t.state.Leases {
+		if l.CreatedAt.Before(cutoff) {
+			delete(t.state.Leases, id)
+		}
+	}
+
+	if t.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(t.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode reputation state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return fmt.Errorf("failed to create reputation state directory: %w", err)
+	}
+	if err := fsutil.WriteFile(t.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write reputation state: %w", err)
+	}
+	return nil
+}
+
+// score applies the beta reputation formula
+func score(rec *record) float64 {
+	return (rec.Positive + 1) / (rec.Positive + rec.Negative + 2)
+}
+
+// normalize makes addresses and lease IDs case-insensitive
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
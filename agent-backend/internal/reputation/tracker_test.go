@@ -0,0 +1,103 @@
+package reputation
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackerScores(t *testing.T) {
+	tracker, err := NewTracker(1, 0, "")
+	if err != nil {
+		t.Fatalf("NewTracker() error = %v", err)
+	}
+
+	if _, ok := tracker.Score("0xAAA"); ok {
+		t.Fatal("expected no score without history")
+	}
+	if prior := tracker.ScoreOrPrior("0xAAA"); prior != 0.5 {
+		t.Errorf("ScoreOrPrior() without history = %v, want 0.5", prior)
+	}
+
+	if err := tracker.RecordLease("0x01", "0xAAA"); err != nil {
+		t.Fatalf("RecordLease() error = %v", err)
+	}
+	if known, err := tracker.RecordOutcome("0x01", OutcomeExecuted); err != nil || !known {
+		t.Fatalf("RecordOutcome() = %v, %v", known, err)
+	}
+	// Replayed events must not count twice
+	tracker.RecordOutcome("0x01", OutcomeExecuted)
+
+	score, ok := tracker.Score("0xaaa")
+	if !ok || math.Abs(score-2.0/3.0) > 1e-9 {
+		t.Errorf("Score() after execution = %v, %v, want 2/3", score, ok)
+	}
+
+	tracker.RecordLease("0x02", "0xaaa")
+	tracker.RecordOutcome("0x02", OutcomeDisputed)
+	score, _ = tracker.Score("0xAAA")
+	if math.Abs(score-2.0/5.0) > 1e-9 {
+		t.Errorf("Score() after dispute = %v, want 2/5", score)
+	}
+	if got := tracker.ScoreOrPrior("0xaaa"); got != score {
+		t.Errorf("ScoreOrPrior() = %v, want %v", got, score)
+	}
+
+	if known, _ := tracker.RecordOutcome("0x99", OutcomeApproved); known {
+		t.Error("RecordOutcome() for unknown lease reported known")
+	}
+	if _, err := tracker.RecordOutcome("0x01", "refunded"); err == nil {
+		t.Error("RecordOutcome() with unknown outcome should fail")
+	}
+}
+
+func TestTrackerDecay(t *testing.T) {
+	tracker, _ := NewTracker(1, 0.5, "")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.RecordLease("0x01", "0xaaa")
+	tracker.RecordOutcome("0x01", OutcomeDisputed)
+
+	// Evidence halves every hour, pulling the score back towards 0.5
+	now = now.Add(time.Hour)
+	score, _ := tracker.Score("0xaaa")
+	if math.Abs(score-1.0/3.0) > 1e-9 {
+		t.Errorf("Score() after one hour = %v, want 1/3", score)
+	}
+}
+
+func TestTrackerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reputation.json")
+
+	tracker, err := NewTracker(0.5, 0, path)
+	if err != nil {
+		t.Fatalf("NewTracker() error = %v", err)
+	}
+	tracker.RecordLease("0x01", "0xaaa")
+	tracker.RecordOutcome("0x01", OutcomeApproved)
+	want, _ := tracker.Score("0xaaa")
+
+	restored, err := NewTracker(0.5, 0, path)
+	if err != nil {
+		t.Fatalf("NewTracker() reload error = %v", err)
+	}
+	if got, ok := restored.Score("0xaaa"); !ok || got != want {
+		t.Errorf("restored Score() = %v, %v, want %v", got, ok, want)
+	}
+	// The lease and its counted outcomes survive restarts too
+	restored.RecordOutcome("0x01", OutcomeApproved)
+	if got, _ := restored.Score("0xaaa"); got != want {
+		t.Errorf("replayed outcome after restart changed score to %v", got)
+	}
+}
+
+func TestNewTrackerValidation(t *testing.T) {
+	if _, err := NewTracker(0, 0, ""); err == nil {
+		t.Error("expected error for zero weight")
+	}
+	if _, err := NewTracker(1, 1, ""); err == nil {
+		t.Error("expected error for decay rate of 1")
+	}
+}
@@ -0,0 +1,100 @@
+package reqsig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", ""},
+		{"b=2&a=1", "a=1&b=2"},
+		{"a=2&a=1&a=10", "a=1&a=10&a=2"},
+		{"q=hello%20world&flag", "flag=&q=hello+world"},
+		{"x=%zz", "x=%25zz"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalQuery(tt.raw); got != tt.want {
+			t.Errorf("CanonicalQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	header := func(version, timestamp, nonce string) http.Header {
+		h := http.Header{}
+		h.Set(HeaderVersion, version)
+		h.Set(HeaderTimestamp, timestamp)
+		h.Set(HeaderNonce, nonce)
+		return h
+	}
+	ts := func(offset time.Duration) string { return strconv.FormatInt(now.Add(offset).Unix(), 10) }
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr error
+	}{
+		{"legacy without version", http.Header{}, nil},
+		{"fresh v2", header("v2", ts(-time.Minute), "abc"), nil},
+		{"stale v2", header("v2", ts(-10*time.Minute), "abc"), ErrStaleTimestamp},
+		{"future v2", header("v2", ts(10*time.Minute), "abc"), ErrStaleTimestamp},
+		{"missing nonce", header("v2", ts(0), ""), ErrInvalidHeaders},
+		{"bad timestamp", header("v2", "yesterday", "abc"), ErrInvalidHeaders},
+		{"unknown version", header("v9", ts(0), "abc"), ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.header, now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignRoundTrip(t *testing.T) {
+	priv, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	body := []byte(`{"productId":"did:pandacea:earner:123/abc-456"}`)
+	now := time.Now()
+
+	r := httptest.NewRequest("POST", "/api/v1/leases?b=2&a=1", bytes.NewReader(body))
+	if err := Sign(priv, r, body, now); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	params, err := Parse(r.Header, now, time.Minute)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(r.Header.Get(HeaderSignature))
+	if ok, _ := pub.Verify(SignedData(r, body, params), sig); !ok {
+		t.Fatal("signature does not verify against the signed request")
+	}
+
+	// Reordering the query keeps the signature valid; changing it does not
+	r.URL.RawQuery = "a=1&b=2"
+	if ok, _ := pub.Verify(SignedData(r, body, params), sig); !ok {
+		t.Error("reordered query should keep the same canonical form")
+	}
+	r.URL.RawQuery = "a=1&b=3"
+	if ok, _ := pub.Verify(SignedData(r, body, params), sig); ok {
+		t.Error("tampered query still verifies")
+	}
+}
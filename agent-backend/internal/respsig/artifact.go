@@ -0,0 +1,80 @@
+package respsig
+
+import "net/http"
+
+// ArtifactSignature proves which agent produced a training artifact. It is
+// returned with the job status and written next to the artifact as
+// <artifact>.sig.
+type ArtifactSignature struct {
+	JobID     string `json:"job_id"`
+	SHA256    string `json:"sha256"`     // Hex SHA-256 of the artifact bytes
+	Signature string `json:"signature"`  // Base64 signature of the artifact digest
+	PeerID    string `json:"peer_id"`    // Agent that signed the artifact
+	PublicKey string `json:"public_key"` // Base64 marshalled public key of PeerID
+}
+
+// Detached signature headers sent with an artifact download in place of
+// the response signature, alongside HeaderPeerID and HeaderPublicKey. The
+// signature covers the artifact itself, so it holds across Range requests
+// and gzip transfer.
+const (
+	HeaderArtifactSHA256    = "X-Pandacea-Artifact-SHA256"
+	HeaderArtifactSignature = "X-Pandacea-Artifact-Signature"
+)
+
+// ResultArtifactID returns the ID an artifact of a computation result is
+// signed under, in place of a job ID
+func ResultArtifactID(computationID, name string) string {
+	return computationID + "/" + name
+}
+
+// ArtifactDigest returns the canonical bytes signed for an artifact:
+//
+//	pandacea-artifact-v1\n<job ID>\n<hex sha256(artifact)>
+func ArtifactDigest(jobID, sha256Hex string) []byte {
+	return artifactDocument.digest(jobID, sha256Hex)
+}
+
+// SignArtifact hashes the artifact produced by a job and signs the digest
+func (s *Signer) SignArtifact(jobID string, data []byte) (*ArtifactSignature, error) {
+	return s.SignArtifactHash(jobID, hashHex(data))
+}
+
+// SignArtifactHash signs the digest of an artifact already hashed to
+// sha256Hex
+func (s *Signer) SignArtifactHash(jobID, sha256Hex string) (*ArtifactSignature, error) {
+	sig, err := s.sign(artifactDocument, jobID, sha256Hex)
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactSignature{sig.ID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, nil
+}
+
+// SetArtifactHeaders sets sig on h as the detached signature of an
+// artifact download
+func SetArtifactHeaders(h http.Header, sig *ArtifactSignature) {
+	h.Set(HeaderArtifactSHA256, sig.SHA256)
+	h.Set(HeaderArtifactSignature, sig.Signature)
+	h.Set(HeaderPeerID, sig.PeerID)
+	h.Set(HeaderPublicKey, sig.PublicKey)
+}
+
+// ArtifactFromHeaders reads the detached signature of an artifact download
+// from h. id is the ID the artifact was signed under; see ResultArtifactID.
+func ArtifactFromHeaders(h http.Header, id string) ArtifactSignature {
+	return ArtifactSignature{
+		JobID:     id,
+		SHA256:    h.Get(HeaderArtifactSHA256),
+		Signature: h.Get(HeaderArtifactSignature),
+		PeerID:    h.Get(HeaderPeerID),
+		PublicKey: h.Get(HeaderPublicKey),
+	}
+}
+
+// VerifyArtifact checks an artifact signature. If data is not nil it must
+// hash to the signed SHA-256, and if expectedPeerID is not empty the
+// artifact must be signed by that peer. It returns the peer ID that signed
+// the artifact.
+func VerifyArtifact(sig ArtifactSignature, data []byte, expectedPeerID string) (string, error) {
+	return artifactDocument.verify(documentSignature{sig.JobID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, data, expectedPeerID)
+}
@@ -0,0 +1,33 @@
+package respsig
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestArtifactHeaders(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	data := []byte("model weights")
+	id := ResultArtifactID("comp_1", "model/weights.bin")
+
+	sig, err := signer.SignArtifact(id, data)
+	if err != nil {
+		t.Fatalf("SignArtifact() error = %v", err)
+	}
+	h := http.Header{}
+	SetArtifactHeaders(h, sig)
+	if h.Get(HeaderSignature) != "" {
+		t.Errorf("detached signature set the response signature header")
+	}
+	if _, err := VerifyArtifact(ArtifactFromHeaders(h, id), data, signer.PeerID()); err != nil {
+		t.Errorf("VerifyArtifact() error = %v", err)
+	}
+	// The signature is bound to the computation and artifact name
+	other := ResultArtifactID("comp_1", "model/other.bin")
+	if _, err := VerifyArtifact(ArtifactFromHeaders(h, other), data, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyArtifact() for another artifact error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
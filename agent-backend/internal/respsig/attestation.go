@@ -0,0 +1,36 @@
+package respsig
+
+// AttestationSignature proves which agent attested to what a computation
+// ran on. Its SHA256 is the hash that is anchored on-chain.
+type AttestationSignature struct {
+	ComputationID string `json:"computation_id"`
+	SHA256        string `json:"sha256"`     // Hex SHA-256 of the statement bytes
+	Signature     string `json:"signature"`  // Base64 signature of the attestation digest
+	PeerID        string `json:"peer_id"`    // Agent that signed the statement
+	PublicKey     string `json:"public_key"` // Base64 marshalled public key of PeerID
+}
+
+// AttestationDigest returns the canonical bytes signed for a computation
+// attestation:
+//
+//	pandacea-attestation-v1\n<computation ID>\n<hex sha256(statement)>
+func AttestationDigest(computationID, sha256Hex string) []byte {
+	return attestationDocument.digest(computationID, sha256Hex)
+}
+
+// SignAttestation hashes a computation's attestation statement and signs
+// the digest
+func (s *Signer) SignAttestation(computationID string, statement []byte) (*AttestationSignature, error) {
+	sig, err := s.sign(attestationDocument, computationID, hashHex(statement))
+	if err != nil {
+		return nil, err
+	}
+	return &AttestationSignature{sig.ID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, nil
+}
+
+// VerifyAttestation checks an attestation signature. The statement must
+// hash to the signed SHA-256, and if expectedPeerID is not empty it must be
+// signed by that peer. It returns the peer ID that signed the statement.
+func VerifyAttestation(sig AttestationSignature, statement []byte, expectedPeerID string) (string, error) {
+	return attestationDocument.verify(documentSignature{sig.ComputationID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, statement, expectedPeerID)
+}
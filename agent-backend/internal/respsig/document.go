@@ -0,0 +1,101 @@
+package respsig
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// document is a kind of signed document. Its signature covers the
+// canonical digest
+//
+//	<prefix>\n<document ID>\n<hex sha256(document)>
+//
+// The prefix versions the format and keeps a signature over one kind of
+// document from verifying as another. Binding the ID stops a signature
+// being replayed for another dispute, job or receipt.
+type document struct {
+	prefix string
+	name   string // What errors call the document
+}
+
+// Signed document kinds
+var (
+	evidenceDocument    = document{prefix: "pandacea-evidence-v1", name: "evidence manifest"}
+	attestationDocument = document{prefix: "pandacea-attestation-v1", name: "attestation"}
+	receiptDocument     = document{prefix: "pandacea-consent-receipt-v1", name: "consent receipt"}
+	artifactDocument    = document{prefix: "pandacea-artifact-v1", name: "artifact"}
+)
+
+// digest returns the canonical bytes signed for the document with id
+func (d document) digest(id, sha256Hex string) []byte {
+	return []byte(d.prefix + "\n" + id + "\n" + sha256Hex)
+}
+
+// documentSignature holds the fields every document signature carries. The
+// exported signature types name the ID after what it identifies.
+type documentSignature struct {
+	ID        string
+	SHA256    string // Hex SHA-256 of the document bytes
+	Signature string // Base64 signature of the digest
+	PeerID    string // Agent that signed the document
+	PublicKey string // Base64 marshalled public key of PeerID
+}
+
+// hashHex returns the hex SHA-256 of data
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign signs the digest of a document with id already hashed to sha256Hex
+func (s *Signer) sign(d document, id, sha256Hex string) (documentSignature, error) {
+	sig, err := s.priv.Sign(d.digest(id, sha256Hex))
+	if err != nil {
+		return documentSignature{}, fmt.Errorf("failed to sign %s: %w", d.name, err)
+	}
+	return documentSignature{
+		ID:        id,
+		SHA256:    sha256Hex,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+		PeerID:    s.peerID,
+		PublicKey: s.pubKey,
+	}, nil
+}
+
+// verify checks a document signature. If data is not nil it must hash to
+// the signed SHA-256, and if expectedPeerID is not empty the document must
+// be signed by that peer. It returns the peer ID that signed the document.
+func (d document) verify(sig documentSignature, data []byte, expectedPeerID string) (string, error) {
+	if sig.Signature == "" || sig.PeerID == "" {
+		return "", ErrMissingSignature
+	}
+	if data != nil && hashHex(data) != sig.SHA256 {
+		return "", fmt.Errorf("%w: %s does not match signed hash", ErrInvalidSignature, d.name)
+	}
+
+	id, err := peer.Decode(sig.PeerID)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid peer ID: %v", ErrInvalidSignature, err)
+	}
+	if expectedPeerID != "" && id.String() != expectedPeerID {
+		return "", fmt.Errorf("%w: got %s, want %s", ErrPeerMismatch, id, expectedPeerID)
+	}
+
+	pub, err := publicKey(id, sig.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: signature is not base64: %v", ErrInvalidSignature, err)
+	}
+	ok, err := pub.Verify(d.digest(sig.ID, sig.SHA256), raw)
+	if err != nil || !ok {
+		return "", fmt.Errorf("%w: signature does not match %s", ErrInvalidSignature, d.name)
+	}
+	return id.String(), nil
+}
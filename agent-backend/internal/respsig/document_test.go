@@ -0,0 +1,96 @@
+package respsig
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestSignVerifyDocument(t *testing.T) {
+	data := []byte(`{"dispute_id":"dispute_1","items":[]}`)
+	for _, keyType := range []int{crypto.Ed25519, crypto.Secp256k1} {
+		signer := newTestSigner(t, keyType)
+		other := newTestSigner(t, crypto.Ed25519)
+
+		sig, err := signer.sign(evidenceDocument, "dispute_1", hashHex(data))
+		if err != nil {
+			t.Fatalf("sign() error = %v", err)
+		}
+		if got, err := evidenceDocument.verify(sig, data, signer.PeerID()); err != nil || got != signer.PeerID() {
+			t.Fatalf("verify() = %s, %v; want %s", got, err, signer.PeerID())
+		}
+		// Without the document bytes the signed hash is verified on its own
+		if _, err := evidenceDocument.verify(sig, nil, ""); err != nil {
+			t.Errorf("verify() without data error = %v", err)
+		}
+
+		otherID := sig
+		otherID.ID = "dispute_2"
+		otherHash := sig
+		otherHash.SHA256 = "00" + sig.SHA256[2:]
+		impostor := sig
+		impostor.PeerID = other.PeerID()
+		tests := []struct {
+			name string
+			doc  document
+			sig  documentSignature
+			data []byte
+			peer string
+			want error
+		}{
+			{"data", evidenceDocument, sig, []byte(`{"dispute_id":"dispute_1","items":[{}]}`), "", ErrInvalidSignature},
+			{"ID", evidenceDocument, otherID, data, "", ErrInvalidSignature},
+			{"hash", evidenceDocument, otherHash, nil, "", ErrInvalidSignature},
+			{"kind", attestationDocument, sig, data, "", ErrInvalidSignature},
+			{"public key", evidenceDocument, impostor, data, "", ErrInvalidSignature},
+			{"expected peer", evidenceDocument, sig, data, other.PeerID(), ErrPeerMismatch},
+			{"unsigned", evidenceDocument, documentSignature{ID: "dispute_1", SHA256: sig.SHA256}, data, "", ErrMissingSignature},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := tt.doc.verify(tt.sig, tt.data, tt.peer); !errors.Is(err, tt.want) {
+					t.Errorf("verify() error = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// Each signature type round-trips through its document kind under its own ID
+func TestSignedDocumentTypes(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	data := []byte("document")
+
+	evidence, err := signer.SignEvidence("dispute_1", data)
+	if err != nil {
+		t.Fatalf("SignEvidence() error = %v", err)
+	}
+	attestation, err := signer.SignAttestation("comp_1", data)
+	if err != nil {
+		t.Fatalf("SignAttestation() error = %v", err)
+	}
+	receipt, err := signer.SignReceipt("lease_prop_1", data)
+	if err != nil {
+		t.Fatalf("SignReceipt() error = %v", err)
+	}
+	artifact, err := signer.SignArtifact("job_1", data)
+	if err != nil {
+		t.Fatalf("SignArtifact() error = %v", err)
+	}
+	if evidence.DisputeID != "dispute_1" || attestation.ComputationID != "comp_1" || receipt.ReceiptID != "lease_prop_1" || artifact.JobID != "job_1" {
+		t.Errorf("signature IDs = %s, %s, %s, %s", evidence.DisputeID, attestation.ComputationID, receipt.ReceiptID, artifact.JobID)
+	}
+
+	verifiers := map[string]func() (string, error){
+		"evidence":    func() (string, error) { return VerifyEvidence(*evidence, data, signer.PeerID()) },
+		"attestation": func() (string, error) { return VerifyAttestation(*attestation, data, signer.PeerID()) },
+		"receipt":     func() (string, error) { return VerifyReceipt(*receipt, data, signer.PeerID()) },
+		"artifact":    func() (string, error) { return VerifyArtifact(*artifact, data, signer.PeerID()) },
+	}
+	for name, verify := range verifiers {
+		if _, err := verify(); err != nil {
+			t.Errorf("verify %s error = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package respsig
+
+// EvidenceSignature proves which agent packaged a dispute's evidence
+// manifest. It is pinned next to the manifest in the evidence bundle.
+type EvidenceSignature struct {
+	DisputeID string `json:"dispute_id"`
+	SHA256    string `json:"sha256"`     // Hex SHA-256 of the manifest bytes
+	Signature string `json:"signature"`  // Base64 signature of the evidence digest
+	PeerID    string `json:"peer_id"`    // Agent that signed the manifest
+	PublicKey string `json:"public_key"` // Base64 marshalled public key of PeerID
+}
+
+// EvidenceDigest returns the canonical bytes signed for an evidence
+// manifest:
+//
+//	pandacea-evidence-v1\n<dispute ID>\n<hex sha256(manifest)>
+func EvidenceDigest(disputeID, sha256Hex string) []byte {
+	return evidenceDocument.digest(disputeID, sha256Hex)
+}
+
+// SignEvidence hashes a dispute's evidence manifest and signs the digest
+func (s *Signer) SignEvidence(disputeID string, manifest []byte) (*EvidenceSignature, error) {
+	sig, err := s.sign(evidenceDocument, disputeID, hashHex(manifest))
+	if err != nil {
+		return nil, err
+	}
+	return &EvidenceSignature{sig.ID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, nil
+}
+
+// VerifyEvidence checks an evidence manifest signature. The manifest must
+// hash to the signed SHA-256, and if expectedPeerID is not empty it must
+// be signed by that peer. It returns the peer ID that signed the manifest.
+func VerifyEvidence(sig EvidenceSignature, manifest []byte, expectedPeerID string) (string, error) {
+	return evidenceDocument.verify(documentSignature{sig.DisputeID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, manifest, expectedPeerID)
+}
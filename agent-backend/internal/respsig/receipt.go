@@ -0,0 +1,34 @@
+package respsig
+
+// ReceiptSignature proves which agent issued a lease's consent receipt. It
+// is stored with the receipt on the lease.
+type ReceiptSignature struct {
+	ReceiptID string `json:"receipt_id"`
+	SHA256    string `json:"sha256"`     // Hex SHA-256 of the receipt bytes
+	Signature string `json:"signature"`  // Base64 signature of the receipt digest
+	PeerID    string `json:"peer_id"`    // Agent that signed the receipt
+	PublicKey string `json:"public_key"` // Base64 marshalled public key of PeerID
+}
+
+// ReceiptDigest returns the canonical bytes signed for a consent receipt:
+//
+//	pandacea-consent-receipt-v1\n<receipt ID>\n<hex sha256(receipt)>
+func ReceiptDigest(receiptID, sha256Hex string) []byte {
+	return receiptDocument.digest(receiptID, sha256Hex)
+}
+
+// SignReceipt hashes a consent receipt and signs the digest
+func (s *Signer) SignReceipt(receiptID string, receipt []byte) (*ReceiptSignature, error) {
+	sig, err := s.sign(receiptDocument, receiptID, hashHex(receipt))
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiptSignature{sig.ID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, nil
+}
+
+// VerifyReceipt checks a consent receipt signature. The receipt must hash
+// to the signed SHA-256, and if expectedPeerID is not empty it must be
+// signed by that peer. It returns the peer ID that signed the receipt.
+func VerifyReceipt(sig ReceiptSignature, receipt []byte, expectedPeerID string) (string, error) {
+	return receiptDocument.verify(documentSignature{sig.ReceiptID, sig.SHA256, sig.Signature, sig.PeerID, sig.PublicKey}, receipt, expectedPeerID)
+}
@@ -0,0 +1,184 @@
+// Package respsig signs and verifies agent API responses so spenders can
+// check that a response really came from the earner agent they addressed.
+// The same key signs training artifacts; see ArtifactDigest.
+//
+// The signature covers a canonical digest of the request it answers and
+// of the response: the request method, escaped path, canonical query (see
+// reqsig.CanonicalQuery) and the timestamp and nonce the request was signed
+// with, then the response status and a SHA-256 hash of the response body:
+//
+//	pandacea-response-v2\n<METHOD>\n<path>\n<query>\n<timestamp>\n<nonce>\n<status>\n<hex sha256(body)>
+//
+// The nonce ties a response to one request, so a response recorded for an
+// earlier request cannot be replayed as the answer to a later one.
+//
+// The agent signs the digest with its libp2p private key and sends the
+// base64 signature, its peer ID and its base64 marshalled public key in the
+// X-Pandacea-Signature, X-Pandacea-Peer-ID and X-Pandacea-Public-Key headers.
+// The public key header is needed because RSA keys cannot be recovered from
+// a peer ID; verifiers check that it hashes to the claimed peer ID.
+package respsig
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Response signature headers
+const (
+	HeaderSignature = "X-Pandacea-Signature"
+	HeaderPeerID    = "X-Pandacea-Peer-ID"
+	HeaderPublicKey = "X-Pandacea-Public-Key"
+)
+
+// digestPrefix versions the canonical digest format
+const digestPrefix = "pandacea-response-v2"
+
+// Errors returned by Verify. Callers branch on them with errors.Is; the
+// wrapped messages carry the details.
+var (
+	ErrMissingSignature = errors.New("response is not signed")
+	ErrInvalidSignature = errors.New("invalid response signature")
+	ErrPeerMismatch     = errors.New("response signed by unexpected peer")
+)
+
+// Digest returns the canonical bytes signed for a response to r
+func Digest(r *http.Request, status int, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		digestPrefix,
+		r.Method,
+		r.URL.EscapedPath(),
+		reqsig.CanonicalQuery(r.URL.RawQuery),
+		r.Header.Get(reqsig.HeaderTimestamp),
+		r.Header.Get(reqsig.HeaderNonce),
+		strconv.Itoa(status),
+		hex.EncodeToString(sum[:]),
+	}, "\n"))
+}
+
+// Signer signs responses with an agent's libp2p key
+type Signer struct {
+	priv   crypto.PrivKey
+	peerID string
+	pubKey string
+}
+
+// NewSigner creates a signer for priv
+func NewSigner(priv crypto.PrivKey) (*Signer, error) {
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+	pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return &Signer{
+		priv:   priv,
+		peerID: id.String(),
+		pubKey: base64.StdEncoding.EncodeToString(pub),
+	}, nil
+}
+
+// PeerID returns the peer ID responses are signed as
+func (s *Signer) PeerID() string {
+	return s.peerID
+}
+
+// Sign sets the signature headers on h for a response to r
+func (s *Signer) Sign(h http.Header, r *http.Request, status int, body []byte) error {
+	sig, err := s.priv.Sign(Digest(r, status, body))
+	if err != nil {
+		return fmt.Errorf("failed to sign response: %w", err)
+	}
+	h.Set(HeaderSignature, base64.StdEncoding.EncodeToString(sig))
+	h.Set(HeaderPeerID, s.peerID)
+	h.Set(HeaderPublicKey, s.pubKey)
+	return nil
+}
+
+// Verify checks the signature headers in h against a response to r, the
+// request as the client sent it. If expectedPeerID is not empty the
+// response must be signed by that peer. It returns the peer ID that signed
+// the response.
+func Verify(h http.Header, r *http.Request, status int, body []byte, expectedPeerID string) (string, error) {
+	sigHeader, peerHeader := h.Get(HeaderSignature), h.Get(HeaderPeerID)
+	if sigHeader == "" || peerHeader == "" {
+		return "", ErrMissingSignature
+	}
+
+	id, err := peer.Decode(peerHeader)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid peer ID: %v", ErrInvalidSignature, err)
+	}
+	if expectedPeerID != "" && id.String() != expectedPeerID {
+		return "", fmt.Errorf("%w: got %s, want %s", ErrPeerMismatch, id, expectedPeerID)
+	}
+
+	pub, err := publicKey(id, h.Get(HeaderPublicKey))
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigHeader)
+	if err != nil {
+		return "", fmt.Errorf("%w: signature is not base64: %v", ErrInvalidSignature, err)
+	}
+	ok, err := pub.Verify(Digest(r, status, body), sig)
+	if err != nil || !ok {
+		return "", fmt.Errorf("%w: signature does not match response", ErrInvalidSignature)
+	}
+	return id.String(), nil
+}
+
+// VerifyResponse reads and verifies resp, returning its body. The body is
+// consumed and closed.
+func VerifyResponse(resp *http.Response, expectedPeerID string) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if _, err := Verify(resp.Header, resp.Request, resp.StatusCode, body, expectedPeerID); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// publicKey returns the key for id, taken from the public key header when
+// the peer ID does not embed it
+func publicKey(id peer.ID, header string) (crypto.PubKey, error) {
+	if header == "" {
+		pub, err := id.ExtractPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: missing public key for %s", ErrInvalidSignature, id)
+		}
+		return pub, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key is not base64: %v", ErrInvalidSignature, err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key: %v", ErrInvalidSignature, err)
+	}
+	if !id.MatchesPublicKey(pub) {
+		return nil, fmt.Errorf("%w: public key does not match peer ID %s", ErrInvalidSignature, id)
+	}
+	return pub, nil
+}
@@ -0,0 +1,118 @@
+package respsig
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pandacea/agent-backend/internal/reqsig"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func newTestSigner(t *testing.T, keyType int) *Signer {
+	t.Helper()
+	bits := -1
+	if keyType == crypto.RSA {
+		bits = 2048
+	}
+	priv, _, err := crypto.GenerateKeyPairWithReader(keyType, bits, rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	signer, err := NewSigner(priv)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return signer
+}
+
+// signedRequest builds a request carrying the signature headers the
+// response digest binds
+func signedRequest(method, target, nonce string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(reqsig.HeaderTimestamp, "1700000000")
+	r.Header.Set(reqsig.HeaderNonce, nonce)
+	return r
+}
+
+func TestSignVerify(t *testing.T) {
+	for name, keyType := range map[string]int{"ed25519": crypto.Ed25519, "rsa": crypto.RSA} {
+		t.Run(name, func(t *testing.T) {
+			signer := newTestSigner(t, keyType)
+			body := []byte(`{"leaseProposalId":"lease_1"}`)
+			r := signedRequest("POST", "/api/v1/leases", "n1")
+			h := http.Header{}
+			if err := signer.Sign(h, r, 202, body); err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			got, err := Verify(h, r, 202, body, signer.PeerID())
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got != signer.PeerID() {
+				t.Errorf("Verify() peer = %s, want %s", got, signer.PeerID())
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	signer := newTestSigner(t, crypto.Ed25519)
+	other := newTestSigner(t, crypto.Ed25519)
+	body := []byte(`{"status":"approved"}`)
+	h := http.Header{}
+	if err := signer.Sign(h, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1"), 200, body); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	staleTimestamp := signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1")
+	staleTimestamp.Header.Set(reqsig.HeaderTimestamp, "1600000000")
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		request *http.Request
+		status  int
+		body    []byte
+		peer    string
+		want    error
+	}{
+		{"body", h, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1"), 200, []byte(`{"status":"rejected"}`), "", ErrInvalidSignature},
+		{"status", h, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1"), 404, body, "", ErrInvalidSignature},
+		{"path", h, signedRequest("GET", "/api/v1/leases/lease_2?view=full", "n1"), 200, body, "", ErrInvalidSignature},
+		{"method", h, signedRequest("POST", "/api/v1/leases/lease_1?view=full", "n1"), 200, body, "", ErrInvalidSignature},
+		{"query", h, signedRequest("GET", "/api/v1/leases/lease_1?view=summary", "n1"), 200, body, "", ErrInvalidSignature},
+		{"nonce", h, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n2"), 200, body, "", ErrInvalidSignature},
+		{"timestamp", h, staleTimestamp, 200, body, "", ErrInvalidSignature},
+		{"expected peer", h, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1"), 200, body, other.PeerID(), ErrPeerMismatch},
+		{"unsigned", http.Header{}, signedRequest("GET", "/api/v1/leases/lease_1?view=full", "n1"), 200, body, "", ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.header, tt.request, tt.status, tt.body, tt.peer); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsForeignPublicKey(t *testing.T) {
+	signer := newTestSigner(t, crypto.RSA)
+	impostor := newTestSigner(t, crypto.RSA)
+	body := []byte(`{}`)
+	r := signedRequest("GET", "/api/v1/products", "n1")
+
+	// A valid signature from one key cannot be passed off as another peer's
+	h := http.Header{}
+	if err := impostor.Sign(h, r, 200, body); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	h.Set(HeaderPeerID, signer.PeerID())
+	if _, err := Verify(h, r, 200, body, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() error = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,155 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"pandacea/agent-backend/internal/p2p"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ProtocolID is the libp2p protocol revocations are gossiped over
+const ProtocolID protocol.ID = "/pandacea/revocations/1.0.0"
+
+// MaxMessageSize is the largest batch of entries that is read
+const MaxMessageSize = 1 << 20
+
+// sendTimeout bounds pushing entries to one peer
+const sendTimeout = 10 * time.Second
+
+// PeerReporter lowers the reputation of peers that misbehave on the
+// revocation protocol
+type PeerReporter interface {
+	ReportPeer(id peer.ID, offense p2p.Offense)
+}
+
+// Gossip pushes revocations to connected peers and applies the ones they
+// push. Each stream carries one batch of entries and no response. Entries
+// that change the registry are forwarded to the other connected peers, so
+// a revocation reaches agents that are not connected to its issuer.
+type Gossip struct {
+	host     host.Host
+	registry *Registry
+	reporter PeerReporter
+	logger   *slog.Logger
+}
+
+// Serve registers the revocation protocol on h, applying the entries peers
+// push to registry. Peers that send malformed batches or forged entries
+// are reported to a non-nil reporter.
+func Serve(h host.Host, registry *Registry, reporter PeerReporter, logger *slog.Logger) *Gossip {
+	g := &Gossip{host: h, registry: registry, reporter: reporter, logger: logger}
+	h.SetStreamHandler(ProtocolID, g.handleStream)
+	return g
+}
+
+// handleStream applies one batch of entries
+func (g *Gossip) handleStream(s network.Stream) {
+	defer s.Close()
+	remote := s.Conn().RemotePeer()
+
+	var entries []Entry
+	if err := json.NewDecoder(io.LimitReader(s, MaxMessageSize)).Decode(&entries); err != nil {
+		g.logger.Warn("failed to decode revocations", "peer_id", remote.String(), "error", err)
+		g.report(remote, p2p.OffenseInvalidMessage)
+		s.Reset()
+		return
+	}
+
+	var changed []Entry
+	for _, e := range entries {
+		applied, err := g.registry.Apply(e)
+		switch {
+		case errors.Is(err, ErrUntrustedIssuer):
+			// Relays forward what they trust, which need not be what we do
+			g.logger.Debug("ignored revocation from untrusted issuer", "peer_id", remote.String(), "issuer", e.Issuer)
+		case errors.Is(err, ErrFutureEntry):
+			// The issuer's clock is off; the relay is not to blame
+			g.logger.Warn("ignored revocation issued in the future", "peer_id", remote.String(), "issuer", e.Issuer, "error", err)
+		case err != nil:
+			g.logger.Warn("rejected gossiped revocation", "peer_id", remote.String(), "identity", e.Identity, "error", err)
+			g.report(remote, p2p.OffenseProtocolViolation)
+		case applied:
+			g.logger.Warn("applied gossiped revocation", "identity", e.Identity, "revoked", e.Revoked, "issuer", e.Issuer, "peer_id", remote.String())
+			changed = append(changed, e)
+		}
+	}
+	if len(changed) > 0 {
+		go g.push(context.Background(), changed, remote)
+	}
+}
+
+// report reports a misbehaving peer to a non-nil reporter
+func (g *Gossip) report(id peer.ID, offense p2p.Offense) {
+	if g.reporter != nil {
+		g.reporter.ReportPeer(id, offense)
+	}
+}
+
+// Publish pushes entries to every connected peer
+func (g *Gossip) Publish(ctx context.Context, entries ...Entry) {
+	g.push(ctx, entries, "")
+}
+
+// push sends entries to every connected peer except skip. Peers that do not
+// speak the protocol are skipped silently.
+func (g *Gossip) push(ctx context.Context, entries []Entry, skip peer.ID) {
+	if len(entries) == 0 {
+		return
+	}
+	for _, id := range g.host.Network().Peers() {
+		if id == skip {
+			continue
+		}
+		if err := g.send(ctx, id, entries); err != nil {
+			g.logger.Debug("failed to push revocations", "peer_id", id.String(), "error", err)
+		}
+	}
+}
+
+// send pushes entries to one peer over a new stream
+func (g *Gossip) send(ctx context.Context, id peer.ID, entries []Entry) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	s, err := g.host.NewStream(ctx, id, ProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+	if err := json.NewEncoder(s).Encode(entries); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send revocations: %w", err)
+	}
+	return nil
+}
+
+// Run re-pushes every entry held to connected peers every interval until
+// ctx is cancelled, so peers that connect after a revocation still learn it
+func (g *Gossip) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.push(ctx, g.registry.Entries(), "")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Package revocation keeps the identities an agent no longer accepts
+// requests from: peer IDs whose keys were compromised and spender
+// addresses acting for them. Revocations are signed by the agent that
+// issues them, so they can be gossiped between agents and applied by every
+// agent that trusts the issuer.
+package revocation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// signingDomain separates revocation signatures from the issuer's other
+// signatures
+const signingDomain = "pandacea-revocation-v1"
+
+// maxClockSkew is how far in the future a gossiped entry may be issued.
+// Later entries would win over every entry issued until then.
+const maxClockSkew = 5 * time.Minute
+
+var (
+	// ErrRevoked is returned for requests from a revoked identity
+	ErrRevoked = errors.New("identity revoked")
+
+	// ErrInvalidIdentity is returned for identities that are neither a
+	// peer ID nor an Ethereum address
+	ErrInvalidIdentity = errors.New("invalid identity")
+
+	// ErrNotRevoked is returned when unrevoking an identity that is not
+	// revoked
+	ErrNotRevoked = errors.New("identity is not revoked")
+
+	// ErrInvalidSignature is returned for entries not signed by their issuer
+	ErrInvalidSignature = errors.New("invalid revocation signature")
+
+	// ErrUntrustedIssuer is returned for entries issued by an agent that is
+	// not trusted to revoke identities
+	ErrUntrustedIssuer = errors.New("untrusted revocation issuer")
+
+	// ErrFutureEntry is returned for entries issued further in the future
+	// than clocks drift apart
+	ErrFutureEntry = errors.New("revocation issued in the future")
+)
+
+// Entry is the latest revocation or unrevocation of an identity. Unrevoked
+// entries are kept so older revocations gossiped later do not undo them.
+type Entry struct {
+	Identity  string    `json:"identity"` // Peer ID, or lowercase 0x address
+	Revoked   bool      `json:"revoked"`
+	Reason    string    `json:"reason,omitempty"`
+	Issuer    string    `json:"issuer"`     // Peer ID of the agent that issued the entry
+	IssuerKey []byte    `json:"issuer_key"` // Issuer's marshalled public key
+	IssuedAt  time.Time `json:"issued_at"`
+	Signature []byte    `json:"signature"`
+}
+
+// Normalize returns the canonical form of a peer ID or Ethereum address
+func Normalize(identity string) (string, error) {
+	identity = strings.TrimSpace(identity)
+	if common.IsHexAddress(identity) {
+		return strings.ToLower(common.HexToAddress(identity).Hex()), nil
+	}
+	if id, err := peer.Decode(identity); err == nil {
+		return id.String(), nil
+	}
+	return "", fmt.Errorf("%w: %q is neither a peer ID nor an address", ErrInvalidIdentity, identity)
+}
+
+// signedData is what an entry's signature covers
+func (e Entry) signedData() []byte {
+	return []byte(strings.Join([]string{
+		signingDomain,
+		e.Identity,
+		strconv.FormatBool(e.Revoked),
+		e.Reason,
+		e.Issuer,
+		e.IssuedAt.UTC().Format(time.RFC3339Nano),
+	}, "\n"))
+}
+
+// sign signs e as the agent holding key
+func (e *Entry) sign(key crypto.PrivKey) error {
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to derive issuer peer ID: %w", err)
+	}
+	pub, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return fmt.Errorf("failed to marshal issuer key: %w", err)
+	}
+	e.Issuer = id.String()
+	e.IssuerKey = pub
+	e.Signature, err = key.Sign(e.signedData())
+	if err != nil {
+		return fmt.Errorf("failed to sign revocation: %w", err)
+	}
+	return nil
+}
+
+// Verify checks that e is signed by its issuer
+func (e Entry) Verify() error {
+	issuer, err := peer.Decode(e.Issuer)
+	if err != nil {
+		return fmt.Errorf("%w: bad issuer: %v", ErrInvalidSignature, err)
+	}
+	pub, err := crypto.UnmarshalPublicKey(e.IssuerKey)
+	if err != nil {
+		return fmt.Errorf("%w: bad issuer key: %v", ErrInvalidSignature, err)
+	}
+	if !issuer.MatchesPublicKey(pub) {
+		return fmt.Errorf("%w: key does not match issuer", ErrInvalidSignature)
+	}
+	if ok, err := pub.Verify(e.signedData(), e.Signature); err != nil || !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Registry holds the revocations an agent applies: the ones it issued and
+// the ones gossiped by the agents it trusts. Entries are persisted to a
+// JSON file when a path is given.
+type Registry struct {
+	key     crypto.PrivKey
+	self    peer.ID
+	trusted map[peer.ID]bool
+	path    string
+	logger  *slog.Logger
+
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// New returns a registry issuing revocations with key and applying those
+// of the trusted issuers, restoring the entries saved at path
+func New(key crypto.PrivKey, trusted []string, path string, logger *slog.Logger) (*Registry, error) {
+	self, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive peer ID: %w", err)
+	}
+	r := &Registry{
+		key:     key,
+		self:    self,
+		trusted: make(map[peer.ID]bool, len(trusted)),
+		path:    path,
+		logger:  logger,
+		entries: make(map[string]*Entry),
+	}
+	for _, issuer := range trusted {
+		id, err := peer.Decode(issuer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted issuer %q: %w", issuer, err)
+		}
+		r.trusted[id] = true
+	}
+
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocations: %w", err)
+	}
+	var saved []*Entry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse revocations: %w", err)
+	}
+	for _, e := range saved {
+		r.entries[e.Identity] = e
+	}
+	return r, nil
+}
+
+// Revoke revokes identity, effective immediately, and returns the signed
+// entry to gossip
+func (r *Registry) Revoke(identity, reason string) (Entry, error) {
+	return r.issue(identity, true, reason)
+}
+
+// Unrevoke lifts the revocation of identity and returns the signed entry
+// to gossip. Revocations issued by other agents can be lifted too.
+func (r *Registry) Unrevoke(identity string) (Entry, error) {
+	normalized, err := Normalize(identity)
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, revoked := r.Revoked(normalized); !revoked {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotRevoked, normalized)
+	}
+	return r.issue(normalized, false, "")
+}
+
+// issue signs and applies a new entry for identity
+func (r *Registry) issue(identity string, revoked bool, reason string) (Entry, error) {
+	normalized, err := Normalize(identity)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		Identity: normalized,
+		Revoked:  revoked,
+		Reason:   reason,
+		IssuedAt: time.Now().UTC(),
+	}
+	if err := e.sign(r.key); err != nil {
+		return Entry{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Keep entries ordered even if the clock stepped back
+	if existing, ok := r.entries[normalized]; ok && !e.IssuedAt.After(existing.IssuedAt) {
+		e.IssuedAt = existing.IssuedAt.Add(time.Nanosecond)
+		if err := e.sign(r.key); err != nil {
+			return Entry{}, err
+		}
+	}
+	previous := r.entries[normalized]
+	r.entries[normalized] = &e
+	if err := r.save(); err != nil {
+		if previous != nil {
+			r.entries[normalized] = previous
+		} else {
+			delete(r.entries, normalized)
+		}
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// Apply applies an entry gossiped by another agent. It reports whether the
+// entry changed the registry: entries no newer than the one held are
+// ignored, as are other issuers' entries for an identity this agent
+// revoked itself, which only this agent can lift. Entries must be signed
+// by this agent or a trusted issuer, and not issued in the future.
+func (r *Registry) Apply(e Entry) (bool, error) {
+	if err := e.Verify(); err != nil {
+		return false, err
+	}
+	issuer, _ := peer.Decode(e.Issuer)
+	if issuer != r.self && !r.trusted[issuer] {
+		return false, fmt.Errorf("%w: %s", ErrUntrustedIssuer, e.Issuer)
+	}
+	normalized, err := Normalize(e.Identity)
+	if err != nil || normalized != e.Identity {
+		return false, fmt.Errorf("%w: %q", ErrInvalidIdentity, e.Identity)
+	}
+	if e.IssuedAt.After(time.Now().Add(maxClockSkew)) {
+		return false, fmt.Errorf("%w: %s issued at %s", ErrFutureEntry, e.Identity, e.IssuedAt.Format(time.RFC3339))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.entries[e.Identity]
+	if ok && !e.IssuedAt.After(existing.IssuedAt) {
+		return false, nil
+	}
+	if ok && existing.Revoked && existing.Issuer == r.self.String() && issuer != r.self {
+		return false, nil
+	}
+	r.entries[e.Identity] = &e
+	if err := r.save(); err != nil {
+		// The entry still applies until restart
+		r.logger.Error("failed to persist revocation", "identity", e.Identity, "error", err)
+	}
+	return true, nil
+}
+
+// Revoked returns the revocation of identity, if it is revoked
+func (r *Registry) Revoked(identity string) (Entry, bool) {
+	normalized, err := Normalize(identity)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[normalized]
+	if !ok || !e.Revoked {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// List returns the revoked identities, most recently revoked first
+func (r *Registry) List() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		if e.Revoked {
+			list = append(list, *e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].IssuedAt.After(list[j].IssuedAt)
+	})
+	return list
+}
+
+// Entries returns every entry held, including unrevocations, for gossip
+func (r *Registry) Entries() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Identity < list[j].Identity
+	})
+	return list
+}
+
+// save writes the entries to disk. Caller must hold mu.
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	list := make([]*Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Identity < list[j].Identity
+	})
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to encode revocations: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create revocations directory: %w", err)
+	}
+	if err := fsutil.WriteFile(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write revocations: %w", err)
+	}
+	return nil
+}
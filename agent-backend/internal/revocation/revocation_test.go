@@ -0,0 +1,202 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newKey(t *testing.T) (crypto.PrivKey, peer.ID) {
+	t.Helper()
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("IDFromPrivateKey: %v", err)
+	}
+	return key, id
+}
+
+func TestRegistry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	key, _ := newKey(t)
+	_, compromised := newKey(t)
+	registry, err := New(key, nil, path, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := registry.Revoke("not-an-identity", "stolen"); !errors.Is(err, ErrInvalidIdentity) {
+		t.Fatalf("Revoke(invalid) error = %v, want ErrInvalidIdentity", err)
+	}
+	entry, err := registry.Revoke(compromised.String(), "key leaked")
+	if err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := entry.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := registry.Revoke("0xAbCdEf0123456789aBcDeF0123456789AbCdEf01", "spender key leaked"); err != nil {
+		t.Fatalf("Revoke(address): %v", err)
+	}
+
+	// Addresses match whatever their case
+	if _, revoked := registry.Revoked("0xabcdef0123456789abcdef0123456789abcdef01"); !revoked {
+		t.Fatal("address is not revoked")
+	}
+	if got := len(registry.List()); got != 2 {
+		t.Fatalf("List() has %d entries, want 2", got)
+	}
+
+	// Revocations survive a restart, and unrevoking keeps the entry so an
+	// older revocation cannot undo it
+	restored, err := New(key, nil, path, logger)
+	if err != nil {
+		t.Fatalf("New(restore): %v", err)
+	}
+	if _, revoked := restored.Revoked(compromised.String()); !revoked {
+		t.Fatal("revocation was not restored")
+	}
+	if _, err := restored.Unrevoke(compromised.String()); err != nil {
+		t.Fatalf("Unrevoke: %v", err)
+	}
+	if _, err := restored.Unrevoke(compromised.String()); !errors.Is(err, ErrNotRevoked) {
+		t.Fatalf("Unrevoke twice error = %v, want ErrNotRevoked", err)
+	}
+	if applied, err := restored.Apply(entry); err != nil || applied {
+		t.Fatalf("Apply(stale) = %v, %v; want false, nil", applied, err)
+	}
+	if _, revoked := restored.Revoked(compromised.String()); revoked {
+		t.Fatal("stale revocation undid the unrevocation")
+	}
+}
+
+func TestRegistryApply(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	issuerKey, issuer := newKey(t)
+	strangerKey, _ := newKey(t)
+	_, compromised := newKey(t)
+
+	issuerRegistry, _ := New(issuerKey, nil, "", logger)
+	strangerRegistry, _ := New(strangerKey, nil, "", logger)
+	ownKey, _ := newKey(t)
+	registry, err := New(ownKey, []string{issuer.String()}, "", logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Only trusted issuers revoke identities here
+	untrusted, _ := strangerRegistry.Revoke(compromised.String(), "spite")
+	if _, err := registry.Apply(untrusted); !errors.Is(err, ErrUntrustedIssuer) {
+		t.Fatalf("Apply(untrusted) error = %v, want ErrUntrustedIssuer", err)
+	}
+
+	// Entries cannot be altered after signing
+	trusted, _ := issuerRegistry.Revoke(compromised.String(), "key leaked")
+	forged := trusted
+	forged.Identity = issuer.String()
+	if _, err := registry.Apply(forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Apply(forged) error = %v, want ErrInvalidSignature", err)
+	}
+
+	if applied, err := registry.Apply(trusted); err != nil || !applied {
+		t.Fatalf("Apply(trusted) = %v, %v; want true, nil", applied, err)
+	}
+	if applied, _ := registry.Apply(trusted); applied {
+		t.Fatal("applying the same entry twice changed the registry")
+	}
+	if e, revoked := registry.Revoked(compromised.String()); !revoked || e.Issuer != issuer.String() {
+		t.Fatalf("Revoked() = %+v, %v", e, revoked)
+	}
+
+	// An entry issued far ahead would outrank every entry until then
+	future := Entry{Identity: compromised.String(), IssuedAt: time.Now().Add(time.Hour).UTC()}
+	if err := future.sign(issuerKey); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := registry.Apply(future); !errors.Is(err, ErrFutureEntry) {
+		t.Fatalf("Apply(future) error = %v, want ErrFutureEntry", err)
+	}
+
+	// A trusted issuer cannot lift a revocation this agent issued
+	if _, err := registry.Revoke(compromised.String(), "seen abusing leases"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	issuerRegistry.Revoke(compromised.String(), "key leaked")
+	lifted, err := issuerRegistry.Unrevoke(compromised.String())
+	if err != nil {
+		t.Fatalf("Unrevoke: %v", err)
+	}
+	if applied, err := registry.Apply(lifted); err != nil || applied {
+		t.Fatalf("Apply(lifted) = %v, %v; want false, nil", applied, err)
+	}
+	if e, revoked := registry.Revoked(compromised.String()); !revoked || e.Reason != "seen abusing leases" {
+		t.Fatalf("Revoked() after a trusted lift = %+v, %v; want the local revocation", e, revoked)
+	}
+}
+
+func TestGossip(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	issuerKey, issuer := newKey(t)
+	_, compromised := newKey(t)
+
+	// The issuer and the last agent are connected only through the middle
+	// one, and both trust the issuer
+	hosts := make([]*Gossip, 3)
+	registries := make([]*Registry, 3)
+	for i := range hosts {
+		key := issuerKey
+		if i > 0 {
+			key, _ = newKey(t)
+		}
+		h, err := libp2p.New(libp2p.Identity(key), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Fatalf("libp2p.New: %v", err)
+		}
+		defer h.Close()
+		registries[i], err = New(key, []string{issuer.String()}, "", logger)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		hosts[i] = Serve(h, registries[i], nil, logger)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, pair := range [][2]int{{0, 1}, {1, 2}} {
+		from, to := hosts[pair[0]].host, hosts[pair[1]].host
+		if err := from.Connect(ctx, peer.AddrInfo{ID: to.ID(), Addrs: to.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+
+	entry, err := registries[0].Revoke(compromised.String(), "key leaked")
+	if err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	hosts[0].Publish(ctx, entry)
+
+	for _, registry := range registries[1:] {
+		for {
+			if _, revoked := registry.Revoked(compromised.String()); revoked {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatal("revocation did not propagate")
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+}
@@ -0,0 +1,400 @@
+// Package scheduler runs training and computation jobs on a bounded pool of
+// workers, shared fairly between the identities that queue them.
+//
+// Queued tasks wait in one of three priority classes. Classes are served by
+// weighted round robin, so high priority tasks get most of the workers but
+// low priority tasks are never starved. Within a class, identities take
+// turns: an identity with many queued tasks runs one, then every other
+// identity waiting in the class runs one before it runs the next.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Scheduler defaults used when the config leaves them unset
+const (
+	DefaultWorkers              = 2
+	DefaultMaxQueued            = 256
+	DefaultMaxQueuedPerIdentity = 16
+)
+
+var (
+	// ErrQueueFull is returned when the scheduler holds its maximum of queued tasks
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrIdentityQueueFull is returned when an identity holds its maximum of queued tasks
+	ErrIdentityQueueFull = errors.New("too many queued jobs for this identity")
+	// ErrClosed is returned for tasks submitted after Close
+	ErrClosed = errors.New("scheduler is closed")
+)
+
+// Priority is a task's scheduling class
+type Priority int
+
+// Priority classes, lowest first
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// priorityWeights is how many tasks each class runs per round of the
+// weighted round robin
+var priorityWeights = [...]int{PriorityLow: 1, PriorityNormal: 2, PriorityHigh: 4}
+
+// String returns the priority's name as used in job status and metrics
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+var (
+	schedulerQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_scheduler_queued",
+		Help: "Jobs waiting for a scheduler worker",
+	}, []string{"priority"})
+	schedulerRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_scheduler_running",
+		Help: "Jobs running on scheduler workers",
+	})
+	schedulerRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_scheduler_rejected_total",
+		Help: "Total jobs rejected because the scheduler queue was full",
+	}, []string{"reason"})
+	schedulerWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pandacea_scheduler_wait_seconds",
+		Help:    "Time jobs spent queued before a worker ran them",
+		Buckets: []float64{0.1, 1, 5, 15, 60, 300, 900, 3600},
+	}, []string{"priority"})
+)
+
+// Task is a unit of work queued on the scheduler
+type Task struct {
+	ID       string   // Unique among queued tasks; used for positions and cancellation
+	Identity string   // Who the task runs for; identities share workers fairly
+	Priority Priority // Out-of-range priorities are clamped
+	Run      func()
+
+	// Drop, if set, is called instead of Run when Close discards the task
+	Drop func()
+
+	queuedAt time.Time
+}
+
+// Scheduler runs queued tasks on a bounded pool of workers
+type Scheduler struct {
+	maxQueued      int
+	maxPerIdentity int
+	logger         *slog.Logger
+	running        sync.WaitGroup
+
+	mu       sync.Mutex
+	ready    *sync.Cond
+	queue    queue
+	tasks    map[string]*Task
+	counts   map[string]int
+	inflight int
+	closed   bool
+}
+
+// New starts workers goroutines running tasks from a queue of at most
+// maxQueued tasks, of which at most maxPerIdentity may belong to one
+// identity
+func New(workers, maxQueued, maxPerIdentity int, logger *slog.Logger) *Scheduler {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if maxQueued <= 0 {
+		maxQueued = DefaultMaxQueued
+	}
+	if maxPerIdentity <= 0 {
+		maxPerIdentity = DefaultMaxQueuedPerIdentity
+	}
+
+	s := &Scheduler{
+		maxQueued:      maxQueued,
+		maxPerIdentity: maxPerIdentity,
+		logger:         logger,
+		queue:          newQueue(),
+		tasks:          make(map[string]*Task),
+		counts:         make(map[string]int),
+	}
+	s.ready = sync.NewCond(&s.mu)
+	for i := 0; i < workers; i++ {
+		s.running.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Submit queues a task. It never blocks; when the queue or the identity's
+// share of it is full the task is rejected.
+func (s *Scheduler) Submit(task Task) error {
+	if task.Run == nil {
+		return fmt.Errorf("task %s has nothing to run", task.ID)
+	}
+	task.Priority = max(PriorityLow, min(task.Priority, PriorityHigh))
+	task.queuedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.closed:
+		return ErrClosed
+	case s.tasks[task.ID] != nil:
+		return fmt.Errorf("task %s is already queued", task.ID)
+	case len(s.tasks) >= s.maxQueued:
+		schedulerRejectedTotal.WithLabelValues("queue_full").Inc()
+		return fmt.Errorf("%w: %d jobs queued", ErrQueueFull, len(s.tasks))
+	case s.counts[task.Identity] >= s.maxPerIdentity:
+		schedulerRejectedTotal.WithLabelValues("identity_full").Inc()
+		return fmt.Errorf("%w: %d jobs queued", ErrIdentityQueueFull, s.counts[task.Identity])
+	}
+
+	t := &task
+	s.queue.push(t)
+	s.tasks[t.ID] = t
+	s.counts[t.Identity]++
+	schedulerQueued.WithLabelValues(t.Priority.String()).Inc()
+	s.ready.Signal()
+	return nil
+}
+
+// Position returns how many tasks will start before the queued task id,
+// plus one, or false if the task is not queued. Positions assume no other
+// tasks are submitted, so a later high priority task can move a task back.
+func (s *Scheduler) Position(id string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tasks[id] == nil {
+		return 0, false
+	}
+	order := s.queue.clone()
+	for position := 1; ; position++ {
+		if order.pop().ID == id {
+			return position, true
+		}
+	}
+}
+
+// Cancel removes a queued task without running it. It returns false if the
+// task is not queued, including when it has already started.
+func (s *Scheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tasks[id]
+	if t == nil {
+		return false
+	}
+	s.queue.remove(t)
+	s.forget(t)
+	return true
+}
+
+// Stats returns the number of queued and running tasks
+func (s *Scheduler) Stats() (queued, running int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks), s.inflight
+}
+
+// Close stops accepting tasks, discards the queued ones and waits for the
+// running ones to finish
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.running.Wait()
+		return
+	}
+	s.closed = true
+	var dropped []*Task
+	for s.queue.len() > 0 {
+		t := s.queue.pop()
+		s.forget(t)
+		dropped = append(dropped, t)
+	}
+	s.ready.Broadcast()
+	s.mu.Unlock()
+
+	for _, t := range dropped {
+		if t.Drop != nil {
+			t.Drop()
+		}
+	}
+	if len(dropped) > 0 {
+		s.logger.Warn("scheduler closed with queued jobs", "dropped", len(dropped))
+	}
+	s.running.Wait()
+}
+
+// forget removes a task that has left the queue. Caller must hold mu.
+func (s *Scheduler) forget(t *Task) {
+	delete(s.tasks, t.ID)
+	if s.counts[t.Identity]--; s.counts[t.Identity] <= 0 {
+		delete(s.counts, t.Identity)
+	}
+	schedulerQueued.WithLabelValues(t.Priority.String()).Dec()
+}
+
+// work runs queued tasks until the scheduler closes
+func (s *Scheduler) work() {
+	defer s.running.Done()
+	for {
+		s.mu.Lock()
+		for !s.closed && s.queue.len() == 0 {
+			s.ready.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		t := s.queue.pop()
+		s.forget(t)
+		s.inflight++
+		s.mu.Unlock()
+
+		s.run(t)
+
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}
+}
+
+// run runs one task, recovering from panics so a bad task cannot stop its
+// worker
+func (s *Scheduler) run(t *Task) {
+	schedulerWaitDuration.WithLabelValues(t.Priority.String()).Observe(time.Since(t.queuedAt).Seconds())
+	schedulerRunning.Inc()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("scheduled job panicked", "task_id", t.ID, "panic", r)
+		}
+		schedulerRunning.Dec()
+	}()
+	t.Run()
+}
+
+// queue orders tasks by weighted round robin between priority classes and
+// round robin between identities within a class. Its order is
+// deterministic, so a clone predicts the order tasks will start in.
+type queue struct {
+	classes [len(priorityWeights)]class
+}
+
+// class holds one priority's queued tasks
+type class struct {
+	turns  []string           // Identities with queued tasks, next turn first
+	tasks  map[string][]*Task // Each identity's tasks in submission order
+	credit int                // Tasks the class may still start this round
+}
+
+func newQueue() queue {
+	var q queue
+	for p := range q.classes {
+		q.classes[p] = class{tasks: make(map[string][]*Task), credit: priorityWeights[p]}
+	}
+	return q
+}
+
+// len returns the number of queued tasks
+func (q *queue) len() int {
+	n := 0
+	for p := range q.classes {
+		for _, tasks := range q.classes[p].tasks {
+			n += len(tasks)
+		}
+	}
+	return n
+}
+
+// push adds a task behind its identity's earlier tasks
+func (q *queue) push(t *Task) {
+	c := &q.classes[t.Priority]
+	if len(c.tasks[t.Identity]) == 0 {
+		c.turns = append(c.turns, t.Identity)
+	}
+	c.tasks[t.Identity] = append(c.tasks[t.Identity], t)
+}
+
+// pop removes the next task to start, or returns nil if the queue is empty.
+// The highest class with tasks and credit left goes first; once every class
+// with tasks has spent its credit, all credits are refilled.
+func (q *queue) pop() *Task {
+	for refilled := false; ; refilled = true {
+		for p := len(q.classes) - 1; p >= 0; p-- {
+			c := &q.classes[p]
+			if len(c.turns) > 0 && c.credit > 0 {
+				c.credit--
+				return c.pop()
+			}
+		}
+		if refilled {
+			return nil
+		}
+		for p := range q.classes {
+			q.classes[p].credit = priorityWeights[p]
+		}
+	}
+}
+
+// pop removes the next identity's oldest task and moves the identity to
+// the back of the turns
+func (c *class) pop() *Task {
+	identity := c.turns[0]
+	tasks := c.tasks[identity]
+	c.turns = c.turns[1:]
+	if len(tasks) > 1 {
+		c.tasks[identity] = tasks[1:]
+		c.turns = append(c.turns, identity)
+	} else {
+		delete(c.tasks, identity)
+	}
+	return tasks[0]
+}
+
+// remove takes a task out of the queue
+func (q *queue) remove(t *Task) {
+	c := &q.classes[t.Priority]
+	tasks := slices.DeleteFunc(slices.Clone(c.tasks[t.Identity]), func(queued *Task) bool { return queued == t })
+	if len(tasks) > 0 {
+		c.tasks[t.Identity] = tasks
+		return
+	}
+	delete(c.tasks, t.Identity)
+	c.turns = slices.DeleteFunc(slices.Clone(c.turns), func(identity string) bool { return identity == t.Identity })
+}
+
+// clone returns a copy that can be popped without changing q
+func (q *queue) clone() queue {
+	copied := *q
+	for p := range q.classes {
+		c := &copied.classes[p]
+		c.turns = slices.Clone(c.turns)
+		c.tasks = make(map[string][]*Task, len(q.classes[p].tasks))
+		for identity, tasks := range q.classes[p].tasks {
+			c.tasks[identity] = slices.Clone(tasks)
+		}
+	}
+	return copied
+}
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockWorkers fills every worker with a task that runs until release is
+// closed
+func blockWorkers(t *testing.T, s *Scheduler, workers int) chan struct{} {
+	t.Helper()
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(workers)
+	for i := 0; i < workers; i++ {
+		task := Task{ID: "blocker" + string(rune('a'+i)), Identity: "blocker", Run: func() {
+			started.Done()
+			<-release
+		}}
+		if err := s.Submit(task); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	started.Wait()
+	return release
+}
+
+func TestQueueOrdersByPriorityAndIdentity(t *testing.T) {
+	q := newQueue()
+	push := func(id, identity string, priority Priority) {
+		q.push(&Task{ID: id, Identity: identity, Priority: priority})
+	}
+	// alice floods the normal class before bob queues anything
+	push("a1", "alice", PriorityNormal)
+	push("a2", "alice", PriorityNormal)
+	push("a3", "alice", PriorityNormal)
+	push("b1", "bob", PriorityNormal)
+	push("h1", "carol", PriorityHigh)
+	push("l1", "dave", PriorityLow)
+
+	var order []string
+	for task := q.pop(); task != nil; task = q.pop() {
+		order = append(order, task.ID)
+	}
+	want := []string{"h1", "a1", "b1", "l1", "a2", "a3"}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestLowPriorityIsNotStarved(t *testing.T) {
+	q := newQueue()
+	for i := 0; i < 20; i++ {
+		q.push(&Task{ID: "high", Identity: "rich", Priority: PriorityHigh})
+	}
+	q.push(&Task{ID: "low", Identity: "poor", Priority: PriorityLow})
+
+	for position := 1; ; position++ {
+		if q.pop().ID == "low" {
+			if position > priorityWeights[PriorityHigh]+1 {
+				t.Errorf("low priority task started at position %d", position)
+			}
+			return
+		}
+	}
+}
+
+func TestSchedulerRunsAndReportsPositions(t *testing.T) {
+	s := New(1, 10, 10, slog.Default())
+	defer s.Close()
+	release := blockWorkers(t, s, 1)
+
+	var mu sync.Mutex
+	var ran []string
+	done := make(chan struct{})
+	for _, task := range []Task{
+		{ID: "n1", Identity: "alice", Priority: PriorityNormal},
+		{ID: "n2", Identity: "alice", Priority: PriorityNormal},
+		{ID: "h1", Identity: "bob", Priority: PriorityHigh},
+	} {
+		id := task.ID
+		task.Run = func() {
+			mu.Lock()
+			ran = append(ran, id)
+			if len(ran) == 3 {
+				close(done)
+			}
+			mu.Unlock()
+		}
+		if err := s.Submit(task); err != nil {
+			t.Fatalf("Submit(%s) error = %v", id, err)
+		}
+	}
+
+	for id, want := range map[string]int{"h1": 1, "n1": 2, "n2": 3} {
+		if got, ok := s.Position(id); !ok || got != want {
+			t.Errorf("Position(%s) = %d, %v; want %d", id, got, ok, want)
+		}
+	}
+	if queued, running := s.Stats(); queued != 3 || running != 1 {
+		t.Errorf("Stats() = %d, %d; want 3, 1", queued, running)
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued tasks did not run")
+	}
+	if want := []string{"h1", "n1", "n2"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if _, ok := s.Position("n1"); ok {
+		t.Error("Position() found a task that already ran")
+	}
+}
+
+func TestSchedulerLimits(t *testing.T) {
+	s := New(1, 3, 2, slog.Default())
+	release := blockWorkers(t, s, 1)
+	noop := func() {}
+
+	if err := s.Submit(Task{ID: "a1", Identity: "alice", Run: noop}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if err := s.Submit(Task{ID: "a1", Identity: "alice", Run: noop}); err == nil {
+		t.Error("Submit() accepted a duplicate task ID")
+	}
+	if err := s.Submit(Task{ID: "a2", Identity: "alice", Run: noop}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if err := s.Submit(Task{ID: "a3", Identity: "alice", Run: noop}); !errors.Is(err, ErrIdentityQueueFull) {
+		t.Errorf("Submit() over the identity limit error = %v, want ErrIdentityQueueFull", err)
+	}
+	if err := s.Submit(Task{ID: "b1", Identity: "bob", Run: noop}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if err := s.Submit(Task{ID: "c1", Identity: "carol", Run: noop}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit() over the queue limit error = %v, want ErrQueueFull", err)
+	}
+
+	// Cancelling frees the identity's slot
+	if !s.Cancel("a2") {
+		t.Fatal("Cancel() did not find a queued task")
+	}
+	if s.Cancel("a2") {
+		t.Error("Cancel() removed a task twice")
+	}
+	if err := s.Submit(Task{ID: "a3", Identity: "alice", Run: noop}); err != nil {
+		t.Errorf("Submit() after Cancel() error = %v", err)
+	}
+
+	// Closing drops what is still queued
+	var dropped int
+	if err := s.Submit(Task{ID: "x", Identity: "x", Run: noop, Drop: func() { dropped++ }}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() error = %v, want ErrQueueFull", err)
+	}
+	s.Cancel("b1")
+	if err := s.Submit(Task{ID: "d1", Identity: "dave", Run: func() { t.Error("dropped task ran") }, Drop: func() { dropped++ }}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	for queued, _ := s.Stats(); queued > 0; queued, _ = s.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-closed
+	if dropped != 1 {
+		t.Errorf("Drop called %d times, want 1", dropped)
+	}
+	if err := s.Submit(Task{ID: "late", Run: noop}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit() after Close() error = %v, want ErrClosed", err)
+	}
+}
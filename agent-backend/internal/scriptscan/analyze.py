@@ -0,0 +1,92 @@
+# Reports what a computation script imports and calls, and how large it is,
+# without running it. The script is read from stdin and the facts are
+# written to stdout as JSON for the agent to check against its policy.
+import ast
+import json
+import sys
+
+
+def dotted(node):
+    """Returns the dotted name of a Name or Attribute chain, or None."""
+    parts = []
+    while isinstance(node, ast.Attribute):
+        parts.append(node.attr)
+        node = node.value
+    if not isinstance(node, ast.Name):
+        return None
+    parts.append(node.id)
+    return ".".join(reversed(parts))
+
+
+def literal(node):
+    """Returns the value of a string constant, or None."""
+    if isinstance(node, ast.Constant) and isinstance(node.value, str):
+        return node.value
+    return None
+
+
+def dunder(name):
+    return len(name) > 4 and name.startswith("__") and name.endswith("__")
+
+
+def analyze(source):
+    try:
+        tree = ast.parse(source)
+    except SyntaxError as e:
+        return {"error": {"kind": "syntax", "message": e.msg, "line": e.lineno or 0}}
+    except (ValueError, RecursionError, MemoryError) as e:
+        return {"error": {"kind": "complexity", "message": str(e) or type(e).__name__, "line": 0}}
+
+    # Names bound by imports, so that calls through aliases resolve to the
+    # module they came from
+    aliases = {}
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Import):
+            for a in node.names:
+                if a.asname:
+                    aliases[a.asname] = a.name
+        elif isinstance(node, ast.ImportFrom):
+            module = "." * node.level + (node.module or "")
+            for a in node.names:
+                aliases[a.asname or a.name] = module + "." + a.name
+
+    # names lists the dunder names used, which introspection escapes go
+    # through; others are not reported
+    report = {"imports": [], "calls": [], "names": [], "nodes": 0, "depth": 0}
+    stack = [(tree, 1)]
+    while stack:
+        node, depth = stack.pop()
+        report["nodes"] += 1
+        report["depth"] = max(report["depth"], depth)
+        line = getattr(node, "lineno", 0)
+
+        if isinstance(node, ast.Import):
+            for a in node.names:
+                report["imports"].append({"module": a.name, "line": line})
+        elif isinstance(node, ast.ImportFrom):
+            module = "." * node.level + (node.module or "")
+            report["imports"].append({"module": module, "line": line})
+        elif isinstance(node, ast.Call):
+            name = dotted(node.func)
+            if name is not None:
+                head, _, rest = name.partition(".")
+                if head in aliases:
+                    name = aliases[head] + ("." + rest if rest else "")
+                call = {"name": name, "line": line}
+                if node.args and literal(node.args[0]) is not None:
+                    call["arg"] = literal(node.args[0])
+                # The attribute getattr reads
+                if len(node.args) > 1 and literal(node.args[1]) is not None:
+                    call["attr"] = literal(node.args[1])
+                report["calls"].append(call)
+        elif isinstance(node, ast.Attribute) and dunder(node.attr):
+            report["names"].append({"name": node.attr, "line": line})
+        elif isinstance(node, ast.Name) and dunder(node.id):
+            report["names"].append({"name": node.id, "line": line})
+
+        for child in ast.iter_child_nodes(node):
+            stack.append((child, depth + 1))
+    return report
+
+
+json.dump(analyze(sys.stdin.read()), sys.stdout)
@@ -0,0 +1,329 @@
+// Package scriptscan checks computation scripts before they run. Scripts
+// are parsed with Python's own ast module, so nothing in them executes,
+// and what they import and call is checked against a policy: modules that
+// reach outside the sandbox are refused, operators can deny or allowlist
+// modules per product, and scripts must fit a size and complexity budget.
+//
+// Static checks are a first line of defence. Python is too dynamic for
+// them to be complete, so the sandbox remains the isolation boundary.
+package scriptscan
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+//go:embed analyze.py
+var analyzer string
+
+// ErrRejected is returned for scripts that break their policy
+var ErrRejected = errors.New("computation script rejected")
+
+// Rules a finding can break
+const (
+	RuleSyntax        = "syntax"         // The script does not parse
+	RuleSize          = "size"           // The script is larger than the budget
+	RuleComplexity    = "complexity"     // The script has more AST nodes or deeper nesting than the budget
+	RuleImport        = "import"         // A module is denied or not allowlisted
+	RuleDynamicImport = "dynamic-import" // A module is imported by calling __import__ or by a name computed at run time
+	RuleDynamicCode   = "dynamic-code"   // Code is built from strings with exec, eval or compile
+	RuleDynamicAttr   = "dynamic-attr"   // An attribute is read with getattr by a name computed at run time
+	RuleCall          = "call"           // A denied function is called
+	RuleIntrospection = "introspection"  // Dunder attributes that reach interpreter internals are used
+)
+
+// DefaultDenyModules are refused whatever the policy: they start
+// processes, open sockets, load native code or import by name
+var DefaultDenyModules = []string{
+	"subprocess", "socket", "ctypes", "cffi", "pty", "multiprocessing",
+	"importlib", "builtins", "_thread", "signal", "resource",
+}
+
+// DefaultDenyCalls are refused whatever the policy. A trailing * matches
+// any name with that prefix. Builtins are matched with or without the
+// builtins. prefix.
+var DefaultDenyCalls = []string{
+	"exec", "eval", "compile", "__import__",
+	"os.system", "os.popen", "os.exec*", "os.spawn*", "os.fork*",
+	"os.posix_spawn*", "os.kill*", "os.setuid", "os.setgid", "os.chroot",
+}
+
+// dynamicCode are the builtins that run code built from strings
+var dynamicCode = []string{"exec", "eval", "compile"}
+
+// introspection are the dunder names sandbox escapes walk through
+var introspection = []string{
+	"__builtins__", "__import__", "__subclasses__", "__globals__",
+	"__code__", "__closure__", "__bases__", "__base__", "__mro__",
+	"__loader__", "__spec__", "__getattribute__",
+}
+
+// Budgets scripts get unless configured otherwise
+const (
+	DefaultMaxBytes = 256 << 10
+	DefaultMaxNodes = 50000
+	DefaultMaxDepth = 64
+)
+
+// Policy is what a script may import and how large it may be
+type Policy struct {
+	Deny     []string // Modules refused in addition to DefaultDenyModules, with their submodules
+	Allow    []string // If set, the only modules that may be imported, with their submodules
+	MaxBytes int      // Zero uses the base policy's budget
+	MaxNodes int
+	MaxDepth int
+}
+
+// Finding is a rule a script breaks
+type Finding struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+	Line   int    `json:"line,omitempty"`
+}
+
+// Report is the outcome of scanning a script
+type Report struct {
+	Bytes    int       `json:"bytes"`
+	Nodes    int       `json:"nodes"`
+	Depth    int       `json:"depth"`
+	Imports  []string  `json:"imports"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Err returns an error wrapping ErrRejected that lists the findings, or
+// nil if there are none
+func (r *Report) Err() error {
+	if len(r.Findings) == 0 {
+		return nil
+	}
+	details := make([]string, len(r.Findings))
+	for i, f := range r.Findings {
+		details[i] = fmt.Sprintf("%s: %s", f.Rule, f.Detail)
+		if f.Line > 0 {
+			details[i] += fmt.Sprintf(" (line %d)", f.Line)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRejected, strings.Join(details, "; "))
+}
+
+// Scanner checks scripts against a base policy and per-product policies
+type Scanner struct {
+	python   string
+	base     Policy
+	products map[string]Policy
+	timeout  time.Duration
+}
+
+// NewScanner creates a scanner that parses scripts with the python
+// interpreter, or python3 if it is empty. products maps product IDs to the
+// policies computations on them must also meet.
+func NewScanner(python string, base Policy, products map[string]Policy) (*Scanner, error) {
+	if python == "" {
+		python = "python3"
+	}
+	if _, err := exec.LookPath(python); err != nil {
+		return nil, fmt.Errorf("script scanning needs a Python interpreter: %w", err)
+	}
+	if base.MaxBytes <= 0 {
+		base.MaxBytes = DefaultMaxBytes
+	}
+	if base.MaxNodes <= 0 {
+		base.MaxNodes = DefaultMaxNodes
+	}
+	if base.MaxDepth <= 0 {
+		base.MaxDepth = DefaultMaxDepth
+	}
+	return &Scanner{python: python, base: base, products: products, timeout: 10 * time.Second}, nil
+}
+
+// facts is what analyze.py reports about a script
+type facts struct {
+	Error *struct {
+		Kind    string `json:"kind"`
+		Message string `json:"message"`
+		Line    int    `json:"line"`
+	} `json:"error"`
+	Imports []struct {
+		Module string `json:"module"`
+		Line   int    `json:"line"`
+	} `json:"imports"`
+	Calls []struct {
+		Name string  `json:"name"`
+		Arg  *string `json:"arg"`
+		Attr *string `json:"attr"`
+		Line int     `json:"line"`
+	} `json:"calls"`
+	Names []struct {
+		Name string `json:"name"`
+		Line int    `json:"line"`
+	} `json:"names"`
+	Nodes int `json:"nodes"`
+	Depth int `json:"depth"`
+}
+
+// Scan checks source against the base policy and those of products. An
+// error is returned only if the script could not be analysed; a script
+// that breaks a rule yields a report with findings.
+func (s *Scanner) Scan(ctx context.Context, source string, products []string) (*Report, error) {
+	policies := []Policy{s.base}
+	for _, product := range products {
+		if p, ok := s.products[product]; ok {
+			policies = append(policies, p)
+		}
+	}
+	report := &Report{Bytes: len(source), Imports: []string{}}
+	add := func(f Finding) {
+		if !slices.Contains(report.Findings, f) {
+			report.Findings = append(report.Findings, f)
+		}
+	}
+
+	maxBytes, maxNodes, maxDepth := s.budget(policies)
+	if report.Bytes > maxBytes {
+		add(Finding{Rule: RuleSize, Detail: fmt.Sprintf("%d bytes exceeds the budget of %d", report.Bytes, maxBytes)})
+		return report, nil
+	}
+
+	f, err := s.analyze(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if f.Error != nil {
+		rule := RuleSyntax
+		if f.Error.Kind == RuleComplexity {
+			rule = RuleComplexity
+		}
+		add(Finding{Rule: rule, Detail: f.Error.Message, Line: f.Error.Line})
+		return report, nil
+	}
+	report.Nodes, report.Depth = f.Nodes, f.Depth
+	if f.Nodes > maxNodes {
+		add(Finding{Rule: RuleComplexity, Detail: fmt.Sprintf("%d syntax nodes exceeds the budget of %d", f.Nodes, maxNodes)})
+	}
+	if f.Depth > maxDepth {
+		add(Finding{Rule: RuleComplexity, Detail: fmt.Sprintf("nesting depth %d exceeds the budget of %d", f.Depth, maxDepth)})
+	}
+
+	checkImport := func(module string, line int) {
+		if !slices.Contains(report.Imports, module) {
+			report.Imports = append(report.Imports, module)
+		}
+		for _, p := range policies {
+			if reason := p.refuses(module); reason != "" {
+				add(Finding{Rule: RuleImport, Detail: fmt.Sprintf("%s is %s", module, reason), Line: line})
+			}
+		}
+	}
+	for _, imp := range f.Imports {
+		checkImport(imp.Module, imp.Line)
+	}
+	for _, call := range f.Calls {
+		name := strings.TrimPrefix(call.Name, "builtins.")
+		if name == "__import__" || name == "importlib.import_module" {
+			if call.Arg != nil {
+				checkImport(*call.Arg, call.Line)
+			} else {
+				add(Finding{Rule: RuleDynamicImport, Detail: name + " with a computed module name", Line: call.Line})
+			}
+		}
+		switch {
+		case name == "getattr" && call.Attr == nil:
+			add(Finding{Rule: RuleDynamicAttr, Detail: "getattr with a computed attribute name", Line: call.Line})
+		case name == "getattr" && slices.Contains(introspection, *call.Attr):
+			add(Finding{Rule: RuleIntrospection, Detail: *call.Attr, Line: call.Line})
+		case name == "__import__":
+			add(Finding{Rule: RuleDynamicImport, Detail: name, Line: call.Line})
+		case slices.Contains(dynamicCode, name):
+			add(Finding{Rule: RuleDynamicCode, Detail: name, Line: call.Line})
+		case matchesAny(DefaultDenyCalls, name):
+			add(Finding{Rule: RuleCall, Detail: name, Line: call.Line})
+		}
+	}
+	for _, name := range f.Names {
+		if slices.Contains(introspection, name.Name) {
+			add(Finding{Rule: RuleIntrospection, Detail: name.Name, Line: name.Line})
+		}
+	}
+	slices.Sort(report.Imports)
+	return report, nil
+}
+
+// analyze runs analyze.py over source
+func (s *Scanner) analyze(ctx context.Context, source string) (*facts, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// -I keeps the interpreter from importing anything from the working
+	// directory or the environment
+	cmd := exec.CommandContext(ctx, s.python, "-I", "-c", analyzer)
+	cmd.Stdin = strings.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to analyse script: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var f facts
+	if err := json.Unmarshal(stdout.Bytes(), &f); err != nil {
+		return nil, fmt.Errorf("failed to read script analysis: %w", err)
+	}
+	return &f, nil
+}
+
+// budget returns the tightest budgets of policies
+func (s *Scanner) budget(policies []Policy) (maxBytes, maxNodes, maxDepth int) {
+	maxBytes, maxNodes, maxDepth = s.base.MaxBytes, s.base.MaxNodes, s.base.MaxDepth
+	for _, p := range policies {
+		if p.MaxBytes > 0 {
+			maxBytes = min(maxBytes, p.MaxBytes)
+		}
+		if p.MaxNodes > 0 {
+			maxNodes = min(maxNodes, p.MaxNodes)
+		}
+		if p.MaxDepth > 0 {
+			maxDepth = min(maxDepth, p.MaxDepth)
+		}
+	}
+	return maxBytes, maxNodes, maxDepth
+}
+
+// refuses returns why p refuses module, or "" if it may be imported.
+// Relative imports are refused, as scripts are not part of a package.
+func (p Policy) refuses(module string) string {
+	switch {
+	case strings.HasPrefix(module, "."):
+		return "a relative import"
+	case coveredBy(DefaultDenyModules, module) || coveredBy(p.Deny, module):
+		return "denied"
+	case len(p.Allow) > 0 && !coveredBy(p.Allow, module):
+		return "not allowlisted"
+	}
+	return ""
+}
+
+// coveredBy reports whether module or a package containing it is listed
+func coveredBy(modules []string, module string) bool {
+	for _, m := range modules {
+		if module == m || strings.HasPrefix(module, m+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether name matches one of patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) || name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package scriptscan
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestScanner(t *testing.T, base Policy, products map[string]Policy) *Scanner {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	s, err := NewScanner("", base, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScan(t *testing.T) {
+	s := newTestScanner(t, Policy{Deny: []string{"requests"}}, map[string]Policy{
+		"strict": {Allow: []string{"numpy", "pandas"}},
+	})
+	tests := []struct {
+		name     string
+		script   string
+		products []string
+		rules    []string
+	}{
+		{"plain", "import numpy as np\nimport os.path\nresult = np.mean([1, 2])\n", nil, nil},
+		{"denied module", "import subprocess\nsubprocess.run(['ls'])\n", nil, []string{RuleImport}},
+		{"denied submodule", "from ctypes.util import find_library\n", nil, []string{RuleImport}},
+		{"configured deny", "import requests\n", nil, []string{RuleImport}},
+		{"not allowlisted", "import numpy\nimport json\n", []string{"strict"}, []string{RuleImport}},
+		{"allowlisted", "import numpy.linalg\nimport pandas as pd\n", []string{"strict", "other"}, nil},
+		{"aliased call", "import os as o\no.system('id')\n", nil, []string{RuleCall}},
+		{"imported call", "from os import execv as run\nrun('/bin/sh', [])\n", nil, []string{RuleCall}},
+		{"literal __import__", "s = __import__('socket')\n", nil, []string{RuleImport, RuleDynamicImport, RuleIntrospection}},
+		{"allowed __import__", "np = __import__('numpy')\n", nil, []string{RuleDynamicImport, RuleIntrospection}},
+		{"computed __import__", "name = 'sock' + 'et'\n__import__(name)\n", nil, []string{RuleDynamicImport, RuleIntrospection}},
+		{"exec", "exec('import socket')\n", nil, []string{RuleDynamicCode}},
+		{"builtins eval", "import builtins as b\nb.eval('1')\n", nil, []string{RuleImport, RuleDynamicCode}},
+		{"compile", "code = compile('1', 'x', 'eval')\n", nil, []string{RuleDynamicCode}},
+		{"constant getattr", "import math\npi = getattr(math, 'pi', 3)\n", nil, nil},
+		{"computed getattr", "import os\nf = getattr(os, 'sys' + 'tem')\n", nil, []string{RuleDynamicAttr}},
+		{"dunder getattr", "g = getattr(len, '__globals__')\n", nil, []string{RuleIntrospection}},
+		{"escape", "().__class__.__bases__[0].__subclasses__()\n", nil, []string{RuleIntrospection}},
+		{"relative", "from . import secrets\n", nil, []string{RuleImport}},
+		{"syntax", "def broken(:\n", nil, []string{RuleSyntax}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := s.Scan(context.Background(), tt.script, tt.products)
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			var rules []string
+			for _, f := range report.Findings {
+				if !slices.Contains(rules, f.Rule) {
+					rules = append(rules, f.Rule)
+				}
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.rules, ",") {
+				t.Errorf("Scan() findings = %+v, want rules %v", report.Findings, tt.rules)
+			}
+			if (report.Err() == nil) != (len(tt.rules) == 0) || report.Err() != nil && !errors.Is(report.Err(), ErrRejected) {
+				t.Errorf("Err() = %v", report.Err())
+			}
+		})
+	}
+}
+
+func TestScanBudgets(t *testing.T) {
+	s := newTestScanner(t, Policy{MaxBytes: 1000}, map[string]Policy{"tiny": {MaxNodes: 10, MaxDepth: 4}})
+
+	report, err := s.Scan(context.Background(), strings.Repeat("x = 1\n", 200), nil)
+	if err != nil || len(report.Findings) != 1 || report.Findings[0].Rule != RuleSize {
+		t.Errorf("oversized script: %+v, %v", report, err)
+	}
+
+	script := "total = sum([(a + b) * c for a, b, c in rows])\n"
+	report, err = s.Scan(context.Background(), script, nil)
+	if err != nil || report.Err() != nil || report.Nodes == 0 || report.Depth == 0 {
+		t.Fatalf("script within the base budget: %+v, %v", report, err)
+	}
+	report, err = s.Scan(context.Background(), script, []string{"tiny"})
+	if err != nil || len(report.Findings) != 2 || report.Findings[0].Rule != RuleComplexity {
+		t.Errorf("script over a product's budget: %+v, %v", report, err)
+	}
+}
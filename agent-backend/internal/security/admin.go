@@ -0,0 +1,168 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// BucketState is a point-in-time view of a token bucket
+type BucketState struct {
+	Key      string  `json:"key"`
+	Tokens   float64 `json:"tokens"`
+	Capacity float64 `json:"capacity"`
+	Rate     float64 `json:"rate"`
+}
+
+// BlockEntry is an IP on the ban list or greylist
+type BlockEntry struct {
+	IP    string    `json:"ip"`
+	Until time.Time `json:"until"`
+}
+
+// SecuritySnapshot is the runtime state of the security service. With a shared
+// rate limit store, buckets and block lists only cover this replica's
+// local entries.
+type SecuritySnapshot struct {
+	IPBuckets       []BucketState  `json:"ip_buckets"`
+	IdentityBuckets []BucketState  `json:"identity_buckets"`
+	Bans            []BlockEntry   `json:"bans"`
+	Greylist        []BlockEntry   `json:"greylist"`
+	ConcurrentJobs  map[string]int `json:"concurrent_jobs"`
+	Costs           []CostUsage    `json:"costs"`
+	SharedStore     bool           `json:"shared_store"`
+}
+
+// Tokens returns the number of tokens currently available without taking one
+func (tb *TokenBucket) Tokens() float64 {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	elapsed := time.Since(tb.lastRefill).Seconds()
+	return min(tb.capacity, tb.tokens+elapsed*tb.rate)
+}
+
+// IsAdmin reports whether peerID is allowed to use the admin API
+func (s *SecurityService) IsAdmin(peerID string) bool {
+	if peerID == "" {
+		return false
+	}
+	return slices.Contains(s.getConfig().Admin.PeerIDs, peerID)
+}
+
+// Snapshot returns the current buckets, block lists and quota counters
+func (s *SecurityService) Snapshot() SecuritySnapshot {
+	costs := s.CostUsages()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	snapshot := SecuritySnapshot{
+		IPBuckets:       bucketStates(s.ipBuckets),
+		IdentityBuckets: bucketStates(s.identityBuckets),
+		Bans:            blockEntries(s.bannedIPs, now),
+		Greylist:        blockEntries(s.greylistedIPs, now),
+		ConcurrentJobs:  make(map[string]int, len(s.concurrentJobs)),
+		Costs:           costs,
+		SharedStore:     s.limitStore != nil,
+	}
+	for identity, jobs := range s.concurrentJobs {
+		if jobs > 0 {
+			snapshot.ConcurrentJobs[identity] = jobs
+		}
+	}
+
+	return snapshot
+}
+
+// BanIP bans ip for the given duration, overriding any existing ban
+func (s *SecurityService) BanIP(ctx context.Context, ip string, duration time.Duration) (time.Time, error) {
+	if ip == "" {
+		return time.Time{}, fmt.Errorf("%w: ip is required", ErrInvalidBan)
+	}
+	if duration <= 0 {
+		duration = time.Duration(s.getConfig().Bans.TempBanSeconds) * time.Second
+	}
+	if duration <= 0 {
+		return time.Time{}, fmt.Errorf("%w: ban duration must be positive", ErrInvalidBan)
+	}
+
+	until := time.Now().Add(duration)
+	s.block(ctx, BlockListBan, ip, until)
+	s.logger.Warn("IP banned by operator", "ip", ip, "until", until)
+	return until, nil
+}
+
+// Unblock removes ip from a block list ("ban" or "greylist") and reports
+// whether it was listed locally
+func (s *SecurityService) Unblock(ctx context.Context, list, ip string) (bool, error) {
+	if list != BlockListBan && list != BlockListGreylist {
+		return false, fmt.Errorf("%w: %q", ErrUnknownBlockList, list)
+	}
+
+	if s.limitStore != nil {
+		ctx, cancel := context.WithTimeout(ctx, rateLimitStoreTimeout)
+		defer cancel()
+		if err := s.limitStore.Unblock(ctx, list, ip); err != nil {
+			return false, fmt.Errorf("failed to update shared %s list: %w", list, err)
+		}
+	}
+
+	s.mu.Lock()
+	entries := s.localBlockList(list)
+	_, listed := entries[ip]
+	delete(entries, ip)
+	s.updateBlockGauges()
+	s.mu.Unlock()
+
+	s.logger.Info("IP removed from block list by operator", "ip", ip, "list", list)
+	return listed, nil
+}
+
+// ResetQuota clears the concurrent job count and cost window for identity,
+// or for every identity if identity is empty
+func (s *SecurityService) ResetQuota(identity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if identity == "" {
+		s.concurrentJobs = make(map[string]int)
+		s.costWindows = make(map[string]*costWindow)
+		concurrentJobsGauge.Reset()
+	} else {
+		delete(s.concurrentJobs, identity)
+		delete(s.costWindows, identity)
+		s.updateConcurrentJobsGauge(identity)
+	}
+	s.logger.Info("concurrency quota reset by operator", "identity", identity)
+}
+
+// bucketStates lists buckets sorted by key
+func bucketStates(buckets map[string]*TokenBucket) []BucketState {
+	states := make([]BucketState, 0, len(buckets))
+	for key, bucket := range buckets {
+		states = append(states, BucketState{
+			Key:      key,
+			Tokens:   bucket.Tokens(),
+			Capacity: bucket.capacity,
+			Rate:     bucket.rate,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	return states
+}
+
+// blockEntries lists unexpired block list entries sorted by IP
+func blockEntries(entries map[string]time.Time, now time.Time) []BlockEntry {
+	list := make([]BlockEntry, 0, len(entries))
+	for ip, until := range entries {
+		if now.Before(until) {
+			list = append(list, BlockEntry{IP: ip, Until: until})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	return list
+}
@@ -0,0 +1,92 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const adminTestConfig = `
+rate_limits:
+  per_ip_rps: 5
+  per_identity_rps: 2
+  burst: 10
+bans:
+  temp_ban_seconds: 60
+admin:
+  peer_ids:
+    - 12D3KooWAdmin
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`
+
+func TestIsAdmin(t *testing.T) {
+	service, _ := newRateLimitTestService(t, adminTestConfig)
+
+	if !service.IsAdmin("12D3KooWAdmin") {
+		t.Error("IsAdmin() = false for configured peer")
+	}
+	for _, peerID := range []string{"", "12D3KooWOther"} {
+		if service.IsAdmin(peerID) {
+			t.Errorf("IsAdmin(%q) = true, want false", peerID)
+		}
+	}
+}
+
+func TestAdminOverrides(t *testing.T) {
+	service, _ := newRateLimitTestService(t, adminTestConfig)
+	ctx := context.Background()
+
+	until, err := service.BanIP(ctx, "10.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("BanIP() error = %v", err)
+	}
+	if d := time.Until(until); d <= 0 || d > time.Minute {
+		t.Errorf("BanIP() default duration = %v, want temp_ban_seconds", d)
+	}
+
+	service.mu.Lock()
+	service.greylistedIPs["10.0.0.2"] = time.Now().Add(time.Minute)
+	service.concurrentJobs["0xabc"] = 2
+	service.concurrentJobs["0xdef"] = 1
+	service.mu.Unlock()
+	service.takeToken(ctx, service.ipBuckets, "ip|10.0.0.3", 10, 5)
+
+	snapshot := service.Snapshot()
+	if len(snapshot.Bans) != 1 || snapshot.Bans[0].IP != "10.0.0.1" {
+		t.Errorf("Snapshot() bans = %+v", snapshot.Bans)
+	}
+	if len(snapshot.Greylist) != 1 || snapshot.Greylist[0].IP != "10.0.0.2" {
+		t.Errorf("Snapshot() greylist = %+v", snapshot.Greylist)
+	}
+	if len(snapshot.IPBuckets) != 1 || snapshot.IPBuckets[0].Tokens >= 10 {
+		t.Errorf("Snapshot() ip buckets = %+v", snapshot.IPBuckets)
+	}
+	if snapshot.ConcurrentJobs["0xabc"] != 2 || snapshot.SharedStore {
+		t.Errorf("Snapshot() = %+v", snapshot)
+	}
+
+	if listed, err := service.Unblock(ctx, BlockListBan, "10.0.0.1"); err != nil || !listed {
+		t.Errorf("Unblock(ban) = %v, %v, want true, nil", listed, err)
+	}
+	if listed, err := service.Unblock(ctx, BlockListGreylist, "10.0.0.9"); err != nil || listed {
+		t.Errorf("Unblock(greylist, unlisted) = %v, %v, want false, nil", listed, err)
+	}
+	if _, err := service.Unblock(ctx, "allowlist", "10.0.0.1"); err == nil {
+		t.Error("Unblock() with unknown list should fail")
+	}
+
+	service.ResetQuota("0xabc")
+	if jobs := service.Snapshot().ConcurrentJobs; jobs["0xabc"] != 0 || jobs["0xdef"] != 1 {
+		t.Errorf("ResetQuota(identity) left %v", jobs)
+	}
+	service.ResetQuota("")
+	if jobs := service.Snapshot().ConcurrentJobs; len(jobs) != 0 {
+		t.Errorf("ResetQuota(\"\") left %v", jobs)
+	}
+
+	if bans := service.Snapshot().Bans; len(bans) != 0 {
+		t.Errorf("ban still listed after Unblock: %+v", bans)
+	}
+}
@@ -0,0 +1,228 @@
+package security
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newAuthTestService() *SecurityService {
+	config := &SecurityConfig{}
+	config.Auth.ChallengeTimeoutSeconds = 300
+	config.Auth.NonceLength = 32
+
+	return &SecurityService{
+		config:          config,
+		logger:          slog.Default(),
+		challenges:      make(map[string]*Challenge),
+		challengeCounts: make(map[string]int),
+	}
+}
+
+// personalSign signs message the way wallets implement personal_sign (V in {27,28})
+func personalSign(t *testing.T, message string, keyHex string) string {
+	t.Helper()
+
+	key, err := crypto.HexToECDSA(keyHex)
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	const signerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	const otherKey = "8da4ef21b864d2cc526dbdb2a120bd2874c36c9d0a1fb7f8c63d7f7a8b41de8f"
+
+	key, _ := crypto.HexToECDSA(signerKey)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	tests := []struct {
+		name      string
+		signature func(nonce string) string
+		want      bool
+	}{
+		{
+			name:      "valid personal_sign signature",
+			signature: func(nonce string) string { return personalSign(t, nonce, signerKey) },
+			want:      true,
+		},
+		{
+			name:      "signature from a different key",
+			signature: func(nonce string) string { return personalSign(t, nonce, otherKey) },
+			want:      false,
+		},
+		{
+			name:      "signature over a different message",
+			signature: func(nonce string) string { return personalSign(t, nonce+"x", signerKey) },
+			want:      false,
+		},
+		{
+			name:      "malformed signature",
+			signature: func(nonce string) string { return "0xdeadbeef" },
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newAuthTestService()
+
+			challenge, err := service.CreateChallenge(address)
+			if err != nil {
+				t.Fatalf("CreateChallenge() error = %v", err)
+			}
+
+			got, valid := service.VerifyChallenge(challenge.Nonce, tt.signature(challenge.Nonce))
+			if valid != tt.want {
+				t.Errorf("VerifyChallenge() valid = %v, want %v", valid, tt.want)
+			}
+			if tt.want && got != address {
+				t.Errorf("VerifyChallenge() address = %s, want %s", got, address)
+			}
+		})
+	}
+}
+
+func TestVerifyChallengeSingleUse(t *testing.T) {
+	const signerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+	key, _ := crypto.HexToECDSA(signerKey)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	service := newAuthTestService()
+	challenge, err := service.CreateChallenge(address)
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	signature := personalSign(t, challenge.Nonce, signerKey)
+
+	if _, valid := service.VerifyChallenge(challenge.Nonce, signature); !valid {
+		t.Fatal("expected first verification to succeed")
+	}
+	if _, valid := service.VerifyChallenge(challenge.Nonce, signature); valid {
+		t.Error("expected replayed challenge to be rejected")
+	}
+}
+
+func TestVerifyChallengeExpired(t *testing.T) {
+	const signerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+	key, _ := crypto.HexToECDSA(signerKey)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	service := newAuthTestService()
+	challenge, err := service.CreateChallenge(address)
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	challenge.ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, valid := service.VerifyChallenge(challenge.Nonce, personalSign(t, challenge.Nonce, signerKey)); valid {
+		t.Error("expected expired challenge to be rejected")
+	}
+}
+
+func TestCreateChallengePerAddressLimit(t *testing.T) {
+	service := newAuthTestService()
+	service.config.Auth.MaxChallengesPerAddress = 2
+
+	first, err := service.CreateChallenge("0xAbC")
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	if _, err := service.CreateChallenge("0xabc"); err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	// Addresses are compared case-insensitively
+	if _, err := service.CreateChallenge("0xABC"); !errors.Is(err, ErrTooManyChallenges) {
+		t.Fatalf("CreateChallenge() error = %v, want ErrTooManyChallenges", err)
+	}
+	if _, err := service.CreateChallenge("0xdef"); err != nil {
+		t.Errorf("other address should not be limited: %v", err)
+	}
+
+	// Expired challenges free up the address's allowance
+	first.ExpiresAt = time.Now().Add(-time.Second)
+	service.cleanup()
+	if _, err := service.CreateChallenge("0xabc"); err != nil {
+		t.Errorf("CreateChallenge() after expiry error = %v", err)
+	}
+}
+
+func TestCreateChallengeEvictsOldest(t *testing.T) {
+	service := newAuthTestService()
+	service.config.Auth.MaxChallenges = 3
+
+	var nonces []string
+	for _, address := range []string{"0x1", "0x2", "0x3", "0x4"} {
+		challenge, err := service.CreateChallenge(address)
+		if err != nil {
+			t.Fatalf("CreateChallenge(%s) error = %v", address, err)
+		}
+		nonces = append(nonces, challenge.Nonce)
+	}
+
+	if len(service.challenges) != 3 {
+		t.Fatalf("outstanding challenges = %d, want 3", len(service.challenges))
+	}
+	if _, exists := service.challenges[nonces[0]]; exists {
+		t.Error("oldest challenge was not evicted")
+	}
+	if _, exists := service.challengeCounts["0x1"]; exists {
+		t.Error("evicted challenge still counted against its address")
+	}
+	for _, nonce := range nonces[1:] {
+		if _, exists := service.challenges[nonce]; !exists {
+			t.Errorf("challenge %s evicted, want kept", nonce)
+		}
+	}
+}
+
+func TestUseRequestNonce(t *testing.T) {
+	s := newAuthTestService()
+	s.requestNonces = make(map[string]time.Time)
+	s.config.Auth.SignatureMaxSkewSeconds = 60
+	now := time.Now()
+
+	ctx := context.Background()
+	if err := s.UseRequestNonce(ctx, "peerA", "n1", now); err != nil {
+		t.Fatalf("first use error = %v", err)
+	}
+	if err := s.UseRequestNonce(ctx, "peerA", "n1", now); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("replayed nonce error = %v, want ErrReplayedNonce", err)
+	}
+	if err := s.UseRequestNonce(ctx, "peerB", "n1", now); err != nil {
+		t.Errorf("same nonce from another peer error = %v", err)
+	}
+
+	// Nonces are forgotten on restart, so requests signed before then are refused
+	s.startedAt = now
+	if err := s.UseRequestNonce(ctx, "peerA", "n3", now.Add(-time.Second)); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("nonce signed before start error = %v, want ErrReplayedNonce", err)
+	}
+
+	// Once the timestamp has left the skew window the nonce is forgotten
+	old := now.Add(-2 * time.Minute)
+	s.requestNonces["peerA/n2"] = old.Add(s.SignatureMaxSkew())
+	s.cleanup()
+	if _, exists := s.requestNonces["peerA/n2"]; exists {
+		t.Error("cleanup kept an expired nonce")
+	}
+	if _, exists := s.requestNonces["peerA/n1"]; !exists {
+		t.Error("cleanup dropped a live nonce")
+	}
+}
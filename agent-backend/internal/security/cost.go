@@ -0,0 +1,162 @@
+package security
+
+import (
+	"sort"
+	"time"
+)
+
+// Cost weights used when the costs config leaves them all unset
+const (
+	defaultCostBaseUnits          = 1
+	defaultCostComputeSecondUnits = 10
+	defaultCostMegabyteUnits      = 5
+	defaultCostWindow             = time.Hour
+)
+
+// costWindow is what an identity has spent in its current cost window
+type costWindow struct {
+	spent    float64
+	requests int
+	resetsAt time.Time
+}
+
+// CostUsage is an identity's spend in its current cost window. Budget is 0
+// when no budget is configured.
+type CostUsage struct {
+	Identity string    `json:"identity"`
+	Spent    float64   `json:"spent"`
+	Requests int       `json:"requests"`
+	Budget   float64   `json:"budget"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// Remaining returns the units left in the budget, or -1 without a budget
+func (u CostUsage) Remaining() float64 {
+	if u.Budget <= 0 {
+		return -1
+	}
+	return max(u.Budget-u.Spent, 0)
+}
+
+// RequestCost scores a request from the time spent handling it and the
+// bytes it served, so large result downloads cost more than status polls
+func (s *SecurityService) RequestCost(compute time.Duration, bytes int64) float64 {
+	costs := s.getConfig().Costs
+	base, perSecond, perMB := costs.BaseUnits, costs.ComputeSecondUnits, costs.MegabyteUnits
+	if base == 0 && perSecond == 0 && perMB == 0 {
+		base, perSecond, perMB = defaultCostBaseUnits, defaultCostComputeSecondUnits, defaultCostMegabyteUnits
+	}
+	return base + perSecond*compute.Seconds() + perMB*float64(bytes)/(1024*1024)
+}
+
+// CheckCostBudget reports whether identity may make another request. When it
+// may not, it returns how long until its cost window resets.
+func (s *SecurityService) CheckCostBudget(identity string) (bool, time.Duration) {
+	budget := s.getConfig().Quotas.CostBudget
+	if budget <= 0 {
+		return true, 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	window, exists := s.costWindows[identity]
+	now := time.Now()
+	if !exists || !now.Before(window.resetsAt) || window.spent < budget {
+		return true, 0
+	}
+	return false, window.resetsAt.Sub(now)
+}
+
+// RecordCost charges a request's cost to identity's current window and
+// returns its usage
+func (s *SecurityService) RecordCost(identity string, cost float64) CostUsage {
+	return s.addCost(identity, cost, 1)
+}
+
+// AddCost charges more cost to a request already recorded with RecordCost
+func (s *SecurityService) AddCost(identity string, cost float64) {
+	s.addCost(identity, cost, 0)
+}
+
+// addCost adds cost and requests to identity's current window
+func (s *SecurityService) addCost(identity string, cost float64, requests int) CostUsage {
+	config := s.getConfig()
+	length := time.Duration(config.Quotas.CostWindowSeconds) * time.Second
+	if length <= 0 {
+		length = defaultCostWindow
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	window, exists := s.costWindows[identity]
+	if !exists || !now.Before(window.resetsAt) {
+		window = &costWindow{resetsAt: now.Add(length)}
+		s.costWindows[identity] = window
+	}
+	window.spent += cost
+	window.requests += requests
+
+	return window.usage(identity, config.Quotas.CostBudget)
+}
+
+// CostUsages returns the spend of every identity with an open cost window,
+// sorted by identity
+func (s *SecurityService) CostUsages() []CostUsage {
+	budget := s.getConfig().Quotas.CostBudget
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	usages := make([]CostUsage, 0, len(s.costWindows))
+	for identity, window := range s.costWindows {
+		if now.Before(window.resetsAt) {
+			usages = append(usages, window.usage(identity, budget))
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Identity < usages[j].Identity })
+	return usages
+}
+
+// usage returns the window's spend as a CostUsage
+func (w *costWindow) usage(identity string, budget float64) CostUsage {
+	return CostUsage{
+		Identity: identity,
+		Spent:    w.spent,
+		Requests: w.requests,
+		Budget:   budget,
+		ResetsAt: w.resetsAt,
+	}
+}
+
+// IdentityQuotas is where an identity stands against its quotas
+type IdentityQuotas struct {
+	Cost              CostUsage `json:"cost"`
+	ConcurrentJobs    int       `json:"concurrent_jobs"`
+	MaxConcurrentJobs int       `json:"max_concurrent_jobs"`
+	RequestsPerSecond int       `json:"requests_per_second"` // Per-identity rate limit
+	Burst             int       `json:"burst"`
+}
+
+// Quotas returns where identity stands against its quotas
+func (s *SecurityService) Quotas(identity string) IdentityQuotas {
+	config := s.getConfig()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quotas := IdentityQuotas{
+		Cost:              CostUsage{Identity: identity, Budget: config.Quotas.CostBudget},
+		ConcurrentJobs:    s.concurrentJobs[identity],
+		MaxConcurrentJobs: config.Quotas.ConcurrentJobsPerIdentity,
+		RequestsPerSecond: config.RateLimits.PerIdentityRPS,
+		Burst:             config.RateLimits.Burst,
+	}
+	if window, ok := s.costWindows[identity]; ok && time.Now().Before(window.resetsAt) {
+		quotas.Cost = window.usage(identity, config.Quotas.CostBudget)
+	}
+	return quotas
+}
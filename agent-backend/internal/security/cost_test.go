@@ -0,0 +1,86 @@
+package security
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func newCostTestService(budget float64) *SecurityService {
+	config := &SecurityConfig{}
+	config.Quotas.CostBudget = budget
+	config.Quotas.CostWindowSeconds = 60
+	return &SecurityService{
+		config:      config,
+		logger:      slog.Default(),
+		costWindows: make(map[string]*costWindow),
+	}
+}
+
+func TestRequestCost(t *testing.T) {
+	s := newCostTestService(0)
+
+	poll := s.RequestCost(10*time.Millisecond, 200)
+	download := s.RequestCost(500*time.Millisecond, 20*1024*1024)
+	if want := 1 + 10*0.01 + 5*200.0/(1024*1024); math.Abs(poll-want) > 1e-9 {
+		t.Errorf("RequestCost(poll) = %v, want %v with default weights", poll, want)
+	}
+	if download < 50*poll {
+		t.Errorf("download cost %v should dwarf a status poll's %v", download, poll)
+	}
+
+	s.config.Costs.BaseUnits = 2
+	if got := s.RequestCost(time.Second, 1024*1024); got != 2 {
+		t.Errorf("RequestCost() = %v, want 2 when only base_units is set", got)
+	}
+}
+
+func TestCostBudget(t *testing.T) {
+	s := newCostTestService(10)
+
+	if ok, _ := s.CheckCostBudget("peerA"); !ok {
+		t.Fatal("identity without spend should be within budget")
+	}
+	usage := s.RecordCost("peerA", 6)
+	if usage.Spent != 6 || usage.Remaining() != 4 || usage.Requests != 1 {
+		t.Errorf("usage after one request = %+v", usage)
+	}
+	s.AddCost("peerA", 5)
+	if ok, retryAfter := s.CheckCostBudget("peerA"); ok || retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("CheckCostBudget() = %v, %v; want refusal until the window resets", ok, retryAfter)
+	}
+	if ok, _ := s.CheckCostBudget("peerB"); !ok {
+		t.Error("another identity's spend should not count")
+	}
+
+	// The window resets once it ends
+	s.costWindows["peerA"].resetsAt = time.Now().Add(-time.Second)
+	if ok, _ := s.CheckCostBudget("peerA"); !ok {
+		t.Error("budget should reset with the window")
+	}
+	if usage := s.RecordCost("peerA", 1); usage.Spent != 1 {
+		t.Errorf("spend after reset = %v, want 1", usage.Spent)
+	}
+
+	s.ResetQuota("peerA")
+	if len(s.CostUsages()) != 0 {
+		t.Errorf("CostUsages() after reset = %+v", s.CostUsages())
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	s := newCostTestService(10)
+	s.concurrentJobs = map[string]int{"peerA": 1}
+	s.config.Quotas.ConcurrentJobsPerIdentity = 2
+	s.config.RateLimits.PerIdentityRPS = 5
+
+	s.RecordCost("peerA", 4)
+	quotas := s.Quotas("peerA")
+	if quotas.Cost.Spent != 4 || quotas.Cost.Remaining() != 6 || quotas.ConcurrentJobs != 1 || quotas.MaxConcurrentJobs != 2 || quotas.RequestsPerSecond != 5 {
+		t.Errorf("Quotas(peerA) = %+v", quotas)
+	}
+	if quotas := s.Quotas("peerB"); quotas.Cost.Spent != 0 || quotas.Cost.Budget != 10 || quotas.ConcurrentJobs != 0 {
+		t.Errorf("Quotas(peerB) = %+v", quotas)
+	}
+}
@@ -0,0 +1,18 @@
+package security
+
+import "errors"
+
+// Errors returned by the security service. Callers branch on them with
+// errors.Is; the wrapped messages carry the details.
+var (
+	// ErrTooManyChallenges is returned by CreateChallenge when the address
+	// already has the maximum number of outstanding challenges
+	ErrTooManyChallenges = errors.New("too many outstanding challenges for address")
+	// ErrInvalidBan is returned by BanIP for a missing IP or unusable duration
+	ErrInvalidBan = errors.New("invalid ban")
+	// ErrUnknownBlockList is returned by Unblock for a list other than ban or greylist
+	ErrUnknownBlockList = errors.New("unknown block list")
+	// ErrReplayedNonce is returned by UseRequestNonce for a nonce the peer
+	// already used within the signature window
+	ErrReplayedNonce = errors.New("request nonce already used")
+)
@@ -0,0 +1,183 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// Defaults for the host sampler when the backpressure config leaves them unset
+const (
+	defaultBackpressureSampleInterval = 5 * time.Second
+	defaultBackpressureDiskPath       = "/"
+)
+
+// HostLoad is a sample of the host's resource usage. Percentages are of the
+// whole host, so they include Docker jobs and anything else running beside
+// the agent. A resource that could not be sampled is reported as 0.
+type HostLoad struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+	// LoadPerCPU is the one-minute load average divided by the CPU count
+	LoadPerCPU  float64 `json:"load_per_cpu"`
+	DiskPercent float64 `json:"disk_percent"`
+	// HeapMB is the agent's own Go heap allocation
+	HeapMB float64 `json:"heap_mb"`
+}
+
+// BackpressureState is the agent's computed pressure state
+type BackpressureState struct {
+	Active bool `json:"active"`
+	// Reasons names the resources keeping the host under pressure
+	Reasons   []string  `json:"reasons,omitempty"`
+	Since     time.Time `json:"since"`
+	Load      HostLoad  `json:"load"`
+	SampledAt time.Time `json:"sampled_at"`
+}
+
+// hostSampler samples the host's resource usage, with disk usage taken for
+// the filesystem holding diskPath
+type hostSampler func(ctx context.Context, diskPath string) (HostLoad, error)
+
+// sampleHost samples the host with gopsutil. A resource that fails to sample
+// is left at 0 and its error joined into the result, so one broken source
+// does not blind the others.
+func sampleHost(ctx context.Context, diskPath string) (HostLoad, error) {
+	var sample HostLoad
+	var errs []error
+
+	// With no interval gopsutil reports usage since its previous call, which
+	// is the previous sample
+	if percent, err := cpu.PercentWithContext(ctx, 0, false); err != nil {
+		errs = append(errs, fmt.Errorf("cpu: %w", err))
+	} else if len(percent) > 0 {
+		sample.CPUPercent = percent[0]
+	}
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("memory: %w", err))
+	} else {
+		sample.MemoryPercent = vm.UsedPercent
+	}
+	if avg, err := load.AvgWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("load: %w", err))
+	} else {
+		sample.LoadPerCPU = avg.Load1 / float64(runtime.NumCPU())
+	}
+	if usage, err := disk.UsageWithContext(ctx, diskPath); err != nil {
+		errs = append(errs, fmt.Errorf("disk %s: %w", diskPath, err))
+	} else {
+		sample.DiskPercent = usage.UsedPercent
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	sample.HeapMB = float64(m.Alloc) / 1024 / 1024
+
+	return sample, errors.Join(errs...)
+}
+
+// backpressureRoutine samples the host until the service shuts down. The
+// interval is re-read after each sample so config reloads apply to it.
+func (s *SecurityService) backpressureRoutine() {
+	for {
+		s.sampleBackpressure(context.Background())
+
+		interval := defaultBackpressureSampleInterval
+		if seconds := s.getConfig().Backpressure.SampleSeconds; seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-s.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// sampleBackpressure takes a host sample and updates the pressure state
+func (s *SecurityService) sampleBackpressure(ctx context.Context) {
+	config := s.getConfig()
+	diskPath := config.Backpressure.DiskPath
+	if diskPath == "" {
+		diskPath = defaultBackpressureDiskPath
+	}
+
+	sample, err := s.hostSampler(ctx, diskPath)
+	s.pressureMu.Lock()
+	defer s.pressureMu.Unlock()
+
+	// Log a failing source once rather than on every sample
+	if err != nil && err.Error() != s.pressureErr {
+		s.logger.Warn("failed to sample host load", "error", err)
+	}
+	s.pressureErr = ""
+	if err != nil {
+		s.pressureErr = err.Error()
+	}
+
+	now := time.Now()
+	wasActive := s.pressure.Active
+	reasons := pressureReasons(config, sample, wasActive)
+	s.pressure.Load = sample
+	s.pressure.SampledAt = now
+	s.pressure.Active = len(reasons) > 0
+	s.pressure.Reasons = reasons
+	if s.pressure.Active && !wasActive {
+		s.pressure.Since = now
+		s.logger.Warn("entering backpressure", "reasons", reasons, "cpu_percent", sample.CPUPercent,
+			"memory_percent", sample.MemoryPercent, "load_per_cpu", sample.LoadPerCPU, "disk_percent", sample.DiskPercent)
+	} else if !s.pressure.Active && wasActive {
+		s.logger.Info("leaving backpressure", "duration", now.Sub(s.pressure.Since).Round(time.Second))
+		s.pressure.Since = time.Time{}
+	}
+	updateBackpressureGauges(s.pressure)
+}
+
+// pressureReasons returns the resources keeping the host under pressure.
+// Outside pressure a resource counts once it is above its high watermark;
+// under pressure it keeps counting until it drops below its low watermark,
+// the high watermark reduced by hysteresis_percent of itself. This stops the state
+// flapping when usage hovers around a watermark. A zero watermark disables
+// its check.
+func pressureReasons(config *SecurityConfig, sample HostLoad, active bool) []string {
+	bp := config.Backpressure
+	threshold := func(high float64) float64 {
+		if active {
+			return high * (1 - float64(bp.HysteresisPercent)/100)
+		}
+		return high
+	}
+
+	var reasons []string
+	check := func(name string, value, high float64) {
+		if high > 0 && value > threshold(high) {
+			reasons = append(reasons, name)
+		}
+	}
+	check("cpu", sample.CPUPercent, float64(bp.CPUHighWatermark))
+	check("memory", sample.MemoryPercent, float64(bp.HostMemHighWatermark))
+	check("load", sample.LoadPerCPU, bp.LoadHighWatermark)
+	check("disk", sample.DiskPercent, float64(bp.DiskHighWatermark))
+	check("heap", sample.HeapMB, float64(bp.MemHighWatermark))
+	return reasons
+}
+
+// BackpressureState returns the pressure state computed from the last host
+// sample
+func (s *SecurityService) BackpressureState() BackpressureState {
+	s.pressureMu.RLock()
+	defer s.pressureMu.RUnlock()
+
+	state := s.pressure
+	state.Reasons = append([]string(nil), s.pressure.Reasons...)
+	return state
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestBackpressureHysteresis(t *testing.T) {
+	config := &SecurityConfig{}
+	config.Backpressure.CPUHighWatermark = 80
+	config.Backpressure.DiskHighWatermark = 90
+	config.Backpressure.HysteresisPercent = 10
+
+	var next HostLoad
+	s := &SecurityService{
+		config: config,
+		logger: slog.Default(),
+		hostSampler: func(ctx context.Context, diskPath string) (HostLoad, error) {
+			if diskPath != defaultBackpressureDiskPath {
+				t.Errorf("disk path = %q, want %q", diskPath, defaultBackpressureDiskPath)
+			}
+			return next, nil
+		},
+	}
+
+	steps := []struct {
+		name    string
+		load    HostLoad
+		active  bool
+		reasons []string
+	}{
+		{"idle", HostLoad{CPUPercent: 50, DiskPercent: 40}, false, nil},
+		{"below high watermark", HostLoad{CPUPercent: 79, DiskPercent: 40}, false, nil},
+		{"above high watermark", HostLoad{CPUPercent: 85, DiskPercent: 40}, true, []string{"cpu"}},
+		{"between watermarks", HostLoad{CPUPercent: 75, DiskPercent: 40}, true, []string{"cpu"}},
+		{"second resource", HostLoad{CPUPercent: 75, DiskPercent: 95}, true, []string{"cpu", "disk"}},
+		{"below low watermark", HostLoad{CPUPercent: 70, DiskPercent: 82}, true, []string{"disk"}},
+		{"all below low watermark", HostLoad{CPUPercent: 70, DiskPercent: 80}, false, nil},
+		{"between watermarks again", HostLoad{CPUPercent: 75, DiskPercent: 80}, false, nil},
+		{"heap and load unchecked", HostLoad{LoadPerCPU: 10, HeapMB: 1 << 20}, false, nil},
+	}
+	for _, step := range steps {
+		next = step.load
+		s.sampleBackpressure(context.Background())
+
+		state := s.BackpressureState()
+		if state.Active != step.active {
+			t.Fatalf("%s: active = %v, want %v", step.name, state.Active, step.active)
+		}
+		if !reflect.DeepEqual(state.Reasons, step.reasons) {
+			t.Errorf("%s: reasons = %v, want %v", step.name, state.Reasons, step.reasons)
+		}
+		if s.CheckBackpressure() != step.active {
+			t.Errorf("%s: CheckBackpressure() = %v, want %v", step.name, !step.active, step.active)
+		}
+		if state.Active && state.Since.IsZero() {
+			t.Errorf("%s: active state has no start time", step.name)
+		}
+	}
+}
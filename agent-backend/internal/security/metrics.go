@@ -0,0 +1,117 @@
+package security
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metric label values for rate limit reasons and challenge outcomes
+const (
+	rateLimitBanned        = "banned"
+	rateLimitGreylisted    = "greylisted"
+	rateLimitIP            = "ip_limit"
+	rateLimitIdentity      = "identity_limit"
+	challengeIssued        = "issued"
+	challengeRefused       = "refused"
+	challengeEvicted       = "evicted"
+	verificationVerified   = "verified"
+	verificationUnknown    = "unknown_nonce"
+	verificationExpired    = "expired"
+	verificationBadSig     = "invalid_signature"
+	verificationWrongOwner = "signer_mismatch"
+)
+
+var (
+	refusedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_security_refused_requests_total",
+		Help: "Requests refused by the security controls, by reason",
+	}, []string{"reason"})
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_security_rate_limited_total",
+		Help: "Requests refused by the rate limiter, by reason",
+	}, []string{"reason"})
+	blocksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_security_blocks_total",
+		Help: "IPs put on the ban list or greylist, by list",
+	}, []string{"list"})
+	blockedIPs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_security_blocked_ips",
+		Help: "IPs on the ban list or greylist of this replica, by list",
+	}, []string{"list"})
+	requestQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_request_queue_depth",
+		Help: "Requests holding a slot in the bounded request queue",
+	})
+	requestQueueCapacity = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_request_queue_capacity",
+		Help: "Slots in the bounded request queue",
+	})
+	concurrentJobsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pandacea_security_concurrent_jobs",
+		Help: "Jobs running under each identity's concurrency quota; identities without jobs are not reported",
+	}, []string{"identity"})
+	challengesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_security_challenges_total",
+		Help: "Auth challenges issued, refused for too many outstanding, or evicted to make room",
+	}, []string{"outcome"})
+	challengeVerificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_security_challenge_verifications_total",
+		Help: "Auth challenge verifications, by outcome",
+	}, []string{"outcome"})
+	outstandingChallenges = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_outstanding_challenges",
+		Help: "Auth challenges issued and not yet verified or expired",
+	})
+	backpressureActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_backpressure",
+		Help: "1 while requests are refused because the host is under pressure, 0 otherwise",
+	})
+	hostCPUPercent = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_host_cpu_percent",
+		Help: "Host CPU usage at the last backpressure sample",
+	})
+	hostMemoryPercent = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_host_memory_percent",
+		Help: "Host memory usage at the last backpressure sample",
+	})
+	hostLoadPerCPU = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_host_load_per_cpu",
+		Help: "One-minute load average divided by the CPU count at the last backpressure sample",
+	})
+	hostDiskPercent = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pandacea_security_host_disk_percent",
+		Help: "Usage of the backpressure disk_path filesystem at the last backpressure sample",
+	})
+)
+
+// updateBlockGauges reports the size of the local block lists. Caller must
+// hold s.mu.
+func (s *SecurityService) updateBlockGauges() {
+	blockedIPs.WithLabelValues(BlockListBan).Set(float64(len(s.bannedIPs)))
+	blockedIPs.WithLabelValues(BlockListGreylist).Set(float64(len(s.greylistedIPs)))
+}
+
+// updateConcurrentJobsGauge reports identity's running jobs, dropping the
+// series once it has none so idle identities do not accumulate. Caller must
+// hold s.mu.
+func (s *SecurityService) updateConcurrentJobsGauge(identity string) {
+	if jobs := s.concurrentJobs[identity]; jobs > 0 {
+		concurrentJobsGauge.WithLabelValues(identity).Set(float64(jobs))
+	} else {
+		concurrentJobsGauge.DeleteLabelValues(identity)
+	}
+}
+
+// updateBackpressureGauges reports the pressure state and the sample it was
+// computed from
+func updateBackpressureGauges(state BackpressureState) {
+	active := 0.0
+	if state.Active {
+		active = 1
+	}
+	backpressureActive.Set(active)
+	hostCPUPercent.Set(state.Load.CPUPercent)
+	hostMemoryPercent.Set(state.Load.MemoryPercent)
+	hostLoadPerCPU.Set(state.Load.LoadPerCPU)
+	hostDiskPercent.Set(state.Load.DiskPercent)
+}
@@ -0,0 +1,136 @@
+package security
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimitMetrics(t *testing.T) {
+	service, _ := newRateLimitTestService(t, `
+rate_limits:
+  per_ip_rps: 1
+  per_identity_rps: 100
+  burst: 1
+bans:
+  greylist_seconds: 600
+`)
+
+	ipLimited := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateLimitIP))
+	greylisted := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateLimitGreylisted))
+	greylistings := testutil.ToFloat64(blocksTotal.WithLabelValues(BlockListGreylist))
+
+	req := httptest.NewRequest("GET", "/api/v1/products", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	for i := 0; i < 3; i++ {
+		service.CheckRateLimit(req, "")
+	}
+
+	// The second request exhausts the bucket and greylists the IP, which
+	// refuses the third
+	if got := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateLimitIP)) - ipLimited; got != 1 {
+		t.Errorf("ip_limit rejections = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateLimitGreylisted)) - greylisted; got != 1 {
+		t.Errorf("greylisted rejections = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(blocksTotal.WithLabelValues(BlockListGreylist)) - greylistings; got != 1 {
+		t.Errorf("greylistings = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(blockedIPs.WithLabelValues(BlockListGreylist)); got != 1 {
+		t.Errorf("greylisted IPs = %v, want 1", got)
+	}
+
+	refused := testutil.ToFloat64(refusedRequestsTotal.WithLabelValues("rate_limited"))
+	service.LogRefusedRequest(req, "", "rate_limited")
+	if got := testutil.ToFloat64(refusedRequestsTotal.WithLabelValues("rate_limited")) - refused; got != 1 {
+		t.Errorf("refused requests = %v, want 1", got)
+	}
+	// Logging the refusal does not count as another rate limit decision
+	if got := testutil.ToFloat64(rateLimitedTotal.WithLabelValues(rateLimitGreylisted)) - greylisted; got != 1 {
+		t.Errorf("greylisted rejections after logging = %v, want 1", got)
+	}
+}
+
+func TestQueueAndQuotaMetrics(t *testing.T) {
+	service, _ := newRateLimitTestService(t, `
+quotas:
+  concurrent_jobs_per_identity: 2
+queue:
+  max_size: 4
+`)
+
+	if got := testutil.ToFloat64(requestQueueCapacity); got != 4 {
+		t.Errorf("queue capacity = %v, want 4", got)
+	}
+	service.CheckRequestQueue()
+	service.CheckRequestQueue()
+	if got := testutil.ToFloat64(requestQueueDepth); got != 2 {
+		t.Errorf("queue depth = %v, want 2", got)
+	}
+	service.ReleaseRequestQueue()
+	service.ReleaseRequestQueue()
+	if got := testutil.ToFloat64(requestQueueDepth); got != 0 {
+		t.Errorf("queue depth after release = %v, want 0", got)
+	}
+
+	service.CheckConcurrencyQuota("peerA")
+	service.CheckConcurrencyQuota("peerA")
+	if got := testutil.ToFloat64(concurrentJobsGauge.WithLabelValues("peerA")); got != 2 {
+		t.Errorf("concurrent jobs = %v, want 2", got)
+	}
+	service.ReleaseConcurrencyQuota("peerA")
+	service.ReleaseConcurrencyQuota("peerA")
+	// Identities without running jobs are dropped rather than reported as 0
+	if got := testutil.CollectAndCount(concurrentJobsGauge); got != 0 {
+		t.Errorf("concurrent job series = %d, want 0", got)
+	}
+}
+
+func TestChallengeMetrics(t *testing.T) {
+	const signerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	key, _ := crypto.HexToECDSA(signerKey)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	service := newAuthTestService()
+	service.config.Auth.MaxChallengesPerAddress = 1
+
+	issued := testutil.ToFloat64(challengesTotal.WithLabelValues(challengeIssued))
+	refused := testutil.ToFloat64(challengesTotal.WithLabelValues(challengeRefused))
+	verified := testutil.ToFloat64(challengeVerificationsTotal.WithLabelValues(verificationVerified))
+	unknown := testutil.ToFloat64(challengeVerificationsTotal.WithLabelValues(verificationUnknown))
+
+	challenge, err := service.CreateChallenge(address)
+	if err != nil {
+		t.Fatalf("CreateChallenge() error = %v", err)
+	}
+	if _, err := service.CreateChallenge(address); err == nil {
+		t.Fatal("second CreateChallenge() succeeded, want the per-address limit")
+	}
+	if got := testutil.ToFloat64(outstandingChallenges); got != 1 {
+		t.Errorf("outstanding challenges = %v, want 1", got)
+	}
+
+	if _, ok := service.VerifyChallenge(challenge.Nonce, personalSign(t, challenge.Nonce, signerKey)); !ok {
+		t.Fatal("VerifyChallenge() failed for a valid signature")
+	}
+	service.VerifyChallenge(challenge.Nonce, personalSign(t, challenge.Nonce, signerKey))
+
+	for name, m := range map[string]struct {
+		got, before float64
+	}{
+		"issued":        {testutil.ToFloat64(challengesTotal.WithLabelValues(challengeIssued)), issued},
+		"refused":       {testutil.ToFloat64(challengesTotal.WithLabelValues(challengeRefused)), refused},
+		"verified":      {testutil.ToFloat64(challengeVerificationsTotal.WithLabelValues(verificationVerified)), verified},
+		"unknown nonce": {testutil.ToFloat64(challengeVerificationsTotal.WithLabelValues(verificationUnknown)), unknown},
+	} {
+		if m.got-m.before != 1 {
+			t.Errorf("%s = %v, want 1", name, m.got-m.before)
+		}
+	}
+	if got := testutil.ToFloat64(outstandingChallenges); got != 0 {
+		t.Errorf("outstanding challenges after verification = %v, want 0", got)
+	}
+}
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Block lists of IPs refused by the rate limiter
+const (
+	BlockListBan      = "ban"
+	BlockListGreylist = "greylist"
+)
+
+// rateLimitStoreTimeout bounds each call to a shared store so a slow backend
+// cannot stall request handling
+const rateLimitStoreTimeout = 100 * time.Millisecond
+
+// rateLimitStoreBackoff is how long local limits are used after the shared
+// store fails, before it is tried again
+const rateLimitStoreBackoff = 5 * time.Second
+
+// RateLimitStore holds token buckets, bans and greylists outside the process
+// so that every agent replica enforces the same limits
+type RateLimitStore interface {
+	// Take consumes a token from the bucket for key, creating it full if needed
+	Take(ctx context.Context, key string, capacity, rate float64) (bool, error)
+	// Block adds ip to a block list until the given time
+	Block(ctx context.Context, list, ip string, until time.Time) error
+	// BlockedUntil returns when ip leaves a block list, or the zero time if it is not listed
+	BlockedUntil(ctx context.Context, list, ip string) (time.Time, error)
+	// Unblock removes ip from a block list
+	Unblock(ctx context.Context, list, ip string) error
+	// Claim records key until the given time, reporting false if it is
+	// already recorded
+	Claim(ctx context.Context, key string, until time.Time) (bool, error)
+	// Close releases the store's connections
+	Close() error
+}
+
+// newRateLimitStore creates the shared store selected by config, or nil for
+// the default in-process limits
+func newRateLimitStore(config *SecurityConfig) (RateLimitStore, error) {
+	switch config.RateLimitStore.Backend {
+	case "", "memory":
+		return nil, nil
+	case "redis":
+		return NewRedisRateLimitStore(config.RateLimitStore.RedisURL, config.RateLimitStore.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store backend: %q", config.RateLimitStore.Backend)
+	}
+}
+
+// takeTokenScript refills and takes from a bucket atomically. The bucket is a
+// hash of the remaining tokens and the last refill time in milliseconds, and
+// expires once it would have refilled completely.
+var takeTokenScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+
+local elapsed = math.max(0, now - ts) / 1000
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return allowed
+`)
+
+// RedisRateLimitStore is a RateLimitStore backed by Redis
+type RedisRateLimitStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRateLimitStore connects to the Redis server at url, e.g.
+// redis://:password@host:6379/0
+func NewRedisRateLimitStore(url, prefix string) (*RedisRateLimitStore, error) {
+	if url == "" {
+		return nil, fmt.Errorf("redis rate limit store requires a redis_url")
+	}
+
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	// A limiter must answer quickly; fall back to local limits instead of retrying
+	opts.MaxRetries = -1
+
+	if prefix == "" {
+		prefix = "pandacea:ratelimit:"
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisRateLimitStore{client: client, prefix: prefix}, nil
+}
+
+// Take consumes a token from the shared bucket for key
+func (rs *RedisRateLimitStore) Take(ctx context.Context, key string, capacity, rate float64) (bool, error) {
+	// Keep idle buckets until they would be full again, then let Redis drop them
+	ttl := time.Hour
+	if rate > 0 {
+		ttl = time.Duration(math.Ceil(capacity/rate)+1) * time.Second
+	}
+
+	allowed, err := takeTokenScript.Run(ctx, rs.client,
+		[]string{rs.prefix + "bucket:" + key},
+		capacity, rate, time.Now().UnixMilli(), ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to take token: %w", err)
+	}
+
+	return allowed == 1, nil
+}
+
+// Block adds ip to a shared block list until the given time
+func (rs *RedisRateLimitStore) Block(ctx context.Context, list, ip string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := rs.client.Set(ctx, rs.prefix+list+":"+ip, until.UnixMilli(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to update %s list: %w", list, err)
+	}
+	return nil
+}
+
+// BlockedUntil returns when ip leaves a shared block list
+func (rs *RedisRateLimitStore) BlockedUntil(ctx context.Context, list, ip string) (time.Time, error) {
+	until, err := rs.client.Get(ctx, rs.prefix+list+":"+ip).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s list: %w", list, err)
+	}
+	return time.UnixMilli(until), nil
+}
+
+// Unblock removes ip from a shared block list
+func (rs *RedisRateLimitStore) Unblock(ctx context.Context, list, ip string) error {
+	if err := rs.client.Del(ctx, rs.prefix+list+":"+ip).Err(); err != nil {
+		return fmt.Errorf("failed to update %s list: %w", list, err)
+	}
+	return nil
+}
+
+// Claim records key in the shared store until the given time. Only the
+// first replica to claim a key gets true.
+func (rs *RedisRateLimitStore) Claim(ctx context.Context, key string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return true, nil
+	}
+	claimed, err := rs.client.SetNX(ctx, rs.prefix+key, until.UnixMilli(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", key, err)
+	}
+	return claimed, nil
+}
+
+// Close closes the Redis connection pool
+func (rs *RedisRateLimitStore) Close() error {
+	return rs.client.Close()
+}
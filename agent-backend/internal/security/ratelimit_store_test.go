@@ -0,0 +1,144 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const sharedLimitConfig = `
+rate_limits:
+  per_ip_rps: 1
+  per_identity_rps: 1
+  burst: 4
+rate_limit_store:
+  backend: redis
+bans:
+  greylist_seconds: 600
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`
+
+func TestRedisRateLimitStoreSharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("RATE_LIMIT_REDIS_URL", "redis://"+mr.Addr())
+
+	replicaA, _ := newRateLimitTestService(t, sharedLimitConfig)
+	replicaB, _ := newRateLimitTestService(t, sharedLimitConfig)
+
+	// The burst of 4 is shared, so alternating replicas exhaust it together
+	replicas := []*SecurityService{replicaA, replicaB}
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/products", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if allowed, _ := replicas[i%2].CheckRateLimit(req, ""); !allowed {
+			t.Fatalf("request %d should be within the shared burst", i)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/products", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if allowed, _ := replicaA.CheckRateLimit(req, ""); allowed {
+		t.Fatal("expected the shared burst to be exhausted")
+	}
+
+	// The greylist set by replica A is enforced by replica B
+	if allowed, retry := replicaB.CheckRateLimit(req, ""); allowed || retry <= 0 {
+		t.Errorf("replica B should honour the shared greylist, got allowed=%v retry=%v", allowed, retry)
+	}
+}
+
+func TestRedisRateLimitStoreFallsBackWhenUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("RATE_LIMIT_REDIS_URL", "redis://"+mr.Addr())
+
+	service, _ := newRateLimitTestService(t, sharedLimitConfig)
+	mr.Close()
+
+	// Local buckets keep enforcing the limit while the store is down
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/products", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		if allowed, _ := service.CheckRateLimit(req, ""); !allowed {
+			t.Fatalf("request %d should be allowed by the local bucket", i)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/products", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	if allowed, _ := service.CheckRateLimit(req, ""); allowed {
+		t.Error("expected the local bucket to limit requests")
+	}
+}
+
+func TestRedisReplayCacheSharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("RATE_LIMIT_REDIS_URL", "redis://"+mr.Addr())
+
+	replicaA, _ := newRateLimitTestService(t, sharedLimitConfig)
+	replicaB, _ := newRateLimitTestService(t, sharedLimitConfig)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := replicaA.UseRequestNonce(ctx, "peerA", "n1", now); err != nil {
+		t.Fatalf("first use error = %v", err)
+	}
+	// A request replayed to another replica is caught there
+	if err := replicaB.UseRequestNonce(ctx, "peerA", "n1", now); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("replay to replica B error = %v, want ErrReplayedNonce", err)
+	}
+	// The claim expires when the timestamp leaves the skew window
+	if ttl := mr.TTL("pandacea:ratelimit:nonce:peerA/n1"); ttl <= 0 || ttl > replicaA.SignatureMaxSkew() {
+		t.Errorf("nonce TTL = %v, want at most %v", ttl, replicaA.SignatureMaxSkew())
+	}
+
+	// While the store is down each replica still catches replays to itself
+	mr.Close()
+	if err := replicaA.UseRequestNonce(ctx, "peerA", "n2", now); err != nil {
+		t.Fatalf("use without store error = %v", err)
+	}
+	if err := replicaA.UseRequestNonce(ctx, "peerA", "n1", now); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("local replay error = %v, want ErrReplayedNonce", err)
+	}
+}
+
+func TestRedisRateLimitStoreRefill(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := NewRedisRateLimitStore("redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("NewRedisRateLimitStore() error = %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if allowed, err := store.Take(ctx, "k", 2, 20); err != nil || !allowed {
+			t.Fatalf("Take() #%d = %v, %v", i, allowed, err)
+		}
+	}
+	if allowed, _ := store.Take(ctx, "k", 2, 20); allowed {
+		t.Fatal("expected bucket to be empty")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if allowed, err := store.Take(ctx, "k", 2, 20); err != nil || !allowed {
+		t.Errorf("Take() after refill = %v, %v", allowed, err)
+	}
+
+	until := time.Now().Add(time.Minute)
+	if err := store.Block(ctx, BlockListBan, "10.0.0.3", until); err != nil {
+		t.Fatalf("Block() error = %v", err)
+	}
+	got, err := store.BlockedUntil(ctx, BlockListBan, "10.0.0.3")
+	if err != nil || got.UnixMilli() != until.UnixMilli() {
+		t.Errorf("BlockedUntil() = %v, %v; want %v", got, err, until)
+	}
+	if got, _ := store.BlockedUntil(ctx, BlockListGreylist, "10.0.0.3"); !got.IsZero() {
+		t.Errorf("IP should not be greylisted, got %v", got)
+	}
+}
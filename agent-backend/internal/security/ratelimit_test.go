@@ -0,0 +1,295 @@
+package security
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const routeLimitConfig = `
+rate_limits:
+  per_ip_rps: 5
+  per_identity_rps: 2
+  burst: 10
+  method_burst:
+    POST: 4
+  routes:
+    - path: /api/v1/train
+      per_ip_rps: 1
+      burst: 2
+    - path: /api/v1/privacy
+      burst: 6
+      method_burst:
+        GET: 8
+bans:
+  greylist_seconds: 600
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+`
+
+func newRateLimitTestService(t *testing.T, content string) (*SecurityService, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "security.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	service, err := NewSecurityService(path, slog.Default())
+	if err != nil {
+		t.Fatalf("NewSecurityService() error = %v", err)
+	}
+	t.Cleanup(service.Shutdown)
+
+	return service, path
+}
+
+func TestResolveRateLimit(t *testing.T) {
+	service, _ := newRateLimitTestService(t, routeLimitConfig)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantRPS   int
+		wantBurst int
+		wantScope string
+	}{
+		{"global default", "GET", "/api/v1/products", 5, 10, ""},
+		{"global method burst", "POST", "/api/v1/leases", 5, 4, "POST"},
+		{"route override", "POST", "/api/v1/train", 1, 2, "/api/v1/train"},
+		{"route subpath", "POST", "/api/v1/train/status", 1, 2, "/api/v1/train"},
+		{"prefix stops at segment", "POST", "/api/v1/training-jobs", 5, 4, "POST"},
+		{"route inherits rps", "POST", "/api/v1/privacy/execute", 5, 6, "/api/v1/privacy"},
+		{"route method burst", "GET", "/api/v1/privacy/results/abc", 5, 8, "/api/v1/privacy GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			service.mu.Lock()
+			limit := service.resolveRateLimit(req)
+			service.mu.Unlock()
+
+			if limit.perIPRPS != tt.wantRPS {
+				t.Errorf("perIPRPS = %d, want %d", limit.perIPRPS, tt.wantRPS)
+			}
+			if limit.burst != tt.wantBurst {
+				t.Errorf("burst = %d, want %d", limit.burst, tt.wantBurst)
+			}
+			if limit.scope != tt.wantScope {
+				t.Errorf("scope = %q, want %q", limit.scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestCheckRateLimitPerRoute(t *testing.T) {
+	service, _ := newRateLimitTestService(t, routeLimitConfig)
+
+	// The strict /train route allows a burst of 2 from this IP
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/v1/train", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if allowed, _ := service.CheckRateLimit(req, ""); !allowed {
+			t.Fatalf("request %d to /train should be allowed", i)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/train", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if allowed, _ := service.CheckRateLimit(req, ""); allowed {
+		t.Fatal("third request to /train should be rate limited")
+	}
+
+	// A different IP is unaffected and uses the global bucket for other routes
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/products", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		if allowed, _ := service.CheckRateLimit(req, ""); !allowed {
+			t.Fatalf("request %d to /products should be allowed", i)
+		}
+	}
+}
+
+const routeClassConfig = `
+rate_limits:
+  per_ip_rps: 5
+  per_identity_rps: 2
+  burst: 10
+  classes:
+    read:
+      per_ip_rps: 20
+      burst: 40
+    heavy_compute:
+      per_ip_rps: 1
+      per_identity_rps: 1
+      burst: 3
+  routes:
+    - path: /api/v1/privacy/execute
+      class: heavy_compute
+    - path: /api/v1/train
+      class: heavy_compute
+      burst: 2
+`
+
+func TestResolveRateLimitByRouteClass(t *testing.T) {
+	service, _ := newRateLimitTestService(t, routeClassConfig)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantRPS   int
+		wantBurst int
+		wantScope string
+	}{
+		{"read by method", "GET", "/api/v1/products", 20, 40, "class:read"},
+		{"write class unset", "POST", "/api/v1/leases", 5, 10, ""},
+		{"class named by route", "POST", "/api/v1/privacy/execute", 1, 3, "class:heavy_compute"},
+		{"route overrides class", "POST", "/api/v1/train", 1, 2, "/api/v1/train"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			service.mu.Lock()
+			limit := service.resolveRateLimit(req)
+			service.mu.Unlock()
+
+			if limit.perIPRPS != tt.wantRPS {
+				t.Errorf("perIPRPS = %d, want %d", limit.perIPRPS, tt.wantRPS)
+			}
+			if limit.burst != tt.wantBurst {
+				t.Errorf("burst = %d, want %d", limit.burst, tt.wantBurst)
+			}
+			if limit.scope != tt.wantScope {
+				t.Errorf("scope = %q, want %q", limit.scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestClassBurstAndMethodBurst(t *testing.T) {
+	service, _ := newRateLimitTestService(t, `
+rate_limits:
+  per_ip_rps: 5
+  burst: 10
+  method_burst:
+    POST: 4
+    DELETE: 20
+  classes:
+    write:
+      burst: 8
+`)
+
+	tests := []struct {
+		method    string
+		wantBurst int
+		wantScope string
+	}{
+		{"POST", 4, "class:write POST"},
+		{"DELETE", 8, "class:write"},
+		{"PUT", 8, "class:write"},
+	}
+	for _, tt := range tests {
+		service.mu.Lock()
+		limit := service.resolveRateLimit(httptest.NewRequest(tt.method, "/api/v1/leases", nil))
+		service.mu.Unlock()
+		if limit.burst != tt.wantBurst || limit.scope != tt.wantScope {
+			t.Errorf("%s: burst %d scope %q, want %d %q", tt.method, limit.burst, limit.scope, tt.wantBurst, tt.wantScope)
+		}
+	}
+
+	// Preflights are never rate limited, however many exceed the burst
+	req := httptest.NewRequest("OPTIONS", "/api/v1/products", nil)
+	req.RemoteAddr = "10.0.0.4:1234"
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	for i := 0; i < 20; i++ {
+		if allowed, _ := service.CheckRateLimit(req, ""); !allowed {
+			t.Fatalf("preflight %d was rate limited", i)
+		}
+	}
+}
+
+func TestLoadConfigRejectsUnknownRouteClass(t *testing.T) {
+	for _, content := range []string{
+		"rate_limits:\n  classes:\n    bulk:\n      burst: 5\n",
+		"rate_limits:\n  routes:\n    - path: /api/v1/train\n      class: heavy\n",
+	} {
+		path := filepath.Join(t.TempDir(), "security.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("loadConfig accepted %q", content)
+		}
+	}
+}
+
+func TestReloadAppliesNewLimits(t *testing.T) {
+	service, path := newRateLimitTestService(t, routeLimitConfig)
+
+	updated := `
+rate_limits:
+  per_ip_rps: 50
+  per_identity_rps: 20
+  burst: 100
+bans:
+  greylist_seconds: 60
+`
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+	if err := service.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/train", nil)
+	service.mu.Lock()
+	limit := service.resolveRateLimit(req)
+	service.mu.Unlock()
+
+	if limit.burst != 100 || limit.perIPRPS != 50 || limit.scope != "" {
+		t.Errorf("limit after reload = %+v, want global burst 100 at 50 rps", limit)
+	}
+}
+
+func TestReloadKeepsConfigOnError(t *testing.T) {
+	service, path := newRateLimitTestService(t, routeLimitConfig)
+
+	if err := os.WriteFile(path, []byte("rate_limits: [not, a, map"), 0644); err != nil {
+		t.Fatalf("failed to corrupt config: %v", err)
+	}
+
+	if err := service.Reload(); err == nil {
+		t.Fatal("expected Reload() to fail on invalid YAML")
+	}
+	if got := service.getConfig().RateLimits.Burst; got != 10 {
+		t.Errorf("burst after failed reload = %d, want 10", got)
+	}
+}
+
+func TestApplyConfigFromRemoteSource(t *testing.T) {
+	service, _ := newRateLimitTestService(t, routeLimitConfig)
+
+	if err := service.ApplyConfig([]byte("rate_limits: [not, a, map")); err == nil {
+		t.Fatal("expected ApplyConfig() to fail on invalid YAML")
+	}
+	if err := service.ApplyConfig([]byte("rate_limits:\n  burst: 100\n  classes:\n    bulk: {per_ip_rps: 1}\n")); err == nil {
+		t.Fatal("expected ApplyConfig() to reject an unknown rate limit class")
+	}
+	if got := service.getConfig().RateLimits.Burst; got != 10 {
+		t.Errorf("burst after failed apply = %d, want 10", got)
+	}
+
+	if err := service.ApplyConfig([]byte("rate_limits:\n  per_ip_rps: 50\n  burst: 100\n")); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+	if got := service.getConfig().RateLimits.Burst; got != 100 {
+		t.Errorf("burst after apply = %d, want 100", got)
+	}
+}
@@ -0,0 +1,65 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultSignatureMaxSkew is used when the auth config leaves
+// signature_max_skew_seconds unset
+const defaultSignatureMaxSkew = 5 * time.Minute
+
+// SignatureMaxSkew returns how far a signed request's timestamp may be from
+// server time
+func (s *SecurityService) SignatureMaxSkew() time.Duration {
+	if seconds := s.getConfig().Auth.SignatureMaxSkewSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultSignatureMaxSkew
+}
+
+// AllowLegacySignatures reports whether v1 request signatures are accepted
+func (s *SecurityService) AllowLegacySignatures() bool {
+	return s.getConfig().Auth.AllowLegacySignatures
+}
+
+// UseRequestNonce records a signed request's nonce for peerID. It returns
+// ErrReplayedNonce if the peer already used the nonce. A nonce only needs
+// to be remembered until its timestamp leaves the skew window, after which
+// the request is rejected as stale anyway.
+//
+// With a shared store the nonce is claimed there, so a request replayed to
+// another replica is caught too. It is also recorded locally, which keeps
+// replays to this replica caught while the store is unavailable.
+//
+// Without a shared store the nonces live only in memory and are lost on
+// restart, so a request signed before the agent started is refused: the
+// previous process may already have accepted it.
+func (s *SecurityService) UseRequestNonce(ctx context.Context, peerID, nonce string, timestamp time.Time) error {
+	key := peerID + "/" + nonce
+	expiresAt := timestamp.Add(s.SignatureMaxSkew())
+
+	if s.useLimitStore() {
+		ctx, cancel := context.WithTimeout(ctx, rateLimitStoreTimeout)
+		claimed, err := s.limitStore.Claim(ctx, "nonce:"+key, expiresAt)
+		cancel()
+		if err != nil {
+			s.limitStoreFailed("claim nonce", err)
+		} else if !claimed {
+			return fmt.Errorf("%w: peer %s", ErrReplayedNonce, peerID)
+		}
+	} else if s.limitStore == nil && timestamp.Before(s.startedAt) {
+		return fmt.Errorf("%w: peer %s signed the request before the agent started", ErrReplayedNonce, peerID)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seen, exists := s.requestNonces[key]; exists && !now.After(seen) {
+		return fmt.Errorf("%w: peer %s", ErrReplayedNonce, peerID)
+	}
+	s.requestNonces[key] = expiresAt
+	return nil
+}
@@ -1,19 +1,23 @@
 package security
 
 import (
+	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
@@ -21,16 +25,45 @@ import (
 // SecurityConfig holds the security configuration
 type SecurityConfig struct {
 	RateLimits struct {
-		PerIPRPS       int `yaml:"per_ip_rps"`
-		PerIdentityRPS int `yaml:"per_identity_rps"`
-		Burst          int `yaml:"burst"`
+		PerIPRPS       int            `yaml:"per_ip_rps"`
+		PerIdentityRPS int            `yaml:"per_identity_rps"`
+		Burst          int            `yaml:"burst"`
+		MethodBurst    map[string]int `yaml:"method_burst"`
+		// Classes set limits for each route class, between the global
+		// limits and route overrides
+		Classes map[string]RateLimitClass `yaml:"classes"`
+		Routes  []RouteRateLimit          `yaml:"routes"`
 	} `yaml:"rate_limits"`
+	RateLimitStore struct {
+		Backend   string `yaml:"backend"`
+		RedisURL  string `yaml:"redis_url"`
+		KeyPrefix string `yaml:"key_prefix"`
+	} `yaml:"rate_limit_store"`
 	Quotas struct {
 		ConcurrentJobsPerIdentity int `yaml:"concurrent_jobs_per_identity"`
+		// CostBudget caps the cost units an identity may spend in each cost
+		// window (0 disables the budget)
+		CostBudget        float64 `yaml:"cost_budget"`
+		CostWindowSeconds int     `yaml:"cost_window_seconds"`
 	} `yaml:"quotas"`
+	Costs struct {
+		BaseUnits          float64 `yaml:"base_units"`           // Charged for every request
+		ComputeSecondUnits float64 `yaml:"compute_second_units"` // Charged per second spent handling the request
+		MegabyteUnits      float64 `yaml:"megabyte_units"`       // Charged per MiB of response body
+	} `yaml:"costs"`
+	// Backpressure refuses requests while the host is overloaded. Each
+	// watermark is checked against a periodic host sample; zero disables it.
 	Backpressure struct {
-		CPUHighWatermark int `yaml:"cpu_high_watermark"`
-		MemHighWatermark int `yaml:"mem_high_watermark_mb"`
+		CPUHighWatermark     int     `yaml:"cpu_high_watermark"`      // Host CPU usage percentage
+		HostMemHighWatermark int     `yaml:"host_mem_high_watermark"` // Host memory usage percentage
+		LoadHighWatermark    float64 `yaml:"load_high_watermark"`     // One-minute load average per CPU
+		DiskHighWatermark    int     `yaml:"disk_high_watermark"`     // Usage percentage of the filesystem holding DiskPath
+		MemHighWatermark     int     `yaml:"mem_high_watermark_mb"`   // The agent's own Go heap in MB
+		DiskPath             string  `yaml:"disk_path"`
+		// HysteresisPercent is how far below its high watermark a resource
+		// must drop before backpressure ends
+		HysteresisPercent int `yaml:"hysteresis_percent"`
+		SampleSeconds     int `yaml:"sample_seconds"`
 	} `yaml:"backpressure"`
 	Queue struct {
 		MaxSize int `yaml:"max_size"`
@@ -40,13 +73,69 @@ type SecurityConfig struct {
 		TempBanSeconds  int `yaml:"temp_ban_seconds"`
 	} `yaml:"bans"`
 	RequestLimits struct {
-		MaxBodySizeMB   int `yaml:"max_body_size_mb"`
-		MaxHeaderSizeKB int `yaml:"max_header_size_kb"`
+		MaxBodySizeMB   int              `yaml:"max_body_size_mb"`
+		MaxHeaderSizeKB int              `yaml:"max_header_size_kb"`
+		Routes          []RouteBodyLimit `yaml:"routes"`
 	} `yaml:"request_limits"`
 	Auth struct {
 		ChallengeTimeoutSeconds int `yaml:"challenge_timeout_seconds"`
 		NonceLength             int `yaml:"nonce_length"`
+		MaxChallenges           int `yaml:"max_challenges"`
+		MaxChallengesPerAddress int `yaml:"max_challenges_per_address"`
+		// SignatureMaxSkewSeconds is how far a signed request's timestamp may
+		// be from server time
+		SignatureMaxSkewSeconds int `yaml:"signature_max_skew_seconds"`
+		// AllowLegacySignatures accepts v1 signatures, which have no
+		// timestamp or nonce and so cannot be protected against replay
+		AllowLegacySignatures bool `yaml:"allow_legacy_signatures"`
 	} `yaml:"auth"`
+	SIEM  SIEMConfig `yaml:"siem"`
+	Admin struct {
+		// PeerIDs lists the libp2p peer IDs allowed to use the admin API
+		PeerIDs []string `yaml:"peer_ids"`
+	} `yaml:"admin"`
+}
+
+// Route classes group routes that share rate limits. Requests fall in the
+// read or write class by method unless their route names a class.
+const (
+	RouteClassRead         = "read"
+	RouteClassWrite        = "write"
+	RouteClassHeavyCompute = "heavy_compute"
+)
+
+// RateLimitClass sets the rate limits of a route class. Zero values inherit
+// the global setting.
+type RateLimitClass struct {
+	PerIPRPS       int `yaml:"per_ip_rps"`
+	PerIdentityRPS int `yaml:"per_identity_rps"`
+	Burst          int `yaml:"burst"`
+}
+
+// RouteRateLimit overrides the global rate limits for requests whose path
+// starts with Path. Zero values inherit the class or global setting.
+type RouteRateLimit struct {
+	Path           string         `yaml:"path"`
+	Class          string         `yaml:"class"`
+	PerIPRPS       int            `yaml:"per_ip_rps"`
+	PerIdentityRPS int            `yaml:"per_identity_rps"`
+	Burst          int            `yaml:"burst"`
+	MethodBurst    map[string]int `yaml:"method_burst"`
+}
+
+// RouteBodyLimit overrides the maximum request body size for requests whose
+// path starts with Path
+type RouteBodyLimit struct {
+	Path          string `yaml:"path"`
+	MaxBodySizeKB int    `yaml:"max_body_size_kb"`
+}
+
+// rateLimit is the effective limit applied to a single request
+type rateLimit struct {
+	scope          string
+	perIPRPS       int
+	perIdentityRPS int
+	burst          int
 }
 
 // TokenBucket implements a simple token bucket rate limiter
@@ -144,14 +233,27 @@ func (bq *BoundedRequestQueue) GetCapacity() int {
 // SecurityService handles security controls
 type SecurityService struct {
 	config          *SecurityConfig
+	configPath      string
 	logger          *slog.Logger
 	ipBuckets       map[string]*TokenBucket
 	identityBuckets map[string]*TokenBucket
 	challenges      map[string]*Challenge
+	challengeCounts map[string]int
+	challengeOrder  []string
+	requestNonces   map[string]time.Time
+	startedAt       time.Time
+	costWindows     map[string]*costWindow
 	concurrentJobs  map[string]int
 	bannedIPs       map[string]time.Time
 	greylistedIPs   map[string]time.Time
+	limitStore      RateLimitStore
+	storeDownUntil  atomic.Int64
+	exporter        *SIEMExporter
 	requestQueue    *BoundedRequestQueue
+	hostSampler     hostSampler
+	pressure        BackpressureState
+	pressureErr     string
+	pressureMu      sync.RWMutex
 	mu              sync.RWMutex
 	cleanupTicker   *time.Ticker
 	done            chan bool
@@ -191,6 +293,23 @@ func NewSecurityService(configPath string, logger *slog.Logger) (*SecurityServic
 		return nil, fmt.Errorf("failed to load security config: %w", err)
 	}
 
+	// Keep credentials for the shared store out of security.yaml
+	if url := os.Getenv("RATE_LIMIT_REDIS_URL"); url != "" {
+		config.RateLimitStore.RedisURL = url
+	}
+	limitStore, err := newRateLimitStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize rate limit store: %w", err)
+	}
+
+	var exporter *SIEMExporter
+	if config.SIEM.Enabled {
+		exporter, err = NewSIEMExporter(config.SIEM, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SIEM exporter: %w", err)
+		}
+	}
+
 	// Set queue size from environment variable or config
 	queueSize := config.Queue.MaxSize
 	if queueSize <= 0 {
@@ -208,39 +327,149 @@ func NewSecurityService(configPath string, logger *slog.Logger) (*SecurityServic
 
 	service := &SecurityService{
 		config:          config,
+		configPath:      configPath,
 		logger:          logger,
 		ipBuckets:       make(map[string]*TokenBucket),
 		identityBuckets: make(map[string]*TokenBucket),
 		challenges:      make(map[string]*Challenge),
+		challengeCounts: make(map[string]int),
+		requestNonces:   make(map[string]time.Time),
+		startedAt:       time.Now().Truncate(time.Second),
+		costWindows:     make(map[string]*costWindow),
 		concurrentJobs:  make(map[string]int),
 		bannedIPs:       make(map[string]time.Time),
 		greylistedIPs:   make(map[string]time.Time),
+		limitStore:      limitStore,
+		exporter:        exporter,
 		requestQueue:    NewBoundedRequestQueue(queueSize, logger),
+		hostSampler:     sampleHost,
 		done:            make(chan bool),
 	}
 
+	requestQueueCapacity.Set(float64(queueSize))
+
 	// Start cleanup goroutine
 	service.cleanupTicker = time.NewTicker(1 * time.Minute)
 	go service.cleanupRoutine()
 
+	// Sample host load for backpressure
+	go service.backpressureRoutine()
+
 	return service, nil
 }
 
+// Defaults for the challenge store bounds when the auth config leaves them unset
+const (
+	defaultMaxChallenges           = 10000
+	defaultMaxChallengesPerAddress = 5
+)
+
+// Reload re-reads the security config from disk and applies it. Token buckets
+// are reset so new rates and burst sizes take effect immediately; bans,
+// greylists, challenges and in-flight quotas are preserved. The rate limit
+// store backend is fixed at startup; shared buckets pick up new rates on
+// their next refill.
+//
+// The service does not watch the file itself; the agent reloads it with
+// its other configuration files.
+func (s *SecurityService) Reload() error {
+	config, err := s.ReadConfig()
+	if err != nil {
+		return err
+	}
+	s.SetConfig(config)
+	return nil
+}
+
+// ReadConfig reads and checks the config file without applying it, so a
+// caller reloading several files can check them all first
+func (s *SecurityService) ReadConfig() (*SecurityConfig, error) {
+	config, err := loadConfig(s.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security config: %w", err)
+	}
+	return config, nil
+}
+
+// SetConfig applies a config returned by ReadConfig, as Reload does
+func (s *SecurityService) SetConfig(config *SecurityConfig) {
+	s.applyConfig(config)
+	s.logger.Info("security config reloaded", "path", s.configPath, "route_overrides", len(config.RateLimits.Routes))
+}
+
+// ApplyConfig applies security config YAML from a source other than the
+// config file, such as a signed remote copy. It takes effect like Reload and
+// stays in force until the next ApplyConfig or change to the config file.
+func (s *SecurityService) ApplyConfig(data []byte) error {
+	config, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse security config: %w", err)
+	}
+	s.applyConfig(config)
+
+	s.logger.Info("security config applied", "route_overrides", len(config.RateLimits.Routes))
+	return nil
+}
+
+// applyConfig swaps in config and resets token buckets so new rates take
+// effect immediately
+func (s *SecurityService) applyConfig(config *SecurityConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+	s.ipBuckets = make(map[string]*TokenBucket)
+	s.identityBuckets = make(map[string]*TokenBucket)
+}
+
+// getConfig returns the current config for callers not holding s.mu
+func (s *SecurityService) getConfig() *SecurityConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
 // loadConfig loads the security configuration from file
 func loadConfig(configPath string) (*SecurityConfig, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
+	return parseConfig(data)
+}
 
+// parseConfig decodes and checks security config YAML
+func parseConfig(data []byte) (*SecurityConfig, error) {
 	var config SecurityConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
+	if err := validateRouteClasses(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
 
+// validateRouteClasses rejects rate limit classes the agent does not know,
+// which would otherwise silently fall back to the global limits
+func validateRouteClasses(config *SecurityConfig) error {
+	known := func(class string) bool {
+		return class == RouteClassRead || class == RouteClassWrite || class == RouteClassHeavyCompute
+	}
+	for class := range config.RateLimits.Classes {
+		if !known(class) {
+			return fmt.Errorf("unknown rate limit class %q", class)
+		}
+	}
+	for _, route := range config.RateLimits.Routes {
+		if route.Class != "" && !known(route.Class) {
+			return fmt.Errorf("route %s: unknown rate limit class %q", route.Path, route.Class)
+		}
+	}
+	return nil
+}
+
 // cleanupRoutine periodically cleans up expired challenges and bans
 func (s *SecurityService) cleanupRoutine() {
 	for {
@@ -263,7 +492,22 @@ func (s *SecurityService) cleanup() {
 	// Clean up expired challenges
 	for nonce, challenge := range s.challenges {
 		if now.After(challenge.ExpiresAt) {
-			delete(s.challenges, nonce)
+			s.removeChallenge(nonce)
+		}
+	}
+	s.compactChallengeOrder()
+
+	// Clean up request nonces that can no longer be replayed
+	for key, expiresAt := range s.requestNonces {
+		if now.After(expiresAt) {
+			delete(s.requestNonces, key)
+		}
+	}
+
+	// Clean up cost windows that have ended
+	for identity, window := range s.costWindows {
+		if !now.Before(window.resetsAt) {
+			delete(s.costWindows, identity)
 		}
 	}
 
@@ -280,6 +524,7 @@ func (s *SecurityService) cleanup() {
 			delete(s.greylistedIPs, ip)
 		}
 	}
+	s.updateBlockGauges()
 }
 
 // Shutdown stops the security service
@@ -287,6 +532,16 @@ func (s *SecurityService) Shutdown() {
 	if s.cleanupTicker != nil {
 		s.cleanupTicker.Stop()
 	}
+	if s.limitStore != nil {
+		if err := s.limitStore.Close(); err != nil {
+			s.logger.Error("failed to close rate limit store", "error", err)
+		}
+	}
+	if s.exporter != nil {
+		if err := s.exporter.Close(); err != nil {
+			s.logger.Error("failed to close SIEM exporter", "error", err)
+		}
+	}
 	close(s.done)
 }
 
@@ -302,60 +557,278 @@ func getClientIP(r *http.Request) string {
 	return r.RemoteAddr
 }
 
+// resolveRateLimit returns the limits that apply to a request, preferring the
+// longest matching route override, then the limits of the request's route
+// class, and a method-specific burst when configured. A global method burst
+// and a class burst both cap the bucket, so the smaller applies; a route's
+// own method burst replaces both. Callers must hold s.mu.
+func (s *SecurityService) resolveRateLimit(r *http.Request) rateLimit {
+	global := s.config.RateLimits
+	limit := rateLimit{
+		perIPRPS:       global.PerIPRPS,
+		perIdentityRPS: global.PerIdentityRPS,
+		burst:          global.Burst,
+	}
+	if burst, ok := global.MethodBurst[r.Method]; ok && burst > 0 {
+		limit.burst = burst
+		limit.scope = r.Method
+	}
+
+	var route *RouteRateLimit
+	for i := range global.Routes {
+		candidate := &global.Routes[i]
+		if candidate.Path == "" || !pathHasPrefix(r.URL.Path, candidate.Path) {
+			continue
+		}
+		if route == nil || len(candidate.Path) > len(route.Path) {
+			route = candidate
+		}
+	}
+
+	class := routeClass(r, route)
+	if classLimit, ok := global.Classes[class]; ok {
+		limit.scope = "class:" + class
+		if classLimit.PerIPRPS > 0 {
+			limit.perIPRPS = classLimit.PerIPRPS
+		}
+		if classLimit.PerIdentityRPS > 0 {
+			limit.perIdentityRPS = classLimit.PerIdentityRPS
+		}
+		if classLimit.Burst > 0 {
+			if burst, ok := global.MethodBurst[r.Method]; ok && burst > 0 && burst < classLimit.Burst {
+				limit.scope += " " + r.Method
+			} else {
+				limit.burst = classLimit.Burst
+			}
+		}
+	}
+	if route == nil {
+		return limit
+	}
+
+	// A route that only names a class shares that class's buckets
+	classOnly := route.Class != "" && route.PerIPRPS == 0 && route.PerIdentityRPS == 0 &&
+		route.Burst == 0 && len(route.MethodBurst) == 0
+	if !classOnly {
+		limit.scope = route.Path
+	}
+	if route.PerIPRPS > 0 {
+		limit.perIPRPS = route.PerIPRPS
+	}
+	if route.PerIdentityRPS > 0 {
+		limit.perIdentityRPS = route.PerIdentityRPS
+	}
+	if route.Burst > 0 {
+		limit.burst = route.Burst
+	}
+	if burst, ok := route.MethodBurst[r.Method]; ok && burst > 0 {
+		limit.burst = burst
+		limit.scope = route.Path + " " + r.Method
+	}
+
+	return limit
+}
+
+// pathHasPrefix reports whether path is prefix or lies under it, so that a
+// rule for /api/v1/train does not also match /api/v1/training-jobs
+func pathHasPrefix(path, prefix string) bool {
+	rest, ok := strings.CutPrefix(path, prefix)
+	return ok && (rest == "" || rest[0] == '/' || strings.HasSuffix(prefix, "/"))
+}
+
+// routeClass returns the class named by the request's route, or read for
+// safe methods and write otherwise. CORS preflights never reach it; see
+// isPreflight.
+func routeClass(r *http.Request, route *RouteRateLimit) string {
+	if route != nil && route.Class != "" {
+		return route.Class
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RouteClassRead
+	default:
+		return RouteClassWrite
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight. Preflights carry no
+// credentials and do no work, so they are never rate limited, whether the
+// CORS middleware answers them or, with CORS off, the router refuses them.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// bucketKey scopes a bucket to the route override it was created for so that
+// strict routes do not share tokens with the global limit
+func bucketKey(scope, key string) string {
+	if scope == "" {
+		return key
+	}
+	return scope + "|" + key
+}
+
 // CheckRateLimit checks if the request should be rate limited
 func (s *SecurityService) CheckRateLimit(r *http.Request, identity string) (bool, time.Duration) {
+	if isPreflight(r) {
+		return true, 0
+	}
 	clientIP := getClientIP(r)
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.mu.RLock()
+	limit := s.resolveRateLimit(r)
+	greylistFor := time.Duration(s.config.Bans.GreylistSeconds) * time.Second
+	s.mu.RUnlock()
 
 	// Check if IP is banned
-	if banTime, banned := s.bannedIPs[clientIP]; banned {
-		if time.Now().Before(banTime) {
-			s.logSecurityEvent(r, identity, "rate_limited", "IP banned", map[string]int{"banned_until": int(banTime.Sub(time.Now()).Seconds())})
-			return false, banTime.Sub(time.Now())
-		}
-		delete(s.bannedIPs, clientIP)
+	if banTime := s.blockedUntil(r.Context(), BlockListBan, clientIP); !banTime.IsZero() {
+		rateLimitedTotal.WithLabelValues(rateLimitBanned).Inc()
+		s.logSecurityEvent(r, identity, "rate_limited", "IP banned", map[string]int{"banned_until": int(time.Until(banTime).Seconds())})
+		return false, time.Until(banTime)
 	}
 
 	// Check if IP is greylisted
-	if greylistTime, greylisted := s.greylistedIPs[clientIP]; greylisted {
-		if time.Now().Before(greylistTime) {
-			s.logSecurityEvent(r, identity, "rate_limited", "IP greylisted", map[string]int{"greylisted_until": int(greylistTime.Sub(time.Now()).Seconds())})
-			return false, greylistTime.Sub(time.Now())
+	if greylistTime := s.blockedUntil(r.Context(), BlockListGreylist, clientIP); !greylistTime.IsZero() {
+		rateLimitedTotal.WithLabelValues(rateLimitGreylisted).Inc()
+		s.logSecurityEvent(r, identity, "rate_limited", "IP greylisted", map[string]int{"greylisted_until": int(time.Until(greylistTime).Seconds())})
+		return false, time.Until(greylistTime)
+	}
+
+	// Check IP rate limit
+	if !s.takeToken(r.Context(), s.ipBuckets, "ip|"+bucketKey(limit.scope, clientIP), float64(limit.burst), float64(limit.perIPRPS)) {
+		s.block(r.Context(), BlockListGreylist, clientIP, time.Now().Add(greylistFor))
+		rateLimitedTotal.WithLabelValues(rateLimitIP).Inc()
+		s.logSecurityEvent(r, identity, "rate_limited", "IP rate limit exceeded", map[string]int{"ip_rps": limit.perIPRPS, "burst": limit.burst})
+		return false, greylistFor
+	}
+
+	// Check identity rate limit if identity is provided
+	if identity != "" {
+		if !s.takeToken(r.Context(), s.identityBuckets, "identity|"+bucketKey(limit.scope, identity), float64(limit.burst), float64(limit.perIdentityRPS)) {
+			rateLimitedTotal.WithLabelValues(rateLimitIdentity).Inc()
+			s.logSecurityEvent(r, identity, "rate_limited", "Identity rate limit exceeded", map[string]int{"identity_rps": limit.perIdentityRPS, "burst": limit.burst})
+			return false, greylistFor
+		}
+	}
+
+	return true, 0
+}
+
+// takeToken takes a token from the shared store when one is configured,
+// falling back to the in-process bucket if the store is unavailable
+func (s *SecurityService) takeToken(ctx context.Context, buckets map[string]*TokenBucket, key string, capacity, rate float64) bool {
+	if s.useLimitStore() {
+		ctx, cancel := context.WithTimeout(ctx, rateLimitStoreTimeout)
+		defer cancel()
+		allowed, err := s.limitStore.Take(ctx, key, capacity, rate)
+		if err == nil {
+			return allowed
 		}
-		delete(s.greylistedIPs, clientIP)
+		s.limitStoreFailed("take", err)
 	}
 
-	// Get or create IP bucket
-	ipBucket, exists := s.ipBuckets[clientIP]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, exists := buckets[key]
 	if !exists {
-		ipBucket = NewTokenBucket(float64(s.config.RateLimits.Burst), float64(s.config.RateLimits.PerIPRPS))
-		s.ipBuckets[clientIP] = ipBucket
+		bucket = NewTokenBucket(capacity, rate)
+		buckets[key] = bucket
 	}
+	return bucket.Take()
+}
 
-	// Check IP rate limit
-	if !ipBucket.Take() {
-		s.greylistedIPs[clientIP] = time.Now().Add(time.Duration(s.config.Bans.GreylistSeconds) * time.Second)
-		s.logSecurityEvent(r, identity, "rate_limited", "IP rate limit exceeded", map[string]int{"ip_rps": s.config.RateLimits.PerIPRPS})
-		return false, time.Duration(s.config.Bans.GreylistSeconds) * time.Second
+// block adds ip to a block list in the shared store and locally, so the entry
+// still applies on this replica if the store becomes unavailable
+func (s *SecurityService) block(ctx context.Context, list, ip string, until time.Time) {
+	if s.useLimitStore() {
+		ctx, cancel := context.WithTimeout(ctx, rateLimitStoreTimeout)
+		defer cancel()
+		if err := s.limitStore.Block(ctx, list, ip, until); err != nil {
+			s.limitStoreFailed("block", err)
+		}
 	}
 
-	// Check identity rate limit if identity is provided
-	if identity != "" {
-		identityBucket, exists := s.identityBuckets[identity]
-		if !exists {
-			identityBucket = NewTokenBucket(float64(s.config.RateLimits.Burst), float64(s.config.RateLimits.PerIdentityRPS))
-			s.identityBuckets[identity] = identityBucket
+	blocksTotal.WithLabelValues(list).Inc()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.localBlockList(list)[ip] = until
+	s.updateBlockGauges()
+}
+
+// blockedUntil returns when ip leaves a block list, or the zero time if it is
+// not currently listed in the shared store or locally
+func (s *SecurityService) blockedUntil(ctx context.Context, list, ip string) time.Time {
+	now := time.Now()
+	if s.useLimitStore() {
+		ctx, cancel := context.WithTimeout(ctx, rateLimitStoreTimeout)
+		defer cancel()
+		until, err := s.limitStore.BlockedUntil(ctx, list, ip)
+		if err != nil {
+			s.limitStoreFailed("check "+list, err)
+		} else if now.Before(until) {
+			return until
 		}
+	}
 
-		if !identityBucket.Take() {
-			s.logSecurityEvent(r, identity, "rate_limited", "Identity rate limit exceeded", map[string]int{"identity_rps": s.config.RateLimits.PerIdentityRPS})
-			return false, time.Duration(s.config.Bans.GreylistSeconds) * time.Second
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.localBlockList(list)
+	until, listed := entries[ip]
+	if !listed {
+		return time.Time{}
+	}
+	if now.Before(until) {
+		return until
+	}
+	delete(entries, ip)
+	s.updateBlockGauges()
+	return time.Time{}
+}
+
+// useLimitStore reports whether the shared store is configured and not backing off
+func (s *SecurityService) useLimitStore() bool {
+	return s.limitStore != nil && time.Now().UnixNano() >= s.storeDownUntil.Load()
+}
+
+// limitStoreFailed switches to local limits for a while after a store error
+func (s *SecurityService) limitStoreFailed(op string, err error) {
+	s.storeDownUntil.Store(time.Now().Add(rateLimitStoreBackoff).UnixNano())
+	s.logger.Warn("rate limit store unavailable, using local limits", "op", op, "retry_in", rateLimitStoreBackoff, "error", err)
+}
+
+// localBlockList returns the in-process map for a block list. Callers must hold s.mu.
+func (s *SecurityService) localBlockList(list string) map[string]time.Time {
+	if list == BlockListBan {
+		return s.bannedIPs
+	}
+	return s.greylistedIPs
+}
+
+// MaxBodyBytes returns the maximum body size allowed for a request, using the
+// longest matching route override, or 0 if bodies are unlimited
+func (s *SecurityService) MaxBodyBytes(r *http.Request) int64 {
+	limits := s.getConfig().RequestLimits
+
+	var route *RouteBodyLimit
+	for i := range limits.Routes {
+		candidate := &limits.Routes[i]
+		if candidate.Path == "" || !pathHasPrefix(r.URL.Path, candidate.Path) {
+			continue
+		}
+		if route == nil || len(candidate.Path) > len(route.Path) {
+			route = candidate
 		}
 	}
+	if route != nil && route.MaxBodySizeKB > 0 {
+		return int64(route.MaxBodySizeKB) * 1024
+	}
 
-	return true, 0
+	if limits.MaxBodySizeMB <= 0 {
+		return 0
+	}
+	return int64(limits.MaxBodySizeMB) * 1024 * 1024
 }
 
 // CheckConcurrencyQuota checks if the identity has exceeded concurrent job limits
@@ -369,6 +842,7 @@ func (s *SecurityService) CheckConcurrencyQuota(identity string) bool {
 	}
 
 	s.concurrentJobs[identity] = currentJobs + 1
+	s.updateConcurrentJobsGauge(identity)
 	return true
 }
 
@@ -379,36 +853,26 @@ func (s *SecurityService) ReleaseConcurrencyQuota(identity string) {
 
 	if currentJobs := s.concurrentJobs[identity]; currentJobs > 0 {
 		s.concurrentJobs[identity] = currentJobs - 1
+		s.updateConcurrentJobsGauge(identity)
 	}
 }
 
-// CheckBackpressure checks if the system is under high load
+// CheckBackpressure reports whether the host is under pressure, as computed
+// from the last host sample
 func (s *SecurityService) CheckBackpressure() bool {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	memUsageMB := int(m.Alloc / 1024 / 1024)
-
-	// Simple CPU check - in production you'd want more sophisticated monitoring
-	// For now, we'll use a simple heuristic based on goroutine count
-	cpuPressure := runtime.NumGoroutine() > 1000
-
-	if memUsageMB > s.config.Backpressure.MemHighWatermark || cpuPressure {
-		return true
-	}
-
-	return false
+	return s.BackpressureState().Active
 }
 
 // CreateChallenge creates a new authentication challenge
 func (s *SecurityService) CreateChallenge(address string) (*Challenge, error) {
-	nonceBytes := make([]byte, s.config.Auth.NonceLength)
+	config := s.getConfig()
+	nonceBytes := make([]byte, config.Auth.NonceLength)
 	if _, err := rand.Read(nonceBytes); err != nil {
 		return nil, err
 	}
 
 	nonce := hex.EncodeToString(nonceBytes)
-	expiresAt := time.Now().Add(time.Duration(s.config.Auth.ChallengeTimeoutSeconds) * time.Second)
+	expiresAt := time.Now().Add(time.Duration(config.Auth.ChallengeTimeoutSeconds) * time.Second)
 
 	challenge := &Challenge{
 		Nonce:     nonce,
@@ -418,12 +882,89 @@ func (s *SecurityService) CreateChallenge(address string) (*Challenge, error) {
 	}
 
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(address)
+	perAddress := config.Auth.MaxChallengesPerAddress
+	if perAddress <= 0 {
+		perAddress = defaultMaxChallengesPerAddress
+	}
+	if s.challengeCounts[key] >= perAddress {
+		challengesTotal.WithLabelValues(challengeRefused).Inc()
+		s.logger.Warn("challenge limit reached for address", "address", address, "limit", perAddress)
+		return nil, ErrTooManyChallenges
+	}
+
+	maxChallenges := config.Auth.MaxChallenges
+	if maxChallenges <= 0 {
+		maxChallenges = defaultMaxChallenges
+	}
+	// The store is full: make room by evicting the oldest challenges rather
+	// than refusing every new client
+	for len(s.challenges) >= maxChallenges {
+		if !s.evictOldestChallenge() {
+			break
+		}
+	}
+
 	s.challenges[nonce] = challenge
-	s.mu.Unlock()
+	s.challengeCounts[key]++
+	challengesTotal.WithLabelValues(challengeIssued).Inc()
+	outstandingChallenges.Set(float64(len(s.challenges)))
+	s.challengeOrder = append(s.challengeOrder, nonce)
+	if len(s.challengeOrder) > 2*maxChallenges {
+		s.compactChallengeOrder()
+	}
 
 	return challenge, nil
 }
 
+// removeChallenge deletes an outstanding challenge. The nonce is left in
+// challengeOrder and skipped when it reaches the front. Caller must hold s.mu.
+func (s *SecurityService) removeChallenge(nonce string) {
+	challenge, exists := s.challenges[nonce]
+	if !exists {
+		return
+	}
+	delete(s.challenges, nonce)
+	outstandingChallenges.Set(float64(len(s.challenges)))
+
+	key := strings.ToLower(challenge.Address)
+	if s.challengeCounts[key] <= 1 {
+		delete(s.challengeCounts, key)
+	} else {
+		s.challengeCounts[key]--
+	}
+}
+
+// evictOldestChallenge removes the oldest outstanding challenge and reports
+// whether one was found. Caller must hold s.mu.
+func (s *SecurityService) evictOldestChallenge() bool {
+	for len(s.challengeOrder) > 0 {
+		nonce := s.challengeOrder[0]
+		s.challengeOrder = s.challengeOrder[1:]
+		if challenge, exists := s.challenges[nonce]; exists {
+			s.removeChallenge(nonce)
+			challengesTotal.WithLabelValues(challengeEvicted).Inc()
+			s.logger.Info("evicted oldest challenge", "address", challenge.Address, "created_at", challenge.CreatedAt)
+			return true
+		}
+	}
+	return false
+}
+
+// compactChallengeOrder drops nonces of challenges that were verified or
+// expired. Caller must hold s.mu.
+func (s *SecurityService) compactChallengeOrder() {
+	order := make([]string, 0, len(s.challenges))
+	for _, nonce := range s.challengeOrder {
+		if _, exists := s.challenges[nonce]; exists {
+			order = append(order, nonce)
+		}
+	}
+	s.challengeOrder = order
+}
+
 // VerifyChallenge verifies an authentication challenge
 func (s *SecurityService) VerifyChallenge(nonce, signature string) (string, bool) {
 	s.mu.Lock()
@@ -431,25 +972,62 @@ func (s *SecurityService) VerifyChallenge(nonce, signature string) (string, bool
 
 	challenge, exists := s.challenges[nonce]
 	if !exists {
+		challengeVerificationsTotal.WithLabelValues(verificationUnknown).Inc()
 		return "", false
 	}
 
 	if time.Now().After(challenge.ExpiresAt) {
-		delete(s.challenges, nonce)
+		s.removeChallenge(nonce)
+		challengeVerificationsTotal.WithLabelValues(verificationExpired).Inc()
 		return "", false
 	}
 
-	// In a real implementation, you would verify the signature against the address
-	// For now, we'll use a simple hash-based verification
-	expectedHash := sha256.Sum256([]byte(nonce + challenge.Address))
-	expectedSignature := hex.EncodeToString(expectedHash[:])
+	// The client signs the nonce with personal_sign (EIP-191), so recover the
+	// signer from the prefixed message hash and compare it to the claimed address
+	signer, err := RecoverPersonalSignAddress([]byte(nonce), signature)
+	if err != nil {
+		challengeVerificationsTotal.WithLabelValues(verificationBadSig).Inc()
+		s.logger.Warn("challenge signature rejected", "address", challenge.Address, "error", err)
+		return "", false
+	}
 
-	if signature == expectedSignature {
-		delete(s.challenges, nonce)
-		return challenge.Address, true
+	if signer != common.HexToAddress(challenge.Address) {
+		challengeVerificationsTotal.WithLabelValues(verificationWrongOwner).Inc()
+		s.logger.Warn("challenge signer mismatch", "address", challenge.Address, "signer", signer.Hex())
+		return "", false
 	}
 
-	return "", false
+	s.removeChallenge(nonce)
+	challengeVerificationsTotal.WithLabelValues(verificationVerified).Inc()
+	return challenge.Address, true
+}
+
+// RecoverPersonalSignAddress recovers the address that produced an EIP-191
+// personal_sign signature over message. The signature is the 65-byte
+// hex-encoded [R || S || V] value returned by wallets, with V in {0,1,27,28}.
+func RecoverPersonalSignAddress(message []byte, signature string) (common.Address, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+
+	// Wallets return V as 27/28; go-ethereum expects the raw recovery id
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	if sig[crypto.RecoveryIDOffset] > 1 {
+		return common.Address{}, fmt.Errorf("invalid signature recovery id: %d", sig[crypto.RecoveryIDOffset])
+	}
+
+	pubKey, err := crypto.SigToPub(accounts.TextHash(message), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover public key: %w", err)
+	}
+
+	return crypto.PubkeyToAddress(*pubKey), nil
 }
 
 // logSecurityEvent logs a security event
@@ -466,16 +1044,33 @@ func (s *SecurityService) logSecurityEvent(r *http.Request, identity, decision,
 
 	eventJSON, _ := json.Marshal(event)
 	s.logger.Info("security_event", "event", string(eventJSON))
+
+	if s.exporter != nil {
+		s.exporter.Export(siemEvent{
+			Kind:      "security_event",
+			Timestamp: event.Timestamp,
+			IP:        event.IP,
+			Identity:  event.Identity,
+			Route:     event.Route,
+			Method:    r.Method,
+			Decision:  event.Decision,
+			Reason:    event.Reason,
+			Counters:  event.Counters,
+		})
+	}
 }
 
 // CheckRequestQueue checks if a request can be queued
 func (s *SecurityService) CheckRequestQueue() bool {
-	return s.requestQueue.TryAcquire()
+	acquired := s.requestQueue.TryAcquire()
+	requestQueueDepth.Set(float64(s.requestQueue.GetQueueDepth()))
+	return acquired
 }
 
 // ReleaseRequestQueue releases a request slot
 func (s *SecurityService) ReleaseRequestQueue() {
 	s.requestQueue.Release()
+	requestQueueDepth.Set(float64(s.requestQueue.GetQueueDepth()))
 }
 
 // GetQueueStats returns current queue statistics
@@ -483,13 +1078,12 @@ func (s *SecurityService) GetQueueStats() (depth, capacity int) {
 	return s.requestQueue.GetQueueDepth(), s.requestQueue.GetCapacity()
 }
 
-// LogRefusedRequest logs a structured refused request event
+// LogRefusedRequest logs a structured refused request event and counts it
+// by reason
 func (s *SecurityService) LogRefusedRequest(r *http.Request, identity, reason string) {
+	refusedRequestsTotal.WithLabelValues(reason).Inc()
 	queueDepth, queueCapacity := s.GetQueueStats()
 
-	// Check if request was rate limited
-	rateLimited, _ := s.CheckRateLimit(r, identity)
-
 	// Check if system is under backpressure
 	backpressure := s.CheckBackpressure()
 
@@ -509,7 +1103,7 @@ func (s *SecurityService) LogRefusedRequest(r *http.Request, identity, reason st
 		Reason:        reason,
 		QueueDepth:    queueDepth,
 		QueueCapacity: queueCapacity,
-		RateLimited:   !rateLimited,
+		RateLimited:   reason == "rate_limited",
 		Backpressure:  backpressure,
 		TraceID:       traceID,
 	}
@@ -527,6 +1121,24 @@ func (s *SecurityService) LogRefusedRequest(r *http.Request, identity, reason st
 		"backpressure", event.Backpressure,
 		"trace_id", event.TraceID,
 	)
+
+	if s.exporter != nil {
+		s.exporter.Export(siemEvent{
+			Kind:      "refused_request",
+			Timestamp: event.Timestamp,
+			IP:        event.IP,
+			Identity:  event.Identity,
+			Route:     event.Route,
+			Method:    event.Method,
+			Decision:  "refused",
+			Reason:    event.Reason,
+			TraceID:   event.TraceID,
+			Counters: map[string]int{
+				"queue_depth":    event.QueueDepth,
+				"queue_capacity": event.QueueCapacity,
+			},
+		})
+	}
 }
 
 // min returns the minimum of two integers
@@ -0,0 +1,413 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SIEMConfig configures forwarding of security events to an external collector
+type SIEMConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Format is "cef" or "json"
+	Format string `yaml:"format"`
+	// Transport is "syslog" or "http"
+	Transport string `yaml:"transport"`
+	// Address is the syslog endpoint, e.g. udp://siem:514 or tcp://siem:601
+	Address string `yaml:"address"`
+	// URL is the HTTP collector endpoint
+	URL string `yaml:"url"`
+	// AuthHeader is sent as the Authorization header to the HTTP collector.
+	// It may also be provided via SIEM_AUTH_HEADER.
+	AuthHeader           string `yaml:"auth_header"`
+	BatchSize            int    `yaml:"batch_size"`
+	FlushIntervalSeconds int    `yaml:"flush_interval_seconds"`
+	MaxRetries           int    `yaml:"max_retries"`
+	QueueSize            int    `yaml:"queue_size"`
+}
+
+// siemEvent is the common form of SecurityEvent and RefusedRequestEvent
+type siemEvent struct {
+	Kind      string         `json:"kind"`
+	Timestamp time.Time      `json:"ts"`
+	IP        string         `json:"ip"`
+	Identity  string         `json:"identity,omitempty"`
+	Route     string         `json:"route"`
+	Method    string         `json:"method,omitempty"`
+	Decision  string         `json:"decision"`
+	Reason    string         `json:"reason"`
+	TraceID   string         `json:"trace_id,omitempty"`
+	Counters  map[string]int `json:"counters,omitempty"`
+}
+
+// siemSink delivers a batch of encoded events
+type siemSink interface {
+	Send(ctx context.Context, lines [][]byte) error
+	Close() error
+}
+
+// SIEMExporter batches security events and forwards them with retries. Export
+// never blocks request handling; events are dropped when the queue is full.
+type SIEMExporter struct {
+	config  SIEMConfig
+	sink    siemSink
+	logger  *slog.Logger
+	queue   chan siemEvent
+	dropped atomic.Int64
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSIEMExporter creates an exporter for config and starts its flush loop
+func NewSIEMExporter(config SIEMConfig, logger *slog.Logger) (*SIEMExporter, error) {
+	if config.Format == "" {
+		config.Format = "cef"
+	}
+	if config.Format != "cef" && config.Format != "json" {
+		return nil, fmt.Errorf("unsupported SIEM format: %q", config.Format)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushIntervalSeconds <= 0 {
+		config.FlushIntervalSeconds = 5
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if header := os.Getenv("SIEM_AUTH_HEADER"); header != "" {
+		config.AuthHeader = header
+	}
+
+	var sink siemSink
+	switch config.Transport {
+	case "syslog":
+		s, err := newSyslogSink(config.Address)
+		if err != nil {
+			return nil, err
+		}
+		sink = s
+	case "http":
+		if config.URL == "" {
+			return nil, fmt.Errorf("SIEM http transport requires a url")
+		}
+		sink = &httpSink{url: config.URL, format: config.Format, authHeader: config.AuthHeader, client: &http.Client{Timeout: 10 * time.Second}}
+	default:
+		return nil, fmt.Errorf("unsupported SIEM transport: %q", config.Transport)
+	}
+
+	exporter := &SIEMExporter{
+		config: config,
+		sink:   sink,
+		logger: logger,
+		queue:  make(chan siemEvent, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+
+	exporter.wg.Add(1)
+	go exporter.run()
+
+	return exporter, nil
+}
+
+// Export queues an event for delivery
+func (e *SIEMExporter) Export(event siemEvent) {
+	select {
+	case e.queue <- event:
+	default:
+		if e.dropped.Add(1)%100 == 1 {
+			e.logger.Warn("SIEM export queue full, dropping security events", "dropped_total", e.dropped.Load())
+		}
+	}
+}
+
+// Dropped returns the number of events discarded because the queue was full
+func (e *SIEMExporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+// Close flushes queued events and closes the sink
+func (e *SIEMExporter) Close() error {
+	close(e.done)
+	e.wg.Wait()
+	return e.sink.Close()
+}
+
+// run batches queued events and flushes them on size or interval
+func (e *SIEMExporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(e.config.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]siemEvent, 0, e.config.BatchSize)
+	for {
+		select {
+		case event := <-e.queue:
+			batch = append(batch, event)
+			if len(batch) >= e.config.BatchSize {
+				e.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.flush(batch)
+				batch = batch[:0]
+			}
+		case <-e.done:
+			// Drain whatever is already queued before exiting
+			for {
+				select {
+				case event := <-e.queue:
+					batch = append(batch, event)
+				default:
+					if len(batch) > 0 {
+						e.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush encodes and sends a batch, retrying with exponential backoff
+func (e *SIEMExporter) flush(batch []siemEvent) {
+	lines := make([][]byte, 0, len(batch))
+	for _, event := range batch {
+		line, err := e.encode(event)
+		if err != nil {
+			e.logger.Error("failed to encode security event for SIEM", "error", err)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := e.sink.Send(ctx, lines)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= e.config.MaxRetries {
+			e.logger.Error("failed to export security events to SIEM", "events", len(lines), "attempts", attempt+1, "error", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// encode renders an event in the configured format
+func (e *SIEMExporter) encode(event siemEvent) ([]byte, error) {
+	if e.config.Format == "json" {
+		return json.Marshal(event)
+	}
+	return []byte(formatCEF(event)), nil
+}
+
+// formatCEF renders an event as an ArcSight Common Event Format record
+func formatCEF(event siemEvent) string {
+	signatureID := event.Kind + ":" + event.Decision
+	severity := 5
+	if event.Decision == "rate_limited" || event.Kind == "refused_request" {
+		severity = 3
+	}
+	if strings.Contains(strings.ToLower(event.Reason), "banned") {
+		severity = 7
+	}
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(event.Timestamp.UnixMilli(), 10),
+		"src=" + cefValue(hostOnly(event.IP)),
+		"request=" + cefValue(event.Route),
+		"act=" + cefValue(event.Decision),
+		"reason=" + cefValue(event.Reason),
+	}
+	if event.Method != "" {
+		ext = append(ext, "requestMethod="+cefValue(event.Method))
+	}
+	if event.Identity != "" {
+		ext = append(ext, "suser="+cefValue(event.Identity))
+	}
+	if event.TraceID != "" {
+		ext = append(ext, "cs1Label=traceId", "cs1="+cefValue(event.TraceID))
+	}
+
+	// Sort counters so identical events encode identically
+	keys := make([]string, 0, len(event.Counters))
+	for k := range event.Counters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i >= 3 {
+			break
+		}
+		n := strconv.Itoa(i + 1)
+		ext = append(ext, "cn"+n+"Label="+cefValue(k), "cn"+n+"="+strconv.Itoa(event.Counters[k]))
+	}
+
+	return fmt.Sprintf("CEF:0|Pandacea|agent-backend|1.0|%s|%s|%d|%s",
+		cefHeader(signatureID), cefHeader(event.Reason), severity, strings.Join(ext, " "))
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// cefValue escapes a CEF extension value
+func cefValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "=", `\=`)
+	s = strings.ReplaceAll(s, "\r", `\r`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// hostOnly strips the port from a host:port address
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// syslogSink writes RFC 5424 messages over UDP or TCP
+type syslogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	mu       sync.Mutex
+}
+
+// newSyslogSink parses a udp:// or tcp:// syslog address
+func newSyslogSink(address string) (*syslogSink, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q: expected udp://host:port or tcp://host:port", address)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network: %q", u.Scheme)
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	return &syslogSink{network: u.Scheme, address: u.Host, hostname: hostname}, nil
+}
+
+// Send writes each line as a syslog message, reconnecting on failure
+func (ss *syslogSink) Send(ctx context.Context, lines [][]byte) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, ss.network, ss.address)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		ss.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ss.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, line := range lines {
+		// <86> is facility authpriv (10) at severity informational (6)
+		msg := fmt.Sprintf("<86>1 %s %s pandacea-agent %d - - %s",
+			time.Now().UTC().Format(time.RFC3339Nano), ss.hostname, os.Getpid(), line)
+		if ss.network == "tcp" {
+			// Octet-counting framing (RFC 6587) so messages may contain newlines
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := ss.conn.Write([]byte(msg)); err != nil {
+			ss.conn.Close()
+			ss.conn = nil
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the syslog connection
+func (ss *syslogSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.conn == nil {
+		return nil
+	}
+	err := ss.conn.Close()
+	ss.conn = nil
+	return err
+}
+
+// httpSink posts batches to an HTTP collector: a JSON array for the json
+// format, newline-delimited records for cef
+type httpSink struct {
+	url        string
+	format     string
+	authHeader string
+	client     *http.Client
+}
+
+// Send posts a batch to the collector
+func (hs *httpSink) Send(ctx context.Context, lines [][]byte) error {
+	var body []byte
+	contentType := "text/plain"
+	if hs.format == "json" {
+		body = append([]byte("["), bytes.Join(lines, []byte(","))...)
+		body = append(body, ']')
+		contentType = "application/json"
+	} else {
+		body = append(bytes.Join(lines, []byte("\n")), '\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hs.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if hs.authHeader != "" {
+		req.Header.Set("Authorization", hs.authHeader)
+	}
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to collector: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close is a no-op for the HTTP sink
+func (hs *httpSink) Close() error {
+	return nil
+}
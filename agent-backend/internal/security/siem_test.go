@@ -0,0 +1,127 @@
+package security
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFormatCEF(t *testing.T) {
+	event := siemEvent{
+		Kind:      "security_event",
+		Timestamp: time.UnixMilli(1700000000000),
+		IP:        "10.0.0.1:5555",
+		Identity:  "0xabc",
+		Route:     "/api/v1/train?x=1",
+		Method:    "POST",
+		Decision:  "rate_limited",
+		Reason:    "IP rate limit | exceeded",
+		Counters:  map[string]int{"ip_rps": 5, "burst": 10},
+	}
+
+	got := formatCEF(event)
+	want := `CEF:0|Pandacea|agent-backend|1.0|security_event:rate_limited|IP rate limit \| exceeded|3|` +
+		`rt=1700000000000 src=10.0.0.1 request=/api/v1/train?x\=1 act=rate_limited reason=IP rate limit | exceeded ` +
+		`requestMethod=POST suser=0xabc cn1Label=burst cn1=10 cn2Label=ip_rps cn2=5`
+	if got != want {
+		t.Errorf("formatCEF() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSIEMExporterHTTPRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var mu sync.Mutex
+	var received []siemEvent
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first delivery to exercise the retry path
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var batch []siemEvent
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter, err := NewSIEMExporter(SIEMConfig{
+		Format:     "json",
+		Transport:  "http",
+		URL:        collector.URL,
+		AuthHeader: "Bearer token",
+		BatchSize:  2,
+		MaxRetries: 2,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewSIEMExporter() error = %v", err)
+	}
+
+	for _, reason := range []string{"a", "b", "c"} {
+		exporter.Export(siemEvent{Kind: "security_event", Reason: reason})
+	}
+	// Close flushes the final partial batch
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 {
+		t.Fatalf("collector received %d events, want 3", len(received))
+	}
+	for i, reason := range []string{"a", "b", "c"} {
+		if received[i].Reason != reason {
+			t.Errorf("event %d reason = %q, want %q", i, received[i].Reason, reason)
+		}
+	}
+}
+
+func TestSIEMExporterSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	exporter, err := NewSIEMExporter(SIEMConfig{
+		Transport: "syslog",
+		Address:   "udp://" + conn.LocalAddr().String(),
+		BatchSize: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewSIEMExporter() error = %v", err)
+	}
+	defer exporter.Close()
+
+	exporter.Export(siemEvent{Kind: "refused_request", Decision: "refused", Reason: "queue_full", IP: "10.0.0.9"})
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no syslog message received: %v", err)
+	}
+
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<86>1 ") || !strings.Contains(msg, "CEF:0|Pandacea|agent-backend|1.0|refused_request:refused|queue_full|3|") {
+		t.Errorf("unexpected syslog message: %s", msg)
+	}
+}
@@ -0,0 +1,593 @@
+// Package spender leases data products from other agents. It finds the
+// agent offering a product through the market, sends that agent a signed
+// lease proposal, pays for the lease with createLease from the agent's own
+// account and follows the lease until the earner approves it. The same
+// binary can then serve leases as an earner and take them as a spender.
+package spender
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/contracts"
+	"pandacea/agent-backend/internal/fsutil"
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/shopspring/decimal"
+)
+
+// TxActionCreateLease is the action createLease transactions are sent for
+const TxActionCreateLease = "lease.create"
+
+// maxResponseSize bounds what is read from an earner agent
+const maxResponseSize = 1 << 20
+
+// weiPerEther converts ether prices to wei
+var weiPerEther = decimal.New(1, 18)
+
+// Errors returned by Propose. Callers branch on them with errors.Is; the
+// wrapped messages carry the details.
+var (
+	ErrInvalidRequest = errors.New("invalid outbound lease request")
+	ErrNoOffer        = errors.New("no agent offers the product")
+	ErrUnreachable    = errors.New("earner agent unreachable")
+)
+
+// Status is where an outbound lease is in its lifecycle
+type Status string
+
+// Outbound lease statuses. Rejected, failed and expired are final.
+const (
+	StatusRejected  Status = "rejected"  // The earner agent refused the proposal
+	StatusSubmitted Status = "submitted" // createLease is queued or pending
+	StatusCreated   Status = "created"   // The lease is on chain, waiting for the earner's approval
+	StatusApproved  Status = "approved"  // The earner approved the lease
+	StatusExpired   Status = "expired"   // The lease's term has run out
+	StatusFailed    Status = "failed"    // createLease could not be sent or reverted
+)
+
+// final reports whether s is a final status
+func (s Status) final() bool {
+	return s == StatusRejected || s == StatusFailed || s == StatusExpired
+}
+
+// Request asks to lease a product from another agent
+type Request struct {
+	ProductID     string `json:"productId"`
+	PeerID        string `json:"peerId,omitempty"` // Agent to lease from; the cheapest offer when empty
+	MaxPrice      string `json:"maxPrice"`         // In ether
+	Duration      string `json:"duration"`         // e.g. 24h or 7d
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// Purpose and PurposeCategory declare what the data will be used for.
+	// The earner binds computations under the lease to the category.
+	Purpose         string `json:"purpose,omitempty"`
+	PurposeCategory string `json:"purposeCategory,omitempty"`
+}
+
+// Proposal tracks one outbound lease from proposal to approval
+type Proposal struct {
+	ID        string `json:"id"`
+	Status    Status `json:"status"`
+	ProductID string `json:"productId"`
+	PeerID    string `json:"peerId"`
+	APIURL    string `json:"apiUrl"`
+	Earner    string `json:"earner"`
+	MaxPrice  string `json:"maxPrice"`
+	Price     string `json:"price,omitempty"` // Paid with createLease, in wei
+	Duration  string `json:"duration"`
+	// LeaseProposalID is the earner agent's ID for the proposal, and
+	// RemoteStatus the status it last reported for the lease
+	LeaseProposalID string    `json:"leaseProposalId,omitempty"`
+	RemoteStatus    string    `json:"remoteStatus,omitempty"`
+	TxID            string    `json:"txId,omitempty"`
+	TxHash          string    `json:"txHash,omitempty"`
+	LeaseID         string    `json:"leaseId,omitempty"` // On-chain lease ID, from its LeaseCreated event
+	Error           string    `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// Query selects outbound leases. Empty fields match everything.
+type Query struct {
+	Status    Status
+	ProductID string
+}
+
+// Finder searches the network for product offers
+type Finder interface {
+	Search(ctx context.Context, q market.Query, sortBy string) (market.Result, error)
+}
+
+// Sender sends transactions from the agent's account
+type Sender interface {
+	Address() common.Address
+	Submit(req txmgr.Request) (txmgr.Record, error)
+	Get(id string) (txmgr.Record, bool)
+}
+
+// Config tunes outbound leases
+type Config struct {
+	Contract       common.Address   // LeaseAgreement contract createLease is sent to
+	MaxPrice       *decimal.Decimal // Most one lease may pay, in ether (nil = unbounded)
+	RequestTimeout time.Duration    // How long an earner agent has to answer
+}
+
+// Manager proposes, pays for and follows outbound leases. It is safe for
+// concurrent use.
+type Manager struct {
+	finder Finder
+	sender Sender
+	key    crypto.PrivKey
+	cfg    Config
+	client *http.Client
+	path   string
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	proposals map[string]*Proposal
+}
+
+// New creates a manager that finds offers with finder, signs proposals with
+// the agent's P2P key and pays through sender. Outbound leases are
+// persisted to path unless it is empty.
+func New(finder Finder, sender Sender, key crypto.PrivKey, cfg Config, path string, logger *slog.Logger) (*Manager, error) {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 10 * time.Second
+	}
+	m := &Manager{
+		finder:    finder,
+		sender:    sender,
+		key:       key,
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.RequestTimeout},
+		path:      path,
+		logger:    logger,
+		proposals: make(map[string]*Proposal),
+	}
+
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbound leases: %w", err)
+	}
+	var proposals []*Proposal
+	if err := json.Unmarshal(data, &proposals); err != nil {
+		return nil, fmt.Errorf("failed to parse outbound leases: %w", err)
+	}
+	for _, p := range proposals {
+		m.proposals[p.ID] = p
+	}
+	return m, nil
+}
+
+// Propose finds an agent offering req's product within its price, sends it
+// the lease proposal and, if the agent accepts it, queues createLease. A
+// proposal the agent refuses is kept as rejected and returned without error.
+func (m *Manager) Propose(ctx context.Context, req Request) (Proposal, error) {
+	maxWei, productID, err := m.check(req)
+	if err != nil {
+		return Proposal{}, err
+	}
+	offer, price, err := m.find(ctx, req, maxWei)
+	if err != nil {
+		return Proposal{}, err
+	}
+
+	now := time.Now().UTC()
+	p := &Proposal{
+		ID:        newID(),
+		ProductID: req.ProductID,
+		PeerID:    offer.PeerID,
+		APIURL:    offer.APIURL,
+		Earner:    common.HexToAddress(offer.Earner).Hex(),
+		MaxPrice:  req.MaxPrice,
+		Price:     price.String(),
+		Duration:  req.Duration,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	leaseProposalID, refusal, err := m.send(ctx, offer.APIURL, req)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if refusal != "" {
+		p.Status, p.Error = StatusRejected, refusal
+		m.logger.Info("earner agent rejected outbound lease", "product_id", req.ProductID, "peer_id", offer.PeerID, "reason", refusal)
+		return m.add(p), nil
+	}
+	p.LeaseProposalID = leaseProposalID
+
+	parsed, err := contracts.LeaseAgreementMetaData.GetAbi()
+	if err != nil {
+		return Proposal{}, fmt.Errorf("failed to parse lease agreement ABI: %w", err)
+	}
+	data, err := parsed.Pack("createLease", common.HexToAddress(offer.Earner), productID, maxWei)
+	if err != nil {
+		return Proposal{}, fmt.Errorf("failed to encode createLease: %w", err)
+	}
+	rec, err := m.sender.Submit(txmgr.Request{
+		Action:    TxActionCreateLease,
+		Reference: p.ID,
+		To:        m.cfg.Contract,
+		Data:      data,
+		Value:     price,
+	})
+	if err != nil {
+		p.Status, p.Error = StatusFailed, err.Error()
+		m.add(p)
+		return Proposal{}, err
+	}
+	p.Status, p.TxID = StatusSubmitted, rec.ID
+	m.logger.Info("outbound lease proposed", "id", p.ID, "product_id", req.ProductID, "peer_id", offer.PeerID, "price", p.Price, "tx_id", rec.ID)
+	return m.add(p), nil
+}
+
+// check validates req and returns its price cap in wei and the product's
+// on-chain ID
+func (m *Manager) check(req Request) (*big.Int, [32]byte, error) {
+	if req.ProductID == "" || req.MaxPrice == "" || req.Duration == "" {
+		return nil, [32]byte{}, fmt.Errorf("%w: productId, maxPrice and duration are required", ErrInvalidRequest)
+	}
+	productID, err := chain.EncodeProductID(req.ProductID)
+	if err != nil {
+		return nil, [32]byte{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	maxPrice, err := decimal.NewFromString(req.MaxPrice)
+	if err != nil || !maxPrice.IsPositive() {
+		return nil, [32]byte{}, fmt.Errorf("%w: maxPrice must be a positive price in ether", ErrInvalidRequest)
+	}
+	if m.cfg.MaxPrice != nil && maxPrice.GreaterThan(*m.cfg.MaxPrice) {
+		return nil, [32]byte{}, fmt.Errorf("%w: maxPrice is above spender.max_price of %s", ErrInvalidRequest, m.cfg.MaxPrice)
+	}
+	return maxPrice.Mul(weiPerEther).Floor().BigInt(), productID, nil
+}
+
+// find returns the cheapest offer of req's product that can take a
+// proposal and costs at most maxWei, with the price to pay. Offers without
+// a price are paid maxWei.
+func (m *Manager) find(ctx context.Context, req Request, maxWei *big.Int) (market.Listing, *big.Int, error) {
+	result, err := m.finder.Search(ctx, market.Query{Text: req.ProductID}, market.SortPrice)
+	if err != nil {
+		return market.Listing{}, nil, err
+	}
+	offered := false
+	for _, l := range result.Listings {
+		if l.ProductID != req.ProductID || l.Local || (req.PeerID != "" && l.PeerID != req.PeerID) {
+			continue
+		}
+		if l.APIURL == "" || !common.IsHexAddress(l.Earner) {
+			continue
+		}
+		offered = true
+		price := new(big.Int).Set(maxWei)
+		if l.Price != "" {
+			quoted, ok := new(big.Int).SetString(l.Price, 10)
+			if !ok || quoted.Cmp(maxWei) > 0 {
+				continue
+			}
+			price = quoted
+		}
+		return l, price, nil
+	}
+	if offered {
+		return market.Listing{}, nil, fmt.Errorf("%w: every offer of %s costs more than maxPrice", ErrNoOffer, req.ProductID)
+	}
+	return market.Listing{}, nil, fmt.Errorf("%w: %s", ErrNoOffer, req.ProductID)
+}
+
+// send posts the lease proposal to the earner agent at apiURL. It returns
+// the agent's proposal ID, or the reason the agent refused it.
+func (m *Manager) send(ctx context.Context, apiURL string, req Request) (string, string, error) {
+	body, err := json.Marshal(map[string]string{
+		"productId":       req.ProductID,
+		"maxPrice":        req.MaxPrice,
+		"duration":        req.Duration,
+		"encryptionKey":   req.EncryptionKey,
+		"purpose":         req.Purpose,
+		"purposeCategory": req.PurposeCategory,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode lease proposal: %w", err)
+	}
+	var accepted struct {
+		LeaseProposalID string `json:"leaseProposalId"`
+	}
+	status, refusal, err := m.do(ctx, http.MethodPost, apiURL+"/api/v1/leases", body, &accepted)
+	if err != nil {
+		return "", "", err
+	}
+	if status != http.StatusAccepted {
+		return "", refusal, nil
+	}
+	return accepted.LeaseProposalID, "", nil
+}
+
+// do sends a signed request to an earner agent and decodes a successful
+// response into v. Unsuccessful responses are returned as their status and
+// error message; only failures to reach the agent are errors.
+func (m *Manager) do(ctx context.Context, method, target string, body []byte, v any) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Earners score spenders by their on-chain address
+	req.Header.Set("X-Pandacea-Spender-Address", m.sender.Address().Hex())
+	if err := reqsig.Sign(m.key, req, body, time.Now()); err != nil {
+		return 0, "", err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	reader := io.LimitReader(resp.Body, maxResponseSize)
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(reader).Decode(&apiErr) == nil && apiErr.Error.Message != "" {
+			return resp.StatusCode, apiErr.Error.Message, nil
+		}
+		return resp.StatusCode, resp.Status, nil
+	}
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return 0, "", fmt.Errorf("%w: invalid response: %v", ErrUnreachable, err)
+	}
+	return resp.StatusCode, "", nil
+}
+
+// Get returns outbound lease id
+func (m *Manager) Get(id string) (Proposal, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.proposals[id]
+	if !ok {
+		return Proposal{}, false
+	}
+	return *p, true
+}
+
+// List returns the outbound leases q selects, newest first
+func (m *Manager) List(q Query) []Proposal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	proposals := []Proposal{}
+	for _, p := range m.proposals {
+		if q.Status != "" && p.Status != q.Status {
+			continue
+		}
+		if q.ProductID != "" && p.ProductID != q.ProductID {
+			continue
+		}
+		proposals = append(proposals, *p)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].CreatedAt.After(proposals[j].CreatedAt) })
+	return proposals
+}
+
+// RecordLeaseCreated matches a LeaseCreated event to the outbound lease
+// whose createLease transaction emitted it. Events of other spenders'
+// leases are ignored.
+func (m *Manager) RecordLeaseCreated(txHash, leaseID, spender string) {
+	if !strings.EqualFold(spender, m.sender.Address().Hex()) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.proposals {
+		if p.TxID == "" || p.LeaseID != "" {
+			continue
+		}
+		rec, ok := m.sender.Get(p.TxID)
+		if !ok || !sentAs(rec, txHash) {
+			continue
+		}
+		m.update(p, func(p *Proposal) {
+			p.LeaseID, p.TxHash = leaseID, txHash
+			if p.Status == StatusSubmitted {
+				p.Status = StatusCreated
+			}
+		})
+		m.logger.Info("outbound lease created", "id", p.ID, "lease_id", leaseID)
+		return
+	}
+}
+
+// sentAs reports whether txHash is one of the attempts to send rec
+func sentAs(rec txmgr.Record, txHash string) bool {
+	if rec.Receipt != nil && strings.EqualFold(rec.Receipt.TxHash, txHash) {
+		return true
+	}
+	for _, hash := range rec.Hashes {
+		if strings.EqualFold(hash, txHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run follows outbound leases every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.refresh(ctx)
+		}
+	}
+}
+
+// refresh follows every unfinished outbound lease: its createLease
+// transaction, then the status the earner agent reports for it
+func (m *Manager) refresh(ctx context.Context) {
+	m.mu.Lock()
+	var open []Proposal
+	for _, p := range m.proposals {
+		if !p.Status.final() {
+			open = append(open, *p)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, p := range open {
+		if p.Status == StatusSubmitted {
+			m.followTransaction(p)
+		}
+		m.followEarner(ctx, p)
+	}
+}
+
+// followTransaction records the outcome of p's createLease transaction
+func (m *Manager) followTransaction(p Proposal) {
+	rec, ok := m.sender.Get(p.TxID)
+	if !ok {
+		return
+	}
+	switch rec.Status {
+	case txmgr.StatusConfirmed:
+		m.set(p.ID, func(p *Proposal) {
+			p.Status = StatusCreated
+			if rec.Receipt != nil {
+				p.TxHash = rec.Receipt.TxHash
+			}
+		})
+	case txmgr.StatusReverted, txmgr.StatusFailed:
+		m.set(p.ID, func(p *Proposal) {
+			p.Status, p.Error = StatusFailed, "createLease "+string(rec.Status)
+			if rec.Error != "" {
+				p.Error += ": " + rec.Error
+			}
+		})
+		m.logger.Warn("outbound lease transaction did not succeed", "id", p.ID, "tx_id", p.TxID, "status", rec.Status)
+	}
+}
+
+// followEarner asks the earner agent for the lease's status. Before the
+// lease is on chain that is the proposal's; afterwards the earner tracks
+// the lease under lease_prop_<lease ID>.
+func (m *Manager) followEarner(ctx context.Context, p Proposal) {
+	id := p.LeaseProposalID
+	if p.LeaseID != "" {
+		id = "lease_prop_" + strings.TrimPrefix(strings.ToLower(p.LeaseID), "0x")
+	}
+	if id == "" {
+		return
+	}
+	var state struct {
+		Status string `json:"status"`
+	}
+	status, _, err := m.do(ctx, http.MethodGet, p.APIURL+"/api/v1/leases/"+url.PathEscape(id), nil, &state)
+	if err != nil || status != http.StatusOK {
+		m.logger.Debug("failed to get outbound lease status from earner agent", "id", p.ID, "status", status, "error", err)
+		return
+	}
+	m.set(p.ID, func(p *Proposal) {
+		p.RemoteStatus = state.Status
+		switch {
+		case p.LeaseID == "":
+		case state.Status == "approved":
+			p.Status = StatusApproved
+		case state.Status == "expired":
+			p.Status = StatusExpired
+		}
+	})
+}
+
+// add stores a new outbound lease and returns a copy of it
+func (m *Manager) add(p *Proposal) Proposal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.proposals[p.ID] = p
+	if err := m.save(); err != nil {
+		m.logger.Error("failed to save outbound leases", "error", err)
+	}
+	return *p
+}
+
+// set applies fn to outbound lease id
+func (m *Manager) set(id string, fn func(p *Proposal)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.proposals[id]; ok {
+		m.update(p, fn)
+	}
+}
+
+// update applies fn to p and saves. Caller must hold m.mu.
+func (m *Manager) update(p *Proposal, fn func(p *Proposal)) {
+	before := *p
+	fn(p)
+	if *p == before {
+		return
+	}
+	p.UpdatedAt = time.Now().UTC()
+	if err := m.save(); err != nil {
+		m.logger.Error("failed to save outbound leases", "error", err)
+	}
+}
+
+// save writes the outbound leases atomically. Caller must hold m.mu.
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	proposals := make([]*Proposal, 0, len(m.proposals))
+	for _, p := range m.proposals {
+		proposals = append(proposals, p)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].CreatedAt.Before(proposals[j].CreatedAt) })
+	data, err := json.Marshal(proposals)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbound leases: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return fmt.Errorf("failed to create outbound leases directory: %w", err)
+	}
+	if err := fsutil.WriteFile(m.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write outbound leases: %w", err)
+	}
+	return nil
+}
+
+// newID returns a random outbound lease ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "out_" + hex.EncodeToString(b)
+}
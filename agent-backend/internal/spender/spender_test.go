@@ -0,0 +1,162 @@
+package spender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"pandacea/agent-backend/internal/market"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/txmgr"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// staticFinder returns the same listings for every search
+type staticFinder []market.Listing
+
+func (f staticFinder) Search(ctx context.Context, q market.Query, sortBy string) (market.Result, error) {
+	return market.Result{Listings: f}, nil
+}
+
+// recordingSender keeps submitted transactions instead of sending them
+type recordingSender struct {
+	mu      sync.Mutex
+	records map[string]txmgr.Record
+	values  map[string]*big.Int
+}
+
+func (s *recordingSender) Address() common.Address {
+	return common.HexToAddress("0x1111111111111111111111111111111111111111")
+}
+
+func (s *recordingSender) Submit(req txmgr.Request) (txmgr.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := txmgr.Record{ID: "tx_1", Action: req.Action, Reference: req.Reference, Status: txmgr.StatusPending, Hashes: []string{"0xabc"}}
+	s.records[rec.ID] = rec
+	s.values[rec.ID] = req.Value
+	return rec, nil
+}
+
+func (s *recordingSender) Get(id string) (txmgr.Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	return rec, ok
+}
+
+func (s *recordingSender) confirm(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.records[id]
+	rec.Status = txmgr.StatusConfirmed
+	rec.Receipt = &txmgr.Receipt{TxHash: "0xabc", Succeeded: true}
+	s.records[id] = rec
+}
+
+// earnerAgent fakes the lease endpoints of an earner agent
+func earnerAgent(t *testing.T, leaseStatus *string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/leases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(reqsig.HeaderSignature) == "" || r.Header.Get("X-Pandacea-Spender-Address") == "" {
+			t.Errorf("proposal is missing its signature or spender address")
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["duration"] == "99d" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":"POLICY_REJECTION","message":"duration exceeds max_lease_duration"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"leaseProposalId":"lease_prop_1"}`))
+	})
+	mux.HandleFunc("GET /api/v1/leases/{id}", func(w http.ResponseWriter, r *http.Request) {
+		status := "pending"
+		if r.PathValue("id") == "lease_prop_"+"00000000000000000000000000000000000000000000000000000000000000aa" {
+			status = *leaseStatus
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": status})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestManagerPropose(t *testing.T) {
+	leaseStatus := "approved"
+	earner := earnerAgent(t, &leaseStatus)
+	finder := staticFinder{
+		{PeerID: "self", ProductID: "dataset-1", Price: "1000", APIURL: earner.URL, Earner: "0x3333333333333333333333333333333333333333", Local: true},
+		{PeerID: "cheap", ProductID: "dataset-1", Price: "2000000000000000", APIURL: earner.URL, Earner: "0x2222222222222222222222222222222222222222"},
+		{PeerID: "unreachable", ProductID: "dataset-1", Price: "1000000000000000"},
+		{PeerID: "dear", ProductID: "dataset-1", Price: "9000000000000000", APIURL: earner.URL, Earner: "0x4444444444444444444444444444444444444444"},
+	}
+	sender := &recordingSender{records: make(map[string]txmgr.Record), values: make(map[string]*big.Int)}
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "outbound.json")
+	m, err := New(finder, sender, key, Config{}, path, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	p, err := m.Propose(context.Background(), Request{ProductID: "dataset-1", MaxPrice: "0.005", Duration: "24h"})
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	if p.Status != StatusSubmitted || p.PeerID != "cheap" || p.LeaseProposalID != "lease_prop_1" || p.TxID != "tx_1" {
+		t.Errorf("Propose() = %+v, want the cheapest remote offer submitted", p)
+	}
+	if got := sender.values["tx_1"]; got == nil || got.String() != "2000000000000000" {
+		t.Errorf("createLease value = %v, want the quoted price", got)
+	}
+
+	// The event of the lease's createLease moves it on, and the earner's
+	// approval completes it
+	m.RecordLeaseCreated("0xABC", "0x00000000000000000000000000000000000000000000000000000000000000aa", "0x1111111111111111111111111111111111111111")
+	sender.confirm("tx_1")
+	m.refresh(context.Background())
+	if got, _ := m.Get(p.ID); got.Status != StatusApproved || got.LeaseID == "" || got.TxHash != "0xABC" {
+		t.Errorf("after approval = %+v", got)
+	}
+
+	rejected, err := m.Propose(context.Background(), Request{ProductID: "dataset-1", MaxPrice: "0.005", Duration: "99d"})
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	if rejected.Status != StatusRejected || rejected.Error != "duration exceeds max_lease_duration" {
+		t.Errorf("rejected proposal = %+v", rejected)
+	}
+
+	if _, err := m.Propose(context.Background(), Request{ProductID: "dataset-1", MaxPrice: "0.001", Duration: "24h"}); !errors.Is(err, ErrNoOffer) {
+		t.Errorf("Propose() below every price error = %v, want ErrNoOffer", err)
+	}
+	if _, err := m.Propose(context.Background(), Request{ProductID: "dataset-2", MaxPrice: "0.005", Duration: "24h"}); !errors.Is(err, ErrNoOffer) {
+		t.Errorf("Propose() of an unlisted product error = %v, want ErrNoOffer", err)
+	}
+	if _, err := m.Propose(context.Background(), Request{ProductID: "did:pandacea:mainnet:earner/a-very-long-product", MaxPrice: "0.005", Duration: "24h"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Propose() of a product ID over 32 bytes error = %v, want ErrInvalidRequest", err)
+	}
+
+	restored, err := New(finder, sender, key, Config{}, path, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := restored.List(Query{}); len(got) != 2 || got[0].ID != rejected.ID {
+		t.Errorf("restored List() = %+v, want both outbound leases, newest first", got)
+	}
+}
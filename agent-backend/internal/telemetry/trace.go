@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the agent's own spans
+const tracerName = "pandacea/agent-backend"
+
+// StartSpan starts a span named name as a child of the span in ctx, if any.
+// Without the otel build tag the global tracer provider is a no-op, so spans
+// cost next to nothing.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan marks span as failed with err, if err is not nil, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport wraps base, or http.DefaultTransport if base is nil, so every
+// request it sends is recorded as a client span and carries the trace
+// context of its request's context
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// Environ returns the W3C trace context of ctx as TRACEPARENT and TRACESTATE
+// environment variables, for worker processes and containers that continue
+// the trace. It returns nil if ctx carries no span.
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	var env []string
+	for _, key := range []string{"traceparent", "tracestate"} {
+		if value := carrier.Get(key); value != "" {
+			env = append(env, strings.ToUpper(key)+"="+value)
+		}
+	}
+	return env
+}
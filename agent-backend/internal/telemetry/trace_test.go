@@ -0,0 +1,88 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider and propagator that record spans
+// for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestStartSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child")
+	EndSpan(child, errors.New("container exited with status 1"))
+	EndSpan(parent, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestEnviron(t *testing.T) {
+	recordSpans(t)
+
+	assert.Empty(t, Environ(context.Background()))
+
+	ctx, span := StartSpan(context.Background(), "job")
+	defer span.End()
+	env := Environ(ctx)
+	require.Len(t, env, 1)
+	assert.Equal(t, "TRACEPARENT=00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", env[0])
+}
+
+func TestTransport(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	ctx, span := StartSpan(context.Background(), "fetch")
+	req, err := http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	span.End()
+
+	// The request is a client span under fetch, and the upstream sees it
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	client := spans[0]
+	assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+	assert.Equal(t, span.SpanContext().SpanID(), client.Parent().SpanID())
+	assert.Contains(t, traceparent, client.SpanContext().SpanID().String())
+}
@@ -0,0 +1,114 @@
+// Package tenant maps data products and earner addresses to the tenants of
+// an agent that hosts data for several earners. Each tenant owns the
+// products whose IDs fall in its namespaces and is paid at its earner
+// address.
+package tenant
+
+import (
+	"sort"
+	"strings"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+// Tenant is one earner hosted by the agent
+type Tenant struct {
+	ID              string   `json:"id"`
+	Earner          string   `json:"earner"` // Lowercase hex address
+	Namespaces      []string `json:"namespaces"`
+	MaxActiveLeases int      `json:"maxActiveLeases,omitempty"`
+}
+
+// Registry looks tenants up by product and earner. It is read-only after
+// creation and safe for concurrent use.
+type Registry struct {
+	tenants  []Tenant
+	byID     map[string]int
+	byEarner map[string]int
+	// namespaces are sorted longest first, so the most specific one matches
+	namespaces []namespace
+}
+
+type namespace struct {
+	prefix string
+	tenant int
+}
+
+// NewRegistry creates a registry of the configured tenants, which are
+// expected to have passed config validation
+func NewRegistry(cfg []config.TenantConfig) *Registry {
+	r := &Registry{
+		byID:     make(map[string]int, len(cfg)),
+		byEarner: make(map[string]int, len(cfg)),
+	}
+	for i, t := range cfg {
+		r.tenants = append(r.tenants, Tenant{
+			ID:              t.ID,
+			Earner:          normalize(t.Earner),
+			Namespaces:      append([]string(nil), t.Namespaces...),
+			MaxActiveLeases: t.MaxActiveLeases,
+		})
+		r.byID[t.ID] = i
+		r.byEarner[normalize(t.Earner)] = i
+		for _, ns := range t.Namespaces {
+			r.namespaces = append(r.namespaces, namespace{prefix: ns, tenant: i})
+		}
+	}
+	sort.Slice(r.namespaces, func(i, j int) bool {
+		return len(r.namespaces[i].prefix) > len(r.namespaces[j].prefix)
+	})
+	return r
+}
+
+// List returns every tenant in configuration order
+func (r *Registry) List() []Tenant {
+	return append([]Tenant(nil), r.tenants...)
+}
+
+// Get returns the tenant with id
+func (r *Registry) Get(id string) (Tenant, bool) {
+	i, ok := r.byID[id]
+	if !ok {
+		return Tenant{}, false
+	}
+	return r.tenants[i], true
+}
+
+// ForEarner returns the tenant paid at address
+func (r *Registry) ForEarner(address string) (Tenant, bool) {
+	i, ok := r.byEarner[normalize(address)]
+	if !ok {
+		return Tenant{}, false
+	}
+	return r.tenants[i], true
+}
+
+// ForProduct returns the tenant owning productID: the one with the longest
+// namespace that is the product ID or a prefix of it ending at a / or :
+func (r *Registry) ForProduct(productID string) (Tenant, bool) {
+	for _, ns := range r.namespaces {
+		if inNamespace(productID, ns.prefix) {
+			return r.tenants[ns.tenant], true
+		}
+	}
+	return Tenant{}, false
+}
+
+// inNamespace reports whether productID falls under prefix. A namespace
+// such as did:pandacea:mainnet:alice must not capture
+// did:pandacea:mainnet:alice2/x.
+func inNamespace(productID, prefix string) bool {
+	if !strings.HasPrefix(productID, prefix) {
+		return false
+	}
+	if len(productID) == len(prefix) || strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ":") {
+		return true
+	}
+	next := productID[len(prefix)]
+	return next == '/' || next == ':'
+}
+
+// normalize makes addresses case-insensitive
+func normalize(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
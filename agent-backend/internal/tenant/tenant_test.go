@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"testing"
+
+	"pandacea/agent-backend/internal/config"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry([]config.TenantConfig{
+		{ID: "alice", Earner: "0xAAaa000000000000000000000000000000000001", Namespaces: []string{"did:pandacea:mainnet:alice"}},
+		{ID: "alice-health", Earner: "0xaaaa000000000000000000000000000000000002", Namespaces: []string{"did:pandacea:mainnet:alice/health"}},
+		{ID: "bob", Earner: "0xbbbb000000000000000000000000000000000003", Namespaces: []string{"did:pandacea:mainnet:bob", "did:pandacea:testnet:bob"}},
+	})
+
+	products := []struct {
+		productID string
+		want      string
+	}{
+		{"did:pandacea:mainnet:alice/weather", "alice"},
+		{"did:pandacea:mainnet:alice/health", "alice-health"},
+		{"did:pandacea:mainnet:alice/healthcare", "alice"},
+		{"did:pandacea:testnet:bob/traffic", "bob"},
+		{"did:pandacea:mainnet:alice2/weather", ""},
+		{"did:pandacea:mainnet:carol/weather", ""},
+	}
+	for _, tt := range products {
+		got, ok := r.ForProduct(tt.productID)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("ForProduct(%s) = %s, want no tenant", tt.productID, got.ID)
+			}
+			continue
+		}
+		if !ok || got.ID != tt.want {
+			t.Errorf("ForProduct(%s) = %s, %v, want %s", tt.productID, got.ID, ok, tt.want)
+		}
+	}
+
+	if got, ok := r.ForEarner("0xaaaa000000000000000000000000000000000001"); !ok || got.ID != "alice" {
+		t.Errorf("ForEarner() = %s, %v, want alice", got.ID, ok)
+	}
+	if _, ok := r.ForEarner("0xcccc000000000000000000000000000000000004"); ok {
+		t.Error("ForEarner() found a tenant for an unknown earner")
+	}
+	if got, ok := r.Get("bob"); !ok || got.Earner != "0xbbbb000000000000000000000000000000000003" {
+		t.Errorf("Get(bob) = %+v, %v", got, ok)
+	}
+}
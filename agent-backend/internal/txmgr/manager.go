@@ -0,0 +1,732 @@
+// Package txmgr sends the agent's own transactions. Requests are queued and
+// sent one at a time so nonces are assigned in order under concurrent
+// callers. Each sent transaction is watched until it has enough
+// confirmations, and re-sent with the same nonce and higher fees if it
+// stalls. The record of every transaction, including its final receipt, is
+// linked to the API action that requested it.
+package txmgr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Errors returned by Submit
+var (
+	ErrQueueFull      = errors.New("transaction queue is full")
+	ErrInvalidRequest = errors.New("invalid transaction request")
+)
+
+// ErrNotFound is returned by Wait for a transaction the manager has no
+// record of
+var ErrNotFound = errors.New("transaction not found")
+
+// recordRetention is how long records of final transactions are kept
+const recordRetention = 30 * 24 * time.Hour
+
+// minBumpPercent is the smallest fee increase nodes accept for a
+// replacement transaction
+const minBumpPercent = 10
+
+var (
+	txSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_tx_sent_total",
+		Help: "Transactions sent by the agent, not counting fee bumps",
+	})
+	txBumps = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pandacea_tx_bumps_total",
+		Help: "Stalled transactions re-sent with higher fees",
+	})
+	txFinal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pandacea_tx_final_total",
+		Help: "Transactions that reached a final status, by status",
+	}, []string{"status"})
+)
+
+// Status is where a transaction is in its lifecycle
+type Status string
+
+// Transaction statuses. Confirmed, reverted and failed are final.
+const (
+	StatusQueued    Status = "queued"    // Waiting to be sent
+	StatusPending   Status = "pending"   // Sent, not yet confirmed
+	StatusConfirmed Status = "confirmed" // Mined and confirmed; the call succeeded
+	StatusReverted  Status = "reverted"  // Mined and confirmed; the call reverted
+	StatusFailed    Status = "failed"    // Never sent
+)
+
+// final reports whether s is a final status
+func (s Status) final() bool {
+	return s == StatusConfirmed || s == StatusReverted || s == StatusFailed
+}
+
+// Backend is the part of an Ethereum client the manager uses
+type Backend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// Config tunes how transactions are sent and watched
+type Config struct {
+	Confirmations uint64        // Blocks, counting the one it is in, before a transaction is final (0 = 1)
+	PollInterval  time.Duration // How often pending transactions are checked
+	StallTimeout  time.Duration // How long a transaction may go unmined before its fees are bumped
+	BumpPercent   int           // Fee increase per bump; at least 10
+	MaxFeeCap     *big.Int      // Highest fee cap per gas in wei (nil = unbounded)
+	QueueSize     int           // Requests waiting to be sent
+}
+
+// Request is a transaction to send
+type Request struct {
+	Action    string // API action that asked for the transaction, e.g. lease.approve
+	Reference string // What the action was on, e.g. a lease ID
+	RequestID string // ID of the API request
+	To        common.Address
+	Data      []byte
+	Value     *big.Int // Nil sends no value
+	GasLimit  uint64   // 0 estimates it
+}
+
+// Receipt is the outcome of a mined transaction
+type Receipt struct {
+	TxHash            string `json:"tx_hash"`
+	BlockNumber       uint64 `json:"block_number"`
+	BlockHash         string `json:"block_hash"`
+	GasUsed           uint64 `json:"gas_used"`
+	EffectiveGasPrice string `json:"effective_gas_price,omitempty"` // In wei
+	Succeeded         bool   `json:"succeeded"`
+	Confirmations     uint64 `json:"confirmations"`
+}
+
+// Record tracks one requested transaction across every attempt to send it
+type Record struct {
+	ID        string        `json:"id"`
+	Action    string        `json:"action"`
+	Reference string        `json:"reference,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Data      hexutil.Bytes `json:"data,omitempty"`
+	Value     string        `json:"value,omitempty"` // In wei
+	Status    Status        `json:"status"`
+	Nonce     *uint64       `json:"nonce,omitempty"`
+	GasLimit  uint64        `json:"gas_limit,omitempty"`
+	GasTipCap string        `json:"gas_tip_cap,omitempty"` // Of the latest attempt, in wei
+	GasFeeCap string        `json:"gas_fee_cap,omitempty"` // Of the latest attempt, in wei
+	Hashes    []string      `json:"hashes,omitempty"`      // Every attempt, latest last
+	Bumps     int           `json:"bumps"`
+	Receipt   *Receipt      `json:"receipt,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	SentAt    *time.Time    `json:"sent_at,omitempty"` // Of the latest attempt
+	UpdatedAt time.Time     `json:"updated_at"`
+
+	value *big.Int
+}
+
+// Query selects records. Zero fields do not filter.
+type Query struct {
+	Action    string
+	Reference string
+	Status    Status
+}
+
+// Manager queues, sends and watches transactions from one account. It is
+// safe for concurrent use.
+type Manager struct {
+	backend Backend
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	signer  types.Signer
+	chainID *big.Int
+	cfg     Config
+	path    string
+	logger  *slog.Logger
+
+	queue chan string
+
+	// nonce is the next nonce to use; only the sender loop touches it
+	nonce *uint64
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// New creates a manager that signs with key for chain chainID. Records are
+// persisted to path unless it is empty; transactions still queued or
+// pending are picked up again by Run.
+func New(backend Backend, key *ecdsa.PrivateKey, chainID *big.Int, cfg Config, path string, logger *slog.Logger) (*Manager, error) {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.StallTimeout <= 0 {
+		cfg.StallTimeout = time.Minute
+	}
+	cfg.BumpPercent = max(cfg.BumpPercent, minBumpPercent)
+	cfg.Confirmations = max(cfg.Confirmations, 1)
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 64
+	}
+
+	m := &Manager{
+		backend: backend,
+		key:     key,
+		from:    crypto.PubkeyToAddress(key.PublicKey),
+		signer:  types.LatestSignerForChainID(chainID),
+		chainID: chainID,
+		cfg:     cfg,
+		path:    path,
+		logger:  logger,
+		queue:   make(chan string, cfg.QueueSize),
+		records: make(map[string]*Record),
+	}
+
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction records: %w", err)
+	}
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction records: %w", err)
+	}
+	for _, rec := range records {
+		rec.value, _ = new(big.Int).SetString(rec.Value, 10)
+		m.records[rec.ID] = rec
+	}
+	return m, nil
+}
+
+// Address returns the account transactions are sent from
+func (m *Manager) Address() common.Address {
+	return m.from
+}
+
+// Submit queues a transaction and returns its record
+func (m *Manager) Submit(req Request) (Record, error) {
+	if req.Action == "" {
+		return Record{}, fmt.Errorf("%w: action is required", ErrInvalidRequest)
+	}
+	if req.Value != nil && req.Value.Sign() < 0 {
+		return Record{}, fmt.Errorf("%w: value must not be negative", ErrInvalidRequest)
+	}
+
+	now := time.Now().UTC()
+	rec := &Record{
+		ID:        newID(),
+		Action:    req.Action,
+		Reference: req.Reference,
+		RequestID: req.RequestID,
+		From:      m.from.Hex(),
+		To:        req.To.Hex(),
+		Data:      req.Data,
+		Status:    StatusQueued,
+		GasLimit:  req.GasLimit,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.Value != nil {
+		rec.value = new(big.Int).Set(req.Value)
+		rec.Value = req.Value.String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- rec.ID:
+	default:
+		return Record{}, ErrQueueFull
+	}
+	m.records[rec.ID] = rec
+	if err := m.save(); err != nil {
+		m.logger.Error("failed to save transaction records", "error", err)
+	}
+	return rec.copy(), nil
+}
+
+// Get returns the record of transaction id
+func (m *Manager) Get(id string) (Record, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[id]
+	if !ok {
+		return Record{}, false
+	}
+	return rec.copy(), true
+}
+
+// Wait blocks until transaction id is final and returns its record. If
+// ctx is done first it returns the latest record with ctx's error.
+func (m *Manager) Wait(ctx context.Context, id string) (Record, error) {
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		rec, ok := m.Get(id)
+		if !ok {
+			return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		if rec.Status.final() {
+			return rec, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return rec, ctx.Err()
+		}
+	}
+}
+
+// List returns the records q selects, newest first
+func (m *Manager) List(q Query) []Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := []Record{}
+	for _, rec := range m.records {
+		if q.Action != "" && rec.Action != q.Action {
+			continue
+		}
+		if q.Reference != "" && !strings.EqualFold(rec.Reference, q.Reference) {
+			continue
+		}
+		if q.Status != "" && rec.Status != q.Status {
+			continue
+		}
+		records = append(records, rec.copy())
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.After(records[j].CreatedAt) })
+	return records
+}
+
+// Run sends queued transactions and watches pending ones until ctx is
+// cancelled. Transactions restored from disk are resumed first.
+func (m *Manager) Run(ctx context.Context) {
+	m.mu.Lock()
+	var backlog []*Record
+	for _, rec := range m.records {
+		switch rec.Status {
+		case StatusQueued:
+			backlog = append(backlog, rec)
+		case StatusPending:
+			go m.watch(ctx, rec.ID)
+		}
+	}
+	m.mu.Unlock()
+	// Queued records restored from disk are not in the channel. Records
+	// submitted before Run are in both, and send skips them the second time.
+	sort.Slice(backlog, func(i, j int) bool { return backlog[i].CreatedAt.Before(backlog[j].CreatedAt) })
+	for _, rec := range backlog {
+		m.send(ctx, rec.ID)
+	}
+
+	for {
+		select {
+		case id := <-m.queue:
+			m.send(ctx, id)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send signs and broadcasts a queued transaction with the next nonce
+func (m *Manager) send(ctx context.Context, id string) {
+	rec, ok := m.Get(id)
+	if !ok || rec.Status != StatusQueued {
+		return
+	}
+	to := common.HexToAddress(rec.To)
+	value := rec.value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	if m.nonce == nil {
+		nonce, err := m.backend.PendingNonceAt(ctx, m.from)
+		if err != nil {
+			m.fail(id, fmt.Errorf("failed to fetch nonce: %w", err))
+			return
+		}
+		m.nonce = &nonce
+	}
+
+	gas := rec.GasLimit
+	if gas == 0 {
+		estimate, err := m.backend.EstimateGas(ctx, ethereum.CallMsg{From: m.from, To: &to, Value: value, Data: rec.Data})
+		if err != nil {
+			m.fail(id, fmt.Errorf("failed to estimate gas: %w", err))
+			return
+		}
+		gas = estimate + estimate/5 // Headroom for state changing before inclusion
+	}
+	tip, feeCap, err := m.fees(ctx)
+	if err != nil {
+		m.fail(id, err)
+		return
+	}
+
+	tx, err := m.broadcast(ctx, *m.nonce, gas, tip, feeCap, to, value, rec.Data)
+	if err != nil && isNonceTooLow(err) {
+		// Something else sent from this account; resync and try once more
+		nonce, nonceErr := m.backend.PendingNonceAt(ctx, m.from)
+		if nonceErr != nil {
+			m.fail(id, fmt.Errorf("failed to fetch nonce: %w", nonceErr))
+			return
+		}
+		m.nonce = &nonce
+		tx, err = m.broadcast(ctx, nonce, gas, tip, feeCap, to, value, rec.Data)
+	}
+	if err != nil {
+		m.fail(id, fmt.Errorf("failed to send transaction: %w", err))
+		return
+	}
+
+	nonce := *m.nonce
+	*m.nonce++
+	txSent.Inc()
+	m.update(id, func(rec *Record) {
+		now := time.Now().UTC()
+		rec.Status = StatusPending
+		rec.Nonce = &nonce
+		rec.GasLimit = gas
+		rec.GasTipCap = tip.String()
+		rec.GasFeeCap = feeCap.String()
+		rec.Hashes = append(rec.Hashes, tx.Hash().Hex())
+		rec.SentAt = &now
+	})
+	m.logger.Info("transaction sent", "tx_id", id, "action", rec.Action, "reference", rec.Reference, "tx_hash", tx.Hash().Hex(), "nonce", nonce)
+
+	go m.watch(ctx, id)
+}
+
+// broadcast signs and sends a dynamic fee transaction. A node that already
+// has it is not an error.
+func (m *Manager) broadcast(ctx context.Context, nonce, gas uint64, tip, feeCap *big.Int, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	tx, err := types.SignNewTx(m.key, m.signer, &types.DynamicFeeTx{
+		ChainID:   m.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if err := m.backend.SendTransaction(ctx, tx); err != nil && !strings.Contains(err.Error(), "already known") {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// fees returns the current tip and a fee cap that survives the base fee
+// doubling, both within the configured maximum
+func (m *Manager) fees(ctx context.Context) (*big.Int, *big.Int, error) {
+	tip, err := m.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest gas tip: %w", err)
+	}
+	head, err := m.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch chain head: %w", err)
+	}
+	feeCap := new(big.Int).Set(tip)
+	if head.BaseFee != nil {
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	tip, feeCap = m.capFees(tip, feeCap)
+	return tip, feeCap, nil
+}
+
+// capFees limits fees to the configured maximum fee cap
+func (m *Manager) capFees(tip, feeCap *big.Int) (*big.Int, *big.Int) {
+	if m.cfg.MaxFeeCap != nil && feeCap.Cmp(m.cfg.MaxFeeCap) > 0 {
+		feeCap = new(big.Int).Set(m.cfg.MaxFeeCap)
+	}
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	return tip, feeCap
+}
+
+// watch polls a pending transaction until it is final or ctx is cancelled
+func (m *Manager) watch(ctx context.Context, id string) {
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if m.poll(ctx, id) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll checks a pending transaction for a receipt, finalising it once it
+// has enough confirmations and bumping its fees if it has stalled. It
+// reports whether the transaction is final.
+func (m *Manager) poll(ctx context.Context, id string) bool {
+	rec, ok := m.Get(id)
+	if !ok || rec.Status != StatusPending {
+		return true
+	}
+
+	// Any attempt may be the one that was mined
+	var receipt *types.Receipt
+	for i := len(rec.Hashes) - 1; i >= 0 && receipt == nil; i-- {
+		r, err := m.backend.TransactionReceipt(ctx, common.HexToHash(rec.Hashes[i]))
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			m.logger.Warn("failed to fetch transaction receipt", "error", err, "tx_id", id)
+			return false
+		}
+		receipt = r
+	}
+
+	if receipt == nil {
+		// A receipt seen before may have been reorganised away
+		if rec.Receipt != nil {
+			m.update(id, func(rec *Record) { rec.Receipt = nil })
+		}
+		if rec.SentAt == nil || time.Since(*rec.SentAt) >= m.cfg.StallTimeout {
+			m.bump(ctx, rec)
+		}
+		return false
+	}
+
+	head, err := m.backend.BlockNumber(ctx)
+	if err != nil {
+		m.logger.Warn("failed to fetch chain head", "error", err, "tx_id", id)
+		return false
+	}
+	confirmations := uint64(0)
+	if head >= receipt.BlockNumber.Uint64() {
+		confirmations = head - receipt.BlockNumber.Uint64() + 1
+	}
+	final := confirmations >= m.cfg.Confirmations
+	status := StatusConfirmed
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		status = StatusReverted
+	}
+
+	m.update(id, func(rec *Record) {
+		rec.Receipt = &Receipt{
+			TxHash:        receipt.TxHash.Hex(),
+			BlockNumber:   receipt.BlockNumber.Uint64(),
+			BlockHash:     receipt.BlockHash.Hex(),
+			GasUsed:       receipt.GasUsed,
+			Succeeded:     receipt.Status == types.ReceiptStatusSuccessful,
+			Confirmations: confirmations,
+		}
+		if receipt.EffectiveGasPrice != nil {
+			rec.Receipt.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+		}
+		if final {
+			rec.Status = status
+		}
+	})
+	if final {
+		txFinal.WithLabelValues(string(status)).Inc()
+		m.logger.Info("transaction final", "tx_id", id, "action", rec.Action, "reference", rec.Reference, "status", status, "tx_hash", receipt.TxHash.Hex(), "block_number", receipt.BlockNumber)
+	}
+	return final
+}
+
+// bump re-sends a stalled transaction with the same nonce and higher fees
+func (m *Manager) bump(ctx context.Context, rec Record) {
+	oldTip, _ := new(big.Int).SetString(rec.GasTipCap, 10)
+	oldFeeCap, _ := new(big.Int).SetString(rec.GasFeeCap, 10)
+	if oldTip == nil || oldFeeCap == nil || rec.Nonce == nil {
+		return
+	}
+
+	tip := bumpBy(oldTip, m.cfg.BumpPercent)
+	feeCap := bumpBy(oldFeeCap, m.cfg.BumpPercent)
+	// Follow the market if it rose faster than the bump
+	if freshTip, freshFeeCap, err := m.fees(ctx); err == nil {
+		tip = bigMax(tip, freshTip)
+		feeCap = bigMax(feeCap, freshFeeCap)
+	}
+	tip, feeCap = m.capFees(tip, feeCap)
+
+	// Replacements must raise both fees by the minimum bump
+	now := time.Now().UTC()
+	if tip.Cmp(bumpBy(oldTip, minBumpPercent)) < 0 || feeCap.Cmp(bumpBy(oldFeeCap, minBumpPercent)) < 0 {
+		m.logger.Warn("stalled transaction is at the maximum fee cap", "tx_id", rec.ID, "gas_fee_cap", oldFeeCap)
+		m.update(rec.ID, func(rec *Record) { rec.SentAt = &now })
+		return
+	}
+
+	value := rec.value
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx, err := m.broadcast(ctx, *rec.Nonce, rec.GasLimit, tip, feeCap, common.HexToAddress(rec.To), value, rec.Data)
+	if err != nil {
+		// Nonce too low means an earlier attempt was mined; the next poll finds it
+		if !isNonceTooLow(err) {
+			m.logger.Warn("failed to bump stalled transaction", "error", err, "tx_id", rec.ID)
+		}
+		m.update(rec.ID, func(rec *Record) { rec.SentAt = &now })
+		return
+	}
+
+	txBumps.Inc()
+	m.update(rec.ID, func(rec *Record) {
+		rec.Bumps++
+		rec.GasTipCap = tip.String()
+		rec.GasFeeCap = feeCap.String()
+		rec.Hashes = append(rec.Hashes, tx.Hash().Hex())
+		rec.SentAt = &now
+	})
+	m.logger.Info("bumped stalled transaction", "tx_id", rec.ID, "tx_hash", tx.Hash().Hex(), "gas_fee_cap", feeCap, "bumps", rec.Bumps+1)
+}
+
+// fail marks a queued transaction failed
+func (m *Manager) fail(id string, err error) {
+	m.logger.Error("transaction failed", "error", err, "tx_id", id)
+	txFinal.WithLabelValues(string(StatusFailed)).Inc()
+	m.update(id, func(rec *Record) {
+		rec.Status = StatusFailed
+		rec.Error = err.Error()
+	})
+}
+
+// update applies fn to a record and saves the records
+func (m *Manager) update(id string, fn func(rec *Record)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[id]
+	if !ok {
+		return
+	}
+	fn(rec)
+	rec.UpdatedAt = time.Now().UTC()
+	if err := m.save(); err != nil {
+		m.logger.Error("failed to save transaction records", "error", err)
+	}
+}
+
+// save prunes old final records and writes the rest atomically. Caller
+// must hold m.mu.
+func (m *Manager) save() error {
+	cutoff := time.Now().Add(-recordRetention)
+	for id, rec := range m.records {
+		if rec.Status.final() && rec.UpdatedAt.Before(cutoff) {
+			delete(m.records, id)
+		}
+	}
+
+	if m.path == "" {
+		return nil
+	}
+
+	records := make([]*Record, 0, len(m.records))
+	for _, rec := range m.records {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction records: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return fmt.Errorf("failed to create transaction records directory: %w", err)
+	}
+	if err := fsutil.WriteFile(m.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write transaction records: %w", err)
+	}
+	return nil
+}
+
+// copy returns a copy of rec that shares no mutable state with it
+func (rec *Record) copy() Record {
+	c := *rec
+	c.Hashes = append([]string(nil), rec.Hashes...)
+	if rec.Nonce != nil {
+		nonce := *rec.Nonce
+		c.Nonce = &nonce
+	}
+	if rec.Receipt != nil {
+		receipt := *rec.Receipt
+		c.Receipt = &receipt
+	}
+	return c
+}
+
+// newID returns a random transaction record ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "tx_" + hex.EncodeToString(b)
+}
+
+// isNotFound reports whether a receipt lookup found nothing. Geth answers
+// "indexing is in progress" until its transaction index catches up.
+func isNotFound(err error) bool {
+	return err != nil && (errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "indexing is in progress"))
+}
+
+// isNonceTooLow reports whether a node rejected a transaction because its
+// nonce was already used
+func isNonceTooLow(err error) bool {
+	return strings.Contains(err.Error(), "nonce too low")
+}
+
+// bumpBy returns v raised by percent, and by at least one
+func bumpBy(v *big.Int, percent int) *big.Int {
+	bumped := new(big.Int).Mul(v, big.NewInt(int64(100+percent)))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(v) <= 0 {
+		bumped.Add(v, big.NewInt(1))
+	}
+	return bumped
+}
+
+// bigMax returns the larger of a and b
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,221 @@
+package txmgr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var recipient = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// newTestManager starts a manager on a simulated chain that mines only when
+// the test commits a block
+func newTestManager(t *testing.T, cfg Config, path string) (*Manager, *simulated.Backend, context.CancelFunc) {
+	t.Helper()
+	key, _ := crypto.HexToECDSA("8f2a55949038a9610f50fb23b5883af3b4ecb3c3bb792cbcefbd1542c692be63")
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	m := startManager(t, backend, key, cfg, path)
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.Run(ctx)
+	t.Cleanup(cancel)
+	return m, backend, cancel
+}
+
+func startManager(t *testing.T, backend *simulated.Backend, key *ecdsa.PrivateKey, cfg Config, path string) *Manager {
+	t.Helper()
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 10 * time.Millisecond
+	}
+	if cfg.StallTimeout == 0 {
+		cfg.StallTimeout = time.Hour
+	}
+	chainID, err := backend.Client().ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("ChainID: %v", err)
+	}
+	m, err := New(backend.Client(), key, chainID, cfg, path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// waitFor polls until cond holds for transaction id
+func waitFor(t *testing.T, m *Manager, id string, cond func(Record) bool) Record {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec, _ := m.Get(id)
+		if cond(rec) {
+			return rec
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction %s stuck: %+v", id, rec)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConcurrentSubmissionsGetDistinctNonces(t *testing.T) {
+	m, backend, _ := newTestManager(t, Config{Confirmations: 2}, "")
+
+	var wg sync.WaitGroup
+	ids := make([]string, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec, err := m.Submit(Request{Action: "test.send", Reference: "ref", To: recipient, Value: big.NewInt(1)})
+			if err != nil {
+				t.Errorf("Submit: %v", err)
+			}
+			ids[i] = rec.ID
+		}()
+	}
+	wg.Wait()
+	for _, id := range ids {
+		waitFor(t, m, id, func(r Record) bool { return r.Status == StatusPending })
+	}
+
+	backend.Commit()
+	for _, id := range ids {
+		rec := waitFor(t, m, id, func(r Record) bool { return r.Receipt != nil })
+		if rec.Status != StatusPending {
+			t.Errorf("status with one confirmation = %s, want pending", rec.Status)
+		}
+	}
+	backend.Commit()
+
+	nonces := make(map[uint64]bool)
+	for _, id := range ids {
+		rec := waitFor(t, m, id, func(r Record) bool { return r.Status.final() })
+		if rec.Status != StatusConfirmed || !rec.Receipt.Succeeded || rec.Receipt.Confirmations < 2 {
+			t.Errorf("record = %+v, receipt %+v", rec, rec.Receipt)
+		}
+		nonces[*rec.Nonce] = true
+	}
+	if len(nonces) != len(ids) {
+		t.Errorf("nonces = %v, want %d distinct", nonces, len(ids))
+	}
+	if got := m.List(Query{Action: "test.send", Status: StatusConfirmed}); len(got) != len(ids) {
+		t.Errorf("List() returned %d records, want %d", len(got), len(ids))
+	}
+}
+
+func TestStalledTransactionIsBumped(t *testing.T) {
+	m, backend, _ := newTestManager(t, Config{StallTimeout: 20 * time.Millisecond, BumpPercent: 25}, "")
+
+	rec, err := m.Submit(Request{Action: "test.send", To: recipient})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	bumped := waitFor(t, m, rec.ID, func(r Record) bool { return r.Bumps >= 1 })
+	first, _ := new(big.Int).SetString(waitFor(t, m, rec.ID, func(r Record) bool { return len(r.Hashes) > 0 }).GasFeeCap, 10)
+	if len(bumped.Hashes) < 2 {
+		t.Fatalf("hashes = %v, want the original and a replacement", bumped.Hashes)
+	}
+
+	backend.Commit()
+	final := waitFor(t, m, rec.ID, func(r Record) bool { return r.Status.final() })
+	if final.Status != StatusConfirmed {
+		t.Fatalf("status = %s (%s)", final.Status, final.Error)
+	}
+	if mined := final.Receipt.TxHash; mined == final.Hashes[0] {
+		t.Errorf("the original attempt %s was mined, want a replacement", mined)
+	}
+	if feeCap, _ := new(big.Int).SetString(final.GasFeeCap, 10); feeCap.Cmp(first) < 0 {
+		t.Errorf("fee cap fell from %s to %s", first, feeCap)
+	}
+}
+
+func TestFailedSendDoesNotConsumeNonce(t *testing.T) {
+	m, backend, _ := newTestManager(t, Config{}, "")
+
+	broke, err := m.Submit(Request{Action: "test.send", To: recipient, Value: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if rec := waitFor(t, m, broke.ID, func(r Record) bool { return r.Status.final() }); rec.Status != StatusFailed || rec.Error == "" {
+		t.Errorf("unaffordable transaction = %+v", rec)
+	}
+
+	ok, err := m.Submit(Request{Action: "test.send", To: recipient})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitFor(t, m, ok.ID, func(r Record) bool { return r.Status == StatusPending })
+	backend.Commit()
+	rec := waitFor(t, m, ok.ID, func(r Record) bool { return r.Status.final() })
+	if rec.Status != StatusConfirmed || *rec.Nonce != 0 {
+		t.Errorf("record = %+v, want confirmed with nonce 0", rec)
+	}
+}
+
+func TestWait(t *testing.T) {
+	m, backend, _ := newTestManager(t, Config{}, "")
+
+	rec, err := m.Submit(Request{Action: "test.send", To: recipient})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitFor(t, m, rec.ID, func(r Record) bool { return r.Status == StatusPending })
+
+	// Unmined, the wait ends with the context and the latest record
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if got, err := m.Wait(ctx, rec.ID); !errors.Is(err, context.DeadlineExceeded) || got.Status != StatusPending {
+		t.Errorf("Wait() before mining = %s, %v", got.Status, err)
+	}
+
+	backend.Commit()
+	if got, err := m.Wait(context.Background(), rec.ID); err != nil || got.Status != StatusConfirmed {
+		t.Errorf("Wait() = %s, %v, want confirmed", got.Status, err)
+	}
+	if _, err := m.Wait(context.Background(), "tx_missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Wait(unknown) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestQueuedTransactionsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.json")
+	key, _ := crypto.HexToECDSA("8f2a55949038a9610f50fb23b5883af3b4ecb3c3bb792cbcefbd1542c692be63")
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.Ether)},
+	})
+	defer backend.Close()
+
+	// Queued while no manager was running
+	stopped := startManager(t, backend, key, Config{}, path)
+	rec, err := stopped.Submit(Request{Action: "lease.approve", Reference: "0xabc", RequestID: "req-1", To: recipient})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	restarted := startManager(t, backend, key, Config{}, path)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+	waitFor(t, restarted, rec.ID, func(r Record) bool { return r.Status == StatusPending })
+	backend.Commit()
+	final := waitFor(t, restarted, rec.ID, func(r Record) bool { return r.Status.final() })
+	if final.Status != StatusConfirmed || final.RequestID != "req-1" || final.Reference != "0xabc" {
+		t.Errorf("restored record = %+v", final)
+	}
+}
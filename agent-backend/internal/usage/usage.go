@@ -0,0 +1,232 @@
+// Package usage accounts what each identity has used of the agent: API
+// requests, jobs started, compute time and artifact bytes. Usage is rolled
+// up per UTC day and month, so callers can see how much they used beyond
+// the instantaneous rate limits.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"pandacea/agent-backend/internal/fsutil"
+)
+
+// Rollups older than these are dropped
+const (
+	dayRetention   = 90 // days
+	monthRetention = 24 // months
+)
+
+// Period layouts
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// Counters are usage totals
+type Counters struct {
+	Requests       int64   `json:"requests"`
+	JobsStarted    int64   `json:"jobsStarted"`    // Training jobs and computations queued
+	ComputeSeconds float64 `json:"computeSeconds"` // Time spent running jobs
+	ArtifactBytes  int64   `json:"artifactBytes"`  // Size of the results and artifacts jobs produced
+}
+
+// add adds c's counts to t
+func (t *Counters) add(c Counters) {
+	t.Requests += c.Requests
+	t.JobsStarted += c.JobsStarted
+	t.ComputeSeconds += c.ComputeSeconds
+	t.ArtifactBytes += c.ArtifactBytes
+}
+
+// Rollup is an identity's usage in a day (2006-01-02) or month (2006-01)
+type Rollup struct {
+	Period string `json:"period"`
+	Counters
+}
+
+// Report is an identity's usage, newest period first
+type Report struct {
+	Today     Rollup   `json:"today"`
+	ThisMonth Rollup   `json:"thisMonth"`
+	Daily     []Rollup `json:"daily"`
+	Monthly   []Rollup `json:"monthly"`
+}
+
+// record is an identity's rollups, keyed by period
+type record struct {
+	Daily   map[string]*Counters `json:"daily"`
+	Monthly map[string]*Counters `json:"monthly"`
+}
+
+// Store keeps usage by identity. Usage is saved by Save and Run rather than
+// on every Add, since every request adds to it. It is safe for concurrent
+// use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*record
+	dirty   bool
+	now     func() time.Time
+}
+
+// NewStore creates a store that persists usage to path unless it is empty,
+// restoring any already saved there
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, records: make(map[string]*record), now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("failed to parse usage: %w", err)
+	}
+	if s.records == nil {
+		s.records = make(map[string]*record)
+	}
+	return s, nil
+}
+
+// Add counts c against identity's current day and month
+func (s *Store) Add(identity string, c Counters) {
+	if identity == "" {
+		return
+	}
+	now := s.now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[identity]
+	if !ok {
+		rec = &record{Daily: make(map[string]*Counters), Monthly: make(map[string]*Counters)}
+		s.records[identity] = rec
+	}
+	addTo(rec.Daily, now.Format(dayLayout), c, now)
+	addTo(rec.Monthly, now.Format(monthLayout), c, now)
+	s.dirty = true
+}
+
+// addTo adds c to the rollup for period, pruning old rollups when it opens
+// a new one
+func addTo(rollups map[string]*Counters, period string, c Counters, now time.Time) {
+	total, ok := rollups[period]
+	if !ok {
+		total = &Counters{}
+		rollups[period] = total
+		prune(rollups, now)
+	}
+	total.add(c)
+}
+
+// prune drops rollups past their retention
+func prune(rollups map[string]*Counters, now time.Time) {
+	oldestDay := now.AddDate(0, 0, -dayRetention).Format(dayLayout)
+	oldestMonth := now.AddDate(0, -monthRetention, 0).Format(monthLayout)
+	for period := range rollups {
+		oldest := oldestDay
+		if len(period) == len(monthLayout) {
+			oldest = oldestMonth
+		}
+		if period < oldest {
+			delete(rollups, period)
+		}
+	}
+}
+
+// Report returns identity's usage, with up to days daily and months monthly
+// rollups
+func (s *Store) Report(identity string, days, months int) Report {
+	now := s.now().UTC()
+	report := Report{
+		Today:     Rollup{Period: now.Format(dayLayout)},
+		ThisMonth: Rollup{Period: now.Format(monthLayout)},
+		Daily:     []Rollup{},
+		Monthly:   []Rollup{},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[identity]
+	if !ok {
+		return report
+	}
+	if c, ok := rec.Daily[report.Today.Period]; ok {
+		report.Today.Counters = *c
+	}
+	if c, ok := rec.Monthly[report.ThisMonth.Period]; ok {
+		report.ThisMonth.Counters = *c
+	}
+	report.Daily = rollups(rec.Daily, days)
+	report.Monthly = rollups(rec.Monthly, months)
+	return report
+}
+
+// rollups returns up to limit of the newest rollups
+func rollups(totals map[string]*Counters, limit int) []Rollup {
+	out := make([]Rollup, 0, len(totals))
+	for period, c := range totals {
+		out = append(out, Rollup{Period: period, Counters: *c})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Period > out[j].Period })
+	if limit >= 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// Run saves usage every interval until ctx is done, then saves it once
+// more. Failed saves are passed to onError and retried on the next tick.
+func (s *Store) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Save writes usage added since the last save
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	if err := fsutil.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
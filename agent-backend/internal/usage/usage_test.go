@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	s.Add("peerA", Counters{Requests: 1})
+	s.Add("peerA", Counters{Requests: 1, JobsStarted: 1})
+	s.Add("peerB", Counters{Requests: 1})
+	now = now.Add(2 * time.Hour) // April 1st
+	s.Add("peerA", Counters{ComputeSeconds: 1.5, ArtifactBytes: 2048})
+	s.Add("", Counters{Requests: 1})
+
+	report := s.Report("peerA", 30, 12)
+	if report.Today != (Rollup{Period: "2026-04-01", Counters: Counters{ComputeSeconds: 1.5, ArtifactBytes: 2048}}) {
+		t.Errorf("today = %+v", report.Today)
+	}
+	if report.ThisMonth.Period != "2026-04" || report.ThisMonth.Requests != 0 {
+		t.Errorf("this month = %+v", report.ThisMonth)
+	}
+	if len(report.Daily) != 2 || report.Daily[1] != (Rollup{Period: "2026-03-31", Counters: Counters{Requests: 2, JobsStarted: 1}}) {
+		t.Errorf("daily = %+v", report.Daily)
+	}
+	if len(report.Monthly) != 2 || report.Monthly[0].Period != "2026-04" {
+		t.Errorf("monthly = %+v", report.Monthly)
+	}
+	if limited := s.Report("peerA", 1, 0); len(limited.Daily) != 1 || len(limited.Monthly) != 0 {
+		t.Errorf("limited report = %+v", limited)
+	}
+	if empty := s.Report("peerC", 30, 12); empty.Today.Period != "2026-04-01" || len(empty.Daily) != 0 {
+		t.Errorf("report without usage = %+v", empty)
+	}
+
+	// Old rollups are dropped when a new period opens
+	now = now.AddDate(0, 0, dayRetention+1)
+	s.Add("peerA", Counters{Requests: 1})
+	if daily := s.Report("peerA", -1, -1).Daily; len(daily) != 1 {
+		t.Errorf("daily after retention = %+v", daily)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.now = s.now
+	if got := restored.Report("peerB", 30, 12).Monthly; len(got) != 1 || got[0].Requests != 1 {
+		t.Errorf("restored peerB monthly = %+v", got)
+	}
+}
@@ -0,0 +1,264 @@
+// Package watermark embeds lease-keyed watermarks into numeric results so an
+// earner can later show that leaked data or models came from a specific
+// lease.
+//
+// Each fractional number is moved by at most a few parts per million onto a
+// lattice point whose parity is a keyed bit. The bit is derived from the
+// secret key, the recipient (a lease or job ID) and the number's leading
+// bits, so detection needs neither the original values nor their position:
+// numbers scraped from a leaked file, in any text format, either agree with
+// the recipient's bits far more often than chance or they do not.
+package watermark
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// KeySize is the length of generated watermark keys in bytes
+	KeySize = 32
+
+	// Threshold is the detection score above which a recipient is reported
+	// as the source. Unmarked numbers score above it with probability about
+	// 3 in 100,000.
+	Threshold = 4.0
+
+	// MinValues is the fewest marked numbers that can reach Threshold
+	MinValues = 16
+
+	// precisionBits is the number of leading mantissa bits kept; the
+	// perturbation is below 1.5 in 2^19 of the value
+	precisionBits = 20
+	// anchorShift drops the lattice bits a number may move within, leaving
+	// the bits that select its keyed bit
+	anchorShift = 8
+	// maxMarkable bounds marked values so the lattice step stays below one
+	// and marked values never become integers
+	maxMarkable = 1 << (precisionBits - 1)
+	// minExponent skips values whose lattice step would underflow
+	minExponent = -1000
+)
+
+// ErrInvalidKey is returned for a key shorter than KeySize
+var ErrInvalidKey = errors.New("invalid watermark key")
+
+// numberPattern finds decimal numbers in arbitrary text
+var numberPattern = regexp.MustCompile(`-?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// Marker embeds and detects watermarks under one secret key
+type Marker struct {
+	key []byte
+}
+
+// NewMarker creates a marker for key
+func NewMarker(key []byte) (*Marker, error) {
+	if len(key) < KeySize {
+		return nil, fmt.Errorf("%w: need %d bytes, got %d", ErrInvalidKey, KeySize, len(key))
+	}
+	return &Marker{key: append([]byte(nil), key...)}, nil
+}
+
+// LoadKey reads the key at path. A leading ~ is expanded to the home
+// directory.
+func LoadKey(path string) ([]byte, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey reads the key at path, generating and saving a new one if
+// the file does not exist
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := LoadKey(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	if path, err = expandHome(path); err != nil {
+		return nil, err
+	}
+	key = make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate watermark key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create watermark key directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save watermark key: %w", err)
+	}
+	return key, nil
+}
+
+// expandHome expands a leading ~ in path to the home directory
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// lattice locates a markable value: |v| = (q + f) * 2^(exp-precisionBits)
+// with q in [2^(precisionBits-1), 2^precisionBits) and f in [0, 1)
+func lattice(v float64) (q int64, exp int, ok bool) {
+	abs := math.Abs(v)
+	if v == math.Trunc(v) || abs >= maxMarkable || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, 0, false
+	}
+	_, exp = math.Frexp(abs)
+	if exp < minExponent {
+		return 0, 0, false
+	}
+	return int64(math.Ldexp(abs, precisionBits-exp)), exp, true
+}
+
+// bit returns the recipient's keyed bit for the lattice block holding q
+func (m *Marker) bit(recipient string, negative bool, exp int, q int64) int64 {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(recipient))
+	var block [13]byte
+	if negative {
+		block[0] = 1
+	}
+	binary.BigEndian.PutUint32(block[1:], uint32(int32(exp)))
+	binary.BigEndian.PutUint64(block[5:], uint64(q>>anchorShift))
+	mac.Write(block[:])
+	return int64(mac.Sum(nil)[0] & 1)
+}
+
+// Embed returns v moved onto the recipient's lattice. Integers, zero and
+// values of magnitude 2^19 or more are returned unchanged.
+func (m *Marker) Embed(recipient string, v float64) float64 {
+	q, exp, ok := lattice(v)
+	if !ok {
+		return v
+	}
+
+	if q&1 != m.bit(recipient, v < 0, exp, q) {
+		// Step to the nearer neighbour, staying inside the lattice block so
+		// the keyed bit is unchanged
+		abs := math.Ldexp(math.Abs(v), precisionBits-exp)
+		up := abs-float64(q) >= 0.5
+		if up && (q+1)>>anchorShift != q>>anchorShift {
+			up = false
+		} else if !up && (q-1)>>anchorShift != q>>anchorShift {
+			up = true
+		}
+		if up {
+			q++
+		} else {
+			q--
+		}
+	}
+
+	// The centre of the cell survives small rounding on the way back
+	marked := math.Ldexp(float64(q)+0.5, exp-precisionBits)
+	if v < 0 {
+		return -marked
+	}
+	return marked
+}
+
+// EmbedJSON marks every fractional number in a JSON document and returns
+// the re-encoded document and the number of values marked. Integers are
+// left alone so counts, sizes and IDs keep their meaning.
+func (m *Marker) EmbedJSON(recipient string, data []byte) ([]byte, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	marked := 0
+	doc = m.embedValue(recipient, doc, &marked)
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return out, marked, nil
+}
+
+func (m *Marker) embedValue(recipient string, value any, marked *int) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			value[key] = m.embedValue(recipient, field, marked)
+		}
+	case []any:
+		for i, elem := range value {
+			value[i] = m.embedValue(recipient, elem, marked)
+		}
+	case json.Number:
+		v, err := value.Float64()
+		if err != nil {
+			return value
+		}
+		if _, _, ok := lattice(v); !ok {
+			return value
+		}
+		*marked++
+		return json.Number(strconv.FormatFloat(m.Embed(recipient, v), 'g', -1, 64))
+	}
+	return value
+}
+
+// Detection is the result of checking text for one recipient's watermark
+type Detection struct {
+	Recipient string  `json:"recipient"`
+	Values    int     `json:"values"`  // Markable numbers found
+	Matches   int     `json:"matches"` // Numbers carrying the recipient's bit
+	Score     float64 `json:"score"`   // Standard deviations above chance
+	Match     bool    `json:"match"`   // Score reached Threshold
+}
+
+// Detect scores the numbers in data, which may be JSON, CSV or any other
+// text, against recipient's watermark
+func (m *Marker) Detect(recipient string, data []byte) Detection {
+	detection := Detection{Recipient: recipient}
+	for _, token := range numberPattern.FindAll(data, -1) {
+		v, err := strconv.ParseFloat(string(token), 64)
+		if err != nil {
+			continue
+		}
+		q, exp, ok := lattice(v)
+		if !ok {
+			continue
+		}
+		detection.Values++
+		if q&1 == m.bit(recipient, v < 0, exp, q) {
+			detection.Matches++
+		}
+	}
+
+	if detection.Values > 0 {
+		n := float64(detection.Values)
+		detection.Score = (2*float64(detection.Matches) - n) / math.Sqrt(n)
+	}
+	detection.Match = detection.Score >= Threshold
+	return detection
+}
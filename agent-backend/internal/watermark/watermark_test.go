@@ -0,0 +1,145 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMarker(t *testing.T) *Marker {
+	t.Helper()
+	marker, err := NewMarker(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatalf("NewMarker() error = %v", err)
+	}
+	return marker
+}
+
+// weights returns n pseudo-random model weights
+func weights(n int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = rng.NormFloat64() * math.Pow(10, float64(rng.Intn(6)-3))
+	}
+	return values
+}
+
+func TestEmbedIsImperceptible(t *testing.T) {
+	marker := newTestMarker(t)
+	for _, v := range weights(1000) {
+		marked := marker.Embed("lease-1", v)
+		if rel := math.Abs(marked-v) / math.Abs(v); rel > 3e-6 {
+			t.Fatalf("Embed(%v) = %v, relative change %v", v, marked, rel)
+		}
+		if again := marker.Embed("lease-1", marked); again != marked {
+			t.Fatalf("Embed() is not idempotent: %v then %v", marked, again)
+		}
+	}
+
+	for _, v := range []float64{0, 3, -12, 1 << 20} {
+		if got := marker.Embed("lease-1", v); got != v {
+			t.Errorf("Embed(%v) = %v, want it unchanged", v, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	marker := newTestMarker(t)
+	values := weights(200)
+
+	var marked, plain strings.Builder
+	for _, v := range values {
+		fmt.Fprintf(&marked, "%v,", marker.Embed("lease-1", v))
+		fmt.Fprintf(&plain, "%v,", v)
+	}
+
+	if got := marker.Detect("lease-1", []byte(marked.String())); !got.Match || got.Matches != got.Values {
+		t.Errorf("Detect() marked = %+v, want a full match", got)
+	}
+	if got := marker.Detect("lease-2", []byte(marked.String())); got.Match {
+		t.Errorf("Detect() for another lease = %+v, want no match", got)
+	}
+	if got := marker.Detect("lease-1", []byte(plain.String())); got.Match {
+		t.Errorf("Detect() unmarked = %+v, want no match", got)
+	}
+
+	other, err := NewMarker(bytes.Repeat([]byte{8}, KeySize))
+	if err != nil {
+		t.Fatalf("NewMarker() error = %v", err)
+	}
+	if got := other.Detect("lease-1", []byte(marked.String())); got.Match {
+		t.Errorf("Detect() with another key = %+v, want no match", got)
+	}
+}
+
+func TestEmbedJSON(t *testing.T) {
+	marker := newTestMarker(t)
+	doc := map[string]any{
+		"samples": 1000,
+		"model":   map[string]any{"weights": weights(40), "bias": 0.125},
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	out, count, err := marker.EmbedJSON("job-1", data)
+	if err != nil {
+		t.Fatalf("EmbedJSON() error = %v", err)
+	}
+	if count != 41 {
+		t.Errorf("EmbedJSON() marked %d values, want 41", count)
+	}
+
+	var got struct {
+		Samples int `json:"samples"`
+		Model   struct {
+			Weights []float64 `json:"weights"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("EmbedJSON() produced invalid JSON: %v", err)
+	}
+	if got.Samples != 1000 || len(got.Model.Weights) != 40 {
+		t.Errorf("EmbedJSON() = %s, want integers and structure kept", out)
+	}
+
+	// The leak is detected after the weights are copied out as CSV
+	var csv strings.Builder
+	for _, w := range got.Model.Weights {
+		fmt.Fprintf(&csv, "%g\n", w)
+	}
+	if detection := marker.Detect("job-1", []byte(csv.String())); !detection.Match {
+		t.Errorf("Detect() on re-encoded weights = %+v, want a match", detection)
+	}
+
+	if _, _, err := marker.EmbedJSON("job-1", []byte("not json")); err == nil {
+		t.Error("EmbedJSON() accepted invalid JSON")
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "watermark.key")
+	key, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() error = %v", err)
+	}
+	again, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() reload error = %v", err)
+	}
+	if len(key) != KeySize || !bytes.Equal(key, again) {
+		t.Errorf("LoadOrCreateKey() = %x then %x, want the same %d-byte key", key, again, KeySize)
+	}
+
+	if _, err := NewMarker(key[:8]); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("NewMarker() short key error = %v, want ErrInvalidKey", err)
+	}
+}
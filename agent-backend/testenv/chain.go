@@ -0,0 +1,208 @@
+package testenv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/contracts"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// fundedBalance is what each fixture account starts with: 1000 ether
+var fundedBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1_000_000_000_000_000_000))
+
+// Account is a funded chain account with a fixed key
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+}
+
+// newAccount derives an account from a seed, so fixtures have the same
+// addresses on every run
+func newAccount(seed string) (*Account, error) {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("pandacea-testenv/" + seed)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s key: %w", seed, err)
+	}
+	return &Account{Key: key, Address: crypto.PubkeyToAddress(key.PublicKey)}, nil
+}
+
+// Chain is an in-process simulated chain running the LeaseAgreement
+// contract. Transactions are mined as soon as they are sent.
+type Chain struct {
+	Address common.Address // Where the LeaseAgreement contract is deployed
+
+	backend  *simulated.Backend
+	contract *contracts.LeaseAgreement
+	chainID  *big.Int
+	created  common.Hash
+
+	// mu serializes sending and mining so each call gets its own block
+	mu sync.Mutex
+}
+
+// newChain starts a simulated chain with the accounts funded and the lease
+// contract deployed by deployer
+func newChain(deployer, spender, earner *Account) (*Chain, error) {
+	alloc := types.GenesisAlloc{}
+	for _, account := range []*Account{deployer, spender, earner} {
+		alloc[account.Address] = types.Account{Balance: fundedBalance}
+	}
+
+	c := &Chain{backend: simulated.NewBackend(alloc)}
+	// fail stops the chain
+	fail := func(err error) (*Chain, error) {
+		c.backend.Close()
+		return nil, err
+	}
+	var err error
+	if c.chainID, err = c.backend.Client().ChainID(context.Background()); err != nil {
+		return fail(fmt.Errorf("failed to read chain ID: %w", err))
+	}
+	if err := c.deployContracts(deployer, []*Account{spender}, []*Account{earner}); err != nil {
+		return fail(err)
+	}
+	if c.contract, err = contracts.NewLeaseAgreement(c.Address, c.backend.Client()); err != nil {
+		return fail(fmt.Errorf("failed to bind lease agreement contract: %w", err))
+	}
+	parsed, err := contracts.LeaseAgreementMetaData.GetAbi()
+	if err != nil {
+		return fail(fmt.Errorf("failed to parse lease agreement ABI: %w", err))
+	}
+	c.created = parsed.Events["LeaseCreated"].ID
+	return c, nil
+}
+
+// Client returns a client for the chain, for contract bindings and the
+// event listener
+func (c *Chain) Client() simulated.Client {
+	return c.backend.Client()
+}
+
+// Dial is a chain.DialFunc for the event listener. The client is wrapped so
+// the listener dropping its connection leaves the chain running.
+func (c *Chain) Dial(ctx context.Context) (chain.Client, error) {
+	client := c.backend.Client()
+	return struct {
+		chain.Client
+		ethereum.ChainIDReader
+	}{client, client}, nil
+}
+
+// Close stops the chain
+func (c *Chain) Close() error {
+	return c.backend.Close()
+}
+
+// MinPrice implements pricing.MinPriceReader
+func (c *Chain) MinPrice(ctx context.Context) (*big.Int, error) {
+	return c.contract.MINPRICE(&bind.CallOpts{Context: ctx})
+}
+
+// LeaseProduct implements chain.LeaseProductReader
+func (c *Chain) LeaseProduct(ctx context.Context, leaseID [32]byte) (string, error) {
+	lease, err := c.contract.GetLease(&bind.CallOpts{Context: ctx}, leaseID)
+	if err != nil {
+		return "", err
+	}
+	return chain.DecodeProductID(lease.DataProductId), nil
+}
+
+// CreateLease has spender create a lease with earner on product, paying
+// price wei, and returns the lease ID as 0x-prefixed hex. The product ID is
+// stored on chain as right-padded ASCII, so it is at most 32 bytes.
+func (c *Chain) CreateLease(spender, earner *Account, product string, price *big.Int) (string, error) {
+	if len(product) > 32 {
+		return "", fmt.Errorf("product ID %q is longer than 32 bytes", product)
+	}
+	var productID [32]byte
+	copy(productID[:], product)
+
+	receipt, err := c.transact(spender, price, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.CreateLease(opts, earner.Address, productID, price)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create lease: %w", err)
+	}
+	for _, log := range receipt.Logs {
+		if len(log.Topics) > 1 && log.Topics[0] == c.created {
+			return log.Topics[1].Hex(), nil
+		}
+	}
+	return "", errors.New("failed to create lease: no LeaseCreated event")
+}
+
+// ApproveLease has earner approve a lease
+func (c *Chain) ApproveLease(earner *Account, leaseID string) error {
+	_, err := c.transact(earner, nil, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.ApproveLease(opts, common.HexToHash(leaseID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to approve lease: %w", err)
+	}
+	return nil
+}
+
+// ExecuteLease marks an approved lease executed
+func (c *Chain) ExecuteLease(from *Account, leaseID string) error {
+	_, err := c.transact(from, nil, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.ExecuteLease(opts, common.HexToHash(leaseID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute lease: %w", err)
+	}
+	return nil
+}
+
+// DisputeLease has spender dispute a lease, staking PGT from the allowance
+// the spender was given at deployment
+func (c *Chain) DisputeLease(spender *Account, leaseID, reason string) error {
+	_, err := c.transact(spender, nil, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contract.RaiseDispute(opts, common.HexToHash(leaseID), reason)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dispute lease: %w", err)
+	}
+	return nil
+}
+
+// transact sends a transaction from an account, mines it and returns its
+// receipt. Reverted transactions are errors.
+func (c *Chain) transact(from *Account, value *big.Int, send func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	opts, err := bind.NewKeyedTransactorWithChainID(from.Key, c.chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Value = value
+	// Reverts are reported by the receipt rather than by gas estimation
+	opts.GasLimit = 500_000
+
+	tx, err := send(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.backend.Commit()
+
+	receipt, err := c.backend.Client().TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, errors.New("transaction reverted")
+	}
+	return receipt, nil
+}
@@ -0,0 +1,66 @@
+// Command testenv runs an agent against deterministic stand-ins for the
+// chain, IPFS and the container runtime, for SDK and integration test
+// suites. It prints the fixture as JSON on stdout once the agent is
+// serving and runs until interrupted.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"pandacea/agent-backend/testenv"
+)
+
+func main() {
+	controlAddr := flag.String("control-addr", "127.0.0.1:8099", "Address to serve the control API on")
+	stateDir := flag.String("state-dir", "", "Directory for agent state (default a temporary directory)")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	env, err := testenv.New(testenv.Options{Logger: logger, StateDir: *stateDir})
+	if err != nil {
+		logger.Error("failed to start test environment", "error", err)
+		os.Exit(1)
+	}
+	defer env.Close()
+
+	ln, err := net.Listen("tcp", *controlAddr)
+	if err != nil {
+		logger.Error("failed to listen for control API", "error", err, "addr", *controlAddr)
+		os.Exit(1)
+	}
+	control := &http.Server{Handler: env.ControlHandler()}
+	go func() {
+		if err := control.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("control API stopped", "error", err)
+		}
+	}()
+	defer control.Close()
+
+	fixture, err := env.Fixture()
+	if err != nil {
+		logger.Error("failed to build fixture", "error", err)
+		os.Exit(1)
+	}
+	out := struct {
+		testenv.Fixture
+		ControlURL string `json:"control_url"`
+	}{fixture, "http://" + ln.Addr().String()}
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		logger.Error("failed to write fixture", "error", err)
+		os.Exit(1)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigChan
+	logger.Info("received shutdown signal", "signal", sig)
+}
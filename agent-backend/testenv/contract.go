@@ -0,0 +1,140 @@
+package testenv
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The simulated chain runs the contracts compiled from contracts/src:
+// LeaseAgreement and the Reputation and PGT contracts it is constructed
+// with. contracts/generate_bindings.sh exports each contract's ABI and
+// creation bytecode from the Foundry build to artifacts/, where they are
+// embedded. Regenerate them whenever the contracts change.
+
+//go:embed artifacts
+var artifacts embed.FS
+
+// ErrNoArtifacts is returned when the contract artifacts have not been
+// generated
+var ErrNoArtifacts = errors.New("contract artifacts not generated; run contracts/generate_bindings.sh")
+
+// minPriceWei is the contract's MIN_PRICE
+var minPriceWei = big.NewInt(1_000_000_000_000_000)
+
+// Setup amounts, in the smallest unit
+var (
+	// initialReputation is the score earners start with
+	initialReputation = big.NewInt(500)
+	// stakeAllowance is the PGT spenders hold and let the lease contract
+	// take as dispute stake: 1000 PGT
+	stakeAllowance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1_000_000_000_000_000_000))
+)
+
+// deployGasLimit covers creating the largest contract
+const deployGasLimit = 8_000_000
+
+// artifact is a compiled contract exported from the Foundry build
+type artifact struct {
+	abi      abi.ABI
+	bytecode []byte
+}
+
+// loadArtifact reads the named contract's artifact
+func loadArtifact(name string) (*artifact, error) {
+	data, err := artifacts.ReadFile("artifacts/" + name + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoArtifacts, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s artifact: %w", name, err)
+	}
+
+	var raw struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode %s artifact: %w", name, err)
+	}
+	parsed, err := abi.JSON(bytes.NewReader(raw.ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s ABI: %w", name, err)
+	}
+	code, err := hexutil.Decode(raw.Bytecode)
+	if err != nil || len(code) == 0 {
+		return nil, fmt.Errorf("%s artifact has no bytecode", name)
+	}
+	return &artifact{abi: parsed, bytecode: code}, nil
+}
+
+// deploy has from create a contract from its artifact and returns its
+// address and the contract bound there
+func (c *Chain) deploy(from *Account, name string, params ...any) (common.Address, *bind.BoundContract, error) {
+	a, err := loadArtifact(name)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	receipt, err := c.transact(from, nil, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		opts.GasLimit = deployGasLimit
+		_, tx, _, err := bind.DeployContract(opts, a.abi, a.bytecode, c.backend.Client(), params...)
+		return tx, err
+	})
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to deploy %s: %w", name, err)
+	}
+	client := c.backend.Client()
+	return receipt.ContractAddress, bind.NewBoundContract(receipt.ContractAddress, a.abi, client, client, client), nil
+}
+
+// deployContracts deploys the lease contract and the contracts it calls.
+// Earners get a reputation, so disputes against them can be raised, and
+// spenders get PGT the lease contract may take as dispute stake.
+func (c *Chain) deployContracts(deployer *Account, spenders, earners []*Account) error {
+	reputationAddress, reputation, err := c.deploy(deployer, "Reputation")
+	if err != nil {
+		return err
+	}
+	pgtAddress, pgt, err := c.deploy(deployer, "PGT")
+	if err != nil {
+		return err
+	}
+	// The deployer is the DAO treasury forfeited stakes go to
+	if c.Address, _, err = c.deploy(deployer, "LeaseAgreement", reputationAddress, pgtAddress, deployer.Address); err != nil {
+		return err
+	}
+
+	call := func(from *Account, contract *bind.BoundContract, method string, params ...any) error {
+		_, err := c.transact(from, nil, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return contract.Transact(opts, method, params...)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to call %s: %w", method, err)
+		}
+		return nil
+	}
+	for _, earner := range earners {
+		if err := call(deployer, reputation, "initializeReputation", earner.Address, initialReputation); err != nil {
+			return err
+		}
+	}
+	for _, spender := range spenders {
+		if err := call(deployer, pgt, "mint", spender.Address, stakeAllowance); err != nil {
+			return err
+		}
+		if err := call(spender, pgt, "approve", c.Address, stakeAllowance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
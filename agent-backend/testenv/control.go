@@ -0,0 +1,122 @@
+package testenv
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// Fixture describes a running Env to test suites outside the process
+type Fixture struct {
+	AgentURL        string `json:"agent_url"`
+	AgentPeerID     string `json:"agent_peer_id"`
+	IPFSURL         string `json:"ipfs_url"`
+	ContractAddress string `json:"contract_address"`
+	MinPriceWei     string `json:"min_price_wei"`
+	SpenderAddress  string `json:"spender_address"`
+	SpenderPeerID   string `json:"spender_peer_id"`
+	SpenderKey      string `json:"spender_key"` // Base64 marshalled libp2p private key
+	EarnerAddress   string `json:"earner_address"`
+}
+
+// Fixture returns the environment's fixture values
+func (e *Env) Fixture() (Fixture, error) {
+	key, err := crypto.MarshalPrivateKey(e.SpenderKey)
+	if err != nil {
+		return Fixture{}, fmt.Errorf("failed to marshal spender key: %w", err)
+	}
+	return Fixture{
+		AgentURL:        e.URL,
+		AgentPeerID:     e.AgentPeerID,
+		IPFSURL:         e.IPFS.URL(),
+		ContractAddress: e.Chain.Address.Hex(),
+		MinPriceWei:     minPriceWei.String(),
+		SpenderAddress:  e.Spender.Address.Hex(),
+		SpenderPeerID:   e.SpenderPeerID(),
+		SpenderKey:      base64.StdEncoding.EncodeToString(key),
+		EarnerAddress:   e.Earner.Address.Hex(),
+	}, nil
+}
+
+// ControlLeaseRequest creates a lease through the control API
+type ControlLeaseRequest struct {
+	ProductID string `json:"product_id"`
+	Pending   bool   `json:"pending,omitempty"` // Leave the lease unapproved
+}
+
+// ControlDisputeRequest disputes a lease through the control API
+type ControlDisputeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ControlHandler serves the control API suites outside the process use to
+// act on the chain, which they cannot reach directly:
+//
+//	GET  /fixture                    the Fixture
+//	POST /leases                     create a lease as the spender; returns {"lease_id"}
+//	POST /leases/{leaseId}/approve   approve a lease as the earner
+//	POST /leases/{leaseId}/execute   mark an approved lease executed
+//	POST /leases/{leaseId}/dispute   dispute a lease as the spender
+//
+// Scripts are published through the IPFS stub's /api/v0/add.
+func (e *Env) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /fixture", func(w http.ResponseWriter, r *http.Request) {
+		fixture, err := e.Fixture()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, fixture)
+	})
+	mux.HandleFunc("POST /leases", func(w http.ResponseWriter, r *http.Request) {
+		var req ControlLeaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProductID == "" {
+			http.Error(w, "product_id is required", http.StatusBadRequest)
+			return
+		}
+		leaseID, err := e.Chain.CreateLease(e.Spender, e.Earner, req.ProductID, minPriceWei)
+		if err == nil && !req.Pending {
+			err = e.Chain.ApproveLease(e.Earner, leaseID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"lease_id": leaseID})
+	})
+	mux.HandleFunc("POST /leases/{leaseId}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		leaseID := r.PathValue("leaseId")
+		var err error
+		switch r.PathValue("action") {
+		case "approve":
+			err = e.Chain.ApproveLease(e.Earner, leaseID)
+		case "execute":
+			err = e.Chain.ExecuteLease(e.Earner, leaseID)
+		case "dispute":
+			var req ControlDisputeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			err = e.Chain.DisputeLease(e.Spender, leaseID, strings.TrimSpace(req.Reason))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
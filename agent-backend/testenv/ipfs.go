@@ -0,0 +1,97 @@
+package testenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// IPFS is an in-memory stand-in for the IPFS HTTP API. It serves the
+// /api/v0/cat, /api/v0/add and /api/v0/version calls the agent and its
+// clients make.
+type IPFS struct {
+	server *httptest.Server
+
+	mu      sync.RWMutex
+	content map[string][]byte
+}
+
+// newIPFS starts the IPFS stub on a loopback port
+func newIPFS() *IPFS {
+	ipfs := &IPFS{content: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/cat", ipfs.handleCat)
+	mux.HandleFunc("/api/v0/add", ipfs.handleAdd)
+	mux.HandleFunc("/api/v0/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Version":"testenv"}`))
+	})
+	ipfs.server = httptest.NewServer(mux)
+	return ipfs
+}
+
+// URL returns the API URL to configure as ipfs.api_url
+func (i *IPFS) URL() string {
+	return i.server.URL
+}
+
+// Add stores content and returns its CID. CIDs are base58 SHA-256
+// multihashes of the raw content, so the same content always gets the same
+// CID; they do not match what a real IPFS node assigns to chunked files.
+func (i *IPFS) Add(content []byte) (string, error) {
+	sum, err := multihash.Sum(content, multihash.SHA2_256, -1)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash content: %w", err)
+	}
+	cid := sum.B58String()
+
+	i.mu.Lock()
+	i.content[cid] = append([]byte(nil), content...)
+	i.mu.Unlock()
+	return cid, nil
+}
+
+// Close stops the stub
+func (i *IPFS) Close() {
+	i.server.Close()
+}
+
+// handleCat serves /api/v0/cat?arg=<cid>
+func (i *IPFS) handleCat(w http.ResponseWriter, r *http.Request) {
+	i.mu.RLock()
+	content, ok := i.content[r.URL.Query().Get("arg")]
+	i.mu.RUnlock()
+	if !ok {
+		http.Error(w, `{"Message":"merkledag: not found","Code":0,"Type":"error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(content)
+}
+
+// handleAdd serves /api/v0/add with the content as a multipart file
+func (i *IPFS) handleAdd(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
+	cid, err := i.Add(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Hash": cid, "Size": fmt.Sprint(len(content))})
+}
@@ -0,0 +1,112 @@
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Computation stands in for the Python process a computation container
+// runs. It gets the host directory the agent staged the container's
+// workspace in, holding computation.py and datasite.py; files it writes to
+// its artifacts subdirectory are returned as result artifacts. It returns
+// the process output.
+type Computation func(workspace string) (string, error)
+
+// DefaultComputation prints what the datasite script prints on success
+func DefaultComputation(workspace string) (string, error) {
+	if _, err := os.Stat(filepath.Join(workspace, "computation.py")); err != nil {
+		return "", fmt.Errorf("computation script not staged: %w", err)
+	}
+	return "Computation completed successfully\n", nil
+}
+
+// Runtime is a privacy.ContainerRuntime that runs computations in process
+// instead of in Docker containers
+type Runtime struct {
+	compute Computation
+
+	mu         sync.Mutex
+	next       int
+	workspaces map[string]string // Container ID to staged workspace
+}
+
+// newRuntime creates a runtime running compute
+func newRuntime(compute Computation) *Runtime {
+	if compute == nil {
+		compute = DefaultComputation
+	}
+	return &Runtime{compute: compute, workspaces: make(map[string]string)}
+}
+
+// Create implements privacy.ContainerRuntime. Container IDs count up from
+// container-1.
+func (rt *Runtime) Create() (string, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.next++
+	id := fmt.Sprintf("container-%d", rt.next)
+	rt.workspaces[id] = ""
+	return id, nil
+}
+
+// Remove implements privacy.ContainerRuntime
+func (rt *Runtime) Remove(id string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.workspaces[id]; !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	delete(rt.workspaces, id)
+	return nil
+}
+
+// Clean implements privacy.ContainerRuntime
+func (rt *Runtime) Clean(id string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.workspaces[id]; !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	rt.workspaces[id] = ""
+	return nil
+}
+
+// CopyTo implements privacy.ContainerRuntime. Copies to /workspace stage
+// the computation; other copies are accepted and ignored.
+func (rt *Runtime) CopyTo(id, srcPath, destPath string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.workspaces[id]; !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	if destPath == "/workspace" {
+		rt.workspaces[id] = srcPath
+	}
+	return nil
+}
+
+// Exec implements privacy.ContainerRuntime by running the computation
+// against the container's staged workspace
+func (rt *Runtime) Exec(ctx context.Context, id string, args ...string) ([]byte, error) {
+	rt.mu.Lock()
+	workspace, ok := rt.workspaces[id]
+	rt.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+	if workspace == "" {
+		return nil, fmt.Errorf("container %s has no staged workspace", id)
+	}
+	output, err := rt.compute(workspace)
+	return []byte(output), err
+}
+
+// Containers returns how many containers are alive
+func (rt *Runtime) Containers() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.workspaces)
+}
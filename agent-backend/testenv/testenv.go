@@ -0,0 +1,390 @@
+// Package testenv runs a complete agent in process against deterministic
+// stand-ins for its dependencies: a simulated chain running the compiled
+// LeaseAgreement contract, an in-memory IPFS API and a container runtime
+// that runs computations as Go functions. Integration tests and SDK test
+// suites use it to drive lease, computation and result flows without
+// Docker, a chain node or an IPFS daemon.
+//
+// Fixture accounts and keys are derived from fixed seeds, so addresses and
+// peer IDs are the same on every run. The package is a module of its own,
+// so the simulated chain it links stays out of the agent's build.
+package testenv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pandacea/agent-backend/internal/api"
+	"pandacea/agent-backend/internal/chain"
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/jobs"
+	"pandacea/agent-backend/internal/p2p"
+	"pandacea/agent-backend/internal/policy"
+	"pandacea/agent-backend/internal/pricing"
+	"pandacea/agent-backend/internal/privacy"
+	"pandacea/agent-backend/internal/reputation"
+	"pandacea/agent-backend/internal/reqsig"
+	"pandacea/agent-backend/internal/respsig"
+	"pandacea/agent-backend/internal/scheduler"
+	"pandacea/agent-backend/internal/security"
+
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// securityConfig keeps the agent's abuse controls out of the way of tests
+const securityConfig = `rate_limits:
+  per_ip_rps: 1000
+  per_identity_rps: 1000
+  burst: 1000
+quotas:
+  concurrent_jobs_per_identity: 16
+backpressure:
+  cpu_high_watermark: 100
+  mem_high_watermark_mb: 1048576
+queue:
+  max_size: 100
+request_limits:
+  max_body_size_mb: 10
+  max_header_size_kb: 8
+auth:
+  challenge_timeout_seconds: 300
+  nonce_length: 32
+  signature_max_skew_seconds: 300
+  allow_legacy_signatures: false
+`
+
+// Options configure an Env
+type Options struct {
+	Logger      *slog.Logger // Defaults to discarding logs
+	Computation Computation  // Defaults to DefaultComputation
+	StateDir    string       // Defaults to a temporary directory removed by Close
+}
+
+// Env is a running agent and the stand-ins it is wired to
+type Env struct {
+	URL         string // Agent API base URL, without /api/v1
+	AgentPeerID string // Peer ID agent responses are signed with
+
+	Chain   *Chain
+	IPFS    *IPFS
+	Runtime *Runtime
+
+	Spender    *Account       // Funded account that creates leases
+	SpenderKey crypto.PrivKey // libp2p key spender requests are signed with
+	Earner     *Account       // Funded account that approves leases
+
+	server    *api.Server
+	privacy   privacy.PrivacyService
+	security  *security.SecurityService
+	scheduler *scheduler.Scheduler
+	earnings  *earnings.Ledger
+	cancel    context.CancelFunc
+	served    chan struct{}
+	stateDir  string
+	ownsState bool
+	client    *http.Client
+}
+
+// New starts an agent wired to a fresh chain, IPFS stub and runtime
+func New(opts Options) (*Env, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	env := &Env{stateDir: opts.StateDir, client: &http.Client{Timeout: 30 * time.Second}}
+	var err error
+	if env.stateDir == "" {
+		if env.stateDir, err = os.MkdirTemp("", "pandacea-testenv-*"); err != nil {
+			return nil, fmt.Errorf("failed to create state directory: %w", err)
+		}
+		env.ownsState = true
+	}
+	// fail stops whatever has been started
+	fail := func(err error) (*Env, error) {
+		env.Close()
+		return nil, err
+	}
+
+	if env.Spender, err = newAccount("spender"); err != nil {
+		return fail(err)
+	}
+	if env.Earner, err = newAccount("earner"); err != nil {
+		return fail(err)
+	}
+	deployer, err := newAccount("deployer")
+	if err != nil {
+		return fail(err)
+	}
+	if env.SpenderKey, err = newPeerKey("spender"); err != nil {
+		return fail(err)
+	}
+	agentKey, err := newPeerKey("agent")
+	if err != nil {
+		return fail(err)
+	}
+
+	if env.Chain, err = newChain(deployer, env.Spender, env.Earner); err != nil {
+		return fail(err)
+	}
+	env.IPFS = newIPFS()
+	env.Runtime = newRuntime(opts.Computation)
+
+	cfg, err := config.Load("", config.ProfileDev)
+	if err != nil {
+		return fail(fmt.Errorf("failed to load default config: %w", err))
+	}
+	cfg.Server.MinPrice = "0.001" // The contract's MIN_PRICE, in ether
+	cfg.Blockchain.ContractAddress = env.Chain.Address.Hex()
+	cfg.Blockchain.ChainID = env.Chain.chainID.Uint64()
+	cfg.Blockchain.StartBlock = 1 // Replay leases created before the listener connects
+	cfg.Blockchain.HeadPollSeconds = 1
+	cfg.IPFS.APIURL = env.IPFS.URL()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env.cancel = cancel
+
+	pricer, err := pricing.NewPricer(logger, cfg.Server.MinPrice, cfg.Pricing)
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize pricer: %w", err))
+	}
+	go pricer.Run(ctx, env.Chain, time.Duration(cfg.Pricing.RefreshSeconds)*time.Second)
+	policyEngine, err := policy.NewEvaluator(ctx, logger, cfg, pricer)
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize policy engine: %w", err))
+	}
+
+	env.privacy, err = privacy.NewPrivacyService(logger, env.Chain.Client(), env.Chain.Address,
+		filepath.Join(env.stateDir, "data"), cfg.Pool.Size, env.IPFS.URL(), nil)
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize privacy service: %w", err))
+	}
+	env.privacy.(privacy.ContainerRunner).UseContainerRuntime(env.Runtime)
+	if err := env.privacy.(privacy.NetworkRegistry).AddNetwork(config.DefaultNetworkName, env.Chain.Client(), env.Chain.Address); err != nil {
+		return fail(err)
+	}
+	if err := env.privacy.Start(); err != nil {
+		env.privacy = nil
+		return fail(fmt.Errorf("failed to start privacy service: %w", err))
+	}
+
+	securityPath := filepath.Join(env.stateDir, "security.yaml")
+	if err := os.WriteFile(securityPath, []byte(securityConfig), 0644); err != nil {
+		return fail(fmt.Errorf("failed to write security config: %w", err))
+	}
+	if env.security, err = security.NewSecurityService(securityPath, logger); err != nil {
+		return fail(fmt.Errorf("failed to initialize security service: %w", err))
+	}
+
+	env.server = api.NewServer(policyEngine, logger, &p2p.Node{}, env.privacy, env.security)
+	signer, err := respsig.NewSigner(agentKey)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create response signer: %w", err))
+	}
+	env.server.SetResponseSigner(signer)
+	env.AgentPeerID = signer.PeerID()
+	trainingStore, err := jobs.NewFileStore(filepath.Join(env.stateDir, "jobs", "training"))
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize training job store: %w", err))
+	}
+	if err := env.server.SetJobStore(trainingStore); err != nil {
+		return fail(fmt.Errorf("failed to restore training jobs: %w", err))
+	}
+	tracker, err := reputation.NewTracker(cfg.Server.ReputationWeight, cfg.Server.ReputationDecayRate, filepath.Join(env.stateDir, "reputation.json"))
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize reputation tracker: %w", err))
+	}
+	env.server.SetReputationTracker(tracker)
+	env.server.SetPricer(pricer)
+	env.server.SetProfile(cfg.Profile, cfg.Hardening)
+	env.server.SetTrainingConfig(cfg.Training)
+	env.scheduler = scheduler.New(cfg.Scheduler.Workers, cfg.Scheduler.MaxQueued, cfg.Scheduler.MaxQueuedPerIdentity, logger)
+	env.server.SetScheduler(env.scheduler, cfg.Scheduler)
+
+	env.server.SetBlockchain(cfg.Blockchain)
+	status := chain.NewListenerStatus(config.DefaultNetworkName, cfg.Blockchain.MaxLagBlocks)
+	env.server.AddListenerStatus(status)
+	listener, err := chain.NewListener(cfg.Blockchain, env.Chain.Dial, env.server, tracker, status, logger)
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize event listener: %w", err))
+	}
+	env.earnings, err = earnings.NewLedger(cfg.Server.RoyaltyPercentage, filepath.Join(env.stateDir, "earnings.json"))
+	if err != nil {
+		return fail(fmt.Errorf("failed to initialize earnings ledger: %w", err))
+	}
+	env.server.SetEarnings(env.earnings)
+	listener.SetEarnings(env.earnings, env.Chain)
+	listener.SetEarner(env.Earner.Address)
+	go listener.Run(ctx)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fail(fmt.Errorf("failed to listen: %w", err))
+	}
+	env.URL = "http://" + ln.Addr().String()
+	env.served = make(chan struct{})
+	go func() {
+		defer close(env.served)
+		if err := env.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("test agent stopped serving", "error", err)
+		}
+	}()
+
+	return env, nil
+}
+
+// newPeerKey derives a libp2p Ed25519 key from a seed
+func newPeerKey(seed string) (crypto.PrivKey, error) {
+	priv := ed25519.NewKeyFromSeed(gethcrypto.Keccak256([]byte("pandacea-testenv/peer/" + seed)))
+	key, err := crypto.UnmarshalEd25519PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s peer key: %w", seed, err)
+	}
+	return key, nil
+}
+
+// Close stops the agent and its stand-ins and removes the state directory
+// if New created it
+func (e *Env) Close() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	if e.served != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		e.server.Shutdown(ctx)
+		cancel()
+		<-e.served
+	}
+	if e.scheduler != nil {
+		e.scheduler.Close()
+	}
+	if e.privacy != nil {
+		e.privacy.Stop()
+	}
+	if e.security != nil {
+		e.security.Shutdown()
+	}
+	if e.IPFS != nil {
+		e.IPFS.Close()
+	}
+	if e.Chain != nil {
+		e.Chain.Close()
+	}
+	if e.ownsState {
+		os.RemoveAll(e.stateDir)
+	}
+}
+
+// SpenderPeerID returns the peer ID spender requests are signed as
+func (e *Env) SpenderPeerID() string {
+	id, _ := peer.IDFromPrivateKey(e.SpenderKey)
+	return id.String()
+}
+
+// Do sends an /api/v1 request as the spender: signed with SpenderKey and
+// carrying the spender's address. The response signature is checked against
+// AgentPeerID. A 2xx response body is decoded into out if out is not nil;
+// other statuses are returned as errors carrying the body.
+func (e *Env) Do(method, path string, in, out any) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	req, err := http.NewRequest(method, e.URL+"/api/v1"+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Pandacea-Spender-Address", e.Spender.Address.Hex())
+	if err := reqsig.Sign(e.SpenderKey, req, body, time.Now()); err != nil {
+		return 0, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	data, err := respsig.VerifyResponse(resp, e.AgentPeerID)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to verify response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Lease creates a lease from Spender to Earner on product at the contract's
+// minimum price, has the earner approve it and returns its ID
+func (e *Env) Lease(product string) (string, error) {
+	leaseID, err := e.Chain.CreateLease(e.Spender, e.Earner, product, minPriceWei)
+	if err != nil {
+		return "", err
+	}
+	if err := e.Chain.ApproveLease(e.Earner, leaseID); err != nil {
+		return "", err
+	}
+	return leaseID, nil
+}
+
+// Publish adds a computation script to IPFS and returns its CID
+func (e *Env) Publish(script string) (string, error) {
+	return e.IPFS.Add([]byte(script))
+}
+
+// Compute queues a computation of the script at cid on a lease and returns
+// the computation ID
+func (e *Env) Compute(leaseID, cid string, inputs ...privacy.DataInput) (string, error) {
+	var resp privacy.ComputationResponse
+	_, err := e.Do("POST", "/privacy/execute", privacy.ComputationRequest{
+		LeaseID:        leaseID,
+		ComputationCid: cid,
+		Inputs:         inputs,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ComputationID, nil
+}
+
+// WaitResult polls a computation until it completes or fails, or ctx is done
+func (e *Env) WaitResult(ctx context.Context, computationID string) (*privacy.ComputationResult, error) {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var result privacy.ComputationResult
+		if _, err := e.Do("GET", "/privacy/results/"+computationID, nil, &result); err != nil {
+			return nil, err
+		}
+		if result.Status != string(jobs.StatePending) {
+			return &result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("computation %s still pending: %w", computationID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
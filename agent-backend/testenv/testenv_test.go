@@ -0,0 +1,219 @@
+package testenv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pandacea/agent-backend/internal/config"
+	"pandacea/agent-backend/internal/earnings"
+	"pandacea/agent-backend/internal/privacy"
+)
+
+func newTestEnv(t *testing.T, opts Options) *Env {
+	t.Helper()
+	opts.StateDir = t.TempDir()
+	env, err := New(opts)
+	if errors.Is(err, ErrNoArtifacts) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(env.Close)
+	return env
+}
+
+func TestLeaseComputeResult(t *testing.T) {
+	env := newTestEnv(t, Options{Computation: func(workspace string) (string, error) {
+		if err := os.MkdirAll(filepath.Join(workspace, "artifacts"), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(workspace, "artifacts", "summary.txt"), []byte("mean=4.2"), 0644); err != nil {
+			return "", err
+		}
+		return "done\n", nil
+	}})
+
+	leaseID, err := env.Lease("dataset-1")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	cid, err := env.Publish("print('hello')\n")
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	computationID, err := env.Compute(leaseID, cid, privacy.DataInput{AssetID: "dataset-1", VariableName: "df"})
+	if err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := env.WaitResult(ctx, computationID)
+	if err != nil {
+		t.Fatalf("WaitResult: %v", err)
+	}
+	if result.Status != "completed" {
+		t.Fatalf("status = %s (%s), want completed", result.Status, result.Error)
+	}
+	if result.Results.Output != "done\n" {
+		t.Errorf("output = %q, want %q", result.Results.Output, "done\n")
+	}
+	if _, ok := result.Results.Artifacts["summary.txt"]; !ok {
+		t.Errorf("artifacts = %v, want summary.txt", result.Results.Artifacts)
+	}
+}
+
+func TestComputeRejectsUnusableLeases(t *testing.T) {
+	env := newTestEnv(t, Options{})
+	cid, err := env.Publish("print('hello')\n")
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	input := privacy.DataInput{AssetID: "dataset-1", VariableName: "df"}
+
+	unapproved, err := env.Chain.CreateLease(env.Spender, env.Earner, "dataset-1", minPriceWei)
+	if err != nil {
+		t.Fatalf("CreateLease: %v", err)
+	}
+	if _, err := env.Compute(unapproved, cid, input); err == nil {
+		t.Error("computation on an unapproved lease was accepted")
+	}
+
+	disputed, err := env.Lease("dataset-1")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if err := env.Chain.DisputeLease(env.Spender, disputed, "stale data"); err != nil {
+		t.Fatalf("DisputeLease: %v", err)
+	}
+	if _, err := env.Compute(disputed, cid, input); err == nil {
+		t.Error("computation on a disputed lease was accepted")
+	}
+}
+
+func TestFixturesAreDeterministic(t *testing.T) {
+	first := newTestEnv(t, Options{})
+	second := newTestEnv(t, Options{})
+
+	if first.Spender.Address != second.Spender.Address || first.AgentPeerID != second.AgentPeerID {
+		t.Fatal("fixture accounts differ between environments")
+	}
+	a, err := first.Lease("dataset-1")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	b, err := second.Lease("dataset-1")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if a != b {
+		t.Errorf("lease IDs differ: %s and %s", a, b)
+	}
+}
+
+func TestControlHandler(t *testing.T) {
+	env := newTestEnv(t, Options{})
+	control := httptest.NewServer(env.ControlHandler())
+	defer control.Close()
+
+	resp, err := http.Get(control.URL + "/fixture")
+	if err != nil {
+		t.Fatalf("GET /fixture: %v", err)
+	}
+	var fixture Fixture
+	json.NewDecoder(resp.Body).Decode(&fixture)
+	resp.Body.Close()
+	if fixture.AgentURL != env.URL || fixture.SpenderPeerID != env.SpenderPeerID() {
+		t.Fatalf("fixture = %+v", fixture)
+	}
+
+	resp, err = http.Post(control.URL+"/leases", "application/json", strings.NewReader(`{"product_id":"dataset-1","pending":true}`))
+	if err != nil {
+		t.Fatalf("POST /leases: %v", err)
+	}
+	var created struct {
+		LeaseID string `json:"lease_id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.LeaseID == "" {
+		t.Fatalf("POST /leases returned %d, lease %q", resp.StatusCode, created.LeaseID)
+	}
+
+	for _, step := range []struct {
+		action string
+		want   int
+	}{
+		{"execute", http.StatusConflict}, // Not approved yet
+		{"approve", http.StatusNoContent},
+		{"execute", http.StatusNoContent},
+	} {
+		resp, err := http.Post(control.URL+"/leases/"+created.LeaseID+"/"+step.action, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", step.action, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != step.want {
+			t.Errorf("%s returned %d, want %d", step.action, resp.StatusCode, step.want)
+		}
+	}
+}
+
+func TestExecutedLeaseIsBooked(t *testing.T) {
+	env := newTestEnv(t, Options{})
+	leaseID, err := env.Lease("dataset-1")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if err := env.Chain.ExecuteLease(env.Earner, leaseID); err != nil {
+		t.Fatalf("ExecuteLease: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		report := env.earnings.Report(earnings.Query{})
+		if report.Total.Leases == 1 {
+			if len(report.Products) != 1 || report.Products[0].ProductID != "dataset-1" || report.Total.Gross != minPriceWei.String() {
+				t.Errorf("report = %+v", report)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("executed lease was not booked")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestLeasesAreVerifiedOnTheProductNetwork(t *testing.T) {
+	env := newTestEnv(t, Options{})
+	env.server.SetBlockchain(config.BlockchainConfig{ProductNetworks: map[string]string{"dataset-2": "amoy"}})
+
+	cid, err := env.Publish("print('hello')\n")
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	for _, product := range []string{"dataset-1", "dataset-2"} {
+		leaseID, err := env.Lease(product)
+		if err != nil {
+			t.Fatalf("Lease: %v", err)
+		}
+		_, err = env.Compute(leaseID, cid, privacy.DataInput{AssetID: product, VariableName: "df"})
+		// Only the default network is registered with the privacy service
+		if product == "dataset-1" && err != nil {
+			t.Errorf("computation on the default network was refused: %v", err)
+		}
+		if product == "dataset-2" && (err == nil || !strings.Contains(err.Error(), "amoy")) {
+			t.Errorf("computation on an unregistered network: err = %v", err)
+		}
+	}
+}
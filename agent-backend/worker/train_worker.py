@@ -18,7 +18,7 @@ from datetime import datetime
 from typing import Dict, Any, Optional
 import argparse
 
-# Try to import PySyft, fall back to mock if not available
+# Try to import PySyft; without it only mock training can run
 try:
     import torch
     import torch.nn as nn
@@ -30,7 +30,7 @@ try:
     PYSYFT_AVAILABLE = True
 except ImportError:
     PYSYFT_AVAILABLE = False
-    print("Warning: PySyft not available, will use mock training")
+    print("Warning: PySyft not available, only mock training can run")
     # Define dummy classes for type hints when PySyft is not available
     class DataLoader:
         pass
@@ -82,12 +82,109 @@ logging.basicConfig(
 )
 logger = logging.getLogger(__name__)
 
+def emit_progress(epoch: int, epochs: int, loss: float, samples_processed: int):
+    """Report training progress to the agent as a single-line JSON object on stderr."""
+    print(json.dumps({
+        'type': 'progress',
+        'epoch': epoch,
+        'epochs': epochs,
+        'loss': loss,
+        'samples_processed': samples_processed
+    }), file=sys.stderr, flush=True)
+
+def decode_model(encoded: str) -> np.ndarray:
+    """Decode a federation model: base64 little-endian float32 weights."""
+    weights = np.frombuffer(base64.b64decode(encoded), dtype='<f4')
+    if weights.size == 0:
+        raise ValueError('initial model is empty')
+    return weights.astype(np.float32)
+
+def encode_model(weights: np.ndarray) -> str:
+    """Encode weights in the format federation models are exchanged in."""
+    return base64.b64encode(np.asarray(weights, dtype='<f4').tobytes()).decode('utf-8')
+
+class Checkpointer:
+    """Writes per-epoch checkpoints and the manifest the agent resumes jobs from.
+
+    Each checkpoint file is written in full before the manifest, which is
+    replaced atomically, so every manifest entry refers to a complete file.
+    Only the newest `keep` checkpoints are kept.
+    """
+
+    MANIFEST = 'manifest.json'
+
+    def __init__(self, directory: Optional[str], job_id: str, keep: int = 3):
+        self.directory = directory
+        self.job_id = job_id
+        self.keep = keep
+        if directory:
+            os.makedirs(directory, exist_ok=True)
+
+    def _manifest(self) -> Dict[str, Any]:
+        try:
+            with open(os.path.join(self.directory, self.MANIFEST)) as f:
+                return json.load(f)
+        except FileNotFoundError:
+            return {'job_id': self.job_id, 'checkpoints': []}
+
+    def save(self, epoch: int, samples_processed: int, state: bytes):
+        """Record the state reached at the end of an epoch."""
+        if not self.directory:
+            return
+        name = f'epoch-{epoch:04d}.ckpt'
+        with open(os.path.join(self.directory, name), 'wb') as f:
+            f.write(state)
+            f.flush()
+            os.fsync(f.fileno())
+
+        manifest = self._manifest()
+        manifest['job_id'] = self.job_id
+        entries = manifest.get('checkpoints', []) + [{
+            'epoch': epoch,
+            'file': name,
+            'sha256': hashlib.sha256(state).hexdigest(),
+            'samples_processed': samples_processed,
+            'created_at': datetime.utcnow().isoformat() + 'Z',
+        }]
+        pruned, manifest['checkpoints'] = entries[:-self.keep], entries[-self.keep:]
+
+        tmp = os.path.join(self.directory, self.MANIFEST + '.tmp')
+        with open(tmp, 'w') as f:
+            json.dump(manifest, f, indent=2)
+        os.replace(tmp, os.path.join(self.directory, self.MANIFEST))
+        for entry in pruned:
+            if entry['file'] != name:
+                try:
+                    os.remove(os.path.join(self.directory, os.path.basename(entry['file'])))
+                except FileNotFoundError:
+                    pass
+
+def load_checkpoint(path: Optional[str]) -> Optional[bytes]:
+    """Read the checkpoint a resumed job continues from, checking its manifest digest."""
+    if not path:
+        return None
+    directory, name = os.path.split(path)
+    with open(path, 'rb') as f:
+        state = f.read()
+    try:
+        with open(os.path.join(directory, Checkpointer.MANIFEST)) as f:
+            entries = json.load(f).get('checkpoints', [])
+    except FileNotFoundError:
+        entries = []
+    for entry in entries:
+        if entry.get('file') == name and entry.get('sha256') != hashlib.sha256(state).hexdigest():
+            raise ValueError(f'checkpoint {name} does not match its manifest digest')
+    return state
+
 class MockTrainer:
     """Mock trainer for development/testing when PySyft is not available."""
     
-    def __init__(self, job_config: Dict[str, Any]):
+    def __init__(self, job_config: Dict[str, Any], checkpointer: Optional[Checkpointer] = None,
+                 resume_state: Optional[bytes] = None):
         self.job_config = job_config
         self.seed = job_config.get('seed', 42)
+        self.checkpointer = checkpointer or Checkpointer(None, '')
+        self.start_epoch = json.loads(resume_state)['epoch'] if resume_state else 0
         random.seed(self.seed)
         np.random.seed(self.seed)
         
@@ -97,15 +194,27 @@ class MockTrainer:
         
         # Simulate training time
         import time
-        time.sleep(2)
+        epochs, n_samples = self.job_config.get('epochs', 10), 1000
+        for epoch in range(self.start_epoch, epochs):
+            time.sleep(0.2)
+            loss = 0.7 / (epoch + 1)
+            emit_progress(epoch + 1, epochs, loss, (epoch + 1) * n_samples)
+            state = json.dumps({'epoch': epoch + 1, 'loss': loss}).encode()
+            self.checkpointer.save(epoch + 1, (epoch + 1) * n_samples, state)
         
         # Generate mock results
-        epsilon = self.job_config.get('dp', {}).get('epsilon', 1.0)
+        dp_config = self.job_config.get('dp', {})
+        epsilon = dp_config.get('epsilon', 1.0)
         accuracy = 0.85 + random.uniform(-0.05, 0.05)
         
-        # Create mock model weights (small tensor)
-        model_weights = np.random.randn(100, 10).astype(np.float32)
-        model_str = base64.b64encode(model_weights.tobytes()).decode('utf-8')
+        # Create mock model weights (small tensor). A federation round
+        # nudges the global model it starts from instead.
+        if self.job_config.get('initial_model'):
+            initial = decode_model(self.job_config['initial_model'])
+            model_str = encode_model(initial + 0.01 * np.random.randn(initial.size))
+        else:
+            model_weights = np.random.randn(100, 10).astype(np.float32)
+            model_str = base64.b64encode(model_weights.tobytes()).decode('utf-8')
         
         return {
             'model': model_str,
@@ -116,13 +225,13 @@ class MockTrainer:
                 'enabled': True,
                 'epsilon': epsilon,
                 'clip': 1.0,
-                'noise_multiplier': 0.5,
+                'noise_multiplier': dp_config.get('noise_multiplier', 0.5),
                 'delta': 1e-5
             },
             'seed': self.seed,
             'training_params': {
-                'epochs': 10,
-                'batch_size': 32,
+                'epochs': epochs,
+                'batch_size': self.job_config.get('batch_size', 32),
                 'learning_rate': 0.01
             }
         }
@@ -130,9 +239,12 @@ class MockTrainer:
 class PySyftTrainer:
     """Real PySyft trainer for differential privacy training."""
     
-    def __init__(self, job_config: Dict[str, Any]):
+    def __init__(self, job_config: Dict[str, Any], checkpointer: Optional[Checkpointer] = None,
+                 resume_state: Optional[bytes] = None):
         self.job_config = job_config
         self.seed = job_config.get('seed', 42)
+        self.checkpointer = checkpointer or Checkpointer(None, '')
+        self.resume_state = resume_state
         
         # Set random seeds
         torch.manual_seed(self.seed)
@@ -208,11 +320,31 @@ class PySyftTrainer:
         # Setup optimizer with DP-SGD
         optimizer = optim.SGD(model.parameters(), lr=self.learning_rate)
         
+        # A federation round starts from the global model. A checkpoint of
+        # the round, being later, takes precedence.
+        if self.job_config.get('initial_model'):
+            initial = torch.from_numpy(decode_model(self.job_config['initial_model']))
+            expected = sum(p.numel() for p in model.parameters())
+            if initial.numel() != expected:
+                raise ValueError(f'initial model has {initial.numel()} weights, model has {expected}')
+            torch.nn.utils.vector_to_parameters(initial, model.parameters())
+            logger.info("Starting from the federation's global model")
+        
+        # Continue from the checkpoint of a resumed job
+        import io
+        start_epoch = 0
+        if self.resume_state:
+            checkpoint = torch.load(io.BytesIO(self.resume_state))
+            model.load_state_dict(checkpoint['model'])
+            optimizer.load_state_dict(checkpoint['optimizer'])
+            start_epoch = checkpoint['epoch']
+            logger.info(f"Resuming from checkpoint at epoch {start_epoch}")
+        
         # Training loop
         total_loss = 0
         num_batches = 0
         
-        for epoch in range(self.epochs):
+        for epoch in range(start_epoch, self.epochs):
             epoch_loss = 0
             for batch_idx, (data, target) in enumerate(dataloader):
                 optimizer.zero_grad()
@@ -239,6 +371,11 @@ class PySyftTrainer:
                 num_batches += 1
             
             logger.info(f"Epoch {epoch+1}/{self.epochs}, Loss: {epoch_loss/len(dataloader):.4f}")
+            emit_progress(epoch + 1, self.epochs, epoch_loss / len(dataloader), (epoch + 1) * n_samples)
+            
+            buffer = io.BytesIO()
+            torch.save({'epoch': epoch + 1, 'model': model.state_dict(), 'optimizer': optimizer.state_dict()}, buffer)
+            self.checkpointer.save(epoch + 1, (epoch + 1) * n_samples, buffer.getvalue())
         
         # Compute final epsilon
         final_epsilon = self._compute_epsilon(
@@ -258,11 +395,15 @@ class PySyftTrainer:
         
         accuracy = correct / total
         
-        # Serialize model
-        import io
-        model_state = model.state_dict()
-        model_bytes = torch.save(model_state, io.BytesIO()).getvalue()
-        model_str = base64.b64encode(model_bytes).decode('utf-8')
+        # Serialize model. Federation rounds return the flat weights the
+        # coordinator averages; other jobs the state dict.
+        if self.job_config.get('federated'):
+            weights = torch.nn.utils.parameters_to_vector(model.parameters()).detach().numpy()
+            model_str = encode_model(weights)
+        else:
+            buffer = io.BytesIO()
+            torch.save(model.state_dict(), buffer)
+            model_str = base64.b64encode(buffer.getvalue()).decode('utf-8')
         
         logger.info(f"Training completed. Accuracy: {accuracy:.4f}, Epsilon: {final_epsilon:.4f}")
         
@@ -322,14 +463,51 @@ def main():
     parser.add_argument('--user-id', default='default_user', help='User ID for privacy accounting')
     parser.add_argument('--accountant-state', default='privacy_accountant_state.json', 
                        help='Path to privacy accountant state file')
+    parser.add_argument('--job-id', help='Job ID; with it the job is read from arguments instead of stdin')
+    parser.add_argument('--dataset', default='synthetic', help='Dataset to train on')
+    parser.add_argument('--task', default='classification', help='Training task')
+    parser.add_argument('--epsilon', type=float, default=1.0, help='DP epsilon budget of the job')
+    parser.add_argument('--noise-multiplier', type=float, default=0.5,
+                       help='DP-SGD noise multiplier the agent calibrated to the epsilon budget')
+    parser.add_argument('--epochs', type=int, default=10, help='Training epochs')
+    parser.add_argument('--batch-size', type=int, default=32, help='Training batch size')
+    parser.add_argument('--output-dir', help='Directory to also write the artifact to as aggregate.json')
+    parser.add_argument('--checkpoint-dir', help='Directory to write per-epoch checkpoints and their manifest to')
+    parser.add_argument('--resume-from', help='Checkpoint file to resume training from')
+    parser.add_argument('--federated', action='store_true',
+                       help='Train a federation round and write the model as flat float32 weights')
+    parser.add_argument('--initial-model-file',
+                       help='File holding the base64 global model a federation round starts from')
     args = parser.parse_args()
     
-    # Read job configuration from stdin
-    try:
-        job_config = json.loads(sys.stdin.read())
-    except json.JSONDecodeError as e:
-        logger.error(f"Failed to parse job configuration: {e}")
-        sys.exit(1)
+    if args.job_id:
+        job_config = {
+            'job_id': args.job_id,
+            'dataset': args.dataset,
+            'task': args.task,
+            'dp': {'enabled': args.epsilon > 0, 'epsilon': args.epsilon, 'noise_multiplier': args.noise_multiplier},
+            'epochs': args.epochs,
+            'batch_size': args.batch_size,
+            'output_dir': args.output_dir,
+            'checkpoint_dir': args.checkpoint_dir,
+            'resume_from': args.resume_from,
+            'federated': args.federated,
+        }
+        if args.initial_model_file:
+            try:
+                with open(args.initial_model_file) as f:
+                    job_config['initial_model'] = f.read().strip()
+            except OSError as e:
+                logger.error(f"Failed to read initial model: {e}")
+                print(json.dumps({'error': str(e), 'job_id': args.job_id, 'status': 'failed'}))
+                sys.exit(1)
+    else:
+        # Read job configuration from stdin
+        try:
+            job_config = json.loads(sys.stdin.read())
+        except json.JSONDecodeError as e:
+            logger.error(f"Failed to parse job configuration: {e}")
+            sys.exit(1)
     
     job_id = job_config.get('job_id', 'unknown')
     logger.info(f"Starting training job: {job_id}")
@@ -351,14 +529,31 @@ def main():
         sys.exit(1)
     
     # Determine training mode
-    use_mock = args.mock or os.environ.get('MOCK_DP') == '1' or not PYSYFT_AVAILABLE
+    use_mock = args.mock or os.environ.get('MOCK_DP') == '1'
+    if not use_mock and not PYSYFT_AVAILABLE:
+        # Never substitute synthetic results for a real run the operator asked for
+        error_response = {
+            'error': 'PySyft is not available; pass --mock or set MOCK_DP=1 for mock training',
+            'job_id': job_id,
+            'status': 'failed'
+        }
+        print(json.dumps(error_response))
+        sys.exit(1)
+    
+    try:
+        checkpointer = Checkpointer(job_config.get('checkpoint_dir'), job_id)
+        resume_state = load_checkpoint(job_config.get('resume_from'))
+    except (OSError, ValueError) as e:
+        logger.error(f"Failed to load checkpoint: {e}")
+        print(json.dumps({'error': str(e), 'job_id': job_id, 'status': 'failed'}))
+        sys.exit(1)
     
     if use_mock:
         logger.info("Using mock training")
-        trainer = MockTrainer(job_config)
+        trainer = MockTrainer(job_config, checkpointer, resume_state)
     else:
         logger.info("Using PySyft training")
-        trainer = PySyftTrainer(job_config)
+        trainer = PySyftTrainer(job_config, checkpointer, resume_state)
     
     try:
         # Perform training
@@ -399,6 +594,9 @@ def main():
         
         # Output artifact
         print(json.dumps(artifact, indent=2))
+        if job_config.get('output_dir'):
+            with open(os.path.join(job_config['output_dir'], 'aggregate.json'), 'w') as f:
+                json.dump(artifact, f, indent=2)
         logger.info(f"Training job {job_id} completed successfully")
         
     except Exception as e:
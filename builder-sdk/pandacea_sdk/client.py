@@ -5,10 +5,13 @@ Main client for interacting with the Pandacea Agent API.
 import base64
 import json
 import logging
+import hashlib
 import os
+import secrets
+import time
 import requests
 from typing import List, Optional
-from urllib.parse import urljoin
+from urllib.parse import quote, quote_plus, unquote_plus, urljoin
 
 from cryptography.hazmat.primitives import hashes, serialization
 from cryptography.hazmat.primitives.asymmetric import rsa, padding
@@ -21,6 +24,35 @@ from .exceptions import AgentConnectionError, APIResponseError, PandaceaExceptio
 from .models import DataProduct
 from .reliability import with_reliability, get_circuit_breaker
 
+# Request signature scheme, matching the agent's reqsig package
+SIGNATURE_VERSION = 'v2'
+_CANONICAL_PREFIX = 'PANDACEA-REQUEST-V2'
+
+
+def _canonical_query(raw_query: str) -> str:
+    """Re-encode a query string with parameters sorted by key, then value."""
+    pairs = []
+    for part in raw_query.split('&'):
+        if not part:
+            continue
+        key, _, value = part.partition('=')
+        pairs.append((unquote_plus(key), unquote_plus(value)))
+    pairs.sort()
+    return '&'.join(f"{quote_plus(k, safe='~')}={quote_plus(v, safe='~')}" for k, v in pairs)
+
+
+def _canonical_string(method: str, path: str, raw_query: str, body: bytes, timestamp: str, nonce: str) -> bytes:
+    """Return the v2 canonical string the agent verifies a request against."""
+    return '\n'.join([
+        _CANONICAL_PREFIX,
+        method.upper(),
+        path,
+        _canonical_query(raw_query),
+        hashlib.sha256(body).hexdigest(),
+        timestamp,
+        nonce,
+    ]).encode('utf-8')
+
 
 class PandaceaClient:
     """
@@ -186,12 +218,19 @@ class PandaceaClient:
         # Return base64-encoded signature
         return base64.b64encode(signature).decode('ascii')
     
-    def _prepare_headers(self, data: Optional[bytes] = None) -> dict:
+    def _prepare_headers(self, method: str, path: str, body: bytes = b"", query: str = "") -> dict:
         """
         Prepare headers for authenticated requests.
         
+        Requests are signed under the v2 scheme, which covers the method,
+        path, query, body hash, a timestamp and a fresh nonce, so the agent
+        can reject replayed or altered requests.
+        
         Args:
-            data: Request data to sign (if any)
+            method: HTTP method of the request
+            path: URL path of the request
+            body: Exact bytes sent as the request body (if any)
+            query: Raw query string of the request (if any)
             
         Returns:
             Dictionary of headers
@@ -199,12 +238,14 @@ class PandaceaClient:
         headers = {}
         
         if self.peer_id:
+            timestamp = str(int(time.time()))
+            nonce = secrets.token_hex(16)
+            canonical = _canonical_string(method, path, query, body, timestamp, nonce)
             headers['X-Pandacea-Peer-ID'] = self.peer_id
-            
-            if data:
-                # Sign the data and add signature header
-                signature = self._sign_request(data)
-                headers['X-Pandacea-Signature'] = signature
+            headers['X-Pandacea-Signature-Version'] = SIGNATURE_VERSION
+            headers['X-Pandacea-Timestamp'] = timestamp
+            headers['X-Pandacea-Nonce'] = nonce
+            headers['X-Pandacea-Signature'] = self._sign_request(canonical)
         
         return headers
     
@@ -225,10 +266,7 @@ class PandaceaClient:
         """
         url = urljoin(self.base_url, '/api/v1/products')
         
-        # Prepare headers for GET request
-        # For GET requests, we sign a canonical representation
-        canonical_data = f"GET /api/v1/products".encode('utf-8')
-        headers = self._prepare_headers(canonical_data)
+        headers = self._prepare_headers('GET', '/api/v1/products')
         
         # Inject trace headers if available
         if hasattr(self, "_otel_inject") and self._otel_inject:
@@ -342,7 +380,7 @@ class PandaceaClient:
         payload_bytes = payload_json.encode('utf-8')
         
         # Prepare headers with signature
-        headers = self._prepare_headers(payload_bytes)
+        headers = self._prepare_headers('POST', '/api/v1/leases', payload_bytes)
         
         if hasattr(self, "_otel_inject") and self._otel_inject:
             self._otel_inject(headers)
@@ -491,7 +529,7 @@ class PandaceaClient:
         payload_bytes = payload_json.encode('utf-8')
 
         # Prepare headers with signature
-        headers = self._prepare_headers(payload_bytes)
+        headers = self._prepare_headers('POST', '/api/v1/privacy/execute', payload_bytes)
 
         url = urljoin(self.base_url, '/api/v1/privacy/execute')
 
@@ -650,18 +688,22 @@ class PandaceaClient:
             raise PandaceaException(f"Failed to get required stake: {e}")
 
     @with_reliability(circuit_name="raise_dispute")
-    def raise_dispute(self, lease_id: str, reason: str) -> str:
+    def raise_dispute(self, lease_id: str, reason: str, evidence: Optional[List[dict]] = None) -> str:
         """
         Raise a stake-based dispute against an earner for a specific lease with dynamic stake calculation.
         
         This method orchestrates the following:
         1. Get the required stake amount based on lease value and dispute stake rate
         2. Approve PGT tokens for the LeaseAgreement contract
-        3. Call raiseDispute on the LeaseAgreement contract
+        3. If evidence is attached, have the agent pin it to IPFS in a signed bundle
+        4. Call raiseDispute on the LeaseAgreement contract, referencing the bundle's CID
 
         Args:
             lease_id: The on-chain ID of the lease to dispute.
             reason: The reason for the dispute.
+            evidence: Optional evidence items. Each has a 'name' and either
+                'content' (bytes, sent inline) or 'uri' (an ipfs:// or https://
+                reference), plus optional 'mediaType' and 'sha256'.
 
         Returns:
             The dispute ID for tracking the dispute.
@@ -689,6 +731,14 @@ class PandaceaClient:
         if not self.w3 or not self.contract or not self.spender_private_key:
             raise PandaceaException("Web3 connection, contract, or spender private key not available")
         
+        # Pin the evidence before going on chain, so the on-chain reason can
+        # reference the bundle arbitrators will review
+        dispute = None
+        chain_reason = reason
+        if evidence:
+            dispute = self._post_dispute(lease_id, reason, evidence)
+            chain_reason = dispute.get('chainReason') or reason
+        
         try:
             # Convert lease_id to bytes32 format
             lease_id_bytes = self.w3.to_bytes(hexstr=lease_id) if lease_id.startswith('0x') else lease_id.encode()
@@ -696,7 +746,7 @@ class PandaceaClient:
             # Build the raiseDispute transaction (now without stake_amount parameter)
             dispute_txn = self.contract.functions.raiseDispute(
                 lease_id_bytes,
-                reason
+                chain_reason
             ).build_transaction({
                 'from': self.w3.to_checksum_address(self.w3.eth.account.from_key(self.spender_private_key).address),
                 'gas': 200000,
@@ -713,16 +763,41 @@ class PandaceaClient:
             
             if receipt.status == 0:
                 raise PandaceaException(f"Dispute transaction failed: {tx_hash.hex()}")
-            
-            # Also call the API endpoint for off-chain tracking
-            payload = {
-                "reason": reason
-            }
+        except Exception as e:
+            raise PandaceaException(f"Failed to raise dispute: {e}")
+        
+        if dispute is not None:
+            return dispute['disputeId']
+        
+        # Also call the API endpoint for off-chain tracking
+        return self._post_dispute(lease_id, reason)['disputeId']
+
+    def _post_dispute(self, lease_id: str, reason: str, evidence: Optional[List[dict]] = None) -> dict:
+        """
+        Record a dispute with the agent, which pins any evidence to IPFS.
+
+        Returns:
+            The agent's response, with 'disputeId' and, when evidence was
+            attached, 'evidenceCid' and 'chainReason'.
+        """
+        payload = {
+            "reason": reason
+        }
+        if evidence:
+            items = []
+            for item in evidence:
+                item = dict(item)
+                if isinstance(item.get('content'), (bytes, bytearray)):
+                    item['content'] = base64.b64encode(item['content']).decode('ascii')
+                items.append(item)
+            payload["evidence"] = items
 
+        try:
             payload_json = json.dumps(payload, separators=(',', ':'))
             payload_bytes = payload_json.encode('utf-8')
-            headers = self._prepare_headers(payload_bytes)
-            url = urljoin(self.base_url, f'/api/v1/leases/{lease_id}/dispute')
+            path = f'/api/v1/leases/{quote(lease_id, safe="")}/dispute'
+            headers = self._prepare_headers('POST', path, payload_bytes)
+            url = urljoin(self.base_url, path)
 
             response = self.session.post(url, data=payload_bytes, headers=headers, timeout=self.timeout)
             response.raise_for_status()
@@ -735,7 +810,7 @@ class PandaceaClient:
                     response_text=response.text
                 )
             
-            return data['disputeId']
+            return data
             
         except requests.exceptions.ConnectionError as e:
             raise AgentConnectionError(
@@ -758,8 +833,6 @@ class PandaceaClient:
                 f"Request failed: {e}",
                 original_error=e
             )
-        except Exception as e:
-            raise PandaceaException(f"Failed to raise dispute: {e}")
 
     @with_reliability(circuit_name="finalize_lease")
     def finalize_lease(self, lease_id: str) -> str:
@@ -872,9 +945,10 @@ class PandaceaClient:
             PandaceaException: For other errors.
         """
         # Prepare headers with signature
-        headers = self._prepare_headers()
+        path = f'/api/v1/privacy/results/{quote(computation_id, safe="")}'
+        headers = self._prepare_headers('GET', path)
 
-        url = urljoin(self.base_url, f'/api/v1/privacy/results/{computation_id}')
+        url = urljoin(self.base_url, path)
 
         if hasattr(self, "_otel_inject") and self._otel_inject:
             self._otel_inject(headers)
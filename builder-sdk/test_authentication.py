@@ -183,13 +183,16 @@ def test_header_preparation():
         test_data = b'{"productId":"test","maxPrice":"10.50","duration":"24h"}'
         
         # Prepare headers
-        headers = client._prepare_headers(test_data)
+        headers = client._prepare_headers('POST', '/api/v1/leases', test_data)
         
         print(f"Generated headers: {headers}")
         
         # Check required headers
         assert 'X-Pandacea-Peer-ID' in headers, "Peer ID header should be present"
         assert 'X-Pandacea-Signature' in headers, "Signature header should be present"
+        assert headers['X-Pandacea-Signature-Version'] == 'v2', "Requests should be signed under v2"
+        assert headers['X-Pandacea-Timestamp'], "Timestamp header should be present"
+        assert headers['X-Pandacea-Nonce'], "Nonce header should be present"
         
         print(f"Peer ID header: {headers['X-Pandacea-Peer-ID']}")
         print(f"Signature header: {headers['X-Pandacea-Signature'][:20]}...")
@@ -9,6 +9,7 @@ import requests_mock
 from unittest.mock import Mock
 
 from pandacea_sdk import PandaceaClient, DataProduct
+from pandacea_sdk.client import _canonical_query, _canonical_string
 from pandacea_sdk.exceptions import AgentConnectionError, APIResponseError
 
 
@@ -250,4 +251,21 @@ class TestPandaceaClient:
         client = PandaceaClient("http://localhost:8080")
         client.close()
         # The session should be closed (we can't easily test this without mocking)
-        # But at least it shouldn't raise an exception 
\ No newline at end of file
+        # But at least it shouldn't raise an exception 
+
+
+class TestRequestSigning:
+    """Test cases for the v2 canonical request string."""
+
+    def test_canonical_query_matches_agent(self):
+        """Query parameters are sorted and escaped the way the agent's reqsig package does."""
+        assert _canonical_query("b=2&a=x y&a=1&c=%7E&d=a%2Fb*") == "a=1&a=x+y&b=2&c=~&d=a%2Fb%2A"
+        assert _canonical_query("") == ""
+
+    def test_canonical_string(self):
+        """The canonical string covers the method, path, query, body hash, timestamp and nonce."""
+        canonical = _canonical_string("post", "/api/v1/leases", "b=2&a=1", b"", "1700000000", "abcd")
+        assert canonical == (
+            b"PANDACEA-REQUEST-V2\nPOST\n/api/v1/leases\na=1&b=2\n"
+            b"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n1700000000\nabcd"
+        )
@@ -1,46 +1,40 @@
-# PowerShell script to generate Go bindings for LeaseAgreement smart contract
+# PowerShell script to generate the Go bindings of the LeaseAgreement smart
+# contract from its versioned ABIs
 Set-StrictMode -Version Latest
 $ErrorActionPreference = "Stop"
 
 Write-Host "=== Generating Go Bindings with abigen ===" -ForegroundColor Green
 
 # Define paths
-$ABI_PATH = "./LeaseAgreement.abi.json"
-$OUTPUT_PATH = "../agent-backend/internal/contracts/LeaseAgreement.go"
-$PKG_NAME = "contracts"
-$CONTRACT_NAME = "LeaseAgreement"
+$ABI_DIR = "../agent-backend/internal/contracts/abi"
+$BACKEND_DIR = "../agent-backend"
 
-# Check if abigen is installed
+# Check if Go is installed; abigen is run through go generate
 try {
-    $null = Get-Command abigen -ErrorAction Stop
+    $null = Get-Command go -ErrorAction Stop
 } catch {
-    Write-Host "abigen could not be found. Please install it first." -ForegroundColor Red
-    Write-Host "Run: go install github.com/ethereum/go-ethereum/cmd/abigen@latest" -ForegroundColor Yellow
+    Write-Host "go could not be found. Please install it first." -ForegroundColor Red
     exit 1
 }
 
-# Check if ABI file exists
-if (!(Test-Path $ABI_PATH)) {
-    Write-Host "ABI file not found at: $ABI_PATH" -ForegroundColor Red
-    Write-Host "Please ensure the LeaseAgreement.abi.json file exists." -ForegroundColor Yellow
+# Check the versioned ABIs exist
+if (!(Test-Path "$ABI_DIR/LeaseAgreement.v*.json")) {
+    Write-Host "No versioned ABI found in: $ABI_DIR" -ForegroundColor Red
+    Write-Host "Run generate_bindings.sh to build the contract and write its ABI." -ForegroundColor Yellow
     exit 1
 }
 
-# Create the output directory if it doesn't exist
-$outputDir = Split-Path $OUTPUT_PATH -Parent
-if (!(Test-Path $outputDir)) {
-    New-Item -ItemType Directory -Path $outputDir -Force | Out-Null
-    Write-Host "Created output directory: $outputDir" -ForegroundColor Green
-}
-
-# Generate the Go bindings using the ABI file
+# Generate one binding per ABI version
 try {
-    abigen --abi $ABI_PATH --pkg $PKG_NAME --type $CONTRACT_NAME --out $OUTPUT_PATH
-    
-    Write-Host "Go bindings generated successfully at: $OUTPUT_PATH" -ForegroundColor Green
-    Write-Host "Package: $PKG_NAME" -ForegroundColor Cyan
-    Write-Host "Contract: $CONTRACT_NAME" -ForegroundColor Cyan
+    Push-Location $BACKEND_DIR
+    go generate ./internal/contracts
+    if ($LASTEXITCODE -ne 0) { throw "go generate exited with $LASTEXITCODE" }
+
+    Write-Host "Go bindings generated successfully in: $BACKEND_DIR/internal/contracts" -ForegroundColor Green
+    Get-ChildItem "$ABI_DIR/LeaseAgreement.v*.json" | ForEach-Object { Write-Host "ABI: $($_.Name)" -ForegroundColor Cyan }
 } catch {
     Write-Host "Failed to generate Go bindings: $_" -ForegroundColor Red
     exit 1
-} 
\ No newline at end of file
+} finally {
+    Pop-Location
+}
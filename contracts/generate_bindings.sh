@@ -7,29 +7,44 @@ cd "$(dirname "$0")"
 echo "=== 1. Compiling Smart Contracts with Foundry ==="
 forge build
 
-echo "=== 2. Generating Go Bindings with abigen ==="
+echo "=== 2. Writing the versioned ABI ==="
 
 # Define paths
-ABI_PATH="./out/LeaseAgreement.sol/LeaseAgreement.json"
-OUTPUT_PATH="../agent-backend/internal/contracts/LeaseAgreement.go"
-PKG_NAME="contracts"
-CONTRACT_NAME="LeaseAgreement"
-
-# Check if abigen is installed
-if ! command -v abigen &> /dev/null
-then
-    echo "abigen could not be found. Please install it first."
-    echo "Run: go install github.com/ethereum/go-ethereum/cmd/abigen@latest"
+ARTIFACT_PATH="./out/LeaseAgreement.sol/LeaseAgreement.json"
+ABI_DIR="../agent-backend/internal/contracts/abi"
+
+# The interface version is the contract's VERSION constant
+VERSION=$(sed -n 's/.*uint256 public constant VERSION = \([0-9]*\);.*/\1/p' src/LeaseAgreement.sol)
+if [ -z "$VERSION" ]; then
+    echo "VERSION constant not found in src/LeaseAgreement.sol"
     exit 1
 fi
+ABI_PATH="$ABI_DIR/LeaseAgreement.v$VERSION.json"
+
+# Each version's binding lives in the same Go package, so the Lease struct
+# is renamed after the version's binding type to keep them apart
+TYPE_NAME="LeaseAgreementV$VERSION"
+mkdir -p "$ABI_DIR"
+jq --arg type "struct $TYPE_NAME.Lease" \
+    '.abi | walk(if type == "object" and .internalType == "struct LeaseAgreement.Lease" then .internalType = $type else . end)' \
+    "$ARTIFACT_PATH" > "$ABI_PATH"
+
+echo "=== 3. Exporting the test environment's contract artifacts ==="
+
+# The test environment deploys the compiled contracts on its simulated chain
+TESTENV_DIR="../agent-backend/testenv/artifacts"
+for CONTRACT in LeaseAgreement Reputation PGT; do
+    jq '{abi: .abi, bytecode: .bytecode.object}' \
+        "./out/$CONTRACT.sol/$CONTRACT.json" > "$TESTENV_DIR/$CONTRACT.json"
+done
+
+echo "=== 4. Generating Go Bindings with abigen ==="
+
+if ! grep -q "LeaseAgreement.v$VERSION.json" ../agent-backend/internal/contracts/generate.go; then
+    echo "Add a go:generate line for $ABI_PATH to agent-backend/internal/contracts/generate.go"
+    echo "and version $VERSION to versions in agent-backend/internal/contracts/probe.go"
+    exit 1
+fi
+(cd ../agent-backend && go generate ./internal/contracts)
 
-# Create the output directory if it doesn't exist
-mkdir -p "$(dirname "$OUTPUT_PATH")"
-
-# Extract the ABI from the Foundry output file
-ABI=$(jq -r '.abi | tojson' $ABI_PATH)
-
-# Generate the Go bindings
-abigen --abi - --pkg "$PKG_NAME" --type "$CONTRACT_NAME" --out "$OUTPUT_PATH" <<< "$ABI"
-
-echo "✅ Go bindings generated successfully at: $OUTPUT_PATH" 
\ No newline at end of file
+echo "✅ Go bindings generated successfully for LeaseAgreement v$VERSION"
@@ -38,6 +38,10 @@ interface ILeaseAgreement {
  */
 contract LeaseAgreement is ILeaseAgreement, ReentrancyGuard, Ownable {
     
+    // Interface version, read by agents at startup to pick their binding.
+    // Bump it whenever the ABI changes.
+    uint256 public constant VERSION = 2;
+    
     // Dynamic Minimum Pricing (DMP) - minimum price required for lease creation
     uint256 public constant MIN_PRICE = 0.001 ether; // 0.001 ETH minimum price
     